@@ -0,0 +1,608 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+const (
+	// DefaultGrantTTL is how long an access grant lasts when the issuer
+	// does not say
+	DefaultGrantTTL = 24 * time.Hour
+
+	// shardFlagRestricted marks a stored shard as fetchable only by its
+	// owner and holders of the owner's grants. The owner's ownership claim
+	// follows it.
+	shardFlagRestricted byte = 1
+
+	maxGrantSize  = 8 * 1024
+	revokeTimeout = 10 * time.Second
+)
+
+// errAccessDenied is returned when a peer may not fetch or store a shard
+var errAccessDenied = errors.New("access denied")
+
+// AccessGrant is a capability token letting a peer fetch the shards of a
+// restricted file. The file's owner signs it with its libp2p identity key,
+// and holders check it against the owner recorded when the shards were
+// stored. Fetchers send it along with each shard request.
+type AccessGrant struct {
+	ID        string `json:"id"`
+	FileHash  string `json:"fileHash"`
+	Grantee   string `json:"grantee,omitempty"` // Peer ID allowed to use it; empty = any bearer
+	Issuer    string `json:"issuer"`            // Owner's peer ID
+	PublicKey []byte `json:"publicKey"`
+	IssuedAt  int64  `json:"issuedAt"` // Unix seconds
+	Expires   int64  `json:"expires"`  // Unix seconds
+	Signature []byte `json:"signature"`
+}
+
+// signingBytes is the grant's signed content
+func (g AccessGrant) signingBytes() []byte {
+	g.Signature = nil
+	data, _ := json.Marshal(g)
+	return data
+}
+
+// Expired reports whether the grant is past its validity
+func (g *AccessGrant) Expired() bool {
+	return time.Now().Unix() >= g.Expires
+}
+
+// Token encodes the grant for handing to the grantee
+func (g *AccessGrant) Token() (string, error) {
+	data, err := json.Marshal(g)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// ParseAccessGrant decodes a grant token and checks its signature
+func ParseAccessGrant(token string) (*AccessGrant, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("invalid grant token: %w", err)
+	}
+	return decodeAccessGrant(data)
+}
+
+// decodeAccessGrant parses a grant and checks that its issuer signed it.
+// It does not check expiry or revocation.
+func decodeAccessGrant(data []byte) (*AccessGrant, error) {
+	if len(data) > maxGrantSize {
+		return nil, fmt.Errorf("grant is larger than %d bytes", maxGrantSize)
+	}
+	var g AccessGrant
+	if err := json.Unmarshal(data, &g); err != nil {
+		return nil, fmt.Errorf("invalid grant: %w", err)
+	}
+	issuer, err := peer.Decode(g.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("invalid grant issuer: %w", err)
+	}
+	pub, err := crypto.UnmarshalPublicKey(g.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid grant public key: %w", err)
+	}
+	if !issuer.MatchesPublicKey(pub) {
+		return nil, fmt.Errorf("grant public key does not match issuer %s", shortPeerID(issuer))
+	}
+	valid, err := pub.Verify(g.signingBytes(), g.Signature)
+	if err != nil || !valid {
+		return nil, fmt.Errorf("invalid signature on grant %s", g.ID)
+	}
+	return &g, nil
+}
+
+// ownershipClaim proves which peer owns a restricted file. A restricted
+// file's hash is bound to its owner (see ownedFileHash), and the owner
+// signs the claim with its libp2p identity key, so no other peer can claim
+// the file however early it learns the hash. It travels with each
+// restricted store request.
+type ownershipClaim struct {
+	FileHash  string `json:"fileHash"`
+	Base      string `json:"base"`  // Hash the file hash was derived from
+	Owner     string `json:"owner"` // Owner's peer ID
+	PublicKey []byte `json:"publicKey"`
+	Signature []byte `json:"signature"`
+}
+
+// signingBytes is the claim's signed content
+func (c ownershipClaim) signingBytes() []byte {
+	c.Signature = nil
+	data, _ := json.Marshal(c)
+	return data
+}
+
+// ownedFileHash derives the hash a restricted file is stored under from
+// base, binding it to its owner
+func ownedFileHash(base string, owner peer.ID) string {
+	sum := sha256.Sum256([]byte(base + string(owner)))
+	return hex.EncodeToString(sum[:])
+}
+
+// decodeOwnershipClaim parses a claim and checks that its owner signed it
+// and that the file hash is bound to the owner
+func decodeOwnershipClaim(data []byte) (*ownershipClaim, error) {
+	if len(data) > maxGrantSize {
+		return nil, fmt.Errorf("ownership claim is larger than %d bytes", maxGrantSize)
+	}
+	var c ownershipClaim
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("invalid ownership claim: %w", err)
+	}
+	owner, err := peer.Decode(c.Owner)
+	if err != nil {
+		return nil, fmt.Errorf("invalid claim owner: %w", err)
+	}
+	pub, err := crypto.UnmarshalPublicKey(c.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid claim public key: %w", err)
+	}
+	if !owner.MatchesPublicKey(pub) {
+		return nil, fmt.Errorf("claim public key does not match owner %s", shortPeerID(owner))
+	}
+	if c.FileHash != ownedFileHash(c.Base, owner) {
+		return nil, fmt.Errorf("%s is not bound to %s", c.FileHash, shortPeerID(owner))
+	}
+	valid, err := pub.Verify(c.signingBytes(), c.Signature)
+	if err != nil || !valid {
+		return nil, fmt.Errorf("invalid signature on ownership claim for %s", c.FileHash)
+	}
+	return &c, nil
+}
+
+// IssuedGrant is a grant this node issued, as listed to its user
+type IssuedGrant struct {
+	AccessGrant
+	Revoked bool `json:"revoked"`
+}
+
+// AccessControl keeps the node's side of shard access control in each of
+// its roles. As an owner it marks its uploads restricted, remembers which
+// peers hold them, and issues and revokes grants. As a fetcher it keeps
+// grants it was given. As a holder it records who owns the restricted
+// shards it stores, checks grants on fetches, and applies revocations from
+// owners. Access control is enforced over libp2p only.
+type AccessControl struct {
+	host host.Host
+	path string // Empty keeps state in memory only
+
+	protected map[string][]string        // File hash -> peer IDs holding shards of this node's restricted upload
+	claims    map[string]*ownershipClaim // File hash -> claim on this node's restricted upload
+	issued    map[string]*IssuedGrant    // Grant ID -> grant this node issued
+	held      map[string]*AccessGrant    // File hash -> grant this node fetches with
+	owners    map[string]string          // File hash -> owner of restricted shards stored here
+	revoked   map[string]int64           // Grant ID -> expiry of a grant revoked by its owner
+	mu        sync.Mutex
+}
+
+// accessState is AccessControl's persisted form
+type accessState struct {
+	Protected map[string][]string        `json:"protected"`
+	Claims    map[string]*ownershipClaim `json:"claims"`
+	Issued    map[string]*IssuedGrant    `json:"issued"`
+	Held      map[string]*AccessGrant    `json:"held"`
+	Owners    map[string]string          `json:"owners"`
+	Revoked   map[string]int64           `json:"revoked"`
+}
+
+// NewAccessControl creates the access control state of a node
+func NewAccessControl(h host.Host) *AccessControl {
+	return &AccessControl{
+		host:      h,
+		protected: make(map[string][]string),
+		claims:    make(map[string]*ownershipClaim),
+		issued:    make(map[string]*IssuedGrant),
+		held:      make(map[string]*AccessGrant),
+		owners:    make(map[string]string),
+		revoked:   make(map[string]int64),
+	}
+}
+
+// SetPath persists access control state to path and loads any already there
+func (ac *AccessControl) SetPath(path string) error {
+	var state accessState
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &state); err != nil {
+			return fmt.Errorf("invalid access control file: %w", err)
+		}
+	}
+
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	ac.path = path
+	for k, v := range state.Protected {
+		ac.protected[k] = v
+	}
+	for k, v := range state.Claims {
+		ac.claims[k] = v
+	}
+	for k, v := range state.Issued {
+		ac.issued[k] = v
+	}
+	for k, v := range state.Held {
+		ac.held[k] = v
+	}
+	for k, v := range state.Owners {
+		ac.owners[k] = v
+	}
+	for k, v := range state.Revoked {
+		ac.revoked[k] = v
+	}
+	if len(state.Issued) > 0 || len(state.Owners) > 0 {
+		log.Printf("🔐 [ACCESS] Loaded %d issued grants and %d restricted files from %s", len(state.Issued), len(state.Owners), path)
+	}
+	return nil
+}
+
+// Protect marks a file this node is about to upload as restricted, so its
+// shards are stored for fetching by this node and its grantees only. It
+// returns the hash to store the file under, baseHash bound to this node.
+func (ac *AccessControl) Protect(baseHash string) (string, error) {
+	fileHash := ownedFileHash(baseHash, ac.host.ID())
+	ac.mu.Lock()
+	_, ok := ac.claims[fileHash]
+	ac.mu.Unlock()
+	if ok {
+		return fileHash, nil
+	}
+
+	pub, err := crypto.MarshalPublicKey(ac.host.Peerstore().PubKey(ac.host.ID()))
+	if err != nil {
+		return "", err
+	}
+	c := &ownershipClaim{FileHash: fileHash, Base: baseHash, Owner: ac.host.ID().String(), PublicKey: pub}
+	if c.Signature, err = ac.host.Peerstore().PrivKey(ac.host.ID()).Sign(c.signingBytes()); err != nil {
+		return "", fmt.Errorf("failed to sign ownership claim: %w", err)
+	}
+
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	if _, ok := ac.protected[fileHash]; !ok {
+		ac.protected[fileHash] = []string{}
+	}
+	ac.claims[fileHash] = c
+	ac.saveLocked()
+	return fileHash, nil
+}
+
+// claimFor returns the encoded ownership claim sent with stores of a
+// restricted upload of this node, or nil if fileHash is not one
+func (ac *AccessControl) claimFor(fileHash string) []byte {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	c, ok := ac.claims[fileHash]
+	if !ok {
+		return nil
+	}
+	data, _ := json.Marshal(c)
+	return data
+}
+
+// Restricted reports whether fileHash is a restricted upload of this node
+func (ac *AccessControl) Restricted(fileHash string) bool {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	_, ok := ac.protected[fileHash]
+	return ok
+}
+
+// AddHolders records peers holding shards of a restricted upload, which
+// revocations are sent to
+func (ac *AccessControl) AddHolders(fileHash string, holders []peer.ID) {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	known, ok := ac.protected[fileHash]
+	if !ok {
+		return
+	}
+	for _, p := range holders {
+		if !slices.Contains(known, p.String()) {
+			known = append(known, p.String())
+		}
+	}
+	ac.protected[fileHash] = known
+	ac.saveLocked()
+}
+
+// Grant issues a grant to fetch a restricted upload of this node. An empty
+// grantee makes a bearer grant usable by any peer holding the token.
+func (ac *AccessControl) Grant(fileHash, grantee string, ttl time.Duration) (*AccessGrant, error) {
+	if !ac.Restricted(fileHash) {
+		return nil, fmt.Errorf("%s is not a restricted upload of this node", fileHash)
+	}
+	if grantee != "" {
+		if _, err := peer.Decode(grantee); err != nil {
+			return nil, fmt.Errorf("invalid grantee %q: %w", grantee, err)
+		}
+	}
+	if ttl <= 0 {
+		ttl = DefaultGrantTTL
+	}
+	pub, err := crypto.MarshalPublicKey(ac.host.Peerstore().PubKey(ac.host.ID()))
+	if err != nil {
+		return nil, err
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	g := &AccessGrant{
+		ID:        hex.EncodeToString(id),
+		FileHash:  fileHash,
+		Grantee:   grantee,
+		Issuer:    ac.host.ID().String(),
+		PublicKey: pub,
+		IssuedAt:  now.Unix(),
+		Expires:   now.Add(ttl).Unix(),
+	}
+	if g.Signature, err = ac.host.Peerstore().PrivKey(ac.host.ID()).Sign(g.signingBytes()); err != nil {
+		return nil, fmt.Errorf("failed to sign grant: %w", err)
+	}
+
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	ac.issued[g.ID] = &IssuedGrant{AccessGrant: *g}
+	ac.saveLocked()
+	log.Printf("🔐 [ACCESS] Granted %s access to %s until %s", grantDescription(g), fileHash, time.Unix(g.Expires, 0).Format(time.RFC3339))
+	return g, nil
+}
+
+// Revoke revokes a grant this node issued and tells the holders of the
+// file's shards, returning how many acknowledged. Holders that were not
+// reached keep honouring the grant until it expires.
+func (ac *AccessControl) Revoke(ctx context.Context, grantID string) (int, error) {
+	ac.mu.Lock()
+	g, ok := ac.issued[grantID]
+	if !ok {
+		ac.mu.Unlock()
+		return 0, fmt.Errorf("no grant %s issued by this node", grantID)
+	}
+	g.Revoked = true
+	ac.revoked[grantID] = g.Expires
+	holders := append([]string(nil), ac.protected[g.FileHash]...)
+	ac.saveLocked()
+	ac.mu.Unlock()
+
+	req := appendRPCString(nil, grantID)
+	req = appendRPCString(req, g.FileHash)
+	req = binary.BigEndian.AppendUint64(req, uint64(g.Expires))
+
+	var reached int
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	for _, h := range holders {
+		pid, err := peer.Decode(h)
+		if err != nil || pid == ac.host.ID() {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, revokeTimeout)
+			defer cancel()
+			rs, err := openRPCStream(ctx, ac.host, pid)
+			if err == nil {
+				_, err = rs.call(rpcMsgRevokeGrant, req, rpcMsgRevokeGrantAck)
+				rs.Close()
+			}
+			if err != nil {
+				log.Printf("⚠️  [ACCESS] Failed to tell %s about revoked grant %s: %v", shortPeerID(pid), grantID, err)
+				return
+			}
+			mu.Lock()
+			reached++
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	log.Printf("🔐 [ACCESS] Revoked grant %s on %s: %d of %d holders told", grantID, g.FileHash, reached, len(holders))
+	return reached, nil
+}
+
+// ListGrants returns the grants this node issued for fileHash, or for
+// every file when fileHash is empty, oldest first
+func (ac *AccessControl) ListGrants(fileHash string) []IssuedGrant {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	var grants []IssuedGrant
+	for _, g := range ac.issued {
+		if fileHash == "" || g.FileHash == fileHash {
+			grants = append(grants, *g)
+		}
+	}
+	sort.Slice(grants, func(i, j int) bool {
+		if grants[i].IssuedAt != grants[j].IssuedAt {
+			return grants[i].IssuedAt < grants[j].IssuedAt
+		}
+		return grants[i].ID < grants[j].ID
+	})
+	return grants
+}
+
+// AddGrant keeps a grant token this node was given, to present when
+// fetching shards of its file
+func (ac *AccessControl) AddGrant(token string) (*AccessGrant, error) {
+	g, err := ParseAccessGrant(token)
+	if err != nil {
+		return nil, err
+	}
+	if g.Expired() {
+		return nil, fmt.Errorf("grant %s has expired", g.ID)
+	}
+	if g.Grantee != "" && g.Grantee != ac.host.ID().String() {
+		return nil, fmt.Errorf("grant %s is for another peer", g.ID)
+	}
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	if old, ok := ac.held[g.FileHash]; !ok || old.Expired() || g.Expires > old.Expires {
+		ac.held[g.FileHash] = g
+		ac.saveLocked()
+	}
+	return g, nil
+}
+
+// grantFor returns the encoded grant this node fetches fileHash with, or
+// nil if it has none
+func (ac *AccessControl) grantFor(fileHash string) []byte {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	g, ok := ac.held[fileHash]
+	if !ok || g.Expired() {
+		return nil
+	}
+	data, _ := json.Marshal(g)
+	return data
+}
+
+// admitStore checks that a peer may store a shard of fileHash here. A
+// restricted shard comes with its owner's ownership claim, and the owner
+// it names is recorded. Once a file is restricted, only its owner can
+// store more of it.
+func (ac *AccessControl) admitStore(fileHash string, from peer.ID, claim []byte) error {
+	owner := ""
+	if claim != nil {
+		c, err := decodeOwnershipClaim(claim)
+		if err != nil {
+			return fmt.Errorf("%w: %v", errAccessDenied, err)
+		}
+		if c.FileHash != fileHash {
+			return fmt.Errorf("%w: ownership claim is for another file", errAccessDenied)
+		}
+		if c.Owner != from.String() {
+			return fmt.Errorf("%w: %s is owned by another peer", errAccessDenied, fileHash)
+		}
+		owner = c.Owner
+	}
+
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	recorded, owned := ac.owners[fileHash]
+	if owned && recorded != owner {
+		return fmt.Errorf("%w: %s is restricted to another owner", errAccessDenied, fileHash)
+	}
+	if owner != "" && !owned {
+		ac.owners[fileHash] = owner
+		ac.saveLocked()
+	}
+	return nil
+}
+
+// authorize checks that a peer may fetch a shard of fileHash stored here,
+// given the grant it sent, if any
+func (ac *AccessControl) authorize(fileHash string, requester peer.ID, grantData []byte) error {
+	ac.mu.Lock()
+	owner, restricted := ac.owners[fileHash]
+	ac.mu.Unlock()
+	if !restricted || requester.String() == owner {
+		return nil
+	}
+	if len(grantData) == 0 {
+		return fmt.Errorf("%w: %s requires an access grant", errAccessDenied, fileHash)
+	}
+	g, err := decodeAccessGrant(grantData)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errAccessDenied, err)
+	}
+	switch {
+	case g.FileHash != fileHash:
+		return fmt.Errorf("%w: grant is for another file", errAccessDenied)
+	case g.Issuer != owner:
+		return fmt.Errorf("%w: grant was not issued by the owner", errAccessDenied)
+	case g.Grantee != "" && g.Grantee != requester.String():
+		return fmt.Errorf("%w: grant is for another peer", errAccessDenied)
+	case g.Expired():
+		return fmt.Errorf("%w: grant has expired", errAccessDenied)
+	}
+	ac.mu.Lock()
+	_, revoked := ac.revoked[g.ID]
+	ac.mu.Unlock()
+	if revoked {
+		return fmt.Errorf("%w: grant has been revoked", errAccessDenied)
+	}
+	return nil
+}
+
+// applyRevocation records a revocation sent by a peer, which must own the
+// file the grant is for
+func (ac *AccessControl) applyRevocation(from peer.ID, grantID, fileHash string, expires int64) error {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	if owner, ok := ac.owners[fileHash]; !ok || owner != from.String() {
+		return fmt.Errorf("%w: %s does not own %s", errAccessDenied, shortPeerID(from), fileHash)
+	}
+	// Expired grants fail anyway, so their revocations can go
+	now := time.Now().Unix()
+	for id, exp := range ac.revoked {
+		if exp <= now {
+			delete(ac.revoked, id)
+		}
+	}
+	if expires > now {
+		ac.revoked[grantID] = expires
+	}
+	ac.saveLocked()
+	return nil
+}
+
+func (ac *AccessControl) saveLocked() {
+	if ac.path == "" {
+		return
+	}
+	data, err := json.MarshalIndent(accessState{
+		Protected: ac.protected,
+		Claims:    ac.claims,
+		Issued:    ac.issued,
+		Held:      ac.held,
+		Owners:    ac.owners,
+		Revoked:   ac.revoked,
+	}, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(ac.path), 0700)
+	}
+	if err == nil {
+		err = os.WriteFile(ac.path+".tmp", data, 0600)
+	}
+	if err == nil {
+		err = os.Rename(ac.path+".tmp", ac.path)
+	}
+	if err != nil {
+		log.Printf("⚠️  [ACCESS] Failed to save access control state: %v", err)
+	}
+}
+
+// grantDescription names who a grant is for, for logs
+func grantDescription(g *AccessGrant) string {
+	if g.Grantee == "" {
+		return "bearer"
+	}
+	if p, err := peer.Decode(g.Grantee); err == nil {
+		return shortPeerID(p)
+	}
+	return g.Grantee
+}
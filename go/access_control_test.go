@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestAccessGrantsGateRestrictedShards(t *testing.T) {
+	var nodes []*LibP2PPangeaNode
+	for i, port := range []int{12640, 12641, 12642} {
+		n, err := NewLibP2PPangeaNodeWithOptions(uint32(641+i), NewNodeStore(), false, true, port)
+		if err != nil {
+			t.Fatalf("failed to create node %d: %v", i, err)
+		}
+		defer n.cancel()
+		nodes = append(nodes, n)
+	}
+	owner, holder, fetcher := nodes[0], nodes[1], nodes[2]
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	for _, n := range []*LibP2PPangeaNode{owner, fetcher} {
+		if err := n.host.Connect(ctx, peer.AddrInfo{ID: holder.host.ID(), Addrs: holder.host.Addrs()}); err != nil {
+			t.Fatalf("connect to holder failed: %v", err)
+		}
+	}
+	if _, err := owner.access.Grant("file", "", 0); err == nil {
+		t.Fatal("expected a grant on an unrestricted file to be refused")
+	}
+	file, err := owner.access.Protect("file")
+	if err != nil {
+		t.Fatalf("Protect failed: %v", err)
+	}
+	push := func(from *LibP2PPangeaNode, data, claim []byte) byte {
+		rs, err := openRPCStream(ctx, from.host, holder.host.ID())
+		if err != nil {
+			t.Fatalf("open stream failed: %v", err)
+		}
+		defer rs.Close()
+		ack, err := pushShardStream(rs, file, 0, data, claim)
+		if err != nil || len(ack) == 0 {
+			t.Fatalf("push failed: %v", err)
+		}
+		return ack[0]
+	}
+	fetch := func(from *LibP2PPangeaNode) ([]byte, error) {
+		rs, err := openRPCStream(ctx, from.host, holder.host.ID())
+		if err != nil {
+			t.Fatalf("open stream failed: %v", err)
+		}
+		defer rs.Close()
+		return fetchShardStream(rs, file, 0, from.access.grantFor(file))
+	}
+
+	// Knowing the hash early lets no other peer claim the file: its own
+	// claim is bound to another hash and the owner's names the owner
+	squatter, err := fetcher.access.Protect("file")
+	if err != nil || squatter == file {
+		t.Fatalf("expected the file hash bound to its owner, got %s (%v)", squatter, err)
+	}
+	if status := push(fetcher, []byte("squat"), fetcher.access.claimFor(squatter)); status != shardAckForbidden {
+		t.Fatalf("expected a claim for another file refused, got status %d", status)
+	}
+	if status := push(fetcher, []byte("squat"), owner.access.claimFor(file)); status != shardAckForbidden {
+		t.Fatalf("expected a replayed claim refused, got status %d", status)
+	}
+	forged := owner.access.claimFor(file)
+	forged[len(forged)-8] ^= 1
+	if status := push(owner, []byte("forged"), forged); status != shardAckForbidden {
+		t.Fatalf("expected a tampered claim refused, got status %d", status)
+	}
+
+	data := []byte("restricted shard")
+	if status := push(owner, data, owner.access.claimFor(file)); status != shardAckStored {
+		t.Fatalf("expected the owner's shard stored, got status %d", status)
+	}
+	owner.access.AddHolders(file, []peer.ID{holder.host.ID()})
+	if status := push(fetcher, []byte("overwrite"), nil); status != shardAckForbidden {
+		t.Fatalf("expected another peer's shard refused, got status %d", status)
+	}
+
+	if got, err := fetch(owner); err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("owner fetch failed: %v", err)
+	}
+	if _, err := fetch(fetcher); err == nil {
+		t.Fatal("expected a fetch without a grant to be refused")
+	}
+
+	// A grant for another peer is refused by both the grantee and holders
+	other, err := owner.access.Grant(file, owner.host.ID().String(), time.Hour)
+	if err != nil {
+		t.Fatalf("Grant failed: %v", err)
+	}
+	otherToken, _ := other.Token()
+	if _, err := fetcher.access.AddGrant(otherToken); err == nil {
+		t.Fatal("expected a grant for another peer to be refused")
+	}
+
+	grant, err := owner.access.Grant(file, fetcher.host.ID().String(), time.Hour)
+	if err != nil {
+		t.Fatalf("Grant failed: %v", err)
+	}
+	token, err := grant.Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fetcher.access.AddGrant(token[:len(token)-4] + "AAAA"); err == nil {
+		t.Fatal("expected a tampered token to be refused")
+	}
+	if _, err := fetcher.access.AddGrant(token); err != nil {
+		t.Fatalf("AddGrant failed: %v", err)
+	}
+	if got, err := fetch(fetcher); err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("granted fetch failed: %v", err)
+	}
+
+	reached, err := owner.access.Revoke(ctx, grant.ID)
+	if err != nil || reached != 1 {
+		t.Fatalf("expected the holder told of the revocation, got %d (%v)", reached, err)
+	}
+	if _, err := fetch(fetcher); err == nil {
+		t.Fatal("expected a revoked grant to be refused")
+	}
+	grants := owner.access.ListGrants(file)
+	if len(grants) != 2 {
+		t.Fatalf("expected two grants, got %+v", grants)
+	}
+	for _, g := range grants {
+		if g.Revoked != (g.ID == grant.ID) {
+			t.Fatalf("expected only the fetcher's grant revoked, got %+v", grants)
+		}
+	}
+
+	// Only the owner can revoke grants on its file
+	if err := holder.access.applyRevocation(fetcher.host.ID(), other.ID, file, other.Expires); err == nil {
+		t.Fatal("expected a revocation from a non-owner to be refused")
+	}
+}
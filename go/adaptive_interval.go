@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Adaptive ticker bounds for the discovery and status loops
+const (
+	DefaultDiscoveryInterval    = 30 * time.Second
+	MinDiscoveryInterval        = 10 * time.Second
+	MaxDiscoveryInterval        = 5 * time.Minute
+	DefaultStatusInterval       = 15 * time.Second
+	MinStatusInterval           = 5 * time.Second
+	MaxStatusInterval           = 2 * time.Minute
+	DefaultTargetPeerCount      = 5   // Below this many peers discovery stays fast
+	intervalBackoffFactor       = 1.5 // Growth per stable round
+	unhealthyPacketLossFraction = 0.2 // A peer losing more probes than this is unhealthy
+)
+
+// AdaptiveInterval is a loop period that grows while the network is stable
+// and drops to its minimum when something changes
+type AdaptiveInterval struct {
+	min     time.Duration
+	max     time.Duration
+	current time.Duration
+	wake    chan struct{}
+	mu      sync.Mutex
+}
+
+// NewAdaptiveInterval creates an interval starting at initial, bounded by
+// minimum and maximum
+func NewAdaptiveInterval(minimum, initial, maximum time.Duration) *AdaptiveInterval {
+	return &AdaptiveInterval{
+		min:     minimum,
+		max:     maximum,
+		current: initial,
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// Current returns the interval until the next round
+func (a *AdaptiveInterval) Current() time.Duration {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.current
+}
+
+// Relax backs the interval off after a stable round
+func (a *AdaptiveInterval) Relax() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.current = min(time.Duration(float64(a.current)*intervalBackoffFactor), a.max)
+}
+
+// Tighten drops the interval to its minimum
+func (a *AdaptiveInterval) Tighten() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.current = a.min
+}
+
+// Nudge tightens the interval and ends a pending Wait early, so the loop
+// reacts to an event such as a disconnect right away
+func (a *AdaptiveInterval) Nudge() {
+	a.Tighten()
+	select {
+	case a.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Wait blocks for the current interval or until nudged. Returns false when
+// ctx is cancelled.
+func (a *AdaptiveInterval) Wait(ctx context.Context) bool {
+	timer := time.NewTimer(a.Current())
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+	case <-a.wake:
+	}
+	return true
+}
+
+// adjust relaxes the interval when the peer set is unchanged since the last
+// round, at least target peers are connected and none of them is losing
+// probes, and tightens it otherwise. Returns the current peer set for the
+// next round.
+func (a *AdaptiveInterval) adjust(prev map[peer.ID]bool, peers []peer.ID, target int, quality func(peer.ID) (PeerQuality, bool)) map[peer.ID]bool {
+	current := make(map[peer.ID]bool, len(peers))
+	stable := len(peers) >= target && len(peers) == len(prev)
+	for _, p := range peers {
+		current[p] = true
+		if !prev[p] {
+			stable = false
+		}
+		if q, ok := quality(p); ok && q.PacketLoss > unhealthyPacketLossFraction {
+			stable = false
+		}
+	}
+
+	if stable {
+		a.Relax()
+	} else {
+		a.Tighten()
+	}
+	return current
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestAdaptiveIntervalBacksOffWhileStable(t *testing.T) {
+	a := NewAdaptiveInterval(10*time.Second, 30*time.Second, time.Minute)
+	noQuality := func(peer.ID) (PeerQuality, bool) { return PeerQuality{}, false }
+	peers := []peer.ID{peer.ID("a"), peer.ID("b")}
+
+	// The first round has nothing to compare against
+	known := a.adjust(nil, peers, 2, noQuality)
+	if a.Current() != 10*time.Second {
+		t.Fatalf("expected minimum after a changed peer set, got %v", a.Current())
+	}
+
+	known = a.adjust(known, peers, 2, noQuality)
+	if a.Current() != 15*time.Second {
+		t.Fatalf("expected backoff to 15s, got %v", a.Current())
+	}
+	for range 5 {
+		known = a.adjust(known, peers, 2, noQuality)
+	}
+	if a.Current() != time.Minute {
+		t.Fatalf("expected backoff capped at 1m, got %v", a.Current())
+	}
+
+	// Below the target peer count discovery speeds up again
+	known = a.adjust(known, peers[:1], 2, noQuality)
+	if a.Current() != 10*time.Second {
+		t.Fatalf("expected minimum below target peer count, got %v", a.Current())
+	}
+
+	// Lossy connections are not healthy
+	a.adjust(known, peers[:1], 1, func(peer.ID) (PeerQuality, bool) { return PeerQuality{PacketLoss: 0.5}, true })
+	if a.Current() != 10*time.Second {
+		t.Errorf("expected minimum with lossy peers, got %v", a.Current())
+	}
+}
+
+func TestAdaptiveIntervalNudgeWakesWait(t *testing.T) {
+	a := NewAdaptiveInterval(time.Second, time.Hour, time.Hour)
+	done := make(chan bool)
+	go func() { done <- a.Wait(context.Background()) }()
+
+	a.Nudge()
+	select {
+	case ok := <-done:
+		if !ok {
+			t.Fatal("Wait reported cancellation")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Nudge did not end Wait")
+	}
+	if a.Current() != time.Second {
+		t.Errorf("expected minimum after nudge, got %v", a.Current())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if a.Wait(ctx) {
+		t.Error("Wait should return false once cancelled")
+	}
+}
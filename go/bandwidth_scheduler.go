@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	// Bandwidth classes: bulk shard transfers, and interactive voice,
+	// video and chat
+	BandwidthBulk        = "bulk"
+	BandwidthInteractive = "interactive"
+
+	// bandwidthPiece is the most a send takes from a bucket at once, so a
+	// large send is paced rather than let through in one burst
+	bandwidthPiece = 32 * 1024
+)
+
+// BandwidthSettings caps outgoing bandwidth in bytes per second; 0 leaves a
+// limit off
+type BandwidthSettings struct {
+	Total       uint64 // Bulk and interactive traffic together
+	Bulk        uint64
+	Interactive uint64
+}
+
+// BandwidthUsage counts one class's traffic since the node started
+type BandwidthUsage struct {
+	Class     string
+	Bytes     uint64
+	Throttled time.Duration // Time sends spent waiting for tokens
+}
+
+// BandwidthScheduler rate-limits outgoing traffic with a token bucket per
+// class and one for the whole uplink. Interactive traffic is never held
+// back by the total limit, only by its own: its bytes are still taken from
+// the total bucket, so bulk transfers yield the bandwidth media is using.
+// A nil BandwidthScheduler lets everything through.
+type BandwidthScheduler struct {
+	mu      sync.Mutex
+	limits  BandwidthSettings
+	total   *rate.Limiter
+	classes map[string]*bandwidthClass
+}
+
+// bandwidthClass is one class's bucket and counters
+type bandwidthClass struct {
+	limiter   *rate.Limiter
+	bytes     atomic.Uint64
+	throttled atomic.Int64 // Nanoseconds
+}
+
+// NewBandwidthScheduler creates a scheduler with no limits set
+func NewBandwidthScheduler() *BandwidthScheduler {
+	return &BandwidthScheduler{
+		total: rate.NewLimiter(rate.Inf, bandwidthPiece),
+		classes: map[string]*bandwidthClass{
+			BandwidthBulk:        {limiter: rate.NewLimiter(rate.Inf, bandwidthPiece)},
+			BandwidthInteractive: {limiter: rate.NewLimiter(rate.Inf, bandwidthPiece)},
+		},
+	}
+}
+
+// SetLimits changes the limits; sends already waiting are paced by the new
+// ones from their next piece
+func (b *BandwidthScheduler) SetLimits(limits BandwidthSettings) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.limits = limits
+	setBucketRate(b.total, limits.Total)
+	setBucketRate(b.classes[BandwidthBulk].limiter, limits.Bulk)
+	setBucketRate(b.classes[BandwidthInteractive].limiter, limits.Interactive)
+}
+
+// setBucketRate sets a bucket to bytesPerSec, letting a tenth of a second's
+// worth through at once
+func setBucketRate(l *rate.Limiter, bytesPerSec uint64) {
+	if bytesPerSec == 0 {
+		l.SetLimit(rate.Inf)
+		l.SetBurst(bandwidthPiece)
+		return
+	}
+	l.SetLimit(rate.Limit(bytesPerSec))
+	l.SetBurst(max(int(bytesPerSec/10), bandwidthPiece))
+}
+
+// Limits returns the limits in force
+func (b *BandwidthScheduler) Limits() BandwidthSettings {
+	if b == nil {
+		return BandwidthSettings{}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.limits
+}
+
+// Stats returns each class's counters, bulk first
+func (b *BandwidthScheduler) Stats() []BandwidthUsage {
+	if b == nil {
+		return nil
+	}
+	stats := make([]BandwidthUsage, 0, len(b.classes))
+	for _, name := range []string{BandwidthBulk, BandwidthInteractive} {
+		c := b.classes[name]
+		stats = append(stats, BandwidthUsage{
+			Class:     name,
+			Bytes:     c.bytes.Load(),
+			Throttled: time.Duration(c.throttled.Load()),
+		})
+	}
+	return stats
+}
+
+// Wait blocks until n bytes of class may be sent, or ctx ends. Bulk sends
+// wait for both their own and the total bucket.
+func (b *BandwidthScheduler) Wait(ctx context.Context, class string, n int) error {
+	if b == nil || n <= 0 {
+		return nil
+	}
+	c, ok := b.classes[class]
+	if !ok {
+		return nil
+	}
+	start := time.Now()
+	defer func() {
+		if waited := time.Since(start); waited > time.Millisecond {
+			c.throttled.Add(int64(waited))
+			bandwidthThrottledSeconds.WithLabelValues(class).Add(waited.Seconds())
+		}
+	}()
+	for n > 0 {
+		piece := min(n, bandwidthPiece)
+		if err := c.limiter.WaitN(ctx, piece); err != nil {
+			return err
+		}
+		if class == BandwidthInteractive {
+			// Taken without waiting; bulk sends make up the debt
+			b.total.ReserveN(time.Now(), piece)
+		} else if err := b.total.WaitN(ctx, piece); err != nil {
+			return err
+		}
+		c.bytes.Add(uint64(piece))
+		n -= piece
+	}
+	return nil
+}
+
+// Pacer returns a function that waits for n bytes of class, for the
+// pace hook of an rpcStream
+func (b *BandwidthScheduler) Pacer(ctx context.Context, class string) func(n int) error {
+	if b == nil {
+		return nil
+	}
+	return func(n int) error {
+		return b.Wait(ctx, class, n)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// timeWait returns how long a Wait for n bytes of class took
+func timeWait(t *testing.T, b *BandwidthScheduler, class string, n int) time.Duration {
+	t.Helper()
+	start := time.Now()
+	if err := b.Wait(context.Background(), class, n); err != nil {
+		t.Fatal(err)
+	}
+	return time.Since(start)
+}
+
+func TestBandwidthScheduler(t *testing.T) {
+	var unset *BandwidthScheduler
+	if err := unset.Wait(context.Background(), BandwidthBulk, 1<<30); err != nil {
+		t.Fatalf("nil scheduler held a send back: %v", err)
+	}
+
+	// A bulk limit paces a send past the burst: 160 KiB at 256 KiB/s,
+	// 32 KiB of it let straight through
+	b := NewBandwidthScheduler()
+	b.SetLimits(BandwidthSettings{Bulk: 256 << 10})
+	if took := timeWait(t, b, BandwidthBulk, 160<<10); took < 400*time.Millisecond || took > 2*time.Second {
+		t.Fatalf("160 KiB at 256 KiB/s took %v", took)
+	}
+	if took := timeWait(t, b, BandwidthInteractive, 1<<20); took > 100*time.Millisecond {
+		t.Fatalf("bulk limit held interactive traffic back for %v", took)
+	}
+
+	// Interactive traffic goes out at once under the total limit, and the
+	// bulk traffic after it makes up for it
+	b.SetLimits(BandwidthSettings{Total: 1 << 20})
+	if took := timeWait(t, b, BandwidthInteractive, 512<<10); took > 100*time.Millisecond {
+		t.Fatalf("total limit held interactive traffic back for %v", took)
+	}
+	if took := timeWait(t, b, BandwidthBulk, 32<<10); took < 300*time.Millisecond {
+		t.Fatalf("bulk send did not yield to interactive traffic (waited %v)", took)
+	}
+
+	// Lifting the limits lets waiting traffic through
+	b.SetLimits(BandwidthSettings{})
+	if took := timeWait(t, b, BandwidthBulk, 64<<20); took > 100*time.Millisecond {
+		t.Fatalf("unlimited bulk send took %v", took)
+	}
+	if limits := b.Limits(); limits != (BandwidthSettings{}) {
+		t.Fatalf("limits read back as %+v", limits)
+	}
+
+	stats := b.Stats()
+	if len(stats) != 2 || stats[0].Class != BandwidthBulk || stats[1].Class != BandwidthInteractive {
+		t.Fatalf("unexpected classes %+v", stats)
+	}
+	if stats[0].Bytes != 160<<10+32<<10+64<<20 || stats[0].Throttled < 700*time.Millisecond {
+		t.Fatalf("unexpected bulk stats %+v", stats[0])
+	}
+	if stats[1].Bytes != 1<<20+512<<10 {
+		t.Fatalf("unexpected interactive stats %+v", stats[1])
+	}
+
+	// A send given up on returns its context's error
+	b.SetLimits(BandwidthSettings{Bulk: 1 << 10})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := b.Wait(ctx, BandwidthBulk, 1<<20); err == nil {
+		t.Fatal("expected a send that cannot finish in time to fail")
+	}
+}
+
+func TestShardUploadBandwidthLimit(t *testing.T) {
+	n1, err := NewLibP2PPangeaNodeWithOptions(687, NewNodeStore(), false, true, 0)
+	if err != nil {
+		t.Fatalf("failed to create node1: %v", err)
+	}
+	defer n1.cancel()
+	n2, err := NewLibP2PPangeaNodeWithOptions(688, NewNodeStore(), false, true, 0)
+	if err != nil {
+		t.Fatalf("failed to create node2: %v", err)
+	}
+	defer n2.cancel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := n1.host.Connect(ctx, peer.AddrInfo{ID: n2.host.ID(), Addrs: n2.host.Addrs()}); err != nil {
+		t.Fatalf("connect n1->n2 failed: %v", err)
+	}
+	lib1 := NewLibP2PAdapter(n1, n1.store)
+	lib1.peerIDToUint32[n2.host.ID().String()] = 2
+	lib1.uint32ToPeerID[2] = n2.host.ID().String()
+
+	// 640 KiB at 1 MiB/s goes out in about half a second
+	n1.GetBandwidthScheduler().SetLimits(BandwidthSettings{Bulk: 1 << 20})
+	shard := bytes.Repeat([]byte("s"), 640<<10)
+	start := time.Now()
+	if err := lib1.SendShard(2, "paced", 0, shard); err != nil {
+		t.Fatal(err)
+	}
+	if took := time.Since(start); took < 400*time.Millisecond {
+		t.Fatalf("640 KiB shard at 1 MiB/s sent in %v", took)
+	}
+	if stored, ok := n2.FetchLocalShard("paced", 0); !ok || !bytes.Equal(stored, shard) {
+		t.Fatal("paced shard not stored intact")
+	}
+	if stats := n1.GetBandwidthScheduler().Stats(); stats[0].Bytes < uint64(len(shard)) {
+		t.Fatalf("%d shard bytes counted as bulk, expected at least %d", stats[0].Bytes, len(shard))
+	}
+}
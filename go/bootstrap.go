@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	dht "github.com/libp2p/go-libp2p-kad-dht"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/pnet"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/multiformats/go-multiaddr"
+)
+
+const (
+	// PrivateDHTPrefix keeps a private network's DHT apart from the public
+	// IPFS one even if a member is misconfigured without the swarm key
+	PrivateDHTPrefix protocol.ID = "/pangea"
+
+	// bootstrapDialTimeout bounds each bootstrap peer dial
+	bootstrapDialTimeout = 15 * time.Second
+)
+
+// NetworkOptions holds libp2p settings fixed when the node is created
+type NetworkOptions struct {
+	// Peers dialed at startup to seed the DHT. Empty means the public IPFS
+	// bootstrap set, or no peers on a private network.
+	BootstrapPeers []peer.AddrInfo
+
+	// Pre-shared key of a private network. Only peers holding the same key
+	// can connect; QUIC cannot carry the key, so the node is TCP only.
+	SwarmKey pnet.PSK
+
+	// Peer threat scoring; unset fields use the defaults
+	Threat ThreatConfig
+
+	// Listen on WebRTC-direct too so browsers can connect. Ignored on a
+	// private network, since WebRTC cannot carry the swarm key either.
+	WebRTC bool
+
+	// TCP port of a WebSocket listener, for networks that only let web
+	// traffic through; 0 means none. With WebSocketTLS set it serves WSS.
+	WebSocketPort int
+	WebSocketTLS  *tls.Config
+
+	// Extra addresses advertised as the node's own, such as the public
+	// /dns4/<host>/tcp/443/wss address of a reverse proxy that terminates
+	// TLS in front of the WebSocket listener
+	AnnounceAddrs []multiaddr.Multiaddr
+
+	// Listen on IPv4 only, for hosts whose IPv6 is broken rather than
+	// absent (an absent stack only fails the IPv6 listeners)
+	DisableIPv6 bool
+
+	// Dial IPv4 addresses of dual-stack peers first; by default IPv6 goes
+	// first and IPv4 follows 250ms later
+	PreferIPv4 bool
+
+	// Namespace of this cluster, from NetworkNamespace; empty is the
+	// default network. Nodes only find and talk to their own namespace.
+	Namespace string
+}
+
+// LoadSwarmKey reads a private network key in the go-ipfs swarm.key format:
+//
+//	/key/swarm/psk/1.0.0/
+//	/base16/
+//	<64 hex digits>
+func LoadSwarmKey(path string) (pnet.PSK, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	psk, err := pnet.DecodeV1PSK(f)
+	if err != nil {
+		return nil, fmt.Errorf("invalid swarm key %s: %w", path, err)
+	}
+	return psk, nil
+}
+
+// LoadWebSocketTLS loads the PEM certificate and key a WSS listener serves
+func LoadWebSocketTLS(certFile, keyFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("invalid WSS certificate: %w", err)
+	}
+	return &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}, nil
+}
+
+// ParseAnnounceAddrs parses the multiaddrs a node advertises besides its
+// listen addresses. They must not end in /p2p/<id>.
+func ParseAnnounceAddrs(addrs []string) ([]multiaddr.Multiaddr, error) {
+	var out []multiaddr.Multiaddr
+	for _, addr := range addrs {
+		addr = strings.TrimSpace(addr)
+		if addr == "" {
+			continue
+		}
+		ma, err := multiaddr.NewMultiaddr(addr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid announce address %q: %w", addr, err)
+		}
+		if _, err := ma.ValueForProtocol(multiaddr.P_P2P); err == nil {
+			return nil, fmt.Errorf("announce address %q must not include /p2p", addr)
+		}
+		out = append(out, ma)
+	}
+	return out, nil
+}
+
+// ParseBootstrapPeers parses bootstrap multiaddrs, each ending in /p2p/<id>.
+// Addresses of the same peer are merged.
+func ParseBootstrapPeers(addrs []string) ([]peer.AddrInfo, error) {
+	var infos []peer.AddrInfo
+	for _, addr := range addrs {
+		addr = strings.TrimSpace(addr)
+		if addr == "" {
+			continue
+		}
+		info, err := peer.AddrInfoFromString(addr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bootstrap peer %q: %w", addr, err)
+		}
+		infos = mergeAddrInfo(infos, *info)
+	}
+	return infos, nil
+}
+
+// mergeAddrInfo adds info to infos, joining the addresses of a known peer
+func mergeAddrInfo(infos []peer.AddrInfo, info peer.AddrInfo) []peer.AddrInfo {
+	for i := range infos {
+		if infos[i].ID != info.ID {
+			continue
+		}
+		for _, addr := range info.Addrs {
+			known := false
+			for _, existing := range infos[i].Addrs {
+				known = known || existing.Equal(addr)
+			}
+			if !known {
+				infos[i].Addrs = append(infos[i].Addrs, addr)
+			}
+		}
+		return infos
+	}
+	return append(infos, info)
+}
+
+// bootstrapSet is the node's current bootstrap peers
+type bootstrapSet struct {
+	peers []peer.AddrInfo
+	mu    sync.RWMutex
+}
+
+// list returns a copy of the set
+func (b *bootstrapSet) list() []peer.AddrInfo {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return append([]peer.AddrInfo(nil), b.peers...)
+}
+
+// defaultBootstrapPeers returns the bootstrap set used when none is configured
+func defaultBootstrapPeers(opts NetworkOptions) []peer.AddrInfo {
+	if len(opts.BootstrapPeers) > 0 {
+		return append([]peer.AddrInfo(nil), opts.BootstrapPeers...)
+	}
+	if opts.SwarmKey != nil {
+		// Public peers could never complete the handshake
+		return nil
+	}
+	return dht.GetDefaultBootstrapPeerAddrInfos()
+}
+
+// IsPrivateNetwork reports whether the node only talks to swarm key holders
+func (n *LibP2PPangeaNode) IsPrivateNetwork() bool {
+	return n.privateNetwork
+}
+
+// BootstrapPeerInfos returns a copy of the current bootstrap set
+func (n *LibP2PPangeaNode) BootstrapPeerInfos() []peer.AddrInfo {
+	return n.bootstrap.list()
+}
+
+// BootstrapPeers returns the bootstrap set as multiaddrs ending in /p2p/<id>
+func (n *LibP2PPangeaNode) BootstrapPeers() []string {
+	var addrs []string
+	for _, info := range n.BootstrapPeerInfos() {
+		p2pAddrs, err := peer.AddrInfoToP2pAddrs(&info)
+		if err != nil {
+			continue
+		}
+		for _, addr := range p2pAddrs {
+			addrs = append(addrs, addr.String())
+		}
+	}
+	return addrs
+}
+
+// AddBootstrapPeer adds a peer to the bootstrap set and, when the DHT is
+// running, dials it in the background
+func (n *LibP2PPangeaNode) AddBootstrapPeer(addr string) error {
+	infos, err := ParseBootstrapPeers([]string{addr})
+	if err != nil {
+		return err
+	}
+	if len(infos) == 0 {
+		return fmt.Errorf("empty bootstrap peer address")
+	}
+	n.bootstrap.mu.Lock()
+	n.bootstrap.peers = mergeAddrInfo(n.bootstrap.peers, infos[0])
+	n.bootstrap.mu.Unlock()
+	log.Printf("➕ Added bootstrap peer %s", shortPeerID(infos[0].ID))
+
+	if n.dht != nil {
+		go n.connectBootstrapPeers(infos)
+	}
+	return nil
+}
+
+// RemoveBootstrapPeer removes a peer, given by multiaddr or peer ID, from the
+// bootstrap set. It reports whether the peer was in the set; existing
+// connections are left alone.
+func (n *LibP2PPangeaNode) RemoveBootstrapPeer(addr string) (bool, error) {
+	id, err := peer.Decode(addr)
+	if err != nil {
+		info, perr := peer.AddrInfoFromString(addr)
+		if perr != nil {
+			return false, fmt.Errorf("invalid bootstrap peer %q: %w", addr, perr)
+		}
+		id = info.ID
+	}
+
+	n.bootstrap.mu.Lock()
+	defer n.bootstrap.mu.Unlock()
+	for i, info := range n.bootstrap.peers {
+		if info.ID == id {
+			n.bootstrap.peers = append(n.bootstrap.peers[:i], n.bootstrap.peers[i+1:]...)
+			log.Printf("➖ Removed bootstrap peer %s", shortPeerID(id))
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// connectBootstrapPeers dials the given peers in parallel, then refreshes the
+// DHT routing table if any answered
+func (n *LibP2PPangeaNode) connectBootstrapPeers(infos []peer.AddrInfo) {
+	if len(infos) == 0 {
+		return
+	}
+
+	var wg sync.WaitGroup
+	var connected atomic.Int32
+	for _, info := range infos {
+		if info.ID == n.host.ID() {
+			continue
+		}
+		wg.Add(1)
+		go func(info peer.AddrInfo) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(n.ctx, bootstrapDialTimeout)
+			defer cancel()
+			if err := n.host.Connect(ctx, info); err != nil {
+				if n.testMode {
+					log.Printf("⚠️  Bootstrap peer %s unreachable: %v", shortPeerID(info.ID), err)
+				}
+				return
+			}
+			connected.Add(1)
+		}(info)
+	}
+	wg.Wait()
+
+	log.Printf("🥾 Connected to %d/%d bootstrap peers", connected.Load(), len(infos))
+	if connected.Load() > 0 && n.dht != nil {
+		if err := n.dht.Bootstrap(n.ctx); err != nil && n.testMode {
+			log.Printf("⚠️  DHT refresh failed: %v", err)
+		}
+	}
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/pnet"
+	"github.com/libp2p/go-libp2p/p2p/protocol/ping"
+	"github.com/multiformats/go-multiaddr"
+)
+
+// writeSwarmKey writes a random swarm key file and loads it back
+func writeSwarmKey(t *testing.T, dir, name string) pnet.PSK {
+	key := make([]byte, 32)
+	rand.Read(key)
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte("/key/swarm/psk/1.0.0/\n/base16/\n"+hex.EncodeToString(key)+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	psk, err := LoadSwarmKey(path)
+	if err != nil {
+		t.Fatalf("failed to load swarm key: %v", err)
+	}
+	return psk
+}
+
+func TestPrivateNetworkRefusesNonMembers(t *testing.T) {
+	dir := t.TempDir()
+	keyA, keyB := writeSwarmKey(t, dir, "a.key"), writeSwarmKey(t, dir, "b.key")
+
+	bad := filepath.Join(dir, "bad.key")
+	os.WriteFile(bad, []byte("not a key"), 0600)
+	if _, err := LoadSwarmKey(bad); err == nil {
+		t.Fatal("expected a malformed swarm key to be rejected")
+	}
+
+	newNode := func(id uint32, port int, key pnet.PSK) *LibP2PPangeaNode {
+		n, err := NewLibP2PPangeaNodeWithNetworkOptions(id, NewNodeStore(), false, true, port, NetworkOptions{SwarmKey: key})
+		if err != nil {
+			t.Fatalf("failed to create node %d: %v", id, err)
+		}
+		t.Cleanup(n.cancel)
+		return n
+	}
+	member := newNode(530, 12530, keyA)
+	otherMember := newNode(531, 12531, keyA)
+	outsider := newNode(532, 12532, keyB)
+	public := newNode(533, 12533, nil)
+
+	if !member.IsPrivateNetwork() || public.IsPrivateNetwork() {
+		t.Fatal("unexpected private network flags")
+	}
+	if len(member.BootstrapPeers()) != 0 {
+		t.Fatalf("a private node must not default to public bootstrap peers: %v", member.BootstrapPeers())
+	}
+	if len(public.BootstrapPeers()) == 0 {
+		t.Fatal("expected a public node to default to the IPFS bootstrap set")
+	}
+
+	connect := func(from, to *LibP2PPangeaNode) error {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return from.host.Connect(ctx, peer.AddrInfo{ID: to.host.ID(), Addrs: to.host.Addrs()})
+	}
+	if err := connect(otherMember, member); err != nil {
+		t.Fatalf("members failed to connect: %v", err)
+	}
+	if err := connect(outsider, member); err == nil {
+		t.Fatal("expected a node with another swarm key to be refused")
+	}
+	if err := connect(public, member); err == nil {
+		t.Fatal("expected a public node to be refused")
+	}
+}
+
+func TestBootstrapPeerManagement(t *testing.T) {
+	const id = "12D3KooWGzxzKZYveHXtpG6AsrUJBcWxHBFS2HsEoGTxrMLvKXtf"
+	infos, err := ParseBootstrapPeers([]string{
+		"/ip4/10.0.0.1/tcp/7777/p2p/" + id,
+		" /ip4/10.0.0.2/tcp/7777/p2p/" + id,
+		"",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 1 || len(infos[0].Addrs) != 2 {
+		t.Fatalf("expected one peer with two addresses, got %v", infos)
+	}
+	if _, err := ParseBootstrapPeers([]string{"/ip4/10.0.0.1/tcp/7777"}); err == nil {
+		t.Fatal("expected an address without a peer ID to be rejected")
+	}
+
+	node, err := NewLibP2PPangeaNodeWithNetworkOptions(534, NewNodeStore(), true, true, 0, NetworkOptions{BootstrapPeers: infos})
+	if err != nil {
+		t.Fatalf("failed to create node: %v", err)
+	}
+	defer node.cancel()
+	if got := node.BootstrapPeers(); len(got) != 2 {
+		t.Fatalf("expected the configured set, got %v", got)
+	}
+
+	other := "/ip4/10.0.0.3/tcp/7777/p2p/" + node.host.ID().String()
+	if err := node.AddBootstrapPeer(other); err != nil {
+		t.Fatal(err)
+	}
+	if err := node.AddBootstrapPeer("garbage"); err == nil {
+		t.Fatal("expected an invalid address to be rejected")
+	}
+	if got := node.BootstrapPeers(); len(got) != 3 || got[2] != other {
+		t.Fatalf("expected the added peer last, got %v", got)
+	}
+
+	if removed, err := node.RemoveBootstrapPeer(id); err != nil || !removed {
+		t.Fatalf("expected removal by peer ID, got %v %v", removed, err)
+	}
+	if removed, _ := node.RemoveBootstrapPeer(id); removed {
+		t.Fatal("expected a second removal to find nothing")
+	}
+	if removed, err := node.RemoveBootstrapPeer(other); err != nil || !removed {
+		t.Fatalf("expected removal by multiaddr, got %v %v", removed, err)
+	}
+	if got := node.BootstrapPeers(); len(got) != 0 {
+		t.Fatalf("expected an empty set, got %v", got)
+	}
+}
+
+func TestWebRTCBrowserPeers(t *testing.T) {
+	newNode := func(id uint32, port int, opts NetworkOptions) *LibP2PPangeaNode {
+		n, err := NewLibP2PPangeaNodeWithNetworkOptions(id, NewNodeStore(), false, true, port, opts)
+		if err != nil {
+			t.Fatalf("failed to create node %d: %v", id, err)
+		}
+		t.Cleanup(n.cancel)
+		return n
+	}
+	server := newNode(610, 12610, NetworkOptions{WebRTC: true})
+	browser := newNode(611, 12611, NetworkOptions{WebRTC: true})
+	private := newNode(612, 12612, NetworkOptions{WebRTC: true, SwarmKey: writeSwarmKey(t, t.TempDir(), "swarm.key")})
+
+	if private.WebRTCEnabled() || len(private.WebRTCAddrs()) != 0 {
+		t.Fatal("WebRTC must stay off on a private network")
+	}
+	addrs := server.WebRTCAddrs()
+	if !server.WebRTCEnabled() || len(addrs) == 0 {
+		t.Fatalf("expected WebRTC-direct addresses, got %v", addrs)
+	}
+
+	// Dial over WebRTC only, as a browser would
+	info, err := peer.AddrInfoFromString(addrs[0])
+	if err != nil {
+		t.Fatalf("bad WebRTC address %s: %v", addrs[0], err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := browser.host.Connect(ctx, *info); err != nil {
+		t.Fatalf("WebRTC connect failed: %v", err)
+	}
+	conns := server.host.Network().ConnsToPeer(browser.host.ID())
+	if len(conns) == 0 || !isBrowserConn(conns[0]) {
+		t.Fatalf("expected a WebRTC connection, got %v", conns)
+	}
+	if res := <-ping.Ping(ctx, browser.host, server.host.ID()); res.Error != nil {
+		t.Fatalf("ping over WebRTC failed: %v", res.Error)
+	}
+}
+
+func TestWebSocketListeners(t *testing.T) {
+	if _, err := ParseAnnounceAddrs([]string{"/dns4/node.example.com/tcp/443/wss/p2p/12D3KooWGzxzKZYveHXtpG6AsrUJBcWxHBFS2HsEoGTxrMLvKXtf"}); err == nil {
+		t.Fatal("expected an announce address with /p2p to be rejected")
+	}
+	announce, err := ParseAnnounceAddrs([]string{" /dns4/node.example.com/tcp/443/wss", ""})
+	if err != nil || len(announce) != 1 {
+		t.Fatalf("ParseAnnounceAddrs = %v, %v", announce, err)
+	}
+
+	newNode := func(id uint32, port int, opts NetworkOptions) *LibP2PPangeaNode {
+		n, err := NewLibP2PPangeaNodeWithNetworkOptions(id, NewNodeStore(), false, true, port, opts)
+		if err != nil {
+			t.Fatalf("failed to create node %d: %v", id, err)
+		}
+		t.Cleanup(n.cancel)
+		return n
+	}
+	hasAddr := func(n *LibP2PPangeaNode, suffix string) multiaddr.Multiaddr {
+		for _, addr := range n.host.Addrs() {
+			if strings.HasSuffix(addr.String(), suffix) {
+				return addr
+			}
+		}
+		return nil
+	}
+
+	// Behind a reverse proxy: plain WebSocket, advertised as the proxy's WSS
+	server := newNode(613, 12613, NetworkOptions{WebSocketPort: 12614, AnnounceAddrs: announce})
+	client := newNode(615, 12615, NetworkOptions{WebSocketPort: 12616})
+	if hasAddr(server, "/dns4/node.example.com/tcp/443/wss") == nil {
+		t.Fatalf("announce address not advertised: %v", server.host.Addrs())
+	}
+	wsAddr := hasAddr(server, "/tcp/12614/ws")
+	if wsAddr == nil {
+		t.Fatalf("WebSocket address not advertised: %v", server.host.Addrs())
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.host.Connect(ctx, peer.AddrInfo{ID: server.host.ID(), Addrs: []multiaddr.Multiaddr{wsAddr}}); err != nil {
+		t.Fatalf("WebSocket connect failed: %v", err)
+	}
+	conns := client.host.Network().ConnsToPeer(server.host.ID())
+	if len(conns) == 0 || conns[0].ConnState().Transport != "websocket" {
+		t.Fatalf("expected a WebSocket connection, got %v", conns)
+	}
+
+	// With a certificate the listener serves WSS itself
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if _, err := LoadWebSocketTLS(certFile, keyFile); err == nil {
+		t.Fatal("expected a missing certificate to be rejected")
+	}
+	writeTestCertificate(t, certFile, keyFile)
+	tlsConf, err := LoadWebSocketTLS(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("LoadWebSocketTLS: %v", err)
+	}
+	secure := newNode(617, 12617, NetworkOptions{WebSocketPort: 12618, WebSocketTLS: tlsConf})
+	if hasAddr(secure, "/tcp/12618/tls/ws") == nil {
+		t.Fatalf("WSS address not advertised: %v", secure.host.Addrs())
+	}
+}
+
+// writeTestCertificate writes a self-signed certificate and key as PEM
+func writeTestCertificate(t *testing.T, certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"localhost"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+}
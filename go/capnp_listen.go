@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Cap'n Proto listen address schemes. An address without a scheme is a TCP
+// host:port, as before.
+//
+//	tcp://host:port   TCP
+//	unix:///path      Unix domain socket, readable by the node's user only
+//	npipe://name      Windows named pipe \\.\pipe\name
+const (
+	capnpSchemeTCP   = "tcp://"
+	capnpSchemeUnix  = "unix://"
+	capnpSchemeNpipe = "npipe://"
+)
+
+// listenCapnp opens the listener for a Cap'n Proto server address
+func listenCapnp(address string) (net.Listener, error) {
+	switch {
+	case strings.HasPrefix(address, capnpSchemeUnix):
+		return listenUnixSocket(strings.TrimPrefix(address, capnpSchemeUnix))
+	case strings.HasPrefix(address, capnpSchemeNpipe):
+		name := strings.TrimPrefix(address, capnpSchemeNpipe)
+		if name == "" {
+			return nil, fmt.Errorf("named pipe address needs a name")
+		}
+		return listenNamedPipe(`\\.\pipe\` + name)
+	default:
+		return net.Listen("tcp", strings.TrimPrefix(address, capnpSchemeTCP))
+	}
+}
+
+// dialCapnp connects to a Cap'n Proto server address as accepted by listenCapnp
+func dialCapnp(address string) (net.Conn, error) {
+	switch {
+	case strings.HasPrefix(address, capnpSchemeUnix):
+		path := strings.TrimPrefix(address, capnpSchemeUnix)
+		if path == "" {
+			return nil, fmt.Errorf("unix socket address needs a path")
+		}
+		return net.Dial("unix", path)
+	case strings.HasPrefix(address, capnpSchemeNpipe):
+		name := strings.TrimPrefix(address, capnpSchemeNpipe)
+		if name == "" {
+			return nil, fmt.Errorf("named pipe address needs a name")
+		}
+		return dialNamedPipe(`\\.\pipe\` + name)
+	default:
+		return net.Dial("tcp", strings.TrimPrefix(address, capnpSchemeTCP))
+	}
+}
+
+// listenUnixSocket listens on a Unix domain socket. A stale socket file left
+// by an earlier run is removed; any other existing file is left alone.
+//
+// Linux abstract sockets (@name) are refused: they have no filesystem entry
+// to carry permissions, so any local user could call the node's RPC service.
+func listenUnixSocket(path string) (net.Listener, error) {
+	if path == "" {
+		return nil, fmt.Errorf("unix socket address needs a path")
+	}
+	if strings.HasPrefix(path, "@") {
+		return nil, fmt.Errorf("abstract socket %s cannot be restricted to the node's user; use a socket path", path)
+	}
+
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("%s is in use by another process", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket: %w", err)
+		}
+	}
+
+	// Only the node's user may talk to the RPC service. The socket is bound
+	// and restricted inside a private directory, then moved into place, so
+	// it is never reachable with the umask's permissions.
+	dir, err := os.MkdirTemp(filepath.Dir(path), ".capnp-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create socket directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	bound := filepath.Join(dir, "sock")
+
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: bound, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+	listener.SetUnlinkOnClose(false)
+	if err := os.Chmod(bound, 0600); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to restrict socket permissions: %w", err)
+	}
+	if err := os.Rename(bound, path); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to move socket into place: %w", err)
+	}
+	return &unixSocketListener{UnixListener: listener, path: path}, nil
+}
+
+// unixSocketListener removes its socket file on Close, since the listener
+// itself only knows the path it was bound at
+type unixSocketListener struct {
+	*net.UnixListener
+	path string
+}
+
+func (l *unixSocketListener) Close() error {
+	err := l.UnixListener.Close()
+	os.Remove(l.path)
+	return err
+}
+
+func (l *unixSocketListener) Addr() net.Addr {
+	return &net.UnixAddr{Name: l.path, Net: "unix"}
+}
@@ -0,0 +1,16 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"net"
+)
+
+func listenNamedPipe(name string) (net.Listener, error) {
+	return nil, fmt.Errorf("named pipes are only available on Windows; use a unix:// address")
+}
+
+func dialNamedPipe(name string) (net.Conn, error) {
+	return nil, fmt.Errorf("named pipes are only available on Windows; use a unix:// address")
+}
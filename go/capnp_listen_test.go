@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"capnproto.org/go/capnp/v3/rpc"
+)
+
+func TestCapnpOverUnixSocket(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix sockets not tested on windows")
+	}
+	path := filepath.Join(t.TempDir(), "node.sock")
+
+	// A stale socket from an earlier run is replaced
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	listener, err := listenCapnp("unix://" + path)
+	if err != nil {
+		t.Fatalf("listen on stale socket failed: %v", err)
+	}
+	defer listener.Close()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Fatalf("expected socket mode 0600, got %v", info.Mode().Perm())
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Fatalf("expected only the socket left in its directory, got %d entries", len(entries))
+	}
+	if addr := listener.Addr().String(); addr != path {
+		t.Fatalf("expected listener address %s, got %s", path, addr)
+	}
+
+	// A live socket is not stolen
+	if _, err := listenCapnp("unix://" + path); err == nil {
+		t.Fatal("expected listening on an in-use socket to fail")
+	}
+
+	store := NewNodeStore()
+	store.AddOrUpdateNode(&LocalNode{ID: 7})
+	go func() {
+		// The in-use probe above is the first connection
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go handleCapnpConnection(conn, store, nil, nil)
+		}
+	}()
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rpcConn := rpc.NewConn(rpc.NewStreamTransport(conn), nil)
+	defer rpcConn.Close()
+	client := NodeService(rpcConn.Bootstrap(context.Background()))
+	defer client.Release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	future, release := client.GetAllNodes(ctx, nil)
+	defer release()
+	results, err := future.Struct()
+	if err != nil {
+		t.Fatalf("RPC over unix socket failed: %v", err)
+	}
+	nodeList, _ := results.Nodes()
+	nodes, _ := nodeList.Nodes()
+	if nodes.Len() != 1 || nodes.At(0).Id() != 7 {
+		t.Fatalf("unexpected nodes over unix socket")
+	}
+}
+
+func TestListenCapnpAddresses(t *testing.T) {
+	l, err := listenCapnp("tcp://127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("tcp:// address failed: %v", err)
+	}
+	l.Close()
+
+	if _, err := listenCapnp("unix://"); err == nil {
+		t.Fatal("expected empty unix path to be rejected")
+	}
+	if _, err := listenCapnp("npipe://"); err == nil {
+		t.Fatal("expected empty pipe name to be rejected")
+	}
+
+	file := filepath.Join(t.TempDir(), "regular")
+	os.WriteFile(file, nil, 0644)
+	if _, err := listenCapnp("unix://" + file); err == nil {
+		t.Fatal("expected a regular file not to be replaced")
+	}
+
+	// Abstract sockets cannot be restricted to the node's user
+	if _, err := listenCapnp("unix://@pangea-test-" + t.Name()); err == nil {
+		t.Fatal("expected an abstract socket to be rejected")
+	}
+}
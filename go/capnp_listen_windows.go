@@ -0,0 +1,211 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+const (
+	pipeBufferSize = 64 * 1024
+
+	// Full access for the owner and SYSTEM only
+	pipeSecurity = "D:P(A;;GA;;;OW)(A;;GA;;;SY)"
+)
+
+// pipeAddr is the address of a named pipe
+type pipeAddr string
+
+func (a pipeAddr) Network() string { return "pipe" }
+func (a pipeAddr) String() string  { return string(a) }
+
+// pipeListener accepts local clients on a named pipe. Every client gets its
+// own pipe instance, and I/O is overlapped so reads and writes on one
+// connection do not serialize.
+type pipeListener struct {
+	name    string
+	sa      *windows.SecurityAttributes
+	pending windows.Handle // Instance waiting for the next client
+	closed  bool
+	mu      sync.Mutex
+}
+
+func listenNamedPipe(name string) (net.Listener, error) {
+	sd, err := windows.SecurityDescriptorFromString(pipeSecurity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build pipe security descriptor: %w", err)
+	}
+	l := &pipeListener{
+		name: name,
+		sa: &windows.SecurityAttributes{
+			Length:             uint32(unsafe.Sizeof(windows.SecurityAttributes{})),
+			SecurityDescriptor: sd,
+		},
+	}
+	// Create the first instance now so a pipe already owned by another
+	// process is reported here rather than on the first Accept
+	if l.pending, err = l.createInstance(true); err != nil {
+		return nil, fmt.Errorf("failed to create pipe %s: %w", name, err)
+	}
+	return l, nil
+}
+
+// dialNamedPipe connects to a named pipe server as a client
+func dialNamedPipe(name string) (net.Conn, error) {
+	path, err := windows.UTF16PtrFromString(name)
+	if err != nil {
+		return nil, err
+	}
+	h, err := windows.CreateFile(path, windows.GENERIC_READ|windows.GENERIC_WRITE, 0, nil,
+		windows.OPEN_EXISTING, windows.FILE_FLAG_OVERLAPPED, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open pipe %s: %w", name, err)
+	}
+	return &pipeConn{h: h, addr: pipeAddr(name)}, nil
+}
+
+func (l *pipeListener) createInstance(first bool) (windows.Handle, error) {
+	name, err := windows.UTF16PtrFromString(l.name)
+	if err != nil {
+		return 0, err
+	}
+	flags := uint32(windows.PIPE_ACCESS_DUPLEX | windows.FILE_FLAG_OVERLAPPED)
+	if first {
+		flags |= windows.FILE_FLAG_FIRST_PIPE_INSTANCE
+	}
+	mode := uint32(windows.PIPE_TYPE_BYTE | windows.PIPE_READMODE_BYTE | windows.PIPE_WAIT | windows.PIPE_REJECT_REMOTE_CLIENTS)
+	return windows.CreateNamedPipe(name, flags, mode, windows.PIPE_UNLIMITED_INSTANCES,
+		pipeBufferSize, pipeBufferSize, 0, l.sa)
+}
+
+func (l *pipeListener) Accept() (net.Conn, error) {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil, net.ErrClosed
+	}
+	h := l.pending
+	if h == 0 {
+		var err error
+		if h, err = l.createInstance(false); err != nil {
+			l.mu.Unlock()
+			return nil, err
+		}
+		l.pending = h
+	}
+	l.mu.Unlock()
+
+	_, err := overlappedIO(h, func(ov *windows.Overlapped) error {
+		err := windows.ConnectNamedPipe(h, ov)
+		if err == windows.ERROR_PIPE_CONNECTED {
+			// Client connected between creation and this call
+			return nil
+		}
+		return err
+	})
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		// Close already released the instance
+		return nil, net.ErrClosed
+	}
+	l.pending = 0
+	if err != nil {
+		windows.CloseHandle(h)
+		return nil, err
+	}
+	return &pipeConn{h: h, addr: pipeAddr(l.name)}, nil
+}
+
+func (l *pipeListener) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return nil
+	}
+	l.closed = true
+	if l.pending != 0 {
+		// Aborts a pending ConnectNamedPipe
+		windows.CloseHandle(l.pending)
+		l.pending = 0
+	}
+	return nil
+}
+
+func (l *pipeListener) Addr() net.Addr { return pipeAddr(l.name) }
+
+// pipeConn is one connected pipe instance
+type pipeConn struct {
+	h         windows.Handle
+	addr      pipeAddr
+	closeOnce sync.Once
+}
+
+func (c *pipeConn) Read(b []byte) (int, error) {
+	n, err := overlappedIO(c.h, func(ov *windows.Overlapped) error {
+		return windows.ReadFile(c.h, b, nil, ov)
+	})
+	if err == windows.ERROR_BROKEN_PIPE || err == windows.ERROR_PIPE_NOT_CONNECTED {
+		return n, io.EOF
+	}
+	return n, err
+}
+
+func (c *pipeConn) Write(b []byte) (int, error) {
+	written := 0
+	for written < len(b) {
+		n, err := overlappedIO(c.h, func(ov *windows.Overlapped) error {
+			return windows.WriteFile(c.h, b[written:], nil, ov)
+		})
+		written += n
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+func (c *pipeConn) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		windows.CancelIoEx(c.h, nil)
+		windows.DisconnectNamedPipe(c.h)
+		err = windows.CloseHandle(c.h)
+	})
+	return err
+}
+
+func (c *pipeConn) LocalAddr() net.Addr  { return c.addr }
+func (c *pipeConn) RemoteAddr() net.Addr { return c.addr }
+
+var errPipeDeadline = errors.New("deadlines are not supported on named pipes")
+
+func (c *pipeConn) SetDeadline(t time.Time) error      { return errPipeDeadline }
+func (c *pipeConn) SetReadDeadline(t time.Time) error  { return errPipeDeadline }
+func (c *pipeConn) SetWriteDeadline(t time.Time) error { return errPipeDeadline }
+
+// overlappedIO starts an overlapped operation on h and waits for it
+func overlappedIO(h windows.Handle, start func(*windows.Overlapped) error) (int, error) {
+	event, err := windows.CreateEvent(nil, 1, 0, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer windows.CloseHandle(event)
+
+	ov := &windows.Overlapped{HEvent: event}
+	if err := start(ov); err != nil && err != windows.ERROR_IO_PENDING {
+		return 0, err
+	}
+	var n uint32
+	err = windows.GetOverlappedResult(h, ov, &n, true)
+	return int(n), err
+}
@@ -7,18 +7,27 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"math"
 	"net"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"crypto/sha256"
 
 	"capnproto.org/go/capnp/v3"
 	"capnproto.org/go/capnp/v3/rpc"
+	"github.com/libp2p/go-libp2p/core/metrics"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/pangea-net/go-node/pkg/communication"
 	"github.com/pangea-net/go-node/pkg/compute"
 	"github.com/pangea-net/go-node/pkg/crypto/dkg"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // nodeServiceServer implements the Cap'n Proto NodeService interface
@@ -35,6 +44,8 @@ type nodeServiceServer struct {
 	configManager    *ConfigManager
 	securityManager  *SecurityManager // Mandate 3: Security & encryption
 	mlCoordinator    *MLCoordinator   // Mandate 3: ML coordination
+	chat             *ChatService     // Mandate 3: Ephemeral chat delivery
+	summary          *summaryTracker  // Versions getNodeSummary sections
 }
 
 // NewNodeServiceServer creates a new NodeService server
@@ -60,6 +71,15 @@ func NewNodeServiceServerWithConfig(store *NodeStore, network NetworkAdapter, sh
 		manager = compute.NewManager(compute.DefaultConfig())
 	}
 
+	mlCoordinator := NewMLCoordinator()
+	securityManager := NewSecurityManager()
+	var chat *ChatService
+	if lib, ok := libp2pOf(network); ok && lib.node != nil {
+		mlCoordinator = lib.node.GetMLCoordinator()
+		securityManager = lib.node.GetSecurityManager()
+		chat = lib.node.GetChatService()
+	}
+
 	return &nodeServiceServer{
 		store:           store,
 		network:         network,
@@ -68,8 +88,10 @@ func NewNodeServiceServerWithConfig(store *NodeStore, network NetworkAdapter, sh
 		computeManager:  manager,
 		cesPipeline:     cesPipeline,
 		configManager:   configMgr,
-		securityManager: NewSecurityManager(), // Mandate 3
-		mlCoordinator:   NewMLCoordinator(),   // Mandate 3
+		securityManager: securityManager, // Mandate 3
+		chat:            chat,            // Mandate 3
+		mlCoordinator:   mlCoordinator,   // Mandate 3
+		summary:         newSummaryTracker(),
 	}
 }
 
@@ -113,6 +135,13 @@ func (s *nodeServiceServer) GetNode(ctx context.Context, call NodeService_getNod
 	nodeMsg.SetStatus(uint32(localNode.Status))
 	nodeMsg.SetLatencyMs(localNode.LatencyMs)
 	nodeMsg.SetThreatScore(localNode.ThreatScore)
+	if err := nodeMsg.SetStorageRole(localNode.StorageRole.String()); err != nil {
+		return err
+	}
+	if err := nodeMsg.SetPeerId(localNode.PeerID); err != nil {
+		return err
+	}
+	nodeMsg.SetUpdatedAt(localNode.UpdatedAt)
 
 	return nil
 }
@@ -143,6 +172,13 @@ func (s *nodeServiceServer) GetAllNodes(ctx context.Context, call NodeService_ge
 		nodeMsg.SetStatus(uint32(localNode.Status))
 		nodeMsg.SetLatencyMs(localNode.LatencyMs)
 		nodeMsg.SetThreatScore(localNode.ThreatScore)
+		if err := nodeMsg.SetStorageRole(localNode.StorageRole.String()); err != nil {
+			return err
+		}
+		if err := nodeMsg.SetPeerId(localNode.PeerID); err != nil {
+			return err
+		}
+		nodeMsg.SetUpdatedAt(localNode.UpdatedAt)
 	}
 
 	nodeList.SetNodes(nodesList)
@@ -168,13 +204,35 @@ func (s *nodeServiceServer) UpdateNode(ctx context.Context, call NodeService_upd
 
 	success := s.store.UpdateLatency(nodeID, latencyMs)
 	if success {
-		success = s.store.UpdateThreatScore(nodeID, threatScore)
+		success = s.updateThreatScore(nodeID, threatScore)
 	}
 
 	results.SetSuccess(success)
 	return nil
 }
 
+// updateThreatScore applies a threat score update. Raises are deferred while
+// the node is partitioned, since peers that look unresponsive are then most
+// likely just on the other side of the split.
+func (s *nodeServiceServer) updateThreatScore(nodeID uint32, threatScore float32) bool {
+	lib, ok := libp2pOf(s.network)
+	localNode, exists := s.store.GetNode(nodeID)
+	if !ok || !exists {
+		return s.store.UpdateThreatScore(nodeID, threatScore)
+	}
+	localNode.mu.RLock()
+	current := localNode.ThreatScore
+	localNode.mu.RUnlock()
+	if threatScore <= current {
+		return s.store.UpdateThreatScore(nodeID, threatScore)
+	}
+
+	lib.node.GetPartitionDetector().RunOrDefer(fmt.Sprintf("threat score raise for node %d", nodeID), func() {
+		s.store.UpdateThreatScore(nodeID, threatScore)
+	})
+	return true
+}
+
 // UpdateLatency implements the updateLatency method
 func (s *nodeServiceServer) UpdateLatency(ctx context.Context, call NodeService_updateLatency) error {
 	results, err := call.AllocResults()
@@ -251,7 +309,7 @@ func (s *nodeServiceServer) ConnectToPeer(ctx context.Context, call NodeService_
 	if strings.HasPrefix(host, "/") {
 		peerAddr = host
 	} else {
-		peerAddr = fmt.Sprintf("%s:%d", host, port)
+		peerAddr = net.JoinHostPort(host, fmt.Sprint(port)) // Brackets IPv6 literals
 	}
 
 	// Connect using network adapter
@@ -306,10 +364,14 @@ func (s *nodeServiceServer) SendMessage(ctx context.Context, call NodeService_se
 	err = s.network.SendMessage(toPeerID, data)
 	if err != nil {
 		log.Printf("Failed to send message to peer %d: %v", toPeerID, err)
-		results.SetSuccess(false)
-	} else {
-		results.SetSuccess(true)
+		// Fall back to store-forward delivery through a relay
+		if lib, ok := libp2pOf(s.network); ok {
+			if relayErr := lib.RelayMessage(toPeerID, data); relayErr == nil {
+				err = nil
+			}
+		}
 	}
+	results.SetSuccess(err == nil)
 
 	return nil
 }
@@ -442,18 +504,39 @@ func (s *nodeServiceServer) GetNetworkMetrics(ctx context.Context, call NodeServ
 		metrics.SetPacketLoss(0.0)
 	}
 
-	// WARNING: Bandwidth estimation is a rough heuristic and may NOT reflect actual capacity.
-	// This uses peer count as a proxy (more peers = better connected), but a node on a 100 Mbps
-	// connection with 100 peers would incorrectly report 1 Gbps. For accurate bandwidth measurement,
-	// implement active probing or use libp2p's built-in bandwidth metrics.
-	estimatedBandwidth := float32(10.0) // Base 10 Mbps for isolated node
-	if peerCount > 0 {
-		estimatedBandwidth = float32(peerCount) * 10.0
-		if estimatedBandwidth > 1000.0 {
-			estimatedBandwidth = 1000.0 // Cap at 1 Gbps
+	// Use measured libp2p throughput when available
+	if lib, ok := libp2pOf(s.network); ok && lib.node != nil && lib.node.bwCounter != nil {
+		totals := lib.GetBandwidthTotals()
+		uploadMbps := bytesPerSecToMbps(totals.RateOut)
+		downloadMbps := bytesPerSecToMbps(totals.RateIn)
+		metrics.SetUploadMbps(uploadMbps)
+		metrics.SetDownloadMbps(downloadMbps)
+		metrics.SetBandwidthMbps(uploadMbps + downloadMbps)
+
+		// Per-version peer counts track network upgrade progress
+		counts, err := newVersionCountList(results.Segment(), lib.node.GetVersionTracker().VersionCounts())
+		if err != nil {
+			return err
+		}
+		if err := metrics.SetVersionCounts(counts); err != nil {
+			return err
+		}
+
+		discovery, status := lib.node.GetLoopIntervals()
+		metrics.SetDiscoveryIntervalSecs(float32(discovery.Seconds()))
+		metrics.SetStatusIntervalSecs(float32(status.Seconds()))
+	} else {
+		// WARNING: Legacy transport has no byte accounting, so fall back to a rough
+		// heuristic that uses peer count as a proxy for connectivity.
+		estimatedBandwidth := float32(10.0) // Base 10 Mbps for isolated node
+		if peerCount > 0 {
+			estimatedBandwidth = float32(peerCount) * 10.0
+			if estimatedBandwidth > 1000.0 {
+				estimatedBandwidth = 1000.0 // Cap at 1 Gbps
+			}
 		}
+		metrics.SetBandwidthMbps(estimatedBandwidth)
 	}
-	metrics.SetBandwidthMbps(estimatedBandwidth)
 
 	// CPU usage from compute manager if available
 	if s.computeManager != nil {
@@ -469,6 +552,238 @@ func (s *nodeServiceServer) GetNetworkMetrics(ctx context.Context, call NodeServ
 	return nil
 }
 
+// GetPeerBandwidth implements the getPeerBandwidth method
+// peerId 0 returns stats for every connected peer
+func (s *nodeServiceServer) GetPeerBandwidth(ctx context.Context, call NodeService_getPeerBandwidth) error {
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+
+	lib, ok := libp2pOf(s.network)
+	if !ok || lib.node == nil || lib.node.bwCounter == nil {
+		return fmt.Errorf("bandwidth metering requires libp2p mode")
+	}
+
+	peerID := call.Args().PeerId()
+	peerIDs := []uint32{peerID}
+	if peerID == 0 {
+		peerIDs = lib.GetConnectedPeers()
+	}
+
+	type peerStats struct {
+		id    uint32
+		stats metrics.Stats
+	}
+	collected := make([]peerStats, 0, len(peerIDs))
+	for _, id := range peerIDs {
+		stats, err := lib.GetPeerBandwidth(id)
+		if err != nil {
+			if peerID != 0 {
+				return err
+			}
+			continue
+		}
+		collected = append(collected, peerStats{id: id, stats: stats})
+	}
+
+	peerList, err := results.NewPeers(int32(len(collected)))
+	if err != nil {
+		return err
+	}
+	for i, p := range collected {
+		entry := peerList.At(i)
+		entry.SetPeerId(p.id)
+		entry.SetTotalIn(uint64(p.stats.TotalIn))
+		entry.SetTotalOut(uint64(p.stats.TotalOut))
+		entry.SetRateInMbps(bytesPerSecToMbps(p.stats.RateIn))
+		entry.SetRateOutMbps(bytesPerSecToMbps(p.stats.RateOut))
+	}
+
+	byProto := lib.GetProtocolBandwidth()
+	protoNames := make([]string, 0, len(byProto))
+	for name := range byProto {
+		protoNames = append(protoNames, name)
+	}
+	sort.Strings(protoNames)
+
+	protoList, err := results.NewProtocols(int32(len(protoNames)))
+	if err != nil {
+		return err
+	}
+	for i, name := range protoNames {
+		stats := byProto[name]
+		entry := protoList.At(i)
+		entry.SetProtocol(name)
+		entry.SetTotalIn(uint64(stats.TotalIn))
+		entry.SetTotalOut(uint64(stats.TotalOut))
+		entry.SetRateInMbps(bytesPerSecToMbps(stats.RateIn))
+		entry.SetRateOutMbps(bytesPerSecToMbps(stats.RateOut))
+	}
+
+	return nil
+}
+
+// GetStorageStatus reports storage usage against the quota and the storage role
+func (s *nodeServiceServer) GetStorageStatus(ctx context.Context, call NodeService_getStorageStatus) error {
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+
+	lib, ok := libp2pOf(s.network)
+	if !ok || lib.node == nil {
+		return fmt.Errorf("storage status requires libp2p mode")
+	}
+
+	disk := lib.node.GetDiskMonitor()
+	used, quota := disk.Usage()
+	role := disk.Role()
+
+	status, err := results.NewStatus()
+	if err != nil {
+		return err
+	}
+	if err := status.SetRole(role.String()); err != nil {
+		return err
+	}
+	status.SetUsedBytes(used)
+	status.SetQuotaBytes(quota)
+	status.SetReadOnly(role == StorageReadOnly)
+	return nil
+}
+
+// GetPeerVersions reports the software versions advertised by connected peers
+func (s *nodeServiceServer) GetPeerVersions(ctx context.Context, call NodeService_getPeerVersions) error {
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	if err := results.SetLocalVersion(NodeVersion); err != nil {
+		return err
+	}
+
+	lib, ok := libp2pOf(s.network)
+	if !ok || lib.node == nil {
+		return fmt.Errorf("peer versions require libp2p mode")
+	}
+
+	versions := lib.GetPeerVersions()
+	ids := make([]uint32, 0, len(versions))
+	for id := range versions {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	peerList, err := results.NewPeers(int32(len(ids)))
+	if err != nil {
+		return err
+	}
+	for i, id := range ids {
+		info := versions[id]
+		entry := peerList.At(i)
+		entry.SetPeerId(id)
+		if err := entry.SetAgentVersion(info.AgentVersion); err != nil {
+			return err
+		}
+		if err := entry.SetVersion(info.Version); err != nil {
+			return err
+		}
+		if err := entry.SetStatus(info.Status.String()); err != nil {
+			return err
+		}
+		if err := entry.SetWarning(info.Warning); err != nil {
+			return err
+		}
+	}
+
+	counts, err := newVersionCountList(results.Segment(), lib.node.GetVersionTracker().VersionCounts())
+	if err != nil {
+		return err
+	}
+	return results.SetCounts(counts)
+}
+
+// GetPeerProtocolStats reports per-peer protocol usage by category
+func (s *nodeServiceServer) GetPeerProtocolStats(ctx context.Context, call NodeService_getPeerProtocolStats) error {
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+
+	lib, ok := libp2pOf(s.network)
+	if !ok || lib.node == nil || lib.node.protoStats == nil {
+		return fmt.Errorf("protocol statistics require libp2p mode")
+	}
+
+	byPeer, err := lib.GetPeerProtocolStats(call.Args().PeerId())
+	if err != nil {
+		return err
+	}
+
+	type entry struct {
+		peerID   uint32
+		category string
+		usage    ProtocolUsage
+	}
+	var entries []entry
+	for peerID, categories := range byPeer {
+		for category, usage := range categories {
+			entries = append(entries, entry{peerID: peerID, category: category, usage: usage})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].peerID != entries[j].peerID {
+			return entries[i].peerID < entries[j].peerID
+		}
+		return entries[i].category < entries[j].category
+	})
+
+	list, err := results.NewStats(int32(len(entries)))
+	if err != nil {
+		return err
+	}
+	for i, e := range entries {
+		item := list.At(i)
+		item.SetPeerId(e.peerID)
+		if err := item.SetProtocol(e.category); err != nil {
+			return err
+		}
+		item.SetStreamReads(e.usage.StreamReads)
+		item.SetStreamWrites(e.usage.StreamWrites)
+		item.SetBytesIn(e.usage.BytesIn)
+		item.SetBytesOut(e.usage.BytesOut)
+	}
+	return nil
+}
+
+// newVersionCountList converts per-version peer counts into a list sorted by version
+func newVersionCountList(seg *capnp.Segment, counts map[string]int) (VersionCount_List, error) {
+	versions := make([]string, 0, len(counts))
+	for v := range counts {
+		versions = append(versions, v)
+	}
+	sort.Strings(versions)
+
+	list, err := NewVersionCount_List(seg, int32(len(versions)))
+	if err != nil {
+		return list, err
+	}
+	for i, v := range versions {
+		entry := list.At(i)
+		if err := entry.SetVersion(v); err != nil {
+			return list, err
+		}
+		entry.SetPeers(uint32(counts[v]))
+	}
+	return list, nil
+}
+
+// bytesPerSecToMbps converts a byte rate into megabits per second
+func bytesPerSecToMbps(rate float64) float32 {
+	return float32(rate * 8 / 1e6)
+}
+
 // StartCapnpServer starts the Cap'n Proto RPC server
 func StartCapnpServer(store *NodeStore, network NetworkAdapter, shmMgr *SharedMemoryManager, address string) error {
 	return StartCapnpServerWithManager(store, network, shmMgr, address, nil)
@@ -481,19 +796,29 @@ func StartCapnpServerWithManager(store *NodeStore, network NetworkAdapter, shmMg
 
 // StartCapnpServerWithConfigManager starts the Cap'n Proto RPC server with compute manager and config manager
 func StartCapnpServerWithConfigManager(store *NodeStore, network NetworkAdapter, shmMgr *SharedMemoryManager, address string, manager *compute.Manager, configMgr *ConfigManager) error {
-	listener, err := net.Listen("tcp", address)
+	var health *HealthMonitor
+	if lib, ok := libp2pOf(network); ok {
+		health = lib.node.GetHealthMonitor()
+		health.Register("capnp", true, nil)
+	}
+
+	listener, err := listenCapnp(address)
 	if err != nil {
+		health.Report("capnp", HealthDown, err)
 		return fmt.Errorf("failed to listen on %s: %w", address, err)
 	}
 
 	log.Printf("Cap'n Proto server listening on %s", address)
+	health.Report("capnp", HealthOK, nil)
 
 	for {
 		conn, err := listener.Accept()
 		if err != nil {
 			log.Printf("Error accepting connection: %v", err)
+			health.Report("capnp", HealthDegraded, err)
 			continue
 		}
+		health.Report("capnp", HealthOK, nil)
 
 		go handleCapnpConnectionWithConfig(conn, store, network, shmMgr, manager, configMgr)
 	}
@@ -530,14 +855,9 @@ func handleCapnpConnectionWithConfig(conn net.Conn, store *NodeStore, network Ne
 // WriteToSharedMemory writes data to shared memory and returns the offset
 // Python can call this to efficiently transfer large data (shards, messages)
 func (s *nodeServiceServer) WriteToSharedMemory(ringName string, data []byte) (uint64, error) {
-	ring, ok := s.shmMgr.GetRing(ringName)
-	if !ok {
-		// Create ring on first use (16MB default)
-		var err error
-		ring, err = s.shmMgr.CreateRing(ringName, 16*1024*1024)
-		if err != nil {
-			return 0, fmt.Errorf("failed to create shared memory ring: %w", err)
-		}
+	ring, err := s.shmMgr.GetOrCreateRing(ringName)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create shared memory ring: %w", err)
 	}
 	return ring.Write(data)
 }
@@ -725,18 +1045,245 @@ func (s *nodeServiceServer) Upload(ctx context.Context, call NodeService_upload)
 		targetPeers[i] = targetPeersList.At(i)
 	}
 
-	// Calculate fileHash (SHA-256 of content)
-	var fileHash string
-	var hashBytes [32]byte
-	if len(data) >= 32 {
-		copy(hashBytes[:], data[:32])
+	fileHash := uploadFileHash(data)
+
+	requestedPolicy, err := request.PlacementPolicy()
+	if err != nil {
+		return err
+	}
+	if request.Restricted() {
+		// Holders learn the file is restricted from the shard pushes, which
+		// only libp2p carries
+		ac, err := s.accessControl()
+		if err != nil {
+			response, rerr := results.NewResponse()
+			if rerr != nil {
+				return rerr
+			}
+			response.SetSuccess(false)
+			return response.SetErrorMsg(err.Error())
+		}
+		if fileHash, err = ac.Protect(fileHash); err != nil {
+			response, rerr := results.NewResponse()
+			if rerr != nil {
+				return rerr
+			}
+			response.SetSuccess(false)
+			return response.SetErrorMsg(err.Error())
+		}
+	}
+	stored, err := s.uploadBlob(ctx, fileHash, data, targetPeers, int(request.Parallelism()), requestedPolicy)
+	if err != nil {
+		response, rerr := results.NewResponse()
+		if rerr != nil {
+			return rerr
+		}
+		response.SetSuccess(false)
+		response.SetErrorMsg(err.Error())
+		return nil
+	}
+	placements := stored.placements
+	throughputMbps := stored.throughputMbps
+
+	confirmedCount, requiredCount := placementQuorum(placements)
+
+	// Build manifest - fileHash already computed above
+
+	response, err := results.NewResponse()
+	if err != nil {
+		return err
+	}
+	response.SetConfirmedShards(confirmedCount)
+	response.SetRequiredShards(requiredCount)
+	response.SetThroughputMbps(throughputMbps)
+	if confirmedCount < requiredCount {
+		response.SetSuccess(false)
+		response.SetErrorMsg(quorumFailure(placements, confirmedCount, requiredCount))
 	} else {
-		// Fallback: hash entire content
-		h := sha256.Sum256(data)
-		hashBytes = h
+		response.SetSuccess(true)
+	}
+
+	m := &fileManifest{
+		FileHash: fileHash,
+		// Set default filename (UploadRequest doesn't include fileName field)
+		FileName:    "uploaded_file",
+		FileSize:    uint64(len(data)),
+		ShardCount:  uint32(len(placements)),
+		ParityCount: cesParityShards,
+		Placements:  placements, // With per-shard placement status and hashes
+		Timestamp:   time.Now().Unix(),
+		ContentHash: fileContentHash(data),
+	}
+	if m.Signature, err = s.signManifest(manifestKindFile, m.signedContent()); err != nil {
+		return err
+	}
+	manifest, err := response.NewManifest()
+	if err != nil {
+		return err
+	}
+	return fillFileManifest(manifest, m)
+}
+
+// fillShardLocations copies shard placements, with their per-shard status,
+// into their RPC form
+func fillShardLocations(list ShardLocation_List, placements []shardPlacement) error {
+	for i, p := range placements {
+		locMsg := list.At(i)
+		locMsg.SetShardIndex(p.shardIndex)
+		locMsg.SetPeerId(p.peerID)
+		locMsg.SetConfirmed(p.confirmed)
+		locMsg.SetRelayed(p.relayed)
+		if err := locMsg.SetShardHash(p.shardHash); err != nil {
+			return err
+		}
+		if p.errorCode != "" {
+			if err := locMsg.SetErrorCode(p.errorCode); err != nil {
+				return err
+			}
+		}
 	}
-	fileHash = fmt.Sprintf("%x", hashBytes[:])
+	return nil
+}
+
+// signManifest signs a manifest with the node's libp2p identity. A
+// legacy-only node has no identity and leaves its manifests unsigned.
+func (s *nodeServiceServer) signManifest(kind string, content interface{}) (*manifestSignature, error) {
+	lib, ok := libp2pOf(s.network)
+	if !ok || lib.node == nil {
+		return nil, nil
+	}
+	return signManifest(lib.node.host, kind, content)
+}
+
+// checkManifest verifies a manifest a client handed back before its shard
+// locations are trusted, and returns who signed it. A valid signature only
+// shows that someone signed the manifest, so the signer must also be the
+// expected one: this node's own identity unless the client names another.
+// A node with a libp2p identity signs what it produces and refuses
+// unsigned manifests; a legacy-only node accepts them, since it could not
+// have signed them.
+func (s *nodeServiceServer) checkManifest(kind string, content interface{}, sig *manifestSignature, expected string) (string, error) {
+	lib, ok := libp2pOf(s.network)
+	hasIdentity := ok && lib.node != nil
+	if sig == nil && !hasIdentity {
+		return "", nil
+	}
+	if err := verifyManifest(kind, content, sig); err != nil {
+		return "", err
+	}
+	if expected == "" && hasIdentity {
+		expected = lib.node.host.ID().String()
+	}
+	if sig.Signer != expected {
+		if expected == "" {
+			return "", fmt.Errorf("%w: no expected signer given for %s", errManifestUntrusted, sig.Signer)
+		}
+		return "", fmt.Errorf("%w: signed by %s, expected %s", errManifestUntrusted, sig.Signer, expected)
+	}
+	return sig.Signer, nil
+}
+
+// fillManifestSignature copies a manifest signature into its RPC form
+func fillManifestSignature(out ManifestSignature, sig *manifestSignature) error {
+	if err := out.SetSigner(sig.Signer); err != nil {
+		return err
+	}
+	if err := out.SetPublicKey(sig.PublicKey); err != nil {
+		return err
+	}
+	return out.SetSignature(sig.Signature)
+}
+
+// readManifestSignature reads a manifest signature, nil when the manifest
+// carries none
+func readManifestSignature(has bool, in func() (ManifestSignature, error)) (*manifestSignature, error) {
+	if !has {
+		return nil, nil
+	}
+	sig, err := in()
+	if err != nil {
+		return nil, err
+	}
+	signer, _ := sig.Signer()
+	signature, _ := sig.Signature()
+	if signer == "" && len(signature) == 0 {
+		return nil, nil
+	}
+	publicKey, _ := sig.PublicKey()
+	return &manifestSignature{Signer: signer, PublicKey: publicKey, Signature: signature}, nil
+}
+
+// fillFileManifest copies a file manifest into its RPC form
+func fillFileManifest(out FileManifest, m *fileManifest) error {
+	if err := out.SetFileHash(m.FileHash); err != nil {
+		return err
+	}
+	if err := out.SetFileName(m.FileName); err != nil {
+		return err
+	}
+	out.SetFileSize(m.FileSize)
+	out.SetShardCount(m.ShardCount)
+	out.SetParityCount(m.ParityCount)
+	out.SetTimestamp(m.Timestamp)
+	out.SetTtl(m.TTL)
+	if err := out.SetContentHash(m.ContentHash); err != nil {
+		return err
+	}
+	locations, err := out.NewShardLocations(int32(len(m.Placements)))
+	if err != nil {
+		return err
+	}
+	if err := fillShardLocations(locations, m.Placements); err != nil {
+		return err
+	}
+	if m.Signature == nil {
+		return nil
+	}
+	sig, err := out.NewSignature()
+	if err != nil {
+		return err
+	}
+	return fillManifestSignature(sig, m.Signature)
+}
+
+// readFileManifest reads a file manifest sent by a client
+func readFileManifest(in FileManifest) (*fileManifest, error) {
+	fileHash, _ := in.FileHash()
+	fileName, _ := in.FileName()
+	contentHash, _ := in.ContentHash()
+	list, err := in.ShardLocations()
+	if err != nil {
+		return nil, err
+	}
+	sig, err := readManifestSignature(in.HasSignature(), in.Signature)
+	if err != nil {
+		return nil, err
+	}
+	return &fileManifest{
+		FileHash:    fileHash,
+		FileName:    fileName,
+		FileSize:    in.FileSize(),
+		ShardCount:  in.ShardCount(),
+		ParityCount: in.ParityCount(),
+		Placements:  shardLocationsFrom(list),
+		Timestamp:   in.Timestamp(),
+		TTL:         in.Ttl(),
+		ContentHash: contentHash,
+		Signature:   sig,
+	}, nil
+}
+
+// storedBlob is where uploadBlob placed a blob's shards
+type storedBlob struct {
+	placements     []shardPlacement // By shard index
+	throughputMbps float32          // Aggregate shard distribution throughput
+}
 
+// uploadBlob CES-processes data under a key dealt to the target peers and
+// distributes its shards with the requested or configured placement
+// policy. Shards that were not confirmed are reported in the placements
+// rather than failing the upload; see placementQuorum.
+func (s *nodeServiceServer) uploadBlob(ctx context.Context, fileHash string, data []byte, targetPeers []uint32, parallelism int, requestedPolicy string) (*storedBlob, error) {
 	// Use dealer-based Shamir DKG: distribute shares to target peers and create a CES pipeline with the resulting key
 	threshold := 2
 	if len(targetPeers) >= 3 {
@@ -749,8 +1296,8 @@ func (s *nodeServiceServer) Upload(ctx context.Context, call NodeService_upload)
 	}
 
 	sendShare := func(peerID uint32, fileID string, share []byte) error {
-		if lib, ok := s.network.(*LibP2PAdapter); ok {
-			return lib.SendShare(peerID, fileID, share)
+		if transport, ok := s.network.(DataTransport); ok {
+			return transport.SendShare(peerID, fileID, share)
 		}
 		// Fallback raw message
 		msg := make([]byte, 1+2+len(fileID)+len(share))
@@ -763,20 +1310,19 @@ func (s *nodeServiceServer) Upload(ctx context.Context, call NodeService_upload)
 	}
 
 	storeOwnShare := func(fileID string, peerID uint32, share []byte) {
-		if lib, ok := s.network.(*LibP2PAdapter); ok {
-			lib.node.StoreDKGShare(fileID, peerID, share)
+		if transport, ok := s.network.(DataTransport); ok {
+			transport.StoreLocalShare(fileID, peerID, share)
 		}
 	}
 
 	fileKeyBytes, err := dkg.DistributeFileKey(ctx, fileHash, participants, threshold, sendShare, storeOwnShare)
 	if err != nil {
-		response, err := results.NewResponse()
-		if err != nil {
-			return err
+		return nil, fmt.Errorf("DKG distribution failed: %w", err)
+	}
+	if lib, ok := libp2pOf(s.network); ok {
+		if err := lib.TrackShareRefresh(fileHash, participants, threshold); err != nil {
+			log.Printf("⚠️  [REFRESH] Shares of %s will not be refreshed: %v", fileHash, err)
 		}
-		response.SetSuccess(false)
-		response.SetErrorMsg(fmt.Sprintf("DKG distribution failed: %v", err))
-		return nil
 	}
 
 	var keyArr [32]byte
@@ -785,87 +1331,288 @@ func (s *nodeServiceServer) Upload(ctx context.Context, call NodeService_upload)
 	// Create CES pipeline with explicit key
 	pipeline := NewCESPipelineWithKey(3, keyArr)
 	if pipeline == nil {
-		response, err := results.NewResponse()
-		if err != nil {
-			return err
-		}
-		response.SetSuccess(false)
-		response.SetErrorMsg("Failed to create CES pipeline with key")
-		return nil
+		return nil, fmt.Errorf("Failed to create CES pipeline with key")
 	}
 	defer pipeline.Close()
 
-	// Process through CES pipeline (compress, encrypt, shard)
-	shards, err := pipeline.Process(data)
+	// Choose shard holders with the requested or configured placement policy
+	policy, err := s.placementPolicy(requestedPolicy)
+	var holders []uint32
+	var candidates []PeerCandidate
+	if err == nil {
+		candidates = buildPlacementCandidates(s.network, s.store, targetPeers)
+		holders, err = policy.Assign(candidates, cesDataShards+cesParityShards)
+	}
 	if err != nil {
-		response, err := results.NewResponse()
-		if err != nil {
-			return err
-		}
-		response.SetSuccess(false)
-		response.SetErrorMsg(fmt.Sprintf("CES processing failed: %v", err))
-		return nil
+		return nil, fmt.Errorf("Shard placement failed: %w", err)
 	}
+	log.Printf("📍 Placement policy %s assigned %d shards across %d candidates", policy.Name(), len(holders), len(candidates))
 
-	// Distribute shards to peers
-	shardLocations := make([][2]uint32, len(shards)) // [shardIndex, peerID]
-	for i, shard := range shards {
-		peerID := targetPeers[i%len(targetPeers)]
+	parallelism = uploadParallelism(parallelism, len(holders))
 
-		// Send shard to peer and instruct them to store it
-		if lib, ok := s.network.(*LibP2PAdapter); ok {
-			if err := lib.SendShard(peerID, fileHash, uint32(i), shard.Data); err != nil {
-				log.Printf("Warning: Failed to send shard %d to peer %d: %v", i, peerID, err)
-			}
-		} else {
-			// Fallback - send raw message
-			if err := s.network.SendMessage(peerID, shard.Data); err != nil {
-				log.Printf("Warning: Failed to send shard %d to peer %d: %v", i, peerID, err)
+	// Send shards as they are copied out of the CES pipeline, collecting
+	// placement acknowledgements as sends complete
+	distributeStart := time.Now()
+	placements, sentBytes, err := distributeShards(parallelism, func(emit func(int, []byte)) error {
+		return pipeline.ProcessStream(data, func(index int, shard ShardData) {
+			emit(index, shard.Data)
+		})
+	}, func(index int, data []byte) (shardPlacement, bool) {
+		peerID := holders[index%len(holders)]
+		digest := sha256.Sum256(data)
+		placement := shardPlacement{
+			shardIndex: uint32(index),
+			peerID:     peerID,
+			shardHash:  fmt.Sprintf("%x", digest[:]),
+		}
+
+		confirmed, err := s.sendShardToPeer(peerID, fileHash, uint32(index), data)
+		if err != nil {
+			log.Printf("Warning: Shard %d not confirmed by peer %d: %v", index, peerID, err)
+			if errors.Is(err, ErrQuotaExceeded) {
+				placement.errorCode = QuotaExceededCode
+			} else if lib, ok := libp2pOf(s.network); ok {
+				// Peer unreachable: leave the shard with a relay for later delivery
+				if err := lib.RelayShard(peerID, fileHash, uint32(index), data); err == nil {
+					placement.relayed = true
+				}
 			}
+			return placement, false
 		}
+		placement.confirmed = confirmed
+		return placement, true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("CES processing failed: %w", err)
+	}
 
-		shardLocations[i] = [2]uint32{uint32(i), peerID}
+	throughputMbps := float32(0)
+	if elapsed := time.Since(distributeStart).Seconds(); elapsed > 0 {
+		throughputMbps = bytesPerSecToMbps(float64(sentBytes) / elapsed)
 	}
+	log.Printf("📦 Distributed %d shards (%d bytes) with parallelism %d at %.2f Mbps",
+		len(placements), sentBytes, parallelism, throughputMbps)
 
-	// Build manifest - fileHash already computed above
+	return &storedBlob{placements: placements, throughputMbps: throughputMbps}, nil
+}
 
-	response, err := results.NewResponse()
+// defaultUploadParallelism is the number of concurrent shard sends when the
+// upload request does not specify one
+const defaultUploadParallelism = 4
+
+// maxUploadParallelism caps client-requested concurrency; there are never
+// more shards than this to send
+const maxUploadParallelism = cesDataShards + cesParityShards
+
+// uploadParallelism turns a requested number of concurrent shard sends
+// (0 = the default) into one between 1 and the number of shard holders
+func uploadParallelism(requested, holders int) int {
+	if requested <= 0 {
+		requested = defaultUploadParallelism
+	}
+	return max(min(requested, maxUploadParallelism, holders), 1)
+}
+
+// distributeShards sends the shards produce emits with up to parallelism
+// sends in flight. Sending starts with the first shard emitted, so sends
+// overlap copying the rest out of the pipeline; produce blocks while every
+// send slot is busy. It returns the placements in shard order and the
+// bytes of the shards send reported as sent.
+func distributeShards(parallelism int, produce func(emit func(index int, data []byte)) error, send func(index int, data []byte) (shardPlacement, bool)) ([]shardPlacement, uint64, error) {
+	type shardJob struct {
+		index int
+		data  []byte
+	}
+	jobs := make(chan shardJob, parallelism)
+	var (
+		placements []shardPlacement
+		sentBytes  uint64
+		placeMu    sync.Mutex
+		wg         sync.WaitGroup
+	)
+	for w := 0; w < parallelism; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				placement, sent := send(job.index, job.data)
+				placeMu.Lock()
+				placements = append(placements, placement)
+				if sent {
+					sentBytes += uint64(len(job.data))
+				}
+				placeMu.Unlock()
+			}
+		}()
+	}
+
+	err := produce(func(index int, data []byte) {
+		jobs <- shardJob{index: index, data: data}
+	})
+	close(jobs)
+	wg.Wait()
 	if err != nil {
-		return err
+		return nil, 0, err
 	}
-	response.SetSuccess(true)
+	sort.Slice(placements, func(i, j int) bool {
+		return placements[i].shardIndex < placements[j].shardIndex
+	})
+	return placements, sentBytes, nil
+}
 
-	manifest, err := response.NewManifest()
+// placementPolicy returns the policy a request names, or when it names none
+// the one in the "placement_policy" config setting, defaulting to score-based
+// placement. Only an unknown requested policy is an error.
+func (s *nodeServiceServer) placementPolicy(requested string) (PlacementPolicy, error) {
+	if requested != "" {
+		return NewPlacementPolicy(requested)
+	}
+	name := "scored"
+	if s.configManager != nil {
+		if configured := s.configManager.GetConfig().CustomSettings["placement_policy"]; configured != "" {
+			name = configured
+		}
+	}
+	policy, err := NewPlacementPolicy(name)
+	if err != nil {
+		log.Printf("⚠️  %v, falling back to scored placement", err)
+		policy, _ = NewPlacementPolicy("scored")
+	}
+	return policy, nil
+}
+
+// PlanUpload implements the planUpload method - the shard layout an upload
+// of the given size would use, without running CES or sending shards
+func (s *nodeServiceServer) PlanUpload(ctx context.Context, call NodeService_planUpload) error {
+	request, err := call.Args().Request()
+	if err != nil {
+		return err
+	}
+	targetPeersList, err := request.TargetPeers()
+	if err != nil {
+		return err
+	}
+	requestedPolicy, err := request.PlacementPolicy()
 	if err != nil {
 		return err
 	}
-	manifest.SetFileHash(fileHash)
-	// Set default filename (UploadRequest doesn't include fileName field)
-	manifest.SetFileName("uploaded_file")
-	manifest.SetFileSize(uint64(len(data)))
-	manifest.SetShardCount(uint32(len(shards)))
-	manifest.SetParityCount(4) // From CES config
-	manifest.SetTimestamp(0)   // TODO: Add timestamp
-	manifest.SetTtl(0)
 
-	// Set shard locations
-	seg := results.Segment()
-	locationsList, err := NewShardLocation_List(seg, int32(len(shardLocations)))
+	targetPeers := make([]uint32, targetPeersList.Len())
+	for i := range targetPeers {
+		targetPeers[i] = targetPeersList.At(i)
+	}
+
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	plan, err := results.NewPlan()
 	if err != nil {
 		return err
 	}
 
-	for i, loc := range shardLocations {
-		locMsg := locationsList.At(i)
-		locMsg.SetShardIndex(loc[0])
-		locMsg.SetPeerId(loc[1])
+	parallelism := int(request.Parallelism())
+	if parallelism > maxUploadParallelism {
+		parallelism = maxUploadParallelism
+	}
+	policy, err := s.placementPolicy(requestedPolicy)
+	var layout *ShardLayout
+	if err == nil {
+		candidates := buildPlacementCandidates(s.network, s.store, targetPeers)
+		layout, err = planShardLayout(policy, candidates, request.FileSize(), parallelism)
+	}
+	if err != nil {
+		plan.SetSuccess(false)
+		return plan.SetErrorMsg(err.Error())
 	}
 
-	manifest.SetShardLocations(locationsList)
+	log.Printf("📐 Planned %d-byte upload with %s placement: %d peers, durability %.4f, ~%v",
+		request.FileSize(), layout.Policy, len(layout.Peers), layout.Durability, layout.EstimatedTime.Round(time.Millisecond))
 
+	if err := plan.SetPolicy(layout.Policy); err != nil {
+		return err
+	}
+	holders, err := plan.NewHolders(int32(len(layout.Holders)))
+	if err != nil {
+		return err
+	}
+	for i, h := range layout.Holders {
+		holders.Set(i, h)
+	}
+	peers, err := plan.NewPeers(int32(len(layout.Peers)))
+	if err != nil {
+		return err
+	}
+	for i, p := range layout.Peers {
+		peer := peers.At(i)
+		peer.SetPeerId(p.PeerID)
+		peer.SetShardCount(p.ShardCount)
+		peer.SetUptime(float32(p.Uptime))
+		peer.SetLatencyMs(p.LatencyMs)
+		peer.SetBandwidthMbps(p.BandwidthMbps)
+	}
+	plan.SetDataShards(layout.DataShards)
+	plan.SetParityShards(layout.ParityShards)
+	plan.SetShardSizeBytes(layout.ShardSizeBytes)
+	plan.SetDurability(layout.Durability)
+	plan.SetEstimatedTransferSecs(float32(layout.EstimatedTime.Seconds()))
+	plan.SetSuccess(true)
 	return nil
 }
 
+// sendShardToPeer sends a shard and reports whether the peer confirmed it
+func (s *nodeServiceServer) sendShardToPeer(peerID uint32, fileHash string, shardIndex uint32, data []byte) (confirmed bool, err error) {
+	if transport, ok := s.network.(DataTransport); ok {
+		if err := transport.SendShard(peerID, fileHash, shardIndex, data); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+	// Other transports have no ack channel, so a completed send stays unconfirmed
+	return false, s.network.SendMessage(peerID, data)
+}
+
+// shardPlacement records where a shard was sent and whether the peer confirmed it
+type shardPlacement struct {
+	shardIndex uint32
+	peerID     uint32
+	shardHash  string
+	confirmed  bool
+	relayed    bool   // Held by a relay; not counted toward the quorum
+	errorCode  string // Set when the peer rejected the shard
+}
+
+// placementQuorum returns the number of confirmed shards and the number
+// required to reconstruct the file (all data shards)
+func placementQuorum(placements []shardPlacement) (confirmed, required uint32) {
+	for _, p := range placements {
+		if p.confirmed {
+			confirmed++
+		}
+	}
+	required = uint32(len(placements))
+	if required > cesParityShards {
+		required -= cesParityShards
+	}
+	return confirmed, required
+}
+
+// quorumFailure explains an upload that fell short of its durability quorum
+func quorumFailure(placements []shardPlacement, confirmed, required uint32) string {
+	msg := fmt.Sprintf("Durability quorum not reached: %d of %d required shards confirmed",
+		confirmed, required)
+	quotaRejected := 0
+	for _, p := range placements {
+		if p.errorCode == QuotaExceededCode {
+			quotaRejected++
+		}
+	}
+	if quotaRejected > 0 {
+		msg += fmt.Sprintf(" (%d rejected with %s)", quotaRejected, QuotaExceededCode)
+	}
+	return msg
+}
+
 // Download implements the download method - fetch shards + CES reconstruct
 func (s *nodeServiceServer) Download(ctx context.Context, call NodeService_download) error {
 	results, err := call.AllocResults()
@@ -879,40 +1626,24 @@ func (s *nodeServiceServer) Download(ctx context.Context, call NodeService_downl
 		return err
 	}
 
-	// Get shard locations
-	shardLocationsList, err := request.ShardLocations()
+	target, signer, err := s.downloadTarget(request)
 	if err != nil {
-		return err
-	}
-
-	shardCount := shardLocationsList.Len()
-	log.Printf("Download requested for %d shard locations", shardCount)
-
-	// Fetch shards from peers
-	shards := make([]ShardData, shardCount)
-	present := make([]bool, shardCount)
-
-	for i := 0; i < shardCount; i++ {
-		loc := shardLocationsList.At(i)
-		shardIndex := loc.ShardIndex()
-		peerID := loc.PeerId()
-
-		// Fetch shard from peer using network layer
-		log.Printf("Fetching shard %d from peer %d", shardIndex, peerID)
-		shardData, err := s.network.FetchShard(peerID, shardIndex)
-		if err != nil {
-			log.Printf("Warning: Failed to fetch shard %d from peer %d: %v", shardIndex, peerID, err)
-			present[i] = false
-			shards[i] = ShardData{Data: nil}
-			continue
+		response, rerr := results.NewResponse()
+		if rerr != nil {
+			return rerr
 		}
-
-		present[i] = true
-		shards[i] = ShardData{Data: shardData}
-		log.Printf("Successfully fetched shard %d (%d bytes)", shardIndex, len(shardData))
+		response.SetSuccess(false)
+		return response.SetErrorMsg(err.Error())
 	}
+	fileHash, locations := target.FileHash, target.Placements
+	log.Printf("Download requested for %d shard locations", len(locations))
+
+	// Fetch shards concurrently, failing over to alternate holders, until
+	// enough are verified to reconstruct (K = dataShards from Reed-Solomon)
+	minRequired := cesDataShards
+	shards, present, fetches := fetchShards(ctx, s.shardSource(), fileHash, locations,
+		cesDataShards+cesParityShards, minRequired, int(request.Parallelism()))
 
-	// Check if we have enough shards to reconstruct
 	presentCount := 0
 	for _, p := range present {
 		if p {
@@ -924,10 +1655,12 @@ func (s *nodeServiceServer) Download(ctx context.Context, call NodeService_downl
 	if err != nil {
 		return err
 	}
+	list, err := response.NewShardFetches(int32(len(fetches)))
+	if err != nil {
+		return err
+	}
+	fillShardFetches(list, fetches)
 
-	// Need at least K shards to reconstruct (K = dataShards from Reed-Solomon)
-	// With 8 data + 4 parity shards, we need at least 8
-	minRequired := 8 // TODO: Get this from CES config
 	if presentCount < minRequired {
 		response.SetSuccess(false)
 		response.SetErrorMsg(fmt.Sprintf("Insufficient shards: have %d, need at least %d", presentCount, minRequired))
@@ -935,21 +1668,111 @@ func (s *nodeServiceServer) Download(ctx context.Context, call NodeService_downl
 		return nil
 	}
 
-	// Derive file-specific key via DKG (simulated) using fileHash provided in request
-	fileHashStr, err := request.FileHash()
+	reconstructed, err := s.reconstructDownload(ctx, fileHash, locations, shards, present)
+	if err == nil {
+		err = checkContentHash(reconstructed, target.ContentHash)
+	}
 	if err != nil {
 		response.SetSuccess(false)
-		response.SetErrorMsg("Missing fileHash in request")
+		response.SetErrorMsg(err.Error())
 		response.SetBytesDownloaded(0)
 		return nil
 	}
 
-	// Collect peer IDs from shard locations to request shares
-	peersList := make([]uint32, 0)
-	for i := 0; i < shardCount; i++ {
-		loc := shardLocationsList.At(i)
-		peerID := loc.PeerId()
-		peersList = append(peersList, peerID)
+	// Return reconstructed data
+	response.SetSuccess(true)
+	response.SetSigner(signer)
+	response.SetData(reconstructed)
+	response.SetBytesDownloaded(uint64(len(reconstructed)))
+
+	log.Printf("Successfully reconstructed %d bytes from %d shards", len(reconstructed), presentCount)
+
+	return nil
+}
+
+// downloadTarget reads the file hash, content hash and shard locations a
+// download request asks for, from its manifest once the signature checks
+// out, or from its bare fields on a legacy-only node. It returns the
+// manifest's signer, "" for an unsigned one.
+func (s *nodeServiceServer) downloadTarget(request DownloadRequest) (*fileManifest, string, error) {
+	if request.HasManifest() {
+		in, err := request.Manifest()
+		if err != nil {
+			return nil, "", err
+		}
+		m, err := readFileManifest(in)
+		if err != nil {
+			return nil, "", err
+		}
+		expected, err := request.ExpectedSigner()
+		if err != nil {
+			return nil, "", err
+		}
+		signer, err := s.checkManifest(manifestKindFile, m.signedContent(), m.Signature, expected)
+		if err != nil {
+			return nil, "", err
+		}
+		return m, signer, nil
+	}
+	// Bare shard locations cannot be checked against anything, so only a
+	// legacy-only node, which signs nothing, accepts them
+	if lib, ok := libp2pOf(s.network); ok && lib.node != nil {
+		return nil, "", fmt.Errorf("%w: downloads need the signed manifest from the upload", errManifestUnsigned)
+	}
+	fileHash, err := request.FileHash()
+	if err != nil {
+		return nil, "", err
+	}
+	contentHash, _ := request.ContentHash()
+	locations, err := downloadLocations(request)
+	if err != nil {
+		return nil, "", err
+	}
+	return &fileManifest{FileHash: fileHash, ContentHash: contentHash, Placements: locations}, "", nil
+}
+
+// downloadLocations reads a download request's shard locations
+func downloadLocations(request DownloadRequest) ([]shardPlacement, error) {
+	list, err := request.ShardLocations()
+	if err != nil {
+		return nil, err
+	}
+	return shardLocationsFrom(list), nil
+}
+
+// shardLocationsFrom reads shard locations as placements to fetch from
+func shardLocationsFrom(list ShardLocation_List) []shardPlacement {
+	locations := make([]shardPlacement, list.Len())
+	for i := range locations {
+		loc := list.At(i)
+		shardHash, _ := loc.ShardHash()
+		locations[i] = shardPlacement{shardIndex: loc.ShardIndex(), peerID: loc.PeerId(), shardHash: shardHash}
+	}
+	return locations
+}
+
+// shardSource fetches shards over the network adapter, looking up DHT
+// providers when it is libp2p
+func (s *nodeServiceServer) shardSource() shardSource {
+	src := shardSource{fetch: s.network.FetchShard}
+	if lib, ok := libp2pOf(s.network); ok {
+		src.providers = lib.ShardProviders
+	}
+	return src
+}
+
+// reconstructDownload recovers the file key from the shard holders' DKG
+// shares and decodes the file from its shards
+func (s *nodeServiceServer) reconstructDownload(ctx context.Context, fileHash string, locations []shardPlacement, shards []ShardData, present []bool) ([]byte, error) {
+	// Collect peer IDs from shard locations to request shares; a peer listed
+	// as the holder of several shards holds one share
+	peersList := make([]uint32, 0, len(locations))
+	seen := make(map[uint32]bool)
+	for _, loc := range locations {
+		if !seen[loc.peerID] {
+			seen[loc.peerID] = true
+			peersList = append(peersList, loc.peerID)
+		}
 	}
 
 	// Determine threshold (same logic as upload)
@@ -958,33 +1781,15 @@ func (s *nodeServiceServer) Download(ctx context.Context, call NodeService_downl
 		threshold = (len(peersList) / 2) + 1
 	}
 
-	// Create adapter wrapper implementing DKGAdapter
-	var adapter dkg.DKGAdapter
-	if lib, ok := s.network.(*LibP2PAdapter); ok {
-		adapter = lib
-	} else if legacy, ok := s.network.(*LegacyP2PAdapter); ok {
-		adapter = legacy
-	} else {
-		response.SetSuccess(false)
-		response.SetErrorMsg("Network adapter does not support DKG operations")
-		response.SetBytesDownloaded(0)
-		return nil
-	}
-
-	// getLocalShare callback
-	getLocalShare := func(fid string) ([]byte, bool) {
-		if lib, ok := s.network.(*LibP2PAdapter); ok {
-			return lib.node.GetLocalShare(fid)
-		}
-		return nil, false
+	// The transport fetches remote shares and holds this node's own
+	transport, ok := s.network.(DataTransport)
+	if !ok {
+		return nil, fmt.Errorf("Network adapter does not support DKG operations")
 	}
 
-	fileKeyBytes, err := dkg.ReconstructFileKeyDistributed(ctx, fileHashStr, peersList, threshold, adapter, getLocalShare)
+	fileKeyBytes, err := dkg.ReconstructFileKeyDistributed(ctx, fileHash, peersList, threshold, transport, transport.GetLocalShare)
 	if err != nil {
-		response.SetSuccess(false)
-		response.SetErrorMsg(fmt.Sprintf("DKG key reconstruction failed: %v", err))
-		response.SetBytesDownloaded(0)
-		return nil
+		return nil, fmt.Errorf("DKG key reconstruction failed: %v", err)
 	}
 
 	var keyArr [32]byte
@@ -992,29 +1797,139 @@ func (s *nodeServiceServer) Download(ctx context.Context, call NodeService_downl
 
 	pipeline := NewCESPipelineWithKey(3, keyArr)
 	if pipeline == nil {
-		response.SetSuccess(false)
-		response.SetErrorMsg("Failed to create CES pipeline with key")
-		response.SetBytesDownloaded(0)
-		return nil
+		return nil, fmt.Errorf("Failed to create CES pipeline with key")
 	}
 	defer pipeline.Close()
 
 	// Reconstruct data from shards
 	reconstructed, err := pipeline.Reconstruct(shards, present)
 	if err != nil {
-		response.SetSuccess(false)
-		response.SetErrorMsg(fmt.Sprintf("CES reconstruction failed: %v", err))
-		response.SetBytesDownloaded(0)
-		return nil
+		return nil, fmt.Errorf("CES reconstruction failed: %v", err)
 	}
+	return reconstructed, nil
+}
 
-	// Return reconstructed data
-	response.SetSuccess(true)
-	response.SetData(reconstructed)
-	response.SetBytesDownloaded(uint64(len(reconstructed)))
+// fillShardFetches copies per-shard fetch results into their RPC form
+func fillShardFetches(list ShardFetch_List, fetches []shardFetch) {
+	for i, f := range fetches {
+		entry := list.At(i)
+		entry.SetShardIndex(f.shardIndex)
+		entry.SetPeerId(f.peerID)
+		entry.SetAttempts(f.attempts)
+		entry.SetDurationMs(float32(f.duration.Seconds() * 1000))
+		entry.SetVerified(f.verified)
+		if f.err != nil {
+			entry.SetErrorMsg(f.err.Error())
+		}
+	}
+}
 
-	log.Printf("Successfully reconstructed %d bytes from %d shards", len(reconstructed), presentCount)
+// downloadSessions returns the node's background download manager
+func (s *nodeServiceServer) downloadSessions() (*DownloadManager, error) {
+	lib, ok := libp2pOf(s.network)
+	if !ok || lib.node == nil || lib.node.GetDownloadManager() == nil {
+		return nil, fmt.Errorf("download sessions require a libp2p node")
+	}
+	return lib.node.GetDownloadManager(), nil
+}
+
+func (s *nodeServiceServer) StartDownload(ctx context.Context, call NodeService_startDownload) error {
+	request, err := call.Args().Request()
+	if err != nil {
+		return err
+	}
+	parallelism := int(request.Parallelism())
+
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	target, _, err := s.downloadTarget(request)
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	fileHash, locations := target.FileHash, target.Placements
+	downloads, err := s.downloadSessions()
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	reconstruct := func(ctx context.Context, shards []ShardData, present []bool) ([]byte, error) {
+		data, err := s.reconstructDownload(ctx, fileHash, locations, shards, present)
+		if err == nil {
+			err = checkContentHash(data, target.ContentHash)
+		}
+		return data, err
+	}
+	id, err := downloads.Start(fileHash, locations, parallelism, s.shardSource(), reconstruct)
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	results.SetSuccess(true)
+	return results.SetSessionId(id)
+}
+
+func (s *nodeServiceServer) GetDownloadProgress(ctx context.Context, call NodeService_getDownloadProgress) error {
+	id, _ := call.Args().SessionId()
+
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	downloads, err := s.downloadSessions()
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	session, ok := downloads.Session(id)
+	if !ok {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(fmt.Sprintf("download %s not found", id))
+	}
+
+	progress, err := results.NewProgress()
+	if err != nil {
+		return err
+	}
+	progress.SetSessionId(session.ID)
+	progress.SetFileHash(session.FileHash)
+	progress.SetState(session.State)
+	progress.SetShardsVerified(session.ShardsVerified)
+	progress.SetShardsRequired(session.ShardsRequired)
+	progress.SetShardsResumed(session.ShardsResumed)
+	progress.SetBytesFetched(session.BytesFetched)
+	progress.SetError(session.Error)
+	progress.SetData(session.Data)
+	progress.SetUpdatedAt(session.Updated.Unix())
+	list, err := progress.NewShardFetches(int32(len(session.Fetches)))
+	if err != nil {
+		return err
+	}
+	fillShardFetches(list, session.Fetches)
+	results.SetSuccess(true)
+	return nil
+}
+
+func (s *nodeServiceServer) CancelDownload(ctx context.Context, call NodeService_cancelDownload) error {
+	args := call.Args()
+	id, _ := args.SessionId()
+	discard := args.Discard()
 
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	downloads, err := s.downloadSessions()
+	if err == nil {
+		err = downloads.Cancel(id, discard)
+	}
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	results.SetSuccess(true)
 	return nil
 }
 
@@ -1047,6 +1962,7 @@ func (s *nodeServiceServer) StartStreaming(ctx context.Context, call NodeService
 	if s.streamingService == nil {
 		streamConfig := DefaultGoStreamConfig()
 		s.streamingService = NewStreamingService(streamConfig)
+		s.streamingService.bandwidth = bandwidthOf(s.network)
 	}
 
 	// Start UDP for video/audio
@@ -1184,11 +2100,6 @@ func (s *nodeServiceServer) SendChatMessage(ctx context.Context, call NodeServic
 		return err
 	}
 
-	if s.streamingService == nil {
-		results.SetSuccess(false)
-		return nil
-	}
-
 	args := call.Args()
 	chatMsg, err := args.Message_()
 	if err != nil {
@@ -1208,6 +2119,27 @@ func (s *nodeServiceServer) SendChatMessage(ctx context.Context, call NodeServic
 		return nil
 	}
 
+	// A libp2p peer ID goes over the always-on chat protocol
+	if peerID, perr := peer.Decode(peerAddr); perr == nil {
+		cs, err := s.communicationService()
+		if err == nil {
+			err = cs.SendChatMessage(peerID, message)
+		}
+		if err != nil {
+			log.Printf("Failed to send chat message: %v", err)
+			results.SetSuccess(false)
+			return nil
+		}
+		chatMessagesTotal.WithLabelValues("sent").Inc()
+		results.SetSuccess(true)
+		return nil
+	}
+
+	if s.streamingService == nil {
+		results.SetSuccess(false)
+		return nil
+	}
+
 	// Send via Go's TCP
 	err = s.streamingService.SendChatMessage(peerAddr, message)
 	if err != nil {
@@ -1216,6 +2148,7 @@ func (s *nodeServiceServer) SendChatMessage(ctx context.Context, call NodeServic
 		return nil
 	}
 
+	chatMessagesTotal.WithLabelValues("sent").Inc()
 	results.SetSuccess(true)
 	return nil
 }
@@ -1305,10 +2238,40 @@ func (s *nodeServiceServer) SubmitComputeJob(ctx context.Context, call NodeServi
 		return nil
 	}
 
-	// Extract manifest fields
+	jobManifest := computeJobManifest(manifest)
+
+	// The job's delegated tasks continue this span's trace
+	ctx, span := tracer.Start(ctx, "NodeService.submitComputeJob", trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attribute.Int("compute.input_bytes", len(jobManifest.InputData))))
+	jobManifest.TraceContext = injectTraceContext(ctx)
+
+	// Submit job
+	log.Printf("📤 [COMPUTE] Received job submission: %s (input size: %d bytes)", jobManifest.JobID, len(jobManifest.InputData))
+	submittedJobID, err := s.computeManager.SubmitJob(jobManifest)
+	if err != nil {
+		endSpan(span, err)
+		log.Printf("❌ [COMPUTE] Job submission failed: %v", err)
+		results.SetSuccess(false)
+		results.SetErrorMsg(fmt.Sprintf("Failed to submit job: %v", err))
+		return nil
+	}
+	span.SetAttributes(attribute.String("compute.job_id", submittedJobID))
+	span.End()
+
+	log.Printf("✅ [COMPUTE] Job submitted successfully: %s", submittedJobID)
+	results.SetJobId(submittedJobID)
+	results.SetSuccess(true)
+	results.SetErrorMsg("")
+	return nil
+}
+
+// computeJobManifest converts an RPC job manifest for the compute manager
+func computeJobManifest(manifest ComputeJobManifest) *compute.JobManifest {
 	jobID, _ := manifest.JobId()
 	wasmModule, _ := manifest.WasmModule()
 	inputDataRef, _ := manifest.InputData()
+	splitStrategy, _ := manifest.SplitStrategy()
+	reducer, _ := manifest.Reducer()
 	minChunkSize := manifest.MinChunkSize()
 	maxChunkSize := manifest.MaxChunkSize()
 	timeoutSecs := manifest.TimeoutSecs()
@@ -1320,17 +2283,28 @@ func (s *nodeServiceServer) SubmitComputeJob(ctx context.Context, call NodeServi
 	// which gets recycled after RPC completes
 	inputData := make([]byte, len(inputDataRef))
 	copy(inputData, inputDataRef)
+	wasmModule = append([]byte(nil), wasmModule...)
 
 	// Debug: log first bytes of input data
 	if len(inputData) > 16 {
 		log.Printf("📊 [COMPUTE] Input data first 16 bytes: %x", inputData[:16])
 	}
 
-	// Create job manifest for manager
-	jobManifest := &compute.JobManifest{
-		JobID:            jobID,
-		WASMModule:       wasmModule,
+	var dependsOn []string
+	if deps, err := manifest.DependsOn(); err == nil {
+		dependsOn = textListStrings(deps)
+	}
+	var postProcess []string
+	if steps, err := manifest.PostProcess(); err == nil {
+		postProcess = textListStrings(steps)
+	}
+
+	return &compute.JobManifest{
+		JobID:            jobID,
+		WASMModule:       wasmModule,
 		InputData:        inputData,
+		SplitStrategy:    splitStrategy,
+		Reducer:          reducer,
 		MinChunkSize:     int64(minChunkSize),
 		MaxChunkSize:     int64(maxChunkSize),
 		TimeoutSecs:      timeoutSecs,
@@ -1338,22 +2312,264 @@ func (s *nodeServiceServer) SubmitComputeJob(ctx context.Context, call NodeServi
 		Priority:         priority,
 		Redundancy:       redundancy,
 		VerificationMode: compute.VerificationHash,
+		DependsOn:        dependsOn,
+		PostProcess:      postProcess,
+		StartAt:          unixTime(manifest.StartAtUnix()),
+		RecordReplay:     manifest.RecordReplay(),
 	}
+}
 
-	// Submit job
-	log.Printf("📤 [COMPUTE] Received job submission: %s (input size: %d bytes)", jobID, len(inputData))
-	submittedJobID, err := s.computeManager.SubmitJob(jobManifest)
+// unixTime converts Unix seconds to a time; 0 is the zero time
+func unixTime(secs int64) time.Time {
+	if secs == 0 {
+		return time.Time{}
+	}
+	return time.Unix(secs, 0)
+}
+
+// textListStrings copies a Cap'n Proto text list
+func textListStrings(list capnp.TextList) []string {
+	out := make([]string, 0, list.Len())
+	for i := 0; i < list.Len(); i++ {
+		s, _ := list.At(i)
+		out = append(out, s)
+	}
+	return out
+}
+
+// SubmitComputeJobGraph implements the submitComputeJobGraph method
+func (s *nodeServiceServer) SubmitComputeJobGraph(ctx context.Context, call NodeService_submitComputeJobGraph) error {
+	manifestList, err := call.Args().Manifests()
 	if err != nil {
-		log.Printf("❌ [COMPUTE] Job submission failed: %v", err)
+		return err
+	}
+	manifests := make([]*compute.JobManifest, manifestList.Len())
+	for i := range manifests {
+		manifests[i] = computeJobManifest(manifestList.At(i))
+	}
+
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+
+	// Every stage's delegated tasks continue this span's trace
+	ctx, span := tracer.Start(ctx, "NodeService.submitComputeJobGraph", trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attribute.Int("compute.stages", len(manifests))))
+	traceHeaders := injectTraceContext(ctx)
+	for _, m := range manifests {
+		m.TraceContext = traceHeaders
+	}
+
+	log.Printf("📤 [COMPUTE] Received job graph submission: %d stages", len(manifests))
+	jobIDs, err := s.computeManager.SubmitJobGraph(manifests)
+	endSpan(span, err)
+	if err != nil {
+		log.Printf("❌ [COMPUTE] Job graph submission failed: %v", err)
 		results.SetSuccess(false)
-		results.SetErrorMsg(fmt.Sprintf("Failed to submit job: %v", err))
-		return nil
+		return results.SetErrorMsg(err.Error())
 	}
 
-	log.Printf("✅ [COMPUTE] Job submitted successfully: %s", submittedJobID)
-	results.SetJobId(submittedJobID)
+	ids, err := results.NewJobIds(int32(len(jobIDs)))
+	if err != nil {
+		return err
+	}
+	for i, id := range jobIDs {
+		if err := ids.Set(i, id); err != nil {
+			return err
+		}
+	}
 	results.SetSuccess(true)
-	results.SetErrorMsg("")
+	return nil
+}
+
+// PutJobTemplate implements the putJobTemplate method
+func (s *nodeServiceServer) PutJobTemplate(ctx context.Context, call NodeService_putJobTemplate) error {
+	template, err := call.Args().Template()
+	if err != nil {
+		return err
+	}
+	name, _ := template.Name()
+	description, _ := template.Description()
+	wasmModule, _ := template.WasmModule()
+	splitStrategy, _ := template.SplitStrategy()
+	reducer, _ := template.Reducer()
+	modeName, _ := template.VerificationMode()
+	var postProcess []string
+	if steps, err := template.PostProcess(); err == nil {
+		postProcess = textListStrings(steps)
+	}
+
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	mode, err := compute.ParseVerificationMode(modeName)
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+
+	version, err := s.computeManager.GetTemplates().Put(compute.JobTemplate{
+		Name:             name,
+		Description:      description,
+		WASMModule:       wasmModule, // Copied by Put
+		SplitStrategy:    splitStrategy,
+		Reducer:          reducer,
+		MinChunkSize:     int64(template.MinChunkSize()),
+		MaxChunkSize:     int64(template.MaxChunkSize()),
+		VerificationMode: mode,
+		TimeoutSecs:      template.TimeoutSecs(),
+		RetryCount:       template.RetryCount(),
+		Priority:         template.Priority(),
+		Redundancy:       template.Redundancy(),
+		PostProcess:      postProcess,
+	})
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	results.SetVersion(version)
+	results.SetSuccess(true)
+	return nil
+}
+
+// GetJobTemplate implements the getJobTemplate method
+func (s *nodeServiceServer) GetJobTemplate(ctx context.Context, call NodeService_getJobTemplate) error {
+	args := call.Args()
+	name, _ := args.Name()
+	version := args.Version()
+
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	t, err := s.computeManager.GetTemplates().Get(name, version)
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	template, err := results.NewTemplate()
+	if err != nil {
+		return err
+	}
+	if err := fillJobTemplate(template, t); err != nil {
+		return err
+	}
+	results.SetSuccess(true)
+	return nil
+}
+
+// ListJobTemplates implements the listJobTemplates method
+func (s *nodeServiceServer) ListJobTemplates(ctx context.Context, call NodeService_listJobTemplates) error {
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	templates := s.computeManager.GetTemplates().List()
+	list, err := results.NewTemplates(int32(len(templates)))
+	if err != nil {
+		return err
+	}
+	for i, t := range templates {
+		if err := fillJobTemplate(list.At(i), t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteJobTemplate implements the deleteJobTemplate method
+func (s *nodeServiceServer) DeleteJobTemplate(ctx context.Context, call NodeService_deleteJobTemplate) error {
+	name, _ := call.Args().Name()
+
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	if err := s.computeManager.GetTemplates().Delete(name); err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	results.SetSuccess(true)
+	return nil
+}
+
+// SubmitTemplateJob implements the submitTemplateJob method
+func (s *nodeServiceServer) SubmitTemplateJob(ctx context.Context, call NodeService_submitTemplateJob) error {
+	args := call.Args()
+	name, _ := args.Name()
+	jobID, _ := args.JobId()
+	inputData, _ := args.InputData()
+	params := compute.TemplateParams{
+		JobID:     jobID,
+		InputData: append([]byte(nil), inputData...), // Outlives the RPC message
+		Overrides: make(map[string]string),
+	}
+	if list, err := args.Parameters(); err == nil {
+		for i := 0; i < list.Len(); i++ {
+			key, _ := list.At(i).Key()
+			value, _ := list.At(i).Value()
+			params.Overrides[key] = value
+		}
+	}
+
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	submittedJobID, err := s.computeManager.SubmitTemplateJob(name, args.Version(), params)
+	if err != nil {
+		log.Printf("❌ [COMPUTE] Template job submission failed: %v", err)
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+
+	log.Printf("✅ [COMPUTE] Job %s submitted from template %s", submittedJobID, name)
+	if err := results.SetJobId(submittedJobID); err != nil {
+		return err
+	}
+	results.SetSuccess(true)
+	return nil
+}
+
+// fillJobTemplate copies a stored template into an RPC message
+func fillJobTemplate(msg JobTemplate, t compute.JobTemplate) error {
+	if err := msg.SetName(t.Name); err != nil {
+		return err
+	}
+	msg.SetVersion(t.Version)
+	if err := msg.SetDescription(t.Description); err != nil {
+		return err
+	}
+	if err := msg.SetWasmModule(t.WASMModule); err != nil {
+		return err
+	}
+	if err := msg.SetSplitStrategy(t.SplitStrategy); err != nil {
+		return err
+	}
+	if err := msg.SetReducer(t.Reducer); err != nil {
+		return err
+	}
+	msg.SetMinChunkSize(uint64(t.MinChunkSize))
+	msg.SetMaxChunkSize(uint64(t.MaxChunkSize))
+	if err := msg.SetVerificationMode(t.VerificationMode.String()); err != nil {
+		return err
+	}
+	msg.SetTimeoutSecs(t.TimeoutSecs)
+	msg.SetRetryCount(t.RetryCount)
+	msg.SetPriority(t.Priority)
+	msg.SetRedundancy(t.Redundancy)
+	msg.SetCreated(t.Created.Unix())
+	steps, err := msg.NewPostProcess(int32(len(t.PostProcess)))
+	if err != nil {
+		return err
+	}
+	for i, step := range t.PostProcess {
+		if err := steps.Set(i, step); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -1398,6 +2614,55 @@ func (s *nodeServiceServer) GetComputeJobStatus(ctx context.Context, call NodeSe
 	status.SetEstimatedTimeRemaining(jobStatus.EstimatedTimeRemaining)
 	status.SetErrorMsg("")
 
+	chunks, err := status.NewChunks(int32(len(jobStatus.Chunks)))
+	if err != nil {
+		return err
+	}
+	for i, c := range jobStatus.Chunks {
+		chunk := chunks.At(i)
+		chunk.SetIndex(c.Index)
+		chunk.SetStatus(c.Status.String())
+		chunk.SetAssignedWorker(c.AssignedWorker)
+		attempts, err := chunk.NewAttempts(int32(len(c.Attempts)))
+		if err != nil {
+			return err
+		}
+		for j, a := range c.Attempts {
+			attempt := attempts.At(j)
+			attempt.SetWorkerId(a.WorkerID)
+			attempt.SetStartedAt(a.StartedAt.UnixMilli())
+			attempt.SetDurationMs(uint64(a.Duration.Milliseconds()))
+			attempt.SetErrorMsg(a.Error)
+		}
+	}
+	return nil
+}
+
+// ListComputeJobs implements the listComputeJobs method
+func (s *nodeServiceServer) ListComputeJobs(ctx context.Context, call NodeService_listComputeJobs) error {
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+
+	jobs := s.computeManager.ListJobs()
+	list, err := results.NewJobs(int32(len(jobs)))
+	if err != nil {
+		return err
+	}
+	for i, job := range jobs {
+		status := list.At(i)
+		if err := status.SetJobId(job.JobID); err != nil {
+			return err
+		}
+		if err := status.SetStatus(job.Status.String()); err != nil {
+			return err
+		}
+		status.SetProgress(job.Progress)
+		status.SetCompletedChunks(job.CompletedChunks)
+		status.SetTotalChunks(job.TotalChunks)
+		status.SetEstimatedTimeRemaining(job.EstimatedTimeRemaining)
+	}
 	return nil
 }
 
@@ -1497,833 +2762,5010 @@ func (s *nodeServiceServer) GetComputeCapacity(ctx context.Context, call NodeSer
 	capacity.SetCurrentLoad(cap.CurrentLoad)
 	capacity.SetDiskMb(cap.DiskMB)
 	capacity.SetBandwidthMbps(cap.BandwidthMbps)
+	capacity.SetTotalRamMb(cap.TotalRAMMB)
+
+	coreLoads, err := capacity.NewCoreLoads(int32(len(cap.CoreLoads)))
+	if err != nil {
+		return err
+	}
+	for i, load := range cap.CoreLoads {
+		coreLoads.Set(i, load)
+	}
 
 	return nil
 }
 
-// =============================================================================
-// mDNS Discovery Methods
-// =============================================================================
-// GetMdnsDiscovered implements the getMdnsDiscovered method
-func (s *nodeServiceServer) GetMdnsDiscovered(ctx context.Context, call NodeService_getMdnsDiscovered) error {
-	results, err := call.AllocResults()
+// GetComputeUsage implements the getComputeUsage method
+func (s *nodeServiceServer) GetComputeUsage(ctx context.Context, call NodeService_getComputeUsage) error {
+	jobID, err := call.Args().JobId()
 	if err != nil {
 		return err
 	}
-
-	// For now, return connected peers as they were likely discovered via mDNS
-	// In a full implementation, we'd track specifically which peers came from mDNS
-	connectedPeers := s.network.GetConnectedPeers()
-
-	peers, err := results.NewPeers(int32(len(connectedPeers)))
+	workerID, err := call.Args().WorkerId()
 	if err != nil {
 		return err
 	}
 
-	for i, peerID := range connectedPeers {
-		peer := peers.At(i)
-		peer.SetPeerId(fmt.Sprintf("%d", peerID))
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
 
-		// Set multiaddrs (placeholder - would need actual peer info)
-		addrs, err := peer.NewMultiaddrs(1)
-		if err != nil {
-			continue
+	usage := s.computeManager.GetLedger().Usage(jobID, workerID)
+	list, err := results.NewRecords(int32(len(usage)))
+	if err != nil {
+		return err
+	}
+	for i, u := range usage {
+		rec := list.At(i)
+		if err := rec.SetJobId(u.JobID); err != nil {
+			return err
 		}
-		addrs.Set(0, fmt.Sprintf("/ip4/127.0.0.1/tcp/9090/p2p/%d", peerID))
-
-		// Set discovery timestamp
-		peer.SetDiscoveredAt(time.Now().Unix())
+		if err := rec.SetWorkerId(u.WorkerID); err != nil {
+			return err
+		}
+		rec.SetExecSeconds(u.ExecSeconds)
+		rec.SetInputBytes(u.InputBytes)
+		rec.SetOutputBytes(u.OutputBytes)
+		rec.SetTasksCompleted(u.TasksCompleted)
+		rec.SetTasksFailed(u.TasksFailed)
+		rec.SetLastUpdated(u.LastUpdated.Unix())
 	}
-
-	log.Printf("📡 [MDNS] Returning %d discovered peers", len(connectedPeers))
 	return nil
 }
 
-// ConnectMdnsPeer implements the connectMdnsPeer method
-func (s *nodeServiceServer) ConnectMdnsPeer(ctx context.Context, call NodeService_connectMdnsPeer) error {
+// =============================================================================
+// Store-Forward Relay Methods
+// =============================================================================
+
+// SetRelayMode implements the setRelayMode method
+func (s *nodeServiceServer) SetRelayMode(ctx context.Context, call NodeService_setRelayMode) error {
 	results, err := call.AllocResults()
 	if err != nil {
 		return err
 	}
 
-	args := call.Args()
-	peerID, err := args.PeerID()
-	if err != nil {
+	lib, ok := libp2pOf(s.network)
+	if !ok {
 		results.SetSuccess(false)
-		results.SetErrorMsg(fmt.Sprintf("Invalid peer ID: %v", err))
 		return nil
 	}
-
-	log.Printf("🔗 [MDNS] Connecting to mDNS peer: %s", peerID)
-
-	// The connection would typically happen automatically via libp2p
-	// This is a placeholder for explicit connection logic
+	lib.node.GetRelayService().SetRelayEnabled(call.Args().Enabled())
 	results.SetSuccess(true)
-	results.SetErrorMsg("")
-
-	log.Printf("✅ [MDNS] Successfully connected to peer %s", peerID)
 	return nil
 }
 
-// =============================================================================
-// Configuration Management Methods
-// =============================================================================
+// SetRelayOptIn implements the setRelayOptIn method
+func (s *nodeServiceServer) SetRelayOptIn(ctx context.Context, call NodeService_setRelayOptIn) error {
+	args := call.Args()
+	relayAddr, err := args.RelayAddr()
+	if err != nil {
+		return err
+	}
 
-// LoadConfig implements the loadConfig method
-func (s *nodeServiceServer) LoadConfig(ctx context.Context, call NodeService_loadConfig) error {
 	results, err := call.AllocResults()
 	if err != nil {
 		return err
 	}
 
-	if s.configManager == nil {
+	lib, ok := libp2pOf(s.network)
+	if !ok {
 		results.SetSuccess(false)
-		results.SetErrorMsg("Configuration manager not initialized")
-		return nil
+		return results.SetErrorMsg("store-forward relaying requires libp2p")
+	}
+	if args.OptIn() {
+		err = lib.node.OptInToRelay(relayAddr)
+	} else {
+		err = lib.node.OptOutOfRelay(relayAddr)
 	}
-
-	cfg, err := s.configManager.LoadConfig()
 	if err != nil {
 		results.SetSuccess(false)
-		results.SetErrorMsg(fmt.Sprintf("Failed to load config: %v", err))
-		return nil
+		return results.SetErrorMsg(err.Error())
 	}
+	results.SetSuccess(true)
+	return nil
+}
 
-	// Build Cap'n Proto config structure
-	configData, err := results.NewConfig()
+// GetRelayedMessages implements the getRelayedMessages method
+func (s *nodeServiceServer) GetRelayedMessages(ctx context.Context, call NodeService_getRelayedMessages) error {
+	results, err := call.AllocResults()
 	if err != nil {
 		return err
 	}
 
-	configData.SetNodeId(cfg.NodeID)
-	configData.SetCapnpAddr(cfg.CapnpAddr)
-	configData.SetLibp2pPort(int32(cfg.LibP2PPort))
-	configData.SetUseLibp2p(cfg.UseLibP2P)
-	configData.SetLocalMode(cfg.LocalMode)
-	configData.SetLastSavedAt(cfg.LastSavedAt)
+	var items []RelayItem
+	if lib, ok := libp2pOf(s.network); ok {
+		items = lib.node.GetRelayService().Inbox()
+	}
 
-	// Set bootstrap peers
-	if len(cfg.BootstrapPeers) > 0 {
-		peers, err := configData.NewBootstrapPeers(int32(len(cfg.BootstrapPeers)))
-		if err != nil {
-			return err
-		}
-		for i, peer := range cfg.BootstrapPeers {
-			peers.Set(i, peer)
-		}
+	list, err := results.NewMessages(int32(len(items)))
+	if err != nil {
+		return err
 	}
-
-	// Set custom settings
-	if len(cfg.CustomSettings) > 0 {
-		settings, err := configData.NewCustomSettings(int32(len(cfg.CustomSettings)))
-		if err != nil {
+	for i, item := range items {
+		msg := list.At(i)
+		if err := msg.SetFromPeer(item.From); err != nil {
 			return err
 		}
-		i := 0
-		for key, value := range cfg.CustomSettings {
-			kv := settings.At(i)
-			kv.SetKey(key)
-			kv.SetValue(value)
-			i++
+		if err := msg.SetData(item.Payload); err != nil {
+			return err
 		}
+		msg.SetStoredAt(item.StoredAt)
 	}
-
-	results.SetSuccess(true)
-	results.SetErrorMsg("")
-	log.Printf("✅ [CONFIG] Configuration loaded successfully")
 	return nil
 }
 
-// SaveConfig implements the saveConfig method
-func (s *nodeServiceServer) SaveConfig(ctx context.Context, call NodeService_saveConfig) error {
-	results, err := call.AllocResults()
-	if err != nil {
-		return err
-	}
+// =============================================================================
+// Chat Filtering Methods
+// =============================================================================
 
-	if s.configManager == nil {
-		results.SetSuccess(false)
-		results.SetErrorMsg("Configuration manager not initialized")
-		return nil
+// communicationService returns the node's chat service, which only runs in libp2p mode
+func (s *nodeServiceServer) communicationService() (*communication.CommunicationService, error) {
+	lib, ok := libp2pOf(s.network)
+	if !ok || lib.node == nil || lib.node.GetCommunicationService() == nil {
+		return nil, fmt.Errorf("this requires the libp2p communication service")
 	}
+	return lib.node.GetCommunicationService(), nil
+}
 
+// SetChatPeerPolicy implements the setChatPeerPolicy method
+func (s *nodeServiceServer) SetChatPeerPolicy(ctx context.Context, call NodeService_setChatPeerPolicy) error {
 	args := call.Args()
-	configData, err := args.Config()
+	peerID, err := args.PeerId()
 	if err != nil {
-		results.SetSuccess(false)
-		results.SetErrorMsg(fmt.Sprintf("Invalid config data: %v", err))
-		return nil
+		return err
+	}
+	policyName, err := args.Policy()
+	if err != nil {
+		return err
 	}
 
-	// Build NodeConfig from Cap'n Proto structure
-	capnpAddr, _ := configData.CapnpAddr()
-	cfg := &NodeConfig{
-		NodeID:         configData.NodeId(),
-		CapnpAddr:      capnpAddr,
-		LibP2PPort:     int(configData.Libp2pPort()),
-		UseLibP2P:      configData.UseLibp2p(),
-		LocalMode:      configData.LocalMode(),
-		CustomSettings: make(map[string]string),
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
 	}
 
-	// Extract bootstrap peers
-	peers, err := configData.BootstrapPeers()
-	if err == nil && peers.Len() > 0 {
-		cfg.BootstrapPeers = make([]string, peers.Len())
-		for i := 0; i < peers.Len(); i++ {
-			peer, _ := peers.At(i)
-			cfg.BootstrapPeers[i] = peer
+	cs, err := s.communicationService()
+	if err == nil {
+		var policy communication.PeerPolicy
+		if policy, err = communication.ParsePeerPolicy(policyName); err == nil {
+			cs.SetPeerPolicy(peerID, policy)
 		}
 	}
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	results.SetSuccess(true)
+	return nil
+}
 
-	// Extract custom settings
-	settings, err := configData.CustomSettings()
-	if err == nil && settings.Len() > 0 {
-		for i := 0; i < settings.Len(); i++ {
-			kv := settings.At(i)
-			key, _ := kv.Key()
-			value, _ := kv.Value()
-			cfg.CustomSettings[key] = value
-		}
+// SetChatClassifier implements the setChatClassifier method
+func (s *nodeServiceServer) SetChatClassifier(ctx context.Context, call NodeService_setChatClassifier) error {
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
 	}
 
-	// Save configuration
-	if err := s.configManager.SaveConfig(cfg); err != nil {
+	cs, err := s.communicationService()
+	if err != nil {
 		results.SetSuccess(false)
-		results.SetErrorMsg(fmt.Sprintf("Failed to save config: %v", err))
-		return nil
+		return results.SetErrorMsg(err.Error())
 	}
 
+	args := call.Args()
+	if args.Enabled() {
+		cs.SetClassifier(communication.NewQueueClassifier(time.Duration(args.TimeoutMs()) * time.Millisecond))
+	} else {
+		cs.SetClassifier(nil)
+	}
 	results.SetSuccess(true)
-	results.SetErrorMsg("")
-	log.Printf("✅ [CONFIG] Configuration saved successfully")
 	return nil
 }
 
-// UpdateConfigValue implements the updateConfigValue method
-func (s *nodeServiceServer) UpdateConfigValue(ctx context.Context, call NodeService_updateConfigValue) error {
+// GetPendingChatClassifications implements the getPendingChatClassifications method
+func (s *nodeServiceServer) GetPendingChatClassifications(ctx context.Context, call NodeService_getPendingChatClassifications) error {
 	results, err := call.AllocResults()
 	if err != nil {
 		return err
 	}
 
-	if s.configManager == nil {
-		results.SetSuccess(false)
-		return nil
+	var pending []communication.QuarantinedMessage
+	if cs, err := s.communicationService(); err == nil {
+		if q, ok := cs.GetClassifier().(*communication.QueueClassifier); ok {
+			pending = q.Pending()
+		}
 	}
+	return setFilteredChatMessages(results.NewMessages, pending)
+}
 
+// ClassifyChatMessage implements the classifyChatMessage method
+func (s *nodeServiceServer) ClassifyChatMessage(ctx context.Context, call NodeService_classifyChatMessage) error {
 	args := call.Args()
 	key, err := args.Key()
 	if err != nil {
-		results.SetSuccess(false)
-		return nil
+		return err
 	}
-
-	value, err := args.Value()
+	actionName, err := args.Action()
 	if err != nil {
-		results.SetSuccess(false)
-		return nil
+		return err
 	}
-
-	// Update config value
-	if err := s.configManager.UpdateConfig(key, value); err != nil {
-		results.SetSuccess(false)
-		return nil
+	tagList, err := args.Tags()
+	if err != nil {
+		return err
 	}
-
-	// Save the updated config
-	cfg := s.configManager.GetConfig()
-	if err := s.configManager.SaveConfig(cfg); err != nil {
-		log.Printf("⚠️  [CONFIG] Failed to save after update: %v", err)
+	tags := make([]string, tagList.Len())
+	for i := range tags {
+		if tags[i], err = tagList.At(i); err != nil {
+			return err
+		}
 	}
 
-	results.SetSuccess(true)
-	log.Printf("✅ [CONFIG] Updated config: %s = %s", key, value)
-	return nil
-}
-
-// =============================================================================
-// Streaming Auxiliary Accessors
-// =============================================================================
-
-func (s *nodeServiceServer) GetReceivedFrames(ctx context.Context, call NodeService_getReceivedFrames) error {
 	results, err := call.AllocResults()
 	if err != nil {
 		return err
 	}
 
-	// No in-memory buffer yet; return empty list
-	frames, err := NewVideoFrame_List(results.Segment(), 0)
+	cs, err := s.communicationService()
+	if err == nil {
+		q, ok := cs.GetClassifier().(*communication.QueueClassifier)
+		if !ok {
+			err = fmt.Errorf("RPC chat classifier is not enabled")
+		} else {
+			var action communication.FilterAction
+			if action, err = communication.ParseFilterAction(actionName); err == nil {
+				err = q.Resolve(key, communication.FilterVerdict{Action: action, Reason: "classifier", Tags: tags})
+			}
+		}
+	}
 	if err != nil {
-		return err
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
 	}
-	return results.SetFrames(VideoFrame_List(frames))
+	results.SetSuccess(true)
+	return nil
 }
 
-func (s *nodeServiceServer) GetReceivedAudio(ctx context.Context, call NodeService_getReceivedAudio) error {
+// GetQuarantinedMessages implements the getQuarantinedMessages method
+func (s *nodeServiceServer) GetQuarantinedMessages(ctx context.Context, call NodeService_getQuarantinedMessages) error {
 	results, err := call.AllocResults()
 	if err != nil {
 		return err
 	}
 
-	chunks, err := NewAudioChunk_List(results.Segment(), 0)
+	var held []communication.QuarantinedMessage
+	if cs, err := s.communicationService(); err == nil {
+		held = cs.GetQuarantinedMessages()
+	}
+	return setFilteredChatMessages(results.NewMessages, held)
+}
+
+// ReviewQuarantinedMessage implements the reviewQuarantinedMessage method
+func (s *nodeServiceServer) ReviewQuarantinedMessage(ctx context.Context, call NodeService_reviewQuarantinedMessage) error {
+	args := call.Args()
+	key, err := args.Key()
 	if err != nil {
 		return err
 	}
-	return results.SetChunks(AudioChunk_List(chunks))
-}
 
-func (s *nodeServiceServer) GetLocalMultiaddr(ctx context.Context, call NodeService_getLocalMultiaddr) error {
 	results, err := call.AllocResults()
 	if err != nil {
 		return err
 	}
 
-	multiaddr := ""
-	if lib, ok := s.network.(*LibP2PAdapter); ok && lib.node != nil {
-		addrs := lib.node.LocalMultiaddrs(false)
-		if len(addrs) == 0 {
-			addrs = lib.node.LocalMultiaddrs(true)
-		}
-		if len(addrs) > 0 {
-			multiaddr = addrs[0]
+	cs, err := s.communicationService()
+	if err == nil {
+		if args.Release() {
+			err = cs.ReleaseQuarantined(key)
+		} else {
+			err = cs.DeleteQuarantined(key)
 		}
 	}
-
-	results.SetMultiaddr(multiaddr)
-	return nil
-}
-
-func (s *nodeServiceServer) ListLibp2pPeers(ctx context.Context, call NodeService_listLibp2pPeers) error {
-	results, err := call.AllocResults()
 	if err != nil {
-		return err
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
 	}
+	results.SetSuccess(true)
+	return nil
+}
 
-	peers := []string{}
-	list, err := capnp.NewTextList(results.Segment(), int32(len(peers)))
+// setFilteredChatMessages fills a result list of held chat messages
+func setFilteredChatMessages(newList func(int32) (FilteredChatMessage_List, error), held []communication.QuarantinedMessage) error {
+	list, err := newList(int32(len(held)))
 	if err != nil {
 		return err
 	}
-	for i, p := range peers {
-		list.Set(i, p)
+	for i, q := range held {
+		msg := list.At(i)
+		if err := msg.SetKey(q.Key); err != nil {
+			return err
+		}
+		if err := msg.SetFromPeer(q.Message.From); err != nil {
+			return err
+		}
+		if err := msg.SetContent(q.Message.Content); err != nil {
+			return err
+		}
+		msg.SetTimestamp(q.Message.Timestamp.Unix())
+		if err := msg.SetReason(q.Reason); err != nil {
+			return err
+		}
 	}
-	return results.SetPeers(list)
+	return nil
 }
 
 // =============================================================================
-// Security & Encryption (Mandate 3)
+// Disappearing Message Methods
 // =============================================================================
 
-func (s *nodeServiceServer) SetProxyConfig(ctx context.Context, call NodeService_setProxyConfig) error {
-	results, err := call.AllocResults()
+// SetChatTtl implements the setChatTtl method
+func (s *nodeServiceServer) SetChatTtl(ctx context.Context, call NodeService_setChatTtl) error {
+	args := call.Args()
+	peerIDStr, err := args.PeerId()
 	if err != nil {
 		return err
 	}
 
-	args := call.Args()
-	cfg, err := args.Config()
+	results, err := call.AllocResults()
 	if err != nil {
 		return err
 	}
 
-	proxyType, _ := cfg.ProxyType()
-	proxyHost, _ := cfg.ProxyHost()
-	username, _ := cfg.Username()
-	data := &ProxyConfigData{
-		Enabled:   cfg.Enabled(),
-		ProxyType: proxyType,
-		ProxyHost: proxyHost,
-		ProxyPort: cfg.ProxyPort(),
-		Username:  username,
+	cs, err := s.communicationService()
+	if err == nil {
+		var p peer.ID
+		if p, err = peer.Decode(peerIDStr); err == nil {
+			cs.SetChatTTL(p, time.Duration(args.TtlSecs())*time.Second)
+		}
 	}
-	data.Password = "" // not carried over capnp for safety
-
-	if err := s.securityManager.SetProxyConfig(data); err != nil {
+	if err != nil {
 		results.SetSuccess(false)
-		results.SetErrorMsg(err.Error())
-		return nil
+		return results.SetErrorMsg(err.Error())
 	}
-
 	results.SetSuccess(true)
-	results.SetErrorMsg("")
 	return nil
 }
 
-func (s *nodeServiceServer) GetProxyConfig(ctx context.Context, call NodeService_getProxyConfig) error {
-	results, err := call.AllocResults()
+// SetChatHistoryPassphrase implements the setChatHistoryPassphrase method
+func (s *nodeServiceServer) SetChatHistoryPassphrase(ctx context.Context, call NodeService_setChatHistoryPassphrase) error {
+	args := call.Args()
+	oldPassphrase, err := args.OldPassphrase()
+	if err != nil {
+		return err
+	}
+	newPassphrase, err := args.NewPassphrase()
 	if err != nil {
 		return err
 	}
 
-	cfg := s.securityManager.GetProxyConfig()
-	proxy, err := results.NewConfig()
+	results, err := call.AllocResults()
 	if err != nil {
 		return err
 	}
-	proxy.SetEnabled(cfg.Enabled)
-	proxy.SetProxyType(cfg.ProxyType)
-	proxy.SetProxyHost(cfg.ProxyHost)
-	proxy.SetProxyPort(cfg.ProxyPort)
-	proxy.SetUsername(cfg.Username)
-	proxy.SetPasswordPresent(cfg.Password != "")
 
+	cs, err := s.communicationService()
+	if err == nil {
+		err = cs.SetHistoryPassphrase(oldPassphrase, newPassphrase)
+	}
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	results.SetSuccess(true)
 	return nil
 }
 
-func (s *nodeServiceServer) SetEncryptionConfig(ctx context.Context, call NodeService_setEncryptionConfig) error {
-	results, err := call.AllocResults()
+// UnlockChatHistory implements the unlockChatHistory method
+func (s *nodeServiceServer) UnlockChatHistory(ctx context.Context, call NodeService_unlockChatHistory) error {
+	passphrase, err := call.Args().Passphrase()
 	if err != nil {
 		return err
 	}
 
-	args := call.Args()
-	cfg, err := args.Config()
+	results, err := call.AllocResults()
 	if err != nil {
 		return err
 	}
 
-	encType, _ := cfg.EncryptionType()
-	keyAlgo, _ := cfg.KeyExchangeAlgorithm()
-	symAlgo, _ := cfg.SymmetricAlgorithm()
-	data := &EncryptionConfigData{
-		EncryptionType:   encType,
-		KeyExchangeAlgo:  keyAlgo,
-		SymmetricAlgo:    symAlgo,
-		EnableSignatures: cfg.EnableSignatures(),
+	cs, err := s.communicationService()
+	if err == nil {
+		err = cs.UnlockHistory(passphrase)
 	}
-
-	if err := s.securityManager.SetEncryptionConfig(data); err != nil {
+	if err != nil {
 		results.SetSuccess(false)
-		results.SetErrorMsg(err.Error())
-		return nil
+		return results.SetErrorMsg(err.Error())
 	}
-
 	results.SetSuccess(true)
-	results.SetErrorMsg("")
 	return nil
 }
 
-func (s *nodeServiceServer) GetEncryptionConfig(ctx context.Context, call NodeService_getEncryptionConfig) error {
+// LockChatHistory implements the lockChatHistory method
+func (s *nodeServiceServer) LockChatHistory(ctx context.Context, call NodeService_lockChatHistory) error {
 	results, err := call.AllocResults()
 	if err != nil {
 		return err
 	}
 
-	cfg := s.securityManager.GetEncryptionConfig()
-	enc, err := results.NewConfig()
+	cs, err := s.communicationService()
+	if err == nil {
+		err = cs.LockHistory()
+	}
 	if err != nil {
-		return err
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
 	}
-	enc.SetEncryptionType(cfg.EncryptionType)
-	enc.SetKeyExchangeAlgorithm(cfg.KeyExchangeAlgo)
-	enc.SetSymmetricAlgorithm(cfg.SymmetricAlgo)
-	enc.SetEnableSignatures(cfg.EnableSignatures)
-
+	results.SetSuccess(true)
 	return nil
 }
 
-func (s *nodeServiceServer) InitiateKeyExchange(ctx context.Context, call NodeService_initiateKeyExchange) error {
+// GetChatHistoryEncryption implements the getChatHistoryEncryption method
+func (s *nodeServiceServer) GetChatHistoryEncryption(ctx context.Context, call NodeService_getChatHistoryEncryption) error {
 	results, err := call.AllocResults()
 	if err != nil {
 		return err
 	}
 
-	// For now, echo back the request as the response
-	args := call.Args()
-	req, err := args.Request()
+	if cs, err := s.communicationService(); err == nil {
+		encrypted, locked := cs.HistoryEncryption()
+		results.SetEncrypted(encrypted)
+		results.SetLocked(locked)
+	}
+	return nil
+}
+
+// SearchChatHistory implements the searchChatHistory method
+func (s *nodeServiceServer) SearchChatHistory(ctx context.Context, call NodeService_searchChatHistory) error {
+	query, err := call.Args().Query()
 	if err != nil {
 		return err
 	}
+	peerID, _ := query.PeerId()
+	text, _ := query.Text()
+	from, _ := query.FromPeer()
+	cursor, _ := query.Cursor()
+	q := communication.HistoryQuery{
+		PeerID: peerID,
+		Text:   text,
+		From:   from,
+		Cursor: cursor,
+		Limit:  int(query.Limit()),
+	}
+	if query.SinceUnix() > 0 {
+		q.Since = time.Unix(query.SinceUnix(), 0)
+	}
+	if query.UntilUnix() > 0 {
+		q.Until = time.Unix(query.UntilUnix(), 0)
+	}
 
-	resp, err := results.NewResponse()
+	results, err := call.AllocResults()
 	if err != nil {
 		return err
 	}
-	pubKey, _ := req.PublicKey()
-	resp.SetPublicKey(pubKey)
-	resp.SetSelectedCipher(func() string {
-		ciphers, _ := req.SupportedCiphers()
-		if ciphers.Len() > 0 {
-			v, _ := ciphers.At(0)
-			return v
-		}
-		return "chacha20"
-	}())
-	resp.SetEncryptedSessionKey(pubKey)
-	nonce, _ := req.Nonce()
-	resp.SetNonce(nonce)
-	resp.SetSignature([]byte{})
 
+	var page communication.HistoryPage
+	cs, err := s.communicationService()
+	if err == nil {
+		page, err = cs.SearchHistory(q)
+	}
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+
+	list, err := results.NewMessages(int32(len(page.Messages)))
+	if err != nil {
+		return err
+	}
+	for i, m := range page.Messages {
+		item := list.At(i)
+		item.SetId(m.ID)
+		item.SetFromPeer(m.From)
+		item.SetToPeer(m.To)
+		item.SetContent(m.Content)
+		item.SetTimestamp(m.Timestamp.Unix())
+		tags, err := item.NewTags(int32(len(m.Tags)))
+		if err != nil {
+			return err
+		}
+		for j, tag := range m.Tags {
+			tags.Set(j, tag)
+		}
+	}
+	results.SetNextCursor(page.NextCursor)
+	results.SetTotal(uint32(page.Total))
 	results.SetSuccess(true)
-	results.SetErrorMsg("")
 	return nil
 }
 
-func (s *nodeServiceServer) AcceptKeyExchange(ctx context.Context, call NodeService_acceptKeyExchange) error {
+// ListChatConversations implements the listChatConversations method
+func (s *nodeServiceServer) ListChatConversations(ctx context.Context, call NodeService_listChatConversations) error {
 	results, err := call.AllocResults()
 	if err != nil {
 		return err
 	}
 
-	args := call.Args()
-	req, err := args.Request()
-	if err != nil {
-		return err
+	var convs []communication.Conversation
+	if cs, err := s.communicationService(); err == nil {
+		convs = cs.Conversations()
 	}
-
-	resp, err := results.NewResponse()
+	list, err := results.NewConversations(int32(len(convs)))
 	if err != nil {
 		return err
 	}
-	pubKey, _ := req.PublicKey()
-	resp.SetPublicKey(pubKey)
-	resp.SetSelectedCipher("chacha20")
-	resp.SetEncryptedSessionKey(pubKey)
-	nonce, _ := req.Nonce()
-	resp.SetNonce(nonce)
-	resp.SetSignature([]byte{})
-
-	results.SetSuccess(true)
-	results.SetErrorMsg("")
+	for i, c := range convs {
+		item := list.At(i)
+		item.SetPeerId(c.PeerID)
+		item.SetMessageCount(uint32(c.Messages))
+		item.SetLastMessage(c.Last.Unix())
+	}
 	return nil
 }
 
 // =============================================================================
-// Ephemeral Chat (Mandate 3)
+// Key Escrow Methods
 // =============================================================================
 
-func (s *nodeServiceServer) StartChatSession(ctx context.Context, call NodeService_startChatSession) error {
-	results, err := call.AllocResults()
+// ExportKeys implements the exportKeys method
+func (s *nodeServiceServer) ExportKeys(ctx context.Context, call NodeService_exportKeys) error {
+	passphrase, err := call.Args().Passphrase()
 	if err != nil {
 		return err
 	}
 
-	args := call.Args()
-	peerAddr, _ := args.PeerAddr()
-	encCfg, err := args.EncryptionConfig()
+	results, err := call.AllocResults()
 	if err != nil {
 		return err
 	}
 
-	encType, _ := encCfg.EncryptionType()
-	keyAlgo, _ := encCfg.KeyExchangeAlgorithm()
-	symAlgo, _ := encCfg.SymmetricAlgorithm()
-	chatCfg := &EncryptionConfigData{
-		EncryptionType:   encType,
-		KeyExchangeAlgo:  keyAlgo,
-		SymmetricAlgo:    symAlgo,
-		EnableSignatures: encCfg.EnableSignatures(),
+	lib, ok := libp2pOf(s.network)
+	if !ok {
+		results.SetSuccess(false)
+		return results.SetErrorMsg("key escrow requires libp2p mode")
 	}
-
-	sessionID := fmt.Sprintf("%s-%d", peerAddr, time.Now().UnixNano())
-	session := &ChatSessionData{
-		SessionID:        sessionID,
-		PeerAddr:         peerAddr,
-		EncryptionConfig: chatCfg,
-		Established:      time.Now(),
-		MessageQueue:     []*EphemeralChatMessageData{},
+	data, bundle, err := lib.node.ExportKeys(passphrase)
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
 	}
 
-	s.securityManager.mu.Lock()
-	s.securityManager.chatSessions[sessionID] = session
-	s.securityManager.mu.Unlock()
-
-	resp, err := results.NewSession()
-	if err != nil {
+	files, shares := bundle.counts()
+	if err := results.SetBundle(data); err != nil {
 		return err
 	}
-	resp.SetSessionId(sessionID)
-	resp.SetPeerAddr(peerAddr)
-	resp.SetEstablished(time.Now().Unix())
-	resp.SetEncryptionConfig(encCfg)
-
+	results.SetFileCount(uint32(files))
+	results.SetShareCount(uint32(shares))
 	results.SetSuccess(true)
-	results.SetErrorMsg("")
 	return nil
 }
 
-func (s *nodeServiceServer) SendEphemeralMessage(ctx context.Context, call NodeService_sendEphemeralMessage) error {
-	results, err := call.AllocResults()
+// ImportKeys implements the importKeys method
+func (s *nodeServiceServer) ImportKeys(ctx context.Context, call NodeService_importKeys) error {
+	args := call.Args()
+	data, err := args.Bundle()
 	if err != nil {
 		return err
 	}
-
-	args := call.Args()
-	msg, err := args.Message_()
+	passphrase, err := args.Passphrase()
 	if err != nil {
 		return err
 	}
 
-	sessionID := "default"
-	s.securityManager.mu.Lock()
-	session, ok := s.securityManager.chatSessions[sessionID]
-	if ok {
-		body, _ := msg.Message_()
-		from, _ := msg.FromPeer()
-		to, _ := msg.ToPeer()
-		msgID, _ := msg.MessageId()
-		session.MessageQueue = append(session.MessageQueue, &EphemeralChatMessageData{
-			FromPeer:  from,
-			ToPeer:    to,
-			Message:   body,
-			Timestamp: msg.Timestamp(),
-			MessageID: msgID,
-		})
+	// Import before allocating results: the bundle lives in the args message
+	err = fmt.Errorf("key escrow requires libp2p mode")
+	var files, shares int
+	if lib, ok := libp2pOf(s.network); ok {
+		files, shares, err = lib.node.ImportKeys(data, passphrase)
 	}
-	s.securityManager.mu.Unlock()
 
-	results.SetSuccess(ok)
-	if !ok {
-		results.SetErrorMsg("session not found")
-	} else {
-		results.SetErrorMsg("")
+	results, allocErr := call.AllocResults()
+	if allocErr != nil {
+		return allocErr
+	}
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
 	}
+	results.SetFileCount(uint32(files))
+	results.SetShareCount(uint32(shares))
+	results.SetSuccess(true)
 	return nil
 }
 
-func (s *nodeServiceServer) ReceiveChatMessages(ctx context.Context, call NodeService_receiveChatMessages) error {
+// GetKeyAuditLog implements the getKeyAuditLog method
+func (s *nodeServiceServer) GetKeyAuditLog(ctx context.Context, call NodeService_getKeyAuditLog) error {
 	results, err := call.AllocResults()
 	if err != nil {
 		return err
 	}
 
-	args := call.Args()
-	sessionID, _ := args.SessionId()
-
-	s.securityManager.mu.Lock()
-	session, ok := s.securityManager.chatSessions[sessionID]
-	var queue []*EphemeralChatMessageData
-	if ok {
-		queue = session.MessageQueue
-		session.MessageQueue = []*EphemeralChatMessageData{}
+	var entries []KeyAuditEntry
+	if lib, ok := libp2pOf(s.network); ok {
+		entries = lib.node.GetKeyAuditLog().Entries()
 	}
-	s.securityManager.mu.Unlock()
 
-	list, err := NewEphemeralChatMessage_List(results.Segment(), int32(len(queue)))
+	list, err := results.NewEntries(int32(len(entries)))
 	if err != nil {
 		return err
 	}
-	for i, m := range queue {
-		item := list.At(i)
-		item.SetFromPeer(m.FromPeer)
-		item.SetToPeer(m.ToPeer)
-		item.SetMessage_(m.Message)
-		item.SetTimestamp(m.Timestamp)
-		item.SetMessageId(m.MessageID)
-		item.SetEncryptionType(m.EncryptionType)
-		item.SetSignature(m.Signature)
+	for i, e := range entries {
+		entry := list.At(i)
+		entry.SetTimestamp(e.Time.Unix())
+		if err := entry.SetAction(e.Action); err != nil {
+			return err
+		}
+		entry.SetFileCount(uint32(e.Files))
+		entry.SetShareCount(uint32(e.Shares))
+		if err := entry.SetDetail(e.Detail); err != nil {
+			return err
+		}
 	}
-
-	return results.SetMessages(list)
+	return nil
 }
 
-func (s *nodeServiceServer) CloseChatSession(ctx context.Context, call NodeService_closeChatSession) error {
+// =============================================================================
+// Partition Detection Methods
+// =============================================================================
+
+// GetPartitionStatus implements the getPartitionStatus method
+func (s *nodeServiceServer) GetPartitionStatus(ctx context.Context, call NodeService_getPartitionStatus) error {
 	results, err := call.AllocResults()
 	if err != nil {
 		return err
 	}
 
-	args := call.Args()
-	sessionID, _ := args.SessionId()
-
-	s.securityManager.mu.Lock()
-	_, ok := s.securityManager.chatSessions[sessionID]
-	if ok {
-		delete(s.securityManager.chatSessions, sessionID)
+	var snapshot PartitionSnapshot
+	if lib, ok := libp2pOf(s.network); ok {
+		snapshot = lib.node.GetPartitionDetector().Snapshot()
 	}
-	s.securityManager.mu.Unlock()
 
-	results.SetSuccess(ok)
+	status, err := results.NewStatus()
+	if err != nil {
+		return err
+	}
+	status.SetPartitioned(snapshot.Partitioned)
+	if snapshot.Partitioned {
+		status.SetSince(snapshot.Since.Unix())
+	}
+	status.SetKnownPeers(uint32(snapshot.Known))
+	status.SetReachablePeers(uint32(snapshot.Reachable))
+	status.SetDeferredOps(uint32(snapshot.Deferred))
 	return nil
 }
 
 // =============================================================================
-// Distributed ML Services (Mandate 3)
+// mDNS Discovery Methods
 // =============================================================================
-
-func (s *nodeServiceServer) DistributeDataset(ctx context.Context, call NodeService_distributeDataset) error {
+// GetMdnsDiscovered implements the getMdnsDiscovered method
+func (s *nodeServiceServer) GetMdnsDiscovered(ctx context.Context, call NodeService_getMdnsDiscovered) error {
 	results, err := call.AllocResults()
 	if err != nil {
 		return err
 	}
 
-	// Stub distribution acknowledgment
-	results.SetSuccess(true)
-	results.SetErrorMsg("")
-	return nil
-}
+	// For now, return connected peers as they were likely discovered via mDNS
+	// In a full implementation, we'd track specifically which peers came from mDNS
+	connectedPeers := s.network.GetConnectedPeers()
 
-func (s *nodeServiceServer) SubmitGradient(ctx context.Context, call NodeService_submitGradient) error {
-	results, err := call.AllocResults()
+	peers, err := results.NewPeers(int32(len(connectedPeers)))
 	if err != nil {
 		return err
 	}
 
-	args := call.Args()
-	update, err := args.Update()
-	if err != nil {
-		return err
-	}
+	for i, peerID := range connectedPeers {
+		peer := peers.At(i)
+		peer.SetPeerId(fmt.Sprintf("%d", peerID))
 
-	workerID, _ := update.WorkerId()
-	grads, _ := update.Gradients()
-	grad := &GradientUpdateData{
-		WorkerID:     workerID,
-		ModelVersion: update.ModelVersion(),
-		Gradients:    grads,
-		NumSamples:   update.NumSamples(),
-		Loss:         update.Loss(),
-		Accuracy:     update.Accuracy(),
-	}
+		// Set multiaddrs (placeholder - would need actual peer info)
+		addrs, err := peer.NewMultiaddrs(1)
+		if err != nil {
+			continue
+		}
+		addrs.Set(0, fmt.Sprintf("/ip4/127.0.0.1/tcp/9090/p2p/%d", peerID))
 
-	if err := s.mlCoordinator.SubmitGradient(ctx, grad); err != nil {
-		results.SetSuccess(false)
-		results.SetErrorMsg(err.Error())
-		return nil
+		// Set discovery timestamp
+		peer.SetDiscoveredAt(time.Now().Unix())
 	}
 
-	results.SetSuccess(true)
-	results.SetErrorMsg("")
+	log.Printf("📡 [MDNS] Returning %d discovered peers", len(connectedPeers))
 	return nil
 }
 
-func (s *nodeServiceServer) GetModelUpdate(ctx context.Context, call NodeService_getModelUpdate) error {
+// ConnectMdnsPeer implements the connectMdnsPeer method
+func (s *nodeServiceServer) ConnectMdnsPeer(ctx context.Context, call NodeService_connectMdnsPeer) error {
 	results, err := call.AllocResults()
 	if err != nil {
 		return err
 	}
 
 	args := call.Args()
-	version := args.ModelVersion()
-
-	s.mlCoordinator.mu.RLock()
-	update, ok := s.mlCoordinator.models[version]
-	s.mlCoordinator.mu.RUnlock()
-
-	if !ok {
+	peerID, err := args.PeerID()
+	if err != nil {
 		results.SetSuccess(false)
-		results.SetErrorMsg("model version not found")
+		results.SetErrorMsg(fmt.Sprintf("Invalid peer ID: %v", err))
 		return nil
 	}
 
-	resp, err := results.NewUpdate()
-	if err != nil {
-		return err
-	}
-	resp.SetModelVersion(update.ModelVersion)
-	resp.SetParameters(update.Parameters)
-	resp.SetAggregationMethod(update.AggregationMethod)
-	resp.SetNumWorkers(update.NumWorkers)
-	resp.SetGlobalLoss(update.GlobalLoss)
-	resp.SetGlobalAccuracy(update.GlobalAccuracy)
+	log.Printf("🔗 [MDNS] Connecting to mDNS peer: %s", peerID)
 
+	// The connection would typically happen automatically via libp2p
+	// This is a placeholder for explicit connection logic
 	results.SetSuccess(true)
 	results.SetErrorMsg("")
+
+	log.Printf("✅ [MDNS] Successfully connected to peer %s", peerID)
 	return nil
 }
 
-func (s *nodeServiceServer) StartMLTraining(ctx context.Context, call NodeService_startMLTraining) error {
+// =============================================================================
+// Configuration Management Methods
+// =============================================================================
+
+// LoadConfig implements the loadConfig method
+func (s *nodeServiceServer) LoadConfig(ctx context.Context, call NodeService_loadConfig) error {
 	results, err := call.AllocResults()
 	if err != nil {
 		return err
 	}
 
-	args := call.Args()
-	task, err := args.Task()
+	if s.configManager == nil {
+		results.SetSuccess(false)
+		results.SetErrorMsg("Configuration manager not initialized")
+		return nil
+	}
+
+	cfg, err := s.configManager.LoadConfig()
 	if err != nil {
-		return err
+		results.SetSuccess(false)
+		results.SetErrorMsg(fmt.Sprintf("Failed to load config: %v", err))
+		return nil
 	}
 
-	// Build task data
-	hyper := make(map[string]string)
-	if h, err := task.Hyperparameters(); err == nil {
-		for i := 0; i < h.Len(); i++ {
-			kv := h.At(i)
-			key, _ := kv.Key()
-			val, _ := kv.Value()
-			hyper[key] = val
-		}
+	// Build Cap'n Proto config structure
+	configData, err := results.NewConfig()
+	if err != nil {
+		return err
 	}
 
-	workers := []string{}
-	if ws, err := task.WorkerNodes(); err == nil {
-		for i := 0; i < ws.Len(); i++ {
-			w, _ := ws.At(i)
-			workers = append(workers, w)
+	configData.SetNodeId(cfg.NodeID)
+	configData.SetCapnpAddr(cfg.CapnpAddr)
+	configData.SetLibp2pPort(int32(cfg.LibP2PPort))
+	configData.SetUseLibp2p(cfg.UseLibP2P)
+	configData.SetLocalMode(cfg.LocalMode)
+	configData.SetLastSavedAt(cfg.LastSavedAt)
+
+	// Set bootstrap peers
+	if len(cfg.BootstrapPeers) > 0 {
+		peers, err := configData.NewBootstrapPeers(int32(len(cfg.BootstrapPeers)))
+		if err != nil {
+			return err
+		}
+		for i, peer := range cfg.BootstrapPeers {
+			peers.Set(i, peer)
 		}
 	}
 
-	taskID, _ := task.TaskId()
-	datasetID, _ := task.DatasetId()
-	modelArch, _ := task.ModelArchitecture()
-	aggNode, _ := task.AggregatorNode()
+	// Set custom settings
+	if len(cfg.CustomSettings) > 0 {
+		settings, err := configData.NewCustomSettings(int32(len(cfg.CustomSettings)))
+		if err != nil {
+			return err
+		}
+		i := 0
+		for key, value := range cfg.CustomSettings {
+			kv := settings.At(i)
+			kv.SetKey(key)
+			kv.SetValue(value)
+			i++
+		}
+	}
 
-	taskData := &MLTrainingTaskData{
-		TaskID:            taskID,
-		DatasetID:         datasetID,
-		ModelArchitecture: modelArch,
-		Hyperparameters:   hyper,
-		WorkerNodes:       workers,
-		AggregatorNode:    aggNode,
-		Epochs:            task.Epochs(),
-		BatchSize:         task.BatchSize(),
+	results.SetSuccess(true)
+	results.SetErrorMsg("")
+	log.Printf("✅ [CONFIG] Configuration loaded successfully")
+	return nil
+}
+
+// SaveConfig implements the saveConfig method
+func (s *nodeServiceServer) SaveConfig(ctx context.Context, call NodeService_saveConfig) error {
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
 	}
 
-	if err := s.mlCoordinator.StartMLTraining(ctx, taskData); err != nil {
+	if s.configManager == nil {
 		results.SetSuccess(false)
-		results.SetErrorMsg(err.Error())
+		results.SetErrorMsg("Configuration manager not initialized")
+		return nil
+	}
+
+	args := call.Args()
+	configData, err := args.Config()
+	if err != nil {
+		results.SetSuccess(false)
+		results.SetErrorMsg(fmt.Sprintf("Invalid config data: %v", err))
+		return nil
+	}
+
+	// Build NodeConfig from Cap'n Proto structure
+	capnpAddr, _ := configData.CapnpAddr()
+	cfg := &NodeConfig{
+		NodeID:         configData.NodeId(),
+		CapnpAddr:      capnpAddr,
+		LibP2PPort:     int(configData.Libp2pPort()),
+		UseLibP2P:      configData.UseLibp2p(),
+		LocalMode:      configData.LocalMode(),
+		CustomSettings: make(map[string]string),
+	}
+
+	// Extract bootstrap peers
+	peers, err := configData.BootstrapPeers()
+	if err == nil && peers.Len() > 0 {
+		cfg.BootstrapPeers = make([]string, peers.Len())
+		for i := 0; i < peers.Len(); i++ {
+			peer, _ := peers.At(i)
+			cfg.BootstrapPeers[i] = peer
+		}
+	}
+
+	// Extract custom settings
+	settings, err := configData.CustomSettings()
+	if err == nil && settings.Len() > 0 {
+		for i := 0; i < settings.Len(); i++ {
+			kv := settings.At(i)
+			key, _ := kv.Key()
+			value, _ := kv.Value()
+			cfg.CustomSettings[key] = value
+		}
+	}
+
+	// Save configuration
+	if err := s.configManager.SaveConfig(cfg); err != nil {
+		results.SetSuccess(false)
+		results.SetErrorMsg(fmt.Sprintf("Failed to save config: %v", err))
 		return nil
 	}
 
 	results.SetSuccess(true)
 	results.SetErrorMsg("")
+	log.Printf("✅ [CONFIG] Configuration saved successfully")
 	return nil
 }
 
-func (s *nodeServiceServer) GetMLTrainingStatus(ctx context.Context, call NodeService_getMLTrainingStatus) error {
+// UpdateConfigValue implements the updateConfigValue method
+func (s *nodeServiceServer) UpdateConfigValue(ctx context.Context, call NodeService_updateConfigValue) error {
 	results, err := call.AllocResults()
 	if err != nil {
 		return err
 	}
 
+	if s.configManager == nil {
+		results.SetSuccess(false)
+		return nil
+	}
+
 	args := call.Args()
-	taskID, _ := args.TaskId()
-	task, err := s.mlCoordinator.GetMLTrainingStatus(taskID)
+	key, err := args.Key()
+	if err != nil {
+		results.SetSuccess(false)
+		return nil
+	}
+
+	value, err := args.Value()
+	if err != nil {
+		results.SetSuccess(false)
+		return nil
+	}
+
+	// Update config value
+	if err := s.configManager.UpdateConfig(key, value); err != nil {
+		results.SetSuccess(false)
+		return nil
+	}
+
+	// Save the updated config
+	cfg := s.configManager.GetConfig()
+	if err := s.configManager.SaveConfig(cfg); err != nil {
+		log.Printf("⚠️  [CONFIG] Failed to save after update: %v", err)
+	}
+
+	results.SetSuccess(true)
+	log.Printf("✅ [CONFIG] Updated config: %s = %s", key, value)
+	return nil
+}
+
+// =============================================================================
+// Streaming Auxiliary Accessors
+// =============================================================================
+
+func (s *nodeServiceServer) GetReceivedFrames(ctx context.Context, call NodeService_getReceivedFrames) error {
+	results, err := call.AllocResults()
 	if err != nil {
 		return err
 	}
 
-	status, err := results.NewStatus()
+	// No in-memory buffer yet; return empty list
+	frames, err := NewVideoFrame_List(results.Segment(), 0)
 	if err != nil {
 		return err
 	}
-	status.SetTaskId(task.TaskID)
-	status.SetCurrentEpoch(task.CurrentEpoch)
-	status.SetTotalEpochs(task.Epochs)
-	status.SetActiveWorkers(uint32(len(task.WorkerNodes)))
-	status.SetCompletedWorkers(0)
-	status.SetCurrentLoss(0)
-	status.SetCurrentAccuracy(0)
-	status.SetEstimatedTimeRemaining(0)
+	return results.SetFrames(VideoFrame_List(frames))
+}
+
+func (s *nodeServiceServer) GetReceivedAudio(ctx context.Context, call NodeService_getReceivedAudio) error {
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+
+	chunks, err := NewAudioChunk_List(results.Segment(), 0)
+	if err != nil {
+		return err
+	}
+	return results.SetChunks(AudioChunk_List(chunks))
+}
+
+func (s *nodeServiceServer) GetLocalMultiaddr(ctx context.Context, call NodeService_getLocalMultiaddr) error {
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+
+	multiaddr := ""
+	if lib, ok := libp2pOf(s.network); ok && lib.node != nil {
+		addrs := lib.node.LocalMultiaddrs(false)
+		if len(addrs) == 0 {
+			addrs = lib.node.LocalMultiaddrs(true)
+		}
+		if len(addrs) > 0 {
+			multiaddr = addrs[0]
+		}
+	}
 
+	results.SetMultiaddr(multiaddr)
 	return nil
 }
 
-func (s *nodeServiceServer) StopMLTraining(ctx context.Context, call NodeService_stopMLTraining) error {
+func (s *nodeServiceServer) ListLibp2pPeers(ctx context.Context, call NodeService_listLibp2pPeers) error {
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+
+	// Each connected peer as a dialable multiaddr of its first connection
+	peers := []string{}
+	if lib, ok := libp2pOf(s.network); ok && lib.node != nil {
+		h := lib.node.GetHost()
+		for _, p := range h.Network().Peers() {
+			conns := h.Network().ConnsToPeer(p)
+			if len(conns) == 0 {
+				continue
+			}
+			peers = append(peers, fmt.Sprintf("%s/p2p/%s", conns[0].RemoteMultiaddr(), p))
+		}
+	}
+	list, err := capnp.NewTextList(results.Segment(), int32(len(peers)))
+	if err != nil {
+		return err
+	}
+	for i, p := range peers {
+		list.Set(i, p)
+	}
+	return results.SetPeers(list)
+}
+
+// =============================================================================
+// Security & Encryption (Mandate 3)
+// =============================================================================
+
+func (s *nodeServiceServer) SetProxyConfig(ctx context.Context, call NodeService_setProxyConfig) error {
 	results, err := call.AllocResults()
 	if err != nil {
 		return err
 	}
 
 	args := call.Args()
-	taskID, _ := args.TaskId()
+	cfg, err := args.Config()
+	if err != nil {
+		return err
+	}
 
-	if err := s.mlCoordinator.StopMLTraining(taskID); err != nil {
+	proxyType, _ := cfg.ProxyType()
+	proxyHost, _ := cfg.ProxyHost()
+	username, _ := cfg.Username()
+	data := &ProxyConfigData{
+		Enabled:   cfg.Enabled(),
+		ProxyType: proxyType,
+		ProxyHost: proxyHost,
+		ProxyPort: cfg.ProxyPort(),
+		Username:  username,
+	}
+	data.Password = "" // not carried over capnp for safety
+
+	if err := s.securityManager.SetProxyConfig(data); err != nil {
 		results.SetSuccess(false)
+		results.SetErrorMsg(err.Error())
 		return nil
 	}
 
+	results.SetSuccess(true)
+	results.SetErrorMsg("")
+	return nil
+}
+
+func (s *nodeServiceServer) GetProxyConfig(ctx context.Context, call NodeService_getProxyConfig) error {
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+
+	cfg := s.securityManager.GetProxyConfig()
+	proxy, err := results.NewConfig()
+	if err != nil {
+		return err
+	}
+	proxy.SetEnabled(cfg.Enabled)
+	proxy.SetProxyType(cfg.ProxyType)
+	proxy.SetProxyHost(cfg.ProxyHost)
+	proxy.SetProxyPort(cfg.ProxyPort)
+	proxy.SetUsername(cfg.Username)
+	proxy.SetPasswordPresent(cfg.Password != "")
+
+	return nil
+}
+
+func (s *nodeServiceServer) SetEncryptionConfig(ctx context.Context, call NodeService_setEncryptionConfig) error {
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+
+	args := call.Args()
+	cfg, err := args.Config()
+	if err != nil {
+		return err
+	}
+
+	encType, _ := cfg.EncryptionType()
+	keyAlgo, _ := cfg.KeyExchangeAlgorithm()
+	symAlgo, _ := cfg.SymmetricAlgorithm()
+	data := &EncryptionConfigData{
+		EncryptionType:   encType,
+		KeyExchangeAlgo:  keyAlgo,
+		SymmetricAlgo:    symAlgo,
+		EnableSignatures: cfg.EnableSignatures(),
+	}
+
+	if err := s.securityManager.SetEncryptionConfig(data); err != nil {
+		results.SetSuccess(false)
+		results.SetErrorMsg(err.Error())
+		return nil
+	}
+
+	results.SetSuccess(true)
+	results.SetErrorMsg("")
+	return nil
+}
+
+func (s *nodeServiceServer) GetEncryptionConfig(ctx context.Context, call NodeService_getEncryptionConfig) error {
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+
+	cfg := s.securityManager.GetEncryptionConfig()
+	enc, err := results.NewConfig()
+	if err != nil {
+		return err
+	}
+	enc.SetEncryptionType(cfg.EncryptionType)
+	enc.SetKeyExchangeAlgorithm(cfg.KeyExchangeAlgo)
+	enc.SetSymmetricAlgorithm(cfg.SymmetricAlgo)
+	enc.SetEnableSignatures(cfg.EnableSignatures)
+
+	return nil
+}
+
+func (s *nodeServiceServer) InitiateKeyExchange(ctx context.Context, call NodeService_initiateKeyExchange) error {
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	req, err := call.Args().Request()
+	if err != nil {
+		return err
+	}
+	resp, err := results.NewResponse()
+	if err != nil {
+		return err
+	}
+	if err := s.fillKeyExchangeResponse(req, resp); err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	results.SetSuccess(true)
+	return nil
+}
+
+func (s *nodeServiceServer) AcceptKeyExchange(ctx context.Context, call NodeService_acceptKeyExchange) error {
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	req, err := call.Args().Request()
+	if err != nil {
+		return err
+	}
+	resp, err := results.NewResponse()
+	if err != nil {
+		return err
+	}
+	if err := s.fillKeyExchangeResponse(req, resp); err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	results.SetSuccess(true)
+	return nil
+}
+
+// fillKeyExchangeResponse answers a key exchange with this node's X25519
+// key, or its RSA key in PEM when the request asks for RSA, and the first
+// requested cipher this node supports
+func (s *nodeServiceServer) fillKeyExchangeResponse(req KeyExchangeRequest, resp KeyExchangeResponse) error {
+	algo, _ := req.Algorithm()
+	var publicKey []byte
+	var err error
+	if strings.HasPrefix(strings.ToLower(algo), "rsa") {
+		if _, err = s.securityManager.defaultKeyPair(); err == nil {
+			publicKey, err = s.securityManager.ExportPublicKey("default")
+		}
+	} else {
+		publicKey, err = s.securityManager.AgreementPublicKey("default")
+	}
+	if err != nil {
+		return err
+	}
+	if err := resp.SetPublicKey(publicKey); err != nil {
+		return err
+	}
+
+	selected := "chacha20"
+	ciphers, _ := req.SupportedCiphers()
+	for i := 0; i < ciphers.Len(); i++ {
+		if c, _ := ciphers.At(i); c != "" {
+			if _, err := messageCipherID(c); err == nil {
+				selected = c
+				break
+			}
+		}
+	}
+	if err := resp.SetSelectedCipher(selected); err != nil {
+		return err
+	}
+	nonce, _ := req.Nonce()
+	return resp.SetNonce(nonce)
+}
+
+// =============================================================================
+// Ephemeral Chat (Mandate 3)
+// =============================================================================
+
+func (s *nodeServiceServer) StartChatSession(ctx context.Context, call NodeService_startChatSession) error {
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+
+	args := call.Args()
+	peerAddr, _ := args.PeerAddr()
+	encCfg, err := args.EncryptionConfig()
+	if err != nil {
+		return err
+	}
+
+	encType, _ := encCfg.EncryptionType()
+	keyAlgo, _ := encCfg.KeyExchangeAlgorithm()
+	symAlgo, _ := encCfg.SymmetricAlgorithm()
+	chatCfg := &EncryptionConfigData{
+		EncryptionType:   encType,
+		KeyExchangeAlgo:  keyAlgo,
+		SymmetricAlgo:    symAlgo,
+		EnableSignatures: encCfg.EnableSignatures(),
+	}
+
+	sessionID := fmt.Sprintf("%s-%d", peerAddr, time.Now().UnixNano())
+	session := &ChatSessionData{
+		SessionID:        sessionID,
+		PeerAddr:         peerAddr,
+		EncryptionConfig: chatCfg,
+		Established:      time.Now(),
+		MessageTTL:       time.Duration(args.MessageTtlSecs()) * time.Second,
+		BurnOnRead:       args.BurnOnRead(),
+		MessageQueue:     []*EphemeralChatMessageData{},
+	}
+
+	s.securityManager.mu.Lock()
+	s.securityManager.chatSessions[sessionID] = session
+	s.securityManager.mu.Unlock()
+
+	resp, err := results.NewSession()
+	if err != nil {
+		return err
+	}
+	resp.SetSessionId(sessionID)
+	resp.SetPeerAddr(peerAddr)
+	resp.SetEstablished(time.Now().Unix())
+	resp.SetEncryptionConfig(encCfg)
+	resp.SetMessageTtlSecs(args.MessageTtlSecs())
+	resp.SetBurnOnRead(args.BurnOnRead())
+
+	results.SetSuccess(true)
+	results.SetErrorMsg("")
+	return nil
+}
+
+func (s *nodeServiceServer) SendEphemeralMessage(ctx context.Context, call NodeService_sendEphemeralMessage) error {
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+
+	args := call.Args()
+	msg, err := args.Message_()
+	if err != nil {
+		return err
+	}
+
+	sessionID, _ := args.SessionId()
+	body, _ := msg.Message_()
+	to, _ := msg.ToPeer()
+	msgID, _ := msg.MessageId()
+	data := &EphemeralChatMessageData{
+		ToPeer:    to,
+		Message:   body,
+		Timestamp: msg.Timestamp(),
+		MessageID: msgID,
+		TTL:       time.Duration(msg.TtlSecs()) * time.Second,
+	}
+
+	if s.chat == nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg("chat requires a libp2p network")
+	}
+	if sessionID == "" {
+		sessionID = s.securityManager.sessionForPeer(to)
+	}
+	if err := s.chat.Send(ctx, sessionID, data); err != nil {
+		log.Printf("❌ [CHAT] Failed to send %s: %v", msgID, err)
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+
+	results.SetSuccess(true)
+	return results.SetErrorMsg("")
+}
+
+func (s *nodeServiceServer) ReceiveChatMessages(ctx context.Context, call NodeService_receiveChatMessages) error {
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+
+	args := call.Args()
+	sessionID, _ := args.SessionId()
+
+	s.securityManager.mu.Lock()
+	session, ok := s.securityManager.chatSessions[sessionID]
+	var queue []*EphemeralChatMessageData
+	if ok {
+		queue = session.takeMessages(args.IncludeRead())
+	}
+	s.securityManager.mu.Unlock()
+
+	list, err := NewEphemeralChatMessage_List(results.Segment(), int32(len(queue)))
+	if err != nil {
+		return err
+	}
+	for i, m := range queue {
+		item := list.At(i)
+		item.SetFromPeer(m.FromPeer)
+		item.SetToPeer(m.ToPeer)
+		item.SetMessage_(m.Message)
+		item.SetTimestamp(m.Timestamp)
+		item.SetMessageId(m.MessageID)
+		item.SetEncryptionType(m.EncryptionType)
+		item.SetSignature(m.Signature)
+		item.SetTtlSecs(uint32(m.TTL / time.Second))
+		item.SetExpiresAt(m.ExpiresAt.Unix())
+	}
+
+	return results.SetMessages(list)
+}
+
+func (s *nodeServiceServer) CloseChatSession(ctx context.Context, call NodeService_closeChatSession) error {
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+
+	args := call.Args()
+	sessionID, _ := args.SessionId()
+
+	_, err = s.securityManager.GetChatSession(sessionID)
+	ok := err == nil
+	if ok {
+		s.securityManager.CloseChatSession(sessionID)
+	}
+
+	results.SetSuccess(ok)
+	return nil
+}
+
+// =============================================================================
+// Distributed ML Services (Mandate 3)
+// =============================================================================
+
+func (s *nodeServiceServer) DistributeDataset(ctx context.Context, call NodeService_distributeDataset) error {
+	args := call.Args()
+	dataset, err := args.Dataset()
+	if err != nil {
+		return err
+	}
+	datasetID, _ := dataset.DatasetId()
+
+	chunkList, _ := dataset.Chunks()
+	chunks := make([]*DatasetChunkData, chunkList.Len())
+	for i := 0; i < chunkList.Len(); i++ {
+		chunk := chunkList.At(i)
+		data, _ := chunk.Data()
+		labels, _ := chunk.Labels()
+		checksum, _ := chunk.Checksum()
+		chunks[i] = &DatasetChunkData{
+			ChunkID:  chunk.ChunkId(),
+			Data:     append([]byte(nil), data...), // Outlives the RPC message
+			Labels:   append([]byte(nil), labels...),
+			Checksum: checksum,
+		}
+	}
+
+	workerList, _ := args.WorkerNodes()
+	workers := make([]string, workerList.Len())
+	for i := range workers {
+		workers[i], _ = workerList.At(i)
+	}
+
+	transfers, distErr := s.mlCoordinator.DistributeDataset(ctx, datasetID, chunks, workers)
+
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	list, err := results.NewTransfers(int32(len(transfers)))
+	if err != nil {
+		return err
+	}
+	for i, t := range transfers {
+		entry := list.At(i)
+		if err := entry.SetWorkerId(t.WorkerID); err != nil {
+			return err
+		}
+		entry.SetChunks(t.Chunks)
+		entry.SetSent(t.Sent)
+		if err := entry.SetStatus(t.Status); err != nil {
+			return err
+		}
+		if err := entry.SetErrorMsg(t.Error); err != nil {
+			return err
+		}
+	}
+
+	if distErr != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(distErr.Error())
+	}
+	results.SetSuccess(true)
+	return nil
+}
+
+func (s *nodeServiceServer) SubmitGradient(ctx context.Context, call NodeService_submitGradient) error {
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+
+	args := call.Args()
+	update, err := args.Update()
+	if err != nil {
+		return err
+	}
+
+	workerID, _ := update.WorkerId()
+	grads, _ := update.Gradients()
+	grad := &GradientUpdateData{
+		WorkerID:     workerID,
+		ModelVersion: update.ModelVersion(),
+		Gradients:    append([]byte(nil), grads...), // Outlives the RPC message
+		NumSamples:   update.NumSamples(),
+		Loss:         update.Loss(),
+		Accuracy:     update.Accuracy(),
+	}
+
+	if err := s.mlCoordinator.SubmitGradient(ctx, grad); err != nil {
+		results.SetSuccess(false)
+		results.SetErrorMsg(err.Error())
+		return nil
+	}
+
+	results.SetSuccess(true)
+	results.SetErrorMsg("")
+	return nil
+}
+
+func (s *nodeServiceServer) GetModelUpdate(ctx context.Context, call NodeService_getModelUpdate) error {
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+
+	args := call.Args()
+	version := args.ModelVersion()
+	taskID, _ := args.TaskId()
+
+	update, err := s.mlCoordinator.GetModelUpdate(taskID, version)
+	if err != nil {
+		results.SetSuccess(false)
+		results.SetErrorMsg(err.Error())
+		return nil
+	}
+
+	resp, err := results.NewUpdate()
+	if err != nil {
+		return err
+	}
+	resp.SetModelVersion(update.ModelVersion)
+	resp.SetParameters(update.Parameters)
+	resp.SetAggregationMethod(update.AggregationMethod)
+	resp.SetNumWorkers(update.NumWorkers)
+	resp.SetGlobalLoss(update.GlobalLoss)
+	resp.SetGlobalAccuracy(update.GlobalAccuracy)
+	resp.SetTaskId(update.TaskID)
+
+	results.SetSuccess(true)
+	results.SetErrorMsg("")
+	return nil
+}
+
+func (s *nodeServiceServer) StartMLTraining(ctx context.Context, call NodeService_startMLTraining) error {
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+
+	args := call.Args()
+	task, err := args.Task()
+	if err != nil {
+		return err
+	}
+
+	// Build task data
+	hyper := make(map[string]string)
+	if h, err := task.Hyperparameters(); err == nil {
+		for i := 0; i < h.Len(); i++ {
+			kv := h.At(i)
+			key, _ := kv.Key()
+			val, _ := kv.Value()
+			hyper[key] = val
+		}
+	}
+
+	workers := []string{}
+	if ws, err := task.WorkerNodes(); err == nil {
+		for i := 0; i < ws.Len(); i++ {
+			w, _ := ws.At(i)
+			workers = append(workers, w)
+		}
+	}
+
+	taskID, _ := task.TaskId()
+	datasetID, _ := task.DatasetId()
+	modelArch, _ := task.ModelArchitecture()
+	aggNode, _ := task.AggregatorNode()
+	initialParams, _ := task.InitialParameters()
+
+	taskData := &MLTrainingTaskData{
+		TaskID:            taskID,
+		DatasetID:         datasetID,
+		ModelArchitecture: modelArch,
+		Hyperparameters:   hyper,
+		WorkerNodes:       workers,
+		AggregatorNode:    aggNode,
+		Epochs:            task.Epochs(),
+		BatchSize:         task.BatchSize(),
+		InitialParameters: append([]byte(nil), initialParams...),
+	}
+	if task.HasPrivacy() {
+		if dp, err := task.Privacy(); err == nil && dp.ClipNorm() > 0 {
+			taskData.Privacy = &DPConfig{
+				ClipNorm:        dp.ClipNorm(),
+				NoiseMultiplier: dp.NoiseMultiplier(),
+				Delta:           dp.Delta(),
+				MaxEpsilon:      dp.MaxEpsilon(),
+			}
+		}
+	}
+
+	if err := s.mlCoordinator.StartMLTraining(ctx, taskData); err != nil {
+		results.SetSuccess(false)
+		results.SetErrorMsg(err.Error())
+		return nil
+	}
+
+	results.SetSuccess(true)
+	results.SetErrorMsg("")
+	return nil
+}
+
+func (s *nodeServiceServer) GetMLTrainingStatus(ctx context.Context, call NodeService_getMLTrainingStatus) error {
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+
+	args := call.Args()
+	taskID, _ := args.TaskId()
+	task, err := s.mlCoordinator.GetMLTrainingStatus(taskID)
+	if err != nil {
+		return err
+	}
+
+	status, err := results.NewStatus()
+	if err != nil {
+		return err
+	}
+	status.SetTaskId(task.TaskID)
+	status.SetCurrentEpoch(task.CurrentEpoch)
+	status.SetTotalEpochs(task.Epochs)
+	status.SetActiveWorkers(uint32(len(task.WorkerNodes)))
+	status.SetCompletedWorkers(0)
+	status.SetCurrentLoss(0)
+	status.SetCurrentAccuracy(0)
+	status.SetEstimatedTimeRemaining(0)
+
+	spent, enabled, err := s.mlCoordinator.GetPrivacySpent(taskID)
+	if err != nil {
+		return err
+	}
+	status.SetPrivacyEnabled(enabled)
+	status.SetPrivacyRounds(spent.Rounds)
+	status.SetPrivacyEpsilon(spent.Epsilon)
+	status.SetPrivacyDelta(spent.Delta)
+
+	traffic := s.mlCoordinator.GetGradientTraffic(taskID)
+	status.SetGradientWireBytes(traffic.WireBytes)
+	status.SetGradientRawBytes(traffic.RawBytes)
+	status.SetBandwidthSavings(traffic.Savings())
+
+	return nil
+}
+
+func (s *nodeServiceServer) StopMLTraining(ctx context.Context, call NodeService_stopMLTraining) error {
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+
+	args := call.Args()
+	taskID, _ := args.TaskId()
+
+	if err := s.mlCoordinator.StopMLTraining(taskID); err != nil {
+		results.SetSuccess(false)
+		return nil
+	}
+
+	results.SetSuccess(true)
+	return nil
+}
+
+func (s *nodeServiceServer) MlHeartbeat(ctx context.Context, call NodeService_mlHeartbeat) error {
+	workerID, _ := call.Args().WorkerId()
+
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+
+	readmitted, err := s.mlCoordinator.Heartbeat(workerID)
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	results.SetReadmitted(readmitted)
+	results.SetSuccess(true)
+	return nil
+}
+
+// ============================================================
+// Capacity Reservation Methods
+// ============================================================
+
+// computeProtocol returns the libp2p compute protocol, if running on libp2p
+func (s *nodeServiceServer) computeProtocol() (*ComputeProtocol, error) {
+	lib, ok := libp2pOf(s.network)
+	if !ok || lib.node == nil || lib.node.GetComputeProtocol() == nil {
+		return nil, fmt.Errorf("reservations require the libp2p compute protocol")
+	}
+	return lib.node.GetComputeProtocol(), nil
+}
+
+func (s *nodeServiceServer) ReserveCapacity(ctx context.Context, call NodeService_reserveCapacity) error {
+	args := call.Args()
+	workerID, _ := args.WorkerPeerId()
+	jobID, _ := args.JobId()
+	req := ReservationRequest{
+		CPUCores: args.CpuCores(),
+		RAMMB:    args.RamMb(),
+		Start:    unixTime(args.StartUnix()),
+		End:      unixTime(args.EndUnix()),
+		JobID:    jobID,
+	}
+
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+
+	r, err := s.reserveCapacity(ctx, workerID, req)
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	reservation, err := results.NewReservation()
+	if err != nil {
+		return err
+	}
+	if err := fillCapacityReservation(reservation, r); err != nil {
+		return err
+	}
+	results.SetSuccess(true)
+	return nil
+}
+
+// reserveCapacity asks a worker for a reservation
+func (s *nodeServiceServer) reserveCapacity(ctx context.Context, workerID string, req ReservationRequest) (compute.Reservation, error) {
+	cp, err := s.computeProtocol()
+	if err != nil {
+		return compute.Reservation{}, err
+	}
+	worker, err := peer.Decode(workerID)
+	if err != nil {
+		return compute.Reservation{}, fmt.Errorf("invalid worker peer ID: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	return cp.ReserveCapacity(ctx, worker, req)
+}
+
+func (s *nodeServiceServer) ReleaseReservation(ctx context.Context, call NodeService_releaseReservation) error {
+	id, _ := call.Args().ReservationId()
+
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+
+	cp, err := s.computeProtocol()
+	if err == nil {
+		ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+		err = cp.ReleaseCapacity(ctx, id)
+	}
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	results.SetSuccess(true)
+	return nil
+}
+
+func (s *nodeServiceServer) ListReservations(ctx context.Context, call NodeService_listReservations) error {
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+
+	held := s.computeManager.HeldReservations()
+	heldList, err := results.NewHeld(int32(len(held)))
+	if err != nil {
+		return err
+	}
+	for i, r := range held {
+		if err := fillCapacityReservation(heldList.At(i), r); err != nil {
+			return err
+		}
+	}
+
+	granted := s.computeManager.GrantedReservations()
+	grantedList, err := results.NewGranted(int32(len(granted)))
+	if err != nil {
+		return err
+	}
+	for i, r := range granted {
+		if err := fillCapacityReservation(grantedList.At(i), r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fillCapacityReservation copies a reservation into its RPC form
+func fillCapacityReservation(out CapacityReservation, r compute.Reservation) error {
+	if err := out.SetId(r.ID); err != nil {
+		return err
+	}
+	if err := out.SetWorkerId(r.WorkerID); err != nil {
+		return err
+	}
+	if err := out.SetHolder(r.Holder); err != nil {
+		return err
+	}
+	if err := out.SetJobId(r.JobID); err != nil {
+		return err
+	}
+	out.SetCpuCores(r.CPUCores)
+	out.SetRamMb(r.RAMMB)
+	out.SetStartUnix(r.Start.Unix())
+	out.SetEndUnix(r.End.Unix())
+	return nil
+}
+
+// ============================================================
+// File Transfer Methods
+// ============================================================
+
+// fileTransfers returns the direct file transfer service, if running on
+// libp2p
+func (s *nodeServiceServer) fileTransfers() (*FileTransferService, error) {
+	lib, ok := libp2pOf(s.network)
+	if !ok || lib.node == nil || lib.node.GetFileTransferService() == nil {
+		return nil, fmt.Errorf("file transfer requires a libp2p node")
+	}
+	return lib.node.GetFileTransferService(), nil
+}
+
+func (s *nodeServiceServer) SendFile(ctx context.Context, call NodeService_sendFile) error {
+	args := call.Args()
+	peerID, _ := args.PeerId()
+	path, _ := args.Path()
+
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	files, err := s.fileTransfers()
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	id, err := files.SendFile(ctx, peerID, path)
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	results.SetSuccess(true)
+	return results.SetTransferId(id)
+}
+
+func (s *nodeServiceServer) AcceptFile(ctx context.Context, call NodeService_acceptFile) error {
+	args := call.Args()
+	id, _ := args.TransferId()
+	destPath, _ := args.DestPath()
+
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	files, err := s.fileTransfers()
+	if err == nil {
+		err = files.AcceptFile(id, destPath)
+	}
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	results.SetSuccess(true)
+	return nil
+}
+
+func (s *nodeServiceServer) PauseTransfer(ctx context.Context, call NodeService_pauseTransfer) error {
+	id, _ := call.Args().TransferId()
+
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	files, err := s.fileTransfers()
+	if err == nil {
+		err = files.PauseTransfer(id)
+	}
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	results.SetSuccess(true)
+	return nil
+}
+
+func (s *nodeServiceServer) ResumeTransfer(ctx context.Context, call NodeService_resumeTransfer) error {
+	id, _ := call.Args().TransferId()
+
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	files, err := s.fileTransfers()
+	if err == nil {
+		err = files.ResumeTransfer(id)
+	}
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	results.SetSuccess(true)
+	return nil
+}
+
+func (s *nodeServiceServer) GetTransferStatus(ctx context.Context, call NodeService_getTransferStatus) error {
+	id, _ := call.Args().TransferId()
+
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	files, err := s.fileTransfers()
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+
+	transfers := files.Transfers()
+	if id != "" {
+		t, ok := files.Transfer(id)
+		if !ok {
+			results.SetSuccess(false)
+			return results.SetErrorMsg(fmt.Sprintf("transfer %s not found", id))
+		}
+		transfers = []FileTransfer{t}
+	}
+	list, err := results.NewTransfers(int32(len(transfers)))
+	if err != nil {
+		return err
+	}
+	for i, t := range transfers {
+		if err := fillFileTransferStatus(list.At(i), t); err != nil {
+			return err
+		}
+	}
+	results.SetSuccess(true)
+	return nil
+}
+
+// fillFileTransferStatus copies a transfer into its RPC form
+func fillFileTransferStatus(out FileTransferStatus, t FileTransfer) error {
+	if err := out.SetTransferId(t.ID); err != nil {
+		return err
+	}
+	if err := out.SetPeerId(t.Peer); err != nil {
+		return err
+	}
+	if err := out.SetName(t.Name); err != nil {
+		return err
+	}
+	if err := out.SetPath(t.Path); err != nil {
+		return err
+	}
+	if err := out.SetState(t.State); err != nil {
+		return err
+	}
+	if err := out.SetError(t.Error); err != nil {
+		return err
+	}
+	out.SetSize(t.Size)
+	out.SetTransferred(t.Transferred)
+	out.SetOutgoing(t.Outgoing)
+	out.SetUpdatedAt(t.Updated.Unix())
+	return nil
+}
+
+// ============================================================
+// Screen Sharing Methods
+// ============================================================
+
+func (s *nodeServiceServer) StartScreenShare(ctx context.Context, call NodeService_startScreenShare) error {
+	args := call.Args()
+	peerID, _ := args.PeerId()
+
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	cs, err := s.communicationService()
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	p, err := peer.Decode(peerID)
+	if err == nil {
+		err = cs.StartScreenShare(p, communication.ScreenShareConfig{KeyframeInterval: int(args.KeyframeInterval())})
+	}
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	results.SetSuccess(true)
+	return nil
+}
+
+func (s *nodeServiceServer) SendScreenUpdate(ctx context.Context, call NodeService_sendScreenUpdate) error {
+	args := call.Args()
+	peerID, _ := args.PeerId()
+	in, err := args.Update()
+	if err != nil {
+		return err
+	}
+	update := communication.ScreenUpdate{
+		ScreenWidth:  in.ScreenWidth(),
+		ScreenHeight: in.ScreenHeight(),
+		Keyframe:     in.Keyframe(),
+	}
+	rects, err := in.Rects()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < rects.Len(); i++ {
+		r := rects.At(i)
+		data, _ := r.Data()
+		update.Rects = append(update.Rects, communication.ScreenRect{
+			X: r.X(), Y: r.Y(), Width: r.Width(), Height: r.Height(), Data: data,
+		})
+	}
+
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	cs, err := s.communicationService()
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	p, err := peer.Decode(peerID)
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	seq, err := cs.SendScreenUpdate(p, update)
+	results.SetKeyframeRequired(cs.KeyframeRequired(p))
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	results.SetSeq(seq)
+	results.SetSuccess(true)
+	return nil
+}
+
+func (s *nodeServiceServer) StopScreenShare(ctx context.Context, call NodeService_stopScreenShare) error {
+	peerID, _ := call.Args().PeerId()
+
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	cs, err := s.communicationService()
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	p, err := peer.Decode(peerID)
+	if err == nil {
+		err = cs.StopScreenShare(p)
+	}
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	results.SetSuccess(true)
+	return nil
+}
+
+func (s *nodeServiceServer) GetScreenUpdates(ctx context.Context, call NodeService_getScreenUpdates) error {
+	maxUpdates := int(call.Args().MaxUpdates())
+
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	var updates []communication.ReceivedScreenUpdate
+	if cs, err := s.communicationService(); err == nil {
+		updates = cs.ReceivedScreenUpdates(maxUpdates)
+	}
+	list, err := results.NewUpdates(int32(len(updates)))
+	if err != nil {
+		return err
+	}
+	for i, u := range updates {
+		out := list.At(i)
+		out.SetSeq(u.Seq)
+		out.SetScreenWidth(u.ScreenWidth)
+		out.SetScreenHeight(u.ScreenHeight)
+		out.SetKeyframe(u.Keyframe)
+		out.SetTimestamp(u.Timestamp.UnixMilli())
+		if err := out.SetFromPeer(u.PeerID); err != nil {
+			return err
+		}
+		rects, err := out.NewRects(int32(len(u.Rects)))
+		if err != nil {
+			return err
+		}
+		for j, r := range u.Rects {
+			rect := rects.At(j)
+			rect.SetX(r.X)
+			rect.SetY(r.Y)
+			rect.SetWidth(r.Width)
+			rect.SetHeight(r.Height)
+			if err := rect.SetData(r.Data); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// decodePeerIDs parses a list of peer ID strings
+func decodePeerIDs(list capnp.TextList) ([]peer.ID, error) {
+	ids := make([]peer.ID, 0, list.Len())
+	for _, str := range textListStrings(list) {
+		p, err := peer.Decode(str)
+		if err != nil {
+			return nil, fmt.Errorf("invalid peer ID %q: %w", str, err)
+		}
+		ids = append(ids, p)
+	}
+	return ids, nil
+}
+
+func (s *nodeServiceServer) SetVideoSpread(ctx context.Context, call NodeService_setVideoSpread) error {
+	args := call.Args()
+	key, _ := args.Key()
+	relayList, err := args.Relays()
+	if err != nil {
+		return err
+	}
+
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	cs, err := s.communicationService()
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	var config *communication.VideoSpreadConfig
+	if args.Enabled() {
+		if len(key) != 32 {
+			results.SetSuccess(false)
+			return results.SetErrorMsg(fmt.Sprintf("video spread key must be 32 bytes, got %d", len(key)))
+		}
+		relays, err := decodePeerIDs(relayList)
+		if err != nil {
+			results.SetSuccess(false)
+			return results.SetErrorMsg(err.Error())
+		}
+		coder := &cesFrameCoder{}
+		copy(coder.key[:], key)
+		config = &communication.VideoSpreadConfig{
+			Coder:        coder,
+			MinFrameSize: int(args.MinFrameBytes()),
+			Relays:       relays,
+		}
+	}
+	if err := cs.SetVideoSpread(config); err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	results.SetSuccess(true)
+	return nil
+}
+
+func (s *nodeServiceServer) SendGroupVideoFrame(ctx context.Context, call NodeService_sendGroupVideoFrame) error {
+	args := call.Args()
+	peerList, err := args.PeerIds()
+	if err != nil {
+		return err
+	}
+	in, err := args.Frame()
+	if err != nil {
+		return err
+	}
+	data, _ := in.Data()
+	frame := communication.VideoFrame{
+		FrameID:   in.FrameId(),
+		Width:     in.Width(),
+		Height:    in.Height(),
+		Quality:   in.Quality(),
+		Keyframe:  in.Keyframe(),
+		Data:      data,
+		Timestamp: time.Now(),
+	}
+
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	cs, err := s.communicationService()
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	receivers, err := decodePeerIDs(peerList)
+	if err == nil {
+		err = cs.SendGroupVideoFrame(receivers, frame)
+	}
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	results.SetSuccess(true)
+	return nil
+}
+
+// ============================================================
+// Threshold Signing Methods
+// ============================================================
+
+// thresholdSigner returns the threshold signing service, if running on
+// libp2p
+func (s *nodeServiceServer) thresholdSigner() (*ThresholdSigner, error) {
+	lib, ok := libp2pOf(s.network)
+	if !ok || lib.node == nil || lib.node.GetThresholdSigner() == nil {
+		return nil, fmt.Errorf("threshold signing requires a libp2p node")
+	}
+	return lib.node.GetThresholdSigner(), nil
+}
+
+func (s *nodeServiceServer) CreateSigningGroup(ctx context.Context, call NodeService_createSigningGroup) error {
+	args := call.Args()
+	groupID, _ := args.GroupId()
+	peerList, err := args.PeerIds()
+	if err != nil {
+		return err
+	}
+
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	ts, err := s.thresholdSigner()
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	peers, err := decodePeerIDs(peerList)
+	var groupKey []byte
+	if err == nil {
+		groupKey, err = ts.CreateGroup(ctx, groupID, peers, int(args.Threshold()))
+	}
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	results.SetSuccess(true)
+	return results.SetGroupKey(groupKey)
+}
+
+func (s *nodeServiceServer) ThresholdSign(ctx context.Context, call NodeService_thresholdSign) error {
+	args := call.Args()
+	groupID, _ := args.GroupId()
+	purpose, _ := args.Purpose()
+	payload, _ := args.Payload()
+
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	ts, err := s.thresholdSigner()
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	sig, err := ts.Sign(ctx, groupID, purpose, payload)
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	if err := results.SetSignature(sig.Signature); err != nil {
+		return err
+	}
+	if err := results.SetGroupKey(sig.GroupKey); err != nil {
+		return err
+	}
+	signers, err := capnp.NewTextList(results.Segment(), int32(len(sig.Signers)))
+	if err != nil {
+		return err
+	}
+	for i, p := range sig.Signers {
+		signers.Set(i, p)
+	}
+	results.SetSuccess(true)
+	return results.SetSigners(signers)
+}
+
+func (s *nodeServiceServer) VerifyThresholdSignature(ctx context.Context, call NodeService_verifyThresholdSignature) error {
+	args := call.Args()
+	groupID, _ := args.GroupId()
+	groupKey, _ := args.GroupKey()
+	purpose, _ := args.Purpose()
+	payload, _ := args.Payload()
+	sig, _ := args.Signature()
+
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	if err := VerifyThresholdSignature(groupID, groupKey, purpose, payload, sig); err != nil {
+		results.SetValid(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	results.SetValid(true)
+	return nil
+}
+
+// ============================================================
+// Security Key Methods
+// ============================================================
+
+func (s *nodeServiceServer) ListKeys(ctx context.Context, call NodeService_listKeys) error {
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	keys := s.securityManager.ListKeys()
+	list, err := results.NewKeys(int32(len(keys)))
+	if err != nil {
+		return err
+	}
+	for i, k := range keys {
+		if err := setSecurityKey(list.At(i), k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *nodeServiceServer) RotateKey(ctx context.Context, call NodeService_rotateKey) error {
+	args := call.Args()
+	keyID, _ := args.KeyId()
+	overlap := time.Duration(args.OverlapSecs()) * time.Second
+	if overlap == 0 {
+		overlap = DefaultKeyOverlap
+	}
+
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	if _, err := s.securityManager.RotateKey(keyID, overlap); err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	for _, k := range s.securityManager.ListKeys() {
+		if k.KeyID == keyID && k.RetiresAt.IsZero() {
+			out, err := results.NewKey()
+			if err != nil {
+				return err
+			}
+			if err := setSecurityKey(out, k); err != nil {
+				return err
+			}
+		}
+	}
+	results.SetSuccess(true)
+	return nil
+}
+
+func (s *nodeServiceServer) GetAgreementKey(ctx context.Context, call NodeService_getAgreementKey) error {
+	keyID, _ := call.Args().KeyId()
+
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	publicKey, err := s.securityManager.AgreementPublicKey(keyID)
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	results.SetSuccess(true)
+	return results.SetPublicKey(publicKey)
+}
+
+func (s *nodeServiceServer) ImportAgreementKey(ctx context.Context, call NodeService_importAgreementKey) error {
+	args := call.Args()
+	keyID, _ := args.KeyId()
+	publicKey, _ := args.PublicKey()
+
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	if err := s.securityManager.ImportAgreementKey(keyID, publicKey); err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	results.SetSuccess(true)
+	return nil
+}
+
+func (s *nodeServiceServer) EncryptMessage(ctx context.Context, call NodeService_encryptMessage) error {
+	args := call.Args()
+	keyID, _ := args.KeyId()
+	recipient, _ := args.RecipientKey()
+	plaintext, _ := args.Plaintext()
+	aad, _ := args.Aad()
+
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	var ciphertext []byte
+	if len(recipient) > 0 {
+		ciphertext, err = s.securityManager.SealMessage(recipient, plaintext, aad)
+	} else {
+		ciphertext, err = s.securityManager.EncryptMessage(keyID, plaintext, aad)
+	}
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	results.SetSuccess(true)
+	return results.SetCiphertext(ciphertext)
+}
+
+func (s *nodeServiceServer) DecryptMessage(ctx context.Context, call NodeService_decryptMessage) error {
+	args := call.Args()
+	keyID, _ := args.KeyId()
+	ciphertext, _ := args.Ciphertext()
+	aad, _ := args.Aad()
+
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	plaintext, err := s.securityManager.DecryptMessage(keyID, ciphertext, aad)
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	results.SetSuccess(true)
+	return results.SetPlaintext(plaintext)
+}
+
+// setSecurityKey fills a SecurityKey from a KeyInfo
+func setSecurityKey(out SecurityKey, k KeyInfo) error {
+	if err := out.SetKeyId(k.KeyID); err != nil {
+		return err
+	}
+	out.SetVersion(uint32(k.Version))
+	if err := out.SetFingerprint(k.Fingerprint); err != nil {
+		return err
+	}
+	out.SetCreated(k.Created.Unix())
+	if !k.RetiresAt.IsZero() {
+		out.SetRetiresAt(k.RetiresAt.Unix())
+	}
+	out.SetHasPrivateKey(k.HasPrivate)
+	return out.SetPublicKey(k.PublicPEM)
+}
+
+// ============================================================
+// Peer Verification Methods
+// ============================================================
+
+// peerVerifier returns the verifier of the libp2p node
+func (s *nodeServiceServer) peerVerifier() (*PeerVerifier, error) {
+	lib, ok := libp2pOf(s.network)
+	if !ok || lib.node == nil || lib.node.GetPeerVerifier() == nil {
+		return nil, fmt.Errorf("peer verification requires a libp2p node")
+	}
+	return lib.node.GetPeerVerifier(), nil
+}
+
+func (s *nodeServiceServer) GetPeerVerification(ctx context.Context, call NodeService_getPeerVerification) error {
+	peerIDStr, _ := call.Args().PeerId()
+
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	pv, err := s.peerVerifier()
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	p, err := peer.Decode(peerIDStr)
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(fmt.Sprintf("invalid peer ID: %v", err))
+	}
+	out, err := results.NewVerification()
+	if err != nil {
+		return err
+	}
+	if err := setPeerVerification(out, pv, p, pv.Status(p)); err != nil {
+		return err
+	}
+	results.SetSuccess(true)
+	return nil
+}
+
+func (s *nodeServiceServer) VerifyPeer(ctx context.Context, call NodeService_verifyPeer) error {
+	args := call.Args()
+	peerIDStr, _ := args.PeerId()
+	safetyNumber, _ := args.SafetyNumber()
+
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	pv, err := s.peerVerifier()
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	p, err := peer.Decode(peerIDStr)
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(fmt.Sprintf("invalid peer ID: %v", err))
+	}
+	if err := pv.Verify(p, safetyNumber); err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	results.SetSuccess(true)
+	return nil
+}
+
+func (s *nodeServiceServer) UnverifyPeer(ctx context.Context, call NodeService_unverifyPeer) error {
+	peerIDStr, _ := call.Args().PeerId()
+
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	pv, err := s.peerVerifier()
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	p, err := peer.Decode(peerIDStr)
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(fmt.Sprintf("invalid peer ID: %v", err))
+	}
+	if err := pv.Unverify(p); err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	results.SetSuccess(true)
+	return nil
+}
+
+func (s *nodeServiceServer) ListVerifiedPeers(ctx context.Context, call NodeService_listVerifiedPeers) error {
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	pv, err := s.peerVerifier()
+	if err != nil {
+		return nil
+	}
+	records := pv.List()
+	list, err := results.NewPeers(int32(len(records)))
+	if err != nil {
+		return err
+	}
+	for i := range records {
+		p, err := peer.Decode(records[i].PeerID)
+		if err != nil {
+			continue
+		}
+		if err := setPeerVerification(list.At(i), pv, p, &records[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setPeerVerification fills a PeerVerification from a peer's record, which
+// is nil for a peer that was never verified
+func setPeerVerification(out PeerVerification, pv *PeerVerifier, p peer.ID, r *PeerVerificationRecord) error {
+	if err := out.SetPeerId(p.String()); err != nil {
+		return err
+	}
+	if number, err := pv.SafetyNumber(p); err == nil {
+		if err := out.SetSafetyNumber(number); err != nil {
+			return err
+		}
+	}
+	if r == nil {
+		return nil
+	}
+	out.SetVerified(r.Verified())
+	out.SetVerifiedAt(r.VerifiedAt.Unix())
+	if !r.KeyChangedAt.IsZero() {
+		out.SetKeyChanged(true)
+		out.SetKeyChangedAt(r.KeyChangedAt.Unix())
+	}
+	if err := out.SetSeenFingerprint(r.SeenKey); err != nil {
+		return err
+	}
+	return out.SetFingerprint(r.Fingerprint)
+}
+
+// ============================================================
+// Peer ID Methods
+// ============================================================
+
+func (s *nodeServiceServer) ResolvePeerId(ctx context.Context, call NodeService_resolvePeerId) error {
+	peerID := call.Args().PeerId()
+
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	lib, ok := libp2pOf(s.network)
+	if !ok {
+		results.SetSuccess(false)
+		return results.SetErrorMsg("peer IDs require a libp2p node")
+	}
+	pid, err := lib.resolvePeer(peerID)
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	results.SetSuccess(true)
+	return results.SetLibp2pId(pid.String())
+}
+
+func (s *nodeServiceServer) LookupPeerId(ctx context.Context, call NodeService_lookupPeerId) error {
+	libp2pID, _ := call.Args().Libp2pId()
+
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	lib, ok := libp2pOf(s.network)
+	if !ok {
+		results.SetSuccess(false)
+		return results.SetErrorMsg("peer IDs require a libp2p node")
+	}
+	pid, err := peer.Decode(libp2pID)
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(fmt.Sprintf("invalid peer ID: %v", err))
+	}
+	results.SetPeerId(lib.PeerUint32ID(pid))
+	results.SetSuccess(true)
+	return nil
+}
+
+// ============================================================
+// WebRTC Methods
+// ============================================================
+
+func (s *nodeServiceServer) GetWebRTCAddrs(ctx context.Context, call NodeService_getWebRTCAddrs) error {
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	node, err := s.libp2pNode()
+	if err != nil || !node.WebRTCEnabled() {
+		return nil
+	}
+	if err := results.SetPeerId(node.GetHost().ID().String()); err != nil {
+		return err
+	}
+	addrs := node.WebRTCAddrs()
+	list, err := results.NewAddrs(int32(len(addrs)))
+	if err != nil {
+		return err
+	}
+	for i, addr := range addrs {
+		if err := list.Set(i, addr); err != nil {
+			return err
+		}
+	}
+	results.SetEnabled(true)
+	return nil
+}
+
+// ============================================================
+// Event Methods
+// ============================================================
+
+// eventCallTimeout bounds delivery of one event to a subscribed client
+const eventCallTimeout = 10 * time.Second
+
+// eventBus returns the node's event bus, if running on libp2p
+func (s *nodeServiceServer) eventBus() (*EventBus, error) {
+	lib, ok := libp2pOf(s.network)
+	if !ok || lib.node == nil || lib.node.GetEventBus() == nil {
+		return nil, fmt.Errorf("events require a libp2p node")
+	}
+	return lib.node.GetEventBus(), nil
+}
+
+func (s *nodeServiceServer) SubscribeEvents(ctx context.Context, call NodeService_subscribeEvents) error {
+	args := call.Args()
+	typeList, _ := args.Types()
+	types, err := eventTypesFromList(typeList)
+	listener := args.Listener().AddRef()
+
+	results, rerr := call.AllocResults()
+	if rerr != nil {
+		listener.Release()
+		return rerr
+	}
+	var bus *EventBus
+	if err == nil {
+		bus, err = s.eventBus()
+	}
+	var sub *EventSubscription
+	if err == nil {
+		sub, err = bus.Subscribe(types...)
+	}
+	if err != nil {
+		listener.Release()
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+
+	// Push events to the client until it unsubscribes or stops answering
+	go func() {
+		defer listener.Release()
+		for evt := range sub.C {
+			ctx, cancel := context.WithTimeout(context.Background(), eventCallTimeout)
+			future, release := listener.OnEvent(ctx, func(p EventListener_onEvent_Params) error {
+				out, err := p.NewEvent()
+				if err != nil {
+					return err
+				}
+				return fillEvent(out, evt)
+			})
+			_, err := future.Struct()
+			release()
+			cancel()
+			if err != nil {
+				log.Printf("⚠️  [EVENTS] Ending subscription %d: %v", sub.ID, err)
+				bus.Unsubscribe(sub.ID)
+				return
+			}
+		}
+	}()
+
+	results.SetSubscriptionId(sub.ID)
+	results.SetSuccess(true)
+	return nil
+}
+
+func (s *nodeServiceServer) UnsubscribeEvents(ctx context.Context, call NodeService_unsubscribeEvents) error {
+	id := call.Args().SubscriptionId()
+
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	bus, err := s.eventBus()
+	results.SetSuccess(err == nil && bus.Unsubscribe(id))
+	return nil
+}
+
+func (s *nodeServiceServer) AddWebhook(ctx context.Context, call NodeService_addWebhook) error {
+	args := call.Args()
+	url, _ := args.Url()
+	secret, _ := args.Secret()
+	typeList, _ := args.Types()
+
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	types, err := eventTypesFromList(typeList)
+	var bus *EventBus
+	if err == nil {
+		bus, err = s.eventBus()
+	}
+	var id string
+	if err == nil {
+		id, err = bus.AddWebhook(url, secret, types...)
+	}
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	results.SetSuccess(true)
+	return results.SetWebhookId(id)
+}
+
+func (s *nodeServiceServer) RemoveWebhook(ctx context.Context, call NodeService_removeWebhook) error {
+	id, _ := call.Args().WebhookId()
+
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	bus, err := s.eventBus()
+	results.SetSuccess(err == nil && bus.RemoveWebhook(id))
+	return nil
+}
+
+func (s *nodeServiceServer) ListWebhooks(ctx context.Context, call NodeService_listWebhooks) error {
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	var webhooks []Webhook
+	if bus, err := s.eventBus(); err == nil {
+		webhooks = bus.Webhooks()
+	}
+	list, err := results.NewWebhooks(int32(len(webhooks)))
+	if err != nil {
+		return err
+	}
+	for i, wh := range webhooks {
+		out := list.At(i)
+		if err := out.SetId(wh.ID); err != nil {
+			return err
+		}
+		if err := out.SetUrl(wh.URL); err != nil {
+			return err
+		}
+		types, err := out.NewTypes(int32(len(wh.Types)))
+		if err != nil {
+			return err
+		}
+		for j, t := range wh.Types {
+			if err := types.Set(j, string(t)); err != nil {
+				return err
+			}
+		}
+		out.SetDelivered(wh.Delivered)
+		out.SetFailed(wh.Failed)
+	}
+	return nil
+}
+
+// eventTypesFromList converts RPC event type names
+func eventTypesFromList(list capnp.TextList) ([]EventType, error) {
+	types := make([]EventType, 0, list.Len())
+	for i := 0; i < list.Len(); i++ {
+		t, err := list.At(i)
+		if err != nil {
+			return nil, err
+		}
+		types = append(types, EventType(t))
+	}
+	return types, validateEventTypes(types)
+}
+
+// fillEvent copies an event into its RPC form
+func fillEvent(out Event, evt NodeEvent) error {
+	out.SetSeq(evt.Seq)
+	out.SetTimestamp(evt.Time.UnixMilli())
+	if err := out.SetType(string(evt.Type)); err != nil {
+		return err
+	}
+	if err := out.SetPeerId(evt.PeerID); err != nil {
+		return err
+	}
+	if err := out.SetSubject(evt.Subject); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(evt.Attributes))
+	for k := range evt.Attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	attrs, err := out.NewAttributes(int32(len(keys)))
+	if err != nil {
+		return err
+	}
+	for i, k := range keys {
+		if err := attrs.At(i).SetKey(k); err != nil {
+			return err
+		}
+		if err := attrs.At(i).SetValue(evt.Attributes[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ============================================================
+// Health Methods
+// ============================================================
+
+func (s *nodeServiceServer) GetHealth(ctx context.Context, call NodeService_getHealth) error {
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	lib, ok := libp2pOf(s.network)
+	if !ok || lib.node == nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg("health requires a libp2p node")
+	}
+	monitor := lib.node.GetHealthMonitor()
+	health := monitor.Check()
+
+	if err := results.SetState(string(health.State)); err != nil {
+		return err
+	}
+	results.SetReady(health.Ready)
+	results.SetUptimeSecs(uint64(monitor.Uptime().Seconds()))
+	list, err := results.NewComponents(int32(len(health.Components)))
+	if err != nil {
+		return err
+	}
+	for i, c := range health.Components {
+		out := list.At(i)
+		if err := out.SetName(c.Name); err != nil {
+			return err
+		}
+		if err := out.SetState(string(c.State)); err != nil {
+			return err
+		}
+		out.SetCritical(c.Critical)
+		out.SetSince(c.Since.UnixMilli())
+		if c.LastError != "" {
+			if err := out.SetLastError(c.LastError); err != nil {
+				return err
+			}
+			out.SetLastErrorAt(c.LastErrorAt.UnixMilli())
+		}
+	}
+	results.SetSuccess(true)
+	return nil
+}
+
+// ============================================================
+// Bootstrap Methods
+// ============================================================
+
+// libp2pNode returns the node behind a libp2p network adapter
+func (s *nodeServiceServer) libp2pNode() (*LibP2PPangeaNode, error) {
+	lib, ok := libp2pOf(s.network)
+	if !ok || lib.node == nil {
+		return nil, fmt.Errorf("bootstrap peers require a libp2p node")
+	}
+	return lib.node, nil
+}
+
+// saveBootstrapPeers persists the node's bootstrap set when a config manager is present
+func (s *nodeServiceServer) saveBootstrapPeers(node *LibP2PPangeaNode) error {
+	if s.configManager == nil {
+		return nil
+	}
+	cfg := s.configManager.GetConfig()
+	cfg.BootstrapPeers = node.BootstrapPeers()
+	return s.configManager.SaveConfig(cfg)
+}
+
+func (s *nodeServiceServer) ListBootstrapPeers(ctx context.Context, call NodeService_listBootstrapPeers) error {
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	node, err := s.libp2pNode()
+	if err != nil {
+		return nil
+	}
+	peers := node.BootstrapPeers()
+	list, err := results.NewPeers(int32(len(peers)))
+	if err != nil {
+		return err
+	}
+	for i, addr := range peers {
+		if err := list.Set(i, addr); err != nil {
+			return err
+		}
+	}
+	results.SetPrivateNetwork(node.IsPrivateNetwork())
+	return nil
+}
+
+func (s *nodeServiceServer) AddBootstrapPeer(ctx context.Context, call NodeService_addBootstrapPeer) error {
+	addr, _ := call.Args().Addr()
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	node, err := s.libp2pNode()
+	if err == nil {
+		err = node.AddBootstrapPeer(addr)
+	}
+	if err == nil {
+		err = s.saveBootstrapPeers(node)
+	}
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	results.SetSuccess(true)
+	return nil
+}
+
+func (s *nodeServiceServer) RemoveBootstrapPeer(ctx context.Context, call NodeService_removeBootstrapPeer) error {
+	addr, _ := call.Args().Addr()
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	node, err := s.libp2pNode()
+	removed := false
+	if err == nil {
+		removed, err = node.RemoveBootstrapPeer(addr)
+	}
+	if err == nil && !removed {
+		err = fmt.Errorf("%s is not a bootstrap peer", addr)
+	}
+	if err == nil {
+		err = s.saveBootstrapPeers(node)
+	}
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	results.SetSuccess(true)
+	return nil
+}
+
+// ============================================================
+// Network Backend Methods
+// ============================================================
+
+// networkSwitch returns the switch routing between network backends
+func (s *nodeServiceServer) networkSwitch() (*NetworkSwitch, error) {
+	sw, ok := s.network.(*NetworkSwitch)
+	if !ok {
+		return nil, fmt.Errorf("this node's network backends cannot be switched")
+	}
+	return sw, nil
+}
+
+// saveNetworkBackends persists the legacy listener and primary backend
+// when a config manager is present
+func (s *nodeServiceServer) saveNetworkBackends(sw *NetworkSwitch) error {
+	if s.configManager == nil {
+		return nil
+	}
+	cfg := s.configManager.GetConfig()
+	cfg.LegacyAddr = ""
+	for _, status := range sw.Status() {
+		if status.Name == BackendLegacy && status.Enabled {
+			cfg.LegacyAddr = status.ListenAddr
+		}
+		if status.Primary {
+			cfg.PrimaryBackend = status.Name
+		}
+	}
+	return s.configManager.SaveConfig(cfg)
+}
+
+func (s *nodeServiceServer) GetNetworkBackends(ctx context.Context, call NodeService_getNetworkBackends) error {
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	var statuses []BackendStatus
+	if sw, err := s.networkSwitch(); err == nil {
+		statuses = sw.Status()
+	} else if b, ok := s.network.(NetworkBackend); ok {
+		statuses = []BackendStatus{{
+			Name:       b.Name(),
+			Enabled:    true,
+			Primary:    true,
+			ListenAddr: b.ListenAddr(),
+			PeerCount:  len(b.GetConnectedPeers()),
+		}}
+	}
+	list, err := results.NewBackends(int32(len(statuses)))
+	if err != nil {
+		return err
+	}
+	for i, status := range statuses {
+		info := list.At(i)
+		if err := info.SetName(status.Name); err != nil {
+			return err
+		}
+		if err := info.SetListenAddr(status.ListenAddr); err != nil {
+			return err
+		}
+		info.SetEnabled(status.Enabled)
+		info.SetPrimary(status.Primary)
+		info.SetSwitchable(status.Switchable)
+		info.SetPeerCount(uint32(status.PeerCount))
+	}
+	return nil
+}
+
+func (s *nodeServiceServer) SetNetworkBackend(ctx context.Context, call NodeService_setNetworkBackend) error {
+	args := call.Args()
+	name, _ := args.Name()
+	listenAddr, _ := args.ListenAddr()
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	sw, err := s.networkSwitch()
+	if err == nil {
+		if args.Enabled() {
+			err = sw.Enable(name, listenAddr)
+		} else {
+			err = sw.Disable(name)
+		}
+	}
+	if err == nil {
+		err = s.saveNetworkBackends(sw)
+	}
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	results.SetSuccess(true)
+	return nil
+}
+
+func (s *nodeServiceServer) SetPrimaryBackend(ctx context.Context, call NodeService_setPrimaryBackend) error {
+	name, _ := call.Args().Name()
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	sw, err := s.networkSwitch()
+	if err == nil {
+		err = sw.SetPrimary(name)
+	}
+	if err == nil {
+		err = s.saveNetworkBackends(sw)
+	}
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	results.SetSuccess(true)
+	return nil
+}
+
+// ============================================================
+// Compute Worker Policy Methods
+// ============================================================
+
+func (s *nodeServiceServer) GetWorkerPolicy(ctx context.Context, call NodeService_getWorkerPolicy) error {
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	if s.computeManager == nil {
+		return nil
+	}
+	p := s.computeManager.WorkerPolicy()
+	out, err := results.NewPolicy()
+	if err != nil {
+		return err
+	}
+	out.SetEnabled(p.Enabled)
+	out.SetMaxConcurrentTasks(uint32(p.MaxConcurrentTasks))
+	out.SetMaxTaskCpuPercent(p.MaxTaskCPUPercent)
+	out.SetMaxTaskMemoryMb(p.MaxTaskMemoryMB)
+	out.SetMaxTaskTimeMs(uint64(p.MaxTaskTime.Milliseconds()))
+	hours, err := out.NewWorkHours(int32(len(p.WorkHours)))
+	if err != nil {
+		return err
+	}
+	for i, w := range p.WorkHours {
+		if err := hours.Set(i, w.String()); err != nil {
+			return err
+		}
+	}
+	active, accepting := s.computeManager.WorkerStatus()
+	results.SetActiveTasks(uint32(active))
+	results.SetAccepting(accepting)
+	return nil
+}
+
+func (s *nodeServiceServer) SetWorkerPolicy(ctx context.Context, call NodeService_setWorkerPolicy) error {
+	in, err := call.Args().Policy()
+	if err != nil {
+		return err
+	}
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	if s.computeManager == nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg("compute manager not initialized")
+	}
+	p := compute.WorkerPolicy{
+		Enabled:            in.Enabled(),
+		MaxConcurrentTasks: int(in.MaxConcurrentTasks()),
+		MaxTaskCPUPercent:  in.MaxTaskCpuPercent(),
+		MaxTaskMemoryMB:    in.MaxTaskMemoryMb(),
+		MaxTaskTime:        time.Duration(in.MaxTaskTimeMs()) * time.Millisecond,
+	}
+	hours, _ := in.WorkHours()
+	for i := 0; i < hours.Len() && err == nil; i++ {
+		var text string
+		if text, err = hours.At(i); err == nil {
+			var w compute.WorkWindow
+			if w, err = compute.ParseWorkWindow(text); err == nil {
+				p.WorkHours = append(p.WorkHours, w)
+			}
+		}
+	}
+	if err == nil {
+		err = s.computeManager.SetWorkerPolicy(p)
+	}
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	results.SetSuccess(true)
+	return nil
+}
+
+// ============================================================================
+// Compute Queue Methods
+// ============================================================================
+
+func (s *nodeServiceServer) GetQueueStats(ctx context.Context, call NodeService_getQueueStats) error {
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	if s.computeManager == nil {
+		return nil
+	}
+	q := s.computeManager.QueueStats()
+	stats, err := results.NewStats()
+	if err != nil {
+		return err
+	}
+	stats.SetRunning(uint32(q.Running))
+	stats.SetQueued(uint32(q.Queued))
+	stats.SetPending(uint32(q.Pending))
+	stats.SetMaxConcurrent(uint32(q.MaxConcurrent))
+	stats.SetMaxQueued(uint32(q.MaxQueued))
+	stats.SetAdmitted(q.Admitted)
+	stats.SetRejected(q.Rejected)
+	stats.SetAvgWaitMs(q.AvgWaitMs)
+	return nil
+}
+
+// ============================================================================
+// Compute Job Event Methods
+// ============================================================================
+
+func (s *nodeServiceServer) SubscribeJobEvents(ctx context.Context, call NodeService_subscribeJobEvents) error {
+	args := call.Args()
+	jobID, _ := args.JobId()
+	listener := args.Listener().AddRef()
+
+	results, err := call.AllocResults()
+	if err != nil {
+		listener.Release()
+		return err
+	}
+	manager := s.computeManager
+	var sub *compute.JobEventSubscription
+	if manager == nil {
+		err = fmt.Errorf("compute manager not initialized")
+	} else {
+		sub, err = manager.SubscribeJobEvents(jobID)
+	}
+	if err != nil {
+		listener.Release()
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+
+	// Push events to the client until the job ends, it unsubscribes or it
+	// stops answering
+	go func() {
+		defer listener.Release()
+		for evt := range sub.C {
+			ctx, cancel := context.WithTimeout(context.Background(), eventCallTimeout)
+			future, release := listener.OnJobEvent(ctx, func(p JobEventListener_onJobEvent_Params) error {
+				out, err := p.NewEvent()
+				if err != nil {
+					return err
+				}
+				return fillJobEvent(out, evt)
+			})
+			_, err := future.Struct()
+			release()
+			cancel()
+			if err != nil {
+				log.Printf("⚠️  [COMPUTE] Ending job event subscription %d: %v", sub.ID, err)
+				manager.UnsubscribeJobEvents(sub.ID)
+				return
+			}
+		}
+	}()
+
+	results.SetSubscriptionId(sub.ID)
+	results.SetSuccess(true)
+	return nil
+}
+
+func (s *nodeServiceServer) UnsubscribeJobEvents(ctx context.Context, call NodeService_unsubscribeJobEvents) error {
+	id := call.Args().SubscriptionId()
+
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	results.SetSuccess(s.computeManager != nil && s.computeManager.UnsubscribeJobEvents(id))
+	return nil
+}
+
+// fillJobEvent copies a compute job event into its Cap'n Proto form
+func fillJobEvent(out JobEvent, evt compute.JobEvent) error {
+	out.SetSeq(evt.Seq)
+	if err := out.SetJobId(evt.JobID); err != nil {
+		return err
+	}
+	if err := out.SetType(string(evt.Type)); err != nil {
+		return err
+	}
+	if err := out.SetStatus(evt.Status.String()); err != nil {
+		return err
+	}
+	out.SetChunkIndex(evt.ChunkIndex)
+	if err := out.SetWorkerId(evt.WorkerID); err != nil {
+		return err
+	}
+	out.SetCompletedChunks(evt.CompletedChunks)
+	out.SetTotalChunks(evt.TotalChunks)
+	out.SetProgress(evt.Progress)
+	if err := out.SetErrorMsg(evt.Error); err != nil {
+		return err
+	}
+	out.SetTimestamp(evt.Time.UnixMilli())
+	return nil
+}
+
+// ============================================================================
+// Compute Replay Methods
+// ============================================================================
+
+func (s *nodeServiceServer) GetReplayLog(ctx context.Context, call NodeService_getReplayLog) error {
+	jobID, _ := call.Args().JobId()
+
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	var rl *compute.ReplayLog
+	if s.computeManager == nil {
+		err = fmt.Errorf("compute manager not initialized")
+	} else {
+		rl, err = s.computeManager.GetReplayLog(jobID)
+	}
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+
+	entries, err := results.NewEntries(int32(len(rl.Entries)))
+	if err != nil {
+		return err
+	}
+	for i, e := range rl.Entries {
+		out := entries.At(i)
+		out.SetChunkIndex(e.ChunkIndex)
+		out.SetWorkerId(e.WorkerID)
+		out.SetInputHash(e.InputHash)
+		out.SetResultHash(e.ResultHash)
+		out.SetAccepted(e.Accepted)
+		out.SetStartedAt(e.StartedAt.UnixMilli())
+		out.SetDurationMs(uint64(e.Duration.Milliseconds()))
+		out.SetErrorMsg(e.Error)
+	}
+	results.SetSuccess(true)
+	return nil
+}
+
+func (s *nodeServiceServer) ReplayJob(ctx context.Context, call NodeService_replayJob) error {
+	args := call.Args()
+	jobID, _ := args.JobId()
+	var chunks []uint32
+	if list, err := args.Chunks(); err == nil {
+		for i := 0; i < list.Len(); i++ {
+			chunks = append(chunks, list.At(i))
+		}
+	}
+
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	var verdicts []compute.ReplayVerdict
+	if s.computeManager == nil {
+		err = fmt.Errorf("compute manager not initialized")
+	} else {
+		verdicts, err = s.computeManager.ReplayJob(jobID, chunks)
+	}
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+
+	list, err := results.NewVerdicts(int32(len(verdicts)))
+	if err != nil {
+		return err
+	}
+	for i, v := range verdicts {
+		out := list.At(i)
+		out.SetChunkIndex(v.ChunkIndex)
+		out.SetWorkerId(v.WorkerID)
+		out.SetAccepted(v.Accepted)
+		out.SetRecordedHash(v.RecordedHash)
+		out.SetLocalHash(v.LocalHash)
+		out.SetMatch(v.Match)
+		out.SetErrorMsg(v.Error)
+	}
+	results.SetSuccess(true)
+	return nil
+}
+
+// ============================================================================
+// Directory Upload Methods
+// ============================================================================
+
+func (s *nodeServiceServer) UploadDirectory(ctx context.Context, call NodeService_uploadDirectory) error {
+	request, err := call.Args().Request()
+	if err != nil {
+		return err
+	}
+	root, _ := request.Path()
+	requestedPolicy, _ := request.PlacementPolicy()
+	targetList, err := request.TargetPeers()
+	if err != nil {
+		return err
+	}
+	targetPeers := make([]uint32, targetList.Len())
+	for i := range targetPeers {
+		targetPeers[i] = targetList.At(i)
+	}
+
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	tree, err := planDirTree(root, request.PackThreshold())
+	if err == nil {
+		err = s.uploadDirTree(ctx, tree, targetPeers, int(request.Parallelism()), requestedPolicy)
+	}
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+
+	sig, err := s.signManifest(manifestKindTree, treeSignedContent(tree))
+	if err != nil {
+		return err
+	}
+	manifest, err := results.NewManifest()
+	if err != nil {
+		return err
+	}
+	if err := fillTreeManifest(manifest, tree, sig); err != nil {
+		return err
+	}
+	results.SetSuccess(true)
+	return nil
+}
+
+// uploadDirTree stores each blob of a planned tree, failing if any misses
+// its durability quorum
+func (s *nodeServiceServer) uploadDirTree(ctx context.Context, tree *dirTree, targetPeers []uint32, parallelism int, requestedPolicy string) error {
+	for i := range tree.Blobs {
+		blob := &tree.Blobs[i]
+		data, err := readBlob(*blob)
+		if err != nil {
+			return err
+		}
+		stored, err := s.uploadBlob(ctx, blob.Hash, data, targetPeers, parallelism, requestedPolicy)
+		if err != nil {
+			return fmt.Errorf("blob %d of %d: %w", i+1, len(tree.Blobs), err)
+		}
+		if confirmed, required := placementQuorum(stored.placements); confirmed < required {
+			return fmt.Errorf("blob %d of %d: %s", i+1, len(tree.Blobs), quorumFailure(stored.placements, confirmed, required))
+		}
+		blob.Placements = stored.placements
+	}
+	log.Printf("📁 Uploaded directory tree %s: %d entries in %d blobs", tree.RootHash, len(tree.Entries), len(tree.Blobs))
+	return nil
+}
+
+func (s *nodeServiceServer) DownloadTreeFile(ctx context.Context, call NodeService_downloadTreeFile) error {
+	args := call.Args()
+	in, err := args.Manifest()
+	if err != nil {
+		return err
+	}
+	filePath, _ := args.Path()
+
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	tree, sig, err := readTreeManifest(in)
+	if err == nil {
+		_, err = s.checkManifest(manifestKindTree, treeSignedContent(tree), sig, "")
+	}
+	var data []byte
+	if err == nil {
+		data, err = s.downloadTreeFile(ctx, tree, filePath, int(args.Parallelism()))
+	}
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	if err := results.SetData(data); err != nil {
+		return err
+	}
+	results.SetSuccess(true)
+	return nil
+}
+
+// downloadTreeFile fetches the blob holding one file of a tree and cuts
+// the file out of it
+func (s *nodeServiceServer) downloadTreeFile(ctx context.Context, tree *dirTree, filePath string, parallelism int) ([]byte, error) {
+	if err := tree.verify(); err != nil {
+		return nil, err
+	}
+	entry, ok := tree.find(filePath)
+	if !ok {
+		return nil, fmt.Errorf("no %q in tree %s", filePath, tree.RootHash)
+	}
+	if entry.Dir {
+		return nil, fmt.Errorf("%q is a directory", filePath)
+	}
+	if entry.Size == 0 {
+		return []byte{}, nil
+	}
+
+	blob := tree.Blobs[entry.Blob]
+	data, err := s.fetchBlob(ctx, blob.Hash, blob.Placements, parallelism)
+	if err != nil {
+		return nil, err
+	}
+	return extractFile(entry, data)
+}
+
+// fetchBlob fetches and reconstructs a CES-stored blob from its shard
+// placements
+func (s *nodeServiceServer) fetchBlob(ctx context.Context, hash string, placements []shardPlacement, parallelism int) ([]byte, error) {
+	minRequired := cesDataShards
+	shards, present, _ := fetchShards(ctx, s.shardSource(), hash, placements,
+		cesDataShards+cesParityShards, minRequired, parallelism)
+	presentCount := 0
+	for _, p := range present {
+		if p {
+			presentCount++
+		}
+	}
+	if presentCount < minRequired {
+		return nil, fmt.Errorf("Insufficient shards: have %d, need at least %d", presentCount, minRequired)
+	}
+	return s.reconstructDownload(ctx, hash, placements, shards, present)
+}
+
+// fillTreeManifest copies a directory tree and its signature, if any,
+// into their RPC form
+func fillTreeManifest(out TreeManifest, tree *dirTree, sig *manifestSignature) error {
+	if err := out.SetRootHash(tree.RootHash); err != nil {
+		return err
+	}
+	entries, err := out.NewEntries(int32(len(tree.Entries)))
+	if err != nil {
+		return err
+	}
+	for i, e := range tree.Entries {
+		entry := entries.At(i)
+		if err := entry.SetPath(e.Path); err != nil {
+			return err
+		}
+		entry.SetIsDir(e.Dir)
+		entry.SetSize(e.Size)
+		if err := entry.SetHash(e.Hash); err != nil {
+			return err
+		}
+		entry.SetBlob(int32(e.Blob))
+		entry.SetOffset(e.Offset)
+	}
+	blobs, err := out.NewBlobs(int32(len(tree.Blobs)))
+	if err != nil {
+		return err
+	}
+	for i, b := range tree.Blobs {
+		blob := blobs.At(i)
+		if err := blob.SetBlobHash(b.Hash); err != nil {
+			return err
+		}
+		blob.SetSize(b.Size)
+		blob.SetPacked(b.Packed)
+		locations, err := blob.NewShardLocations(int32(len(b.Placements)))
+		if err != nil {
+			return err
+		}
+		if err := fillShardLocations(locations, b.Placements); err != nil {
+			return err
+		}
+	}
+	if sig == nil {
+		return nil
+	}
+	signature, err := out.NewSignature()
+	if err != nil {
+		return err
+	}
+	return fillManifestSignature(signature, sig)
+}
+
+// readTreeManifest reads a tree manifest sent by a client, along with its
+// signature
+func readTreeManifest(in TreeManifest) (*dirTree, *manifestSignature, error) {
+	rootHash, err := in.RootHash()
+	if err != nil {
+		return nil, nil, err
+	}
+	entries, err := in.Entries()
+	if err != nil {
+		return nil, nil, err
+	}
+	blobs, err := in.Blobs()
+	if err != nil {
+		return nil, nil, err
+	}
+	sig, err := readManifestSignature(in.HasSignature(), in.Signature)
+	if err != nil {
+		return nil, nil, err
+	}
+	tree := &dirTree{RootHash: rootHash}
+	for i := 0; i < entries.Len(); i++ {
+		e := entries.At(i)
+		p, _ := e.Path()
+		hash, _ := e.Hash()
+		tree.Entries = append(tree.Entries, dirEntry{Path: p, Dir: e.IsDir(), Size: e.Size(), Hash: hash,
+			Blob: int(e.Blob()), Offset: e.Offset()})
+	}
+	for i := 0; i < blobs.Len(); i++ {
+		b := blobs.At(i)
+		hash, _ := b.BlobHash()
+		locations, err := b.ShardLocations()
+		if err != nil {
+			return nil, nil, err
+		}
+		tree.Blobs = append(tree.Blobs, dirBlob{Hash: hash, Size: b.Size(), Packed: b.Packed(),
+			Placements: shardLocationsFrom(locations)})
+	}
+	return tree, sig, nil
+}
+
+// ============================================================
+// Name Registry Methods
+// ============================================================
+
+// nameRegistry returns the name registry of the libp2p node
+func (s *nodeServiceServer) nameRegistry() (*NameRegistry, error) {
+	lib, ok := libp2pOf(s.network)
+	if !ok || lib.node == nil || lib.node.GetNameRegistry() == nil {
+		return nil, fmt.Errorf("named content requires a libp2p node")
+	}
+	return lib.node.GetNameRegistry(), nil
+}
+
+func (s *nodeServiceServer) PublishName(ctx context.Context, call NodeService_publishName) error {
+	name, _ := call.Args().Name()
+	value, _ := call.Args().Value()
+	ttl := time.Duration(call.Args().TtlSeconds()) * time.Second
+
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	nr, err := s.nameRegistry()
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	record, err := nr.Publish(ctx, name, value, ttl)
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	out, err := results.NewRecord()
+	if err != nil {
+		return err
+	}
+	if err := fillNameRecord(out, record); err != nil {
+		return err
+	}
+	results.SetSuccess(true)
+	return nil
+}
+
+func (s *nodeServiceServer) ResolveName(ctx context.Context, call NodeService_resolveName) error {
+	publisher, _ := call.Args().Publisher()
+	name, _ := call.Args().Name()
+
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	nr, err := s.nameRegistry()
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	record, err := nr.Resolve(ctx, publisher, name)
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	out, err := results.NewRecord()
+	if err != nil {
+		return err
+	}
+	if err := fillNameRecord(out, record); err != nil {
+		return err
+	}
+	results.SetSuccess(true)
+	return nil
+}
+
+// fillNameRecord copies a name record into its capnp form
+func fillNameRecord(out PublishedName, r *NameRecord) error {
+	if err := out.SetName(r.Name); err != nil {
+		return err
+	}
+	if err := out.SetValue(r.Value); err != nil {
+		return err
+	}
+	out.SetVersion(r.Version)
+	if err := out.SetPublisher(r.Publisher); err != nil {
+		return err
+	}
+	out.SetPublished(r.Published)
+	out.SetExpires(r.Expires)
+	return nil
+}
+
+// ============================================================
+// File Versioning Methods
+// ============================================================
+
+func (s *nodeServiceServer) UploadDelta(ctx context.Context, call NodeService_uploadDelta) error {
+	request, err := call.Args().Request()
+	if err != nil {
+		return err
+	}
+	data, _ := request.Data()
+	requestedPolicy, _ := request.PlacementPolicy()
+	targetList, err := request.TargetPeers()
+	if err != nil {
+		return err
+	}
+	targetPeers := make([]uint32, targetList.Len())
+	for i := range targetPeers {
+		targetPeers[i] = targetList.At(i)
+	}
+	blockSize := int(request.BlockSize())
+	if blockSize == 0 {
+		blockSize = defaultDeltaBlockSize
+	}
+
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	if blockSize < minDeltaBlockSize || blockSize > maxDeltaBlockSize {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(fmt.Sprintf("block size must be between %d and %d bytes", minDeltaBlockSize, maxDeltaBlockSize))
+	}
+	var versions []fileVersion
+	if request.HasBase() {
+		base, err := request.Base()
+		if err != nil {
+			return err
+		}
+		var sig *manifestSignature
+		if versions, sig, err = readVersionedFile(base); err != nil {
+			return err
+		}
+		if _, err := s.checkManifest(manifestKindVersion, versionsSignedContent(versions), sig, ""); err != nil {
+			results.SetSuccess(false)
+			return results.SetErrorMsg(fmt.Sprintf("base: %v", err))
+		}
+	}
+	versions, err = s.uploadVersion(ctx, versions, data, blockSize, targetPeers, int(request.Parallelism()), requestedPolicy)
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+
+	sig, err := s.signManifest(manifestKindVersion, versionsSignedContent(versions))
+	if err != nil {
+		return err
+	}
+	manifest, err := results.NewManifest()
+	if err != nil {
+		return err
+	}
+	if err := fillVersionedFile(manifest, versions, sig); err != nil {
+		return err
+	}
+	results.SetStoredBytes(versions[len(versions)-1].BlobSize)
+	results.SetSuccess(true)
+	return nil
+}
+
+// uploadVersion adds data as the next version of a file, rebuilding the
+// latest version to diff against and storing the blob the new version
+// needs
+func (s *nodeServiceServer) uploadVersion(ctx context.Context, versions []fileVersion, data []byte, blockSize int, targetPeers []uint32, parallelism int, requestedPolicy string) ([]fileVersion, error) {
+	var prev []byte
+	if len(versions) > 0 {
+		var err error
+		if prev, err = s.rebuildVersion(ctx, versions, len(versions)-1, parallelism); err != nil {
+			return nil, fmt.Errorf("failed to rebuild the latest version: %w", err)
+		}
+	}
+	v, blob := planVersion(prev, len(versions) > 0, data, blockSize)
+	if len(blob) > 0 {
+		stored, err := s.uploadBlob(ctx, v.BlobHash, blob, targetPeers, parallelism, requestedPolicy)
+		if err != nil {
+			return nil, err
+		}
+		if confirmed, required := placementQuorum(stored.placements); confirmed < required {
+			return nil, errors.New(quorumFailure(stored.placements, confirmed, required))
+		}
+		v.Placements = stored.placements
+	}
+	log.Printf("📝 Stored version %d of %s: %d of %d bytes (full: %v)", len(versions)+1, v.FileHash, v.BlobSize, v.Size, v.Full)
+	return append(versions, v), nil
+}
+
+func (s *nodeServiceServer) DownloadVersion(ctx context.Context, call NodeService_downloadVersion) error {
+	args := call.Args()
+	in, err := args.Manifest()
+	if err != nil {
+		return err
+	}
+
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	versions, sig, err := readVersionedFile(in)
+	if err == nil {
+		_, err = s.checkManifest(manifestKindVersion, versionsSignedContent(versions), sig, "")
+	}
+	var data []byte
+	if err == nil {
+		index := len(versions) - 1
+		if args.Version() > 0 {
+			index = int(args.Version()) - 1
+		}
+		data, err = s.rebuildVersion(ctx, versions, index, int(args.Parallelism()))
+	}
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	if err := results.SetData(data); err != nil {
+		return err
+	}
+	results.SetSuccess(true)
+	return nil
+}
+
+// rebuildVersion fetches the blobs a version needs and rebuilds it
+func (s *nodeServiceServer) rebuildVersion(ctx context.Context, versions []fileVersion, index, parallelism int) ([]byte, error) {
+	return rebuildVersion(versions, index, func(v fileVersion) ([]byte, error) {
+		return s.fetchBlob(ctx, v.BlobHash, v.Placements, parallelism)
+	})
+}
+
+// fillVersionedFile copies a version history and its signature, if any,
+// into their RPC form
+func fillVersionedFile(out VersionedFile, versions []fileVersion, sig *manifestSignature) error {
+	list, err := out.NewVersions(int32(len(versions)))
+	if err != nil {
+		return err
+	}
+	for i, v := range versions {
+		fv := list.At(i)
+		if err := fv.SetFileHash(v.FileHash); err != nil {
+			return err
+		}
+		fv.SetSize(v.Size)
+		fv.SetFull(v.Full)
+		if err := fv.SetBlobHash(v.BlobHash); err != nil {
+			return err
+		}
+		fv.SetBlobSize(v.BlobSize)
+		fv.SetCreated(v.Created)
+		locations, err := fv.NewShardLocations(int32(len(v.Placements)))
+		if err != nil {
+			return err
+		}
+		if err := fillShardLocations(locations, v.Placements); err != nil {
+			return err
+		}
+		ops, err := fv.NewOps(int32(len(v.Ops)))
+		if err != nil {
+			return err
+		}
+		for j, op := range v.Ops {
+			ops.At(j).SetFromBase(op.FromBase)
+			ops.At(j).SetOffset(op.Offset)
+			ops.At(j).SetLength(op.Length)
+		}
+	}
+	if sig == nil {
+		return nil
+	}
+	signature, err := out.NewSignature()
+	if err != nil {
+		return err
+	}
+	return fillManifestSignature(signature, sig)
+}
+
+// readVersionedFile reads a version history sent by a client, along with
+// its signature
+func readVersionedFile(in VersionedFile) ([]fileVersion, *manifestSignature, error) {
+	list, err := in.Versions()
+	if err != nil {
+		return nil, nil, err
+	}
+	sig, err := readManifestSignature(in.HasSignature(), in.Signature)
+	if err != nil {
+		return nil, nil, err
+	}
+	versions := make([]fileVersion, 0, list.Len())
+	for i := 0; i < list.Len(); i++ {
+		fv := list.At(i)
+		fileHash, _ := fv.FileHash()
+		blobHash, _ := fv.BlobHash()
+		locations, err := fv.ShardLocations()
+		if err != nil {
+			return nil, nil, err
+		}
+		ops, err := fv.Ops()
+		if err != nil {
+			return nil, nil, err
+		}
+		v := fileVersion{FileHash: fileHash, Size: fv.Size(), Full: fv.Full(), BlobHash: blobHash,
+			BlobSize: fv.BlobSize(), Placements: shardLocationsFrom(locations), Created: fv.Created()}
+		for j := 0; j < ops.Len(); j++ {
+			op := ops.At(j)
+			v.Ops = append(v.Ops, deltaOp{FromBase: op.FromBase(), Offset: op.Offset(), Length: op.Length()})
+		}
+		versions = append(versions, v)
+	}
+	return versions, sig, nil
+}
+
+// ============================================================
+// Access Control Methods
+// ============================================================
+
+// accessControl returns the access control of the libp2p node
+func (s *nodeServiceServer) accessControl() (*AccessControl, error) {
+	lib, ok := libp2pOf(s.network)
+	if !ok || lib.node == nil || lib.node.GetAccessControl() == nil {
+		return nil, fmt.Errorf("access control requires a libp2p node")
+	}
+	return lib.node.GetAccessControl(), nil
+}
+
+func (s *nodeServiceServer) IssueAccessGrant(ctx context.Context, call NodeService_issueAccessGrant) error {
+	fileHash, _ := call.Args().FileHash()
+	grantee, _ := call.Args().Grantee()
+	ttl := time.Duration(call.Args().TtlSeconds()) * time.Second
+
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	ac, err := s.accessControl()
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	grant, err := ac.Grant(fileHash, grantee, ttl)
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	token, err := grant.Token()
+	if err != nil {
+		return err
+	}
+	if err := results.SetToken(token); err != nil {
+		return err
+	}
+	out, err := results.NewGrant()
+	if err != nil {
+		return err
+	}
+	if err := fillAccessGrant(out, grant, false); err != nil {
+		return err
+	}
+	results.SetSuccess(true)
+	return nil
+}
+
+func (s *nodeServiceServer) RevokeAccessGrant(ctx context.Context, call NodeService_revokeAccessGrant) error {
+	grantID, _ := call.Args().GrantId()
+
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	ac, err := s.accessControl()
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	notified, err := ac.Revoke(ctx, grantID)
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	results.SetHoldersNotified(uint32(notified))
+	results.SetSuccess(true)
+	return nil
+}
+
+func (s *nodeServiceServer) AddAccessGrant(ctx context.Context, call NodeService_addAccessGrant) error {
+	token, _ := call.Args().Token()
+
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	ac, err := s.accessControl()
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	grant, err := ac.AddGrant(token)
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	out, err := results.NewGrant()
+	if err != nil {
+		return err
+	}
+	if err := fillAccessGrant(out, grant, false); err != nil {
+		return err
+	}
+	results.SetSuccess(true)
+	return nil
+}
+
+func (s *nodeServiceServer) ListAccessGrants(ctx context.Context, call NodeService_listAccessGrants) error {
+	fileHash, _ := call.Args().FileHash()
+
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	ac, err := s.accessControl()
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	grants := ac.ListGrants(fileHash)
+	list, err := results.NewGrants(int32(len(grants)))
+	if err != nil {
+		return err
+	}
+	for i := range grants {
+		if err := fillAccessGrant(list.At(i), &grants[i].AccessGrant, grants[i].Revoked); err != nil {
+			return err
+		}
+	}
+	results.SetSuccess(true)
+	return nil
+}
+
+// fillAccessGrant copies a grant into its capnp form
+func fillAccessGrant(out AccessGrantInfo, g *AccessGrant, revoked bool) error {
+	if err := out.SetGrantId(g.ID); err != nil {
+		return err
+	}
+	if err := out.SetFileHash(g.FileHash); err != nil {
+		return err
+	}
+	if err := out.SetGrantee(g.Grantee); err != nil {
+		return err
+	}
+	if err := out.SetIssuer(g.Issuer); err != nil {
+		return err
+	}
+	out.SetIssuedAt(g.IssuedAt)
+	out.SetExpires(g.Expires)
+	out.SetRevoked(revoked)
+	return nil
+}
+
+// ============================================================
+// Pinning Methods
+// ============================================================
+
+// pinnedNode returns the libp2p node, which holds the pinned shards
+func (s *nodeServiceServer) pinnedNode() (*LibP2PPangeaNode, error) {
+	lib, ok := libp2pOf(s.network)
+	if !ok || lib.node == nil || lib.node.GetPins() == nil {
+		return nil, fmt.Errorf("pinning requires a libp2p node")
+	}
+	return lib.node, nil
+}
+
+func (s *nodeServiceServer) PinContent(ctx context.Context, call NodeService_pinContent) error {
+	hash, _ := call.Args().Hash()
+	label, _ := call.Args().Label()
+	var refs []string
+	if list, err := call.Args().Refs(); err == nil {
+		for i := 0; i < list.Len(); i++ {
+			ref, _ := list.At(i)
+			refs = append(refs, ref)
+		}
+	}
+
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	node, err := s.pinnedNode()
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	if _, err := node.GetPins().Pin(hash, label, refs); err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	out, err := results.NewStatus()
+	if err != nil {
+		return err
+	}
+	if err := fillPinStatus(out, node, hash); err != nil {
+		return err
+	}
+	results.SetSuccess(true)
+	return nil
+}
+
+func (s *nodeServiceServer) UnpinContent(ctx context.Context, call NodeService_unpinContent) error {
+	hash, _ := call.Args().Hash()
+
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	node, err := s.pinnedNode()
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	if !node.GetPins().Unpin(hash) {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(fmt.Sprintf("%s is not pinned", hash))
+	}
+	results.SetSuccess(true)
+	return nil
+}
+
+func (s *nodeServiceServer) GetPinStatus(ctx context.Context, call NodeService_getPinStatus) error {
+	hash, _ := call.Args().Hash()
+
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	node, err := s.pinnedNode()
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	out, err := results.NewStatus()
+	if err != nil {
+		return err
+	}
+	if err := fillPinStatus(out, node, hash); err != nil {
+		return err
+	}
+	results.SetSuccess(true)
+	return nil
+}
+
+func (s *nodeServiceServer) ListPins(ctx context.Context, call NodeService_listPins) error {
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	node, err := s.pinnedNode()
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	pins := node.GetPins().List()
+	list, err := results.NewPins(int32(len(pins)))
+	if err != nil {
+		return err
+	}
+	for i, p := range pins {
+		if err := fillPinStatus(list.At(i), node, p.Hash); err != nil {
+			return err
+		}
+	}
+	results.SetSuccess(true)
+	return nil
+}
+
+// fillPinStatus writes the pin state and local shards of hash
+func fillPinStatus(out PinStatus, node *LibP2PPangeaNode, hash string) error {
+	if err := out.SetHash(hash); err != nil {
+		return err
+	}
+	pins := node.GetPins()
+	out.SetProtected(pins.Covers(hash))
+	if p, ok := pins.Get(hash); ok {
+		out.SetPinned(true)
+		out.SetPinnedAt(p.Pinned)
+		if err := out.SetLabel(p.Label); err != nil {
+			return err
+		}
+		refs, err := out.NewRefs(int32(len(p.Refs)))
+		if err != nil {
+			return err
+		}
+		for i, ref := range p.Refs {
+			if err := refs.Set(i, ref); err != nil {
+				return err
+			}
+		}
+	}
+	shards, bytes := node.LocalShardUsage(hash)
+	out.SetLocalShards(uint32(shards))
+	out.SetLocalBytes(bytes)
+	return nil
+}
+
+// ============================================================
+// Key Rotation Methods
+// ============================================================
+
+func (s *nodeServiceServer) RotateContentKey(ctx context.Context, call NodeService_rotateContentKey) error {
+	request, err := call.Args().Request()
+	if err != nil {
+		return err
+	}
+	in, err := request.Manifest()
+	if err != nil {
+		return err
+	}
+	manifest, err := readFileManifest(in)
+	if err != nil {
+		return err
+	}
+	fileHash, locations := manifest.FileHash, manifest.Placements
+	targets, err := request.TargetPeers()
+	if err != nil {
+		return err
+	}
+	targetPeers := make([]uint32, targets.Len())
+	for i := range targetPeers {
+		targetPeers[i] = targets.At(i)
+	}
+	parallelism := int(request.Parallelism())
+	policy, _ := request.PlacementPolicy()
+
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	lib, ok := libp2pOf(s.network)
+	if !ok || lib.node == nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg("key rotation requires a libp2p node")
+	}
+	if len(locations) == 0 {
+		results.SetSuccess(false)
+		return results.SetErrorMsg("manifest lists no shard locations")
+	}
+	if _, err := s.checkManifest(manifestKindFile, manifest.signedContent(), manifest.Signature, ""); err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	run := func(ctx context.Context, update func(func(*KeyRotation))) error {
+		return s.rotateContentKey(ctx, lib, update, manifest, targetPeers, parallelism, policy)
+	}
+	id, err := lib.node.GetKeyRotations().Start(fileHash, manifest.FileName, manifest.FileSize, manifest.TTL, run)
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	results.SetSuccess(true)
+	return results.SetJobId(id)
+}
+
+// rotateContentKey re-keys a file: it downloads the file under its old
+// key, uploads it under a fresh one as a new file hash, and once the new
+// shards reach quorum deletes the old shards and key shares from every
+// old holder
+func (s *nodeServiceServer) rotateContentKey(ctx context.Context, lib *LibP2PAdapter, update func(func(*KeyRotation)), manifest *fileManifest, targetPeers []uint32, parallelism int, policy string) error {
+	fileHash, locations := manifest.FileHash, manifest.Placements
+	var holders []uint32
+	seen := make(map[uint32]bool)
+	for _, loc := range locations {
+		if !seen[loc.peerID] {
+			seen[loc.peerID] = true
+			holders = append(holders, loc.peerID)
+		}
+	}
+	if len(targetPeers) == 0 {
+		targetPeers = holders
+	}
+
+	shards, present, _ := fetchShards(ctx, s.shardSource(), fileHash, locations,
+		cesDataShards+cesParityShards, cesDataShards, parallelism)
+	var have int
+	for _, p := range present {
+		if p {
+			have++
+		}
+	}
+	if have < cesDataShards {
+		return fmt.Errorf("insufficient shards: have %d, need at least %d", have, cesDataShards)
+	}
+	data, err := s.reconstructDownload(ctx, fileHash, locations, shards, present)
+	if err != nil {
+		return err
+	}
+	// Don't leave plaintext behind once it is re-encrypted
+	defer clear(data)
+	if err := checkContentHash(data, manifest.ContentHash); err != nil {
+		return err
+	}
+
+	newHash := newRotatedFileHash(fileHash)
+	update(func(r *KeyRotation) { r.State = RotationReencrypting })
+	ac := lib.node.GetAccessControl()
+	if ac.Restricted(fileHash) {
+		if newHash, err = ac.Protect(newHash); err != nil {
+			return err
+		}
+	}
+	stored, err := s.uploadBlob(ctx, newHash, data, targetPeers, parallelism, policy)
+	if err != nil {
+		return err
+	}
+	if confirmed, required := placementQuorum(stored.placements); confirmed < required {
+		// Keep the old copy and clean up what reached the new holders
+		for _, p := range stored.placements {
+			if p.confirmed {
+				lib.DeleteShards(ctx, p.peerID, newHash)
+			}
+		}
+		return errors.New(quorumFailure(stored.placements, confirmed, required))
+	}
+	update(func(r *KeyRotation) {
+		r.State = RotationDeleting
+		r.NewFileHash = newHash
+		r.ContentHash = fileContentHash(data)
+		r.Placements = stored.placements
+		r.Holders = uint32(len(holders))
+	})
+
+	// The new copy is complete; delete the old one everywhere it was
+	for _, h := range holders {
+		deleted, err := lib.DeleteShards(ctx, h, fileHash)
+		update(func(r *KeyRotation) {
+			if err != nil {
+				r.DeleteErrors = append(r.DeleteErrors, fmt.Sprintf("peer %d: %v", h, err))
+				return
+			}
+			r.HoldersCleared++
+			r.ShardsDeleted += uint32(deleted)
+		})
+	}
+	// This node's own key share of the old file, if it dealt itself one
+	lib.node.DeleteFileData(fileHash)
+	return nil
+}
+
+func (s *nodeServiceServer) GetKeyRotation(ctx context.Context, call NodeService_getKeyRotation) error {
+	id, _ := call.Args().JobId()
+
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	lib, ok := libp2pOf(s.network)
+	if !ok || lib.node == nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg("key rotation requires a libp2p node")
+	}
+	r, ok := lib.node.GetKeyRotations().Rotation(id)
+	if !ok {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(fmt.Sprintf("key rotation %s not found", id))
+	}
+	var manifest *fileManifest
+	if r.State == RotationCompleted {
+		manifest = r.manifest()
+		if manifest.Signature, err = signManifest(lib.node.host, manifestKindFile, manifest.signedContent()); err != nil {
+			return err
+		}
+	}
+	out, err := results.NewStatus()
+	if err != nil {
+		return err
+	}
+	if err := fillKeyRotation(out, &r, manifest); err != nil {
+		return err
+	}
+	results.SetSuccess(true)
+	return nil
+}
+
+// fillKeyRotation copies a rotation's state, and its new manifest once
+// completed, into their capnp form
+func fillKeyRotation(out KeyRotationStatus, r *KeyRotation, manifest *fileManifest) error {
+	if err := out.SetJobId(r.ID); err != nil {
+		return err
+	}
+	if err := out.SetFileHash(r.FileHash); err != nil {
+		return err
+	}
+	if err := out.SetNewFileHash(r.NewFileHash); err != nil {
+		return err
+	}
+	if err := out.SetState(r.State); err != nil {
+		return err
+	}
+	out.SetHolders(r.Holders)
+	out.SetHoldersCleared(r.HoldersCleared)
+	out.SetShardsDeleted(r.ShardsDeleted)
+	deleteErrors, err := out.NewDeleteErrors(int32(len(r.DeleteErrors)))
+	if err != nil {
+		return err
+	}
+	for i, msg := range r.DeleteErrors {
+		if err := deleteErrors.Set(i, msg); err != nil {
+			return err
+		}
+	}
+	if err := out.SetErrorMsg(r.Error); err != nil {
+		return err
+	}
+	out.SetStarted(r.Started.Unix())
+	out.SetUpdated(r.Updated.Unix())
+	if manifest == nil {
+		return nil
+	}
+	m, err := out.NewManifest()
+	if err != nil {
+		return err
+	}
+	return fillFileManifest(m, manifest)
+}
+
+// ============================================================
+// Node Summary
+// ============================================================
+
+func (s *nodeServiceServer) GetNodeSummary(ctx context.Context, call NodeService_getNodeSummary) error {
+	since := call.Args().SinceVersion()
+
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	summary := s.gatherSummary()
+	version, include := s.summary.update(summary, since)
+
+	out, err := results.NewSummary()
+	if err != nil {
+		return err
+	}
+	out.SetVersion(version)
+	if err := out.SetNodeId(summary.NodeID); err != nil {
+		return err
+	}
+	out.SetUptimeSecs(uint64(summary.Uptime.Seconds()))
+
+	if include[sectionPeers] {
+		if err := fillSummaryPeers(out, &summary.Peers); err != nil {
+			return err
+		}
+	}
+	if include[sectionReachability] {
+		if err := fillSummaryReachability(out, &summary.Reachability); err != nil {
+			return err
+		}
+	}
+	if include[sectionStorage] {
+		st := &summary.Storage
+		storage, err := out.NewStorage()
+		if err != nil {
+			return err
+		}
+		if err := storage.SetRole(st.Role.String()); err != nil {
+			return err
+		}
+		storage.SetUsedBytes(st.UsedBytes)
+		storage.SetQuotaBytes(st.QuotaBytes)
+		storage.SetReadOnly(st.Role == StorageReadOnly)
+		storage.SetShards(uint32(st.Shards))
+		storage.SetShardBytes(st.ShardBytes)
+		storage.SetPins(uint32(st.Pins))
+	}
+	if include[sectionJobs] {
+		if err := fillSummaryJobs(out, &summary.Jobs); err != nil {
+			return err
+		}
+	}
+	if include[sectionStreams] {
+		st := &summary.Streams
+		streams, err := out.NewStreams()
+		if err != nil {
+			return err
+		}
+		streams.SetStreaming(st.Streaming)
+		streams.SetFramesSent(st.FramesSent)
+		streams.SetFramesReceived(st.FramesReceived)
+		streams.SetBytesSent(st.BytesSent)
+		streams.SetBytesReceived(st.BytesReceived)
+		streams.SetVideoPeers(uint32(st.VideoPeers))
+		streams.SetVoicePeers(uint32(st.VoicePeers))
+		streams.SetChatPeers(uint32(st.ChatPeers))
+		streams.SetChatSessions(uint32(st.ChatSessions))
+		streams.SetConferenceRooms(uint32(st.ConferenceRooms))
+		streams.SetFileTransfers(uint32(st.FileTransfers))
+		streams.SetDownloads(uint32(st.Downloads))
+	}
+	if include[sectionCapacity] {
+		c := &summary.Capacity
+		capacity, err := out.NewCapacity()
+		if err != nil {
+			return err
+		}
+		capacity.SetCpuCores(c.CPUCores)
+		capacity.SetRamMb(c.RAMMB)
+		capacity.SetCurrentLoad(c.CurrentLoad)
+		capacity.SetDiskMb(c.DiskMB)
+		capacity.SetBandwidthMbps(c.BandwidthMbps)
+		capacity.SetTotalRamMb(c.TotalRAMMB)
+		coreLoads, err := capacity.NewCoreLoads(int32(len(c.CoreLoads)))
+		if err != nil {
+			return err
+		}
+		for i, load := range c.CoreLoads {
+			coreLoads.Set(i, load)
+		}
+	}
+	return nil
+}
+
+// fillSummaryPeers copies the peer section into its capnp form
+func fillSummaryPeers(out NodeSummary, set *summaryPeerSet) error {
+	peers, err := out.NewPeers()
+	if err != nil {
+		return err
+	}
+	peers.SetKnownPeers(uint32(set.Known))
+	peers.SetReachablePeers(uint32(set.Reachable))
+	list, err := peers.NewConnected(int32(len(set.Connected)))
+	if err != nil {
+		return err
+	}
+	for i, p := range set.Connected {
+		entry := list.At(i)
+		entry.SetPeerId(p.PeerID)
+		if err := entry.SetLibp2pId(p.Libp2pID); err != nil {
+			return err
+		}
+		if err := entry.SetAddress(p.Address); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fillSummaryReachability copies the reachability section into its capnp form
+func fillSummaryReachability(out NodeSummary, r *summaryReachability) error {
+	reach, err := out.NewReachability()
+	if err != nil {
+		return err
+	}
+	if err := reach.SetReachability(string(r.Reachability)); err != nil {
+		return err
+	}
+	if err := reach.SetNatType(string(r.NATType)); err != nil {
+		return err
+	}
+	addrs, err := textList(reach.Segment(), r.Addrs)
+	if err != nil {
+		return err
+	}
+	if err := reach.SetAddrs(addrs); err != nil {
+		return err
+	}
+	reach.SetPartitioned(r.Partitioned)
+	if r.Partitioned {
+		reach.SetPartitionedSince(r.Since.Unix())
+	}
+	return nil
+}
+
+// fillSummaryJobs copies the job section into its capnp form
+func fillSummaryJobs(out NodeSummary, j *summaryJobs) error {
+	jobs, err := out.NewJobs()
+	if err != nil {
+		return err
+	}
+	jobs.SetQueued(j.Queued)
+	jobs.SetRunning(j.Running)
+	jobs.SetCompleted(j.Completed)
+	jobs.SetFailed(j.Failed)
+	jobs.SetCancelled(j.Cancelled)
+	active, err := jobs.NewActive(int32(len(j.Active)))
+	if err != nil {
+		return err
+	}
+	for i, job := range j.Active {
+		status := active.At(i)
+		if err := status.SetJobId(job.JobID); err != nil {
+			return err
+		}
+		if err := status.SetStatus(job.Status.String()); err != nil {
+			return err
+		}
+		status.SetProgress(job.Progress)
+		status.SetCompletedChunks(job.CompletedChunks)
+		status.SetTotalChunks(job.TotalChunks)
+		status.SetEstimatedTimeRemaining(job.EstimatedTimeRemaining)
+	}
+	return nil
+}
+
+// ============================================================
+// Fault Injection
+// ============================================================
+
+func (s *nodeServiceServer) SetFaultInjection(ctx context.Context, call NodeService_setFaultInjection) error {
+	in, err := call.Args().Config()
+	if err != nil {
+		return err
+	}
+	config := FaultSettings{
+		ShardFetchDropRate:   float64(in.ShardFetchDropRate()),
+		ComputeDelay:         time.Duration(in.ComputeDelayMs()) * time.Millisecond,
+		ComputeDelayRate:     float64(in.ComputeDelayRate()),
+		StreamKillRate:       float64(in.StreamKillRate()),
+		StreamKillAfterBytes: in.StreamKillAfterBytes(),
+		Seed:                 in.Seed(),
+	}
+
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	lib, ok := libp2pOf(s.network)
+	if !ok || lib.node == nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg("fault injection requires a libp2p node")
+	}
+	if err := lib.node.GetFaultInjector().Configure(config); err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	results.SetSuccess(true)
+	return nil
+}
+
+func (s *nodeServiceServer) GetFaultInjection(ctx context.Context, call NodeService_getFaultInjection) error {
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	var faults *FaultInjector
+	if lib, ok := libp2pOf(s.network); ok && lib.node != nil {
+		faults = lib.node.GetFaultInjector()
+	}
+	config, stats := faults.Snapshot()
+	results.SetEnabled(faults.Enabled())
+
+	out, err := results.NewConfig()
+	if err != nil {
+		return err
+	}
+	out.SetShardFetchDropRate(float32(config.ShardFetchDropRate))
+	out.SetComputeDelayMs(uint32(config.ComputeDelay.Milliseconds()))
+	out.SetComputeDelayRate(float32(config.ComputeDelayRate))
+	out.SetStreamKillRate(float32(config.StreamKillRate))
+	out.SetStreamKillAfterBytes(config.StreamKillAfterBytes)
+	out.SetSeed(config.Seed)
+
+	counts, err := results.NewStats()
+	if err != nil {
+		return err
+	}
+	counts.SetShardFetchesDropped(stats.ShardFetchesDropped)
+	counts.SetComputeResponsesDelayed(stats.ComputeResponsesDelayed)
+	counts.SetStreamsKilled(stats.StreamsKilled)
+	return nil
+}
+
+// ============================================================
+// Bandwidth Limits
+// ============================================================
+
+func (s *nodeServiceServer) SetBandwidthLimits(ctx context.Context, call NodeService_setBandwidthLimits) error {
+	in, err := call.Args().Limits()
+	if err != nil {
+		return err
+	}
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	bandwidth := bandwidthOf(s.network)
+	if bandwidth == nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg("no network backend to limit")
+	}
+	bandwidth.SetLimits(BandwidthSettings{
+		Total:       in.TotalBytesPerSec(),
+		Bulk:        in.BulkBytesPerSec(),
+		Interactive: in.InteractiveBytesPerSec(),
+	})
+	results.SetSuccess(true)
+	return nil
+}
+
+func (s *nodeServiceServer) GetBandwidthLimits(ctx context.Context, call NodeService_getBandwidthLimits) error {
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	bandwidth := bandwidthOf(s.network)
+	limits := bandwidth.Limits()
+	out, err := results.NewLimits()
+	if err != nil {
+		return err
+	}
+	out.SetTotalBytesPerSec(limits.Total)
+	out.SetBulkBytesPerSec(limits.Bulk)
+	out.SetInteractiveBytesPerSec(limits.Interactive)
+
+	usage := bandwidth.Stats()
+	classes, err := results.NewClasses(int32(len(usage)))
+	if err != nil {
+		return err
+	}
+	for i, u := range usage {
+		c := classes.At(i)
+		c.SetClass(u.Class)
+		c.SetBytes(u.Bytes)
+		c.SetThrottledMs(uint64(u.Throttled.Milliseconds()))
+	}
+	return nil
+}
+
+// ============================================================
+// Shared Memory Rings
+// ============================================================
+
+// errNoSharedMemory is returned by shared memory RPCs on a service
+// created without a manager
+var errNoSharedMemory = errors.New("shared memory is not available on this node")
+
+// fillSharedMemoryRing writes a ring's RPC form
+func fillSharedMemoryRing(out SharedMemoryRingInfo, stats SharedMemoryRingStats) error {
+	if err := out.SetName(stats.Name); err != nil {
+		return err
+	}
+	if err := out.SetPath(stats.Path); err != nil {
+		return err
+	}
+	out.SetCapacity(stats.Capacity)
+	out.SetWriteCursor(stats.WriteCursor)
+	out.SetReadCursor(stats.ReadCursor)
+	out.SetUsed(stats.Used)
+	out.SetMessagesWritten(stats.MessagesWritten)
+	out.SetMessagesRead(stats.MessagesRead)
+	return nil
+}
+
+func (s *nodeServiceServer) CreateSharedMemoryRing(ctx context.Context, call NodeService_createSharedMemoryRing) error {
+	name, err := call.Args().Name()
+	if err != nil {
+		return err
+	}
+	size := call.Args().SizeBytes()
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	if s.shmMgr == nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(errNoSharedMemory.Error())
+	}
+	if size > uint64(MaxSharedMemoryRingSize) || size > math.MaxInt {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(fmt.Sprintf("ring size %d exceeds %d bytes", size, MaxSharedMemoryRingSize))
+	}
+	ring, err := s.shmMgr.CreateRing(name, int(size))
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	out, err := results.NewRing()
+	if err != nil {
+		return err
+	}
+	results.SetSuccess(true)
+	return fillSharedMemoryRing(out, ring.Stats())
+}
+
+func (s *nodeServiceServer) AttachSharedMemoryRing(ctx context.Context, call NodeService_attachSharedMemoryRing) error {
+	name, err := call.Args().Name()
+	if err != nil {
+		return err
+	}
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	if s.shmMgr == nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(errNoSharedMemory.Error())
+	}
+	ring, err := s.shmMgr.AttachRing(name)
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	out, err := results.NewRing()
+	if err != nil {
+		return err
+	}
+	results.SetSuccess(true)
+	return fillSharedMemoryRing(out, ring.Stats())
+}
+
+func (s *nodeServiceServer) DetachSharedMemoryRing(ctx context.Context, call NodeService_detachSharedMemoryRing) error {
+	name, err := call.Args().Name()
+	if err != nil {
+		return err
+	}
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	if s.shmMgr == nil {
+		err = errNoSharedMemory
+	} else {
+		err = s.shmMgr.DetachRing(name)
+	}
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	results.SetSuccess(true)
+	return nil
+}
+
+func (s *nodeServiceServer) DestroySharedMemoryRing(ctx context.Context, call NodeService_destroySharedMemoryRing) error {
+	name, err := call.Args().Name()
+	if err != nil {
+		return err
+	}
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	if s.shmMgr == nil {
+		err = errNoSharedMemory
+	} else {
+		err = s.shmMgr.DestroyRing(name)
+	}
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+	results.SetSuccess(true)
+	return nil
+}
+
+func (s *nodeServiceServer) ListSharedMemoryRings(ctx context.Context, call NodeService_listSharedMemoryRings) error {
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	var rings []*SharedMemoryRing
+	if s.shmMgr != nil {
+		rings = s.shmMgr.Rings()
+	}
+	list, err := results.NewRings(int32(len(rings)))
+	if err != nil {
+		return err
+	}
+	for i, ring := range rings {
+		if err := fillSharedMemoryRing(list.At(i), ring.Stats()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// shmReadBatchBytes stops a readSharedMemoryRing batch once it holds this
+// much data
+const shmReadBatchBytes = 16 << 20
+
+func (s *nodeServiceServer) SubscribeSharedMemoryRing(ctx context.Context, call NodeService_subscribeSharedMemoryRing) error {
+	args := call.Args()
+	name, _ := args.Name()
+	listener := args.Listener().AddRef()
+
+	results, err := call.AllocResults()
+	if err != nil {
+		listener.Release()
+		return err
+	}
+	var sub *SharedMemoryRingSubscription
+	if s.shmMgr == nil {
+		err = errNoSharedMemory
+	} else {
+		sub, err = s.shmMgr.Subscribe(name)
+	}
+	if err != nil {
+		listener.Release()
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+
+	// Tell the client of new messages until the ring is detached, it
+	// unsubscribes or it stops answering
+	go func() {
+		defer listener.Release()
+		for seq := range sub.C {
+			var pending uint64
+			if ring, ok := s.shmMgr.GetRing(name); ok {
+				stats := ring.Stats()
+				pending = stats.MessagesWritten - stats.MessagesRead
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), eventCallTimeout)
+			future, release := listener.OnRingData(ctx, func(p SharedMemoryRingListener_onRingData_Params) error {
+				p.SetSequence(seq)
+				p.SetPending(pending)
+				return p.SetName(name)
+			})
+			_, err := future.Struct()
+			release()
+			cancel()
+			if err != nil {
+				log.Printf("⚠️  [SHM] Ending ring subscription %d: %v", sub.ID, err)
+				s.shmMgr.Unsubscribe(sub.ID)
+				return
+			}
+		}
+	}()
+
+	results.SetSubscriptionId(sub.ID)
+	results.SetSuccess(true)
+	return nil
+}
+
+func (s *nodeServiceServer) UnsubscribeSharedMemoryRing(ctx context.Context, call NodeService_unsubscribeSharedMemoryRing) error {
+	id := call.Args().SubscriptionId()
+
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	results.SetSuccess(s.shmMgr != nil && s.shmMgr.Unsubscribe(id))
+	return nil
+}
+
+func (s *nodeServiceServer) ReadSharedMemoryRing(ctx context.Context, call NodeService_readSharedMemoryRing) error {
+	args := call.Args()
+	name, err := args.Name()
+	if err != nil {
+		return err
+	}
+	maxMessages := max(int(args.MaxMessages()), 1)
+	timeout := time.Duration(args.TimeoutMs()) * time.Millisecond
+
+	results, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+	var ring *SharedMemoryRing
+	if s.shmMgr == nil {
+		err = errNoSharedMemory
+	} else if r, ok := s.shmMgr.GetRing(name); ok {
+		ring = r
+	} else {
+		err = fmt.Errorf("ring %s is not attached", name)
+	}
+	if err != nil {
+		results.SetSuccess(false)
+		return results.SetErrorMsg(err.Error())
+	}
+
+	// Wait for the first message, then take what else is waiting
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	var messages [][]byte
+	var size int
+	for len(messages) < maxMessages && size < shmReadBatchBytes {
+		seq := ring.Sequence()
+		msg, err := ring.Read()
+		if errors.Is(err, errRingEmpty) && len(messages) == 0 && timeout > 0 {
+			_, waitErr := ring.Wait(waitCtx, seq)
+			if waitErr == nil {
+				continue
+			}
+			if errors.Is(waitErr, errRingDetached) {
+				err = waitErr
+			}
+		}
+		// Messages already taken are returned rather than lost
+		if errors.Is(err, errRingEmpty) || (err != nil && len(messages) > 0) {
+			break
+		}
+		if err != nil {
+			results.SetSuccess(false)
+			return results.SetErrorMsg(err.Error())
+		}
+		messages = append(messages, msg)
+		size += len(msg)
+	}
+
+	list, err := results.NewMessages(int32(len(messages)))
+	if err != nil {
+		return err
+	}
+	for i, msg := range messages {
+		if err := list.Set(i, msg); err != nil {
+			return err
+		}
+	}
+	results.SetSequence(ring.Sequence())
 	results.SetSuccess(true)
 	return nil
 }
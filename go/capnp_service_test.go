@@ -1,9 +1,17 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math"
 	"testing"
+	"time"
 
+	"github.com/pangea-net/go-node/internal/testutil"
+	"github.com/pangea-net/go-node/pkg/compute"
 	"github.com/pangea-net/go-node/pkg/crypto/dkg"
 )
 
@@ -42,3 +50,99 @@ func TestCESPipelineWithDKGKey(t *testing.T) {
 		t.Fatalf("Reconstructed mismatch: got %s want %s", string(reconstructed), string(input))
 	}
 }
+
+// The fake network's nodes stand in for transports and compute delegators
+var (
+	_ DataTransport         = (*testutil.Adapter)(nil)
+	_ compute.TaskDelegator = (*testutil.Adapter)(nil)
+)
+
+func TestUploadDownloadOverFakeNetwork(t *testing.T) {
+	requireCES(t)
+	net := testutil.NewNetwork(1)
+	net.Connect(1, 2, 3, 4, 5)
+	net.SetDefaultLink(testutil.Link{Latency: time.Millisecond})
+	uploader := NewNodeServiceServer(NewNodeStore(), net.Node(1), nil).(*nodeServiceServer)
+	ctx := context.Background()
+
+	data := bytes.Repeat([]byte("fake network upload "), 512)
+	sum := sha256.Sum256(data)
+	fileHash := fmt.Sprintf("%x", sum[:])
+	stored, err := uploader.uploadBlob(ctx, fileHash, data, []uint32{2, 3, 4}, 0, "")
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+	if confirmed, required := placementQuorum(stored.placements); confirmed < required {
+		t.Fatalf("only %d of %d shards confirmed", confirmed, required)
+	}
+	for _, p := range stored.placements {
+		if _, ok := net.Node(p.peerID).Shard(fileHash, p.shardIndex); !ok {
+			t.Fatalf("shard %d missing from node %d", p.shardIndex, p.peerID)
+		}
+	}
+
+	// Another node downloads it, fetching the holders' shards and shares
+	downloader := NewNodeServiceServer(NewNodeStore(), net.Node(5), nil).(*nodeServiceServer)
+	shards, present, _ := fetchShards(ctx, downloader.shardSource(), fileHash, stored.placements,
+		cesDataShards+cesParityShards, cesDataShards, 0)
+	got, err := downloader.reconstructDownload(ctx, fileHash, stored.placements, shards, present)
+	if err != nil {
+		t.Fatalf("download failed: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("downloaded %d bytes differing from the %d uploaded", len(got), len(data))
+	}
+	if err := checkContentHash(got, fileContentHash(data)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestComputeDelegationOverFakeNetwork(t *testing.T) {
+	net := testutil.NewNetwork(1)
+	net.Connect(1, 2, 3)
+	net.SetLink(1, 3, testutil.Link{Down: true})
+	for _, id := range []uint32{2, 3} {
+		worker := compute.NewManager(compute.DefaultConfig())
+		defer worker.Close()
+		net.Node(id).SetComputeManager(worker)
+	}
+	manager := compute.NewManager(compute.DefaultConfig())
+	defer manager.Close()
+	manager.SetDelegator(net.Node(1))
+
+	// A 4x2 by 2x2 multiply, split into row blocks across the workers
+	var input bytes.Buffer
+	for _, m := range [][]float64{{4, 2, 1, 2, 3, 4, 5, 6, 7, 8}, {2, 2, 1, 0, 0, 1}} {
+		binary.Write(&input, binary.BigEndian, uint32(m[0]))
+		binary.Write(&input, binary.BigEndian, uint32(m[1]))
+		for _, v := range m[2:] {
+			binary.Write(&input, binary.BigEndian, math.Float64bits(v))
+		}
+	}
+	expected, err := compute.ExecuteMatrixBlockMultiply(input.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobID, err := manager.SubmitJob(&compute.JobManifest{
+		JobID:         "fake-network-job",
+		InputData:     input.Bytes(),
+		SplitStrategy: compute.SplitMatrixRows,
+		MinChunkSize:  1,
+		MaxChunkSize:  64,
+		TimeoutSecs:   30,
+	})
+	if err != nil {
+		t.Fatalf("SubmitJob failed: %v", err)
+	}
+	result, err := manager.GetJobResult(jobID, 10*time.Second)
+	if err != nil {
+		t.Fatalf("job failed: %v", err)
+	}
+	if !bytes.Equal(result, expected) {
+		t.Fatal("delegated job result differs from the local multiply")
+	}
+	// Chunks sent to the unreachable worker ran elsewhere
+	if net.Node(2).Served() == 0 || net.Node(3).Served() != 0 {
+		t.Fatalf("expected only node 2 to run chunks, served %d and %d", net.Node(2).Served(), net.Node(3).Served())
+	}
+}
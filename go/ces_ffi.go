@@ -38,9 +38,16 @@ void ces_free_shards(FFIShards shards);
 import "C"
 import (
 	"fmt"
+	"time"
 	"unsafe"
 )
 
+// Reed-Solomon layout produced by the Rust CES pipeline
+const (
+	cesDataShards   = 8
+	cesParityShards = 4
+)
+
 // CESPipeline represents a Rust CES pipeline instance
 type CESPipeline struct {
 	handle unsafe.Pointer
@@ -83,31 +90,52 @@ func (c *CESPipeline) Close() {
 
 // Process data through the CES pipeline (Compress, Encrypt, Shard)
 func (c *CESPipeline) Process(data []byte) ([]ShardData, error) {
+	var shards []ShardData
+	err := c.ProcessStream(data, func(index int, shard ShardData) {
+		shards = append(shards, shard)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return shards, nil
+}
+
+// ProcessStream runs the CES pipeline and hands each shard to emit as it is
+// copied out of Rust memory, in index order once the whole shard set has
+// been validated. Encoding is not pipelined with sending: ces_process
+// compresses, encrypts and erasure-codes the whole input in one call, so
+// the first shard is emitted only after every shard has been encoded, and
+// callers overlap their sends with the copies out of Rust memory alone.
+// Overlapping the encoding itself needs a per-shard FFI call, which the
+// CES library does not have yet.
+func (c *CESPipeline) ProcessStream(data []byte, emit func(index int, shard ShardData)) error {
 	if c.handle == nil {
-		return nil, fmt.Errorf("pipeline is closed")
+		return fmt.Errorf("pipeline is closed")
 	}
 	if len(data) == 0 {
-		return nil, fmt.Errorf("data is empty")
+		return fmt.Errorf("data is empty")
 	}
 
 	// Call Rust FFI
+	start := time.Now()
 	ffiShards := C.ces_process(
 		c.handle,
 		(*C.uint8_t)(unsafe.Pointer(&data[0])),
 		C.size_t(len(data)),
 	)
+	elapsed := time.Since(start)
 	defer C.ces_free_shards(ffiShards)
 
 	// Check for error
 	if ffiShards.shards == nil || ffiShards.count == 0 {
-		return nil, fmt.Errorf("CES processing failed")
+		return fmt.Errorf("CES processing failed")
 	}
 
 	// Validate shard count to prevent out-of-bounds access
 	// Use a reasonable limit based on expected use cases (100 shards * 1MB = 100MB)
 	const maxShardCount = 1000
 	if ffiShards.count > maxShardCount {
-		return nil, fmt.Errorf("shard count too large: %d (max %d)", ffiShards.count, maxShardCount)
+		return fmt.Errorf("shard count too large: %d (max %d)", ffiShards.count, maxShardCount)
 	}
 
 	// Validate the total size doesn't exceed reasonable limits
@@ -122,27 +150,26 @@ func (c *CESPipeline) Process(data []byte) ([]ShardData, error) {
 
 	for i := 0; i < shardCount; i++ {
 		if cShards[i].data == nil {
-			return nil, fmt.Errorf("shard %d has no data", i)
+			return fmt.Errorf("shard %d has no data", i)
 		}
 		shardLen := uint64(cShards[i].len)
 		if shardLen > maxTotalSize {
-			return nil, fmt.Errorf("shard %d size too large: %d", i, shardLen)
+			return fmt.Errorf("shard %d size too large: %d", i, shardLen)
 		}
 		totalSize += shardLen
 		if totalSize > maxTotalSize {
-			return nil, fmt.Errorf("total shard size exceeds limit: %d > %d", totalSize, maxTotalSize)
+			return fmt.Errorf("total shard size exceeds limit: %d > %d", totalSize, maxTotalSize)
 		}
 	}
 
-	// Convert C shards to Go
-	shards := make([]ShardData, shardCount)
+	// Convert C shards to Go, emitting each one as soon as it is copied
 	for i := 0; i < shardCount; i++ {
-		// Copy shard data to Go
 		shardData := C.GoBytes(unsafe.Pointer(cShards[i].data), C.int(cShards[i].len))
-		shards[i] = ShardData{Data: shardData}
+		emit(i, ShardData{Data: shardData})
 	}
 
-	return shards, nil
+	recordCES("process", len(data), elapsed)
+	return nil
 }
 
 // Reconstruct data from shards (reverse CES pipeline)
@@ -183,6 +210,7 @@ func (c *CESPipeline) Reconstruct(shards []ShardData, present []bool) ([]byte, e
 	}()
 
 	// Call Rust FFI
+	start := time.Now()
 	result := C.ces_reconstruct(
 		c.handle,
 		(*C.FFIShard)(unsafe.Pointer(&cShards[0])),
@@ -206,6 +234,7 @@ func (c *CESPipeline) Reconstruct(shards []ShardData, present []bool) ([]byte, e
 	}
 
 	reconstructed := C.GoBytes(unsafe.Pointer(result.data), C.int(result.data_len))
+	recordCES("reconstruct", len(reconstructed), time.Since(start))
 	return reconstructed, nil
 }
 
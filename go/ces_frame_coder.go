@@ -0,0 +1,51 @@
+package main
+
+import "fmt"
+
+// cesFrameCoder erasure-codes video keyframes with the CES pipeline for
+// spreading across peers. Fragments are encrypted with the call's shared
+// key, so relays cannot read them and tampered fragments fail to decode.
+type cesFrameCoder struct {
+	key [32]byte
+}
+
+// cesFrameCompressionLevel is low because video frames are already
+// compressed by their codec
+const cesFrameCompressionLevel = 1
+
+func (c *cesFrameCoder) DataShards() int {
+	return cesDataShards
+}
+
+func (c *cesFrameCoder) Encode(data []byte) ([][]byte, error) {
+	pipeline := NewCESPipelineWithKey(cesFrameCompressionLevel, c.key)
+	if pipeline == nil {
+		return nil, fmt.Errorf("failed to create CES pipeline")
+	}
+	defer pipeline.Close()
+
+	shards, err := pipeline.Process(data)
+	if err != nil {
+		return nil, err
+	}
+	fragments := make([][]byte, len(shards))
+	for i, s := range shards {
+		fragments[i] = s.Data
+	}
+	return fragments, nil
+}
+
+func (c *cesFrameCoder) Decode(fragments [][]byte) ([]byte, error) {
+	pipeline := NewCESPipelineWithKey(cesFrameCompressionLevel, c.key)
+	if pipeline == nil {
+		return nil, fmt.Errorf("failed to create CES pipeline")
+	}
+	defer pipeline.Close()
+
+	shards := make([]ShardData, len(fragments))
+	present := make([]bool, len(fragments))
+	for i, f := range fragments {
+		shards[i], present[i] = ShardData{Data: f}, f != nil
+	}
+	return pipeline.Reconstruct(shards, present)
+}
@@ -0,0 +1,628 @@
+package main
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+const (
+	// ChatProtocolID is the libp2p protocol for ephemeral chat messages
+	ChatProtocolID = "/pangea/ephemeral-chat/1.0.0"
+
+	// Chat frame types
+	chatFrameHello   = "hello"   // Initiator opens a session and offers its key material
+	chatFrameWelcome = "welcome" // Responder answers with its key material
+	chatFrameKey     = "key"     // Initiator sends an RSA-wrapped session key
+	chatFrameMessage = "message" // An encrypted, optionally signed message
+	chatFrameAck     = "ack"     // Answer to key and message frames
+
+	// Key exchange methods
+	chatKeyExchangeX25519 = "x25519"
+	chatKeyExchangeRSA    = "rsa"
+	chatKeyExchangeNone   = "none"
+
+	chatSessionKeySize = 32
+	chatStreamTimeout  = 30 * time.Second
+	chatKeyInfo        = "pangea-chat-session-key"
+)
+
+// chatFrame is one JSON message on a chat stream
+type chatFrame struct {
+	Type        string `json:"type"`
+	SessionID   string `json:"sessionId"`
+	KeyExchange string `json:"keyExchange,omitempty"`
+	Cipher      string `json:"cipher,omitempty"`
+	Signatures  bool   `json:"signatures,omitempty"`
+	TTLSecs     uint32 `json:"ttlSecs,omitempty"` // Session retention (hello) or per-message TTL (message)
+	BurnOnRead  bool   `json:"burnOnRead,omitempty"`
+	PublicKey   []byte `json:"publicKey,omitempty"`  // X25519 public key or PKIX RSA key
+	WrappedKey  []byte `json:"wrappedKey,omitempty"` // RSA-OAEP session key
+	RatchetKey  []byte `json:"ratchetKey,omitempty"` // Responder's first ratchet key, then the sender's current one
+	PrevCount   uint32 `json:"prevCount,omitempty"`  // Messages on the sender's previous sending chain
+	Counter     uint32 `json:"counter,omitempty"`    // Message number on the sender's sending chain
+
+	MessageID      string `json:"messageId,omitempty"`
+	Timestamp      int64  `json:"timestamp,omitempty"`
+	EncryptionType string `json:"encryptionType,omitempty"`
+	Nonce          []byte `json:"nonce,omitempty"`
+	Payload        []byte `json:"payload,omitempty"`
+	Signature      []byte `json:"signature,omitempty"`
+
+	Error string `json:"error,omitempty"`
+}
+
+// ChatService delivers ephemeral chat messages over libp2p. Sessions are
+// set up per the session's EncryptionConfigData, with an X25519 or
+// RSA-wrapped handshake secret seeding a Double Ratchet so that every
+// message is sealed with a fresh ChaCha20-Poly1305 or AES-256-GCM key.
+// Messages carry libp2p identity signatures when enabled.
+type ChatService struct {
+	host     host.Host
+	security *SecurityManager
+	events   *EventBus
+	threat   *ThreatEngine // Scores failed key exchanges; may be nil
+	queues   *SendQueues   // Bounds concurrent sends per peer; may be nil
+}
+
+// NewChatService creates the service and registers its protocol handler.
+// Received messages are announced on events.
+func NewChatService(h host.Host, security *SecurityManager, events *EventBus) *ChatService {
+	cs := &ChatService{host: h, security: security, events: events}
+	h.SetStreamHandler(protocol.ID(ChatProtocolID), cs.handleStream)
+	return cs
+}
+
+// Send encrypts and signs msg per its session's configuration and delivers
+// it to the session's peer, opening the session on the peer first if needed
+func (cs *ChatService) Send(ctx context.Context, sessionID string, msg *EphemeralChatMessageData) error {
+	session, err := cs.security.GetChatSession(sessionID)
+	if err != nil {
+		return err
+	}
+	cs.security.mu.RLock()
+	peerAddr := session.PeerAddr
+	config := *session.EncryptionConfig
+	ttl, burnOnRead := session.MessageTTL, session.BurnOnRead
+	cs.security.mu.RUnlock()
+
+	p, err := peer.Decode(peerAddr)
+	if err != nil {
+		return fmt.Errorf("chat peer %q is not a peer ID: %w", peerAddr, err)
+	}
+	return cs.queues.Do(ctx, p, sendClassChat, func(ctx context.Context) error {
+		// Queued sends run concurrently, and each handshake replaces the
+		// responder's session, so only one of them opens it. The rest wait
+		// here until it has.
+		session.openMu.Lock()
+		cs.security.mu.RLock()
+		opened := session.opened
+		cs.security.mu.RUnlock()
+		if opened {
+			session.openMu.Unlock()
+		} else {
+			defer session.openMu.Unlock()
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, chatStreamTimeout)
+		defer cancel()
+		s, err := cs.host.NewStream(ctx, p, protocol.ID(ChatProtocolID))
+		if err != nil {
+			return fmt.Errorf("failed to open chat stream: %w", err)
+		}
+		defer s.Close()
+		if deadline, ok := ctx.Deadline(); ok {
+			s.SetDeadline(deadline)
+		}
+		encoder := json.NewEncoder(s)
+		decoder := json.NewDecoder(s)
+
+		encrypted := config.EncryptionType != "none"
+		if !opened {
+			ratchet, err := cs.initiate(encoder, decoder, sessionID, &config, ttl, burnOnRead)
+			if err != nil {
+				return fmt.Errorf("key exchange with %s failed: %w", shortPeerID(p), err)
+			}
+			cs.security.mu.Lock()
+			session.ratchet = ratchet
+			session.opened = true
+			cs.security.mu.Unlock()
+		}
+
+		if msg.MessageID == "" {
+			msg.MessageID = newChatMessageID()
+		}
+		if msg.Timestamp == 0 {
+			msg.Timestamp = time.Now().Unix()
+		}
+		frame := chatFrame{
+			Type:           chatFrameMessage,
+			SessionID:      sessionID,
+			MessageID:      msg.MessageID,
+			Timestamp:      msg.Timestamp,
+			EncryptionType: config.EncryptionType,
+			Payload:        msg.Message,
+			TTLSecs:        uint32(msg.TTL / time.Second),
+		}
+		if encrypted {
+			cs.security.mu.Lock()
+			var key []byte
+			var header ratchetHeader
+			err := fmt.Errorf("session %s was closed", sessionID)
+			if session.ratchet != nil {
+				key, header, err = session.ratchet.nextSendKey()
+			}
+			cs.security.mu.Unlock()
+			if err != nil {
+				return err
+			}
+			frame.RatchetKey, frame.PrevCount, frame.Counter = header.Key, header.PrevCount, header.Counter
+
+			aead, err := chatAEAD(config.SymmetricAlgo, key)
+			wipe(key)
+			if err != nil {
+				return err
+			}
+			frame.Nonce = make([]byte, aead.NonceSize())
+			rand.Read(frame.Nonce)
+			frame.Payload = aead.Seal(nil, frame.Nonce, msg.Message, chatMessageAAD(&frame))
+		}
+		if config.EnableSignatures {
+			if frame.Signature, err = cs.host.Peerstore().PrivKey(cs.host.ID()).Sign(chatSigningBytes(&frame)); err != nil {
+				return fmt.Errorf("failed to sign message: %w", err)
+			}
+		}
+
+		if err := encoder.Encode(frame); err != nil {
+			return fmt.Errorf("failed to send message: %w", err)
+		}
+		var ack chatFrame
+		if err := decoder.Decode(&ack); err != nil {
+			return fmt.Errorf("no acknowledgement: %w", err)
+		}
+		if ack.Error != "" {
+			return fmt.Errorf("peer rejected message: %s", ack.Error)
+		}
+		chatMessagesTotal.WithLabelValues("sent").Inc()
+		log.Printf("💬 [CHAT] Sent %s to %s (%s)", msg.MessageID, shortPeerID(p), sessionID)
+		return nil
+	})
+}
+
+// initiate opens the session on the peer, runs the initiator side of its
+// key exchange and starts the session's ratchet from the resulting secret.
+// Unencrypted sessions get no ratchet.
+func (cs *ChatService) initiate(encoder *json.Encoder, decoder *json.Decoder, sessionID string, config *EncryptionConfigData, ttl time.Duration, burnOnRead bool) (*doubleRatchet, error) {
+	hello := chatFrame{
+		Type:           chatFrameHello,
+		SessionID:      sessionID,
+		EncryptionType: config.EncryptionType,
+		KeyExchange:    chatKeyExchange(config),
+		Cipher:         config.SymmetricAlgo,
+		Signatures:     config.EnableSignatures,
+		TTLSecs:        uint32(ttl / time.Second),
+		BurnOnRead:     burnOnRead,
+	}
+	var ephemeral *ecdh.PrivateKey
+	if hello.KeyExchange == chatKeyExchangeX25519 {
+		var err error
+		if ephemeral, err = ecdh.X25519().GenerateKey(rand.Reader); err != nil {
+			return nil, err
+		}
+		hello.PublicKey = ephemeral.PublicKey().Bytes()
+	}
+	if err := encoder.Encode(hello); err != nil {
+		return nil, err
+	}
+
+	var welcome chatFrame
+	if err := decoder.Decode(&welcome); err != nil {
+		return nil, err
+	}
+	if welcome.Error != "" {
+		return nil, fmt.Errorf("%s", welcome.Error)
+	}
+
+	switch hello.KeyExchange {
+	case chatKeyExchangeNone:
+		return nil, nil
+	case chatKeyExchangeX25519:
+		secret, err := deriveX25519Key(ephemeral, welcome.PublicKey, sessionID)
+		if err != nil {
+			return nil, err
+		}
+		defer wipe(secret)
+		return newInitiatorRatchet(secret, welcome.RatchetKey)
+	}
+
+	// RSA: wrap a fresh session key with the responder's public key
+	parsed, err := x509.ParsePKIXPublicKey(welcome.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid RSA public key: %w", err)
+	}
+	pub, ok := parsed.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("not an RSA public key")
+	}
+	key := make([]byte, chatSessionKeySize)
+	rand.Read(key)
+	defer wipe(key)
+	wrapped, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, pub, key, []byte(sessionID))
+	if err != nil {
+		return nil, err
+	}
+	if err := encoder.Encode(chatFrame{Type: chatFrameKey, SessionID: sessionID, WrappedKey: wrapped}); err != nil {
+		return nil, err
+	}
+	var ack chatFrame
+	if err := decoder.Decode(&ack); err != nil {
+		return nil, err
+	}
+	if ack.Error != "" {
+		return nil, fmt.Errorf("%s", ack.Error)
+	}
+	return newInitiatorRatchet(key, welcome.RatchetKey)
+}
+
+// handleStream answers key exchanges and stores received messages until
+// the sender closes the stream
+func (cs *ChatService) handleStream(s network.Stream) {
+	defer s.Close()
+	s.SetDeadline(time.Now().Add(chatStreamTimeout))
+
+	remote := s.Conn().RemotePeer()
+	encoder := json.NewEncoder(s)
+	decoder := json.NewDecoder(s)
+	for {
+		var frame chatFrame
+		if err := decoder.Decode(&frame); err != nil {
+			return
+		}
+
+		var reply chatFrame
+		var err error
+		switch frame.Type {
+		case chatFrameHello:
+			reply, err = cs.accept(remote, &frame)
+		case chatFrameKey:
+			reply = chatFrame{Type: chatFrameAck, SessionID: frame.SessionID}
+			err = cs.unwrapKey(remote, &frame)
+		case chatFrameMessage:
+			reply = chatFrame{Type: chatFrameAck, SessionID: frame.SessionID, MessageID: frame.MessageID}
+			err = cs.receive(s, remote, &frame)
+		default:
+			err = fmt.Errorf("unknown frame type %q", frame.Type)
+			cs.threat.Record(remote, ThreatProtocolViolation, err.Error())
+		}
+		if err != nil {
+			log.Printf("❌ [CHAT] %s from %s: %v", frame.Type, shortPeerID(remote), err)
+			if frame.Type == chatFrameHello || frame.Type == chatFrameKey {
+				cs.threat.Record(remote, ThreatFailedHandshake, fmt.Sprintf("chat %s: %v", frame.Type, err))
+			}
+			reply = chatFrame{Type: chatFrameAck, SessionID: frame.SessionID, Error: err.Error()}
+		}
+		if err := encoder.Encode(reply); err != nil {
+			return
+		}
+	}
+}
+
+// accept creates the responder's side of a session and answers a hello
+// with this node's key material
+func (cs *ChatService) accept(remote peer.ID, hello *chatFrame) (chatFrame, error) {
+	welcome := chatFrame{Type: chatFrameWelcome, SessionID: hello.SessionID, KeyExchange: hello.KeyExchange}
+	if _, err := chatAEAD(hello.Cipher, make([]byte, chatSessionKeySize)); err != nil {
+		return welcome, err
+	}
+
+	var ratchet *doubleRatchet
+	if hello.KeyExchange != chatKeyExchangeNone {
+		var err error
+		if ratchet, err = newResponderRatchet(); err != nil {
+			return welcome, err
+		}
+		welcome.RatchetKey = ratchet.selfPub
+	}
+
+	switch hello.KeyExchange {
+	case chatKeyExchangeNone:
+	case chatKeyExchangeX25519:
+		ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+		if err != nil {
+			return welcome, err
+		}
+		secret, err := deriveX25519Key(ephemeral, hello.PublicKey, hello.SessionID)
+		if err != nil {
+			return welcome, err
+		}
+		ratchet.start(secret)
+		wipe(secret)
+		welcome.PublicKey = ephemeral.PublicKey().Bytes()
+	case chatKeyExchangeRSA:
+		pair, err := cs.security.defaultKeyPair()
+		if err != nil {
+			return welcome, err
+		}
+		if welcome.PublicKey, err = x509.MarshalPKIXPublicKey(pair.PublicKey); err != nil {
+			return welcome, err
+		}
+	default:
+		return welcome, fmt.Errorf("unsupported key exchange %q", hello.KeyExchange)
+	}
+
+	encryptionType, keyExchange := "asymmetric", "curve25519"
+	switch hello.KeyExchange {
+	case chatKeyExchangeNone:
+		encryptionType, keyExchange = "none", ""
+	case chatKeyExchangeRSA:
+		keyExchange = chatKeyExchangeRSA
+	}
+	cs.security.mu.Lock()
+	defer cs.security.mu.Unlock()
+	existing, ok := cs.security.chatSessions[hello.SessionID]
+	if ok && existing.PeerAddr != remote.String() {
+		return welcome, fmt.Errorf("session %s belongs to another peer", hello.SessionID)
+	}
+	if ok {
+		existing.wipe()
+	}
+	cs.security.chatSessions[hello.SessionID] = &ChatSessionData{
+		SessionID: hello.SessionID,
+		PeerAddr:  remote.String(),
+		EncryptionConfig: &EncryptionConfigData{
+			EncryptionType:   encryptionType,
+			KeyExchangeAlgo:  keyExchange,
+			SymmetricAlgo:    hello.Cipher,
+			EnableSignatures: hello.Signatures,
+		},
+		PublicKey:    hello.PublicKey,
+		Established:  time.Now(),
+		MessageTTL:   time.Duration(hello.TTLSecs) * time.Second,
+		BurnOnRead:   hello.BurnOnRead,
+		MessageQueue: make([]*EphemeralChatMessageData, 0),
+		ratchet:      ratchet,
+		opened:       true,
+	}
+	log.Printf("💬 [CHAT] Session %s opened by %s (%s, %s)", hello.SessionID, shortPeerID(remote), hello.KeyExchange, hello.Cipher)
+	return welcome, nil
+}
+
+// unwrapKey starts a session's ratchet from the secret an RSA initiator
+// wrapped for this node
+func (cs *ChatService) unwrapKey(remote peer.ID, frame *chatFrame) error {
+	if _, err := cs.security.defaultKeyPair(); err != nil {
+		return err
+	}
+	// The initiator may have wrapped with a key rotated out since
+	var key []byte
+	err := errors.New("no private key")
+	for _, pair := range cs.security.usableKeys("default") {
+		if pair.PrivateKey == nil {
+			continue
+		}
+		if key, err = rsa.DecryptOAEP(sha256.New(), rand.Reader, pair.PrivateKey, frame.WrappedKey, []byte(frame.SessionID)); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to unwrap session key: %w", err)
+	}
+	defer wipe(key)
+	if len(key) != chatSessionKeySize {
+		return fmt.Errorf("session key is %d bytes, want %d", len(key), chatSessionKeySize)
+	}
+
+	cs.security.mu.Lock()
+	defer cs.security.mu.Unlock()
+	session, ok := cs.security.chatSessions[frame.SessionID]
+	if !ok || session.PeerAddr != remote.String() || session.ratchet == nil {
+		return fmt.Errorf("no session %s with this peer", frame.SessionID)
+	}
+	session.ratchet.start(key)
+	return nil
+}
+
+// receive verifies and decrypts a message and queues it on its session.
+// The session's ratchet only advances once the message decrypts.
+func (cs *ChatService) receive(s network.Stream, remote peer.ID, frame *chatFrame) error {
+	cs.security.mu.RLock()
+	session, ok := cs.security.chatSessions[frame.SessionID]
+	var config EncryptionConfigData
+	if ok {
+		config = *session.EncryptionConfig
+	}
+	cs.security.mu.RUnlock()
+	if !ok || session.PeerAddr != remote.String() {
+		return fmt.Errorf("no session %s with this peer", frame.SessionID)
+	}
+
+	if config.EnableSignatures || len(frame.Signature) > 0 {
+		pub := s.Conn().RemotePublicKey()
+		if pub == nil {
+			return fmt.Errorf("sender's public key unavailable")
+		}
+		valid, err := pub.Verify(chatSigningBytes(frame), frame.Signature)
+		if err != nil || !valid {
+			return fmt.Errorf("invalid signature on message %s", frame.MessageID)
+		}
+	}
+
+	// The session, not the frame, decides whether a message must be encrypted
+	plaintext := frame.Payload
+	if config.EncryptionType != "none" {
+		var err error
+		if plaintext, err = cs.open(session, config.SymmetricAlgo, frame); err != nil {
+			return err
+		}
+	}
+
+	err := cs.security.AddChatMessage(frame.SessionID, &EphemeralChatMessageData{
+		FromPeer:       remote.String(),
+		ToPeer:         cs.host.ID().String(),
+		Message:        plaintext,
+		Timestamp:      frame.Timestamp,
+		MessageID:      frame.MessageID,
+		EncryptionType: config.EncryptionType,
+		Signature:      frame.Signature,
+		TTL:            time.Duration(frame.TTLSecs) * time.Second,
+	})
+	if err == nil {
+		chatMessagesTotal.WithLabelValues("received").Inc()
+		cs.events.Publish(NodeEvent{
+			Type:       EventChatReceived,
+			PeerID:     remote.String(),
+			Subject:    frame.SessionID,
+			Attributes: map[string]string{"messageId": frame.MessageID, "ephemeral": "true"},
+		})
+	}
+	return err
+}
+
+// open decrypts a message with the next key of the session's ratchet
+func (cs *ChatService) open(session *ChatSessionData, algo string, frame *chatFrame) ([]byte, error) {
+	cs.security.mu.Lock()
+	defer cs.security.mu.Unlock()
+	if session.ratchet == nil {
+		return nil, fmt.Errorf("session %s has no key", frame.SessionID)
+	}
+	header := ratchetHeader{Key: frame.RatchetKey, PrevCount: frame.PrevCount, Counter: frame.Counter}
+	key, next, err := session.ratchet.receiveKey(header)
+	if err != nil {
+		return nil, fmt.Errorf("message %s: %w", frame.MessageID, err)
+	}
+	defer wipe(key)
+
+	aead, err := chatAEAD(algo, key)
+	if err != nil {
+		next.zero()
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, frame.Nonce, frame.Payload, chatMessageAAD(frame))
+	if err != nil {
+		next.zero()
+		return nil, fmt.Errorf("failed to decrypt message %s", frame.MessageID)
+	}
+	session.ratchet.zero()
+	session.ratchet = next
+	return plaintext, nil
+}
+
+// defaultKeyPair returns this node's RSA key pair for wrapped session
+// keys, generating it on first use
+func (sm *SecurityManager) defaultKeyPair() (*RSAKeyPair, error) {
+	sm.mu.RLock()
+	pair, ok := sm.keyPairs["default"]
+	sm.mu.RUnlock()
+	if ok && pair.PrivateKey != nil {
+		return pair, nil
+	}
+	return sm.GenerateRSAKeyPair("default")
+}
+
+// sessionForPeer returns the most recently established session with a
+// peer, or "" if there is none
+func (sm *SecurityManager) sessionForPeer(peerAddr string) string {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	var id string
+	var latest time.Time
+	for _, session := range sm.chatSessions {
+		if session.PeerAddr == peerAddr && !session.Established.Before(latest) {
+			id, latest = session.SessionID, session.Established
+		}
+	}
+	return id
+}
+
+// chatKeyExchange maps a session's configuration to the key exchange used
+// on the wire; everything but RSA and unencrypted sessions uses X25519
+func chatKeyExchange(config *EncryptionConfigData) string {
+	switch {
+	case config.EncryptionType == "none":
+		return chatKeyExchangeNone
+	case strings.EqualFold(config.KeyExchangeAlgo, chatKeyExchangeRSA):
+		return chatKeyExchangeRSA
+	}
+	return chatKeyExchangeX25519
+}
+
+// chatAEAD returns the session cipher: AES-256-GCM for "aes256",
+// ChaCha20-Poly1305 otherwise
+func chatAEAD(algo string, key []byte) (cipher.AEAD, error) {
+	switch strings.ToLower(algo) {
+	case "aes256", "aes-256-gcm", "aes":
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		return cipher.NewGCM(block)
+	case "", "chacha20", "chacha20-poly1305":
+		return chacha20poly1305.New(key)
+	}
+	return nil, fmt.Errorf("unsupported symmetric algorithm %q", algo)
+}
+
+// deriveX25519Key derives a session key from an X25519 exchange, bound to
+// the session ID
+func deriveX25519Key(priv *ecdh.PrivateKey, peerPublic []byte, sessionID string) ([]byte, error) {
+	pub, err := ecdh.X25519().NewPublicKey(peerPublic)
+	if err != nil {
+		return nil, fmt.Errorf("invalid X25519 public key: %w", err)
+	}
+	shared, err := priv.ECDH(pub)
+	if err != nil {
+		return nil, err
+	}
+	return hkdf.Key(sha256.New, shared, []byte(sessionID), chatKeyInfo, chatSessionKeySize)
+}
+
+// chatAAD binds a ciphertext to its session, message ID and timestamp
+func chatAAD(sessionID, messageID string, timestamp int64) []byte {
+	aad := []byte(sessionID + "\x00" + messageID + "\x00")
+	return binary.BigEndian.AppendUint64(aad, uint64(timestamp))
+}
+
+// chatMessageAAD binds a ciphertext to its session, message, TTL and
+// ratchet header
+func chatMessageAAD(frame *chatFrame) []byte {
+	aad := chatAAD(frame.SessionID, frame.MessageID, frame.Timestamp)
+	aad = binary.BigEndian.AppendUint32(aad, frame.TTLSecs)
+	header := ratchetHeader{Key: frame.RatchetKey, PrevCount: frame.PrevCount, Counter: frame.Counter}
+	return header.appendAAD(aad)
+}
+
+// chatSigningBytes is what a message signature covers
+func chatSigningBytes(frame *chatFrame) []byte {
+	out := chatMessageAAD(frame)
+	out = append(out, frame.EncryptionType...)
+	out = append(out, 0)
+	out = append(out, frame.Nonce...)
+	return append(out, frame.Payload...)
+}
+
+// newChatMessageID returns a random message ID
+func newChatMessageID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return "msg-" + hex.EncodeToString(b)
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	// chatMaxSkippedKeys bounds the message keys a session keeps for
+	// messages that have not arrived yet
+	chatMaxSkippedKeys = 1000
+
+	chatRootInfo = "pangea-chat-ratchet-root"
+)
+
+// ratchetHeader travels with every message so the receiver can follow the
+// sender's ratchet
+type ratchetHeader struct {
+	Key       []byte // Sender's current ratchet public key
+	PrevCount uint32 // Messages the sender sent on its previous sending chain
+	Counter   uint32 // Message number on the current sending chain
+}
+
+// skippedKey identifies a message key held for a late message
+type skippedKey struct {
+	ratchetKey string
+	counter    uint32
+}
+
+// doubleRatchet is a session's Double Ratchet state. Every message is
+// sealed with its own key from a symmetric chain, and each change of
+// speaker runs an X25519 ratchet step, so old keys cannot be recomputed
+// from the current state.
+type doubleRatchet struct {
+	self      []byte // Our ratchet private key
+	selfPub   []byte
+	remote    []byte // Peer's ratchet public key
+	root      []byte
+	sendChain []byte
+	recvChain []byte
+	sendN     uint32
+	recvN     uint32
+	prevN     uint32
+	skipped   map[skippedKey][]byte
+}
+
+// newInitiatorRatchet starts the ratchet of the side that opened the
+// session, from the handshake secret and the responder's ratchet key
+func newInitiatorRatchet(secret, remote []byte) (*doubleRatchet, error) {
+	r, err := newResponderRatchet()
+	if err != nil {
+		return nil, err
+	}
+	out, err := ratchetDH(r.self, remote)
+	if err != nil {
+		return nil, err
+	}
+	defer wipe(out)
+	r.remote = bytes.Clone(remote)
+	r.root, r.sendChain, err = kdfRoot(secret, out)
+	return r, err
+}
+
+// newResponderRatchet creates a responder's ratchet key pair. The ratchet
+// can receive once start has been given the handshake secret, and send
+// once the initiator's first message has arrived.
+func newResponderRatchet() (*doubleRatchet, error) {
+	priv, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &doubleRatchet{
+		self:    priv.Bytes(),
+		selfPub: priv.PublicKey().Bytes(),
+		skipped: make(map[skippedKey][]byte),
+	}, nil
+}
+
+// start sets a responder's root key from the handshake secret
+func (r *doubleRatchet) start(secret []byte) {
+	r.root = bytes.Clone(secret)
+}
+
+// nextSendKey returns the key for the next outgoing message and its header
+func (r *doubleRatchet) nextSendKey() ([]byte, ratchetHeader, error) {
+	if r.sendChain == nil {
+		return nil, ratchetHeader{}, fmt.Errorf("cannot send before the peer's first message")
+	}
+	header := ratchetHeader{Key: bytes.Clone(r.selfPub), PrevCount: r.prevN, Counter: r.sendN}
+	var key []byte
+	key, r.sendChain = kdfChain(r.sendChain)
+	r.sendN++
+	return key, header, nil
+}
+
+// receiveKey returns the key for an incoming message and the state after
+// it. The state is only adopted once the message decrypts, so forged or
+// corrupt messages leave the session untouched.
+func (r *doubleRatchet) receiveKey(h ratchetHeader) ([]byte, *doubleRatchet, error) {
+	if r.root == nil {
+		return nil, nil, fmt.Errorf("ratchet has no root key yet")
+	}
+	next := r.clone()
+	id := skippedKey{string(h.Key), h.Counter}
+	if key, ok := next.skipped[id]; ok {
+		delete(next.skipped, id)
+		return key, next, nil
+	}
+
+	if !bytes.Equal(h.Key, next.remote) {
+		if err := next.skip(h.PrevCount); err != nil {
+			return nil, nil, err
+		}
+		if err := next.step(h.Key); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := next.skip(h.Counter); err != nil {
+		return nil, nil, err
+	}
+	if h.Counter != next.recvN {
+		return nil, nil, fmt.Errorf("message %d is a replay or its key was discarded", h.Counter)
+	}
+	var key []byte
+	key, next.recvChain = kdfChain(next.recvChain)
+	next.recvN++
+	return key, next, nil
+}
+
+// skip stores the keys of messages on the receiving chain before until
+func (r *doubleRatchet) skip(until uint32) error {
+	if r.recvChain == nil || until <= r.recvN {
+		return nil
+	}
+	if len(r.skipped)+int(until-r.recvN) > chatMaxSkippedKeys {
+		return fmt.Errorf("too many missing messages (%d)", until-r.recvN)
+	}
+	for r.recvN < until {
+		var key []byte
+		key, r.recvChain = kdfChain(r.recvChain)
+		r.skipped[skippedKey{string(r.remote), r.recvN}] = key
+		r.recvN++
+	}
+	return nil
+}
+
+// step runs an X25519 ratchet step for the peer's new ratchet key
+func (r *doubleRatchet) step(remote []byte) error {
+	r.prevN, r.sendN, r.recvN = r.sendN, 0, 0
+	r.remote = bytes.Clone(remote)
+
+	out, err := ratchetDH(r.self, remote)
+	if err != nil {
+		return err
+	}
+	root := r.root
+	r.root, r.recvChain, err = kdfRoot(root, out)
+	wipe(out, root)
+	if err != nil {
+		return err
+	}
+
+	priv, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+	wipe(r.self)
+	r.self, r.selfPub = priv.Bytes(), priv.PublicKey().Bytes()
+	if out, err = ratchetDH(r.self, remote); err != nil {
+		return err
+	}
+	root = r.root
+	r.root, r.sendChain, err = kdfRoot(root, out)
+	wipe(out, root)
+	return err
+}
+
+// clone deep-copies the state
+func (r *doubleRatchet) clone() *doubleRatchet {
+	c := *r
+	c.self = bytes.Clone(r.self)
+	c.root = bytes.Clone(r.root)
+	c.sendChain = bytes.Clone(r.sendChain)
+	c.recvChain = bytes.Clone(r.recvChain)
+	c.skipped = make(map[skippedKey][]byte, len(r.skipped))
+	for id, key := range r.skipped {
+		c.skipped[id] = bytes.Clone(key)
+	}
+	return &c
+}
+
+// zero wipes every secret the ratchet holds
+func (r *doubleRatchet) zero() {
+	wipe(r.self, r.root, r.sendChain, r.recvChain)
+	for id, key := range r.skipped {
+		wipe(key)
+		delete(r.skipped, id)
+	}
+	r.self, r.root, r.sendChain, r.recvChain = nil, nil, nil, nil
+}
+
+// appendAAD appends the header to a message's associated data
+func (h ratchetHeader) appendAAD(aad []byte) []byte {
+	aad = append(aad, h.Key...)
+	aad = binary.BigEndian.AppendUint32(aad, h.PrevCount)
+	return binary.BigEndian.AppendUint32(aad, h.Counter)
+}
+
+// kdfRoot mixes a DH output into the root key, returning the new root key
+// and a chain key
+func kdfRoot(root, dh []byte) ([]byte, []byte, error) {
+	out, err := hkdf.Key(sha256.New, dh, root, chatRootInfo, 2*chatSessionKeySize)
+	if err != nil {
+		return nil, nil, err
+	}
+	return out[:chatSessionKeySize], out[chatSessionKeySize:], nil
+}
+
+// kdfChain advances a chain key, returning a message key and the next
+// chain key
+func kdfChain(chain []byte) ([]byte, []byte) {
+	derive := func(b byte) []byte {
+		mac := hmac.New(sha256.New, chain)
+		mac.Write([]byte{b})
+		return mac.Sum(nil)
+	}
+	key, next := derive(1), derive(2)
+	wipe(chain)
+	return key, next
+}
+
+// ratchetDH returns the X25519 shared secret of a private and public key
+func ratchetDH(priv, pub []byte) ([]byte, error) {
+	sk, err := ecdh.X25519().NewPrivateKey(priv)
+	if err != nil {
+		return nil, err
+	}
+	pk, err := ecdh.X25519().NewPublicKey(pub)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ratchet key: %w", err)
+	}
+	return sk.ECDH(pk)
+}
+
+// wipe zeroes secrets in place
+func wipe(secrets ...[]byte) {
+	for _, b := range secrets {
+		clear(b)
+	}
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestChatDeliversEncryptedMessages(t *testing.T) {
+	n1, err := NewLibP2PPangeaNodeWithOptions(481, NewNodeStore(), false, true, 12480)
+	if err != nil {
+		t.Fatalf("failed to create node1: %v", err)
+	}
+	defer n1.cancel()
+	n2, err := NewLibP2PPangeaNodeWithOptions(482, NewNodeStore(), false, true, 12481)
+	if err != nil {
+		t.Fatalf("failed to create node2: %v", err)
+	}
+	defer n2.cancel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := n1.host.Connect(ctx, peer.AddrInfo{ID: n2.host.ID(), Addrs: n2.host.Addrs()}); err != nil {
+		t.Fatalf("connect n1->n2 failed: %v", err)
+	}
+
+	configs := map[string]*EncryptionConfigData{
+		"x25519-chacha20": {EncryptionType: "asymmetric", KeyExchangeAlgo: "curve25519", SymmetricAlgo: "chacha20", EnableSignatures: true},
+		"rsa-aes256":      {EncryptionType: "asymmetric", KeyExchangeAlgo: "rsa", SymmetricAlgo: "aes256"},
+		"plaintext":       {EncryptionType: "none", EnableSignatures: true},
+	}
+	for name, config := range configs {
+		t.Run(name, func(t *testing.T) {
+			if _, err := n1.GetSecurityManager().CreateChatSession(name, n2.host.ID().String(), config); err != nil {
+				t.Fatalf("CreateChatSession failed: %v", err)
+			}
+			for _, text := range []string{"hello", "again"} {
+				msg := &EphemeralChatMessageData{ToPeer: n2.host.ID().String(), Message: []byte(text)}
+				if err := n1.GetChatService().Send(ctx, name, msg); err != nil {
+					t.Fatalf("Send failed: %v", err)
+				}
+			}
+
+			received, err := n2.GetSecurityManager().GetChatMessages(name)
+			if err != nil {
+				t.Fatalf("receiver has no session: %v", err)
+			}
+			if len(received) != 2 || string(received[0].Message) != "hello" || string(received[1].Message) != "again" {
+				t.Fatalf("unexpected messages: %+v", received)
+			}
+			if received[0].FromPeer != n1.host.ID().String() {
+				t.Errorf("expected sender %s, got %s", n1.host.ID(), received[0].FromPeer)
+			}
+		})
+	}
+
+	// The responder can answer on the same session once the first message arrived
+	reply := &EphemeralChatMessageData{Message: []byte("hi back")}
+	if err := n2.GetChatService().Send(ctx, "x25519-chacha20", reply); err != nil {
+		t.Fatalf("reply failed: %v", err)
+	}
+	received, _ := n1.GetSecurityManager().GetChatMessages("x25519-chacha20")
+	if len(received) != 1 || string(received[0].Message) != "hi back" {
+		t.Fatalf("unexpected reply: %+v", received)
+	}
+
+	// A message under a chain the receiver doesn't share is rejected
+	session, _ := n1.GetSecurityManager().GetChatSession("x25519-chacha20")
+	session.ratchet.sendChain = make([]byte, chatSessionKeySize)
+	msg := &EphemeralChatMessageData{Message: []byte("forged")}
+	if err := n1.GetChatService().Send(ctx, "x25519-chacha20", msg); err == nil {
+		t.Error("expected a message under the wrong key to be rejected")
+	}
+
+	// Closing a session wipes its ratchet
+	peerSession, _ := n2.GetSecurityManager().GetChatSession("x25519-chacha20")
+	ratchet := peerSession.ratchet
+	root := ratchet.root
+	n2.GetSecurityManager().CloseChatSession("x25519-chacha20")
+	if ratchet.root != nil || len(ratchet.skipped) != 0 || !bytes.Equal(root, make([]byte, len(root))) {
+		t.Error("expected the closed session's ratchet to be zeroed")
+	}
+}
+
+func TestChatConcurrentFirstSends(t *testing.T) {
+	n1, err := NewLibP2PPangeaNodeWithOptions(691, NewNodeStore(), false, true, 0)
+	if err != nil {
+		t.Fatalf("failed to create node1: %v", err)
+	}
+	defer n1.cancel()
+	n2, err := NewLibP2PPangeaNodeWithOptions(692, NewNodeStore(), false, true, 0)
+	if err != nil {
+		t.Fatalf("failed to create node2: %v", err)
+	}
+	defer n2.cancel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := n1.host.Connect(ctx, peer.AddrInfo{ID: n2.host.ID(), Addrs: n2.host.Addrs()}); err != nil {
+		t.Fatalf("connect n1->n2 failed: %v", err)
+	}
+	config := &EncryptionConfigData{EncryptionType: "asymmetric", KeyExchangeAlgo: "curve25519", SymmetricAlgo: "chacha20"}
+	if _, err := n1.GetSecurityManager().CreateChatSession("burst", n2.host.ID().String(), config); err != nil {
+		t.Fatalf("CreateChatSession failed: %v", err)
+	}
+
+	// The queue's writers all start on the unopened session at once
+	const count = sendQueueWriters
+	errs := make(chan error, count)
+	for i := 0; i < count; i++ {
+		go func(i int) {
+			msg := &EphemeralChatMessageData{Message: []byte(fmt.Sprintf("message %d", i))}
+			errs <- n1.GetChatService().Send(ctx, "burst", msg)
+		}(i)
+	}
+	for i := 0; i < count; i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("Send failed: %v", err)
+		}
+	}
+	received, err := n2.GetSecurityManager().GetChatMessages("burst")
+	if err != nil {
+		t.Fatalf("receiver has no session: %v", err)
+	}
+	if len(received) != count {
+		t.Fatalf("expected %d messages, got %d", count, len(received))
+	}
+}
+
+func TestDoubleRatchet(t *testing.T) {
+	secret := bytes.Repeat([]byte{7}, chatSessionKeySize)
+	bob, err := newResponderRatchet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob.start(secret)
+	alice, err := newInitiatorRatchet(secret, bob.selfPub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type sealed struct {
+		key    []byte
+		header ratchetHeader
+	}
+	send := func(r *doubleRatchet) sealed {
+		key, header, err := r.nextSendKey()
+		if err != nil {
+			t.Fatalf("nextSendKey failed: %v", err)
+		}
+		return sealed{bytes.Clone(key), header}
+	}
+	receive := func(r **doubleRatchet, m sealed) error {
+		key, next, err := (*r).receiveKey(m.header)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(key, m.key) {
+			return fmt.Errorf("message %d: keys differ", m.header.Counter)
+		}
+		*r = next
+		return nil
+	}
+
+	if _, _, err := bob.nextSendKey(); err == nil {
+		t.Error("expected the responder to wait for the first message")
+	}
+
+	// Every message gets its own key, and they may arrive out of order
+	first := []sealed{send(alice), send(alice), send(alice)}
+	if bytes.Equal(first[0].key, first[1].key) {
+		t.Error("expected a fresh key per message")
+	}
+	for _, i := range []int{2, 0} {
+		if err := receive(&bob, first[i]); err != nil {
+			t.Fatalf("receive %d failed: %v", i, err)
+		}
+	}
+
+	// Bob's reply ratchets; Alice's late message from the old chain still opens
+	answer := send(bob)
+	if err := receive(&alice, answer); err != nil {
+		t.Fatalf("reply failed: %v", err)
+	}
+	second := send(alice)
+	if bytes.Equal(second.header.Key, first[0].header.Key) {
+		t.Error("expected a new ratchet key after the reply")
+	}
+	if err := receive(&bob, second); err != nil {
+		t.Fatalf("receive after ratchet failed: %v", err)
+	}
+	if err := receive(&bob, first[1]); err != nil {
+		t.Fatalf("late message failed: %v", err)
+	}
+
+	// Replays are rejected
+	if err := receive(&bob, first[1]); err == nil {
+		t.Error("expected a replayed message to be rejected")
+	}
+	if err := receive(&bob, second); err == nil {
+		t.Error("expected a replayed message to be rejected")
+	}
+
+	bob.zero()
+	if bob.root != nil || bob.self != nil || bob.sendChain != nil || bob.recvChain != nil {
+		t.Error("expected zero to drop every key")
+	}
+}
+
+func TestChatRetention(t *testing.T) {
+	sm := NewSecurityManager()
+	config := &EncryptionConfigData{EncryptionType: "none"}
+	keep, _ := sm.CreateChatSession("keep", "peer", config)
+	burn, _ := sm.CreateChatSession("burn", "peer", config)
+	burn.BurnOnRead = true
+
+	for _, id := range []string{"keep", "burn"} {
+		sm.AddChatMessage(id, &EphemeralChatMessageData{MessageID: "long", Message: []byte("stays")})
+		sm.AddChatMessage(id, &EphemeralChatMessageData{MessageID: "short", Message: []byte("goes"), TTL: time.Minute})
+	}
+	if got := keep.MessageQueue[0].ExpiresAt; time.Until(got) < DefaultChatRetention-time.Minute {
+		t.Errorf("expected the default retention without a session TTL, expires %v", got)
+	}
+
+	// Burn-on-read deletes on the first read; other sessions keep read messages
+	for _, id := range []string{"keep", "burn"} {
+		if got, _ := sm.GetChatMessages(id); len(got) != 2 {
+			t.Fatalf("%s: expected 2 messages on first read, got %d", id, len(got))
+		}
+		if got, _ := sm.GetChatMessages(id); len(got) != 0 {
+			t.Errorf("%s: expected no unread messages on second read, got %d", id, len(got))
+		}
+	}
+	if len(burn.MessageQueue) != 0 {
+		t.Errorf("expected burn-on-read session to be empty, has %d", len(burn.MessageQueue))
+	}
+	sm.mu.Lock()
+	retained := keep.takeMessages(true)
+	sm.mu.Unlock()
+	if len(retained) != 2 {
+		t.Fatalf("expected 2 retained messages, got %d", len(retained))
+	}
+
+	// The reaper deletes and wipes what has expired
+	short := retained[1]
+	if removed := sm.ReapExpiredMessages(time.Now().Add(2 * time.Minute)); removed != 1 {
+		t.Fatalf("expected 1 expired message, got %d", removed)
+	}
+	if len(keep.MessageQueue) != 1 || keep.MessageQueue[0].MessageID != "long" {
+		t.Errorf("expected only the long-lived message to remain")
+	}
+	if !bytes.Equal(short.Message, make([]byte, len("goes"))) {
+		t.Errorf("expected the expired message to be wiped, got %q", short.Message)
+	}
+}
@@ -0,0 +1,616 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"capnproto.org/go/capnp/v3/rpc"
+)
+
+// Admin subcommands talk to a running node over its Cap'n Proto socket:
+//
+//	pangea peers
+//	pangea upload [-peers 1,2,3] <file>
+//	pangea download [-o out] [-signer id] <hash>
+//	pangea jobs [job-id]
+//	pangea chat send <peer> <message>
+//	pangea pin <hash> [ref...]
+//	pangea unpin <hash>
+//	pangea pins [hash]
+//
+// Upload saves the file manifest under ~/.pangea/manifests so a later
+// download can find the shards by file hash.
+var cliCommands = map[string]func(ctx context.Context, client NodeService, args []string, out io.Writer) error{
+	"peers":    cliPeers,
+	"upload":   cliUpload,
+	"download": cliDownload,
+	"jobs":     cliJobs,
+	"chat":     cliChat,
+	"pin":      cliPin,
+	"unpin":    cliUnpin,
+	"pins":     cliPins,
+}
+
+// cliTimeout bounds one admin command, including shard transfers
+const cliTimeout = 5 * time.Minute
+
+// isCLICommand reports whether a first argument names an admin subcommand
+// rather than a daemon flag
+func isCLICommand(arg string) bool {
+	_, ok := cliCommands[arg]
+	return ok || arg == "help"
+}
+
+// runCLI runs an admin subcommand and returns the process exit code
+func runCLI(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "help" {
+		cliUsage(stderr)
+		return 2
+	}
+	command, ok := cliCommands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown command %q\n", args[0])
+		cliUsage(stderr)
+		return 2
+	}
+
+	fs := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	addr := fs.String("addr", cliDefaultAddr(), "Node Cap'n Proto address (host:port, unix:///path or npipe://name)")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	conn, err := dialCapnp(*addr)
+	if err != nil {
+		fmt.Fprintf(stderr, "failed to connect to node at %s: %v\n", *addr, err)
+		return 1
+	}
+	rpcConn := rpc.NewConn(rpc.NewStreamTransport(conn), nil)
+	defer rpcConn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), cliTimeout)
+	defer cancel()
+	client := NodeService(rpcConn.Bootstrap(ctx))
+	defer client.Release()
+
+	if err := command(ctx, client, fs.Args(), stdout); err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", args[0], err)
+		return 1
+	}
+	return 0
+}
+
+// cliDefaultAddr is the node address used without -addr
+func cliDefaultAddr() string {
+	if addr := os.Getenv("PANGEA_RPC_ADDR"); addr != "" {
+		return addr
+	}
+	return "localhost:8080"
+}
+
+func cliUsage(w io.Writer) {
+	fmt.Fprintln(w, `Usage: pangea <command> [-addr address] [arguments]
+
+Commands:
+  peers                              List connected libp2p peers
+  upload [-peers 1,2,3] <file>       Upload a file to connected (or the given) peers
+  download [-o out] [-signer id] <hash>
+                                     Download a file uploaded from this machine
+  jobs [job-id]                      List compute jobs, or show one
+  chat send <peer> <message>         Send a chat message to a peer ID or host:port
+  pin <hash> [ref...]                Keep content held here from expiry and eviction
+  unpin <hash>                       Remove a pin
+  pins [hash]                        List pins, or show the pin status of a hash
+
+The node address defaults to $PANGEA_RPC_ADDR, then localhost:8080.
+Run without a command to start the node.`)
+}
+
+func cliPeers(ctx context.Context, client NodeService, args []string, out io.Writer) error {
+	future, release := client.ListLibp2pPeers(ctx, nil)
+	defer release()
+	results, err := future.Struct()
+	if err != nil {
+		return err
+	}
+	peers, err := results.Peers()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < peers.Len(); i++ {
+		p, _ := peers.At(i)
+		fmt.Fprintln(out, p)
+	}
+	fmt.Fprintf(out, "%d peer(s) connected\n", peers.Len())
+	return nil
+}
+
+// cliManifest is an upload's file manifest as saved for later downloads
+type cliManifest struct {
+	FileHash    string             `json:"fileHash"`
+	FileName    string             `json:"fileName"`
+	FileSize    uint64             `json:"fileSize"`
+	ShardCount  uint32             `json:"shardCount"`
+	ParityCount uint32             `json:"parityCount"`
+	Shards      []cliShardLocation `json:"shards"`
+	Timestamp   int64              `json:"timestamp"`
+	ContentHash string             `json:"contentHash,omitempty"` // BLAKE3 of the file, checked on download
+	TTL         uint32             `json:"ttl,omitempty"`
+	SignedName  string             `json:"signedName,omitempty"` // File name in the node's signed manifest
+	Signature   *cliSignature      `json:"signature,omitempty"`
+}
+
+// cliSignature is the node's signature over an upload's manifest, sent
+// back with the manifest so the node can check it on download
+type cliSignature struct {
+	Signer    string `json:"signer"`
+	PublicKey []byte `json:"publicKey"`
+	Signature []byte `json:"signature"`
+}
+
+type cliShardLocation struct {
+	ShardIndex uint32 `json:"shardIndex"`
+	PeerID     uint32 `json:"peerId"`
+	ShardHash  string `json:"shardHash,omitempty"`
+}
+
+// signed is the saved manifest as the node signed it
+func (m *cliManifest) signed() *fileManifest {
+	placements := make([]shardPlacement, len(m.Shards))
+	for i, shard := range m.Shards {
+		placements[i] = shardPlacement{shardIndex: shard.ShardIndex, peerID: shard.PeerID, shardHash: shard.ShardHash}
+	}
+	return &fileManifest{
+		FileHash:    m.FileHash,
+		FileName:    m.SignedName,
+		FileSize:    m.FileSize,
+		ShardCount:  m.ShardCount,
+		ParityCount: m.ParityCount,
+		Placements:  placements,
+		Timestamp:   m.Timestamp,
+		TTL:         m.TTL,
+		ContentHash: m.ContentHash,
+		Signature:   &manifestSignature{Signer: m.Signature.Signer, PublicKey: m.Signature.PublicKey, Signature: m.Signature.Signature},
+	}
+}
+
+// cliManifestPath is where the manifest for a file hash is saved
+func cliManifestPath(fileHash string) (string, error) {
+	if strings.ContainsAny(fileHash, `/\.`) || fileHash == "" {
+		return "", fmt.Errorf("invalid file hash %q", fileHash)
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".pangea", "manifests", fileHash+".json"), nil
+}
+
+func cliUpload(ctx context.Context, client NodeService, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("upload", flag.ContinueOnError)
+	peerList := fs.String("peers", "", "Comma-separated peer IDs to store shards on (default: connected peers)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: pangea upload [-peers 1,2,3] <file>")
+	}
+	path := fs.Arg(0)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var peers []uint32
+	if *peerList != "" {
+		for _, p := range strings.Split(*peerList, ",") {
+			id, err := strconv.ParseUint(strings.TrimSpace(p), 10, 32)
+			if err != nil {
+				return fmt.Errorf("invalid peer ID %q", p)
+			}
+			peers = append(peers, uint32(id))
+		}
+	} else {
+		future, release := client.GetConnectedPeers(ctx, nil)
+		defer release()
+		results, err := future.Struct()
+		if err != nil {
+			return err
+		}
+		list, err := results.Peers()
+		if err != nil {
+			return err
+		}
+		for i := 0; i < list.Len(); i++ {
+			peers = append(peers, list.At(i))
+		}
+	}
+	if len(peers) == 0 {
+		return fmt.Errorf("no peers to upload to")
+	}
+
+	future, release := client.Upload(ctx, func(p NodeService_upload_Params) error {
+		request, err := p.NewRequest()
+		if err != nil {
+			return err
+		}
+		if err := request.SetData(data); err != nil {
+			return err
+		}
+		targets, err := request.NewTargetPeers(int32(len(peers)))
+		if err != nil {
+			return err
+		}
+		for i, id := range peers {
+			targets.Set(i, id)
+		}
+		return nil
+	})
+	defer release()
+	results, err := future.Struct()
+	if err != nil {
+		return err
+	}
+	response, err := results.Response()
+	if err != nil {
+		return err
+	}
+	if !response.Success() {
+		msg, _ := response.ErrorMsg()
+		return fmt.Errorf("upload failed: %s", msg)
+	}
+	manifest, err := response.Manifest()
+	if err != nil {
+		return err
+	}
+
+	saved := cliManifest{
+		FileName:    filepath.Base(path),
+		FileSize:    uint64(len(data)),
+		ShardCount:  manifest.ShardCount(),
+		ParityCount: manifest.ParityCount(),
+		Timestamp:   manifest.Timestamp(),
+		TTL:         manifest.Ttl(),
+	}
+	saved.FileHash, _ = manifest.FileHash()
+	saved.ContentHash, _ = manifest.ContentHash()
+	saved.SignedName, _ = manifest.FileName()
+	if manifest.HasSignature() {
+		sig, err := manifest.Signature()
+		if err != nil {
+			return err
+		}
+		saved.Signature = &cliSignature{}
+		saved.Signature.Signer, _ = sig.Signer()
+		saved.Signature.PublicKey, _ = sig.PublicKey()
+		saved.Signature.Signature, _ = sig.Signature()
+	}
+	locations, err := manifest.ShardLocations()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < locations.Len(); i++ {
+		loc := locations.At(i)
+		shardHash, _ := loc.ShardHash()
+		saved.Shards = append(saved.Shards, cliShardLocation{ShardIndex: loc.ShardIndex(), PeerID: loc.PeerId(), ShardHash: shardHash})
+	}
+
+	manifestPath, err := cliManifestPath(saved.FileHash)
+	if err != nil {
+		return err
+	}
+	encoded, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(manifestPath), 0700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(manifestPath, encoded, 0600); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Uploaded %s (%d bytes) to %d peer(s)\n", saved.FileName, saved.FileSize, len(peers))
+	fmt.Fprintf(out, "Confirmed shards: %d/%d\n", response.ConfirmedShards(), response.RequiredShards())
+	fmt.Fprintf(out, "File hash: %s\n", saved.FileHash)
+	return nil
+}
+
+func cliDownload(ctx context.Context, client NodeService, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("download", flag.ContinueOnError)
+	output := fs.String("o", "", "Output path (default: the uploaded file name)")
+	signer := fs.String("signer", "", "Peer ID the manifest must be signed by (default: the node's own)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: pangea download [-o out] [-signer id] <hash>")
+	}
+	manifestPath, err := cliManifestPath(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	encoded, err := os.ReadFile(manifestPath)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("no manifest for %s; only files uploaded from this machine can be downloaded", fs.Arg(0))
+	} else if err != nil {
+		return err
+	}
+	var manifest cliManifest
+	if err := json.Unmarshal(encoded, &manifest); err != nil {
+		return fmt.Errorf("corrupt manifest %s: %w", manifestPath, err)
+	}
+
+	future, release := client.Download(ctx, func(p NodeService_download_Params) error {
+		request, err := p.NewRequest()
+		if err != nil {
+			return err
+		}
+		if err := request.SetExpectedSigner(*signer); err != nil {
+			return err
+		}
+		if manifest.Signature != nil {
+			out, err := request.NewManifest()
+			if err != nil {
+				return err
+			}
+			return fillFileManifest(out, manifest.signed())
+		}
+		// A manifest saved without a signature, by a legacy-only node
+		if err := request.SetFileHash(manifest.FileHash); err != nil {
+			return err
+		}
+		if err := request.SetContentHash(manifest.ContentHash); err != nil {
+			return err
+		}
+		locations, err := request.NewShardLocations(int32(len(manifest.Shards)))
+		if err != nil {
+			return err
+		}
+		for i, shard := range manifest.Shards {
+			locations.At(i).SetShardIndex(shard.ShardIndex)
+			locations.At(i).SetPeerId(shard.PeerID)
+			if err := locations.At(i).SetShardHash(shard.ShardHash); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	defer release()
+	results, err := future.Struct()
+	if err != nil {
+		return err
+	}
+	response, err := results.Response()
+	if err != nil {
+		return err
+	}
+	if !response.Success() {
+		msg, _ := response.ErrorMsg()
+		return fmt.Errorf("download failed: %s", msg)
+	}
+	data, err := response.Data()
+	if err != nil {
+		return err
+	}
+
+	path := *output
+	if path == "" {
+		path = manifest.FileName
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Downloaded %d bytes to %s\n", len(data), path)
+	if signer, _ := response.Signer(); signer != "" {
+		fmt.Fprintf(out, "Manifest signed by %s\n", signer)
+	}
+	return nil
+}
+
+func cliJobs(ctx context.Context, client NodeService, args []string, out io.Writer) error {
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "JOB\tSTATUS\tPROGRESS\tCHUNKS\tETA")
+	row := func(status ComputeJobStatus) {
+		jobID, _ := status.JobId()
+		state, _ := status.Status()
+		fmt.Fprintf(tw, "%s\t%s\t%.0f%%\t%d/%d\t%ds\n", jobID, state, status.Progress()*100,
+			status.CompletedChunks(), status.TotalChunks(), status.EstimatedTimeRemaining())
+	}
+
+	switch len(args) {
+	case 0:
+		future, release := client.ListComputeJobs(ctx, nil)
+		defer release()
+		results, err := future.Struct()
+		if err != nil {
+			return err
+		}
+		jobs, err := results.Jobs()
+		if err != nil {
+			return err
+		}
+		for i := 0; i < jobs.Len(); i++ {
+			row(jobs.At(i))
+		}
+	case 1:
+		future, release := client.GetComputeJobStatus(ctx, func(p NodeService_getComputeJobStatus_Params) error {
+			return p.SetJobId(args[0])
+		})
+		defer release()
+		results, err := future.Struct()
+		if err != nil {
+			return err
+		}
+		status, err := results.Status()
+		if err != nil {
+			return err
+		}
+		if msg, _ := status.ErrorMsg(); msg != "" {
+			return fmt.Errorf("%s", msg)
+		}
+		row(status)
+	default:
+		return fmt.Errorf("usage: pangea jobs [job-id]")
+	}
+	return tw.Flush()
+}
+
+func cliChat(ctx context.Context, client NodeService, args []string, out io.Writer) error {
+	if len(args) < 3 || args[0] != "send" {
+		return fmt.Errorf("usage: pangea chat send <peer> <message>")
+	}
+	peerAddr, message := args[1], strings.Join(args[2:], " ")
+
+	future, release := client.SendChatMessage(ctx, func(p NodeService_sendChatMessage_Params) error {
+		msg, err := p.NewMessage_()
+		if err != nil {
+			return err
+		}
+		if err := msg.SetPeerAddr(peerAddr); err != nil {
+			return err
+		}
+		msg.SetTimestamp(time.Now().Unix())
+		return msg.SetMessage_(message)
+	})
+	defer release()
+	results, err := future.Struct()
+	if err != nil {
+		return err
+	}
+	if !results.Success() {
+		return fmt.Errorf("node could not deliver the message to %s", peerAddr)
+	}
+	fmt.Fprintf(out, "Sent to %s\n", peerAddr)
+	return nil
+}
+
+// cliPinRow writes one pin status as a table row
+func cliPinRow(tw io.Writer, status PinStatus) {
+	hash, _ := status.Hash()
+	label, _ := status.Label()
+	state := "unpinned"
+	if status.Pinned() {
+		state = "pinned"
+	} else if status.Protected() {
+		state = "via manifest"
+	}
+	refs, _ := status.Refs()
+	fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%s\n", hash, state, refs.Len(), status.LocalShards(),
+		status.LocalBytes(), label)
+}
+
+func cliPin(ctx context.Context, client NodeService, args []string, out io.Writer) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: pangea pin <hash> [ref...]")
+	}
+	hash, refs := args[0], args[1:]
+
+	future, release := client.PinContent(ctx, func(p NodeService_pinContent_Params) error {
+		if err := p.SetHash(hash); err != nil {
+			return err
+		}
+		list, err := p.NewRefs(int32(len(refs)))
+		if err != nil {
+			return err
+		}
+		for i, ref := range refs {
+			if err := list.Set(i, ref); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	defer release()
+	results, err := future.Struct()
+	if err != nil {
+		return err
+	}
+	if !results.Success() {
+		msg, _ := results.ErrorMsg()
+		return fmt.Errorf("%s", msg)
+	}
+	status, err := results.Status()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Pinned %s (%d refs, %d local shards)\n", hash, len(refs), status.LocalShards())
+	return nil
+}
+
+func cliUnpin(ctx context.Context, client NodeService, args []string, out io.Writer) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: pangea unpin <hash>")
+	}
+	future, release := client.UnpinContent(ctx, func(p NodeService_unpinContent_Params) error {
+		return p.SetHash(args[0])
+	})
+	defer release()
+	results, err := future.Struct()
+	if err != nil {
+		return err
+	}
+	if !results.Success() {
+		msg, _ := results.ErrorMsg()
+		return fmt.Errorf("%s", msg)
+	}
+	fmt.Fprintf(out, "Unpinned %s\n", args[0])
+	return nil
+}
+
+func cliPins(ctx context.Context, client NodeService, args []string, out io.Writer) error {
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "HASH\tSTATE\tREFS\tSHARDS\tBYTES\tLABEL")
+
+	switch len(args) {
+	case 0:
+		future, release := client.ListPins(ctx, nil)
+		defer release()
+		results, err := future.Struct()
+		if err != nil {
+			return err
+		}
+		if !results.Success() {
+			msg, _ := results.ErrorMsg()
+			return fmt.Errorf("%s", msg)
+		}
+		pins, err := results.Pins()
+		if err != nil {
+			return err
+		}
+		for i := 0; i < pins.Len(); i++ {
+			cliPinRow(tw, pins.At(i))
+		}
+	case 1:
+		future, release := client.GetPinStatus(ctx, func(p NodeService_getPinStatus_Params) error {
+			return p.SetHash(args[0])
+		})
+		defer release()
+		results, err := future.Struct()
+		if err != nil {
+			return err
+		}
+		if !results.Success() {
+			msg, _ := results.ErrorMsg()
+			return fmt.Errorf("%s", msg)
+		}
+		status, err := results.Status()
+		if err != nil {
+			return err
+		}
+		cliPinRow(tw, status)
+	default:
+		return fmt.Errorf("usage: pangea pins [hash]")
+	}
+	return tw.Flush()
+}
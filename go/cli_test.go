@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/pangea-net/go-node/pkg/compute"
+)
+
+func TestCLICommands(t *testing.T) {
+	node, err := NewLibP2PPangeaNodeWithOptions(520, NewNodeStore(), false, true, 12520)
+	if err != nil {
+		t.Fatalf("failed to create node: %v", err)
+	}
+	defer node.cancel()
+	manager := compute.NewManager(compute.DefaultConfig())
+	defer manager.Close()
+
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	addr := "unix://" + filepath.Join(dir, "node.sock")
+	listener, err := listenCapnp(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	store := NewNodeStore()
+	network := NewLibP2PAdapter(node, store)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go handleCapnpConnectionWithManager(conn, store, network, nil, manager)
+		}
+	}()
+
+	run := func(args ...string) (int, string, string) {
+		var stdout, stderr bytes.Buffer
+		code := runCLI(append([]string{args[0], "-addr", addr}, args[1:]...), &stdout, &stderr)
+		return code, stdout.String(), stderr.String()
+	}
+
+	if code, out, _ := run("peers"); code != 0 || !strings.Contains(out, "0 peer(s) connected") {
+		t.Fatalf("peers: code %d, output %q", code, out)
+	}
+	if code, out, _ := run("jobs"); code != 0 || !strings.HasPrefix(out, "JOB") {
+		t.Fatalf("jobs: code %d, output %q", code, out)
+	}
+	if code, _, errOut := run("jobs", "no-such-job"); code != 1 || !strings.Contains(errOut, "not found") {
+		t.Fatalf("expected unknown job to fail, got %d %q", code, errOut)
+	}
+	if code, _, errOut := run("upload", filepath.Join(dir, "missing.bin")); code != 1 || !strings.Contains(errOut, "no such file") {
+		t.Fatalf("expected missing file to fail, got %d %q", code, errOut)
+	}
+	file := filepath.Join(dir, "data.bin")
+	os.WriteFile(file, []byte("hello"), 0644)
+	if code, _, errOut := run("upload", file); code != 1 || !strings.Contains(errOut, "no peers") {
+		t.Fatalf("expected upload without peers to fail, got %d %q", code, errOut)
+	}
+	if code, _, errOut := run("download", "abc123"); code != 1 || !strings.Contains(errOut, "no manifest") {
+		t.Fatalf("expected download without manifest to fail, got %d %q", code, errOut)
+	}
+	if code, _, _ := run("download", "../etc"); code != 1 {
+		t.Fatal("expected a path in the hash to be rejected")
+	}
+	node.StoreShard("held", 0, []byte("shard"))
+	if code, out, _ := run("pin", "tree-root", "held"); code != 0 || !strings.Contains(out, "Pinned tree-root (1 refs") {
+		t.Fatalf("pin: code %d, output %q", code, out)
+	}
+	if code, out, _ := run("pins", "held"); code != 0 || !strings.Contains(out, "via manifest") {
+		t.Fatalf("pins held: code %d, output %q", code, out)
+	}
+	if code, out, _ := run("pins"); code != 0 || !strings.Contains(out, "tree-root") {
+		t.Fatalf("pins: code %d, output %q", code, out)
+	}
+	if code, _, _ := run("unpin", "tree-root"); code != 0 {
+		t.Fatalf("unpin failed with code %d", code)
+	}
+	if code, _, errOut := run("unpin", "tree-root"); code != 1 || !strings.Contains(errOut, "not pinned") {
+		t.Fatalf("expected a second unpin to fail, got %d %q", code, errOut)
+	}
+	// The node runs without the communication service
+	if code, _, errOut := run("chat", "send", node.GetHost().ID().String(), "hi"); code != 1 || !strings.Contains(errOut, "could not deliver") {
+		t.Fatalf("expected chat without the communication service to fail, got %d %q", code, errOut)
+	}
+
+	other, err := NewLibP2PPangeaNodeWithOptions(521, NewNodeStore(), false, true, 12521)
+	if err != nil {
+		t.Fatalf("failed to create peer node: %v", err)
+	}
+	defer other.cancel()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := other.host.Connect(ctx, peer.AddrInfo{ID: node.host.ID(), Addrs: node.host.Addrs()}); err != nil {
+		t.Fatalf("failed to connect nodes: %v", err)
+	}
+	if code, out, _ := run("peers"); code != 0 || !strings.Contains(out, "/p2p/"+other.host.ID().String()) || !strings.Contains(out, "1 peer(s)") {
+		t.Fatalf("peers: code %d, output %q", code, out)
+	}
+
+	var stderr bytes.Buffer
+	if code := runCLI([]string{"bogus"}, &stderr, &stderr); code != 2 {
+		t.Fatalf("expected usage error, got %d", code)
+	}
+	if isCLICommand("-node-id") || !isCLICommand("peers") {
+		t.Fatal("daemon flags must not be taken for subcommands")
+	}
+}
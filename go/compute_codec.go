@@ -0,0 +1,245 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"capnproto.org/go/capnp/v3"
+
+	"github.com/pangea-net/go-node/pkg/compute"
+)
+
+// Task messages are Cap'n Proto on ComputeProtocolV12 and JSON on earlier
+// versions. Decoded byte fields alias the message they were read from, so
+// input and result data are not copied out of it, nor base64-expanded on
+// the wire as JSON does.
+
+// taskMessageSegment allocates a segment big enough for a message carrying
+// data, so encoding it does not grow and copy the segment
+func taskMessageSegment(data []byte) (*capnp.Message, *capnp.Segment, error) {
+	return capnp.NewMessage(capnp.SingleSegment(make([]byte, 0, len(data)+1024)))
+}
+
+// readTaskMessage unmarshals a Cap'n Proto task message, allowing it to be
+// traversed up to the largest message the protocol carries
+func readTaskMessage(data []byte) (*capnp.Message, error) {
+	msg, err := capnp.Unmarshal(data)
+	if err != nil {
+		return nil, err
+	}
+	msg.ResetReadLimit(maxComputeMessageSize)
+	return msg, nil
+}
+
+// encodeTaskRequest serializes a task request for a stream
+func encodeTaskRequest(req *TaskRequest, binary bool) ([]byte, error) {
+	if !binary {
+		return json.Marshal(req)
+	}
+	msg, seg, err := taskMessageSegment(req.InputData)
+	if err != nil {
+		return nil, err
+	}
+	out, err := NewRootComputeTaskRequest(seg)
+	if err != nil {
+		return nil, err
+	}
+	if err := out.SetTaskId(req.TaskID); err != nil {
+		return nil, err
+	}
+	if err := out.SetParentJobId(req.ParentJobID); err != nil {
+		return nil, err
+	}
+	out.SetChunkIndex(req.ChunkIndex)
+	if err := out.SetInputData(req.InputData); err != nil {
+		return nil, err
+	}
+	if err := out.SetFunctionName(req.FunctionName); err != nil {
+		return nil, err
+	}
+	out.SetTimeoutMs(req.TimeoutMs)
+	out.SetDelegationDepth(req.DelegationDepth)
+	if len(req.TraceContext) > 0 {
+		headers, err := out.NewTraceContext(int32(len(req.TraceContext)))
+		if err != nil {
+			return nil, err
+		}
+		i := 0
+		for k, v := range req.TraceContext {
+			headers.At(i).SetKey(k)
+			headers.At(i).SetValue(v)
+			i++
+		}
+	}
+	return msg.Marshal()
+}
+
+// decodeTaskRequest parses a task request read from a stream
+func decodeTaskRequest(data []byte, binary bool) (*TaskRequest, error) {
+	var req TaskRequest
+	if !binary {
+		if err := json.Unmarshal(data, &req); err != nil {
+			return nil, err
+		}
+		return &req, nil
+	}
+	msg, err := readTaskMessage(data)
+	if err != nil {
+		return nil, err
+	}
+	in, err := ReadRootComputeTaskRequest(msg)
+	if err != nil {
+		return nil, err
+	}
+	req.TaskID, _ = in.TaskId()
+	req.ParentJobID, _ = in.ParentJobId()
+	req.ChunkIndex = in.ChunkIndex()
+	if req.InputData, err = in.InputData(); err != nil {
+		return nil, fmt.Errorf("invalid input data: %w", err)
+	}
+	req.FunctionName, _ = in.FunctionName()
+	req.TimeoutMs = in.TimeoutMs()
+	req.DelegationDepth = in.DelegationDepth()
+	if headers, err := in.TraceContext(); err == nil && headers.Len() > 0 {
+		req.TraceContext = make(map[string]string, headers.Len())
+		for i := 0; i < headers.Len(); i++ {
+			k, _ := headers.At(i).Key()
+			v, _ := headers.At(i).Value()
+			req.TraceContext[k] = v
+		}
+	}
+	return &req, nil
+}
+
+// encodeTaskResponse serializes a task response for a stream
+func encodeTaskResponse(resp *TaskResponse, binary bool) ([]byte, error) {
+	if !binary {
+		return json.Marshal(resp)
+	}
+	msg, seg, err := taskMessageSegment(resp.ResultData)
+	if err != nil {
+		return nil, err
+	}
+	out, err := NewRootComputeTaskResponse(seg)
+	if err != nil {
+		return nil, err
+	}
+	if err := out.SetTaskId(resp.TaskID); err != nil {
+		return nil, err
+	}
+	out.SetSuccess(resp.Success)
+	if err := out.SetResultData(resp.ResultData); err != nil {
+		return nil, err
+	}
+	if err := out.SetResultHash(resp.ResultHash); err != nil {
+		return nil, err
+	}
+	if len(resp.MerkleProof) > 0 {
+		proof, err := out.NewMerkleProof(int32(len(resp.MerkleProof)))
+		if err != nil {
+			return nil, err
+		}
+		for i, h := range resp.MerkleProof {
+			proof.Set(i, h)
+		}
+	}
+	out.SetExecutionTimeMs(resp.ExecutionTimeMs)
+	if err := out.SetError(resp.Error); err != nil {
+		return nil, err
+	}
+	if resp.Provenance != nil {
+		p, err := out.NewProvenance()
+		if err != nil {
+			return nil, err
+		}
+		if err := fillComputeProvenance(p, resp.Provenance); err != nil {
+			return nil, err
+		}
+	}
+	return msg.Marshal()
+}
+
+// decodeTaskResponse parses a task response read from a stream
+func decodeTaskResponse(data []byte, binary bool) (*TaskResponse, error) {
+	var resp TaskResponse
+	if !binary {
+		if err := json.Unmarshal(data, &resp); err != nil {
+			return nil, err
+		}
+		return &resp, nil
+	}
+	msg, err := readTaskMessage(data)
+	if err != nil {
+		return nil, err
+	}
+	in, err := ReadRootComputeTaskResponse(msg)
+	if err != nil {
+		return nil, err
+	}
+	resp.TaskID, _ = in.TaskId()
+	resp.Success = in.Success()
+	if resp.ResultData, err = in.ResultData(); err != nil {
+		return nil, fmt.Errorf("invalid result data: %w", err)
+	}
+	resp.ResultHash, _ = in.ResultHash()
+	if proof, err := in.MerkleProof(); err == nil && proof.Len() > 0 {
+		resp.MerkleProof = make([]string, proof.Len())
+		for i := range resp.MerkleProof {
+			resp.MerkleProof[i], _ = proof.At(i)
+		}
+	}
+	resp.ExecutionTimeMs = in.ExecutionTimeMs()
+	resp.Error, _ = in.Error()
+	if in.HasProvenance() {
+		p, err := in.Provenance()
+		if err != nil {
+			return nil, fmt.Errorf("invalid provenance: %w", err)
+		}
+		resp.Provenance = readComputeProvenance(p)
+	}
+	return &resp, nil
+}
+
+// fillComputeProvenance writes a provenance tree into its RPC form
+func fillComputeProvenance(out ComputeProvenance, p *compute.Provenance) error {
+	if err := out.SetWorkerId(p.WorkerID); err != nil {
+		return err
+	}
+	out.SetDepth(p.Depth)
+	out.SetRowStart(p.RowStart)
+	out.SetRowEnd(p.RowEnd)
+	if err := out.SetResultHash(p.ResultHash); err != nil {
+		return err
+	}
+	if len(p.Children) == 0 {
+		return nil
+	}
+	children, err := out.NewChildren(int32(len(p.Children)))
+	if err != nil {
+		return err
+	}
+	for i := range p.Children {
+		if err := fillComputeProvenance(children.At(i), &p.Children[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readComputeProvenance reads a provenance tree from its RPC form
+func readComputeProvenance(in ComputeProvenance) *compute.Provenance {
+	p := &compute.Provenance{
+		Depth:    in.Depth(),
+		RowStart: in.RowStart(),
+		RowEnd:   in.RowEnd(),
+	}
+	p.WorkerID, _ = in.WorkerId()
+	p.ResultHash, _ = in.ResultHash()
+	if children, err := in.Children(); err == nil && children.Len() > 0 {
+		p.Children = make([]compute.Provenance, children.Len())
+		for i := range p.Children {
+			p.Children[i] = *readComputeProvenance(children.At(i))
+		}
+	}
+	return p
+}
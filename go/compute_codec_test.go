@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/pangea-net/go-node/pkg/compute"
+)
+
+func TestComputeTaskCodec(t *testing.T) {
+	req := &TaskRequest{
+		TaskID:          "job-1-chunk-3",
+		ParentJobID:     "job-1",
+		ChunkIndex:      3,
+		InputData:       bytes.Repeat([]byte{0x00, 0x01, 0xfe, 0xff}, 64<<10),
+		FunctionName:    "matrix_block_multiply",
+		TimeoutMs:       30000,
+		DelegationDepth: 1,
+		TraceContext:    map[string]string{"traceparent": "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"},
+	}
+	resp := &TaskResponse{
+		TaskID:          req.TaskID,
+		Success:         true,
+		ResultData:      []byte("result"),
+		ResultHash:      "hash",
+		MerkleProof:     []string{"a", "b"},
+		ExecutionTimeMs: 12,
+		Provenance: &compute.Provenance{WorkerID: "w0", Depth: 1, RowEnd: 4, ResultHash: "hash", Children: []compute.Provenance{
+			{WorkerID: "w1", Depth: 2, RowEnd: 2, ResultHash: "h1"},
+			{WorkerID: "w2", Depth: 2, RowStart: 2, RowEnd: 4, ResultHash: "h2"},
+		}},
+	}
+
+	// Both encodings read back what was written
+	for _, binary := range []bool{false, true} {
+		data, err := encodeTaskRequest(req, binary)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gotReq, err := decodeTaskRequest(data, binary)
+		if err != nil || !reflect.DeepEqual(gotReq, req) {
+			t.Fatalf("binary=%v: request read back as %+v (%v)", binary, gotReq, err)
+		}
+		data, err = encodeTaskResponse(resp, binary)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gotResp, err := decodeTaskResponse(data, binary)
+		if err != nil || !reflect.DeepEqual(gotResp, resp) {
+			t.Fatalf("binary=%v: response read back as %+v (%v)", binary, gotResp, err)
+		}
+	}
+
+	// Input data is carried as is rather than base64-expanded, and read
+	// back in place
+	jsonData, _ := encodeTaskRequest(req, false)
+	data, _ := encodeTaskRequest(req, true)
+	if len(data) > len(req.InputData)+1024 || len(jsonData) < len(req.InputData)*4/3 {
+		t.Fatalf("%d byte input encoded as %d bytes binary, %d JSON", len(req.InputData), len(data), len(jsonData))
+	}
+	decoded, _ := decodeTaskRequest(data, true)
+	decoded.InputData[0] = 0x42
+	if again, _ := decodeTaskRequest(data, true); again.InputData[0] != 0x42 {
+		t.Fatal("decoded input data was copied out of the message")
+	}
+
+	// A failed task without result data or provenance reads back empty
+	failed := &TaskResponse{TaskID: "t", Error: "worker unavailable"}
+	data, _ = encodeTaskResponse(failed, true)
+	gotFailed, err := decodeTaskResponse(data, true)
+	if err != nil || gotFailed.Success || gotFailed.Error != failed.Error || len(gotFailed.ResultData) != 0 || gotFailed.Provenance != nil {
+		t.Fatalf("failed response read back as %+v (%v)", gotFailed, err)
+	}
+	if _, err := decodeTaskRequest([]byte("not capnp"), true); err == nil {
+		t.Fatal("expected a malformed request to be rejected")
+	}
+}
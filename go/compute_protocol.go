@@ -3,13 +3,13 @@ package main
 import (
 	"bytes"
 	"context"
-	"crypto/sha256"
 	"encoding/binary"
-	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"strings"
 	"sync"
 	"time"
 
@@ -17,6 +17,8 @@ import (
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/protocol"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/pangea-net/go-node/pkg/compute"
 )
@@ -25,10 +27,26 @@ const (
 	// ComputeProtocolID is the libp2p protocol for distributed compute
 	ComputeProtocolID = "/pangea/compute/1.0.0"
 
+	// ComputeProtocolV11 frames task messages with checksums and optional
+	// compression (see writeComputeMessage). Nodes serve every version and
+	// prefer it over ComputeProtocolID when sending.
+	ComputeProtocolV11 = "/pangea/compute/1.1.0"
+
+	// ComputeProtocolV12 carries task messages as Cap'n Proto rather than
+	// JSON (see encodeTaskRequest), framed as on ComputeProtocolV11. It is
+	// preferred over both earlier versions.
+	ComputeProtocolV12 = "/pangea/compute/1.2.0"
+
 	// Message types for compute protocol
 	MsgTypeTaskRequest  uint8 = 1
 	MsgTypeTaskResponse uint8 = 2
 	MsgTypeCapacity     uint8 = 3
+	MsgTypeReserve      uint8 = 4
+	MsgTypeRelease      uint8 = 5
+
+	// workerDeclineBackoff is how long a worker whose policy turned a task
+	// away is left out of delegation
+	workerDeclineBackoff = time.Minute
 )
 
 // ComputeProtocol handles distributed compute over libp2p
@@ -40,6 +58,10 @@ type ComputeProtocol struct {
 	ctx         context.Context
 	cancel      context.CancelFunc
 	localNodeID uint32
+	queues      *SendQueues           // Orders task sends per worker; may be nil
+	faults      *FaultInjector        // Test-only response delays; may be nil
+	declined    map[peer.ID]time.Time // Workers not accepting tasks, until when
+	wire        ComputeWireOptions    // Framing of task messages sent from ComputeProtocolV11 on
 }
 
 // WorkerInfo tracks information about a compute worker
@@ -59,6 +81,11 @@ type TaskRequest struct {
 	InputData    []byte `json:"inputData"`
 	FunctionName string `json:"functionName"`
 	TimeoutMs    uint64 `json:"timeoutMs"`
+	// DelegationDepth is how many times this task has been re-delegated
+	DelegationDepth uint32 `json:"delegationDepth"`
+	// TraceContext carries the delegating span's trace headers (W3C
+	// traceparent) so the worker's span joins the submitter's trace
+	TraceContext map[string]string `json:"traceContext,omitempty"`
 }
 
 // TaskResponse is returned by a worker after executing a task
@@ -70,6 +97,9 @@ type TaskResponse struct {
 	MerkleProof     []string `json:"merkleProof,omitempty"`
 	ExecutionTimeMs uint64   `json:"executionTimeMs"`
 	Error           string   `json:"error,omitempty"`
+
+	// Provenance lists the workers that produced a re-delegated result
+	Provenance *compute.Provenance `json:"provenance,omitempty"`
 }
 
 // NewComputeProtocol creates a new compute protocol handler
@@ -80,19 +110,49 @@ func NewComputeProtocol(h host.Host, manager *compute.Manager, nodeID uint32) *C
 		host:        h,
 		manager:     manager,
 		workers:     make(map[peer.ID]*WorkerInfo),
+		declined:    make(map[peer.ID]time.Time),
 		ctx:         ctx,
 		cancel:      cancel,
 		localNodeID: nodeID,
+		wire:        DefaultComputeWireOptions(),
 	}
 
-	// Register stream handler for compute protocol
+	// Register stream handlers for every compute protocol version
+	h.SetStreamHandler(protocol.ID(ComputeProtocolV12), cp.handleStream)
+	h.SetStreamHandler(protocol.ID(ComputeProtocolV11), cp.handleStream)
 	h.SetStreamHandler(protocol.ID(ComputeProtocolID), cp.handleStream)
 
-	log.Printf("⚙️ [COMPUTE] Protocol registered: %s", ComputeProtocolID)
+	log.Printf("⚙️ [COMPUTE] Protocol registered: %s, %s, %s", ComputeProtocolV12, ComputeProtocolV11, ComputeProtocolID)
 
 	return cp
 }
 
+// SetWireOptions sets how task messages this node sends are framed
+func (cp *ComputeProtocol) SetWireOptions(opts ComputeWireOptions) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	cp.wire = opts
+}
+
+// wireOptions returns how task messages this node sends are framed
+func (cp *ComputeProtocol) wireOptions() ComputeWireOptions {
+	cp.mu.RLock()
+	defer cp.mu.RUnlock()
+	return cp.wire
+}
+
+// framedStream reports whether a stream negotiated ComputeProtocolV11 or
+// later
+func framedStream(s network.Stream) bool {
+	p := baseProtocol(s.Protocol())
+	return p == ComputeProtocolV11 || p == ComputeProtocolV12
+}
+
+// binaryStream reports whether a stream carries Cap'n Proto task messages
+func binaryStream(s network.Stream) bool {
+	return baseProtocol(s.Protocol()) == ComputeProtocolV12
+}
+
 // handleStream handles incoming compute protocol streams
 func (cp *ComputeProtocol) handleStream(s network.Stream) {
 	defer s.Close()
@@ -112,6 +172,10 @@ func (cp *ComputeProtocol) handleStream(s network.Stream) {
 		cp.handleTaskRequest(s, remotePeer)
 	case MsgTypeCapacity:
 		cp.handleCapacityRequest(s, remotePeer)
+	case MsgTypeReserve:
+		cp.handleReserveRequest(s, remotePeer)
+	case MsgTypeRelease:
+		cp.handleReleaseRequest(s, remotePeer)
 	default:
 		log.Printf("❌ [COMPUTE] Unknown message type: %d", msgType[0])
 	}
@@ -119,24 +183,16 @@ func (cp *ComputeProtocol) handleStream(s network.Stream) {
 
 // handleTaskRequest handles an incoming compute task
 func (cp *ComputeProtocol) handleTaskRequest(s network.Stream, from peer.ID) {
-	// Read request length
-	lengthBuf := make([]byte, 4)
-	if _, err := io.ReadFull(s, lengthBuf); err != nil {
-		log.Printf("❌ [COMPUTE] Failed to read request length: %v", err)
-		return
-	}
-	length := binary.BigEndian.Uint32(lengthBuf)
-
-	// Read request data
-	reqData := make([]byte, length)
-	if _, err := io.ReadFull(s, reqData); err != nil {
-		log.Printf("❌ [COMPUTE] Failed to read request data: %v", err)
+	// Read request, checking and decompressing it on ComputeProtocolV11
+	reqData, err := readComputeMessage(s, framedStream(s))
+	if err != nil {
+		log.Printf("❌ [COMPUTE] Failed to read task request: %v", err)
 		return
 	}
 
 	// Parse request
-	var req TaskRequest
-	if err := json.Unmarshal(reqData, &req); err != nil {
+	req, err := decodeTaskRequest(reqData, binaryStream(s))
+	if err != nil {
 		log.Printf("❌ [COMPUTE] Failed to parse task request: %v", err)
 		return
 	}
@@ -144,52 +200,71 @@ func (cp *ComputeProtocol) handleTaskRequest(s network.Stream, from peer.ID) {
 	log.Printf("🔧 [COMPUTE] Received task %s (chunk %d, %d bytes) from %s",
 		req.TaskID, req.ChunkIndex, len(req.InputData), from.String()[:12])
 
-	// Execute the compute task
+	// Execute the compute task, continuing the delegator's trace
+	ctx, span := tracer.Start(extractTraceContext(cp.ctx, req.TraceContext), "compute.execute",
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("compute.task_id", req.TaskID),
+			attribute.Int("compute.input_bytes", len(req.InputData)),
+			attribute.String("peer.id", from.String()),
+		))
 	startTime := time.Now()
-	response := cp.executeTask(&req)
+	response := cp.executeTask(ctx, req, from)
 	response.ExecutionTimeMs = uint64(time.Since(startTime).Milliseconds())
+	var taskErr error
+	if !response.Success {
+		taskErr = errors.New(response.Error)
+	}
+	endSpan(span, taskErr)
 
 	log.Printf("✅ [COMPUTE] Task %s completed in %dms (result: %d bytes)",
 		req.TaskID, response.ExecutionTimeMs, len(response.ResultData))
+	cp.faults.DelayComputeResponse(cp.ctx)
 
 	// Send response
-	respData, err := json.Marshal(response)
+	respData, err := encodeTaskResponse(response, binaryStream(s))
 	if err != nil {
 		log.Printf("❌ [COMPUTE] Failed to marshal response: %v", err)
 		return
 	}
 
-	// Write response type + length + data
-	respBuf := bytes.NewBuffer(nil)
-	respBuf.WriteByte(MsgTypeTaskResponse)
-	binary.Write(respBuf, binary.BigEndian, uint32(len(respData)))
-	respBuf.Write(respData)
-
-	if _, err := s.Write(respBuf.Bytes()); err != nil {
+	if _, err := writeComputeMessage(s, MsgTypeTaskResponse, respData, framedStream(s), cp.wireOptions()); err != nil {
 		log.Printf("❌ [COMPUTE] Failed to send response: %v", err)
 	}
 }
 
-// executeTask executes a compute task locally
-func (cp *ComputeProtocol) executeTask(req *TaskRequest) *TaskResponse {
-	response := &TaskResponse{
-		TaskID:  req.TaskID,
-		Success: false,
+// executeTask executes a compute task, re-delegating part of it to other
+// peers (never back to the sender) when this node is overloaded
+func (cp *ComputeProtocol) executeTask(ctx context.Context, req *TaskRequest, from peer.ID) *TaskResponse {
+	task := &compute.ComputeTask{
+		TaskID:          req.TaskID,
+		ParentJobID:     req.ParentJobID,
+		ChunkIndex:      req.ChunkIndex,
+		InputData:       req.InputData,
+		FunctionName:    req.FunctionName,
+		DelegationDepth: req.DelegationDepth,
+		TimeoutMs:       req.TimeoutMs,
+		TraceContext:    injectTraceContext(ctx),
 	}
 
-	// Execute matrix block multiplication
-	result, err := compute.ExecuteMatrixBlockMultiply(req.InputData)
-	if err != nil {
-		response.Error = err.Error()
-		log.Printf("❌ [COMPUTE] Task execution failed: %v", err)
-		return response
+	if req.TimeoutMs > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(req.TimeoutMs)*time.Millisecond)
+		defer cancel()
 	}
 
-	// Calculate hash of result
-	hash := sha256.Sum256(result)
-	response.ResultHash = hex.EncodeToString(hash[:])
-	response.ResultData = result
-	response.Success = true
+	result := cp.manager.ExecuteTask(ctx, task, cp.host.ID().String(), from.String())
+	response := &TaskResponse{
+		TaskID:     req.TaskID,
+		Success:    result.Status == compute.TaskCompleted,
+		ResultData: result.ResultData,
+		ResultHash: result.ResultHash,
+		Error:      result.Error,
+		Provenance: result.Provenance,
+	}
+	if !response.Success {
+		log.Printf("❌ [COMPUTE] Task execution failed: %s", result.Error)
+	}
 
 	return response
 }
@@ -217,62 +292,58 @@ func (cp *ComputeProtocol) handleCapacityRequest(s network.Stream, from peer.ID)
 func (cp *ComputeProtocol) SendTask(ctx context.Context, workerPeer peer.ID, task *TaskRequest) (*TaskResponse, error) {
 	log.Printf("📤 [COMPUTE] Sending task %s to %s", task.TaskID, workerPeer.String()[:12])
 
-	// Open stream to worker
-	s, err := cp.host.NewStream(ctx, workerPeer, protocol.ID(ComputeProtocolID))
-	if err != nil {
-		return nil, fmt.Errorf("failed to open stream to %s: %w", workerPeer.String()[:12], err)
-	}
-	defer s.Close()
-
-	// Marshal task request
-	reqData, err := json.Marshal(task)
+	var result *TaskResponse
+	err := cp.queues.Do(ctx, workerPeer, sendClassCompute, func(ctx context.Context) error {
+		// Open stream to worker, on the latest version it speaks
+		s, err := cp.host.NewStream(ctx, workerPeer, protocol.ID(ComputeProtocolV12), protocol.ID(ComputeProtocolV11), protocol.ID(ComputeProtocolID))
+		if err != nil {
+			return fmt.Errorf("failed to open stream to %s: %w", workerPeer.String()[:12], err)
+		}
+		defer s.Close()
+
+		// Marshal task request
+		reqData, err := encodeTaskRequest(task, binaryStream(s))
+		if err != nil {
+			return fmt.Errorf("failed to marshal task: %w", err)
+		}
+
+		framed := framedStream(s)
+		if _, err := writeComputeMessage(s, MsgTypeTaskRequest, reqData, framed, cp.wireOptions()); err != nil {
+			return fmt.Errorf("failed to send task: %w", err)
+		}
+
+		// Read response type
+		respType := make([]byte, 1)
+		if _, err := io.ReadFull(s, respType); err != nil {
+			return fmt.Errorf("failed to read response type: %w", err)
+		}
+
+		if respType[0] != MsgTypeTaskResponse {
+			return fmt.Errorf("unexpected response type: %d", respType[0])
+		}
+
+		respData, err := readComputeMessage(s, framed)
+		if err != nil {
+			return fmt.Errorf("failed to read response: %w", err)
+		}
+
+		// Parse response
+		resp, err := decodeTaskResponse(respData, binaryStream(s))
+		if err != nil {
+			return fmt.Errorf("failed to parse response: %w", err)
+		}
+
+		log.Printf("📥 [COMPUTE] Received result for task %s (success=%t, %d bytes)",
+			task.TaskID, resp.Success, len(resp.ResultData))
+
+		result = resp
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal task: %w", err)
-	}
-
-	// Write request: type + length + data
-	reqBuf := bytes.NewBuffer(nil)
-	reqBuf.WriteByte(MsgTypeTaskRequest)
-	binary.Write(reqBuf, binary.BigEndian, uint32(len(reqData)))
-	reqBuf.Write(reqData)
-
-	if _, err := s.Write(reqBuf.Bytes()); err != nil {
-		return nil, fmt.Errorf("failed to send task: %w", err)
-	}
-
-	// Read response type
-	respType := make([]byte, 1)
-	if _, err := io.ReadFull(s, respType); err != nil {
-		return nil, fmt.Errorf("failed to read response type: %w", err)
-	}
-
-	if respType[0] != MsgTypeTaskResponse {
-		return nil, fmt.Errorf("unexpected response type: %d", respType[0])
-	}
-
-	// Read response length
-	lengthBuf := make([]byte, 4)
-	if _, err := io.ReadFull(s, lengthBuf); err != nil {
-		return nil, fmt.Errorf("failed to read response length: %w", err)
-	}
-	length := binary.BigEndian.Uint32(lengthBuf)
-
-	// Read response data
-	respData := make([]byte, length)
-	if _, err := io.ReadFull(s, respData); err != nil {
-		return nil, fmt.Errorf("failed to read response data: %w", err)
-	}
-
-	// Parse response
-	var resp TaskResponse
-	if err := json.Unmarshal(respData, &resp); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+		// result may still be written by a send abandoned when ctx ended
+		return nil, err
 	}
-
-	log.Printf("📥 [COMPUTE] Received result for task %s (success=%t, %d bytes)",
-		task.TaskID, resp.Success, len(resp.ResultData))
-
-	return &resp, nil
+	return result, nil
 }
 
 // RegisterWorker registers a peer as a compute worker
@@ -297,13 +368,32 @@ func (cp *ComputeProtocol) RegisterWorker(peerID peer.ID, capacity compute.Compu
 		peerID.String()[:12], capacity.CPUCores, capacity.RAMMB)
 }
 
+// GetWorkerCapacity returns the capacity recorded for a registered worker
+func (cp *ComputeProtocol) GetWorkerCapacity(peerID peer.ID) (compute.ComputeCapacity, bool) {
+	cp.mu.RLock()
+	defer cp.mu.RUnlock()
+	worker, exists := cp.workers[peerID]
+	if !exists {
+		return compute.ComputeCapacity{}, false
+	}
+	return worker.Capacity, true
+}
+
 // GetAvailableWorkerPeers returns a list of available compute worker peer IDs
 func (cp *ComputeProtocol) GetAvailableWorkerPeers() []peer.ID {
 	cp.mu.RLock()
 	defer cp.mu.RUnlock()
 
-	// Get all connected peers as potential workers
-	workers := cp.host.Network().Peers()
+	// Connected peers are potential workers, less those that recently
+	// turned tasks away
+	now := time.Now()
+	var workers []peer.ID
+	for _, p := range cp.host.Network().Peers() {
+		if until, ok := cp.declined[p]; ok && now.Before(until) {
+			continue
+		}
+		workers = append(workers, p)
+	}
 	return workers
 }
 
@@ -311,21 +401,39 @@ func (cp *ComputeProtocol) GetAvailableWorkerPeers() []peer.ID {
 
 // DelegateTask sends a task to a remote worker and returns the result
 // Implements compute.TaskDelegator interface
-func (cp *ComputeProtocol) DelegateTask(ctx context.Context, workerID string, task *compute.ComputeTask) (*compute.TaskResult, error) {
+func (cp *ComputeProtocol) DelegateTask(ctx context.Context, workerID string, task *compute.ComputeTask) (result *compute.TaskResult, err error) {
 	// Parse peer ID from string
 	peerID, err := peer.Decode(workerID)
 	if err != nil {
 		return nil, fmt.Errorf("invalid worker ID: %w", err)
 	}
 
+	// Continue the trace the task was issued under
+	ctx, span := tracer.Start(extractTraceContext(ctx, task.TraceContext), "compute.delegate",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("compute.task_id", task.TaskID),
+			attribute.Int("compute.input_bytes", len(task.InputData)),
+			attribute.String("peer.id", workerID),
+		))
+	defer func() {
+		if err == nil && result.Status != compute.TaskCompleted {
+			endSpan(span, errors.New(result.Error))
+			return
+		}
+		endSpan(span, err)
+	}()
+
 	// Convert compute.ComputeTask to TaskRequest
 	req := &TaskRequest{
-		TaskID:       task.TaskID,
-		ParentJobID:  task.ParentJobID,
-		ChunkIndex:   task.ChunkIndex,
-		InputData:    task.InputData,
-		FunctionName: task.FunctionName,
-		TimeoutMs:    task.TimeoutMs,
+		TaskID:          task.TaskID,
+		ParentJobID:     task.ParentJobID,
+		ChunkIndex:      task.ChunkIndex,
+		InputData:       task.InputData,
+		FunctionName:    task.FunctionName,
+		TimeoutMs:       task.TimeoutMs,
+		DelegationDepth: task.DelegationDepth,
+		TraceContext:    injectTraceContext(ctx),
 	}
 
 	// Send task and get response
@@ -335,13 +443,14 @@ func (cp *ComputeProtocol) DelegateTask(ctx context.Context, workerID string, ta
 	}
 
 	// Convert TaskResponse to compute.TaskResult
-	result := &compute.TaskResult{
+	result = &compute.TaskResult{
 		TaskID:          resp.TaskID,
 		ResultData:      resp.ResultData,
 		ResultHash:      resp.ResultHash,
 		MerkleProof:     resp.MerkleProof,
 		ExecutionTimeMs: resp.ExecutionTimeMs,
 		WorkerID:        workerID,
+		Provenance:      resp.Provenance,
 	}
 
 	if resp.Success {
@@ -349,6 +458,12 @@ func (cp *ComputeProtocol) DelegateTask(ctx context.Context, workerID string, ta
 	} else {
 		result.Status = compute.TaskFailed
 		result.Error = resp.Error
+		if strings.HasPrefix(resp.Error, compute.ErrWorkerUnavailable.Error()) {
+			cp.mu.Lock()
+			cp.declined[peerID] = time.Now().Add(workerDeclineBackoff)
+			cp.mu.Unlock()
+			log.Printf("👷 [COMPUTE] Worker %s declined task %s: %s", peerID.String()[:12], task.TaskID, resp.Error)
+		}
 	}
 
 	return result, nil
@@ -368,12 +483,13 @@ func (cp *ComputeProtocol) GetAvailableWorkers() []string {
 // HasWorkers returns true if there are remote workers available
 // Implements compute.TaskDelegator interface
 func (cp *ComputeProtocol) HasWorkers() bool {
-	peers := cp.host.Network().Peers()
-	return len(peers) > 0
+	return len(cp.GetAvailableWorkerPeers()) > 0
 }
 
 // Close shuts down the compute protocol
 func (cp *ComputeProtocol) Close() {
 	cp.cancel()
+	cp.host.RemoveStreamHandler(protocol.ID(ComputeProtocolV12))
+	cp.host.RemoveStreamHandler(protocol.ID(ComputeProtocolV11))
 	cp.host.RemoveStreamHandler(protocol.ID(ComputeProtocolID))
 }
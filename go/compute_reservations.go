@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+
+	"github.com/pangea-net/go-node/pkg/compute"
+)
+
+// ReservationRequest asks a worker to hold capacity for a time window
+type ReservationRequest struct {
+	CPUCores uint32    `json:"cpuCores"`
+	RAMMB    uint64    `json:"ramMb"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	JobID    string    `json:"jobId,omitempty"`
+}
+
+// ReservationResponse confirms a reservation or says why it was refused
+type ReservationResponse struct {
+	Reservation *compute.Reservation `json:"reservation,omitempty"`
+	Error       string               `json:"error,omitempty"`
+}
+
+// ReleaseRequest cancels a reservation held with a worker
+type ReleaseRequest struct {
+	ID string `json:"id"`
+}
+
+// ReleaseResponse acknowledges a release
+type ReleaseResponse struct {
+	Error string `json:"error,omitempty"`
+}
+
+// handleReserveRequest grants capacity to the requesting peer, which
+// becomes the reservation's holder
+func (cp *ComputeProtocol) handleReserveRequest(s network.Stream, from peer.ID) {
+	var req ReservationRequest
+	if err := readComputeFrame(s, &req); err != nil {
+		log.Printf("❌ [COMPUTE] Failed to read reservation request: %v", err)
+		return
+	}
+
+	var resp ReservationResponse
+	r, err := cp.manager.GrantReservation(compute.Reservation{
+		WorkerID: cp.host.ID().String(),
+		Holder:   from.String(),
+		JobID:    req.JobID,
+		CPUCores: req.CPUCores,
+		RAMMB:    req.RAMMB,
+		Start:    req.Start,
+		End:      req.End,
+	})
+	if err != nil {
+		resp.Error = err.Error()
+	} else {
+		resp.Reservation = &r
+	}
+	if err := writeComputeFrame(s, MsgTypeReserve, resp); err != nil {
+		log.Printf("❌ [COMPUTE] Failed to answer reservation request: %v", err)
+	}
+}
+
+// handleReleaseRequest cancels a reservation held by the requesting peer
+func (cp *ComputeProtocol) handleReleaseRequest(s network.Stream, from peer.ID) {
+	var req ReleaseRequest
+	if err := readComputeFrame(s, &req); err != nil {
+		log.Printf("❌ [COMPUTE] Failed to read release request: %v", err)
+		return
+	}
+
+	var resp ReleaseResponse
+	if err := cp.manager.ReleaseReservation(req.ID, from.String()); err != nil {
+		resp.Error = err.Error()
+	}
+	if err := writeComputeFrame(s, MsgTypeRelease, resp); err != nil {
+		log.Printf("❌ [COMPUTE] Failed to answer release request: %v", err)
+	}
+}
+
+// ReserveCapacity asks a worker to hold capacity for this node. A confirmed
+// reservation is recorded so that scheduled jobs are planned onto it.
+func (cp *ComputeProtocol) ReserveCapacity(ctx context.Context, worker peer.ID, req ReservationRequest) (compute.Reservation, error) {
+	var resp ReservationResponse
+	if err := cp.exchange(ctx, worker, MsgTypeReserve, req, &resp); err != nil {
+		return compute.Reservation{}, err
+	}
+	if resp.Error != "" {
+		return compute.Reservation{}, fmt.Errorf("worker refused reservation: %s", resp.Error)
+	}
+	if resp.Reservation == nil {
+		return compute.Reservation{}, fmt.Errorf("worker sent an empty reservation")
+	}
+
+	r := *resp.Reservation
+	r.WorkerID = worker.String() // Trust the stream, not the payload
+	cp.manager.AddHeldReservation(r)
+	log.Printf("📅 [COMPUTE] %s reserved %d cores / %dMB for us (%s)", worker.String()[:12], r.CPUCores, r.RAMMB, r.ID)
+	return r, nil
+}
+
+// ReleaseCapacity cancels a reservation this node holds with a worker
+func (cp *ComputeProtocol) ReleaseCapacity(ctx context.Context, id string) error {
+	r, exists := cp.manager.RemoveHeldReservation(id)
+	if !exists {
+		return fmt.Errorf("reservation %s not found", id)
+	}
+	worker, err := peer.Decode(r.WorkerID)
+	if err != nil {
+		return fmt.Errorf("invalid worker ID: %w", err)
+	}
+
+	var resp ReleaseResponse
+	if err := cp.exchange(ctx, worker, MsgTypeRelease, ReleaseRequest{ID: id}, &resp); err != nil {
+		cp.manager.AddHeldReservation(r)
+		return err
+	}
+	if resp.Error != "" {
+		return fmt.Errorf("worker refused release: %s", resp.Error)
+	}
+	return nil
+}
+
+// exchange sends one request frame to a worker and reads its answer of the
+// same message type
+func (cp *ComputeProtocol) exchange(ctx context.Context, worker peer.ID, msgType uint8, req, resp any) error {
+	s, err := cp.host.NewStream(ctx, worker, protocol.ID(ComputeProtocolID))
+	if err != nil {
+		return fmt.Errorf("failed to open stream to %s: %w", worker.String()[:12], err)
+	}
+	defer s.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		s.SetDeadline(deadline)
+	}
+
+	if err := writeComputeFrame(s, msgType, req); err != nil {
+		return err
+	}
+	respType := make([]byte, 1)
+	if _, err := io.ReadFull(s, respType); err != nil {
+		return fmt.Errorf("failed to read response type: %w", err)
+	}
+	if respType[0] != msgType {
+		return fmt.Errorf("unexpected response type: %d", respType[0])
+	}
+	return readComputeFrame(s, resp)
+}
+
+// writeComputeFrame writes a message as type + length + JSON
+func writeComputeFrame(w io.Writer, msgType uint8, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+	buf := bytes.NewBuffer(nil)
+	buf.WriteByte(msgType)
+	binary.Write(buf, binary.BigEndian, uint32(len(data)))
+	buf.Write(data)
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	return nil
+}
+
+// readComputeFrame reads a length-prefixed JSON message whose type byte has
+// already been consumed
+func readComputeFrame(r io.Reader, v any) error {
+	lengthBuf := make([]byte, 4)
+	if _, err := io.ReadFull(r, lengthBuf); err != nil {
+		return fmt.Errorf("failed to read message length: %w", err)
+	}
+	data := make([]byte, binary.BigEndian.Uint32(lengthBuf))
+	if _, err := io.ReadFull(r, data); err != nil {
+		return fmt.Errorf("failed to read message: %w", err)
+	}
+	return json.Unmarshal(data, v)
+}
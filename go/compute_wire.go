@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Task requests and responses from ComputeProtocolV11 on are framed as
+//
+//	flags (1) | length (4) | [CRC-32C (4)] | body
+//
+// after the message type byte. The CRC covers the uncompressed body and
+// is present when wireChecksum is set; the body is zstd-compressed when
+// wireZstd is set. Each side frames what it sends with its own
+// ComputeWireOptions and accepts any flags it receives, so the options
+// need not match across peers. On ComputeProtocolID messages are sent as
+// length and body only.
+const (
+	wireChecksum uint8 = 1 << 0
+	wireZstd     uint8 = 1 << 1
+
+	// maxComputeMessageSize bounds a task message, compressed or not, so
+	// a peer cannot make the node allocate without limit
+	maxComputeMessageSize = 512 << 20
+)
+
+var (
+	// errComputeChecksum is returned for a message whose body does not
+	// match its checksum
+	errComputeChecksum = errors.New("compute message checksum mismatch")
+
+	crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+	// Shared by all streams; EncodeAll and DecodeAll are safe for
+	// concurrent use
+	computeEncoder, _ = zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedFastest))
+	computeDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderMaxMemory(maxComputeMessageSize))
+)
+
+// ComputeWireOptions configures how this node frames the task messages it
+// sends from ComputeProtocolV11 on
+type ComputeWireOptions struct {
+	Checksums bool // Add a CRC-32C the receiver checks
+	// Compression zstd-compresses messages of at least CompressMinBytes,
+	// when that makes them smaller; large matrix chunks shrink by half or
+	// more
+	Compression      bool
+	CompressMinBytes int
+}
+
+// DefaultComputeWireOptions checksums every message and compresses those
+// of 4 KiB or more
+func DefaultComputeWireOptions() ComputeWireOptions {
+	return ComputeWireOptions{Checksums: true, Compression: true, CompressMinBytes: 4096}
+}
+
+// writeComputeMessage writes a task message: its type, then its body
+// framed for the negotiated protocol version. It returns the bytes
+// written.
+func writeComputeMessage(w io.Writer, msgType uint8, body []byte, framed bool, opts ComputeWireOptions) (int, error) {
+	if len(body) > maxComputeMessageSize {
+		return 0, fmt.Errorf("compute message of %d bytes exceeds %d", len(body), maxComputeMessageSize)
+	}
+	var header []byte
+	payload := body
+	if !framed {
+		header = binary.BigEndian.AppendUint32([]byte{msgType}, uint32(len(body)))
+	} else {
+		var flags uint8
+		if opts.Compression && len(body) >= opts.CompressMinBytes {
+			if compressed := computeEncoder.EncodeAll(body, nil); len(compressed) < len(body) {
+				payload = compressed
+				flags |= wireZstd
+			}
+		}
+		if opts.Checksums {
+			flags |= wireChecksum
+		}
+		header = binary.BigEndian.AppendUint32([]byte{msgType, flags}, uint32(len(payload)))
+		if opts.Checksums {
+			header = binary.BigEndian.AppendUint32(header, crc32.Checksum(body, crc32c))
+		}
+	}
+	// One write, so the message is not split across stream frames
+	return w.Write(append(header, payload...))
+}
+
+// readComputeMessage reads the body of a task message whose type byte was
+// already read, checking and decompressing it as its framing says
+func readComputeMessage(r io.Reader, framed bool) ([]byte, error) {
+	var flags uint8
+	if framed {
+		var b [1]byte
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return nil, fmt.Errorf("failed to read message flags: %w", err)
+		}
+		flags = b[0]
+		if flags&^(wireChecksum|wireZstd) != 0 {
+			return nil, fmt.Errorf("unknown compute message flags %#x", flags)
+		}
+	}
+	var lengthBuf [4]byte
+	if _, err := io.ReadFull(r, lengthBuf[:]); err != nil {
+		return nil, fmt.Errorf("failed to read message length: %w", err)
+	}
+	length := binary.BigEndian.Uint32(lengthBuf[:])
+	if length > maxComputeMessageSize {
+		return nil, fmt.Errorf("compute message of %d bytes exceeds %d", length, maxComputeMessageSize)
+	}
+	var sum uint32
+	if flags&wireChecksum != 0 {
+		var sumBuf [4]byte
+		if _, err := io.ReadFull(r, sumBuf[:]); err != nil {
+			return nil, fmt.Errorf("failed to read message checksum: %w", err)
+		}
+		sum = binary.BigEndian.Uint32(sumBuf[:])
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, fmt.Errorf("failed to read message data: %w", err)
+	}
+	if flags&wireZstd != 0 {
+		var err error
+		if body, err = computeDecoder.DecodeAll(body, nil); err != nil {
+			return nil, fmt.Errorf("failed to decompress message: %w", err)
+		}
+	}
+	if flags&wireChecksum != 0 && crc32.Checksum(body, crc32c) != sum {
+		return nil, errComputeChecksum
+	}
+	return body, nil
+}
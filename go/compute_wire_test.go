@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/pangea-net/go-node/pkg/compute"
+)
+
+// matrixChunkRequest is a task request carrying a 128x128 matrix block of
+// small integers, as matrix jobs send
+func matrixChunkRequest(t *testing.T) []byte {
+	t.Helper()
+	var input bytes.Buffer
+	binary.Write(&input, binary.BigEndian, uint32(128))
+	binary.Write(&input, binary.BigEndian, uint32(128))
+	for i := 0; i < 128*128; i++ {
+		binary.Write(&input, binary.BigEndian, math.Float64bits(float64(i%17)))
+	}
+	body, err := json.Marshal(TaskRequest{TaskID: "chunk", InputData: input.Bytes(), FunctionName: "matrix_block_multiply"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+func TestComputeWireFraming(t *testing.T) {
+	body := matrixChunkRequest(t)
+	opts := DefaultComputeWireOptions()
+
+	// Messages read back as sent on both protocol versions
+	for _, framed := range []bool{false, true} {
+		var buf bytes.Buffer
+		if _, err := writeComputeMessage(&buf, MsgTypeTaskRequest, body, framed, opts); err != nil {
+			t.Fatal(err)
+		}
+		if msgType, _ := buf.ReadByte(); msgType != MsgTypeTaskRequest {
+			t.Fatalf("message type %d, want %d", msgType, MsgTypeTaskRequest)
+		}
+		got, err := readComputeMessage(&buf, framed)
+		if err != nil || !bytes.Equal(got, body) {
+			t.Fatalf("framed=%v: read %d bytes back (%v)", framed, len(got), err)
+		}
+	}
+
+	// A large matrix chunk goes out at under half its size
+	var buf bytes.Buffer
+	n, err := writeComputeMessage(&buf, MsgTypeTaskRequest, body, true, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n*2 > len(body) {
+		t.Fatalf("%d byte chunk sent as %d bytes", len(body), n)
+	}
+
+	// A corrupted body fails its checksum
+	opts.Compression = false
+	buf.Reset()
+	writeComputeMessage(&buf, MsgTypeTaskRequest, body, true, opts)
+	wire := buf.Bytes()
+	wire[len(wire)-10] ^= 0x01
+	if _, err := readComputeMessage(bytes.NewReader(wire[1:]), true); !errors.Is(err, errComputeChecksum) {
+		t.Fatalf("expected a checksum mismatch, got %v", err)
+	}
+
+	// Without checksums or compression only the flags byte is added
+	buf.Reset()
+	if n, _ := writeComputeMessage(&buf, MsgTypeTaskRequest, []byte("tiny"), true, ComputeWireOptions{}); n != 1+1+4+4 {
+		t.Fatalf("unexpected %d byte frame", n)
+	}
+	if _, err := readComputeMessage(bytes.NewReader([]byte{0x80, 0, 0, 0, 0}), true); err == nil {
+		t.Fatal("expected unknown flags to be rejected")
+	}
+}
+
+func TestComputeProtocolVersionNegotiation(t *testing.T) {
+	var nodes []*LibP2PPangeaNode
+	var protocols []*ComputeProtocol
+	for i := 0; i < 4; i++ {
+		n, err := NewLibP2PPangeaNodeWithOptions(uint32(684+i), NewNodeStore(), false, true, 0)
+		if err != nil {
+			t.Fatalf("failed to create node %d: %v", i, err)
+		}
+		defer n.cancel()
+		manager := compute.NewManager(compute.DefaultConfig())
+		defer manager.Close()
+		cp := NewComputeProtocol(n.GetHost(), manager, n.nodeID)
+		defer cp.Close()
+		nodes = append(nodes, n)
+		protocols = append(protocols, cp)
+	}
+	// The third node predates 1.2, the last one 1.1
+	nodes[2].host.RemoveStreamHandler(protocol.ID(ComputeProtocolV12))
+	nodes[3].host.RemoveStreamHandler(protocol.ID(ComputeProtocolV12))
+	nodes[3].host.RemoveStreamHandler(protocol.ID(ComputeProtocolV11))
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	for _, worker := range nodes[1:] {
+		if err := nodes[0].host.Connect(ctx, peer.AddrInfo{ID: worker.host.ID(), Addrs: worker.host.Addrs()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	input := matrixInput(6, 7)
+	expected, err := compute.ExecuteMatrixBlockMultiply(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	delegate := func(worker *LibP2PPangeaNode) {
+		t.Helper()
+		task := &compute.ComputeTask{TaskID: "negotiated", InputData: input, FunctionName: "matrix_block_multiply", TimeoutMs: 10000}
+		result, err := protocols[0].DelegateTask(ctx, worker.host.ID().String(), task)
+		if err != nil || result.Status != compute.TaskCompleted || !bytes.Equal(result.ResultData, expected) {
+			t.Fatalf("delegation failed: %v %+v", err, result)
+		}
+	}
+
+	// Both nodes speak 1.2, with compression of every message
+	protocols[0].SetWireOptions(ComputeWireOptions{Checksums: true, Compression: true})
+	delegate(nodes[1])
+
+	// Workers that predate 1.2 are still reached over JSON
+	delegate(nodes[2])
+	delegate(nodes[3])
+}
@@ -18,6 +18,8 @@ type NodeConfig struct {
 	UseLibP2P      bool              `json:"use_libp2p"`
 	LocalMode      bool              `json:"local_mode"`
 	BootstrapPeers []string          `json:"bootstrap_peers"`
+	LegacyAddr     string            `json:"legacy_addr,omitempty"`     // Legacy P2P listener beside libp2p, empty = off
+	PrimaryBackend string            `json:"primary_backend,omitempty"` // Backend for peers not connected on either
 	LastSavedAt    string            `json:"last_saved_at"`
 	CustomSettings map[string]string `json:"custom_settings,omitempty"`
 }
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// defaultPackThreshold is the file size below which directory uploads
+	// pack files together instead of giving each its own blob
+	defaultPackThreshold = 64 << 10
+
+	// maxPackBytes caps the size of a blob of packed small files
+	maxPackBytes = 4 << 20
+)
+
+// dirTree is the manifest of an uploaded directory. Entries list the tree
+// in pre-order, children by name, starting with the root directory. Like a
+// UnixFS DAG, each directory's hash covers its children's names, kinds,
+// sizes and hashes, so the root hash names the whole tree and any entry
+// can be checked against it.
+type dirTree struct {
+	RootHash string
+	Entries  []dirEntry
+	Blobs    []dirBlob
+}
+
+// dirEntry is a file or directory in a dirTree
+type dirEntry struct {
+	Path   string // Slash-separated, relative to the root; "" for the root
+	Dir    bool
+	Size   uint64 // Content bytes; for directories, of all files below
+	Hash   string // SHA-256 of the content, or the directory hash
+	Blob   int    // Index into Blobs; -1 for directories and empty files
+	Offset uint64 // Position of the content in the blob
+}
+
+// dirBlob is one CES-stored blob holding a file, or several small files
+// packed back to back
+type dirBlob struct {
+	Hash       string // SHA-256 of the blob, also its CES file hash
+	Size       uint64
+	Packed     bool
+	Placements []shardPlacement // Set once uploaded
+
+	parts []blobPart // Files the blob is read from when uploading
+}
+
+// blobPart is a file's place in a blob being uploaded
+type blobPart struct {
+	path string
+	size uint64
+	hash string
+}
+
+// planDirTree walks a local directory and lays its files out in blobs.
+// Files of at least packThreshold bytes get a blob of their own; smaller
+// ones are packed together. Files with identical content share one copy.
+// Symlinks and special files are skipped.
+func planDirTree(root string, packThreshold uint64) (*dirTree, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", root)
+	}
+	if packThreshold == 0 {
+		packThreshold = defaultPackThreshold
+	}
+
+	type location struct {
+		blob   int
+		offset uint64
+	}
+	tree := &dirTree{}
+	stored := make(map[string]location) // Content hash -> where it went
+	pack := -1                          // Blob taking small files
+	var packHash hash.Hash
+	closePack := func() {
+		if pack >= 0 {
+			tree.Blobs[pack].Hash = hex.EncodeToString(packHash.Sum(nil))
+			pack = -1
+		}
+	}
+
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == "." {
+			rel = ""
+		}
+		if d.IsDir() {
+			tree.Entries = append(tree.Entries, dirEntry{Path: rel, Dir: true, Blob: -1})
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			log.Printf("⚠️  Skipping %s: not a regular file", p)
+			return nil
+		}
+
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(data)
+		entry := dirEntry{Path: rel, Size: uint64(len(data)), Hash: hex.EncodeToString(sum[:]), Blob: -1}
+		part := blobPart{path: p, size: entry.Size, hash: entry.Hash}
+
+		switch loc, dup := stored[entry.Hash]; {
+		case entry.Size == 0:
+		case dup:
+			entry.Blob, entry.Offset = loc.blob, loc.offset
+		case entry.Size >= packThreshold:
+			entry.Blob = len(tree.Blobs)
+			tree.Blobs = append(tree.Blobs, dirBlob{Hash: entry.Hash, Size: entry.Size, parts: []blobPart{part}})
+		default:
+			if pack >= 0 && tree.Blobs[pack].Size+entry.Size > maxPackBytes {
+				closePack()
+			}
+			if pack < 0 {
+				pack = len(tree.Blobs)
+				packHash = sha256.New()
+				tree.Blobs = append(tree.Blobs, dirBlob{Packed: true})
+			}
+			entry.Blob, entry.Offset = pack, tree.Blobs[pack].Size
+			tree.Blobs[pack].Size += entry.Size
+			tree.Blobs[pack].parts = append(tree.Blobs[pack].parts, part)
+			packHash.Write(data)
+		}
+		if entry.Blob >= 0 {
+			stored[entry.Hash] = location{entry.Blob, entry.Offset}
+		}
+		tree.Entries = append(tree.Entries, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	closePack()
+
+	tree.RootHash = hashDirEntries(tree.Entries)
+	return tree, nil
+}
+
+// hashDirEntries fills in the sizes and hashes of the directory entries
+// from the files below them and returns the root hash. Entries must be in
+// pre-order with children by name.
+func hashDirEntries(entries []dirEntry) string {
+	if len(entries) == 0 {
+		return ""
+	}
+	children := make(map[string][]int)
+	for i := 1; i < len(entries); i++ {
+		parent := parentPath(entries[i].Path)
+		children[parent] = append(children[parent], i)
+	}
+	// Walking backwards, a directory's children are all settled by the
+	// time it is reached
+	for i := len(entries) - 1; i >= 0; i-- {
+		if !entries[i].Dir {
+			continue
+		}
+		var listing bytes.Buffer
+		var size uint64
+		for _, c := range children[entries[i].Path] {
+			child := entries[c]
+			kind := "f"
+			if child.Dir {
+				kind = "d"
+			}
+			fmt.Fprintf(&listing, "%s %s %d %s\n", kind, child.Hash, child.Size, path.Base(child.Path))
+			size += child.Size
+		}
+		sum := sha256.Sum256(listing.Bytes())
+		entries[i].Hash = hex.EncodeToString(sum[:])
+		entries[i].Size = size
+	}
+	return entries[0].Hash
+}
+
+// parentPath is the path of an entry's directory, "" for the root's
+// children
+func parentPath(p string) string {
+	if i := strings.LastIndexByte(p, '/'); i >= 0 {
+		return p[:i]
+	}
+	return ""
+}
+
+// verify checks that the entries hash to the root hash and that every
+// file lies within its blob
+func (t *dirTree) verify() error {
+	if len(t.Entries) == 0 || t.Entries[0].Path != "" || !t.Entries[0].Dir {
+		return fmt.Errorf("tree manifest has no root directory")
+	}
+	seen := make(map[string]bool)
+	for _, e := range t.Entries {
+		if seen[e.Path] {
+			return fmt.Errorf("tree manifest lists %q twice", e.Path)
+		}
+		seen[e.Path] = true
+	}
+	entries := make([]dirEntry, len(t.Entries))
+	copy(entries, t.Entries)
+	if root := hashDirEntries(entries); root != t.RootHash {
+		return fmt.Errorf("tree manifest does not match root hash %s", t.RootHash)
+	}
+	for _, e := range t.Entries {
+		if e.Dir || e.Size == 0 {
+			continue
+		}
+		if e.Blob < 0 || e.Blob >= len(t.Blobs) || !withinBlob(e, t.Blobs[e.Blob].Size) {
+			return fmt.Errorf("file %q lies outside its blob", e.Path)
+		}
+	}
+	return nil
+}
+
+// find returns the entry at a path; leading and trailing slashes are
+// ignored
+func (t *dirTree) find(p string) (dirEntry, bool) {
+	p = strings.Trim(path.Clean("/"+p), "/")
+	for _, e := range t.Entries {
+		if e.Path == p {
+			return e, true
+		}
+	}
+	return dirEntry{}, false
+}
+
+// readBlob reads a blob's files back from disk for uploading, failing if
+// any changed since the tree was planned
+func readBlob(blob dirBlob) ([]byte, error) {
+	data := make([]byte, 0, blob.Size)
+	for _, part := range blob.parts {
+		content, err := os.ReadFile(part.path)
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(content)
+		if uint64(len(content)) != part.size || hex.EncodeToString(sum[:]) != part.hash {
+			return nil, fmt.Errorf("%s changed during upload", part.path)
+		}
+		data = append(data, content...)
+	}
+	return data, nil
+}
+
+// extractFile cuts a file out of its downloaded blob and checks its hash
+func extractFile(e dirEntry, blob []byte) ([]byte, error) {
+	if !withinBlob(e, uint64(len(blob))) {
+		return nil, fmt.Errorf("blob too short for %q", e.Path)
+	}
+	data := blob[e.Offset : e.Offset+e.Size]
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != e.Hash {
+		return nil, fmt.Errorf("content of %q does not match its hash", e.Path)
+	}
+	return data, nil
+}
+
+// withinBlob reports whether a file's content fits in a blob of blobSize
+// bytes, guarding against overflow from a forged manifest
+func withinBlob(e dirEntry, blobSize uint64) bool {
+	return e.Size <= blobSize && e.Offset <= blobSize-e.Size
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTestTree(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	files := map[string][]byte{
+		"big.bin":         bytes.Repeat([]byte("x"), 4096),
+		"a.txt":           []byte("alpha"),
+		"docs/b.txt":      []byte("bravo"),
+		"docs/copy.txt":   []byte("alpha"),
+		"docs/deep/c.txt": []byte("charlie"),
+		"empty":           nil,
+	}
+	for name, data := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestPlanDirTreePacksAndDedups(t *testing.T) {
+	root := writeTestTree(t)
+	tree, err := planDirTree(root, 1024)
+	if err != nil {
+		t.Fatalf("planDirTree: %v", err)
+	}
+	if err := tree.verify(); err != nil {
+		t.Fatalf("fresh tree failed to verify: %v", err)
+	}
+
+	// big.bin gets its own blob; the small files share one, with the
+	// duplicate stored once
+	if len(tree.Blobs) != 2 {
+		t.Fatalf("expected 2 blobs, got %d", len(tree.Blobs))
+	}
+	if root := tree.Entries[0]; root.Path != "" || !root.Dir || root.Size != 4096+5+5+5+7 {
+		t.Fatalf("unexpected root entry %+v", root)
+	}
+	a, _ := tree.find("a.txt")
+	dup, _ := tree.find("/docs/copy.txt/")
+	if a.Blob != dup.Blob || a.Offset != dup.Offset {
+		t.Fatalf("duplicate not shared: %+v vs %+v", a, dup)
+	}
+	if empty, ok := tree.find("empty"); !ok || empty.Blob != -1 {
+		t.Fatalf("expected empty file without a blob, got %+v", empty)
+	}
+
+	blobs := make([][]byte, len(tree.Blobs))
+	for i, b := range tree.Blobs {
+		if blobs[i], err = readBlob(b); err != nil {
+			t.Fatalf("readBlob %d: %v", i, err)
+		}
+		if uint64(len(blobs[i])) != b.Size {
+			t.Fatalf("blob %d is %d bytes, planned %d", i, len(blobs[i]), b.Size)
+		}
+	}
+	for _, name := range []string{"big.bin", "docs/b.txt", "docs/copy.txt", "docs/deep/c.txt"} {
+		e, ok := tree.find(name)
+		if !ok {
+			t.Fatalf("%s missing from tree", name)
+		}
+		data, err := extractFile(e, blobs[e.Blob])
+		if err != nil {
+			t.Fatalf("extractFile %s: %v", name, err)
+		}
+		want, _ := os.ReadFile(filepath.Join(root, filepath.FromSlash(name)))
+		if !bytes.Equal(data, want) {
+			t.Fatalf("%s round trip mismatch", name)
+		}
+	}
+}
+
+func TestDirTreeVerifyRejectsTampering(t *testing.T) {
+	root := writeTestTree(t)
+	tree, err := planDirTree(root, 1024)
+	if err != nil {
+		t.Fatalf("planDirTree: %v", err)
+	}
+
+	forged := *tree
+	forged.Entries = append([]dirEntry(nil), tree.Entries...)
+	for i, e := range forged.Entries {
+		if e.Path == "docs/b.txt" {
+			forged.Entries[i].Hash = strings.Repeat("0", len(e.Hash))
+		}
+	}
+	if err := forged.verify(); err == nil {
+		t.Fatal("expected a changed file hash to fail verification")
+	}
+
+	forged = *tree
+	forged.RootHash = "00"
+	if err := forged.verify(); err == nil {
+		t.Fatal("expected a wrong root hash to fail verification")
+	}
+
+	// A file changed after planning must not be uploaded
+	if err := os.WriteFile(filepath.Join(root, "big.bin"), []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	big, _ := tree.find("big.bin")
+	if _, err := readBlob(tree.Blobs[big.Blob]); err == nil {
+		t.Fatal("expected readBlob to notice the changed file")
+	}
+}
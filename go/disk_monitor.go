@@ -0,0 +1,321 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// QuotaExceededCode is the error code returned when a shard store is
+// rejected because the node is out of storage quota
+const QuotaExceededCode = "QUOTA_EXCEEDED"
+
+// ErrQuotaExceeded matches any QuotaError, including rejections reported by peers
+var ErrQuotaExceeded = errors.New(QuotaExceededCode)
+
+const (
+	// DefaultDiskCheckInterval is how often the data directory is rescanned
+	DefaultDiskCheckInterval = 30 * time.Second
+	// DefaultDiskWarnRatio is the usage fraction that raises a nearly-full alert
+	DefaultDiskWarnRatio = 0.80
+	// DefaultDiskReadOnlyRatio is the usage fraction that makes storage read-only
+	DefaultDiskReadOnlyRatio = 0.95
+	// DefaultDiskResumeRatio is the usage fraction below which writes resume
+	DefaultDiskResumeRatio = 0.90
+)
+
+// StorageRole describes whether this node accepts new shards
+type StorageRole int
+
+const (
+	StorageReadWrite StorageRole = iota
+	StorageReadOnly
+)
+
+// String returns the role name used in status reports
+func (r StorageRole) String() string {
+	switch r {
+	case StorageReadWrite:
+		return "read_write"
+	case StorageReadOnly:
+		return "read_only"
+	default:
+		return "unknown"
+	}
+}
+
+// QuotaError is returned when storing data would exceed the storage quota
+type QuotaError struct {
+	Code      string
+	Requested uint64
+	Used      uint64
+	Quota     uint64
+	Role      StorageRole
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("%s: storing %d bytes with %d/%d bytes used (role %s)",
+		e.Code, e.Requested, e.Used, e.Quota, e.Role)
+}
+
+// Unwrap allows errors.Is(err, ErrQuotaExceeded)
+func (e *QuotaError) Unwrap() error {
+	return ErrQuotaExceeded
+}
+
+// DiskEventKind identifies a disk monitor alert
+type DiskEventKind string
+
+const (
+	DiskEventNearlyFull DiskEventKind = "nearly_full"
+	DiskEventReadOnly   DiskEventKind = "read_only"
+	DiskEventReadWrite  DiskEventKind = "read_write"
+)
+
+// DiskEvent is emitted when storage usage crosses a threshold
+type DiskEvent struct {
+	Kind       DiskEventKind
+	Role       StorageRole
+	UsedBytes  uint64
+	QuotaBytes uint64
+	Time       time.Time
+}
+
+// DiskMonitorConfig configures quota enforcement for the data directory
+type DiskMonitorConfig struct {
+	DataDir       string
+	QuotaBytes    uint64 // 0 = unlimited
+	WarnRatio     float64
+	ReadOnlyRatio float64
+	ResumeRatio   float64
+	CheckInterval time.Duration
+}
+
+// DefaultDiskMonitorConfig returns the default thresholds with no quota
+func DefaultDiskMonitorConfig() DiskMonitorConfig {
+	return DiskMonitorConfig{
+		WarnRatio:     DefaultDiskWarnRatio,
+		ReadOnlyRatio: DefaultDiskReadOnlyRatio,
+		ResumeRatio:   DefaultDiskResumeRatio,
+		CheckInterval: DefaultDiskCheckInterval,
+	}
+}
+
+// DiskMonitor tracks storage usage against the configured quota and moves
+// the node's storage role to read-only when it is nearly full
+type DiskMonitor struct {
+	config DiskMonitorConfig
+
+	dirBytes     uint64 // Last scanned size of the data directory
+	trackedBytes uint64 // Bytes held outside the data directory (in-memory shards)
+	role         StorageRole
+	warned       bool
+	listeners    []func(DiskEvent)
+	mu           sync.RWMutex
+}
+
+// NewDiskMonitor creates a monitor and performs an initial scan
+func NewDiskMonitor(config DiskMonitorConfig) *DiskMonitor {
+	defaults := DefaultDiskMonitorConfig()
+	if config.WarnRatio <= 0 {
+		config.WarnRatio = defaults.WarnRatio
+	}
+	if config.ReadOnlyRatio <= 0 {
+		config.ReadOnlyRatio = defaults.ReadOnlyRatio
+	}
+	if config.ResumeRatio <= 0 || config.ResumeRatio > config.ReadOnlyRatio {
+		config.ResumeRatio = config.ReadOnlyRatio
+	}
+	if config.CheckInterval <= 0 {
+		config.CheckInterval = defaults.CheckInterval
+	}
+
+	dm := &DiskMonitor{config: config}
+	if err := dm.Refresh(); err != nil {
+		log.Printf("⚠️  [DISK] Initial scan of %s failed: %v", config.DataDir, err)
+	}
+	return dm
+}
+
+// OnEvent registers a listener for threshold alerts
+func (dm *DiskMonitor) OnEvent(fn func(DiskEvent)) {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+	dm.listeners = append(dm.listeners, fn)
+}
+
+// Run rescans the data directory every CheckInterval until ctx is cancelled
+func (dm *DiskMonitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(dm.config.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := dm.Refresh(); err != nil {
+				log.Printf("⚠️  [DISK] Scan of %s failed: %v", dm.config.DataDir, err)
+			}
+		}
+	}
+}
+
+// Refresh rescans the data directory and re-evaluates the storage role
+func (dm *DiskMonitor) Refresh() error {
+	var size uint64
+	if dm.config.DataDir != "" {
+		err := filepath.WalkDir(dm.config.DataDir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.Type().IsRegular() {
+				info, err := d.Info()
+				if err != nil {
+					return err
+				}
+				size += uint64(info.Size())
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	dm.mu.Lock()
+	dm.dirBytes = size
+	events := dm.evaluate()
+	dm.mu.Unlock()
+
+	dm.emit(events)
+	return nil
+}
+
+// Reserve accounts for n new bytes, failing with a QUOTA_EXCEEDED error if
+// storage is read-only or the bytes would not fit in the quota
+func (dm *DiskMonitor) Reserve(n uint64) error {
+	events, err := dm.reserve(n)
+	if err != nil {
+		return err
+	}
+	dm.emit(events)
+	return nil
+}
+
+// Release returns n previously reserved bytes
+func (dm *DiskMonitor) Release(n uint64) {
+	dm.emit(dm.release(n))
+}
+
+// reserve is Reserve without notifying listeners, for callers that must
+// emit the returned events after dropping their own locks
+func (dm *DiskMonitor) reserve(n uint64) ([]DiskEvent, error) {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+	used := dm.dirBytes + dm.trackedBytes
+	if dm.config.QuotaBytes > 0 && (dm.role == StorageReadOnly || used+n > dm.config.QuotaBytes) {
+		return nil, &QuotaError{
+			Code:      QuotaExceededCode,
+			Requested: n,
+			Used:      used,
+			Quota:     dm.config.QuotaBytes,
+			Role:      dm.role,
+		}
+	}
+	dm.trackedBytes += n
+	return dm.evaluate(), nil
+}
+
+// release is Release without notifying listeners
+func (dm *DiskMonitor) release(n uint64) []DiskEvent {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+	if n > dm.trackedBytes {
+		n = dm.trackedBytes
+	}
+	dm.trackedBytes -= n
+	return dm.evaluate()
+}
+
+// Usage returns the bytes in use and the configured quota (0 = unlimited)
+func (dm *DiskMonitor) Usage() (used, quota uint64) {
+	dm.mu.RLock()
+	defer dm.mu.RUnlock()
+	return dm.dirBytes + dm.trackedBytes, dm.config.QuotaBytes
+}
+
+// Role returns the current storage role
+func (dm *DiskMonitor) Role() StorageRole {
+	dm.mu.RLock()
+	defer dm.mu.RUnlock()
+	return dm.role
+}
+
+// evaluate applies the thresholds and returns any events to emit.
+// Caller must hold dm.mu.
+func (dm *DiskMonitor) evaluate() []DiskEvent {
+	quota := dm.config.QuotaBytes
+	if quota == 0 {
+		return nil
+	}
+	used := dm.dirBytes + dm.trackedBytes
+	ratio := float64(used) / float64(quota)
+
+	var events []DiskEvent
+	event := func(kind DiskEventKind) {
+		events = append(events, DiskEvent{
+			Kind:       kind,
+			Role:       dm.role,
+			UsedBytes:  used,
+			QuotaBytes: quota,
+			Time:       time.Now(),
+		})
+	}
+
+	if ratio >= dm.config.WarnRatio && !dm.warned {
+		dm.warned = true
+		event(DiskEventNearlyFull)
+	} else if ratio < dm.config.WarnRatio {
+		dm.warned = false
+	}
+
+	switch {
+	case dm.role == StorageReadWrite && ratio >= dm.config.ReadOnlyRatio:
+		dm.role = StorageReadOnly
+		event(DiskEventReadOnly)
+	case dm.role == StorageReadOnly && ratio < dm.config.ResumeRatio:
+		dm.role = StorageReadWrite
+		event(DiskEventReadWrite)
+	}
+	return events
+}
+
+// emit logs events and notifies listeners outside the lock
+func (dm *DiskMonitor) emit(events []DiskEvent) {
+	if len(events) == 0 {
+		return
+	}
+	dm.mu.RLock()
+	listeners := append([]func(DiskEvent){}, dm.listeners...)
+	dm.mu.RUnlock()
+
+	for _, ev := range events {
+		switch ev.Kind {
+		case DiskEventNearlyFull:
+			log.Printf("⚠️  [DISK] Storage nearly full: %d/%d bytes used", ev.UsedBytes, ev.QuotaBytes)
+		case DiskEventReadOnly:
+			log.Printf("🚫 [DISK] Storage quota reached (%d/%d bytes), switching to read-only", ev.UsedBytes, ev.QuotaBytes)
+		case DiskEventReadWrite:
+			log.Printf("✅ [DISK] Storage usage back to %d/%d bytes, accepting shards again", ev.UsedBytes, ev.QuotaBytes)
+		}
+		for _, fn := range listeners {
+			fn(ev)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestDiskMonitorReadOnlyTransition(t *testing.T) {
+	config := DefaultDiskMonitorConfig()
+	config.QuotaBytes = 1000
+	dm := NewDiskMonitor(config)
+
+	var events []DiskEventKind
+	dm.OnEvent(func(ev DiskEvent) { events = append(events, ev.Kind) })
+
+	if err := dm.Reserve(850); err != nil {
+		t.Fatalf("Reserve below quota failed: %v", err)
+	}
+	if dm.Role() != StorageReadWrite {
+		t.Fatalf("expected read-write at 85%%, got %s", dm.Role())
+	}
+
+	if err := dm.Reserve(100); err != nil {
+		t.Fatalf("Reserve up to read-only threshold failed: %v", err)
+	}
+	if dm.Role() != StorageReadOnly {
+		t.Fatalf("expected read-only at 95%%, got %s", dm.Role())
+	}
+
+	err := dm.Reserve(1)
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("expected QUOTA_EXCEEDED while read-only, got %v", err)
+	}
+	var quotaErr *QuotaError
+	if !errors.As(err, &quotaErr) || quotaErr.Code != QuotaExceededCode {
+		t.Errorf("expected QuotaError with code %s, got %v", QuotaExceededCode, err)
+	}
+
+	// Dropping just below the read-only threshold keeps the node read-only
+	dm.Release(20)
+	if dm.Role() != StorageReadOnly {
+		t.Error("expected read-only to persist until usage falls below resume threshold")
+	}
+	dm.Release(100)
+	if dm.Role() != StorageReadWrite {
+		t.Errorf("expected read-write after usage dropped, got %s", dm.Role())
+	}
+
+	expected := []DiskEventKind{DiskEventNearlyFull, DiskEventReadOnly, DiskEventReadWrite}
+	if len(events) != len(expected) {
+		t.Fatalf("expected events %v, got %v", expected, events)
+	}
+	for i := range expected {
+		if events[i] != expected[i] {
+			t.Errorf("event %d: expected %s, got %s", i, expected[i], events[i])
+		}
+	}
+}
+
+func TestDiskMonitorCountsDataDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "blob"), make([]byte, 600), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	config := DefaultDiskMonitorConfig()
+	config.DataDir = dir
+	config.QuotaBytes = 1000
+	dm := NewDiskMonitor(config)
+
+	if used, _ := dm.Usage(); used != 600 {
+		t.Errorf("expected 600 bytes used, got %d", used)
+	}
+	if err := dm.Reserve(500); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("expected QUOTA_EXCEEDED for store past quota, got %v", err)
+	}
+}
+
+func TestSendShardRejectedWhenQuotaExceeded(t *testing.T) {
+	store1 := NewNodeStore()
+	n1, err := NewLibP2PPangeaNodeWithOptions(441, store1, false, true, 12440)
+	if err != nil {
+		t.Fatalf("failed to create node1: %v", err)
+	}
+	defer n1.cancel()
+
+	store2 := NewNodeStore()
+	n2, err := NewLibP2PPangeaNodeWithOptions(442, store2, false, true, 12441)
+	if err != nil {
+		t.Fatalf("failed to create node2: %v", err)
+	}
+	defer n2.cancel()
+
+	config := DefaultDiskMonitorConfig()
+	config.QuotaBytes = 1024
+	n2.ConfigureDiskMonitor(config)
+
+	lib1 := NewLibP2PAdapter(n1, store1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := n1.host.Connect(ctx, peer.AddrInfo{ID: n2.host.ID(), Addrs: n2.host.Addrs()}); err != nil {
+		t.Fatalf("connect n1->n2 failed: %v", err)
+	}
+
+	lib1.peerIDToUint32[n2.host.ID().String()] = 2
+	lib1.uint32ToPeerID[2] = n2.host.ID().String()
+
+	err = lib1.SendShard(2, "quota-test", 0, make([]byte, 4096))
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("expected QUOTA_EXCEEDED rejection, got %v", err)
+	}
+	if _, ok := n2.FetchLocalShard("quota-test", 0); ok {
+		t.Error("rejected shard should not be stored")
+	}
+}
+
+func TestReadOnlyRoleShownInNodeStatus(t *testing.T) {
+	store := NewNodeStore()
+	store.CreateNode(443)
+	n, err := NewLibP2PPangeaNodeWithOptions(443, store, false, true, 12442)
+	if err != nil {
+		t.Fatalf("failed to create node: %v", err)
+	}
+	defer n.cancel()
+
+	config := DefaultDiskMonitorConfig()
+	config.QuotaBytes = 1000
+	dm := n.ConfigureDiskMonitor(config)
+
+	// Listeners run outside shardMu, so calling back into the node must not deadlock
+	events := make(chan DiskEvent, 4)
+	dm.OnEvent(func(ev DiskEvent) {
+		_ = n.GetDiskMonitor()
+		events <- ev
+	})
+
+	done := make(chan error, 1)
+	go func() { done <- n.StoreShard("role-test", 0, make([]byte, 960)) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("StoreShard failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("StoreShard deadlocked while notifying listeners")
+	}
+	if len(events) == 0 {
+		t.Fatal("expected disk events from the store")
+	}
+
+	local, _ := store.GetNode(443)
+	if local.StorageRole != StorageReadOnly {
+		t.Fatalf("node status role = %s, want read_only", local.StorageRole)
+	}
+
+	if err := n.StoreShard("role-test", 0, nil); err != nil {
+		t.Fatalf("StoreShard failed: %v", err)
+	}
+	if local.StorageRole != StorageReadWrite {
+		t.Fatalf("node status role = %s, want read_write", local.StorageRole)
+	}
+}
@@ -0,0 +1,711 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	kyberdkg "github.com/pangea-net/go-node/pkg/crypto/dkg/kyber"
+	"go.dedis.ch/kyber/v3"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Networked Feldman DKG. A coordinator drives the rounds over
+// PangeaRPCProtocol, whose streams are authenticated by libp2p, while
+// dealers send each member's share to that member directly:
+//
+//  1. init: every member commits to a random polynomial. Members that do
+//     not answer are dropped and the round starts over without them.
+//  2. commits: the coordinator relays all commitments and each member
+//     deals its shares to the others.
+//  3. verify: members check their shares against the commitments and
+//     complain about dealers whose share is missing or wrong.
+//  4. reveal: each accused dealer must reveal the disputed share to the
+//     coordinator; a dealer that cannot, or reveals a bad one, is
+//     disqualified.
+//  5. finish: members derive their key share from the qualified dealers,
+//     persist it and report the group key, which must agree.
+
+// DKG steps carried in rpcMsgDKG frames
+const (
+	dkgStepInit    byte = 1 // [threshold(4)][count(4)][peerID]... -> [count(4)][commitment]...
+	dkgStepCommits byte = 2 // [count(4)] then per dealer [count(4)][commitment]... -> empty, once dealt
+	dkgStepShare   byte = 3 // [dealer(4)][share] -> empty
+	dkgStepVerify  byte = 4 // empty -> [count(4)][dealer(4)]... complained about
+	dkgStepReveal  byte = 5 // [accuser(4)] -> [share]
+	dkgStepFinish  byte = 6 // [count(4)][dealer(4)]... qualified, [count(4)][dealer(4)][share]... revealed -> [groupKey]
+)
+
+const (
+	// dkgStepTimeout bounds one step with one member
+	dkgStepTimeout = 15 * time.Second
+
+	// dkgDealTimeout bounds dealing one share, within a commits step
+	dkgDealTimeout = 5 * time.Second
+
+	// dkgSessionTTL is how long a member keeps an unfinished session
+	dkgSessionTTL = 5 * time.Minute
+
+	// maxDKGAttempts bounds restarts after members fail to commit
+	maxDKGAttempts = 3
+
+	// maxDKGParticipants caps the members of one group
+	maxDKGParticipants = 64
+)
+
+// DKGKey is this node's share of a group key
+type DKGKey struct {
+	GroupID      string
+	Participants []peer.ID // Index i+1 is Participants[i]
+	Threshold    int
+	Share        *kyberdkg.SigningShare
+}
+
+// dkgSession is a member's state while a group key is generated
+type dkgSession struct {
+	id           string
+	groupID      string
+	coordinator  peer.ID
+	participants []peer.ID
+	threshold    int
+	self         int
+	state        *kyberdkg.DKGState
+	commits      map[int]*kyberdkg.Commit
+	dealt        map[int]*kyberdkg.Share // Shares this member dealt, by recipient
+	received     map[int]*kyberdkg.Share // Shares dealt to this member, by dealer
+	started      time.Time
+}
+
+// NetworkDKG runs distributed key generation with other nodes and keeps
+// the resulting key shares, on disk when a key directory is set
+type NetworkDKG struct {
+	host     host.Host
+	keyDir   string
+	sessions map[string]*dkgSession // By group
+	keys     map[string]*DKGKey
+	mu       sync.Mutex
+}
+
+// NewNetworkDKG creates the service. Key shares are kept in memory until
+// SetKeyDir is called.
+func NewNetworkDKG(h host.Host) *NetworkDKG {
+	return &NetworkDKG{
+		host:     h,
+		sessions: make(map[string]*dkgSession),
+		keys:     make(map[string]*DKGKey),
+	}
+}
+
+// SetKeyDir persists key shares in dir and loads the ones already there
+func (d *NetworkDKG) SetKeyDir(dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.keyDir = dir
+	for _, e := range entries {
+		if !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		key, err := loadDKGKey(filepath.Join(dir, e.Name()))
+		if err != nil {
+			log.Printf("⚠️  [DKG] Skipping key file %s: %v", e.Name(), err)
+			continue
+		}
+		d.keys[key.GroupID] = key
+	}
+	if len(d.keys) > 0 {
+		log.Printf("🔑 [DKG] Loaded %d group key shares from %s", len(d.keys), dir)
+	}
+	return nil
+}
+
+// Key returns this node's share of a group key
+func (d *NetworkDKG) Key(groupID string) (*DKGKey, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	key, ok := d.keys[groupID]
+	return key, ok
+}
+
+// Run generates a group key among this node and peers, any threshold of
+// which can later use it. Members that fail during the run are left
+// without a share; the run fails if fewer than threshold members finish.
+func (d *NetworkDKG) Run(ctx context.Context, groupID string, peers []peer.ID, threshold int) (*DKGKey, error) {
+	if !validDKGGroupID(groupID) {
+		return nil, fmt.Errorf("invalid group ID %q", groupID)
+	}
+	if _, ok := d.Key(groupID); ok {
+		return nil, fmt.Errorf("group %s already has a key", groupID)
+	}
+	participants := []peer.ID{d.host.ID()}
+	for _, p := range peers {
+		if !slices.Contains(participants, p) {
+			participants = append(participants, p)
+		}
+	}
+	if len(participants) > maxDKGParticipants {
+		return nil, fmt.Errorf("a group has at most %d members", maxDKGParticipants)
+	}
+	if threshold < 1 || threshold > len(participants) {
+		return nil, fmt.Errorf("invalid threshold %d for %d members", threshold, len(participants))
+	}
+
+	// Round 1, restarted without members that do not commit
+	var sessionID string
+	var commits map[int][][]byte
+	for attempt := 1; ; attempt++ {
+		sessionID = generateSessionID()
+		init := binary.BigEndian.AppendUint32(nil, uint32(threshold))
+		init = binary.BigEndian.AppendUint32(init, uint32(len(participants)))
+		for _, p := range participants {
+			init = appendRPCString(init, p.String())
+		}
+		replies, errs := d.callAll(ctx, participants, groupID, sessionID, dkgStepInit, func(int) []byte { return init })
+		var responders []peer.ID
+		commits = make(map[int][][]byte, len(participants))
+		for i, p := range participants {
+			if errs[i] != nil {
+				log.Printf("⚠️  [DKG] %s did not commit to %s: %v", shortPeerID(p), groupID, errs[i])
+				continue
+			}
+			responders = append(responders, p)
+			r := &rpcPayload{b: replies[i]}
+			commits[i+1] = readRPCBytesList(r)
+		}
+		if len(responders) == len(participants) {
+			break
+		}
+		if len(responders) == 0 || responders[0] != d.host.ID() || len(responders) < threshold || attempt == maxDKGAttempts {
+			return nil, fmt.Errorf("only %d of %d members committed to %s", len(responders), len(participants), groupID)
+		}
+		participants = responders
+	}
+
+	// Round 2: members deal their shares. A dealer that fails here is
+	// complained about in round 3.
+	body := binary.BigEndian.AppendUint32(nil, uint32(len(participants)))
+	for i := range participants {
+		body = appendRPCBytesList(body, commits[i+1])
+	}
+	_, errs := d.callAll(ctx, participants, groupID, sessionID, dkgStepCommits, func(int) []byte { return body })
+	for i, err := range errs {
+		if err != nil {
+			log.Printf("⚠️  [DKG] %s did not deal its shares for %s: %v", shortPeerID(participants[i]), groupID, err)
+		}
+	}
+
+	// Round 3: collect complaints
+	replies, errs := d.callAll(ctx, participants, groupID, sessionID, dkgStepVerify, func(int) []byte { return nil })
+	complaints := make(map[int][]int) // Dealer -> accusers
+	for i, reply := range replies {
+		if errs[i] != nil {
+			log.Printf("⚠️  [DKG] %s did not verify its shares for %s: %v", shortPeerID(participants[i]), groupID, errs[i])
+			continue
+		}
+		r := &rpcPayload{b: reply}
+		for _, dealer := range readRPCUint32List(r) {
+			complaints[int(dealer)] = append(complaints[int(dealer)], i+1)
+		}
+	}
+
+	// Round 4: accused dealers reveal the disputed shares
+	disqualified := make(map[int]bool)
+	revealed := make(map[int]map[int][]byte) // Accuser -> dealer -> share
+	for dealer, accusers := range complaints {
+		if dealer < 1 || dealer > len(participants) {
+			continue
+		}
+		commit, err := kyberdkg.DecodeCommit(dealer, commits[dealer])
+		if err != nil {
+			disqualified[dealer] = true
+			continue
+		}
+		for _, accuser := range accusers {
+			share, err := d.call(ctx, participants[dealer-1], groupID, sessionID, dkgStepReveal, binary.BigEndian.AppendUint32(nil, uint32(accuser)))
+			var s kyber.Scalar
+			if err == nil {
+				s, err = kyberdkg.ScalarFromBytes(share)
+			}
+			if err != nil || !kyberdkg.VerifyShare(commit, accuser, s) {
+				log.Printf("🚫 [DKG] Disqualified dealer %s in %s: share for member %d not revealed or invalid", shortPeerID(participants[dealer-1]), groupID, accuser)
+				disqualified[dealer] = true
+				break
+			}
+			if revealed[accuser] == nil {
+				revealed[accuser] = make(map[int][]byte)
+			}
+			revealed[accuser][dealer] = share
+		}
+	}
+	var qualified []uint32
+	for i := range participants {
+		if !disqualified[i+1] {
+			qualified = append(qualified, uint32(i+1))
+		}
+	}
+	if len(qualified) < threshold {
+		return nil, fmt.Errorf("only %d dealers of %s qualified, %d needed", len(qualified), groupID, threshold)
+	}
+
+	// Round 5: members derive their key shares
+	replies, errs = d.callAll(ctx, participants, groupID, sessionID, dkgStepFinish, func(i int) []byte {
+		b := appendRPCUint32List(nil, qualified)
+		b = binary.BigEndian.AppendUint32(b, uint32(len(revealed[i+1])))
+		for dealer, share := range revealed[i+1] {
+			b = binary.BigEndian.AppendUint32(b, uint32(dealer))
+			b = appendRPCString(b, string(share))
+		}
+		return b
+	})
+	var groupKey []byte
+	finished := 0
+	for i, reply := range replies {
+		if errs[i] != nil {
+			log.Printf("⚠️  [DKG] %s holds no share of %s: %v", shortPeerID(participants[i]), groupID, errs[i])
+			continue
+		}
+		if groupKey == nil {
+			groupKey = reply
+		} else if string(reply) != string(groupKey) {
+			return nil, fmt.Errorf("member %s derived a different key for %s", shortPeerID(participants[i]), groupID)
+		}
+		finished++
+	}
+	key, ok := d.Key(groupID)
+	if !ok || finished < threshold {
+		return nil, fmt.Errorf("only %d members of %s hold a share, %d needed", finished, groupID, threshold)
+	}
+	log.Printf("🔑 [DKG] Group %s ready: %d of %d members, %d dealers qualified", groupID, finished, len(participants), len(qualified))
+	return key, nil
+}
+
+// callAll runs a step with every participant concurrently
+func (d *NetworkDKG) callAll(ctx context.Context, participants []peer.ID, groupID, sessionID string, step byte, body func(i int) []byte) ([][]byte, []error) {
+	replies := make([][]byte, len(participants))
+	errs := make([]error, len(participants))
+	var wg sync.WaitGroup
+	for i, p := range participants {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			replies[i], errs[i] = d.call(ctx, p, groupID, sessionID, step, body(i))
+		}()
+	}
+	wg.Wait()
+	return replies, errs
+}
+
+// call runs a step with one member. Steps for this node are handled in
+// place.
+func (d *NetworkDKG) call(ctx context.Context, p peer.ID, groupID, sessionID string, step byte, body []byte) ([]byte, error) {
+	if p == d.host.ID() {
+		return d.serve(ctx, p, groupID, sessionID, step, body)
+	}
+	ctx, cancel := context.WithTimeout(ctx, dkgStepTimeout)
+	defer cancel()
+	rs, err := openRPCStream(ctx, d.host, p)
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Close()
+	rs.SetDeadline(time.Now().Add(dkgStepTimeout))
+
+	req := appendRPCString(nil, groupID)
+	req = appendRPCString(req, sessionID)
+	req = append(req, step)
+	return rs.call(rpcMsgDKG, append(req, body...), rpcMsgDKGReply)
+}
+
+// serve handles a step from remote, which may be this node
+func (d *NetworkDKG) serve(ctx context.Context, remote peer.ID, groupID, sessionID string, step byte, body []byte) ([]byte, error) {
+	req := &rpcPayload{b: body}
+	if step == dkgStepInit {
+		return d.init(remote, groupID, sessionID, req)
+	}
+
+	d.mu.Lock()
+	s, ok := d.sessions[groupID]
+	d.mu.Unlock()
+	if !ok || s.id != sessionID {
+		return nil, fmt.Errorf("no key generation session %s for %s", sessionID, groupID)
+	}
+	if step == dkgStepShare {
+		return nil, d.receiveShare(remote, s, req)
+	}
+	// Every other step comes from the coordinator
+	if remote != s.coordinator {
+		return nil, errors.New("only the coordinator drives key generation")
+	}
+	switch step {
+	case dkgStepCommits:
+		return nil, d.deal(ctx, s, req)
+	case dkgStepVerify:
+		return appendRPCUint32List(nil, d.complaints(s)), nil
+	case dkgStepReveal:
+		accuser := req.uint32()
+		if req.err != nil {
+			return nil, req.err
+		}
+		share, ok := s.dealt[int(accuser)]
+		if !ok {
+			return nil, fmt.Errorf("no share dealt to member %d", accuser)
+		}
+		return share.Payload, nil
+	case dkgStepFinish:
+		return d.finish(s, req)
+	default:
+		return nil, fmt.Errorf("unknown DKG step %d", step)
+	}
+}
+
+// init joins a key generation and commits to this member's polynomial
+func (d *NetworkDKG) init(remote peer.ID, groupID, sessionID string, req *rpcPayload) ([]byte, error) {
+	threshold, count := int(req.uint32()), int(req.uint32())
+	if req.err != nil || count > maxDKGParticipants || threshold < 1 || threshold > count || !validDKGGroupID(groupID) {
+		return nil, errors.New("invalid key generation parameters")
+	}
+	s := &dkgSession{
+		id:          sessionID,
+		groupID:     groupID,
+		coordinator: remote,
+		threshold:   threshold,
+		dealt:       make(map[int]*kyberdkg.Share),
+		received:    make(map[int]*kyberdkg.Share),
+		started:     time.Now(),
+	}
+	for i := 0; i < count; i++ {
+		p, err := peer.Decode(req.string())
+		if err != nil || req.err != nil || slices.Contains(s.participants, p) {
+			return nil, errors.New("invalid participant list")
+		}
+		s.participants = append(s.participants, p)
+		if p == d.host.ID() {
+			s.self = i + 1
+		}
+	}
+	if s.self == 0 || !slices.Contains(s.participants, remote) {
+		return nil, errors.New("both this node and the coordinator must be members")
+	}
+	nodes := make([]*kyberdkg.Node, count)
+	for i := range nodes {
+		nodes[i] = &kyberdkg.Node{ID: i + 1}
+	}
+	s.state = kyberdkg.NewDKGState(s.self, nodes, threshold)
+	commit, err := s.state.Round1GenerateCommitments()
+	if err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.keys[groupID]; ok {
+		return nil, fmt.Errorf("group %s already has a key", groupID)
+	}
+	for g, old := range d.sessions {
+		if time.Since(old.started) > dkgSessionTTL {
+			delete(d.sessions, g)
+		}
+	}
+	if old, ok := d.sessions[groupID]; ok && old.coordinator != remote {
+		return nil, fmt.Errorf("group %s is being generated by another coordinator", groupID)
+	}
+	d.sessions[groupID] = s
+	return appendRPCBytesList(nil, commit.Commits), nil
+}
+
+// deal records every dealer's commitments and sends each member its share
+func (d *NetworkDKG) deal(ctx context.Context, s *dkgSession, req *rpcPayload) error {
+	count := int(req.uint32())
+	if req.err != nil || count != len(s.participants) {
+		return errors.New("commitments do not match the participants")
+	}
+	commits := make(map[int]*kyberdkg.Commit, count)
+	for i := 1; i <= count; i++ {
+		list := readRPCBytesList(req)
+		if req.err != nil {
+			return req.err
+		}
+		if len(list) == 0 {
+			continue // Dealer left before committing
+		}
+		c, err := kyberdkg.DecodeCommit(i, list)
+		if err != nil {
+			return err
+		}
+		if len(c.C) != s.threshold {
+			return fmt.Errorf("dealer %d committed to %d coefficients, expected %d", i, len(c.C), s.threshold)
+		}
+		commits[i] = c
+	}
+	shares, err := s.state.Round2GenerateShares(commits)
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	s.commits = commits
+	for to, share := range shares {
+		s.dealt[to] = share
+	}
+	d.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, dkgDealTimeout)
+	defer cancel()
+	var wg sync.WaitGroup
+	for i, p := range s.participants {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			body := binary.BigEndian.AppendUint32(nil, uint32(s.self))
+			body = appendRPCString(body, string(shares[i+1].Payload))
+			if _, err := d.call(ctx, p, s.groupID, s.id, dkgStepShare, body); err != nil {
+				log.Printf("⚠️  [DKG] Failed to deal share to %s for %s: %v", shortPeerID(p), s.groupID, err)
+			}
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+// receiveShare stores a share dealt by the sending member
+func (d *NetworkDKG) receiveShare(remote peer.ID, s *dkgSession, req *rpcPayload) error {
+	dealer := int(req.uint32())
+	payload := []byte(req.string())
+	if req.err != nil {
+		return req.err
+	}
+	if dealer < 1 || dealer > len(s.participants) || s.participants[dealer-1] != remote {
+		return errors.New("share is not from the dealer it claims")
+	}
+	share, err := kyberdkg.DecodeShare(dealer, s.self, payload)
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	s.received[dealer] = share
+	d.mu.Unlock()
+	return nil
+}
+
+// complaints lists the dealers whose share for this member is missing or
+// does not match their commitments
+func (d *NetworkDKG) complaints(s *dkgSession) []uint32 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var out []uint32
+	for i := 1; i <= len(s.participants); i++ {
+		share, ok := s.received[i]
+		if !ok || !kyberdkg.VerifyShare(s.commits[i], s.self, share.S) {
+			out = append(out, uint32(i))
+		}
+	}
+	return out
+}
+
+// finish derives this member's key share from the qualified dealers
+func (d *NetworkDKG) finish(s *dkgSession, req *rpcPayload) ([]byte, error) {
+	qualified := make(map[int]bool)
+	for _, dealer := range readRPCUint32List(req) {
+		qualified[int(dealer)] = true
+	}
+	count := int(req.uint32())
+	if req.err != nil || count > len(s.participants) {
+		return nil, errors.New("invalid finish request")
+	}
+
+	d.mu.Lock()
+	for i := 0; i < count; i++ {
+		dealer := int(req.uint32())
+		payload := []byte(req.string())
+		if req.err != nil {
+			d.mu.Unlock()
+			return nil, req.err
+		}
+		if share, err := kyberdkg.DecodeShare(dealer, s.self, payload); err == nil {
+			s.received[dealer] = share
+		}
+	}
+	sharesByID := make(map[int]map[int]*kyberdkg.Share)
+	for i := 1; i <= len(s.participants); i++ {
+		if !qualified[i] {
+			s.state.Disqualify(i)
+			continue
+		}
+		share, ok := s.received[i]
+		if !ok || !kyberdkg.VerifyShare(s.commits[i], s.self, share.S) {
+			d.mu.Unlock()
+			return nil, fmt.Errorf("no valid share from qualified dealer %d", i)
+		}
+		sharesByID[i] = map[int]*kyberdkg.Share{s.self: share}
+	}
+	d.mu.Unlock()
+
+	if err := s.state.Round3VerifyAndAccumulateShares(sharesByID); err != nil {
+		return nil, err
+	}
+	share, err := s.state.SigningShare()
+	if err != nil {
+		return nil, err
+	}
+	groupKey, err := share.GroupKey.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	key := &DKGKey{GroupID: s.groupID, Participants: s.participants, Threshold: s.threshold, Share: share}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.keyDir != "" {
+		if err := saveDKGKey(filepath.Join(d.keyDir, s.groupID+".json"), key); err != nil {
+			return nil, fmt.Errorf("failed to persist key share: %w", err)
+		}
+	}
+	d.keys[s.groupID] = key
+	delete(d.sessions, s.groupID)
+	log.Printf("🔑 [DKG] Holding share %d of group %s", s.self, s.groupID)
+	return groupKey, nil
+}
+
+// dkgKeyFile is a key share on disk
+type dkgKeyFile struct {
+	GroupID      string         `json:"groupId"`
+	Participants []string       `json:"participants"`
+	Threshold    int            `json:"threshold"`
+	Index        int            `json:"index"`
+	Secret       []byte         `json:"secret"`
+	GroupKey     []byte         `json:"groupKey"`
+	PublicShares map[int][]byte `json:"publicShares"`
+}
+
+// saveDKGKey writes a key share readable only by this user, so a partial
+// write is never loaded
+func saveDKGKey(path string, key *DKGKey) error {
+	f := dkgKeyFile{
+		GroupID:      key.GroupID,
+		Threshold:    key.Threshold,
+		Index:        key.Share.Index,
+		PublicShares: make(map[int][]byte, len(key.Share.PublicShares)),
+	}
+	for _, p := range key.Participants {
+		f.Participants = append(f.Participants, p.String())
+	}
+	var err error
+	if f.Secret, err = key.Share.Secret.MarshalBinary(); err != nil {
+		return err
+	}
+	if f.GroupKey, err = key.Share.GroupKey.MarshalBinary(); err != nil {
+		return err
+	}
+	for i, y := range key.Share.PublicShares {
+		if f.PublicShares[i], err = y.MarshalBinary(); err != nil {
+			return err
+		}
+	}
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path+".tmp", data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// loadDKGKey reads a key share written by saveDKGKey
+func loadDKGKey(path string) (*DKGKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f dkgKeyFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, err
+	}
+	key := &DKGKey{GroupID: f.GroupID, Threshold: f.Threshold}
+	for _, s := range f.Participants {
+		p, err := peer.Decode(s)
+		if err != nil {
+			return nil, err
+		}
+		key.Participants = append(key.Participants, p)
+	}
+	share := &kyberdkg.SigningShare{Index: f.Index, Threshold: f.Threshold, PublicShares: make(map[int]kyber.Point)}
+	if share.Secret, err = kyberdkg.ScalarFromBytes(f.Secret); err != nil {
+		return nil, err
+	}
+	if share.GroupKey, err = kyberdkg.PointFromBytes(f.GroupKey); err != nil {
+		return nil, err
+	}
+	for i, b := range f.PublicShares {
+		if share.PublicShares[i], err = kyberdkg.PointFromBytes(b); err != nil {
+			return nil, err
+		}
+	}
+	key.Share = share
+	return key, nil
+}
+
+// validDKGGroupID accepts group IDs that are safe as file names
+func validDKGGroupID(groupID string) bool {
+	return groupID != "" && len(groupID) <= 128 && filepath.Base(groupID) == groupID && !strings.HasPrefix(groupID, ".")
+}
+
+// appendRPCBytesList appends [count(4)] and each item as a string
+func appendRPCBytesList(b []byte, items [][]byte) []byte {
+	b = binary.BigEndian.AppendUint32(b, uint32(len(items)))
+	for _, item := range items {
+		b = appendRPCString(b, string(item))
+	}
+	return b
+}
+
+func readRPCBytesList(p *rpcPayload) [][]byte {
+	count := p.uint32()
+	if count > maxDKGParticipants {
+		p.err = fmt.Errorf("%w: list of %d items", errRPCBadFrame, count)
+		return nil
+	}
+	var out [][]byte
+	for i := uint32(0); i < count && p.err == nil; i++ {
+		out = append(out, []byte(p.string()))
+	}
+	return out
+}
+
+// appendRPCUint32List appends [count(4)] and each value
+func appendRPCUint32List(b []byte, values []uint32) []byte {
+	b = binary.BigEndian.AppendUint32(b, uint32(len(values)))
+	for _, v := range values {
+		b = binary.BigEndian.AppendUint32(b, v)
+	}
+	return b
+}
+
+func readRPCUint32List(p *rpcPayload) []uint32 {
+	count := p.uint32()
+	if count > maxDKGParticipants {
+		p.err = fmt.Errorf("%w: list of %d values", errRPCBadFrame, count)
+		return nil
+	}
+	var out []uint32
+	for i := uint32(0); i < count && p.err == nil; i++ {
+		out = append(out, p.uint32())
+	}
+	return out
+}
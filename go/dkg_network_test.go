@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// newDKGTestNodes starts connected nodes on consecutive ports
+func newDKGTestNodes(t *testing.T, port, count int) []*LibP2PPangeaNode {
+	t.Helper()
+	var nodes []*LibP2PPangeaNode
+	for i := 0; i < count; i++ {
+		n, err := NewLibP2PPangeaNodeWithOptions(uint32(port-12000+i), NewNodeStore(), false, true, port+i)
+		if err != nil {
+			t.Fatalf("failed to create node %d: %v", i, err)
+		}
+		t.Cleanup(n.cancel)
+		nodes = append(nodes, n)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for i, a := range nodes {
+		for _, b := range nodes[i+1:] {
+			if err := a.host.Connect(ctx, peer.AddrInfo{ID: b.host.ID(), Addrs: b.host.Addrs()}); err != nil {
+				t.Fatalf("connect failed: %v", err)
+			}
+		}
+	}
+	return nodes
+}
+
+func TestNetworkDKGDisqualifiesWithholdingDealer(t *testing.T) {
+	nodes := newDKGTestNodes(t, 12580, 4)
+
+	// The last node commits but never deals its shares
+	cheat := nodes[3]
+	cheat.host.SetStreamHandler(PangeaRPCProtocol, func(s network.Stream) {
+		defer s.Close()
+		rs, err := acceptRPCStream(s)
+		if err != nil {
+			return
+		}
+		frame, err := rs.recv()
+		if err != nil || frame.Type != rpcMsgDKG {
+			return
+		}
+		req := &rpcPayload{b: frame.Payload}
+		groupID, sessionID, step := req.string(), req.string(), req.take(1)
+		if req.err != nil {
+			return
+		}
+		if step[0] == dkgStepCommits {
+			rs.send(rpcMsgDKGReply, nil)
+			return
+		}
+		reply, err := cheat.dkg.serve(context.Background(), s.Conn().RemotePeer(), groupID, sessionID, step[0], req.rest())
+		if err != nil {
+			rs.sendError(err.Error())
+			return
+		}
+		rs.send(rpcMsgDKGReply, reply)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	peers := []peer.ID{nodes[1].host.ID(), nodes[2].host.ID(), cheat.host.ID()}
+	key, err := nodes[0].dkg.Run(ctx, "audit", peers, 2)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	for _, n := range nodes[1:3] {
+		other, ok := n.dkg.Key("audit")
+		if !ok || !other.Share.GroupKey.Equal(key.Share.GroupKey) {
+			t.Fatal("honest member does not hold the group key")
+		}
+	}
+	if _, ok := cheat.dkg.Key("audit"); ok {
+		t.Fatal("withholding dealer holds a share")
+	}
+	if len(key.Share.PublicShares) != 4 {
+		t.Fatalf("expected public shares for all 4 members, got %d", len(key.Share.PublicShares))
+	}
+
+	// The honest members sign without the disqualified one
+	sig, err := nodes[2].GetThresholdSigner().Sign(ctx, "audit", SignPurposeManifest, []byte("manifest"))
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	if err := VerifyThresholdSignature("audit", sig.GroupKey, SignPurposeManifest, []byte("manifest"), sig.Signature); err != nil {
+		t.Fatalf("signature does not verify: %v", err)
+	}
+
+	// A second run for the same group is refused
+	if _, err := nodes[1].dkg.Run(ctx, "audit", peers, 2); err == nil {
+		t.Fatal("expected a group to be generated only once")
+	}
+}
+
+func TestNetworkDKGDropsUnreachableMemberAndPersists(t *testing.T) {
+	nodes := newDKGTestNodes(t, 12584, 4)
+	dir := t.TempDir()
+	if err := nodes[0].dkg.SetKeyDir(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if _, err := nodes[0].dkg.Run(ctx, "../escape", []peer.ID{nodes[1].host.ID()}, 1); err == nil {
+		t.Fatal("expected a group ID that is not a file name to be refused")
+	}
+
+	// One member is gone before the run starts
+	nodes[3].cancel()
+	nodes[3].host.Close()
+	peers := []peer.ID{nodes[1].host.ID(), nodes[2].host.ID(), nodes[3].host.ID()}
+	key, err := nodes[0].dkg.Run(ctx, "storage", peers, 2)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(key.Participants) != 3 || key.Participants[2] != nodes[2].host.ID() {
+		t.Fatalf("unexpected participants %v", key.Participants)
+	}
+
+	// The share survives a restart
+	reloaded := NewNetworkDKG(nodes[0].host)
+	if err := reloaded.SetKeyDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	loaded, ok := reloaded.Key("storage")
+	if !ok {
+		t.Fatal("key share was not persisted")
+	}
+	if !loaded.Share.Secret.Equal(key.Share.Secret) || !loaded.Share.GroupKey.Equal(key.Share.GroupKey) ||
+		len(loaded.Share.PublicShares) != 3 || loaded.Threshold != 2 || loaded.Participants[1] != nodes[1].host.ID() {
+		t.Fatal("reloaded key share differs")
+	}
+
+	// Too few members left for the threshold
+	nodes[2].cancel()
+	nodes[2].host.Close()
+	if _, err := nodes[0].dkg.Run(ctx, "small", []peer.ID{nodes[2].host.ID(), nodes[3].host.ID()}, 2); err == nil {
+		t.Fatal("expected a run without enough members to fail")
+	}
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Download session states
+const (
+	DownloadFetching       = "fetching"       // Shards are being fetched
+	DownloadReconstructing = "reconstructing" // Enough shards are verified; decrypting and decoding
+	DownloadCompleted      = "completed"      // Data is available
+	DownloadFailed         = "failed"         // Stopped on an error; spooled shards are kept
+	DownloadCancelled      = "cancelled"      // Stopped by the client; spooled shards are kept unless discarded
+)
+
+// downloadSessionTTL is how long a finished session stays queryable
+const downloadSessionTTL = time.Hour
+
+// DownloadSession is the state of one download on this node
+type DownloadSession struct {
+	ID             string
+	FileHash       string
+	State          string
+	ShardsVerified uint32
+	ShardsRequired uint32
+	ShardsResumed  uint32 // Loaded from the spool instead of fetched
+	BytesFetched   uint64
+	Fetches        []shardFetch
+	Data           []byte // The reconstructed file once completed
+	Error          string
+	Started        time.Time
+	Updated        time.Time
+
+	cancel context.CancelFunc
+	done   chan struct{} // Closed when the session's run has returned
+}
+
+// downloadReconstructor turns verified shards into the original file
+type downloadReconstructor func(ctx context.Context, shards []ShardData, present []bool) ([]byte, error)
+
+// DownloadManager runs downloads in the background and spools every
+// verified shard to disk, so a download started again for the same file
+// after a cancel, failure or restart fetches only the shards it lacks
+type DownloadManager struct {
+	spoolDir string
+	sessions map[string]*DownloadSession
+	mu       sync.Mutex
+}
+
+// NewDownloadManager creates a manager spooling shards under spoolDir, or
+// ~/.pangea/download_spool when it is empty. Directories are created on
+// first use.
+func NewDownloadManager(spoolDir string) *DownloadManager {
+	if spoolDir == "" {
+		spoolDir = "download_spool"
+		if homeDir, err := os.UserHomeDir(); err == nil {
+			spoolDir = filepath.Join(homeDir, ".pangea", "download_spool")
+		}
+	}
+	return &DownloadManager{spoolDir: spoolDir, sessions: make(map[string]*DownloadSession)}
+}
+
+// Start begins downloading a file from the listed shard locations and
+// returns the session ID. A download of the same file already in progress
+// is returned instead of starting another.
+func (dm *DownloadManager) Start(fileHash string, locations []shardPlacement, parallelism int, src shardSource, reconstruct downloadReconstructor) (string, error) {
+	if fileHash == "" || filepath.Base(fileHash) != fileHash || strings.HasPrefix(fileHash, ".") {
+		return "", fmt.Errorf("invalid file hash %q", fileHash)
+	}
+
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+	var previous []chan struct{}
+	for id, s := range dm.sessions {
+		running := s.State == DownloadFetching || s.State == DownloadReconstructing
+		if s.FileHash == fileHash {
+			if running {
+				return id, nil
+			}
+			previous = append(previous, s.done)
+		}
+		if !running && time.Since(s.Updated) > downloadSessionTTL {
+			delete(dm.sessions, id)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &DownloadSession{
+		ID:             generateDownloadID(),
+		FileHash:       fileHash,
+		State:          DownloadFetching,
+		ShardsRequired: cesDataShards,
+		Started:        time.Now(),
+		Updated:        time.Now(),
+		cancel:         cancel,
+		done:           make(chan struct{}),
+	}
+	dm.sessions[s.ID] = s
+	go func() {
+		defer close(s.done)
+		// A cancelled run of the same file may still be unwinding; it owns
+		// the spool until it returns
+		for _, done := range previous {
+			<-done
+		}
+		dm.run(ctx, s, locations, parallelism, src, reconstruct)
+	}()
+	log.Printf("📥 [DOWNLOAD] Started %s for %s", s.ID, fileHash)
+	return s.ID, nil
+}
+
+// Cancel stops a running download. Its spooled shards are kept for a
+// later Start of the same file unless discard is set.
+func (dm *DownloadManager) Cancel(id string, discard bool) error {
+	dm.mu.Lock()
+	s, ok := dm.sessions[id]
+	if !ok {
+		dm.mu.Unlock()
+		return fmt.Errorf("download %s not found", id)
+	}
+	running := s.State == DownloadFetching || s.State == DownloadReconstructing
+	if running {
+		s.cancel()
+		dm.setStateLocked(s, DownloadCancelled, "")
+	}
+	done := s.done
+	dm.mu.Unlock()
+
+	if !running && !discard {
+		return fmt.Errorf("download %s is %s, not running", id, s.State)
+	}
+	if discard {
+		// The run owns the spool until it returns
+		go func() {
+			<-done
+			if err := os.RemoveAll(dm.spoolPath(s.FileHash)); err != nil {
+				log.Printf("⚠️  [DOWNLOAD] Failed to discard spool for %s: %v", s.FileHash, err)
+			}
+		}()
+	}
+	return nil
+}
+
+// Session returns a download's state
+func (dm *DownloadManager) Session(id string) (DownloadSession, bool) {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+	s, ok := dm.sessions[id]
+	if !ok {
+		return DownloadSession{}, false
+	}
+	return s.snapshot(), true
+}
+
+// Active returns the number of downloads still fetching or reconstructing
+func (dm *DownloadManager) Active() int {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+	var n int
+	for _, s := range dm.sessions {
+		if s.State == DownloadFetching || s.State == DownloadReconstructing {
+			n++
+		}
+	}
+	return n
+}
+
+// run loads spooled shards, fetches the missing ones and reconstructs
+func (dm *DownloadManager) run(ctx context.Context, s *DownloadSession, locations []shardPlacement, parallelism int, src shardSource, reconstruct downloadReconstructor) {
+	shardCount := cesDataShards + cesParityShards
+	spool := dm.spoolPath(s.FileHash)
+	shards, present := dm.loadSpool(spool, locations, shardCount)
+
+	var have int
+	for _, p := range present {
+		if p {
+			have++
+		}
+	}
+	dm.mu.Lock()
+	s.ShardsResumed = uint32(have)
+	s.ShardsVerified = uint32(have)
+	dm.mu.Unlock()
+	if have > 0 {
+		log.Printf("📥 [DOWNLOAD] %s resumed with %d spooled shards", s.ID, have)
+	}
+
+	if have < cesDataShards {
+		missing := make([]shardPlacement, 0, len(locations))
+		for _, loc := range locations {
+			if int(loc.shardIndex) >= shardCount || !present[loc.shardIndex] {
+				missing = append(missing, loc)
+			}
+		}
+		spoolErr := os.MkdirAll(spool, 0755)
+		if spoolErr != nil {
+			log.Printf("⚠️  [DOWNLOAD] Shards for %s will not be spooled: %v", s.ID, spoolErr)
+		}
+		src.onFetch = func(f shardFetch, data []byte) {
+			if f.err == nil && spoolErr == nil {
+				if err := writeSpoolShard(spool, f.shardIndex, data); err != nil {
+					log.Printf("⚠️  [DOWNLOAD] Failed to spool shard %d of %s: %v", f.shardIndex, s.ID, err)
+				}
+			}
+			dm.mu.Lock()
+			defer dm.mu.Unlock()
+			s.Fetches = append(s.Fetches, f)
+			if f.verified {
+				s.ShardsVerified++
+			}
+			if f.err == nil {
+				s.BytesFetched += uint64(len(data))
+			}
+			s.Updated = time.Now()
+		}
+		fetched, fetchedPresent, _ := fetchShards(ctx, src, s.FileHash, missing, shardCount, cesDataShards-have, parallelism)
+		for i, p := range fetchedPresent {
+			if p {
+				shards[i], present[i] = fetched[i], true
+				have++
+			}
+		}
+	}
+
+	dm.mu.Lock()
+	if ctx.Err() != nil {
+		dm.mu.Unlock()
+		return // Cancelled
+	}
+	if have < cesDataShards {
+		dm.setStateLocked(s, DownloadFailed, fmt.Sprintf("Insufficient shards: have %d, need at least %d", have, cesDataShards))
+		dm.mu.Unlock()
+		log.Printf("❌ [DOWNLOAD] %s stopped with %d of %d shards", s.ID, have, cesDataShards)
+		return
+	}
+	dm.setStateLocked(s, DownloadReconstructing, "")
+	dm.mu.Unlock()
+
+	data, err := reconstruct(ctx, shards, present)
+
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+	switch {
+	case ctx.Err() != nil:
+		// Cancelled
+	case err != nil:
+		dm.setStateLocked(s, DownloadFailed, err.Error())
+		log.Printf("❌ [DOWNLOAD] %s failed: %v", s.ID, err)
+	default:
+		s.Data = data
+		dm.setStateLocked(s, DownloadCompleted, "")
+		os.RemoveAll(spool)
+		log.Printf("📥 [DOWNLOAD] %s reconstructed %d bytes", s.ID, len(data))
+	}
+}
+
+// spoolPath is the directory holding a file's spooled shards
+func (dm *DownloadManager) spoolPath(fileHash string) string {
+	return filepath.Join(dm.spoolDir, fileHash)
+}
+
+// loadSpool reads a file's spooled shards, dropping any that no longer
+// match their recorded hash
+func (dm *DownloadManager) loadSpool(spool string, locations []shardPlacement, shardCount int) ([]ShardData, []bool) {
+	shards := make([]ShardData, shardCount)
+	present := make([]bool, shardCount)
+	hashes := make(map[uint32]string)
+	for _, loc := range locations {
+		if loc.shardHash != "" {
+			hashes[loc.shardIndex] = loc.shardHash
+		}
+	}
+
+	entries, err := os.ReadDir(spool)
+	if err != nil {
+		return shards, present
+	}
+	for _, e := range entries {
+		idx, err := strconv.ParseUint(strings.TrimPrefix(e.Name(), "shard-"), 10, 32)
+		if err != nil || !strings.HasPrefix(e.Name(), "shard-") || int(idx) >= shardCount {
+			continue
+		}
+		path := filepath.Join(spool, e.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		if expected, ok := hashes[uint32(idx)]; ok && fmt.Sprintf("%x", sha256.Sum256(data)) != expected {
+			os.Remove(path)
+			continue
+		}
+		shards[idx], present[idx] = ShardData{Data: data}, true
+	}
+	return shards, present
+}
+
+// writeSpoolShard writes a shard so that a partial write is never loaded
+func writeSpoolShard(spool string, shardIndex uint32, data []byte) error {
+	path := filepath.Join(spool, fmt.Sprintf("shard-%d", shardIndex))
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// setStateLocked changes a session's state. Caller must hold dm.mu.
+func (dm *DownloadManager) setStateLocked(s *DownloadSession, state, errMsg string) {
+	s.State, s.Error, s.Updated = state, errMsg, time.Now()
+}
+
+// snapshot copies the session's exported state
+func (s *DownloadSession) snapshot() DownloadSession {
+	c := *s
+	c.Fetches = append([]shardFetch(nil), s.Fetches...)
+	c.cancel, c.done = nil, nil
+	return c
+}
+
+func generateDownloadID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return "dl-" + hex.EncodeToString(b)
+}
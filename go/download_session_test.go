@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// waitForDownload polls a session until cond holds
+func waitForDownload(t *testing.T, dm *DownloadManager, id string, cond func(DownloadSession) bool) DownloadSession {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		s, ok := dm.Session(id)
+		if !ok {
+			t.Fatalf("download %s not found", id)
+		}
+		if cond(s) {
+			return s
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("download %s stuck in %s with %d shards", id, s.State, s.ShardsVerified)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func downloadLocationsFor(count int) []shardPlacement {
+	locations := make([]shardPlacement, count)
+	for i := range locations {
+		locations[i] = shardPlacement{shardIndex: uint32(i), peerID: uint32(i + 1), shardHash: fakeShardHash(uint32(i))}
+	}
+	return locations
+}
+
+// joinShards stands in for CES reconstruction
+func joinShards(ctx context.Context, shards []ShardData, present []bool) ([]byte, error) {
+	var out []byte
+	for i, s := range shards {
+		if present[i] {
+			out = append(out, s.Data...)
+		}
+	}
+	return out, nil
+}
+
+func TestDownloadSessionResumesFromSpool(t *testing.T) {
+	dm := NewDownloadManager(t.TempDir())
+	locations := downloadLocationsFor(cesDataShards + cesParityShards)
+
+	// The first run stalls after three shards and is cancelled there
+	release := make(chan struct{})
+	first := shardSource{fetch: func(ctx context.Context, peerID uint32, fileHash string, shardIndex uint32) ([]byte, error) {
+		if shardIndex >= 3 {
+			<-release
+		}
+		return fakeShardBytes(shardIndex), nil
+	}}
+	id, err := dm.Start("file1", locations, 1, first, joinShards)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := dm.Start("file1", locations, 1, first, joinShards); again != id {
+		t.Fatalf("expected the running session %s, got %s", id, again)
+	}
+	waitForDownload(t, dm, id, func(s DownloadSession) bool { return s.ShardsVerified == 3 })
+	if err := dm.Cancel(id, false); err != nil {
+		t.Fatal(err)
+	}
+	close(release)
+	if s, _ := dm.Session(id); s.State != DownloadCancelled {
+		t.Fatalf("expected a cancelled session, got %s", s.State)
+	}
+
+	var mu sync.Mutex
+	refetched := map[uint32]bool{}
+	second := shardSource{fetch: func(ctx context.Context, peerID uint32, fileHash string, shardIndex uint32) ([]byte, error) {
+		mu.Lock()
+		refetched[shardIndex] = true
+		mu.Unlock()
+		return fakeShardBytes(shardIndex), nil
+	}}
+	resumed, err := dm.Start("file1", locations, 2, second, joinShards)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resumed == id {
+		t.Fatal("expected a new session for the resumed download")
+	}
+	s := waitForDownload(t, dm, resumed, func(s DownloadSession) bool { return s.State == DownloadCompleted })
+
+	if s.ShardsResumed < 3 || s.ShardsVerified != cesDataShards {
+		t.Fatalf("expected at least 3 resumed of %d verified shards, got %d of %d", cesDataShards, s.ShardsResumed, s.ShardsVerified)
+	}
+	for i := uint32(0); i < 3; i++ {
+		if refetched[i] {
+			t.Errorf("shard %d was fetched again", i)
+		}
+	}
+	var want []byte
+	for i := uint32(0); i < cesDataShards; i++ {
+		want = append(want, fakeShardBytes(i)...)
+	}
+	if !bytes.Equal(s.Data, want) {
+		t.Fatalf("unexpected data %q", s.Data)
+	}
+	if _, err := os.Stat(filepath.Join(dm.spoolDir, "file1")); !os.IsNotExist(err) {
+		t.Fatal("expected the spool to be removed after completion")
+	}
+}
+
+func TestDownloadSessionKeepsSpoolOnFailure(t *testing.T) {
+	dm := NewDownloadManager(t.TempDir())
+	down := make(map[uint32]bool)
+	for i := uint32(1); i <= cesParityShards+1; i++ {
+		down[i] = true // Too few holders left to reconstruct
+	}
+	_, local := newShardHolders("file2", cesDataShards+cesParityShards, down, nil)
+
+	id, err := dm.Start("file2", downloadLocationsFor(cesDataShards+cesParityShards), 0, shardSource{fetch: local.FetchShard}, joinShards)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := waitForDownload(t, dm, id, func(s DownloadSession) bool { return s.State == DownloadFailed })
+	if s.ShardsVerified != cesDataShards-1 || s.Error == "" || len(s.Fetches) != cesDataShards+cesParityShards {
+		t.Fatalf("unexpected failed session %+v", s)
+	}
+
+	spool := filepath.Join(dm.spoolDir, "file2")
+	if entries, _ := os.ReadDir(spool); len(entries) != cesDataShards-1 {
+		t.Fatalf("expected %d spooled shards, got %d", cesDataShards-1, len(entries))
+	}
+	if err := dm.Cancel(id, false); err == nil {
+		t.Fatal("expected cancelling a finished download to fail")
+	}
+	if err := dm.Cancel(id, true); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for _, err := os.Stat(spool); !os.IsNotExist(err); _, err = os.Stat(spool) {
+		if time.Now().After(deadline) {
+			t.Fatal("expected discard to remove the spool")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if _, err := dm.Start("../escape", nil, 0, shardSource{fetch: local.FetchShard}, joinShards); err == nil {
+		t.Fatal("expected a path in the file hash to be rejected")
+	}
+}
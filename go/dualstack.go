@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/p2p/net/swarm"
+	"github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+// ipFamilyDelay is how long dials to the less preferred address family
+// wait behind the preferred one, the Connection Attempt Delay of RFC 8305
+const ipFamilyDelay = 250 * time.Millisecond
+
+// cgnatRange is carrier-grade NAT space (RFC 6598), which net.IP does not
+// count as private but is not reachable from the internet either
+var cgnatRange = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// addrIP returns the IP address a multiaddr starts with, nil for DNS,
+// relay and other non-IP addresses
+func addrIP(addr multiaddr.Multiaddr) net.IP {
+	ip, err := manet.ToIP(addr)
+	if err != nil {
+		return nil
+	}
+	return ip
+}
+
+// addrIPString is addrIP for logging, "unknown" when there is no IP
+func addrIPString(addr multiaddr.Multiaddr) string {
+	if ip := addrIP(addr); ip != nil {
+		return ip.String()
+	}
+	return "unknown"
+}
+
+// isPrivateIP reports whether ip is unreachable from the internet:
+// loopback, RFC 1918 and IPv6 unique local (fc00::/7) ranges, link-local
+// addresses and carrier-grade NAT
+func isPrivateIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsUnspecified() || cgnatRange.Contains(ip)
+}
+
+// dualStackDialRanker returns the swarm's dial ranker with the address
+// families in the given order. libp2p already ranks IPv6 first, happy
+// eyeballs style; preferring IPv4 holds every IPv6 dial back by
+// ipFamilyDelay so IPv4 gets the head start instead.
+func dualStackDialRanker(preferIPv4 bool) network.DialRanker {
+	if !preferIPv4 {
+		return swarm.DefaultDialRanker
+	}
+	return func(addrs []multiaddr.Multiaddr) []network.AddrDelay {
+		var v4, other []multiaddr.Multiaddr
+		for _, addr := range addrs {
+			if ip := addrIP(addr); ip != nil && ip.To4() == nil {
+				other = append(other, addr)
+			} else {
+				v4 = append(v4, addr)
+			}
+		}
+		ranked := swarm.DefaultDialRanker(v4)
+		delay := time.Duration(0)
+		if len(v4) > 0 {
+			delay = ipFamilyDelay
+		}
+		for _, d := range swarm.DefaultDialRanker(other) {
+			d.Delay += delay
+			ranked = append(ranked, d)
+		}
+		return ranked
+	}
+}
+
+// listenIPs returns the multiaddr prefixes to listen on: the IPv4 one, and
+// the IPv6 one unless disabled. Listeners on a host without IPv6 just fail.
+func listenIPs(v4, v6 string, disableIPv6 bool) []string {
+	if disableIPv6 {
+		return []string{v4}
+	}
+	return []string{v4, v6}
+}
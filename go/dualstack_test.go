@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+)
+
+func TestDualStack(t *testing.T) {
+	for ip, private := range map[string]bool{
+		"127.0.0.1": true, "10.1.2.3": true, "172.20.0.1": true, "192.168.1.5": true,
+		"100.72.0.1": true, "169.254.1.1": true, "::1": true, "fd12:3456::1": true, "fe80::1": true,
+		"8.8.8.8": false, "172.32.0.1": false, "110.0.0.1": false, "2001:4860:4860::8888": false,
+	} {
+		if got := isPrivateIP(net.ParseIP(ip)); got != private {
+			t.Errorf("isPrivateIP(%s) = %v, want %v", ip, got, private)
+		}
+	}
+
+	// Preferring IPv4 holds IPv6 dials back behind the IPv4 ones
+	v4 := multiaddr.StringCast("/ip4/8.8.8.8/tcp/4001")
+	v6 := multiaddr.StringCast("/ip6/2001:4860:4860::8888/tcp/4001")
+	delays := map[string]time.Duration{}
+	for _, d := range dualStackDialRanker(true)([]multiaddr.Multiaddr{v6, v4}) {
+		delays[d.Addr.String()] = d.Delay
+	}
+	if delays[v4.String()] != 0 || delays[v6.String()] < ipFamilyDelay {
+		t.Fatalf("IPv4 preference not applied: %v", delays)
+	}
+	if got := dualStackDialRanker(true)([]multiaddr.Multiaddr{v6}); len(got) != 1 || got[0].Delay != 0 {
+		t.Fatalf("a lone IPv6 address must be dialed at once: %v", got)
+	}
+
+	// Nodes listen on both families and connect over IPv6
+	newNode := func(id uint32, port int) *LibP2PPangeaNode {
+		n, err := NewLibP2PPangeaNodeWithNetworkOptions(id, NewNodeStore(), false, true, port, NetworkOptions{})
+		if err != nil {
+			t.Fatalf("failed to create node %d: %v", id, err)
+		}
+		t.Cleanup(n.cancel)
+		return n
+	}
+	a, b := newNode(620, 12620), newNode(621, 12621)
+	listensV6 := false
+	for _, addr := range a.host.Network().ListenAddresses() {
+		if ip := addrIP(addr); ip != nil && ip.To4() == nil {
+			listensV6 = true
+		}
+	}
+	if !listensV6 {
+		t.Skipf("no IPv6 listener: %v", a.host.Network().ListenAddresses())
+	}
+	v6Addr := multiaddr.StringCast("/ip6/::1/tcp/12620")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := b.host.Connect(ctx, peer.AddrInfo{ID: a.host.ID(), Addrs: []multiaddr.Multiaddr{v6Addr}}); err != nil {
+		t.Fatalf("IPv6 connect failed: %v", err)
+	}
+	if got := addrIPString(b.host.Network().ConnsToPeer(a.host.ID())[0].RemoteMultiaddr()); got != "::1" {
+		t.Fatalf("expected an IPv6 connection, got %s", got)
+	}
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// EventType identifies a kind of node event
+type EventType string
+
+const (
+	EventPeerConnected    EventType = "peer_connected"
+	EventPeerDisconnected EventType = "peer_disconnected"
+	EventShardStored      EventType = "shard_stored"
+	EventJobFinished      EventType = "job_finished" // Completed, failed or cancelled; see the "status" attribute
+	EventChatReceived     EventType = "chat_received"
+	EventNATChanged       EventType = "nat_changed"
+	EventPeerKeyChanged   EventType = "peer_key_changed" // A verified peer presented a different identity key
+	EventShardsDropped    EventType = "shards_dropped"   // Held shards of a file were expired or evicted; see the "reason" attribute
+)
+
+// EventTypes lists every event type
+var EventTypes = []EventType{
+	EventPeerConnected, EventPeerDisconnected, EventShardStored,
+	EventJobFinished, EventChatReceived, EventNATChanged, EventPeerKeyChanged,
+	EventShardsDropped,
+}
+
+const (
+	// eventBufferSize bounds the events queued for a slow subscriber or
+	// webhook; further events are dropped and counted
+	eventBufferSize = 256
+
+	// Webhook delivery attempts and the timeout of each
+	webhookAttempts = 3
+	webhookTimeout  = 10 * time.Second
+)
+
+// NodeEvent is something that happened on this node
+type NodeEvent struct {
+	Seq        uint64            `json:"seq"`
+	Type       EventType         `json:"type"`
+	Time       time.Time         `json:"time"`
+	PeerID     string            `json:"peerId,omitempty"`  // Peer involved, if any
+	Subject    string            `json:"subject,omitempty"` // File hash, job ID or chat message/session ID
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// EventSubscription receives the events of the types it subscribed to
+type EventSubscription struct {
+	ID      uint64
+	C       <-chan NodeEvent
+	ch      chan NodeEvent
+	types   []EventType // Empty = all types
+	dropped uint64
+}
+
+// Webhook delivers events to an HTTP endpoint as JSON POSTs. If a secret is
+// set, the body is signed with HMAC-SHA256 in the X-Pangea-Signature header.
+type Webhook struct {
+	ID        string
+	URL       string
+	Types     []EventType // Empty = all types
+	Delivered uint64
+	Failed    uint64 // Events not delivered after retries, or dropped
+
+	secret string
+	queue  chan NodeEvent
+	cancel context.CancelFunc
+}
+
+// EventBus fans node events out to subscribers and webhooks. Publishing
+// never blocks: a subscriber or webhook that falls behind loses events.
+type EventBus struct {
+	ctx      context.Context
+	seq      uint64 // Last event sequence number
+	nextSub  uint64
+	subs     map[uint64]*EventSubscription
+	webhooks map[string]*Webhook
+	client   *http.Client
+	mu       sync.Mutex
+}
+
+// NewEventBus creates an event bus. Webhook delivery stops when ctx ends.
+func NewEventBus(ctx context.Context) *EventBus {
+	return &EventBus{
+		ctx:      ctx,
+		subs:     make(map[uint64]*EventSubscription),
+		webhooks: make(map[string]*Webhook),
+		client:   &http.Client{Timeout: webhookTimeout},
+	}
+}
+
+// Publish sends an event to every matching subscriber and webhook. A nil
+// bus discards events.
+func (b *EventBus) Publish(evt NodeEvent) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.seq++
+	evt.Seq = b.seq
+	if evt.Time.IsZero() {
+		evt.Time = time.Now()
+	}
+
+	for _, sub := range b.subs {
+		if !matchesEventType(sub.types, evt.Type) {
+			continue
+		}
+		select {
+		case sub.ch <- evt:
+		default:
+			sub.dropped++
+		}
+	}
+	for _, wh := range b.webhooks {
+		if !matchesEventType(wh.Types, evt.Type) {
+			continue
+		}
+		select {
+		case wh.queue <- evt:
+		default:
+			wh.Failed++
+		}
+	}
+}
+
+// Subscribe returns a subscription to events of the given types, or of all
+// types if none are given
+func (b *EventBus) Subscribe(types ...EventType) (*EventSubscription, error) {
+	if err := validateEventTypes(types); err != nil {
+		return nil, err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.nextSub++
+	ch := make(chan NodeEvent, eventBufferSize)
+	sub := &EventSubscription{ID: b.nextSub, C: ch, ch: ch, types: types}
+	b.subs[sub.ID] = sub
+	return sub, nil
+}
+
+// Unsubscribe ends a subscription and closes its channel
+func (b *EventBus) Unsubscribe(id uint64) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	sub, ok := b.subs[id]
+	if !ok {
+		return false
+	}
+	delete(b.subs, id)
+	close(sub.ch)
+	if sub.dropped > 0 {
+		log.Printf("⚠️  [EVENTS] Subscription %d dropped %d events", id, sub.dropped)
+	}
+	return true
+}
+
+// AddWebhook starts delivering events of the given types, or of all types
+// if none are given, to an HTTP(S) URL
+func (b *EventBus) AddWebhook(rawURL, secret string, types ...EventType) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("webhook URL must be an http or https URL")
+	}
+	if err := validateEventTypes(types); err != nil {
+		return "", err
+	}
+
+	id := make([]byte, 8)
+	rand.Read(id)
+	ctx, cancel := context.WithCancel(b.ctx)
+	wh := &Webhook{
+		ID:     "wh-" + hex.EncodeToString(id),
+		URL:    rawURL,
+		Types:  types,
+		secret: secret,
+		queue:  make(chan NodeEvent, eventBufferSize),
+		cancel: cancel,
+	}
+
+	b.mu.Lock()
+	b.webhooks[wh.ID] = wh
+	b.mu.Unlock()
+	go b.deliver(ctx, wh)
+	log.Printf("🪝 [EVENTS] Webhook %s delivering to %s", wh.ID, u.Host)
+	return wh.ID, nil
+}
+
+// RemoveWebhook stops a webhook. Events still queued for it are discarded.
+func (b *EventBus) RemoveWebhook(id string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	wh, ok := b.webhooks[id]
+	if !ok {
+		return false
+	}
+	wh.cancel()
+	delete(b.webhooks, id)
+	return true
+}
+
+// Webhooks returns the registered webhooks
+func (b *EventBus) Webhooks() []Webhook {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out := make([]Webhook, 0, len(b.webhooks))
+	for _, wh := range b.webhooks {
+		out = append(out, Webhook{ID: wh.ID, URL: wh.URL, Types: wh.Types, Delivered: wh.Delivered, Failed: wh.Failed})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// deliver posts a webhook's events in order until ctx ends
+func (b *EventBus) deliver(ctx context.Context, wh *Webhook) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case evt := <-wh.queue:
+			err := b.post(ctx, wh, evt)
+			b.mu.Lock()
+			if err == nil {
+				wh.Delivered++
+			} else {
+				wh.Failed++
+			}
+			b.mu.Unlock()
+			if err != nil && ctx.Err() == nil {
+				log.Printf("⚠️  [EVENTS] Webhook %s failed to deliver event %d: %v", wh.ID, evt.Seq, err)
+			}
+		}
+	}
+}
+
+// post delivers one event, retrying with backoff
+func (b *EventBus) post(ctx context.Context, wh *Webhook, evt NodeEvent) error {
+	body, err := json.Marshal(evt)
+	if err != nil {
+		return err
+	}
+	backoff := time.Second
+	for attempt := 1; ; attempt++ {
+		err = b.postOnce(ctx, wh, evt.Type, body)
+		if err == nil || attempt == webhookAttempts {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (b *EventBus) postOnce(ctx context.Context, wh *Webhook, eventType EventType, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wh.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Pangea-Event", string(eventType))
+	if wh.secret != "" {
+		mac := hmac.New(sha256.New, []byte(wh.secret))
+		mac.Write(body)
+		req.Header.Set("X-Pangea-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("endpoint answered %s", resp.Status)
+	}
+	return nil
+}
+
+// matchesEventType reports whether a filter admits an event type
+func matchesEventType(types []EventType, t EventType) bool {
+	return len(types) == 0 || slices.Contains(types, t)
+}
+
+// validateEventTypes rejects unknown event types
+func validateEventTypes(types []EventType) error {
+	for _, t := range types {
+		if !slices.Contains(EventTypes, t) {
+			return fmt.Errorf("unknown event type %q", t)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestEventBusSubscriptionFilters(t *testing.T) {
+	bus := NewEventBus(context.Background())
+	if _, err := bus.Subscribe("bogus"); err == nil {
+		t.Fatal("expected unknown event type to be rejected")
+	}
+
+	jobs, err := bus.Subscribe(EventJobFinished)
+	if err != nil {
+		t.Fatal(err)
+	}
+	all, err := bus.Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bus.Publish(NodeEvent{Type: EventPeerConnected, PeerID: "peer-a"})
+	bus.Publish(NodeEvent{Type: EventJobFinished, Subject: "job-1", Attributes: map[string]string{"status": "COMPLETED"}})
+
+	evt := <-jobs.C
+	if evt.Subject != "job-1" || evt.Seq != 2 || evt.Time.IsZero() {
+		t.Fatalf("unexpected job event: %+v", evt)
+	}
+	if len(jobs.C) != 0 {
+		t.Fatal("filtered subscription received an unrelated event")
+	}
+	if len(all.C) != 2 {
+		t.Fatalf("expected 2 events for unfiltered subscription, got %d", len(all.C))
+	}
+
+	if !bus.Unsubscribe(jobs.ID) || bus.Unsubscribe(jobs.ID) {
+		t.Fatal("unsubscribe should succeed exactly once")
+	}
+	if _, ok := <-jobs.C; ok {
+		t.Fatal("expected channel closed after unsubscribe")
+	}
+
+	// A subscriber that stops reading loses events instead of blocking
+	for range eventBufferSize + 10 {
+		bus.Publish(NodeEvent{Type: EventShardStored})
+	}
+	if all.dropped == 0 {
+		t.Fatal("expected overflow events to be dropped")
+	}
+}
+
+func TestEventBusWebhookSignsAndRetries(t *testing.T) {
+	const secret = "s3cret"
+	var calls atomic.Int32
+	received := make(chan NodeEvent, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		if r.Header.Get("X-Pangea-Signature") != "sha256="+hex.EncodeToString(mac.Sum(nil)) {
+			t.Errorf("bad signature header %q", r.Header.Get("X-Pangea-Signature"))
+		}
+		if r.Header.Get("X-Pangea-Event") != string(EventNATChanged) {
+			t.Errorf("bad event header %q", r.Header.Get("X-Pangea-Event"))
+		}
+		var evt NodeEvent
+		if err := json.Unmarshal(body, &evt); err != nil {
+			t.Errorf("bad body: %v", err)
+		}
+		received <- evt
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bus := NewEventBus(ctx)
+	if _, err := bus.AddWebhook("ftp://example.com", ""); err == nil {
+		t.Fatal("expected non-HTTP URL to be rejected")
+	}
+	id, err := bus.AddWebhook(srv.URL, secret, EventNATChanged)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bus.Publish(NodeEvent{Type: EventChatReceived})
+	bus.Publish(NodeEvent{Type: EventNATChanged, Attributes: map[string]string{"reachability": "public"}})
+
+	select {
+	case evt := <-received:
+		if evt.Type != EventNATChanged || evt.Attributes["reachability"] != "public" {
+			t.Fatalf("unexpected event delivered: %+v", evt)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook was not delivered")
+	}
+	if calls.Load() != 2 {
+		t.Fatalf("expected one failed and one successful attempt, got %d calls", calls.Load())
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for bus.Webhooks()[0].Delivered != 1 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if hooks := bus.Webhooks(); hooks[0].ID != id || hooks[0].Delivered != 1 || hooks[0].Failed != 0 {
+		t.Fatalf("unexpected webhook stats: %+v", hooks[0])
+	}
+	if !bus.RemoveWebhook(id) || len(bus.Webhooks()) != 0 {
+		t.Fatal("expected webhook removed")
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// errInjectedFault is returned by operations a fault injector failed on
+// purpose
+var errInjectedFault = errors.New("injected fault")
+
+// FaultSettings selects the faults a node injects into its own traffic. Rates
+// are fractions from 0 (never) to 1 (always).
+type FaultSettings struct {
+	ShardFetchDropRate   float64       // Shard fetches from peers that fail before dialing
+	ComputeDelay         time.Duration // Added before answering a delegated compute task
+	ComputeDelayRate     float64       // Compute responses that get ComputeDelay
+	StreamKillRate       float64       // Outgoing file transfer streams reset mid-transfer
+	StreamKillAfterBytes uint64        // Bytes a killed stream sends first; 0 = one chunk
+	Seed                 int64         // Seeds the fault choices for reproducible runs; 0 = random
+}
+
+// Validate checks that rates are fractions
+func (c FaultSettings) Validate() error {
+	for name, rate := range map[string]float64{
+		"shard fetch drop rate": c.ShardFetchDropRate,
+		"compute delay rate":    c.ComputeDelayRate,
+		"stream kill rate":      c.StreamKillRate,
+	} {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("%s %v is not between 0 and 1", name, rate)
+		}
+	}
+	if c.ComputeDelay < 0 {
+		return fmt.Errorf("compute delay %v is negative", c.ComputeDelay)
+	}
+	return nil
+}
+
+// FaultCounts counts the faults injected since the config was last set
+type FaultCounts struct {
+	ShardFetchesDropped     uint64
+	ComputeResponsesDelayed uint64
+	StreamsKilled           uint64
+}
+
+// FaultInjector fails a node's own shard fetches, delays its compute
+// responses and resets its file transfer streams, so retry, failover and
+// repair paths can be exercised against real nodes. It is for tests only:
+// faults can only be configured once Enable has been called, which the node
+// does only when started with -chaos. A nil injector injects nothing.
+type FaultInjector struct {
+	mu      sync.Mutex
+	enabled bool
+	config  FaultSettings
+	rng     *rand.Rand
+	stats   FaultCounts
+}
+
+// NewFaultInjector creates a disabled injector
+func NewFaultInjector() *FaultInjector {
+	return &FaultInjector{rng: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+// Enable allows faults to be configured
+func (f *FaultInjector) Enable() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.enabled = true
+}
+
+// Enabled reports whether faults can be configured
+func (f *FaultInjector) Enabled() bool {
+	if f == nil {
+		return false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.enabled
+}
+
+// Configure replaces the injected faults and resets the counters. A zero
+// config stops injecting.
+func (f *FaultInjector) Configure(config FaultSettings) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.enabled {
+		return errors.New("fault injection is disabled; start the node with -chaos")
+	}
+	seed := config.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	f.config, f.stats = config, FaultCounts{}
+	f.rng = rand.New(rand.NewSource(seed))
+	log.Printf("💥 Fault injection set: %+v", config)
+	return nil
+}
+
+// Snapshot returns the current config and counters
+func (f *FaultInjector) Snapshot() (FaultSettings, FaultCounts) {
+	if f == nil {
+		return FaultSettings{}, FaultCounts{}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.config, f.stats
+}
+
+// rollLocked reports whether a fault with the given rate fires. Caller must
+// hold f.mu.
+func (f *FaultInjector) rollLocked(rate float64) bool {
+	return rate > 0 && f.rng.Float64() < rate
+}
+
+// DropShardFetch returns errInjectedFault for the fetches chosen to fail
+func (f *FaultInjector) DropShardFetch(peerID uint32, fileHash string, shardIndex uint32) error {
+	if f == nil {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.rollLocked(f.config.ShardFetchDropRate) {
+		return nil
+	}
+	f.stats.ShardFetchesDropped++
+	return fmt.Errorf("%w: fetch of shard %d of %s from peer %d dropped", errInjectedFault, shardIndex, fileHash, peerID)
+}
+
+// DelayComputeResponse holds back the responses chosen to be delayed until
+// the delay passes or ctx ends
+func (f *FaultInjector) DelayComputeResponse(ctx context.Context) {
+	if f == nil {
+		return
+	}
+	f.mu.Lock()
+	delay := f.config.ComputeDelay
+	fire := delay > 0 && f.rollLocked(f.config.ComputeDelayRate)
+	if fire {
+		f.stats.ComputeResponsesDelayed++
+	}
+	f.mu.Unlock()
+	if fire {
+		sleepCtx(ctx, delay)
+	}
+}
+
+// StreamKill reports whether an outgoing stream is to be reset and after
+// how many bytes; 0 means after its first chunk
+func (f *FaultInjector) StreamKill() (uint64, bool) {
+	if f == nil {
+		return 0, false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.rollLocked(f.config.StreamKillRate) {
+		return 0, false
+	}
+	return f.config.StreamKillAfterBytes, true
+}
+
+// recordStreamKilled counts a stream reset by StreamKill
+func (f *FaultInjector) recordStreamKilled() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.stats.StreamsKilled++
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestFaultInjectorConfig(t *testing.T) {
+	f := NewFaultInjector()
+	if err := f.Configure(FaultSettings{ShardFetchDropRate: 1}); err == nil {
+		t.Fatal("expected a disabled injector to refuse faults")
+	}
+	f.Enable()
+	if err := f.Configure(FaultSettings{StreamKillRate: 1.5}); err == nil {
+		t.Fatal("expected a rate above 1 to be refused")
+	}
+
+	// The same seed picks the same fetches to drop
+	rolls := func() []bool {
+		if err := f.Configure(FaultSettings{ShardFetchDropRate: 0.5, Seed: 42}); err != nil {
+			t.Fatal(err)
+		}
+		var out []bool
+		for i := 0; i < 32; i++ {
+			out = append(out, f.DropShardFetch(1, "file", uint32(i)) != nil)
+		}
+		return out
+	}
+	first, second := rolls(), rolls()
+	var dropped int
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("seeded runs differ at fetch %d", i)
+		}
+		if first[i] {
+			dropped++
+		}
+	}
+	if dropped == 0 || dropped == len(first) {
+		t.Fatalf("expected about half the fetches dropped, got %d of %d", dropped, len(first))
+	}
+	if _, stats := f.Snapshot(); stats.ShardFetchesDropped != uint64(dropped) {
+		t.Fatalf("counted %d drops, want %d", stats.ShardFetchesDropped, dropped)
+	}
+
+	if err := f.Configure(FaultSettings{ComputeDelay: 50 * time.Millisecond, ComputeDelayRate: 1}); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	f.DelayComputeResponse(context.Background())
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Fatalf("compute response delayed only %v", elapsed)
+	}
+
+	// A nil injector, as in services built without a node, injects nothing
+	var none *FaultInjector
+	if none.DropShardFetch(1, "file", 0) != nil || none.Enabled() {
+		t.Fatal("nil injector injected a fault")
+	}
+	if _, kill := none.StreamKill(); kill {
+		t.Fatal("nil injector killed a stream")
+	}
+}
+
+func TestFaultInjectionExercisesRetryPaths(t *testing.T) {
+	var nodes []*LibP2PPangeaNode
+	for i := 0; i < 3; i++ {
+		n, err := NewLibP2PPangeaNodeWithOptions(uint32(660+i), NewNodeStore(), false, true, 12660+i)
+		if err != nil {
+			t.Fatalf("failed to create node %d: %v", i, err)
+		}
+		defer n.cancel()
+		nodes = append(nodes, n)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	for _, n := range nodes[1:] {
+		if err := nodes[0].host.Connect(ctx, peer.AddrInfo{ID: n.host.ID(), Addrs: n.host.Addrs()}); err != nil {
+			t.Fatalf("connect failed: %v", err)
+		}
+	}
+	faults := nodes[0].GetFaultInjector()
+	faults.Enable()
+
+	// Dropped shard fetches fail over to the next holder, and fail the
+	// shard once every holder was dropped
+	store := NewNodeStore()
+	adapter := NewLibP2PAdapter(nodes[0], store)
+	shard := []byte("chaos shard")
+	var holders []shardPlacement
+	for i, n := range nodes[1:] {
+		id := uint32(2 + i)
+		adapter.peerIDToUint32[n.host.ID().String()] = id
+		adapter.uint32ToPeerID[id] = n.host.ID().String()
+		if err := n.StoreShard("chaos-file", 0, shard); err != nil {
+			t.Fatal(err)
+		}
+		holders = append(holders, shardPlacement{shardIndex: 0, peerID: id, shardHash: fmt.Sprintf("%x", sha256.Sum256(shard))})
+	}
+	src := shardSource{fetch: adapter.FetchShard}
+
+	if err := faults.Configure(FaultSettings{ShardFetchDropRate: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, fetch := fetchShardWithFailover(ctx, src, "chaos-file", 0, holders); fetch.verified || fetch.attempts != 2 || !errors.Is(fetch.err, errInjectedFault) {
+		t.Fatalf("expected both holders dropped, got %+v", fetch)
+	}
+	if _, stats := faults.Snapshot(); stats.ShardFetchesDropped != 2 {
+		t.Fatalf("expected 2 dropped fetches, got %d", stats.ShardFetchesDropped)
+	}
+	if err := faults.Configure(FaultSettings{}); err != nil {
+		t.Fatal(err)
+	}
+	if data, fetch := fetchShardWithFailover(ctx, src, "chaos-file", 0, holders); !fetch.verified || !bytes.Equal(data, shard) {
+		t.Fatalf("fetch failed without faults: %+v", fetch)
+	}
+
+	// A file transfer reset mid-stream pauses, and resumes from whatever
+	// chunks arrived before the reset
+	dir := t.TempDir()
+	content := make([]byte, 3*FileChunkSize)
+	rand.Read(content)
+	path := filepath.Join(dir, "payload.bin")
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := faults.Configure(FaultSettings{StreamKillRate: 1}); err != nil {
+		t.Fatal(err)
+	}
+	sender, receiver := nodes[0].GetFileTransferService(), nodes[1].GetFileTransferService()
+	id, err := sender.SendFile(ctx, nodes[1].host.ID().String(), path)
+	if err != nil {
+		t.Fatalf("SendFile failed: %v", err)
+	}
+	dest := filepath.Join(dir, "received.bin")
+	if err := receiver.AcceptFile(id, dest); err != nil {
+		t.Fatalf("AcceptFile failed: %v", err)
+	}
+	wait := func(state string) FileTransfer {
+		t.Helper()
+		deadline := time.Now().Add(10 * time.Second)
+		for {
+			ft, _ := receiver.Transfer(id)
+			if ft.State == state {
+				return ft
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("transfer is %s (%s), expected %s", ft.State, ft.Error, state)
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+	if ft := wait(TransferPaused); ft.Transferred >= ft.Size {
+		t.Fatalf("expected the stream killed mid-transfer, got %d of %d bytes", ft.Transferred, ft.Size)
+	}
+	if _, stats := faults.Snapshot(); stats.StreamsKilled != 1 {
+		t.Fatalf("expected 1 killed stream, got %d", stats.StreamsKilled)
+	}
+
+	if err := faults.Configure(FaultSettings{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := receiver.ResumeTransfer(id); err != nil {
+		t.Fatalf("ResumeTransfer failed: %v", err)
+	}
+	wait(TransferCompleted)
+	if got, err := os.ReadFile(dest); err != nil || !bytes.Equal(got, content) {
+		t.Fatalf("resumed file differs from the original (err %v)", err)
+	}
+}
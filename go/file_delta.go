@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+const (
+	// defaultDeltaBlockSize is the block size delta uploads match on when
+	// the request does not give one
+	defaultDeltaBlockSize = 4096
+	minDeltaBlockSize     = 64
+	maxDeltaBlockSize     = 1 << 20
+
+	// deltaFullRatio is the share of changed bytes above which a new version
+	// is stored whole. Full versions also end the chain of patches a
+	// download has to apply.
+	deltaFullRatio = 0.5
+)
+
+// fileVersion is one version of a file stored with delta uploads. A full
+// version keeps the whole file in its blob; any other keeps only the bytes
+// that changed, and is rebuilt by applying its ops to the version before.
+type fileVersion struct {
+	FileHash   string // SHA-256 of the version's content
+	Size       uint64
+	Full       bool
+	BlobHash   string // SHA-256 of the blob, also its CES file hash; empty if nothing was stored
+	BlobSize   uint64
+	Placements []shardPlacement
+	Ops        []deltaOp
+	Created    int64 // Unix seconds
+}
+
+// deltaOp appends Length bytes to a version being rebuilt, from Offset in
+// the previous version when FromBase is set and from Offset in the
+// version's blob otherwise
+type deltaOp struct {
+	FromBase bool
+	Offset   uint64
+	Length   uint64
+}
+
+// rollingSum is the rsync weak checksum of a block, which can slide along
+// the data a byte at a time
+type rollingSum struct {
+	a, b uint32
+}
+
+func newRollingSum(block []byte) rollingSum {
+	var r rollingSum
+	n := uint32(len(block))
+	for i, c := range block {
+		r.a += uint32(c)
+		r.b += (n - uint32(i)) * uint32(c)
+	}
+	return r
+}
+
+func (r rollingSum) sum() uint32 {
+	return r.a&0xffff | r.b<<16
+}
+
+// roll slides an n-byte window one byte on, dropping out and taking in
+func (r *rollingSum) roll(out, in byte, n int) {
+	r.a += uint32(in) - uint32(out)
+	r.b += r.a - uint32(n)*uint32(out)
+}
+
+// computeDelta expresses data as copies of blockSize-aligned blocks of base
+// and literal bytes, returning the ops and the literals they refer to.
+// Matches found by the weak checksum are confirmed byte for byte.
+func computeDelta(base, data []byte, blockSize int) ([]deltaOp, []byte) {
+	var ops []deltaOp
+	var literals []byte
+	emit := func(fromBase bool, offset, length uint64) {
+		if n := len(ops); n > 0 && ops[n-1].FromBase == fromBase && ops[n-1].Offset+ops[n-1].Length == offset {
+			ops[n-1].Length += length
+			return
+		}
+		ops = append(ops, deltaOp{FromBase: fromBase, Offset: offset, Length: length})
+	}
+	literal := func(b []byte) {
+		emit(false, uint64(len(literals)), uint64(len(b)))
+		literals = append(literals, b...)
+	}
+
+	blocks := make(map[uint32][]int) // Weak checksum -> offsets of base blocks
+	for off := 0; off+blockSize <= len(base); off += blockSize {
+		sum := newRollingSum(base[off : off+blockSize]).sum()
+		blocks[sum] = append(blocks[sum], off)
+	}
+	if len(blocks) == 0 || len(data) < blockSize {
+		if len(data) > 0 {
+			literal(data)
+		}
+		return ops, literals
+	}
+
+	start := 0 // First byte not yet covered by an op
+	i := 0
+	r := newRollingSum(data[:blockSize])
+	for {
+		if off, ok := matchBlock(base, blocks[r.sum()], data[i:i+blockSize]); ok {
+			if start < i {
+				literal(data[start:i])
+			}
+			emit(true, uint64(off), uint64(blockSize))
+			i += blockSize
+			start = i
+			if i+blockSize > len(data) {
+				break
+			}
+			r = newRollingSum(data[i : i+blockSize])
+			continue
+		}
+		if i+blockSize >= len(data) {
+			break
+		}
+		r.roll(data[i], data[i+blockSize], blockSize)
+		i++
+	}
+	if start < len(data) {
+		literal(data[start:])
+	}
+	return ops, literals
+}
+
+// matchBlock returns the first of the candidate base offsets whose block
+// equals block
+func matchBlock(base []byte, candidates []int, block []byte) (int, bool) {
+	for _, off := range candidates {
+		if bytes.Equal(base[off:off+len(block)], block) {
+			return off, true
+		}
+	}
+	return 0, false
+}
+
+// planVersion lays out the next version of a file, returning it and the
+// blob to store for it. Without a previous version, or when too much
+// changed, the version is stored whole.
+func planVersion(prev []byte, hasPrev bool, data []byte, blockSize int) (fileVersion, []byte) {
+	sum := sha256.Sum256(data)
+	v := fileVersion{FileHash: hex.EncodeToString(sum[:]), Size: uint64(len(data)), Full: true, Created: time.Now().Unix()}
+	blob := data
+	if hasPrev {
+		ops, literals := computeDelta(prev, data, blockSize)
+		if float64(len(literals)) <= deltaFullRatio*float64(len(data)) {
+			v.Full, v.Ops, blob = false, ops, literals
+		}
+	}
+	v.BlobSize = uint64(len(blob))
+	if len(blob) > 0 {
+		blobSum := sha256.Sum256(blob)
+		v.BlobHash = hex.EncodeToString(blobSum[:])
+	}
+	return v, blob
+}
+
+// buildVersion rebuilds a version from its blob and, unless it is full,
+// the previous version, and checks the result against its hash
+func buildVersion(v fileVersion, prev, blob []byte) ([]byte, error) {
+	if uint64(len(blob)) != v.BlobSize {
+		return nil, fmt.Errorf("blob of version %s is %d bytes, expected %d", v.FileHash, len(blob), v.BlobSize)
+	}
+	data := blob
+	if !v.Full {
+		data = make([]byte, 0, v.Size)
+		for _, op := range v.Ops {
+			src := blob
+			if op.FromBase {
+				src = prev
+			}
+			if op.Length > uint64(len(src)) || op.Offset > uint64(len(src))-op.Length ||
+				op.Length > v.Size-uint64(len(data)) {
+				return nil, fmt.Errorf("delta of version %s reaches outside its source", v.FileHash)
+			}
+			data = append(data, src[op.Offset:op.Offset+op.Length]...)
+		}
+	}
+	sum := sha256.Sum256(data)
+	if uint64(len(data)) != v.Size || hex.EncodeToString(sum[:]) != v.FileHash {
+		return nil, fmt.Errorf("rebuilt content does not match version %s", v.FileHash)
+	}
+	return data, nil
+}
+
+// rebuildVersion returns the content of versions[index], fetching blobs
+// from the last full version up to it
+func rebuildVersion(versions []fileVersion, index int, fetch func(fileVersion) ([]byte, error)) ([]byte, error) {
+	if index < 0 || index >= len(versions) {
+		return nil, fmt.Errorf("no version %d; the file has %d", index+1, len(versions))
+	}
+	start := index
+	for start >= 0 && !versions[start].Full {
+		start--
+	}
+	if start < 0 {
+		return nil, fmt.Errorf("version %d has no full version before it", index+1)
+	}
+
+	var data []byte
+	for i := start; i <= index; i++ {
+		v := versions[i]
+		blob := []byte{}
+		if v.BlobSize > 0 {
+			var err error
+			if blob, err = fetch(v); err != nil {
+				return nil, fmt.Errorf("version %d: %w", i+1, err)
+			}
+		}
+		var err error
+		if data, err = buildVersion(v, data, blob); err != nil {
+			return nil, fmt.Errorf("version %d: %w", i+1, err)
+		}
+	}
+	return data, nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestComputeDeltaStoresOnlyChanges(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	base := make([]byte, 64*1024)
+	rng.Read(base)
+
+	// Insert bytes in the middle, overwrite a stretch and append a tail;
+	// the insertion shifts everything after it off block alignment
+	data := append([]byte{}, base[:10000]...)
+	data = append(data, []byte("inserted text")...)
+	data = append(data, base[10000:40000]...)
+	data = append(data, bytes.Repeat([]byte{0xAB}, 500)...)
+	data = append(data, base[40500:]...)
+	data = append(data, []byte("appended")...)
+
+	ops, literals := computeDelta(base, data, 1024)
+	if len(literals) > 4*1024 {
+		t.Fatalf("expected a few KiB of literals, got %d bytes", len(literals))
+	}
+	v, blob := planVersion(base, true, data, 1024)
+	if v.Full || len(v.Ops) != len(ops) || !bytes.Equal(blob, literals) {
+		t.Fatalf("expected a delta version, got full=%v with %d ops", v.Full, len(v.Ops))
+	}
+	rebuilt, err := buildVersion(v, base, blob)
+	if err != nil {
+		t.Fatalf("buildVersion failed: %v", err)
+	}
+	if !bytes.Equal(rebuilt, data) {
+		t.Fatal("rebuilt version differs from the uploaded data")
+	}
+
+	// An op reaching past the previous version is rejected, not panicked on
+	bad := v
+	bad.Ops = append([]deltaOp{{FromBase: true, Offset: uint64(len(base)) - 10, Length: 20}}, v.Ops[1:]...)
+	if _, err := buildVersion(bad, base, blob); err == nil {
+		t.Fatal("expected an out-of-range op to be rejected")
+	}
+}
+
+func TestRebuildVersionChainsFromLastFull(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	contents := make([][]byte, 4)
+	contents[0] = make([]byte, 32*1024)
+	rng.Read(contents[0])
+	contents[1] = append(append([]byte{}, contents[0]...), "v2"...)
+	contents[2] = make([]byte, 8*1024) // Unrelated content is stored whole
+	rng.Read(contents[2])
+	contents[3] = append([]byte("v4"), contents[2]...)
+
+	var versions []fileVersion
+	blobs := make(map[string][]byte)
+	for i, data := range contents {
+		var prev []byte
+		if i > 0 {
+			prev = contents[i-1]
+		}
+		v, blob := planVersion(prev, i > 0, data, 512)
+		if v.BlobHash != "" {
+			blobs[v.BlobHash] = blob
+		}
+		versions = append(versions, v)
+	}
+	if !versions[0].Full || versions[1].Full || !versions[2].Full || versions[3].Full {
+		t.Fatalf("unexpected full flags %v %v %v %v", versions[0].Full, versions[1].Full, versions[2].Full, versions[3].Full)
+	}
+
+	var fetched []string
+	fetch := func(v fileVersion) ([]byte, error) {
+		fetched = append(fetched, v.BlobHash)
+		blob, ok := blobs[v.BlobHash]
+		if !ok {
+			return nil, fmt.Errorf("blob %s missing", v.BlobHash)
+		}
+		return blob, nil
+	}
+	for i, want := range contents {
+		fetched = nil
+		got, err := rebuildVersion(versions, i, fetch)
+		if err != nil {
+			t.Fatalf("version %d: %v", i+1, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("version %d rebuilt wrong", i+1)
+		}
+		if i == 3 && len(fetched) != 2 {
+			t.Fatalf("expected version 4 to start from full version 3, fetched %d blobs", len(fetched))
+		}
+	}
+
+	// A corrupted blob fails the version's hash check
+	blobs[versions[1].BlobHash] = []byte("xx")
+	if _, err := rebuildVersion(versions, 1, fetch); err == nil {
+		t.Fatal("expected a corrupted blob to be detected")
+	}
+	if _, err := rebuildVersion(versions, 4, fetch); err == nil {
+		t.Fatal("expected a missing version to be rejected")
+	}
+}
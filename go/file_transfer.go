@@ -0,0 +1,594 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+const (
+	// FileProtocolID is the libp2p protocol for direct file transfers
+	FileProtocolID = "/pangea/file/1.0.0"
+
+	// FileChunkSize is the size of each transferred chunk
+	FileChunkSize = 256 * 1024
+
+	// fileFrameTimeout bounds waiting for any single frame
+	fileFrameTimeout = 30 * time.Second
+
+	// File frame types
+	fileFrameOffer = "offer" // Sender announces a file
+	fileFramePull  = "pull"  // Receiver asks for the file from an offset
+	fileFrameChunk = "chunk" // One chunk with its hash
+	fileFrameEnd   = "end"   // Sender has sent the last chunk
+	fileFrameDone  = "done"  // Receiver verified (or rejected) the whole file
+	fileFrameAck   = "ack"   // Answer to an offer
+)
+
+// File transfer states
+const (
+	TransferOffered      = "offered"      // Waiting for the receiver to accept
+	TransferTransferring = "transferring" // Chunks are moving
+	TransferPaused       = "paused"       // Stopped; the receiver can resume from its offset
+	TransferCompleted    = "completed"    // Received and verified
+	TransferFailed       = "failed"       // The whole-file hash did not match
+)
+
+// fileFrame is one JSON message on a file stream
+type fileFrame struct {
+	Type       string `json:"type"`
+	TransferID string `json:"transferId"`
+	Name       string `json:"name,omitempty"`
+	Size       uint64 `json:"size,omitempty"`
+	Hash       string `json:"hash,omitempty"` // Whole file (offer) or chunk (chunk), hex SHA-256
+	Offset     uint64 `json:"offset,omitempty"`
+	Data       []byte `json:"data,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// FileTransfer is the state of one transfer on this node
+type FileTransfer struct {
+	ID          string
+	Peer        string
+	Name        string
+	Path        string // Source when sending, destination when receiving
+	Size        uint64
+	Transferred uint64
+	Hash        string
+	Outgoing    bool
+	State       string
+	Error       string
+	Started     time.Time
+	Updated     time.Time
+
+	cancel context.CancelFunc // Stops the running pull (receiving side)
+	done   chan struct{}      // Closed when the running pull has returned
+}
+
+// FileTransferService sends files to peers and receives the files peers
+// offer once they are accepted. Receivers pull chunks from an offset, so a
+// paused or interrupted transfer resumes where the verified data ends.
+type FileTransferService struct {
+	host        host.Host
+	downloadDir string
+	transfers   map[string]*FileTransfer
+	onProgress  func(FileTransfer)
+	faults      *FaultInjector // Test-only stream resets; nil = none
+	mu          sync.Mutex
+}
+
+// NewFileTransferService creates the service and registers its protocol
+// handler. Accepted files without a destination are saved in
+// ~/.pangea/downloads.
+func NewFileTransferService(h host.Host) *FileTransferService {
+	downloadDir := "downloads"
+	if homeDir, err := os.UserHomeDir(); err == nil {
+		downloadDir = filepath.Join(homeDir, ".pangea", "downloads")
+	}
+	fs := &FileTransferService{
+		host:        h,
+		downloadDir: downloadDir,
+		transfers:   make(map[string]*FileTransfer),
+	}
+	h.SetStreamHandler(protocol.ID(FileProtocolID), fs.handleStream)
+	return fs
+}
+
+// SetProgressCallback sets a function called with a transfer's state after
+// every chunk and state change, on both sides
+func (fs *FileTransferService) SetProgressCallback(cb func(FileTransfer)) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.onProgress = cb
+}
+
+// SendFile offers a file to a peer. The peer pulls it once accepted.
+func (fs *FileTransferService) SendFile(ctx context.Context, peerID, path string) (string, error) {
+	p, err := peer.Decode(peerID)
+	if err != nil {
+		return "", fmt.Errorf("invalid peer ID: %w", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("%s is not a regular file", path)
+	}
+	hash, err := hashFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	t := &FileTransfer{
+		ID:       generateTransferID(),
+		Peer:     peerID,
+		Name:     filepath.Base(path),
+		Path:     path,
+		Size:     uint64(info.Size()),
+		Hash:     hash,
+		Outgoing: true,
+		State:    TransferOffered,
+		Started:  time.Now(),
+		Updated:  time.Now(),
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, fileFrameTimeout)
+	defer cancel()
+	s, err := fs.host.NewStream(ctx, p, protocol.ID(FileProtocolID))
+	if err != nil {
+		return "", fmt.Errorf("failed to open file stream: %w", err)
+	}
+	defer s.Close()
+	s.SetDeadline(time.Now().Add(fileFrameTimeout))
+
+	offer := fileFrame{Type: fileFrameOffer, TransferID: t.ID, Name: t.Name, Size: t.Size, Hash: t.Hash}
+	if err := json.NewEncoder(s).Encode(offer); err != nil {
+		return "", fmt.Errorf("failed to send offer: %w", err)
+	}
+	var ack fileFrame
+	if err := json.NewDecoder(s).Decode(&ack); err != nil {
+		return "", fmt.Errorf("no answer to offer: %w", err)
+	}
+	if ack.Error != "" {
+		return "", fmt.Errorf("peer refused offer: %s", ack.Error)
+	}
+
+	fs.mu.Lock()
+	fs.transfers[t.ID] = t
+	fs.mu.Unlock()
+	log.Printf("📤 [FILE] Offered %s (%d bytes) to %s as %s", t.Name, t.Size, shortPeerID(p), t.ID)
+	return t.ID, nil
+}
+
+// AcceptFile starts receiving an offered file into destPath. An empty
+// destPath saves it under the download directory. If a partial download
+// of the file exists at destPath, the transfer resumes from it.
+func (fs *FileTransferService) AcceptFile(transferID, destPath string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	t, err := fs.incomingLocked(transferID)
+	if err != nil {
+		return err
+	}
+	if t.State != TransferOffered {
+		return fmt.Errorf("transfer %s is %s, not offered", transferID, t.State)
+	}
+	if destPath == "" {
+		destPath = filepath.Join(fs.downloadDir, t.Name)
+	}
+	if err := os.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
+		return fmt.Errorf("failed to create destination directory: %w", err)
+	}
+	t.Path = destPath
+	fs.startPullLocked(t)
+	return nil
+}
+
+// PauseTransfer stops receiving a file, keeping what has been verified
+func (fs *FileTransferService) PauseTransfer(transferID string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	t, err := fs.incomingLocked(transferID)
+	if err != nil {
+		return err
+	}
+	if t.State != TransferTransferring {
+		return fmt.Errorf("transfer %s is %s, not transferring", transferID, t.State)
+	}
+	t.cancel()
+	fs.setStateLocked(t, TransferPaused, "")
+	return nil
+}
+
+// ResumeTransfer continues receiving a paused file from its offset
+func (fs *FileTransferService) ResumeTransfer(transferID string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	t, err := fs.incomingLocked(transferID)
+	if err != nil {
+		return err
+	}
+	if t.State != TransferPaused {
+		return fmt.Errorf("transfer %s is %s, not paused", transferID, t.State)
+	}
+	fs.startPullLocked(t)
+	return nil
+}
+
+// Transfer returns a transfer's state
+func (fs *FileTransferService) Transfer(transferID string) (FileTransfer, bool) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	t, ok := fs.transfers[transferID]
+	if !ok {
+		return FileTransfer{}, false
+	}
+	return t.snapshot(), true
+}
+
+// Transfers returns every transfer, newest first
+func (fs *FileTransferService) Transfers() []FileTransfer {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	out := make([]FileTransfer, 0, len(fs.transfers))
+	for _, t := range fs.transfers {
+		out = append(out, t.snapshot())
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Started.After(out[j].Started) })
+	return out
+}
+
+// incomingLocked returns a transfer this node receives. Caller must hold
+// fs.mu.
+func (fs *FileTransferService) incomingLocked(transferID string) (*FileTransfer, error) {
+	t, ok := fs.transfers[transferID]
+	if !ok {
+		return nil, fmt.Errorf("transfer %s not found", transferID)
+	}
+	if t.Outgoing {
+		return nil, fmt.Errorf("transfer %s is controlled by its receiver", transferID)
+	}
+	return t, nil
+}
+
+// startPullLocked runs a pull for a transfer in the background. Caller
+// must hold fs.mu.
+func (fs *FileTransferService) startPullLocked(t *FileTransfer) {
+	ctx, cancel := context.WithCancel(context.Background())
+	prev, done := t.done, make(chan struct{})
+	t.cancel, t.done = cancel, done
+	fs.setStateLocked(t, TransferTransferring, "")
+	go func() {
+		defer close(done)
+		// A paused pull may still be unwinding; it owns the partial file
+		// until it returns
+		if prev != nil {
+			<-prev
+		}
+		fs.pull(ctx, t)
+	}()
+}
+
+// pull receives a file from its verified offset, then checks the whole
+// file and moves it into place
+func (fs *FileTransferService) pull(ctx context.Context, t *FileTransfer) {
+	err := fs.receive(ctx, t)
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	switch {
+	case ctx.Err() != nil:
+		// Paused
+	case errors.Is(err, errFileHashMismatch):
+		os.Remove(t.Path + ".part")
+		fs.setStateLocked(t, TransferFailed, err.Error())
+		log.Printf("❌ [FILE] %s failed verification", t.ID)
+	case err != nil:
+		fs.setStateLocked(t, TransferPaused, err.Error())
+		log.Printf("⚠️  [FILE] %s interrupted at %d/%d bytes: %v", t.ID, t.Transferred, t.Size, err)
+	default:
+		fs.setStateLocked(t, TransferCompleted, "")
+		log.Printf("📥 [FILE] Received %s (%d bytes) into %s", t.Name, t.Size, t.Path)
+	}
+}
+
+var errFileHashMismatch = errors.New("file hash mismatch")
+
+// receive runs one pull over a new stream
+func (fs *FileTransferService) receive(ctx context.Context, t *FileTransfer) error {
+	p, err := peer.Decode(t.Peer)
+	if err != nil {
+		return err
+	}
+	part := t.Path + ".part"
+	f, err := os.OpenFile(part, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// Resume from the last whole chunk already on disk
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	offset := uint64(info.Size())
+	offset -= offset % FileChunkSize
+	if offset > t.Size {
+		offset = 0
+	}
+	if err := f.Truncate(int64(offset)); err != nil {
+		return err
+	}
+	if _, err := f.Seek(int64(offset), io.SeekStart); err != nil {
+		return err
+	}
+	fs.progress(t, offset)
+
+	s, err := fs.host.NewStream(ctx, p, protocol.ID(FileProtocolID))
+	if err != nil {
+		return fmt.Errorf("failed to open file stream: %w", err)
+	}
+	defer s.Close()
+	stop := context.AfterFunc(ctx, func() { s.Reset() })
+	defer stop()
+
+	encoder := json.NewEncoder(s)
+	decoder := json.NewDecoder(s)
+	s.SetDeadline(time.Now().Add(fileFrameTimeout))
+	if err := encoder.Encode(fileFrame{Type: fileFramePull, TransferID: t.ID, Offset: offset}); err != nil {
+		return err
+	}
+
+	for {
+		s.SetDeadline(time.Now().Add(fileFrameTimeout))
+		var frame fileFrame
+		if err := decoder.Decode(&frame); err != nil {
+			return fmt.Errorf("transfer interrupted: %w", err)
+		}
+		if frame.Error != "" {
+			return fmt.Errorf("sender: %s", frame.Error)
+		}
+		if frame.Type == fileFrameEnd {
+			break
+		}
+		if frame.Type != fileFrameChunk || frame.Offset != offset || len(frame.Data) == 0 {
+			return fmt.Errorf("unexpected %s frame at offset %d", frame.Type, frame.Offset)
+		}
+		if sum := sha256.Sum256(frame.Data); hex.EncodeToString(sum[:]) != frame.Hash {
+			return fmt.Errorf("chunk at offset %d failed its hash", offset)
+		}
+		if _, err := f.Write(frame.Data); err != nil {
+			return err
+		}
+		offset += uint64(len(frame.Data))
+		fs.progress(t, offset)
+	}
+
+	verr := fs.finish(f, t, offset)
+	done := fileFrame{Type: fileFrameDone, TransferID: t.ID}
+	if verr != nil {
+		done.Error = verr.Error()
+	}
+	encoder.Encode(done)
+	return verr
+}
+
+// finish checks a fully received file and moves it to its destination
+func (fs *FileTransferService) finish(f *os.File, t *FileTransfer, received uint64) error {
+	if received != t.Size {
+		return fmt.Errorf("%w: received %d of %d bytes", errFileHashMismatch, received, t.Size)
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	hash, err := hashFile(f.Name())
+	if err != nil {
+		return err
+	}
+	if hash != t.Hash {
+		return errFileHashMismatch
+	}
+	return os.Rename(f.Name(), t.Path)
+}
+
+// handleStream answers offers and serves pulls
+func (fs *FileTransferService) handleStream(s network.Stream) {
+	defer s.Close()
+	s.SetDeadline(time.Now().Add(fileFrameTimeout))
+
+	remote := s.Conn().RemotePeer()
+	var frame fileFrame
+	if err := json.NewDecoder(s).Decode(&frame); err != nil {
+		return
+	}
+	switch frame.Type {
+	case fileFrameOffer:
+		ack := fileFrame{Type: fileFrameAck, TransferID: frame.TransferID}
+		if err := fs.offered(remote, &frame); err != nil {
+			ack.Error = err.Error()
+		}
+		json.NewEncoder(s).Encode(ack)
+	case fileFramePull:
+		if err := fs.serve(s, remote, &frame); err != nil {
+			log.Printf("⚠️  [FILE] Serving %s to %s stopped: %v", frame.TransferID, shortPeerID(remote), err)
+		}
+	}
+}
+
+// offered records a file a peer offers to this node
+func (fs *FileTransferService) offered(remote peer.ID, offer *fileFrame) error {
+	name := filepath.Base(offer.Name)
+	if name == "." || name == string(filepath.Separator) || offer.TransferID == "" || offer.Hash == "" {
+		return fmt.Errorf("invalid offer")
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if _, exists := fs.transfers[offer.TransferID]; exists {
+		return fmt.Errorf("transfer %s already exists", offer.TransferID)
+	}
+	t := &FileTransfer{
+		ID:      offer.TransferID,
+		Peer:    remote.String(),
+		Name:    name,
+		Size:    offer.Size,
+		Hash:    offer.Hash,
+		State:   TransferOffered,
+		Started: time.Now(),
+		Updated: time.Now(),
+	}
+	fs.transfers[t.ID] = t
+	fs.notifyLocked(t)
+	log.Printf("📨 [FILE] %s offers %s (%d bytes) as %s", shortPeerID(remote), name, offer.Size, t.ID)
+	return nil
+}
+
+// serve streams a file this node offered, from the requested offset, and
+// records the receiver's verdict
+func (fs *FileTransferService) serve(s network.Stream, remote peer.ID, pull *fileFrame) error {
+	encoder := json.NewEncoder(s)
+	fs.mu.Lock()
+	t, ok := fs.transfers[pull.TransferID]
+	if !ok || !t.Outgoing || t.Peer != remote.String() {
+		fs.mu.Unlock()
+		return encoder.Encode(fileFrame{Type: fileFrameEnd, TransferID: pull.TransferID, Error: "unknown transfer"})
+	}
+	fs.setStateLocked(t, TransferTransferring, "")
+	fs.mu.Unlock()
+
+	err := fs.sendChunks(s, encoder, t, pull.Offset)
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	switch {
+	case err == nil:
+		fs.setStateLocked(t, TransferCompleted, "")
+		log.Printf("📤 [FILE] %s delivered to %s", t.ID, shortPeerID(remote))
+	case errors.Is(err, errFileHashMismatch):
+		fs.setStateLocked(t, TransferFailed, err.Error())
+	default:
+		fs.setStateLocked(t, TransferPaused, err.Error())
+	}
+	return err
+}
+
+// sendChunks sends a file's chunks from offset and waits for the
+// receiver's verdict
+func (fs *FileTransferService) sendChunks(s network.Stream, encoder *json.Encoder, t *FileTransfer, offset uint64) error {
+	f, err := os.Open(t.Path)
+	if err != nil {
+		encoder.Encode(fileFrame{Type: fileFrameEnd, TransferID: t.ID, Error: "file unavailable"})
+		return err
+	}
+	defer f.Close()
+	if offset > t.Size {
+		offset = t.Size
+	}
+	if _, err := f.Seek(int64(offset), io.SeekStart); err != nil {
+		return err
+	}
+
+	killAfter, kill := fs.faults.StreamKill()
+	start := offset
+	buf := make([]byte, FileChunkSize)
+	for offset < t.Size {
+		n, err := io.ReadFull(f, buf[:min(uint64(FileChunkSize), t.Size-offset)])
+		if err != nil {
+			encoder.Encode(fileFrame{Type: fileFrameEnd, TransferID: t.ID, Error: "file changed while sending"})
+			return err
+		}
+		sum := sha256.Sum256(buf[:n])
+		s.SetDeadline(time.Now().Add(fileFrameTimeout))
+		chunk := fileFrame{Type: fileFrameChunk, TransferID: t.ID, Offset: offset, Data: buf[:n], Hash: hex.EncodeToString(sum[:])}
+		if err := encoder.Encode(chunk); err != nil {
+			return err
+		}
+		offset += uint64(n)
+		fs.progress(t, offset)
+		if kill && offset-start >= killAfter && offset < t.Size {
+			s.Reset()
+			fs.faults.recordStreamKilled()
+			return fmt.Errorf("%w: stream reset after %d bytes", errInjectedFault, offset-start)
+		}
+	}
+	if err := encoder.Encode(fileFrame{Type: fileFrameEnd, TransferID: t.ID}); err != nil {
+		return err
+	}
+
+	s.SetDeadline(time.Now().Add(fileFrameTimeout))
+	var done fileFrame
+	if err := json.NewDecoder(s).Decode(&done); err != nil {
+		return fmt.Errorf("no verdict from receiver: %w", err)
+	}
+	if done.Error != "" {
+		return fmt.Errorf("%w: %s", errFileHashMismatch, done.Error)
+	}
+	return nil
+}
+
+// progress records how far a transfer has got
+func (fs *FileTransferService) progress(t *FileTransfer, transferred uint64) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	t.Transferred = transferred
+	t.Updated = time.Now()
+	fs.notifyLocked(t)
+}
+
+// setStateLocked changes a transfer's state. Caller must hold fs.mu.
+func (fs *FileTransferService) setStateLocked(t *FileTransfer, state, errMsg string) {
+	t.State, t.Error, t.Updated = state, errMsg, time.Now()
+	fs.notifyLocked(t)
+}
+
+// notifyLocked reports a transfer's state to the progress callback.
+// Caller must hold fs.mu.
+func (fs *FileTransferService) notifyLocked(t *FileTransfer) {
+	if fs.onProgress != nil {
+		fs.onProgress(t.snapshot())
+	}
+}
+
+// snapshot copies the transfer's exported state
+func (t *FileTransfer) snapshot() FileTransfer {
+	c := *t
+	c.cancel, c.done = nil, nil
+	return c
+}
+
+// hashFile returns the hex SHA-256 of a file
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// generateTransferID returns a random transfer ID
+func generateTransferID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return "xfer-" + hex.EncodeToString(b)
+}
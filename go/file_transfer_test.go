@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestFileTransferResumesAndVerifies(t *testing.T) {
+	n1, err := NewLibP2PPangeaNodeWithOptions(491, NewNodeStore(), false, true, 12490)
+	if err != nil {
+		t.Fatalf("failed to create node1: %v", err)
+	}
+	defer n1.cancel()
+	n2, err := NewLibP2PPangeaNodeWithOptions(492, NewNodeStore(), false, true, 12491)
+	if err != nil {
+		t.Fatalf("failed to create node2: %v", err)
+	}
+	defer n2.cancel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := n1.host.Connect(ctx, peer.AddrInfo{ID: n2.host.ID(), Addrs: n2.host.Addrs()}); err != nil {
+		t.Fatalf("connect n1->n2 failed: %v", err)
+	}
+
+	dir := t.TempDir()
+	content := make([]byte, 3*FileChunkSize+1234)
+	rand.Read(content)
+	src := filepath.Join(dir, "payload.bin")
+	if err := os.WriteFile(src, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var progress []uint64
+	n2.GetFileTransferService().SetProgressCallback(func(ft FileTransfer) {
+		mu.Lock()
+		defer mu.Unlock()
+		progress = append(progress, ft.Transferred)
+	})
+
+	sender, receiver := n1.GetFileTransferService(), n2.GetFileTransferService()
+	send := func() string {
+		id, err := sender.SendFile(ctx, n2.host.ID().String(), src)
+		if err != nil {
+			t.Fatalf("SendFile failed: %v", err)
+		}
+		if ft, ok := receiver.Transfer(id); !ok || ft.State != TransferOffered || ft.Size != uint64(len(content)) {
+			t.Fatalf("receiver did not record the offer: %+v", ft)
+		}
+		return id
+	}
+	wait := func(id, state string) FileTransfer {
+		t.Helper()
+		deadline := time.Now().Add(10 * time.Second)
+		for time.Now().Before(deadline) {
+			if ft, _ := receiver.Transfer(id); ft.State == state {
+				return ft
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		ft, _ := receiver.Transfer(id)
+		t.Fatalf("transfer %s is %s (%s), expected %s", id, ft.State, ft.Error, state)
+		return ft
+	}
+
+	// Resume from a partial download: the first chunk and a half are on disk
+	dest := filepath.Join(dir, "received.bin")
+	if err := os.WriteFile(dest+".part", content[:FileChunkSize+FileChunkSize/2], 0644); err != nil {
+		t.Fatal(err)
+	}
+	id := send()
+	if err := receiver.AcceptFile(id, dest); err != nil {
+		t.Fatalf("AcceptFile failed: %v", err)
+	}
+	// Pausing and resuming must not lose or duplicate data
+	if err := receiver.PauseTransfer(id); err == nil {
+		if err := receiver.ResumeTransfer(id); err != nil {
+			t.Fatalf("ResumeTransfer failed: %v", err)
+		}
+	}
+	wait(id, TransferCompleted)
+	got, err := os.ReadFile(dest)
+	if err != nil || !bytes.Equal(got, content) {
+		t.Fatalf("received file differs from the original (err %v)", err)
+	}
+	if _, err := os.Stat(dest + ".part"); !os.IsNotExist(err) {
+		t.Errorf("partial file left behind")
+	}
+	mu.Lock()
+	if len(progress) == 0 || progress[len(progress)-1] != uint64(len(content)) {
+		t.Errorf("progress did not reach the file size: %v", progress)
+	}
+	for _, p := range progress {
+		if p != 0 && p < FileChunkSize {
+			t.Errorf("resumed transfer restarted below the partial offset: %v", progress)
+			break
+		}
+	}
+	mu.Unlock()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for ft, _ := sender.Transfer(id); ft.State != TransferCompleted; ft, _ = sender.Transfer(id) {
+		if time.Now().After(deadline) {
+			t.Fatalf("sender did not see completion: %+v", ft)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	// A corrupt partial file fails the whole-file hash and is discarded
+	dest = filepath.Join(dir, "corrupt.bin")
+	if err := os.WriteFile(dest+".part", make([]byte, FileChunkSize), 0644); err != nil {
+		t.Fatal(err)
+	}
+	id = send()
+	if err := receiver.AcceptFile(id, dest); err != nil {
+		t.Fatalf("AcceptFile failed: %v", err)
+	}
+	wait(id, TransferFailed)
+	if _, err := os.Stat(dest); !os.IsNotExist(err) {
+		t.Errorf("unverified file was moved into place")
+	}
+
+	// Only the receiver controls a transfer
+	if err := sender.PauseTransfer(id); err == nil {
+		t.Errorf("sender paused a transfer it does not receive")
+	}
+}
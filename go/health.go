@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// HealthState is the health of a node component
+type HealthState string
+
+const (
+	HealthOK       HealthState = "ok"
+	HealthDegraded HealthState = "degraded" // Working with reduced function
+	HealthDown     HealthState = "down"
+)
+
+// HealthCheck reports a component's current state. A non-nil error is
+// recorded as the component's last error.
+type HealthCheck func() (HealthState, error)
+
+// ComponentHealth is the health of one component
+type ComponentHealth struct {
+	Name        string      `json:"name"`
+	State       HealthState `json:"state"`
+	Critical    bool        `json:"critical"` // Must be ok for the node to be ready
+	Since       time.Time   `json:"since"`    // When State last changed
+	LastError   string      `json:"lastError,omitempty"`
+	LastErrorAt time.Time   `json:"lastErrorAt,omitzero"`
+}
+
+// NodeHealth aggregates the health of every component
+type NodeHealth struct {
+	State      HealthState       `json:"state"` // Down if a critical component is down
+	Ready      bool              `json:"ready"` // Every critical component is ok
+	StartedAt  time.Time         `json:"startedAt"`
+	Components []ComponentHealth `json:"components"`
+}
+
+// HealthMonitor tracks per-component health. Components are either polled
+// through a HealthCheck or report their own state.
+type HealthMonitor struct {
+	started    time.Time
+	components map[string]*healthComponent
+	mu         sync.Mutex
+}
+
+type healthComponent struct {
+	check  HealthCheck // Nil for components that report their own state
+	status ComponentHealth
+}
+
+// NewHealthMonitor creates a monitor with no components
+func NewHealthMonitor() *HealthMonitor {
+	return &HealthMonitor{started: time.Now(), components: make(map[string]*healthComponent)}
+}
+
+// Register adds a component. A nil check registers a component that calls
+// Report; it is down until its first report.
+func (h *HealthMonitor) Register(name string, critical bool, check HealthCheck) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.components[name] = &healthComponent{
+		check:  check,
+		status: ComponentHealth{Name: name, State: HealthDown, Critical: critical, Since: time.Now()},
+	}
+}
+
+// Report sets a component's state, registering it as non-critical if it is
+// unknown. A non-nil err is recorded as its last error. Reports to a nil
+// monitor are dropped.
+func (h *HealthMonitor) Report(name string, state HealthState, err error) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	c, ok := h.components[name]
+	if !ok {
+		c = &healthComponent{status: ComponentHealth{Name: name, State: state, Since: time.Now()}}
+		h.components[name] = c
+	}
+	c.update(state, err)
+}
+
+func (c *healthComponent) update(state HealthState, err error) {
+	now := time.Now()
+	if state != c.status.State {
+		c.status.State = state
+		c.status.Since = now
+	}
+	if err != nil {
+		c.status.LastError = err.Error()
+		c.status.LastErrorAt = now
+	}
+}
+
+// Check runs the registered checks and returns the node's health
+func (h *HealthMonitor) Check() NodeHealth {
+	h.mu.Lock()
+	checks := make(map[string]HealthCheck)
+	for name, c := range h.components {
+		if c.check != nil {
+			checks[name] = c.check
+		}
+	}
+	h.mu.Unlock()
+
+	// Checks may call into other subsystems, so run them unlocked
+	type result struct {
+		state HealthState
+		err   error
+	}
+	results := make(map[string]result, len(checks))
+	for name, check := range checks {
+		state, err := check()
+		results[name] = result{state, err}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	health := NodeHealth{State: HealthOK, Ready: true, StartedAt: h.started}
+	for name, c := range h.components {
+		if r, ok := results[name]; ok {
+			c.update(r.state, r.err)
+		}
+		health.Components = append(health.Components, c.status)
+
+		switch {
+		case c.status.State == HealthOK:
+		case c.status.Critical:
+			health.Ready = false
+			if c.status.State == HealthDown {
+				health.State = HealthDown
+			} else if health.State == HealthOK {
+				health.State = HealthDegraded
+			}
+		case health.State == HealthOK:
+			health.State = HealthDegraded
+		}
+	}
+	sort.Slice(health.Components, func(i, j int) bool {
+		return health.Components[i].Name < health.Components[j].Name
+	})
+	return health
+}
+
+// Uptime returns how long the monitor has been running
+func (h *HealthMonitor) Uptime() time.Duration {
+	return time.Since(h.started)
+}
+
+// HealthHandler serves /healthz, failing while a critical component is down,
+// and /readyz, failing until every critical component is ok. Both return
+// the node's health as JSON.
+func HealthHandler(h *HealthMonitor) http.Handler {
+	mux := http.NewServeMux()
+	serve := func(w http.ResponseWriter, ok func(NodeHealth) bool) {
+		health := h.Check()
+		w.Header().Set("Content-Type", "application/json")
+		if !ok(health) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(health)
+	}
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		serve(w, func(health NodeHealth) bool { return health.State != HealthDown })
+	})
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, r *http.Request) {
+		serve(w, func(health NodeHealth) bool { return health.Ready })
+	})
+	return mux
+}
+
+// StartHealthServer serves HealthHandler on address
+func StartHealthServer(address string, h *HealthMonitor) error {
+	return http.ListenAndServe(address, HealthHandler(h))
+}
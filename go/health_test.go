@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHealthMonitorAggregates(t *testing.T) {
+	h := NewHealthMonitor()
+	dhtState := HealthOK
+	h.Register("dht", false, func() (HealthState, error) { return dhtState, nil })
+	h.Register("capnp", true, nil)
+
+	// A critical component is down until it reports
+	health := h.Check()
+	if health.State != HealthDown || health.Ready {
+		t.Fatalf("expected down and not ready before capnp reports, got %+v", health)
+	}
+
+	h.Report("capnp", HealthOK, nil)
+	if health = h.Check(); health.State != HealthOK || !health.Ready {
+		t.Fatalf("expected ok and ready, got %+v", health)
+	}
+
+	// A degraded non-critical component does not affect readiness
+	dhtState = HealthDegraded
+	if health = h.Check(); health.State != HealthDegraded || !health.Ready {
+		t.Fatalf("expected degraded but ready, got %+v", health)
+	}
+
+	h.Report("capnp", HealthDegraded, errors.New("accept: too many open files"))
+	health = h.Check()
+	if health.State != HealthDegraded || health.Ready {
+		t.Fatalf("expected degraded and not ready, got %+v", health)
+	}
+	if len(health.Components) != 2 || health.Components[0].Name != "capnp" {
+		t.Fatalf("expected components sorted by name, got %+v", health.Components)
+	}
+	capnp := health.Components[0]
+	if capnp.LastError != "accept: too many open files" || capnp.LastErrorAt.IsZero() {
+		t.Fatalf("expected last error recorded, got %+v", capnp)
+	}
+
+	// Recovery keeps the last error
+	h.Report("capnp", HealthOK, nil)
+	if capnp = h.Check().Components[0]; capnp.State != HealthOK || capnp.LastError == "" {
+		t.Fatalf("expected recovered with last error kept, got %+v", capnp)
+	}
+
+	var nilMonitor *HealthMonitor
+	nilMonitor.Report("capnp", HealthDown, nil)
+}
+
+func TestHealthHandler(t *testing.T) {
+	h := NewHealthMonitor()
+	h.Register("compute", true, nil)
+	h.Report("mdns", HealthOK, nil)
+	srv := httptest.NewServer(HealthHandler(h))
+	defer srv.Close()
+
+	get := func(path string) (int, NodeHealth) {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var health NodeHealth
+		if err := json.NewDecoder(resp.Body).Decode(&health); err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, health
+	}
+
+	if code, health := get("/healthz"); code != http.StatusServiceUnavailable || health.State != HealthDown {
+		t.Fatalf("expected 503 while compute is down, got %d %+v", code, health)
+	}
+
+	h.Report("compute", HealthDegraded, nil)
+	if code, _ := get("/healthz"); code != http.StatusOK {
+		t.Fatalf("expected degraded node to be live, got %d", code)
+	}
+	if code, _ := get("/readyz"); code != http.StatusServiceUnavailable {
+		t.Fatalf("expected degraded node not ready, got %d", code)
+	}
+
+	h.Report("compute", HealthOK, nil)
+	code, health := get("/readyz")
+	if code != http.StatusOK || len(health.Components) != 2 {
+		t.Fatalf("expected ready with 2 components, got %d %+v", code, health)
+	}
+}
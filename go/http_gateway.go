@@ -0,0 +1,1156 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"capnproto.org/go/capnp/v3"
+)
+
+// openAPISpec documents the HTTP gateway
+//
+//go:embed openapi.yaml
+var openAPISpec []byte
+
+const (
+	// httpMaxBodyBytes bounds request bodies; uploads and compute inputs
+	// arrive base64-encoded inside them
+	httpMaxBodyBytes = 256 << 20
+
+	// httpCallTimeout bounds one gateway request's RPC calls; job result
+	// requests add their own wait on top
+	httpCallTimeout = 60 * time.Second
+)
+
+// HTTPGateway serves a REST/JSON API for clients that do not speak Cap'n
+// Proto. Every endpoint calls the same NodeService implementation the Cap'n
+// Proto server uses, through an in-process client, so both APIs behave
+// identically. Binary fields are base64 in JSON.
+type HTTPGateway struct {
+	client NodeService
+	mux    *http.ServeMux
+}
+
+// NewHTTPGateway creates a gateway over a NodeService implementation
+func NewHTTPGateway(service NodeService_Server) *HTTPGateway {
+	g := &HTTPGateway{
+		client: NodeService_ServerToClient(service),
+		mux:    http.NewServeMux(),
+	}
+	g.mux.HandleFunc("GET /api/v1/openapi.yaml", g.handleOpenAPI)
+	g.mux.HandleFunc("GET /api/v1/status", g.handleStatus)
+	g.mux.HandleFunc("GET /api/v1/status/summary", g.handleSummary)
+	g.mux.HandleFunc("GET /api/v1/peers", g.handlePeers)
+	g.mux.HandleFunc("POST /api/v1/uploads", g.handleUpload)
+	g.mux.HandleFunc("POST /api/v1/downloads", g.handleDownload)
+	g.mux.HandleFunc("POST /api/v1/jobs", g.handleSubmitJob)
+	g.mux.HandleFunc("GET /api/v1/jobs/{id}", g.handleJobStatus)
+	g.mux.HandleFunc("GET /api/v1/jobs/{id}/result", g.handleJobResult)
+	g.mux.HandleFunc("DELETE /api/v1/jobs/{id}", g.handleCancelJob)
+	g.mux.HandleFunc("POST /api/v1/chat/sessions", g.handleStartChat)
+	g.mux.HandleFunc("DELETE /api/v1/chat/sessions/{id}", g.handleCloseChat)
+	g.mux.HandleFunc("GET /api/v1/chat/sessions/{id}/messages", g.handleReceiveChat)
+	g.mux.HandleFunc("POST /api/v1/chat/sessions/{id}/messages", g.handleSendChat)
+	g.mux.HandleFunc("GET /api/v1/webrtc", g.handleWebRTC)
+	return g
+}
+
+// StartHTTPGateway serves the REST/JSON API on address until it fails
+func StartHTTPGateway(address string, service NodeService_Server) error {
+	gateway := NewHTTPGateway(service)
+	defer gateway.Close()
+
+	server := &http.Server{
+		Addr:              address,
+		Handler:           gateway,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	log.Printf("HTTP gateway listening on %s", address)
+	return server.ListenAndServe()
+}
+
+func (g *HTTPGateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, httpMaxBodyBytes)
+	g.mux.ServeHTTP(w, r)
+}
+
+// Close releases the gateway's service client
+func (g *HTTPGateway) Close() {
+	g.client.Release()
+}
+
+// ============================================================
+// Status and Peers
+// ============================================================
+
+type httpNetworkMetrics struct {
+	AvgRttMs      float32 `json:"avgRttMs"`
+	PacketLoss    float32 `json:"packetLoss"`
+	BandwidthMbps float32 `json:"bandwidthMbps"`
+	PeerCount     uint32  `json:"peerCount"`
+	CPUUsage      float32 `json:"cpuUsage"`
+	IOCapacity    float32 `json:"ioCapacity"`
+	UploadMbps    float32 `json:"uploadMbps"`
+	DownloadMbps  float32 `json:"downloadMbps"`
+}
+
+type httpStorageStatus struct {
+	Role       string `json:"role"`
+	UsedBytes  uint64 `json:"usedBytes"`
+	QuotaBytes uint64 `json:"quotaBytes"`
+	ReadOnly   bool   `json:"readOnly"`
+}
+
+type httpStatus struct {
+	LocalMultiaddr string             `json:"localMultiaddr"`
+	Metrics        httpNetworkMetrics `json:"metrics"`
+	Storage        httpStorageStatus  `json:"storage"`
+}
+
+func (g *HTTPGateway) handleStatus(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), httpCallTimeout)
+	defer cancel()
+	var out httpStatus
+
+	addrFuture, releaseAddr := g.client.GetLocalMultiaddr(ctx, nil)
+	defer releaseAddr()
+	metricsFuture, releaseMetrics := g.client.GetNetworkMetrics(ctx, nil)
+	defer releaseMetrics()
+	storageFuture, releaseStorage := g.client.GetStorageStatus(ctx, nil)
+	defer releaseStorage()
+
+	addrResults, err := addrFuture.Struct()
+	if err != nil {
+		writeRPCError(w, err)
+		return
+	}
+	out.LocalMultiaddr, _ = addrResults.Multiaddr()
+
+	metricsResults, err := metricsFuture.Struct()
+	if err != nil {
+		writeRPCError(w, err)
+		return
+	}
+	if m, err := metricsResults.Metrics(); err == nil {
+		out.Metrics = httpNetworkMetrics{
+			AvgRttMs:      m.AvgRttMs(),
+			PacketLoss:    m.PacketLoss(),
+			BandwidthMbps: m.BandwidthMbps(),
+			PeerCount:     m.PeerCount(),
+			CPUUsage:      m.CpuUsage(),
+			IOCapacity:    m.IoCapacity(),
+			UploadMbps:    m.UploadMbps(),
+			DownloadMbps:  m.DownloadMbps(),
+		}
+	}
+
+	storageResults, err := storageFuture.Struct()
+	if err != nil {
+		writeRPCError(w, err)
+		return
+	}
+	if st, err := storageResults.Status(); err == nil {
+		role, _ := st.Role()
+		out.Storage = httpStorageStatus{
+			Role:       role,
+			UsedBytes:  st.UsedBytes(),
+			QuotaBytes: st.QuotaBytes(),
+			ReadOnly:   st.ReadOnly(),
+		}
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+type httpSummaryPeer struct {
+	PeerID   uint32 `json:"peerId"`
+	Libp2pID string `json:"libp2pId,omitempty"`
+	Address  string `json:"address,omitempty"`
+}
+
+type httpSummaryPeers struct {
+	Connected      []httpSummaryPeer `json:"connected"`
+	KnownPeers     uint32            `json:"knownPeers"`
+	ReachablePeers uint32            `json:"reachablePeers"`
+}
+
+type httpSummaryReachability struct {
+	Reachability     string   `json:"reachability"`
+	NATType          string   `json:"natType"`
+	Addrs            []string `json:"addrs"`
+	Partitioned      bool     `json:"partitioned"`
+	PartitionedSince int64    `json:"partitionedSince"`
+}
+
+type httpSummaryStorage struct {
+	httpStorageStatus
+	Shards     uint32 `json:"shards"`
+	ShardBytes uint64 `json:"shardBytes"`
+	Pins       uint32 `json:"pins"`
+}
+
+type httpSummaryJobs struct {
+	Queued    uint32          `json:"queued"`
+	Running   uint32          `json:"running"`
+	Completed uint32          `json:"completed"`
+	Failed    uint32          `json:"failed"`
+	Cancelled uint32          `json:"cancelled"`
+	Active    []httpJobStatus `json:"active"`
+}
+
+type httpSummaryStreams struct {
+	Streaming       bool   `json:"streaming"`
+	FramesSent      uint64 `json:"framesSent"`
+	FramesReceived  uint64 `json:"framesReceived"`
+	BytesSent       uint64 `json:"bytesSent"`
+	BytesReceived   uint64 `json:"bytesReceived"`
+	VideoPeers      uint32 `json:"videoPeers"`
+	VoicePeers      uint32 `json:"voicePeers"`
+	ChatPeers       uint32 `json:"chatPeers"`
+	ChatSessions    uint32 `json:"chatSessions"`
+	ConferenceRooms uint32 `json:"conferenceRooms"`
+	FileTransfers   uint32 `json:"fileTransfers"`
+	Downloads       uint32 `json:"downloads"`
+}
+
+type httpSummaryCapacity struct {
+	CPUCores      uint32    `json:"cpuCores"`
+	RAMMB         uint64    `json:"ramMb"`
+	TotalRAMMB    uint64    `json:"totalRamMb"`
+	CurrentLoad   float32   `json:"currentLoad"`
+	CoreLoads     []float32 `json:"coreLoads"`
+	DiskMB        uint64    `json:"diskMb"`
+	BandwidthMbps float32   `json:"bandwidthMbps"`
+}
+
+// httpSummary leaves out the sections unchanged since the request's since.
+// The version is a string because it does not fit a JSON number exactly.
+type httpSummary struct {
+	Version      uint64                   `json:"version,string"`
+	NodeID       string                   `json:"nodeId"`
+	UptimeSecs   uint64                   `json:"uptimeSecs"`
+	Peers        *httpSummaryPeers        `json:"peers,omitempty"`
+	Reachability *httpSummaryReachability `json:"reachability,omitempty"`
+	Storage      *httpSummaryStorage      `json:"storage,omitempty"`
+	Jobs         *httpSummaryJobs         `json:"jobs,omitempty"`
+	Streams      *httpSummaryStreams      `json:"streams,omitempty"`
+	Capacity     *httpSummaryCapacity     `json:"capacity,omitempty"`
+}
+
+func (g *HTTPGateway) handleSummary(w http.ResponseWriter, r *http.Request) {
+	var since uint64
+	if v := r.URL.Query().Get("since"); v != "" {
+		var err error
+		if since, err = strconv.ParseUint(v, 10, 64); err != nil {
+			writeError(w, http.StatusBadRequest, "since must be a version from a previous summary")
+			return
+		}
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), httpCallTimeout)
+	defer cancel()
+
+	future, release := g.client.GetNodeSummary(ctx, func(p NodeService_getNodeSummary_Params) error {
+		p.SetSinceVersion(since)
+		return nil
+	})
+	defer release()
+	results, err := future.Struct()
+	if err != nil {
+		writeRPCError(w, err)
+		return
+	}
+	s, err := results.Summary()
+	if err != nil {
+		writeRPCError(w, err)
+		return
+	}
+	out := httpSummary{Version: s.Version(), UptimeSecs: s.UptimeSecs()}
+	out.NodeID, _ = s.NodeId()
+
+	if s.HasPeers() {
+		p, _ := s.Peers()
+		peers := &httpSummaryPeers{
+			Connected:      []httpSummaryPeer{},
+			KnownPeers:     p.KnownPeers(),
+			ReachablePeers: p.ReachablePeers(),
+		}
+		connected, _ := p.Connected()
+		for i := 0; i < connected.Len(); i++ {
+			c := connected.At(i)
+			peer := httpSummaryPeer{PeerID: c.PeerId()}
+			peer.Libp2pID, _ = c.Libp2pId()
+			peer.Address, _ = c.Address()
+			peers.Connected = append(peers.Connected, peer)
+		}
+		out.Peers = peers
+	}
+	if s.HasReachability() {
+		reach, _ := s.Reachability()
+		out.Reachability = &httpSummaryReachability{
+			Partitioned:      reach.Partitioned(),
+			PartitionedSince: reach.PartitionedSince(),
+		}
+		out.Reachability.Reachability, _ = reach.Reachability()
+		out.Reachability.NATType, _ = reach.NatType()
+		addrs, _ := reach.Addrs()
+		out.Reachability.Addrs = textListStrings(addrs)
+	}
+	if s.HasStorage() {
+		st, _ := s.Storage()
+		out.Storage = &httpSummaryStorage{
+			httpStorageStatus: httpStorageStatus{
+				UsedBytes:  st.UsedBytes(),
+				QuotaBytes: st.QuotaBytes(),
+				ReadOnly:   st.ReadOnly(),
+			},
+			Shards:     st.Shards(),
+			ShardBytes: st.ShardBytes(),
+			Pins:       st.Pins(),
+		}
+		out.Storage.Role, _ = st.Role()
+	}
+	if s.HasJobs() {
+		j, _ := s.Jobs()
+		jobs := &httpSummaryJobs{
+			Queued:    j.Queued(),
+			Running:   j.Running(),
+			Completed: j.Completed(),
+			Failed:    j.Failed(),
+			Cancelled: j.Cancelled(),
+			Active:    []httpJobStatus{},
+		}
+		active, _ := j.Active()
+		for i := 0; i < active.Len(); i++ {
+			st := active.At(i)
+			job := httpJobStatus{
+				Progress:               st.Progress(),
+				CompletedChunks:        st.CompletedChunks(),
+				TotalChunks:            st.TotalChunks(),
+				EstimatedTimeRemaining: st.EstimatedTimeRemaining(),
+			}
+			job.JobID, _ = st.JobId()
+			job.Status, _ = st.Status()
+			jobs.Active = append(jobs.Active, job)
+		}
+		out.Jobs = jobs
+	}
+	if s.HasStreams() {
+		st, _ := s.Streams()
+		out.Streams = &httpSummaryStreams{
+			Streaming:       st.Streaming(),
+			FramesSent:      st.FramesSent(),
+			FramesReceived:  st.FramesReceived(),
+			BytesSent:       st.BytesSent(),
+			BytesReceived:   st.BytesReceived(),
+			VideoPeers:      st.VideoPeers(),
+			VoicePeers:      st.VoicePeers(),
+			ChatPeers:       st.ChatPeers(),
+			ChatSessions:    st.ChatSessions(),
+			ConferenceRooms: st.ConferenceRooms(),
+			FileTransfers:   st.FileTransfers(),
+			Downloads:       st.Downloads(),
+		}
+	}
+	if s.HasCapacity() {
+		c, _ := s.Capacity()
+		out.Capacity = &httpSummaryCapacity{
+			CPUCores:      c.CpuCores(),
+			RAMMB:         c.RamMb(),
+			TotalRAMMB:    c.TotalRamMb(),
+			CurrentLoad:   c.CurrentLoad(),
+			CoreLoads:     []float32{},
+			DiskMB:        c.DiskMb(),
+			BandwidthMbps: c.BandwidthMbps(),
+		}
+		loads, _ := c.CoreLoads()
+		for i := 0; i < loads.Len(); i++ {
+			out.Capacity.CoreLoads = append(out.Capacity.CoreLoads, loads.At(i))
+		}
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+type httpPeer struct {
+	ID         uint32  `json:"id"`
+	LatencyMs  float32 `json:"latencyMs"`
+	JitterMs   float32 `json:"jitterMs"`
+	PacketLoss float32 `json:"packetLoss"`
+}
+
+func (g *HTTPGateway) handlePeers(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), httpCallTimeout)
+	defer cancel()
+
+	future, release := g.client.GetConnectedPeers(ctx, nil)
+	defer release()
+	results, err := future.Struct()
+	if err != nil {
+		writeRPCError(w, err)
+		return
+	}
+	ids, _ := results.Peers()
+
+	peers := make([]httpPeer, 0, ids.Len())
+	for i := 0; i < ids.Len(); i++ {
+		p := httpPeer{ID: ids.At(i)}
+		qFuture, qRelease := g.client.GetConnectionQuality(ctx, func(params NodeService_getConnectionQuality_Params) error {
+			params.SetPeerId(p.ID)
+			return nil
+		})
+		if qResults, err := qFuture.Struct(); err == nil {
+			if q, err := qResults.Quality(); err == nil {
+				p.LatencyMs, p.JitterMs, p.PacketLoss = q.LatencyMs(), q.JitterMs(), q.PacketLoss()
+			}
+		}
+		qRelease()
+		peers = append(peers, p)
+	}
+	writeJSON(w, http.StatusOK, peers)
+}
+
+// ============================================================
+// Uploads and Downloads
+// ============================================================
+
+type httpShardLocation struct {
+	ShardIndex uint32 `json:"shardIndex"`
+	PeerID     uint32 `json:"peerId"`
+	Confirmed  bool   `json:"confirmed"`
+	ShardHash  string `json:"shardHash,omitempty"`
+	ErrorCode  string `json:"errorCode,omitempty"`
+	Relayed    bool   `json:"relayed"`
+}
+
+type httpUploadRequest struct {
+	Data            []byte   `json:"data"`
+	TargetPeers     []uint32 `json:"targetPeers"`
+	Parallelism     uint32   `json:"parallelism"`
+	PlacementPolicy string   `json:"placementPolicy"`
+}
+
+type httpFileManifest struct {
+	FileHash       string              `json:"fileHash"`
+	FileName       string              `json:"fileName"`
+	FileSize       uint64              `json:"fileSize"`
+	ShardCount     uint32              `json:"shardCount"`
+	ParityCount    uint32              `json:"parityCount"`
+	ShardLocations []httpShardLocation `json:"shardLocations"`
+	Timestamp      int64               `json:"timestamp"`
+	TTL            uint32              `json:"ttl"`
+	ContentHash    string              `json:"contentHash,omitempty"`
+	Signature      *httpManifestSig    `json:"signature,omitempty"`
+}
+
+type httpManifestSig struct {
+	Signer    string `json:"signer"`
+	PublicKey []byte `json:"publicKey"`
+	Signature []byte `json:"signature"`
+}
+
+type httpUploadResponse struct {
+	Manifest        httpFileManifest `json:"manifest"`
+	ConfirmedShards uint32           `json:"confirmedShards"`
+	RequiredShards  uint32           `json:"requiredShards"`
+	ThroughputMbps  float32          `json:"throughputMbps"`
+}
+
+func (g *HTTPGateway) handleUpload(w http.ResponseWriter, r *http.Request) {
+	var req httpUploadRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if len(req.Data) == 0 {
+		writeError(w, http.StatusBadRequest, "data is required")
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), httpCallTimeout)
+	defer cancel()
+
+	future, release := g.client.Upload(ctx, func(p NodeService_upload_Params) error {
+		up, err := p.NewRequest()
+		if err != nil {
+			return err
+		}
+		if err := up.SetData(req.Data); err != nil {
+			return err
+		}
+		peers, err := up.NewTargetPeers(int32(len(req.TargetPeers)))
+		if err != nil {
+			return err
+		}
+		for i, id := range req.TargetPeers {
+			peers.Set(i, id)
+		}
+		up.SetParallelism(req.Parallelism)
+		return up.SetPlacementPolicy(req.PlacementPolicy)
+	})
+	defer release()
+	results, err := future.Struct()
+	if err != nil {
+		writeRPCError(w, err)
+		return
+	}
+	resp, err := results.Response()
+	if err != nil {
+		writeRPCError(w, err)
+		return
+	}
+	if !resp.Success() {
+		msg, _ := resp.ErrorMsg()
+		writeError(w, http.StatusUnprocessableEntity, msg)
+		return
+	}
+
+	out := httpUploadResponse{
+		ConfirmedShards: resp.ConfirmedShards(),
+		RequiredShards:  resp.RequiredShards(),
+		ThroughputMbps:  resp.ThroughputMbps(),
+	}
+	if m, err := resp.Manifest(); err == nil {
+		out.Manifest = readHTTPFileManifest(m)
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// readHTTPFileManifest copies a file manifest into its JSON form
+func readHTTPFileManifest(m FileManifest) httpFileManifest {
+	var out httpFileManifest
+	out.FileHash, _ = m.FileHash()
+	out.FileName, _ = m.FileName()
+	out.FileSize = m.FileSize()
+	out.ShardCount = m.ShardCount()
+	out.ParityCount = m.ParityCount()
+	out.Timestamp = m.Timestamp()
+	out.TTL = m.Ttl()
+	out.ContentHash, _ = m.ContentHash()
+	locs, _ := m.ShardLocations()
+	out.ShardLocations = make([]httpShardLocation, locs.Len())
+	for i := 0; i < locs.Len(); i++ {
+		loc := locs.At(i)
+		hash, _ := loc.ShardHash()
+		code, _ := loc.ErrorCode()
+		out.ShardLocations[i] = httpShardLocation{
+			ShardIndex: loc.ShardIndex(),
+			PeerID:     loc.PeerId(),
+			Confirmed:  loc.Confirmed(),
+			ShardHash:  hash,
+			ErrorCode:  code,
+			Relayed:    loc.Relayed(),
+		}
+	}
+	if m.HasSignature() {
+		sig, _ := m.Signature()
+		out.Signature = &httpManifestSig{}
+		out.Signature.Signer, _ = sig.Signer()
+		out.Signature.PublicKey, _ = sig.PublicKey()
+		out.Signature.Signature, _ = sig.Signature()
+	}
+	return out
+}
+
+// fillHTTPFileManifest copies a JSON file manifest into its RPC form
+func fillHTTPFileManifest(out FileManifest, m *httpFileManifest) error {
+	if err := out.SetFileHash(m.FileHash); err != nil {
+		return err
+	}
+	if err := out.SetFileName(m.FileName); err != nil {
+		return err
+	}
+	out.SetFileSize(m.FileSize)
+	out.SetShardCount(m.ShardCount)
+	out.SetParityCount(m.ParityCount)
+	out.SetTimestamp(m.Timestamp)
+	out.SetTtl(m.TTL)
+	if err := out.SetContentHash(m.ContentHash); err != nil {
+		return err
+	}
+	if err := fillHTTPShardLocations(out.NewShardLocations, m.ShardLocations); err != nil {
+		return err
+	}
+	if m.Signature == nil {
+		return nil
+	}
+	sig, err := out.NewSignature()
+	if err != nil {
+		return err
+	}
+	if err := sig.SetSigner(m.Signature.Signer); err != nil {
+		return err
+	}
+	if err := sig.SetPublicKey(m.Signature.PublicKey); err != nil {
+		return err
+	}
+	return sig.SetSignature(m.Signature.Signature)
+}
+
+// fillHTTPShardLocations copies JSON shard locations into a new RPC list
+func fillHTTPShardLocations(newList func(int32) (ShardLocation_List, error), locations []httpShardLocation) error {
+	locs, err := newList(int32(len(locations)))
+	if err != nil {
+		return err
+	}
+	for i, l := range locations {
+		loc := locs.At(i)
+		loc.SetShardIndex(l.ShardIndex)
+		loc.SetPeerId(l.PeerID)
+		loc.SetConfirmed(l.Confirmed)
+		loc.SetRelayed(l.Relayed)
+		if err := loc.SetShardHash(l.ShardHash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// httpDownloadRequest names the file to download by its signed manifest
+// from an upload, or, on a legacy-only node, by its hash and shard
+// locations
+type httpDownloadRequest struct {
+	Manifest       *httpFileManifest   `json:"manifest,omitempty"`
+	ExpectedSigner string              `json:"expectedSigner,omitempty"` // Defaults to the node's own identity
+	FileHash       string              `json:"fileHash"`
+	ContentHash    string              `json:"contentHash,omitempty"`
+	ShardLocations []httpShardLocation `json:"shardLocations"`
+}
+
+type httpDownloadResponse struct {
+	Data            []byte `json:"data"`
+	BytesDownloaded uint64 `json:"bytesDownloaded"`
+	Signer          string `json:"signer,omitempty"`
+}
+
+func (g *HTTPGateway) handleDownload(w http.ResponseWriter, r *http.Request) {
+	var req httpDownloadRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), httpCallTimeout)
+	defer cancel()
+
+	future, release := g.client.Download(ctx, func(p NodeService_download_Params) error {
+		down, err := p.NewRequest()
+		if err != nil {
+			return err
+		}
+		if err := down.SetExpectedSigner(req.ExpectedSigner); err != nil {
+			return err
+		}
+		if req.Manifest != nil {
+			m, err := down.NewManifest()
+			if err != nil {
+				return err
+			}
+			return fillHTTPFileManifest(m, req.Manifest)
+		}
+		if err := down.SetFileHash(req.FileHash); err != nil {
+			return err
+		}
+		if err := down.SetContentHash(req.ContentHash); err != nil {
+			return err
+		}
+		return fillHTTPShardLocations(down.NewShardLocations, req.ShardLocations)
+	})
+	defer release()
+	results, err := future.Struct()
+	if err != nil {
+		writeRPCError(w, err)
+		return
+	}
+	resp, err := results.Response()
+	if err != nil {
+		writeRPCError(w, err)
+		return
+	}
+	if !resp.Success() {
+		msg, _ := resp.ErrorMsg()
+		writeError(w, http.StatusUnprocessableEntity, msg)
+		return
+	}
+	data, _ := resp.Data()
+	signer, _ := resp.Signer()
+	writeJSON(w, http.StatusOK, httpDownloadResponse{Data: data, BytesDownloaded: resp.BytesDownloaded(), Signer: signer})
+}
+
+// ============================================================
+// Compute Jobs
+// ============================================================
+
+type httpJobManifest struct {
+	JobID            string   `json:"jobId"`
+	WasmModule       []byte   `json:"wasmModule"`
+	InputData        []byte   `json:"inputData"`
+	SplitStrategy    string   `json:"splitStrategy"`
+	MinChunkSize     uint64   `json:"minChunkSize"`
+	MaxChunkSize     uint64   `json:"maxChunkSize"`
+	VerificationMode string   `json:"verificationMode"`
+	TimeoutSecs      uint32   `json:"timeoutSecs"`
+	RetryCount       uint32   `json:"retryCount"`
+	Priority         uint32   `json:"priority"`
+	Redundancy       uint32   `json:"redundancy"`
+	Reducer          string   `json:"reducer"`
+	DependsOn        []string `json:"dependsOn"`
+	PostProcess      []string `json:"postProcess"`
+	StartAtUnix      int64    `json:"startAtUnix"`
+}
+
+type httpJobStatus struct {
+	JobID                  string  `json:"jobId"`
+	Status                 string  `json:"status"`
+	Progress               float32 `json:"progress"`
+	CompletedChunks        uint32  `json:"completedChunks"`
+	TotalChunks            uint32  `json:"totalChunks"`
+	EstimatedTimeRemaining uint32  `json:"estimatedTimeRemaining"`
+	ErrorMsg               string  `json:"errorMsg,omitempty"`
+}
+
+type httpJobResult struct {
+	Result     []byte `json:"result"`
+	WorkerNode string `json:"workerNode"`
+}
+
+func (g *HTTPGateway) handleSubmitJob(w http.ResponseWriter, r *http.Request) {
+	var req httpJobManifest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), httpCallTimeout)
+	defer cancel()
+
+	future, release := g.client.SubmitComputeJob(ctx, func(p NodeService_submitComputeJob_Params) error {
+		m, err := p.NewManifest()
+		if err != nil {
+			return err
+		}
+		return fillJobManifest(m, req)
+	})
+	defer release()
+	results, err := future.Struct()
+	if err != nil {
+		writeRPCError(w, err)
+		return
+	}
+	if !results.Success() {
+		msg, _ := results.ErrorMsg()
+		writeError(w, http.StatusUnprocessableEntity, msg)
+		return
+	}
+	jobID, _ := results.JobId()
+	writeJSON(w, http.StatusAccepted, map[string]string{"jobId": jobID})
+}
+
+func (g *HTTPGateway) handleJobStatus(w http.ResponseWriter, r *http.Request) {
+	jobID := r.PathValue("id")
+	ctx, cancel := context.WithTimeout(r.Context(), httpCallTimeout)
+	defer cancel()
+
+	future, release := g.client.GetComputeJobStatus(ctx, func(p NodeService_getComputeJobStatus_Params) error {
+		return p.SetJobId(jobID)
+	})
+	defer release()
+	results, err := future.Struct()
+	if err != nil {
+		writeRPCError(w, err)
+		return
+	}
+	st, err := results.Status()
+	if err != nil {
+		writeRPCError(w, err)
+		return
+	}
+	out := httpJobStatus{
+		Progress:               st.Progress(),
+		CompletedChunks:        st.CompletedChunks(),
+		TotalChunks:            st.TotalChunks(),
+		EstimatedTimeRemaining: st.EstimatedTimeRemaining(),
+	}
+	out.JobID, _ = st.JobId()
+	out.Status, _ = st.Status()
+	out.ErrorMsg, _ = st.ErrorMsg()
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (g *HTTPGateway) handleJobResult(w http.ResponseWriter, r *http.Request) {
+	jobID := r.PathValue("id")
+	var timeoutMs uint64
+	if v := r.URL.Query().Get("timeoutMs"); v != "" {
+		var err error
+		if timeoutMs, err = strconv.ParseUint(v, 10, 32); err != nil {
+			writeError(w, http.StatusBadRequest, "timeoutMs must be a non-negative integer")
+			return
+		}
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), httpCallTimeout+time.Duration(timeoutMs)*time.Millisecond)
+	defer cancel()
+
+	future, release := g.client.GetComputeJobResult(ctx, func(p NodeService_getComputeJobResult_Params) error {
+		p.SetTimeoutMs(uint32(timeoutMs))
+		return p.SetJobId(jobID)
+	})
+	defer release()
+	results, err := future.Struct()
+	if err != nil {
+		writeRPCError(w, err)
+		return
+	}
+	if !results.Success() {
+		msg, _ := results.ErrorMsg()
+		writeError(w, http.StatusConflict, msg)
+		return
+	}
+	var out httpJobResult
+	out.Result, _ = results.Result()
+	out.WorkerNode, _ = results.WorkerNode()
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (g *HTTPGateway) handleCancelJob(w http.ResponseWriter, r *http.Request) {
+	jobID := r.PathValue("id")
+	ctx, cancel := context.WithTimeout(r.Context(), httpCallTimeout)
+	defer cancel()
+
+	future, release := g.client.CancelComputeJob(ctx, func(p NodeService_cancelComputeJob_Params) error {
+		return p.SetJobId(jobID)
+	})
+	defer release()
+	results, err := future.Struct()
+	if err != nil {
+		writeRPCError(w, err)
+		return
+	}
+	if !results.Success() {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("job %s not found or already finished", jobID))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// fillJobManifest copies a JSON job manifest into its RPC form
+func fillJobManifest(m ComputeJobManifest, req httpJobManifest) error {
+	if err := m.SetJobId(req.JobID); err != nil {
+		return err
+	}
+	if err := m.SetWasmModule(req.WasmModule); err != nil {
+		return err
+	}
+	if err := m.SetInputData(req.InputData); err != nil {
+		return err
+	}
+	if err := m.SetSplitStrategy(req.SplitStrategy); err != nil {
+		return err
+	}
+	if err := m.SetVerificationMode(req.VerificationMode); err != nil {
+		return err
+	}
+	if err := m.SetReducer(req.Reducer); err != nil {
+		return err
+	}
+	m.SetMinChunkSize(req.MinChunkSize)
+	m.SetMaxChunkSize(req.MaxChunkSize)
+	m.SetTimeoutSecs(req.TimeoutSecs)
+	m.SetRetryCount(req.RetryCount)
+	m.SetPriority(req.Priority)
+	m.SetRedundancy(req.Redundancy)
+	m.SetStartAtUnix(req.StartAtUnix)
+
+	deps, err := textList(m.Segment(), req.DependsOn)
+	if err != nil {
+		return err
+	}
+	if err := m.SetDependsOn(deps); err != nil {
+		return err
+	}
+	steps, err := textList(m.Segment(), req.PostProcess)
+	if err != nil {
+		return err
+	}
+	return m.SetPostProcess(steps)
+}
+
+// ============================================================
+// Chat
+// ============================================================
+
+type httpChatSessionRequest struct {
+	PeerAddr       string `json:"peerAddr"`
+	EncryptionType string `json:"encryptionType"`
+	MessageTTLSecs uint32 `json:"messageTtlSecs"`
+	BurnOnRead     bool   `json:"burnOnRead"`
+}
+
+type httpChatSession struct {
+	SessionID      string `json:"sessionId"`
+	PeerAddr       string `json:"peerAddr"`
+	Established    int64  `json:"established"`
+	MessageTTLSecs uint32 `json:"messageTtlSecs"`
+	BurnOnRead     bool   `json:"burnOnRead"`
+}
+
+type httpChatMessage struct {
+	MessageID string `json:"messageId"`
+	FromPeer  string `json:"fromPeer,omitempty"`
+	ToPeer    string `json:"toPeer"`
+	Message   string `json:"message"`
+	Timestamp int64  `json:"timestamp"`
+	TTLSecs   uint32 `json:"ttlSecs"`
+	ExpiresAt int64  `json:"expiresAt,omitempty"`
+}
+
+func (g *HTTPGateway) handleStartChat(w http.ResponseWriter, r *http.Request) {
+	var req httpChatSessionRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if req.PeerAddr == "" {
+		writeError(w, http.StatusBadRequest, "peerAddr is required")
+		return
+	}
+	if req.EncryptionType == "" {
+		req.EncryptionType = "symmetric"
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), httpCallTimeout)
+	defer cancel()
+
+	future, release := g.client.StartChatSession(ctx, func(p NodeService_startChatSession_Params) error {
+		if err := p.SetPeerAddr(req.PeerAddr); err != nil {
+			return err
+		}
+		enc, err := p.NewEncryptionConfig()
+		if err != nil {
+			return err
+		}
+		if err := enc.SetEncryptionType(req.EncryptionType); err != nil {
+			return err
+		}
+		p.SetMessageTtlSecs(req.MessageTTLSecs)
+		p.SetBurnOnRead(req.BurnOnRead)
+		return nil
+	})
+	defer release()
+	results, err := future.Struct()
+	if err != nil {
+		writeRPCError(w, err)
+		return
+	}
+	if !results.Success() {
+		msg, _ := results.ErrorMsg()
+		writeError(w, http.StatusUnprocessableEntity, msg)
+		return
+	}
+	session, err := results.Session()
+	if err != nil {
+		writeRPCError(w, err)
+		return
+	}
+	out := httpChatSession{
+		Established:    session.Established(),
+		MessageTTLSecs: session.MessageTtlSecs(),
+		BurnOnRead:     session.BurnOnRead(),
+	}
+	out.SessionID, _ = session.SessionId()
+	out.PeerAddr, _ = session.PeerAddr()
+	writeJSON(w, http.StatusCreated, out)
+}
+
+func (g *HTTPGateway) handleCloseChat(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.PathValue("id")
+	ctx, cancel := context.WithTimeout(r.Context(), httpCallTimeout)
+	defer cancel()
+
+	future, release := g.client.CloseChatSession(ctx, func(p NodeService_closeChatSession_Params) error {
+		return p.SetSessionId(sessionID)
+	})
+	defer release()
+	results, err := future.Struct()
+	if err != nil {
+		writeRPCError(w, err)
+		return
+	}
+	if !results.Success() {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("chat session %s not found", sessionID))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (g *HTTPGateway) handleReceiveChat(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.PathValue("id")
+	includeRead, _ := strconv.ParseBool(r.URL.Query().Get("includeRead"))
+	ctx, cancel := context.WithTimeout(r.Context(), httpCallTimeout)
+	defer cancel()
+
+	future, release := g.client.ReceiveChatMessages(ctx, func(p NodeService_receiveChatMessages_Params) error {
+		p.SetIncludeRead(includeRead)
+		return p.SetSessionId(sessionID)
+	})
+	defer release()
+	results, err := future.Struct()
+	if err != nil {
+		writeRPCError(w, err)
+		return
+	}
+	list, _ := results.Messages()
+	out := make([]httpChatMessage, list.Len())
+	for i := range out {
+		m := list.At(i)
+		body, _ := m.Message_()
+		out[i] = httpChatMessage{
+			Message:   string(body),
+			Timestamp: m.Timestamp(),
+			TTLSecs:   m.TtlSecs(),
+			ExpiresAt: m.ExpiresAt(),
+		}
+		out[i].MessageID, _ = m.MessageId()
+		out[i].FromPeer, _ = m.FromPeer()
+		out[i].ToPeer, _ = m.ToPeer()
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (g *HTTPGateway) handleSendChat(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.PathValue("id")
+	var req httpChatMessage
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if req.MessageID == "" {
+		req.MessageID = fmt.Sprintf("http-%d", time.Now().UnixNano())
+	}
+	if req.Timestamp == 0 {
+		req.Timestamp = time.Now().Unix()
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), httpCallTimeout)
+	defer cancel()
+
+	future, release := g.client.SendEphemeralMessage(ctx, func(p NodeService_sendEphemeralMessage_Params) error {
+		if err := p.SetSessionId(sessionID); err != nil {
+			return err
+		}
+		m, err := p.NewMessage_()
+		if err != nil {
+			return err
+		}
+		if err := m.SetToPeer(req.ToPeer); err != nil {
+			return err
+		}
+		if err := m.SetMessage_([]byte(req.Message)); err != nil {
+			return err
+		}
+		m.SetTimestamp(req.Timestamp)
+		m.SetTtlSecs(req.TTLSecs)
+		return m.SetMessageId(req.MessageID)
+	})
+	defer release()
+	results, err := future.Struct()
+	if err != nil {
+		writeRPCError(w, err)
+		return
+	}
+	if !results.Success() {
+		msg, _ := results.ErrorMsg()
+		writeError(w, http.StatusUnprocessableEntity, msg)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]string{"messageId": req.MessageID})
+}
+
+// ============================================================
+// WebRTC
+// ============================================================
+
+type httpWebRTC struct {
+	Enabled bool     `json:"enabled"`
+	PeerID  string   `json:"peerId,omitempty"`
+	Addrs   []string `json:"addrs"`
+}
+
+// handleWebRTC is the signaling step for browser peers: it tells a page
+// which WebRTC-direct multiaddrs to dial. Pages are served from other
+// origins, so any origin may read it.
+func (g *HTTPGateway) handleWebRTC(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), httpCallTimeout)
+	defer cancel()
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	future, release := g.client.GetWebRTCAddrs(ctx, nil)
+	defer release()
+	results, err := future.Struct()
+	if err != nil {
+		writeRPCError(w, err)
+		return
+	}
+	out := httpWebRTC{Enabled: results.Enabled(), Addrs: []string{}}
+	out.PeerID, _ = results.PeerId()
+	if addrs, err := results.Addrs(); err == nil {
+		for i := 0; i < addrs.Len(); i++ {
+			addr, _ := addrs.At(i)
+			out.Addrs = append(out.Addrs, addr)
+		}
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// ============================================================
+// Helpers
+// ============================================================
+
+func (g *HTTPGateway) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Write(openAPISpec)
+}
+
+// readJSON decodes a request body, answering 400 if it is malformed
+func readJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, "request body too large")
+		} else {
+			writeError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		}
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("⚠️  [HTTP] Failed to write response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+// writeRPCError reports a failed service call
+func writeRPCError(w http.ResponseWriter, err error) {
+	if errors.Is(err, context.DeadlineExceeded) {
+		writeError(w, http.StatusGatewayTimeout, err.Error())
+		return
+	}
+	writeError(w, http.StatusInternalServerError, err.Error())
+}
+
+// textList builds a capnp text list
+func textList(seg *capnp.Segment, items []string) (capnp.TextList, error) {
+	list, err := capnp.NewTextList(seg, int32(len(items)))
+	if err != nil {
+		return list, err
+	}
+	for i, s := range items {
+		if err := list.Set(i, s); err != nil {
+			return list, err
+		}
+	}
+	return list, nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestHTTPGatewayEndpoints(t *testing.T) {
+	node, err := NewLibP2PPangeaNodeWithOptions(500, NewNodeStore(), false, true, 12500)
+	if err != nil {
+		t.Fatalf("failed to create node: %v", err)
+	}
+	defer node.cancel()
+	store := NewNodeStore()
+	service := NewNodeServiceServerWithConfig(store, NewLibP2PAdapter(node, store), nil, nil, nil)
+	gateway := NewHTTPGateway(service)
+	defer gateway.Close()
+	srv := httptest.NewServer(gateway)
+	defer srv.Close()
+
+	do := func(method, path, body string) (int, []byte) {
+		req, _ := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", method, path, err)
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, data
+	}
+
+	code, body := do("GET", "/api/v1/status", "")
+	var status httpStatus
+	if code != http.StatusOK || json.Unmarshal(body, &status) != nil {
+		t.Fatalf("status: %d %s", code, body)
+	}
+	if !strings.Contains(status.LocalMultiaddr, "12500") || status.Storage.Role != "read_write" {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+
+	code, body = do("GET", "/api/v1/status/summary", "")
+	var summary httpSummary
+	if code != http.StatusOK || json.Unmarshal(body, &summary) != nil {
+		t.Fatalf("summary: %d %s", code, body)
+	}
+	if summary.NodeID != node.GetHost().ID().String() || summary.Peers == nil || summary.Storage == nil ||
+		summary.Jobs == nil || summary.Capacity == nil || summary.Capacity.CPUCores == 0 {
+		t.Fatalf("unexpected summary: %s", body)
+	}
+	// Only what changed is sent again
+	code, body = do("GET", "/api/v1/status/summary?since="+strconv.FormatUint(summary.Version, 10), "")
+	var update httpSummary
+	if code != http.StatusOK || json.Unmarshal(body, &update) != nil {
+		t.Fatalf("summary update: %d %s", code, body)
+	}
+	if update.Version < summary.Version || update.Peers != nil || update.Storage != nil || update.Jobs != nil {
+		t.Fatalf("unchanged sections were sent again: %s", body)
+	}
+	if code, _ = do("GET", "/api/v1/status/summary?since=abc", ""); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a malformed since, got %d", code)
+	}
+
+	code, body = do("GET", "/api/v1/peers", "")
+	if code != http.StatusOK || strings.TrimSpace(string(body)) != "[]" {
+		t.Fatalf("peers: %d %s", code, body)
+	}
+
+	code, body = do("GET", "/api/v1/openapi.yaml", "")
+	if code != http.StatusOK || !strings.HasPrefix(string(body), "openapi: 3") {
+		t.Fatalf("openapi: %d", code)
+	}
+
+	code, body = do("GET", "/api/v1/jobs/missing-job", "")
+	var job httpJobStatus
+	if code != http.StatusOK || json.Unmarshal(body, &job) != nil || job.Status != "failed" {
+		t.Fatalf("job status: %d %s", code, body)
+	}
+	if code, _ = do("DELETE", "/api/v1/jobs/missing-job", ""); code != http.StatusNotFound {
+		t.Fatalf("expected 404 cancelling unknown job, got %d", code)
+	}
+
+	if code, _ = do("POST", "/api/v1/jobs", `{"jobId": 7}`); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for malformed job, got %d", code)
+	}
+	if code, _ = do("POST", "/api/v1/uploads", `{"data": "", "bogus": 1}`); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown field, got %d", code)
+	}
+	if code, _ = do("POST", "/api/v1/chat/sessions", `{}`); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 without peerAddr, got %d", code)
+	}
+	if code, _ = do("PUT", "/api/v1/status", ""); code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405 for wrong method, got %d", code)
+	}
+}
@@ -0,0 +1,380 @@
+package testutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pangea-net/go-node/pkg/compute"
+)
+
+var (
+	// ErrDropped is returned by calls the fake network lost
+	ErrDropped = errors.New("dropped by the fake network")
+	// ErrNotConnected is returned by calls to a peer the caller is not
+	// connected to
+	ErrNotConnected = errors.New("peer not connected")
+	// ErrNotFound is returned when a peer does not hold what was fetched
+	ErrNotFound = errors.New("not found")
+)
+
+// Link is how the fake network treats calls between two nodes
+type Link struct {
+	Latency time.Duration // Added to every call crossing the link
+	Loss    float64       // Fraction of calls dropped, from 0 to 1
+	Down    bool          // Every call is dropped
+}
+
+// Message is a message an Adapter received
+type Message struct {
+	From uint32
+	Data []byte
+}
+
+// Network is an in-memory network of Adapters. Calls between them cross a
+// Link instead of a socket; losses are drawn from a seeded source, so a
+// test sees the same drops on every run.
+type Network struct {
+	mu    sync.Mutex
+	rng   *rand.Rand
+	link  Link // For pairs without a link of their own
+	links map[[2]uint32]Link
+	nodes map[uint32]*Adapter
+}
+
+// NewNetwork creates an empty network drawing losses from seed
+func NewNetwork(seed int64) *Network {
+	return &Network{
+		rng:   rand.New(rand.NewSource(seed)),
+		links: make(map[[2]uint32]Link),
+		nodes: make(map[uint32]*Adapter),
+	}
+}
+
+// SetDefaultLink sets the link used between nodes without their own
+func (n *Network) SetDefaultLink(link Link) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.link = link
+}
+
+// SetLink sets the link between a and b in both directions
+func (n *Network) SetLink(a, b uint32, link Link) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.links[linkKey(a, b)] = link
+}
+
+// Node returns the adapter of node id, adding the node if it is new
+func (n *Network) Node(id uint32) *Adapter {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	a, ok := n.nodes[id]
+	if !ok {
+		a = &Adapter{
+			network:   n,
+			id:        id,
+			connected: make(map[uint32]bool),
+			shards:    make(map[string][]byte),
+			shares:    make(map[string][]byte),
+		}
+		n.nodes[id] = a
+	}
+	return a
+}
+
+// Connect adds the nodes and connects every pair of them
+func (n *Network) Connect(ids ...uint32) {
+	for _, id := range ids {
+		n.Node(id)
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, a := range ids {
+		for _, b := range ids {
+			if a != b {
+				n.nodes[a].connected[b] = true
+			}
+		}
+	}
+}
+
+// traverse carries one call from one node to another over their link
+func (n *Network) traverse(from, to uint32) (*Adapter, error) {
+	n.mu.Lock()
+	peer, ok := n.nodes[to]
+	if !ok || !n.nodes[from].connected[to] {
+		n.mu.Unlock()
+		return nil, fmt.Errorf("%w: %d", ErrNotConnected, to)
+	}
+	link := n.linkLocked(from, to)
+	dropped := link.Down || (link.Loss > 0 && n.rng.Float64() < link.Loss)
+	n.mu.Unlock()
+
+	time.Sleep(link.Latency)
+	if dropped {
+		return nil, fmt.Errorf("%w: call from %d to %d", ErrDropped, from, to)
+	}
+	return peer, nil
+}
+
+// linkLocked returns the link between two nodes. Caller must hold n.mu.
+func (n *Network) linkLocked(a, b uint32) Link {
+	if link, ok := n.links[linkKey(a, b)]; ok {
+		return link
+	}
+	return n.link
+}
+
+func linkKey(a, b uint32) [2]uint32 {
+	if a > b {
+		a, b = b, a
+	}
+	return [2]uint32{a, b}
+}
+
+// Adapter is one node of a Network. It implements the node's
+// NetworkAdapter and DataTransport, holding shards and shares in memory,
+// and compute.TaskDelegator, running delegated tasks on the worker's
+// compute manager.
+type Adapter struct {
+	network   *Network
+	id        uint32
+	connected map[uint32]bool
+	shards    map[string][]byte
+	shares    map[string][]byte
+	messages  []Message
+	onMessage func(from uint32, data []byte)
+	manager   *compute.Manager
+	served    int
+}
+
+// ID returns the node's ID
+func (a *Adapter) ID() uint32 { return a.id }
+
+// OnMessage calls handler with every message the node receives
+func (a *Adapter) OnMessage(handler func(from uint32, data []byte)) {
+	a.network.mu.Lock()
+	defer a.network.mu.Unlock()
+	a.onMessage = handler
+}
+
+// Messages returns the messages the node received
+func (a *Adapter) Messages() []Message {
+	a.network.mu.Lock()
+	defer a.network.mu.Unlock()
+	return append([]Message(nil), a.messages...)
+}
+
+// SetComputeManager runs tasks delegated to the node on m
+func (a *Adapter) SetComputeManager(m *compute.Manager) {
+	a.network.mu.Lock()
+	defer a.network.mu.Unlock()
+	a.manager = m
+}
+
+// StoreShard puts a shard on the node
+func (a *Adapter) StoreShard(fileHash string, shardIndex uint32, data []byte) {
+	a.network.mu.Lock()
+	defer a.network.mu.Unlock()
+	a.shards[shardKey(fileHash, shardIndex)] = append([]byte(nil), data...)
+}
+
+// Shard returns a shard the node holds
+func (a *Adapter) Shard(fileHash string, shardIndex uint32) ([]byte, bool) {
+	a.network.mu.Lock()
+	defer a.network.mu.Unlock()
+	data, ok := a.shards[shardKey(fileHash, shardIndex)]
+	return data, ok
+}
+
+// Served returns the number of fetches and tasks that reached the node
+func (a *Adapter) Served() int {
+	a.network.mu.Lock()
+	defer a.network.mu.Unlock()
+	return a.served
+}
+
+// ConnectToPeer connects to a node of the network; the address is ignored
+func (a *Adapter) ConnectToPeer(peerAddr string, peerID uint32) error {
+	a.network.mu.Lock()
+	defer a.network.mu.Unlock()
+	peer, ok := a.network.nodes[peerID]
+	if !ok || peerID == a.id {
+		return fmt.Errorf("%w: node %d", ErrNotFound, peerID)
+	}
+	a.connected[peerID] = true
+	peer.connected[a.id] = true
+	return nil
+}
+
+// DisconnectPeer disconnects from a node
+func (a *Adapter) DisconnectPeer(peerID uint32) error {
+	a.network.mu.Lock()
+	defer a.network.mu.Unlock()
+	if !a.connected[peerID] {
+		return fmt.Errorf("%w: %d", ErrNotConnected, peerID)
+	}
+	delete(a.connected, peerID)
+	delete(a.network.nodes[peerID].connected, a.id)
+	return nil
+}
+
+// SendMessage delivers a message to a node
+func (a *Adapter) SendMessage(peerID uint32, data []byte) error {
+	peer, err := a.network.traverse(a.id, peerID)
+	if err != nil {
+		return err
+	}
+	a.network.mu.Lock()
+	peer.messages = append(peer.messages, Message{From: a.id, Data: append([]byte(nil), data...)})
+	handler := peer.onMessage
+	a.network.mu.Unlock()
+	if handler != nil {
+		handler(a.id, data)
+	}
+	return nil
+}
+
+// FetchShard fetches a shard from a node
+func (a *Adapter) FetchShard(ctx context.Context, peerID uint32, fileHash string, shardIndex uint32) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	peer, err := a.network.traverse(a.id, peerID)
+	if err != nil {
+		return nil, err
+	}
+	a.network.mu.Lock()
+	defer a.network.mu.Unlock()
+	peer.served++
+	data, ok := peer.shards[shardKey(fileHash, shardIndex)]
+	if !ok {
+		return nil, fmt.Errorf("%w: shard %d of %s on %d", ErrNotFound, shardIndex, fileHash, peerID)
+	}
+	return append([]byte(nil), data...), nil
+}
+
+// FetchShare fetches a node's DKG share of a file
+func (a *Adapter) FetchShare(peerID uint32, fileID string) ([]byte, error) {
+	peer, err := a.network.traverse(a.id, peerID)
+	if err != nil {
+		return nil, err
+	}
+	a.network.mu.Lock()
+	defer a.network.mu.Unlock()
+	peer.served++
+	share, ok := peer.shares[fileID]
+	if !ok {
+		return nil, fmt.Errorf("%w: share of %s on %d", ErrNotFound, fileID, peerID)
+	}
+	return append([]byte(nil), share...), nil
+}
+
+// GetConnectedPeers returns the connected nodes in ID order
+func (a *Adapter) GetConnectedPeers() []uint32 {
+	a.network.mu.Lock()
+	defer a.network.mu.Unlock()
+	peers := make([]uint32, 0, len(a.connected))
+	for id := range a.connected {
+		peers = append(peers, id)
+	}
+	sort.Slice(peers, func(i, j int) bool { return peers[i] < peers[j] })
+	return peers
+}
+
+// GetConnectionQuality reports the link to a node: its latency, no
+// jitter, and its loss
+func (a *Adapter) GetConnectionQuality(peerID uint32) (latencyMs, jitterMs, packetLoss float32, err error) {
+	a.network.mu.Lock()
+	defer a.network.mu.Unlock()
+	if !a.connected[peerID] {
+		return 0, 0, 0, fmt.Errorf("%w: %d", ErrNotConnected, peerID)
+	}
+	link := a.network.linkLocked(a.id, peerID)
+	return float32(link.Latency.Milliseconds()), 0, float32(link.Loss), nil
+}
+
+// SendShard stores a shard on a node
+func (a *Adapter) SendShard(peerID uint32, fileHash string, shardIndex uint32, data []byte) error {
+	peer, err := a.network.traverse(a.id, peerID)
+	if err != nil {
+		return err
+	}
+	peer.StoreShard(fileHash, shardIndex, data)
+	return nil
+}
+
+// SendShare stores a DKG share on a node
+func (a *Adapter) SendShare(peerID uint32, fileID string, share []byte) error {
+	peer, err := a.network.traverse(a.id, peerID)
+	if err != nil {
+		return err
+	}
+	peer.StoreLocalShare(fileID, a.id, share)
+	return nil
+}
+
+// StoreLocalShare keeps the node's own DKG share of a file
+func (a *Adapter) StoreLocalShare(fileID string, fromPeer uint32, share []byte) {
+	a.network.mu.Lock()
+	defer a.network.mu.Unlock()
+	a.shares[fileID] = append([]byte(nil), share...)
+}
+
+// GetLocalShare returns the node's own DKG share of a file
+func (a *Adapter) GetLocalShare(fileID string) ([]byte, bool) {
+	a.network.mu.Lock()
+	defer a.network.mu.Unlock()
+	share, ok := a.shares[fileID]
+	return share, ok
+}
+
+// DelegateTask runs a task on the worker node's compute manager. Worker IDs
+// are node IDs in decimal.
+func (a *Adapter) DelegateTask(ctx context.Context, workerID string, task *compute.ComputeTask) (*compute.TaskResult, error) {
+	id, err := strconv.ParseUint(workerID, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid worker ID %q: %w", workerID, err)
+	}
+	peer, err := a.network.traverse(a.id, uint32(id))
+	if err != nil {
+		return nil, err
+	}
+	a.network.mu.Lock()
+	peer.served++
+	manager := peer.manager
+	a.network.mu.Unlock()
+	if manager == nil {
+		return nil, fmt.Errorf("node %d runs no compute tasks", id)
+	}
+	return manager.ExecuteTask(ctx, task, workerID, strconv.FormatUint(uint64(a.id), 10)), nil
+}
+
+// GetAvailableWorkers returns the connected nodes that run compute tasks
+func (a *Adapter) GetAvailableWorkers() []string {
+	var workers []string
+	for _, id := range a.GetConnectedPeers() {
+		a.network.mu.Lock()
+		manager := a.network.nodes[id].manager
+		a.network.mu.Unlock()
+		if manager != nil {
+			workers = append(workers, strconv.FormatUint(uint64(id), 10))
+		}
+	}
+	return workers
+}
+
+// HasWorkers reports whether a connected node runs compute tasks
+func (a *Adapter) HasWorkers() bool {
+	return len(a.GetAvailableWorkers()) > 0
+}
+
+func shardKey(fileHash string, shardIndex uint32) string {
+	return fmt.Sprintf("%s/%d", fileHash, shardIndex)
+}
@@ -0,0 +1,92 @@
+package testutil
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNetworkCarriesCallsOverLinks(t *testing.T) {
+	n := NewNetwork(1)
+	n.Connect(1, 2)
+	a, b := n.Node(1), n.Node(2)
+
+	if err := a.SendShard(2, "file", 0, []byte("shard")); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := b.FetchShard(context.Background(), 2, "file", 0); err == nil {
+		t.Fatalf("a node fetched %q from itself", data)
+	}
+	if data, err := a.FetchShard(context.Background(), 2, "file", 0); err != nil || !bytes.Equal(data, []byte("shard")) {
+		t.Fatalf("fetched %q, %v", data, err)
+	}
+	if _, err := a.FetchShard(context.Background(), 2, "file", 1); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected a missing shard to be not found, got %v", err)
+	}
+
+	var got []Message
+	b.OnMessage(func(from uint32, data []byte) { got = append(got, Message{From: from, Data: data}) })
+	if err := a.SendMessage(2, []byte("hi")); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].From != 1 || len(b.Messages()) != 1 {
+		t.Fatalf("unexpected delivery %+v", got)
+	}
+
+	// Latency is added to every call and reported as connection quality
+	n.SetLink(1, 2, Link{Latency: 20 * time.Millisecond, Loss: 0.25})
+	start := time.Now()
+	a.SendMessage(2, []byte("slow"))
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Fatalf("call took %v over a 20ms link", elapsed)
+	}
+	if latency, _, loss, err := b.GetConnectionQuality(1); err != nil || latency != 20 || loss != 0.25 {
+		t.Fatalf("unexpected quality %v %v %v", latency, loss, err)
+	}
+
+	if err := a.DisconnectPeer(2); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.SendMessage(1, nil); !errors.Is(err, ErrNotConnected) {
+		t.Fatalf("expected a disconnected peer to be unreachable, got %v", err)
+	}
+}
+
+func TestNetworkLossIsSeeded(t *testing.T) {
+	drops := func() []bool {
+		n := NewNetwork(7)
+		n.Connect(1, 2)
+		n.SetDefaultLink(Link{Loss: 0.5})
+		var out []bool
+		for i := 0; i < 32; i++ {
+			err := n.Node(1).SendMessage(2, nil)
+			if err != nil && !errors.Is(err, ErrDropped) {
+				t.Fatal(err)
+			}
+			out = append(out, err != nil)
+		}
+		return out
+	}
+	first, second := drops(), drops()
+	var dropped int
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("runs with the same seed differ at call %d", i)
+		}
+		if first[i] {
+			dropped++
+		}
+	}
+	if dropped == 0 || dropped == len(first) {
+		t.Fatalf("expected about half the calls dropped, got %d of %d", dropped, len(first))
+	}
+
+	n := NewNetwork(7)
+	n.Connect(1, 2)
+	n.SetLink(1, 2, Link{Down: true})
+	if err := n.Node(2).SendShare(1, "file", []byte("share")); !errors.Is(err, ErrDropped) {
+		t.Fatalf("expected a down link to drop calls, got %v", err)
+	}
+}
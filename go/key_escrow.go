@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Key bundles are laid out as magic | version | salt | nonce | ciphertext,
+// where the ciphertext is the JSON bundle sealed with AES-256-GCM under a
+// key derived from the passphrase with Argon2id
+const (
+	keyBundleMagic      = "PGKB"
+	keyBundleVersion    = 1
+	keyBundleSaltSize   = 16
+	minKeyPassphraseLen = 8
+
+	// Argon2id parameters (RFC 9106 second recommended option)
+	keyBundleArgonTime    = 3
+	keyBundleArgonMemory  = 64 * 1024 // KiB
+	keyBundleArgonThreads = 4
+
+	// maxKeyAuditEntries caps the in-memory audit history; the audit file
+	// keeps everything
+	maxKeyAuditEntries = 1000
+)
+
+// ErrBadPassphrase is returned when a bundle cannot be decrypted
+var ErrBadPassphrase = errors.New("wrong passphrase or corrupted key bundle")
+
+// KeyBundle is the plaintext of an exported key bundle: every DKG share the
+// node holds, from which the CES file keys are reconstructed
+type KeyBundle struct {
+	NodeID   uint32                       `json:"nodeId"`
+	Exported time.Time                    `json:"exported"`
+	Shares   map[string]map[uint32][]byte `json:"shares"` // fileID -> peerID -> share
+}
+
+// counts returns the number of files and shares in the bundle
+func (b *KeyBundle) counts() (files, shares int) {
+	for _, s := range b.Shares {
+		shares += len(s)
+	}
+	return len(b.Shares), shares
+}
+
+// EncryptKeyBundle seals a bundle with a passphrase
+func EncryptKeyBundle(bundle *KeyBundle, passphrase string) ([]byte, error) {
+	plaintext, err := json.Marshal(bundle)
+	if err != nil {
+		return nil, err
+	}
+	return sealWithPassphrase(keyBundleMagic, keyBundleVersion, plaintext, passphrase)
+}
+
+// DecryptKeyBundle opens a bundle sealed by EncryptKeyBundle
+func DecryptKeyBundle(data []byte, passphrase string) (*KeyBundle, error) {
+	plaintext, err := openWithPassphrase(data, keyBundleMagic, keyBundleVersion, passphrase, "key bundle")
+	if err != nil {
+		return nil, err
+	}
+	var bundle KeyBundle
+	if err := json.Unmarshal(plaintext, &bundle); err != nil {
+		return nil, fmt.Errorf("invalid key bundle contents: %w", err)
+	}
+	return &bundle, nil
+}
+
+// sealWithPassphrase lays out magic | version | salt | nonce | ciphertext
+func sealWithPassphrase(magic string, version byte, plaintext []byte, passphrase string) ([]byte, error) {
+	if len(passphrase) < minKeyPassphraseLen {
+		return nil, fmt.Errorf("passphrase must be at least %d characters", minKeyPassphraseLen)
+	}
+	salt := make([]byte, keyBundleSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	aead, err := keyBundleAEAD(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	header := append([]byte(magic), version)
+	out := append(header, salt...)
+	out = append(out, nonce...)
+	// The header is authenticated so the version cannot be swapped
+	return aead.Seal(out, nonce, plaintext, header), nil
+}
+
+// openWithPassphrase opens data sealed by sealWithPassphrase; name
+// describes the data in errors
+func openWithPassphrase(data []byte, magic string, version byte, passphrase, name string) ([]byte, error) {
+	headerLen := len(magic) + 1
+	if len(data) < headerLen+keyBundleSaltSize || !bytes.Equal(data[:len(magic)], []byte(magic)) {
+		return nil, fmt.Errorf("not a %s", name)
+	}
+	if v := data[len(magic)]; v != version {
+		return nil, fmt.Errorf("unsupported %s version %d", name, v)
+	}
+
+	header := data[:headerLen]
+	salt := data[headerLen : headerLen+keyBundleSaltSize]
+	aead, err := keyBundleAEAD(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	rest := data[headerLen+keyBundleSaltSize:]
+	if len(rest) < aead.NonceSize() {
+		return nil, fmt.Errorf("%s truncated", name)
+	}
+	plaintext, err := aead.Open(nil, rest[:aead.NonceSize()], rest[aead.NonceSize():], header)
+	if err != nil {
+		return nil, ErrBadPassphrase
+	}
+	return plaintext, nil
+}
+
+func keyBundleAEAD(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key := argon2.IDKey([]byte(passphrase), salt, keyBundleArgonTime, keyBundleArgonMemory, keyBundleArgonThreads, 32)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// KeyAuditEntry records one key export or import attempt
+type KeyAuditEntry struct {
+	Time   time.Time `json:"time"`
+	Action string    `json:"action"` // export, import, export_failed or import_failed
+	Files  int       `json:"files"`
+	Shares int       `json:"shares"`
+	Detail string    `json:"detail,omitempty"`
+}
+
+// KeyAuditLog keeps key escrow audit entries in memory and, when a path is
+// set, appends them to a JSON-lines file
+type KeyAuditLog struct {
+	path    string
+	entries []KeyAuditEntry
+	mu      sync.Mutex
+}
+
+// NewKeyAuditLog creates an audit log persisted at path (empty keeps it in memory)
+func NewKeyAuditLog(path string) *KeyAuditLog {
+	return &KeyAuditLog{path: path}
+}
+
+// Record appends an entry. A failure to persist is logged, not returned, so
+// that audit storage problems do not block recovery.
+func (a *KeyAuditLog) Record(entry KeyAuditEntry) {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	log.Printf("🔐 [AUDIT] Key %s: %d files, %d shares %s", entry.Action, entry.Files, entry.Shares, entry.Detail)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.entries = append(a.entries, entry)
+	if len(a.entries) > maxKeyAuditEntries {
+		a.entries = a.entries[len(a.entries)-maxKeyAuditEntries:]
+	}
+	if a.path == "" {
+		return
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	f, err := os.OpenFile(a.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		log.Printf("⚠️  Failed to open key audit log: %v", err)
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		log.Printf("⚠️  Failed to write key audit log: %v", err)
+	}
+}
+
+// Entries returns the recorded entries, oldest first
+func (a *KeyAuditLog) Entries() []KeyAuditEntry {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]KeyAuditEntry(nil), a.entries...)
+}
+
+// ExportKeys seals every DKG share this node holds under passphrase and
+// records the export in the audit log
+func (n *LibP2PPangeaNode) ExportKeys(passphrase string) ([]byte, *KeyBundle, error) {
+	bundle := &KeyBundle{
+		NodeID:   n.nodeID,
+		Exported: time.Now(),
+		Shares:   make(map[string]map[uint32][]byte),
+	}
+	n.dkgMu.RLock()
+	for fileID, shares := range n.dkgShares {
+		copied := make(map[uint32][]byte, len(shares))
+		for peerID, share := range shares {
+			copied[peerID] = share
+		}
+		bundle.Shares[fileID] = copied
+	}
+	n.dkgMu.RUnlock()
+
+	files, shares := bundle.counts()
+	data, err := EncryptKeyBundle(bundle, passphrase)
+	if err != nil {
+		n.keyAudit.Record(KeyAuditEntry{Action: "export_failed", Files: files, Shares: shares, Detail: err.Error()})
+		return nil, nil, err
+	}
+	n.keyAudit.Record(KeyAuditEntry{Action: "export", Files: files, Shares: shares})
+	return data, bundle, nil
+}
+
+// ImportKeys restores shares from a bundle. Shares already held are kept,
+// so an import never replaces newer key material. Returns the number of
+// files and shares added.
+func (n *LibP2PPangeaNode) ImportKeys(data []byte, passphrase string) (files, shares int, err error) {
+	bundle, err := DecryptKeyBundle(data, passphrase)
+	if err != nil {
+		n.keyAudit.Record(KeyAuditEntry{Action: "import_failed", Detail: err.Error()})
+		return 0, 0, err
+	}
+
+	n.dkgMu.Lock()
+	for fileID, bundled := range bundle.Shares {
+		held := n.dkgShares[fileID]
+		added := 0
+		for peerID, share := range bundled {
+			if _, exists := held[peerID]; exists {
+				continue
+			}
+			if held == nil {
+				held = make(map[uint32][]byte)
+				n.dkgShares[fileID] = held
+			}
+			held[peerID] = share
+			added++
+		}
+		if added > 0 {
+			files++
+			shares += added
+		}
+	}
+	n.dkgMu.Unlock()
+
+	n.keyAudit.Record(KeyAuditEntry{Action: "import", Files: files, Shares: shares,
+		Detail: fmt.Sprintf("from node %d bundle exported %s", bundle.NodeID, bundle.Exported.Format(time.RFC3339))})
+	return files, shares, nil
+}
+
+// SetKeyAuditLog replaces the key escrow audit log
+func (n *LibP2PPangeaNode) SetKeyAuditLog(audit *KeyAuditLog) {
+	n.keyAudit = audit
+}
+
+// GetKeyAuditLog returns the key escrow audit log
+func (n *LibP2PPangeaNode) GetKeyAuditLog() *KeyAuditLog {
+	return n.keyAudit
+}
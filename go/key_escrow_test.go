@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestKeyBundleRoundTrip(t *testing.T) {
+	bundle := &KeyBundle{NodeID: 7, Shares: map[string]map[uint32][]byte{
+		"file-a": {1: []byte("share-1"), 7: []byte("share-7")},
+	}}
+	data, err := EncryptKeyBundle(bundle, "correct horse")
+	if err != nil {
+		t.Fatalf("EncryptKeyBundle failed: %v", err)
+	}
+	if bytes.Contains(data, []byte("share-7")) {
+		t.Fatal("bundle contains plaintext share")
+	}
+
+	if _, err := DecryptKeyBundle(data, "wrong horse!"); err != ErrBadPassphrase {
+		t.Errorf("expected ErrBadPassphrase, got %v", err)
+	}
+	tampered := append([]byte(nil), data...)
+	tampered[len(keyBundleMagic)]++
+	if _, err := DecryptKeyBundle(tampered, "correct horse"); err == nil {
+		t.Error("expected a modified version byte to be rejected")
+	}
+
+	restored, err := DecryptKeyBundle(data, "correct horse")
+	if err != nil {
+		t.Fatalf("DecryptKeyBundle failed: %v", err)
+	}
+	if restored.NodeID != 7 || string(restored.Shares["file-a"][7]) != "share-7" {
+		t.Errorf("unexpected restored bundle: %+v", restored)
+	}
+	if _, err := EncryptKeyBundle(bundle, "short"); err == nil {
+		t.Error("expected a short passphrase to be rejected")
+	}
+}
+
+func TestExportImportKeysBetweenNodes(t *testing.T) {
+	source := &LibP2PPangeaNode{nodeID: 1, dkgShares: map[string]map[uint32][]byte{}, keyAudit: NewKeyAuditLog("")}
+	auditPath := filepath.Join(t.TempDir(), "key_audit.log")
+	target := &LibP2PPangeaNode{nodeID: 2, dkgShares: map[string]map[uint32][]byte{}, keyAudit: NewKeyAuditLog(auditPath)}
+
+	source.StoreDKGShare("file-a", 1, []byte("own"))
+	source.StoreDKGShare("file-b", 3, []byte("received"))
+	target.StoreDKGShare("file-a", 1, []byte("newer"))
+
+	data, _, err := source.ExportKeys("disaster recovery")
+	if err != nil {
+		t.Fatalf("ExportKeys failed: %v", err)
+	}
+	if _, _, err := target.ImportKeys(data, "not the passphrase"); err == nil {
+		t.Fatal("expected import with the wrong passphrase to fail")
+	}
+	files, shares, err := target.ImportKeys(data, "disaster recovery")
+	if err != nil {
+		t.Fatalf("ImportKeys failed: %v", err)
+	}
+	if files != 1 || shares != 1 {
+		t.Errorf("expected only file-b's share to be added, got %d files, %d shares", files, shares)
+	}
+	if string(target.dkgShares["file-a"][1]) != "newer" || string(target.dkgShares["file-b"][3]) != "received" {
+		t.Errorf("unexpected shares after import: %v", target.dkgShares)
+	}
+
+	if entries := source.GetKeyAuditLog().Entries(); len(entries) != 1 || entries[0].Action != "export" || entries[0].Shares != 2 {
+		t.Errorf("expected one audited export of 2 shares, got %+v", entries)
+	}
+	logged, err := os.ReadFile(auditPath)
+	if err != nil {
+		t.Fatalf("audit log not written: %v", err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(logged)), "\n"); len(lines) != 2 ||
+		!strings.Contains(lines[0], "import_failed") || !strings.Contains(lines[1], `"import"`) {
+		t.Errorf("unexpected audit log: %s", logged)
+	}
+}
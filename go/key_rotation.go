@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Key rotation states
+const (
+	RotationDownloading  = "downloading"  // Fetching and decrypting under the old key
+	RotationReencrypting = "reencrypting" // Dealing a fresh key and distributing new shards
+	RotationDeleting     = "deleting"     // Deleting the old shards and shares from their holders
+	RotationCompleted    = "completed"    // New manifest is available; see DeleteErrors for holders missed
+	RotationFailed       = "failed"       // Stopped on an error; the old shards are untouched
+)
+
+const (
+	// keyRotationTimeout bounds a whole rotation, transfers included
+	keyRotationTimeout = 30 * time.Minute
+
+	// keyRotationTTL is how long a finished rotation stays queryable
+	keyRotationTTL = 24 * time.Hour
+
+	deleteShardsTimeout = 15 * time.Second
+)
+
+// KeyRotation is the state of one content key rotation. A rotation
+// downloads a file under its old key, re-encrypts it under a freshly dealt
+// key as new shards with a new file hash, and then has every old holder
+// securely delete the old shards and key shares. The old content stays
+// readable until the new shards reach quorum.
+type KeyRotation struct {
+	ID             string
+	FileHash       string // File hash under the old key
+	NewFileHash    string // File hash under the new key, set once re-encrypted
+	State          string
+	FileName       string
+	FileSize       uint64
+	TTL            uint32
+	ContentHash    string           // BLAKE3 of the file, unchanged by the new key
+	Placements     []shardPlacement // New shards
+	Holders        uint32           // Old holders asked to delete
+	HoldersCleared uint32           // Old holders that confirmed deletion
+	ShardsDeleted  uint32
+	DeleteErrors   []string
+	Error          string
+	Started        time.Time
+	Updated        time.Time
+}
+
+// snapshot copies the rotation for callers outside the manager's lock
+func (r *KeyRotation) snapshot() KeyRotation {
+	c := *r
+	c.Placements = append([]shardPlacement(nil), r.Placements...)
+	c.DeleteErrors = append([]string(nil), r.DeleteErrors...)
+	return c
+}
+
+// manifest is the manifest of the re-keyed file, once the rotation
+// completed
+func (r *KeyRotation) manifest() *fileManifest {
+	return &fileManifest{
+		FileHash:    r.NewFileHash,
+		FileName:    r.FileName,
+		FileSize:    r.FileSize,
+		ShardCount:  uint32(len(r.Placements)),
+		ParityCount: cesParityShards,
+		Placements:  r.Placements,
+		Timestamp:   r.Updated.Unix(),
+		TTL:         r.TTL,
+		ContentHash: r.ContentHash,
+	}
+}
+
+// keyRotationRunner performs a rotation, reporting progress through update
+type keyRotationRunner func(ctx context.Context, update func(func(*KeyRotation))) error
+
+// KeyRotationManager runs content key rotations in the background
+type KeyRotationManager struct {
+	jobs map[string]*KeyRotation
+	mu   sync.Mutex
+}
+
+// NewKeyRotationManager creates an empty manager
+func NewKeyRotationManager() *KeyRotationManager {
+	return &KeyRotationManager{jobs: make(map[string]*KeyRotation)}
+}
+
+// Start begins rotating the key of a file and returns the job ID. A
+// rotation of the same file already in progress is refused.
+func (m *KeyRotationManager) Start(fileHash, fileName string, fileSize uint64, ttl uint32, run keyRotationRunner) (string, error) {
+	if fileHash == "" {
+		return "", fmt.Errorf("no file hash to rotate")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, r := range m.jobs {
+		finished := r.State == RotationCompleted || r.State == RotationFailed
+		if r.FileHash == fileHash && !finished {
+			return "", fmt.Errorf("%s is already being rotated by %s", fileHash, id)
+		}
+		if finished && time.Since(r.Updated) > keyRotationTTL {
+			delete(m.jobs, id)
+		}
+	}
+
+	b := make([]byte, 8)
+	rand.Read(b)
+	r := &KeyRotation{
+		ID:       "rot-" + hex.EncodeToString(b),
+		FileHash: fileHash,
+		State:    RotationDownloading,
+		FileName: fileName,
+		FileSize: fileSize,
+		TTL:      ttl,
+		Started:  time.Now(),
+		Updated:  time.Now(),
+	}
+	m.jobs[r.ID] = r
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), keyRotationTimeout)
+		defer cancel()
+		update := func(fn func(*KeyRotation)) {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			fn(r)
+			r.Updated = time.Now()
+		}
+		err := run(ctx, update)
+		update(func(r *KeyRotation) {
+			if err != nil {
+				r.State, r.Error = RotationFailed, err.Error()
+			} else {
+				r.State = RotationCompleted
+			}
+		})
+		if err != nil {
+			log.Printf("❌ [ROTATE] %s of %s failed: %v", r.ID, fileHash, err)
+		} else {
+			log.Printf("🔑 [ROTATE] %s re-keyed %s as %s", r.ID, fileHash, r.NewFileHash)
+		}
+	}()
+	log.Printf("🔑 [ROTATE] Started %s for %s", r.ID, fileHash)
+	return r.ID, nil
+}
+
+// Rotation returns a rotation's state
+func (m *KeyRotationManager) Rotation(id string) (KeyRotation, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, ok := m.jobs[id]
+	if !ok {
+		return KeyRotation{}, false
+	}
+	return r.snapshot(), true
+}
+
+// newRotatedFileHash names a file's content under a new key. The new
+// shards and key shares must not overwrite the old ones, which stay in
+// use until the new shards reach quorum.
+func newRotatedFileHash(fileHash string) string {
+	salt := make([]byte, 16)
+	rand.Read(salt)
+	sum := sha256.Sum256(append([]byte(fileHash), salt...))
+	return hex.EncodeToString(sum[:])
+}
+
+// recordOrigin remembers the peer that first stored a shard or key share
+// of fileHash here, the only peer that may later delete them
+func (n *LibP2PPangeaNode) recordOrigin(fileHash string, from peer.ID) {
+	n.shardMu.Lock()
+	defer n.shardMu.Unlock()
+	if _, ok := n.shardOrigins[fileHash]; !ok {
+		n.shardOrigins[fileHash] = from
+	}
+}
+
+// DeleteFileData securely deletes every shard and the DKG share of
+// fileHash held here, overwriting them before they are dropped, and
+// returns the number of shards deleted
+func (n *LibP2PPangeaNode) DeleteFileData(fileHash string) int {
+	n.shardMu.Lock()
+	shards := n.shardStore[fileHash]
+	for _, data := range shards {
+		clear(data)
+	}
+	events := n.dropShardsLocked(fileHash)
+	delete(n.shardOrigins, fileHash)
+	dm := n.disk
+	n.shardMu.Unlock()
+	dm.emit(events)
+
+	n.dkgMu.Lock()
+	for _, share := range n.dkgShares[fileHash] {
+		clear(share)
+	}
+	delete(n.dkgShares, fileHash)
+	n.dkgMu.Unlock()
+	n.refresh.Untrack(fileHash)
+
+	if len(shards) > 0 {
+		n.publishShardsDropped(fileHash, "deleted")
+	}
+	return len(shards)
+}
+
+// deleteRemoteFileData has a peer securely delete its shards and DKG share
+// of fileHash, returning the number of shards it deleted
+func (n *LibP2PPangeaNode) deleteRemoteFileData(ctx context.Context, p peer.ID, fileHash string) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, deleteShardsTimeout)
+	defer cancel()
+	rs, err := openRPCStream(ctx, n.host, p)
+	if err != nil {
+		return 0, err
+	}
+	defer rs.Close()
+	reply, err := rs.call(rpcMsgDeleteShards, appendRPCString(nil, fileHash), rpcMsgDeleteShardsAck)
+	if err != nil {
+		return 0, err
+	}
+	if len(reply) != 4 {
+		return 0, fmt.Errorf("%w: bad delete ack", errRPCBadFrame)
+	}
+	return int(binary.BigEndian.Uint32(reply)), nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestDeleteShardsOnlyByStoringPeer(t *testing.T) {
+	var nodes []*LibP2PPangeaNode
+	for i, port := range []int{12650, 12651, 12652} {
+		n, err := NewLibP2PPangeaNodeWithOptions(uint32(651+i), NewNodeStore(), false, true, port)
+		if err != nil {
+			t.Fatalf("failed to create node %d: %v", i, err)
+		}
+		defer n.cancel()
+		nodes = append(nodes, n)
+	}
+	owner, holder, stranger := nodes[0], nodes[1], nodes[2]
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	for _, n := range []*LibP2PPangeaNode{owner, stranger} {
+		if err := n.host.Connect(ctx, peer.AddrInfo{ID: holder.host.ID(), Addrs: holder.host.Addrs()}); err != nil {
+			t.Fatalf("connect to holder failed: %v", err)
+		}
+	}
+
+	rs, err := openRPCStream(ctx, owner.host, holder.host.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	shard := []byte("old ciphertext")
+	if ack, err := pushShardStream(rs, "old", 3, shard, nil); err != nil || ack[0] != shardAckStored {
+		t.Fatalf("push failed: %v", err)
+	}
+	rs.Close()
+	rs, err = openRPCStream(ctx, owner.host, holder.host.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := appendRPCString(nil, "old")
+	req = append(req, 0, 0, 0, 1)
+	if _, err := rs.call(rpcMsgStoreShare, append(req, "share"...), rpcMsgStoreShareAck); err != nil {
+		t.Fatalf("store share failed: %v", err)
+	}
+	rs.Close()
+	held, _ := holder.FetchLocalShard("old", 3)
+
+	if _, err := stranger.deleteRemoteFileData(ctx, holder.host.ID(), "old"); err == nil || !strings.Contains(err.Error(), "access denied") {
+		t.Fatalf("expected a stranger's delete refused, got %v", err)
+	}
+	if _, ok := holder.FetchLocalShard("old", 3); !ok {
+		t.Fatal("shard deleted by a stranger")
+	}
+
+	deleted, err := owner.deleteRemoteFileData(ctx, holder.host.ID(), "old")
+	if err != nil || deleted != 1 {
+		t.Fatalf("expected 1 shard deleted, got %d (%v)", deleted, err)
+	}
+	if _, ok := holder.FetchLocalShard("old", 3); ok {
+		t.Fatal("shard still held after delete")
+	}
+	if _, ok := holder.GetLocalShare("old"); ok {
+		t.Fatal("key share still held after delete")
+	}
+	for _, b := range held {
+		if b != 0 {
+			t.Fatal("deleted shard was not overwritten")
+		}
+	}
+	// The origin is forgotten with the data
+	if _, err := owner.deleteRemoteFileData(ctx, holder.host.ID(), "old"); err == nil {
+		t.Fatal("expected a second delete to be refused")
+	}
+}
+
+func TestKeyRotationManagerTracksJobs(t *testing.T) {
+	m := NewKeyRotationManager()
+	release := make(chan struct{})
+	id, err := m.Start("file", "a.txt", 10, 0, func(ctx context.Context, update func(func(*KeyRotation))) error {
+		update(func(r *KeyRotation) { r.State = RotationReencrypting })
+		<-release
+		update(func(r *KeyRotation) {
+			r.NewFileHash = "new"
+			r.HoldersCleared = 2
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Start("file", "a.txt", 10, 0, nil); err == nil {
+		t.Fatal("expected a second rotation of the same file refused")
+	}
+	close(release)
+
+	var r KeyRotation
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		r, _ = m.Rotation(id)
+		if r.State == RotationCompleted || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if r.State != RotationCompleted || r.NewFileHash != "new" || r.HoldersCleared != 2 {
+		t.Fatalf("unexpected rotation %+v", r)
+	}
+
+	failed, _ := m.Start("file", "a.txt", 10, 0, func(ctx context.Context, update func(func(*KeyRotation))) error {
+		return errors.New("insufficient shards")
+	})
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		r, _ = m.Rotation(failed)
+		if r.State == RotationFailed || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if r.State != RotationFailed || r.Error != "insufficient shards" {
+		t.Fatalf("expected a failed rotation, got %+v", r)
+	}
+	if newRotatedFileHash("file") == newRotatedFileHash("file") {
+		t.Fatal("rotated file hashes must not repeat")
+	}
+}
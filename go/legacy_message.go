@@ -0,0 +1,323 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Messages on legacy P2P connections:
+//
+//	[type(1)][requestID(4)][length(4)][body]
+//
+// Integers are big endian. A message is encrypted in pieces of at most
+// legacyMaxPlaintext bytes, one Noise frame each, and the reader joins the
+// pieces back together using the length field. A request carries a nonzero
+// request ID that its response echoes; one-way messages carry 0.
+const (
+	legacyMsgHeaderSize = 9
+
+	// legacyMaxPlaintext is what fits in one frame beside the AEAD tag
+	legacyMaxPlaintext = legacyMaxFrame - 16
+
+	// legacyMaxMessage covers a whole shard plus its request fields
+	legacyMaxMessage = maxShardSize + 64*1024
+)
+
+// legacyMsgType identifies a legacy message's body
+type legacyMsgType uint8
+
+const (
+	legacyMsgPing          legacyMsgType = 1  // [nonce]
+	legacyMsgPong          legacyMsgType = 2  // [nonce], echoed from the ping
+	legacyMsgError         legacyMsgType = 3  // [message]
+	legacyMsgData          legacyMsgType = 4  // Opaque SendMessage payload
+	legacyMsgFetchShard    legacyMsgType = 5  // [fileHash][shardIndex(4)]
+	legacyMsgShardData     legacyMsgType = 6  // [shard]
+	legacyMsgStoreShard    legacyMsgType = 7  // [fileHash][shardIndex(4)][shard]
+	legacyMsgStoreShardAck legacyMsgType = 8  // [status(1)][sha256(shard)(32)]
+	legacyMsgFetchShare    legacyMsgType = 9  // [fileID]
+	legacyMsgShareData     legacyMsgType = 10 // [share]
+	legacyMsgStoreShare    legacyMsgType = 11 // [fileID][fromPeer(4)][share]
+	legacyMsgStoreShareAck legacyMsgType = 12 // empty
+)
+
+// Strings in bodies are [length(2)][bytes], as on the RPC protocol
+
+// errLegacyBadMessage marks messages that break the protocol
+var errLegacyBadMessage = errors.New("malformed legacy message")
+
+// legacyMessage is one decoded message
+type legacyMessage struct {
+	Type      legacyMsgType
+	RequestID uint32
+	Body      []byte
+}
+
+// legacyHandler serves one message type. For a request, the returned type
+// and body are sent back as the response; a returned error is sent as an
+// error message instead. Responses to one-way messages are dropped.
+type legacyHandler func(c *P2PConnection, body []byte) (legacyMsgType, []byte, error)
+
+// Handle registers the handler for a message type, replacing any earlier one
+func (p *P2PNode) Handle(t legacyMsgType, h legacyHandler) {
+	p.handlerMu.Lock()
+	defer p.handlerMu.Unlock()
+	p.handlers[t] = h
+}
+
+// registerDefaultHandlers serves the operations the libp2p RPC protocol
+// offers: pings, user messages, and shard and DKG share transfers
+func (p *P2PNode) registerDefaultHandlers() {
+	p.Handle(legacyMsgPing, func(c *P2PConnection, body []byte) (legacyMsgType, []byte, error) {
+		if len(body) > legacyNonceSize {
+			return 0, nil, fmt.Errorf("%w: %d byte ping nonce", errLegacyBadMessage, len(body))
+		}
+		return legacyMsgPong, body, nil
+	})
+
+	p.Handle(legacyMsgData, func(c *P2PConnection, body []byte) (legacyMsgType, []byte, error) {
+		p.handlerMu.RLock()
+		onMessage := p.onMessage
+		p.handlerMu.RUnlock()
+		if onMessage != nil {
+			onMessage(c.id, body)
+		} else {
+			log.Printf("📨 Received %d byte message from node %d", len(body), c.id)
+		}
+		return 0, nil, nil
+	})
+
+	p.Handle(legacyMsgFetchShard, func(c *P2PConnection, body []byte) (legacyMsgType, []byte, error) {
+		req := &rpcPayload{b: body}
+		fileHash, shardIdx := req.string(), req.uint32()
+		if req.err != nil {
+			return 0, nil, req.err
+		}
+		data, ok := p.FetchLocalShard(fileHash, shardIdx)
+		if !ok {
+			return 0, nil, fmt.Errorf("shard %d of %s not found", shardIdx, fileHash)
+		}
+		return legacyMsgShardData, data, nil
+	})
+
+	p.Handle(legacyMsgStoreShard, func(c *P2PConnection, body []byte) (legacyMsgType, []byte, error) {
+		req := &rpcPayload{b: body}
+		fileHash, shardIdx, data := req.string(), req.uint32(), req.rest()
+		if req.err != nil {
+			return 0, nil, req.err
+		}
+		status := shardAckStored
+		if len(data) > maxShardSize {
+			log.Printf("🚫 Rejected shard %d for %s: exceeds %d bytes", shardIdx, fileHash, maxShardSize)
+			status = shardAckTooLarge
+		} else {
+			p.StoreShard(fileHash, shardIdx, data)
+		}
+		digest := sha256.Sum256(data)
+		return legacyMsgStoreShardAck, append([]byte{status}, digest[:]...), nil
+	})
+
+	p.Handle(legacyMsgFetchShare, func(c *P2PConnection, body []byte) (legacyMsgType, []byte, error) {
+		req := &rpcPayload{b: body}
+		fileID := req.string()
+		if req.err != nil {
+			return 0, nil, req.err
+		}
+		share, ok := p.GetLocalShare(fileID)
+		if !ok {
+			return 0, nil, fmt.Errorf("no share for %s", fileID)
+		}
+		return legacyMsgShareData, share, nil
+	})
+
+	p.Handle(legacyMsgStoreShare, func(c *P2PConnection, body []byte) (legacyMsgType, []byte, error) {
+		// The dealer's node ID is informational, as on the RPC protocol
+		req := &rpcPayload{b: body}
+		fileID, _, share := req.string(), req.uint32(), req.rest()
+		if req.err != nil {
+			return 0, nil, req.err
+		}
+		p.StoreDKGShare(fileID, share)
+		return legacyMsgStoreShareAck, nil, nil
+	})
+}
+
+// dispatch routes a message: responses go to the caller waiting on their
+// request ID, everything else to the handler for its type
+func (p *P2PNode) dispatch(c *P2PConnection, msg legacyMessage) {
+	if isLegacyResponse(msg.Type) {
+		if !c.deliver(msg) {
+			log.Printf("Dropped unexpected type %d response from node %d", msg.Type, c.id)
+		}
+		return
+	}
+
+	p.handlerMu.RLock()
+	h := p.handlers[msg.Type]
+	p.handlerMu.RUnlock()
+
+	var respType legacyMsgType
+	var resp []byte
+	var err error
+	if h == nil {
+		err = fmt.Errorf("%w: unknown message type %d", errLegacyBadMessage, msg.Type)
+	} else {
+		respType, resp, err = h(c, msg.Body)
+	}
+	if err != nil {
+		log.Printf("❌ Type %d message from node %d failed: %v", msg.Type, c.id, err)
+		respType, resp = legacyMsgError, []byte(err.Error())
+	}
+	if msg.RequestID == 0 || respType == 0 {
+		return
+	}
+	if err := c.send(respType, msg.RequestID, resp); err != nil {
+		log.Printf("Failed to answer node %d: %v", c.id, err)
+	}
+}
+
+// isLegacyResponse reports whether t only ever answers a request
+func isLegacyResponse(t legacyMsgType) bool {
+	switch t {
+	case legacyMsgPong, legacyMsgError, legacyMsgShardData, legacyMsgStoreShardAck, legacyMsgShareData, legacyMsgStoreShareAck:
+		return true
+	}
+	return false
+}
+
+// send encrypts a message and writes it in as many frames as it needs.
+// Frames of one message are never interleaved with another's.
+func (c *P2PConnection) send(t legacyMsgType, requestID uint32, body []byte) error {
+	if len(body) > legacyMaxMessage {
+		return fmt.Errorf("message of %d bytes exceeds %d", len(body), legacyMaxMessage)
+	}
+	msg := make([]byte, legacyMsgHeaderSize, legacyMsgHeaderSize+len(body))
+	msg[0] = byte(t)
+	binary.BigEndian.PutUint32(msg[1:], requestID)
+	binary.BigEndian.PutUint32(msg[5:], uint32(len(body)))
+	msg = append(msg, body...)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(msg) > 0 {
+		n := min(len(msg), legacyMaxPlaintext)
+		ciphertext, err := c.sendCS.Encrypt(nil, nil, msg[:n])
+		if err != nil {
+			return err
+		}
+		if err := writeFrame(c.conn, ciphertext); err != nil {
+			return err
+		}
+		msg = msg[n:]
+	}
+	return nil
+}
+
+// receive reads and decrypts frames until one whole message has arrived
+func (c *P2PConnection) receive() (legacyMessage, error) {
+	buf, err := c.readPiece()
+	if err != nil {
+		return legacyMessage{}, err
+	}
+	if len(buf) < legacyMsgHeaderSize {
+		return legacyMessage{}, fmt.Errorf("%w: %d byte header", errLegacyBadMessage, len(buf))
+	}
+	msg := legacyMessage{
+		Type:      legacyMsgType(buf[0]),
+		RequestID: binary.BigEndian.Uint32(buf[1:]),
+	}
+	length := binary.BigEndian.Uint32(buf[5:])
+	if length > legacyMaxMessage {
+		return msg, fmt.Errorf("%w: %d byte body exceeds %d", errLegacyBadMessage, length, legacyMaxMessage)
+	}
+	body := buf[legacyMsgHeaderSize:]
+	for uint32(len(body)) < length {
+		piece, err := c.readPiece()
+		if err != nil {
+			return msg, err
+		}
+		body = append(body, piece...)
+	}
+	if uint32(len(body)) != length {
+		return msg, fmt.Errorf("%w: body of %d bytes, header says %d", errLegacyBadMessage, len(body), length)
+	}
+	msg.Body = body
+	return msg, nil
+}
+
+// readPiece reads and decrypts one frame
+func (c *P2PConnection) readPiece() ([]byte, error) {
+	frame, err := readFrame(c.conn)
+	if err != nil {
+		return nil, err
+	}
+	piece, err := c.recvCS.Decrypt(nil, nil, frame)
+	if err != nil {
+		// The nonces are out of step now, so the connection is unusable
+		return nil, fmt.Errorf("decryption failed: %w", err)
+	}
+	return piece, nil
+}
+
+// call sends a request and waits up to timeout for its response, which
+// must have type want. An error response is returned as an error.
+func (c *P2PConnection) call(t legacyMsgType, body []byte, want legacyMsgType, timeout time.Duration) ([]byte, error) {
+	return c.callContext(context.Background(), t, body, want, timeout)
+}
+
+// callContext is call that also gives up when ctx ends
+func (c *P2PConnection) callContext(ctx context.Context, t legacyMsgType, body []byte, want legacyMsgType, timeout time.Duration) ([]byte, error) {
+	c.pendingMu.Lock()
+	c.nextRequestID++
+	if c.nextRequestID == 0 {
+		c.nextRequestID++
+	}
+	id := c.nextRequestID
+	reply := make(chan legacyMessage, 1)
+	c.pending[id] = reply
+	c.pendingMu.Unlock()
+	defer func() {
+		c.pendingMu.Lock()
+		delete(c.pending, id)
+		c.pendingMu.Unlock()
+	}()
+
+	if err := c.send(t, id, body); err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case msg := <-reply:
+		if msg.Type == legacyMsgError {
+			return nil, fmt.Errorf("peer error: %s", msg.Body)
+		}
+		if msg.Type != want {
+			return nil, fmt.Errorf("%w: expected type %d, got %d", errLegacyBadMessage, want, msg.Type)
+		}
+		return msg.Body, nil
+	case <-c.closed:
+		return nil, ErrPeerNotConnected
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-timer.C:
+		return nil, fmt.Errorf("no response from node %d within %v", c.id, timeout)
+	}
+}
+
+// deliver hands a response to the caller waiting on its request ID
+func (c *P2PConnection) deliver(msg legacyMessage) bool {
+	c.pendingMu.Lock()
+	reply, ok := c.pending[msg.RequestID]
+	delete(c.pending, msg.RequestID)
+	c.pendingMu.Unlock()
+	if ok {
+		reply <- msg
+	}
+	return ok
+}
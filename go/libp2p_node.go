@@ -2,15 +2,19 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
 	"fmt"
-	"io"
 	"log"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/libp2p/go-libp2p"
 	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/metrics"
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/protocol"
@@ -21,18 +25,39 @@ import (
 	"github.com/libp2p/go-libp2p/p2p/discovery/routing"
 	"github.com/libp2p/go-libp2p/p2p/muxer/yamux"
 	"github.com/libp2p/go-libp2p/p2p/net/connmgr"
+	"github.com/libp2p/go-libp2p/p2p/net/swarm"
 	"github.com/libp2p/go-libp2p/p2p/protocol/ping"
 	"github.com/libp2p/go-libp2p/p2p/security/noise"
 	quic "github.com/libp2p/go-libp2p/p2p/transport/quic"
 	"github.com/libp2p/go-libp2p/p2p/transport/tcp"
+	libp2pwebrtc "github.com/libp2p/go-libp2p/p2p/transport/webrtc"
+	"github.com/libp2p/go-libp2p/p2p/transport/websocket"
 
+	"github.com/pangea-net/go-node/pkg/communication"
 	"github.com/pangea-net/go-node/pkg/compute"
 )
 
 const (
 	// Protocol IDs for Pangea Net
-	PangeaRPCProtocol    = "/pangea/rpc/1.0.0"
+	PangeaRPCProtocol    = "/pangea/rpc/2.0.0" // Framed; see rpc_frame.go
 	PangeaDiscoveryTopic = "pangea-network"
+
+	// maxShardSize bounds a single shard a node stores or fetches. Shards
+	// stream in chunks, so this is a storage policy rather than a transfer
+	// limit. CES emits 12 shards, so this covers files up to roughly 128 MB.
+	maxShardSize = 16 * 1024 * 1024
+
+	// shardAckStored is the status byte sent back once a shard is persisted
+	shardAckStored byte = 1
+	// shardAckQuotaExceeded is sent when the node is out of storage quota
+	shardAckQuotaExceeded byte = 2
+	// shardAckTooLarge is sent when a shard exceeds maxShardSize
+	shardAckTooLarge byte = 3
+	// shardAckCorrupt is sent when a shard does not match its trailing hash
+	shardAckCorrupt byte = 4
+	// shardAckForbidden is sent when the shard's file is restricted to
+	// another owner
+	shardAckForbidden byte = 5
 )
 
 // ReachabilityStatus represents the NAT reachability status
@@ -74,17 +99,123 @@ type LibP2PPangeaNode struct {
 	natType         NATType
 	reachabilityMu  sync.RWMutex
 	computeProtocol *ComputeProtocol
+	comm            *communication.CommunicationService // Chat/voice/video over libp2p
+
+	// Bandwidth accounting for all streams opened on the host
+	bwCounter  *metrics.BandwidthCounter
+	protoStats *ProtocolStats // Per-peer, per-protocol usage
+
+	// Periodic latency/jitter/loss measurement for connected peers
+	prober *QualityProber
+
+	// Store-forward relay for intermittently connected peers
+	relay *RelayService
+
+	// Software versions advertised by connected peers
+	versions *VersionTracker
+
+	// Dataset chunk delivery to ML training workers
+	mlData *MLDataService
+
+	// ML training tasks coordinated by this node, shared by all RPC clients
+	mlCoordinator *MLCoordinator
+
+	// Chat sessions and keys, shared by all RPC clients
+	security *SecurityManager
+
+	// Encrypted ephemeral chat delivery
+	chat *ChatService
+
+	// Node events for RPC subscribers and webhooks
+	events *EventBus
+
+	// Per-subsystem health for RPC clients and orchestration probes
+	health *HealthMonitor
+
+	// Test-only faults injected into shard fetches, compute responses and
+	// file transfer streams; configurable only with -chaos
+	faults *FaultInjector
+
+	// Peers dialed to seed the DHT; the DHT falls back to them whenever its
+	// routing table empties
+	bootstrap      *bootstrapSet
+	privateNetwork bool   // Only swarm key holders can connect
+	webrtc         bool   // Browsers can connect over WebRTC-direct
+	topic          string // mDNS service tag and DHT topic of the namespace
+
+	// Direct peer-to-peer file transfers
+	files *FileTransferService
+
+	// Background downloads of files stored in the swarm
+	downloads *DownloadManager
+
+	// Distributed key generation and the group key shares it produced
+	dkg *NetworkDKG
+
+	// Threshold signing with DKG-generated group keys
+	tsign *ThresholdSigner
+
+	// Peers the user verified by safety number
+	verifier *PeerVerifier
+
+	// Per-peer outbound queues for compute, shard and chat sends
+	sendQueues *SendQueues
+
+	// Rate limits on shard uploads and fetches served to peers
+	bandwidth *BandwidthScheduler
+
+	// Signed node state exchanged with the swarm and merged into store
+	gossip *StateGossip
+
+	// Signed name records mapping human-readable names to manifest hashes
+	names *NameRegistry
+
+	// Restricted uploads, issued and held grants, and owners of the
+	// restricted shards stored here
+	access *AccessControl
+
+	// Content key rotations started on this node
+	rotations *KeyRotationManager
+
+	// Per-peer threat scores; also gates connections from blocked peers
+	threat *ThreatEngine
+
+	// Discovery and status loop periods, adapted to network stability
+	discoveryPace *AdaptiveInterval
+	statusPace    *AdaptiveInterval
+
+	// Detects loss of contact with most of the swarm and defers destructive
+	// operations while it lasts
+	partition *PartitionDetector
 
 	// Local stores for shards and DKG shares
-	shardStore map[string]map[uint32][]byte // fileHash -> shardIndex -> data
-	shardMu    sync.RWMutex
+	shardStore   map[string]map[uint32][]byte // fileHash -> shardIndex -> data
+	shardStored  map[string]time.Time         // fileHash -> when a shard of it was last stored
+	shardOrigins map[string]peer.ID           // fileHash -> peer that first stored its shards or share here
+	shardMu      sync.RWMutex
+	disk         *DiskMonitor       // Storage quota accounting, guarded by shardMu
+	diskCancel   context.CancelFunc // Stops the current monitor's rescans, guarded by shardMu
+	retention    ShardRetention     // When held shards are dropped, guarded by shardMu
+	pins         *PinSet            // Content exempt from retention
 
 	dkgShares map[string]map[uint32][]byte // fileID -> peerID -> share bytes
 	dkgMu     sync.RWMutex
+	keyAudit  *KeyAuditLog // Records every key export and import
+
+	// Proactive refresh of dkgShares
+	refresh *ShareRefresher
 }
 
 func NewLibP2PPangeaNodeWithOptions(nodeID uint32, store *NodeStore, localMode bool, testMode bool, port int) (*LibP2PPangeaNode, error) {
+	return NewLibP2PPangeaNodeWithNetworkOptions(nodeID, store, localMode, testMode, port, NetworkOptions{})
+}
+
+// NewLibP2PPangeaNodeWithNetworkOptions creates a node with a custom bootstrap
+// set or on a private network
+func NewLibP2PPangeaNodeWithNetworkOptions(nodeID uint32, store *NodeStore, localMode bool, testMode bool, port int, netOpts NetworkOptions) (*LibP2PPangeaNode, error) {
 	ctx, cancel := context.WithCancel(context.Background())
+	private := netOpts.SwarmKey != nil
+	threat := NewThreatEngine(netOpts.Threat)
 
 	connMgr, err := connmgr.NewConnManager(
 		100, // low watermark
@@ -96,57 +227,113 @@ func NewLibP2PPangeaNodeWithOptions(nodeID uint32, store *NodeStore, localMode b
 		return nil, fmt.Errorf("failed to create connection manager: %w", err)
 	}
 
+	// Bandwidth counter records bytes per peer and per protocol; protoStats
+	// also attributes each stream's traffic to a (peer, protocol) pair
+	bwCounter := metrics.NewBandwidthCounter()
+	protoStats := NewProtocolStats(bwCounter)
+
 	var libp2pOptions []libp2p.Option
 
+	// Network transports - TCP, QUIC for different scenarios. A private
+	// network is TCP only because QUIC cannot carry the pre-shared key.
+	libp2pOptions = append(libp2pOptions, libp2p.Transport(tcp.NewTCPTransport))
+	if private {
+		libp2pOptions = append(libp2pOptions, libp2p.PrivateNetwork(netOpts.SwarmKey))
+		log.Printf("🔒 PRIVATE NETWORK: Only peers with the swarm key can connect")
+	} else {
+		libp2pOptions = append(libp2pOptions, libp2p.Transport(quic.NewTransport))
+	}
+	webrtc := netOpts.WebRTC && !private
+	if webrtc {
+		libp2pOptions = append(libp2pOptions, libp2p.Transport(libp2pwebrtc.New))
+	} else if netOpts.WebRTC {
+		log.Printf("⚠️  WebRTC disabled: it cannot carry the swarm key of a private network")
+	}
+	// WebSocket runs over TCP, so it carries the swarm key like TCP does
+	wsListen := ""
+	if netOpts.WebSocketPort > 0 {
+		wsListen = "ws"
+		var wsOpts []interface{}
+		if netOpts.WebSocketTLS != nil {
+			wsListen = "tls/ws"
+			wsOpts = append(wsOpts, websocket.WithTLSConfig(netOpts.WebSocketTLS))
+		}
+		libp2pOptions = append(libp2pOptions, libp2p.Transport(websocket.New, wsOpts...))
+	}
+
 	// Basic configuration
 	libp2pOptions = append(libp2pOptions,
-		// Network transports - TCP, QUIC for different scenarios
-		libp2p.Transport(tcp.NewTCPTransport),
-		libp2p.Transport(quic.NewTransport),
-
 		// Security and multiplexing
 		libp2p.Security(noise.ID, noise.New), // Noise Protocol for security
 		libp2p.Muxer(yamux.ID, yamux.DefaultTransport),
 
 		// Connection management
 		libp2p.ConnectionManager(connMgr),
+		libp2p.ConnectionGater(threat),
 
 		// Resource management
 		libp2p.ResourceManager(&network.NullResourceManager{}),
 
+		// Bandwidth metering
+		libp2p.BandwidthReporter(protoStats),
+
+		// Happy eyeballs between the address families of dual-stack peers
+		libp2p.SwarmOpts(swarm.WithDialRanker(dualStackDialRanker(netOpts.PreferIPv4))),
+
+		// Advertise our version in the identify handshake
+		libp2p.UserAgent(NodeAgentVersion()),
+
 		// Address filtering - don't announce localhost addresses
 		libp2p.AddrsFactory(func(addrs []multiaddr.Multiaddr) []multiaddr.Multiaddr {
 			filtered := make([]multiaddr.Multiaddr, 0, len(addrs))
 			for _, addr := range addrs {
-				addrStr := addr.String()
 				// Skip localhost addresses
-				if !strings.Contains(addrStr, "127.0.0.1") && !strings.Contains(addrStr, "::1") {
+				if ip := addrIP(addr); ip == nil || !ip.IsLoopback() {
 					filtered = append(filtered, addr)
 				}
 			}
-			return filtered
+			// Addresses a reverse proxy or port forward exposes
+			return append(filtered, netOpts.AnnounceAddrs...)
 		}),
 	)
 
 	// Configure listen addresses based on mode
 	if localMode {
 		// Local mode: only bind to localhost and use random ports
-		libp2pOptions = append(libp2pOptions,
-			libp2p.ListenAddrStrings(
-				"/ip4/127.0.0.1/tcp/0",      // Localhost TCP
-				"/ip4/127.0.0.1/udp/0/quic", // Localhost QUIC
-			),
-		)
+		var listenAddrs []string
+		for _, ip := range listenIPs("/ip4/127.0.0.1", "/ip6/::1", netOpts.DisableIPv6) {
+			listenAddrs = append(listenAddrs, ip+"/tcp/0") // Localhost TCP
+			if !private {
+				listenAddrs = append(listenAddrs, ip+"/udp/0/quic") // Localhost QUIC
+			}
+			if webrtc {
+				listenAddrs = append(listenAddrs, ip+"/udp/0/webrtc-direct") // Localhost WebRTC
+			}
+			if wsListen != "" {
+				listenAddrs = append(listenAddrs, ip+"/tcp/0/"+wsListen) // Localhost WebSocket
+			}
+		}
+		libp2pOptions = append(libp2pOptions, libp2p.ListenAddrStrings(listenAddrs...))
 		if testMode {
 			log.Printf("🏠 LOCAL MODE: Binding only to localhost")
 		}
 	} else {
 		// WAN mode: bind to all interfaces with NAT traversal
+		var listenAddrs []string
+		for _, ip := range listenIPs("/ip4/0.0.0.0", "/ip6/::", netOpts.DisableIPv6) {
+			listenAddrs = append(listenAddrs, fmt.Sprintf("%s/tcp/%d", ip, port)) // All interfaces TCP - FIXED PORT
+			if !private {
+				listenAddrs = append(listenAddrs, fmt.Sprintf("%s/udp/%d/quic", ip, port)) // All interfaces QUIC - FIXED PORT
+			}
+			if webrtc {
+				listenAddrs = append(listenAddrs, fmt.Sprintf("%s/udp/%d/webrtc-direct", ip, port)) // Browsers - FIXED PORT
+			}
+			if wsListen != "" {
+				listenAddrs = append(listenAddrs, fmt.Sprintf("%s/tcp/%d/%s", ip, netOpts.WebSocketPort, wsListen)) // Firewalled networks - FIXED PORT
+			}
+		}
 		libp2pOptions = append(libp2pOptions,
-			libp2p.ListenAddrStrings(
-				fmt.Sprintf("/ip4/0.0.0.0/tcp/%d", port),      // All interfaces TCP - FIXED PORT
-				fmt.Sprintf("/ip4/0.0.0.0/udp/%d/quic", port), // All interfaces QUIC - FIXED PORT
-			),
+			libp2p.ListenAddrStrings(listenAddrs...),
 
 			// NAT traversal - THE KEY PART! 🔥
 			libp2p.EnableNATService(),   // Detect NAT status
@@ -163,20 +350,33 @@ func NewLibP2PPangeaNodeWithOptions(nodeID uint32, store *NodeStore, localMode b
 		cancel()
 		return nil, fmt.Errorf("failed to create libp2p host: %w", err)
 	}
+	host = newNamespacedHost(host, netOpts.Namespace)
+	topic := discoveryTopic(netOpts.Namespace)
+	if netOpts.Namespace != "" {
+		log.Printf("🏷️  NETWORK NAMESPACE: %s", netOpts.Namespace)
+	}
 
-	var kadDHT *dht.IpfsDHT
+	var kadDHT, nameDHT *dht.IpfsDHT
 	var routingDiscovery *routing.RoutingDiscovery
+	bootstrap := &bootstrapSet{peers: defaultBootstrapPeers(netOpts)}
 
 	if !localMode {
 		// Only create DHT for WAN mode
-		kadDHT, err = dht.New(ctx, host, dht.Mode(dht.ModeServer))
+		dhtOptions := []dht.Option{dht.Mode(dht.ModeServer), dht.BootstrapPeersFunc(bootstrap.list)}
+		if private {
+			dhtOptions = append(dhtOptions,
+				dht.ProtocolPrefix(PrivateDHTPrefix),
+				dht.NamespacedValidator(NameNamespace, nameValidator{}))
+		}
+		// The DHT keeps its own protocol IDs; a private one already has a prefix
+		kadDHT, err = dht.New(ctx, baseHost(host), dhtOptions...)
 		if err != nil {
 			host.Close()
 			cancel()
 			return nil, fmt.Errorf("failed to create DHT: %w", err)
 		}
 
-		// Bootstrap DHT with known nodes (IPFS bootstrap by default)
+		// Bootstrap DHT with known nodes; Start dials the bootstrap set
 		if err := kadDHT.Bootstrap(ctx); err != nil {
 			if testMode {
 				log.Printf("⚠️  DHT bootstrap failed (this is normal in test mode): %v", err)
@@ -184,6 +384,23 @@ func NewLibP2PPangeaNodeWithOptions(nodeID uint32, store *NodeStore, localMode b
 			// Don't fail completely - DHT bootstrap can fail in test environments
 		}
 
+		// The public IPFS DHT takes only its own record types, so name
+		// records get a DHT of Pangea nodes alongside it
+		nameDHT = kadDHT
+		if !private {
+			nameDHT, err = dht.New(ctx, baseHost(host),
+				dht.Mode(dht.ModeServer),
+				dht.BootstrapPeersFunc(bootstrap.list),
+				dht.ProtocolPrefix(NameDHTPrefix),
+				dht.NamespacedValidator(NameNamespace, nameValidator{}))
+			if err != nil {
+				kadDHT.Close()
+				host.Close()
+				cancel()
+				return nil, fmt.Errorf("failed to create name DHT: %w", err)
+			}
+		}
+
 		// Create routing discovery for content-based discovery
 		routingDiscovery = routing.NewRoutingDiscovery(kadDHT)
 	} else if testMode {
@@ -196,29 +413,100 @@ func NewLibP2PPangeaNodeWithOptions(nodeID uint32, store *NodeStore, localMode b
 	// Create mDNS discovery for local network - works in both modes
 	// mDNS enables automatic peer discovery on the local network (same subnet)
 	notifee := &discoveryNotifee{testMode: testMode}
-	mdnsService := mdns.NewMdnsService(host, PangeaDiscoveryTopic, notifee)
+	mdnsService := mdns.NewMdnsService(host, topic, notifee)
 	log.Printf("📡 mDNS service initialized - local peers will auto-connect")
 
 	node := &LibP2PPangeaNode{
-		nodeID:       nodeID,
-		host:         host,
-		dht:          kadDHT,
-		ping:         pingService,
-		discovery:    routingDiscovery,
-		mdns:         mdnsService,
-		store:        store,
-		ctx:          ctx,
-		cancel:       cancel,
-		localMode:    localMode,
-		testMode:     testMode,
-		reachability: ReachabilityUnknown,
-		natType:      NATTypeUnknown,
-		shardStore:   make(map[string]map[uint32][]byte),
-		dkgShares:    make(map[string]map[uint32][]byte),
+		nodeID:         nodeID,
+		host:           host,
+		dht:            kadDHT,
+		ping:           pingService,
+		discovery:      routingDiscovery,
+		mdns:           mdnsService,
+		store:          store,
+		ctx:            ctx,
+		cancel:         cancel,
+		localMode:      localMode,
+		testMode:       testMode,
+		reachability:   ReachabilityUnknown,
+		natType:        NATTypeUnknown,
+		bwCounter:      bwCounter,
+		protoStats:     protoStats,
+		prober:         NewQualityProber(host, pingService, DefaultProbeInterval),
+		versions:       NewVersionTracker(NodeVersion),
+		partition:      NewPartitionDetector(DefaultPartitionConfig()),
+		discoveryPace:  NewAdaptiveInterval(MinDiscoveryInterval, DefaultDiscoveryInterval, MaxDiscoveryInterval),
+		statusPace:     NewAdaptiveInterval(MinStatusInterval, DefaultStatusInterval, MaxStatusInterval),
+		shardStore:     make(map[string]map[uint32][]byte),
+		shardStored:    make(map[string]time.Time),
+		shardOrigins:   make(map[string]peer.ID),
+		rotations:      NewKeyRotationManager(),
+		pins:           NewPinSet(),
+		disk:           NewDiskMonitor(DefaultDiskMonitorConfig()),
+		dkgShares:      make(map[string]map[uint32][]byte),
+		keyAudit:       NewKeyAuditLog(""),
+		downloads:      NewDownloadManager(""),
+		health:         NewHealthMonitor(),
+		faults:         NewFaultInjector(),
+		bandwidth:      NewBandwidthScheduler(),
+		bootstrap:      bootstrap,
+		privateNetwork: private,
+		webrtc:         webrtc,
+		topic:          topic,
+		threat:         threat,
 	}
 
 	// Link notifee to node for auto-connect
 	notifee.node = node
+	node.refresh = NewShareRefresher(node)
+
+	// Keep quality history for unreachable peers while partitioned
+	node.prober.partition = node.partition
+
+	// Track peer versions as the identify handshake completes
+	if err := node.versions.Watch(ctx, host.EventBus()); err != nil {
+		log.Printf("⚠️  Peer version tracking disabled: %v", err)
+	}
+
+	// Score failed handshakes and cut off peers that cross the threshold
+	if err := threat.Watch(ctx, host.EventBus(), host.Network()); err != nil {
+		log.Printf("⚠️  Handshake threat scoring disabled: %v", err)
+	}
+	threat.OnChange(node.applyThreatScore)
+
+	// Register store-forward relay protocol (relay role is opt-in)
+	node.relay = NewRelayService(host, node.StoreShard)
+
+	// Register ML dataset chunk protocol
+	node.mlData = NewMLDataService(host)
+	node.mlCoordinator = NewMLCoordinator()
+	node.mlCoordinator.SetDatasetTransport(node.mlData)
+
+	// Register ephemeral chat protocol
+	node.sendQueues = NewSendQueues()
+	node.events = NewEventBus(ctx)
+	node.security = NewSecurityManager()
+	node.chat = NewChatService(host, node.security, node.events)
+	node.chat.threat = threat
+	node.chat.queues = node.sendQueues
+	node.verifier = NewPeerVerifier(host, node.events)
+
+	// Register direct file transfer protocol
+	node.files = NewFileTransferService(host)
+	node.files.faults = node.faults
+
+	// Register threshold signing protocol; group keys come from DKG runs
+	// over the pangea RPC protocol
+	node.dkg = NewNetworkDKG(host)
+	node.tsign = NewThresholdSigner(host, node.dkg)
+
+	// Register node state gossip protocol
+	node.gossip = NewStateGossip(host, store, nodeID)
+	node.gossip.threat = threat
+
+	// Name records live in the DHT, so in local mode they stay on this node
+	node.names = NewNameRegistry(host, nameDHT)
+	node.access = NewAccessControl(host)
 
 	// Set stream handler for Pangea RPC protocol
 	host.SetStreamHandler(protocol.ID(PangeaRPCProtocol), node.handlePangeaRPC)
@@ -226,17 +514,196 @@ func NewLibP2PPangeaNodeWithOptions(nodeID uint32, store *NodeStore, localMode b
 	// Register network notifier to handle incoming connections
 	host.Network().Notify(&networkNotifee{node: node})
 
+	// mDNS reports its own state from Start; the DHT is polled
+	node.health.Register("mdns", false, nil)
+	if kadDHT != nil {
+		node.health.Register("dht", false, func() (HealthState, error) {
+			if kadDHT.RoutingTable().Size() == 0 {
+				return HealthDegraded, nil
+			}
+			return HealthOK, nil
+		})
+	}
+
 	return node, nil
 }
 
-// StoreShard stores a shard for a given fileHash and index on this node
-func (n *LibP2PPangeaNode) StoreShard(fileHash string, shardIndex uint32, data []byte) {
+// StoreShard stores a shard for a given fileHash and index on this node.
+// Returns a QUOTA_EXCEEDED error when storage is read-only or full, unless
+// the retention policy lets unpinned files be evicted to make room.
+func (n *LibP2PPangeaNode) StoreShard(fileHash string, shardIndex uint32, data []byte) error {
 	n.shardMu.Lock()
-	defer n.shardMu.Unlock()
+	dm := n.disk
+
+	existing := 0
+	if m, ok := n.shardStore[fileHash]; ok {
+		existing = len(m[shardIndex])
+	}
+	var events []DiskEvent
+	var evicted []string
+	if len(data) > existing {
+		need := uint64(len(data) - existing)
+		var err error
+		for {
+			var reserved []DiskEvent
+			if reserved, err = dm.reserve(need); err == nil {
+				events = append(events, reserved...)
+				break
+			}
+			if len(evicted) == 0 && !n.canEvictLocked(fileHash, need) {
+				break
+			}
+			dropped, dropEvents, ok := n.evictOldestLocked(fileHash)
+			if !ok {
+				break
+			}
+			evicted = append(evicted, dropped)
+			events = append(events, dropEvents...)
+		}
+		if err != nil {
+			n.shardMu.Unlock()
+			n.finishEviction(dm, events, evicted)
+			return err
+		}
+	} else if existing > len(data) {
+		events = dm.release(uint64(existing - len(data)))
+	}
+
 	if _, ok := n.shardStore[fileHash]; !ok {
 		n.shardStore[fileHash] = make(map[uint32][]byte)
 	}
 	n.shardStore[fileHash][shardIndex] = data
+	n.shardStored[fileHash] = time.Now()
+	n.shardMu.Unlock()
+
+	// Listeners may call back into the node, so notify them outside shardMu
+	n.finishEviction(dm, events, evicted)
+	go n.announceShard(fileHash, shardIndex)
+	n.events.Publish(NodeEvent{
+		Type:    EventShardStored,
+		Subject: fileHash,
+		Attributes: map[string]string{
+			"shardIndex": strconv.FormatUint(uint64(shardIndex), 10),
+			"size":       strconv.Itoa(len(data)),
+		},
+	})
+	return nil
+}
+
+// ConfigureDiskMonitor replaces the storage quota monitor, charges it for
+// shards already held, and starts periodic rescans of the data directory.
+// The previous monitor's rescans are stopped.
+func (n *LibP2PPangeaNode) ConfigureDiskMonitor(config DiskMonitorConfig) *DiskMonitor {
+	dm := NewDiskMonitor(config)
+	n.watchDiskRole(dm)
+
+	ctx, cancel := context.WithCancel(n.ctx)
+
+	n.shardMu.Lock()
+	var held uint64
+	for _, shards := range n.shardStore {
+		for _, data := range shards {
+			held += uint64(len(data))
+		}
+	}
+	events, err := dm.reserve(held)
+	if err != nil {
+		log.Printf("⚠️  [DISK] Shards already held exceed quota: %v", err)
+	}
+	n.disk = dm
+	if n.diskCancel != nil {
+		n.diskCancel()
+	}
+	n.diskCancel = cancel
+	n.shardMu.Unlock()
+
+	dm.emit(events)
+	n.store.UpdateStorageRole(n.nodeID, dm.Role())
+	go dm.Run(ctx)
+	return dm
+}
+
+// watchDiskRole mirrors the monitor's storage role into the local node's
+// entry in the NodeStore so node status shows when storage is read-only
+func (n *LibP2PPangeaNode) watchDiskRole(dm *DiskMonitor) {
+	dm.OnEvent(func(ev DiskEvent) {
+		if n.GetDiskMonitor() != dm {
+			return
+		}
+		n.store.UpdateStorageRole(n.nodeID, dm.Role())
+	})
+}
+
+// GetProtocolStats returns per-peer protocol usage statistics
+func (n *LibP2PPangeaNode) GetProtocolStats() *ProtocolStats {
+	return n.protoStats
+}
+
+// GetVersionTracker returns the tracker of peer software versions
+func (n *LibP2PPangeaNode) GetVersionTracker() *VersionTracker {
+	return n.versions
+}
+
+// GetMLDataService returns the ML dataset chunk service
+func (n *LibP2PPangeaNode) GetMLDataService() *MLDataService {
+	return n.mlData
+}
+
+// GetMLCoordinator returns the node's ML training coordinator
+func (n *LibP2PPangeaNode) GetMLCoordinator() *MLCoordinator {
+	return n.mlCoordinator
+}
+
+// GetSecurityManager returns the node's security manager
+func (n *LibP2PPangeaNode) GetSecurityManager() *SecurityManager {
+	return n.security
+}
+
+// GetChatService returns the ephemeral chat service
+func (n *LibP2PPangeaNode) GetChatService() *ChatService {
+	return n.chat
+}
+
+// GetEventBus returns the node's event bus
+func (n *LibP2PPangeaNode) GetEventBus() *EventBus {
+	return n.events
+}
+
+// GetFileTransferService returns the direct file transfer service
+func (n *LibP2PPangeaNode) GetFileTransferService() *FileTransferService {
+	return n.files
+}
+
+// GetNetworkDKG returns the distributed key generation service
+func (n *LibP2PPangeaNode) GetNetworkDKG() *NetworkDKG {
+	return n.dkg
+}
+
+// GetThresholdSigner returns the threshold signing service
+func (n *LibP2PPangeaNode) GetThresholdSigner() *ThresholdSigner {
+	return n.tsign
+}
+
+// SetDownloadManager replaces the background download manager
+func (n *LibP2PPangeaNode) SetDownloadManager(dm *DownloadManager) {
+	n.downloads = dm
+}
+
+// GetDownloadManager returns the background download manager
+func (n *LibP2PPangeaNode) GetDownloadManager() *DownloadManager {
+	return n.downloads
+}
+
+// GetPartitionDetector returns the swarm partition detector
+func (n *LibP2PPangeaNode) GetPartitionDetector() *PartitionDetector {
+	return n.partition
+}
+
+// GetDiskMonitor returns the storage quota monitor
+func (n *LibP2PPangeaNode) GetDiskMonitor() *DiskMonitor {
+	n.shardMu.RLock()
+	defer n.shardMu.RUnlock()
+	return n.disk
 }
 
 // FetchLocalShard returns shard data if present locally
@@ -251,6 +718,19 @@ func (n *LibP2PPangeaNode) FetchLocalShard(fileHash string, shardIndex uint32) (
 	return nil, false
 }
 
+// GetShardStats returns the number and total size of shards held locally
+func (n *LibP2PPangeaNode) GetShardStats() (shards int, bytes uint64) {
+	n.shardMu.RLock()
+	defer n.shardMu.RUnlock()
+	for _, m := range n.shardStore {
+		for _, data := range m {
+			shards++
+			bytes += uint64(len(data))
+		}
+	}
+	return shards, bytes
+}
+
 // StoreDKGShare stores a received DKG share for a file ID
 func (n *LibP2PPangeaNode) StoreDKGShare(fileID string, fromPeer uint32, share []byte) {
 	n.dkgMu.Lock()
@@ -261,6 +741,37 @@ func (n *LibP2PPangeaNode) StoreDKGShare(fileID string, fromPeer uint32, share [
 	n.dkgShares[fileID][fromPeer] = share
 }
 
+// GetPeerVerifier returns the safety number verification records
+func (n *LibP2PPangeaNode) GetPeerVerifier() *PeerVerifier {
+	return n.verifier
+}
+
+// GetNameRegistry returns the registry of published names
+func (n *LibP2PPangeaNode) GetNameRegistry() *NameRegistry {
+	return n.names
+}
+
+// GetAccessControl returns the node's shard access control
+func (n *LibP2PPangeaNode) GetAccessControl() *AccessControl {
+	return n.access
+}
+
+// GetKeyRotations returns the node's content key rotations
+func (n *LibP2PPangeaNode) GetKeyRotations() *KeyRotationManager {
+	return n.rotations
+}
+
+// GetShareRefresher returns the proactive DKG share refresher
+func (n *LibP2PPangeaNode) GetShareRefresher() *ShareRefresher {
+	return n.refresh
+}
+
+// StartShareRefresh refreshes the shares of tracked files every interval
+// until the node stops
+func (n *LibP2PPangeaNode) StartShareRefresh(interval time.Duration) {
+	go n.refresh.Run(n.ctx, interval)
+}
+
 // GetLocalShare returns a locally stored share for fileID if present
 func (n *LibP2PPangeaNode) GetLocalShare(fileID string) ([]byte, bool) {
 	n.dkgMu.RLock()
@@ -273,6 +784,91 @@ func (n *LibP2PPangeaNode) GetLocalShare(fileID string) ([]byte, bool) {
 	return nil, false
 }
 
+// GetBandwidthTotals returns aggregate bandwidth across all peers and protocols
+func (n *LibP2PPangeaNode) GetBandwidthTotals() metrics.Stats {
+	return n.bwCounter.GetBandwidthTotals()
+}
+
+// GetBandwidthForPeer returns bandwidth stats for a single peer
+func (n *LibP2PPangeaNode) GetBandwidthForPeer(p peer.ID) metrics.Stats {
+	return n.bwCounter.GetBandwidthForPeer(p)
+}
+
+// GetBandwidthByProtocol returns bandwidth stats keyed by protocol ID
+func (n *LibP2PPangeaNode) GetBandwidthByProtocol() map[protocol.ID]metrics.Stats {
+	return n.bwCounter.GetBandwidthByProtocol()
+}
+
+// GetPeerQuality returns probed connection quality for a peer
+func (n *LibP2PPangeaNode) GetPeerQuality(p peer.ID) (PeerQuality, bool) {
+	return n.prober.Get(p)
+}
+
+// GetRelayService returns the store-forward relay service
+func (n *LibP2PPangeaNode) GetRelayService() *RelayService {
+	return n.relay
+}
+
+// OptInToRelay connects to a relay peer and asks it to hold items for this node
+func (n *LibP2PPangeaNode) OptInToRelay(addr string) error {
+	pi, err := parseMultiaddr(addr)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(n.ctx, 30*time.Second)
+	defer cancel()
+	if err := n.host.Connect(ctx, pi); err != nil {
+		return fmt.Errorf("failed to connect to relay: %w", err)
+	}
+	if err := n.relay.OptIn(ctx, pi.ID); err != nil {
+		return err
+	}
+	log.Printf("📮 Opted in to store-forward relay %s", shortPeerID(pi.ID))
+
+	// Collect anything the relay already holds for this node
+	if delivered, err := n.relay.Announce(ctx, pi.ID); err != nil {
+		log.Printf("⚠️  Failed to collect pending items from relay %s: %v", shortPeerID(pi.ID), err)
+	} else if delivered > 0 {
+		log.Printf("📬 Collected %d pending items from relay %s", delivered, shortPeerID(pi.ID))
+	}
+	return nil
+}
+
+// OptOutOfRelay withdraws consent from a relay; it drops anything it holds
+// for this node
+func (n *LibP2PPangeaNode) OptOutOfRelay(addr string) error {
+	pi, err := parseMultiaddr(addr)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(n.ctx, 30*time.Second)
+	defer cancel()
+	if err := n.host.Connect(ctx, pi); err != nil {
+		return fmt.Errorf("failed to connect to relay: %w", err)
+	}
+	if err := n.relay.OptOut(ctx, pi.ID); err != nil {
+		return err
+	}
+	log.Printf("📮 Opted out of store-forward relay %s", shortPeerID(pi.ID))
+	return nil
+}
+
+// DepositViaRelay hands an item for an unreachable recipient to the first
+// connected peer willing to relay it
+func (n *LibP2PPangeaNode) DepositViaRelay(recipient peer.ID, item RelayItem) (peer.ID, error) {
+	item.Recipient = recipient.String()
+	ctx, cancel := context.WithTimeout(n.ctx, 30*time.Second)
+	defer cancel()
+	relay, err := n.relay.DepositAny(ctx, n.host.Network().Peers(), item)
+	if err != nil {
+		return "", err
+	}
+	log.Printf("📮 Deposited %s for %s with relay %s", item.Kind, shortPeerID(recipient), shortPeerID(relay))
+	return relay, nil
+}
+
 // Start begins the libp2p node operations
 func (n *LibP2PPangeaNode) Start() error {
 	log.Printf("🚀 Starting libp2p Pangea node")
@@ -286,33 +882,138 @@ func (n *LibP2PPangeaNode) Start() error {
 	if n.mdns != nil {
 		if err := n.mdns.Start(); err != nil {
 			log.Printf("❌ Failed to start mDNS discovery: %v", err)
+			n.health.Report("mdns", HealthDown, err)
 		} else {
-			log.Printf("📡 mDNS discovery running (service: %s)", PangeaDiscoveryTopic)
+			log.Printf("📡 mDNS discovery running (service: %s)", n.topic)
+			n.health.Report("mdns", HealthOK, nil)
 		}
 	}
 
+	// Seed the DHT from the bootstrap set
+	if n.dht != nil {
+		go n.connectBootstrapPeers(n.BootstrapPeerInfos())
+	}
+
 	// Start peer discovery
 	go n.discoverPeers()
 
 	// Start connection monitoring
 	go n.monitorConnections()
 
+	// Start per-peer connection quality probing
+	go n.prober.Run(n.ctx)
+
+	// Start partition detection over the known swarm
+	go n.partition.Run(n.ctx, n.host.Network().Peers)
+
+	// Fail silent ML workers and relax stalled epoch barriers
+	go n.mlCoordinator.Run(n.ctx)
+
+	// Delete expired ephemeral chat messages
+	go n.security.Run(n.ctx)
+
+	// Drop unpinned shards held past the retention period
+	go n.runShardGC(n.ctx)
+
+	// Share this node's state and learn everyone else's
+	go n.gossip.Run(n.ctx, StateGossipInterval)
+
+	// Keep this node's names alive in the DHT
+	go n.names.Run(n.ctx, NameRepublishInterval)
+
 	// Start NAT detection and reachability monitoring
 	go n.monitorReachability()
 
 	return nil
 }
 
+// GetHealthMonitor returns the node's subsystem health monitor
+func (n *LibP2PPangeaNode) GetHealthMonitor() *HealthMonitor {
+	return n.health
+}
+
+// GetFaultInjector returns the node's test-only fault injector
+func (n *LibP2PPangeaNode) GetFaultInjector() *FaultInjector {
+	return n.faults
+}
+
+// GetThreatEngine returns the node's peer threat scores
+func (n *LibP2PPangeaNode) GetThreatEngine() *ThreatEngine {
+	return n.threat
+}
+
 // SetComputeProtocol sets the compute protocol for this node
 func (n *LibP2PPangeaNode) SetComputeProtocol(cp *ComputeProtocol) {
+	cp.queues = n.sendQueues
+	cp.faults = n.faults
 	n.computeProtocol = cp
 }
 
+// GetSendQueues returns the per-peer outbound send queues
+func (n *LibP2PPangeaNode) GetSendQueues() *SendQueues {
+	return n.sendQueues
+}
+
+// GetBandwidthScheduler returns the rate limits on shard transfers
+func (n *LibP2PPangeaNode) GetBandwidthScheduler() *BandwidthScheduler {
+	return n.bandwidth
+}
+
+// SetBandwidthScheduler shares a scheduler with the node's other
+// transports, so one set of limits covers the whole uplink
+func (n *LibP2PPangeaNode) SetBandwidthScheduler(b *BandwidthScheduler) {
+	n.bandwidth = b
+}
+
+// WebRTCEnabled reports whether browsers can connect over WebRTC-direct
+func (n *LibP2PPangeaNode) WebRTCEnabled() bool {
+	return n.webrtc
+}
+
+// WebRTCAddrs returns the WebRTC-direct multiaddrs browsers dial, ending
+// in /p2p/<id>. Each carries the hash of the node's DTLS certificate,
+// which is generated at startup, so browsers must fetch them again after
+// a restart.
+func (n *LibP2PPangeaNode) WebRTCAddrs() []string {
+	var addrs []string
+	for _, addr := range n.host.Addrs() {
+		if ok, hashes := libp2pwebrtc.IsWebRTCDirectMultiaddr(addr); ok && hashes > 0 {
+			addrs = append(addrs, fmt.Sprintf("%s/p2p/%s", addr, n.host.ID()))
+		}
+	}
+	return addrs
+}
+
+// isBrowserConn reports whether a connection came in over WebRTC-direct,
+// which only browsers use; Go nodes dial each other over TCP or QUIC
+func isBrowserConn(conn network.Conn) bool {
+	return conn.ConnState().Transport == "webrtc-direct"
+}
+
 // GetComputeProtocol returns the compute protocol handler
 func (n *LibP2PPangeaNode) GetComputeProtocol() *ComputeProtocol {
 	return n.computeProtocol
 }
 
+// SetCommunicationService sets the chat/voice/video service for this node
+func (n *LibP2PPangeaNode) SetCommunicationService(cs *communication.CommunicationService) {
+	n.comm = cs
+	cs.SetChatCallback(func(msg communication.ChatMessage) {
+		chatMessagesTotal.WithLabelValues("received").Inc()
+		n.events.Publish(NodeEvent{
+			Type:       EventChatReceived,
+			PeerID:     msg.From,
+			Subject:    msg.ID,
+			Attributes: map[string]string{"messageId": msg.ID},
+		})
+	})
+}
+
+// GetCommunicationService returns the chat/voice/video service, if started
+func (n *LibP2PPangeaNode) GetCommunicationService() *communication.CommunicationService {
+	return n.comm
+}
+
 // LocalMultiaddrs returns the node's listen multiaddrs with peer ID appended.
 // includeLocal controls whether localhost addresses are returned (useful for
 // local testing / CLI display).
@@ -336,22 +1037,19 @@ func (n *LibP2PPangeaNode) discoverPeers() {
 		return
 	}
 
-	if _, err := n.discovery.Advertise(n.ctx, PangeaDiscoveryTopic); err != nil {
+	if _, err := n.discovery.Advertise(n.ctx, n.topic); err != nil {
 		log.Printf("❌ Failed to advertise on DHT: %v", err)
 	} else {
-		log.Printf("📢 Advertising on DHT topic: %s", PangeaDiscoveryTopic)
+		log.Printf("📢 Advertising on DHT topic: %s", n.topic)
 	}
 
-	// Continuously discover peers
-	ticker := time.NewTicker(30 * time.Second)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-n.ctx.Done():
-			return
-		case <-ticker.C:
-			n.findAndConnectPeers()
+	// Continuously discover peers, less often while the peer set is stable
+	var known map[peer.ID]bool
+	for n.discoveryPace.Wait(n.ctx) {
+		n.findAndConnectPeers()
+		known = n.discoveryPace.adjust(known, n.host.Network().Peers(), DefaultTargetPeerCount, n.prober.Get)
+		if n.testMode {
+			log.Printf("⏱️  Next discovery in %v", n.discoveryPace.Current())
 		}
 	}
 }
@@ -364,7 +1062,7 @@ func (n *LibP2PPangeaNode) findAndConnectPeers() {
 
 	log.Printf("🔍 Discovering Pangea peers...")
 
-	peerChan, err := n.discovery.FindPeers(n.ctx, PangeaDiscoveryTopic)
+	peerChan, err := n.discovery.FindPeers(n.ctx, n.topic)
 	if err != nil {
 		log.Printf("❌ Failed to find peers: %v", err)
 		return
@@ -434,20 +1132,8 @@ func (n *LibP2PPangeaNode) connectPeerInfo(pi peer.AddrInfo) (bool, error) {
 
 	// Get the peer's IP address for logging
 	peerIP := "unknown"
-	if len(pi.Addrs) > 0 {
-		for _, addr := range pi.Addrs {
-			addrStr := addr.String()
-			// Extract IP from multiaddr like /ip4/192.168.1.5/tcp/7777
-			if strings.Contains(addrStr, "/ip4/") {
-				parts := strings.Split(addrStr, "/")
-				for i, p := range parts {
-					if p == "ip4" && i+1 < len(parts) {
-						peerIP = parts[i+1]
-						break
-					}
-				}
-			}
-		}
+	if conns := n.host.Network().ConnsToPeer(pi.ID); len(conns) > 0 {
+		peerIP = addrIPString(conns[0].RemoteMultiaddr())
 	}
 
 	log.Printf("✅ Connected to peer %s (IP: %s)", shortPeerID(pi.ID), peerIP)
@@ -497,175 +1183,180 @@ func (n *LibP2PPangeaNode) testConnection(peerID peer.ID) {
 	}
 }
 
-// handlePangeaRPC handles incoming RPC streams
+// handlePangeaRPC serves one framed request per stream (see rpc_frame.go)
 func (n *LibP2PPangeaNode) handlePangeaRPC(stream network.Stream) {
 	defer stream.Close()
+	remote := stream.Conn().RemotePeer()
 
-	log.Printf("📞 Incoming RPC from peer %s", stream.Conn().RemotePeer().String()[:8])
+	log.Printf("📞 Incoming RPC from peer %s", shortPeerID(remote))
 
-	// Unified RPC handler - supports small request types for testing:
-	// [1] Shard fetch request: [TYPE=1][fileHashLen(2)][fileHash][shardIndex(4)] -> responds with shard bytes
-	// [2] DKG share request: [TYPE=2][fileIDLen(2)][fileID] -> responds with stored share bytes
+	rs, err := acceptRPCStream(stream)
+	if err == nil {
+		err = n.serveRPC(rs)
+	}
+	if err != nil {
+		log.Printf("❌ RPC from %s failed: %v", shortPeerID(remote), err)
+		if errors.Is(err, errRPCBadFrame) {
+			n.threat.Record(remote, ThreatProtocolViolation, err.Error())
+		}
+	}
+}
 
-	header := make([]byte, 1)
-	if _, err := io.ReadFull(stream, header); err != nil {
-		log.Printf("❌ Failed to read request header: %v", err)
-		return
+// serveRPC reads a request and writes its response
+func (n *LibP2PPangeaNode) serveRPC(rs *rpcStream) error {
+	frame, err := rs.recv()
+	if err != nil {
+		return err
 	}
+	req := &rpcPayload{b: frame.Payload}
 
-	switch header[0] {
-	case 1:
-		// Shard fetch
-		lenBuf := make([]byte, 2)
-		if _, err := io.ReadFull(stream, lenBuf); err != nil {
-			log.Printf("❌ Failed to read fileHash len: %v", err)
-			return
+	switch frame.Type {
+	case rpcMsgFetchShard:
+		fileHash, shardIdx, grant := req.string(), req.uint32(), req.rest()
+		if req.err != nil {
+			return req.err
 		}
-		hashLen := int(lenBuf[0])<<8 | int(lenBuf[1])
-		fh := make([]byte, hashLen)
-		if _, err := io.ReadFull(stream, fh); err != nil {
-			log.Printf("❌ Failed to read fileHash: %v", err)
-			return
+		if err := n.access.authorize(fileHash, rs.Conn().RemotePeer(), grant); err != nil {
+			log.Printf("🚫 Refused shard %d of %s to %s: %v", shardIdx, fileHash, shortPeerID(rs.Conn().RemotePeer()), err)
+			return rs.sendError(err.Error())
 		}
-		idxBuf := make([]byte, 4)
-		if _, err := io.ReadFull(stream, idxBuf); err != nil {
-			log.Printf("❌ Failed to read shard index: %v", err)
-			return
+		data, ok := n.FetchLocalShard(fileHash, shardIdx)
+		if !ok {
+			return rs.sendError(fmt.Sprintf("shard %d of %s not found", shardIdx, fileHash))
 		}
-		shardIdx := uint32(idxBuf[0])<<24 | uint32(idxBuf[1])<<16 | uint32(idxBuf[2])<<8 | uint32(idxBuf[3])
+		rs.pace = n.bandwidth.Pacer(n.ctx, BandwidthBulk)
+		return serveShardStream(rs, data)
 
-		fileHash := string(fh)
-		n.shardMu.RLock()
-		if shardMap, ok := n.shardStore[fileHash]; ok {
-			if data, ok2 := shardMap[shardIdx]; ok2 {
-				n.shardMu.RUnlock()
-				if _, err := stream.Write(data); err != nil {
-					log.Printf("❌ Failed to write shard response: %v", err)
-				}
-				return
-			}
+	case rpcMsgFetchShare:
+		fileID := req.string()
+		if req.err != nil {
+			return req.err
 		}
-		n.shardMu.RUnlock()
-		// Not found - respond with empty
-		if _, err := stream.Write([]byte{}); err != nil {
-			log.Printf("❌ Failed to write empty shard response: %v", err)
+		n.dkgMu.RLock()
+		share, ok := n.dkgShares[fileID][n.nodeID]
+		n.dkgMu.RUnlock()
+		if !ok {
+			return rs.sendError(fmt.Sprintf("no share for %s", fileID))
 		}
+		return rs.send(rpcMsgShareData, share)
 
-	case 2:
-		// DKG share request
-		lenBuf := make([]byte, 2)
-		if _, err := io.ReadFull(stream, lenBuf); err != nil {
-			log.Printf("❌ Failed to read fileID len: %v", err)
-			return
+	case rpcMsgStoreShard:
+		fileHash, shardIdx, size, flags := req.string(), req.uint32(), req.uint64(), req.rest()
+		if req.err != nil {
+			return req.err
 		}
-		idLen := int(lenBuf[0])<<8 | int(lenBuf[1])
-		fid := make([]byte, idLen)
-		if _, err := io.ReadFull(stream, fid); err != nil {
-			log.Printf("❌ Failed to read fileID: %v", err)
-			return
+		var claim []byte
+		if len(flags) > 0 && flags[0]&shardFlagRestricted != 0 {
+			claim = flags[1:]
 		}
-		fileID := string(fid)
-
-		n.dkgMu.RLock()
-		if shares, ok := n.dkgShares[fileID]; ok {
-			if s, ok2 := shares[n.nodeID]; ok2 {
-				n.dkgMu.RUnlock()
-				if _, err := stream.Write(s); err != nil {
-					log.Printf("❌ Failed to write share response: %v", err)
-				}
-				return
-			}
-		}
-		n.dkgMu.RUnlock()
-		// Not found
-		if _, err := stream.Write([]byte{}); err != nil {
-			log.Printf("❌ Failed to write empty share response: %v", err)
+		if err := n.access.admitStore(fileHash, rs.Conn().RemotePeer(), claim); err != nil {
+			log.Printf("🚫 Rejected shard %d for %s: %v", shardIdx, fileHash, err)
+			return rs.send(rpcMsgStoreShardAck, append([]byte{shardAckForbidden}, make([]byte, sha256.Size)...))
 		}
-
-	case 3:
-		// Store shard on this node
-		lenBuf := make([]byte, 2)
-		if _, err := io.ReadFull(stream, lenBuf); err != nil {
-			log.Printf("❌ Failed to read fileHash len for store: %v", err)
-			return
+		if size > maxShardSize {
+			log.Printf("🚫 Rejected shard %d for %s: exceeds %d bytes", shardIdx, fileHash, maxShardSize)
+			return rs.send(rpcMsgStoreShardAck, append([]byte{shardAckTooLarge}, make([]byte, sha256.Size)...))
 		}
-		hashLen := int(lenBuf[0])<<8 | int(lenBuf[1])
-		fh := make([]byte, hashLen)
-		if _, err := io.ReadFull(stream, fh); err != nil {
-			log.Printf("❌ Failed to read fileHash for store: %v", err)
-			return
+		if err := rs.send(rpcMsgShardReady, nil); err != nil {
+			return err
 		}
-		idxBuf := make([]byte, 4)
-		if _, err := io.ReadFull(stream, idxBuf); err != nil {
-			log.Printf("❌ Failed to read shard index for store: %v", err)
-			return
+		n.recordOrigin(fileHash, rs.Conn().RemotePeer())
+
+		status := shardAckStored
+		shardData, err := newShardReceiver(size).receiveAll(rs)
+		if errors.Is(err, errShardHashMismatch) {
+			log.Printf("🚫 Rejected shard %d for %s: %v", shardIdx, fileHash, err)
+			status = shardAckCorrupt
+		} else if err != nil {
+			return err
+		} else if err := n.StoreShard(fileHash, shardIdx, shardData); err != nil {
+			log.Printf("🚫 Rejected shard %d for %s: %v", shardIdx, fileHash, err)
+			status = shardAckQuotaExceeded
 		}
-		shardIdx := uint32(idxBuf[0])<<24 | uint32(idxBuf[1])<<16 | uint32(idxBuf[2])<<8 | uint32(idxBuf[3])
 
-		// Read remaining as shard data
-		dataBuf := make([]byte, 1024*1024)
-		nread, _ := stream.Read(dataBuf)
-		shardData := dataBuf[:nread]
+		// Acknowledge placement with the hash of what arrived
+		digest := sha256.Sum256(shardData)
+		return rs.send(rpcMsgStoreShardAck, append([]byte{status}, digest[:]...))
 
-		n.StoreShard(string(fh), shardIdx, shardData)
-		if _, err := stream.Write([]byte("OK")); err != nil {
-			log.Printf("❌ Failed to write store ack: %v", err)
+	case rpcMsgStoreShare:
+		// The dealer's node ID is informational; the share is stored under
+		// this node's own ID so others can fetch it
+		fileID, _, share := req.string(), req.uint32(), req.rest()
+		if req.err != nil {
+			return req.err
 		}
-
-	case 4:
-		// Store a DKG share sent by a dealer
-		lenBuf := make([]byte, 2)
-		if _, err := io.ReadFull(stream, lenBuf); err != nil {
-			log.Printf("❌ Failed to read fileID len for share store: %v", err)
-			return
+		n.recordOrigin(fileID, rs.Conn().RemotePeer())
+		n.StoreDKGShare(fileID, n.nodeID, share)
+		return rs.send(rpcMsgStoreShareAck, nil)
+
+	case rpcMsgDeleteShards:
+		fileHash := req.string()
+		if req.err != nil {
+			return req.err
 		}
-		idLen := int(lenBuf[0])<<8 | int(lenBuf[1])
-		fid := make([]byte, idLen)
-		if _, err := io.ReadFull(stream, fid); err != nil {
-			log.Printf("❌ Failed to read fileID for share store: %v", err)
-			return
+		n.shardMu.RLock()
+		origin, ok := n.shardOrigins[fileHash]
+		n.shardMu.RUnlock()
+		if !ok || origin != rs.Conn().RemotePeer() {
+			return rs.sendError(fmt.Sprintf("%v: %s did not store %s here", errAccessDenied, shortPeerID(rs.Conn().RemotePeer()), fileHash))
 		}
-		// fromPeer
-		fromBuf := make([]byte, 4)
-		if _, err := io.ReadFull(stream, fromBuf); err != nil {
-			log.Printf("❌ Failed to read fromPeer for share store: %v", err)
-			return
+		deleted := n.DeleteFileData(fileHash)
+		log.Printf("🗑️  Deleted %d shards of %s at the request of %s", deleted, fileHash, shortPeerID(origin))
+		return rs.send(rpcMsgDeleteShardsAck, binary.BigEndian.AppendUint32(nil, uint32(deleted)))
+
+	case rpcMsgDKG:
+		groupID, sessionID, step := req.string(), req.string(), req.take(1)
+		if req.err != nil {
+			return req.err
 		}
-		fromPeer := uint32(fromBuf[0])<<24 | uint32(fromBuf[1])<<16 | uint32(fromBuf[2])<<8 | uint32(fromBuf[3])
-		_ = fromPeer // currently unused by recipient
-		// share len
-		slenBuf := make([]byte, 4)
-		if _, err := io.ReadFull(stream, slenBuf); err != nil {
-			log.Printf("❌ Failed to read share len for store: %v", err)
-			return
+		ctx, cancel := context.WithTimeout(n.ctx, dkgStepTimeout)
+		defer cancel()
+		reply, err := n.dkg.serve(ctx, rs.Conn().RemotePeer(), groupID, sessionID, step[0], req.rest())
+		if err != nil {
+			return rs.sendError(err.Error())
 		}
-		slen := int(slenBuf[0])<<24 | int(slenBuf[1])<<16 | int(slenBuf[2])<<8 | int(slenBuf[3])
-		shareBuf := make([]byte, slen)
-		if _, err := io.ReadFull(stream, shareBuf); err != nil {
-			log.Printf("❌ Failed to read share data: %v", err)
-			return
+		return rs.send(rpcMsgDKGReply, reply)
+
+	case rpcMsgShareRefresh:
+		fileID, sessionID, step := req.string(), req.string(), req.take(1)
+		if req.err != nil {
+			return req.err
 		}
+		ctx, cancel := context.WithTimeout(n.ctx, refreshStepTimeout)
+		defer cancel()
+		reply, err := n.refresh.serve(ctx, rs.Conn().RemotePeer(), fileID, sessionID, step[0], req.rest())
+		if err != nil {
+			return rs.sendError(err.Error())
+		}
+		return rs.send(rpcMsgShareRefreshReply, reply)
 
-		// Store share under this node's own id (the recipient) so it can be fetched by others
-		n.StoreDKGShare(string(fid), n.nodeID, shareBuf)
-		if _, err := stream.Write([]byte("OK")); err != nil {
-			log.Printf("❌ Failed to write share store ack: %v", err)
+	case rpcMsgRevokeGrant:
+		grantID, fileHash, expires := req.string(), req.string(), req.uint64()
+		if req.err != nil {
+			return req.err
+		}
+		if err := n.access.applyRevocation(rs.Conn().RemotePeer(), grantID, fileHash, int64(expires)); err != nil {
+			return rs.sendError(err.Error())
 		}
+		return rs.send(rpcMsgRevokeGrantAck, nil)
+
+	case rpcMsgData:
+		log.Printf("📨 Received %d byte message from %s", len(frame.Payload), shortPeerID(rs.Conn().RemotePeer()))
+		return nil
+
+	default:
+		rs.sendError(fmt.Sprintf("unknown request type %d", frame.Type))
+		return fmt.Errorf("%w: unknown request type %d", errRPCBadFrame, frame.Type)
 	}
 }
 
-// monitorConnections monitors connection health and NAT status
+// monitorConnections monitors connection health and NAT status, less often
+// while the peer set is stable
 func (n *LibP2PPangeaNode) monitorConnections() {
-	ticker := time.NewTicker(15 * time.Second)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-n.ctx.Done():
-			return
-		case <-ticker.C:
-			n.reportConnectionStatus()
-		}
+	var known map[peer.ID]bool
+	for n.statusPace.Wait(n.ctx) {
+		n.reportConnectionStatus()
+		known = n.statusPace.adjust(known, n.host.Network().Peers(), DefaultTargetPeerCount, n.prober.Get)
 	}
 }
 
@@ -702,6 +1393,15 @@ func (n *LibP2PPangeaNode) reportConnectionStatus() {
 	n.reachabilityMu.RUnlock()
 
 	log.Printf("   Reachability: %s (NAT: %s)", reachability, natType)
+	if n.testMode {
+		discovery, status := n.GetLoopIntervals()
+		log.Printf("   Intervals: discovery %v, status %v", discovery, status)
+	}
+}
+
+// GetLoopIntervals returns the current discovery and status loop periods
+func (n *LibP2PPangeaNode) GetLoopIntervals() (discovery, status time.Duration) {
+	return n.discoveryPace.Current(), n.statusPace.Current()
 }
 
 // monitorReachability detects NAT type and reachability status
@@ -746,28 +1446,27 @@ func (n *LibP2PPangeaNode) detectReachability() {
 	usingRelay := false
 
 	for _, addr := range n.host.Addrs() {
-		addrStr := addr.String()
-
 		// Check for relay
-		if strings.Contains(addrStr, "/p2p-circuit/") {
+		if strings.Contains(addr.String(), "/p2p-circuit/") {
 			usingRelay = true
 			continue
 		}
 
-		// Check for private vs public IP
-		if strings.Contains(addrStr, "127.0.0.1") ||
-			strings.Contains(addrStr, "192.168.") ||
-			strings.Contains(addrStr, "10.") ||
-			strings.Contains(addrStr, "172.16.") {
+		// Check for private vs public IP, in either family
+		ip := addrIP(addr)
+		if ip == nil {
+			continue
+		}
+		if isPrivateIP(ip) {
 			hasPrivateAddr = true
-		} else if strings.Contains(addrStr, "/ip4/") || strings.Contains(addrStr, "/ip6/") {
+		} else {
 			hasPublicAddr = true
 		}
 	}
 
 	// Determine reachability and NAT type
 	n.reachabilityMu.Lock()
-	defer n.reachabilityMu.Unlock()
+	prevReachability, prevNAT := n.reachability, n.natType
 
 	if hasPublicAddr && !hasPrivateAddr {
 		n.reachability = ReachabilityPublic
@@ -792,8 +1491,22 @@ func (n *LibP2PPangeaNode) detectReachability() {
 		n.natType = NATTypeUnknown
 	}
 
+	reachability, natType := n.reachability, n.natType
+	n.reachabilityMu.Unlock()
+
 	if n.testMode {
-		log.Printf("🔍 Detected reachability: %s (NAT: %s)", n.reachability, n.natType)
+		log.Printf("🔍 Detected reachability: %s (NAT: %s)", reachability, natType)
+	}
+	if reachability != prevReachability || natType != prevNAT {
+		n.events.Publish(NodeEvent{
+			Type: EventNATChanged,
+			Attributes: map[string]string{
+				"reachability":         string(reachability),
+				"natType":              string(natType),
+				"previousReachability": string(prevReachability),
+				"previousNatType":      string(prevNAT),
+			},
+		})
 	}
 }
 
@@ -869,6 +1582,11 @@ func (n *LibP2PPangeaNode) Stop() error {
 			log.Printf("❌ Error closing DHT: %v", err)
 		}
 	}
+	if d := n.names.dht; d != nil && d != n.dht {
+		if err := d.Close(); err != nil {
+			log.Printf("❌ Error closing name DHT: %v", err)
+		}
+	}
 
 	if err := n.host.Close(); err != nil {
 		log.Printf("❌ Error closing libp2p host: %v", err)
@@ -938,30 +1656,46 @@ type networkNotifee struct {
 
 func (n *networkNotifee) Listen(network.Network, multiaddr.Multiaddr)      {}
 func (n *networkNotifee) ListenClose(network.Network, multiaddr.Multiaddr) {}
-func (n *networkNotifee) Disconnected(_ network.Network, conn network.Conn) {
+func (n *networkNotifee) Disconnected(nw network.Network, conn network.Conn) {
 	log.Printf("🔌 PEER DISCONNECTED: PeerID=%s", conn.RemotePeer().String())
+	if n.node != nil && nw.Connectedness(conn.RemotePeer()) != network.Connected {
+		n.node.events.Publish(NodeEvent{Type: EventPeerDisconnected, PeerID: conn.RemotePeer().String()})
+		n.node.versions.Forget(conn.RemotePeer())
+		n.node.protoStats.Forget(conn.RemotePeer())
+
+		// Look for replacements and report sooner after losing a peer
+		n.node.discoveryPace.Nudge()
+		n.node.statusPace.Nudge()
+	}
 }
 
-func (n *networkNotifee) Connected(_ network.Network, conn network.Conn) {
+func (n *networkNotifee) Connected(nw network.Network, conn network.Conn) {
 	peerID := conn.RemotePeer()
 	remoteAddr := conn.RemoteMultiaddr().String()
 
-	// Extract IP from multiaddr
-	peerIP := "unknown"
-	if strings.Contains(remoteAddr, "/ip4/") {
-		parts := strings.Split(remoteAddr, "/")
-		for i, p := range parts {
-			if p == "ip4" && i+1 < len(parts) {
-				peerIP = parts[i+1]
-				break
-			}
-		}
-	}
+	peerIP := addrIPString(conn.RemoteMultiaddr())
 
 	log.Printf("🔗 PEER CONNECTED: PeerID=%s IP=%s", peerID.String(), peerIP)
+	if n.node != nil && len(nw.ConnsToPeer(peerID)) == 1 {
+		n.node.events.Publish(NodeEvent{
+			Type:       EventPeerConnected,
+			PeerID:     peerID.String(),
+			Attributes: map[string]string{"address": remoteAddr},
+		})
+	}
 
-	// Register this peer as a compute worker
-	if n.node != nil && n.node.computeProtocol != nil {
+	// Warn if a verified peer presents a different identity key
+	if n.node != nil && n.node.verifier != nil {
+		n.node.verifier.CheckKey(peerID, conn.RemotePublicKey())
+	}
+
+	// Deliver any items held for this peer while it was offline
+	if n.node != nil && n.node.relay != nil {
+		n.node.relay.PeerConnected(peerID)
+	}
+
+	// Register this peer as a compute worker; browsers cannot run tasks
+	if n.node != nil && n.node.computeProtocol != nil && !isBrowserConn(conn) {
 		defaultCapacity := compute.ComputeCapacity{
 			CPUCores:      4,
 			RAMMB:         8192,
@@ -1,28 +1,87 @@
 package main
 
 import (
+	"context"
 	"flag"
-	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/pangea-net/go-node/pkg/communication"
 	"github.com/pangea-net/go-node/pkg/compute"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 func main() {
+	// Sandboxed compute tasks re-run this binary; run the task and exit
+	compute.RunSandboxIfChild()
+
+	// Admin subcommands drive an already running node
+	if len(os.Args) > 1 && isCLICommand(os.Args[1]) {
+		os.Exit(runCLI(os.Args[1:], os.Stdout, os.Stderr))
+	}
+
 	var (
-		nodeID     = flag.Uint("node-id", 1, "Node ID for this instance")
-		capnpAddr  = flag.String("capnp-addr", ":8080", "Cap'n Proto server address")
-		p2pAddr    = flag.String("p2p-addr", ":9090", "P2P network listener address (legacy mode)")
-		libp2pPort = flag.Int("libp2p-port", 7777, "Libp2p listener port")
-		peerAddrs  = flag.String("peers", "", "Comma-separated list of peer addresses")
-		useLibp2p  = flag.Bool("libp2p", true, "Use libp2p for P2P networking (recommended)")
-		localMode  = flag.Bool("local", false, "Local testing mode (mDNS discovery only)")
-		testMode   = flag.Bool("test", false, "Enable testing mode with debug output")
+		nodeID      = flag.Uint("node-id", 1, "Node ID for this instance")
+		capnpAddr   = flag.String("capnp-addr", ":8080", "Cap'n Proto server address (host:port, unix:///path or npipe://name)")
+		httpAddr    = flag.String("http-addr", "", "REST/JSON gateway address (empty = disabled)")
+		metricsAddr = flag.String("metrics-addr", "", "Prometheus metrics and health check address (empty = disabled)")
+		healthAddr  = flag.String("health-addr", "", "Subsystem health probe address serving /healthz and /readyz, libp2p mode only (empty = disabled)")
+		orchURL     = flag.String("orchestrator-url", "", "Go orchestrator training API (comma-separated for a cluster) that ML tasks with global_aggregation combine epochs through, libp2p mode only; its token is read from ORCHESTRATOR_TRAINING_TOKEN (empty = disabled)")
+		orchWorker  = flag.Bool("orchestrator-worker", false, "Register with the orchestrator at -orchestrator-url as a compute worker and run the chunks it assigns")
+		otlpAddr    = flag.String("otlp-endpoint", os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"), "OTLP/HTTP endpoint traces are exported to, e.g. http://localhost:4318 (empty = disabled)")
+		p2pAddr     = flag.String("p2p-addr", ":9090", "P2P network listener address (legacy mode)")
+		legacyAddr  = flag.String("legacy-addr", "", "Also run the legacy P2P listener on this address beside libp2p, for peers that have not upgraded (empty = saved legacy_addr, else off)")
+		libp2pPort  = flag.Int("libp2p-port", 7777, "Libp2p listener port")
+		peerAddrs   = flag.String("peers", "", "Comma-separated list of peer addresses")
+		bootstrap   = flag.String("bootstrap", "", "Comma-separated DHT bootstrap multiaddrs ending in /p2p/<id> (default: saved bootstrap_peers, else the public IPFS set; none on a private network)")
+		swarmKey    = flag.String("swarm-key", "", "Private network key file in swarm.key format; only peers with the same key can connect (empty = public network)")
+		threatLimit = flag.Float64("threat-threshold", DefaultThreatDisconnectThreshold, "Threat score (0-1) at which a misbehaving peer is disconnected and refused")
+		threatDecay = flag.Duration("threat-half-life", DefaultThreatHalfLife, "Time for a peer's threat score to decay to half")
+		useLibp2p   = flag.Bool("libp2p", true, "Use libp2p for P2P networking (recommended)")
+		wsPort      = flag.Int("ws-port", 0, "TCP port for a libp2p WebSocket listener, for networks that only allow web traffic (0 = off)")
+		wssCert     = flag.String("wss-cert", "", "PEM certificate for the WebSocket listener; with -wss-key it serves WSS (leave empty behind a TLS-terminating reverse proxy)")
+		wssKey      = flag.String("wss-key", "", "PEM private key for -wss-cert")
+		announce    = flag.String("announce", "", "Comma-separated extra multiaddrs to advertise, e.g. /dns4/node.example.com/tcp/443/wss for a reverse proxy")
+		networkKey  = flag.String("network-key", "", "Shared key naming this cluster; nodes with another key ignore it over mDNS, DHT discovery and every Pangea protocol (empty = default network)")
+		useIPv6     = flag.Bool("ipv6", true, "Listen on IPv6 as well as IPv4")
+		preferIPv4  = flag.Bool("prefer-ipv4", false, "Dial dual-stack peers over IPv4 first (default: IPv6 first, IPv4 250ms later)")
+		enableRTC   = flag.Bool("enable-webrtc", false, "Also listen on WebRTC-direct (UDP, libp2p port) so browsers can connect; addresses are served at /api/v1/webrtc (public networks only)")
+		localMode   = flag.Bool("local", false, "Local testing mode (mDNS discovery only)")
+		testMode    = flag.Bool("test", false, "Enable testing mode with debug output")
+		chaosMode   = flag.Bool("chaos", false, "Let setFaultInjection drop shard fetches, delay compute responses and reset file transfers on this node, for testing retry and repair (never in production)")
+		relayMode   = flag.Bool("relay", false, "Hold shards/messages for opted-in intermittently connected peers")
+		relayVia    = flag.String("relay-via", "", "Comma-separated relay multiaddrs to opt in with for store-forward delivery")
+		dataDir     = flag.String("data-dir", "", "Data directory counted against the storage quota")
+		quotaMB     = flag.Uint64("storage-quota-mb", 0, "Storage quota in MB; storage turns read-only when nearly full (0 = unlimited)")
+		shardTTL    = flag.Duration("shard-ttl", 0, "Drop held shards of a file this long after the last one was stored, unless pinned (0 = keep)")
+		evictShards = flag.Bool("evict-unpinned", false, "When the storage quota is reached, evict the oldest unpinned shards to fit new ones instead of refusing them")
+		keyPassFile = flag.String("key-passphrase-file", "", "File holding the passphrase that encrypts the node's RSA keys in <data-dir>/security_keys.pgks (empty = keys kept in memory only)")
+		refreshIval = flag.Duration("share-refresh-interval", DefaultShareRefreshInterval, "How often DKG shares of files this node dealt are refreshed across their holders (0 = never)")
+		queueSize   = flag.Int("send-queue-size", DefaultSendQueueSize, "Compute, shard and chat sends that may wait for one peer")
+		queuePolicy = flag.String("send-queue-policy", SendQueuePark, "What a send to a peer with a full queue does: park (wait for room) or drop (fail at once)")
+		totalLimit  = flag.Uint64("bandwidth-limit", 0, "Outgoing KiB/s for shard transfers and streaming together; media is not held back by it, shard transfers yield to media (0 = unlimited)")
+		bulkLimit   = flag.Uint64("bulk-bandwidth-limit", 0, "Outgoing KiB/s for shard uploads and shards served to peers (0 = unlimited)")
+		mediaLimit  = flag.Uint64("interactive-bandwidth-limit", 0, "Outgoing KiB/s for voice, video and chat streaming (0 = unlimited)")
+		shmDir      = flag.String("shm-dir", "", "Directory shared memory rings are created in as named files (empty = /dev/shm where present, else the temp directory)")
+		shmRingMB   = flag.Int("shm-ring-mb", 16, "Size in MB of shared memory rings created without an explicit size")
+		worker      = flag.Bool("compute-worker", false, "Run compute tasks for peers (opt-in; the policy can be changed live with setWorkerPolicy)")
+		workerTasks = flag.Int("worker-max-tasks", 0, "Peer compute tasks run at once (0 = unlimited)")
+		workerCPU   = flag.Uint("worker-task-cpu", 0, "Percent of one core each peer task may use (0 = unlimited)")
+		workerMemMB = flag.Uint64("worker-task-memory-mb", 0, "Memory a peer task may need, in MB (0 = unlimited in-process, 256MB sandboxed)")
+		workerTime  = flag.Duration("worker-task-time", 0, "Longest a peer task may run (0 = the task's own timeout)")
+		workerHours = flag.String("worker-hours", "", "Semicolon-separated local-time windows peer tasks are accepted in, e.g. \"Mon-Fri 18:00-08:00;Sat,Sun 00:00-24:00\" (empty = any time)")
+		isolation   = flag.String("compute-isolation", compute.IsolationProcess, "How peer compute tasks are isolated: process (child process under rlimits and seccomp) or none (in the node's process)")
+		maxJobs     = flag.Int("compute-max-jobs", compute.DefaultConfig().MaxConcurrentJobs, "Compute jobs processed at once; more wait in the queue by priority (0 = unlimited)")
+		maxQueued   = flag.Int("compute-max-queued", compute.DefaultConfig().MaxQueuedJobs, "Compute jobs waiting to run before submissions are refused (0 = unlimited)")
+		wireSums    = flag.Bool("compute-wire-checksums", true, "CRC-32C checksum compute task messages sent to peers that speak compute protocol 1.1")
+		wireZstd    = flag.Bool("compute-wire-compression", true, "zstd-compress compute task messages of 4 KiB or more sent to peers that speak compute protocol 1.1")
 	)
 	flag.Parse()
 
@@ -47,6 +106,7 @@ func main() {
 	configManager := NewConfigManager(uint32(*nodeID))
 
 	// Try to load existing configuration
+	var bootstrapPeers []string
 	loadedConfig, err := configManager.LoadConfig()
 	if err != nil {
 		log.Printf("⚠️  Could not load config: %v, using defaults", err)
@@ -54,9 +114,12 @@ func main() {
 		// Override flags with loaded config if they match
 		if loadedConfig.NodeID == uint32(*nodeID) {
 			log.Printf("📋 Loaded configuration for node %d", loadedConfig.NodeID)
-			// Could optionally override flags here with loaded values
+			bootstrapPeers = loadedConfig.BootstrapPeers
 		}
 	}
+	if *bootstrap != "" {
+		bootstrapPeers = strings.Split(*bootstrap, ",")
+	}
 
 	// Save initial configuration
 	initialConfig := &NodeConfig{
@@ -65,30 +128,130 @@ func main() {
 		LibP2PPort:     *libp2pPort,
 		UseLibP2P:      *useLibp2p,
 		LocalMode:      *localMode,
+		BootstrapPeers: bootstrapPeers,
 		CustomSettings: make(map[string]string),
 	}
+	if loadedConfig != nil && loadedConfig.NodeID == uint32(*nodeID) {
+		initialConfig.LegacyAddr, initialConfig.PrimaryBackend = loadedConfig.LegacyAddr, loadedConfig.PrimaryBackend
+	}
 	if err := configManager.SaveConfig(initialConfig); err != nil {
 		log.Printf("⚠️  Could not save initial config: %v", err)
 	}
 
+	// Export traces of compute jobs across RPC, delegation and workers
+	if *otlpAddr != "" {
+		shutdownTracing, err := InitTracing(*otlpAddr, "pangea-node", attribute.Int("node.id", int(*nodeID)))
+		if err != nil {
+			log.Fatalf("❌ Failed to start tracing: %v", err)
+		}
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			shutdownTracing(ctx)
+		}()
+		log.Printf("🔭 Exporting traces to %s", *otlpAddr)
+	}
+
 	// Create shared memory manager for Go-Python data streaming
 	shmMgr := NewSharedMemoryManager()
+	if err := shmMgr.Configure(*shmDir, *shmRingMB<<20); err != nil {
+		log.Fatalf("Invalid shared memory settings: %v", err)
+	}
 	defer shmMgr.CloseAll()
 
 	// Create compute manager - shared across all connections
-	computeManager := compute.NewManager(compute.DefaultConfig())
+	computeConfig := compute.DefaultConfig()
+	if *dataDir != "" {
+		computeConfig.LedgerPath = filepath.Join(*dataDir, "compute_ledger.json")
+		computeConfig.TemplatePath = filepath.Join(*dataDir, "job_templates.json")
+		computeConfig.WorkerPolicyPath = filepath.Join(*dataDir, "worker_policy.json")
+		computeConfig.ReplayDir = filepath.Join(*dataDir, "compute_replay")
+	}
+
+	// Peer tasks only run on nodes that opt in. A policy changed over RPC
+	// is saved and used on restart, unless a worker flag is given.
+	computeConfig.WorkerPolicy = compute.WorkerPolicy{
+		Enabled:            *worker,
+		MaxConcurrentTasks: *workerTasks,
+		MaxTaskCPUPercent:  uint32(*workerCPU),
+		MaxTaskMemoryMB:    *workerMemMB,
+		MaxTaskTime:        *workerTime,
+	}
+	for _, window := range strings.Split(*workerHours, ";") {
+		if window = strings.TrimSpace(window); window == "" {
+			continue
+		}
+		w, err := compute.ParseWorkWindow(window)
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		computeConfig.WorkerPolicy.WorkHours = append(computeConfig.WorkerPolicy.WorkHours, w)
+	}
+	if *isolation != compute.IsolationProcess && *isolation != compute.IsolationNone {
+		log.Fatalf("❌ Invalid -compute-isolation %q: want %s or %s", *isolation, compute.IsolationProcess, compute.IsolationNone)
+	}
+	computeConfig.Sandbox.Isolation = *isolation
+	computeConfig.MaxConcurrentJobs = *maxJobs
+	computeConfig.MaxQueuedJobs = *maxQueued
+	computeManager := compute.NewManager(computeConfig)
+	defer computeManager.Close()
+	workerFlagSet := false
+	flag.Visit(func(f *flag.Flag) {
+		workerFlagSet = workerFlagSet || f.Name == "compute-worker" || strings.HasPrefix(f.Name, "worker-")
+	})
+	if workerFlagSet {
+		if err := computeManager.SetWorkerPolicy(computeConfig.WorkerPolicy); err != nil {
+			log.Fatalf("❌ Invalid worker policy: %v", err)
+		}
+	}
+	if !computeManager.WorkerPolicy().Enabled {
+		log.Printf("👷 Not running compute tasks for peers (opt in with -compute-worker)")
+	}
 	log.Printf("⚙️ Compute manager initialized")
 
-	// Network adapter will be set based on which P2P implementation we use
-	var networkAdapter NetworkAdapter
+	// Run libp2p, the legacy transport, or both side by side. The legacy
+	// backend can be switched on and off at runtime over RPC.
+	// One bandwidth scheduler paces every transport; limits can be changed
+	// at runtime with setBandwidthLimits
+	bandwidth := NewBandwidthScheduler()
+	bandwidth.SetLimits(BandwidthSettings{Total: *totalLimit << 10, Bulk: *bulkLimit << 10, Interactive: *mediaLimit << 10})
+
+	network := NewNetworkSwitch()
+	network.Register(BackendLegacy, NewLegacyBackendFactory(uint32(*nodeID), store, bandwidth))
+	var libp2pNode *LibP2PPangeaNode
+	stopWorker := func() {}
 
-	// Choose P2P implementation
 	if *useLibp2p {
 		// Use libp2p (recommended for production)
-		libp2pNode, err := NewLibP2PPangeaNodeWithOptions(uint32(*nodeID), store, *localMode, *testMode, *libp2pPort)
+		netOpts := NetworkOptions{Threat: ThreatConfig{HalfLife: *threatDecay, DisconnectThreshold: *threatLimit}, WebRTC: *enableRTC, WebSocketPort: *wsPort}
+		netOpts.DisableIPv6, netOpts.PreferIPv4 = !*useIPv6, *preferIPv4
+		if *networkKey != "" {
+			netOpts.Namespace = NetworkNamespace([]byte(*networkKey))
+		}
+		if netOpts.BootstrapPeers, err = ParseBootstrapPeers(bootstrapPeers); err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		if netOpts.AnnounceAddrs, err = ParseAnnounceAddrs(strings.Split(*announce, ",")); err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		if (*wssCert == "") != (*wssKey == "") {
+			log.Fatalf("❌ -wss-cert and -wss-key must be given together")
+		}
+		if *wssCert != "" {
+			if netOpts.WebSocketTLS, err = LoadWebSocketTLS(*wssCert, *wssKey); err != nil {
+				log.Fatalf("❌ %v", err)
+			}
+		}
+		if *swarmKey != "" {
+			if netOpts.SwarmKey, err = LoadSwarmKey(*swarmKey); err != nil {
+				log.Fatalf("❌ Failed to load swarm key: %v", err)
+			}
+		}
+		libp2pNode, err = NewLibP2PPangeaNodeWithNetworkOptions(uint32(*nodeID), store, *localMode, *testMode, *libp2pPort, netOpts)
 		if err != nil {
 			log.Fatalf("❌ Failed to create libp2p node: %v", err)
 		}
+		libp2pNode.SetBandwidthScheduler(bandwidth)
 
 		// Configure for local or WAN mode
 		if *localMode {
@@ -101,146 +264,302 @@ func main() {
 			log.Fatalf("❌ Failed to start libp2p node: %v", err)
 		}
 
+		if err := libp2pNode.GetSendQueues().Configure(*queueSize, *queuePolicy); err != nil {
+			log.Fatalf("❌ Invalid send queue settings: %v", err)
+		}
+		if *chaosMode {
+			libp2pNode.GetFaultInjector().Enable()
+			log.Printf("💥 Chaos mode: faults can be injected with setFaultInjection")
+		}
+
 		for _, addr := range libp2pNode.LocalMultiaddrs(true) {
 			log.Printf("🛰️  Share this multiaddr (manual fallback): %s", addr)
 		}
+		for _, addr := range libp2pNode.WebRTCAddrs() {
+			log.Printf("🌐 Browsers can dial: %s", addr)
+		}
 
 		// Create and register compute protocol
 		computeProtocol := NewComputeProtocol(libp2pNode.GetHost(), computeManager, uint32(*nodeID))
+		wire := DefaultComputeWireOptions()
+		wire.Checksums, wire.Compression = *wireSums, *wireZstd
+		computeProtocol.SetWireOptions(wire)
 		libp2pNode.SetComputeProtocol(computeProtocol)
 
 		// Wire the compute protocol as the task delegator for distributed compute
 		computeManager.SetDelegator(computeProtocol)
 		log.Printf("🌐 Distributed compute protocol enabled")
 
-		// Note: The communication service (go/pkg/communication/communication.go)
-		// provides always-on chat/voice/video message handling.
-		// To integrate, add this import:
-		//   import "github.com/pangea-net/go-node/pkg/communication"
-		// Then call:
-		//   commService := communication.NewCommunicationService(libp2pNode.GetHost(), communication.Config{})
-		//   commService.Start()
-		//   defer commService.Stop()
-		// Messages are automatically stored in ~/.pangea/communication/chat_history.json
+		// Score workers whose results fail verification
+		computeManager.OnResultRejected(func(workerID string, err error) {
+			if p, perr := peer.Decode(workerID); perr == nil {
+				libp2pNode.GetThreatEngine().Record(p, ThreatComputeMismatch, err.Error())
+			}
+		})
+
+		// Announce finished jobs to event subscribers
+		computeManager.OnJobFinished(func(jobID string, status compute.TaskStatus) {
+			libp2pNode.GetEventBus().Publish(NodeEvent{
+				Type:       EventJobFinished,
+				Subject:    jobID,
+				Attributes: map[string]string{"status": status.String()},
+			})
+		})
+
+		// Start always-on chat/voice/video messaging; chat history is kept in
+		// ~/.pangea/communication/chat_history.json
+		commService := communication.NewCommunicationService(libp2pNode.GetHost(), communication.Config{})
+		if err := commService.Start(); err != nil {
+			log.Fatalf("❌ Failed to start communication service: %v", err)
+		}
+		defer commService.Stop()
+		libp2pNode.SetCommunicationService(commService)
+
+		// Persist the key escrow audit trail, download spool, DKG key
+		// shares, peer verifications and published names alongside node data
+		if *dataDir != "" {
+			libp2pNode.SetKeyAuditLog(NewKeyAuditLog(filepath.Join(*dataDir, "key_audit.log")))
+			libp2pNode.SetDownloadManager(NewDownloadManager(filepath.Join(*dataDir, "download_spool")))
+			if err := libp2pNode.GetNetworkDKG().SetKeyDir(filepath.Join(*dataDir, "dkg_keys")); err != nil {
+				log.Printf("⚠️  Failed to load DKG key shares: %v", err)
+			}
+			if err := libp2pNode.GetPeerVerifier().SetPath(filepath.Join(*dataDir, "peer_verification.json")); err != nil {
+				log.Printf("⚠️  Failed to load peer verifications: %v", err)
+			}
+			if err := libp2pNode.GetNameRegistry().SetPath(filepath.Join(*dataDir, "names.json")); err != nil {
+				log.Printf("⚠️  Failed to load published names: %v", err)
+			}
+			if err := libp2pNode.GetAccessControl().SetPath(filepath.Join(*dataDir, "access_control.json")); err != nil {
+				log.Printf("⚠️  Failed to load access grants: %v", err)
+			}
+			if err := libp2pNode.GetPins().SetPath(filepath.Join(*dataDir, "pins.json")); err != nil {
+				log.Printf("⚠️  Failed to load pins: %v", err)
+			}
+		}
 
-		// Create network adapter for libp2p
-		networkAdapter = NewLibP2PAdapter(libp2pNode, store)
+		// Keep RSA keys across restarts, encrypted at rest
+		if *keyPassFile != "" {
+			if *dataDir == "" {
+				log.Fatalf("❌ -key-passphrase-file requires -data-dir")
+			}
+			passphrase, err := os.ReadFile(*keyPassFile)
+			if err != nil {
+				log.Fatalf("❌ Failed to read key passphrase: %v", err)
+			}
+			keyStore, err := NewKeyStore(filepath.Join(*dataDir, "security_keys.pgks"), strings.TrimSpace(string(passphrase)))
+			if err != nil {
+				log.Fatalf("❌ Invalid key passphrase: %v", err)
+			}
+			if err := libp2pNode.GetSecurityManager().SetKeyStore(keyStore); err != nil {
+				log.Fatalf("❌ Failed to open key store: %v", err)
+			}
+		}
 
-		// Start Cap'n Proto server for Python communication with shared compute manager and config
-		go func() {
-			log.Printf("🔌 Starting Cap'n Proto server on %s", *capnpAddr)
-			if err := StartCapnpServerWithConfigManager(store, networkAdapter, shmMgr, *capnpAddr, computeManager, configManager); err != nil {
-				log.Fatalf("❌ Failed to start Cap'n Proto server: %v", err)
+		// The compute manager must stay up for the node to be ready
+		libp2pNode.GetHealthMonitor().Register("compute", true, func() (HealthState, error) {
+			if err := computeManager.Err(); err != nil {
+				return HealthDown, err
 			}
-		}()
+			return HealthOK, nil
+		})
+
+		// Create network adapter for libp2p, keeping peer IDs stable across
+		// restarts
+		libp2pAdapter := NewLibP2PAdapter(libp2pNode, store)
+		if *dataDir != "" {
+			if err := libp2pAdapter.SetPeerIDPath(filepath.Join(*dataDir, "peer_ids.json")); err != nil {
+				log.Printf("⚠️  Failed to load peer ID mapping: %v", err)
+			}
+		}
+		if err := network.Add(libp2pAdapter); err != nil {
+			log.Fatalf("❌ %v", err)
+		}
 
-		// Connect to specified peers
-		if *peerAddrs != "" {
-			peers := strings.Split(*peerAddrs, ",")
-			for _, peerAddr := range peers {
-				peerAddr = strings.TrimSpace(peerAddr)
-				if peerAddr == "" {
-					continue
-				}
-				log.Printf("🔗 Connecting to peer: %s", peerAddr)
-				if err := libp2pNode.ConnectToPeer(peerAddr); err != nil {
-					log.Printf("❌ Failed to connect to peer %s: %v", peerAddr, err)
+		// Federated tasks across go-nodes aggregate through the orchestrator
+		if *orchURL != "" {
+			client := NewOrchestratorClient(*orchURL, os.Getenv("ORCHESTRATOR_TRAINING_TOKEN"), strconv.FormatUint(uint64(*nodeID), 10))
+			libp2pNode.GetMLCoordinator().SetGlobalAggregator(client)
+			log.Printf("🌐 Global ML aggregation through %s", *orchURL)
+
+			// Compute chunks of orchestrator jobs run here as well
+			if *orchWorker {
+				worker := NewOrchestratorWorker(client, computeManager, "node-"+strconv.FormatUint(uint64(*nodeID), 10), 0)
+				workerCtx, cancel := context.WithCancel(context.Background())
+				done := make(chan struct{})
+				go func() {
+					defer close(done)
+					worker.Run(workerCtx)
+				}()
+				stopWorker = func() {
+					cancel()
+					<-done
 				}
 			}
 		}
 
-		// Wait for interrupt signal
-		sigChan := make(chan os.Signal, 1)
-		signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
-
-		if *testMode {
+		if *healthAddr != "" {
 			go func() {
-				ticker := time.NewTicker(5 * time.Second)
-				defer ticker.Stop()
-				// In test mode, periodically report status
-				for {
-					select {
-					case <-sigChan:
-						return
-					case <-ticker.C:
-						peers := libp2pNode.GetConnectedPeers()
-						log.Printf("📊 Connected peers: %d", len(peers))
-						for i, peer := range peers {
-							if i < 3 { // Limit output
-								log.Printf("   Peer %d: %s", i+1, peer.ID[:16]+"...")
-							}
-						}
-					}
+				log.Printf("🩺 Health probes listening on %s/healthz and /readyz", *healthAddr)
+				if err := StartHealthServer(*healthAddr, libp2pNode.GetHealthMonitor()); err != nil {
+					log.Fatalf("❌ Failed to start health server: %v", err)
 				}
 			}()
 		}
 
-		log.Println("🌐 Node running. Press Ctrl+C to stop.")
-		<-sigChan
+		// Enforce the storage quota for shards held by this node
+		if *quotaMB > 0 || *dataDir != "" {
+			diskConfig := DefaultDiskMonitorConfig()
+			diskConfig.DataDir = *dataDir
+			diskConfig.QuotaBytes = *quotaMB * 1024 * 1024
+			libp2pNode.ConfigureDiskMonitor(diskConfig)
+			log.Printf("💾 Storage quota: %d MB (data dir: %q)", *quotaMB, *dataDir)
+		}
+		libp2pNode.SetShardRetention(ShardRetention{TTL: *shardTTL, EvictOnQuota: *evictShards})
+		if *shardTTL > 0 || *evictShards {
+			log.Printf("🧹 Shard retention: ttl %s, evict unpinned on quota: %v", *shardTTL, *evictShards)
+		}
 
-		log.Println("🛑 Shutting down...")
+		// Proactively refresh the key shares of files this node dealt
+		if *refreshIval > 0 {
+			libp2pNode.StartShareRefresh(*refreshIval)
+		}
 
-		// Save configuration on shutdown
-		if configManager != nil {
-			log.Printf("💾 Saving configuration...")
-			finalConfig := configManager.GetConfig()
-			if err := configManager.SaveConfig(finalConfig); err != nil {
-				log.Printf("⚠️  Could not save config on shutdown: %v", err)
-			} else {
-				log.Printf("✅ Configuration saved")
+		// Configure store-forward relaying (both sides must opt in)
+		if *relayMode {
+			libp2pNode.GetRelayService().SetRelayEnabled(true)
+		}
+		if *relayVia != "" {
+			for _, relayAddr := range strings.Split(*relayVia, ",") {
+				relayAddr = strings.TrimSpace(relayAddr)
+				if relayAddr == "" {
+					continue
+				}
+				if err := libp2pNode.OptInToRelay(relayAddr); err != nil {
+					log.Printf("❌ Failed to opt in to relay %s: %v", relayAddr, err)
+				}
 			}
 		}
+	}
 
-		libp2pNode.Stop()
-		log.Println("✅ Shutdown complete")
-
-	} else {
-		// Use legacy custom P2P implementation
+	// The legacy listener serves peers that have not moved to libp2p
+	legacyListen := *legacyAddr
+	if legacyListen == "" {
+		legacyListen = initialConfig.LegacyAddr
+	}
+	if !*useLibp2p {
 		log.Printf("⚠️  Using legacy P2P implementation")
-		p2pNode, err := NewP2PNode(uint32(*nodeID), store)
-		if err != nil {
-			log.Fatalf("❌ Failed to create P2P node: %v", err)
+		legacyListen = *p2pAddr
+	}
+	if legacyListen != "" {
+		if err := network.Enable(BackendLegacy, legacyListen); err != nil {
+			log.Fatalf("❌ Failed to start P2P node: %v", err)
 		}
+	}
+	if primary := initialConfig.PrimaryBackend; primary != "" {
+		if err := network.SetPrimary(primary); err != nil {
+			log.Printf("⚠️  Keeping default primary backend: %v", err)
+		}
+	}
 
-		if err := p2pNode.Start(*p2pAddr); err != nil {
-			log.Fatalf("❌ Failed to start P2P node: %v", err)
+	// Start Cap'n Proto server for Python communication with shared compute manager and config
+	go func() {
+		log.Printf("🔌 Starting Cap'n Proto server on %s", *capnpAddr)
+		if err := StartCapnpServerWithConfigManager(store, network, shmMgr, *capnpAddr, computeManager, configManager); err != nil {
+			log.Fatalf("❌ Failed to start Cap'n Proto server: %v", err)
 		}
+	}()
 
-		// Create network adapter for legacy P2P
-		networkAdapter = NewLegacyP2PAdapter(p2pNode, store)
+	// Serve the same API as REST/JSON for clients without Cap'n Proto
+	if *httpAddr != "" {
+		go func() {
+			log.Printf("🌐 Starting HTTP gateway on %s", *httpAddr)
+			service := NewNodeServiceServerWithConfig(store, network, shmMgr, computeManager, configManager)
+			if err := StartHTTPGateway(*httpAddr, service); err != nil {
+				log.Fatalf("❌ Failed to start HTTP gateway: %v", err)
+			}
+		}()
+	}
 
-		// Start Cap'n Proto server for Python communication
+	if *metricsAddr != "" {
 		go func() {
-			log.Printf("🔌 Starting Cap'n Proto server on %s", *capnpAddr)
-			if err := StartCapnpServer(store, networkAdapter, shmMgr, *capnpAddr); err != nil {
-				log.Fatalf("❌ Failed to start Cap'n Proto server: %v", err)
+			log.Printf("📊 Metrics server listening on %s/metrics", *metricsAddr)
+			if err := StartMetricsServer(*metricsAddr, NewNodeMetricsRegistry(libp2pNode, computeManager)); err != nil {
+				log.Fatalf("❌ Failed to start metrics server: %v", err)
 			}
 		}()
+	}
 
-		// Connect to peers if specified
-		if *peerAddrs != "" {
-			peers := strings.Split(*peerAddrs, ",")
-			for _, peerAddr := range peers {
-				parts := strings.Split(strings.TrimSpace(peerAddr), ":")
-				if len(parts) >= 3 {
-					// Format: id:host:port
-					addr := fmt.Sprintf("%s:%s", parts[1], parts[2])
-					log.Printf("🔗 Connecting to peer: %s", addr)
-					// Parse peer ID and connect
-					// This would need proper implementation
+	// Connect to specified peers: multiaddrs over libp2p, id:host:port
+	// (IPv6 hosts in brackets) over the legacy transport
+	if *peerAddrs != "" {
+		for _, peerAddr := range strings.Split(*peerAddrs, ",") {
+			peerAddr = strings.TrimSpace(peerAddr)
+			if peerAddr == "" {
+				continue
+			}
+			if strings.HasPrefix(peerAddr, "/") {
+				log.Printf("🔗 Connecting to peer: %s", peerAddr)
+				if err := network.ConnectToPeer(peerAddr, 0); err != nil {
+					log.Printf("❌ Failed to connect to peer %s: %v", peerAddr, err)
 				}
+				continue
+			}
+			idStr, addr, ok := strings.Cut(peerAddr, ":")
+			peerID, err := strconv.ParseUint(idStr, 10, 32)
+			if !ok || err != nil {
+				log.Printf("⚠️  Skipping peer %q: want a multiaddr or id:host:port", peerAddr)
+				continue
 			}
+			log.Printf("🔗 Connecting to peer %d at %s", peerID, addr)
+			go func() {
+				if err := network.ConnectToPeer(addr, uint32(peerID)); err != nil {
+					log.Printf("❌ %v", err)
+				}
+			}()
 		}
+	}
+
+	// Wait for interrupt signal
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+
+	if *testMode && libp2pNode != nil {
+		go func() {
+			ticker := time.NewTicker(5 * time.Second)
+			defer ticker.Stop()
+			// In test mode, periodically report status
+			for {
+				select {
+				case <-sigChan:
+					return
+				case <-ticker.C:
+					peers := libp2pNode.GetConnectedPeers()
+					log.Printf("📊 Connected peers: %d", len(peers))
+					for i, peer := range peers {
+						if i < 3 { // Limit output
+							log.Printf("   Peer %d: %s", i+1, peer.ID[:16]+"...")
+						}
+					}
+				}
+			}
+		}()
+	}
 
-		// Wait for interrupt signal
-		sigChan := make(chan os.Signal, 1)
-		signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	log.Println("🌐 Node running. Press Ctrl+C to stop.")
+	<-sigChan
 
-		log.Println("🌐 Node running. Press Ctrl+C to stop.")
-		<-sigChan
+	log.Println("🛑 Shutting down...")
+	stopWorker()
 
-		log.Println("🛑 Shutting down...")
-		p2pNode.Stop()
-		log.Println("✅ Shutdown complete")
+	// Save configuration on shutdown
+	log.Printf("💾 Saving configuration...")
+	finalConfig := configManager.GetConfig()
+	if err := configManager.SaveConfig(finalConfig); err != nil {
+		log.Printf("⚠️  Could not save config on shutdown: %v", err)
+	} else {
+		log.Printf("✅ Configuration saved")
 	}
+
+	network.Close()
+	log.Println("✅ Shutdown complete")
 }
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"lukechampine.com/blake3"
+)
+
+// Manifest kinds, signed along with the content so a signature over one
+// kind of manifest cannot pass for another
+const (
+	manifestKindFile    = "file"
+	manifestKindTree    = "tree"
+	manifestKindVersion = "versions"
+
+	manifestSigningDomain = "pangea-manifest-v1"
+)
+
+// errContentHashMismatch is returned when a reconstructed file does not
+// hash to the content hash its manifest lists
+var errContentHashMismatch = errors.New("reconstructed file does not match its content hash")
+
+// errManifestUnsigned is returned for a manifest without a signature by a
+// node that only accepts signed ones
+var errManifestUnsigned = errors.New("manifest is not signed")
+
+// errManifestUntrusted is returned for a manifest validly signed by
+// someone other than the signer the download trusts
+var errManifestUntrusted = errors.New("manifest signed by an untrusted signer")
+
+// manifestSignature binds a manifest to the libp2p identity of the node
+// that produced it
+type manifestSignature struct {
+	Signer    string // Signer's libp2p peer ID
+	PublicKey []byte
+	Signature []byte
+}
+
+// manifestLocation is the signed part of a shard placement: where the
+// shard is and what it hashes to. Placement status is left out, as it
+// describes the upload rather than the file.
+type manifestLocation struct {
+	ShardIndex uint32 `json:"shardIndex"`
+	PeerID     uint32 `json:"peerId"`
+	ShardHash  string `json:"shardHash"`
+}
+
+// signedLocations is the signed form of a list of shard placements
+func signedLocations(placements []shardPlacement) []manifestLocation {
+	out := make([]manifestLocation, len(placements))
+	for i, p := range placements {
+		out[i] = manifestLocation{ShardIndex: p.shardIndex, PeerID: p.peerID, ShardHash: p.shardHash}
+	}
+	return out
+}
+
+// fileManifest is a FileManifest as the node reads and writes it
+type fileManifest struct {
+	FileHash    string
+	FileName    string
+	FileSize    uint64
+	ShardCount  uint32
+	ParityCount uint32
+	Placements  []shardPlacement
+	Timestamp   int64 // Unix seconds
+	TTL         uint32
+	ContentHash string // BLAKE3 of the complete file
+	Signature   *manifestSignature
+}
+
+// signedContent is what a file manifest's signature covers
+func (m *fileManifest) signedContent() interface{} {
+	return struct {
+		FileHash    string             `json:"fileHash"`
+		FileName    string             `json:"fileName"`
+		FileSize    uint64             `json:"fileSize"`
+		ShardCount  uint32             `json:"shardCount"`
+		ParityCount uint32             `json:"parityCount"`
+		Locations   []manifestLocation `json:"locations"`
+		Timestamp   int64              `json:"timestamp"`
+		TTL         uint32             `json:"ttl"`
+		ContentHash string             `json:"contentHash,omitempty"`
+	}{m.FileHash, m.FileName, m.FileSize, m.ShardCount, m.ParityCount, signedLocations(m.Placements), m.Timestamp, m.TTL, m.ContentHash}
+}
+
+// uploadFileHash names an uploaded file: the SHA-256 of its complete
+// content, as for directory and version blobs
+func uploadFileHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// fileContentHash is the BLAKE3 of a file's complete content. Unlike the
+// file hash, which key rotation replaces, it stays with the content, so a
+// download can check what it reconstructed.
+func fileContentHash(data []byte) string {
+	sum := blake3.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// checkContentHash checks reconstructed data against the content hash it
+// was downloaded for; an empty hash, from a manifest that predates them,
+// is not checked
+func checkContentHash(data []byte, contentHash string) error {
+	if contentHash == "" || fileContentHash(data) == contentHash {
+		return nil
+	}
+	return errContentHashMismatch
+}
+
+// treeSignedContent is what a tree manifest's signature covers: the whole
+// tree, with the location of every blob's shards. Empty lists are signed
+// the same whether or not they were allocated, as reading a manifest back
+// leaves them nil.
+func treeSignedContent(tree *dirTree) interface{} {
+	type signedBlob struct {
+		Hash      string             `json:"hash"`
+		Size      uint64             `json:"size"`
+		Packed    bool               `json:"packed"`
+		Locations []manifestLocation `json:"locations"`
+	}
+	blobs := make([]signedBlob, len(tree.Blobs))
+	for i, b := range tree.Blobs {
+		blobs[i] = signedBlob{b.Hash, b.Size, b.Packed, signedLocations(b.Placements)}
+	}
+	return struct {
+		RootHash string       `json:"rootHash"`
+		Entries  []dirEntry   `json:"entries"`
+		Blobs    []signedBlob `json:"blobs"`
+	}{tree.RootHash, append([]dirEntry{}, tree.Entries...), blobs}
+}
+
+// versionsSignedContent is what a version history's signature covers
+func versionsSignedContent(versions []fileVersion) interface{} {
+	type signedVersion struct {
+		FileHash  string             `json:"fileHash"`
+		Size      uint64             `json:"size"`
+		Full      bool               `json:"full"`
+		BlobHash  string             `json:"blobHash"`
+		BlobSize  uint64             `json:"blobSize"`
+		Locations []manifestLocation `json:"locations"`
+		Ops       []deltaOp          `json:"ops"`
+		Created   int64              `json:"created"`
+	}
+	out := make([]signedVersion, len(versions))
+	for i, v := range versions {
+		out[i] = signedVersion{v.FileHash, v.Size, v.Full, v.BlobHash, v.BlobSize, signedLocations(v.Placements), append([]deltaOp{}, v.Ops...), v.Created}
+	}
+	return out
+}
+
+// manifestSigningBytes is the content a manifest signature is made over
+func manifestSigningBytes(kind string, content interface{}, signer string, publicKey []byte) ([]byte, error) {
+	return json.Marshal(struct {
+		Domain    string      `json:"domain"`
+		Kind      string      `json:"kind"`
+		Signer    string      `json:"signer"`
+		PublicKey []byte      `json:"publicKey"`
+		Content   interface{} `json:"content"`
+	}{manifestSigningDomain, kind, signer, publicKey, content})
+}
+
+// signManifest signs a manifest's content with the host's identity key
+func signManifest(h host.Host, kind string, content interface{}) (*manifestSignature, error) {
+	pub, err := crypto.MarshalPublicKey(h.Peerstore().PubKey(h.ID()))
+	if err != nil {
+		return nil, err
+	}
+	sig := &manifestSignature{Signer: h.ID().String(), PublicKey: pub}
+	data, err := manifestSigningBytes(kind, content, sig.Signer, sig.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	if sig.Signature, err = h.Peerstore().PrivKey(h.ID()).Sign(data); err != nil {
+		return nil, fmt.Errorf("failed to sign %s manifest: %w", kind, err)
+	}
+	return sig, nil
+}
+
+// verifyManifest checks that the signer signed a manifest's content
+func verifyManifest(kind string, content interface{}, sig *manifestSignature) error {
+	if sig == nil {
+		return errManifestUnsigned
+	}
+	signer, err := peer.Decode(sig.Signer)
+	if err != nil {
+		return fmt.Errorf("invalid manifest signer: %w", err)
+	}
+	pub, err := crypto.UnmarshalPublicKey(sig.PublicKey)
+	if err != nil {
+		return fmt.Errorf("invalid manifest public key: %w", err)
+	}
+	if !signer.MatchesPublicKey(pub) {
+		return fmt.Errorf("manifest public key does not match signer %s", shortPeerID(signer))
+	}
+	data, err := manifestSigningBytes(kind, content, sig.Signer, sig.PublicKey)
+	if err != nil {
+		return err
+	}
+	valid, err := pub.Verify(data, sig.Signature)
+	if err != nil || !valid {
+		return fmt.Errorf("invalid signature on %s manifest by %s", kind, shortPeerID(signer))
+	}
+	return nil
+}
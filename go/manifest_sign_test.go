@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"capnproto.org/go/capnp/v3"
+)
+
+func TestManifestSignatures(t *testing.T) {
+	node, err := NewLibP2PPangeaNodeWithOptions(680, NewNodeStore(), false, true, 0)
+	if err != nil {
+		t.Fatalf("failed to create node: %v", err)
+	}
+	defer node.cancel()
+	other, err := NewLibP2PPangeaNodeWithOptions(681, NewNodeStore(), false, true, 0)
+	if err != nil {
+		t.Fatalf("failed to create node: %v", err)
+	}
+	defer other.cancel()
+	store := NewNodeStore()
+	service := NewNodeServiceServer(store, NewLibP2PAdapter(node, store), nil).(*nodeServiceServer)
+
+	m := &fileManifest{
+		FileHash:    "file",
+		FileName:    "report.pdf",
+		FileSize:    1024,
+		ShardCount:  2,
+		ParityCount: cesParityShards,
+		Placements: []shardPlacement{
+			{shardIndex: 0, peerID: 7, shardHash: "h0", confirmed: true},
+			{shardIndex: 1, peerID: 8, shardHash: "h1"},
+		},
+		Timestamp: 1700000000,
+	}
+	if m.Signature, err = service.signManifest(manifestKindFile, m.signedContent()); err != nil || m.Signature == nil {
+		t.Fatalf("signManifest failed: %v", err)
+	}
+	if m.Signature.Signer != node.host.ID().String() {
+		t.Fatalf("manifest signed by %s, expected the node", m.Signature.Signer)
+	}
+
+	// The signature survives the RPC form, which drops nothing it covers
+	_, seg, _ := capnp.NewMessage(capnp.SingleSegment(nil))
+	out, _ := NewRootFileManifest(seg)
+	if err := fillFileManifest(out, m); err != nil {
+		t.Fatal(err)
+	}
+	read, err := readFileManifest(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signer, err := service.checkManifest(manifestKindFile, read.signedContent(), read.Signature, ""); err != nil || signer != node.host.ID().String() {
+		t.Fatalf("signed manifest rejected: %q, %v", signer, err)
+	}
+
+	// Placement status is not signed; where the shards are is
+	read.Placements[1].confirmed = true
+	if err := verifyManifest(manifestKindFile, read.signedContent(), read.Signature); err != nil {
+		t.Fatalf("changing placement status broke the signature: %v", err)
+	}
+	read.Placements[1].peerID = 99
+	if err := verifyManifest(manifestKindFile, read.signedContent(), read.Signature); err == nil {
+		t.Fatal("expected a manifest pointing at another holder to be rejected")
+	}
+	if err := verifyManifest(manifestKindTree, m.signedContent(), m.Signature); err == nil {
+		t.Fatal("expected a file manifest signature not to pass for a tree")
+	}
+
+	// Re-signing under another identity without its key fails
+	forged := *m.Signature
+	forged.Signer = other.host.ID().String()
+	if err := verifyManifest(manifestKindFile, m.signedContent(), &forged); err == nil {
+		t.Fatal("expected a signature claimed by another peer to be rejected")
+	}
+
+	// A manifest validly signed by another peer is only trusted when the
+	// client names that peer
+	theirs, err := signManifest(other.host, manifestKindFile, m.signedContent())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.checkManifest(manifestKindFile, m.signedContent(), theirs, ""); !errors.Is(err, errManifestUntrusted) {
+		t.Fatalf("expected a manifest signed by another peer refused, got %v", err)
+	}
+	if _, err := service.checkManifest(manifestKindFile, m.signedContent(), m.Signature, other.host.ID().String()); !errors.Is(err, errManifestUntrusted) {
+		t.Fatalf("expected the node's own manifest refused when another signer is expected, got %v", err)
+	}
+	if signer, err := service.checkManifest(manifestKindFile, m.signedContent(), theirs, other.host.ID().String()); err != nil || signer != other.host.ID().String() {
+		t.Fatalf("manifest from the expected signer rejected: %q, %v", signer, err)
+	}
+
+	// A libp2p node refuses unsigned manifests; a legacy-only node, which
+	// cannot sign, accepts them, but has no identity to trust a signed one by
+	if _, err := service.checkManifest(manifestKindFile, m.signedContent(), nil, ""); !errors.Is(err, errManifestUnsigned) {
+		t.Fatalf("expected an unsigned manifest refused, got %v", err)
+	}
+	legacy := NewNodeServiceServer(NewNodeStore(), nil, nil).(*nodeServiceServer)
+	if sig, err := legacy.signManifest(manifestKindFile, m.signedContent()); sig != nil || err != nil {
+		t.Fatalf("legacy node signed a manifest: %v", err)
+	}
+	if signer, err := legacy.checkManifest(manifestKindFile, m.signedContent(), nil, ""); err != nil || signer != "" {
+		t.Fatalf("legacy node refused an unsigned manifest: %q, %v", signer, err)
+	}
+	if _, err := legacy.checkManifest(manifestKindFile, m.signedContent(), theirs, ""); !errors.Is(err, errManifestUntrusted) {
+		t.Fatalf("expected a legacy node to refuse a signer it was not told to trust, got %v", err)
+	}
+
+	// Downloads on a libp2p node go through the signed manifest; bare
+	// shard locations are refused
+	_, seg, _ = capnp.NewMessage(capnp.SingleSegment(nil))
+	request, _ := NewRootDownloadRequest(seg)
+	request.SetFileHash(m.FileHash)
+	if _, err := request.NewShardLocations(1); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := service.downloadTarget(request); !errors.Is(err, errManifestUnsigned) {
+		t.Fatalf("expected bare shard locations refused, got %v", err)
+	}
+	if _, signer, err := legacy.downloadTarget(request); err != nil || signer != "" {
+		t.Fatalf("legacy node refused bare shard locations: %q, %v", signer, err)
+	}
+	manifest, _ := request.NewManifest()
+	if err := fillFileManifest(manifest, &fileManifest{FileHash: m.FileHash, FileName: m.FileName, FileSize: m.FileSize,
+		ShardCount: m.ShardCount, ParityCount: m.ParityCount, Placements: m.Placements, Timestamp: m.Timestamp, Signature: theirs}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := service.downloadTarget(request); !errors.Is(err, errManifestUntrusted) {
+		t.Fatalf("expected a download of another peer's manifest refused, got %v", err)
+	}
+	request.SetExpectedSigner(other.host.ID().String())
+	if target, signer, err := service.downloadTarget(request); err != nil || signer != other.host.ID().String() || target.FileName != m.FileName {
+		t.Fatalf("download of the expected signer's manifest refused: %q, %v", signer, err)
+	}
+}
+
+func TestTreeAndVersionManifestSignatures(t *testing.T) {
+	node, err := NewLibP2PPangeaNodeWithOptions(682, NewNodeStore(), false, true, 0)
+	if err != nil {
+		t.Fatalf("failed to create node: %v", err)
+	}
+	defer node.cancel()
+
+	tree := &dirTree{
+		RootHash: "root",
+		Entries:  []dirEntry{{Path: "", Dir: true, Blob: -1}, {Path: "a.txt", Size: 3, Hash: "ha", Blob: 0}},
+		Blobs:    []dirBlob{{Hash: "blob", Size: 3, Placements: []shardPlacement{{shardIndex: 0, peerID: 4, shardHash: "s"}}}},
+	}
+	sig, err := signManifest(node.host, manifestKindTree, treeSignedContent(tree))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, seg, _ := capnp.NewMessage(capnp.SingleSegment(nil))
+	treeOut, _ := NewRootTreeManifest(seg)
+	if err := fillTreeManifest(treeOut, tree, sig); err != nil {
+		t.Fatal(err)
+	}
+	readTree, readSig, err := readTreeManifest(treeOut)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyManifest(manifestKindTree, treeSignedContent(readTree), readSig); err != nil {
+		t.Fatalf("tree manifest rejected after a round trip: %v", err)
+	}
+	readTree.Blobs[0].Placements[0].shardHash = "forged"
+	if err := verifyManifest(manifestKindTree, treeSignedContent(readTree), readSig); err == nil {
+		t.Fatal("expected a tree with a changed shard hash to be rejected")
+	}
+
+	// A full version has no ops; it reads back with none
+	versions := []fileVersion{
+		{FileHash: "v1", Size: 3, Full: true, BlobHash: "b1", BlobSize: 3, Ops: []deltaOp{}, Created: 1},
+		{FileHash: "v2", Size: 4, BlobHash: "b2", BlobSize: 1, Ops: []deltaOp{{FromBase: true, Length: 3}, {Length: 1}}, Created: 2},
+	}
+	sig, err = signManifest(node.host, manifestKindVersion, versionsSignedContent(versions))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, seg, _ = capnp.NewMessage(capnp.SingleSegment(nil))
+	versionsOut, _ := NewRootVersionedFile(seg)
+	if err := fillVersionedFile(versionsOut, versions, sig); err != nil {
+		t.Fatal(err)
+	}
+	readVersions, readSig, err := readVersionedFile(versionsOut)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyManifest(manifestKindVersion, versionsSignedContent(readVersions), readSig); err != nil {
+		t.Fatalf("version history rejected after a round trip: %v", err)
+	}
+	if err := verifyManifest(manifestKindVersion, versionsSignedContent(readVersions[:1]), readSig); err == nil {
+		t.Fatal("expected a truncated history to be rejected")
+	}
+}
+
+func TestManifestContentHashes(t *testing.T) {
+	// Files sharing a prefix no longer share a file hash
+	a := append(bytes.Repeat([]byte("x"), 64), 'a')
+	b := append(bytes.Repeat([]byte("x"), 64), 'b')
+	if uploadFileHash(a) == uploadFileHash(b) {
+		t.Fatal("files differing after 32 bytes got the same file hash")
+	}
+	if fileContentHash(a) == fileContentHash(b) || len(fileContentHash(a)) != 64 {
+		t.Fatalf("unexpected content hashes %s and %s", fileContentHash(a), fileContentHash(b))
+	}
+
+	if err := checkContentHash(a, fileContentHash(a)); err != nil {
+		t.Fatalf("matching content rejected: %v", err)
+	}
+	if err := checkContentHash(b, fileContentHash(a)); !errors.Is(err, errContentHashMismatch) {
+		t.Fatalf("expected mismatched content rejected, got %v", err)
+	}
+	if err := checkContentHash(b, ""); err != nil {
+		t.Fatalf("content without a hash to check rejected: %v", err)
+	}
+
+	// The content hash is signed with the rest of the manifest
+	node, err := NewLibP2PPangeaNodeWithOptions(683, NewNodeStore(), false, true, 0)
+	if err != nil {
+		t.Fatalf("failed to create node: %v", err)
+	}
+	defer node.cancel()
+	m := &fileManifest{FileHash: uploadFileHash(a), ContentHash: fileContentHash(a)}
+	sig, err := signManifest(node.host, manifestKindFile, m.signedContent())
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.ContentHash = fileContentHash(b)
+	if err := verifyManifest(manifestKindFile, m.signedContent(), sig); err == nil {
+		t.Fatal("expected a manifest with a swapped content hash to be rejected")
+	}
+}
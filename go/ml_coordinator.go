@@ -4,19 +4,62 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
 
+// defaultLearningRate is the server learning rate applied to averaged
+// gradients when a task does not set the "learning_rate" hyperparameter
+const defaultLearningRate = 0.01
+
+// Worker liveness and epoch barrier defaults. Tasks can override the
+// barrier with the "barrier_timeout_secs" and "min_workers"
+// hyperparameters, and allow failed workers back with "readmit_workers".
+const (
+	DefaultMLHeartbeatTimeout = 30 * time.Second // Silence after which a worker is failed
+	DefaultMLBarrierTimeout   = 60 * time.Second // Round age after which K of N gradients suffice
+	mlMonitorInterval         = 5 * time.Second
+)
+
 // MLCoordinator manages distributed machine learning tasks
 type MLCoordinator struct {
 	tasks        map[string]*MLTrainingTaskData
 	gradients    map[string][]*GradientUpdateData
-	models       map[uint32]*ModelUpdateData
+	params       map[string][]float32                   // taskID -> current model parameters
+	models       map[string]map[uint32]*ModelUpdateData // taskID -> version -> update
 	workerStatus map[string]*WorkerStatus
+	transfers    map[string]map[string]*DatasetTransfer    // datasetID -> worker -> transfer
+	assignments  map[string]map[string][]*DatasetChunkData // datasetID -> worker -> chunks it holds
+	roundStart   map[string]time.Time                      // taskID -> when the current epoch began
+	dpRounds     map[string]uint32                         // taskID -> noised aggregations so far
+	traffic      map[string]*GradientTraffic               // taskID -> gradient bytes received
+	syncing      map[string]bool                           // taskID -> epoch out for global aggregation
+	transport    DatasetTransport
+	global       GlobalAggregator // Aggregates "global_aggregation" tasks across go-nodes
+	heartbeat    time.Duration    // Worker heartbeat timeout
 	mu           sync.RWMutex
 }
 
+// DatasetTransport delivers dataset chunks to a worker and returns how many
+// the worker accepted
+type DatasetTransport interface {
+	SendDatasetChunks(ctx context.Context, worker string, datasetID string, chunks []*DatasetChunkData) (int, error)
+}
+
+// DatasetTransfer tracks sending a dataset's chunks to one worker
+type DatasetTransfer struct {
+	WorkerID   string
+	DatasetID  string
+	Chunks     uint32
+	Sent       uint32
+	Status     string // "sending", "completed", "failed"
+	Error      string
+	LastUpdate time.Time
+}
+
 // MLTrainingTaskData represents an ML training task
 type MLTrainingTaskData struct {
 	TaskID            string
@@ -27,6 +70,8 @@ type MLTrainingTaskData struct {
 	AggregatorNode    string
 	Epochs            uint32
 	BatchSize         uint32
+	InitialParameters []byte    // Serialized tensor; zeros when empty
+	Privacy           *DPConfig // Differentially private aggregation; nil = off
 	CurrentEpoch      uint32
 	StartTime         time.Time
 	Status            string // "pending", "running", "completed", "failed"
@@ -41,10 +86,13 @@ type GradientUpdateData struct {
 	Loss         float64
 	Accuracy     float64
 	Timestamp    time.Time
+
+	values []float32 // Decoded Gradients
 }
 
 // ModelUpdateData represents an updated model from the aggregator
 type ModelUpdateData struct {
+	TaskID            string
 	ModelVersion      uint32
 	Parameters        []byte
 	AggregationMethod string
@@ -54,6 +102,22 @@ type ModelUpdateData struct {
 	Timestamp         time.Time
 }
 
+// GradientTraffic counts the gradient bytes a task received against what
+// the same updates would have taken uncompressed
+type GradientTraffic struct {
+	WireBytes uint64
+	RawBytes  uint64
+	Updates   map[string]uint32 // Codec -> updates received
+}
+
+// Savings returns the fraction of raw bytes compression avoided
+func (t GradientTraffic) Savings() float64 {
+	if t.RawBytes == 0 {
+		return 0
+	}
+	return 1 - float64(t.WireBytes)/float64(t.RawBytes)
+}
+
 // WorkerStatus tracks the status of a worker node
 type WorkerStatus struct {
 	WorkerID         string
@@ -77,13 +141,44 @@ func NewMLCoordinator() *MLCoordinator {
 	return &MLCoordinator{
 		tasks:        make(map[string]*MLTrainingTaskData),
 		gradients:    make(map[string][]*GradientUpdateData),
-		models:       make(map[uint32]*ModelUpdateData),
+		params:       make(map[string][]float32),
+		models:       make(map[string]map[uint32]*ModelUpdateData),
 		workerStatus: make(map[string]*WorkerStatus),
+		transfers:    make(map[string]map[string]*DatasetTransfer),
+		assignments:  make(map[string]map[string][]*DatasetChunkData),
+		roundStart:   make(map[string]time.Time),
+		dpRounds:     make(map[string]uint32),
+		traffic:      make(map[string]*GradientTraffic),
+		syncing:      make(map[string]bool),
+		heartbeat:    DefaultMLHeartbeatTimeout,
 	}
 }
 
+// SetDatasetTransport sets how dataset chunks reach workers
+func (mlc *MLCoordinator) SetDatasetTransport(t DatasetTransport) {
+	mlc.mu.Lock()
+	defer mlc.mu.Unlock()
+	mlc.transport = t
+}
+
+// SetGlobalAggregator sets where tasks with the "global_aggregation"
+// hyperparameter combine their epochs with other go-nodes
+func (mlc *MLCoordinator) SetGlobalAggregator(g GlobalAggregator) {
+	mlc.mu.Lock()
+	defer mlc.mu.Unlock()
+	mlc.global = g
+}
+
 // StartMLTraining starts a new ML training task
 func (mlc *MLCoordinator) StartMLTraining(ctx context.Context, task *MLTrainingTaskData) error {
+	// A task trained across go-nodes is started on the global aggregator
+	// first, so a node that cannot reach it trains nothing
+	if boolHyperparameter(task, "global_aggregation") {
+		if err := mlc.startGlobal(ctx, task); err != nil {
+			return err
+		}
+	}
+
 	mlc.mu.Lock()
 	defer mlc.mu.Unlock()
 
@@ -103,13 +198,31 @@ func (mlc *MLCoordinator) StartMLTraining(ctx context.Context, task *MLTrainingT
 		return fmt.Errorf("task %s already exists", task.TaskID)
 	}
 
+	if task.Privacy != nil {
+		if err := task.Privacy.Validate(); err != nil {
+			return fmt.Errorf("invalid privacy configuration: %w", err)
+		}
+	}
+
+	// Without initial parameters the model starts at zero, sized by the
+	// first gradient received
+	if len(task.InitialParameters) > 0 {
+		params, err := DecodeTensor(task.InitialParameters)
+		if err != nil {
+			return fmt.Errorf("invalid initial parameters: %w", err)
+		}
+		mlc.params[task.TaskID] = params
+	}
+
 	// Initialize task
 	task.CurrentEpoch = 0
 	task.StartTime = time.Now()
 	task.Status = "pending"
 
 	mlc.tasks[task.TaskID] = task
+	mlc.roundStart[task.TaskID] = task.StartTime
 	mlc.gradients[task.TaskID] = make([]*GradientUpdateData, 0)
+	mlc.models[task.TaskID] = make(map[uint32]*ModelUpdateData)
 
 	log.Printf("ML Training task started: %s with %d workers", task.TaskID, len(task.WorkerNodes))
 
@@ -128,9 +241,6 @@ func (mlc *MLCoordinator) StartMLTraining(ctx context.Context, task *MLTrainingT
 	// 1. Distribute the dataset to all workers
 	// 2. Initialize the model on all workers
 	// 3. Start the training loop
-	// 4. Collect gradients asynchronously
-	// 5. Perform federated averaging
-	// 6. Broadcast updated model
 
 	// Mark as running
 	task.Status = "running"
@@ -191,9 +301,48 @@ func (mlc *MLCoordinator) SubmitGradient(ctx context.Context, update *GradientUp
 		return fmt.Errorf("task not found for worker: %s", update.WorkerID)
 	}
 
-	// Add gradient to collection
+	if task.Status != "running" {
+		return fmt.Errorf("task %s is %s", taskID, task.Status)
+	}
+	if mlc.syncing[taskID] {
+		return fmt.Errorf("epoch %d of task %s is being aggregated across nodes", task.CurrentEpoch, taskID)
+	}
+	if workerStatus.Status == "failed" {
+		return fmt.Errorf("worker %s was marked failed; send a heartbeat to rejoin", update.WorkerID)
+	}
+	// Gradients must be computed against the current model
+	if update.ModelVersion != task.CurrentEpoch {
+		return fmt.Errorf("stale gradient: computed on model version %d, current is %d",
+			update.ModelVersion, task.CurrentEpoch)
+	}
+	if update.NumSamples == 0 {
+		return fmt.Errorf("gradient from %s covers no samples", update.WorkerID)
+	}
+	values, err := DecodeGradient(update.Gradients)
+	if err != nil {
+		return fmt.Errorf("invalid gradient from %s: %w", update.WorkerID, err)
+	}
+	if want := mlc.expectedTensorLen(taskID); want >= 0 && len(values) != want {
+		return fmt.Errorf("gradient from %s has %d elements, model has %d", update.WorkerID, len(values), want)
+	}
+	mlc.recordTrafficLocked(taskID, update.Gradients, len(values))
+
+	// Add gradient to collection, replacing an earlier one from the same
+	// worker in this round
 	update.Timestamp = time.Now()
-	mlc.gradients[taskID] = append(mlc.gradients[taskID], update)
+	update.values = values
+	pending := mlc.gradients[taskID]
+	replaced := false
+	for i, g := range pending {
+		if g.WorkerID == update.WorkerID {
+			pending[i] = update
+			replaced = true
+		}
+	}
+	if !replaced {
+		pending = append(pending, update)
+	}
+	mlc.gradients[taskID] = pending
 
 	// Update worker status
 	workerStatus.LastUpdate = time.Now()
@@ -203,61 +352,189 @@ func (mlc *MLCoordinator) SubmitGradient(ctx context.Context, update *GradientUp
 	log.Printf("Gradient received from worker %s for task %s: loss=%.4f, accuracy=%.4f",
 		update.WorkerID, taskID, update.Loss, update.Accuracy)
 
-	// Check if we have gradients from all workers for this epoch
-	if len(mlc.gradients[taskID]) >= len(task.WorkerNodes) {
+	// Check if every active worker has reported for this epoch
+	if len(mlc.gradients[taskID]) >= mlc.activeWorkersLocked(task) {
 		log.Printf("All gradients received for epoch %d, performing aggregation", task.CurrentEpoch)
-
-		// Perform federated averaging
-		err := mlc.aggregateGradients(taskID)
-		if err != nil {
-			return fmt.Errorf("gradient aggregation failed: %w", err)
+		if err := mlc.completeRoundLocked(task); err != nil {
+			return err
 		}
+	}
 
-		// Clear gradients for next epoch
-		mlc.gradients[taskID] = make([]*GradientUpdateData, 0)
-
-		// Increment epoch
-		task.CurrentEpoch++
+	return nil
+}
 
-		// Check if training is complete
-		if task.CurrentEpoch >= task.Epochs {
+// completeRoundLocked aggregates the gradients collected for the current
+// epoch and advances the task. Caller must hold mlc.mu.
+func (mlc *MLCoordinator) completeRoundLocked(task *MLTrainingTaskData) error {
+	// Stop rather than release a model that would exceed the privacy budget
+	if dp := task.Privacy; dp != nil && dp.MaxEpsilon > 0 {
+		if eps := dpEpsilon(mlc.dpRounds[task.TaskID]+1, dp.NoiseMultiplier, dp.Delta); eps > dp.MaxEpsilon {
+			mlc.gradients[task.TaskID] = make([]*GradientUpdateData, 0)
 			task.Status = "completed"
-			log.Printf("Training completed for task: %s", taskID)
+			log.Printf("🔒 Training stopped for task %s at epoch %d: another round would spend ε=%.3f of %.3f",
+				task.TaskID, task.CurrentEpoch, eps, dp.MaxEpsilon)
+			return nil
 		}
 	}
 
+	if boolHyperparameter(task, "global_aggregation") {
+		return mlc.startGlobalRoundLocked(task)
+	}
+
+	// Perform federated averaging
+	if err := mlc.aggregateGradients(task.TaskID); err != nil {
+		return fmt.Errorf("gradient aggregation failed: %w", err)
+	}
+
+	// Clear gradients for next epoch
+	mlc.gradients[task.TaskID] = make([]*GradientUpdateData, 0)
+	mlc.roundStart[task.TaskID] = time.Now()
+
+	// Increment epoch
+	task.CurrentEpoch++
+
+	// Check if training is complete
+	if task.CurrentEpoch >= task.Epochs {
+		task.Status = "completed"
+		log.Printf("Training completed for task: %s", task.TaskID)
+	}
 	return nil
 }
 
-// aggregateGradients performs federated averaging on collected gradients
-func (mlc *MLCoordinator) aggregateGradients(taskID string) error {
+// activeWorkersLocked counts a task's workers that have not failed. Caller
+// must hold mlc.mu.
+func (mlc *MLCoordinator) activeWorkersLocked(task *MLTrainingTaskData) int {
+	active := 0
+	for _, workerID := range task.WorkerNodes {
+		if status, ok := mlc.workerStatus[workerID]; ok && status.TaskID == task.TaskID && status.Status != "failed" {
+			active++
+		}
+	}
+	return active
+}
+
+// recordTrafficLocked adds an accepted gradient to its task's traffic.
+// Caller must hold mlc.mu.
+func (mlc *MLCoordinator) recordTrafficLocked(taskID string, encoded []byte, elements int) {
+	t := mlc.traffic[taskID]
+	if t == nil {
+		t = &GradientTraffic{Updates: make(map[string]uint32)}
+		mlc.traffic[taskID] = t
+	}
+	t.WireBytes += uint64(len(encoded))
+	t.RawBytes += uint64(rawTensorSize(elements))
+	t.Updates[GradientCodec(encoded)]++
+}
+
+// GetGradientTraffic returns the gradient bytes a task has received
+func (mlc *MLCoordinator) GetGradientTraffic(taskID string) GradientTraffic {
+	mlc.mu.RLock()
+	defer mlc.mu.RUnlock()
+	t := mlc.traffic[taskID]
+	if t == nil {
+		return GradientTraffic{}
+	}
+	out := *t
+	out.Updates = make(map[string]uint32, len(t.Updates))
+	for codec, n := range t.Updates {
+		out.Updates[codec] = n
+	}
+	return out
+}
+
+// expectedTensorLen returns the parameter count of a task's model, or -1
+// if neither parameters nor gradients have fixed it yet
+func (mlc *MLCoordinator) expectedTensorLen(taskID string) int {
+	if params, ok := mlc.params[taskID]; ok {
+		return len(params)
+	}
+	if pending := mlc.gradients[taskID]; len(pending) > 0 {
+		return len(pending[0].values)
+	}
+	return -1
+}
+
+// roundMean is the combined result of an epoch's gradients
+type roundMean struct {
+	gradient []float64
+	samples  uint32
+	loss     float64
+	accuracy float64
+}
+
+// meanGradient returns the sample-weighted mean of the gradients collected
+// for a task's epoch, or the clipped and noised mean for a differentially
+// private task, with the sample-weighted loss and accuracy
+func (mlc *MLCoordinator) meanGradient(taskID string) (*roundMean, error) {
 	gradients := mlc.gradients[taskID]
 	task := mlc.tasks[taskID]
 
 	if len(gradients) == 0 {
-		return fmt.Errorf("no gradients to aggregate")
+		return nil, fmt.Errorf("no gradients to aggregate")
 	}
 
-	// Calculate weighted average of losses and accuracies
+	// Calculate weighted average of gradients, losses and accuracies
 	totalSamples := uint32(0)
 	weightedLoss := 0.0
 	weightedAccuracy := 0.0
+	mean := make([]float64, len(gradients[0].values))
 
 	for _, grad := range gradients {
 		totalSamples += grad.NumSamples
-		weightedLoss += grad.Loss * float64(grad.NumSamples)
-		weightedAccuracy += grad.Accuracy * float64(grad.NumSamples)
+		weight := float64(grad.NumSamples)
+		weightedLoss += grad.Loss * weight
+		weightedAccuracy += grad.Accuracy * weight
+		for i, g := range grad.values {
+			mean[i] += float64(g) * weight
+		}
+	}
+	for i := range mean {
+		mean[i] /= float64(totalSamples)
+	}
+	if task.Privacy != nil {
+		mean = privateMean(gradients, *task.Privacy)
+		mlc.dpRounds[taskID]++
 	}
 
-	globalLoss := weightedLoss / float64(totalSamples)
-	globalAccuracy := weightedAccuracy / float64(totalSamples)
+	return &roundMean{
+		gradient: mean,
+		samples:  totalSamples,
+		loss:     weightedLoss / float64(totalSamples),
+		accuracy: weightedAccuracy / float64(totalSamples),
+	}, nil
+}
+
+// aggregateGradients performs federated averaging on collected gradients:
+// the mean gradient is applied to the task's parameters with the task's
+// learning rate
+func (mlc *MLCoordinator) aggregateGradients(taskID string) error {
+	gradients := mlc.gradients[taskID]
+	task := mlc.tasks[taskID]
+
+	round, err := mlc.meanGradient(taskID)
+	if err != nil {
+		return err
+	}
+	mean := round.gradient
+	globalLoss := round.loss
+	globalAccuracy := round.accuracy
+
+	params, ok := mlc.params[taskID]
+	if !ok {
+		params = make([]float32, len(mean))
+	}
+	lr := learningRate(task)
+	updated := make([]float32, len(params))
+	for i := range params {
+		updated[i] = params[i] - float32(lr*mean[i])
+	}
+	mlc.params[taskID] = updated
 
 	// Create model update
 	modelUpdate := &ModelUpdateData{
-		ModelVersion: task.CurrentEpoch + 1,
-		// Foundation: Tensor operations not yet implemented
-		// In production, Parameters would contain serialized PyTorch/TensorFlow tensors
-		Parameters:        []byte{}, // Placeholder for model parameter tensors
+		TaskID:            taskID,
+		ModelVersion:      task.CurrentEpoch + 1,
+		Parameters:        EncodeTensor(updated),
 		AggregationMethod: "fedavg",
 		NumWorkers:        uint32(len(gradients)),
 		GlobalLoss:        globalLoss,
@@ -265,41 +542,91 @@ func (mlc *MLCoordinator) aggregateGradients(taskID string) error {
 		Timestamp:         time.Now(),
 	}
 
-	mlc.models[modelUpdate.ModelVersion] = modelUpdate
-
-	log.Printf("Model aggregated for epoch %d: loss=%.4f, accuracy=%.4f, workers=%d",
-		task.CurrentEpoch, globalLoss, globalAccuracy, len(gradients))
+	mlc.models[taskID][modelUpdate.ModelVersion] = modelUpdate
 
-	// In a full implementation, this would:
-	// 1. Deserialize gradient tensors from each worker
-	// 2. Perform weighted averaging based on number of samples
-	// 3. Apply aggregation method (FedAvg, FedProx, etc.)
-	// 4. Serialize updated model parameters
-	// 5. Broadcast to all workers
+	log.Printf("Model aggregated for epoch %d: loss=%.4f, accuracy=%.4f, workers=%d, params=%d",
+		task.CurrentEpoch, globalLoss, globalAccuracy, len(gradients), len(updated))
 
 	return nil
 }
 
-// GetModelUpdate retrieves a model update for a specific version
-func (mlc *MLCoordinator) GetModelUpdate(modelVersion uint32) (*ModelUpdateData, error) {
+// learningRate returns the task's "learning_rate" hyperparameter or the default
+func learningRate(task *MLTrainingTaskData) float64 {
+	if v, ok := task.Hyperparameters["learning_rate"]; ok {
+		if lr, err := strconv.ParseFloat(v, 64); err == nil && lr > 0 {
+			return lr
+		}
+		log.Printf("⚠️  Invalid learning_rate %q for task %s, using %v", v, task.TaskID, defaultLearningRate)
+	}
+	return defaultLearningRate
+}
+
+// GetPrivacySpent returns the privacy loss of a differentially private
+// task. The second result is false when the task does not use DP.
+func (mlc *MLCoordinator) GetPrivacySpent(taskID string) (PrivacySpent, bool, error) {
 	mlc.mu.RLock()
 	defer mlc.mu.RUnlock()
 
-	model, exists := mlc.models[modelVersion]
+	task, exists := mlc.tasks[taskID]
 	if !exists {
-		return nil, fmt.Errorf("model version not found: %d", modelVersion)
+		return PrivacySpent{}, false, fmt.Errorf("task not found: %s", taskID)
+	}
+	if task.Privacy == nil {
+		return PrivacySpent{}, false, nil
+	}
+	rounds := mlc.dpRounds[taskID]
+	return PrivacySpent{
+		Rounds:  rounds,
+		Epsilon: dpEpsilon(rounds, task.Privacy.NoiseMultiplier, task.Privacy.Delta),
+		Delta:   task.Privacy.Delta,
+	}, true, nil
+}
+
+// GetModelUpdate retrieves a task's model update for a specific version. An
+// empty task ID matches the version in whichever task has it, and fails if
+// several do.
+func (mlc *MLCoordinator) GetModelUpdate(taskID string, modelVersion uint32) (*ModelUpdateData, error) {
+	mlc.mu.RLock()
+	defer mlc.mu.RUnlock()
+
+	if taskID != "" {
+		model, exists := mlc.models[taskID][modelVersion]
+		if !exists {
+			return nil, fmt.Errorf("model version %d not found for task %s", modelVersion, taskID)
+		}
+		return model, nil
 	}
 
-	return model, nil
+	var found *ModelUpdateData
+	for _, versions := range mlc.models {
+		if model, exists := versions[modelVersion]; exists {
+			if found != nil {
+				return nil, fmt.Errorf("model version %d exists in several tasks; specify a task ID", modelVersion)
+			}
+			found = model
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("model version not found: %d", modelVersion)
+	}
+	return found, nil
 }
 
-// DistributeDataset distributes dataset chunks to worker nodes
-func (mlc *MLCoordinator) DistributeDataset(ctx context.Context, datasetID string, chunks []*DatasetChunkData, workerNodes []string) error {
+// DistributeDataset splits the chunks evenly across workers and sends each
+// worker its share, verifying checksums on receipt. Returns the transfer of
+// every worker; the error summarises any that failed.
+func (mlc *MLCoordinator) DistributeDataset(ctx context.Context, datasetID string, chunks []*DatasetChunkData, workerNodes []string) ([]DatasetTransfer, error) {
 	if len(chunks) == 0 {
-		return fmt.Errorf("no chunks to distribute")
+		return nil, fmt.Errorf("no chunks to distribute")
 	}
 	if len(workerNodes) == 0 {
-		return fmt.Errorf("no worker nodes specified")
+		return nil, fmt.Errorf("no worker nodes specified")
+	}
+	mlc.mu.RLock()
+	transport := mlc.transport
+	mlc.mu.RUnlock()
+	if transport == nil {
+		return nil, fmt.Errorf("no dataset transport available")
 	}
 
 	log.Printf("Distributing dataset %s: %d chunks to %d workers",
@@ -311,8 +638,13 @@ func (mlc *MLCoordinator) DistributeDataset(ctx context.Context, datasetID strin
 		chunksPerWorker = 1
 	}
 
+	transfers := make([]*DatasetTransfer, 0, len(workerNodes))
+	var wg sync.WaitGroup
 	for i, workerID := range workerNodes {
 		startIdx := i * chunksPerWorker
+		if startIdx >= len(chunks) {
+			break
+		}
 		endIdx := startIdx + chunksPerWorker
 		if endIdx > len(chunks) || i == len(workerNodes)-1 {
 			endIdx = len(chunks)
@@ -321,15 +653,80 @@ func (mlc *MLCoordinator) DistributeDataset(ctx context.Context, datasetID strin
 		workerChunks := chunks[startIdx:endIdx]
 		log.Printf("Worker %s assigned chunks %d-%d (%d chunks)",
 			workerID, startIdx, endIdx-1, len(workerChunks))
+		mlc.assign(datasetID, workerID, workerChunks)
+
+		transfer := mlc.startTransfer(datasetID, workerID, len(workerChunks))
+		transfers = append(transfers, transfer)
+		wg.Add(1)
+		go func(transfer *DatasetTransfer, workerChunks []*DatasetChunkData) {
+			defer wg.Done()
+			sent, err := transport.SendDatasetChunks(ctx, transfer.WorkerID, datasetID, workerChunks)
+			mlc.finishTransfer(transfer, sent, err)
+		}(transfer, workerChunks)
+	}
+	wg.Wait()
 
-		// In a full implementation, this would:
-		// 1. Connect to the worker node via RPC
-		// 2. Send the dataset chunks
-		// 3. Verify receipt with checksums
-		// 4. Handle transmission errors and retries
+	mlc.mu.RLock()
+	defer mlc.mu.RUnlock()
+	result := make([]DatasetTransfer, len(transfers))
+	var failures []string
+	for i, transfer := range transfers {
+		result[i] = *transfer
+		if transfer.Status == "failed" {
+			failures = append(failures, fmt.Sprintf("%s: %s", transfer.WorkerID, transfer.Error))
+		}
+	}
+	if len(failures) > 0 {
+		return result, fmt.Errorf("%d of %d workers failed: %s",
+			len(failures), len(transfers), strings.Join(failures, "; "))
 	}
+	return result, nil
+}
 
-	return nil
+// startTransfer records a transfer to a worker as in progress
+func (mlc *MLCoordinator) startTransfer(datasetID, workerID string, chunks int) *DatasetTransfer {
+	mlc.mu.Lock()
+	defer mlc.mu.Unlock()
+	transfer := &DatasetTransfer{
+		WorkerID:   workerID,
+		DatasetID:  datasetID,
+		Chunks:     uint32(chunks),
+		Status:     "sending",
+		LastUpdate: time.Now(),
+	}
+	if mlc.transfers[datasetID] == nil {
+		mlc.transfers[datasetID] = make(map[string]*DatasetTransfer)
+	}
+	mlc.transfers[datasetID][workerID] = transfer
+	return transfer
+}
+
+// finishTransfer records the outcome of a transfer
+func (mlc *MLCoordinator) finishTransfer(transfer *DatasetTransfer, sent int, err error) {
+	mlc.mu.Lock()
+	defer mlc.mu.Unlock()
+	transfer.Sent = uint32(sent)
+	transfer.LastUpdate = time.Now()
+	if err != nil {
+		transfer.Status = "failed"
+		transfer.Error = err.Error()
+		log.Printf("❌ Dataset %s transfer to %s failed after %d/%d chunks: %v",
+			transfer.DatasetID, transfer.WorkerID, sent, transfer.Chunks, err)
+		return
+	}
+	transfer.Status = "completed"
+}
+
+// GetDatasetTransfers returns the per-worker transfers of a dataset
+func (mlc *MLCoordinator) GetDatasetTransfers(datasetID string) []DatasetTransfer {
+	mlc.mu.RLock()
+	defer mlc.mu.RUnlock()
+	transfers := make([]DatasetTransfer, 0, len(mlc.transfers[datasetID]))
+	for _, transfer := range mlc.transfers[datasetID] {
+		transfers = append(transfers, *transfer)
+	}
+	sort.Slice(transfers, func(i, j int) bool { return transfers[i].WorkerID < transfers[j].WorkerID })
+	return transfers
 }
 
 // GetWorkerStatus retrieves the status of a worker
@@ -360,25 +757,231 @@ func (mlc *MLCoordinator) ListActiveTasks() []*MLTrainingTaskData {
 	return tasks
 }
 
-// HandleWorkerFailure handles a worker failure during training
+// HandleWorkerFailure marks a worker failed, hands its dataset chunks to
+// the task's surviving workers and completes the current epoch if every
+// remaining worker has already reported
 func (mlc *MLCoordinator) HandleWorkerFailure(workerID string) error {
+	mlc.mu.Lock()
+	status, exists := mlc.workerStatus[workerID]
+	if !exists {
+		mlc.mu.Unlock()
+		return fmt.Errorf("worker not found: %s", workerID)
+	}
+	plan := mlc.failWorkerLocked(status)
+	err := mlc.checkBarrierLocked(status.TaskID, time.Now())
+	mlc.mu.Unlock()
+
+	mlc.redistribute(plan)
+	return err
+}
+
+// Heartbeat records that a worker is alive. A failed worker is readmitted
+// if its task sets "readmit_workers"; it rejoins at the current epoch.
+func (mlc *MLCoordinator) Heartbeat(workerID string) (readmitted bool, err error) {
 	mlc.mu.Lock()
 	defer mlc.mu.Unlock()
 
 	status, exists := mlc.workerStatus[workerID]
 	if !exists {
-		return fmt.Errorf("worker not found: %s", workerID)
+		return false, fmt.Errorf("worker not registered: %s", workerID)
 	}
+	task := mlc.tasks[status.TaskID]
+	if status.Status == "failed" {
+		if task == nil || task.Status != "running" || !boolHyperparameter(task, "readmit_workers") {
+			return false, fmt.Errorf("worker %s has failed and task %s does not readmit workers", workerID, status.TaskID)
+		}
+		status.Status = "idle"
+		status.CurrentEpoch = task.CurrentEpoch
+		readmitted = true
+		log.Printf("🔁 Worker %s readmitted to task %s at epoch %d", workerID, task.TaskID, task.CurrentEpoch)
+	}
+	status.LastUpdate = time.Now()
+	return readmitted, nil
+}
 
-	status.Status = "failed"
-	log.Printf("Worker failure detected: %s", workerID)
+// Run checks worker heartbeats and epoch barriers until ctx is cancelled
+func (mlc *MLCoordinator) Run(ctx context.Context) {
+	ticker := time.NewTicker(mlMonitorInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			mlc.checkWorkers(now)
+		}
+	}
+}
 
-	// In a full implementation with fault tolerance:
-	// 1. Remove worker from active worker list
-	// 2. Redistribute worker's data to other workers
-	// 3. Continue training with remaining workers
-	// 4. Log the failure for monitoring
-	// 5. Optionally retry connecting to the worker
+// checkWorkers fails workers of running tasks that have been silent past
+// the heartbeat timeout, redistributes their data and relaxes any epoch
+// barrier that has waited too long
+func (mlc *MLCoordinator) checkWorkers(now time.Time) {
+	mlc.mu.Lock()
+	var plans []redistribution
+	for _, status := range mlc.workerStatus {
+		task := mlc.tasks[status.TaskID]
+		if task == nil || task.Status != "running" || status.Status == "failed" {
+			continue
+		}
+		if silent := now.Sub(status.LastUpdate); silent > mlc.heartbeat {
+			log.Printf("💔 Worker %s missed heartbeats for %v", status.WorkerID, silent.Round(time.Second))
+			plans = append(plans, mlc.failWorkerLocked(status))
+		}
+	}
+	for taskID := range mlc.tasks {
+		if err := mlc.checkBarrierLocked(taskID, now); err != nil {
+			log.Printf("❌ Task %s: %v", taskID, err)
+		}
+	}
+	mlc.mu.Unlock()
+
+	for _, plan := range plans {
+		mlc.redistribute(plan)
+	}
+}
 
+// checkBarrierLocked completes a running task's epoch once every active
+// worker has reported, or once the round has outlived the task's barrier
+// timeout with at least its minimum number of gradients. Caller must hold
+// mlc.mu.
+func (mlc *MLCoordinator) checkBarrierLocked(taskID string, now time.Time) error {
+	task := mlc.tasks[taskID]
+	if task == nil || task.Status != "running" {
+		return nil
+	}
+	received := len(mlc.gradients[taskID])
+	if received == 0 {
+		return nil
+	}
+
+	if received >= mlc.activeWorkersLocked(task) {
+		log.Printf("All remaining workers reported for epoch %d, performing aggregation", task.CurrentEpoch)
+		return mlc.completeRoundLocked(task)
+	}
+	if now.Sub(mlc.roundStart[taskID]) < barrierTimeout(task) {
+		return nil
+	}
+	if k := minWorkers(task); received >= k {
+		log.Printf("⏱️  Epoch %d barrier timed out, proceeding with %d of %d gradients (need %d)",
+			task.CurrentEpoch, received, len(task.WorkerNodes), k)
+		return mlc.completeRoundLocked(task)
+	}
 	return nil
 }
+
+// redistribution is a failed worker's chunks to be sent to the survivors
+type redistribution struct {
+	datasetID string
+	failed    string
+	chunks    []*DatasetChunkData
+	survivors []string
+}
+
+// failWorkerLocked marks a worker failed and plans handing its chunks to the
+// task's remaining workers. Caller must hold mlc.mu.
+func (mlc *MLCoordinator) failWorkerLocked(status *WorkerStatus) redistribution {
+	status.Status = "failed"
+	log.Printf("Worker failure detected: %s", status.WorkerID)
+
+	task := mlc.tasks[status.TaskID]
+	if task == nil {
+		return redistribution{}
+	}
+	plan := redistribution{
+		datasetID: task.DatasetID,
+		failed:    status.WorkerID,
+		chunks:    mlc.assignments[task.DatasetID][status.WorkerID],
+	}
+	for _, workerID := range task.WorkerNodes {
+		if s, ok := mlc.workerStatus[workerID]; ok && s.TaskID == task.TaskID && s.Status != "failed" {
+			plan.survivors = append(plan.survivors, workerID)
+		}
+	}
+	return plan
+}
+
+// redistribute sends a failed worker's chunks round-robin to the surviving
+// workers, recording each transfer and the new assignments
+func (mlc *MLCoordinator) redistribute(plan redistribution) {
+	if len(plan.chunks) == 0 {
+		return
+	}
+	mlc.mu.RLock()
+	transport := mlc.transport
+	mlc.mu.RUnlock()
+	if transport == nil || len(plan.survivors) == 0 {
+		log.Printf("⚠️  Cannot redistribute %d chunks of %s from %s: no transport or surviving workers",
+			len(plan.chunks), plan.datasetID, plan.failed)
+		return
+	}
+
+	shares := make(map[string][]*DatasetChunkData, len(plan.survivors))
+	for i, chunk := range plan.chunks {
+		workerID := plan.survivors[i%len(plan.survivors)]
+		shares[workerID] = append(shares[workerID], chunk)
+	}
+	log.Printf("🔀 Redistributing %d chunks of %s from %s to %d workers",
+		len(plan.chunks), plan.datasetID, plan.failed, len(shares))
+
+	var wg sync.WaitGroup
+	for workerID, share := range shares {
+		transfer := mlc.startTransfer(plan.datasetID, workerID, len(share))
+		wg.Add(1)
+		go func(transfer *DatasetTransfer, share []*DatasetChunkData) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), mlDataStreamTimeout)
+			defer cancel()
+			sent, err := transport.SendDatasetChunks(ctx, transfer.WorkerID, plan.datasetID, share)
+			mlc.finishTransfer(transfer, sent, err)
+			if sent > 0 {
+				mlc.assign(plan.datasetID, transfer.WorkerID, share[:sent])
+			}
+		}(transfer, share)
+	}
+	wg.Wait()
+
+	mlc.mu.Lock()
+	delete(mlc.assignments[plan.datasetID], plan.failed)
+	mlc.mu.Unlock()
+}
+
+// assign records chunks as held by a worker
+func (mlc *MLCoordinator) assign(datasetID, workerID string, chunks []*DatasetChunkData) {
+	mlc.mu.Lock()
+	defer mlc.mu.Unlock()
+	if mlc.assignments[datasetID] == nil {
+		mlc.assignments[datasetID] = make(map[string][]*DatasetChunkData)
+	}
+	mlc.assignments[datasetID][workerID] = append(mlc.assignments[datasetID][workerID], chunks...)
+}
+
+// barrierTimeout returns the task's "barrier_timeout_secs" hyperparameter or
+// the default
+func barrierTimeout(task *MLTrainingTaskData) time.Duration {
+	if v, ok := task.Hyperparameters["barrier_timeout_secs"]; ok {
+		if secs, err := strconv.ParseFloat(v, 64); err == nil && secs > 0 {
+			return time.Duration(secs * float64(time.Second))
+		}
+		log.Printf("⚠️  Invalid barrier_timeout_secs %q for task %s, using %v", v, task.TaskID, DefaultMLBarrierTimeout)
+	}
+	return DefaultMLBarrierTimeout
+}
+
+// minWorkers returns how many gradients a timed-out epoch needs: the task's
+// "min_workers" hyperparameter, or a majority of its workers
+func minWorkers(task *MLTrainingTaskData) int {
+	if v, ok := task.Hyperparameters["min_workers"]; ok {
+		if k, err := strconv.Atoi(v); err == nil && k > 0 {
+			return k
+		}
+		log.Printf("⚠️  Invalid min_workers %q for task %s, using a majority", v, task.TaskID)
+	}
+	return len(task.WorkerNodes)/2 + 1
+}
+
+// boolHyperparameter reports whether a task sets a hyperparameter to true
+func boolHyperparameter(task *MLTrainingTaskData, name string) bool {
+	v, err := strconv.ParseBool(task.Hyperparameters[name])
+	return err == nil && v
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestFedAvgAppliesWeightedGradients(t *testing.T) {
+	mlc := NewMLCoordinator()
+	ctx := context.Background()
+	err := mlc.StartMLTraining(ctx, &MLTrainingTaskData{
+		TaskID:            "task",
+		DatasetID:         "data",
+		WorkerNodes:       []string{"w1", "w2"},
+		Epochs:            2,
+		Hyperparameters:   map[string]string{"learning_rate": "0.5"},
+		InitialParameters: EncodeTensor([]float32{1, 1}),
+	})
+	if err != nil {
+		t.Fatalf("StartMLTraining failed: %v", err)
+	}
+
+	submit := func(worker string, version uint32, grad []float32, samples uint32) error {
+		return mlc.SubmitGradient(ctx, &GradientUpdateData{
+			WorkerID: worker, ModelVersion: version, Gradients: EncodeTensor(grad), NumSamples: samples,
+		})
+	}
+	if err := submit("w1", 0, []float32{1, 0}, 1); err != nil {
+		t.Fatalf("SubmitGradient failed: %v", err)
+	}
+	if err := submit("w2", 0, []float32{1, 2, 3}, 3); err == nil {
+		t.Error("expected a gradient of the wrong size to be rejected")
+	}
+	if err := submit("w2", 0, []float32{0, 2}, 3); err != nil {
+		t.Fatalf("SubmitGradient failed: %v", err)
+	}
+
+	update, err := mlc.GetModelUpdate("task", 1)
+	if err != nil {
+		t.Fatalf("GetModelUpdate failed: %v", err)
+	}
+	params, err := DecodeTensor(update.Parameters)
+	if err != nil {
+		t.Fatalf("DecodeTensor failed: %v", err)
+	}
+	// Weighted mean gradient is [0.25, 1.5]; params move by 0.5 of it
+	if len(params) != 2 || params[0] != 0.875 || params[1] != 0.25 {
+		t.Errorf("expected [0.875 0.25], got %v", params)
+	}
+
+	if err := submit("w1", 0, []float32{1, 0}, 1); err == nil {
+		t.Error("expected a gradient for the previous model version to be rejected")
+	}
+	if _, err := mlc.GetModelUpdate("", 1); err != nil {
+		t.Errorf("expected the version to resolve without a task ID: %v", err)
+	}
+}
+
+func TestDecodeTensorRejectsMalformedInput(t *testing.T) {
+	data := EncodeTensor([]float32{1, 2})
+	if _, err := DecodeTensor(data[:len(data)-1]); err == nil {
+		t.Error("expected a truncated tensor to be rejected")
+	}
+	if _, err := DecodeTensor([]byte("nope0000")); err == nil {
+		t.Error("expected a missing header to be rejected")
+	}
+}
+
+func TestCompressedGradients(t *testing.T) {
+	values := []float32{-1, 0.5, 0, 2, -0.25, 1}
+
+	quantized := QuantizeTensor(values)
+	decoded, err := DecodeGradient(quantized)
+	if err != nil {
+		t.Fatalf("DecodeGradient(q8) failed: %v", err)
+	}
+	step := float32(3.0 / 255)
+	for i, v := range decoded {
+		if d := v - values[i]; d > step/2+1e-6 || d < -step/2-1e-6 {
+			t.Errorf("element %d: %v quantized to %v", i, values[i], v)
+		}
+	}
+
+	sparse := SparsifyTensor(values, 2)
+	decoded, err = DecodeGradient(sparse)
+	if err != nil {
+		t.Fatalf("DecodeGradient(topk) failed: %v", err)
+	}
+	if want := []float32{-1, 0, 0, 2, 0, 0}; fmt.Sprint(decoded) != fmt.Sprint(want) {
+		t.Errorf("expected %v, got %v", want, decoded)
+	}
+	bad := append([]byte(nil), sparse...)
+	bad[12] = 0xff // First index out of range
+	if _, err := DecodeGradient(bad); err == nil {
+		t.Error("expected an out-of-range sparse index to be rejected")
+	}
+
+	// The coordinator aggregates compressed updates and counts the savings
+	mlc := NewMLCoordinator()
+	ctx := context.Background()
+	err = mlc.StartMLTraining(ctx, &MLTrainingTaskData{TaskID: "task", DatasetID: "data", WorkerNodes: []string{"w1", "w2"}, Epochs: 1})
+	if err != nil {
+		t.Fatalf("StartMLTraining failed: %v", err)
+	}
+	for worker, grad := range map[string][]byte{"w1": quantized, "w2": sparse} {
+		if err := mlc.SubmitGradient(ctx, &GradientUpdateData{WorkerID: worker, Gradients: grad, NumSamples: 1}); err != nil {
+			t.Fatalf("SubmitGradient(%s) failed: %v", worker, err)
+		}
+	}
+	if _, err := mlc.GetModelUpdate("task", 1); err != nil {
+		t.Fatalf("expected the compressed round to aggregate: %v", err)
+	}
+	traffic := mlc.GetGradientTraffic("task")
+	if traffic.RawBytes != 2*32 || traffic.WireBytes != uint64(len(quantized)+len(sparse)) {
+		t.Errorf("unexpected traffic %+v", traffic)
+	}
+	if traffic.Updates[CodecQuantized] != 1 || traffic.Updates[CodecTopK] != 1 || traffic.Savings() <= 0 {
+		t.Errorf("expected one update per codec with savings, got %+v", traffic)
+	}
+}
+
+func TestDistributeDatasetVerifiesChecksums(t *testing.T) {
+	coordStore := NewNodeStore()
+	coordinator, err := NewLibP2PPangeaNodeWithOptions(471, coordStore, false, true, 12470)
+	if err != nil {
+		t.Fatalf("failed to create coordinator node: %v", err)
+	}
+	defer coordinator.cancel()
+
+	workerStore := NewNodeStore()
+	worker, err := NewLibP2PPangeaNodeWithOptions(472, workerStore, false, true, 12471)
+	if err != nil {
+		t.Fatalf("failed to create worker node: %v", err)
+	}
+	defer worker.cancel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := coordinator.host.Connect(ctx, peer.AddrInfo{ID: worker.host.ID(), Addrs: worker.host.Addrs()}); err != nil {
+		t.Fatalf("connect failed: %v", err)
+	}
+
+	mlc := NewMLCoordinator()
+	mlc.SetDatasetTransport(coordinator.GetMLDataService())
+	workerID := worker.host.ID().String()
+	chunks := []*DatasetChunkData{
+		{ChunkID: 0, Data: []byte("rows 0-9"), Labels: []byte("labels 0-9")},
+		{ChunkID: 1, Data: []byte("rows 10-19"), Checksum: ChunkChecksum([]byte("rows 10-19"), nil)},
+	}
+	transfers, err := mlc.DistributeDataset(ctx, "ds", chunks, []string{workerID})
+	if err != nil {
+		t.Fatalf("DistributeDataset failed: %v", err)
+	}
+	if len(transfers) != 1 || transfers[0].Status != "completed" || transfers[0].Sent != 2 {
+		t.Fatalf("expected a completed transfer of 2 chunks, got %+v", transfers)
+	}
+	received := worker.GetMLDataService().ReceivedChunks("ds")
+	if len(received) != 2 || string(received[1].Data) != "rows 10-19" {
+		t.Fatalf("worker did not receive the chunks: %+v", received)
+	}
+
+	// A checksum that does not match the data is rejected by the worker
+	corrupt := []*DatasetChunkData{{ChunkID: 2, Data: []byte("rows 20-29"), Checksum: "bad"}}
+	transfers, err = mlc.DistributeDataset(ctx, "ds", corrupt, []string{workerID})
+	if err == nil {
+		t.Fatal("expected distribution with a corrupt chunk to fail")
+	}
+	if len(transfers) != 1 || transfers[0].Status != "failed" || transfers[0].Sent != 0 {
+		t.Errorf("expected the corrupt transfer to fail, got %+v", transfers)
+	}
+	if len(worker.GetMLDataService().ReceivedChunks("ds")) != 2 {
+		t.Error("corrupt chunk was stored")
+	}
+}
+
+// recordingTransport accepts every chunk and remembers who received what
+type recordingTransport struct {
+	mu   sync.Mutex
+	sent map[string][]uint32
+}
+
+func (r *recordingTransport) SendDatasetChunks(ctx context.Context, worker string, datasetID string, chunks []*DatasetChunkData) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, chunk := range chunks {
+		r.sent[worker] = append(r.sent[worker], chunk.ChunkID)
+	}
+	return len(chunks), nil
+}
+
+func TestWorkerFailureRecovery(t *testing.T) {
+	transport := &recordingTransport{sent: make(map[string][]uint32)}
+	mlc := NewMLCoordinator()
+	mlc.SetDatasetTransport(transport)
+	ctx := context.Background()
+	workers := []string{"w1", "w2", "w3"}
+	err := mlc.StartMLTraining(ctx, &MLTrainingTaskData{
+		TaskID:          "task",
+		DatasetID:       "data",
+		WorkerNodes:     workers,
+		Epochs:          5,
+		Hyperparameters: map[string]string{"barrier_timeout_secs": "10", "min_workers": "2", "readmit_workers": "true"},
+	})
+	if err != nil {
+		t.Fatalf("StartMLTraining failed: %v", err)
+	}
+	chunks := make([]*DatasetChunkData, 6)
+	for i := range chunks {
+		chunks[i] = &DatasetChunkData{ChunkID: uint32(i), Data: []byte{byte(i)}}
+	}
+	if _, err := mlc.DistributeDataset(ctx, "data", chunks, workers); err != nil {
+		t.Fatalf("DistributeDataset failed: %v", err)
+	}
+
+	submit := func(worker string, version uint32) error {
+		return mlc.SubmitGradient(ctx, &GradientUpdateData{
+			WorkerID: worker, ModelVersion: version, Gradients: EncodeTensor([]float32{1}), NumSamples: 1,
+		})
+	}
+	task, _ := mlc.GetMLTrainingStatus("task")
+
+	// Two of three gradients do not close the epoch until the barrier times out
+	if err := submit("w1", 0); err != nil {
+		t.Fatalf("SubmitGradient failed: %v", err)
+	}
+	if err := submit("w2", 0); err != nil {
+		t.Fatalf("SubmitGradient failed: %v", err)
+	}
+	start := time.Now()
+	mlc.checkWorkers(start.Add(5 * time.Second))
+	if task.CurrentEpoch != 0 {
+		t.Fatalf("epoch advanced before the barrier timeout")
+	}
+	mlc.checkWorkers(start.Add(11 * time.Second))
+	if task.CurrentEpoch != 1 {
+		t.Fatalf("expected the relaxed barrier to advance to epoch 1, got %d", task.CurrentEpoch)
+	}
+
+	// w3 goes silent: it is failed and its chunks move to w1 and w2
+	mlc.Heartbeat("w1")
+	mlc.Heartbeat("w2")
+	mlc.workerStatus["w3"].LastUpdate = time.Now().Add(-2 * DefaultMLHeartbeatTimeout)
+	mlc.checkWorkers(time.Now())
+	if status, _ := mlc.GetWorkerStatus("w3"); status.Status != "failed" {
+		t.Fatalf("expected w3 to be failed, got %s", status.Status)
+	}
+	if got := append(transport.sent["w1"], transport.sent["w2"]...); len(got) != 6 {
+		t.Fatalf("expected w3's two chunks to be resent, got %v", transport.sent)
+	}
+	if err := submit("w3", 1); err == nil {
+		t.Error("expected a gradient from a failed worker to be rejected")
+	}
+
+	// The remaining two workers close the epoch without waiting
+	if err := submit("w1", 1); err != nil {
+		t.Fatalf("SubmitGradient failed: %v", err)
+	}
+	if err := submit("w2", 1); err != nil {
+		t.Fatalf("SubmitGradient failed: %v", err)
+	}
+	if task.CurrentEpoch != 2 {
+		t.Fatalf("expected epoch 2 after all active workers reported, got %d", task.CurrentEpoch)
+	}
+
+	// On reconnect w3 is readmitted and counted again
+	readmitted, err := mlc.Heartbeat("w3")
+	if err != nil || !readmitted {
+		t.Fatalf("expected w3 to be readmitted, got %v, %v", readmitted, err)
+	}
+	if err := submit("w3", 2); err != nil {
+		t.Fatalf("SubmitGradient from readmitted worker failed: %v", err)
+	}
+	if err := submit("w1", 2); err != nil {
+		t.Fatalf("SubmitGradient failed: %v", err)
+	}
+	if task.CurrentEpoch != 2 {
+		t.Error("epoch advanced without the readmitted worker's peers")
+	}
+}
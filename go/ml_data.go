@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+const (
+	// MLDataProtocolID is the libp2p protocol for sending dataset chunks to
+	// training workers
+	MLDataProtocolID = "/pangea/ml-data/1.0.0"
+
+	// maxMLChunkAttempts bounds how often a chunk rejected by the worker is
+	// resent
+	maxMLChunkAttempts = 3
+
+	// mlDataStreamTimeout bounds sending one worker's chunks
+	mlDataStreamTimeout = 2 * time.Minute
+)
+
+// ChunkChecksum returns the hex SHA-256 of a chunk's data followed by its
+// labels
+func ChunkChecksum(data, labels []byte) string {
+	h := sha256.New()
+	h.Write(data)
+	h.Write(labels)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// mlDataFrame carries one dataset chunk
+type mlDataFrame struct {
+	DatasetID string `json:"datasetId"`
+	ChunkID   uint32 `json:"chunkId"`
+	Data      []byte `json:"data"`
+	Labels    []byte `json:"labels,omitempty"`
+	Checksum  string `json:"checksum"`
+}
+
+// mlDataAck is the worker's answer to one chunk
+type mlDataAck struct {
+	ChunkID  uint32 `json:"chunkId"`
+	Accepted bool   `json:"accepted"`
+	Error    string `json:"error,omitempty"`
+}
+
+// MLDataService sends dataset chunks to workers and stores the chunks this
+// node receives as a worker
+type MLDataService struct {
+	host     host.Host
+	received map[string]map[uint32]*DatasetChunkData // datasetID -> chunkID -> chunk
+	mu       sync.RWMutex
+}
+
+// NewMLDataService creates the service and registers its protocol handler
+func NewMLDataService(h host.Host) *MLDataService {
+	ds := &MLDataService{
+		host:     h,
+		received: make(map[string]map[uint32]*DatasetChunkData),
+	}
+	h.SetStreamHandler(protocol.ID(MLDataProtocolID), ds.handleStream)
+	return ds
+}
+
+// handleStream receives chunks until the sender closes the stream,
+// acknowledging each one after verifying its checksum
+func (ds *MLDataService) handleStream(s network.Stream) {
+	defer s.Close()
+
+	remote := s.Conn().RemotePeer()
+	decoder := json.NewDecoder(s)
+	encoder := json.NewEncoder(s)
+	for {
+		var frame mlDataFrame
+		if err := decoder.Decode(&frame); err != nil {
+			return
+		}
+
+		ack := mlDataAck{ChunkID: frame.ChunkID, Accepted: true}
+		if sum := ChunkChecksum(frame.Data, frame.Labels); sum != frame.Checksum {
+			ack = mlDataAck{ChunkID: frame.ChunkID, Error: "checksum mismatch"}
+			log.Printf("❌ [ML-DATA] Chunk %d of %s from %s failed checksum", frame.ChunkID, frame.DatasetID, shortPeerID(remote))
+		} else {
+			ds.store(frame)
+		}
+		if err := encoder.Encode(ack); err != nil {
+			log.Printf("❌ [ML-DATA] Failed to ack chunk %d to %s: %v", frame.ChunkID, shortPeerID(remote), err)
+			return
+		}
+	}
+}
+
+// store keeps a verified chunk
+func (ds *MLDataService) store(frame mlDataFrame) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	chunks := ds.received[frame.DatasetID]
+	if chunks == nil {
+		chunks = make(map[uint32]*DatasetChunkData)
+		ds.received[frame.DatasetID] = chunks
+	}
+	chunks[frame.ChunkID] = &DatasetChunkData{
+		ChunkID:  frame.ChunkID,
+		Data:     frame.Data,
+		Labels:   frame.Labels,
+		Checksum: frame.Checksum,
+	}
+}
+
+// SendDatasetChunks sends chunks to a worker over one stream. Each chunk is
+// resent up to maxMLChunkAttempts times if the worker rejects it. Returns
+// the number of chunks the worker accepted.
+func (ds *MLDataService) SendDatasetChunks(ctx context.Context, worker string, datasetID string, chunks []*DatasetChunkData) (int, error) {
+	p, err := peer.Decode(worker)
+	if err != nil {
+		return 0, fmt.Errorf("invalid worker peer ID: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, mlDataStreamTimeout)
+	defer cancel()
+	s, err := ds.host.NewStream(ctx, p, protocol.ID(MLDataProtocolID))
+	if err != nil {
+		return 0, fmt.Errorf("failed to open ml-data stream: %w", err)
+	}
+	defer s.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		s.SetDeadline(deadline)
+	}
+
+	encoder := json.NewEncoder(s)
+	decoder := json.NewDecoder(s)
+	for sent, chunk := range chunks {
+		frame := mlDataFrame{
+			DatasetID: datasetID,
+			ChunkID:   chunk.ChunkID,
+			Data:      chunk.Data,
+			Labels:    chunk.Labels,
+			Checksum:  chunk.Checksum,
+		}
+		if frame.Checksum == "" {
+			frame.Checksum = ChunkChecksum(chunk.Data, chunk.Labels)
+		}
+
+		var ack mlDataAck
+		for attempt := 1; attempt <= maxMLChunkAttempts; attempt++ {
+			if err := encoder.Encode(frame); err != nil {
+				return sent, fmt.Errorf("failed to send chunk %d: %w", chunk.ChunkID, err)
+			}
+			if err := decoder.Decode(&ack); err != nil {
+				return sent, fmt.Errorf("no ack for chunk %d: %w", chunk.ChunkID, err)
+			}
+			if ack.Accepted {
+				break
+			}
+			log.Printf("⚠️  [ML-DATA] %s rejected chunk %d (attempt %d/%d): %s",
+				shortPeerID(p), chunk.ChunkID, attempt, maxMLChunkAttempts, ack.Error)
+		}
+		if !ack.Accepted {
+			return sent, fmt.Errorf("chunk %d rejected: %s", chunk.ChunkID, ack.Error)
+		}
+	}
+	return len(chunks), nil
+}
+
+// ReceivedChunks returns the verified chunks of a dataset held by this node
+func (ds *MLDataService) ReceivedChunks(datasetID string) []*DatasetChunkData {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+	chunks := make([]*DatasetChunkData, 0, len(ds.received[datasetID]))
+	for _, chunk := range ds.received[datasetID] {
+		chunks = append(chunks, chunk)
+	}
+	sort.Slice(chunks, func(i, j int) bool { return chunks[i].ChunkID < chunks[j].ChunkID })
+	return chunks
+}
@@ -0,0 +1,430 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A task with the "global_aggregation" hyperparameter is trained by every
+// go-node listed in its "global_nodes" hyperparameter. Each node averages
+// its own workers' gradients for an epoch, sends the mean to the global
+// aggregator instead of applying it, and continues from the global model
+// published once the nodes' means are combined.
+const (
+	// globalModelWait is how long one GetGlobalModel long-poll asks the
+	// orchestrator to hold the request; the orchestrator caps it at 30s
+	globalModelWait = 25 * time.Second
+
+	// globalSyncTimeout bounds waiting for one epoch's global model
+	globalSyncTimeout = 10 * time.Minute
+)
+
+var (
+	// errGlobalTaskExists is returned by StartTraining when another node
+	// has already started the task on the aggregator
+	errGlobalTaskExists = errors.New("training task already exists on the global aggregator")
+
+	// errGlobalStale is returned by SubmitGradient when the aggregator has
+	// moved past the gradient's epoch or finished the task
+	errGlobalStale = errors.New("global aggregator is past this epoch")
+
+	// errOrchestratorUnavailable marks a call another orchestrator of the
+	// cluster may still answer
+	errOrchestratorUnavailable = errors.New("orchestrator unavailable")
+)
+
+// GlobalAggregator combines the epochs of go-nodes training the same task
+type GlobalAggregator interface {
+	StartTraining(ctx context.Context, task *GlobalTrainingTask) error
+	SubmitGradient(ctx context.Context, update *GlobalGradient) error
+	GetGlobalModel(ctx context.Context, taskID string, afterVersion uint32) (*GlobalModelData, error)
+	GetTrainingStatus(ctx context.Context, taskID string) (string, error)
+}
+
+// GlobalTrainingTask describes a task to the global aggregator
+type GlobalTrainingTask struct {
+	TaskID            string
+	Nodes             []string // go-nodes that each submit one gradient per epoch
+	Epochs            uint32
+	LearningRate      float64
+	InitialParameters []float64
+}
+
+// GlobalGradient is one go-node's sample-weighted mean gradient for an epoch
+type GlobalGradient struct {
+	TaskID       string
+	ModelVersion uint32
+	Gradients    []float64
+	NumSamples   uint32
+	Loss         float64
+	Accuracy     float64
+}
+
+// GlobalModelData is a global model published by the aggregator
+type GlobalModelData struct {
+	TaskID     string
+	Version    uint32
+	Parameters []float64
+	NumNodes   uint32
+	Loss       float64
+	Accuracy   float64
+	Final      bool
+}
+
+// OrchestratorClient is a GlobalAggregator reached through the go
+// orchestrator's JSON training API
+type OrchestratorClient struct {
+	baseURLs []string
+	token    string
+	nodeID   string
+	client   *http.Client
+
+	mu      sync.Mutex
+	current int // index of the orchestrator that last answered
+}
+
+// NewOrchestratorClient creates a client for the training API at baseURL,
+// submitting gradients as nodeID. baseURL may list the comma-separated
+// APIs of an orchestrator cluster: a call that cannot reach one, or that
+// it answers Unavailable while electing a leader, moves on to the next.
+func NewOrchestratorClient(baseURL, token, nodeID string) *OrchestratorClient {
+	var urls []string
+	for _, u := range strings.Split(baseURL, ",") {
+		if u = strings.TrimRight(strings.TrimSpace(u), "/"); u != "" {
+			urls = append(urls, u)
+		}
+	}
+	return &OrchestratorClient{
+		baseURLs: urls,
+		token:    token,
+		nodeID:   nodeID,
+		client:   &http.Client{Timeout: globalModelWait + 30*time.Second},
+	}
+}
+
+// StartTraining starts the task on the orchestrator. It returns
+// errGlobalTaskExists when another node started it first.
+func (c *OrchestratorClient) StartTraining(ctx context.Context, task *GlobalTrainingTask) error {
+	req := map[string]any{
+		"taskId":            task.TaskID,
+		"nodeIds":           task.Nodes,
+		"epochs":            task.Epochs,
+		"learningRate":      task.LearningRate,
+		"initialParameters": nonNil(task.InitialParameters),
+	}
+	return c.call(ctx, "StartTraining", req, nil)
+}
+
+// SubmitGradient sends this node's gradient for an epoch
+func (c *OrchestratorClient) SubmitGradient(ctx context.Context, update *GlobalGradient) error {
+	req := map[string]any{
+		"taskId":       update.TaskID,
+		"nodeId":       c.nodeID,
+		"modelVersion": update.ModelVersion,
+		"gradients":    nonNil(update.Gradients),
+		"numSamples":   update.NumSamples,
+		"loss":         update.Loss,
+		"accuracy":     update.Accuracy,
+	}
+	return c.call(ctx, "SubmitGradient", req, nil)
+}
+
+// GetGlobalModel returns the task's latest global model, waiting for one
+// past afterVersion for up to globalModelWait
+func (c *OrchestratorClient) GetGlobalModel(ctx context.Context, taskID string, afterVersion uint32) (*GlobalModelData, error) {
+	req := map[string]any{
+		"taskId":       taskID,
+		"afterVersion": afterVersion,
+		"waitMs":       globalModelWait.Milliseconds(),
+	}
+	var resp struct {
+		TaskID     string    `json:"taskId"`
+		Version    uint32    `json:"version"`
+		Parameters []float64 `json:"parameters"`
+		NumNodes   uint32    `json:"numNodes"`
+		Loss       float64   `json:"loss"`
+		Accuracy   float64   `json:"accuracy"`
+		Final      bool      `json:"final"`
+	}
+	if err := c.call(ctx, "GetGlobalModel", req, &resp); err != nil {
+		return nil, err
+	}
+	return &GlobalModelData{
+		TaskID:     resp.TaskID,
+		Version:    resp.Version,
+		Parameters: resp.Parameters,
+		NumNodes:   resp.NumNodes,
+		Loss:       resp.Loss,
+		Accuracy:   resp.Accuracy,
+		Final:      resp.Final,
+	}, nil
+}
+
+// GetTrainingStatus returns the task's status on the orchestrator:
+// "running", "completed" or "stopped"
+func (c *OrchestratorClient) GetTrainingStatus(ctx context.Context, taskID string) (string, error) {
+	var resp struct {
+		Status string `json:"status"`
+	}
+	if err := c.call(ctx, "GetTrainingStatus", map[string]any{"taskId": taskID}, &resp); err != nil {
+		return "", err
+	}
+	return resp.Status, nil
+}
+
+// call posts a request to /rpc/<method> and decodes the response into out,
+// failing over across the orchestrators of a cluster
+func (c *OrchestratorClient) call(ctx context.Context, method string, req, out any) error {
+	if len(c.baseURLs) == 0 {
+		return fmt.Errorf("orchestrator %s: no orchestrator URL configured", method)
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	start := c.current
+	c.mu.Unlock()
+	for i := range c.baseURLs {
+		idx := (start + i) % len(c.baseURLs)
+		err = c.callOne(ctx, c.baseURLs[idx], method, body, out)
+		if !errors.Is(err, errOrchestratorUnavailable) || ctx.Err() != nil {
+			if err == nil {
+				c.mu.Lock()
+				c.current = idx
+				c.mu.Unlock()
+			}
+			return err
+		}
+	}
+	return err
+}
+
+// callOne posts a request to one orchestrator
+func (c *OrchestratorClient) callOne(ctx context.Context, baseURL, method string, body []byte, out any) error {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, baseURL+"/rpc/"+method, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if c.token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.client.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("orchestrator %s: %w: %v", method, errOrchestratorUnavailable, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 256<<20))
+	if err != nil {
+		return fmt.Errorf("orchestrator %s: %w", method, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var rpcErr struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(data, &rpcErr) != nil || rpcErr.Code == "" {
+			if resp.StatusCode == http.StatusServiceUnavailable || resp.StatusCode == http.StatusBadGateway {
+				return fmt.Errorf("orchestrator %s: %w: HTTP %d", method, errOrchestratorUnavailable, resp.StatusCode)
+			}
+			return fmt.Errorf("orchestrator %s: HTTP %d", method, resp.StatusCode)
+		}
+		switch rpcErr.Code {
+		case "AlreadyExists":
+			return errGlobalTaskExists
+		case "FailedPrecondition":
+			return fmt.Errorf("%w: %s", errGlobalStale, rpcErr.Message)
+		case "Unavailable":
+			return fmt.Errorf("orchestrator %s: %w: %s", method, errOrchestratorUnavailable, rpcErr.Message)
+		}
+		return fmt.Errorf("orchestrator %s: %s: %s", method, rpcErr.Code, rpcErr.Message)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("orchestrator %s: invalid response: %w", method, err)
+	}
+	return nil
+}
+
+// nonNil keeps an empty slice from encoding as null
+func nonNil(v []float64) []float64 {
+	if v == nil {
+		return []float64{}
+	}
+	return v
+}
+
+// startGlobal starts a task on the global aggregator, or joins it if
+// another node started it first
+func (mlc *MLCoordinator) startGlobal(ctx context.Context, task *MLTrainingTaskData) error {
+	mlc.mu.RLock()
+	global := mlc.global
+	mlc.mu.RUnlock()
+	if global == nil {
+		return fmt.Errorf("task %s needs global aggregation but no orchestrator is configured", task.TaskID)
+	}
+
+	var nodes []string
+	for _, node := range strings.Split(task.Hyperparameters["global_nodes"], ",") {
+		if node = strings.TrimSpace(node); node != "" {
+			nodes = append(nodes, node)
+		}
+	}
+	if len(nodes) == 0 {
+		return fmt.Errorf("task %s needs the global_nodes hyperparameter for global aggregation", task.TaskID)
+	}
+	var initial []float64
+	if len(task.InitialParameters) > 0 {
+		params, err := DecodeTensor(task.InitialParameters)
+		if err != nil {
+			return fmt.Errorf("invalid initial parameters: %w", err)
+		}
+		initial = make([]float64, len(params))
+		for i, v := range params {
+			initial[i] = float64(v)
+		}
+	}
+
+	err := global.StartTraining(ctx, &GlobalTrainingTask{
+		TaskID:            task.TaskID,
+		Nodes:             nodes,
+		Epochs:            task.Epochs,
+		LearningRate:      learningRate(task),
+		InitialParameters: initial,
+	})
+	switch {
+	case errors.Is(err, errGlobalTaskExists):
+		log.Printf("🌐 Joining global training task %s", task.TaskID)
+	case err != nil:
+		return fmt.Errorf("failed to start global training: %w", err)
+	default:
+		log.Printf("🌐 Started global training task %s across %d nodes", task.TaskID, len(nodes))
+	}
+	return nil
+}
+
+// startGlobalRoundLocked sends the epoch's mean gradient to the global
+// aggregator instead of applying it. Workers' gradients are refused until
+// the global model for the next epoch arrives. Caller must hold mlc.mu.
+func (mlc *MLCoordinator) startGlobalRoundLocked(task *MLTrainingTaskData) error {
+	if mlc.global == nil {
+		return fmt.Errorf("task %s needs global aggregation but no orchestrator is configured", task.TaskID)
+	}
+	round, err := mlc.meanGradient(task.TaskID)
+	if err != nil {
+		return fmt.Errorf("gradient aggregation failed: %w", err)
+	}
+	workers := uint32(len(mlc.gradients[task.TaskID]))
+	mlc.gradients[task.TaskID] = make([]*GradientUpdateData, 0)
+	mlc.syncing[task.TaskID] = true
+
+	update := &GlobalGradient{
+		TaskID:       task.TaskID,
+		ModelVersion: task.CurrentEpoch,
+		Gradients:    round.gradient,
+		NumSamples:   round.samples,
+		Loss:         round.loss,
+		Accuracy:     round.accuracy,
+	}
+	log.Printf("🌐 Sending epoch %d of task %s to the global aggregator: loss=%.4f, workers=%d",
+		task.CurrentEpoch, task.TaskID, round.loss, workers)
+	go mlc.syncGlobal(mlc.global, task, update, workers)
+	return nil
+}
+
+// syncGlobal submits an epoch's mean gradient, waits for the global model
+// that follows it, and moves the task on to it
+func (mlc *MLCoordinator) syncGlobal(global GlobalAggregator, task *MLTrainingTaskData, update *GlobalGradient, workers uint32) {
+	ctx, cancel := context.WithTimeout(context.Background(), globalSyncTimeout)
+	defer cancel()
+	model, err := exchangeGlobal(ctx, global, update)
+
+	mlc.mu.Lock()
+	defer mlc.mu.Unlock()
+	delete(mlc.syncing, task.TaskID)
+	if task.Status != "running" {
+		return
+	}
+	if err != nil {
+		task.Status = "failed"
+		log.Printf("❌ Global aggregation failed for task %s at epoch %d: %v", task.TaskID, update.ModelVersion, err)
+		return
+	}
+	mlc.applyGlobalModelLocked(task, model, workers)
+}
+
+// exchangeGlobal submits a gradient and returns the first global model
+// past its version. A gradient the aggregator no longer needs is dropped
+// and the node catches up to the current model.
+func exchangeGlobal(ctx context.Context, global GlobalAggregator, update *GlobalGradient) (*GlobalModelData, error) {
+	if err := global.SubmitGradient(ctx, update); err != nil {
+		if !errors.Is(err, errGlobalStale) {
+			return nil, err
+		}
+		log.Printf("⚠️  Global aggregator skipped epoch %d of task %s: %v", update.ModelVersion, update.TaskID, err)
+	}
+	for {
+		model, err := global.GetGlobalModel(ctx, update.TaskID, update.ModelVersion)
+		if err != nil {
+			return nil, err
+		}
+		if model.Version > update.ModelVersion || model.Final {
+			return model, nil
+		}
+		// No new model: keep waiting unless the task ended without one
+		status, err := global.GetTrainingStatus(ctx, update.TaskID)
+		if err != nil {
+			return nil, err
+		}
+		if status != "running" {
+			return nil, fmt.Errorf("global task is %s", status)
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// applyGlobalModelLocked adopts a global model as the task's parameters and
+// advances the task to its version. Caller must hold mlc.mu.
+func (mlc *MLCoordinator) applyGlobalModelLocked(task *MLTrainingTaskData, model *GlobalModelData, workers uint32) {
+	params := make([]float32, len(model.Parameters))
+	for i, v := range model.Parameters {
+		params[i] = float32(v)
+	}
+	mlc.params[task.TaskID] = params
+	mlc.models[task.TaskID][model.Version] = &ModelUpdateData{
+		TaskID:            task.TaskID,
+		ModelVersion:      model.Version,
+		Parameters:        EncodeTensor(params),
+		AggregationMethod: "fedavg-global",
+		NumWorkers:        workers,
+		GlobalLoss:        model.Loss,
+		GlobalAccuracy:    model.Accuracy,
+		Timestamp:         time.Now(),
+	}
+	task.CurrentEpoch = model.Version
+	mlc.roundStart[task.TaskID] = time.Now()
+	log.Printf("🌐 Global model %d for task %s from %d nodes: loss=%.4f, accuracy=%.4f",
+		model.Version, task.TaskID, model.NumNodes, model.Loss, model.Accuracy)
+
+	if model.Final || task.CurrentEpoch >= task.Epochs {
+		task.Status = "completed"
+		log.Printf("Training completed for task: %s", task.TaskID)
+	}
+}
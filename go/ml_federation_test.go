@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeOrchestrator serves the orchestrator's JSON training API for one
+// task, averaging the nodes' gradients like its gradient manager
+type fakeOrchestrator struct {
+	mu        sync.Mutex
+	nodes     []string
+	epochs    uint32
+	lr        float64
+	version   uint32
+	params    []float64
+	pending   map[string][]float64
+	samples   map[string]float64
+	published chan struct{}
+}
+
+func (f *fakeOrchestrator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"code": "Unauthenticated"})
+		return
+	}
+	var req struct {
+		TaskID            string    `json:"taskId"`
+		NodeIDs           []string  `json:"nodeIds"`
+		Epochs            uint32    `json:"epochs"`
+		LearningRate      float64   `json:"learningRate"`
+		InitialParameters []float64 `json:"initialParameters"`
+		NodeID            string    `json:"nodeId"`
+		ModelVersion      uint32    `json:"modelVersion"`
+		Gradients         []float64 `json:"gradients"`
+		NumSamples        uint32    `json:"numSamples"`
+		AfterVersion      uint32    `json:"afterVersion"`
+		WaitMs            int64     `json:"waitMs"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+	reply := func(code int, v any) {
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(v)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.URL.Path {
+	case "/rpc/StartTraining":
+		if f.nodes != nil {
+			reply(http.StatusConflict, map[string]string{"code": "AlreadyExists", "message": "exists"})
+			return
+		}
+		f.nodes, f.epochs, f.lr, f.params = req.NodeIDs, req.Epochs, req.LearningRate, req.InitialParameters
+		reply(http.StatusOK, map[string]string{"taskId": req.TaskID})
+	case "/rpc/SubmitGradient":
+		if req.ModelVersion != f.version {
+			reply(http.StatusConflict, map[string]string{"code": "FailedPrecondition", "message": "stale gradient"})
+			return
+		}
+		f.pending[req.NodeID] = req.Gradients
+		f.samples[req.NodeID] = float64(req.NumSamples)
+		if len(f.pending) == len(f.nodes) {
+			var total float64
+			mean := make([]float64, len(f.params))
+			for node, grad := range f.pending {
+				total += f.samples[node]
+				for i, v := range grad {
+					mean[i] += v * f.samples[node]
+				}
+			}
+			for i := range f.params {
+				f.params[i] -= f.lr * mean[i] / total
+			}
+			f.version++
+			f.pending = make(map[string][]float64)
+			close(f.published)
+			f.published = make(chan struct{})
+		}
+		reply(http.StatusOK, map[string]any{"modelVersion": f.version})
+	case "/rpc/GetGlobalModel":
+		for f.version <= req.AfterVersion {
+			published := f.published
+			f.mu.Unlock()
+			select {
+			case <-published:
+			case <-time.After(time.Duration(req.WaitMs) * time.Millisecond):
+			}
+			f.mu.Lock()
+		}
+		reply(http.StatusOK, map[string]any{
+			"taskId": req.TaskID, "version": f.version, "parameters": f.params,
+			"numNodes": len(f.nodes), "final": f.version >= f.epochs,
+		})
+	case "/rpc/GetTrainingStatus":
+		reply(http.StatusOK, map[string]string{"status": "running"})
+	}
+}
+
+func TestGlobalAggregationAcrossNodes(t *testing.T) {
+	orch := &fakeOrchestrator{
+		pending:   make(map[string][]float64),
+		samples:   make(map[string]float64),
+		published: make(chan struct{}),
+	}
+	srv := httptest.NewServer(orch)
+	defer srv.Close()
+
+	ctx := context.Background()
+	task := func() *MLTrainingTaskData {
+		return &MLTrainingTaskData{
+			TaskID:      "global",
+			DatasetID:   "data",
+			WorkerNodes: []string{"w"},
+			Epochs:      2,
+			Hyperparameters: map[string]string{
+				"learning_rate":      "0.5",
+				"global_aggregation": "true",
+				"global_nodes":       "1, 2",
+			},
+			InitialParameters: EncodeTensor([]float32{1, 1}),
+		}
+	}
+
+	if err := NewMLCoordinator().StartMLTraining(ctx, task()); err == nil {
+		t.Fatal("expected a global task refused without an orchestrator")
+	}
+	coordinators := make([]*MLCoordinator, 2)
+	for i, id := range []string{"1", "2"} {
+		coordinators[i] = NewMLCoordinator()
+		coordinators[i].SetGlobalAggregator(NewOrchestratorClient(srv.URL+"/", "token", id))
+		if err := coordinators[i].StartMLTraining(ctx, task()); err != nil {
+			t.Fatalf("node %s failed to start: %v", id, err)
+		}
+	}
+
+	submit := func(mlc *MLCoordinator, version uint32, grad []float32, samples uint32) error {
+		return mlc.SubmitGradient(ctx, &GradientUpdateData{
+			WorkerID: "w", ModelVersion: version, Gradients: EncodeTensor(grad), NumSamples: samples,
+		})
+	}
+	waitEpoch := func(mlc *MLCoordinator, epoch uint32) {
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			mlc.mu.RLock()
+			current, syncing := mlc.tasks["global"].CurrentEpoch, mlc.syncing["global"]
+			mlc.mu.RUnlock()
+			if current == epoch && !syncing {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("node stuck at epoch %d, waiting for %d", current, epoch)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	if err := submit(coordinators[0], 0, []float32{1, 0}, 1); err != nil {
+		t.Fatalf("SubmitGradient failed: %v", err)
+	}
+	// The node's epoch is with the orchestrator until the other node reports
+	if err := submit(coordinators[0], 0, []float32{1, 0}, 1); err == nil {
+		t.Error("expected gradients refused while the epoch is aggregated globally")
+	}
+	if err := submit(coordinators[1], 0, []float32{0, 2}, 3); err != nil {
+		t.Fatalf("SubmitGradient failed: %v", err)
+	}
+
+	for _, mlc := range coordinators {
+		waitEpoch(mlc, 1)
+		update, err := mlc.GetModelUpdate("global", 1)
+		if err != nil {
+			t.Fatalf("GetModelUpdate failed: %v", err)
+		}
+		params, _ := DecodeTensor(update.Parameters)
+		// Weighted mean across nodes is [0.25, 1.5]; params move by 0.5 of it
+		if update.AggregationMethod != "fedavg-global" || len(params) != 2 || params[0] != 0.875 || params[1] != 0.25 {
+			t.Errorf("expected global [0.875 0.25], got %s %v", update.AggregationMethod, params)
+		}
+	}
+
+	for _, mlc := range coordinators {
+		if err := submit(mlc, 1, []float32{0, 0}, 1); err != nil {
+			t.Fatalf("SubmitGradient failed: %v", err)
+		}
+	}
+	for _, mlc := range coordinators {
+		waitEpoch(mlc, 2)
+		if status, _ := mlc.GetMLTrainingStatus("global"); status.Status != "completed" {
+			t.Errorf("expected the task completed with the final global model, got %s", status.Status)
+		}
+	}
+}
+
+func TestOrchestratorClientErrors(t *testing.T) {
+	orch := &fakeOrchestrator{
+		pending:   make(map[string][]float64),
+		samples:   make(map[string]float64),
+		published: make(chan struct{}),
+	}
+	srv := httptest.NewServer(orch)
+	defer srv.Close()
+	ctx := context.Background()
+
+	if err := NewOrchestratorClient(srv.URL, "wrong", "1").StartTraining(ctx, &GlobalTrainingTask{TaskID: "t"}); err == nil {
+		t.Error("expected a wrong token refused")
+	}
+	client := NewOrchestratorClient(srv.URL, "token", "1")
+	task := &GlobalTrainingTask{TaskID: "t", Nodes: []string{"1"}, Epochs: 1, InitialParameters: []float64{0}}
+	if err := client.StartTraining(ctx, task); err != nil {
+		t.Fatalf("StartTraining failed: %v", err)
+	}
+	if err := client.StartTraining(ctx, task); !errors.Is(err, errGlobalTaskExists) {
+		t.Errorf("expected errGlobalTaskExists, got %v", err)
+	}
+	err := client.SubmitGradient(ctx, &GlobalGradient{TaskID: "t", ModelVersion: 4, Gradients: []float64{1}, NumSamples: 1})
+	if !errors.Is(err, errGlobalStale) {
+		t.Errorf("expected a stale gradient reported as errGlobalStale, got %v", err)
+	}
+}
+
+func TestOrchestratorClientFailsOver(t *testing.T) {
+	orch := &fakeOrchestrator{
+		pending:   make(map[string][]float64),
+		samples:   make(map[string]float64),
+		published: make(chan struct{}),
+	}
+	leader := httptest.NewServer(orch)
+	defer leader.Close()
+	// A follower that lost its leader refuses calls until one is elected
+	electing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"code": "Unavailable", "message": "not the cluster leader"})
+	}))
+	defer electing.Close()
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	ctx := context.Background()
+	client := NewOrchestratorClient(down.URL+", "+electing.URL+","+leader.URL+"/", "token", "1")
+	task := &GlobalTrainingTask{TaskID: "t", Nodes: []string{"1"}, Epochs: 1, InitialParameters: []float64{0}}
+	if err := client.StartTraining(ctx, task); err != nil {
+		t.Fatalf("expected the call to reach the leader, got %v", err)
+	}
+	if client.current != 2 {
+		t.Errorf("expected the client to stay with the orchestrator that answered, got %d", client.current)
+	}
+	// Errors the leader returns are not retried elsewhere
+	if err := client.StartTraining(ctx, task); !errors.Is(err, errGlobalTaskExists) {
+		t.Errorf("expected errGlobalTaskExists, got %v", err)
+	}
+
+	client = NewOrchestratorClient(down.URL+","+electing.URL, "token", "1")
+	if err := client.StartTraining(ctx, task); !errors.Is(err, errOrchestratorUnavailable) {
+		t.Errorf("expected errOrchestratorUnavailable with no leader reachable, got %v", err)
+	}
+}
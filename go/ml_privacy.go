@@ -0,0 +1,125 @@
+package main
+
+import (
+	crand "crypto/rand"
+	"fmt"
+	"math"
+	"math/rand/v2"
+)
+
+// DefaultDPDelta is the δ of the reported (ε, δ) guarantee when a task's
+// privacy configuration leaves it unset
+const DefaultDPDelta = 1e-5
+
+// rdpOrders are the Rényi orders searched when converting the accumulated
+// privacy loss to (ε, δ)
+var rdpOrders = []float64{1.25, 1.5, 2, 2.5, 3, 4, 5, 6, 8, 10, 12, 16, 20, 32, 64, 128, 256}
+
+// DPConfig enables differentially private aggregation for a task. Each
+// worker's gradient is clipped to ClipNorm in L2 and Gaussian noise with
+// standard deviation NoiseMultiplier × ClipNorm is added to their sum, so
+// no single worker's update can move the model by more than the noise
+// hides. Training stops before a round would push ε past MaxEpsilon, when
+// set.
+type DPConfig struct {
+	ClipNorm        float64
+	NoiseMultiplier float64
+	Delta           float64
+	MaxEpsilon      float64 // 0 = unbounded
+}
+
+// Validate checks the configuration and fills in the default δ
+func (c *DPConfig) Validate() error {
+	if c.ClipNorm <= 0 || math.IsInf(c.ClipNorm, 0) || math.IsNaN(c.ClipNorm) {
+		return fmt.Errorf("clip norm must be positive, got %v", c.ClipNorm)
+	}
+	if c.NoiseMultiplier < 0 || math.IsInf(c.NoiseMultiplier, 0) || math.IsNaN(c.NoiseMultiplier) {
+		return fmt.Errorf("noise multiplier must not be negative, got %v", c.NoiseMultiplier)
+	}
+	if c.Delta == 0 {
+		c.Delta = DefaultDPDelta
+	}
+	if c.Delta <= 0 || c.Delta >= 1 {
+		return fmt.Errorf("delta must be in (0, 1), got %v", c.Delta)
+	}
+	if c.MaxEpsilon < 0 {
+		return fmt.Errorf("max epsilon must not be negative, got %v", c.MaxEpsilon)
+	}
+	return nil
+}
+
+// PrivacySpent is a task's privacy loss so far
+type PrivacySpent struct {
+	Rounds  uint32  // Noised aggregations performed
+	Epsilon float64 // +Inf without noise
+	Delta   float64
+}
+
+// dpEpsilon returns the ε of rounds Gaussian mechanism releases at the given
+// noise multiplier and δ. Every worker contributes to every round, so no
+// subsampling amplification applies: the Rényi divergence of order α is
+// rounds·α/(2z²), converted with ε = RDP(α) + ln(1/δ)/(α−1) at the best α.
+func dpEpsilon(rounds uint32, noiseMultiplier, delta float64) float64 {
+	if rounds == 0 {
+		return 0
+	}
+	if noiseMultiplier == 0 {
+		return math.Inf(1)
+	}
+	best := math.Inf(1)
+	for _, alpha := range rdpOrders {
+		rdp := float64(rounds) * alpha / (2 * noiseMultiplier * noiseMultiplier)
+		best = min(best, rdp+math.Log(1/delta)/(alpha-1))
+	}
+	return best
+}
+
+// clipGradient scales values down in place so their L2 norm is at most
+// clipNorm
+func clipGradient(values []float64, clipNorm float64) {
+	norm := 0.0
+	for _, v := range values {
+		norm += v * v
+	}
+	norm = math.Sqrt(norm)
+	if norm <= clipNorm {
+		return
+	}
+	scale := clipNorm / norm
+	for i := range values {
+		values[i] *= scale
+	}
+}
+
+// privateMean clips each gradient, sums them, adds Gaussian noise scaled to
+// the clip norm and divides by the number of workers. Workers are weighted
+// equally: weighting by their reported sample counts would let one worker's
+// share, and so the sensitivity, grow without bound.
+func privateMean(gradients []*GradientUpdateData, dp DPConfig) []float64 {
+	sum := make([]float64, len(gradients[0].values))
+	clipped := make([]float64, len(sum))
+	for _, grad := range gradients {
+		for i, g := range grad.values {
+			clipped[i] = float64(g)
+		}
+		clipGradient(clipped, dp.ClipNorm)
+		for i, c := range clipped {
+			sum[i] += c
+		}
+	}
+
+	rng := newNoiseSource()
+	stddev := dp.NoiseMultiplier * dp.ClipNorm
+	for i := range sum {
+		sum[i] = (sum[i] + rng.NormFloat64()*stddev) / float64(len(gradients))
+	}
+	return sum
+}
+
+// newNoiseSource returns a ChaCha8 generator seeded from the operating
+// system, so the noise cannot be predicted and subtracted
+func newNoiseSource() *rand.Rand {
+	var seed [32]byte
+	crand.Read(seed[:])
+	return rand.New(rand.NewChaCha8(seed))
+}
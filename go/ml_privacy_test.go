@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+func TestDifferentialPrivacyClipsGradients(t *testing.T) {
+	mlc := NewMLCoordinator()
+	ctx := context.Background()
+	err := mlc.StartMLTraining(ctx, &MLTrainingTaskData{
+		TaskID:          "dp",
+		DatasetID:       "data",
+		WorkerNodes:     []string{"w1", "w2"},
+		Epochs:          3,
+		Hyperparameters: map[string]string{"learning_rate": "1"},
+		Privacy:         &DPConfig{ClipNorm: 1},
+	})
+	if err != nil {
+		t.Fatalf("StartMLTraining failed: %v", err)
+	}
+
+	// Without noise only clipping applies: [3 4] shrinks to [0.6 0.8] and
+	// workers count equally regardless of samples
+	for _, g := range []struct {
+		worker  string
+		values  []float32
+		samples uint32
+	}{{"w1", []float32{3, 4}, 1}, {"w2", []float32{0, 0.5}, 100}} {
+		err := mlc.SubmitGradient(ctx, &GradientUpdateData{
+			WorkerID: g.worker, Gradients: EncodeTensor(g.values), NumSamples: g.samples,
+		})
+		if err != nil {
+			t.Fatalf("SubmitGradient failed: %v", err)
+		}
+	}
+	update, err := mlc.GetModelUpdate("dp", 1)
+	if err != nil {
+		t.Fatalf("GetModelUpdate failed: %v", err)
+	}
+	params, _ := DecodeTensor(update.Parameters)
+	if len(params) != 2 || math.Abs(float64(params[0])+0.3) > 1e-6 || math.Abs(float64(params[1])+0.65) > 1e-6 {
+		t.Errorf("expected [-0.3 -0.65], got %v", params)
+	}
+
+	spent, enabled, err := mlc.GetPrivacySpent("dp")
+	if err != nil || !enabled {
+		t.Fatalf("expected privacy accounting, got %v, %v", enabled, err)
+	}
+	if spent.Rounds != 1 || !math.IsInf(spent.Epsilon, 1) || spent.Delta != DefaultDPDelta {
+		t.Errorf("expected one round with unbounded ε, got %+v", spent)
+	}
+}
+
+func TestDifferentialPrivacyBudgetStopsTraining(t *testing.T) {
+	one, two := dpEpsilon(1, 1.1, 1e-5), dpEpsilon(2, 1.1, 1e-5)
+	if !(one > 0 && two > one && dpEpsilon(1, 4, 1e-5) < one) {
+		t.Fatalf("ε should grow with rounds and shrink with noise: %v %v", one, two)
+	}
+
+	mlc := NewMLCoordinator()
+	ctx := context.Background()
+	err := mlc.StartMLTraining(ctx, &MLTrainingTaskData{
+		TaskID:      "budget",
+		DatasetID:   "data",
+		WorkerNodes: []string{"w1"},
+		Epochs:      10,
+		Privacy:     &DPConfig{ClipNorm: 1, NoiseMultiplier: 1.1, MaxEpsilon: (one + two) / 2},
+	})
+	if err != nil {
+		t.Fatalf("StartMLTraining failed: %v", err)
+	}
+	for version := uint32(0); version < 2; version++ {
+		err := mlc.SubmitGradient(ctx, &GradientUpdateData{
+			WorkerID: "w1", ModelVersion: version, Gradients: EncodeTensor([]float32{1}), NumSamples: 1,
+		})
+		if err != nil {
+			t.Fatalf("SubmitGradient %d failed: %v", version, err)
+		}
+	}
+
+	task, _ := mlc.GetMLTrainingStatus("budget")
+	if task.Status != "completed" || task.CurrentEpoch != 1 {
+		t.Errorf("expected training to stop after one round, got %s at epoch %d", task.Status, task.CurrentEpoch)
+	}
+	if _, err := mlc.GetModelUpdate("budget", 2); err == nil {
+		t.Error("a model beyond the privacy budget was released")
+	}
+	if spent, _, _ := mlc.GetPrivacySpent("budget"); spent.Rounds != 1 || spent.Epsilon != one {
+		t.Errorf("expected ε=%v after one round, got %+v", one, spent)
+	}
+
+	bad := &MLTrainingTaskData{TaskID: "bad", DatasetID: "data", WorkerNodes: []string{"w2"}, Privacy: &DPConfig{}}
+	if err := mlc.StartMLTraining(ctx, bad); err == nil {
+		t.Error("expected a zero clip norm to be rejected")
+	}
+}
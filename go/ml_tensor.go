@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Tensors exchanged with ML workers are flat float32 arrays: the magic
+// "PGT1", a big-endian uint32 element count, then the big-endian elements.
+// Model parameters and gradients share the format, flattened in the same
+// order by the worker.
+//
+// Workers may compress gradients with one of two self-describing codecs,
+// recognised by their magic:
+//
+//   - "PGQ8": 8-bit linear quantization. uint32 count, float32 minimum,
+//     float32 step, then one byte q per element; the value is min + q·step.
+//   - "PGK1": top-k sparsification. uint32 dense count, uint32 k, then k
+//     pairs of uint32 index and float32 value; other elements are zero.
+const (
+	tensorMagic      = "PGT1"
+	quantizedMagic   = "PGQ8"
+	sparseMagic      = "PGK1"
+	tensorHeaderSize = 8
+
+	// maxTensorElements bounds tensors decoded from peer input
+	maxTensorElements = 1 << 28
+)
+
+// Gradient codec names, as reported in training status
+const (
+	CodecRaw       = "raw"
+	CodecQuantized = "q8"
+	CodecTopK      = "topk"
+)
+
+// EncodeTensor serializes a flat float32 tensor
+func EncodeTensor(values []float32) []byte {
+	out := make([]byte, tensorHeaderSize, tensorHeaderSize+4*len(values))
+	copy(out, tensorMagic)
+	binary.BigEndian.PutUint32(out[4:8], uint32(len(values)))
+	for _, v := range values {
+		out = binary.BigEndian.AppendUint32(out, math.Float32bits(v))
+	}
+	return out
+}
+
+// DecodeTensor parses a tensor produced by EncodeTensor
+func DecodeTensor(data []byte) ([]float32, error) {
+	if len(data) < tensorHeaderSize || string(data[:4]) != tensorMagic {
+		return nil, fmt.Errorf("not a tensor: missing %s header", tensorMagic)
+	}
+	n := binary.BigEndian.Uint32(data[4:8])
+	if n > maxTensorElements {
+		return nil, fmt.Errorf("tensor too large: %d elements", n)
+	}
+	if len(data) != tensorHeaderSize+4*int(n) {
+		return nil, fmt.Errorf("tensor length mismatch: header says %d elements, have %d bytes", n, len(data)-tensorHeaderSize)
+	}
+
+	values := make([]float32, n)
+	for i := range values {
+		values[i] = math.Float32frombits(binary.BigEndian.Uint32(data[tensorHeaderSize+4*i:]))
+	}
+	return values, nil
+}
+
+// rawTensorSize is the encoded size of an uncompressed tensor of n elements
+func rawTensorSize(n int) int {
+	return tensorHeaderSize + 4*n
+}
+
+// GradientCodec names the codec of an encoded gradient, or "" if the data
+// is not a tensor
+func GradientCodec(data []byte) string {
+	if len(data) < 4 {
+		return ""
+	}
+	switch string(data[:4]) {
+	case tensorMagic:
+		return CodecRaw
+	case quantizedMagic:
+		return CodecQuantized
+	case sparseMagic:
+		return CodecTopK
+	}
+	return ""
+}
+
+// DecodeGradient parses a gradient in any supported codec into a dense
+// tensor
+func DecodeGradient(data []byte) ([]float32, error) {
+	switch GradientCodec(data) {
+	case CodecQuantized:
+		return decodeQuantized(data)
+	case CodecTopK:
+		return decodeSparse(data)
+	default:
+		return DecodeTensor(data)
+	}
+}
+
+// QuantizeTensor encodes values with 8-bit linear quantization, to within
+// half a step of (max-min)/255
+func QuantizeTensor(values []float32) []byte {
+	lo, hi := float32(0), float32(0)
+	if len(values) > 0 {
+		lo, hi = values[0], values[0]
+	}
+	for _, v := range values {
+		lo, hi = min(lo, v), max(hi, v)
+	}
+	step := (hi - lo) / 255
+
+	out := make([]byte, 16, 16+len(values))
+	copy(out, quantizedMagic)
+	binary.BigEndian.PutUint32(out[4:8], uint32(len(values)))
+	binary.BigEndian.PutUint32(out[8:12], math.Float32bits(lo))
+	binary.BigEndian.PutUint32(out[12:16], math.Float32bits(step))
+	for _, v := range values {
+		q := 0.0
+		if step > 0 {
+			q = math.Round(float64((v - lo) / step))
+		}
+		out = append(out, byte(min(max(q, 0), 255)))
+	}
+	return out
+}
+
+func decodeQuantized(data []byte) ([]float32, error) {
+	if len(data) < 16 {
+		return nil, fmt.Errorf("quantized tensor too short: %d bytes", len(data))
+	}
+	n := binary.BigEndian.Uint32(data[4:8])
+	if n > maxTensorElements {
+		return nil, fmt.Errorf("tensor too large: %d elements", n)
+	}
+	if len(data) != 16+int(n) {
+		return nil, fmt.Errorf("quantized tensor length mismatch: header says %d elements, have %d bytes", n, len(data)-16)
+	}
+	lo := math.Float32frombits(binary.BigEndian.Uint32(data[8:12]))
+	step := math.Float32frombits(binary.BigEndian.Uint32(data[12:16]))
+	if isNonFinite(lo) || isNonFinite(step) || step < 0 {
+		return nil, fmt.Errorf("invalid quantization range: min %v, step %v", lo, step)
+	}
+
+	values := make([]float32, n)
+	for i, q := range data[16:] {
+		values[i] = lo + float32(q)*step
+	}
+	return values, nil
+}
+
+// SparsifyTensor keeps the k elements of largest magnitude
+func SparsifyTensor(values []float32, k int) []byte {
+	k = min(max(k, 0), len(values))
+	order := make([]int, len(values))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return math.Abs(float64(values[order[a]])) > math.Abs(float64(values[order[b]]))
+	})
+	kept := order[:k]
+	sort.Ints(kept)
+
+	out := make([]byte, 12, 12+8*k)
+	copy(out, sparseMagic)
+	binary.BigEndian.PutUint32(out[4:8], uint32(len(values)))
+	binary.BigEndian.PutUint32(out[8:12], uint32(k))
+	for _, i := range kept {
+		out = binary.BigEndian.AppendUint32(out, uint32(i))
+		out = binary.BigEndian.AppendUint32(out, math.Float32bits(values[i]))
+	}
+	return out
+}
+
+func decodeSparse(data []byte) ([]float32, error) {
+	if len(data) < 12 {
+		return nil, fmt.Errorf("sparse tensor too short: %d bytes", len(data))
+	}
+	n := binary.BigEndian.Uint32(data[4:8])
+	k := binary.BigEndian.Uint32(data[8:12])
+	if n > maxTensorElements {
+		return nil, fmt.Errorf("tensor too large: %d elements", n)
+	}
+	if k > n || len(data) != 12+8*int(k) {
+		return nil, fmt.Errorf("sparse tensor length mismatch: %d of %d elements in %d bytes", k, n, len(data)-12)
+	}
+
+	values := make([]float32, n)
+	for j := 0; j < int(k); j++ {
+		entry := data[12+8*j:]
+		i := binary.BigEndian.Uint32(entry[0:4])
+		if i >= n {
+			return nil, fmt.Errorf("sparse index %d out of range for %d elements", i, n)
+		}
+		values[i] = math.Float32frombits(binary.BigEndian.Uint32(entry[4:8]))
+	}
+	return values, nil
+}
+
+func isNonFinite(v float32) bool {
+	return math.IsNaN(float64(v)) || math.IsInf(float64(v), 0)
+}
@@ -0,0 +1,438 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	dht "github.com/libp2p/go-libp2p-kad-dht"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/libp2p/go-libp2p/core/routing"
+)
+
+const (
+	// NameNamespace is the DHT record namespace holding name records
+	NameNamespace = "pangea-name"
+
+	// NameDHTPrefix is the protocol prefix of the DHT holding name records
+	// on the public network, whose IPFS DHT accepts no other record types.
+	// A private network keeps them in its own DHT.
+	NameDHTPrefix protocol.ID = "/pangea-names"
+
+	// DefaultNameTTL is how long a published name record stays valid when
+	// the publisher does not say
+	DefaultNameTTL = 7 * 24 * time.Hour
+
+	// NameRepublishInterval is how often a node re-signs and republishes
+	// its names, keeping them in the DHT and ahead of their expiry
+	NameRepublishInterval = 4 * time.Hour
+
+	nameLookupTimeout = 20 * time.Second
+	maxNameLength     = 128
+	maxNameRecordSize = 4096
+)
+
+// NameRecord maps a human-readable name to a manifest hash. Names belong to
+// the publisher's libp2p identity: the record is signed with its key and
+// stored under a DHT key containing its peer ID, so only the publisher can
+// update it. Version increases with every publish; the highest wins.
+type NameRecord struct {
+	Name      string `json:"name"`
+	Value     string `json:"value"`     // Manifest hash the name points to
+	Version   uint64 `json:"version"`   // Starts at 1
+	Publisher string `json:"publisher"` // Publisher's libp2p peer ID
+	PublicKey []byte `json:"publicKey"`
+	Published int64  `json:"published"` // Unix milliseconds of signing
+	Expires   int64  `json:"expires"`   // Unix milliseconds
+	Signature []byte `json:"signature"`
+}
+
+// signingBytes is the record's signed content
+func (r NameRecord) signingBytes() []byte {
+	r.Signature = nil
+	data, _ := json.Marshal(r)
+	return data
+}
+
+// Expired reports whether the record is past its validity
+func (r *NameRecord) Expired() bool {
+	return time.Now().UnixMilli() >= r.Expires
+}
+
+// newerThan reports whether the record supersedes other: a higher version,
+// or the same version signed later, as when it was republished
+func (r *NameRecord) newerThan(other *NameRecord) bool {
+	if r.Version != other.Version {
+		return r.Version > other.Version
+	}
+	return r.Published > other.Published
+}
+
+// nameKey is the DHT key of a publisher's name
+func nameKey(publisher peer.ID, name string) string {
+	return "/" + NameNamespace + "/" + publisher.String() + "/" + name
+}
+
+// validateName checks that a name can be published: non-empty UTF-8 of at
+// most maxNameLength bytes, without control characters
+func validateName(name string) error {
+	if name == "" {
+		return fmt.Errorf("name cannot be empty")
+	}
+	if len(name) > maxNameLength {
+		return fmt.Errorf("name is longer than %d bytes", maxNameLength)
+	}
+	if !utf8.ValidString(name) || strings.IndexFunc(name, unicode.IsControl) >= 0 {
+		return fmt.Errorf("name %q contains invalid characters", name)
+	}
+	return nil
+}
+
+// decodeNameRecord parses a name record and checks its signature. It does
+// not check expiry.
+func decodeNameRecord(data []byte) (*NameRecord, error) {
+	if len(data) > maxNameRecordSize {
+		return nil, fmt.Errorf("name record is larger than %d bytes", maxNameRecordSize)
+	}
+	var r NameRecord
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("invalid name record: %w", err)
+	}
+	if err := validateName(r.Name); err != nil {
+		return nil, err
+	}
+	publisher, err := peer.Decode(r.Publisher)
+	if err != nil {
+		return nil, fmt.Errorf("invalid publisher: %w", err)
+	}
+	pub, err := crypto.UnmarshalPublicKey(r.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+	if !publisher.MatchesPublicKey(pub) {
+		return nil, fmt.Errorf("public key does not match publisher %s", shortPeerID(publisher))
+	}
+	valid, err := pub.Verify(r.signingBytes(), r.Signature)
+	if err != nil || !valid {
+		return nil, fmt.Errorf("invalid signature on name %q", r.Name)
+	}
+	return &r, nil
+}
+
+// nameValidator lets the DHT accept only signed, unexpired name records
+// stored under their own key, and keep the newest
+type nameValidator struct{}
+
+// Validate implements record.Validator
+func (nameValidator) Validate(key string, value []byte) error {
+	r, err := decodeNameRecord(value)
+	if err != nil {
+		return err
+	}
+	publisher, _ := peer.Decode(r.Publisher)
+	if key != nameKey(publisher, r.Name) {
+		return fmt.Errorf("name record for %q stored under another key", r.Name)
+	}
+	if r.Expired() {
+		return fmt.Errorf("name record for %q has expired", r.Name)
+	}
+	return nil
+}
+
+// Select implements record.Validator, picking the newest record
+func (nameValidator) Select(key string, values [][]byte) (int, error) {
+	best := -1
+	var newest *NameRecord
+	for i, value := range values {
+		r, err := decodeNameRecord(value)
+		if err != nil {
+			continue
+		}
+		if newest == nil || r.newerThan(newest) {
+			best, newest = i, r
+		}
+	}
+	if best < 0 {
+		return 0, fmt.Errorf("no valid name record")
+	}
+	return best, nil
+}
+
+// NameRegistry publishes this node's names to the DHT and resolves the
+// names of any publisher. Without a DHT, in local mode, names only
+// resolve on this node.
+type NameRegistry struct {
+	host    host.Host
+	dht     *dht.IpfsDHT
+	path    string                 // Empty keeps this node's names in memory only
+	records map[string]*NameRecord // Newest known record by DHT key
+	mu      sync.Mutex
+}
+
+// NewNameRegistry creates a registry; kadDHT may be nil
+func NewNameRegistry(h host.Host, kadDHT *dht.IpfsDHT) *NameRegistry {
+	return &NameRegistry{host: h, dht: kadDHT, records: make(map[string]*NameRecord)}
+}
+
+// SetPath persists this node's names to path and loads those already there
+func (nr *NameRegistry) SetPath(path string) error {
+	var records []*NameRecord
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &records); err != nil {
+			return fmt.Errorf("invalid name record file: %w", err)
+		}
+	}
+
+	nr.mu.Lock()
+	defer nr.mu.Unlock()
+	nr.path = path
+	for _, r := range records {
+		if r.Publisher != nr.host.ID().String() {
+			continue
+		}
+		key := nameKey(nr.host.ID(), r.Name)
+		if old, ok := nr.records[key]; !ok || r.newerThan(old) {
+			nr.records[key] = r
+		}
+	}
+	if len(records) > 0 {
+		log.Printf("🏷️  [NAMES] Loaded %d published names from %s", len(records), path)
+	}
+	return nil
+}
+
+// Publish points one of this node's names at value, signed with the node's
+// identity key and valid for ttl (0 = DefaultNameTTL). The version follows
+// the newest record found for the name. The record is kept and republished
+// even if the DHT put fails.
+func (nr *NameRegistry) Publish(ctx context.Context, name, value string, ttl time.Duration) (*NameRecord, error) {
+	if err := validateName(name); err != nil {
+		return nil, err
+	}
+	if value == "" {
+		return nil, fmt.Errorf("name value cannot be empty")
+	}
+	if ttl <= 0 {
+		ttl = DefaultNameTTL
+	}
+	key := nameKey(nr.host.ID(), name)
+
+	// Another node with this identity, or an earlier run without a data
+	// directory, may have published newer versions than this node knows
+	var version uint64
+	if prev, err := nr.lookup(ctx, key); err == nil {
+		version = prev.Version
+	}
+	r := &NameRecord{Name: name, Value: value, Version: version + 1}
+	if err := nr.sign(r, ttl); err != nil {
+		return nil, err
+	}
+
+	nr.mu.Lock()
+	if old, ok := nr.records[key]; ok && old.Version >= r.Version {
+		// Raced with another publish of the same name
+		r.Version = old.Version + 1
+		if err := nr.sign(r, ttl); err != nil {
+			nr.mu.Unlock()
+			return nil, err
+		}
+	}
+	nr.records[key] = r
+	if err := nr.saveLocked(); err != nil {
+		log.Printf("⚠️  [NAMES] Failed to save names: %v", err)
+	}
+	nr.mu.Unlock()
+
+	if err := nr.put(ctx, key, r); err != nil {
+		return nil, fmt.Errorf("failed to publish name %q to the DHT, will retry: %w", name, err)
+	}
+	log.Printf("🏷️  [NAMES] Published %q v%d -> %s", name, r.Version, r.Value)
+	return r, nil
+}
+
+// Resolve returns the newest unexpired record of a publisher's name. An
+// empty publisher means this node.
+func (nr *NameRegistry) Resolve(ctx context.Context, publisher, name string) (*NameRecord, error) {
+	if err := validateName(name); err != nil {
+		return nil, err
+	}
+	pid := nr.host.ID()
+	if publisher != "" {
+		var err error
+		if pid, err = peer.Decode(publisher); err != nil {
+			return nil, fmt.Errorf("invalid publisher %q: %w", publisher, err)
+		}
+	}
+	r, err := nr.lookup(ctx, nameKey(pid, name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve name %q: %w", name, err)
+	}
+	return r, nil
+}
+
+// lookup returns the newest unexpired record under key, from this node and
+// the DHT, remembering what the DHT returned
+func (nr *NameRegistry) lookup(ctx context.Context, key string) (*NameRecord, error) {
+	nr.mu.Lock()
+	best := nr.records[key]
+	nr.mu.Unlock()
+	if best != nil && best.Expired() {
+		best = nil
+	}
+	if nr.dht == nil {
+		if best == nil {
+			return nil, routing.ErrNotFound
+		}
+		return best, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, nameLookupTimeout)
+	defer cancel()
+	data, err := nr.dht.GetValue(ctx, key)
+	if err != nil {
+		if best == nil {
+			return nil, err
+		}
+		return best, nil
+	}
+	found, err := decodeNameRecord(data)
+	if err != nil || found.Expired() {
+		// The DHT validates records, so this is not expected
+		if best == nil {
+			return nil, routing.ErrNotFound
+		}
+		return best, nil
+	}
+	if best != nil && !found.newerThan(best) {
+		return best, nil
+	}
+
+	nr.mu.Lock()
+	if old, ok := nr.records[key]; !ok || found.newerThan(old) {
+		nr.records[key] = found
+		if found.Publisher == nr.host.ID().String() {
+			if err := nr.saveLocked(); err != nil {
+				log.Printf("⚠️  [NAMES] Failed to save names: %v", err)
+			}
+		}
+	}
+	nr.mu.Unlock()
+	return found, nil
+}
+
+// sign stamps a record as published by this node now and signs it
+func (nr *NameRegistry) sign(r *NameRecord, ttl time.Duration) error {
+	pub, err := crypto.MarshalPublicKey(nr.host.Peerstore().PubKey(nr.host.ID()))
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	r.Publisher = nr.host.ID().String()
+	r.PublicKey = pub
+	r.Published = now.UnixMilli()
+	r.Expires = now.Add(ttl).UnixMilli()
+	if r.Signature, err = nr.host.Peerstore().PrivKey(nr.host.ID()).Sign(r.signingBytes()); err != nil {
+		return fmt.Errorf("failed to sign name record: %w", err)
+	}
+	return nil
+}
+
+// put stores a record in the DHT; it does nothing in local mode
+func (nr *NameRegistry) put(ctx context.Context, key string, r *NameRecord) error {
+	if nr.dht == nil {
+		return nil
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, nameLookupTimeout)
+	defer cancel()
+	return nr.dht.PutValue(ctx, key, data)
+}
+
+// Run republishes this node's names every interval until ctx is done
+func (nr *NameRegistry) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			nr.republish(ctx)
+		}
+	}
+}
+
+// republish re-signs this node's names with a fresh validity of the same
+// length and puts them in the DHT again. Versions stay as they are.
+func (nr *NameRegistry) republish(ctx context.Context) {
+	self := nr.host.ID().String()
+	nr.mu.Lock()
+	var own []*NameRecord
+	for key, r := range nr.records {
+		if r.Publisher != self {
+			continue
+		}
+		renewed := *r
+		if err := nr.sign(&renewed, time.Duration(r.Expires-r.Published)*time.Millisecond); err != nil {
+			log.Printf("⚠️  [NAMES] Failed to renew %q: %v", r.Name, err)
+			continue
+		}
+		nr.records[key] = &renewed
+		own = append(own, &renewed)
+	}
+	if err := nr.saveLocked(); err != nil {
+		log.Printf("⚠️  [NAMES] Failed to save names: %v", err)
+	}
+	nr.mu.Unlock()
+
+	for _, r := range own {
+		if err := nr.put(ctx, nameKey(nr.host.ID(), r.Name), r); err != nil && ctx.Err() == nil {
+			log.Printf("⚠️  [NAMES] Failed to republish %q: %v", r.Name, err)
+		}
+	}
+}
+
+func (nr *NameRegistry) saveLocked() error {
+	if nr.path == "" {
+		return nil
+	}
+	self := nr.host.ID().String()
+	records := make([]*NameRecord, 0)
+	for _, r := range nr.records {
+		if r.Publisher == self {
+			records = append(records, r)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Name < records[j].Name })
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(nr.path), 0700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(nr.path+".tmp", data, 0600); err != nil {
+		return fmt.Errorf("failed to save names: %w", err)
+	}
+	return os.Rename(nr.path+".tmp", nr.path)
+}
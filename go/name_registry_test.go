@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestNameRegistryVersionsAndValidation(t *testing.T) {
+	node, err := NewLibP2PPangeaNodeWithOptions(570, NewNodeStore(), true, true, 0)
+	if err != nil {
+		t.Fatalf("failed to create node: %v", err)
+	}
+	defer node.cancel()
+	nr := node.GetNameRegistry()
+	ctx := context.Background()
+
+	if _, err := nr.Resolve(ctx, "", "site"); err == nil {
+		t.Fatal("expected an unpublished name not to resolve")
+	}
+	first, err := nr.Publish(ctx, "site", "hash-1", 0)
+	if err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	second, err := nr.Publish(ctx, "site", "hash-2", time.Hour)
+	if err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	if first.Version != 1 || second.Version != 2 {
+		t.Fatalf("expected versions 1 and 2, got %d and %d", first.Version, second.Version)
+	}
+	got, err := nr.Resolve(ctx, node.host.ID().String(), "site")
+	if err != nil || got.Value != "hash-2" {
+		t.Fatalf("expected hash-2, got %+v (%v)", got, err)
+	}
+
+	// The DHT validator accepts the records under their own key only, and
+	// prefers the newer one
+	v := nameValidator{}
+	key := nameKey(node.host.ID(), "site")
+	oldData, _ := json.Marshal(first)
+	newData, _ := json.Marshal(second)
+	if err := v.Validate(key, newData); err != nil {
+		t.Fatalf("valid record rejected: %v", err)
+	}
+	if err := v.Validate(nameKey(node.host.ID(), "other"), newData); err == nil {
+		t.Fatal("expected a record under another name's key to be rejected")
+	}
+	if best, err := v.Select(key, [][]byte{newData, oldData}); err != nil || best != 0 {
+		t.Fatalf("expected the newer record selected, got %d (%v)", best, err)
+	}
+
+	forged := *second
+	forged.Value = "evil-hash"
+	forgedData, _ := json.Marshal(forged)
+	if err := v.Validate(key, forgedData); err == nil {
+		t.Fatal("expected a record with a changed value to be rejected")
+	}
+	if best, err := v.Select(key, [][]byte{forgedData, oldData}); err != nil || best != 1 {
+		t.Fatalf("expected the forged record skipped, got %d (%v)", best, err)
+	}
+
+	expired := *second
+	if err := nr.sign(&expired, -time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	expiredData, _ := json.Marshal(expired)
+	if err := v.Validate(key, expiredData); err == nil {
+		t.Fatal("expected an expired record to be rejected")
+	}
+
+	if _, err := nr.Publish(ctx, "", "hash", 0); err == nil {
+		t.Fatal("expected an empty name to be rejected")
+	}
+}
+
+func TestNameRegistryResolvesOverDHT(t *testing.T) {
+	n1, err := NewLibP2PPangeaNodeWithOptions(631, NewNodeStore(), false, true, 12630)
+	if err != nil {
+		t.Fatalf("failed to create node1: %v", err)
+	}
+	defer n1.cancel()
+	n2, err := NewLibP2PPangeaNodeWithOptions(632, NewNodeStore(), false, true, 12631)
+	if err != nil {
+		t.Fatalf("failed to create node2: %v", err)
+	}
+	defer n2.cancel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := n1.host.Connect(ctx, peer.AddrInfo{ID: n2.host.ID(), Addrs: n2.host.Addrs()}); err != nil {
+		t.Fatalf("connect n1->n2 failed: %v", err)
+	}
+	// Wait for the peers to enter each other's routing tables
+	for n1.names.dht.RoutingTable().Size() == 0 || n2.names.dht.RoutingTable().Size() == 0 {
+		if ctx.Err() != nil {
+			t.Fatal("routing tables never filled")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	for _, value := range []string{"hash-1", "hash-2"} {
+		if _, err := n1.GetNameRegistry().Publish(ctx, "docs", value, 0); err != nil {
+			t.Fatalf("Publish failed: %v", err)
+		}
+	}
+	got, err := n2.GetNameRegistry().Resolve(ctx, n1.host.ID().String(), "docs")
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if got.Value != "hash-2" || got.Version != 2 || got.Publisher != n1.host.ID().String() {
+		t.Fatalf("unexpected record %+v", got)
+	}
+
+	// n2 cannot resolve the name under its own identity
+	if _, err := n2.GetNameRegistry().Resolve(ctx, "", "docs"); err == nil {
+		t.Fatal("expected the name not to exist for another publisher")
+	}
+}
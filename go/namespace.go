@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+// A network namespace keeps clusters that share a LAN or the public DHT
+// apart: it is appended to the mDNS service tag and DHT discovery topic,
+// and moves every Pangea protocol ID from /pangea/... to /pangea-<ns>/...,
+// so nodes of different namespaces neither find nor talk to each other.
+const (
+	namespaceInfo = "pangea-network-namespace"
+	namespaceLen  = 16 // Hex digits
+)
+
+// NetworkNamespace derives a namespace from a network key shared by the
+// cluster's nodes
+func NetworkNamespace(key []byte) string {
+	h := sha256.New()
+	h.Write([]byte(namespaceInfo))
+	h.Write(key)
+	return hex.EncodeToString(h.Sum(nil))[:namespaceLen]
+}
+
+// discoveryTopic is the mDNS service tag and DHT topic of a namespace
+func discoveryTopic(ns string) string {
+	if ns == "" {
+		return PangeaDiscoveryTopic
+	}
+	return PangeaDiscoveryTopic + "-" + ns
+}
+
+// namespacedProtocol moves a Pangea protocol ID into ns; IDs of other
+// protocols, such as identify and the DHT, are left alone
+func namespacedProtocol(ns string, proto protocol.ID) protocol.ID {
+	if ns == "" || !strings.HasPrefix(string(proto), "/pangea/") {
+		return proto
+	}
+	return protocol.ID("/pangea-" + ns + strings.TrimPrefix(string(proto), "/pangea"))
+}
+
+// baseProtocol strips any namespace from a Pangea protocol ID
+func baseProtocol(proto protocol.ID) protocol.ID {
+	rest, ok := strings.CutPrefix(string(proto), "/pangea-")
+	if !ok {
+		return proto
+	}
+	if i := strings.IndexByte(rest, '/'); i >= 0 {
+		return protocol.ID("/pangea" + rest[i:])
+	}
+	return proto
+}
+
+// namespacedHost is a host whose Pangea protocol handlers and streams live
+// in a namespace. Services use it like any host and keep their plain
+// protocol IDs.
+type namespacedHost struct {
+	host.Host
+	ns string
+}
+
+// newNamespacedHost wraps h, or returns it as is when ns is empty
+func newNamespacedHost(h host.Host, ns string) host.Host {
+	if ns == "" {
+		return h
+	}
+	return &namespacedHost{Host: h, ns: ns}
+}
+
+// baseHost returns the host a namespaced host wraps
+func baseHost(h host.Host) host.Host {
+	if nh, ok := h.(*namespacedHost); ok {
+		return nh.Host
+	}
+	return h
+}
+
+func (h *namespacedHost) SetStreamHandler(pid protocol.ID, handler network.StreamHandler) {
+	h.Host.SetStreamHandler(namespacedProtocol(h.ns, pid), handler)
+}
+
+func (h *namespacedHost) SetStreamHandlerMatch(pid protocol.ID, match func(protocol.ID) bool, handler network.StreamHandler) {
+	h.Host.SetStreamHandlerMatch(namespacedProtocol(h.ns, pid), func(id protocol.ID) bool {
+		if id != namespacedProtocol(h.ns, baseProtocol(id)) {
+			return false // Another namespace
+		}
+		return match(baseProtocol(id))
+	}, handler)
+}
+
+func (h *namespacedHost) RemoveStreamHandler(pid protocol.ID) {
+	h.Host.RemoveStreamHandler(namespacedProtocol(h.ns, pid))
+}
+
+func (h *namespacedHost) NewStream(ctx context.Context, p peer.ID, pids ...protocol.ID) (network.Stream, error) {
+	ids := make([]protocol.ID, len(pids))
+	for i, pid := range pids {
+		ids[i] = namespacedProtocol(h.ns, pid)
+	}
+	return h.Host.NewStream(ctx, p, ids...)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestNetworkNamespace(t *testing.T) {
+	nsA, nsB := NetworkNamespace([]byte("cluster-a")), NetworkNamespace([]byte("cluster-b"))
+	if nsA == nsB || nsA != NetworkNamespace([]byte("cluster-a")) || len(nsA) != namespaceLen {
+		t.Fatalf("bad namespaces %q, %q", nsA, nsB)
+	}
+	if got := namespacedProtocol(nsA, ChatProtocolID); string(got) != "/pangea-"+nsA+"/ephemeral-chat/1.0.0" {
+		t.Fatalf("namespacedProtocol = %s", got)
+	}
+	if got := namespacedProtocol(nsA, "/ipfs/kad/1.0.0"); got != "/ipfs/kad/1.0.0" {
+		t.Fatalf("non-Pangea protocol rewritten to %s", got)
+	}
+	if got := baseProtocol(namespacedProtocol(nsA, ComputeProtocolID)); got != ComputeProtocolID {
+		t.Fatalf("baseProtocol = %s", got)
+	}
+	if protocolCategory(namespacedProtocol(nsA, ComputeProtocolID)) != ProtocolCategoryCompute {
+		t.Fatal("namespaced protocol not categorised")
+	}
+
+	newNode := func(id uint32, port int, ns string) *LibP2PPangeaNode {
+		n, err := NewLibP2PPangeaNodeWithNetworkOptions(id, NewNodeStore(), true, true, port, NetworkOptions{Namespace: ns})
+		if err != nil {
+			t.Fatalf("failed to create node %d: %v", id, err)
+		}
+		t.Cleanup(n.cancel)
+		return n
+	}
+	a, b, c := newNode(622, 0, nsA), newNode(623, 0, nsA), newNode(624, 0, nsB)
+	if a.topic == c.topic || a.topic != discoveryTopic(nsA) {
+		t.Fatalf("discovery topics %q and %q must differ", a.topic, c.topic)
+	}
+
+	const testProto = "/pangea/namespace-test/1.0.0"
+	a.host.SetStreamHandler(testProto, func(s network.Stream) { s.Close() })
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for _, n := range []*LibP2PPangeaNode{b, c} {
+		addrs := a.host.Network().ListenAddresses()
+		if err := n.host.Connect(ctx, peer.AddrInfo{ID: a.host.ID(), Addrs: addrs}); err != nil {
+			t.Fatalf("connect failed: %v", err)
+		}
+	}
+	s, err := b.host.NewStream(ctx, a.host.ID(), testProto)
+	if err != nil {
+		t.Fatalf("same namespace stream failed: %v", err)
+	}
+	s.Close()
+	if s, err := c.host.NewStream(ctx, a.host.ID(), testProto); err == nil {
+		s.Close()
+		t.Fatal("expected a node in another namespace to be refused")
+	}
+}
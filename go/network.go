@@ -1,9 +1,13 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"crypto/rand"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"sync"
@@ -12,6 +16,32 @@ import (
 	"github.com/flynn/noise"
 )
 
+const (
+	// Dialing: each attempt is bounded by legacyDialTimeout, and failed
+	// attempts are retried after a backoff that doubles up to a cap
+	legacyDialTimeout  = 5 * time.Second
+	legacyDialAttempts = 5
+	legacyDialBackoff  = 250 * time.Millisecond
+	legacyMaxBackoff   = 4 * time.Second
+
+	legacyHandshakeTimeout = 10 * time.Second
+	legacyReplyTimeout     = 10 * time.Second
+
+	// Each probe interval every peer gets DefaultProbesPerRound pings, each
+	// lost unless its nonce comes back within legacyPingTimeout
+	legacyProbeInterval = 5 * time.Second
+	legacyPingTimeout   = 2 * time.Second
+	legacyNonceSize     = 8
+
+	// legacyMaxFrame is the largest Noise message; frames carry a 2-byte
+	// length prefix
+	legacyMaxFrame = 65535
+)
+
+// errPeerIDMismatch means the node at an address is not the one the
+// caller asked for; retrying cannot help
+var errPeerIDMismatch = errors.New("peer ID mismatch")
+
 // NoiseConfig holds the Noise Protocol configuration
 type NoiseConfig struct {
 	staticKey noise.DHKey
@@ -27,16 +57,33 @@ type P2PNode struct {
 	listener    net.Listener
 	ctx         context.Context
 	cancel      context.CancelFunc
+
+	handlers  map[legacyMsgType]legacyHandler
+	onMessage func(from uint32, data []byte)
+	handlerMu sync.RWMutex
+
+	shards  map[string]map[uint32][]byte // fileHash -> shardIndex -> data
+	shares  map[string][]byte            // fileID -> this node's DKG share
+	storeMu sync.RWMutex
+
+	quality   map[uint32]*PeerQuality // Ping statistics of connected peers
+	qualityMu sync.RWMutex
 }
 
 // P2PConnection represents a connection to another peer
 type P2PConnection struct {
-	id          uint32
-	conn        net.Conn
-	cipherState *noise.CipherState // Encryption state after handshake
-	mu          sync.Mutex
-	lastPing    time.Time
-	latency     time.Duration
+	id     uint32
+	key    []byte // Peer's Noise static key, which its node ID is bound to
+	conn   net.Conn
+	sendCS *noise.CipherState // Encrypts what this side writes
+	recvCS *noise.CipherState // Decrypts what the peer writes
+	closed chan struct{}      // Closed when the reader stops
+	mu     sync.Mutex         // Serialises writes
+
+	// Requests awaiting a response, by request ID
+	pending       map[uint32]chan legacyMessage
+	nextRequestID uint32
+	pendingMu     sync.Mutex
 }
 
 // NewP2PNode creates a new P2P node
@@ -49,7 +96,7 @@ func NewP2PNode(id uint32, store *NodeStore) (*P2PNode, error) {
 
 	ctx, cancel := context.WithCancel(context.Background())
 
-	return &P2PNode{
+	p := &P2PNode{
 		id:    id,
 		store: store,
 		noiseConfig: &NoiseConfig{
@@ -58,7 +105,13 @@ func NewP2PNode(id uint32, store *NodeStore) (*P2PNode, error) {
 		connections: make(map[uint32]*P2PConnection),
 		ctx:         ctx,
 		cancel:      cancel,
-	}, nil
+		handlers:    make(map[legacyMsgType]legacyHandler),
+		shards:      make(map[string]map[uint32][]byte),
+		shares:      make(map[string][]byte),
+		quality:     make(map[uint32]*PeerQuality),
+	}
+	p.registerDefaultHandlers()
+	return p, nil
 }
 
 // Start starts the P2P node listener
@@ -69,13 +122,13 @@ func (p *P2PNode) Start(listenAddr string) error {
 	}
 
 	p.listener = listener
-	log.Printf("P2P Node %d listening on %s", p.id, listenAddr)
+	log.Printf("P2P Node %d listening on %s", p.id, listener.Addr())
 
 	// Start accepting connections
 	go p.acceptConnections()
 
-	// Start latency measurement loop
-	go p.measureLatency()
+	// Start connection quality probing
+	go p.probeLoop()
 
 	return nil
 }
@@ -83,53 +136,42 @@ func (p *P2PNode) Start(listenAddr string) error {
 // acceptConnections accepts incoming P2P connections
 func (p *P2PNode) acceptConnections() {
 	for {
-		select {
-		case <-p.ctx.Done():
-			return
-		default:
-			conn, err := p.listener.Accept()
-			if err != nil {
-				log.Printf("Error accepting connection: %v", err)
-				continue
+		conn, err := p.listener.Accept()
+		if err != nil {
+			if p.ctx.Err() != nil {
+				return
 			}
-
-			go p.handleIncomingConnection(conn)
+			log.Printf("Error accepting connection: %v", err)
+			continue
 		}
+
+		go p.handleIncomingConnection(conn)
 	}
 }
 
 // handleIncomingConnection handles a new incoming connection
 func (p *P2PNode) handleIncomingConnection(conn net.Conn) {
-	defer conn.Close()
-
 	// Perform Noise Protocol handshake (as responder)
-	cipherState, peerID, err := p.performHandshake(conn, false)
+	c, err := p.performHandshake(conn, false)
 	if err != nil {
 		log.Printf("Handshake failed: %v", err)
+		conn.Close()
 		return
 	}
-
-	p2pConn := &P2PConnection{
-		id:          peerID,
-		conn:        conn,
-		cipherState: cipherState,
-		lastPing:    time.Now(),
+	if err := p.register(c); err != nil {
+		log.Printf("Rejected P2P connection from %s: %v", conn.RemoteAddr(), err)
+		conn.Close()
+		return
 	}
-
-	p.mu.Lock()
-	p.connections[peerID] = p2pConn
-	p.mu.Unlock()
-
-	log.Printf("New P2P connection established with node %d", peerID)
+	log.Printf("New P2P connection established with node %d", c.id)
 
 	// Handle connection messages
-	p.handleConnectionMessages(p2pConn)
+	p.handleConnectionMessages(c)
 }
 
-// performHandshake performs Noise Protocol XX handshake
-// Returns: cipher state, peer ID, error
-func (p *P2PNode) performHandshake(conn net.Conn, isInitiator bool) (*noise.CipherState, uint32, error) {
-	// Configure Noise Protocol with XX handshake pattern
+// performHandshake performs a Noise XX handshake in which each side sends
+// its node ID as the payload of its static key message
+func (p *P2PNode) performHandshake(conn net.Conn, isInitiator bool) (*P2PConnection, error) {
 	config := noise.Config{
 		CipherSuite:   noise.NewCipherSuite(noise.DH25519, noise.CipherChaChaPoly, noise.HashBLAKE2b),
 		Random:        rand.Reader,
@@ -137,191 +179,225 @@ func (p *P2PNode) performHandshake(conn net.Conn, isInitiator bool) (*noise.Ciph
 		Initiator:     isInitiator,
 		StaticKeypair: p.noiseConfig.staticKey,
 	}
-
 	handshake, err := noise.NewHandshakeState(config)
 	if err != nil {
-		return nil, 0, fmt.Errorf("failed to create handshake state: %w", err)
+		return nil, fmt.Errorf("failed to create handshake state: %w", err)
 	}
 
-	var message, plaintext []byte
+	conn.SetDeadline(time.Now().Add(legacyHandshakeTimeout))
+	defer conn.SetDeadline(time.Time{})
 
-	// XX handshake: 3 messages
-	// Message 1: -> e
-	var cs1, cs2 *noise.CipherState
-	if isInitiator {
-		message, cs1, cs2, err = handshake.WriteMessage(nil, nil)
-		if err != nil {
-			return nil, 0, fmt.Errorf("handshake message 1 failed: %w", err)
-		}
-		if _, err := conn.Write(message); err != nil {
-			return nil, 0, fmt.Errorf("failed to send handshake message 1: %w", err)
-		}
-	} else {
-		buffer := make([]byte, 64) // XX message 1 is typically 48 bytes
-		n, err := conn.Read(buffer)
+	nodeID := binary.LittleEndian.AppendUint32(nil, p.id)
+	write := func(n int, payload []byte) (*noise.CipherState, *noise.CipherState, error) {
+		msg, cs1, cs2, err := handshake.WriteMessage(nil, payload)
 		if err != nil {
-			return nil, 0, fmt.Errorf("failed to read handshake message 1: %w", err)
+			return nil, nil, fmt.Errorf("handshake message %d failed: %w", n, err)
 		}
-		_, _, _, err = handshake.ReadMessage(nil, buffer[:n])
-		if err != nil {
-			return nil, 0, fmt.Errorf("handshake message 1 read failed: %w", err)
+		if err := writeFrame(conn, msg); err != nil {
+			return nil, nil, fmt.Errorf("failed to send handshake message %d: %w", n, err)
 		}
+		return cs1, cs2, nil
 	}
-
-	// Message 2: <- e, ee, s, es (contains peer's static public key)
-	var peerID uint32
-
-	if !isInitiator {
-		// Include our node ID in the payload
-		nodeIDPayload := make([]byte, 4)
-		nodeIDPayload[0] = byte(p.id)
-		nodeIDPayload[1] = byte(p.id >> 8)
-		nodeIDPayload[2] = byte(p.id >> 16)
-		nodeIDPayload[3] = byte(p.id >> 24)
-
-		var msg []byte
-		msg, cs1, cs2, err = handshake.WriteMessage(nil, nodeIDPayload)
+	read := func(n int) ([]byte, *noise.CipherState, *noise.CipherState, error) {
+		msg, err := readFrame(conn)
 		if err != nil {
-			return nil, 0, fmt.Errorf("handshake message 2 failed: %w", err)
-		}
-		message = msg
-		if _, err := conn.Write(message); err != nil {
-			return nil, 0, fmt.Errorf("failed to send handshake message 2: %w", err)
+			return nil, nil, nil, fmt.Errorf("failed to read handshake message %d: %w", n, err)
 		}
-	} else {
-		buffer := make([]byte, 128) // XX message 2 is typically 96 bytes + payload
-		n, err := conn.Read(buffer)
+		payload, cs1, cs2, err := handshake.ReadMessage(nil, msg)
 		if err != nil {
-			return nil, 0, fmt.Errorf("failed to read handshake message 2: %w", err)
-		}
-		var pt []byte
-		pt, cs1, cs2, err = handshake.ReadMessage(nil, buffer[:n])
-		if err != nil {
-			return nil, 0, fmt.Errorf("handshake message 2 read failed: %w", err)
-		}
-		plaintext = pt
-		// Extract peer ID from payload
-		if len(plaintext) >= 4 {
-			peerID = uint32(plaintext[0]) | uint32(plaintext[1])<<8 | uint32(plaintext[2])<<16 | uint32(plaintext[3])<<24
+			return nil, nil, nil, fmt.Errorf("handshake message %d read failed: %w", n, err)
 		}
+		return payload, cs1, cs2, nil
 	}
 
-	// Message 3: -> s, se (contains our static public key)
+	// XX: -> e; <- e, ee, s, es; -> s, se. The final message yields the
+	// initiator-to-responder (cs1) and responder-to-initiator (cs2) keys.
+	var payload []byte
+	var cs1, cs2 *noise.CipherState
 	if isInitiator {
-		// Include our node ID in the payload
-		nodeIDPayload := make([]byte, 4)
-		nodeIDPayload[0] = byte(p.id)
-		nodeIDPayload[1] = byte(p.id >> 8)
-		nodeIDPayload[2] = byte(p.id >> 16)
-		nodeIDPayload[3] = byte(p.id >> 24)
-
-		var msg []byte
-		msg, _, _, err = handshake.WriteMessage(nil, nodeIDPayload)
-		if err != nil {
-			return nil, 0, fmt.Errorf("handshake message 3 failed: %w", err)
+		if _, _, err = write(1, nil); err != nil {
+			return nil, err
 		}
-		message = msg
-		if _, err := conn.Write(message); err != nil {
-			return nil, 0, fmt.Errorf("failed to send handshake message 3: %w", err)
+		if payload, _, _, err = read(2); err != nil {
+			return nil, err
 		}
-		cipherState := cs1
-		if cipherState == nil {
-			cipherState = cs2
+		if cs1, cs2, err = write(3, nodeID); err != nil {
+			return nil, err
 		}
-		// Peer ID was extracted from message 2
-		return cipherState, peerID, nil
 	} else {
-		buffer := make([]byte, 80) // XX message 3 is typically 64 bytes + payload
-		n, err := conn.Read(buffer)
-		if err != nil {
-			return nil, 0, fmt.Errorf("failed to read handshake message 3: %w", err)
-		}
-		var pt []byte
-		pt, _, _, err = handshake.ReadMessage(nil, buffer[:n])
-		if err != nil {
-			return nil, 0, fmt.Errorf("handshake message 3 read failed: %w", err)
+		if _, _, _, err = read(1); err != nil {
+			return nil, err
 		}
-		plaintext = pt
-		// Extract peer ID from payload (though we already know it from ConnectToPeer)
-		if len(plaintext) >= 4 {
-			peerID = uint32(plaintext[0]) | uint32(plaintext[1])<<8 | uint32(plaintext[2])<<16 | uint32(plaintext[3])<<24
+		if _, _, err = write(2, nodeID); err != nil {
+			return nil, err
 		}
-		cipherState := cs1
-		if cipherState == nil {
-			cipherState = cs2
+		if payload, cs1, cs2, err = read(3); err != nil {
+			return nil, err
 		}
-		return cipherState, peerID, nil
 	}
+	if len(payload) < 4 || cs1 == nil || cs2 == nil {
+		return nil, errors.New("handshake did not complete")
+	}
+
+	c := &P2PConnection{
+		id:      binary.LittleEndian.Uint32(payload),
+		key:     handshake.PeerStatic(),
+		conn:    conn,
+		sendCS:  cs1,
+		recvCS:  cs2,
+		closed:  make(chan struct{}),
+		pending: make(map[uint32]chan legacyMessage),
+	}
+	if !isInitiator {
+		c.sendCS, c.recvCS = cs2, cs1
+	}
+	return c, nil
 }
 
-// handleConnectionMessages handles messages from a connection
-func (p *P2PNode) handleConnectionMessages(conn *P2PConnection) {
-	buffer := make([]byte, 4096)
-	for {
-		select {
-		case <-p.ctx.Done():
-			return
-		default:
-			n, err := conn.conn.Read(buffer)
-			if err != nil {
-				log.Printf("Error reading from connection: %v", err)
-				p.mu.Lock()
-				delete(p.connections, conn.id)
-				p.mu.Unlock()
-				return
-			}
+// register adds a connection to the connections map. Node IDs are only
+// claimed in the handshake, so while a connection to a node is open its ID
+// stays bound to that connection's Noise static key: a new connection from
+// the same key replaces it, and one from another key is refused.
+func (p *P2PNode) register(c *P2PConnection) error {
+	p.mu.Lock()
+	old := p.connections[c.id]
+	if old != nil && old != c && !bytes.Equal(old.key, c.key) {
+		p.mu.Unlock()
+		return fmt.Errorf("node %d is already connected with another key", c.id)
+	}
+	p.connections[c.id] = c
+	p.mu.Unlock()
+	if old != nil && old != c {
+		old.conn.Close()
+	}
+	return nil
+}
+
+// unregister closes a connection and removes it from the connections
+// map, unless it was already replaced
+func (p *P2PNode) unregister(c *P2PConnection) {
+	c.conn.Close()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.connections[c.id] == c {
+		delete(p.connections, c.id)
+	}
+}
 
-			// Decrypt and process message using Noise Protocol
-			if conn.cipherState != nil {
-				plaintext, err := conn.cipherState.Decrypt(nil, nil, buffer[:n])
-				if err != nil {
-					log.Printf("Decryption failed: %v", err)
-					continue
-				}
-				log.Printf("Received %d encrypted bytes from node %d (decrypted: %d bytes)", n, conn.id, len(plaintext))
-			} else {
-				log.Printf("Received %d unencrypted bytes from node %d", n, conn.id)
+// handleConnectionMessages reads messages from a connection until it
+// closes and dispatches each one (see legacy_message.go)
+func (p *P2PNode) handleConnectionMessages(c *P2PConnection) {
+	defer close(c.closed)
+	defer p.unregister(c)
+	for {
+		msg, err := c.receive()
+		if err != nil {
+			if p.ctx.Err() == nil && !errors.Is(err, net.ErrClosed) && !errors.Is(err, io.EOF) {
+				log.Printf("Error reading from node %d: %v", c.id, err)
 			}
+			return
 		}
+		p.dispatch(c, msg)
+	}
+}
+
+// SetMessageHandler sets the function that receives SendMessage payloads
+// from peers. Without one they are only logged.
+func (p *P2PNode) SetMessageHandler(fn func(from uint32, data []byte)) {
+	p.handlerMu.Lock()
+	defer p.handlerMu.Unlock()
+	p.onMessage = fn
+}
+
+// StoreShard stores a shard for a given fileHash and index on this node
+func (p *P2PNode) StoreShard(fileHash string, shardIndex uint32, data []byte) {
+	p.storeMu.Lock()
+	defer p.storeMu.Unlock()
+	if _, ok := p.shards[fileHash]; !ok {
+		p.shards[fileHash] = make(map[uint32][]byte)
 	}
+	p.shards[fileHash][shardIndex] = data
 }
 
-// ConnectToPeer connects to another peer
+// FetchLocalShard returns shard data if present locally
+func (p *P2PNode) FetchLocalShard(fileHash string, shardIndex uint32) ([]byte, bool) {
+	p.storeMu.RLock()
+	defer p.storeMu.RUnlock()
+	data, ok := p.shards[fileHash][shardIndex]
+	return data, ok
+}
+
+// StoreDKGShare stores this node's DKG share for a file ID
+func (p *P2PNode) StoreDKGShare(fileID string, share []byte) {
+	p.storeMu.Lock()
+	defer p.storeMu.Unlock()
+	p.shares[fileID] = share
+}
+
+// GetLocalShare returns this node's share for fileID if present
+func (p *P2PNode) GetLocalShare(fileID string) ([]byte, bool) {
+	p.storeMu.RLock()
+	defer p.storeMu.RUnlock()
+	share, ok := p.shares[fileID]
+	return share, ok
+}
+
+// ConnectToPeer dials a peer, runs the Noise handshake and registers the
+// connection. Failed attempts are retried with exponential backoff. A
+// nonzero peerID must match the ID the peer presents.
 func (p *P2PNode) ConnectToPeer(peerAddr string, peerID uint32) error {
-	conn, err := net.Dial("tcp", peerAddr)
+	backoff := legacyDialBackoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = p.dialPeer(peerAddr, peerID); err == nil {
+			return nil
+		}
+		if errors.Is(err, errPeerIDMismatch) || attempt == legacyDialAttempts {
+			break
+		}
+		log.Printf("Connect to %s failed (attempt %d/%d), retrying in %v: %v", peerAddr, attempt, legacyDialAttempts, backoff, err)
+		select {
+		case <-p.ctx.Done():
+			return p.ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, legacyMaxBackoff)
+	}
+	return fmt.Errorf("failed to connect to %s: %w", peerAddr, err)
+}
+
+// dialPeer makes one connection attempt
+func (p *P2PNode) dialPeer(peerAddr string, peerID uint32) error {
+	dialer := net.Dialer{Timeout: legacyDialTimeout}
+	conn, err := dialer.DialContext(p.ctx, "tcp", peerAddr)
 	if err != nil {
-		return fmt.Errorf("failed to connect to %s: %w", peerAddr, err)
+		return err
 	}
 
 	// Perform Noise Protocol handshake (as initiator)
-	cipherState, remotePeerID, err := p.performHandshake(conn, true)
+	c, err := p.performHandshake(conn, true)
 	if err != nil {
 		conn.Close()
 		return fmt.Errorf("handshake failed: %w", err)
 	}
-
-	p2pConn := &P2PConnection{
-		id:          remotePeerID,
-		conn:        conn,
-		cipherState: cipherState,
-		lastPing:    time.Now(),
+	if peerID != 0 && c.id != peerID {
+		conn.Close()
+		return fmt.Errorf("%w: expected node %d, %s is node %d", errPeerIDMismatch, peerID, peerAddr, c.id)
 	}
-
-	p.mu.Lock()
-	p.connections[peerID] = p2pConn
-	p.mu.Unlock()
-
-	log.Printf("Connected to peer %d at %s", peerID, peerAddr)
+	if err := p.register(c); err != nil {
+		conn.Close()
+		return err
+	}
+	log.Printf("Connected to peer %d at %s", c.id, peerAddr)
 
 	// Handle connection messages
-	go p.handleConnectionMessages(p2pConn)
-
+	go p.handleConnectionMessages(c)
 	return nil
 }
 
-// measureLatency periodically measures latency to all connected peers
-func (p *P2PNode) measureLatency() {
-	ticker := time.NewTicker(5 * time.Second)
+// probeLoop pings all connected peers every legacyProbeInterval
+func (p *P2PNode) probeLoop() {
+	ticker := time.NewTicker(legacyProbeInterval)
 	defer ticker.Stop()
 
 	for {
@@ -329,70 +405,99 @@ func (p *P2PNode) measureLatency() {
 		case <-p.ctx.Done():
 			return
 		case <-ticker.C:
-			p.mu.RLock()
-			connections := make([]*P2PConnection, 0, len(p.connections))
-			for _, conn := range p.connections {
-				connections = append(connections, conn)
-			}
-			p.mu.RUnlock()
-
-			for _, conn := range connections {
-				latency := p.pingPeer(conn)
-				if latency > 0 {
-					conn.mu.Lock()
-					conn.latency = latency
-					conn.lastPing = time.Now()
-					conn.mu.Unlock()
-
-					// Update node store with latency
-					p.store.UpdateLatency(conn.id, float32(latency.Milliseconds()))
-				}
-			}
+			p.probeAll()
 		}
 	}
 }
 
-// pingPeer sends a ping to a peer and measures RTT
-func (p *P2PNode) pingPeer(conn *P2PConnection) time.Duration {
-	conn.mu.Lock()
-	defer conn.mu.Unlock()
+// probeAll runs one probe round against every connected peer and drops
+// the statistics of peers that are gone
+func (p *P2PNode) probeAll() {
+	p.mu.RLock()
+	connections := make([]*P2PConnection, 0, len(p.connections))
+	for _, conn := range p.connections {
+		connections = append(connections, conn)
+	}
+	p.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	live := make(map[uint32]bool, len(connections))
+	for _, conn := range connections {
+		live[conn.id] = true
+		wg.Add(1)
+		go func(c *P2PConnection) {
+			defer wg.Done()
+			p.probePeer(c)
+		}(conn)
+	}
+	wg.Wait()
 
-	start := time.Now()
-	pingMsg := []byte("PING")
+	p.qualityMu.Lock()
+	defer p.qualityMu.Unlock()
+	for id := range p.quality {
+		if !live[id] {
+			delete(p.quality, id)
+		}
+	}
+}
 
-	// Encrypt ping message using Noise Protocol
-	var encryptedMsg []byte
-	var err error
-	if conn.cipherState != nil {
-		encryptedMsg, err = conn.cipherState.Encrypt(nil, nil, pingMsg)
-		if err != nil {
-			return 0
+// probePeer sends DefaultProbesPerRound pings, folds the answers into the
+// peer's rolling statistics and copies them to the node store
+func (p *P2PNode) probePeer(c *P2PConnection) {
+	rtts := make([]time.Duration, 0, DefaultProbesPerRound)
+	for range DefaultProbesPerRound {
+		if rtt := p.pingPeer(c); rtt > 0 {
+			rtts = append(rtts, rtt)
 		}
+	}
+
+	p.qualityMu.Lock()
+	q, exists := p.quality[c.id]
+	if !exists {
+		q = &PeerQuality{}
+		p.quality[c.id] = q
+	}
+	q.record(rtts, DefaultProbesPerRound)
+	latency, loss := q.LatencyMs, q.PacketLoss
+	p.qualityMu.Unlock()
+
+	if len(rtts) == 0 {
+		log.Printf("❌ Quality probe to node %d: all %d pings lost", c.id, DefaultProbesPerRound)
 	} else {
-		encryptedMsg = pingMsg
+		p.store.UpdateLatency(c.id, latency)
 	}
+	p.store.UpdatePacketLoss(c.id, loss)
+}
 
-	if _, err := conn.conn.Write(encryptedMsg); err != nil {
-		return 0
+// GetPeerQuality returns the ping statistics for a connected peer
+func (p *P2PNode) GetPeerQuality(peerID uint32) (PeerQuality, bool) {
+	p.qualityMu.RLock()
+	defer p.qualityMu.RUnlock()
+	q, exists := p.quality[peerID]
+	if !exists {
+		return PeerQuality{}, false
 	}
+	return *q, true
+}
 
-	// Wait for PONG response (simplified - in real implementation, this would be async)
-	buffer := make([]byte, 64) // Enough for encrypted response
-	conn.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
-	n, err := conn.conn.Read(buffer)
+// pingPeer sends a ping carrying a random nonce and measures the RTT until
+// the nonce is echoed back; 0 means the ping was lost
+func (p *P2PNode) pingPeer(c *P2PConnection) time.Duration {
+	nonce := make([]byte, legacyNonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return 0
+	}
+	start := time.Now()
+	echo, err := c.call(legacyMsgPing, nonce, legacyMsgPong, legacyPingTimeout)
 	if err != nil {
 		return 0
 	}
-
-	// Decrypt response if using Noise Protocol
-	if conn.cipherState != nil {
-		_, err = conn.cipherState.Decrypt(nil, nil, buffer[:n])
-		if err != nil {
-			return 0
-		}
+	rtt := time.Since(start)
+	if !bytes.Equal(echo, nonce) {
+		log.Printf("⚠️  Node %d answered a ping with the wrong nonce", c.id)
+		return 0
 	}
-
-	return time.Since(start)
+	return rtt
 }
 
 // Stop stops the P2P node
@@ -408,3 +513,25 @@ func (p *P2PNode) Stop() {
 		conn.conn.Close()
 	}
 }
+
+// writeFrame writes a length-prefixed frame
+func writeFrame(w io.Writer, frame []byte) error {
+	if len(frame) > legacyMaxFrame {
+		return fmt.Errorf("frame of %d bytes exceeds %d", len(frame), legacyMaxFrame)
+	}
+	_, err := w.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(frame))), frame...))
+	return err
+}
+
+// readFrame reads a length-prefixed frame
+func readFrame(r io.Reader) ([]byte, error) {
+	var size [2]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		return nil, err
+	}
+	frame := make([]byte, binary.BigEndian.Uint16(size[:]))
+	if _, err := io.ReadFull(r, frame); err != nil {
+		return nil, err
+	}
+	return frame, nil
+}
@@ -1,12 +1,28 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"os"
+	"path/filepath"
 	"sync"
+	"time"
 
+	"github.com/libp2p/go-libp2p/core/metrics"
 	"github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/pangea-net/go-node/pkg/compute"
 )
 
+// shardAckTimeout bounds how long SendShard waits for a placement ack
+const shardAckTimeout = 10 * time.Second
+
 // NetworkAdapter provides a unified interface for both libp2p and legacy P2P implementations
 type NetworkAdapter interface {
 	// ConnectToPeer connects to a peer by address
@@ -18,8 +34,8 @@ type NetworkAdapter interface {
 	// SendMessage sends a message to a peer
 	SendMessage(peerID uint32, data []byte) error
 
-	// FetchShard fetches a shard from a peer
-	FetchShard(peerID uint32, shardIndex uint32) ([]byte, error)
+	// FetchShard fetches a shard of fileHash from a peer
+	FetchShard(ctx context.Context, peerID uint32, fileHash string, shardIndex uint32) ([]byte, error)
 
 	// FetchShare fetches a DKG share for a fileID from a peer
 	FetchShare(peerID uint32, fileID string) ([]byte, error)
@@ -33,10 +49,12 @@ type NetworkAdapter interface {
 type LibP2PAdapter struct {
 	node  *LibP2PPangeaNode
 	store *NodeStore
-	// Bidirectional peer ID mapping
+	// Bidirectional peer ID mapping. IDs are derived from the peer ID, so
+	// they stay the same across restarts; peerIDPath keeps the rare IDs
+	// moved by a collision stable too.
 	peerIDToUint32 map[string]uint32
 	uint32ToPeerID map[uint32]string
-	nextPeerID     uint32
+	peerIDPath     string // Empty keeps the mapping in memory only
 	peerIDMu       sync.RWMutex
 }
 
@@ -46,15 +64,15 @@ func NewLibP2PAdapter(node *LibP2PPangeaNode, store *NodeStore) *LibP2PAdapter {
 		store:          store,
 		peerIDToUint32: make(map[string]uint32),
 		uint32ToPeerID: make(map[uint32]string),
-		nextPeerID:     1,
 	}
 }
 
 // LegacyP2PAdapter wraps the legacy TCP-based P2P node for tests and backwards compatibility
 // This is a thin adapter that maps the older P2P node API to NetworkAdapter.
 type LegacyP2PAdapter struct {
-	node  *P2PNode
-	store *NodeStore
+	node      *P2PNode
+	store     *NodeStore
+	bandwidth *BandwidthScheduler // Paces shard uploads; may be nil
 }
 
 func NewLegacyP2PAdapter(node *P2PNode, store *NodeStore) *LegacyP2PAdapter {
@@ -67,9 +85,7 @@ var ErrPeerNotConnected = ErrorString("peer not connected")
 
 // Minimal ConnectToPeer implementation for legacy adapter (no-op for tests)
 func (a *LegacyP2PAdapter) ConnectToPeer(peerAddr string, peerID uint32) error {
-	// In the legacy adapter, explicit dialing may be out-of-band (test harness connects nodes),
-	// so for now this is a no-op that succeeds when used in local tests.
-	return nil
+	return a.node.ConnectToPeer(peerAddr, peerID)
 }
 
 // getPeerUint32ID returns the uint32 ID for a libp2p peer ID, creating one if needed
@@ -81,14 +97,82 @@ func (a *LibP2PAdapter) getPeerUint32ID(peerIDStr string) uint32 {
 		return id
 	}
 
-	// Create new mapping
-	id := a.nextPeerID
-	a.nextPeerID++
+	// Start from a hash of the peer ID and move past IDs already taken.
+	// 0 means "all peers" in several RPCs and the node's own ID means
+	// itself, so neither is handed out.
+	sum := sha256.Sum256([]byte(peerIDStr))
+	id := binary.BigEndian.Uint32(sum[:4])
+	for {
+		_, taken := a.uint32ToPeerID[id]
+		if id != 0 && !taken && (a.node == nil || id != a.node.nodeID) {
+			break
+		}
+		id++
+	}
 	a.peerIDToUint32[peerIDStr] = id
 	a.uint32ToPeerID[id] = peerIDStr
+	if err := a.savePeerIDsLocked(); err != nil {
+		log.Printf("⚠️  Failed to save peer ID mapping: %v", err)
+	}
 	return id
 }
 
+// SetPeerIDPath persists the peer ID mapping to path and loads the
+// mapping already there
+func (a *LibP2PAdapter) SetPeerIDPath(path string) error {
+	var saved map[string]uint32
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &saved); err != nil {
+			return fmt.Errorf("invalid peer ID mapping: %w", err)
+		}
+	}
+
+	a.peerIDMu.Lock()
+	defer a.peerIDMu.Unlock()
+	a.peerIDPath = path
+	for peerIDStr, id := range saved {
+		if other, taken := a.uint32ToPeerID[id]; taken && other != peerIDStr {
+			log.Printf("⚠️  Peer ID %d is assigned to both %s and %s; keeping %s", id, other, peerIDStr, other)
+			continue
+		}
+		if old, ok := a.peerIDToUint32[peerIDStr]; ok {
+			delete(a.uint32ToPeerID, old)
+		}
+		a.peerIDToUint32[peerIDStr] = id
+		a.uint32ToPeerID[id] = peerIDStr
+	}
+	return a.savePeerIDsLocked()
+}
+
+// savePeerIDsLocked writes the mapping to peerIDPath, if set. Caller must
+// hold peerIDMu.
+func (a *LibP2PAdapter) savePeerIDsLocked() error {
+	if a.peerIDPath == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(a.peerIDToUint32, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(a.peerIDPath), 0700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(a.peerIDPath+".tmp", data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(a.peerIDPath+".tmp", a.peerIDPath)
+}
+
+// PeerUint32ID returns the uint32 ID of a libp2p peer, assigning one if
+// the peer has none yet
+func (a *LibP2PAdapter) PeerUint32ID(p peer.ID) uint32 {
+	return a.getPeerUint32ID(p.String())
+}
+
 // getLibp2pPeerID returns the libp2p peer ID string for a uint32 ID
 func (a *LibP2PAdapter) getLibp2pPeerID(id uint32) (string, bool) {
 	a.peerIDMu.RLock()
@@ -119,110 +203,164 @@ func (a *LibP2PAdapter) DisconnectPeer(peerID uint32) error {
 	return nil
 }
 
-func (a *LibP2PAdapter) SendMessage(peerID uint32, data []byte) error {
-	// Look up the libp2p peer ID
-	peerIDStr, exists := a.getLibp2pPeerID(peerID)
-	if !exists {
-		return fmt.Errorf("peer %d not found in mapping", peerID)
+// openRPC resolves a uint32 peer ID and opens a negotiated RPC stream to it
+func (a *LibP2PAdapter) openRPC(peerID uint32) (*rpcStream, peer.ID, error) {
+	pid, err := a.resolvePeer(peerID)
+	if err != nil {
+		return nil, "", err
 	}
+	rs, err := openRPCStream(a.node.ctx, a.node.host, pid)
+	if err != nil {
+		return nil, "", err
+	}
+	return rs, pid, nil
+}
 
-	pid, err := peer.Decode(peerIDStr)
+func (a *LibP2PAdapter) SendMessage(peerID uint32, data []byte) error {
+	rs, _, err := a.openRPC(peerID)
 	if err != nil {
-		return fmt.Errorf("invalid peer ID: %w", err)
+		return err
 	}
+	defer rs.Close()
+	return rs.send(rpcMsgData, data)
+}
 
-	stream, err := a.node.host.NewStream(a.node.ctx, pid, PangeaRPCProtocol)
+// SendShare sends a DKG share to the peer for the given fileID and waits
+// for it to be stored
+func (a *LibP2PAdapter) SendShare(peerID uint32, fileID string, share []byte) error {
+	rs, _, err := a.openRPC(peerID)
 	if err != nil {
 		return err
 	}
-	defer stream.Close()
+	defer rs.Close()
 
-	_, err = stream.Write(data)
+	req := appendRPCString(nil, fileID)
+	req = binary.BigEndian.AppendUint32(req, a.node.nodeID)
+	_, err = rs.call(rpcMsgStoreShare, append(req, share...), rpcMsgStoreShareAck)
 	return err
 }
 
-// SendShare sends a DKG share to the peer for the given fileID
-// New message format: [TYPE=4][fileIDLen(2)][fileID][fromPeer(4)][shareLen(4)][share]
-func (a *LibP2PAdapter) SendShare(peerID uint32, fileID string, share []byte) error {
-	peerIDStr, exists := a.getLibp2pPeerID(peerID)
-	if !exists {
-		return fmt.Errorf("peer %d not found in mapping", peerID)
+// TrackShareRefresh adds a file this node dealt to the periodic share
+// refresh. It fails, leaving the file out, unless every holder is known,
+// since a refresh needs them all.
+func (a *LibP2PAdapter) TrackShareRefresh(fileID string, holders []uint32, threshold int) error {
+	pids := make([]peer.ID, 0, len(holders))
+	for _, h := range holders {
+		pid := a.node.host.ID()
+		if h != a.node.nodeID {
+			var err error
+			if pid, err = a.resolvePeer(h); err != nil {
+				return err
+			}
+		}
+		pids = append(pids, pid)
 	}
+	a.node.refresh.Track(fileID, pids, threshold)
+	return nil
+}
 
-	pid, err := peer.Decode(peerIDStr)
+// SendShard instructs the peer to store shard bytes for fileHash and waits
+// for the peer to acknowledge receipt with the SHA-256 of what it stored
+func (a *LibP2PAdapter) SendShard(peerID uint32, fileHash string, shardIndex uint32, data []byte) error {
+	if len(data) > maxShardSize {
+		return fmt.Errorf("shard %d is %d bytes, over the %d byte limit", shardIndex, len(data), maxShardSize)
+	}
+	pid, err := a.resolvePeer(peerID)
 	if err != nil {
-		return fmt.Errorf("invalid peer ID: %w", err)
+		return err
 	}
-
-	stream, err := a.node.host.NewStream(a.node.ctx, pid, PangeaRPCProtocol)
+	claim := a.node.access.claimFor(fileHash)
+	if claim == nil && a.node.access.Restricted(fileHash) {
+		return fmt.Errorf("no ownership claim for restricted file %s", fileHash)
+	}
+	var ack []byte
+	err = a.node.sendQueues.Do(a.node.ctx, pid, sendClassShard, func(ctx context.Context) error {
+		rs, err := openRPCStream(ctx, a.node.host, pid)
+		if err != nil {
+			return err
+		}
+		defer rs.Close()
+		rs.pace = a.node.bandwidth.Pacer(ctx, BandwidthBulk)
+		if ack, err = pushShardStream(rs, fileHash, shardIndex, data, claim); err != nil {
+			return fmt.Errorf("no placement ack for shard %d: %w", shardIndex, err)
+		}
+		return nil
+	})
 	if err != nil {
 		return err
 	}
-	defer stream.Close()
-
-	fromPeer := a.node.nodeID
-
-	// Build message: [TYPE=4][fileIDLen(2)][fileID][fromPeer(4)][shareLen(4)][share]
-	msg := make([]byte, 1+2+len(fileID)+4+4+len(share))
-	msg[0] = 4
-	msg[1] = byte(len(fileID) >> 8)
-	msg[2] = byte(len(fileID) & 0xff)
-	off := 3
-	copy(msg[off:off+len(fileID)], []byte(fileID))
-	off += len(fileID)
-	msg[off] = byte(fromPeer >> 24)
-	msg[off+1] = byte(fromPeer >> 16)
-	msg[off+2] = byte(fromPeer >> 8)
-	msg[off+3] = byte(fromPeer)
-	off += 4
-	msg[off] = byte(len(share) >> 24)
-	msg[off+1] = byte(len(share) >> 16)
-	msg[off+2] = byte(len(share) >> 8)
-	msg[off+3] = byte(len(share) & 0xff)
-	off += 4
-	copy(msg[off:], share)
-
-	_, err = stream.Write(msg)
-	return err
+	if len(ack) != 1+sha256.Size {
+		return fmt.Errorf("peer %d sent a malformed ack for shard %d", peerID, shardIndex)
+	}
+	if ack[0] == shardAckQuotaExceeded {
+		return fmt.Errorf("peer %d rejected shard %d: %w", peerID, shardIndex, ErrQuotaExceeded)
+	}
+	if ack[0] == shardAckTooLarge {
+		return fmt.Errorf("peer %d rejected shard %d: exceeds %d bytes", peerID, shardIndex, maxShardSize)
+	}
+	if ack[0] == shardAckCorrupt {
+		return fmt.Errorf("peer %d rejected shard %d: arrived corrupt", peerID, shardIndex)
+	}
+	if ack[0] == shardAckForbidden {
+		return fmt.Errorf("peer %d rejected shard %d: %w", peerID, shardIndex, errAccessDenied)
+	}
+	if ack[0] != shardAckStored {
+		return fmt.Errorf("peer %d rejected shard %d (status %d)", peerID, shardIndex, ack[0])
+	}
+	expected := sha256.Sum256(data)
+	if !bytes.Equal(ack[1:], expected[:]) {
+		a.node.threat.Record(pid, ThreatShardAuditFailure, fmt.Sprintf("shard %d acknowledged with a different hash", shardIndex))
+		return fmt.Errorf("peer %d acknowledged shard %d with mismatched hash", peerID, shardIndex)
+	}
+	if claim != nil {
+		a.node.access.AddHolders(fileHash, []peer.ID{pid})
+	}
+	return nil
 }
 
-// SendShard instructs the peer to store shard bytes for fileHash
-func (a *LibP2PAdapter) SendShard(peerID uint32, fileHash string, shardIndex uint32, data []byte) error {
-	peerIDStr, exists := a.getLibp2pPeerID(peerID)
-	if !exists {
-		return fmt.Errorf("peer %d not found in mapping", peerID)
+// RelayShard leaves a shard with a store-forward relay for a peer that could
+// not be reached directly. The relay delivers it when the peer reconnects.
+func (a *LibP2PAdapter) RelayShard(peerID uint32, fileHash string, shardIndex uint32, data []byte) error {
+	if a.node.access.Restricted(fileHash) {
+		return fmt.Errorf("shard %d of restricted file %s cannot be relayed", shardIndex, fileHash)
 	}
-
-	pid, err := peer.Decode(peerIDStr)
+	pid, err := a.resolvePeer(peerID)
 	if err != nil {
-		return fmt.Errorf("invalid peer ID: %w", err)
+		return err
 	}
+	_, err = a.node.DepositViaRelay(pid, RelayItem{
+		Kind:       RelayItemShard,
+		FileHash:   fileHash,
+		ShardIndex: shardIndex,
+		Payload:    data,
+	})
+	return err
+}
 
-	stream, err := a.node.host.NewStream(a.node.ctx, pid, PangeaRPCProtocol)
+// RelayMessage leaves a message with a store-forward relay for a peer that
+// could not be reached directly
+func (a *LibP2PAdapter) RelayMessage(peerID uint32, data []byte) error {
+	pid, err := a.resolvePeer(peerID)
 	if err != nil {
 		return err
 	}
-	defer stream.Close()
-
-	// Message: [TYPE=3][fileHashLen(2)][fileHash][shardIndex(4)][data]
-	msg := make([]byte, 1+2+len(fileHash)+4+len(data))
-	msg[0] = 3
-	msg[1] = byte(len(fileHash) >> 8)
-	msg[2] = byte(len(fileHash) & 0xff)
-	off := 3
-	copy(msg[off:off+len(fileHash)], []byte(fileHash))
-	off += len(fileHash)
-	msg[off] = byte(shardIndex >> 24)
-	msg[off+1] = byte(shardIndex >> 16)
-	msg[off+2] = byte(shardIndex >> 8)
-	msg[off+3] = byte(shardIndex & 0xff)
-	off += 4
-	copy(msg[off:], data)
-
-	_, err = stream.Write(msg)
+	_, err = a.node.DepositViaRelay(pid, RelayItem{Kind: RelayItemMessage, Payload: data})
 	return err
 }
 
+// resolvePeer maps a uint32 peer ID to its libp2p peer ID
+func (a *LibP2PAdapter) resolvePeer(peerID uint32) (peer.ID, error) {
+	peerIDStr, exists := a.getLibp2pPeerID(peerID)
+	if !exists {
+		return "", fmt.Errorf("peer %d not found in mapping", peerID)
+	}
+	pid, err := peer.Decode(peerIDStr)
+	if err != nil {
+		return "", fmt.Errorf("invalid peer ID: %w", err)
+	}
+	return pid, nil
+}
+
 func (a *LibP2PAdapter) GetConnectedPeers() []uint32 {
 	peers := a.node.GetConnectedPeers()
 	// Use proper bidirectional mapping
@@ -237,7 +375,12 @@ func (a *LibP2PAdapter) GetConnectedPeers() []uint32 {
 }
 
 func (a *LibP2PAdapter) GetConnectionQuality(peerID uint32) (latencyMs, jitterMs, packetLoss float32, err error) {
-	// Get quality metrics from node store
+	// Prefer live measurements from the quality prober
+	if q, ok := a.GetPeerQuality(peerID); ok {
+		return q.LatencyMs, q.JitterMs, q.PacketLoss, nil
+	}
+
+	// Fall back to metrics from node store
 	node, exists := a.store.GetNode(peerID)
 	if !exists {
 		node, exists = a.store.GetNode(a.node.nodeID)
@@ -249,79 +392,144 @@ func (a *LibP2PAdapter) GetConnectionQuality(peerID uint32) (latencyMs, jitterMs
 	return node.LatencyMs, node.JitterMs, node.PacketLoss, nil
 }
 
-func (a *LibP2PAdapter) FetchShard(peerID uint32, shardIndex uint32) ([]byte, error) {
-	// Look up the libp2p peer ID
+// GetPeerQuality returns probed connection quality for a peer
+func (a *LibP2PAdapter) GetPeerQuality(peerID uint32) (PeerQuality, bool) {
 	peerIDStr, exists := a.getLibp2pPeerID(peerID)
 	if !exists {
-		return nil, fmt.Errorf("peer %d not found in mapping", peerID)
+		return PeerQuality{}, false
 	}
-
 	pid, err := peer.Decode(peerIDStr)
 	if err != nil {
-		return nil, fmt.Errorf("invalid peer ID: %w", err)
+		return PeerQuality{}, false
 	}
+	return a.node.GetPeerQuality(pid)
+}
 
-	stream, err := a.node.host.NewStream(a.node.ctx, pid, PangeaRPCProtocol)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open stream: %w", err)
+// GetPeerProtocolStats returns protocol usage per category for each peer.
+// peerID 0 returns every peer with recorded usage.
+func (a *LibP2PAdapter) GetPeerProtocolStats(peerID uint32) (map[uint32]map[string]ProtocolUsage, error) {
+	stats := a.node.GetProtocolStats()
+	result := make(map[uint32]map[string]ProtocolUsage)
+
+	if peerID != 0 {
+		peerIDStr, exists := a.getLibp2pPeerID(peerID)
+		if !exists {
+			return nil, fmt.Errorf("peer %d not found in mapping", peerID)
+		}
+		pid, err := peer.Decode(peerIDStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid peer ID: %w", err)
+		}
+		result[peerID] = stats.ForPeer(pid)
+		return result, nil
 	}
-	defer stream.Close()
 
-	// Send shard request: [TYPE=1][fileHashLen(2)][fileHash][shardIndex(4)]
-	// For backwards compatibility, support short form where caller only provided shardIndex earlier
-	// However our callers will send full request via Stream write directly.
-	// Here we assume caller builds it; keep the simple legacy support for tests where stream expects full message.
+	for _, p := range stats.Peers() {
+		result[a.getPeerUint32ID(p.String())] = stats.ForPeer(p)
+	}
+	return result, nil
+}
 
-	// Read response
-	buffer := make([]byte, 1024*1024) // 1MB max shard size
-	n, err := stream.Read(buffer)
+// GetPeerVersions returns the advertised software version of each tracked peer
+func (a *LibP2PAdapter) GetPeerVersions() map[uint32]PeerVersionInfo {
+	infos := a.node.GetVersionTracker().Snapshot()
+	result := make(map[uint32]PeerVersionInfo, len(infos))
+	for _, info := range infos {
+		result[a.getPeerUint32ID(info.PeerID.String())] = info
+	}
+	return result
+}
+
+// GetPeerCapacity returns the compute/storage capacity a peer advertised
+func (a *LibP2PAdapter) GetPeerCapacity(peerID uint32) (compute.ComputeCapacity, bool) {
+	cp := a.node.GetComputeProtocol()
+	if cp == nil {
+		return compute.ComputeCapacity{}, false
+	}
+	peerIDStr, exists := a.getLibp2pPeerID(peerID)
+	if !exists {
+		return compute.ComputeCapacity{}, false
+	}
+	pid, err := peer.Decode(peerIDStr)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return compute.ComputeCapacity{}, false
 	}
+	return cp.GetWorkerCapacity(pid)
+}
 
-	return buffer[:n], nil
+// GetBandwidthTotals returns aggregate host throughput across all peers
+func (a *LibP2PAdapter) GetBandwidthTotals() metrics.Stats {
+	return a.node.GetBandwidthTotals()
 }
 
-// FetchShare requests a DKG share for fileID from the peer
-func (a *LibP2PAdapter) FetchShare(peerID uint32, fileID string) ([]byte, error) {
+// GetPeerBandwidth returns measured throughput for a single peer
+func (a *LibP2PAdapter) GetPeerBandwidth(peerID uint32) (metrics.Stats, error) {
 	peerIDStr, exists := a.getLibp2pPeerID(peerID)
 	if !exists {
-		return nil, fmt.Errorf("peer %d not found in mapping", peerID)
+		return metrics.Stats{}, fmt.Errorf("peer %d not found in mapping", peerID)
 	}
 
 	pid, err := peer.Decode(peerIDStr)
 	if err != nil {
-		return nil, fmt.Errorf("invalid peer ID: %w", err)
+		return metrics.Stats{}, fmt.Errorf("invalid peer ID: %w", err)
 	}
 
-	stream, err := a.node.host.NewStream(a.node.ctx, pid, PangeaRPCProtocol)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open stream: %w", err)
+	return a.node.GetBandwidthForPeer(pid), nil
+}
+
+// GetProtocolBandwidth returns measured throughput keyed by protocol ID
+func (a *LibP2PAdapter) GetProtocolBandwidth() map[string]metrics.Stats {
+	byProto := a.node.GetBandwidthByProtocol()
+	result := make(map[string]metrics.Stats, len(byProto))
+	for proto, stats := range byProto {
+		result[string(proto)] = stats
 	}
-	defer stream.Close()
+	return result
+}
 
-	// Request: [TYPE=2][fileIDLen(2)][fileID]
-	req := make([]byte, 1+2+len(fileID))
-	req[0] = 2
-	req[1] = byte(len(fileID) >> 8)
-	req[2] = byte(len(fileID) & 0xff)
-	copy(req[3:], []byte(fileID))
+// FetchShard requests a stored shard of fileHash from the peer. The stream
+// is reset if ctx ends before the shard arrives.
+func (a *LibP2PAdapter) FetchShard(ctx context.Context, peerID uint32, fileHash string, shardIndex uint32) ([]byte, error) {
+	if err := a.node.faults.DropShardFetch(peerID, fileHash, shardIndex); err != nil {
+		return nil, err
+	}
+	rs, _, err := a.openRPC(peerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Close()
+	stop := context.AfterFunc(ctx, func() { rs.Reset() })
+	defer stop()
 
-	if _, err := stream.Write(req); err != nil {
-		return nil, fmt.Errorf("failed to write request: %w", err)
+	data, err := fetchShardStream(rs, fileHash, shardIndex, a.node.access.grantFor(fileHash))
+	if err != nil && ctx.Err() != nil {
+		return nil, ctx.Err()
 	}
+	return data, err
+}
 
-	// Read response
-	buf := make([]byte, 4096)
-	n, err := stream.Read(buf)
+// DeleteShards has the peer securely delete the shards and DKG share of
+// fileHash it holds, returning the number of shards deleted. Only the peer
+// that stored them may do so; this node's own copies are deleted locally.
+func (a *LibP2PAdapter) DeleteShards(ctx context.Context, peerID uint32, fileHash string) (int, error) {
+	if peerID == a.node.nodeID {
+		return a.node.DeleteFileData(fileHash), nil
+	}
+	pid, err := a.resolvePeer(peerID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return 0, err
 	}
+	return a.node.deleteRemoteFileData(ctx, pid, fileHash)
+}
 
-	if n == 0 {
-		return nil, fmt.Errorf("no share returned")
+// FetchShare requests a DKG share for fileID from the peer
+func (a *LibP2PAdapter) FetchShare(peerID uint32, fileID string) ([]byte, error) {
+	rs, _, err := a.openRPC(peerID)
+	if err != nil {
+		return nil, err
 	}
-	return buf[:n], nil
+	defer rs.Close()
+	return rs.call(rpcMsgFetchShare, appendRPCString(nil, fileID), rpcMsgShareData)
 }
 
 func (a *LegacyP2PAdapter) DisconnectPeer(peerID uint32) error {
@@ -338,32 +546,24 @@ func (a *LegacyP2PAdapter) DisconnectPeer(peerID uint32) error {
 	return nil
 }
 
-func (a *LegacyP2PAdapter) SendMessage(peerID uint32, data []byte) error {
+// connection returns the live connection to a peer
+func (a *LegacyP2PAdapter) connection(peerID uint32) (*P2PConnection, error) {
 	a.node.mu.RLock()
 	conn, exists := a.node.connections[peerID]
 	a.node.mu.RUnlock()
 
 	if !exists {
-		return ErrPeerNotConnected
+		return nil, ErrPeerNotConnected
 	}
+	return conn, nil
+}
 
-	conn.mu.Lock()
-	defer conn.mu.Unlock()
-
-	// Encrypt message if cipher state exists
-	var encryptedMsg []byte
-	var err error
-	if conn.cipherState != nil {
-		encryptedMsg, err = conn.cipherState.Encrypt(nil, nil, data)
-		if err != nil {
-			return err
-		}
-	} else {
-		encryptedMsg = data
+func (a *LegacyP2PAdapter) SendMessage(peerID uint32, data []byte) error {
+	conn, err := a.connection(peerID)
+	if err != nil {
+		return err
 	}
-
-	_, err = conn.conn.Write(encryptedMsg)
-	return err
+	return conn.send(legacyMsgData, 0, data)
 }
 
 func (a *LegacyP2PAdapter) GetConnectedPeers() []uint32 {
@@ -378,6 +578,11 @@ func (a *LegacyP2PAdapter) GetConnectedPeers() []uint32 {
 }
 
 func (a *LegacyP2PAdapter) GetConnectionQuality(peerID uint32) (latencyMs, jitterMs, packetLoss float32, err error) {
+	// Prefer the node's own ping statistics
+	if q, ok := a.node.GetPeerQuality(peerID); ok {
+		return q.LatencyMs, q.JitterMs, q.PacketLoss, nil
+	}
+
 	node, exists := a.store.GetNode(peerID)
 	if !exists {
 		return 0, 0, 0, nil
@@ -386,171 +591,67 @@ func (a *LegacyP2PAdapter) GetConnectionQuality(peerID uint32) (latencyMs, jitte
 	return node.LatencyMs, node.JitterMs, node.PacketLoss, nil
 }
 
-func (a *LegacyP2PAdapter) FetchShard(peerID uint32, shardIndex uint32) ([]byte, error) {
-	a.node.mu.RLock()
-	conn, exists := a.node.connections[peerID]
-	a.node.mu.RUnlock()
-
-	if !exists {
-		return nil, ErrPeerNotConnected
+// FetchShard requests a stored shard of fileHash from the peer
+func (a *LegacyP2PAdapter) FetchShard(ctx context.Context, peerID uint32, fileHash string, shardIndex uint32) ([]byte, error) {
+	conn, err := a.connection(peerID)
+	if err != nil {
+		return nil, err
 	}
+	req := binary.BigEndian.AppendUint32(appendRPCString(nil, fileHash), shardIndex)
+	return conn.callContext(ctx, legacyMsgFetchShard, req, legacyMsgShardData, legacyReplyTimeout)
+}
 
-	conn.mu.Lock()
-	defer conn.mu.Unlock()
-
-	// Send shard request: [REQUEST_TYPE=1][fileHashLen(2)][fileHash][shardIndex(4)]
-	request := make([]byte, 5)
-	request[0] = 1 // Request type: fetch shard
-	request[1] = byte(shardIndex >> 24)
-	request[2] = byte(shardIndex >> 16)
-	request[3] = byte(shardIndex >> 8)
-	request[4] = byte(shardIndex)
-
-	// Encrypt if we have cipher state
-	var toSend []byte
-	var err error
-	if conn.cipherState != nil {
-		toSend, err = conn.cipherState.Encrypt(nil, nil, request)
-		if err != nil {
-			return nil, err
-		}
-	} else {
-		toSend = request
+// SendShard instructs the peer to store shard bytes for fileHash and waits
+// for the peer to acknowledge receipt with the SHA-256 of what it stored
+func (a *LegacyP2PAdapter) SendShard(peerID uint32, fileHash string, shardIndex uint32, data []byte) error {
+	if len(data) > maxShardSize {
+		return fmt.Errorf("shard %d is %d bytes, over the %d byte limit", shardIndex, len(data), maxShardSize)
 	}
-
-	_, err = conn.conn.Write(toSend)
+	conn, err := a.connection(peerID)
 	if err != nil {
-		return nil, err
+		return err
 	}
-
-	// Read response
-	buffer := make([]byte, 1024*1024) // 1MB max
-	n, err := conn.conn.Read(buffer)
+	// The legacy transport sends a shard as one message, so it is paced
+	// as a whole before it goes out
+	if err := a.bandwidth.Wait(a.node.ctx, BandwidthBulk, len(data)); err != nil {
+		return err
+	}
+	req := binary.BigEndian.AppendUint32(appendRPCString(nil, fileHash), shardIndex)
+	ack, err := conn.call(legacyMsgStoreShard, append(req, data...), legacyMsgStoreShardAck, shardAckTimeout)
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("no placement ack for shard %d: %w", shardIndex, err)
 	}
-
-	// Decrypt if needed
-	var response []byte
-	if conn.cipherState != nil {
-		response, err = conn.cipherState.Decrypt(nil, nil, buffer[:n])
-		if err != nil {
-			return nil, err
-		}
-	} else {
-		response = buffer[:n]
+	if len(ack) != 1+sha256.Size {
+		return fmt.Errorf("peer %d sent a malformed ack for shard %d", peerID, shardIndex)
 	}
-
-	return response, nil
+	if ack[0] != shardAckStored {
+		return fmt.Errorf("peer %d rejected shard %d (status %d)", peerID, shardIndex, ack[0])
+	}
+	expected := sha256.Sum256(data)
+	if !bytes.Equal(ack[1:], expected[:]) {
+		return fmt.Errorf("peer %d acknowledged shard %d with mismatched hash", peerID, shardIndex)
+	}
+	return nil
 }
 
 // FetchShare requests a DKG share for fileID from the peer
 func (a *LegacyP2PAdapter) FetchShare(peerID uint32, fileID string) ([]byte, error) {
-	a.node.mu.RLock()
-	conn, exists := a.node.connections[peerID]
-	a.node.mu.RUnlock()
-
-	if !exists {
-		return nil, ErrPeerNotConnected
-	}
-
-	conn.mu.Lock()
-	defer conn.mu.Unlock()
-
-	// Request: [TYPE=2][fileIDLen(2)][fileID]
-	req := make([]byte, 1+2+len(fileID))
-	req[0] = 2
-	req[1] = byte(len(fileID) >> 8)
-	req[2] = byte(len(fileID) & 0xff)
-	copy(req[3:], []byte(fileID))
-
-	var toSend []byte
-	var err error
-	if conn.cipherState != nil {
-		toSend, err = conn.cipherState.Encrypt(nil, nil, req)
-		if err != nil {
-			return nil, err
-		}
-	} else {
-		toSend = req
-	}
-
-	_, err = conn.conn.Write(toSend)
+	conn, err := a.connection(peerID)
 	if err != nil {
 		return nil, err
 	}
-
-	// Read response
-	buf := make([]byte, 4096)
-	n, err := conn.conn.Read(buf)
-	if err != nil {
-		return nil, err
-	}
-
-	if n == 0 {
-		return nil, fmt.Errorf("no share returned")
-	}
-	// Decrypt if needed
-	var response []byte
-	if conn.cipherState != nil {
-		response, err = conn.cipherState.Decrypt(nil, nil, buf[:n])
-		if err != nil {
-			return nil, err
-		}
-	} else {
-		response = buf[:n]
-	}
-
-	return response, nil
+	return conn.call(legacyMsgFetchShare, appendRPCString(nil, fileID), legacyMsgShareData, legacyReplyTimeout)
 }
 
-// SendShare sends a DKG share to the peer (legacy path)
-// Message format: [TYPE=4][fileIDLen(2)][fileID][fromPeer(4)][shareLen(4)][share]
+// SendShare sends a DKG share to the peer for the given fileID and waits
+// for it to be stored
 func (a *LegacyP2PAdapter) SendShare(peerID uint32, fileID string, share []byte) error {
-	a.node.mu.RLock()
-	conn, exists := a.node.connections[peerID]
-	a.node.mu.RUnlock()
-
-	if !exists {
-		return ErrPeerNotConnected
-	}
-
-	conn.mu.Lock()
-	defer conn.mu.Unlock()
-
-	fromPeer := a.node.id
-
-	// Message: [TYPE=4][fileIDLen(2)][fileID][fromPeer(4)][shareLen(4)][share]
-	msg := make([]byte, 1+2+len(fileID)+4+4+len(share))
-	msg[0] = 4
-	msg[1] = byte(len(fileID) >> 8)
-	msg[2] = byte(len(fileID) & 0xff)
-	off := 3
-	copy(msg[off:off+len(fileID)], []byte(fileID))
-	off += len(fileID)
-	msg[off] = byte(fromPeer >> 24)
-	msg[off+1] = byte(fromPeer >> 16)
-	msg[off+2] = byte(fromPeer >> 8)
-	msg[off+3] = byte(fromPeer)
-	off += 4
-	msg[off+1] = byte(len(share) >> 16)
-	msg[off+2] = byte(len(share) >> 8)
-	msg[off+3] = byte(len(share) & 0xff)
-	off += 4
-	copy(msg[off:], share)
-
-	var toSend []byte
-	var err error
-	if conn.cipherState != nil {
-		toSend, err = conn.cipherState.Encrypt(nil, nil, msg)
-		if err != nil {
-			return err
-		}
-	} else {
-		toSend = msg
+	conn, err := a.connection(peerID)
+	if err != nil {
+		return err
 	}
-
-	_, err = conn.conn.Write(toSend)
+	req := binary.BigEndian.AppendUint32(appendRPCString(nil, fileID), a.node.id)
+	_, err = conn.call(legacyMsgStoreShare, append(req, share...), legacyMsgStoreShareAck, legacyReplyTimeout)
 	return err
 }
 
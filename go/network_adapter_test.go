@@ -0,0 +1,61 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"path/filepath"
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func newTestPeerID(t *testing.T) peer.ID {
+	t.Helper()
+	_, pub, err := crypto.GenerateEd25519Key(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := peer.IDFromPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestStablePeerIDs(t *testing.T) {
+	a, b := newTestPeerID(t), newTestPeerID(t)
+	sum := sha256.Sum256([]byte(a.String()))
+	hashed := binary.BigEndian.Uint32(sum[:4])
+
+	// IDs do not depend on connection order
+	first := NewLibP2PAdapter(nil, nil)
+	idA, idB := first.PeerUint32ID(a), first.PeerUint32ID(b)
+	second := NewLibP2PAdapter(nil, nil)
+	if second.PeerUint32ID(b) != idB || second.PeerUint32ID(a) != idA || idA != hashed {
+		t.Fatalf("IDs changed with connection order")
+	}
+
+	// A collision moves the later peer to the next free ID, and the
+	// mapping file keeps it there after a restart
+	path := filepath.Join(t.TempDir(), "peer_ids.json")
+	collided := NewLibP2PAdapter(nil, nil)
+	if err := collided.SetPeerIDPath(path); err != nil {
+		t.Fatal(err)
+	}
+	collided.peerIDToUint32[b.String()] = hashed
+	collided.uint32ToPeerID[hashed] = b.String()
+	if id := collided.PeerUint32ID(a); id != hashed+1 {
+		t.Fatalf("expected collided peer at %d, got %d", hashed+1, id)
+	}
+	restarted := NewLibP2PAdapter(nil, nil)
+	if err := restarted.SetPeerIDPath(path); err != nil {
+		t.Fatal(err)
+	}
+	if id := restarted.PeerUint32ID(a); id != hashed+1 {
+		t.Fatalf("expected %d after restart, got %d", hashed+1, id)
+	}
+	if pid, err := restarted.resolvePeer(hashed + 1); err != nil || pid != a {
+		t.Fatalf("resolvePeer = %s, %v", pid, err)
+	}
+}
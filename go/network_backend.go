@@ -0,0 +1,460 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+)
+
+// Network backend names, as used in config and over RPC
+const (
+	BackendLibP2P = "libp2p"
+	BackendLegacy = "legacy"
+)
+
+// backendOrder is the order backends are consulted in after the primary
+var backendOrder = []string{BackendLibP2P, BackendLegacy}
+
+// ErrNoBackend means no enabled backend can carry a call
+var ErrNoBackend = errors.New("no network backend enabled")
+
+// DataTransport is what shard and DKG share placement need beyond
+// NetworkAdapter. Backends provide it, and so does the switch routing
+// between them.
+type DataTransport interface {
+	NetworkAdapter
+
+	// SendShard stores a shard on a peer and waits for its ack
+	SendShard(peerID uint32, fileHash string, shardIndex uint32, data []byte) error
+
+	// SendShare stores a DKG share on a peer
+	SendShare(peerID uint32, fileID string, share []byte) error
+
+	// StoreLocalShare keeps this node's DKG share of a file, so that
+	// peers can fetch it
+	StoreLocalShare(fileID string, fromPeer uint32, share []byte)
+
+	// GetLocalShare returns this node's DKG share of a file
+	GetLocalShare(fileID string) ([]byte, bool)
+}
+
+// NetworkBackend is one transport a node runs. libp2p and the legacy
+// Noise/TCP transport both implement it, so a node can run either or both.
+type NetworkBackend interface {
+	DataTransport
+
+	// Name returns BackendLibP2P or BackendLegacy
+	Name() string
+
+	// ListenAddr returns where the backend accepts connections
+	ListenAddr() string
+
+	// Close stops the backend and drops its connections
+	Close() error
+}
+
+// BackendFactory starts a backend listening on listenAddr
+type BackendFactory func(listenAddr string) (NetworkBackend, error)
+
+// BackendStatus describes one backend known to a NetworkSwitch
+type BackendStatus struct {
+	Name       string
+	Enabled    bool
+	Primary    bool
+	Switchable bool // Whether it can be enabled and disabled at runtime
+	ListenAddr string
+	PeerCount  int
+}
+
+// NetworkSwitch is a NetworkAdapter over the enabled backends. A call for
+// a peer goes to the backend the peer is connected on, and otherwise to the
+// primary backend. Backends with a factory can be enabled and disabled at
+// runtime, so a libp2p node can open a legacy listener for old peers.
+type NetworkSwitch struct {
+	backends  map[string]NetworkBackend
+	factories map[string]BackendFactory
+	primary   string
+	mu        sync.RWMutex
+}
+
+// NewNetworkSwitch creates a switch with no backends
+func NewNetworkSwitch() *NetworkSwitch {
+	return &NetworkSwitch{
+		backends:  make(map[string]NetworkBackend),
+		factories: make(map[string]BackendFactory),
+	}
+}
+
+// Register makes a backend switchable at runtime by giving its factory
+func (s *NetworkSwitch) Register(name string, factory BackendFactory) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.factories[name] = factory
+}
+
+// Add enables a backend that is already running. The first backend added
+// becomes the primary.
+func (s *NetworkSwitch) Add(b NetworkBackend) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.backends[b.Name()]; exists {
+		return fmt.Errorf("backend %s is already enabled", b.Name())
+	}
+	s.backends[b.Name()] = b
+	if s.primary == "" {
+		s.primary = b.Name()
+	}
+	return nil
+}
+
+// Enable starts a registered backend on listenAddr
+func (s *NetworkSwitch) Enable(name, listenAddr string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.backends[name]; exists {
+		return fmt.Errorf("backend %s is already enabled", name)
+	}
+	factory, ok := s.factories[name]
+	if !ok {
+		return fmt.Errorf("backend %s cannot be enabled at runtime", name)
+	}
+	b, err := factory(listenAddr)
+	if err != nil {
+		return fmt.Errorf("failed to start %s backend: %w", name, err)
+	}
+	s.backends[name] = b
+	if s.primary == "" {
+		s.primary = name
+	}
+	log.Printf("🔀 Enabled %s backend on %s", name, b.ListenAddr())
+	return nil
+}
+
+// Disable stops a registered backend. If it was the primary, the next
+// enabled backend takes over.
+func (s *NetworkSwitch) Disable(name string) error {
+	s.mu.Lock()
+	b, exists := s.backends[name]
+	if !exists {
+		s.mu.Unlock()
+		return fmt.Errorf("backend %s is not enabled", name)
+	}
+	if _, ok := s.factories[name]; !ok {
+		s.mu.Unlock()
+		return fmt.Errorf("backend %s cannot be restarted, so it stays enabled", name)
+	}
+	delete(s.backends, name)
+	if s.primary == name {
+		s.primary = ""
+		for _, other := range backendOrder {
+			if _, ok := s.backends[other]; ok {
+				s.primary = other
+				break
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	log.Printf("🔀 Disabled %s backend", name)
+	return b.Close()
+}
+
+// SetPrimary picks the backend used for peers not connected on any
+func (s *NetworkSwitch) SetPrimary(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.backends[name]; !exists {
+		return fmt.Errorf("backend %s is not enabled", name)
+	}
+	s.primary = name
+	return nil
+}
+
+// Backend returns an enabled backend by name
+func (s *NetworkSwitch) Backend(name string) (NetworkBackend, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	b, ok := s.backends[name]
+	return b, ok
+}
+
+// Status describes every enabled or switchable backend
+func (s *NetworkSwitch) Status() []BackendStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	statuses := make([]BackendStatus, 0, len(backendOrder))
+	for _, name := range backendOrder {
+		b, enabled := s.backends[name]
+		_, switchable := s.factories[name]
+		if !enabled && !switchable {
+			continue
+		}
+		status := BackendStatus{Name: name, Enabled: enabled, Primary: name == s.primary, Switchable: switchable}
+		if enabled {
+			status.ListenAddr = b.ListenAddr()
+			status.PeerCount = len(b.GetConnectedPeers())
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// enabled returns the enabled backends, primary first
+func (s *NetworkSwitch) enabled() []NetworkBackend {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	backends := make([]NetworkBackend, 0, len(s.backends))
+	if b, ok := s.backends[s.primary]; ok {
+		backends = append(backends, b)
+	}
+	for _, name := range backendOrder {
+		if b, ok := s.backends[name]; ok && name != s.primary {
+			backends = append(backends, b)
+		}
+	}
+	return backends
+}
+
+// route picks the backend a peer is connected on, else the primary
+func (s *NetworkSwitch) route(peerID uint32) (NetworkBackend, error) {
+	backends := s.enabled()
+	if len(backends) == 0 {
+		return nil, ErrNoBackend
+	}
+	for _, b := range backends {
+		for _, id := range b.GetConnectedPeers() {
+			if id == peerID {
+				return b, nil
+			}
+		}
+	}
+	return backends[0], nil
+}
+
+// ConnectToPeer dials multiaddrs over libp2p and host:port addresses over
+// the legacy transport
+func (s *NetworkSwitch) ConnectToPeer(peerAddr string, peerID uint32) error {
+	name := BackendLegacy
+	if strings.HasPrefix(peerAddr, "/") {
+		name = BackendLibP2P
+	}
+	b, ok := s.Backend(name)
+	if !ok {
+		return fmt.Errorf("cannot dial %s: %s backend is not enabled", peerAddr, name)
+	}
+	return b.ConnectToPeer(peerAddr, peerID)
+}
+
+// DisconnectPeer disconnects the peer on every backend
+func (s *NetworkSwitch) DisconnectPeer(peerID uint32) error {
+	var errs []error
+	for _, b := range s.enabled() {
+		if err := b.DisconnectPeer(peerID); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *NetworkSwitch) SendMessage(peerID uint32, data []byte) error {
+	b, err := s.route(peerID)
+	if err != nil {
+		return err
+	}
+	return b.SendMessage(peerID, data)
+}
+
+func (s *NetworkSwitch) FetchShard(ctx context.Context, peerID uint32, fileHash string, shardIndex uint32) ([]byte, error) {
+	b, err := s.route(peerID)
+	if err != nil {
+		return nil, err
+	}
+	return b.FetchShard(ctx, peerID, fileHash, shardIndex)
+}
+
+func (s *NetworkSwitch) FetchShare(peerID uint32, fileID string) ([]byte, error) {
+	b, err := s.route(peerID)
+	if err != nil {
+		return nil, err
+	}
+	return b.FetchShare(peerID, fileID)
+}
+
+func (s *NetworkSwitch) SendShard(peerID uint32, fileHash string, shardIndex uint32, data []byte) error {
+	b, err := s.route(peerID)
+	if err != nil {
+		return err
+	}
+	return b.SendShard(peerID, fileHash, shardIndex, data)
+}
+
+func (s *NetworkSwitch) SendShare(peerID uint32, fileID string, share []byte) error {
+	b, err := s.route(peerID)
+	if err != nil {
+		return err
+	}
+	return b.SendShare(peerID, fileID, share)
+}
+
+// StoreLocalShare keeps the share on every backend, so peers on either
+// transport can fetch it
+func (s *NetworkSwitch) StoreLocalShare(fileID string, fromPeer uint32, share []byte) {
+	for _, b := range s.enabled() {
+		b.StoreLocalShare(fileID, fromPeer, share)
+	}
+}
+
+func (s *NetworkSwitch) GetLocalShare(fileID string) ([]byte, bool) {
+	for _, b := range s.enabled() {
+		if share, ok := b.GetLocalShare(fileID); ok {
+			return share, true
+		}
+	}
+	return nil, false
+}
+
+// GetConnectedPeers returns the peers connected on any backend
+func (s *NetworkSwitch) GetConnectedPeers() []uint32 {
+	seen := make(map[uint32]bool)
+	var peers []uint32
+	for _, b := range s.enabled() {
+		for _, id := range b.GetConnectedPeers() {
+			if !seen[id] {
+				seen[id] = true
+				peers = append(peers, id)
+			}
+		}
+	}
+	return peers
+}
+
+func (s *NetworkSwitch) GetConnectionQuality(peerID uint32) (latencyMs, jitterMs, packetLoss float32, err error) {
+	b, err := s.route(peerID)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	return b.GetConnectionQuality(peerID)
+}
+
+// Close stops every backend
+func (s *NetworkSwitch) Close() error {
+	s.mu.Lock()
+	backends := s.backends
+	s.backends = make(map[string]NetworkBackend)
+	s.primary = ""
+	s.mu.Unlock()
+
+	var errs []error
+	for _, b := range backends {
+		if err := b.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// libp2pOf returns the libp2p adapter behind a network adapter, if enabled
+func libp2pOf(network NetworkAdapter) (*LibP2PAdapter, bool) {
+	switch n := network.(type) {
+	case *LibP2PAdapter:
+		return n, true
+	case *NetworkSwitch:
+		if b, ok := n.Backend(BackendLibP2P); ok {
+			lib, ok := b.(*LibP2PAdapter)
+			return lib, ok
+		}
+	}
+	return nil, false
+}
+
+// NewLegacyBackendFactory starts legacy P2P nodes for a NetworkSwitch,
+// pacing their shard uploads with bandwidth (nil = unlimited)
+func NewLegacyBackendFactory(nodeID uint32, store *NodeStore, bandwidth *BandwidthScheduler) BackendFactory {
+	return func(listenAddr string) (NetworkBackend, error) {
+		node, err := NewP2PNode(nodeID, store)
+		if err != nil {
+			return nil, err
+		}
+		if err := node.Start(listenAddr); err != nil {
+			return nil, err
+		}
+		adapter := NewLegacyP2PAdapter(node, store)
+		adapter.bandwidth = bandwidth
+		return adapter, nil
+	}
+}
+
+// bandwidthOf returns the bandwidth scheduler of a network adapter's
+// transports, or nil if it has none
+func bandwidthOf(network NetworkAdapter) *BandwidthScheduler {
+	if lib, ok := libp2pOf(network); ok && lib.node != nil {
+		return lib.node.bandwidth
+	}
+	switch n := network.(type) {
+	case *LegacyP2PAdapter:
+		return n.bandwidth
+	case *NetworkSwitch:
+		if b, ok := n.Backend(BackendLegacy); ok {
+			if legacy, ok := b.(*LegacyP2PAdapter); ok {
+				return legacy.bandwidth
+			}
+		}
+	}
+	return nil
+}
+
+// Backend identity for the libp2p adapter
+
+func (a *LibP2PAdapter) Name() string {
+	return BackendLibP2P
+}
+
+// ListenAddr returns the first listen multiaddr
+func (a *LibP2PAdapter) ListenAddr() string {
+	if addrs := a.node.LocalMultiaddrs(true); len(addrs) > 0 {
+		return addrs[0]
+	}
+	return ""
+}
+
+func (a *LibP2PAdapter) Close() error {
+	return a.node.Stop()
+}
+
+func (a *LibP2PAdapter) StoreLocalShare(fileID string, fromPeer uint32, share []byte) {
+	a.node.StoreDKGShare(fileID, fromPeer, share)
+}
+
+func (a *LibP2PAdapter) GetLocalShare(fileID string) ([]byte, bool) {
+	return a.node.GetLocalShare(fileID)
+}
+
+// Backend identity for the legacy adapter
+
+func (a *LegacyP2PAdapter) Name() string {
+	return BackendLegacy
+}
+
+func (a *LegacyP2PAdapter) ListenAddr() string {
+	if a.node.listener == nil {
+		return ""
+	}
+	return a.node.listener.Addr().String()
+}
+
+func (a *LegacyP2PAdapter) Close() error {
+	a.node.Stop()
+	return nil
+}
+
+// StoreLocalShare keeps the share; the legacy store has no dealer field
+func (a *LegacyP2PAdapter) StoreLocalShare(fileID string, fromPeer uint32, share []byte) {
+	a.node.StoreDKGShare(fileID, share)
+}
+
+func (a *LegacyP2PAdapter) GetLocalShare(fileID string) ([]byte, bool) {
+	return a.node.GetLocalShare(fileID)
+}
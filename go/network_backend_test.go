@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// stubBackend stands in for libp2p: it reports a fixed peer set and
+// records which peers it was asked to send to
+type stubBackend struct {
+	name   string
+	peers  []uint32
+	sent   []uint32
+	shares map[string][]byte
+	closed bool
+}
+
+func (b *stubBackend) Name() string                               { return b.name }
+func (b *stubBackend) ListenAddr() string                         { return "/ip4/127.0.0.1/tcp/7777" }
+func (b *stubBackend) Close() error                               { b.closed = true; return nil }
+func (b *stubBackend) ConnectToPeer(addr string, id uint32) error { return nil }
+func (b *stubBackend) DisconnectPeer(id uint32) error             { return nil }
+func (b *stubBackend) GetConnectedPeers() []uint32                { return b.peers }
+func (b *stubBackend) SendMessage(id uint32, data []byte) error {
+	b.sent = append(b.sent, id)
+	return nil
+}
+func (b *stubBackend) FetchShard(ctx context.Context, id uint32, fileHash string, shardIndex uint32) ([]byte, error) {
+	return nil, errors.New("not found")
+}
+func (b *stubBackend) FetchShare(id uint32, fileID string) ([]byte, error) {
+	return nil, errors.New("not found")
+}
+func (b *stubBackend) SendShard(id uint32, fileHash string, shardIndex uint32, data []byte) error {
+	b.sent = append(b.sent, id)
+	return nil
+}
+func (b *stubBackend) SendShare(id uint32, fileID string, share []byte) error {
+	b.sent = append(b.sent, id)
+	return nil
+}
+func (b *stubBackend) StoreLocalShare(fileID string, fromPeer uint32, share []byte) {
+	b.shares[fileID] = share
+}
+func (b *stubBackend) GetLocalShare(fileID string) ([]byte, bool) {
+	share, ok := b.shares[fileID]
+	return share, ok
+}
+func (b *stubBackend) GetConnectionQuality(id uint32) (float32, float32, float32, error) {
+	return 0, 0, 0, nil
+}
+
+func TestNetworkSwitchRoutesByBackend(t *testing.T) {
+	// A legacy peer to reach over the switch's legacy listener
+	old, err := NewP2PNode(1, NewNodeStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := old.Start("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(old.Stop)
+
+	store := NewNodeStore()
+	lib := &stubBackend{name: BackendLibP2P, peers: []uint32{7}, shares: make(map[string][]byte)}
+	sw := NewNetworkSwitch()
+	sw.Register(BackendLegacy, NewLegacyBackendFactory(2, store, nil))
+	if err := sw.Add(lib); err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.Enable(BackendLegacy, "127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sw.Close() })
+	if err := sw.Enable(BackendLegacy, "127.0.0.1:0"); err == nil {
+		t.Fatal("enabled the legacy backend twice")
+	}
+
+	// Host:port addresses dial over the legacy transport
+	if err := sw.ConnectToPeer(old.listener.Addr().String(), 1); err != nil {
+		t.Fatal(err)
+	}
+	if peers := sw.GetConnectedPeers(); !slices.Contains(peers, 1) || !slices.Contains(peers, 7) {
+		t.Fatalf("expected peers from both backends, got %v", peers)
+	}
+
+	// A legacy peer's shard goes over legacy, a libp2p peer's over libp2p
+	shard := []byte("shard bytes")
+	if err := sw.SendShard(1, "file", 0, shard); err != nil {
+		t.Fatalf("send shard to legacy peer: %v", err)
+	}
+	if stored, ok := old.FetchLocalShard("file", 0); !ok || !bytes.Equal(stored, shard) {
+		t.Fatal("legacy peer did not store the shard")
+	}
+	if err := sw.SendShard(7, "file", 1, shard); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(lib.sent, []uint32{7}) {
+		t.Fatalf("libp2p backend sent to %v", lib.sent)
+	}
+
+	// Unconnected peers go to the primary
+	if err := sw.SetPrimary(BackendLegacy); err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.SendMessage(9, nil); !errors.Is(err, ErrPeerNotConnected) {
+		t.Fatalf("expected the legacy backend to refuse peer 9, got %v", err)
+	}
+
+	// Our own share is kept where peers on either transport can fetch it
+	sw.StoreLocalShare("file", 2, []byte("share"))
+	if _, ok := lib.GetLocalShare("file"); !ok {
+		t.Fatal("share not stored on libp2p backend")
+	}
+	if share, err := NewLegacyP2PAdapter(old, old.store).FetchShare(2, "file"); err != nil || string(share) != "share" {
+		t.Fatalf("legacy peer fetched %q, %v", share, err)
+	}
+
+	statuses := sw.Status()
+	if len(statuses) != 2 || statuses[0].Name != BackendLibP2P || statuses[0].Switchable || !statuses[1].Primary || statuses[1].PeerCount != 1 {
+		t.Fatalf("unexpected status %+v", statuses)
+	}
+
+	// libp2p has no factory, so only the legacy listener switches off
+	if err := sw.Disable(BackendLibP2P); err == nil {
+		t.Fatal("disabled a backend that cannot be restarted")
+	}
+	if err := sw.Disable(BackendLegacy); err != nil {
+		t.Fatal(err)
+	}
+	if statuses := sw.Status(); statuses[1].Enabled || !statuses[0].Primary {
+		t.Fatalf("primary did not fall back to libp2p: %+v", statuses)
+	}
+	if err := sw.ConnectToPeer(old.listener.Addr().String(), 1); err == nil || !strings.Contains(err.Error(), "not enabled") {
+		t.Fatalf("expected a disabled backend error, got %v", err)
+	}
+
+	if _, ok := libp2pOf(sw); ok {
+		t.Fatal("stub backend mistaken for a libp2p adapter")
+	}
+	sw.Close()
+	if !lib.closed {
+		t.Fatal("Close did not stop the libp2p backend")
+	}
+	if err := sw.SendMessage(7, nil); !errors.Is(err, ErrNoBackend) {
+		t.Fatalf("expected ErrNoBackend, got %v", err)
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLegacyPeerConnections(t *testing.T) {
+	newNode := func(id uint32, addr string) *P2PNode {
+		n, err := NewP2PNode(id, NewNodeStore())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := n.Start(addr); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(n.Stop)
+		return n
+	}
+	connected := func(n *P2PNode, peerID uint32) *P2PConnection {
+		deadline := time.Now().Add(2 * time.Second)
+		for time.Now().Before(deadline) {
+			n.mu.RLock()
+			c := n.connections[peerID]
+			n.mu.RUnlock()
+			if c != nil {
+				return c
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("node %d has no connection to node %d", n.id, peerID)
+		return nil
+	}
+	a, b := newNode(1, "127.0.0.1:0"), newNode(2, "127.0.0.1:0")
+
+	// Both sides register the connection under the other's node ID
+	if err := b.ConnectToPeer(a.listener.Addr().String(), 1); err != nil {
+		t.Fatalf("connect failed: %v", err)
+	}
+	toA, toB := connected(b, 1), connected(a, 2)
+	if b.pingPeer(toA) <= 0 || a.pingPeer(toB) <= 0 {
+		t.Fatal("expected pings to be answered both ways")
+	}
+
+	// Messages are encrypted with a key per direction
+	received := make(chan []byte, 1)
+	a.SetMessageHandler(func(from uint32, data []byte) {
+		if from == 2 {
+			received <- data
+		}
+	})
+	if err := NewLegacyP2PAdapter(b, b.store).SendMessage(1, []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msg := <-received:
+		if string(msg) != "hello" {
+			t.Fatalf("got %q", msg)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("message not delivered")
+	}
+
+	// Another node claiming b's ID is refused while b is connected
+	impostor := newNode(2, "127.0.0.1:0")
+	if err := impostor.ConnectToPeer(a.listener.Addr().String(), 1); err != nil {
+		t.Fatalf("impostor connect failed: %v", err)
+	}
+	rejected := connected(impostor, 1)
+	select {
+	case <-rejected.closed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the impostor's connection closed")
+	}
+	if connected(a, 2) != toB || a.pingPeer(toB) <= 0 {
+		t.Fatal("expected b's connection kept")
+	}
+
+	if err := b.ConnectToPeer(a.listener.Addr().String(), 9); !errors.Is(err, errPeerIDMismatch) {
+		t.Fatalf("expected a peer ID mismatch, got %v", err)
+	}
+
+	// A peer that is not listening yet is retried until it is
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lateAddr := l.Addr().String()
+	l.Close()
+	late, err := NewP2PNode(3, NewNodeStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(late.Stop)
+	started := make(chan error, 1)
+	go func() {
+		time.Sleep(2 * legacyDialBackoff)
+		started <- late.Start(lateAddr)
+	}()
+	if err := b.ConnectToPeer(lateAddr, 3); err != nil {
+		t.Fatalf("connect with retries failed: %v", err)
+	}
+	if err := <-started; err != nil {
+		t.Fatal(err)
+	}
+	connected(b, 3)
+}
+
+func TestLegacyShardAndShareTransfer(t *testing.T) {
+	a, err := NewP2PNode(1, NewNodeStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewP2PNode(2, NewNodeStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []*P2PNode{a, b} {
+		if err := n.Start("127.0.0.1:0"); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(n.Stop)
+	}
+	if err := b.ConnectToPeer(a.listener.Addr().String(), 1); err != nil {
+		t.Fatal(err)
+	}
+	adapter := NewLegacyP2PAdapter(b, b.store)
+
+	// A shard spanning many frames is stored, acknowledged and fetched back
+	shard := bytes.Repeat([]byte("0123456789abcdef"), 20000)
+	if err := adapter.SendShard(1, "file", 3, shard); err != nil {
+		t.Fatalf("send shard: %v", err)
+	}
+	if stored, ok := a.FetchLocalShard("file", 3); !ok || !bytes.Equal(stored, shard) {
+		t.Fatal("shard not stored intact")
+	}
+	got, err := adapter.FetchShard(context.Background(), 1, "file", 3)
+	if err != nil || !bytes.Equal(got, shard) {
+		t.Fatalf("fetch shard: %v", err)
+	}
+	if _, err := adapter.FetchShard(context.Background(), 1, "file", 4); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("expected a not found error, got %v", err)
+	}
+
+	if err := adapter.SendShare(1, "file", []byte("share")); err != nil {
+		t.Fatalf("send share: %v", err)
+	}
+	share, err := adapter.FetchShare(1, "file")
+	if err != nil || string(share) != "share" {
+		t.Fatalf("fetch share: %q, %v", share, err)
+	}
+
+	// Custom handlers answer requests under their own message type
+	a.Handle(100, func(c *P2PConnection, body []byte) (legacyMsgType, []byte, error) {
+		return legacyMsgShareData, append([]byte("echo:"), body...), nil
+	})
+	b.mu.RLock()
+	conn := b.connections[1]
+	b.mu.RUnlock()
+	echo, err := conn.call(100, []byte("x"), legacyMsgShareData, time.Second)
+	if err != nil || string(echo) != "echo:x" {
+		t.Fatalf("custom handler: %q, %v", echo, err)
+	}
+}
+
+func TestLegacyPingStatistics(t *testing.T) {
+	a, err := NewP2PNode(1, NewNodeStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewP2PNode(2, NewNodeStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []*P2PNode{a, b} {
+		if err := n.Start("127.0.0.1:0"); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(n.Stop)
+	}
+	if err := b.ConnectToPeer(a.listener.Addr().String(), 1); err != nil {
+		t.Fatal(err)
+	}
+	b.mu.RLock()
+	conn := b.connections[1]
+	b.mu.RUnlock()
+
+	b.probePeer(conn)
+	q, ok := b.GetPeerQuality(1)
+	if !ok || q.Sent != DefaultProbesPerRound || q.Received != DefaultProbesPerRound {
+		t.Fatalf("expected every ping answered, got %+v", q)
+	}
+	if q.LatencyMs <= 0 || q.PacketLoss != 0 {
+		t.Fatalf("unexpected statistics %+v", q)
+	}
+	latency, _, loss, err := NewLegacyP2PAdapter(b, b.store).GetConnectionQuality(1)
+	if err != nil || latency != q.LatencyMs || loss != q.PacketLoss {
+		t.Fatalf("adapter returned %.2f/%.2f, node has %.2f/%.2f", latency, loss, q.LatencyMs, q.PacketLoss)
+	}
+
+	// A pong that does not echo the nonce counts as lost
+	a.Handle(legacyMsgPing, func(c *P2PConnection, body []byte) (legacyMsgType, []byte, error) {
+		return legacyMsgPong, []byte("garbage!"), nil
+	})
+	b.probePeer(conn)
+	q, _ = b.GetPeerQuality(1)
+	if q.Received != DefaultProbesPerRound || q.PacketLoss <= 0 {
+		t.Fatalf("expected the second round lost, got %+v", q)
+	}
+
+	// Statistics of disconnected peers are dropped
+	conn.conn.Close()
+	<-conn.closed
+	b.probeAll()
+	if _, ok := b.GetPeerQuality(1); ok {
+		t.Fatal("expected statistics dropped after disconnect")
+	}
+}
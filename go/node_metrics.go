@@ -0,0 +1,174 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/pangea-net/go-node/pkg/compute"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Counters recorded from anywhere in the node. They are registered on each
+// registry built by NewNodeMetricsRegistry; gauges that read node state are
+// added per registry.
+var (
+	// cesBytesTotal counts bytes passed through the CES pipeline
+	cesBytesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "pangea_ces_bytes_total",
+			Help: "Bytes passed through the CES pipeline",
+		},
+		[]string{"operation"}, // process, reconstruct
+	)
+
+	// cesDuration times CES pipeline calls
+	cesDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "pangea_ces_duration_seconds",
+			Help:    "CES pipeline call duration in seconds",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"operation"},
+	)
+
+	// chatMessagesTotal counts chat messages sent and received
+	chatMessagesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "pangea_chat_messages_total",
+			Help: "Chat messages sent and received",
+		},
+		[]string{"direction"}, // sent, received
+	)
+
+	// sendQueueDepth counts sends waiting in per-peer outbound queues
+	sendQueueDepth = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "pangea_send_queue_depth",
+			Help: "Sends waiting in per-peer outbound queues",
+		},
+		[]string{"class"}, // compute, shard, chat
+	)
+
+	// sendQueueDroppedTotal counts sends refused because a peer's queue
+	// was full
+	sendQueueDroppedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "pangea_send_queue_dropped_total",
+			Help: "Sends refused because the peer's outbound queue was full",
+		},
+		[]string{"class"},
+	)
+
+	// bandwidthThrottledSeconds counts time sends waited on bandwidth
+	// limits
+	bandwidthThrottledSeconds = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "pangea_bandwidth_throttled_seconds_total",
+			Help: "Time outgoing sends waited on bandwidth limits",
+		},
+		[]string{"class"}, // bulk, interactive
+	)
+
+	// udpStreamBytesTotal counts legacy UDP video/audio stream bytes; libp2p
+	// stream bytes are read from the bandwidth counter
+	udpStreamBytesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "pangea_udp_stream_bytes_total",
+			Help: "Legacy UDP video/audio stream bytes",
+		},
+		[]string{"direction"},
+	)
+)
+
+// NewNodeMetricsRegistry returns a registry with the node's counters, Go
+// runtime and process metrics, the compute manager's job, chunk and queue
+// metrics if manager is non-nil, and, if node is non-nil, its peer, shard
+// and libp2p stream gauges
+func NewNodeMetricsRegistry(node *LibP2PPangeaNode, manager *compute.Manager) *prometheus.Registry {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		cesBytesTotal,
+		cesDuration,
+		chatMessagesTotal,
+		sendQueueDepth,
+		sendQueueDroppedTotal,
+		bandwidthThrottledSeconds,
+		udpStreamBytesTotal,
+	)
+	if manager != nil {
+		reg.MustRegister(manager.Metrics())
+	}
+	if node != nil {
+		reg.MustRegister(
+			prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+				Name: "pangea_peers_connected",
+				Help: "Peers with an open connection",
+			}, func() float64 {
+				return float64(len(node.GetHost().Network().Peers()))
+			}),
+			&nodeStateCollector{node: node},
+		)
+	}
+	return reg
+}
+
+// StartMetricsServer serves /metrics and /health for reg
+func StartMetricsServer(address string, reg *prometheus.Registry) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{Registry: reg}))
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("OK"))
+	})
+	return http.ListenAndServe(address, mux)
+}
+
+// recordCES records one CES pipeline call over size bytes
+func recordCES(operation string, size int, elapsed time.Duration) {
+	cesBytesTotal.WithLabelValues(operation).Add(float64(size))
+	cesDuration.WithLabelValues(operation).Observe(elapsed.Seconds())
+}
+
+// nodeStateCollector reports shards held and libp2p stream bytes, read from
+// the node at scrape time
+type nodeStateCollector struct {
+	node *LibP2PPangeaNode
+}
+
+var (
+	shardsStoredDesc = prometheus.NewDesc("pangea_shards_stored",
+		"Shards held by this node", nil, nil)
+	shardBytesStoredDesc = prometheus.NewDesc("pangea_shard_bytes_stored",
+		"Bytes of shards held by this node", nil, nil)
+	streamBytesDesc = prometheus.NewDesc("pangea_stream_bytes_total",
+		"Bytes sent and received on libp2p streams, by protocol category", []string{"protocol", "direction"}, nil)
+)
+
+// Describe implements prometheus.Collector
+func (c *nodeStateCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- shardsStoredDesc
+	ch <- shardBytesStoredDesc
+	ch <- streamBytesDesc
+}
+
+// Collect implements prometheus.Collector
+func (c *nodeStateCollector) Collect(ch chan<- prometheus.Metric) {
+	shards, bytes := c.node.GetShardStats()
+	ch <- prometheus.MustNewConstMetric(shardsStoredDesc, prometheus.GaugeValue, float64(shards))
+	ch <- prometheus.MustNewConstMetric(shardBytesStoredDesc, prometheus.GaugeValue, float64(bytes))
+
+	in, out := make(map[string]int64), make(map[string]int64)
+	for proto, stats := range c.node.GetBandwidthByProtocol() {
+		category := protocolCategory(proto)
+		in[category] += stats.TotalIn
+		out[category] += stats.TotalOut
+	}
+	for category, total := range in {
+		ch <- prometheus.MustNewConstMetric(streamBytesDesc, prometheus.CounterValue, float64(total), category, "in")
+		ch <- prometheus.MustNewConstMetric(streamBytesDesc, prometheus.CounterValue, float64(out[category]), category, "out")
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pangea-net/go-node/pkg/compute"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// metricValue reads a counter's value or a histogram's sample count
+func metricValue(t *testing.T, m prometheus.Metric) float64 {
+	var pb dto.Metric
+	if err := m.Write(&pb); err != nil {
+		t.Fatal(err)
+	}
+	if pb.Histogram != nil {
+		return float64(pb.Histogram.GetSampleCount())
+	}
+	return pb.Counter.GetValue()
+}
+
+func TestNodeMetricsRegistry(t *testing.T) {
+	node, err := NewLibP2PPangeaNodeWithOptions(510, NewNodeStore(), false, true, 12510)
+	if err != nil {
+		t.Fatalf("failed to create node: %v", err)
+	}
+	defer node.cancel()
+	if err := node.StoreShard("file-a", 0, make([]byte, 100)); err != nil {
+		t.Fatal(err)
+	}
+	if err := node.StoreShard("file-a", 1, make([]byte, 50)); err != nil {
+		t.Fatal(err)
+	}
+
+	manager := compute.NewManager(compute.DefaultConfig())
+	defer manager.Close()
+	jobID, err := manager.SubmitJob(&compute.JobManifest{
+		InputData:     make([]byte, 8),
+		SplitStrategy: "fixed_size",
+		MinChunkSize:  4,
+		MaxChunkSize:  4,
+		TimeoutSecs:   5,
+		Priority:      3,
+		StartAt:       time.Now().Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.CancelJob(jobID); err != nil {
+		t.Fatal(err)
+	}
+
+	recordCES("process", 1000, 5*time.Millisecond)
+	chatMessagesTotal.WithLabelValues("sent").Inc()
+	(&StreamingStats{}).RecordReceived(64)
+
+	reg := NewNodeMetricsRegistry(node, manager)
+	srv := httptest.NewServer(promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+	defer srv.Close()
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	for _, want := range []string{
+		"pangea_peers_connected 0",
+		"pangea_shards_stored 2",
+		"pangea_shard_bytes_stored 150",
+		`pangea_ces_bytes_total{operation="process"}`,
+		`pangea_ces_duration_seconds_count{operation="process"}`,
+		`pangea_compute_jobs_submitted_total{priority="3"} 1`,
+		`pangea_compute_jobs_total{priority="3",status="cancelled"} 1`,
+		`pangea_compute_job_duration_seconds_count{priority="3"} 1`,
+		"pangea_compute_jobs_running 0",
+		`pangea_chat_messages_total{direction="sent"}`,
+		`pangea_udp_stream_bytes_total{direction="in"}`,
+		"go_goroutines",
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("metrics output missing %q", want)
+		}
+	}
+
+	// A registry without a node still serves the counters
+	if _, err := NewNodeMetricsRegistry(nil, nil).Gather(); err != nil {
+		t.Fatalf("gather failed: %v", err)
+	}
+}
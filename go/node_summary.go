@@ -0,0 +1,277 @@
+package main
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pangea-net/go-node/pkg/compute"
+)
+
+// Sections of a node summary, in the order the tracker versions them
+const (
+	sectionPeers = iota
+	sectionReachability
+	sectionStorage
+	sectionJobs
+	sectionStreams
+	sectionCapacity
+	summarySections
+)
+
+// summaryCapacityStepMB is the granularity at which free RAM and disk
+// count as a capacity change, so their constant jitter does not resend the
+// capacity section on every poll
+const summaryCapacityStepMB = 64
+
+// nodeSummary is everything a dashboard shows about the node
+type nodeSummary struct {
+	NodeID       string
+	Uptime       time.Duration
+	Peers        summaryPeerSet
+	Reachability summaryReachability
+	Storage      summaryStorage
+	Jobs         summaryJobs
+	Streams      summaryStreams
+	Capacity     compute.ComputeCapacity
+}
+
+type summaryPeerSet struct {
+	Connected []summaryPeer
+	Known     int
+	Reachable int
+}
+
+type summaryPeer struct {
+	PeerID   uint32
+	Libp2pID string
+	Address  string
+}
+
+type summaryReachability struct {
+	Reachability ReachabilityStatus
+	NATType      NATType
+	Addrs        []string
+	Partitioned  bool
+	Since        time.Time
+}
+
+type summaryStorage struct {
+	Role       StorageRole
+	UsedBytes  uint64
+	QuotaBytes uint64
+	Shards     int
+	ShardBytes uint64
+	Pins       int
+}
+
+type summaryJobs struct {
+	Queued    uint32
+	Running   uint32
+	Completed uint32
+	Failed    uint32
+	Cancelled uint32
+	Active    []*compute.JobStatus
+}
+
+type summaryStreams struct {
+	Streaming       bool
+	FramesSent      uint64
+	FramesReceived  uint64
+	BytesSent       uint64
+	BytesReceived   uint64
+	VideoPeers      int
+	VoicePeers      int
+	ChatPeers       int
+	ChatSessions    int
+	ConferenceRooms int
+	FileTransfers   int
+	Downloads       int
+}
+
+// summaryTracker versions node summaries. Each summary gathered is hashed
+// per section; any section whose hash moved bumps the version, and the
+// section remembers the version it last changed at.
+type summaryTracker struct {
+	mu      sync.Mutex
+	version uint64
+	hashes  [summarySections]uint64
+	changed [summarySections]uint64
+}
+
+// newSummaryTracker starts versions at the current time in nanoseconds, so
+// a restarted node never reissues a version a client already holds
+func newSummaryTracker() *summaryTracker {
+	return &summaryTracker{version: uint64(time.Now().UnixNano())}
+}
+
+// update records a freshly gathered summary and returns the current version
+// and which sections changed after since. A since from the future (another
+// node, or a clock that went back) gets every section.
+func (t *summaryTracker) update(summary *nodeSummary, since uint64) (uint64, [summarySections]bool) {
+	hashes := summary.sectionHashes()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	bumped := false
+	for i, h := range hashes {
+		if h == t.hashes[i] && t.changed[i] != 0 {
+			continue
+		}
+		if !bumped {
+			t.version++
+			bumped = true
+		}
+		t.hashes[i] = h
+		t.changed[i] = t.version
+	}
+
+	var include [summarySections]bool
+	for i := range include {
+		include[i] = since == 0 || since > t.version || t.changed[i] > since
+	}
+	return t.version, include
+}
+
+// sectionHashes hashes each section. Capacity is hashed coarsely: loads to
+// whole percents, free RAM and disk to summaryCapacityStepMB.
+func (s *nodeSummary) sectionHashes() [summarySections]uint64 {
+	capacity := s.Capacity
+	capacity.CurrentLoad = float32(math.Round(float64(capacity.CurrentLoad) * 100))
+	capacity.CoreLoads = make([]float32, len(s.Capacity.CoreLoads))
+	for i, load := range s.Capacity.CoreLoads {
+		capacity.CoreLoads[i] = float32(math.Round(float64(load) * 100))
+	}
+	capacity.RAMMB /= summaryCapacityStepMB
+	capacity.DiskMB /= summaryCapacityStepMB
+
+	sections := [summarySections]any{
+		sectionPeers:        s.Peers,
+		sectionReachability: s.Reachability,
+		sectionStorage:      s.Storage,
+		sectionJobs:         s.Jobs,
+		sectionStreams:      s.Streams,
+		sectionCapacity:     capacity,
+	}
+	var hashes [summarySections]uint64
+	for i, section := range sections {
+		data, _ := json.Marshal(section)
+		h := fnv.New64a()
+		h.Write(data)
+		hashes[i] = h.Sum64()
+	}
+	return hashes
+}
+
+// gatherSummary collects the node's state from its services. Sections a
+// node in local mode lacks are left zero.
+func (s *nodeServiceServer) gatherSummary() *nodeSummary {
+	summary := &nodeSummary{}
+
+	if s.streamStats != nil {
+		st := &summary.Streams
+		st.Streaming = s.streamingService != nil
+		st.FramesSent, st.FramesReceived, st.BytesSent, st.BytesReceived = s.streamStats.GetStats()
+	}
+	s.securityManager.mu.RLock()
+	summary.Streams.ChatSessions = len(s.securityManager.chatSessions)
+	s.securityManager.mu.RUnlock()
+
+	if s.computeManager != nil {
+		summary.Capacity = s.computeManager.GetCapacity()
+		jobs := &summary.Jobs
+		for _, job := range s.computeManager.ListJobs() {
+			switch job.Status {
+			case compute.TaskPending:
+				jobs.Queued++
+				jobs.Active = append(jobs.Active, job)
+			case compute.TaskAssigned, compute.TaskComputing, compute.TaskVerifying:
+				jobs.Running++
+				jobs.Active = append(jobs.Active, job)
+			case compute.TaskCompleted:
+				jobs.Completed++
+			case compute.TaskFailed, compute.TaskTimeout:
+				jobs.Failed++
+			case compute.TaskCancelled:
+				jobs.Cancelled++
+			}
+		}
+	}
+
+	lib, ok := libp2pOf(s.network)
+	if !ok || lib.node == nil {
+		for _, id := range s.network.GetConnectedPeers() {
+			summary.Peers.Connected = append(summary.Peers.Connected, summaryPeer{PeerID: id})
+		}
+		sort.Slice(summary.Peers.Connected, func(i, j int) bool {
+			return summary.Peers.Connected[i].PeerID < summary.Peers.Connected[j].PeerID
+		})
+		return summary
+	}
+	node := lib.node
+	h := node.GetHost()
+	summary.NodeID = h.ID().String()
+	if monitor := node.GetHealthMonitor(); monitor != nil {
+		summary.Uptime = monitor.Uptime()
+	}
+
+	for _, p := range h.Network().Peers() {
+		conns := h.Network().ConnsToPeer(p)
+		if len(conns) == 0 {
+			continue
+		}
+		summary.Peers.Connected = append(summary.Peers.Connected, summaryPeer{
+			PeerID:   lib.PeerUint32ID(p),
+			Libp2pID: p.String(),
+			Address:  conns[0].RemoteMultiaddr().String(),
+		})
+	}
+	sort.Slice(summary.Peers.Connected, func(i, j int) bool {
+		return summary.Peers.Connected[i].Libp2pID < summary.Peers.Connected[j].Libp2pID
+	})
+
+	reach := &summary.Reachability
+	reach.Reachability, reach.NATType = node.GetReachabilityStatus()
+	reach.Addrs = node.LocalMultiaddrs(false)
+	if detector := node.GetPartitionDetector(); detector != nil {
+		snapshot := detector.Snapshot()
+		summary.Peers.Known, summary.Peers.Reachable = snapshot.Known, snapshot.Reachable
+		reach.Partitioned = snapshot.Partitioned
+		if snapshot.Partitioned {
+			reach.Since = snapshot.Since
+		}
+	}
+
+	storage := &summary.Storage
+	if disk := node.GetDiskMonitor(); disk != nil {
+		storage.UsedBytes, storage.QuotaBytes = disk.Usage()
+		storage.Role = disk.Role()
+	}
+	storage.Shards, storage.ShardBytes = node.GetShardStats()
+	if pins := node.GetPins(); pins != nil {
+		storage.Pins = len(pins.List())
+	}
+
+	streams := &summary.Streams
+	if comm := node.GetCommunicationService(); comm != nil {
+		streams.VideoPeers = len(comm.GetConnectedVideoPeers())
+		streams.VoicePeers = len(comm.GetConnectedVoicePeers())
+		streams.ChatPeers = len(comm.GetConnectedChatPeers())
+		streams.ConferenceRooms = len(comm.Rooms())
+	}
+	if files := node.GetFileTransferService(); files != nil {
+		for _, t := range files.Transfers() {
+			switch t.State {
+			case TransferOffered, TransferTransferring, TransferPaused:
+				streams.FileTransfers++
+			}
+		}
+	}
+	if downloads := node.GetDownloadManager(); downloads != nil {
+		streams.Downloads = downloads.Active()
+	}
+	return summary
+}
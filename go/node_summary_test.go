@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/pangea-net/go-node/pkg/compute"
+)
+
+func TestSummaryTrackerSendsChangedSections(t *testing.T) {
+	tracker := newSummaryTracker()
+	summary := &nodeSummary{
+		Storage:  summaryStorage{UsedBytes: 100},
+		Capacity: compute.ComputeCapacity{CPUCores: 4, RAMMB: 4096, CurrentLoad: 0.25},
+	}
+
+	first, include := tracker.update(summary, 0)
+	for i, ok := range include {
+		if !ok {
+			t.Fatalf("section %d missing from a full summary", i)
+		}
+	}
+
+	// Nothing changed: same version, no sections
+	version, include := tracker.update(summary, first)
+	if version != first || include != [summarySections]bool{} {
+		t.Fatalf("unchanged summary: version %d (was %d), sections %v", version, first, include)
+	}
+
+	// Load and free RAM jitter below the capacity granularity is not a change
+	summary.Capacity.CurrentLoad = 0.251
+	summary.Capacity.RAMMB = 4097
+	if version, _ = tracker.update(summary, first); version != first {
+		t.Fatal("capacity jitter bumped the version")
+	}
+
+	summary.Jobs.Running = 1
+	version, include = tracker.update(summary, first)
+	if version != first+1 {
+		t.Fatalf("expected version %d after a change, got %d", first+1, version)
+	}
+	want := [summarySections]bool{sectionJobs: true}
+	if include != want {
+		t.Fatalf("expected only jobs, got %v", include)
+	}
+	// A version from before the first summary, or from the future, gets
+	// every section
+	if _, include = tracker.update(summary, first-1); include != [summarySections]bool{true, true, true, true, true, true} {
+		t.Fatalf("expected every section since before the first summary, got %v", include)
+	}
+	if _, include = tracker.update(summary, version+10); include != [summarySections]bool{true, true, true, true, true, true} {
+		t.Fatalf("expected every section for a future version, got %v", include)
+	}
+}
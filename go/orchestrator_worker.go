@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/pangea-net/go-node/pkg/compute"
+)
+
+const (
+	// workerTaskWait is how long one GetTask long-poll asks the
+	// orchestrator to hold the request; the orchestrator caps it at 30s
+	workerTaskWait = 25 * time.Second
+
+	// workerRetryDelay is the pause after a failed call to the orchestrator
+	workerRetryDelay = 2 * time.Second
+
+	// orchestratorUpstream identifies the orchestrator to the compute
+	// manager's admission control
+	orchestratorUpstream = "orchestrator"
+)
+
+// errWorkerDropped is returned when the orchestrator no longer knows this
+// worker, which must register again
+var errWorkerDropped = errors.New("orchestrator dropped the worker")
+
+// OrchestratorWorker offers this node's compute to a go orchestrator. It
+// registers the node's capacity, keeps the registration alive with
+// heartbeats, runs the chunks the orchestrator assigns it in as many task
+// slots as the orchestrator granted, and reports each result. A worker the
+// orchestrator drops registers again.
+type OrchestratorWorker struct {
+	client   *OrchestratorClient
+	manager  *compute.Manager
+	workerID string
+	maxTasks uint32 // 0 = one per core, as the orchestrator decides
+}
+
+// NewOrchestratorWorker creates a worker registering as workerID (empty =
+// assigned by the orchestrator) that runs tasks on manager
+func NewOrchestratorWorker(client *OrchestratorClient, manager *compute.Manager, workerID string, maxTasks uint32) *OrchestratorWorker {
+	return &OrchestratorWorker{client: client, manager: manager, workerID: workerID, maxTasks: maxTasks}
+}
+
+// workerRegistration is what the orchestrator granted a worker
+type workerRegistration struct {
+	WorkerID          string `json:"workerId"`
+	HeartbeatInterval uint32 `json:"heartbeatIntervalMs"`
+	MaxTasks          uint32 `json:"maxTasks"`
+}
+
+// Run serves the orchestrator until ctx ends, then deregisters so the
+// worker's place under the orchestrator's worker limit is freed
+func (w *OrchestratorWorker) Run(ctx context.Context) {
+	for ctx.Err() == nil {
+		reg, err := w.client.RegisterWorker(ctx, w.workerID, w.manager.GetCapacity(), w.maxTasks)
+		if err != nil {
+			log.Printf("⚠️  Orchestrator registration failed: %v", err)
+			sleepCtx(ctx, workerRetryDelay)
+			continue
+		}
+		// Keep an ID the orchestrator assigned across registrations
+		w.workerID = reg.WorkerID
+		log.Printf("👷 Registered with the orchestrator as %s (%d task slots)", reg.WorkerID, reg.MaxTasks)
+		w.serve(ctx, reg)
+	}
+
+	leaveCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := w.client.DeregisterWorker(leaveCtx, w.workerID); err != nil {
+		log.Printf("⚠️  Failed to leave the orchestrator: %v", err)
+	}
+}
+
+// serve heartbeats and runs tasks for one registration, returning when ctx
+// ends or the orchestrator drops the worker
+func (w *OrchestratorWorker) serve(ctx context.Context, reg *workerRegistration) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for i := uint32(0); i < max(reg.MaxTasks, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.pullTasks(ctx)
+		}()
+	}
+
+	interval := time.Duration(reg.HeartbeatInterval) * time.Millisecond
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case <-ticker.C:
+			err := w.client.Heartbeat(ctx, w.workerID, w.manager.GetCapacity().CurrentLoad)
+			if errors.Is(err, errWorkerDropped) {
+				log.Printf("⚠️  Orchestrator dropped worker %s, registering again", w.workerID)
+				cancel()
+			} else if err != nil {
+				log.Printf("⚠️  Orchestrator heartbeat failed: %v", err)
+			}
+		}
+	}
+}
+
+// pullTasks runs the tasks assigned to one slot until ctx ends
+func (w *OrchestratorWorker) pullTasks(ctx context.Context) {
+	for ctx.Err() == nil {
+		task, err := w.client.GetTask(ctx, w.workerID)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("⚠️  Failed to get a task from the orchestrator: %v", err)
+				sleepCtx(ctx, workerRetryDelay)
+			}
+			continue
+		}
+		if task == nil {
+			continue
+		}
+
+		result := w.runTask(ctx, task)
+		// Report even if the registration is ending, so the slot is freed
+		submitCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+		accepted, err := w.client.SubmitResult(submitCtx, w.workerID, result)
+		cancel()
+		switch {
+		case err != nil:
+			log.Printf("⚠️  Failed to report task %s: %v", task.TaskID, err)
+		case !accepted:
+			log.Printf("⚠️  Orchestrator no longer waits for task %s", task.TaskID)
+		}
+	}
+}
+
+// runTask executes one assigned task within its timeout
+func (w *OrchestratorWorker) runTask(ctx context.Context, task *compute.ComputeTask) *compute.TaskResult {
+	if task.TimeoutMs > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(task.TimeoutMs)*time.Millisecond)
+		defer cancel()
+	}
+	return w.manager.ExecuteTask(ctx, task, w.workerID, orchestratorUpstream)
+}
+
+// RegisterWorker registers this node as a compute worker
+func (c *OrchestratorClient) RegisterWorker(ctx context.Context, workerID string, capacity compute.ComputeCapacity, maxTasks uint32) (*workerRegistration, error) {
+	req := map[string]any{
+		"workerId": workerID,
+		"capacity": map[string]any{
+			"cpuCores":      capacity.CPUCores,
+			"ramMb":         capacity.RAMMB,
+			"currentLoad":   capacity.CurrentLoad,
+			"diskMb":        capacity.DiskMB,
+			"bandwidthMbps": capacity.BandwidthMbps,
+		},
+		"maxTasks": maxTasks,
+	}
+	var reg workerRegistration
+	if err := c.call(ctx, "RegisterWorker", req, &reg); err != nil {
+		return nil, err
+	}
+	return &reg, nil
+}
+
+// Heartbeat keeps a worker registered. It returns errWorkerDropped when the
+// orchestrator no longer knows the worker.
+func (c *OrchestratorClient) Heartbeat(ctx context.Context, workerID string, load float32) error {
+	var resp struct {
+		Registered bool `json:"registered"`
+	}
+	if err := c.call(ctx, "Heartbeat", map[string]any{"workerId": workerID, "currentLoad": load}, &resp); err != nil {
+		return err
+	}
+	if !resp.Registered {
+		return errWorkerDropped
+	}
+	return nil
+}
+
+// GetTask returns the next task assigned to a worker, or nil if none was
+// assigned within workerTaskWait
+func (c *OrchestratorClient) GetTask(ctx context.Context, workerID string) (*compute.ComputeTask, error) {
+	req := map[string]any{"workerId": workerID, "waitMs": workerTaskWait.Milliseconds()}
+	var resp struct {
+		Task *struct {
+			TaskID       string `json:"taskId"`
+			ParentJobID  string `json:"parentJobId"`
+			ChunkIndex   uint32 `json:"chunkIndex"`
+			WASMModule   []byte `json:"wasmModule"`
+			InputData    []byte `json:"inputData"`
+			FunctionName string `json:"functionName"`
+			TimeoutMs    uint64 `json:"timeoutMs,string"` // protojson writes 64-bit integers as strings
+		} `json:"task"`
+	}
+	if err := c.call(ctx, "GetTask", req, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Task == nil {
+		return nil, nil
+	}
+	t := resp.Task
+	return &compute.ComputeTask{
+		TaskID:       t.TaskID,
+		ParentJobID:  t.ParentJobID,
+		ChunkIndex:   t.ChunkIndex,
+		WASMModule:   t.WASMModule,
+		InputData:    t.InputData,
+		FunctionName: t.FunctionName,
+		TimeoutMs:    t.TimeoutMs,
+	}, nil
+}
+
+// SubmitResult reports a task's result. It returns false if the
+// orchestrator was no longer waiting for it.
+func (c *OrchestratorClient) SubmitResult(ctx context.Context, workerID string, result *compute.TaskResult) (bool, error) {
+	req := map[string]any{
+		"workerId": workerID,
+		"result": map[string]any{
+			"taskId":          result.TaskID,
+			"status":          int(result.Status), // TaskStatus mirrors the protocol's enum
+			"resultData":      result.ResultData,
+			"resultHash":      result.ResultHash,
+			"executionTimeMs": result.ExecutionTimeMs,
+			"error":           result.Error,
+		},
+	}
+	var resp struct {
+		Accepted bool `json:"accepted"`
+	}
+	if err := c.call(ctx, "SubmitResult", req, &resp); err != nil {
+		return false, err
+	}
+	return resp.Accepted, nil
+}
+
+// DeregisterWorker removes a worker that is leaving
+func (c *OrchestratorClient) DeregisterWorker(ctx context.Context, workerID string) error {
+	return c.call(ctx, "DeregisterWorker", map[string]any{"workerId": workerID}, nil)
+}
+
+// sleepCtx waits for d or until ctx ends
+func sleepCtx(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
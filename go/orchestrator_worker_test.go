@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pangea-net/go-node/pkg/compute"
+)
+
+// fakeWorkerOrchestrator serves the orchestrator's JSON worker API, hands
+// out the tasks queued on it and drops the worker on its first heartbeat
+type fakeWorkerOrchestrator struct {
+	mu            sync.Mutex
+	tasks         chan map[string]any
+	results       chan map[string]any
+	registrations int
+	heartbeats    int
+	deregistered  bool
+}
+
+func (f *fakeWorkerOrchestrator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req map[string]any
+	json.NewDecoder(r.Body).Decode(&req)
+	reply := func(v any) { json.NewEncoder(w).Encode(v) }
+
+	switch r.URL.Path {
+	case "/rpc/RegisterWorker":
+		f.mu.Lock()
+		f.registrations++
+		f.mu.Unlock()
+		capacity, _ := req["capacity"].(map[string]any)
+		if req["workerId"] != "node-1" || capacity["cpuCores"] == nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		reply(map[string]any{"workerId": "node-1", "heartbeatIntervalMs": 20, "maxTasks": 2})
+	case "/rpc/Heartbeat":
+		f.mu.Lock()
+		f.heartbeats++
+		first := f.heartbeats == 1
+		f.mu.Unlock()
+		reply(map[string]any{"registered": !first})
+	case "/rpc/GetTask":
+		select {
+		case task := <-f.tasks:
+			reply(map[string]any{"task": task})
+		case <-time.After(50 * time.Millisecond):
+			reply(map[string]any{})
+		}
+	case "/rpc/SubmitResult":
+		f.results <- req["result"].(map[string]any)
+		reply(map[string]any{"accepted": true})
+	case "/rpc/DeregisterWorker":
+		f.mu.Lock()
+		f.deregistered = req["workerId"] == "node-1"
+		f.mu.Unlock()
+		reply(map[string]any{"deregistered": true})
+	}
+}
+
+func TestOrchestratorWorkerRunsAssignedTasks(t *testing.T) {
+	orch := &fakeWorkerOrchestrator{tasks: make(chan map[string]any, 1), results: make(chan map[string]any, 1)}
+	srv := httptest.NewServer(orch)
+	defer srv.Close()
+
+	manager := compute.NewManager(compute.DefaultConfig())
+	defer manager.Close()
+	worker := NewOrchestratorWorker(NewOrchestratorClient(srv.URL, "token", "1"), manager, "node-1", 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		worker.Run(ctx)
+	}()
+
+	// A 1x1 by 1x1 block multiply, as the orchestrator's manager sends it
+	var input bytes.Buffer
+	for _, v := range []float64{3, 4} {
+		binary.Write(&input, binary.BigEndian, uint32(1))
+		binary.Write(&input, binary.BigEndian, uint32(1))
+		binary.Write(&input, binary.BigEndian, math.Float64bits(v))
+	}
+	expected, _ := compute.ExecuteMatrixBlockMultiply(input.Bytes())
+	orch.tasks <- map[string]any{
+		"taskId":       "job:0",
+		"parentJobId":  "job",
+		"inputData":    input.Bytes(),
+		"functionName": "matrix_block_multiply",
+		"timeoutMs":    "5000",
+	}
+
+	select {
+	case result := <-orch.results:
+		data, _ := json.Marshal(result["resultData"])
+		var out []byte
+		json.Unmarshal(data, &out)
+		if result["taskId"] != "job:0" || result["status"] != float64(compute.TaskCompleted) || !bytes.Equal(out, expected) {
+			t.Fatalf("unexpected result %v", result)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the assigned task was not reported")
+	}
+
+	// The first heartbeat finds the worker dropped: it registers again
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		orch.mu.Lock()
+		registrations := orch.registrations
+		orch.mu.Unlock()
+		if registrations >= 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("dropped worker did not register again")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	<-done
+	orch.mu.Lock()
+	defer orch.mu.Unlock()
+	if !orch.deregistered {
+		t.Error("expected the worker to leave the orchestrator on shutdown")
+	}
+}
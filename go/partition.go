@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Partition detection defaults
+const (
+	DefaultPartitionCheckInterval = 5 * time.Second
+	DefaultPartitionPeerWindow    = 10 * time.Minute // Peers seen this recently form the known swarm
+	DefaultPartitionMinKnownPeers = 3                // Smaller swarms are never considered partitioned
+	DefaultPartitionEnterRatio    = 0.5              // Enter degraded mode below this reachable fraction
+	DefaultPartitionExitRatio     = 0.7              // Leave it again at or above this fraction
+)
+
+// PartitionEventKind identifies a partition detector event
+type PartitionEventKind string
+
+const (
+	PartitionEventStart PartitionEventKind = "partition_start"
+	PartitionEventEnd   PartitionEventKind = "partition_end"
+)
+
+// PartitionEvent is emitted when the node loses or regains contact with the
+// majority of its known swarm
+type PartitionEvent struct {
+	Kind      PartitionEventKind
+	Known     int
+	Reachable int
+	Time      time.Time
+	Duration  time.Duration // How long the partition lasted (end events only)
+}
+
+// PartitionConfig configures partition detection
+type PartitionConfig struct {
+	CheckInterval time.Duration
+	PeerWindow    time.Duration
+	MinKnownPeers int
+	EnterRatio    float64
+	ExitRatio     float64
+}
+
+// DefaultPartitionConfig returns the default partition heuristics
+func DefaultPartitionConfig() PartitionConfig {
+	return PartitionConfig{
+		CheckInterval: DefaultPartitionCheckInterval,
+		PeerWindow:    DefaultPartitionPeerWindow,
+		MinKnownPeers: DefaultPartitionMinKnownPeers,
+		EnterRatio:    DefaultPartitionEnterRatio,
+		ExitRatio:     DefaultPartitionExitRatio,
+	}
+}
+
+// PartitionSnapshot describes the detector's current view of the swarm
+type PartitionSnapshot struct {
+	Partitioned bool
+	Since       time.Time // Start of the current partition
+	Known       int
+	Reachable   int
+	Deferred    int // Destructive operations waiting for the partition to end
+}
+
+// PartitionDetector compares the peers seen recently with the peers that are
+// still connected. When most of the known swarm disappears at once the node
+// is more likely cut off than the peers gone, so it enters a degraded mode
+// in which destructive operations against unreachable peers are deferred.
+//
+// A partition that outlasts PeerWindow ends on its own: the missing peers
+// age out of the known swarm and the reachable side is accepted as the
+// whole network.
+type PartitionDetector struct {
+	config PartitionConfig
+
+	lastSeen    map[peer.ID]time.Time
+	partitioned bool
+	since       time.Time
+	known       int
+	reachable   int
+
+	deferred      map[string]func()
+	deferredOrder []string
+	listeners     []func(PartitionEvent)
+	mu            sync.RWMutex
+}
+
+// NewPartitionDetector creates a detector, filling unset config fields with
+// the defaults
+func NewPartitionDetector(config PartitionConfig) *PartitionDetector {
+	defaults := DefaultPartitionConfig()
+	if config.CheckInterval <= 0 {
+		config.CheckInterval = defaults.CheckInterval
+	}
+	if config.PeerWindow <= 0 {
+		config.PeerWindow = defaults.PeerWindow
+	}
+	if config.MinKnownPeers <= 0 {
+		config.MinKnownPeers = defaults.MinKnownPeers
+	}
+	if config.EnterRatio <= 0 {
+		config.EnterRatio = defaults.EnterRatio
+	}
+	if config.ExitRatio < config.EnterRatio {
+		config.ExitRatio = max(defaults.ExitRatio, config.EnterRatio)
+	}
+	return &PartitionDetector{
+		config:   config,
+		lastSeen: make(map[peer.ID]time.Time),
+		deferred: make(map[string]func()),
+	}
+}
+
+// OnEvent registers a listener for partition start and end events
+func (pd *PartitionDetector) OnEvent(fn func(PartitionEvent)) {
+	pd.mu.Lock()
+	defer pd.mu.Unlock()
+	pd.listeners = append(pd.listeners, fn)
+}
+
+// Run checks the host's connected peers every CheckInterval until ctx is
+// cancelled
+func (pd *PartitionDetector) Run(ctx context.Context, peers func() []peer.ID) {
+	ticker := time.NewTicker(pd.config.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pd.Check(peers(), time.Now())
+		}
+	}
+}
+
+// Check updates the known swarm with the connected peers and enters or
+// leaves degraded mode. Deferred operations run when a partition ends.
+func (pd *PartitionDetector) Check(connected []peer.ID, now time.Time) {
+	pd.mu.Lock()
+	for _, p := range connected {
+		pd.lastSeen[p] = now
+	}
+	for p, seen := range pd.lastSeen {
+		if now.Sub(seen) > pd.config.PeerWindow {
+			delete(pd.lastSeen, p)
+		}
+	}
+	pd.known = len(pd.lastSeen)
+	pd.reachable = len(connected)
+
+	ratio := 1.0
+	if pd.known > 0 {
+		ratio = float64(pd.reachable) / float64(pd.known)
+	}
+
+	var event *PartitionEvent
+	var pending []func()
+	switch {
+	case !pd.partitioned && pd.known >= pd.config.MinKnownPeers && ratio < pd.config.EnterRatio:
+		pd.partitioned = true
+		pd.since = now
+		event = &PartitionEvent{Kind: PartitionEventStart, Known: pd.known, Reachable: pd.reachable, Time: now}
+	case pd.partitioned && (ratio >= pd.config.ExitRatio || pd.known < pd.config.MinKnownPeers):
+		pd.partitioned = false
+		event = &PartitionEvent{Kind: PartitionEventEnd, Known: pd.known, Reachable: pd.reachable,
+			Time: now, Duration: now.Sub(pd.since)}
+		pd.since = time.Time{}
+		for _, key := range pd.deferredOrder {
+			pending = append(pending, pd.deferred[key])
+		}
+		pd.deferred = make(map[string]func())
+		pd.deferredOrder = nil
+	}
+	listeners := append([]func(PartitionEvent){}, pd.listeners...)
+	pd.mu.Unlock()
+
+	if event == nil {
+		return
+	}
+	switch event.Kind {
+	case PartitionEventStart:
+		log.Printf("🚧 [PARTITION] Lost contact with %d of %d known peers, entering degraded mode",
+			event.Known-event.Reachable, event.Known)
+	case PartitionEventEnd:
+		log.Printf("✅ [PARTITION] %d of %d known peers reachable after %v, running %d deferred operations",
+			event.Reachable, event.Known, event.Duration.Round(time.Second), len(pending))
+	}
+	for _, fn := range listeners {
+		fn(*event)
+	}
+	for _, fn := range pending {
+		fn()
+	}
+}
+
+// RunOrDefer runs a destructive operation now, or holds it until the
+// partition ends if the node is in degraded mode. A later operation with the
+// same key replaces an earlier one still waiting. Returns true if deferred.
+func (pd *PartitionDetector) RunOrDefer(key string, fn func()) bool {
+	pd.mu.Lock()
+	if !pd.partitioned {
+		pd.mu.Unlock()
+		fn()
+		return false
+	}
+	if _, exists := pd.deferred[key]; !exists {
+		pd.deferredOrder = append(pd.deferredOrder, key)
+	}
+	pd.deferred[key] = fn
+	pd.mu.Unlock()
+	log.Printf("⏸️  [PARTITION] Deferred %s until the partition ends", key)
+	return true
+}
+
+// Partitioned reports whether the node is in degraded mode
+func (pd *PartitionDetector) Partitioned() bool {
+	pd.mu.RLock()
+	defer pd.mu.RUnlock()
+	return pd.partitioned
+}
+
+// Snapshot returns the detector's current state
+func (pd *PartitionDetector) Snapshot() PartitionSnapshot {
+	pd.mu.RLock()
+	defer pd.mu.RUnlock()
+	return PartitionSnapshot{
+		Partitioned: pd.partitioned,
+		Since:       pd.since,
+		Known:       pd.known,
+		Reachable:   pd.reachable,
+		Deferred:    len(pd.deferred),
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestPartitionDetectorStartAndEnd(t *testing.T) {
+	pd := NewPartitionDetector(PartitionConfig{PeerWindow: time.Minute})
+	var events []PartitionEvent
+	pd.OnEvent(func(ev PartitionEvent) { events = append(events, ev) })
+
+	swarm := []peer.ID{peer.ID("a"), peer.ID("b"), peer.ID("c"), peer.ID("d")}
+	now := time.Now()
+	pd.Check(swarm, now)
+	if pd.Partitioned() {
+		t.Fatal("fully connected swarm reported as partitioned")
+	}
+
+	// Ordinary churn: one peer of four leaves
+	pd.Check(swarm[:3], now.Add(time.Second))
+	if pd.Partitioned() {
+		t.Fatal("losing one of four peers should not be a partition")
+	}
+
+	// Three of four peers vanish at once
+	pd.Check(swarm[:1], now.Add(2*time.Second))
+	if !pd.Partitioned() || len(events) != 1 || events[0].Kind != PartitionEventStart {
+		t.Fatalf("expected partition start, got %+v", events)
+	}
+	if s := pd.Snapshot(); s.Known != 4 || s.Reachable != 1 {
+		t.Errorf("expected 1 of 4 peers reachable, got %+v", s)
+	}
+
+	// Hysteresis: half the swarm back is not enough to leave degraded mode
+	pd.Check(swarm[:2], now.Add(3*time.Second))
+	if !pd.Partitioned() {
+		t.Fatal("partition ended below the exit ratio")
+	}
+
+	pd.Check(swarm, now.Add(4*time.Second))
+	if pd.Partitioned() || len(events) != 2 || events[1].Kind != PartitionEventEnd {
+		t.Fatalf("expected partition end, got %+v", events)
+	}
+	if events[1].Duration != 2*time.Second {
+		t.Errorf("expected 2s partition, got %v", events[1].Duration)
+	}
+}
+
+func TestPartitionDetectorSmallSwarm(t *testing.T) {
+	pd := NewPartitionDetector(PartitionConfig{})
+	now := time.Now()
+	pd.Check([]peer.ID{peer.ID("a"), peer.ID("b")}, now)
+	pd.Check(nil, now.Add(time.Second))
+	if pd.Partitioned() {
+		t.Error("swarm below MinKnownPeers should never be partitioned")
+	}
+}
+
+func TestPartitionDetectorDefersOperations(t *testing.T) {
+	pd := NewPartitionDetector(PartitionConfig{PeerWindow: time.Minute})
+	swarm := []peer.ID{peer.ID("a"), peer.ID("b"), peer.ID("c")}
+	now := time.Now()
+	pd.Check(swarm, now)
+
+	var ran []string
+	if pd.RunOrDefer("gc", func() { ran = append(ran, "gc") }) || len(ran) != 1 {
+		t.Fatal("operation should run immediately when not partitioned")
+	}
+
+	pd.Check(nil, now.Add(time.Second))
+	ran = nil
+	pd.RunOrDefer("penalty", func() { ran = append(ran, "penalty 1") })
+	pd.RunOrDefer("penalty", func() { ran = append(ran, "penalty 2") })
+	pd.RunOrDefer("gc", func() { ran = append(ran, "gc") })
+	if len(ran) != 0 {
+		t.Fatalf("operations ran during partition: %v", ran)
+	}
+	if s := pd.Snapshot(); s.Deferred != 2 {
+		t.Errorf("expected 2 deferred operations, got %d", s.Deferred)
+	}
+
+	pd.Check(swarm, now.Add(2*time.Second))
+	if len(ran) != 2 || ran[0] != "penalty 2" || ran[1] != "gc" {
+		t.Errorf("expected latest penalty then gc after partition, got %v", ran)
+	}
+}
+
+func TestPartitionEndsWhenMissingPeersAgeOut(t *testing.T) {
+	pd := NewPartitionDetector(PartitionConfig{PeerWindow: time.Minute})
+	swarm := []peer.ID{peer.ID("a"), peer.ID("b"), peer.ID("c"), peer.ID("d")}
+	now := time.Now()
+	pd.Check(swarm, now)
+	pd.Check(swarm[:1], now.Add(time.Second))
+	if !pd.Partitioned() {
+		t.Fatal("expected partition")
+	}
+
+	// The missing peers leave the window; the remaining side is the swarm
+	pd.Check(swarm[:1], now.Add(2*time.Minute))
+	if pd.Partitioned() {
+		t.Error("partition should end once missing peers age out of the window")
+	}
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Safety numbers let two users confirm out of band that each node holds
+// the identity key the other expects. Each side's half is 30 digits
+// derived from its libp2p public key; both sides show the two halves in
+// the same order, so the numbers match exactly when neither key was
+// swapped.
+const (
+	safetyNumberVersion    = 0
+	safetyNumberIterations = 5200
+	safetyNumberChunks     = 6 // Five-digit groups per half
+)
+
+// PeerVerificationRecord is the verification state of one peer
+type PeerVerificationRecord struct {
+	PeerID       string    `json:"peerId"`
+	Fingerprint  string    `json:"fingerprint"` // Identity key fingerprint when verified
+	VerifiedAt   time.Time `json:"verifiedAt"`
+	KeyChangedAt time.Time `json:"keyChangedAt,omitempty"` // Set when the peer presented another key
+	SeenKey      string    `json:"seenKey,omitempty"`      // Fingerprint of the key it presented instead
+}
+
+// Verified reports whether the peer is verified and has not changed key
+// since
+func (v *PeerVerificationRecord) Verified() bool {
+	return v != nil && v.KeyChangedAt.IsZero()
+}
+
+// PeerVerifier records which peers the user has verified by comparing
+// safety numbers, and warns when a verified peer shows up with a
+// different identity key
+type PeerVerifier struct {
+	host    host.Host
+	events  *EventBus
+	path    string // Empty keeps verifications in memory only
+	records map[peer.ID]*PeerVerificationRecord
+	mu      sync.Mutex
+}
+
+// NewPeerVerifier creates a verifier; key changes are announced on events
+func NewPeerVerifier(h host.Host, events *EventBus) *PeerVerifier {
+	return &PeerVerifier{host: h, events: events, records: make(map[peer.ID]*PeerVerificationRecord)}
+}
+
+// SetPath persists verifications to path and loads those already there
+func (pv *PeerVerifier) SetPath(path string) error {
+	var records []*PeerVerificationRecord
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &records); err != nil {
+			return fmt.Errorf("invalid peer verification file: %w", err)
+		}
+	}
+
+	pv.mu.Lock()
+	defer pv.mu.Unlock()
+	pv.path = path
+	for _, r := range records {
+		id, err := peer.Decode(r.PeerID)
+		if err != nil {
+			log.Printf("⚠️  [VERIFY] Skipping record for %q: %v", r.PeerID, err)
+			continue
+		}
+		pv.records[id] = r
+	}
+	if len(records) > 0 {
+		log.Printf("🔏 [VERIFY] Loaded %d peer verifications from %s", len(records), path)
+	}
+	return nil
+}
+
+// SafetyNumber returns the 60-digit safety number shared with a peer,
+// in twelve groups of five. The peer's key must be known, so it must be
+// or have been connected.
+func (pv *PeerVerifier) SafetyNumber(p peer.ID) (string, error) {
+	local, err := identityHalf(pv.host.Peerstore().PubKey(pv.host.ID()), pv.host.ID())
+	if err != nil {
+		return "", err
+	}
+	remote, err := identityHalf(pv.host.Peerstore().PubKey(p), p)
+	if err != nil {
+		return "", fmt.Errorf("peer %s: %w", shortPeerID(p), err)
+	}
+	halves := []string{local, remote}
+	sort.Strings(halves)
+	digits := halves[0] + halves[1]
+	groups := make([]string, 0, len(digits)/5)
+	for i := 0; i < len(digits); i += 5 {
+		groups = append(groups, digits[i:i+5])
+	}
+	return strings.Join(groups, " "), nil
+}
+
+// Verify marks a peer as verified under its current identity key. If
+// safetyNumber is not empty it must match, ignoring spacing.
+func (pv *PeerVerifier) Verify(p peer.ID, safetyNumber string) error {
+	fingerprint, err := IdentityFingerprint(pv.host.Peerstore().PubKey(p))
+	if err != nil {
+		return fmt.Errorf("peer %s: %w", shortPeerID(p), err)
+	}
+	if safetyNumber != "" {
+		want, err := pv.SafetyNumber(p)
+		if err != nil {
+			return err
+		}
+		if strings.Join(strings.Fields(safetyNumber), "") != strings.ReplaceAll(want, " ", "") {
+			return errors.New("safety number does not match")
+		}
+	}
+
+	pv.mu.Lock()
+	defer pv.mu.Unlock()
+	pv.records[p] = &PeerVerificationRecord{PeerID: p.String(), Fingerprint: fingerprint, VerifiedAt: time.Now()}
+	log.Printf("🔏 [VERIFY] Verified peer %s (%s)", shortPeerID(p), fingerprint[:16])
+	return pv.saveLocked()
+}
+
+// Unverify forgets a peer's verification
+func (pv *PeerVerifier) Unverify(p peer.ID) error {
+	pv.mu.Lock()
+	defer pv.mu.Unlock()
+	if _, ok := pv.records[p]; !ok {
+		return fmt.Errorf("peer %s is not verified", shortPeerID(p))
+	}
+	delete(pv.records, p)
+	return pv.saveLocked()
+}
+
+// Status returns a peer's verification record, nil if it was never
+// verified
+func (pv *PeerVerifier) Status(p peer.ID) *PeerVerificationRecord {
+	pv.mu.Lock()
+	defer pv.mu.Unlock()
+	if r, ok := pv.records[p]; ok {
+		out := *r
+		return &out
+	}
+	return nil
+}
+
+// List returns every verification record, by peer ID
+func (pv *PeerVerifier) List() []PeerVerificationRecord {
+	pv.mu.Lock()
+	defer pv.mu.Unlock()
+	out := make([]PeerVerificationRecord, 0, len(pv.records))
+	for _, r := range pv.records {
+		out = append(out, *r)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].PeerID < out[j].PeerID })
+	return out
+}
+
+// CheckKey compares the identity key a peer presented with the one it was
+// verified under. A different key clears the verification, logs a warning
+// and publishes EventPeerKeyChanged once per new key.
+func (pv *PeerVerifier) CheckKey(p peer.ID, pub crypto.PubKey) {
+	fingerprint, err := IdentityFingerprint(pub)
+	if err != nil {
+		return
+	}
+	pv.mu.Lock()
+	r, ok := pv.records[p]
+	if !ok || r.Fingerprint == fingerprint || r.SeenKey == fingerprint {
+		pv.mu.Unlock()
+		return
+	}
+	r.KeyChangedAt, r.SeenKey = time.Now(), fingerprint
+	verified := r.Fingerprint
+	if err := pv.saveLocked(); err != nil {
+		log.Printf("⚠️  [VERIFY] %v", err)
+	}
+	pv.mu.Unlock()
+
+	log.Printf("🚨 [VERIFY] Identity key of verified peer %s changed (%s -> %s); verify it again", shortPeerID(p), verified[:16], fingerprint[:16])
+	pv.events.Publish(NodeEvent{
+		Type:       EventPeerKeyChanged,
+		PeerID:     p.String(),
+		Attributes: map[string]string{"verifiedFingerprint": verified, "fingerprint": fingerprint},
+	})
+}
+
+// saveLocked writes every record to the verification file, if one is
+// set. Caller must hold pv.mu.
+func (pv *PeerVerifier) saveLocked() error {
+	if pv.path == "" {
+		return nil
+	}
+	records := make([]*PeerVerificationRecord, 0, len(pv.records))
+	for _, r := range pv.records {
+		records = append(records, r)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].PeerID < records[j].PeerID })
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(pv.path), 0700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(pv.path+".tmp", data, 0600); err != nil {
+		return fmt.Errorf("failed to save peer verifications: %w", err)
+	}
+	return os.Rename(pv.path+".tmp", pv.path)
+}
+
+// IdentityFingerprint is the hex SHA-256 of a marshalled libp2p public key
+func IdentityFingerprint(pub crypto.PubKey) (string, error) {
+	if pub == nil {
+		return "", errors.New("identity key unknown")
+	}
+	raw, err := crypto.MarshalPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// identityHalf derives one side's 30 digits of a safety number from its
+// key and peer ID, with iterated hashing to slow down searches for a
+// colliding key
+func identityHalf(pub crypto.PubKey, id peer.ID) (string, error) {
+	if pub == nil {
+		return "", errors.New("identity key unknown")
+	}
+	raw, err := crypto.MarshalPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+	h := sha512.New()
+	h.Write([]byte{0, safetyNumberVersion})
+	h.Write(raw)
+	h.Write([]byte(id))
+	digest := h.Sum(nil)
+	for i := 0; i < safetyNumberIterations; i++ {
+		h.Reset()
+		h.Write(digest)
+		h.Write(raw)
+		digest = h.Sum(digest[:0])
+	}
+
+	var b strings.Builder
+	for i := 0; i < safetyNumberChunks; i++ {
+		chunk := binary.BigEndian.Uint64(append([]byte{0, 0, 0}, digest[i*5:i*5+5]...))
+		fmt.Fprintf(&b, "%05d", chunk%100000)
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"crypto/rand"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+)
+
+func TestPeerVerification(t *testing.T) {
+	nodes := newDKGTestNodes(t, 12600, 2)
+	a, b := nodes[0], nodes[1]
+
+	numberA, err := a.GetPeerVerifier().SafetyNumber(b.host.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	numberB, err := b.GetPeerVerifier().SafetyNumber(a.host.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if numberA != numberB || len(strings.ReplaceAll(numberA, " ", "")) != 60 {
+		t.Fatalf("safety numbers differ or are malformed: %q vs %q", numberA, numberB)
+	}
+
+	path := filepath.Join(t.TempDir(), "peer_verification.json")
+	pv := a.GetPeerVerifier()
+	if err := pv.SetPath(path); err != nil {
+		t.Fatal(err)
+	}
+	wrong := []byte(strings.ReplaceAll(numberA, " ", ""))
+	wrong[0] = '0' + (wrong[0]-'0'+1)%10
+	if err := pv.Verify(b.host.ID(), string(wrong)); err == nil {
+		t.Fatal("expected a wrong safety number to be refused")
+	}
+	if err := pv.Verify(b.host.ID(), strings.ReplaceAll(numberB, " ", "")); err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if !pv.Status(b.host.ID()).Verified() {
+		t.Fatal("peer not verified")
+	}
+
+	// A different key clears the verification and warns once
+	sub, err := a.events.Subscribe(EventPeerKeyChanged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pv.CheckKey(b.host.ID(), b.host.Peerstore().PubKey(b.host.ID()))
+	pv.CheckKey(b.host.ID(), other.GetPublic())
+	pv.CheckKey(b.host.ID(), other.GetPublic())
+	select {
+	case evt := <-sub.C:
+		if evt.PeerID != b.host.ID().String() {
+			t.Fatalf("unexpected event %+v", evt)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no key change event")
+	}
+	select {
+	case evt := <-sub.C:
+		t.Fatalf("key change announced twice: %+v", evt)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// The state survives a restart
+	reloaded := NewPeerVerifier(a.host, nil)
+	if err := reloaded.SetPath(path); err != nil {
+		t.Fatal(err)
+	}
+	r := reloaded.Status(b.host.ID())
+	if r == nil || r.Verified() || r.KeyChangedAt.IsZero() {
+		t.Fatalf("unexpected reloaded record: %+v", r)
+	}
+	if err := reloaded.Verify(b.host.ID(), ""); err != nil || !reloaded.Status(b.host.ID()).Verified() {
+		t.Fatalf("re-verifying failed: %v", err)
+	}
+	if err := reloaded.Unverify(b.host.ID()); err != nil || len(reloaded.List()) != 0 {
+		t.Fatalf("Unverify failed: %v", err)
+	}
+}
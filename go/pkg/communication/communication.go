@@ -33,6 +33,9 @@ const (
 
 	// Debounce interval for saving chat history
 	SaveDebounceInterval = 2 * time.Second
+
+	// Most messages kept in history per peer
+	maxHistoryPerPeer = 1000
 )
 
 // ChatMessage represents a chat message
@@ -42,6 +45,17 @@ type ChatMessage struct {
 	To        string    `json:"to"`
 	Content   string    `json:"content"`
 	Timestamp time.Time `json:"timestamp"`
+	Seq       uint64    `json:"seq,omitempty"`  // Per-peer sequence, continues across resumed sessions
+	Tags      []string  `json:"tags,omitempty"` // Set by the incoming filter (e.g. "priority")
+
+	// Disappearing messages: TTL is the sender's session policy, ExpiresAt
+	// is when this copy is deleted
+	TTL       time.Duration `json:"ttl,omitempty"`
+	ExpiresAt time.Time     `json:"expiresAt,omitzero"`
+
+	// Control frames: Type "delete" asks the receiver to delete DeleteIDs
+	Type      string   `json:"type,omitempty"`
+	DeleteIDs []string `json:"deleteIds,omitempty"`
 }
 
 // VideoFrame represents a video frame for streaming
@@ -50,10 +64,14 @@ type VideoFrame struct {
 	Width     uint16
 	Height    uint16
 	Quality   uint8
+	Keyframe  bool // Decodable on its own; large keyframes may be spread
 	Data      []byte
 	Timestamp time.Time
 }
 
+// videoFlagKeyframe marks a keyframe in byte 9 of the video frame header
+const videoFlagKeyframe = 0x01
+
 // VoiceChunk represents an audio chunk for streaming
 type VoiceChunk struct {
 	SampleRate uint32
@@ -73,15 +91,26 @@ type CommunicationService struct {
 	wg      sync.WaitGroup // Track goroutines for clean shutdown
 
 	// Callbacks
-	onChatMessage func(msg ChatMessage)
-	onVideoFrame  func(peerID string, frame VideoFrame)
-	onVoiceChunk  func(peerID string, chunk VoiceChunk)
+	onChatMessage  func(msg ChatMessage)
+	onVideoFrame   func(peerID string, frame VideoFrame)
+	onVoiceChunk   func(peerID string, chunk VoiceChunk)
+	onSessionEvent func(evt SessionEvent)
+	onScreenUpdate func(peerID string, update ScreenUpdate)
 
 	// Chat history storage
 	chatHistory     map[string][]ChatMessage // key: peer ID
 	chatHistoryFile string
 	historyMu       sync.RWMutex
 
+	// Encryption at rest: the salt is set while history is encrypted, the
+	// key while it is also unlocked
+	historySalt   []byte
+	historyKey    []byte
+	historyLocked bool // Encrypted history not yet unlocked; nothing is saved
+
+	// Word index for history search, per peer; built lazily
+	historyIndex map[string]*peerIndex
+
 	// Debounced save mechanism (fixes race condition from review comment)
 	saveChan    chan struct{}
 	saveTimer   *time.Timer
@@ -92,11 +121,37 @@ type CommunicationService struct {
 	videoStreams map[peer.ID]network.Stream
 	voiceStreams map[peer.ID]network.Stream
 	streamMu     sync.RWMutex
+	chatWriteMu  sync.Mutex
+
+	// Session resumption across transient disconnects
+	sessions    map[peer.ID]*peerSession
+	sessionMu   sync.RWMutex
+	resumeGrace time.Duration
+	notifiee    network.Notifiee
+
+	// Screen sharing: outgoing shares by peer and received updates
+	screenShares map[peer.ID]*screenShare
+	screenQueue  []ReceivedScreenUpdate
+	screenMu     sync.Mutex
+
+	// Conference bridge: hosted rooms and the room each participant is in
+	rooms  map[string]*conferenceRoom
+	roomOf map[peer.ID]string
+	confMu sync.Mutex
+
+	// Incoming chat filtering (allow/deny, spam, classifier, quarantine)
+	filter *chatFilter
+
+	// Erasure-coded spreading of large video keyframes
+	spread       *VideoSpreadConfig
+	spreadFrames map[spreadKey]*spreadAssembly
+	spreadMu     sync.Mutex
 }
 
 // Config holds configuration for the communication service
 type Config struct {
-	DataDir string // Directory for storing chat history
+	DataDir            string        // Directory for storing chat history
+	SessionResumeGrace time.Duration // How long to hold a disconnected peer's session (default 30s)
 }
 
 // NewCommunicationService creates a new communication service
@@ -128,7 +183,16 @@ func NewCommunicationService(h host.Host, cfg Config) *CommunicationService {
 		chatStreams:     make(map[peer.ID]network.Stream),
 		videoStreams:    make(map[peer.ID]network.Stream),
 		voiceStreams:    make(map[peer.ID]network.Stream),
+		screenShares:    make(map[peer.ID]*screenShare),
+		rooms:           make(map[string]*conferenceRoom),
+		roomOf:          make(map[peer.ID]string),
 		saveChan:        make(chan struct{}, 1), // Buffered channel for debouncing
+		sessions:        make(map[peer.ID]*peerSession),
+		resumeGrace:     cfg.SessionResumeGrace,
+		filter:          newChatFilter(),
+	}
+	if cs.resumeGrace <= 0 {
+		cs.resumeGrace = DefaultSessionResumeGrace
 	}
 
 	// Load existing chat history
@@ -150,16 +214,30 @@ func (cs *CommunicationService) Start() error {
 	cs.host.SetStreamHandler(ChatProtocol, cs.handleChatStream)
 	cs.host.SetStreamHandler(VideoProtocol, cs.handleVideoStream)
 	cs.host.SetStreamHandler(VoiceProtocol, cs.handleVoiceStream)
+	cs.host.SetStreamHandler(ScreenShareProtocol, cs.handleScreenStream)
+	cs.host.SetStreamHandler(ConferenceProtocol, cs.handleConferenceStream)
+	cs.host.SetStreamHandler(VideoSpreadProtocol, cs.handleSpreadStream)
+
+	// Watch connections so streams can be resumed after transient disconnects
+	cs.notifiee = cs.sessionNotifiee()
+	cs.host.Network().Notify(cs.notifiee)
 
 	// Start debounced save goroutine
 	cs.wg.Add(1)
 	go cs.debouncedSaveLoop()
 
+	// Delete disappearing messages as they expire
+	cs.wg.Add(1)
+	go cs.expiryLoop()
+
 	cs.running = true
 	log.Printf("💬 Communication service started")
 	log.Printf("   Chat Protocol:  %s", ChatProtocol)
 	log.Printf("   Video Protocol: %s", VideoProtocol)
 	log.Printf("   Voice Protocol: %s", VoiceProtocol)
+	log.Printf("   Screen Protocol: %s", ScreenShareProtocol)
+	log.Printf("   Conference Protocol: %s", ConferenceProtocol)
+	log.Printf("   Video Spread Protocol: %s", VideoSpreadProtocol)
 
 	return nil
 }
@@ -202,6 +280,8 @@ func (cs *CommunicationService) Stop() error {
 
 	// Cancel context to signal all goroutines to stop
 	cs.cancel()
+	cs.host.Network().StopNotify(cs.notifiee)
+	cs.stopSessions()
 
 	// Close all streams - this will unblock any blocking reads
 	cs.streamMu.Lock()
@@ -219,6 +299,15 @@ func (cs *CommunicationService) Stop() error {
 	cs.voiceStreams = make(map[peer.ID]network.Stream)
 	cs.streamMu.Unlock()
 
+	cs.screenMu.Lock()
+	for _, share := range cs.screenShares {
+		if share.stream != nil {
+			share.stream.Close()
+		}
+	}
+	cs.screenShares = make(map[peer.ID]*screenShare)
+	cs.screenMu.Unlock()
+
 	// Wait for all goroutines to finish with timeout
 	done := make(chan struct{})
 	go func() {
@@ -280,11 +369,10 @@ func (cs *CommunicationService) handleChatStream(stream network.Stream) {
 	cs.streamMu.Lock()
 	cs.chatStreams[remotePeer] = stream
 	cs.streamMu.Unlock()
+	cs.touchSession(remotePeer, ChatProtocol)
 
 	defer func() {
-		cs.streamMu.Lock()
-		delete(cs.chatStreams, remotePeer)
-		cs.streamMu.Unlock()
+		cs.removeStream(ChatProtocol, remotePeer, stream)
 		stream.Close()
 	}()
 
@@ -339,24 +427,47 @@ func (cs *CommunicationService) handleChatStream(stream network.Stream) {
 
 		msg.From = remotePeer.String()
 		msg.Timestamp = time.Now()
+		msg.Tags = nil
 
-		// Store in history
-		cs.addToHistory(msg)
-
-		// Invoke callback
-		cs.mu.RLock()
-		cb := cs.onChatMessage
-		cs.mu.RUnlock()
-
-		if cb != nil {
-			cb(msg)
+		if msg.Type == ChatFrameDelete {
+			cs.deleteRemoteMessages(remotePeer, msg.DeleteIDs)
+			continue
+		}
+		cs.recordRecvSeq(remotePeer, msg.Seq)
+		msg.ExpiresAt = time.Time{}
+		if msg.TTL > 0 {
+			cs.recordPeerTTL(remotePeer, msg.TTL)
+			msg.ExpiresAt = msg.Timestamp.Add(msg.TTL)
 		}
 
-		fromStr := msg.From
-		if len(fromStr) > 12 {
-			fromStr = fromStr[:12]
+		// Filter before the message reaches history and callbacks
+		verdict := cs.filterIncoming(msg)
+		switch verdict.Action {
+		case FilterDrop:
+			log.Printf("🚫 Dropped chat from %s: %s", peerStr, verdict.Reason)
+			continue
+		case FilterQuarantine:
+			cs.quarantineMessage(msg, verdict.Reason)
+			log.Printf("🔒 Quarantined chat from %s: %s", peerStr, verdict.Reason)
+			continue
 		}
-		log.Printf("📨 Chat from %s: %s", fromStr, msg.Content)
+		msg.Tags = verdict.Tags
+
+		cs.deliverChat(msg)
+		log.Printf("📨 Chat from %s: %s", peerStr, msg.Content)
+	}
+}
+
+// deliverChat stores an accepted incoming message and invokes the callback
+func (cs *CommunicationService) deliverChat(msg ChatMessage) {
+	cs.addToHistory(msg)
+
+	cs.mu.RLock()
+	cb := cs.onChatMessage
+	cs.mu.RUnlock()
+
+	if cb != nil {
+		cb(msg)
 	}
 }
 
@@ -375,32 +486,46 @@ func (cs *CommunicationService) SendChatMessage(peerID peer.ID, content string)
 	if err != nil {
 		return fmt.Errorf("failed to get chat stream: %w", err)
 	}
+	msg.Seq = cs.nextSendSeq(peerID)
+	if ttl := cs.ChatTTL(peerID); ttl > 0 {
+		msg.TTL = ttl
+		msg.ExpiresAt = msg.Timestamp.Add(ttl)
+	}
 
-	// Serialize message
+	if err := cs.writeChatFrame(stream, msg); err != nil {
+		return err
+	}
+
+	// Store in our history
+	cs.addToHistory(msg)
+
+	peerStr := peerID.String()
+	if len(peerStr) > 12 {
+		peerStr = peerStr[:12]
+	}
+	log.Printf("📤 Chat to %s: %s", peerStr, content)
+	return nil
+}
+
+// writeChatFrame sends a length-prefixed JSON chat frame. Writes are
+// serialized so frames from different goroutines do not interleave.
+func (cs *CommunicationService) writeChatFrame(stream network.Stream, msg ChatMessage) error {
 	msgData, err := json.Marshal(msg)
 	if err != nil {
 		return fmt.Errorf("failed to serialize message: %w", err)
 	}
 
-	// Send length-prefixed message
 	lengthBuf := make([]byte, 4)
 	binary.BigEndian.PutUint32(lengthBuf, uint32(len(msgData)))
 
+	cs.chatWriteMu.Lock()
+	defer cs.chatWriteMu.Unlock()
 	if _, err := stream.Write(lengthBuf); err != nil {
 		return fmt.Errorf("failed to send message length: %w", err)
 	}
 	if _, err := stream.Write(msgData); err != nil {
 		return fmt.Errorf("failed to send message: %w", err)
 	}
-
-	// Store in our history
-	cs.addToHistory(msg)
-
-	peerStr := peerID.String()
-	if len(peerStr) > 12 {
-		peerStr = peerStr[:12]
-	}
-	log.Printf("📤 Chat to %s: %s", peerStr, content)
 	return nil
 }
 
@@ -426,6 +551,7 @@ func (cs *CommunicationService) getChatStream(peerID peer.ID) (network.Stream, e
 	cs.streamMu.Lock()
 	cs.chatStreams[peerID] = newStream
 	cs.streamMu.Unlock()
+	cs.touchSession(peerID, ChatProtocol)
 
 	// Start reading from stream in background with proper tracking
 	cs.wg.Add(1)
@@ -482,11 +608,15 @@ func (cs *CommunicationService) addToHistory(msg ChatMessage) {
 	cs.chatHistory[peerID] = append(cs.chatHistory[peerID], msg)
 
 	// Keep only last 1000 messages per peer
-	if len(cs.chatHistory[peerID]) > 1000 {
-		cs.chatHistory[peerID] = cs.chatHistory[peerID][len(cs.chatHistory[peerID])-1000:]
+	if len(cs.chatHistory[peerID]) > maxHistoryPerPeer {
+		cs.chatHistory[peerID] = cs.chatHistory[peerID][len(cs.chatHistory[peerID])-maxHistoryPerPeer:]
 	}
 
-	// Signal debounced save (non-blocking)
+	cs.requestSave()
+}
+
+// requestSave signals a debounced save of chat history (non-blocking)
+func (cs *CommunicationService) requestSave() {
 	select {
 	case cs.saveChan <- struct{}{}:
 	default:
@@ -494,18 +624,16 @@ func (cs *CommunicationService) addToHistory(msg ChatMessage) {
 	}
 }
 
-// saveChatHistory saves chat history to disk
+// saveChatHistory saves chat history to disk. Locked history is not
+// saved; messages received meanwhile are merged in when it is unlocked.
 func (cs *CommunicationService) saveChatHistory() {
 	cs.historyMu.RLock()
 	defer cs.historyMu.RUnlock()
 
-	data, err := json.MarshalIndent(cs.chatHistory, "", "  ")
-	if err != nil {
-		log.Printf("Failed to serialize chat history: %v", err)
+	if cs.historyLocked {
 		return
 	}
-
-	if err := os.WriteFile(cs.chatHistoryFile, data, 0644); err != nil {
+	if err := cs.writeChatHistoryLocked(); err != nil {
 		log.Printf("Failed to save chat history: %v", err)
 	}
 }
@@ -523,6 +651,18 @@ func (cs *CommunicationService) loadChatHistory() {
 		return
 	}
 
+	if isEncryptedHistory(data) {
+		salt, err := readHistorySalt(data)
+		if err != nil {
+			log.Printf("Failed to load chat history: %v", err)
+			return
+		}
+		cs.historySalt = salt
+		cs.historyLocked = true
+		log.Printf("🔒 Chat history is encrypted; unlock it to load past messages")
+		return
+	}
+
 	if err := json.Unmarshal(data, &cs.chatHistory); err != nil {
 		log.Printf("Failed to parse chat history: %v", err)
 	}
@@ -544,11 +684,10 @@ func (cs *CommunicationService) handleVideoStream(stream network.Stream) {
 	cs.streamMu.Lock()
 	cs.videoStreams[remotePeer] = stream
 	cs.streamMu.Unlock()
+	cs.touchSession(remotePeer, VideoProtocol)
 
 	defer func() {
-		cs.streamMu.Lock()
-		delete(cs.videoStreams, remotePeer)
-		cs.streamMu.Unlock()
+		cs.removeStream(VideoProtocol, remotePeer, stream)
 		stream.Close()
 	}()
 
@@ -583,6 +722,7 @@ func (cs *CommunicationService) handleVideoStream(stream network.Stream) {
 		width := binary.BigEndian.Uint16(header[4:6])
 		height := binary.BigEndian.Uint16(header[6:8])
 		quality := header[8]
+		keyframe := header[9]&videoFlagKeyframe != 0
 
 		// Read data length (4 bytes)
 		lengthBuf := make([]byte, 4)
@@ -613,6 +753,7 @@ func (cs *CommunicationService) handleVideoStream(stream network.Stream) {
 			Width:     width,
 			Height:    height,
 			Quality:   quality,
+			Keyframe:  keyframe,
 			Data:      data,
 			Timestamp: time.Now(),
 		}
@@ -640,6 +781,9 @@ func (cs *CommunicationService) SendVideoFrame(peerID peer.ID, frame VideoFrame)
 	binary.BigEndian.PutUint16(header[4:6], frame.Width)
 	binary.BigEndian.PutUint16(header[6:8], frame.Height)
 	header[8] = frame.Quality
+	if frame.Keyframe {
+		header[9] = videoFlagKeyframe
+	}
 
 	// Build length
 	lengthBuf := make([]byte, 4)
@@ -680,6 +824,7 @@ func (cs *CommunicationService) getVideoStream(peerID peer.ID) (network.Stream,
 	cs.streamMu.Lock()
 	cs.videoStreams[peerID] = newStream
 	cs.streamMu.Unlock()
+	cs.touchSession(peerID, VideoProtocol)
 
 	cs.wg.Add(1)
 	go func() {
@@ -706,11 +851,10 @@ func (cs *CommunicationService) handleVoiceStream(stream network.Stream) {
 	cs.streamMu.Lock()
 	cs.voiceStreams[remotePeer] = stream
 	cs.streamMu.Unlock()
+	cs.touchSession(remotePeer, VoiceProtocol)
 
 	defer func() {
-		cs.streamMu.Lock()
-		delete(cs.voiceStreams, remotePeer)
-		cs.streamMu.Unlock()
+		cs.removeStream(VoiceProtocol, remotePeer, stream)
 		stream.Close()
 	}()
 
@@ -772,6 +916,11 @@ func (cs *CommunicationService) handleVoiceStream(stream network.Stream) {
 			Timestamp:  time.Now(),
 		}
 
+		// Audio from conference participants goes into their room's mix
+		if cs.bridgeVoiceChunk(remotePeer, chunk) {
+			continue
+		}
+
 		cs.mu.RLock()
 		cb := cs.onVoiceChunk
 		cs.mu.RUnlock()
@@ -833,6 +982,7 @@ func (cs *CommunicationService) getVoiceStream(peerID peer.ID) (network.Stream,
 	cs.streamMu.Lock()
 	cs.voiceStreams[peerID] = newStream
 	cs.streamMu.Unlock()
+	cs.touchSession(peerID, VoiceProtocol)
 
 	cs.wg.Add(1)
 	go func() {
@@ -0,0 +1,421 @@
+package communication
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+// ConferenceProtocol carries room join/leave requests to a conference
+// bridge. Audio itself flows over VoiceProtocol.
+const ConferenceProtocol protocol.ID = "/pangea/conference/1.0.0"
+
+const (
+	// ConferenceMixInterval is the length of audio mixed per tick
+	ConferenceMixInterval = 20 * time.Millisecond
+
+	// Room defaults
+	DefaultConferenceSampleRate      = 48000
+	DefaultConferenceChannels        = 1
+	DefaultConferenceMaxParticipants = 16
+
+	// MaxConferenceGain bounds per-participant gain
+	MaxConferenceGain = 4.0
+
+	// conferenceMaxBufferedFrames bounds the audio buffered per participant;
+	// older audio is dropped so latency cannot grow without limit
+	conferenceMaxBufferedFrames = 10
+)
+
+// ConferenceConfig configures a conference room. Audio in a room is 16-bit
+// little-endian PCM at the room's sample rate and channel count; voice
+// chunks in another format are dropped.
+type ConferenceConfig struct {
+	SampleRate      uint32
+	Channels        uint8
+	MaxParticipants int
+}
+
+// ConferenceParticipant describes a member of a room
+type ConferenceParticipant struct {
+	PeerID string
+	Gain   float64
+	Joined time.Time
+}
+
+// ConferenceRoom describes a room hosted by this node's bridge
+type ConferenceRoom struct {
+	ID           string
+	Config       ConferenceConfig
+	Participants []ConferenceParticipant
+}
+
+// conferenceRequest is a join or leave request and its answer
+type conferenceRequest struct {
+	Action     string `json:"action"` // "join" or "leave"
+	Room       string `json:"room"`
+	Error      string `json:"error,omitempty"`
+	SampleRate uint32 `json:"sampleRate,omitempty"`
+	Channels   uint8  `json:"channels,omitempty"`
+}
+
+// conferenceRoom is a room's mixing state
+type conferenceRoom struct {
+	id           string
+	config       ConferenceConfig
+	participants map[peer.ID]*conferenceParticipant
+	cancel       context.CancelFunc
+}
+
+type conferenceParticipant struct {
+	gain   float64
+	joined time.Time
+	buffer []int16 // Received audio not yet mixed
+}
+
+// mixedAudio is one participant's share of a mixed tick
+type mixedAudio struct {
+	peer peer.ID
+	data []byte
+}
+
+// CreateRoom creates a conference room hosted by this node. Participants
+// join it with JoinRoom or are added with AddParticipant; each hears the
+// mix of everyone else.
+func (cs *CommunicationService) CreateRoom(roomID string, config ConferenceConfig) error {
+	if roomID == "" {
+		return fmt.Errorf("room ID is required")
+	}
+	if config.SampleRate == 0 {
+		config.SampleRate = DefaultConferenceSampleRate
+	}
+	if config.Channels == 0 {
+		config.Channels = DefaultConferenceChannels
+	}
+	if config.MaxParticipants <= 0 {
+		config.MaxParticipants = DefaultConferenceMaxParticipants
+	}
+
+	cs.confMu.Lock()
+	defer cs.confMu.Unlock()
+	if _, exists := cs.rooms[roomID]; exists {
+		return fmt.Errorf("room %s already exists", roomID)
+	}
+	ctx, cancel := context.WithCancel(cs.ctx)
+	room := &conferenceRoom{
+		id:           roomID,
+		config:       config,
+		participants: make(map[peer.ID]*conferenceParticipant),
+		cancel:       cancel,
+	}
+	cs.rooms[roomID] = room
+
+	cs.wg.Add(1)
+	go func() {
+		defer cs.wg.Done()
+		cs.mixLoop(ctx, room)
+	}()
+	log.Printf("🎙️  Conference room %s created (%d Hz, %d ch)", roomID, config.SampleRate, config.Channels)
+	return nil
+}
+
+// CloseRoom closes a room and stops mixing it
+func (cs *CommunicationService) CloseRoom(roomID string) error {
+	cs.confMu.Lock()
+	defer cs.confMu.Unlock()
+	room, ok := cs.rooms[roomID]
+	if !ok {
+		return fmt.Errorf("room %s not found", roomID)
+	}
+	room.cancel()
+	for p := range room.participants {
+		delete(cs.roomOf, p)
+	}
+	delete(cs.rooms, roomID)
+	log.Printf("🎙️  Conference room %s closed", roomID)
+	return nil
+}
+
+// AddParticipant adds a peer to a room. A peer is in at most one room of
+// this bridge at a time.
+func (cs *CommunicationService) AddParticipant(roomID string, p peer.ID) error {
+	cs.confMu.Lock()
+	defer cs.confMu.Unlock()
+	room, ok := cs.rooms[roomID]
+	if !ok {
+		return fmt.Errorf("room %s not found", roomID)
+	}
+	if current, ok := cs.roomOf[p]; ok {
+		if current == roomID {
+			return nil
+		}
+		return fmt.Errorf("peer is already in room %s", current)
+	}
+	if len(room.participants) >= room.config.MaxParticipants {
+		return fmt.Errorf("room %s is full", roomID)
+	}
+	room.participants[p] = &conferenceParticipant{gain: 1, joined: time.Now()}
+	cs.roomOf[p] = roomID
+	log.Printf("🎙️  %s joined room %s (%d participants)", shortID(p), roomID, len(room.participants))
+	return nil
+}
+
+// RemoveParticipant removes a peer from a room
+func (cs *CommunicationService) RemoveParticipant(roomID string, p peer.ID) error {
+	cs.confMu.Lock()
+	defer cs.confMu.Unlock()
+	room, ok := cs.rooms[roomID]
+	if !ok {
+		return fmt.Errorf("room %s not found", roomID)
+	}
+	if _, ok := room.participants[p]; !ok {
+		return fmt.Errorf("peer is not in room %s", roomID)
+	}
+	delete(room.participants, p)
+	delete(cs.roomOf, p)
+	log.Printf("🎙️  %s left room %s", shortID(p), roomID)
+	return nil
+}
+
+// SetParticipantGain sets the gain applied to a participant's audio in the
+// mix; 0 mutes them
+func (cs *CommunicationService) SetParticipantGain(roomID string, p peer.ID, gain float64) error {
+	if gain < 0 || gain > MaxConferenceGain || math.IsNaN(gain) {
+		return fmt.Errorf("gain must be between 0 and %v", MaxConferenceGain)
+	}
+	cs.confMu.Lock()
+	defer cs.confMu.Unlock()
+	room, ok := cs.rooms[roomID]
+	if !ok {
+		return fmt.Errorf("room %s not found", roomID)
+	}
+	part, ok := room.participants[p]
+	if !ok {
+		return fmt.Errorf("peer is not in room %s", roomID)
+	}
+	part.gain = gain
+	return nil
+}
+
+// Rooms lists the rooms hosted by this node
+func (cs *CommunicationService) Rooms() []ConferenceRoom {
+	cs.confMu.Lock()
+	defer cs.confMu.Unlock()
+	out := make([]ConferenceRoom, 0, len(cs.rooms))
+	for _, room := range cs.rooms {
+		info := ConferenceRoom{ID: room.id, Config: room.config}
+		for p, part := range room.participants {
+			info.Participants = append(info.Participants, ConferenceParticipant{PeerID: p.String(), Gain: part.gain, Joined: part.joined})
+		}
+		sort.Slice(info.Participants, func(i, j int) bool { return info.Participants[i].Joined.Before(info.Participants[j].Joined) })
+		out = append(out, info)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// JoinRoom joins a room hosted by a bridge peer and returns the room's
+// audio format. Afterwards, voice chunks sent to the bridge are mixed into
+// the room and the mix arrives through the voice callback.
+func (cs *CommunicationService) JoinRoom(ctx context.Context, bridge peer.ID, roomID string) (ConferenceConfig, error) {
+	resp, err := cs.conferenceRequest(ctx, bridge, conferenceRequest{Action: "join", Room: roomID})
+	if err != nil {
+		return ConferenceConfig{}, err
+	}
+	return ConferenceConfig{SampleRate: resp.SampleRate, Channels: resp.Channels}, nil
+}
+
+// LeaveRoom leaves a room hosted by a bridge peer
+func (cs *CommunicationService) LeaveRoom(ctx context.Context, bridge peer.ID, roomID string) error {
+	_, err := cs.conferenceRequest(ctx, bridge, conferenceRequest{Action: "leave", Room: roomID})
+	return err
+}
+
+// conferenceRequest sends a request to a bridge and returns its answer
+func (cs *CommunicationService) conferenceRequest(ctx context.Context, bridge peer.ID, req conferenceRequest) (*conferenceRequest, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	stream, err := cs.host.NewStream(ctx, bridge, ConferenceProtocol)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach conference bridge: %w", err)
+	}
+	defer stream.Close()
+	stream.SetDeadline(time.Now().Add(10 * time.Second))
+
+	if err := json.NewEncoder(stream).Encode(req); err != nil {
+		return nil, err
+	}
+	var resp conferenceRequest
+	if err := json.NewDecoder(stream).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("no answer from conference bridge: %w", err)
+	}
+	if resp.Error != "" {
+		return nil, fmt.Errorf("conference bridge: %s", resp.Error)
+	}
+	return &resp, nil
+}
+
+// handleConferenceStream answers join and leave requests
+func (cs *CommunicationService) handleConferenceStream(stream network.Stream) {
+	defer stream.Close()
+	stream.SetDeadline(time.Now().Add(10 * time.Second))
+	remotePeer := stream.Conn().RemotePeer()
+
+	var req conferenceRequest
+	if err := json.NewDecoder(stream).Decode(&req); err != nil {
+		return
+	}
+	resp := conferenceRequest{Action: req.Action, Room: req.Room}
+	var err error
+	switch req.Action {
+	case "join":
+		if err = cs.AddParticipant(req.Room, remotePeer); err == nil {
+			cs.confMu.Lock()
+			if room, ok := cs.rooms[req.Room]; ok {
+				resp.SampleRate, resp.Channels = room.config.SampleRate, room.config.Channels
+			}
+			cs.confMu.Unlock()
+		}
+	case "leave":
+		err = cs.RemoveParticipant(req.Room, remotePeer)
+	default:
+		err = fmt.Errorf("unknown action %q", req.Action)
+	}
+	if err != nil {
+		resp.Error = err.Error()
+	}
+	json.NewEncoder(stream).Encode(resp)
+}
+
+// bridgeVoiceChunk takes a voice chunk from a room participant into the
+// room's mix. It reports whether the chunk belonged to a room.
+func (cs *CommunicationService) bridgeVoiceChunk(p peer.ID, chunk VoiceChunk) bool {
+	cs.confMu.Lock()
+	defer cs.confMu.Unlock()
+	roomID, ok := cs.roomOf[p]
+	if !ok {
+		return false
+	}
+	room := cs.rooms[roomID]
+	part := room.participants[p]
+	if chunk.SampleRate != room.config.SampleRate || chunk.Channels != room.config.Channels {
+		return true
+	}
+
+	for i := 0; i+1 < len(chunk.Data); i += 2 {
+		part.buffer = append(part.buffer, int16(binary.LittleEndian.Uint16(chunk.Data[i:])))
+	}
+	if limit := room.frameSamples() * conferenceMaxBufferedFrames; len(part.buffer) > limit {
+		part.buffer = part.buffer[len(part.buffer)-limit:]
+	}
+	return true
+}
+
+// mixLoop mixes a room every ConferenceMixInterval and sends each
+// participant its mix
+func (cs *CommunicationService) mixLoop(ctx context.Context, room *conferenceRoom) {
+	ticker := time.NewTicker(ConferenceMixInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		for _, out := range cs.mixRoom(room) {
+			chunk := VoiceChunk{SampleRate: room.config.SampleRate, Channels: room.config.Channels, Data: out.data}
+			if err := cs.SendVoiceChunk(out.peer, chunk); err != nil && ctx.Err() == nil {
+				log.Printf("⚠️  Failed to send room %s mix to %s: %v", room.id, shortID(out.peer), err)
+			}
+		}
+	}
+}
+
+// mixRoom takes one tick of audio from every participant and returns the
+// mix for each participant that has someone else to hear
+func (cs *CommunicationService) mixRoom(room *conferenceRoom) []mixedAudio {
+	cs.confMu.Lock()
+	n := room.frameSamples()
+	peers := make([]peer.ID, 0, len(room.participants))
+	inputs := make([][]int32, 0, len(room.participants))
+	for p, part := range room.participants {
+		peers = append(peers, p)
+		if len(part.buffer) == 0 {
+			inputs = append(inputs, nil)
+			continue
+		}
+		take := min(n, len(part.buffer))
+		in := make([]int32, n)
+		for i, s := range part.buffer[:take] {
+			in[i] = int32(math.Round(float64(s) * part.gain))
+		}
+		part.buffer = part.buffer[take:]
+		inputs = append(inputs, in)
+	}
+	cs.confMu.Unlock()
+
+	var out []mixedAudio
+	for i, mix := range mixMinus(inputs, n) {
+		if mix == nil {
+			continue
+		}
+		data := make([]byte, 2*len(mix))
+		for j, s := range mix {
+			binary.LittleEndian.PutUint16(data[2*j:], uint16(s))
+		}
+		out = append(out, mixedAudio{peer: peers[i], data: data})
+	}
+	return out
+}
+
+// frameSamples is the number of samples in one mix interval
+func (room *conferenceRoom) frameSamples() int {
+	return int(room.config.SampleRate) * int(room.config.Channels) * int(ConferenceMixInterval/time.Millisecond) / 1000
+}
+
+// mixMinus returns, for each input, the clipped sum of all other inputs of
+// n samples. A nil input is silent; a participant with no other input gets
+// nil.
+func mixMinus(inputs [][]int32, n int) [][]int16 {
+	total := make([]int32, n)
+	active := 0
+	for _, in := range inputs {
+		if in == nil {
+			continue
+		}
+		active++
+		for i, s := range in {
+			total[i] += s
+		}
+	}
+
+	out := make([][]int16, len(inputs))
+	for k, in := range inputs {
+		others := active
+		if in != nil {
+			others--
+		}
+		if others == 0 {
+			continue
+		}
+		mix := make([]int16, n)
+		for i := range mix {
+			s := total[i]
+			if in != nil {
+				s -= in[i]
+			}
+			mix[i] = int16(max(math.MinInt16, min(math.MaxInt16, s)))
+		}
+		out[k] = mix
+	}
+	return out
+}
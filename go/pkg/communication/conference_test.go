@@ -0,0 +1,113 @@
+package communication
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestMixMinus(t *testing.T) {
+	inputs := [][]int32{{100, 30000}, {200, 30000}, nil, {-50, 30000}}
+	out := mixMinus(inputs, 2)
+
+	// Each participant hears everyone but themselves
+	if out[0][0] != 150 || out[1][0] != 50 || out[3][0] != 300 {
+		t.Fatalf("unexpected mix: %v", out)
+	}
+	// A silent participant hears everyone
+	if out[2][0] != 250 {
+		t.Fatalf("silent participant got %d, expected 250", out[2][0])
+	}
+	// Sums are clipped to the 16-bit range
+	if out[2][1] != math.MaxInt16 {
+		t.Fatalf("expected clipping, got %d", out[2][1])
+	}
+	// Nobody else speaking means nothing to send
+	if alone := mixMinus([][]int32{{1, 2}, nil}, 2); alone[0] != nil {
+		t.Fatalf("lone speaker should get no mix, got %v", alone[0])
+	}
+}
+
+func TestConferenceBridgeMixesParticipants(t *testing.T) {
+	bridge, hBridge := newTestService(t, 5*time.Second)
+	csB, hB := newTestService(t, 5*time.Second)
+	csC, hC := newTestService(t, 5*time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	bridgeInfo := peer.AddrInfo{ID: hBridge.ID(), Addrs: hBridge.Addrs()}
+	for _, h := range []host.Host{hB, hC} {
+		if err := h.Connect(ctx, bridgeInfo); err != nil {
+			t.Fatalf("connect failed: %v", err)
+		}
+	}
+
+	if err := bridge.CreateRoom("standup", ConferenceConfig{SampleRate: 8000}); err != nil {
+		t.Fatalf("CreateRoom failed: %v", err)
+	}
+	if _, err := csB.JoinRoom(ctx, hBridge.ID(), "missing"); err == nil {
+		t.Fatal("joined a room that does not exist")
+	}
+	for _, cs := range []*CommunicationService{csB, csC} {
+		config, err := cs.JoinRoom(ctx, hBridge.ID(), "standup")
+		if err != nil {
+			t.Fatalf("JoinRoom failed: %v", err)
+		}
+		if config.SampleRate != 8000 || config.Channels != 1 {
+			t.Fatalf("unexpected room format: %+v", config)
+		}
+	}
+	if rooms := bridge.Rooms(); len(rooms) != 1 || len(rooms[0].Participants) != 2 {
+		t.Fatalf("expected one room with two participants, got %+v", rooms)
+	}
+	if err := bridge.SetParticipantGain("standup", hC.ID(), 2); err != nil {
+		t.Fatalf("SetParticipantGain failed: %v", err)
+	}
+
+	heardByB := make(chan int16, 64)
+	csB.SetVoiceCallback(func(peerID string, chunk VoiceChunk) {
+		if peerID == hBridge.ID().String() && len(chunk.Data) >= 2 {
+			heardByB <- int16(binary.LittleEndian.Uint16(chunk.Data))
+		}
+	})
+
+	// One 20ms frame of constant PCM from each participant
+	frame := func(value int16) VoiceChunk {
+		data := make([]byte, 2*160)
+		for i := 0; i < len(data); i += 2 {
+			binary.LittleEndian.PutUint16(data[i:], uint16(value))
+		}
+		return VoiceChunk{SampleRate: 8000, Channels: 1, Data: data}
+	}
+	if err := csB.SendVoiceChunk(hBridge.ID(), frame(1000)); err != nil {
+		t.Fatalf("SendVoiceChunk failed: %v", err)
+	}
+	if err := csC.SendVoiceChunk(hBridge.ID(), frame(500)); err != nil {
+		t.Fatalf("SendVoiceChunk failed: %v", err)
+	}
+
+	// B hears only C, at twice C's level
+	select {
+	case sample := <-heardByB:
+		if sample != 1000 {
+			t.Fatalf("B heard %d, expected 1000", sample)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("B heard nothing from the bridge")
+	}
+
+	if err := csB.LeaveRoom(ctx, hBridge.ID(), "standup"); err != nil {
+		t.Fatalf("LeaveRoom failed: %v", err)
+	}
+	if err := bridge.CloseRoom("standup"); err != nil {
+		t.Fatalf("CloseRoom failed: %v", err)
+	}
+	if len(bridge.Rooms()) != 0 {
+		t.Fatal("room still listed after closing")
+	}
+}
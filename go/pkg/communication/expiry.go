@@ -0,0 +1,152 @@
+package communication
+
+import (
+	"log"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+const (
+	// ChatFrameDelete marks a chat frame asking the peer to delete messages
+	ChatFrameDelete = "delete"
+
+	// ChatExpiryInterval is how often expired messages are purged
+	ChatExpiryInterval = time.Second
+)
+
+// SetChatTTL sets the disappearing-message policy for the session with a
+// peer. Messages sent afterwards carry the TTL, so the peer applies it too.
+// A zero TTL keeps messages until they are deleted.
+func (cs *CommunicationService) SetChatTTL(p peer.ID, ttl time.Duration) {
+	if ttl < 0 {
+		ttl = 0
+	}
+	cs.sessionMu.Lock()
+	defer cs.sessionMu.Unlock()
+	cs.getSessionLocked(p).ttl = ttl
+}
+
+// ChatTTL returns the disappearing-message policy for a peer's session
+func (cs *CommunicationService) ChatTTL(p peer.ID) time.Duration {
+	cs.sessionMu.RLock()
+	defer cs.sessionMu.RUnlock()
+	if sess, ok := cs.sessions[p]; ok {
+		return sess.ttl
+	}
+	return 0
+}
+
+// recordPeerTTL adopts the TTL a peer attached to its messages
+func (cs *CommunicationService) recordPeerTTL(p peer.ID, ttl time.Duration) {
+	cs.sessionMu.Lock()
+	defer cs.sessionMu.Unlock()
+	sess := cs.getSessionLocked(p)
+	if sess.ttl != ttl {
+		sess.ttl = ttl
+		log.Printf("⏳ Chat with %s now uses disappearing messages (TTL %v)", shortID(p), ttl)
+	}
+}
+
+// expiryLoop purges expired messages until the service stops
+func (cs *CommunicationService) expiryLoop() {
+	defer cs.wg.Done()
+
+	ticker := time.NewTicker(ChatExpiryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-cs.ctx.Done():
+			return
+		case <-ticker.C:
+			cs.purgeExpired(time.Now())
+		}
+	}
+}
+
+// purgeExpired deletes expired messages from history (and, via the next
+// save, from disk), then tells each peer which of our messages to delete
+func (cs *CommunicationService) purgeExpired(now time.Time) {
+	self := cs.host.ID().String()
+	sent := make(map[string][]string) // peer -> IDs of our expired messages
+
+	cs.historyMu.Lock()
+	removed := 0
+	for peerID, history := range cs.chatHistory {
+		kept := history[:0]
+		for _, msg := range history {
+			if msg.ExpiresAt.IsZero() || now.Before(msg.ExpiresAt) {
+				kept = append(kept, msg)
+				continue
+			}
+			removed++
+			if msg.From == self {
+				sent[peerID] = append(sent[peerID], msg.ID)
+			}
+		}
+		if len(kept) == 0 {
+			delete(cs.chatHistory, peerID)
+		} else {
+			cs.chatHistory[peerID] = kept
+		}
+	}
+	cs.historyMu.Unlock()
+
+	if removed == 0 {
+		return
+	}
+	cs.requestSave()
+
+	for peerStr, ids := range sent {
+		p, err := peer.Decode(peerStr)
+		if err != nil {
+			continue
+		}
+		cs.streamMu.RLock()
+		stream := cs.chatStreams[p]
+		cs.streamMu.RUnlock()
+		if stream == nil {
+			// The peer expires its copies on its own timer
+			continue
+		}
+		frame := ChatMessage{From: self, To: peerStr, Timestamp: now, Type: ChatFrameDelete, DeleteIDs: ids}
+		if err := cs.writeChatFrame(stream, frame); err != nil {
+			log.Printf("⚠️  Failed to signal deletion of %d messages to %s: %v", len(ids), shortID(p), err)
+		}
+	}
+}
+
+// deleteRemoteMessages deletes messages a peer asked us to remove. Only
+// messages that peer sent are deleted.
+func (cs *CommunicationService) deleteRemoteMessages(p peer.ID, ids []string) {
+	if len(ids) == 0 {
+		return
+	}
+	remove := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		remove[id] = true
+	}
+	peerStr := p.String()
+
+	cs.historyMu.Lock()
+	history := cs.chatHistory[peerStr]
+	kept := history[:0]
+	for _, msg := range history {
+		if msg.From == peerStr && remove[msg.ID] {
+			continue
+		}
+		kept = append(kept, msg)
+	}
+	removed := len(history) - len(kept)
+	if len(kept) == 0 {
+		delete(cs.chatHistory, peerStr)
+	} else {
+		cs.chatHistory[peerStr] = kept
+	}
+	cs.historyMu.Unlock()
+
+	if removed > 0 {
+		cs.requestSave()
+		log.Printf("🗑️  Deleted %d expired messages from %s at their request", removed, shortID(p))
+	}
+}
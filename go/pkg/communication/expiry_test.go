@@ -0,0 +1,65 @@
+package communication
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDisappearingMessagesExpireOnBothPeers(t *testing.T) {
+	csA, csB, bID, received := connectedPair(t)
+	aID := csA.GetHost().ID()
+
+	csA.SetChatTTL(bID, 300*time.Millisecond)
+	if err := csA.SendChatMessage(bID, "secret"); err != nil {
+		t.Fatalf("send failed: %v", err)
+	}
+	msg := expectMessage(t, received, "secret")
+	if msg.TTL != 300*time.Millisecond || msg.ExpiresAt.IsZero() {
+		t.Fatalf("expected TTL on received message, got ttl=%v expires=%v", msg.TTL, msg.ExpiresAt)
+	}
+	if ttl := csB.ChatTTL(aID); ttl != 300*time.Millisecond {
+		t.Errorf("expected receiver session to record TTL, got %v", ttl)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(csA.GetChatHistory(bID.String())) > 0 || len(csB.GetChatHistory(aID.String())) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected message to disappear from both histories")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	// Deletion is also persisted
+	csB.saveChatHistory()
+	data, err := os.ReadFile(csB.GetChatHistoryFilePath())
+	if err != nil {
+		t.Fatalf("read history: %v", err)
+	}
+	if strings.Contains(string(data), "secret") {
+		t.Error("expired message still in persisted history")
+	}
+}
+
+func TestDeleteFrameOnlyRemovesSendersMessages(t *testing.T) {
+	csA, csB, bID, received := connectedPair(t)
+	aID := csA.GetHost().ID()
+
+	if err := csA.SendChatMessage(bID, "mine"); err != nil {
+		t.Fatalf("send failed: %v", err)
+	}
+	msg := expectMessage(t, received, "mine")
+
+	// A peer cannot delete messages it did not send
+	own := ChatMessage{ID: "local-1", From: bID.String(), To: aID.String(), Content: "reply", Timestamp: time.Now()}
+	csB.addToHistory(own)
+	csB.deleteRemoteMessages(aID, []string{own.ID, msg.ID})
+
+	history := csB.GetChatHistory(aID.String())
+	if len(history) != 1 || history[0].ID != own.ID {
+		data, _ := json.Marshal(history)
+		t.Fatalf("expected only the local message to remain, got %s", data)
+	}
+}
@@ -0,0 +1,375 @@
+package communication
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultSpamRateLimit is how many messages a peer may send per
+	// DefaultSpamRateWindow before further messages are treated as spam
+	DefaultSpamRateLimit  = 20
+	DefaultSpamRateWindow = 10 * time.Second
+
+	// DefaultClassifierTimeout bounds how long a message waits for an
+	// external classifier before it is delivered unclassified
+	DefaultClassifierTimeout = 2 * time.Second
+
+	// MaxQuarantinedMessages caps the review queue; the oldest are dropped
+	MaxQuarantinedMessages = 1000
+
+	// TagPriority marks messages from allow-listed peers
+	TagPriority = "priority"
+)
+
+// FilterAction is what the chat filter does with an incoming message
+type FilterAction string
+
+const (
+	FilterDeliver    FilterAction = "deliver"
+	FilterDrop       FilterAction = "drop"
+	FilterQuarantine FilterAction = "quarantine"
+)
+
+// ParseFilterAction converts an action name from RPC callers
+func ParseFilterAction(s string) (FilterAction, error) {
+	switch a := FilterAction(s); a {
+	case FilterDeliver, FilterDrop, FilterQuarantine:
+		return a, nil
+	default:
+		return "", fmt.Errorf("unknown filter action %q", s)
+	}
+}
+
+// PeerPolicy overrides filtering for a single peer
+type PeerPolicy string
+
+const (
+	PeerPolicyNone  PeerPolicy = ""
+	PeerPolicyAllow PeerPolicy = "allow" // Always delivered, tagged priority
+	PeerPolicyDeny  PeerPolicy = "deny"  // Always dropped
+)
+
+// ParsePeerPolicy converts a policy name from RPC callers
+func ParsePeerPolicy(s string) (PeerPolicy, error) {
+	switch p := PeerPolicy(s); p {
+	case PeerPolicyNone, PeerPolicyAllow, PeerPolicyDeny:
+		return p, nil
+	case "none":
+		return PeerPolicyNone, nil
+	default:
+		return "", fmt.Errorf("unknown peer policy %q", s)
+	}
+}
+
+// FilterVerdict is the outcome of filtering one message
+type FilterVerdict struct {
+	Action FilterAction
+	Reason string
+	Tags   []string
+}
+
+// Classifier decides what to do with a message that passed the peer and
+// rate checks. Errors deliver the message unclassified.
+type Classifier interface {
+	Classify(msg ChatMessage) (FilterVerdict, error)
+}
+
+// ClassifierFunc adapts a function to the Classifier interface
+type ClassifierFunc func(msg ChatMessage) (FilterVerdict, error)
+
+// Classify implements Classifier
+func (f ClassifierFunc) Classify(msg ChatMessage) (FilterVerdict, error) {
+	return f(msg)
+}
+
+// FilterConfig configures rate-based spam detection for incoming chat
+type FilterConfig struct {
+	RateLimit  int           // Messages per RateWindow before spam (0 = disabled)
+	RateWindow time.Duration // Window for RateLimit
+	SpamAction FilterAction  // What to do with spam (default quarantine)
+}
+
+// DefaultFilterConfig returns the default spam thresholds
+func DefaultFilterConfig() FilterConfig {
+	return FilterConfig{
+		RateLimit:  DefaultSpamRateLimit,
+		RateWindow: DefaultSpamRateWindow,
+		SpamAction: FilterQuarantine,
+	}
+}
+
+// QuarantinedMessage is a message held for review instead of being delivered
+type QuarantinedMessage struct {
+	Key     string // Unique key used by the review methods
+	Message ChatMessage
+	Reason  string
+	Held    time.Time
+}
+
+// ErrNotQuarantined is returned when reviewing a message that is not held
+var ErrNotQuarantined = errors.New("message not in quarantine")
+
+// rateWindow counts messages from one peer in the current fixed window
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+// chatFilter holds the incoming chat filtering pipeline state
+type chatFilter struct {
+	config     FilterConfig
+	policies   map[string]PeerPolicy
+	rates      map[string]*rateWindow
+	classifier Classifier
+	quarantine map[string]*QuarantinedMessage
+	order      []string // Quarantine keys, oldest first
+	mu         sync.Mutex
+}
+
+func newChatFilter() *chatFilter {
+	return &chatFilter{
+		config:     DefaultFilterConfig(),
+		policies:   make(map[string]PeerPolicy),
+		rates:      make(map[string]*rateWindow),
+		quarantine: make(map[string]*QuarantinedMessage),
+	}
+}
+
+// SetFilterConfig replaces the spam detection thresholds
+func (cs *CommunicationService) SetFilterConfig(config FilterConfig) {
+	if config.SpamAction == "" {
+		config.SpamAction = FilterQuarantine
+	}
+	cs.filter.mu.Lock()
+	defer cs.filter.mu.Unlock()
+	cs.filter.config = config
+	cs.filter.rates = make(map[string]*rateWindow)
+}
+
+// SetPeerPolicy allow-lists or deny-lists a peer (PeerPolicyNone clears it)
+func (cs *CommunicationService) SetPeerPolicy(peerID string, policy PeerPolicy) {
+	cs.filter.mu.Lock()
+	defer cs.filter.mu.Unlock()
+	if policy == PeerPolicyNone {
+		delete(cs.filter.policies, peerID)
+		return
+	}
+	cs.filter.policies[peerID] = policy
+}
+
+// SetClassifier installs an optional classifier run on messages that pass
+// the peer and rate checks (nil removes it)
+func (cs *CommunicationService) SetClassifier(c Classifier) {
+	cs.filter.mu.Lock()
+	defer cs.filter.mu.Unlock()
+	cs.filter.classifier = c
+}
+
+// GetClassifier returns the installed classifier, if any
+func (cs *CommunicationService) GetClassifier() Classifier {
+	cs.filter.mu.Lock()
+	defer cs.filter.mu.Unlock()
+	return cs.filter.classifier
+}
+
+// filterIncoming runs the pipeline on a received message: peer policy, then
+// rate-based spam detection, then the classifier
+func (cs *CommunicationService) filterIncoming(msg ChatMessage) FilterVerdict {
+	f := cs.filter
+	f.mu.Lock()
+	switch f.policies[msg.From] {
+	case PeerPolicyDeny:
+		f.mu.Unlock()
+		return FilterVerdict{Action: FilterDrop, Reason: "peer denied"}
+	case PeerPolicyAllow:
+		f.mu.Unlock()
+		return FilterVerdict{Action: FilterDeliver, Tags: []string{TagPriority}}
+	}
+
+	if f.config.RateLimit > 0 {
+		now := time.Now()
+		w, ok := f.rates[msg.From]
+		if !ok || now.Sub(w.start) >= f.config.RateWindow {
+			w = &rateWindow{start: now}
+			f.rates[msg.From] = w
+		}
+		w.count++
+		if w.count > f.config.RateLimit {
+			action := f.config.SpamAction
+			f.mu.Unlock()
+			return FilterVerdict{Action: action, Reason: "rate limit exceeded"}
+		}
+	}
+	classifier := f.classifier
+	f.mu.Unlock()
+
+	if classifier == nil {
+		return FilterVerdict{Action: FilterDeliver}
+	}
+	verdict, err := classifier.Classify(msg)
+	if err != nil {
+		log.Printf("⚠️  Chat classifier failed for message from %s, delivering: %v", shortKey(msg.From), err)
+		return FilterVerdict{Action: FilterDeliver}
+	}
+	if verdict.Action == "" {
+		verdict.Action = FilterDeliver
+	}
+	return verdict
+}
+
+// quarantineMessage holds a message for review, evicting the oldest when full
+func (cs *CommunicationService) quarantineMessage(msg ChatMessage, reason string) {
+	f := cs.filter
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key := messageKey(msg)
+	if _, exists := f.quarantine[key]; !exists {
+		f.order = append(f.order, key)
+	}
+	f.quarantine[key] = &QuarantinedMessage{Key: key, Message: msg, Reason: reason, Held: time.Now()}
+	for len(f.order) > MaxQuarantinedMessages {
+		delete(f.quarantine, f.order[0])
+		f.order = f.order[1:]
+	}
+}
+
+// GetQuarantinedMessages returns messages held for review, oldest first
+func (cs *CommunicationService) GetQuarantinedMessages() []QuarantinedMessage {
+	f := cs.filter
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := make([]QuarantinedMessage, 0, len(f.order))
+	for _, key := range f.order {
+		out = append(out, *f.quarantine[key])
+	}
+	return out
+}
+
+// ReleaseQuarantined delivers a held message to history and the chat callback
+func (cs *CommunicationService) ReleaseQuarantined(key string) error {
+	q, err := cs.takeQuarantined(key)
+	if err != nil {
+		return err
+	}
+	cs.deliverChat(q.Message)
+	return nil
+}
+
+// DeleteQuarantined discards a held message
+func (cs *CommunicationService) DeleteQuarantined(key string) error {
+	_, err := cs.takeQuarantined(key)
+	return err
+}
+
+func (cs *CommunicationService) takeQuarantined(key string) (*QuarantinedMessage, error) {
+	f := cs.filter
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	q, ok := f.quarantine[key]
+	if !ok {
+		return nil, ErrNotQuarantined
+	}
+	delete(f.quarantine, key)
+	for i, k := range f.order {
+		if k == key {
+			f.order = append(f.order[:i], f.order[i+1:]...)
+			break
+		}
+	}
+	return q, nil
+}
+
+// messageKey identifies a received message; sender IDs are only unique per peer
+func messageKey(msg ChatMessage) string {
+	return msg.From + "/" + msg.ID
+}
+
+func shortKey(s string) string {
+	if len(s) > 12 {
+		return s[:12]
+	}
+	return s
+}
+
+// QueueClassifier is a Classifier answered by an external process that polls
+// for pending messages over RPC and posts verdicts back. Messages that are
+// not classified within the timeout are delivered unclassified.
+type QueueClassifier struct {
+	timeout time.Duration
+	pending map[string]*pendingClassification
+	mu      sync.Mutex
+}
+
+type pendingClassification struct {
+	msg     ChatMessage
+	queued  time.Time
+	verdict chan FilterVerdict
+}
+
+// NewQueueClassifier creates a classifier that waits up to timeout for a verdict
+func NewQueueClassifier(timeout time.Duration) *QueueClassifier {
+	if timeout <= 0 {
+		timeout = DefaultClassifierTimeout
+	}
+	return &QueueClassifier{
+		timeout: timeout,
+		pending: make(map[string]*pendingClassification),
+	}
+}
+
+// Classify implements Classifier by queueing msg until Resolve is called
+func (q *QueueClassifier) Classify(msg ChatMessage) (FilterVerdict, error) {
+	key := messageKey(msg)
+	p := &pendingClassification{msg: msg, queued: time.Now(), verdict: make(chan FilterVerdict, 1)}
+
+	q.mu.Lock()
+	q.pending[key] = p
+	q.mu.Unlock()
+	defer func() {
+		q.mu.Lock()
+		if q.pending[key] == p {
+			delete(q.pending, key)
+		}
+		q.mu.Unlock()
+	}()
+
+	select {
+	case v := <-p.verdict:
+		return v, nil
+	case <-time.After(q.timeout):
+		return FilterVerdict{}, fmt.Errorf("no verdict within %v", q.timeout)
+	}
+}
+
+// Pending returns messages awaiting a verdict with their keys, oldest first
+func (q *QueueClassifier) Pending() []QuarantinedMessage {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	out := make([]QuarantinedMessage, 0, len(q.pending))
+	for key, p := range q.pending {
+		out = append(out, QuarantinedMessage{Key: key, Message: p.msg, Reason: "awaiting classification", Held: p.queued})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Held.Before(out[j].Held) })
+	return out
+}
+
+// Resolve posts the verdict for a pending message
+func (q *QueueClassifier) Resolve(key string, verdict FilterVerdict) error {
+	q.mu.Lock()
+	p, ok := q.pending[key]
+	if ok {
+		delete(q.pending, key)
+	}
+	q.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("message %s is not awaiting classification", key)
+	}
+	p.verdict <- verdict
+	return nil
+}
@@ -0,0 +1,146 @@
+package communication
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func connectedPair(t *testing.T) (*CommunicationService, *CommunicationService, peer.ID, chan ChatMessage) {
+	t.Helper()
+	csA, hA := newTestService(t, time.Second)
+	csB, hB := newTestService(t, time.Second)
+
+	received := make(chan ChatMessage, 16)
+	csB.SetChatCallback(func(msg ChatMessage) { received <- msg })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := hA.Connect(ctx, peer.AddrInfo{ID: hB.ID(), Addrs: hB.Addrs()}); err != nil {
+		t.Fatalf("connect failed: %v", err)
+	}
+	return csA, csB, hB.ID(), received
+}
+
+func expectMessage(t *testing.T, received <-chan ChatMessage, content string) ChatMessage {
+	t.Helper()
+	select {
+	case msg := <-received:
+		if msg.Content != content {
+			t.Fatalf("expected %q, got %q", content, msg.Content)
+		}
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for %q", content)
+	}
+	return ChatMessage{}
+}
+
+func TestChatFilterPeerPolicies(t *testing.T) {
+	csA, csB, bID, received := connectedPair(t)
+	aID := csA.GetHost().ID().String()
+
+	csB.SetPeerPolicy(aID, PeerPolicyDeny)
+	if err := csA.SendChatMessage(bID, "denied"); err != nil {
+		t.Fatalf("send failed: %v", err)
+	}
+	if v := csB.filterIncoming(ChatMessage{From: aID}); v.Action != FilterDrop {
+		t.Fatalf("expected denied peer to be dropped, got %+v", v)
+	}
+
+	// Wait until the denied message has been read before changing policy
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, recv := csB.ChatSequence(csA.GetHost().ID()); recv == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("denied message was never received")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	csB.SetPeerPolicy(aID, PeerPolicyAllow)
+	if err := csA.SendChatMessage(bID, "priority"); err != nil {
+		t.Fatalf("send failed: %v", err)
+	}
+
+	// The denied message never reaches the callback or history
+	msg := expectMessage(t, received, "priority")
+	if len(msg.Tags) != 1 || msg.Tags[0] != TagPriority {
+		t.Errorf("expected priority tag, got %v", msg.Tags)
+	}
+	if history := csB.GetChatHistory(aID); len(history) != 1 {
+		t.Errorf("expected only the allowed message in history, got %d", len(history))
+	}
+}
+
+func TestChatFilterQuarantinesSpam(t *testing.T) {
+	csA, csB, bID, received := connectedPair(t)
+	csB.SetFilterConfig(FilterConfig{RateLimit: 1, RateWindow: time.Minute})
+
+	for _, content := range []string{"first", "spam"} {
+		if err := csA.SendChatMessage(bID, content); err != nil {
+			t.Fatalf("send failed: %v", err)
+		}
+	}
+	expectMessage(t, received, "first")
+
+	var held []QuarantinedMessage
+	deadline := time.Now().Add(5 * time.Second)
+	for len(held) == 0 && time.Now().Before(deadline) {
+		held = csB.GetQuarantinedMessages()
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(held) != 1 || held[0].Message.Content != "spam" {
+		t.Fatalf("expected spam to be quarantined, got %+v", held)
+	}
+
+	if err := csB.ReleaseQuarantined(held[0].Key); err != nil {
+		t.Fatalf("release failed: %v", err)
+	}
+	expectMessage(t, received, "spam")
+	if err := csB.DeleteQuarantined(held[0].Key); err != ErrNotQuarantined {
+		t.Errorf("expected ErrNotQuarantined after release, got %v", err)
+	}
+}
+
+func TestQueueClassifierVerdicts(t *testing.T) {
+	csA, csB, bID, received := connectedPair(t)
+	q := NewQueueClassifier(5 * time.Second)
+	csB.SetClassifier(q)
+
+	go func() {
+		for _, content := range []string{"tagged", "blocked"} {
+			csA.SendChatMessage(bID, content)
+		}
+	}()
+
+	for _, want := range []FilterAction{FilterDeliver, FilterDrop} {
+		var pending []QuarantinedMessage
+		deadline := time.Now().Add(5 * time.Second)
+		for len(pending) == 0 && time.Now().Before(deadline) {
+			pending = q.Pending()
+			time.Sleep(10 * time.Millisecond)
+		}
+		if len(pending) != 1 {
+			t.Fatalf("expected one pending message, got %d", len(pending))
+		}
+		if err := q.Resolve(pending[0].Key, FilterVerdict{Action: want, Tags: []string{"checked"}}); err != nil {
+			t.Fatalf("resolve failed: %v", err)
+		}
+	}
+
+	msg := expectMessage(t, received, "tagged")
+	if len(msg.Tags) != 1 || msg.Tags[0] != "checked" {
+		t.Errorf("expected classifier tags, got %v", msg.Tags)
+	}
+	select {
+	case msg := <-received:
+		t.Errorf("dropped message was delivered: %q", msg.Content)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
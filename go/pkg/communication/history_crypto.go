@@ -0,0 +1,281 @@
+package communication
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Encrypted history files are laid out as magic | version | salt | nonce |
+// ciphertext, where the ciphertext is the JSON history sealed with
+// AES-256-GCM under a key derived from the passphrase with Argon2id
+const (
+	historyMagic         = "PGCH"
+	historyVersion       = 1
+	historySaltSize      = 16
+	historyKeySize       = 32
+	minHistoryPassphrase = 8
+
+	// Argon2id parameters (RFC 9106 second recommended option)
+	historyArgonTime    = 3
+	historyArgonMemory  = 64 * 1024 // KiB
+	historyArgonThreads = 4
+)
+
+var (
+	// ErrHistoryLocked is returned while encrypted history waits for its
+	// passphrase
+	ErrHistoryLocked = errors.New("chat history is locked")
+
+	// ErrBadHistoryPassphrase is returned when history cannot be decrypted
+	ErrBadHistoryPassphrase = errors.New("wrong passphrase or corrupted chat history")
+)
+
+// HistoryEncryption reports whether the history file is encrypted and, if
+// so, whether it is still locked
+func (cs *CommunicationService) HistoryEncryption() (encrypted, locked bool) {
+	cs.historyMu.RLock()
+	defer cs.historyMu.RUnlock()
+	return cs.historySalt != nil, cs.historyLocked
+}
+
+// SetHistoryPassphrase enables, changes or disables encryption of the
+// history file. With plaintext history, oldPassphrase must be empty; with
+// encrypted history it must be the current passphrase. An empty
+// newPassphrase stores history in plaintext again. The file is rewritten
+// under the new key before this returns.
+func (cs *CommunicationService) SetHistoryPassphrase(oldPassphrase, newPassphrase string) error {
+	if newPassphrase != "" && len(newPassphrase) < minHistoryPassphrase {
+		return fmt.Errorf("passphrase must be at least %d characters", minHistoryPassphrase)
+	}
+
+	cs.historyMu.Lock()
+	defer cs.historyMu.Unlock()
+
+	switch {
+	case cs.historySalt == nil && oldPassphrase != "":
+		return fmt.Errorf("chat history is not encrypted")
+	case cs.historySalt == nil && newPassphrase == "":
+		return nil
+	case cs.historyLocked:
+		if err := cs.unlockLocked(oldPassphrase); err != nil {
+			return err
+		}
+	case cs.historySalt != nil:
+		key := deriveHistoryKey(oldPassphrase, cs.historySalt)
+		defer clear(key)
+		if subtle.ConstantTimeCompare(key, cs.historyKey) != 1 {
+			return ErrBadHistoryPassphrase
+		}
+	}
+
+	clear(cs.historyKey)
+	cs.historyKey, cs.historySalt = nil, nil
+	if newPassphrase != "" {
+		cs.historySalt = make([]byte, historySaltSize)
+		if _, err := rand.Read(cs.historySalt); err != nil {
+			return err
+		}
+		cs.historyKey = deriveHistoryKey(newPassphrase, cs.historySalt)
+	}
+	if err := cs.writeChatHistoryLocked(); err != nil {
+		return err
+	}
+
+	if newPassphrase == "" {
+		log.Printf("🔓 Chat history is now stored in plaintext")
+	} else {
+		log.Printf("🔒 Chat history re-encrypted under a new passphrase")
+	}
+	return nil
+}
+
+// UnlockHistory decrypts encrypted history with its passphrase. Messages
+// that arrived while it was locked are kept after the stored ones.
+func (cs *CommunicationService) UnlockHistory(passphrase string) error {
+	cs.historyMu.Lock()
+	defer cs.historyMu.Unlock()
+
+	if cs.historySalt == nil {
+		return fmt.Errorf("chat history is not encrypted")
+	}
+	if !cs.historyLocked {
+		key := deriveHistoryKey(passphrase, cs.historySalt)
+		defer clear(key)
+		if subtle.ConstantTimeCompare(key, cs.historyKey) != 1 {
+			return ErrBadHistoryPassphrase
+		}
+		return nil
+	}
+	if err := cs.unlockLocked(passphrase); err != nil {
+		return err
+	}
+	cs.requestSave()
+	log.Printf("🔓 Chat history unlocked")
+	return nil
+}
+
+// LockHistory writes encrypted history to disk, then forgets its key and
+// the messages held in memory until it is unlocked again
+func (cs *CommunicationService) LockHistory() error {
+	cs.historyMu.Lock()
+	defer cs.historyMu.Unlock()
+
+	if cs.historySalt == nil {
+		return fmt.Errorf("chat history is not encrypted")
+	}
+	if cs.historyLocked {
+		return nil
+	}
+	if err := cs.writeChatHistoryLocked(); err != nil {
+		return err
+	}
+	clear(cs.historyKey)
+	cs.historyKey = nil
+	cs.chatHistory = make(map[string][]ChatMessage)
+	cs.historyLocked = true
+	log.Printf("🔒 Chat history locked")
+	return nil
+}
+
+// unlockLocked decrypts the history file and merges it with the messages
+// in memory. Caller must hold historyMu.
+func (cs *CommunicationService) unlockLocked(passphrase string) error {
+	data, err := os.ReadFile(cs.chatHistoryFile)
+	if err != nil {
+		return fmt.Errorf("failed to read chat history: %w", err)
+	}
+	stored, salt, key, err := openHistory(data, passphrase)
+	if err != nil {
+		return err
+	}
+
+	for peerID, recent := range cs.chatHistory {
+		merged := append(stored[peerID], recent...)
+		if len(merged) > maxHistoryPerPeer {
+			merged = merged[len(merged)-maxHistoryPerPeer:]
+		}
+		stored[peerID] = merged
+	}
+	cs.chatHistory = stored
+	cs.historySalt, cs.historyKey = salt, key
+	cs.historyLocked = false
+	return nil
+}
+
+// writeChatHistoryLocked writes history to disk, sealed if a key is set.
+// Caller must hold historyMu.
+func (cs *CommunicationService) writeChatHistoryLocked() error {
+	if cs.historyLocked {
+		return ErrHistoryLocked
+	}
+	data, err := json.MarshalIndent(cs.chatHistory, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize chat history: %w", err)
+	}
+
+	perm := os.FileMode(0644)
+	if cs.historyKey != nil {
+		if data, err = sealHistory(data, cs.historyKey, cs.historySalt); err != nil {
+			return err
+		}
+		perm = 0600
+	}
+
+	tmp := cs.chatHistoryFile + ".tmp"
+	if err := os.WriteFile(tmp, data, perm); err != nil {
+		return fmt.Errorf("failed to write chat history: %w", err)
+	}
+	if err := os.Rename(tmp, cs.chatHistoryFile); err != nil {
+		return fmt.Errorf("failed to replace chat history: %w", err)
+	}
+	return nil
+}
+
+// isEncryptedHistory reports whether a history file was written sealed
+func isEncryptedHistory(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(historyMagic))
+}
+
+// sealHistory encrypts serialized history
+func sealHistory(plaintext, key, salt []byte) ([]byte, error) {
+	aead, err := historyAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	header := append([]byte(historyMagic), historyVersion)
+	out := append(header, salt...)
+	out = append(out, nonce...)
+	// The header is authenticated so the version cannot be swapped
+	return aead.Seal(out, nonce, plaintext, header), nil
+}
+
+// openHistory decrypts a history file, returning the history with the
+// file's salt and the derived key
+func openHistory(data []byte, passphrase string) (map[string][]ChatMessage, []byte, []byte, error) {
+	headerLen := len(historyMagic) + 1
+	if len(data) < headerLen+historySaltSize || !isEncryptedHistory(data) {
+		return nil, nil, nil, fmt.Errorf("chat history is not encrypted")
+	}
+	if version := data[len(historyMagic)]; version != historyVersion {
+		return nil, nil, nil, fmt.Errorf("unsupported chat history version %d", version)
+	}
+
+	header := data[:headerLen]
+	salt := bytes.Clone(data[headerLen : headerLen+historySaltSize])
+	key := deriveHistoryKey(passphrase, salt)
+	aead, err := historyAEAD(key)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	rest := data[headerLen+historySaltSize:]
+	if len(rest) < aead.NonceSize() {
+		return nil, nil, nil, fmt.Errorf("chat history truncated")
+	}
+	plaintext, err := aead.Open(nil, rest[:aead.NonceSize()], rest[aead.NonceSize():], header)
+	if err != nil {
+		clear(key)
+		return nil, nil, nil, ErrBadHistoryPassphrase
+	}
+
+	history := make(map[string][]ChatMessage)
+	if err := json.Unmarshal(plaintext, &history); err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid chat history contents: %w", err)
+	}
+	return history, salt, key, nil
+}
+
+// readHistorySalt returns the salt of an encrypted history file
+func readHistorySalt(data []byte) ([]byte, error) {
+	headerLen := len(historyMagic) + 1
+	if len(data) < headerLen+historySaltSize {
+		return nil, fmt.Errorf("chat history truncated")
+	}
+	return bytes.Clone(data[headerLen : headerLen+historySaltSize]), nil
+}
+
+func deriveHistoryKey(passphrase string, salt []byte) []byte {
+	return argon2.IDKey([]byte(passphrase), salt, historyArgonTime, historyArgonMemory, historyArgonThreads, historyKeySize)
+}
+
+func historyAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,83 @@
+package communication
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestEncryptedChatHistory(t *testing.T) {
+	cs, h := newTestService(t, time.Second)
+	self := h.ID().String()
+	cs.addToHistory(ChatMessage{ID: "1", From: "peer-a", To: self, Content: "top secret", Timestamp: time.Now()})
+
+	if err := cs.SetHistoryPassphrase("", "short"); err == nil {
+		t.Error("expected a short passphrase to be rejected")
+	}
+	if err := cs.SetHistoryPassphrase("", "first passphrase"); err != nil {
+		t.Fatalf("enable encryption failed: %v", err)
+	}
+	data, err := os.ReadFile(cs.GetChatHistoryFilePath())
+	if err != nil {
+		t.Fatalf("read history: %v", err)
+	}
+	if !isEncryptedHistory(data) || bytes.Contains(data, []byte("top secret")) {
+		t.Fatal("expected the history file to be encrypted")
+	}
+
+	// A restarted service starts locked and keeps new messages in memory
+	reopened := NewCommunicationService(h, Config{DataDir: cs.dataDir})
+	if encrypted, locked := reopened.HistoryEncryption(); !encrypted || !locked {
+		t.Fatalf("expected locked encrypted history, got encrypted=%v locked=%v", encrypted, locked)
+	}
+	if len(reopened.GetChatHistory("peer-a")) != 0 {
+		t.Error("expected no history before unlocking")
+	}
+	reopened.addToHistory(ChatMessage{ID: "2", From: "peer-a", To: self, Content: "while locked", Timestamp: time.Now()})
+	if err := reopened.UnlockHistory("wrong passphrase"); !errors.Is(err, ErrBadHistoryPassphrase) {
+		t.Fatalf("expected ErrBadHistoryPassphrase, got %v", err)
+	}
+	if err := reopened.UnlockHistory("first passphrase"); err != nil {
+		t.Fatalf("unlock failed: %v", err)
+	}
+	history := reopened.GetChatHistory("peer-a")
+	if len(history) != 2 || history[0].Content != "top secret" || history[1].Content != "while locked" {
+		t.Fatalf("unexpected history after unlock: %+v", history)
+	}
+
+	// Changing the passphrase re-encrypts the file
+	if err := reopened.SetHistoryPassphrase("wrong passphrase", "second passphrase"); !errors.Is(err, ErrBadHistoryPassphrase) {
+		t.Fatalf("expected ErrBadHistoryPassphrase, got %v", err)
+	}
+	if err := reopened.SetHistoryPassphrase("first passphrase", "second passphrase"); err != nil {
+		t.Fatalf("change passphrase failed: %v", err)
+	}
+	data, _ = os.ReadFile(cs.GetChatHistoryFilePath())
+	if _, _, _, err := openHistory(data, "first passphrase"); !errors.Is(err, ErrBadHistoryPassphrase) {
+		t.Errorf("expected the old passphrase to stop working, got %v", err)
+	}
+	if stored, _, _, err := openHistory(data, "second passphrase"); err != nil || len(stored["peer-a"]) != 2 {
+		t.Errorf("expected both messages under the new passphrase, got %d (%v)", len(stored["peer-a"]), err)
+	}
+
+	// Locking drops the key and the messages in memory
+	if err := reopened.LockHistory(); err != nil {
+		t.Fatalf("lock failed: %v", err)
+	}
+	if len(reopened.GetChatHistory("peer-a")) != 0 || reopened.historyKey != nil {
+		t.Error("expected locked history to hold no key or messages")
+	}
+
+	// An empty new passphrase goes back to plaintext
+	if err := reopened.SetHistoryPassphrase("second passphrase", ""); err != nil {
+		t.Fatalf("disable encryption failed: %v", err)
+	}
+	data, _ = os.ReadFile(cs.GetChatHistoryFilePath())
+	var plain map[string][]ChatMessage
+	if err := json.Unmarshal(data, &plain); err != nil || len(plain["peer-a"]) != 2 {
+		t.Errorf("expected plaintext history with 2 messages, got %d (%v)", len(plain["peer-a"]), err)
+	}
+}
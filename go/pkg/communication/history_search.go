@@ -0,0 +1,279 @@
+package communication
+
+import (
+	"encoding/base64"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// History search page sizes
+const (
+	DefaultHistoryPageSize = 50
+	MaxHistoryPageSize     = 500
+)
+
+// HistoryQuery selects chat history messages. Zero fields match anything.
+type HistoryQuery struct {
+	PeerID string    // Conversation to search; empty searches all
+	Text   string    // Every word must start a word of the message, case-insensitively
+	From   string    // Sender peer ID
+	Since  time.Time // Inclusive
+	Until  time.Time // Exclusive
+	Cursor string    // NextCursor of the previous page; empty starts at the newest message
+	Limit  int       // Page size, 0 = DefaultHistoryPageSize
+}
+
+// HistoryPage is one page of search results, newest first
+type HistoryPage struct {
+	Messages   []ChatMessage
+	NextCursor string // Empty on the last page
+	Total      int    // Matches across all pages
+}
+
+// Conversation summarizes the history held for one peer
+type Conversation struct {
+	PeerID   string
+	Messages int
+	Last     time.Time
+}
+
+// peerIndex maps words to positions in one peer's history. It remembers
+// the history it was built from so that appends are indexed incrementally
+// and any other change triggers a rebuild.
+type peerIndex struct {
+	count   int
+	firstID string
+	lastID  string
+	words   map[string][]int // word -> ascending positions
+}
+
+// historyHit is a matching message and the conversation it belongs to
+type historyHit struct {
+	peerID string
+	msg    ChatMessage
+}
+
+// SearchHistory returns a page of the messages matching q, newest first
+func (cs *CommunicationService) SearchHistory(q HistoryQuery) (HistoryPage, error) {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultHistoryPageSize
+	}
+	limit = min(limit, MaxHistoryPageSize)
+
+	var after *historyCursor
+	if q.Cursor != "" {
+		c, err := parseHistoryCursor(q.Cursor)
+		if err != nil {
+			return HistoryPage{}, err
+		}
+		after = &c
+	}
+	terms := historyWords(q.Text)
+
+	cs.historyMu.Lock()
+	peers := []string{q.PeerID}
+	if q.PeerID == "" {
+		peers = make([]string, 0, len(cs.chatHistory))
+		for peerID := range cs.chatHistory {
+			peers = append(peers, peerID)
+		}
+	}
+	var hits []historyHit
+	for _, peerID := range peers {
+		history := cs.chatHistory[peerID]
+		for _, pos := range cs.candidatesLocked(peerID, history, terms) {
+			msg := history[pos]
+			if q.From != "" && msg.From != q.From {
+				continue
+			}
+			if !q.Since.IsZero() && msg.Timestamp.Before(q.Since) {
+				continue
+			}
+			if !q.Until.IsZero() && !msg.Timestamp.Before(q.Until) {
+				continue
+			}
+			hits = append(hits, historyHit{peerID, msg})
+		}
+	}
+	cs.historyMu.Unlock()
+
+	sort.Slice(hits, func(i, j int) bool { return cursorOf(hits[j]).before(cursorOf(hits[i])) })
+	page := HistoryPage{Total: len(hits)}
+	start := 0
+	if after != nil {
+		start = sort.Search(len(hits), func(i int) bool { return cursorOf(hits[i]).before(*after) })
+	}
+	end := min(start+limit, len(hits))
+	page.Messages = make([]ChatMessage, 0, end-start)
+	for _, hit := range hits[start:end] {
+		page.Messages = append(page.Messages, hit.msg)
+	}
+	if end < len(hits) {
+		page.NextCursor = cursorOf(hits[end-1]).String()
+	}
+	return page, nil
+}
+
+// Conversations lists the peers with chat history, most recent first
+func (cs *CommunicationService) Conversations() []Conversation {
+	cs.historyMu.RLock()
+	defer cs.historyMu.RUnlock()
+	out := make([]Conversation, 0, len(cs.chatHistory))
+	for peerID, history := range cs.chatHistory {
+		if len(history) == 0 {
+			continue
+		}
+		out = append(out, Conversation{PeerID: peerID, Messages: len(history), Last: history[len(history)-1].Timestamp})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Last.After(out[j].Last) })
+	return out
+}
+
+// candidatesLocked returns the positions in a peer's history holding every
+// term as a word prefix, or every position if there are no terms. Caller
+// must hold historyMu for writing.
+func (cs *CommunicationService) candidatesLocked(peerID string, history []ChatMessage, terms []string) []int {
+	if len(terms) == 0 {
+		all := make([]int, len(history))
+		for i := range all {
+			all[i] = i
+		}
+		return all
+	}
+
+	idx := cs.indexLocked(peerID, history)
+	var matches []int
+	for i, term := range terms {
+		var positions []int
+		for word, list := range idx.words {
+			if strings.HasPrefix(word, term) {
+				positions = append(positions, list...)
+			}
+		}
+		slices.Sort(positions)
+		positions = slices.Compact(positions)
+		if i == 0 {
+			matches = positions
+		} else {
+			matches = intersectSorted(matches, positions)
+		}
+		if len(matches) == 0 {
+			return nil
+		}
+	}
+	return matches
+}
+
+// indexLocked returns the word index of a peer's history, extending it
+// over appended messages or rebuilding it if the history changed
+// otherwise. Caller must hold historyMu for writing.
+func (cs *CommunicationService) indexLocked(peerID string, history []ChatMessage) *peerIndex {
+	if cs.historyIndex == nil {
+		cs.historyIndex = make(map[string]*peerIndex)
+	}
+	idx := cs.historyIndex[peerID]
+	if idx == nil || !idx.extends(history) {
+		idx = &peerIndex{words: make(map[string][]int)}
+		cs.historyIndex[peerID] = idx
+	}
+	for pos := idx.count; pos < len(history); pos++ {
+		for _, word := range slices.Compact(sortedWords(history[pos].Content)) {
+			idx.words[word] = append(idx.words[word], pos)
+		}
+	}
+	idx.count = len(history)
+	if len(history) > 0 {
+		idx.firstID, idx.lastID = history[0].ID, history[len(history)-1].ID
+	}
+	return idx
+}
+
+// extends reports whether history is the indexed history with messages
+// appended
+func (idx *peerIndex) extends(history []ChatMessage) bool {
+	if idx.count == 0 {
+		return true
+	}
+	return len(history) >= idx.count && history[0].ID == idx.firstID && history[idx.count-1].ID == idx.lastID
+}
+
+// historyWords splits text into lowercase words
+func historyWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// sortedWords returns a message's words sorted, for deduplication
+func sortedWords(text string) []string {
+	words := historyWords(text)
+	slices.Sort(words)
+	return words
+}
+
+// intersectSorted returns the values present in both ascending lists
+func intersectSorted(a, b []int) []int {
+	out := a[:0]
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			out = append(out, a[i])
+			i, j = i+1, j+1
+		}
+	}
+	return out
+}
+
+// historyCursor is a position in newest-first result order
+type historyCursor struct {
+	nanos  int64
+	peerID string
+	id     string
+}
+
+func cursorOf(hit historyHit) historyCursor {
+	return historyCursor{hit.msg.Timestamp.UnixNano(), hit.peerID, hit.msg.ID}
+}
+
+// before reports whether c sorts before other, i.e. is older
+func (c historyCursor) before(other historyCursor) bool {
+	if c.nanos != other.nanos {
+		return c.nanos < other.nanos
+	}
+	if c.peerID != other.peerID {
+		return c.peerID < other.peerID
+	}
+	return c.id < other.id
+}
+
+// String encodes the cursor for clients, which treat it as opaque
+func (c historyCursor) String() string {
+	raw := strconv.FormatInt(c.nanos, 10) + "\x00" + c.peerID + "\x00" + c.id
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func parseHistoryCursor(s string) (historyCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return historyCursor{}, fmt.Errorf("invalid history cursor")
+	}
+	parts := strings.SplitN(string(raw), "\x00", 3)
+	if len(parts) != 3 {
+		return historyCursor{}, fmt.Errorf("invalid history cursor")
+	}
+	nanos, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return historyCursor{}, fmt.Errorf("invalid history cursor")
+	}
+	return historyCursor{nanos, parts[1], parts[2]}, nil
+}
@@ -0,0 +1,87 @@
+package communication
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestSearchHistory(t *testing.T) {
+	cs, h := newTestService(t, time.Second)
+	self := h.ID().String()
+	base := time.Now().Add(-time.Hour)
+	for i := range 120 {
+		from, to := "peer-a", self
+		if i%2 == 1 {
+			from, to = self, "peer-a"
+		}
+		content := fmt.Sprintf("message %d", i)
+		if i%10 == 0 {
+			content = fmt.Sprintf("Deploy window %d opens", i)
+		}
+		cs.addToHistory(ChatMessage{ID: fmt.Sprint(i), From: from, To: to, Content: content, Timestamp: base.Add(time.Duration(i) * time.Second)})
+	}
+	cs.addToHistory(ChatMessage{ID: "b1", From: "peer-b", To: self, Content: "deployment done", Timestamp: base.Add(time.Minute)})
+
+	// Pages walk back from the newest message without gaps or repeats
+	seen := make(map[string]bool)
+	cursor := ""
+	for pages := 0; ; pages++ {
+		page, err := cs.SearchHistory(HistoryQuery{PeerID: "peer-a", Cursor: cursor, Limit: 50})
+		if err != nil {
+			t.Fatalf("search failed: %v", err)
+		}
+		if page.Total != 120 {
+			t.Fatalf("expected 120 matches, got %d", page.Total)
+		}
+		for _, msg := range page.Messages {
+			if seen[msg.ID] {
+				t.Fatalf("message %s returned twice", msg.ID)
+			}
+			seen[msg.ID] = true
+		}
+		if pages == 0 && page.Messages[0].ID != "119" {
+			t.Errorf("expected the newest message first, got %s", page.Messages[0].ID)
+		}
+		if cursor = page.NextCursor; cursor == "" {
+			break
+		}
+	}
+	if len(seen) != 120 {
+		t.Fatalf("expected to page through 120 messages, got %d", len(seen))
+	}
+
+	// Word-prefix text search across peers, with sender and time filters
+	page, _ := cs.SearchHistory(HistoryQuery{Text: "DEPLOY"})
+	if page.Total != 13 {
+		t.Errorf("expected 13 deploy matches across peers, got %d", page.Total)
+	}
+	page, _ = cs.SearchHistory(HistoryQuery{Text: "deploy opens", From: "peer-a", Since: base.Add(50 * time.Second), Until: base.Add(100 * time.Second)})
+	if page.Total != 5 || page.Messages[0].ID != "90" {
+		t.Errorf("expected windows 50-90 from peer-a, got %d starting %v", page.Total, page.Messages)
+	}
+	if page, _ = cs.SearchHistory(HistoryQuery{Text: "ploy"}); page.Total != 0 {
+		t.Errorf("expected no match for a word infix, got %d", page.Total)
+	}
+
+	// The index follows appends and removals
+	expires := time.Now().Add(time.Minute)
+	cs.addToHistory(ChatMessage{ID: "120", From: "peer-a", To: self, Content: "late deploy", Timestamp: base.Add(200 * time.Second), ExpiresAt: expires})
+	if page, _ = cs.SearchHistory(HistoryQuery{PeerID: "peer-a", Text: "deploy"}); page.Total != 13 {
+		t.Errorf("expected the appended message to be indexed, got %d", page.Total)
+	}
+	cs.historyMu.Lock()
+	cs.chatHistory["peer-a"][0].ExpiresAt = expires
+	cs.historyMu.Unlock()
+	cs.purgeExpired(expires)
+	if page, _ = cs.SearchHistory(HistoryQuery{PeerID: "peer-a", Text: "deploy"}); page.Total != 11 {
+		t.Errorf("expected deleted messages to drop out, got %d", page.Total)
+	}
+
+	if _, err := cs.SearchHistory(HistoryQuery{Cursor: "not a cursor"}); err == nil {
+		t.Error("expected an invalid cursor to be rejected")
+	}
+	if convs := cs.Conversations(); len(convs) != 2 || convs[0].PeerID != "peer-a" {
+		t.Errorf("unexpected conversations: %+v", convs)
+	}
+}
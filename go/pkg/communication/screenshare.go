@@ -0,0 +1,434 @@
+package communication
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+// ScreenShareProtocol carries desktop sharing separately from camera video,
+// so a busy screen does not delay camera frames and vice versa
+const ScreenShareProtocol protocol.ID = "/pangea/screen/1.0.0"
+
+const (
+	// DefaultScreenKeyframeInterval is how many updates may follow a
+	// keyframe before the next keyframe is required
+	DefaultScreenKeyframeInterval = 100
+
+	// Limits on a single screen update
+	MaxScreenRects      = 1024
+	MaxScreenUpdateSize = 16 * 1024 * 1024
+
+	// screenQueueSize bounds the received updates waiting to be collected
+	screenQueueSize = 64
+
+	// Wire layout: update header is seq(4) + width(2) + height(2) + flags(1)
+	// + reserved(1) + rectCount(2); each rect is x(2) + y(2) + width(2) +
+	// height(2) + dataLen(4) followed by its data
+	screenUpdateHeaderSize = 12
+	screenRectHeaderSize   = 12
+	screenFlagKeyframe     = 0x01
+
+	// Control byte sent back by the receiver
+	screenRequestKeyframe = 0x01
+)
+
+// ErrKeyframeRequired is returned when a delta update is sent while the
+// receiver needs a full keyframe
+var ErrKeyframeRequired = errors.New("screen share needs a keyframe")
+
+// ScreenRect is an encoded region of the screen. Data is opaque to the
+// transport (e.g. PNG or JPEG of the region).
+type ScreenRect struct {
+	X, Y          uint16
+	Width, Height uint16
+	Data          []byte
+}
+
+// ScreenUpdate is one screen-share frame. A keyframe repaints the whole
+// screen; other updates only carry the regions that changed since the
+// previous update.
+type ScreenUpdate struct {
+	Seq          uint32 // Set by SendScreenUpdate
+	ScreenWidth  uint16
+	ScreenHeight uint16
+	Keyframe     bool
+	Rects        []ScreenRect
+	Timestamp    time.Time
+}
+
+// ReceivedScreenUpdate is a screen update and the peer sharing its screen
+type ReceivedScreenUpdate struct {
+	PeerID string
+	ScreenUpdate
+}
+
+// ScreenShareConfig configures an outgoing screen share
+type ScreenShareConfig struct {
+	KeyframeInterval int // Updates between keyframes, 0 = DefaultScreenKeyframeInterval
+}
+
+// screenShare is an outgoing screen share to one peer. The stream is
+// reopened on the next update if it drops, which requires a keyframe.
+type screenShare struct {
+	config   ScreenShareConfig
+	stream   network.Stream
+	seq      uint32
+	sinceKey int
+	needKey  bool
+}
+
+// screenView is what the receiver knows about a peer's shared screen
+type screenView struct {
+	synced  bool // A keyframe arrived and no update was lost since
+	lastSeq uint32
+}
+
+// SetScreenShareCallback sets the callback for incoming screen updates.
+// Updates are also queued for ReceivedScreenUpdates.
+func (cs *CommunicationService) SetScreenShareCallback(cb func(peerID string, update ScreenUpdate)) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.onScreenUpdate = cb
+}
+
+// StartScreenShare begins sharing this node's screen with a peer. The first
+// update must be a keyframe.
+func (cs *CommunicationService) StartScreenShare(peerID peer.ID, config ScreenShareConfig) error {
+	if config.KeyframeInterval <= 0 {
+		config.KeyframeInterval = DefaultScreenKeyframeInterval
+	}
+	cs.screenMu.Lock()
+	defer cs.screenMu.Unlock()
+	if _, ok := cs.screenShares[peerID]; ok {
+		return fmt.Errorf("already sharing screen with %s", shortID(peerID))
+	}
+	share := &screenShare{config: config, needKey: true}
+	if err := cs.openScreenStreamLocked(peerID, share); err != nil {
+		return err
+	}
+	cs.screenShares[peerID] = share
+	log.Printf("🖥️  Screen share to %s started (keyframe every %d updates)", shortID(peerID), config.KeyframeInterval)
+	return nil
+}
+
+// StopScreenShare ends sharing this node's screen with a peer
+func (cs *CommunicationService) StopScreenShare(peerID peer.ID) error {
+	cs.screenMu.Lock()
+	defer cs.screenMu.Unlock()
+	share, ok := cs.screenShares[peerID]
+	if !ok {
+		return fmt.Errorf("not sharing screen with %s", shortID(peerID))
+	}
+	delete(cs.screenShares, peerID)
+	if share.stream != nil {
+		share.stream.Close()
+	}
+	log.Printf("🖥️  Screen share to %s stopped", shortID(peerID))
+	return nil
+}
+
+// KeyframeRequired reports whether the next update to a peer must be a
+// keyframe: at the start, after the keyframe interval, after the stream was
+// reopened, or when the receiver lost updates and asked for one
+func (cs *CommunicationService) KeyframeRequired(peerID peer.ID) bool {
+	cs.screenMu.Lock()
+	defer cs.screenMu.Unlock()
+	share, ok := cs.screenShares[peerID]
+	return ok && share.keyframeDue()
+}
+
+// SendScreenUpdate sends a screen update to a peer and returns its
+// sequence number. Delta updates are refused with ErrKeyframeRequired while
+// a keyframe is due.
+func (cs *CommunicationService) SendScreenUpdate(peerID peer.ID, update ScreenUpdate) (uint32, error) {
+	if err := validateScreenUpdate(&update); err != nil {
+		return 0, err
+	}
+
+	cs.screenMu.Lock()
+	defer cs.screenMu.Unlock()
+	share, ok := cs.screenShares[peerID]
+	if !ok {
+		return 0, fmt.Errorf("not sharing screen with %s", shortID(peerID))
+	}
+	if share.stream == nil {
+		if err := cs.openScreenStreamLocked(peerID, share); err != nil {
+			return 0, err
+		}
+	}
+	if !update.Keyframe && share.keyframeDue() {
+		return 0, ErrKeyframeRequired
+	}
+
+	share.seq++
+	update.Seq = share.seq
+	if err := writeScreenUpdate(share.stream, &update); err != nil {
+		share.stream.Reset()
+		share.stream = nil
+		share.needKey = true
+		return 0, fmt.Errorf("failed to send screen update: %w", err)
+	}
+	if update.Keyframe {
+		share.sinceKey, share.needKey = 0, false
+	} else {
+		share.sinceKey++
+	}
+	return update.Seq, nil
+}
+
+// ReceivedScreenUpdates returns and removes up to max queued incoming
+// updates, oldest first
+func (cs *CommunicationService) ReceivedScreenUpdates(max int) []ReceivedScreenUpdate {
+	cs.screenMu.Lock()
+	defer cs.screenMu.Unlock()
+	n := len(cs.screenQueue)
+	if max > 0 {
+		n = min(n, max)
+	}
+	out := make([]ReceivedScreenUpdate, n)
+	copy(out, cs.screenQueue)
+	cs.screenQueue = cs.screenQueue[n:]
+	return out
+}
+
+// keyframeDue reports whether the next update must be a keyframe
+func (s *screenShare) keyframeDue() bool {
+	return s.needKey || s.sinceKey >= s.config.KeyframeInterval
+}
+
+// openScreenStreamLocked opens the stream for a share and listens for the
+// receiver's keyframe requests on it. Caller must hold screenMu.
+func (cs *CommunicationService) openScreenStreamLocked(peerID peer.ID, share *screenShare) error {
+	ctx, cancel := context.WithTimeout(cs.ctx, 10*time.Second)
+	defer cancel()
+	stream, err := cs.host.NewStream(ctx, peerID, ScreenShareProtocol)
+	if err != nil {
+		return fmt.Errorf("failed to open screen share stream: %w", err)
+	}
+	share.stream = stream
+	share.needKey = true
+	cs.touchSession(peerID, ScreenShareProtocol)
+
+	cs.wg.Add(1)
+	go func() {
+		defer cs.wg.Done()
+		cs.readKeyframeRequests(peerID, share, stream)
+	}()
+	return nil
+}
+
+// readKeyframeRequests marks a share as needing a keyframe whenever its
+// receiver asks, until the stream closes
+func (cs *CommunicationService) readKeyframeRequests(peerID peer.ID, share *screenShare, stream network.Stream) {
+	buf := make([]byte, 1)
+	for {
+		if _, err := stream.Read(buf); err != nil {
+			cs.screenMu.Lock()
+			if share.stream == stream {
+				share.stream = nil
+			}
+			cs.screenMu.Unlock()
+			return
+		}
+		if buf[0] == screenRequestKeyframe {
+			cs.screenMu.Lock()
+			share.needKey = true
+			cs.screenMu.Unlock()
+			log.Printf("🖥️  %s requested a screen keyframe", shortID(peerID))
+		}
+	}
+}
+
+// handleScreenStream receives a peer's screen share. Updates are applied in
+// sequence from a keyframe; after a gap, deltas are dropped and a keyframe
+// is requested until one arrives.
+func (cs *CommunicationService) handleScreenStream(stream network.Stream) {
+	remotePeer := stream.Conn().RemotePeer()
+	log.Printf("🖥️  New screen share from peer: %s", shortID(remotePeer))
+	cs.touchSession(remotePeer, ScreenShareProtocol)
+	defer stream.Close()
+
+	reader := bufio.NewReaderSize(stream, 64*1024)
+	view := &screenView{}
+	requested := false
+	for {
+		select {
+		case <-cs.ctx.Done():
+			return
+		default:
+		}
+
+		stream.SetReadDeadline(time.Now().Add(StreamReadTimeout))
+		update, err := readScreenUpdate(reader)
+		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				continue
+			}
+			if err != io.EOF && cs.ctx.Err() == nil {
+				log.Printf("Screen update read error: %v", err)
+			}
+			return
+		}
+
+		if view.synced && update.Seq != view.lastSeq+1 {
+			view.synced = false
+		}
+		view.lastSeq = update.Seq
+		if update.Keyframe {
+			view.synced, requested = true, false
+		}
+		if !view.synced {
+			if !requested {
+				stream.Write([]byte{screenRequestKeyframe})
+				requested = true
+			}
+			continue
+		}
+
+		if !cs.queueScreenUpdate(remotePeer.String(), update) {
+			// Updates were dropped; start again from a keyframe
+			view.synced = false
+			stream.Write([]byte{screenRequestKeyframe})
+			requested = true
+			continue
+		}
+
+		cs.mu.RLock()
+		cb := cs.onScreenUpdate
+		cs.mu.RUnlock()
+		if cb != nil {
+			cb(remotePeer.String(), *update)
+		}
+	}
+}
+
+// queueScreenUpdate queues an update for collection. If the queue is full,
+// the peer's queued updates are discarded and false is returned: the
+// remaining deltas could not be applied without them.
+func (cs *CommunicationService) queueScreenUpdate(peerID string, update *ScreenUpdate) bool {
+	cs.screenMu.Lock()
+	defer cs.screenMu.Unlock()
+	if len(cs.screenQueue) < screenQueueSize {
+		cs.screenQueue = append(cs.screenQueue, ReceivedScreenUpdate{PeerID: peerID, ScreenUpdate: *update})
+		return true
+	}
+	kept := cs.screenQueue[:0]
+	for _, u := range cs.screenQueue {
+		if u.PeerID != peerID {
+			kept = append(kept, u)
+		}
+	}
+	cs.screenQueue = kept
+	return false
+}
+
+// validateScreenUpdate checks an update before it is sent
+func validateScreenUpdate(u *ScreenUpdate) error {
+	if u.ScreenWidth == 0 || u.ScreenHeight == 0 {
+		return fmt.Errorf("screen size is required")
+	}
+	if len(u.Rects) > MaxScreenRects {
+		return fmt.Errorf("too many regions (%d, max %d)", len(u.Rects), MaxScreenRects)
+	}
+	if u.Keyframe && len(u.Rects) == 0 {
+		return fmt.Errorf("keyframe has no regions")
+	}
+	size := 0
+	for _, r := range u.Rects {
+		if r.Width == 0 || r.Height == 0 ||
+			uint32(r.X)+uint32(r.Width) > uint32(u.ScreenWidth) ||
+			uint32(r.Y)+uint32(r.Height) > uint32(u.ScreenHeight) {
+			return fmt.Errorf("region %dx%d at (%d,%d) is outside the %dx%d screen",
+				r.Width, r.Height, r.X, r.Y, u.ScreenWidth, u.ScreenHeight)
+		}
+		size += len(r.Data)
+	}
+	if size > MaxScreenUpdateSize {
+		return fmt.Errorf("screen update too large: %d bytes", size)
+	}
+	return nil
+}
+
+// writeScreenUpdate writes an update in wire format
+func writeScreenUpdate(w io.Writer, u *ScreenUpdate) error {
+	size := screenUpdateHeaderSize
+	for _, r := range u.Rects {
+		size += screenRectHeaderSize + len(r.Data)
+	}
+	buf := make([]byte, screenUpdateHeaderSize, size)
+	binary.BigEndian.PutUint32(buf[0:4], u.Seq)
+	binary.BigEndian.PutUint16(buf[4:6], u.ScreenWidth)
+	binary.BigEndian.PutUint16(buf[6:8], u.ScreenHeight)
+	if u.Keyframe {
+		buf[8] = screenFlagKeyframe
+	}
+	binary.BigEndian.PutUint16(buf[10:12], uint16(len(u.Rects)))
+	for _, r := range u.Rects {
+		buf = binary.BigEndian.AppendUint16(buf, r.X)
+		buf = binary.BigEndian.AppendUint16(buf, r.Y)
+		buf = binary.BigEndian.AppendUint16(buf, r.Width)
+		buf = binary.BigEndian.AppendUint16(buf, r.Height)
+		buf = binary.BigEndian.AppendUint32(buf, uint32(len(r.Data)))
+		buf = append(buf, r.Data...)
+	}
+	_, err := w.Write(buf)
+	return err
+}
+
+// readScreenUpdate reads and checks one update in wire format
+func readScreenUpdate(r io.Reader) (*ScreenUpdate, error) {
+	header := make([]byte, screenUpdateHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	u := &ScreenUpdate{
+		Seq:          binary.BigEndian.Uint32(header[0:4]),
+		ScreenWidth:  binary.BigEndian.Uint16(header[4:6]),
+		ScreenHeight: binary.BigEndian.Uint16(header[6:8]),
+		Keyframe:     header[8]&screenFlagKeyframe != 0,
+		Timestamp:    time.Now(),
+	}
+	count := int(binary.BigEndian.Uint16(header[10:12]))
+	if count > MaxScreenRects {
+		return nil, fmt.Errorf("too many regions: %d", count)
+	}
+
+	size := 0
+	u.Rects = make([]ScreenRect, count)
+	rectHeader := make([]byte, screenRectHeaderSize)
+	for i := range u.Rects {
+		if _, err := io.ReadFull(r, rectHeader); err != nil {
+			return nil, err
+		}
+		dataLen := int(binary.BigEndian.Uint32(rectHeader[8:12]))
+		if size += dataLen; size > MaxScreenUpdateSize {
+			return nil, fmt.Errorf("screen update too large: %d bytes", size)
+		}
+		rect := ScreenRect{
+			X:      binary.BigEndian.Uint16(rectHeader[0:2]),
+			Y:      binary.BigEndian.Uint16(rectHeader[2:4]),
+			Width:  binary.BigEndian.Uint16(rectHeader[4:6]),
+			Height: binary.BigEndian.Uint16(rectHeader[6:8]),
+			Data:   make([]byte, dataLen),
+		}
+		if _, err := io.ReadFull(r, rect.Data); err != nil {
+			return nil, err
+		}
+		u.Rects[i] = rect
+	}
+	if err := validateScreenUpdate(u); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
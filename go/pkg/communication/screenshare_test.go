@@ -0,0 +1,119 @@
+package communication
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestScreenShareKeyframesAndDeltas(t *testing.T) {
+	csA, hA := newTestService(t, 5*time.Second)
+	csB, hB := newTestService(t, 5*time.Second)
+
+	received := make(chan ScreenUpdate, 16)
+	csB.SetScreenShareCallback(func(peerID string, u ScreenUpdate) {
+		if peerID == hA.ID().String() {
+			received <- u
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := hA.Connect(ctx, peer.AddrInfo{ID: hB.ID(), Addrs: hB.Addrs()}); err != nil {
+		t.Fatalf("connect failed: %v", err)
+	}
+	if err := csA.StartScreenShare(hB.ID(), ScreenShareConfig{KeyframeInterval: 2}); err != nil {
+		t.Fatalf("StartScreenShare failed: %v", err)
+	}
+
+	full := ScreenUpdate{ScreenWidth: 1920, ScreenHeight: 1080, Keyframe: true,
+		Rects: []ScreenRect{{Width: 1920, Height: 1080, Data: []byte("full frame")}}}
+	delta := ScreenUpdate{ScreenWidth: 1920, ScreenHeight: 1080,
+		Rects: []ScreenRect{{X: 100, Y: 200, Width: 64, Height: 32, Data: []byte("dirty")}}}
+	expect := func(keyframe bool) ScreenUpdate {
+		t.Helper()
+		select {
+		case u := <-received:
+			if u.Keyframe != keyframe {
+				t.Fatalf("expected keyframe=%v, got update %d keyframe=%v", keyframe, u.Seq, u.Keyframe)
+			}
+			return u
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for screen update")
+		}
+		return ScreenUpdate{}
+	}
+
+	// The share starts with a keyframe
+	if _, err := csA.SendScreenUpdate(hB.ID(), delta); !errors.Is(err, ErrKeyframeRequired) {
+		t.Fatalf("expected ErrKeyframeRequired before the first keyframe, got %v", err)
+	}
+	if _, err := csA.SendScreenUpdate(hB.ID(), full); err != nil {
+		t.Fatalf("keyframe failed: %v", err)
+	}
+	expect(true)
+
+	// Two deltas fit the interval, the third needs a keyframe
+	for range 2 {
+		if _, err := csA.SendScreenUpdate(hB.ID(), delta); err != nil {
+			t.Fatalf("delta failed: %v", err)
+		}
+		u := expect(false)
+		if r := u.Rects[0]; r.X != 100 || r.Y != 200 || r.Width != 64 || r.Height != 32 || !bytes.Equal(r.Data, []byte("dirty")) {
+			t.Fatalf("delta region corrupted: %+v", r)
+		}
+	}
+	if !csA.KeyframeRequired(hB.ID()) {
+		t.Fatal("keyframe interval not enforced")
+	}
+	if _, err := csA.SendScreenUpdate(hB.ID(), delta); !errors.Is(err, ErrKeyframeRequired) {
+		t.Fatalf("expected ErrKeyframeRequired after the interval, got %v", err)
+	}
+	if _, err := csA.SendScreenUpdate(hB.ID(), full); err != nil {
+		t.Fatalf("keyframe failed: %v", err)
+	}
+	expect(true)
+
+	// Updates are queued for collection as well as delivered to the callback
+	if queued := csB.ReceivedScreenUpdates(0); len(queued) != 4 || queued[0].PeerID != hA.ID().String() {
+		t.Fatalf("expected 4 queued updates from A, got %d", len(queued))
+	}
+
+	// Regions must lie on the screen
+	bad := ScreenUpdate{ScreenWidth: 100, ScreenHeight: 100, Keyframe: true,
+		Rects: []ScreenRect{{X: 90, Width: 20, Height: 10}}}
+	if _, err := csA.SendScreenUpdate(hB.ID(), bad); err == nil {
+		t.Fatal("accepted a region outside the screen")
+	}
+
+	if err := csA.StopScreenShare(hB.ID()); err != nil {
+		t.Fatalf("StopScreenShare failed: %v", err)
+	}
+	if _, err := csA.SendScreenUpdate(hB.ID(), full); err == nil {
+		t.Fatal("sent an update after the share stopped")
+	}
+}
+
+func TestScreenUpdateWireFormat(t *testing.T) {
+	var buf bytes.Buffer
+	for _, u := range []ScreenUpdate{
+		{Seq: 7, ScreenWidth: 10, ScreenHeight: 10, Rects: []ScreenRect{{Width: 1, Height: 1}}},
+		{Seq: 8, ScreenWidth: 10, ScreenHeight: 10, Keyframe: true, Rects: []ScreenRect{{Width: 10, Height: 10, Data: []byte{1}}}},
+	} {
+		if err := writeScreenUpdate(&buf, &u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	first, err := readScreenUpdate(&buf)
+	if err != nil || first.Seq != 7 || first.Keyframe {
+		t.Fatalf("bad first update: %+v, %v", first, err)
+	}
+	second, err := readScreenUpdate(&buf)
+	if err != nil || second.Seq != 8 || !second.Keyframe || second.Rects[0].Data[0] != 1 {
+		t.Fatalf("bad second update: %+v, %v", second, err)
+	}
+}
@@ -0,0 +1,290 @@
+package communication
+
+import (
+	"bytes"
+	"log"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// DefaultSessionResumeGrace is how long a disconnected peer's session is kept
+// so streams and chat sequence numbers can resume when it reconnects
+const DefaultSessionResumeGrace = 30 * time.Second
+
+// SessionEventKind identifies a session lifecycle event
+type SessionEventKind string
+
+const (
+	SessionInterrupted SessionEventKind = "interrupted"
+	SessionResumed     SessionEventKind = "resumed"
+	SessionExpired     SessionEventKind = "expired"
+)
+
+// SessionEvent is emitted when a peer's session is interrupted by a
+// disconnect, resumed after reconnecting within the grace window, or expired
+type SessionEvent struct {
+	PeerID      string
+	Kind        SessionEventKind
+	Protocols   []protocol.ID // Streams that were active when the peer dropped
+	LastSentSeq uint64
+	LastRecvSeq uint64
+	Downtime    time.Duration
+	TTL         time.Duration // Disappearing-message policy of the session
+}
+
+// peerSession tracks per-peer stream state that survives a transient disconnect
+type peerSession struct {
+	sendSeq        uint64
+	recvSeq        uint64
+	ttl            time.Duration // Disappearing-message policy, 0 = keep forever
+	protocols      map[protocol.ID]bool
+	disconnectedAt time.Time
+	expiry         *time.Timer
+}
+
+// SetSessionCallback sets the callback for session lifecycle events
+func (cs *CommunicationService) SetSessionCallback(cb func(evt SessionEvent)) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.onSessionEvent = cb
+}
+
+// touchSession records that a stream for proto is active with a peer
+func (cs *CommunicationService) touchSession(p peer.ID, proto protocol.ID) {
+	cs.sessionMu.Lock()
+	defer cs.sessionMu.Unlock()
+	sess := cs.getSessionLocked(p)
+	sess.protocols[proto] = true
+}
+
+func (cs *CommunicationService) getSessionLocked(p peer.ID) *peerSession {
+	sess, ok := cs.sessions[p]
+	if !ok {
+		sess = &peerSession{protocols: make(map[protocol.ID]bool)}
+		cs.sessions[p] = sess
+	}
+	return sess
+}
+
+// nextSendSeq returns the next outgoing chat sequence number for a peer
+func (cs *CommunicationService) nextSendSeq(p peer.ID) uint64 {
+	cs.sessionMu.Lock()
+	defer cs.sessionMu.Unlock()
+	sess := cs.getSessionLocked(p)
+	sess.sendSeq++
+	return sess.sendSeq
+}
+
+// recordRecvSeq records an incoming chat sequence number, logging any gap
+func (cs *CommunicationService) recordRecvSeq(p peer.ID, seq uint64) {
+	if seq == 0 {
+		return
+	}
+	cs.sessionMu.Lock()
+	sess := cs.getSessionLocked(p)
+	expected := sess.recvSeq + 1
+	if seq > sess.recvSeq {
+		sess.recvSeq = seq
+	}
+	cs.sessionMu.Unlock()
+
+	if seq > expected {
+		log.Printf("⚠️  Chat sequence gap from %s: expected %d, got %d", shortID(p), expected, seq)
+	}
+}
+
+// ChatSequence returns the last chat sequence numbers sent to and received
+// from a peer in the current session
+func (cs *CommunicationService) ChatSequence(p peer.ID) (sent, received uint64) {
+	cs.sessionMu.RLock()
+	defer cs.sessionMu.RUnlock()
+	if sess, ok := cs.sessions[p]; ok {
+		return sess.sendSeq, sess.recvSeq
+	}
+	return 0, 0
+}
+
+// sessionNotifiee returns network callbacks that track peer disconnects
+func (cs *CommunicationService) sessionNotifiee() network.Notifiee {
+	return &network.NotifyBundle{
+		ConnectedF: func(n network.Network, c network.Conn) {
+			go cs.peerConnected(c.RemotePeer())
+		},
+		DisconnectedF: func(n network.Network, c network.Conn) {
+			if n.Connectedness(c.RemotePeer()) != network.Connected {
+				cs.peerDisconnected(c.RemotePeer())
+			}
+		},
+		ListenF:      func(network.Network, ma.Multiaddr) {},
+		ListenCloseF: func(network.Network, ma.Multiaddr) {},
+	}
+}
+
+// peerDisconnected drops a peer's dead streams and starts its grace window
+func (cs *CommunicationService) peerDisconnected(p peer.ID) {
+	if cs.ctx.Err() != nil {
+		return
+	}
+
+	cs.sessionMu.Lock()
+	sess, ok := cs.sessions[p]
+	if !ok || len(sess.protocols) == 0 || !sess.disconnectedAt.IsZero() {
+		cs.sessionMu.Unlock()
+		return
+	}
+	sess.disconnectedAt = time.Now()
+	sess.expiry = time.AfterFunc(cs.resumeGrace, func() { cs.expireSession(p) })
+	evt := cs.sessionEventLocked(p, sess, SessionInterrupted)
+	cs.sessionMu.Unlock()
+
+	var stale []network.Stream
+	cs.streamMu.Lock()
+	for _, streams := range []map[peer.ID]network.Stream{cs.chatStreams, cs.videoStreams, cs.voiceStreams} {
+		if s, ok := streams[p]; ok {
+			stale = append(stale, s)
+			delete(streams, p)
+		}
+	}
+	cs.streamMu.Unlock()
+	cs.screenMu.Lock()
+	if share, ok := cs.screenShares[p]; ok && share.stream != nil {
+		// The share stays registered; its next update reopens the stream
+		stale = append(stale, share.stream)
+		share.stream = nil
+	}
+	cs.screenMu.Unlock()
+	for _, s := range stale {
+		s.Reset()
+	}
+
+	log.Printf("🔌 Peer %s disconnected, holding session for %v", shortID(p), cs.resumeGrace)
+	cs.emitSessionEvent(evt)
+}
+
+// peerConnected resumes a peer's session if it reconnected within the grace
+// window. Only the peer with the lower ID reopens streams so both sides do
+// not race to replace each other's streams.
+func (cs *CommunicationService) peerConnected(p peer.ID) {
+	if cs.ctx.Err() != nil {
+		return
+	}
+
+	cs.sessionMu.Lock()
+	sess, ok := cs.sessions[p]
+	if !ok || sess.disconnectedAt.IsZero() {
+		cs.sessionMu.Unlock()
+		return
+	}
+	if sess.expiry != nil {
+		sess.expiry.Stop()
+		sess.expiry = nil
+	}
+	evt := cs.sessionEventLocked(p, sess, SessionResumed)
+	sess.disconnectedAt = time.Time{}
+	cs.sessionMu.Unlock()
+
+	if bytes.Compare([]byte(cs.host.ID()), []byte(p)) < 0 {
+		for _, proto := range evt.Protocols {
+			var err error
+			switch proto {
+			case ChatProtocol:
+				_, err = cs.getChatStream(p)
+			case VideoProtocol:
+				_, err = cs.getVideoStream(p)
+			case VoiceProtocol:
+				_, err = cs.getVoiceStream(p)
+			}
+			if err != nil && cs.ctx.Err() == nil {
+				log.Printf("⚠️  Failed to reopen %s stream to %s: %v", proto, shortID(p), err)
+			}
+		}
+	}
+
+	log.Printf("🔁 Session with %s resumed after %v (chat seq sent=%d recv=%d)",
+		shortID(p), evt.Downtime.Round(time.Millisecond), evt.LastSentSeq, evt.LastRecvSeq)
+	cs.emitSessionEvent(evt)
+}
+
+// expireSession discards a session whose peer did not reconnect in time
+func (cs *CommunicationService) expireSession(p peer.ID) {
+	cs.sessionMu.Lock()
+	sess, ok := cs.sessions[p]
+	if !ok || sess.disconnectedAt.IsZero() {
+		cs.sessionMu.Unlock()
+		return
+	}
+	evt := cs.sessionEventLocked(p, sess, SessionExpired)
+	delete(cs.sessions, p)
+	cs.sessionMu.Unlock()
+
+	log.Printf("⌛ Session with %s expired after %v", shortID(p), cs.resumeGrace)
+	cs.emitSessionEvent(evt)
+}
+
+func (cs *CommunicationService) sessionEventLocked(p peer.ID, sess *peerSession, kind SessionEventKind) SessionEvent {
+	evt := SessionEvent{
+		PeerID:      p.String(),
+		Kind:        kind,
+		LastSentSeq: sess.sendSeq,
+		LastRecvSeq: sess.recvSeq,
+		TTL:         sess.ttl,
+	}
+	for _, proto := range []protocol.ID{ChatProtocol, VideoProtocol, VoiceProtocol, ScreenShareProtocol} {
+		if sess.protocols[proto] {
+			evt.Protocols = append(evt.Protocols, proto)
+		}
+	}
+	if kind != SessionInterrupted {
+		evt.Downtime = time.Since(sess.disconnectedAt)
+	}
+	return evt
+}
+
+func (cs *CommunicationService) emitSessionEvent(evt SessionEvent) {
+	cs.mu.RLock()
+	cb := cs.onSessionEvent
+	cs.mu.RUnlock()
+	if cb != nil {
+		cb(evt)
+	}
+}
+
+// stopSessions cancels pending expiry timers on shutdown
+func (cs *CommunicationService) stopSessions() {
+	cs.sessionMu.Lock()
+	defer cs.sessionMu.Unlock()
+	for _, sess := range cs.sessions {
+		if sess.expiry != nil {
+			sess.expiry.Stop()
+		}
+	}
+	cs.sessions = make(map[peer.ID]*peerSession)
+}
+
+// removeStream deletes a peer's stream only if it has not been replaced
+func (cs *CommunicationService) removeStream(proto protocol.ID, p peer.ID, stream network.Stream) {
+	cs.streamMu.Lock()
+	defer cs.streamMu.Unlock()
+	streams := cs.chatStreams
+	switch proto {
+	case VideoProtocol:
+		streams = cs.videoStreams
+	case VoiceProtocol:
+		streams = cs.voiceStreams
+	}
+	if streams[p] == stream {
+		delete(streams, p)
+	}
+}
+
+func shortID(p peer.ID) string {
+	s := p.String()
+	if len(s) > 12 {
+		return s[:12]
+	}
+	return s
+}
@@ -0,0 +1,115 @@
+package communication
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func newTestService(t *testing.T, grace time.Duration) (*CommunicationService, host.Host) {
+	t.Helper()
+	h, err := libp2p.New(libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	if err != nil {
+		t.Fatalf("failed to create host: %v", err)
+	}
+	cs := NewCommunicationService(h, Config{DataDir: t.TempDir(), SessionResumeGrace: grace})
+	if err := cs.Start(); err != nil {
+		t.Fatalf("failed to start service: %v", err)
+	}
+	t.Cleanup(func() {
+		cs.Stop()
+		h.Close()
+	})
+	return cs, h
+}
+
+func waitForEvent(t *testing.T, events <-chan SessionEvent, kind SessionEventKind) SessionEvent {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case evt := <-events:
+			if evt.Kind == kind {
+				return evt
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for %s event", kind)
+		}
+	}
+}
+
+func TestChatSessionResumesAfterReconnect(t *testing.T) {
+	csA, hA := newTestService(t, 5*time.Second)
+	csB, hB := newTestService(t, 5*time.Second)
+
+	events := make(chan SessionEvent, 16)
+	csA.SetSessionCallback(func(evt SessionEvent) { events <- evt })
+
+	received := make(chan ChatMessage, 4)
+	csB.SetChatCallback(func(msg ChatMessage) { received <- msg })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	bInfo := peer.AddrInfo{ID: hB.ID(), Addrs: hB.Addrs()}
+	if err := hA.Connect(ctx, bInfo); err != nil {
+		t.Fatalf("connect failed: %v", err)
+	}
+
+	if err := csA.SendChatMessage(hB.ID(), "before"); err != nil {
+		t.Fatalf("send failed: %v", err)
+	}
+	if msg := <-received; msg.Seq != 1 {
+		t.Fatalf("expected seq 1, got %d", msg.Seq)
+	}
+
+	hA.Network().ClosePeer(hB.ID())
+	waitForEvent(t, events, SessionInterrupted)
+
+	if err := hA.Connect(ctx, bInfo); err != nil {
+		t.Fatalf("reconnect failed: %v", err)
+	}
+	evt := waitForEvent(t, events, SessionResumed)
+	if evt.LastSentSeq != 1 || len(evt.Protocols) != 1 || evt.Protocols[0] != ChatProtocol {
+		t.Errorf("unexpected resumed event: %+v", evt)
+	}
+
+	if err := csA.SendChatMessage(hB.ID(), "after"); err != nil {
+		t.Fatalf("send after resume failed: %v", err)
+	}
+	select {
+	case msg := <-received:
+		if msg.Seq != 2 || msg.Content != "after" {
+			t.Errorf("expected resumed seq 2, got %d (%q)", msg.Seq, msg.Content)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("message after resume not received")
+	}
+}
+
+func TestChatSessionExpiresAfterGrace(t *testing.T) {
+	csA, hA := newTestService(t, 200*time.Millisecond)
+	_, hB := newTestService(t, 200*time.Millisecond)
+
+	events := make(chan SessionEvent, 16)
+	csA.SetSessionCallback(func(evt SessionEvent) { events <- evt })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := hA.Connect(ctx, peer.AddrInfo{ID: hB.ID(), Addrs: hB.Addrs()}); err != nil {
+		t.Fatalf("connect failed: %v", err)
+	}
+	if err := csA.SendChatMessage(hB.ID(), "hello"); err != nil {
+		t.Fatalf("send failed: %v", err)
+	}
+
+	hA.Network().ClosePeer(hB.ID())
+	waitForEvent(t, events, SessionExpired)
+
+	if sent, _ := csA.ChatSequence(hB.ID()); sent != 0 {
+		t.Errorf("expected sequence reset after expiry, got %d", sent)
+	}
+}
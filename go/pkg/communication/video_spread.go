@@ -0,0 +1,405 @@
+package communication
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+// VideoSpreadProtocol carries erasure-coded fragments of large video
+// keyframes. Each fragment goes to a different relay peer, which passes it
+// on to the other receivers, so no single peer carries a whole keyframe to
+// everyone and losing a relay mid-stream loses no more than its fragments.
+const VideoSpreadProtocol protocol.ID = "/pangea/video-spread/1.0.0"
+
+const (
+	// DefaultSpreadMinFrameSize is the smallest keyframe that is spread;
+	// smaller frames are sent whole
+	DefaultSpreadMinFrameSize = 64 * 1024
+
+	// spreadReassemblyTimeout is how long a receiver waits for enough
+	// fragments of a frame before dropping it
+	spreadReassemblyTimeout = 2 * time.Second
+
+	// Limits on a single fragment message
+	maxSpreadFragmentSize = 10 * 1024 * 1024
+	maxSpreadFragments    = 255
+
+	// Wire layout: origin and each forward target are len(1) + peer ID
+	// bytes; the frame header is frameID(4) + width(2) + height(2) +
+	// quality(1) + flags(1) + index(1) + total(1) + dataShards(1), then
+	// forwardCount(1), the targets, and fragLen(4) followed by the fragment
+	spreadHeaderSize   = 13
+	spreadFlagDeliver  = 0x01 // The recipient is one of the frame's receivers
+	spreadFlagKeyframe = 0x02
+)
+
+// FrameCoder erasure-codes video frames for spreading. Encode splits a
+// frame into fragments of which any DataShards are enough for Decode,
+// which gets the fragments in index order with nil for missing ones.
+type FrameCoder interface {
+	Encode(data []byte) ([][]byte, error)
+	Decode(fragments [][]byte) ([]byte, error)
+	DataShards() int
+}
+
+// VideoSpreadConfig configures spreading of large keyframes. Everyone in
+// the call needs a coder that can decode the others' fragments.
+type VideoSpreadConfig struct {
+	Coder        FrameCoder
+	MinFrameSize int       // Smallest keyframe to spread, 0 = DefaultSpreadMinFrameSize
+	Relays       []peer.ID // Peers fragments are spread across, empty = the receivers
+}
+
+// spreadKey identifies a frame being reassembled
+type spreadKey struct {
+	origin  peer.ID
+	frameID uint32
+}
+
+// spreadAssembly collects the fragments of one frame
+type spreadAssembly struct {
+	frame     VideoFrame
+	fragments [][]byte
+	count     int
+	started   time.Time
+	delivered bool
+}
+
+// spreadFragment is one fragment message on the wire
+type spreadFragment struct {
+	origin     peer.ID
+	frame      VideoFrame // Without data
+	deliver    bool
+	index      uint8
+	total      uint8
+	dataShards uint8
+	forward    []peer.ID
+	data       []byte
+}
+
+// SetVideoSpread enables spreading of large keyframes sent with
+// SendGroupVideoFrame and reassembly of spread frames from others. A nil
+// config disables it.
+func (cs *CommunicationService) SetVideoSpread(config *VideoSpreadConfig) error {
+	if config != nil {
+		if config.Coder == nil {
+			return errors.New("video spreading needs a frame coder")
+		}
+		c := *config
+		if c.MinFrameSize <= 0 {
+			c.MinFrameSize = DefaultSpreadMinFrameSize
+		}
+		c.Relays = append([]peer.ID(nil), config.Relays...)
+		config = &c
+	}
+	cs.spreadMu.Lock()
+	defer cs.spreadMu.Unlock()
+	cs.spread = config
+	cs.spreadFrames = make(map[spreadKey]*spreadAssembly)
+	if config != nil {
+		log.Printf("🎞️  Video spreading enabled for keyframes of %d bytes or more", config.MinFrameSize)
+	}
+	return nil
+}
+
+// SendGroupVideoFrame sends a frame to every receiver. With spreading
+// enabled, large keyframes are erasure-coded and their fragments spread
+// across the relays; the frame is sent whole otherwise. A spread frame
+// fails only if too few fragments could be handed to a relay.
+func (cs *CommunicationService) SendGroupVideoFrame(receivers []peer.ID, frame VideoFrame) error {
+	cs.spreadMu.Lock()
+	config := cs.spread
+	cs.spreadMu.Unlock()
+
+	if config == nil || !frame.Keyframe || len(frame.Data) < config.MinFrameSize {
+		var errs []error
+		for _, p := range receivers {
+			if err := cs.SendVideoFrame(p, frame); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", shortID(p), err))
+			}
+		}
+		return errors.Join(errs...)
+	}
+
+	fragments, err := config.Coder.Encode(frame.Data)
+	if err != nil {
+		return fmt.Errorf("failed to encode frame %d: %w", frame.FrameID, err)
+	}
+	dataShards := config.Coder.DataShards()
+	if len(fragments) > maxSpreadFragments || dataShards <= 0 || dataShards > len(fragments) {
+		return fmt.Errorf("frame coder produced %d fragments needing %d", len(fragments), dataShards)
+	}
+
+	relays := config.Relays
+	if len(relays) == 0 {
+		relays = receivers
+	}
+	if len(relays) == 0 {
+		return errors.New("no receivers to spread the frame to")
+	}
+	isReceiver := make(map[peer.ID]bool, len(receivers))
+	for _, p := range receivers {
+		isReceiver[p] = true
+	}
+
+	// A fragment whose relay fails moves on to the next relay still up
+	down := make(map[peer.ID]bool)
+	sent := 0
+	for i, data := range fragments {
+		for j := 0; j < len(relays); j++ {
+			relay := relays[(i+j)%len(relays)]
+			if down[relay] {
+				continue
+			}
+			msg := spreadFragment{
+				origin:     cs.host.ID(),
+				frame:      frame,
+				deliver:    isReceiver[relay],
+				index:      uint8(i),
+				total:      uint8(len(fragments)),
+				dataShards: uint8(dataShards),
+				data:       data,
+			}
+			for _, p := range receivers {
+				if p != relay {
+					msg.forward = append(msg.forward, p)
+				}
+			}
+			if err := cs.sendSpreadFragment(relay, &msg); err != nil {
+				log.Printf("⚠️  Failed to spread fragment %d of frame %d via %s: %v", i, frame.FrameID, shortID(relay), err)
+				down[relay] = true
+				continue
+			}
+			sent++
+			break
+		}
+	}
+	if sent < dataShards {
+		return fmt.Errorf("only %d of %d fragments of frame %d reached a relay, %d needed", sent, len(fragments), frame.FrameID, dataShards)
+	}
+	return nil
+}
+
+// sendSpreadFragment writes one fragment message on a new stream
+func (cs *CommunicationService) sendSpreadFragment(p peer.ID, msg *spreadFragment) error {
+	ctx, cancel := context.WithTimeout(cs.ctx, 10*time.Second)
+	defer cancel()
+	stream, err := cs.host.NewStream(ctx, p, VideoSpreadProtocol)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+	stream.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	_, err = stream.Write(encodeSpreadFragment(msg))
+	return err
+}
+
+// handleSpreadStream receives a fragment, forwards it to the receivers the
+// sender listed and keeps it when this node is one of the receivers
+func (cs *CommunicationService) handleSpreadStream(stream network.Stream) {
+	defer stream.Close()
+	from := stream.Conn().RemotePeer()
+	stream.SetReadDeadline(time.Now().Add(10 * time.Second))
+	msg, err := readSpreadFragment(bufio.NewReader(stream))
+	if err != nil {
+		if cs.ctx.Err() == nil {
+			log.Printf("Video fragment from %s rejected: %v", shortID(from), err)
+		}
+		return
+	}
+
+	cs.spreadMu.Lock()
+	enabled := cs.spread != nil
+	cs.spreadMu.Unlock()
+	if !enabled {
+		return
+	}
+
+	// Only the origin may ask for forwarding, so relays cannot be used to
+	// amplify traffic
+	if len(msg.forward) > 0 && from == msg.origin {
+		fwd := *msg
+		fwd.deliver, fwd.forward = true, nil
+		for _, p := range msg.forward {
+			if p == cs.host.ID() || p == msg.origin {
+				continue
+			}
+			cs.wg.Add(1)
+			go func(p peer.ID) {
+				defer cs.wg.Done()
+				if err := cs.sendSpreadFragment(p, &fwd); err != nil && cs.ctx.Err() == nil {
+					log.Printf("⚠️  Failed to relay fragment %d of frame %d to %s: %v", fwd.index, fwd.frame.FrameID, shortID(p), err)
+				}
+			}(p)
+		}
+	}
+	if msg.deliver {
+		cs.addSpreadFragment(msg)
+	}
+}
+
+// addSpreadFragment stores a fragment and delivers its frame once enough
+// fragments decode
+func (cs *CommunicationService) addSpreadFragment(msg *spreadFragment) {
+	cs.spreadMu.Lock()
+	if cs.spread == nil {
+		cs.spreadMu.Unlock()
+		return
+	}
+	coder := cs.spread.Coder
+	now := time.Now()
+	for k, a := range cs.spreadFrames {
+		if now.Sub(a.started) > spreadReassemblyTimeout {
+			if !a.delivered {
+				log.Printf("⚠️  Dropped frame %d from %s with %d of %d fragments", k.frameID, shortID(k.origin), a.count, len(a.fragments))
+			}
+			delete(cs.spreadFrames, k)
+		}
+	}
+
+	key := spreadKey{origin: msg.origin, frameID: msg.frame.FrameID}
+	a, ok := cs.spreadFrames[key]
+	if !ok {
+		a = &spreadAssembly{frame: msg.frame, fragments: make([][]byte, msg.total), started: now}
+		cs.spreadFrames[key] = a
+	}
+	if a.delivered || int(msg.index) >= len(a.fragments) || a.fragments[msg.index] != nil {
+		cs.spreadMu.Unlock()
+		return
+	}
+	a.fragments[msg.index] = msg.data
+	a.count++
+	if a.count < int(msg.dataShards) {
+		cs.spreadMu.Unlock()
+		return
+	}
+	fragments := append([][]byte(nil), a.fragments...)
+	cs.spreadMu.Unlock()
+
+	// A fragment that fails to decode leaves the frame waiting for more
+	data, err := coder.Decode(fragments)
+	if err != nil {
+		log.Printf("Frame %d from %s did not decode from %d fragments: %v", key.frameID, shortID(key.origin), len(fragments), err)
+		return
+	}
+	cs.spreadMu.Lock()
+	if a.delivered {
+		cs.spreadMu.Unlock()
+		return
+	}
+	a.delivered = true
+	frame := a.frame
+	cs.spreadMu.Unlock()
+
+	frame.Data = data
+	frame.Timestamp = time.Now()
+	cs.mu.RLock()
+	cb := cs.onVideoFrame
+	cs.mu.RUnlock()
+	if cb != nil {
+		cb(key.origin.String(), frame)
+	}
+}
+
+func encodeSpreadFragment(msg *spreadFragment) []byte {
+	buf := appendPeerID(nil, msg.origin)
+	header := make([]byte, spreadHeaderSize)
+	binary.BigEndian.PutUint32(header[0:4], msg.frame.FrameID)
+	binary.BigEndian.PutUint16(header[4:6], msg.frame.Width)
+	binary.BigEndian.PutUint16(header[6:8], msg.frame.Height)
+	header[8] = msg.frame.Quality
+	if msg.deliver {
+		header[9] |= spreadFlagDeliver
+	}
+	if msg.frame.Keyframe {
+		header[9] |= spreadFlagKeyframe
+	}
+	header[10], header[11], header[12] = msg.index, msg.total, msg.dataShards
+	buf = append(buf, header...)
+	buf = append(buf, byte(len(msg.forward)))
+	for _, p := range msg.forward {
+		buf = appendPeerID(buf, p)
+	}
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(msg.data)))
+	return append(buf, msg.data...)
+}
+
+func readSpreadFragment(r *bufio.Reader) (*spreadFragment, error) {
+	origin, err := readPeerID(r)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, spreadHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	msg := &spreadFragment{
+		origin: origin,
+		frame: VideoFrame{
+			FrameID:  binary.BigEndian.Uint32(header[0:4]),
+			Width:    binary.BigEndian.Uint16(header[4:6]),
+			Height:   binary.BigEndian.Uint16(header[6:8]),
+			Quality:  header[8],
+			Keyframe: header[9]&spreadFlagKeyframe != 0,
+		},
+		deliver:    header[9]&spreadFlagDeliver != 0,
+		index:      header[10],
+		total:      header[11],
+		dataShards: header[12],
+	}
+	if msg.total == 0 || msg.index >= msg.total || msg.dataShards == 0 || msg.dataShards > msg.total {
+		return nil, fmt.Errorf("invalid fragment %d of %d needing %d", msg.index, msg.total, msg.dataShards)
+	}
+
+	count, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < int(count); i++ {
+		p, err := readPeerID(r)
+		if err != nil {
+			return nil, err
+		}
+		msg.forward = append(msg.forward, p)
+	}
+
+	lengthBuf := make([]byte, 4)
+	if _, err := io.ReadFull(r, lengthBuf); err != nil {
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(lengthBuf)
+	if size > maxSpreadFragmentSize {
+		return nil, fmt.Errorf("fragment too large: %d bytes", size)
+	}
+	msg.data = make([]byte, size)
+	if _, err := io.ReadFull(r, msg.data); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+func appendPeerID(buf []byte, p peer.ID) []byte {
+	return append(append(buf, byte(len(p))), p...)
+}
+
+func readPeerID(r *bufio.Reader) (peer.ID, error) {
+	n, err := r.ReadByte()
+	if err != nil {
+		return "", err
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return "", err
+	}
+	return peer.IDFromBytes(b)
+}
@@ -0,0 +1,158 @@
+package communication
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// xorCoder splits a frame in two halves plus their XOR, so any two of the
+// three fragments decode. Each fragment starts with the frame length.
+type xorCoder struct{}
+
+func (xorCoder) DataShards() int { return 2 }
+
+func (xorCoder) Encode(data []byte) ([][]byte, error) {
+	half := (len(data) + 1) / 2
+	a, b := make([]byte, half), make([]byte, half)
+	copy(a, data[:half])
+	copy(b, data[half:])
+	parity := make([]byte, half)
+	for i := range parity {
+		parity[i] = a[i] ^ b[i]
+	}
+	out := make([][]byte, 3)
+	for i, part := range [][]byte{a, b, parity} {
+		out[i] = binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+		out[i] = append(out[i], part...)
+	}
+	return out, nil
+}
+
+func (xorCoder) Decode(fragments [][]byte) ([]byte, error) {
+	var parts [3][]byte
+	var size uint32
+	for i, f := range fragments {
+		if f != nil {
+			size, parts[i] = binary.BigEndian.Uint32(f), f[4:]
+		}
+	}
+	for i := range 2 {
+		if parts[i] == nil {
+			other, parity := parts[1-i], parts[2]
+			if other == nil || parity == nil {
+				return nil, errors.New("too few fragments")
+			}
+			parts[i] = make([]byte, len(parity))
+			for j := range parity {
+				parts[i][j] = other[j] ^ parity[j]
+			}
+		}
+	}
+	return append(append([]byte(nil), parts[0]...), parts[1]...)[:size], nil
+}
+
+func TestSpreadFrameReassemblesFromAnyDataShards(t *testing.T) {
+	cs, _ := newTestService(t, time.Second)
+	if err := cs.SetVideoSpread(&VideoSpreadConfig{Coder: xorCoder{}}); err != nil {
+		t.Fatal(err)
+	}
+	frames := make(chan VideoFrame, 2)
+	cs.SetVideoCallback(func(peerID string, frame VideoFrame) { frames <- frame })
+
+	data := []byte("a keyframe of odd length")
+	fragments, _ := xorCoder{}.Encode(data)
+	origin := cs.host.ID()
+	for _, i := range []int{2, 2, 0, 1} { // A duplicate, then one more than needed
+		cs.addSpreadFragment(&spreadFragment{
+			origin:     origin,
+			frame:      VideoFrame{FrameID: 7, Keyframe: true},
+			deliver:    true,
+			index:      uint8(i),
+			total:      3,
+			dataShards: 2,
+			data:       fragments[i],
+		})
+	}
+
+	select {
+	case frame := <-frames:
+		if frame.FrameID != 7 || !frame.Keyframe || !bytes.Equal(frame.Data, data) {
+			t.Fatalf("unexpected frame %+v", frame)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("frame was not delivered")
+	}
+	select {
+	case frame := <-frames:
+		t.Fatalf("frame %d delivered twice", frame.FrameID)
+	default:
+	}
+}
+
+func TestSendGroupVideoFrameSurvivesRelayLoss(t *testing.T) {
+	sender, hA := newTestService(t, time.Second)
+	var receivers []*CommunicationService
+	var hosts []host.Host
+	for range 3 {
+		cs, h := newTestService(t, time.Second)
+		receivers, hosts = append(receivers, cs), append(hosts, h)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for i, h := range hosts {
+		for _, other := range append([]host.Host{hA}, hosts[i+1:]...) {
+			if err := h.Connect(ctx, peer.AddrInfo{ID: other.ID(), Addrs: other.Addrs()}); err != nil {
+				t.Fatalf("connect failed: %v", err)
+			}
+		}
+	}
+
+	received := make(chan string, 8)
+	ids := make([]peer.ID, len(hosts))
+	for i, cs := range receivers {
+		ids[i] = hosts[i].ID()
+		if err := cs.SetVideoSpread(&VideoSpreadConfig{Coder: xorCoder{}}); err != nil {
+			t.Fatal(err)
+		}
+		want := hA.ID().String()
+		cs.SetVideoCallback(func(peerID string, frame VideoFrame) {
+			if peerID == want && frame.Keyframe && len(frame.Data) == 2000 {
+				received <- ids[i].String()
+			}
+		})
+	}
+	if err := sender.SetVideoSpread(&VideoSpreadConfig{Coder: xorCoder{}, MinFrameSize: 1000}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The third receiver drops out mid-call; its fragment goes to the next
+	// relay and the remaining receivers still get the frame
+	receivers[2].Stop()
+	hosts[2].Close()
+
+	frame := VideoFrame{FrameID: 1, Keyframe: true, Data: bytes.Repeat([]byte{0xab}, 2000)}
+	if err := sender.SendGroupVideoFrame(ids, frame); err != nil {
+		t.Fatalf("SendGroupVideoFrame failed: %v", err)
+	}
+	got := map[string]bool{}
+	timeout := time.After(5 * time.Second)
+	for len(got) < 2 {
+		select {
+		case id := <-received:
+			got[id] = true
+		case <-timeout:
+			t.Fatalf("only %d receivers got the frame", len(got))
+		}
+	}
+	if got[ids[2].String()] {
+		t.Fatal("a stopped receiver got the frame")
+	}
+}
@@ -0,0 +1,196 @@
+package compute
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrQueueFull means a job was turned away because too many are waiting to
+// run; the submitter should retry later
+var ErrQueueFull = errors.New("job queue full")
+
+// QueueStats describes the job admission queue
+type QueueStats struct {
+	Running       int    `json:"running"`       // Jobs holding a slot
+	Queued        int    `json:"queued"`        // Jobs ready and waiting for a slot
+	Pending       int    `json:"pending"`       // Accepted jobs not yet running, including those held for a start time or dependencies
+	MaxConcurrent int    `json:"maxConcurrent"` // 0 = unlimited
+	MaxQueued     int    `json:"maxQueued"`     // 0 = unlimited
+	Admitted      uint64 `json:"admitted"`      // Jobs that got a slot
+	Rejected      uint64 `json:"rejected"`      // Submissions turned away by the queue limit
+	AvgWaitMs     uint64 `json:"avgWaitMs"`     // Mean time admitted jobs waited for a slot
+}
+
+// jobQueue admits jobs to a bounded number of slots, highest priority
+// first and in submission order within a priority. Accepted jobs count
+// against maxQueued until they hold a slot.
+type jobQueue struct {
+	mu        sync.Mutex
+	limit     int // Slots; 0 = unlimited
+	maxQueued int // Pending jobs; 0 = unlimited
+	running   int
+	pending   int
+	waiting   []*queuedJob
+	seq       uint64
+	admitted  uint64
+	rejected  uint64
+	waitTotal time.Duration
+}
+
+// queuedJob is a job waiting for a slot
+type queuedJob struct {
+	jobID    string
+	priority uint32
+	seq      uint64
+	enqueued time.Time
+	granted  bool
+	ready    chan struct{} // Closed when granted a slot or removed
+}
+
+func newJobQueue(limit, maxQueued int) *jobQueue {
+	return &jobQueue{limit: limit, maxQueued: maxQueued}
+}
+
+// reserve accepts n jobs into the queue, or none of them if that would
+// take it past maxQueued
+func (q *jobQueue) reserve(n int) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.maxQueued > 0 && q.pending+n > q.maxQueued {
+		q.rejected += uint64(n)
+		return fmt.Errorf("%w: %d jobs pending, limit is %d", ErrQueueFull, q.pending, q.maxQueued)
+	}
+	q.pending += n
+	return nil
+}
+
+// leave drops an accepted job that will never ask for a slot
+func (q *jobQueue) leave() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.pending--
+}
+
+// acquire waits for a slot for an accepted job. Returns false, with the job
+// out of the queue, if ctx ends or the job is removed first.
+func (q *jobQueue) acquire(ctx context.Context, jobID string, priority uint32) bool {
+	q.mu.Lock()
+	if q.limit <= 0 || (q.running < q.limit && len(q.waiting) == 0) {
+		q.running++
+		q.pending--
+		q.admitted++
+		q.mu.Unlock()
+		return true
+	}
+	q.seq++
+	job := &queuedJob{jobID: jobID, priority: priority, seq: q.seq, enqueued: time.Now(), ready: make(chan struct{})}
+	q.waiting = append(q.waiting, job)
+	sort.SliceStable(q.waiting, func(i, j int) bool {
+		a, b := q.waiting[i], q.waiting[j]
+		if a.priority != b.priority {
+			return a.priority > b.priority
+		}
+		return a.seq < b.seq
+	})
+	q.mu.Unlock()
+
+	select {
+	case <-job.ready:
+		return job.granted
+	case <-ctx.Done():
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if job.granted {
+		// Granted as ctx ended; pass the slot on
+		q.releaseLocked()
+		return false
+	}
+	q.removeLocked(job)
+	return false
+}
+
+// release frees a slot, handing it to the first waiting job
+func (q *jobQueue) release() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.releaseLocked()
+}
+
+func (q *jobQueue) releaseLocked() {
+	q.running--
+	if len(q.waiting) == 0 || q.running >= q.limit {
+		return
+	}
+	next := q.waiting[0]
+	q.waiting = q.waiting[1:]
+	q.running++
+	q.pending--
+	q.admitted++
+	q.waitTotal += time.Since(next.enqueued)
+	next.granted = true
+	close(next.ready)
+}
+
+// remove takes a job waiting for a slot out of the queue, as when it is
+// cancelled
+func (q *jobQueue) remove(jobID string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, job := range q.waiting {
+		if job.jobID == jobID {
+			q.removeLocked(job)
+			return
+		}
+	}
+}
+
+func (q *jobQueue) removeLocked(job *queuedJob) {
+	for i, w := range q.waiting {
+		if w == job {
+			q.waiting = append(q.waiting[:i], q.waiting[i+1:]...)
+			q.pending--
+			close(job.ready)
+			return
+		}
+	}
+}
+
+func (q *jobQueue) stats() QueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	s := QueueStats{
+		Running:       q.running,
+		Queued:        len(q.waiting),
+		Pending:       q.pending,
+		MaxConcurrent: q.limit,
+		MaxQueued:     q.maxQueued,
+		Admitted:      q.admitted,
+		Rejected:      q.rejected,
+	}
+	if q.admitted > 0 {
+		s.AvgWaitMs = uint64(q.waitTotal.Milliseconds()) / q.admitted
+	}
+	return s
+}
+
+// depth returns the jobs holding a slot and those waiting, by priority
+func (q *jobQueue) depth() (int, map[uint32]int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	waiting := make(map[uint32]int)
+	for _, job := range q.waiting {
+		waiting[job.priority]++
+	}
+	return q.running, waiting
+}
+
+// QueueStats returns the state of the job admission queue
+func (m *Manager) QueueStats() QueueStats {
+	return m.queue.stats()
+}
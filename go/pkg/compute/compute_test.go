@@ -1,12 +1,66 @@
 package compute
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
 )
 
+// sandboxProbeEnv makes the test binary lock itself down like a sandbox
+// child and report which escapes still work
+const sandboxProbeEnv = "PANGEA_COMPUTE_SANDBOX_PROBE"
+
+// sandboxCrashEnv makes a sandbox child started from the test binary
+// crash before running its task
+const sandboxCrashEnv = "PANGEA_COMPUTE_SANDBOX_CRASH"
+
+var sandboxProbeSink []byte
+
+func TestMain(m *testing.M) {
+	if os.Getenv(sandboxCrashEnv) != "" && os.Getenv(sandboxEnv) != "" {
+		panic("sandbox child crashed")
+	}
+	RunSandboxIfChild()
+	if os.Getenv(sandboxProbeEnv) != "" {
+		if err := applySandboxLimits(sandboxLimits{MemoryMB: 16}); err != nil {
+			fmt.Println("limits:", err)
+			os.Exit(1)
+		}
+		if f, err := os.Open(os.Args[0]); err == nil {
+			f.Close()
+			fmt.Println("opened a file")
+		}
+		if l, err := net.Listen("tcp", "127.0.0.1:0"); err == nil {
+			l.Close()
+			fmt.Println("opened a socket")
+		}
+		if err := exec.Command(os.Args[0], "-test.run=^$").Run(); err == nil {
+			fmt.Println("ran a program")
+		}
+		// Far past the 16MB budget, so the runtime gives up
+		sandboxProbeSink = make([]byte, 1<<30)
+		fmt.Println("allocated 1GB")
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
 func TestNewManager(t *testing.T) {
 	config := DefaultConfig()
 	manager := NewManager(config)
@@ -92,6 +146,33 @@ func TestGetJobStatusNotFound(t *testing.T) {
 	}
 }
 
+func TestListJobs(t *testing.T) {
+	manager := NewManager(DefaultConfig())
+	defer manager.Close()
+
+	if jobs := manager.ListJobs(); len(jobs) != 0 {
+		t.Fatalf("Expected no jobs, got %d", len(jobs))
+	}
+	for _, id := range []string{"list-job-1", "list-job-2"} {
+		if _, err := manager.SubmitJob(&JobManifest{JobID: id, InputData: []byte("test data")}); err != nil {
+			t.Fatalf("SubmitJob failed: %v", err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	jobs := manager.ListJobs()
+	if len(jobs) != 2 || jobs[0].JobID != "list-job-1" || jobs[1].JobID != "list-job-2" {
+		t.Fatalf("Expected both jobs oldest first, got %+v", jobs)
+	}
+	if manager.Err() != nil {
+		t.Fatalf("Expected open manager, got %v", manager.Err())
+	}
+	manager.Close()
+	if manager.Err() == nil {
+		t.Error("Expected an error after Close")
+	}
+}
+
 func TestCancelJob(t *testing.T) {
 	config := DefaultConfig()
 	manager := NewManager(config)
@@ -138,6 +219,45 @@ func TestGetCapacity(t *testing.T) {
 	}
 }
 
+func TestCapacityFromHostMetrics(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("host metrics are read from /proc")
+	}
+	config := DefaultConfig()
+	config.CapacityRefreshInterval = 20 * time.Millisecond
+	manager := NewManager(config)
+	defer manager.Close()
+
+	time.Sleep(100 * time.Millisecond) // Let the probe take a utilization delta
+	capacity := manager.GetCapacity()
+
+	if capacity.TotalRAMMB == 0 || capacity.RAMMB > capacity.TotalRAMMB {
+		t.Errorf("expected available RAM within total, got %d of %d MB", capacity.RAMMB, capacity.TotalRAMMB)
+	}
+	if len(capacity.CoreLoads) != int(capacity.CPUCores) {
+		t.Errorf("expected a load per core, got %d for %d cores", len(capacity.CoreLoads), capacity.CPUCores)
+	}
+	for i, load := range append(capacity.CoreLoads, capacity.CurrentLoad) {
+		if load < 0 || load > 1 {
+			t.Errorf("load %d out of range: %f", i, load)
+		}
+	}
+	if capacity.DiskMB == 0 {
+		t.Error("expected free disk space")
+	}
+}
+
+func TestUtilizationBetweenSamples(t *testing.T) {
+	prev := cpuTimes{busy: 100, total: 400}
+	if got := utilization(prev, cpuTimes{busy: 150, total: 500}); got != 0.5 {
+		t.Errorf("expected 0.5, got %f", got)
+	}
+	// Counter resets (e.g. CPU hotplug) report idle rather than garbage
+	if got := utilization(prev, cpuTimes{busy: 50, total: 300}); got != 0 {
+		t.Errorf("expected 0 after counter reset, got %f", got)
+	}
+}
+
 func TestRegisterWorker(t *testing.T) {
 	config := DefaultConfig()
 	manager := NewManager(config)
@@ -196,7 +316,14 @@ func TestSplitData(t *testing.T) {
 		data[i] = byte(i % 256)
 	}
 
-	chunks := manager.splitData(data, 10*1024, 50*1024)
+	strategy, err := GetSplitStrategy(SplitFixedSize)
+	if err != nil {
+		t.Fatalf("fixed-size strategy missing: %v", err)
+	}
+	chunks, err := strategy.Split(data, 10*1024, 50*1024)
+	if err != nil {
+		t.Fatalf("split failed: %v", err)
+	}
 
 	if len(chunks) == 0 {
 		t.Error("Expected at least one chunk")
@@ -220,6 +347,238 @@ func TestSplitData(t *testing.T) {
 	}
 }
 
+func TestMatrixRowSplitMultipliesCorrectly(t *testing.T) {
+	a := make([][]float64, 7)
+	for i := range a {
+		a[i] = []float64{float64(i), 1, 2}
+	}
+	b := [][]float64{{1, 0}, {0, 1}, {1, 1}}
+	input := encodeMatrices(a, b)
+	expected, err := ExecuteMatrixBlockMultiply(input)
+	if err != nil {
+		t.Fatalf("direct multiply failed: %v", err)
+	}
+
+	strategy, err := GetSplitStrategy(SplitMatrixRows)
+	if err != nil {
+		t.Fatalf("matrix strategy missing: %v", err)
+	}
+	// Room for the header, B and two rows of A per chunk
+	maxSize := int64(8 + 8 + 3*2*8 + 2*3*8)
+	chunks, err := strategy.Split(input, 0, maxSize)
+	if err != nil {
+		t.Fatalf("split failed: %v", err)
+	}
+	if len(chunks) != 4 {
+		t.Fatalf("expected 4 row blocks, got %d", len(chunks))
+	}
+
+	results := make([][]byte, len(chunks))
+	for i, chunk := range chunks {
+		if int64(len(chunk)) > maxSize {
+			t.Errorf("chunk %d is %d bytes, over %d", i, len(chunk), maxSize)
+		}
+		if results[i], err = ExecuteMatrixBlockMultiply(chunk); err != nil {
+			t.Fatalf("chunk %d is not a valid input: %v", i, err)
+		}
+	}
+	reducer, err := GetReducer(strategy.Reducer())
+	if err != nil {
+		t.Fatalf("matrix reducer missing: %v", err)
+	}
+	merged, err := reducer.Reduce(results)
+	if err != nil {
+		t.Fatalf("merge failed: %v", err)
+	}
+	if !bytes.Equal(merged, expected) {
+		t.Error("merged row blocks differ from the direct product")
+	}
+}
+
+func TestLineAndRecordSplitKeepBoundaries(t *testing.T) {
+	lines, _ := GetSplitStrategy(SplitLines)
+	chunks, err := lines.Split([]byte("alpha\nbeta\ngamma\nverylongline\nz"), 0, 11)
+	if err != nil {
+		t.Fatalf("line split failed: %v", err)
+	}
+	want := []string{"alpha\nbeta\n", "gamma\n", "verylongline\n", "z"}
+	if len(chunks) != len(want) {
+		t.Fatalf("expected %d chunks, got %q", len(want), chunks)
+	}
+	for i := range want {
+		if string(chunks[i]) != want[i] {
+			t.Errorf("chunk %d: expected %q, got %q", i, want[i], chunks[i])
+		}
+	}
+
+	var data []byte
+	for _, rec := range []string{"one", "two", "three"} {
+		data = binary.BigEndian.AppendUint32(data, uint32(len(rec)))
+		data = append(data, rec...)
+	}
+	records, _ := GetSplitStrategy(SplitRecords)
+	chunks, err = records.Split(data, 0, 14)
+	if err != nil {
+		t.Fatalf("record split failed: %v", err)
+	}
+	if len(chunks) != 2 || len(chunks[0]) != 14 || len(chunks[1]) != 9 {
+		t.Errorf("expected records grouped as 14+9 bytes, got %d chunks", len(chunks))
+	}
+	if _, err := records.Split(data[:len(data)-1], 0, 14); err == nil {
+		t.Error("expected a truncated record to be rejected")
+	}
+}
+
+func TestSumBlocksReducerAddsPartialProducts(t *testing.T) {
+	a := [][]float64{{1, 2, 3, 4}, {5, 6, 7, 8}}
+	b := [][]float64{{1, 0}, {0, 1}, {2, 0}, {0, 2}}
+	expected, err := ExecuteMatrixBlockMultiply(encodeMatrices(a, b))
+	if err != nil {
+		t.Fatalf("direct multiply failed: %v", err)
+	}
+
+	// Split the inner dimension: A by columns, B by rows
+	var partials [][]byte
+	for _, k := range [][2]int{{0, 2}, {2, 4}} {
+		aPart := make([][]float64, len(a))
+		for i := range a {
+			aPart[i] = a[i][k[0]:k[1]]
+		}
+		partial, err := ExecuteMatrixBlockMultiply(encodeMatrices(aPart, b[k[0]:k[1]]))
+		if err != nil {
+			t.Fatalf("partial multiply failed: %v", err)
+		}
+		partials = append(partials, partial)
+	}
+
+	reducer, err := GetReducer(ReducerSumBlocks)
+	if err != nil {
+		t.Fatalf("sum reducer missing: %v", err)
+	}
+	sum, err := reducer.Reduce(partials)
+	if err != nil {
+		t.Fatalf("reduce failed: %v", err)
+	}
+	if !bytes.Equal(sum, expected) {
+		t.Error("summed partial products differ from the direct product")
+	}
+	if _, err := reducer.Reduce([][]byte{partials[0], expected[:16]}); err == nil {
+		t.Error("expected mismatched shapes to be rejected")
+	}
+}
+
+type fakeWASMRuntime struct{}
+
+func (fakeWASMRuntime) Reduce(module []byte, results [][]byte) ([]byte, error) {
+	return append([]byte(nil), module...), nil
+}
+
+func (fakeWASMRuntime) PostProcess(module []byte, result []byte) ([]byte, error) {
+	return append(append([]byte(nil), module...), result...), nil
+}
+
+func TestWASMReducerNeedsRuntime(t *testing.T) {
+	manager := NewManager(DefaultConfig())
+	defer manager.Close()
+
+	manifest := &JobManifest{WASMModule: []byte("mod"), InputData: []byte{1}, Reducer: ReducerWASM}
+	if _, err := manager.SubmitJob(manifest); err == nil {
+		t.Fatal("expected the wasm reducer to be rejected without a runtime")
+	}
+
+	manager.SetWASMRuntime(fakeWASMRuntime{})
+	manager.mu.RLock()
+	reducer, err := manager.jobReducer(manifest)
+	manager.mu.RUnlock()
+	if err != nil {
+		t.Fatalf("jobReducer failed: %v", err)
+	}
+	if out, _ := reducer.Reduce([][]byte{{1}}); string(out) != "mod" {
+		t.Errorf("expected the job's module to be run, got %q", out)
+	}
+}
+
+func TestJobGraphFeedsStageOutputsForward(t *testing.T) {
+	manager := NewManager(DefaultConfig())
+	defer manager.Close()
+
+	a := [][]float64{{1, 2}, {3, 4}}
+	b := [][]float64{{0, 1}, {1, 0}}
+	d := [][]float64{{2, 0}, {0, 3}}
+	ab, _ := ExecuteMatrixBlockMultiply(encodeMatrices(a, b))
+	expected, err := ExecuteMatrixBlockMultiply(append(ab, encodeMatrices(d)...))
+	if err != nil {
+		t.Fatalf("direct multiply failed: %v", err)
+	}
+
+	// Listed out of order: the graph is sorted before submission
+	ids, err := manager.SubmitJobGraph([]*JobManifest{
+		{JobID: "abd", InputData: encodeMatrices(d), DependsOn: []string{"ab"}, MaxChunkSize: 1 << 20},
+		{JobID: "ab", InputData: encodeMatrices(a, b), MaxChunkSize: 1 << 20},
+	})
+	if err != nil {
+		t.Fatalf("SubmitJobGraph failed: %v", err)
+	}
+	if len(ids) != 2 || ids[0] != "ab" || ids[1] != "abd" {
+		t.Errorf("expected topological order [ab abd], got %v", ids)
+	}
+
+	result, err := manager.GetJobResult("abd", 10*time.Second)
+	if err != nil {
+		t.Fatalf("pipeline failed: %v", err)
+	}
+	if !bytes.Equal(result, expected) {
+		t.Error("pipeline result differs from (A*B)*D")
+	}
+}
+
+func TestJobGraphRejectsCyclesAndFailsDownstream(t *testing.T) {
+	manager := NewManager(DefaultConfig())
+	defer manager.Close()
+
+	_, err := manager.SubmitJobGraph([]*JobManifest{
+		{JobID: "x", InputData: []byte{1}, DependsOn: []string{"y"}},
+		{JobID: "y", InputData: []byte{1}, DependsOn: []string{"x"}},
+	})
+	if err == nil {
+		t.Fatal("expected a cyclic graph to be rejected")
+	}
+	if _, err := manager.GetJobStatus("x"); err == nil {
+		t.Error("rejected graph should not submit any job")
+	}
+	if _, err := manager.SubmitJob(&JobManifest{JobID: "z", DependsOn: []string{"missing"}}); err == nil {
+		t.Error("expected an unknown dependency to be rejected")
+	}
+
+	// An upstream failure fails the stages that consume it
+	if _, err := manager.SubmitJobGraph([]*JobManifest{
+		{JobID: "bad", InputData: []byte{1, 2, 3}},
+		{JobID: "after-bad", InputData: []byte{1}, DependsOn: []string{"bad"}},
+	}); err != nil {
+		t.Fatalf("SubmitJobGraph failed: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		status, _ := manager.GetJobStatus("after-bad")
+		if status.Status == TaskFailed {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("downstream job not failed, status %s", status.Status)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestSubmitJobRejectsUnknownSplitStrategy(t *testing.T) {
+	manager := NewManager(DefaultConfig())
+	defer manager.Close()
+
+	if _, err := manager.SubmitJob(&JobManifest{InputData: []byte{1}, SplitStrategy: "semantic"}); err == nil {
+		t.Error("expected an unknown split strategy to be rejected")
+	}
+}
+
 func TestDefaultConfig(t *testing.T) {
 	config := DefaultConfig()
 
@@ -289,6 +648,94 @@ func TestScheduler(t *testing.T) {
 	}
 }
 
+func TestSchedulerPerJobLimit(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxChunksPerJob = 2
+	manager := NewManager(config)
+	defer manager.Close()
+
+	scheduler := NewScheduler(manager)
+	for i := 0; i < 3; i++ {
+		scheduler.Schedule(&ComputeTask{
+			TaskID:      fmt.Sprintf("a-%d", i),
+			ParentJobID: "job-a",
+			ChunkIndex:  uint32(i),
+		}, 10)
+	}
+	scheduler.Schedule(&ComputeTask{TaskID: "b-0", ParentJobID: "job-b"}, 1)
+
+	first := scheduler.GetNextTask()
+	second := scheduler.GetNextTask()
+	if first.ParentJobID != "job-a" || second.ParentJobID != "job-a" {
+		t.Fatalf("Expected two job-a chunks first, got %s and %s", first.TaskID, second.TaskID)
+	}
+
+	// job-a is at its limit, so the lower priority job-b chunk runs next
+	next := scheduler.GetNextTask()
+	if next == nil || next.TaskID != "b-0" {
+		t.Fatalf("Expected b-0 while job-a is at its limit, got %v", next)
+	}
+	if scheduler.GetNextTask() != nil {
+		t.Error("Expected no eligible task while job-a is at its limit")
+	}
+
+	scheduler.TaskDone(first)
+	next = scheduler.GetNextTask()
+	if next == nil || next.TaskID != "a-2" {
+		t.Errorf("Expected a-2 after a slot was released, got %v", next)
+	}
+}
+
+func TestSchedulerPreemptsQueuedLowPriority(t *testing.T) {
+	config := DefaultConfig()
+	manager := NewManager(config)
+	defer manager.Close()
+
+	scheduler := NewScheduler(manager)
+	scheduler.Schedule(&ComputeTask{TaskID: "low-0", ParentJobID: "low"}, 1)
+	scheduler.Schedule(&ComputeTask{TaskID: "low-1", ParentJobID: "low"}, 1)
+
+	// A high priority chunk arriving later jumps ahead of queued low priority ones
+	scheduler.Schedule(&ComputeTask{TaskID: "high-0", ParentJobID: "high"}, 9)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	next := scheduler.WaitNextTask(ctx)
+	if next == nil || next.TaskID != "high-0" {
+		t.Fatalf("Expected high-0 to preempt queued chunks, got %v", next)
+	}
+
+	// WaitNextTask returns nil once the context is cancelled
+	scheduler.GetNextTask()
+	scheduler.GetNextTask()
+	cancel()
+	if task := scheduler.WaitNextTask(ctx); task != nil {
+		t.Errorf("Expected nil after cancel, got %s", task.TaskID)
+	}
+}
+
+func TestSchedulerWaitUnblocksOnCancel(t *testing.T) {
+	manager := NewManager(DefaultConfig())
+	defer manager.Close()
+	scheduler := NewScheduler(manager)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan *ComputeTask)
+	go func() { done <- scheduler.WaitNextTask(ctx) }()
+
+	// Cancel while the caller is blocked on an empty queue
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	select {
+	case task := <-done:
+		if task != nil {
+			t.Errorf("Expected nil after cancel, got %s", task.TaskID)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("WaitNextTask did not return after its context was cancelled")
+	}
+}
+
 func TestSchedulerSelectWorker(t *testing.T) {
 	config := DefaultConfig()
 	manager := NewManager(config)
@@ -493,3 +940,1180 @@ func TestDelegatorHasWorkersCheck(t *testing.T) {
 		t.Errorf("Expected DelegateTask not to be called when no workers, but called %d times", delegateCount)
 	}
 }
+
+// stragglerDelegator finishes tasks instantly on every worker except slow,
+// which blocks until its context is cancelled
+type stragglerDelegator struct {
+	workers   []string
+	slow      string
+	cancelled atomic.Int32
+}
+
+func (d *stragglerDelegator) DelegateTask(ctx context.Context, workerID string, task *ComputeTask) (*TaskResult, error) {
+	if workerID == d.slow {
+		<-ctx.Done()
+		d.cancelled.Add(1)
+		return nil, ctx.Err()
+	}
+	return &TaskResult{
+		TaskID:     task.TaskID,
+		Status:     TaskCompleted,
+		ResultData: []byte("ok"),
+		WorkerID:   workerID,
+	}, nil
+}
+
+func (d *stragglerDelegator) GetAvailableWorkers() []string { return d.workers }
+
+func (d *stragglerDelegator) HasWorkers() bool { return len(d.workers) > 0 }
+
+func TestStragglerChunksAreStolen(t *testing.T) {
+	config := DefaultConfig()
+	config.StragglerFactor = 2
+	config.StragglerMinSamples = 1
+	config.StragglerMinDeadline = 50 * time.Millisecond
+	manager := NewManager(config)
+	defer manager.Close()
+
+	delegator := &stragglerDelegator{workers: []string{"fast", "slow"}, slow: "slow"}
+	manager.SetDelegator(delegator)
+
+	manifest := &JobManifest{
+		JobID:        "straggler-job",
+		InputData:    make([]byte, 40),
+		MinChunkSize: 10,
+		MaxChunkSize: 10,
+		TimeoutSecs:  30,
+	}
+	if _, err := manager.SubmitJob(manifest); err != nil {
+		t.Fatalf("SubmitJob failed: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		status, err := manager.GetJobStatus("straggler-job")
+		if err != nil {
+			t.Fatalf("GetJobStatus failed: %v", err)
+		}
+		if status.Status == TaskCompleted || status.Status == TaskFailed {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	manager.mu.RLock()
+	state := manager.jobs["straggler-job"]
+	if state.status != TaskCompleted {
+		manager.mu.RUnlock()
+		t.Fatalf("Expected job to complete via work-stealing, got %s", state.status)
+	}
+	stolen := 0
+	for _, result := range state.results {
+		if result.WorkerID != "fast" {
+			t.Errorf("Chunk result accepted from %s, expected fast", result.WorkerID)
+		}
+		if result.ReplacedWorker == "slow" {
+			stolen++
+		}
+	}
+	manager.mu.RUnlock()
+
+	if stolen == 0 {
+		t.Error("Expected at least one chunk to be stolen from the slow worker")
+	}
+	if delegator.cancelled.Load() == 0 {
+		t.Error("Expected the straggling attempt to be cancelled")
+	}
+	if slow := manager.GetLedger().Usage("straggler-job", "slow"); len(slow) != 1 || slow[0].TasksFailed == 0 {
+		t.Errorf("Expected the cancelled attempt to be billed to slow, got %+v", slow)
+	}
+}
+
+// encodeMatrices serializes matrices in the matrix block multiply input format
+func encodeMatrices(ms ...[][]float64) []byte {
+	var buf bytes.Buffer
+	for _, m := range ms {
+		binary.Write(&buf, binary.BigEndian, uint32(len(m)))
+		binary.Write(&buf, binary.BigEndian, uint32(len(m[0])))
+		for _, row := range m {
+			for _, v := range row {
+				binary.Write(&buf, binary.BigEndian, math.Float64bits(v))
+			}
+		}
+	}
+	return buf.Bytes()
+}
+
+// peerDelegator executes delegated tasks on in-process peer managers
+type peerDelegator struct {
+	peers map[string]*Manager
+	calls atomic.Int32
+}
+
+func (d *peerDelegator) DelegateTask(ctx context.Context, workerID string, task *ComputeTask) (*TaskResult, error) {
+	d.calls.Add(1)
+	return d.peers[workerID].ExecuteTask(ctx, task, workerID, "origin"), nil
+}
+
+func (d *peerDelegator) GetAvailableWorkers() []string {
+	workers := make([]string, 0, len(d.peers))
+	for id := range d.peers {
+		workers = append(workers, id)
+	}
+	sort.Strings(workers)
+	return append(workers, "origin")
+}
+
+func (d *peerDelegator) HasWorkers() bool { return len(d.peers) > 0 }
+
+func TestHierarchicalDelegationMergesWithProvenance(t *testing.T) {
+	a := [][]float64{{1, 2}, {3, 4}, {5, 6}, {7, 8}, {9, 10}}
+	b := [][]float64{{1, 0, 2}, {0, 1, 3}}
+	input := encodeMatrices(a, b)
+
+	expected, err := ExecuteMatrixBlockMultiply(input)
+	if err != nil {
+		t.Fatalf("direct multiply failed: %v", err)
+	}
+
+	config := DefaultConfig()
+	worker := NewManager(config)
+	defer worker.Close()
+	worker.capacity.CurrentLoad = 1.0 // Overloaded, so received tasks are split
+
+	delegator := &peerDelegator{peers: map[string]*Manager{}}
+	for _, id := range []string{"peer-a", "peer-b"} {
+		peer := NewManager(config)
+		defer peer.Close()
+		delegator.peers[id] = peer
+	}
+	worker.SetDelegator(delegator)
+
+	task := &ComputeTask{TaskID: "job:0", ParentJobID: "job", InputData: input}
+	result := worker.ExecuteTask(context.Background(), task, "worker", "origin")
+	if result.Status != TaskCompleted {
+		t.Fatalf("Expected completed result, got %s: %s", result.Status, result.Error)
+	}
+	if !bytes.Equal(result.ResultData, expected) {
+		t.Fatal("Merged result differs from direct multiplication")
+	}
+	if delegator.calls.Load() != 2 {
+		t.Errorf("Expected 2 sub-delegations, got %d", delegator.calls.Load())
+	}
+
+	prov := result.Provenance
+	if prov == nil || len(prov.Children) != 2 {
+		t.Fatalf("Expected provenance with 2 children, got %+v", prov)
+	}
+	for _, child := range prov.Children {
+		if child.WorkerID == "origin" {
+			t.Error("Task was delegated back to the upstream sender")
+		}
+		if child.Depth != 1 {
+			t.Errorf("Expected child depth 1, got %d", child.Depth)
+		}
+	}
+	if err := VerifyProvenance(result.ResultData, prov); err != nil {
+		t.Errorf("Provenance verification failed: %v", err)
+	}
+
+	// Both levels bill the sub-delegated parts to the peers that computed them
+	for id, peer := range delegator.peers {
+		if usage := worker.GetLedger().Usage("job", id); len(usage) != 1 || usage[0].TasksCompleted != 1 {
+			t.Errorf("Expected worker ledger to bill %s for one part, got %+v", id, usage)
+		}
+		if usage := peer.GetLedger().Usage("job", id); len(usage) != 1 || usage[0].TasksCompleted != 1 {
+			t.Errorf("Expected %s to record the work it did, got %+v", id, usage)
+		}
+	}
+
+	// Tampering with a sub-result is detected
+	tampered := append([]byte(nil), result.ResultData...)
+	tampered[len(tampered)-1] ^= 0xff
+	prov.ResultHash = hashData(tampered)
+	if err := VerifyProvenance(tampered, prov); err == nil {
+		t.Error("Expected provenance verification to fail for tampered rows")
+	}
+}
+
+func TestDelegationDepthLimit(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxDelegationDepth = 1
+	worker := NewManager(config)
+	defer worker.Close()
+	worker.capacity.CurrentLoad = 1.0
+
+	peer := NewManager(config)
+	defer peer.Close()
+	delegator := &peerDelegator{peers: map[string]*Manager{"peer-a": peer}}
+	worker.SetDelegator(delegator)
+
+	input := encodeMatrices([][]float64{{1}, {2}}, [][]float64{{3}})
+	task := &ComputeTask{TaskID: "job:0", InputData: input, DelegationDepth: 1}
+	result := worker.ExecuteTask(context.Background(), task, "worker", "origin")
+	if result.Status != TaskCompleted {
+		t.Fatalf("Expected completed result, got %s: %s", result.Status, result.Error)
+	}
+	if delegator.calls.Load() != 0 {
+		t.Errorf("Expected no re-delegation at max depth, got %d calls", delegator.calls.Load())
+	}
+	if result.Provenance == nil || result.Provenance.WorkerID != "worker" {
+		t.Errorf("Expected local provenance, got %+v", result.Provenance)
+	}
+}
+
+func TestSplitMatrixTaskRejectsOversizedDimensions(t *testing.T) {
+	data := make([]byte, 24)
+	binary.BigEndian.PutUint32(data[0:4], 1<<29)
+	binary.BigEndian.PutUint32(data[4:8], 1<<31)
+	if _, _, err := SplitMatrixTask(data, 2); err == nil {
+		t.Error("Expected error for overflowing matrix dimensions")
+	}
+}
+
+// shortResultDelegator reports success with an empty result
+type shortResultDelegator struct{ peerDelegator }
+
+func (d *shortResultDelegator) DelegateTask(ctx context.Context, workerID string, task *ComputeTask) (*TaskResult, error) {
+	d.calls.Add(1)
+	return &TaskResult{TaskID: task.TaskID, Status: TaskCompleted, ResultHash: hashData(nil), WorkerID: workerID}, nil
+}
+
+func TestMalformedSubResultFallsBackToLocal(t *testing.T) {
+	config := DefaultConfig()
+	worker := NewManager(config)
+	defer worker.Close()
+	worker.capacity.CurrentLoad = 1.0
+
+	delegator := &shortResultDelegator{peerDelegator{peers: map[string]*Manager{"peer-a": nil, "peer-b": nil}}}
+	worker.SetDelegator(delegator)
+
+	input := encodeMatrices([][]float64{{1}, {2}}, [][]float64{{3}})
+	expected, _ := ExecuteMatrixBlockMultiply(input)
+	result := worker.ExecuteTask(context.Background(), &ComputeTask{TaskID: "job:0", InputData: input}, "worker", "origin")
+	if result.Status != TaskCompleted {
+		t.Fatalf("Expected completed result, got %s: %s", result.Status, result.Error)
+	}
+	if !bytes.Equal(result.ResultData, expected) {
+		t.Error("Expected malformed sub-results to be recomputed locally")
+	}
+}
+
+func TestLedgerRecordsAndPersistsUsage(t *testing.T) {
+	config := DefaultConfig()
+	config.LedgerPath = t.TempDir() + "/ledger.json"
+	manager := NewManager(config)
+
+	input := encodeMatrices([][]float64{{1, 2}, {3, 4}}, [][]float64{{5, 6}, {7, 8}})
+	jobID, err := manager.SubmitJob(&JobManifest{
+		JobID:        "ledger-job",
+		InputData:    input,
+		MinChunkSize: 1,
+		MaxChunkSize: 1024,
+		TimeoutSecs:  10,
+	})
+	if err != nil {
+		t.Fatalf("SubmitJob failed: %v", err)
+	}
+	if _, err := manager.GetJobResult(jobID, 5*time.Second); err != nil {
+		t.Fatalf("GetJobResult failed: %v", err)
+	}
+
+	usage := manager.GetLedger().Usage(jobID, "")
+	if len(usage) != 1 || usage[0].WorkerID != "local" || usage[0].TasksCompleted == 0 {
+		t.Fatalf("expected completed local usage, got %+v", usage)
+	}
+	if usage[0].InputBytes != uint64(len(input)) || usage[0].OutputBytes == 0 {
+		t.Errorf("unexpected byte accounting: %+v", usage[0])
+	}
+	manager.Close()
+
+	reloaded := NewLedger(config.LedgerPath)
+	if got := reloaded.Usage(jobID, "local"); len(got) != 1 || got[0].TasksCompleted != usage[0].TasksCompleted {
+		t.Errorf("expected persisted usage %+v, got %+v", usage[0], got)
+	}
+}
+
+func TestLedgerWorkerTotals(t *testing.T) {
+	l := NewLedger("")
+	l.Record("job-a", "w1", 2*time.Second, 100, 10, true)
+	l.Record("job-b", "w1", time.Second, 50, 0, false)
+	l.Record("job-a", "w2", time.Second, 100, 10, true)
+
+	if got := l.Usage("", "w1"); len(got) != 2 {
+		t.Fatalf("expected 2 records for w1, got %d", len(got))
+	}
+	w1 := l.WorkerTotals()["w1"]
+	if w1.ExecSeconds != 3 || w1.InputBytes != 150 || w1.TasksCompleted != 1 || w1.TasksFailed != 1 {
+		t.Errorf("unexpected totals for w1: %+v", w1)
+	}
+}
+
+func TestTemplateJobSubmission(t *testing.T) {
+	config := DefaultConfig()
+	config.TemplatePath = t.TempDir() + "/templates.json"
+	manager := NewManager(config)
+	defer manager.Close()
+	templates := manager.GetTemplates()
+
+	if _, err := templates.Put(JobTemplate{Name: "bad", SplitStrategy: "no_such_strategy"}); err == nil {
+		t.Error("expected a template with an unknown strategy to be rejected")
+	}
+	v1, err := templates.Put(JobTemplate{Name: "matmul", SplitStrategy: SplitMatrixRows, MaxChunkSize: 1024, TimeoutSecs: 10})
+	if err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	v2, err := templates.Put(JobTemplate{Name: "matmul", SplitStrategy: SplitMatrixRows, MaxChunkSize: 2048, TimeoutSecs: 10})
+	if err != nil || v1 != 1 || v2 != 2 {
+		t.Fatalf("expected versions 1 and 2, got %d, %d (%v)", v1, v2, err)
+	}
+
+	input := encodeMatrices([][]float64{{1, 2}, {3, 4}}, [][]float64{{5, 6}, {7, 8}})
+	jobID, err := manager.SubmitTemplateJob("matmul", 1, TemplateParams{
+		JobID:     "template-job",
+		InputData: input,
+		Overrides: map[string]string{"priority": "7"},
+	})
+	if err != nil {
+		t.Fatalf("SubmitTemplateJob failed: %v", err)
+	}
+	if _, err := manager.GetJobResult(jobID, 5*time.Second); err != nil {
+		t.Fatalf("GetJobResult failed: %v", err)
+	}
+
+	manifest, err := templates.Instantiate("matmul", 0, TemplateParams{Overrides: map[string]string{"redundancy": "2"}})
+	if err != nil {
+		t.Fatalf("Instantiate failed: %v", err)
+	}
+	if manifest.MaxChunkSize != 2048 || manifest.Redundancy != 2 || manifest.VerificationMode != VerificationNone {
+		t.Errorf("expected latest version with override applied, got %+v", manifest)
+	}
+	if _, err := templates.Instantiate("matmul", 0, TemplateParams{Overrides: map[string]string{"colour": "blue"}}); err == nil {
+		t.Error("expected an unknown parameter to be rejected")
+	}
+
+	reloaded := NewTemplateLibrary(config.TemplatePath)
+	if got, err := reloaded.Get("matmul", 1); err != nil || got.MaxChunkSize != 1024 {
+		t.Errorf("expected version 1 to persist, got %+v (%v)", got, err)
+	}
+	if err := reloaded.Delete("matmul"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if len(reloaded.List()) != 0 {
+		t.Error("expected no templates after delete")
+	}
+}
+
+func TestPostProcessingStepsRunOnMergedResult(t *testing.T) {
+	manager := NewManager(DefaultConfig())
+	defer manager.Close()
+
+	input := encodeMatrices([][]float64{{1, 2}, {3, 4}}, [][]float64{{1, 0}, {0, 1}})
+	if _, err := manager.SubmitJob(&JobManifest{JobID: "bad-step", InputData: input, PostProcess: []string{"no_such_step"}}); err == nil {
+		t.Fatal("expected an unknown post-processing step to be rejected")
+	}
+
+	jobID, err := manager.SubmitJob(&JobManifest{
+		JobID:        "csv-job",
+		InputData:    input,
+		MaxChunkSize: 1 << 20,
+		PostProcess:  []string{PostProcessVerifyMatrix, PostProcessMatrixCSV},
+	})
+	if err != nil {
+		t.Fatalf("SubmitJob failed: %v", err)
+	}
+	result, err := manager.GetJobResult(jobID, 5*time.Second)
+	if err != nil {
+		t.Fatalf("GetJobResult failed: %v", err)
+	}
+	if string(result) != "1,2\n3,4\n" {
+		t.Errorf("expected CSV of the product, got %q", result)
+	}
+
+	nan := encodeMatrices([][]float64{{math.NaN()}})
+	if _, err := verifyMatrixResult(nan); err == nil {
+		t.Error("expected a NaN to fail matrix verification")
+	}
+
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	zw.Write([]byte("payload"))
+	zw.Close()
+	if out, err := gunzipResult(compressed.Bytes()); err != nil || string(out) != "payload" {
+		t.Errorf("gunzip returned %q, %v", out, err)
+	}
+
+	manager.SetWASMRuntime(fakeWASMRuntime{})
+	manager.RegisterWASMPostProcessor("tag", []byte("tag:"))
+	manager.mu.RLock()
+	steps, err := manager.jobPostProcessors(&JobManifest{PostProcess: []string{"tag"}})
+	manager.mu.RUnlock()
+	if err != nil || len(steps) != 1 {
+		t.Fatalf("jobPostProcessors failed: %v", err)
+	}
+	if out, _ := steps[0].Process([]byte("x")); string(out) != "tag:x" {
+		t.Errorf("expected the registered module to run, got %q", out)
+	}
+}
+
+func TestCapacityReservations(t *testing.T) {
+	worker := NewManager(DefaultConfig())
+	defer worker.Close()
+	worker.mu.Lock()
+	worker.capacity = ComputeCapacity{CPUCores: 4, RAMMB: 8192}
+	worker.mu.Unlock()
+
+	now := time.Now()
+	first, err := worker.GrantReservation(Reservation{Holder: "alice", CPUCores: 3, RAMMB: 1024, Start: now.Add(-time.Minute), End: now.Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("GrantReservation failed: %v", err)
+	}
+	// Overlapping windows share the same four cores
+	if _, err := worker.GrantReservation(Reservation{Holder: "bob", CPUCores: 2, Start: now.Add(30 * time.Minute), End: now.Add(2 * time.Hour)}); err == nil {
+		t.Fatal("expected an overlapping reservation beyond capacity to be refused")
+	}
+	if _, err := worker.GrantReservation(Reservation{Holder: "bob", CPUCores: 2, Start: now.Add(time.Hour), End: now.Add(2 * time.Hour)}); err != nil {
+		t.Fatalf("expected a reservation after the first to fit: %v", err)
+	}
+	if _, err := worker.GrantReservation(Reservation{Holder: "bob", CPUCores: 1, Start: now.Add(time.Hour), End: now}); err == nil {
+		t.Error("expected an inverted window to be refused")
+	}
+
+	// With three cores held for alice, other submitters get one
+	input := encodeMatrices([][]float64{{1}}, [][]float64{{2}})
+	worker.mu.Lock()
+	worker.activeTasks = 1
+	worker.mu.Unlock()
+	if r := worker.ExecuteTask(context.Background(), &ComputeTask{TaskID: "j:0", InputData: input}, "worker", "bob"); r.Status != TaskFailed {
+		t.Errorf("expected bob's task to be turned away, got %s", r.Status)
+	}
+	if r := worker.ExecuteTask(context.Background(), &ComputeTask{TaskID: "j:1", InputData: input}, "worker", "alice"); r.Status != TaskCompleted {
+		t.Errorf("expected alice's task to run, got %s: %s", r.Status, r.Error)
+	}
+
+	if err := worker.ReleaseReservation(first.ID, "bob"); err == nil {
+		t.Error("expected only the holder to release a reservation")
+	}
+	if err := worker.ReleaseReservation(first.ID, "alice"); err != nil {
+		t.Fatalf("ReleaseReservation failed: %v", err)
+	}
+	if r := worker.ExecuteTask(context.Background(), &ComputeTask{TaskID: "j:2", InputData: input}, "worker", "bob"); r.Status != TaskCompleted {
+		t.Errorf("expected bob's task to run after release, got %s: %s", r.Status, r.Error)
+	}
+	if got := worker.GrantedReservations(); len(got) != 1 || got[0].Holder != "bob" {
+		t.Errorf("expected only bob's reservation to remain, got %+v", got)
+	}
+}
+
+func TestScheduledJobUsesReservedWorkers(t *testing.T) {
+	m := NewManager(DefaultConfig())
+	defer m.Close()
+	delegator := &MockDelegator{workers: []string{"w1", "w2", "w3"}}
+	m.SetDelegator(delegator)
+
+	now := time.Now()
+	m.AddHeldReservation(Reservation{ID: "r1", WorkerID: "w2", CPUCores: 2, Start: now.Add(-time.Minute), End: now.Add(time.Hour)})
+	m.AddHeldReservation(Reservation{ID: "r2", WorkerID: "w3", CPUCores: 1, JobID: "other", Start: now.Add(-time.Minute), End: now.Add(time.Hour)})
+
+	scheduled := &JobManifest{JobID: "scheduled", StartAt: now.Add(200 * time.Millisecond)}
+	m.mu.RLock()
+	planned := m.reservedWorkersLocked(scheduled, now, delegator.workers)
+	unscheduled := m.reservedWorkersLocked(&JobManifest{JobID: "adhoc"}, now, delegator.workers)
+	m.mu.RUnlock()
+	if len(planned) != 2 || planned[0] != "w2" || planned[1] != "w2" {
+		t.Errorf("expected the scheduled job on w2's two reserved cores, got %v", planned)
+	}
+	if len(unscheduled) != 0 {
+		t.Errorf("expected unbound reservations to skip unscheduled jobs, got %v", unscheduled)
+	}
+
+	scheduled.InputData = make([]byte, 4096)
+	scheduled.MaxChunkSize = 1024
+	if _, err := m.SubmitJob(scheduled); err != nil {
+		t.Fatalf("SubmitJob failed: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if status, _ := m.GetJobStatus("scheduled"); status.Status != TaskPending {
+		t.Fatalf("expected the job to wait for its start time, got %s", status.Status)
+	}
+	if _, worker, err := m.GetJobResultWithWorker("scheduled", 5*time.Second); err != nil || worker != "w2" {
+		t.Errorf("expected the job to run on w2, got %q, %v", worker, err)
+	}
+}
+
+func TestWorkWindowParsing(t *testing.T) {
+	w, err := ParseWorkWindow("Mon-Wed,Fri 22:00-06:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.String() != "Mon-Wed,Fri 22:00-06:00" {
+		t.Errorf("round trip gave %q", w.String())
+	}
+
+	// 2026-01-05 is a Monday
+	at := func(day, hour int) time.Time { return time.Date(2026, 1, day, hour, 0, 0, 0, time.Local) }
+	cases := []struct {
+		t    time.Time
+		want bool
+	}{
+		{at(5, 23), true},  // Monday night
+		{at(6, 3), true},   // Monday's window running into Tuesday
+		{at(6, 12), false}, // Tuesday midday
+		{at(9, 3), false},  // Thursday morning, after Wednesday's window ended
+		{at(10, 3), true},  // Saturday morning, Friday's window
+		{at(10, 23), false},
+	}
+	for _, c := range cases {
+		if got := w.Contains(c.t); got != c.want {
+			t.Errorf("Contains(%v) = %v, want %v", c.t, got, c.want)
+		}
+	}
+
+	for _, bad := range []string{"09:00-09:00", "Mon 9-17", "Funday 09:00-17:00", "24:00-01:00", "09:00-25:00"} {
+		if _, err := ParseWorkWindow(bad); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestWorkerPolicyTurnsAwayPeerTasks(t *testing.T) {
+	config := DefaultConfig()
+	config.WorkerPolicyPath = t.TempDir() + "/worker_policy.json"
+	manager := NewManager(config)
+	defer manager.Close()
+
+	input := encodeMatrices([][]float64{{1, 2}, {3, 4}}, [][]float64{{5, 6}, {7, 8}})
+	task := &ComputeTask{TaskID: "t1", InputData: input}
+	if res := manager.ExecuteTask(context.Background(), task, "local", "peer"); res.Status != TaskCompleted {
+		t.Fatalf("default policy refused the task: %s", res.Error)
+	}
+
+	if err := manager.SetWorkerPolicy(WorkerPolicy{Enabled: false}); err != nil {
+		t.Fatal(err)
+	}
+	res := manager.ExecuteTask(context.Background(), task, "local", "peer")
+	if res.Status != TaskFailed || !strings.HasPrefix(res.Error, ErrWorkerUnavailable.Error()) {
+		t.Fatalf("expected an opted-out worker to refuse, got %v %q", res.Status, res.Error)
+	}
+	if _, accepting := manager.WorkerStatus(); accepting {
+		t.Error("opted-out worker reports accepting")
+	}
+
+	// Outside work hours (an hour-long window that ended an hour ago)
+	now := time.Now()
+	window := WorkWindow{Start: (now.Hour()*60 + 22*60) % (24 * 60), End: (now.Hour()*60 + 23*60) % (24 * 60)}
+	if err := manager.SetWorkerPolicy(WorkerPolicy{Enabled: true, WorkHours: []WorkWindow{window}}); err != nil {
+		t.Fatal(err)
+	}
+	if res := manager.ExecuteTask(context.Background(), task, "local", "peer"); !strings.Contains(res.Error, "outside work hours") {
+		t.Fatalf("expected an outside work hours refusal, got %q", res.Error)
+	}
+
+	// Over the memory budget: a 1000x1000 by 1000x1000 product needs ~30MB
+	big := make([]byte, 16+1000*1000*8)
+	binary.BigEndian.PutUint32(big[0:], 1000)
+	binary.BigEndian.PutUint32(big[4:], 1000)
+	binary.BigEndian.PutUint32(big[8+1000*1000*8:], 1000)
+	binary.BigEndian.PutUint32(big[12+1000*1000*8:], 1000)
+	if err := manager.SetWorkerPolicy(WorkerPolicy{Enabled: true, MaxTaskMemoryMB: 16}); err != nil {
+		t.Fatal(err)
+	}
+	if res := manager.ExecuteTask(context.Background(), &ComputeTask{TaskID: "big", InputData: big}, "local", "peer"); !strings.HasPrefix(res.Error, ErrTaskOverBudget.Error()) {
+		t.Fatalf("expected an over budget refusal, got %q", res.Error)
+	}
+
+	// Tasks running past the time limit are stopped
+	if err := manager.SetWorkerPolicy(WorkerPolicy{Enabled: true, MaxTaskTime: time.Nanosecond}); err != nil {
+		t.Fatal(err)
+	}
+	if res := manager.ExecuteTask(context.Background(), task, "local", "peer"); res.Status != TaskFailed {
+		t.Fatal("expected a task over its time limit to fail")
+	}
+
+	if err := manager.SetWorkerPolicy(WorkerPolicy{Enabled: true, MaxTaskCPUPercent: 150}); err == nil {
+		t.Fatal("accepted a CPU share over 100%")
+	}
+
+	// The last valid policy survives a restart
+	reloaded := NewManager(config)
+	defer reloaded.Close()
+	if p := reloaded.WorkerPolicy(); p.MaxTaskTime != time.Nanosecond || !p.Enabled {
+		t.Errorf("expected the saved policy, got %+v", p)
+	}
+}
+
+func TestCPUPacerIdlesForShare(t *testing.T) {
+	pacer := newCPUPacer(50)
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		busy := time.Now()
+		for time.Since(busy) < 2*time.Millisecond {
+		}
+		pacer.pace(context.Background())
+	}
+	// 10ms of work at half a core takes at least 20ms
+	if elapsed := time.Since(start); elapsed < 18*time.Millisecond {
+		t.Errorf("paced work finished in %v", elapsed)
+	}
+}
+
+func TestProcessIsolationRunsTasksInChild(t *testing.T) {
+	config := DefaultConfig()
+	config.Sandbox.Isolation = IsolationProcess
+	manager := NewManager(config)
+	defer manager.Close()
+
+	input := encodeMatrices([][]float64{{1, 2}, {3, 4}}, [][]float64{{5, 6}, {7, 8}})
+	expected, err := ExecuteMatrixBlockMultiply(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := manager.ExecuteTask(context.Background(), &ComputeTask{TaskID: "t1", InputData: input}, "local", "peer")
+	if res.Status != TaskCompleted || !bytes.Equal(res.ResultData, expected) {
+		t.Fatalf("sandboxed task gave %v %q", res.Status, res.Error)
+	}
+
+	// The child's own errors come back as they are
+	res = manager.ExecuteTask(context.Background(), &ComputeTask{TaskID: "t2", InputData: []byte("short")}, "local", "peer")
+	if res.Status != TaskFailed || !strings.Contains(res.Error, "input data too short") {
+		t.Fatalf("expected the kernel's error, got %q", res.Error)
+	}
+
+	// A child that dies is reported, and the node carries on
+	t.Setenv(sandboxCrashEnv, "1")
+	res = manager.ExecuteTask(context.Background(), &ComputeTask{TaskID: "t3", InputData: input}, "local", "peer")
+	if res.Status != TaskFailed || !strings.HasPrefix(res.Error, ErrSandboxKilled.Error()) {
+		t.Fatalf("expected a killed sandbox, got %q", res.Error)
+	}
+}
+
+func TestSandboxLimitsBlockEscapes(t *testing.T) {
+	if seccompArch == 0 {
+		t.Skip("seccomp sandbox is only installed on Linux amd64 and arm64")
+	}
+	if raceEnabled {
+		t.Skip("the race detector cannot map its shadow memory under the sandbox's address space limit")
+	}
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	cmd.Env = append(os.Environ(), sandboxProbeEnv+"=1", "GOTRACEBACK=none")
+	out, _ := cmd.CombinedOutput()
+	if !bytes.HasPrefix(out, []byte("runtime: out of memory")) {
+		t.Fatalf("sandbox probe got out:\n%s", out)
+	}
+}
+
+// fakeWASMTaskRuntime runs task modules by echoing the input
+type fakeWASMTaskRuntime struct {
+	fakeWASMRuntime
+	maxMemory uint64
+}
+
+func (r *fakeWASMTaskRuntime) Execute(ctx context.Context, module []byte, input []byte, maxMemoryBytes uint64) ([]byte, error) {
+	r.maxMemory = maxMemoryBytes
+	return input, nil
+}
+
+func TestWASMTasksRunInHostRuntime(t *testing.T) {
+	manager := NewManager(DefaultConfig())
+	defer manager.Close()
+	runtime := &fakeWASMTaskRuntime{}
+	manager.SetWASMRuntime(runtime)
+	if err := manager.SetWorkerPolicy(WorkerPolicy{Enabled: true, MaxTaskMemoryMB: 64}); err != nil {
+		t.Fatal(err)
+	}
+
+	res := manager.ExecuteTask(context.Background(), &ComputeTask{TaskID: "w", WASMModule: wasmModuleWithMemory(0x01, 1, 1024), InputData: []byte("in")}, "local", "peer")
+	if res.Status != TaskCompleted || string(res.ResultData) != "in" {
+		t.Fatalf("WASM task gave %v %q", res.Status, res.Error)
+	}
+	if runtime.maxMemory != 64<<20 {
+		t.Errorf("expected a 64MB memory cap, got %d", runtime.maxMemory)
+	}
+
+	// Modules that could grow past the cap never reach the runtime
+	for name, module := range map[string][]byte{
+		"over the cap":      wasmModuleWithMemory(0x01, 1, 1025),
+		"no maximum":        wasmModuleWithMemory(0x00, 1, 0),
+		"imported memory":   []byte("\x00asm\x01\x00\x00\x00\x02\x0c\x01\x03env\x03mem\x02\x00\x01"),
+		"not a WASM module": {0},
+	} {
+		runtime.maxMemory = 0
+		res := manager.ExecuteTask(context.Background(), &ComputeTask{TaskID: "w-" + name, WASMModule: module, InputData: []byte("in")}, "local", "peer")
+		if res.Status != TaskFailed || runtime.maxMemory != 0 {
+			t.Errorf("%s: module was run (%v %q)", name, res.Status, res.Error)
+		}
+	}
+}
+
+// wasmModuleWithMemory is a WASM module defining one memory with the given
+// limits flags and page counts, each under 128
+func wasmModuleWithMemory(flags byte, minPages, maxPages uint16) []byte {
+	leb := func(v uint16) []byte {
+		var out []byte
+		for {
+			b := byte(v & 0x7f)
+			if v >>= 7; v == 0 {
+				return append(out, b)
+			}
+			out = append(out, b|0x80)
+		}
+	}
+	limits := append([]byte{flags}, leb(minPages)...)
+	if flags&0x01 != 0 {
+		limits = append(limits, leb(maxPages)...)
+	}
+	section := append([]byte{1}, limits...)
+	module := []byte("\x00asm\x01\x00\x00\x00")
+	module = append(module, 5, byte(len(section)))
+	return append(module, section...)
+}
+
+func TestJobQueueAdmitsByPriority(t *testing.T) {
+	q := newJobQueue(1, 3)
+	if err := q.reserve(3); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.reserve(1); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("expected ErrQueueFull, got %v", err)
+	}
+	if !q.acquire(context.Background(), "first", 0) {
+		t.Fatal("free slot not granted")
+	}
+
+	// Two jobs wait; the higher priority one gets the slot first
+	order := make(chan string, 2)
+	var wg sync.WaitGroup
+	for _, job := range []struct {
+		id       string
+		priority uint32
+	}{{"low", 1}, {"high", 5}} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if q.acquire(context.Background(), job.id, job.priority) {
+				order <- job.id
+				q.release()
+			}
+		}()
+		for q.stats().Queued == 0 || (job.id == "high" && q.stats().Queued < 2) {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	if s := q.stats(); s.Running != 1 || s.Queued != 2 || s.Pending != 2 || s.Rejected != 1 {
+		t.Fatalf("unexpected stats %+v", s)
+	}
+	q.release()
+	wg.Wait()
+	if first, second := <-order, <-order; first != "high" || second != "low" {
+		t.Errorf("admitted %s before %s", first, second)
+	}
+	if s := q.stats(); s.Running != 0 || s.Pending != 0 || s.Admitted != 3 {
+		t.Errorf("unexpected stats after draining %+v", s)
+	}
+}
+
+func TestCancelledJobLeavesQueue(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxConcurrentJobs = 1
+	config.MaxQueuedJobs = 1
+	manager := NewManager(config)
+	defer manager.Close()
+
+	// Hold the only slot so that submitted jobs queue
+	if err := manager.queue.reserve(1); err != nil {
+		t.Fatal(err)
+	}
+	if !manager.queue.acquire(context.Background(), "holder", 0) {
+		t.Fatal("slot not granted")
+	}
+	manifest := &JobManifest{JobID: "queued", InputData: []byte("data"), MinChunkSize: 1, MaxChunkSize: 100}
+	if _, err := manager.SubmitJob(manifest); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := manager.SubmitJob(&JobManifest{JobID: "rejected", InputData: []byte("data")}); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("expected ErrQueueFull, got %v", err)
+	}
+	for manager.QueueStats().Queued == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := manager.CancelJob("queued"); err != nil {
+		t.Fatal(err)
+	}
+	if s := manager.QueueStats(); s.Queued != 0 || s.Pending != 0 || s.Rejected != 1 {
+		t.Fatalf("cancelled job still queued: %+v", s)
+	}
+	if _, err := manager.SubmitJob(&JobManifest{JobID: "next", InputData: []byte("data")}); err != nil {
+		t.Fatalf("queue did not free up: %v", err)
+	}
+}
+
+// gatedDelegator holds the first task until release is closed and
+// finishes the rest at once
+type gatedDelegator struct {
+	started chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func (d *gatedDelegator) DelegateTask(ctx context.Context, workerID string, task *ComputeTask) (*TaskResult, error) {
+	first := false
+	d.once.Do(func() { first = true })
+	if first {
+		close(d.started)
+		<-d.release
+	}
+	return &TaskResult{TaskID: task.TaskID, Status: TaskCompleted, ResultData: []byte("ok"), WorkerID: workerID}, nil
+}
+
+func (d *gatedDelegator) GetAvailableWorkers() []string { return []string{"a"} }
+
+func (d *gatedDelegator) HasWorkers() bool { return true }
+
+func TestCancelledJobIsNotCompletedFromPartialResults(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxChunksPerJob = 1
+	manager := NewManager(config)
+	defer manager.Close()
+	delegator := &gatedDelegator{started: make(chan struct{}), release: make(chan struct{})}
+	manager.SetDelegator(delegator)
+
+	manifest := &JobManifest{JobID: "partial", InputData: make([]byte, 40), MinChunkSize: 10, MaxChunkSize: 10, TimeoutSecs: 10}
+	if _, err := manager.SubmitJob(manifest); err != nil {
+		t.Fatal(err)
+	}
+	<-delegator.started
+	if err := manager.CancelJob("partial"); err != nil {
+		t.Fatal(err)
+	}
+	close(delegator.release)
+
+	// The in-flight chunk finishes; the other three are never dispatched
+	deadline := time.Now().Add(5 * time.Second)
+	for manager.QueueStats().Running != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("job did not finish")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	status, err := manager.GetJobStatus("partial")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Status != TaskCancelled {
+		t.Fatalf("expected the job to stay cancelled, got %s", status.Status)
+	}
+	if _, err := manager.GetJobResult("partial", time.Second); err == nil {
+		t.Fatal("expected no result for a cancelled job")
+	}
+
+	// A partial result never merges, and a finished job cannot be cancelled
+	manager.mu.Lock()
+	_, mergeErr := manager.mergeResults(manager.jobs["partial"])
+	manager.jobs["partial"].status = TaskCompleted
+	manager.mu.Unlock()
+	if mergeErr == nil {
+		t.Fatal("expected merging missing chunks to fail")
+	}
+	if err := manager.CancelJob("partial"); !errors.Is(err, ErrJobFinished) {
+		t.Fatalf("expected ErrJobFinished, got %v", err)
+	}
+	if status, _ := manager.GetJobStatus("partial"); status.Status != TaskCompleted {
+		t.Fatalf("expected the finished status kept, got %s", status.Status)
+	}
+}
+
+// flakyDelegator fails every task sent to the workers in failing
+type flakyDelegator struct {
+	workers []string
+	failing map[string]bool
+	mu      sync.Mutex
+	calls   []string
+}
+
+func (d *flakyDelegator) DelegateTask(ctx context.Context, workerID string, task *ComputeTask) (*TaskResult, error) {
+	d.mu.Lock()
+	d.calls = append(d.calls, workerID)
+	d.mu.Unlock()
+	if d.failing[workerID] {
+		return nil, fmt.Errorf("worker %s is down", workerID)
+	}
+	result, err := ExecuteMatrixBlockMultiply(task.InputData)
+	if err != nil {
+		return nil, err
+	}
+	return &TaskResult{TaskID: task.TaskID, Status: TaskCompleted, ResultData: result, WorkerID: workerID}, nil
+}
+
+func (d *flakyDelegator) GetAvailableWorkers() []string { return d.workers }
+
+func (d *flakyDelegator) HasWorkers() bool { return len(d.workers) > 0 }
+
+func TestChunkRetriesOtherWorkersWithBackoff(t *testing.T) {
+	config := DefaultConfig()
+	config.RetryBackoff = 20 * time.Millisecond
+	manager := NewManager(config)
+	defer manager.Close()
+	delegator := &flakyDelegator{workers: []string{"a", "b", "c"}, failing: map[string]bool{"a": true, "b": true}}
+	manager.SetDelegator(delegator)
+
+	input := encodeMatrices([][]float64{{1, 2}, {3, 4}}, [][]float64{{5, 6}, {7, 8}})
+	start := time.Now()
+	jobID, err := manager.SubmitJob(&JobManifest{JobID: "retry-job", InputData: input, MinChunkSize: 1,
+		MaxChunkSize: 1 << 20, TimeoutSecs: 10, RetryCount: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := manager.GetJobResult(jobID, 5*time.Second); err != nil {
+		t.Fatalf("job failed: %v", err)
+	}
+	// Backoffs of 20ms and 40ms before the two retries
+	if elapsed := time.Since(start); elapsed < 60*time.Millisecond {
+		t.Errorf("retries did not back off: finished in %v", elapsed)
+	}
+
+	status, err := manager.GetJobStatus(jobID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	attempts := status.Chunks[0].Attempts
+	if len(attempts) != 3 || attempts[0].WorkerID != "a" || attempts[1].WorkerID != "b" || attempts[2].WorkerID != "c" {
+		t.Fatalf("expected attempts on a, b then c, got %+v", attempts)
+	}
+	if attempts[0].Error == "" || attempts[2].Error != "" || status.Chunks[0].AssignedWorker != "c" {
+		t.Errorf("unexpected attempt outcomes %+v", status.Chunks[0])
+	}
+
+	// Out of retries, the chunk runs locally
+	delegator.failing["c"] = true
+	jobID, err = manager.SubmitJob(&JobManifest{JobID: "local-fallback", InputData: input, MinChunkSize: 1,
+		MaxChunkSize: 1 << 20, TimeoutSecs: 10, RetryCount: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := manager.GetJobResult(jobID, 5*time.Second); err != nil {
+		t.Fatalf("job failed: %v", err)
+	}
+	status, _ = manager.GetJobStatus(jobID)
+	if attempts := status.Chunks[0].Attempts; len(attempts) != 3 || attempts[2].WorkerID != "local" {
+		t.Fatalf("expected two remote attempts then a local one, got %+v", attempts)
+	}
+}
+
+func TestJobEventsReportProgress(t *testing.T) {
+	manager := NewManager(DefaultConfig())
+	defer manager.Close()
+	manager.SetDelegator(&flakyDelegator{workers: []string{"a"}})
+	var observed []JobEvent
+	var mu sync.Mutex
+	manager.OnJobEvent(func(ev JobEvent) {
+		mu.Lock()
+		observed = append(observed, ev)
+		mu.Unlock()
+	})
+	all, err := manager.SubscribeJobEvents("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := encodeMatrices([][]float64{{1, 2}, {3, 4}}, [][]float64{{5, 6}, {7, 8}})
+	jobID, err := manager.SubmitJob(&JobManifest{JobID: "events-job", InputData: input, MinChunkSize: 1,
+		MaxChunkSize: 1 << 20, TimeoutSecs: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []JobEvent
+	for len(events) == 0 || !events[len(events)-1].Terminal() {
+		select {
+		case ev := <-all.C:
+			events = append(events, ev)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("job did not finish, got %+v", events)
+		}
+	}
+	if len(events) != 3 || events[0].Status != TaskComputing ||
+		events[1].Type != JobEventChunkCompleted || events[1].WorkerID != "a" ||
+		events[2].Status != TaskCompleted || events[2].Progress != 1 {
+		t.Fatalf("unexpected events %+v", events)
+	}
+	for i, ev := range events {
+		if ev.JobID != jobID || ev.Seq != uint64(i+1) {
+			t.Fatalf("event %d misnumbered: %+v", i, ev)
+		}
+	}
+	mu.Lock()
+	if len(observed) != 3 {
+		t.Errorf("observer saw %d events, want 3", len(observed))
+	}
+	mu.Unlock()
+
+	// Following a finished job yields its final state and ends
+	sub, err := manager.SubscribeJobEvents(jobID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev := <-sub.C; ev.Status != TaskCompleted || ev.Seq != 3 {
+		t.Fatalf("unexpected opening event %+v", ev)
+	}
+	if _, open := <-sub.C; open {
+		t.Fatal("subscription to a finished job stayed open")
+	}
+	if _, err := manager.SubscribeJobEvents("missing"); err == nil {
+		t.Fatal("subscribed to an unknown job")
+	}
+
+	if !manager.UnsubscribeJobEvents(all.ID) || manager.UnsubscribeJobEvents(all.ID) {
+		t.Fatal("unsubscribe did not end the subscription exactly once")
+	}
+	if _, open := <-all.C; open {
+		t.Fatal("channel open after unsubscribe")
+	}
+}
+
+// tamperingDelegator returns a corrupted result from the workers in liars
+type tamperingDelegator struct {
+	flakyDelegator
+	liars map[string]bool
+}
+
+func (d *tamperingDelegator) DelegateTask(ctx context.Context, workerID string, task *ComputeTask) (*TaskResult, error) {
+	result, err := d.flakyDelegator.DelegateTask(ctx, workerID, task)
+	if err == nil && d.liars[workerID] {
+		result.ResultData = append([]byte(nil), result.ResultData...)
+		result.ResultData[len(result.ResultData)-1] ^= 0xff
+	}
+	return result, err
+}
+
+func TestReplayJobChecksWorkerResults(t *testing.T) {
+	config := DefaultConfig()
+	config.ReplayDir = t.TempDir()
+	manager := NewManager(config)
+	defer manager.Close()
+	manager.SetDelegator(&tamperingDelegator{
+		flakyDelegator: flakyDelegator{workers: []string{"honest", "liar"}},
+		liars:          map[string]bool{"liar": true},
+	})
+
+	// Two row chunks, one per worker
+	a := [][]float64{{1, 2}, {3, 4}}
+	b := [][]float64{{5, 6}, {7, 8}}
+	input := encodeMatrices(a, b)
+	jobID, err := manager.SubmitJob(&JobManifest{JobID: "replayed", InputData: input, SplitStrategy: "matrix_rows",
+		MinChunkSize: 1, MaxChunkSize: int64(len(input)) - 8, TimeoutSecs: 10, RecordReplay: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := manager.GetJobResult(jobID, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := manager.GetReplayLog("unrecorded"); err == nil {
+		t.Fatal("found a replay log for an unknown job")
+	}
+
+	// A fresh manager reads the log and chunk inputs back from disk
+	reloaded := NewManager(config)
+	defer reloaded.Close()
+	rl, err := reloaded.GetReplayLog(jobID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rl.Entries) != 2 || rl.Entries[0].InputHash == "" || !rl.Entries[0].Accepted {
+		t.Fatalf("unexpected replay log %+v", rl)
+	}
+	verdicts, err := reloaded.ReplayJob(jobID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(verdicts) != 2 {
+		t.Fatalf("expected a verdict per chunk, got %+v", verdicts)
+	}
+	for _, v := range verdicts {
+		if v.Error != "" || v.Match != (v.WorkerID == "honest") {
+			t.Errorf("wrong verdict for worker %s: %+v", v.WorkerID, v)
+		}
+	}
+
+	verdicts, err = reloaded.ReplayJob(jobID, []uint32{7})
+	if err != nil || len(verdicts) != 1 || verdicts[0].Error == "" {
+		t.Fatalf("expected an unrecorded chunk verdict, got %+v, %v", verdicts, err)
+	}
+}
+
+// echoDelegator runs delegated tasks in process on one worker
+type echoDelegator struct{}
+
+func (echoDelegator) DelegateTask(ctx context.Context, workerID string, task *ComputeTask) (*TaskResult, error) {
+	data, err := ExecuteMatrixBlockMultiply(task.InputData)
+	if err != nil {
+		return nil, err
+	}
+	return &TaskResult{TaskID: task.TaskID, Status: TaskCompleted, ResultData: data, ResultHash: hashData(data)}, nil
+}
+
+func (echoDelegator) GetAvailableWorkers() []string { return []string{"peer-a"} }
+
+func (echoDelegator) HasWorkers() bool { return true }
+
+func TestManagerMetrics(t *testing.T) {
+	manager := NewManager(DefaultConfig())
+	defer manager.Close()
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(manager.Metrics())
+
+	input := encodeMatrices([][]float64{{1, 2}, {3, 4}}, [][]float64{{5, 6}, {7, 8}})
+	run := func(id string, priority uint32) {
+		t.Helper()
+		if _, err := manager.SubmitJob(&JobManifest{JobID: id, InputData: input, MinChunkSize: 1, MaxChunkSize: 1024, TimeoutSecs: 10, Priority: priority}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := manager.GetJobResult(id, 5*time.Second); err != nil {
+			t.Fatalf("GetJobResult failed: %v", err)
+		}
+	}
+	run("local-job", 1)
+	manager.SetDelegator(echoDelegator{})
+	manager.RegisterWorker("peer-a", ComputeCapacity{CPUCores: 2})
+	run("delegated-job", 7)
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// name{label=value,...} -> counter, gauge or histogram sample count
+	values := make(map[string]float64)
+	for _, f := range families {
+		for _, m := range f.GetMetric() {
+			var labels []string
+			for _, l := range m.GetLabel() {
+				labels = append(labels, l.GetName()+"="+l.GetValue())
+			}
+			key := f.GetName() + "{" + strings.Join(labels, ",") + "}"
+			switch {
+			case m.Counter != nil:
+				values[key] = m.Counter.GetValue()
+			case m.Gauge != nil:
+				values[key] = m.Gauge.GetValue()
+			case m.Histogram != nil:
+				values[key] = float64(m.Histogram.GetSampleCount())
+			}
+		}
+	}
+
+	for key, want := range map[string]float64{
+		"pangea_compute_jobs_submitted_total{priority=1}":        1,
+		"pangea_compute_jobs_total{priority=7,status=completed}": 1,
+		"pangea_compute_job_duration_seconds{priority=1}":        1,
+		"pangea_compute_worker_trust{worker=peer-a}":             0.5,
+		"pangea_compute_jobs_running{}":                          0,
+		"pangea_compute_chunks_pending{}":                        0,
+	} {
+		if got, ok := values[key]; !ok || got != want {
+			t.Errorf("%s = %v (present %v), want %v", key, got, ok, want)
+		}
+	}
+	if values["pangea_compute_chunks_total{placement=local,status=completed}"] == 0 ||
+		values["pangea_compute_chunks_total{placement=delegated,status=completed}"] == 0 {
+		t.Errorf("expected local and delegated chunks counted, got %v", values)
+	}
+}
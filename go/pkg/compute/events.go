@@ -0,0 +1,203 @@
+package compute
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// JobEventType identifies what a job event reports
+type JobEventType string
+
+// Job event types
+const (
+	JobEventStateChanged   JobEventType = "job_state_changed" // The job moved to Status
+	JobEventChunkCompleted JobEventType = "chunk_completed"   // Chunk ChunkIndex produced its result
+	JobEventChunkFailed    JobEventType = "chunk_failed"      // Chunk ChunkIndex failed for good
+)
+
+// jobEventBufferSize is how many events a subscription holds before new
+// ones are dropped
+const jobEventBufferSize = 256
+
+// JobEvent reports progress of a job. Chunk events are sent once a chunk
+// settles, not for each attempt; the chunk's attempts are in JobStatus.
+type JobEvent struct {
+	Seq             uint64       `json:"seq"` // Increases by one per event across all jobs
+	JobID           string       `json:"jobId"`
+	Type            JobEventType `json:"type"`
+	Status          TaskStatus   `json:"status"`               // Job status after the event
+	ChunkIndex      uint32       `json:"chunkIndex,omitempty"` // Chunk events only
+	WorkerID        string       `json:"workerId,omitempty"`   // Worker that produced the chunk result
+	CompletedChunks uint32       `json:"completedChunks"`
+	TotalChunks     uint32       `json:"totalChunks"`
+	Progress        float32      `json:"progress"` // 0.0 to 1.0
+	Error           string       `json:"error,omitempty"`
+	Time            time.Time    `json:"time"`
+}
+
+// Terminal reports whether the event ends its job
+func (e JobEvent) Terminal() bool {
+	return e.Type == JobEventStateChanged &&
+		(e.Status == TaskCompleted || e.Status == TaskFailed || e.Status == TaskCancelled)
+}
+
+// JobEventSubscription receives job events on C until it is unsubscribed,
+// or, when it follows one job, until that job ends
+type JobEventSubscription struct {
+	ID    uint64
+	JobID string // Empty = every job
+	C     <-chan JobEvent
+
+	ch chan JobEvent
+}
+
+// jobEventHub numbers job events and fans them out to observers and
+// subscriptions
+type jobEventHub struct {
+	mu        sync.Mutex
+	seq       uint64
+	nextID    uint64
+	subs      map[uint64]*JobEventSubscription
+	observers []func(JobEvent)
+}
+
+func newJobEventHub() *jobEventHub {
+	return &jobEventHub{subs: make(map[uint64]*JobEventSubscription)}
+}
+
+// OnJobEvent registers an observer called for every job event, in order.
+// Observers run on the goroutine that caused the event and must not block.
+func (m *Manager) OnJobEvent(fn func(JobEvent)) {
+	m.events.mu.Lock()
+	defer m.events.mu.Unlock()
+	m.events.observers = append(m.events.observers, fn)
+}
+
+// SubscribeJobEvents starts a subscription to the events of one job, or of
+// every job when jobID is empty. A job subscription opens with the job's
+// current state, and ends at once if the job is already over. A slow
+// reader loses events rather than stalling jobs.
+func (m *Manager) SubscribeJobEvents(jobID string) (*JobEventSubscription, error) {
+	ch := make(chan JobEvent, jobEventBufferSize)
+	h := m.events
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var current JobEvent
+	if jobID != "" {
+		current = JobEvent{JobID: jobID, Type: JobEventStateChanged, Seq: h.seq, Time: time.Now()}
+		if !m.fillJobEvent(&current) {
+			return nil, fmt.Errorf("job %s not found", jobID)
+		}
+	}
+	h.nextID++
+	sub := &JobEventSubscription{ID: h.nextID, JobID: jobID, C: ch, ch: ch}
+	if jobID == "" {
+		h.subs[sub.ID] = sub
+		return sub, nil
+	}
+	ch <- current
+	if current.Terminal() {
+		close(ch)
+	} else {
+		h.subs[sub.ID] = sub
+	}
+	return sub, nil
+}
+
+// UnsubscribeJobEvents ends a subscription and closes its channel. Returns
+// false if it had already ended.
+func (m *Manager) UnsubscribeJobEvents(id uint64) bool {
+	h := m.events
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	sub, ok := h.subs[id]
+	if !ok {
+		return false
+	}
+	delete(h.subs, id)
+	close(sub.ch)
+	return true
+}
+
+// emitJobEvent fills in the job's progress and publishes the event.
+// Callers must not hold m.mu.
+func (m *Manager) emitJobEvent(ev JobEvent) {
+	h := m.events
+	h.mu.Lock()
+	status := ev.Status
+	if !m.fillJobEvent(&ev) {
+		h.mu.Unlock()
+		return
+	}
+	if ev.Type == JobEventStateChanged {
+		// The job may have moved on already; report the move itself
+		ev.Status = status
+	}
+	h.seq++
+	ev.Seq = h.seq
+	ev.Time = time.Now()
+	for id, sub := range h.subs {
+		if sub.JobID != "" && sub.JobID != ev.JobID {
+			continue
+		}
+		select {
+		case sub.ch <- ev:
+		default:
+		}
+		if sub.JobID != "" && ev.Terminal() {
+			delete(h.subs, id)
+			close(sub.ch)
+		}
+	}
+	observers := h.observers
+	h.mu.Unlock()
+
+	for _, fn := range observers {
+		fn(ev)
+	}
+}
+
+// fillJobEvent sets the event's job status and progress. Returns false if
+// the job is gone.
+func (m *Manager) fillJobEvent(ev *JobEvent) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	state, ok := m.jobs[ev.JobID]
+	if !ok {
+		return false
+	}
+	ev.Status = state.status
+	for _, result := range state.results {
+		if result.Status == TaskCompleted {
+			ev.CompletedChunks++
+		}
+	}
+	ev.TotalChunks = max(uint32(len(state.chunks)), 1)
+	ev.Progress = float32(ev.CompletedChunks) / float32(ev.TotalChunks)
+	if ev.Type == JobEventStateChanged && ev.Status == TaskFailed {
+		for i := range state.chunks {
+			if result, ok := state.results[uint32(i)]; ok && result.Error != "" {
+				ev.Error = result.Error
+				break
+			}
+		}
+	}
+	return true
+}
+
+// emitStateEvent publishes a job's move to status
+func (m *Manager) emitStateEvent(jobID string, status TaskStatus) {
+	m.emitJobEvent(JobEvent{JobID: jobID, Type: JobEventStateChanged, Status: status})
+}
+
+// emitChunkEvent publishes the settled outcome of a chunk
+func (m *Manager) emitChunkEvent(jobID string, chunkIndex uint32, workerID, errMsg string) {
+	ev := JobEvent{JobID: jobID, Type: JobEventChunkCompleted, ChunkIndex: chunkIndex, WorkerID: workerID}
+	if errMsg != "" {
+		ev.Type = JobEventChunkFailed
+		ev.Error = errMsg
+	}
+	m.emitJobEvent(ev)
+}
@@ -0,0 +1,174 @@
+package compute
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// LedgerSaveInterval is how often a changed ledger is flushed to disk
+const LedgerSaveInterval = 10 * time.Second
+
+// UsageRecord accounts the work one worker did for one job. ExecSeconds is
+// wall-clock execution time: as reported by the worker for accepted results,
+// or as measured by the requester for failed and cancelled attempts. Byte
+// counts are the chunk input sent to the worker and the result returned by it.
+type UsageRecord struct {
+	JobID          string    `json:"jobId"`
+	WorkerID       string    `json:"workerId"`
+	ExecSeconds    float64   `json:"execSeconds"`
+	InputBytes     uint64    `json:"inputBytes"`
+	OutputBytes    uint64    `json:"outputBytes"`
+	TasksCompleted uint32    `json:"tasksCompleted"`
+	TasksFailed    uint32    `json:"tasksFailed"`
+	LastUpdated    time.Time `json:"lastUpdated"`
+}
+
+// Ledger records per-job, per-worker compute usage and persists it as JSON
+type Ledger struct {
+	path    string
+	records map[string]*UsageRecord // key: jobID + "/" + workerID
+	dirty   bool
+	mu      sync.RWMutex
+}
+
+// NewLedger creates a ledger backed by path, loading any existing records.
+// An empty path keeps the ledger in memory only.
+func NewLedger(path string) *Ledger {
+	l := &Ledger{
+		path:    path,
+		records: make(map[string]*UsageRecord),
+	}
+	if path != "" {
+		if err := l.load(); err != nil {
+			log.Printf("⚠️  [COMPUTE] Failed to load usage ledger: %v", err)
+		}
+	}
+	return l
+}
+
+// Record adds the outcome of one task executed by workerID for jobID
+func (l *Ledger) Record(jobID, workerID string, elapsed time.Duration, inputBytes, outputBytes uint64, success bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := jobID + "/" + workerID
+	rec, ok := l.records[key]
+	if !ok {
+		rec = &UsageRecord{JobID: jobID, WorkerID: workerID}
+		l.records[key] = rec
+	}
+	rec.ExecSeconds += elapsed.Seconds()
+	rec.InputBytes += inputBytes
+	rec.OutputBytes += outputBytes
+	if success {
+		rec.TasksCompleted++
+	} else {
+		rec.TasksFailed++
+	}
+	rec.LastUpdated = time.Now()
+	l.dirty = true
+}
+
+// Usage returns records matching jobID and workerID (empty matches all),
+// ordered by job then worker
+func (l *Ledger) Usage(jobID, workerID string) []UsageRecord {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	out := make([]UsageRecord, 0, len(l.records))
+	for _, rec := range l.records {
+		if (jobID == "" || rec.JobID == jobID) && (workerID == "" || rec.WorkerID == workerID) {
+			out = append(out, *rec)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].JobID != out[j].JobID {
+			return out[i].JobID < out[j].JobID
+		}
+		return out[i].WorkerID < out[j].WorkerID
+	})
+	return out
+}
+
+// WorkerTotals sums usage across all jobs for each worker
+func (l *Ledger) WorkerTotals() map[string]UsageRecord {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	totals := make(map[string]UsageRecord)
+	for _, rec := range l.records {
+		t := totals[rec.WorkerID]
+		t.WorkerID = rec.WorkerID
+		t.ExecSeconds += rec.ExecSeconds
+		t.InputBytes += rec.InputBytes
+		t.OutputBytes += rec.OutputBytes
+		t.TasksCompleted += rec.TasksCompleted
+		t.TasksFailed += rec.TasksFailed
+		if rec.LastUpdated.After(t.LastUpdated) {
+			t.LastUpdated = rec.LastUpdated
+		}
+		totals[rec.WorkerID] = t
+	}
+	return totals
+}
+
+// Save writes the ledger to disk if it changed since the last save
+func (l *Ledger) Save() error {
+	if l.path == "" {
+		return nil
+	}
+
+	l.mu.Lock()
+	if !l.dirty {
+		l.mu.Unlock()
+		return nil
+	}
+	records := make([]*UsageRecord, 0, len(l.records))
+	for _, rec := range l.records {
+		copied := *rec
+		records = append(records, &copied)
+	}
+	l.dirty = false
+	l.mu.Unlock()
+
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize ledger: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return fmt.Errorf("failed to create ledger directory: %w", err)
+	}
+	tmp := l.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write ledger: %w", err)
+	}
+	if err := os.Rename(tmp, l.path); err != nil {
+		return fmt.Errorf("failed to replace ledger: %w", err)
+	}
+	return nil
+}
+
+func (l *Ledger) load() error {
+	data, err := os.ReadFile(l.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	var records []*UsageRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return fmt.Errorf("failed to parse ledger: %w", err)
+	}
+	for _, rec := range records {
+		l.records[rec.JobID+"/"+rec.WorkerID] = rec
+	}
+	return nil
+}
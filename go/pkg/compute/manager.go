@@ -19,10 +19,12 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
 	"math"
-	"runtime"
+	"slices"
+	"sort"
 	"sync"
 	"time"
 	"unsafe"
@@ -32,10 +34,22 @@ import (
 type ComputeConfig struct {
 	// MaxConcurrentJobs is the maximum number of jobs to process concurrently
 	MaxConcurrentJobs int
+	// MaxQueuedJobs caps jobs accepted but not yet running; submissions
+	// past it fail with ErrQueueFull (0 = unlimited)
+	MaxQueuedJobs int
+	// MaxConcurrentTasks is the number of chunks dispatched at once across all jobs
+	MaxConcurrentTasks int
+	// MaxChunksPerJob caps the in-flight chunks of a single job (0 = unlimited)
+	MaxChunksPerJob int
 	// DefaultTimeout is the default timeout for task execution
 	DefaultTimeout time.Duration
-	// RetryCount is the number of times to retry a failed task
+	// RetryCount is the number of times to retry a failed task, for jobs
+	// that do not set their own
 	RetryCount int
+	// RetryBackoff is the wait before a chunk's first retry; each further
+	// retry waits twice as long, up to RetryMaxBackoff
+	RetryBackoff    time.Duration
+	RetryMaxBackoff time.Duration
 	// ComplexityThreshold is the threshold above which tasks are delegated
 	ComplexityThreshold float64
 	// MinChunkSize is the minimum size for data chunks
@@ -44,18 +58,70 @@ type ComputeConfig struct {
 	MaxChunkSize int64
 	// VerificationMode determines how results are verified
 	VerificationMode VerificationMode
+	// StragglerFactor re-dispatches a remote chunk running longer than this
+	// multiple of the job's median chunk time (0 disables work-stealing)
+	StragglerFactor float64
+	// StragglerMinSamples is the number of completed chunks needed before
+	// straggler detection starts
+	StragglerMinSamples int
+	// StragglerMinDeadline is the shortest time a chunk may run before it
+	// can be treated as a straggler
+	StragglerMinDeadline time.Duration
+	// MaxDelegationDepth is how many times a received task may be split and
+	// forwarded again (0 disables re-delegation)
+	MaxDelegationDepth uint32
+	// SubdelegateLoadThreshold is the CPU load above which a worker
+	// re-delegates received tasks
+	SubdelegateLoadThreshold float32
+	// SubdelegateFanout is the maximum number of peers a task is split across
+	SubdelegateFanout int
+	// LedgerPath is where per-worker usage accounting is persisted
+	// (empty keeps the ledger in memory)
+	LedgerPath string
+	// TemplatePath is where job templates are persisted (empty keeps them
+	// in memory)
+	TemplatePath string
+	// CapacityRefreshInterval is how often host metrics are re-read
+	CapacityRefreshInterval time.Duration
+	// CapacityDiskPath is the filesystem whose free space is reported
+	// (default: working directory)
+	CapacityDiskPath string
+	// WorkerPolicy decides which peer tasks this node runs and their budget
+	WorkerPolicy WorkerPolicy
+	// WorkerPolicyPath is where policy changes are persisted; a saved
+	// policy replaces WorkerPolicy at start (empty keeps it in memory)
+	WorkerPolicyPath string
+	// Sandbox isolates peer tasks from the node
+	Sandbox SandboxConfig
+	// ReplayDir is where replay logs of jobs submitted with RecordReplay
+	// are persisted (empty keeps them in memory)
+	ReplayDir string
 }
 
 // DefaultConfig returns a default compute configuration
 func DefaultConfig() ComputeConfig {
 	return ComputeConfig{
-		MaxConcurrentJobs:   10,
-		DefaultTimeout:      5 * time.Minute,
-		RetryCount:          3,
-		ComplexityThreshold: 0.000001,    // Very low threshold to prefer delegation
-		MinChunkSize:        1024,        // 1 KB - smaller chunks for testing
-		MaxChunkSize:        1024 * 1024, // 1 MB
-		VerificationMode:    VerificationHash,
+		MaxConcurrentJobs:        10,
+		MaxQueuedJobs:            100,
+		MaxConcurrentTasks:       16,
+		MaxChunksPerJob:          4,
+		DefaultTimeout:           5 * time.Minute,
+		RetryCount:               3,
+		RetryBackoff:             250 * time.Millisecond,
+		RetryMaxBackoff:          10 * time.Second,
+		ComplexityThreshold:      0.000001,    // Very low threshold to prefer delegation
+		MinChunkSize:             1024,        // 1 KB - smaller chunks for testing
+		MaxChunkSize:             1024 * 1024, // 1 MB
+		VerificationMode:         VerificationHash,
+		StragglerFactor:          3.0,
+		StragglerMinSamples:      3,
+		StragglerMinDeadline:     2 * time.Second,
+		MaxDelegationDepth:       2,
+		SubdelegateLoadThreshold: 0.8,
+		SubdelegateFanout:        2,
+		CapacityRefreshInterval:  DefaultCapacityRefreshInterval,
+		WorkerPolicy:             DefaultWorkerPolicy(),
+		Sandbox:                  SandboxConfig{Isolation: IsolationNone, DefaultMemoryMB: DefaultSandboxMemoryMB},
 	}
 }
 
@@ -73,6 +139,38 @@ const (
 	VerificationRedundancy
 )
 
+func (v VerificationMode) String() string {
+	switch v {
+	case VerificationNone:
+		return "none"
+	case VerificationHash:
+		return "hash"
+	case VerificationMerkle:
+		return "merkle"
+	case VerificationRedundancy:
+		return "redundancy"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseVerificationMode converts a mode name to a VerificationMode. An empty
+// name selects hash verification.
+func ParseVerificationMode(name string) (VerificationMode, error) {
+	switch name {
+	case "", "hash":
+		return VerificationHash, nil
+	case "none":
+		return VerificationNone, nil
+	case "merkle":
+		return VerificationMerkle, nil
+	case "redundancy":
+		return VerificationRedundancy, nil
+	default:
+		return 0, fmt.Errorf("unknown verification mode %q", name)
+	}
+}
+
 // TaskStatus represents the status of a compute task
 type TaskStatus int
 
@@ -110,6 +208,15 @@ func (s TaskStatus) String() string {
 	}
 }
 
+// finished reports whether a job in this status will not run further
+func (s TaskStatus) finished() bool {
+	switch s {
+	case TaskCompleted, TaskFailed, TaskTimeout, TaskCancelled:
+		return true
+	}
+	return false
+}
+
 // JobManifest describes a compute job
 type JobManifest struct {
 	// JobID is the unique identifier for this job
@@ -120,6 +227,14 @@ type JobManifest struct {
 	InputData []byte `json:"inputData"`
 	// SplitStrategy determines how data is split
 	SplitStrategy string `json:"splitStrategy"`
+	// Reducer combines chunk results (default: the split strategy's reducer)
+	Reducer string `json:"reducer,omitempty"`
+	// DependsOn lists jobs whose results, in order, are prepended to
+	// InputData once they complete
+	DependsOn []string `json:"dependsOn,omitempty"`
+	// PostProcess names steps run, in order, on the merged result before
+	// it is returned
+	PostProcess []string `json:"postProcess,omitempty"`
 	// MinChunkSize is the minimum chunk size
 	MinChunkSize int64 `json:"minChunkSize"`
 	// MaxChunkSize is the maximum chunk size
@@ -128,12 +243,22 @@ type JobManifest struct {
 	VerificationMode VerificationMode `json:"verificationMode"`
 	// TimeoutSecs is the timeout in seconds
 	TimeoutSecs uint32 `json:"timeoutSecs"`
-	// RetryCount is the number of retries
+	// RetryCount is how many times a failed chunk is retried on other
+	// workers before it runs locally (0 = the node's RetryCount)
 	RetryCount uint32 `json:"retryCount"`
 	// Priority is the job priority (higher = more urgent)
 	Priority uint32 `json:"priority"`
 	// Redundancy is the number of workers to use for each task
 	Redundancy uint32 `json:"redundancy"`
+	// StartAt holds the job until then; zero runs it at once. Scheduled
+	// jobs run on workers holding reservations for the submitter.
+	StartAt time.Time `json:"startAt,omitempty"`
+	// TraceContext carries the submitter's trace headers (W3C traceparent)
+	// to the job's tasks
+	TraceContext map[string]string `json:"traceContext,omitempty"`
+	// RecordReplay keeps each chunk's input and every worker's result hash
+	// so that ReplayJob can re-check them later
+	RecordReplay bool `json:"recordReplay,omitempty"`
 }
 
 // ComputeTask represents a single compute task (a chunk of a job)
@@ -154,6 +279,8 @@ type ComputeTask struct {
 	DelegationDepth uint32 `json:"delegationDepth"`
 	// TimeoutMs is the timeout in milliseconds
 	TimeoutMs uint64 `json:"timeoutMs"`
+	// TraceContext carries the trace headers of the span that issued the task
+	TraceContext map[string]string `json:"traceContext,omitempty"`
 }
 
 // TaskResult represents the result of a compute task
@@ -172,6 +299,10 @@ type TaskResult struct {
 	ExecutionTimeMs uint64 `json:"executionTimeMs"`
 	// WorkerID is the ID of the worker that executed the task
 	WorkerID string `json:"workerId"`
+	// ReplacedWorker is the straggling worker this result was stolen from
+	ReplacedWorker string `json:"replacedWorker,omitempty"`
+	// Provenance records the workers that produced a re-delegated result
+	Provenance *Provenance `json:"provenance,omitempty"`
 	// Error is the error message if failed
 	Error string `json:"error,omitempty"`
 }
@@ -188,6 +319,20 @@ type ChunkInfo struct {
 	Status TaskStatus `json:"status"`
 	// AssignedWorker is the assigned worker ID
 	AssignedWorker string `json:"assignedWorker,omitempty"`
+	// Attempts is every execution of the chunk, oldest first
+	Attempts []AttemptInfo `json:"attempts,omitempty"`
+}
+
+// AttemptInfo records one execution of a chunk
+type AttemptInfo struct {
+	// WorkerID is the worker that ran the attempt ("local" for this node)
+	WorkerID string `json:"workerId"`
+	// StartedAt is when the attempt was sent
+	StartedAt time.Time `json:"startedAt"`
+	// Duration is how long the attempt took
+	Duration time.Duration `json:"duration"`
+	// Error is why the attempt failed (empty on success)
+	Error string `json:"error,omitempty"`
 }
 
 // JobStatus represents the status of a compute job
@@ -206,6 +351,10 @@ type JobStatus struct {
 	EstimatedTimeRemaining uint32 `json:"estimatedTimeRemaining"`
 	// Error is the error message if failed
 	Error string `json:"error,omitempty"`
+	// StartedAt is when the job was submitted
+	StartedAt time.Time `json:"startedAt"`
+	// Chunks is the state and attempt history of each chunk
+	Chunks []ChunkInfo `json:"chunks,omitempty"`
 }
 
 // ComputeCapacity represents a node's compute capacity
@@ -214,8 +363,12 @@ type ComputeCapacity struct {
 	CPUCores uint32 `json:"cpuCores"`
 	// RAMMB is the available RAM in megabytes
 	RAMMB uint64 `json:"ramMb"`
+	// TotalRAMMB is the host's total RAM in megabytes
+	TotalRAMMB uint64 `json:"totalRamMb,omitempty"`
 	// CurrentLoad is the current CPU load (0.0 to 1.0)
 	CurrentLoad float32 `json:"currentLoad"`
+	// CoreLoads is the CPU load of each core (0.0 to 1.0)
+	CoreLoads []float32 `json:"coreLoads,omitempty"`
 	// DiskMB is the available disk space in megabytes
 	DiskMB uint64 `json:"diskMb"`
 	// BandwidthMbps is the network bandwidth in Mbps
@@ -238,10 +391,45 @@ type Manager struct {
 	jobs      map[string]*jobState
 	workers   map[string]*workerState
 	capacity  ComputeCapacity
+	prober    *hostProber // Refreshes capacity from host metrics
 	delegator TaskDelegator
 	mu        sync.RWMutex
 	ctx       context.Context
 	cancel    context.CancelFunc
+
+	// Priority dispatch of chunks across jobs
+	scheduler     *Scheduler
+	pendingChunks map[string]*pendingChunk // taskID -> chunk waiting for dispatch
+	queue         *jobQueue                // Admits jobs to MaxConcurrentJobs slots by priority
+	workerBusy    map[string]int           // workerID -> remote attempts in flight
+	activeTasks   int                      // Tasks received from peers and still running
+	policy        WorkerPolicy             // Which peer tasks run, and their budget
+
+	ledger    *Ledger          // Per-job, per-worker usage accounting
+	templates *TemplateLibrary // Named job defaults for parameterized submissions
+	replay    *replayStore     // Execution records of jobs that asked for them
+
+	wasmRuntime WASMRuntime       // Runs job-supplied WASM reducers, if configured
+	wasmSteps   map[string][]byte // Named WASM post-processing modules
+
+	granted map[string]*Reservation // Capacity this node promised to submitters
+	held    map[string]*Reservation // Capacity workers promised to this node
+
+	jobListeners    []func(jobID string, status TaskStatus) // Notified when a job finishes
+	rejectListeners []func(workerID string, err error)      // Notified when a worker's result fails verification
+	events          *jobEventHub                            // Job progress observers and subscriptions
+	metrics         *metrics                                // Prometheus job, chunk and queue metrics
+}
+
+// pendingChunk is a chunk queued in the scheduler awaiting a dispatcher
+type pendingChunk struct {
+	jobID     string
+	index     uint32
+	manifest  *JobManifest
+	data      []byte
+	workerID  string
+	delegator TaskDelegator
+	done      func()
 }
 
 // jobState tracks the internal state of a job
@@ -252,6 +440,7 @@ type jobState struct {
 	status     TaskStatus
 	startTime  time.Time
 	lastUpdate time.Time
+	durations  []time.Duration // Execution times of completed chunks
 }
 
 // workerState tracks the internal state of a worker
@@ -270,13 +459,124 @@ type workerState struct {
 func NewManager(config ComputeConfig) *Manager {
 	ctx, cancel := context.WithCancel(context.Background())
 
-	return &Manager{
-		config:   config,
-		jobs:     make(map[string]*jobState),
-		workers:  make(map[string]*workerState),
-		capacity: probeCapacity(),
-		ctx:      ctx,
-		cancel:   cancel,
+	prober := newHostProber(config.CapacityDiskPath)
+	m := &Manager{
+		config:        config,
+		jobs:          make(map[string]*jobState),
+		workers:       make(map[string]*workerState),
+		capacity:      prober.probe(),
+		prober:        prober,
+		ctx:           ctx,
+		cancel:        cancel,
+		pendingChunks: make(map[string]*pendingChunk),
+		workerBusy:    make(map[string]int),
+		ledger:        NewLedger(config.LedgerPath),
+		templates:     NewTemplateLibrary(config.TemplatePath),
+		replay:        newReplayStore(config.ReplayDir),
+		granted:       make(map[string]*Reservation),
+		held:          make(map[string]*Reservation),
+		policy:        config.WorkerPolicy,
+		events:        newJobEventHub(),
+		metrics:       newMetrics(),
+	}
+	m.loadWorkerPolicy()
+	m.scheduler = NewScheduler(m)
+	m.queue = newJobQueue(config.MaxConcurrentJobs, config.MaxQueuedJobs)
+
+	dispatchers := config.MaxConcurrentTasks
+	if dispatchers <= 0 {
+		dispatchers = 1
+	}
+	for i := 0; i < dispatchers; i++ {
+		go m.runDispatcher()
+	}
+	go func() {
+		<-ctx.Done()
+		m.scheduler.Wake()
+	}()
+	go m.runLedgerSaver()
+	go m.runCapacityProbe()
+
+	return m
+}
+
+// GetTemplates returns the job template library
+func (m *Manager) GetTemplates() *TemplateLibrary {
+	return m.templates
+}
+
+// SubmitTemplateJob submits a job built from a stored template
+func (m *Manager) SubmitTemplateJob(name string, version uint32, params TemplateParams) (string, error) {
+	manifest, err := m.templates.Instantiate(name, version, params)
+	if err != nil {
+		return "", err
+	}
+	return m.SubmitJob(manifest)
+}
+
+// GetLedger returns the usage ledger recording who computed what
+func (m *Manager) GetLedger() *Ledger {
+	return m.ledger
+}
+
+// runLedgerSaver periodically flushes the usage ledger to disk
+func (m *Manager) runLedgerSaver() {
+	ticker := time.NewTicker(LedgerSaveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+			if err := m.ledger.Save(); err != nil {
+				log.Printf("⚠️  [COMPUTE] Failed to save usage ledger: %v", err)
+			}
+		}
+	}
+}
+
+// GetScheduler returns the scheduler that orders chunk dispatch
+func (m *Manager) GetScheduler() *Scheduler {
+	return m.scheduler
+}
+
+// OnJobFinished registers a listener called when a job completes, fails or
+// is cancelled
+func (m *Manager) OnJobFinished(fn func(jobID string, status TaskStatus)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.jobListeners = append(m.jobListeners, fn)
+}
+
+// notifyJobFinished calls the job listeners. Listeners may call back into
+// the manager, so callers must not hold m.mu.
+func (m *Manager) notifyJobFinished(jobID string, status TaskStatus) {
+	m.recordJobFinished(jobID, status)
+	m.emitStateEvent(jobID, status)
+	m.mu.RLock()
+	listeners := m.jobListeners
+	m.mu.RUnlock()
+	for _, fn := range listeners {
+		fn(jobID, status)
+	}
+}
+
+// OnResultRejected registers a listener called when a worker returns a
+// result that fails verification
+func (m *Manager) OnResultRejected(fn func(workerID string, err error)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rejectListeners = append(m.rejectListeners, fn)
+}
+
+// notifyResultRejected calls the reject listeners. Callers must not hold
+// m.mu.
+func (m *Manager) notifyResultRejected(workerID string, err error) {
+	m.mu.RLock()
+	listeners := m.rejectListeners
+	m.mu.RUnlock()
+	for _, fn := range listeners {
+		fn(workerID, err)
 	}
 }
 
@@ -289,31 +589,24 @@ func (m *Manager) SetDelegator(delegator TaskDelegator) {
 
 }
 
-// probeCapacity probes the system for compute capacity using actual system info
-func probeCapacity() ComputeCapacity {
-	numCPU := runtime.NumCPU()
-
-	// Get memory stats
-	// NOTE: HeapSys is NOT total system memory - it's memory obtained from OS for the heap.
-	// This is an approximation based on heap allocation patterns, not actual available RAM.
-	// For accurate system memory, consider using github.com/shirou/gopsutil or similar.
-	var memStats runtime.MemStats
-	runtime.ReadMemStats(&memStats)
-	ramMB := memStats.HeapSys / (1024 * 1024)
-	if ramMB < 512 {
-		ramMB = 512 // Minimum reasonable value for fresh processes
+// runCapacityProbe refreshes this node's capacity from live host metrics
+func (m *Manager) runCapacityProbe() {
+	interval := m.config.CapacityRefreshInterval
+	if interval <= 0 {
+		interval = DefaultCapacityRefreshInterval
 	}
-
-	// Estimate current load based on number of goroutines vs available CPUs
-	numGoroutines := runtime.NumGoroutine()
-	currentLoad := math.Min(float64(numGoroutines)/float64(numCPU*10), 1.0)
-
-	return ComputeCapacity{
-		CPUCores:      uint32(numCPU),
-		RAMMB:         ramMB,
-		CurrentLoad:   float32(currentLoad),
-		DiskMB:        100000, // Disk probing requires OS-specific code
-		BandwidthMbps: 100.0,  // Network probing requires active measurement
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+			capacity := m.prober.probe()
+			m.mu.Lock()
+			m.capacity = capacity
+			m.mu.Unlock()
+		}
 	}
 }
 
@@ -325,12 +618,49 @@ func (m *Manager) SubmitJob(manifest *JobManifest) (string, error) {
 	if manifest.JobID == "" {
 		manifest.JobID = generateJobID()
 	}
+	if err := m.validateJobLocked(manifest, nil); err != nil {
+		return "", err
+	}
+	if err := m.queue.reserve(1); err != nil {
+		return "", err
+	}
+	m.startJobLocked(manifest)
+
+	return manifest.JobID, nil
+}
 
+// validateJobLocked checks a manifest before it is accepted. Dependencies
+// must be existing jobs or, for graph submissions, jobs in graph.
+func (m *Manager) validateJobLocked(manifest *JobManifest, graph map[string]*JobManifest) error {
 	// Check if job already exists
 	if _, exists := m.jobs[manifest.JobID]; exists {
-		return "", fmt.Errorf("job %s already exists", manifest.JobID)
+		return fmt.Errorf("job %s already exists", manifest.JobID)
 	}
 
+	if _, err := GetSplitStrategy(manifest.SplitStrategy); err != nil {
+		return err
+	}
+	if _, err := m.jobReducer(manifest); err != nil {
+		return err
+	}
+	if _, err := m.jobPostProcessors(manifest); err != nil {
+		return err
+	}
+
+	for _, dep := range manifest.DependsOn {
+		if dep == manifest.JobID {
+			return fmt.Errorf("job %s depends on itself", dep)
+		}
+		if _, exists := m.jobs[dep]; !exists && graph[dep] == nil {
+			return fmt.Errorf("job %s depends on unknown job %s", manifest.JobID, dep)
+		}
+	}
+	return nil
+}
+
+// startJobLocked records a validated job, already reserved in the queue,
+// and starts processing it
+func (m *Manager) startJobLocked(manifest *JobManifest) {
 	// Create job state
 	state := &jobState{
 		manifest:   manifest,
@@ -342,11 +672,10 @@ func (m *Manager) SubmitJob(manifest *JobManifest) (string, error) {
 	}
 
 	m.jobs[manifest.JobID] = state
+	m.recordJobSubmitted(manifest)
 
 	// Start processing in background
 	go m.processJob(manifest.JobID)
-
-	return manifest.JobID, nil
 }
 
 // GetJobStatus returns the status of a job
@@ -380,9 +709,36 @@ func (m *Manager) GetJobStatus(jobID string) (*JobStatus, error) {
 		CompletedChunks:        completed,
 		TotalChunks:            total,
 		EstimatedTimeRemaining: m.estimateTimeRemaining(state, completed, total),
+		StartedAt:              state.startTime,
+		Chunks:                 copyChunks(state.chunks),
 	}, nil
 }
 
+// ListJobs returns the status of every job the manager knows, oldest first
+func (m *Manager) ListJobs() []*JobStatus {
+	m.mu.RLock()
+	ids := make([]string, 0, len(m.jobs))
+	for id := range m.jobs {
+		ids = append(ids, id)
+	}
+	m.mu.RUnlock()
+
+	jobs := make([]*JobStatus, 0, len(ids))
+	for _, id := range ids {
+		// Skip jobs removed since the snapshot
+		if status, err := m.GetJobStatus(id); err == nil {
+			jobs = append(jobs, status)
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		if !jobs[i].StartedAt.Equal(jobs[j].StartedAt) {
+			return jobs[i].StartedAt.Before(jobs[j].StartedAt)
+		}
+		return jobs[i].JobID < jobs[j].JobID
+	})
+	return jobs
+}
+
 // GetJobResult returns the final result of a completed job
 func (m *Manager) GetJobResult(jobID string, timeout time.Duration) ([]byte, error) {
 	result, _, err := m.GetJobResultWithWorker(jobID, timeout)
@@ -411,8 +767,12 @@ func (m *Manager) GetJobResultWithWorker(jobID string, timeout time.Duration) ([
 			}
 
 			if state.status == TaskCompleted {
-				// Merge results and get worker info
-				result := m.mergeResults(state)
+				// Merge and post-process results and get worker info
+				result, err := m.jobOutput(state)
+				if err != nil {
+					m.mu.RUnlock()
+					return nil, "", fmt.Errorf("job %s: %w", jobID, err)
+				}
 
 				// Get worker ID from first chunk result
 				workerID := "local"
@@ -436,19 +796,29 @@ func (m *Manager) GetJobResultWithWorker(jobID string, timeout time.Duration) ([
 	}
 }
 
+// ErrJobFinished is returned when cancelling a job that already finished
+var ErrJobFinished = errors.New("job already finished")
+
 // CancelJob cancels a running job
 func (m *Manager) CancelJob(jobID string) error {
 	m.mu.Lock()
-	defer m.mu.Unlock()
-
 	state, exists := m.jobs[jobID]
 	if !exists {
+		m.mu.Unlock()
 		return fmt.Errorf("job %s not found", jobID)
 	}
+	if state.status.finished() {
+		status := state.status
+		m.mu.Unlock()
+		return fmt.Errorf("%w: job %s is %s", ErrJobFinished, jobID, status)
+	}
 
 	state.status = TaskCancelled
 	state.lastUpdate = time.Now()
+	m.mu.Unlock()
+	m.queue.remove(jobID)
 
+	m.notifyJobFinished(jobID, TaskCancelled)
 	return nil
 }
 
@@ -474,9 +844,25 @@ func (m *Manager) RegisterWorker(workerID string, capacity ComputeCapacity) {
 
 // processJob processes a job (internal)
 func (m *Manager) processJob(jobID string) {
+	// Wait for the jobs this one consumes before taking a slot, so that
+	// waiting stages cannot starve the stages they wait on
+	if !m.awaitStart(jobID) || !m.awaitDependencies(jobID) {
+		m.queue.leave()
+		return
+	}
+
+	// Honor MaxConcurrentJobs: wait for a slot, behind higher priorities
+	m.mu.RLock()
+	priority := m.jobs[jobID].manifest.Priority
+	m.mu.RUnlock()
+	if !m.queue.acquire(m.ctx, jobID, priority) {
+		return
+	}
+	defer m.queue.release()
+
 	m.mu.Lock()
 	state, exists := m.jobs[jobID]
-	if !exists {
+	if !exists || state.status == TaskCancelled {
 		m.mu.Unlock()
 		return
 	}
@@ -485,6 +871,7 @@ func (m *Manager) processJob(jobID string) {
 	manifest := state.manifest
 	delegator := m.delegator
 	m.mu.Unlock()
+	m.emitStateEvent(jobID, TaskComputing)
 
 	// Calculate complexity
 	complexity := m.calculateComplexity(manifest)
@@ -552,11 +939,23 @@ func (m *Manager) calculateComplexity(manifest *JobManifest) float64 {
 
 // delegateJob delegates a job to workers
 func (m *Manager) delegateJob(jobID string, manifest *JobManifest) {
-	// Split data into chunks
-	chunks := m.splitData(manifest.InputData, manifest.MinChunkSize, manifest.MaxChunkSize)
+	// Split data into chunks along boundaries the input format allows
+	var chunks [][]byte
+	strategy, err := GetSplitStrategy(manifest.SplitStrategy)
+	if err == nil {
+		chunks, err = strategy.Split(manifest.InputData, manifest.MinChunkSize, manifest.MaxChunkSize)
+	}
 
 	m.mu.Lock()
 	state := m.jobs[jobID]
+	if err != nil {
+		log.Printf("❌ [COMPUTE] Failed to split job %s: %v", jobID, err)
+		state.status = TaskFailed
+		state.lastUpdate = time.Now()
+		m.mu.Unlock()
+		m.notifyJobFinished(jobID, TaskFailed)
+		return
+	}
 	state.chunks = make([]ChunkInfo, len(chunks))
 	for i, chunk := range chunks {
 		state.chunks[i] = ChunkInfo{
@@ -576,51 +975,120 @@ func (m *Manager) delegateJob(jobID string, manifest *JobManifest) {
 		log.Printf("🌐 [COMPUTE] Found %d remote workers for job %s", len(workers), jobID)
 	}
 
+	// Prefer workers that confirmed reservations for this job
+	m.mu.RLock()
+	reserved := m.reservedWorkersLocked(manifest, time.Now(), workers)
+	m.mu.RUnlock()
+	if len(reserved) > 0 && delegator != nil {
+		log.Printf("📅 [COMPUTE] Job %s runs on %d reserved cores", jobID, len(reserved))
+		workers = reserved
+	}
+
 	// Delegate ALL chunks to remote workers for true distributed computing
-	// Only fall back to local execution if no workers are available
+	// Only fall back to local execution if no workers are available.
+	// Chunks go through the priority scheduler so that chunks of
+	// higher-priority jobs are dispatched before queued lower-priority ones.
 	var wg sync.WaitGroup
 	for i, chunk := range chunks {
 		wg.Add(1)
 
+		pc := &pendingChunk{
+			jobID:    jobID,
+			index:    uint32(i),
+			manifest: manifest,
+			data:     chunk,
+			done:     wg.Done,
+		}
 		if len(workers) > 0 {
 			// Delegate to remote worker using round-robin across available workers
-			workerIdx := i % len(workers)
-			workerID := workers[workerIdx]
-			shortID := truncateID(workerID, 12)
-			log.Printf("📤 [COMPUTE] Sending chunk %d to remote worker %s", i, shortID)
-			go func(index int, data []byte, wID string, d TaskDelegator) {
-				defer wg.Done()
-				m.executeChunkRemote(jobID, uint32(index), manifest, data, wID, d)
-			}(i, chunk, workerID, delegator)
-		} else {
-			// No remote workers, execute locally
-			log.Printf("💻 [COMPUTE] No remote workers, executing chunk %d locally", i)
-			go func(index int, data []byte) {
-				defer wg.Done()
-				m.executeChunk(jobID, uint32(index), manifest, data)
-			}(i, chunk)
+			pc.workerID = workers[i%len(workers)]
+			pc.delegator = delegator
 		}
+		m.enqueueChunk(pc)
 	}
 
-	wg.Wait()
+	allDispatched := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(allDispatched)
+	}()
+	select {
+	case <-allDispatched:
+	case <-m.ctx.Done():
+		return
+	}
 
-	// Check if all chunks completed
+	// A cancelled job stays cancelled; its undispatched chunks have no result
 	m.mu.Lock()
-	allComplete := true
-	for _, result := range state.results {
-		if result.Status != TaskCompleted {
-			allComplete = false
-			break
+	cancelled := state.status == TaskCancelled
+	if !cancelled {
+		if allChunksCompleted(state) {
+			state.status = TaskCompleted
+		} else {
+			state.status = TaskFailed
 		}
 	}
+	state.lastUpdate = time.Now()
+	status := state.status
+	m.mu.Unlock()
+	// Cancellation was already reported
+	if !cancelled {
+		m.notifyJobFinished(jobID, status)
+	}
+}
 
-	if allComplete {
-		state.status = TaskCompleted
-	} else {
-		state.status = TaskFailed
+// enqueueChunk queues a chunk for dispatch at its job's priority
+func (m *Manager) enqueueChunk(pc *pendingChunk) {
+	task := &ComputeTask{
+		TaskID:      fmt.Sprintf("%s:%d", pc.jobID, pc.index),
+		ParentJobID: pc.jobID,
+		ChunkIndex:  pc.index,
+		InputData:   pc.data,
 	}
-	state.lastUpdate = time.Now()
+
+	m.mu.Lock()
+	m.pendingChunks[task.TaskID] = pc
 	m.mu.Unlock()
+
+	m.scheduler.Schedule(task, int(pc.manifest.Priority))
+}
+
+// runDispatcher pulls chunks from the scheduler in priority order and runs them
+func (m *Manager) runDispatcher() {
+	for {
+		task := m.scheduler.WaitNextTask(m.ctx)
+		if task == nil {
+			return
+		}
+
+		m.mu.Lock()
+		pc := m.pendingChunks[task.TaskID]
+		delete(m.pendingChunks, task.TaskID)
+		cancelled := false
+		if state, ok := m.jobs[task.ParentJobID]; ok && state.status == TaskCancelled {
+			cancelled = true
+		}
+		m.mu.Unlock()
+
+		if pc != nil && !cancelled {
+			m.runChunk(pc)
+		}
+		m.scheduler.TaskDone(task)
+		if pc != nil {
+			pc.done()
+		}
+	}
+}
+
+// runChunk executes a dispatched chunk remotely or locally
+func (m *Manager) runChunk(pc *pendingChunk) {
+	if pc.delegator != nil && pc.workerID != "" {
+		log.Printf("📤 [COMPUTE] Sending chunk %d to remote worker %s", pc.index, truncateID(pc.workerID, 12))
+		m.executeChunkRemote(pc.jobID, pc.index, pc.manifest, pc.data, pc.workerID, pc.delegator)
+		return
+	}
+	log.Printf("💻 [COMPUTE] No remote workers, executing chunk %d locally", pc.index)
+	m.executeChunk(pc.jobID, pc.index, pc.manifest, pc.data)
 }
 
 // executeJobLocally executes a job on this node
@@ -641,13 +1109,20 @@ func (m *Manager) executeJobLocally(jobID string, manifest *JobManifest) {
 
 	// Mark job as complete
 	m.mu.Lock()
-	if result, ok := state.results[0]; ok && result.Status == TaskCompleted {
-		state.status = TaskCompleted
-	} else {
-		state.status = TaskFailed
+	cancelled := state.status == TaskCancelled
+	if !cancelled {
+		if allChunksCompleted(state) {
+			state.status = TaskCompleted
+		} else {
+			state.status = TaskFailed
+		}
 	}
 	state.lastUpdate = time.Now()
+	status := state.status
 	m.mu.Unlock()
+	if !cancelled {
+		m.notifyJobFinished(jobID, status)
+	}
 }
 
 // executeChunk executes a single chunk
@@ -682,47 +1157,60 @@ func (m *Manager) executeChunk(jobID string, chunkIndex uint32, manifest *JobMan
 		}
 	}
 
+	m.ledger.Record(jobID, "local", time.Since(start), uint64(len(data)), uint64(len(resultData)),
+		result.Status == TaskCompleted)
+	m.recordReplay(jobID, manifest, data, ReplayEntry{ChunkIndex: chunkIndex, WorkerID: "local",
+		ResultHash: result.ResultHash, Accepted: result.Status == TaskCompleted, StartedAt: start,
+		Duration: time.Since(start), Error: result.Error})
+
 	m.mu.Lock()
 	state := m.jobs[jobID]
 	state.results[chunkIndex] = result
+	state.chunks[chunkIndex].Attempts = append(state.chunks[chunkIndex].Attempts, AttemptInfo{
+		WorkerID:  "local",
+		StartedAt: start,
+		Duration:  time.Since(start),
+		Error:     result.Error,
+	})
 	if result.Status == TaskCompleted {
 		state.chunks[chunkIndex].Status = TaskCompleted
+		state.chunks[chunkIndex].AssignedWorker = "local"
+		state.durations = append(state.durations, time.Since(start))
 	} else {
 		state.chunks[chunkIndex].Status = TaskFailed
 	}
 	state.lastUpdate = time.Now()
 	m.mu.Unlock()
+	m.recordChunk(placementLocal, result.Status, time.Since(start))
+	m.emitChunkEvent(jobID, chunkIndex, "local", result.Error)
 }
 
-// executeChunkRemote executes a chunk on a remote worker
-// Handles TOCTOU by checking worker availability and retrying with fresh workers if needed
+// executeChunkRemote executes a chunk on remote workers. A failed attempt
+// is retried, after an exponential backoff, on a worker that has not tried
+// the chunk yet, up to the job's retry count; after that the chunk runs
+// locally. Worker availability is re-checked before each attempt (TOCTOU).
 func (m *Manager) executeChunkRemote(jobID string, chunkIndex uint32, manifest *JobManifest, data []byte, workerID string, delegator TaskDelegator) {
 	start := time.Now()
-	maxRetries := 3
+	retries := m.chunkRetries(manifest)
+	tried := make(map[string]bool)
 	currentWorkerID := workerID
 
-	for attempt := 0; attempt < maxRetries; attempt++ {
-		// Check if worker is still available (mitigate TOCTOU)
-		if delegator.HasWorkers() {
-			workers := delegator.GetAvailableWorkers()
-			workerAvailable := false
-			for _, w := range workers {
-				if w == currentWorkerID {
-					workerAvailable = true
-					break
-				}
-			}
-			if !workerAvailable && len(workers) > 0 {
-				// Worker disconnected, select a new one
-				log.Printf("🔄 [COMPUTE] Worker %s disconnected, selecting new worker for chunk %d",
-					truncateID(currentWorkerID, 12), chunkIndex)
-				currentWorkerID = workers[int(chunkIndex)%len(workers)]
-			}
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 && !m.awaitRetry(jobID, attempt) {
+			m.failChunk(jobID, chunkIndex, "job cancelled before retry")
+			return
 		}
+		if currentWorkerID = pickAttemptWorker(delegator, currentWorkerID, tried, attempt); currentWorkerID == "" {
+			// No more workers available, fall back to local execution
+			log.Printf("🔄 [COMPUTE] No workers available, falling back to local execution for chunk %d", chunkIndex)
+			m.executeChunk(jobID, chunkIndex, manifest, data)
+			return
+		}
+		tried[currentWorkerID] = true
 
 		shortID := truncateID(currentWorkerID, 12)
-		log.Printf("📤 [COMPUTE] Delegating chunk %d to worker %s (%d bytes, attempt %d)",
-			chunkIndex, shortID, len(data), attempt+1)
+		log.Printf("📤 [COMPUTE] Delegating chunk %d to worker %s (%d bytes, attempt %d of %d)",
+			chunkIndex, shortID, len(data), attempt+1, retries+1)
 
 		// Create compute task for remote execution
 		task := &ComputeTask{
@@ -734,66 +1222,316 @@ func (m *Manager) executeChunkRemote(jobID string, chunkIndex uint32, manifest *
 			FunctionName:    "matrix_block_multiply",
 			DelegationDepth: 0,
 			TimeoutMs:       uint64(manifest.TimeoutSecs) * 1000,
+			TraceContext:    manifest.TraceContext,
 		}
 
-		// Execute on remote worker via delegator
-		ctx, cancel := context.WithTimeout(m.ctx, time.Duration(manifest.TimeoutSecs)*time.Second)
-		remoteResult, err := delegator.DelegateTask(ctx, currentWorkerID, task)
-		cancel()
+		// Execute on remote worker via delegator, stealing the chunk for an
+		// idle worker if this one straggles
+		attemptStart := time.Now()
+		remoteResult, acceptedWorker, err := m.delegateWithStealing(task, currentWorkerID, delegator,
+			time.Duration(manifest.TimeoutSecs)*time.Second)
 
 		if err != nil {
 			log.Printf("❌ [COMPUTE] Remote chunk %d failed on %s: %v (attempt %d)",
 				chunkIndex, shortID, err, attempt+1)
+			m.recordAttempt(jobID, chunkIndex, AttemptInfo{WorkerID: currentWorkerID, StartedAt: attemptStart,
+				Duration: time.Since(attemptStart), Error: err.Error()})
+			m.recordReplay(jobID, manifest, data, ReplayEntry{ChunkIndex: chunkIndex, WorkerID: currentWorkerID,
+				StartedAt: attemptStart, Duration: time.Since(attemptStart), Error: err.Error()})
+			continue
+		}
 
-			// Refresh worker list for next attempt
-			if delegator.HasWorkers() {
-				workers := delegator.GetAvailableWorkers()
-				if len(workers) > 0 {
-					// Pick a different worker for retry
-					currentWorkerID = workers[(int(chunkIndex)+attempt+1)%len(workers)]
-					continue
+		// Attempts that did not produce the accepted result were billed by
+		// delegateWithStealing
+		if remoteResult.Status == TaskCompleted {
+			if remoteResult.Provenance != nil {
+				if err := VerifyProvenance(remoteResult.ResultData, remoteResult.Provenance); err != nil {
+					log.Printf("❌ [COMPUTE] Chunk %d from %s failed provenance check: %v",
+						chunkIndex, truncateID(acceptedWorker, 12), err)
+					remoteResult.Status = TaskFailed
+					remoteResult.Error = err.Error()
+					m.notifyResultRejected(acceptedWorker, err)
 				}
 			}
-
-			// No more workers available, fall back to local execution
-			log.Printf("🔄 [COMPUTE] No workers available, falling back to local execution for chunk %d", chunkIndex)
-			m.executeChunk(jobID, chunkIndex, manifest, data)
-			return
+			m.ledger.Record(jobID, acceptedWorker, time.Duration(remoteResult.ExecutionTimeMs)*time.Millisecond,
+				uint64(len(data)), uint64(len(remoteResult.ResultData)), remoteResult.Status == TaskCompleted)
+		}
+		tried[acceptedWorker] = true
+		m.recordAttempt(jobID, chunkIndex, AttemptInfo{WorkerID: acceptedWorker, StartedAt: attemptStart,
+			Duration: time.Since(attemptStart), Error: remoteResult.Error})
+		replayed := ReplayEntry{ChunkIndex: chunkIndex, WorkerID: acceptedWorker, Accepted: remoteResult.Status == TaskCompleted,
+			StartedAt: attemptStart, Duration: time.Since(attemptStart), Error: remoteResult.Error}
+		if remoteResult.ResultData != nil {
+			replayed.ResultHash = hashData(remoteResult.ResultData)
 		}
+		m.recordReplay(jobID, manifest, data, replayed)
 
 		if remoteResult.Status == TaskCompleted {
 			log.Printf("✅ [COMPUTE] Chunk %d completed by worker %s in %dms: %d bytes",
-				chunkIndex, shortID, remoteResult.ExecutionTimeMs, len(remoteResult.ResultData))
+				chunkIndex, truncateID(acceptedWorker, 12), remoteResult.ExecutionTimeMs, len(remoteResult.ResultData))
 
 			result := remoteResult
-			result.WorkerID = currentWorkerID
+			result.WorkerID = acceptedWorker
+			if acceptedWorker != currentWorkerID {
+				result.ReplacedWorker = currentWorkerID
+			}
 			result.ExecutionTimeMs = uint64(time.Since(start).Milliseconds())
 
 			m.mu.Lock()
 			state := m.jobs[jobID]
 			state.results[chunkIndex] = result
 			state.chunks[chunkIndex].Status = TaskCompleted
+			state.chunks[chunkIndex].AssignedWorker = acceptedWorker
+			state.durations = append(state.durations, time.Since(attemptStart))
 			state.lastUpdate = time.Now()
 			m.mu.Unlock()
+			m.recordChunk(placementDelegated, TaskCompleted, time.Since(start))
+			m.emitChunkEvent(jobID, chunkIndex, acceptedWorker, "")
 			return
 		}
 
 		log.Printf("❌ [COMPUTE] Remote chunk %d returned failure: %s (attempt %d)",
 			chunkIndex, remoteResult.Error, attempt+1)
+	}
 
-		// Try to get a different worker for retry
-		if delegator.HasWorkers() {
-			workers := delegator.GetAvailableWorkers()
-			if len(workers) > 0 {
-				currentWorkerID = workers[(int(chunkIndex)+attempt+1)%len(workers)]
+	// All retries exhausted, fall back to local execution
+	log.Printf("🔄 [COMPUTE] All remote attempts failed, falling back to local execution for chunk %d", chunkIndex)
+	m.executeChunk(jobID, chunkIndex, manifest, data)
+}
+
+// chunkRetries is how many times a failed chunk of the job is retried
+func (m *Manager) chunkRetries(manifest *JobManifest) int {
+	if manifest.RetryCount > 0 {
+		return int(manifest.RetryCount)
+	}
+	return max(m.config.RetryCount, 0)
+}
+
+// retryBackoff is the wait before the given retry (1 = first retry)
+func (m *Manager) retryBackoff(retry int) time.Duration {
+	delay := m.config.RetryBackoff
+	for i := 1; i < retry && delay < m.config.RetryMaxBackoff; i++ {
+		delay *= 2
+	}
+	if m.config.RetryMaxBackoff > 0 && delay > m.config.RetryMaxBackoff {
+		delay = m.config.RetryMaxBackoff
+	}
+	return delay
+}
+
+// awaitRetry waits out the backoff before a retry. Returns false if the
+// manager closes or the job is cancelled meanwhile.
+func (m *Manager) awaitRetry(jobID string, retry int) bool {
+	if delay := m.retryBackoff(retry); delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-m.ctx.Done():
+			return false
+		case <-timer.C:
+		}
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.jobs[jobID].status != TaskCancelled
+}
+
+// pickAttemptWorker returns the worker for an attempt: the assigned one
+// for the first attempt while it is available, otherwise an available
+// worker that has not tried the chunk, otherwise any available worker.
+// Returns "" when no worker is available.
+func pickAttemptWorker(delegator TaskDelegator, assigned string, tried map[string]bool, attempt int) string {
+	if !delegator.HasWorkers() {
+		if attempt == 0 {
+			return assigned
+		}
+		return ""
+	}
+	workers := delegator.GetAvailableWorkers()
+	if len(workers) == 0 {
+		return ""
+	}
+	if attempt == 0 && slices.Contains(workers, assigned) {
+		return assigned
+	}
+	for _, w := range workers {
+		if !tried[w] {
+			return w
+		}
+	}
+	return workers[attempt%len(workers)]
+}
+
+// recordAttempt adds an attempt to a chunk's history
+func (m *Manager) recordAttempt(jobID string, chunkIndex uint32, a AttemptInfo) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	chunk := &m.jobs[jobID].chunks[chunkIndex]
+	chunk.Attempts = append(chunk.Attempts, a)
+}
+
+// failChunk marks a chunk failed without another attempt
+func (m *Manager) failChunk(jobID string, chunkIndex uint32, reason string) {
+	m.mu.Lock()
+	state := m.jobs[jobID]
+	state.results[chunkIndex] = &TaskResult{
+		TaskID: fmt.Sprintf("%s:%d", jobID, chunkIndex),
+		Status: TaskFailed,
+		Error:  reason,
+	}
+	state.chunks[chunkIndex].Status = TaskFailed
+	state.lastUpdate = time.Now()
+	m.mu.Unlock()
+	m.emitChunkEvent(jobID, chunkIndex, "", reason)
+}
+
+// copyChunks copies chunk states, attempt histories included
+func copyChunks(chunks []ChunkInfo) []ChunkInfo {
+	out := make([]ChunkInfo, len(chunks))
+	for i, c := range chunks {
+		out[i] = c
+		out[i].Attempts = append([]AttemptInfo(nil), c.Attempts...)
+	}
+	return out
+}
+
+// stragglerCheckInterval is how often in-flight remote chunks are compared
+// against the straggler deadline
+const stragglerCheckInterval = 50 * time.Millisecond
+
+// chunkAttempt is the outcome of one remote execution of a chunk
+type chunkAttempt struct {
+	workerID string
+	result   *TaskResult
+	err      error
+}
+
+// delegateWithStealing runs a task on workerID. If the chunk outlives the
+// job's straggler deadline, it is re-dispatched to an idle worker; the first
+// successful result is accepted and the other attempt is cancelled.
+// Returns the result and the worker that produced it.
+func (m *Manager) delegateWithStealing(task *ComputeTask, workerID string, delegator TaskDelegator, timeout time.Duration) (*TaskResult, string, error) {
+	ctx, cancel := context.WithTimeout(m.ctx, timeout)
+	defer cancel()
+
+	attempts := make(chan chunkAttempt, 2)
+	cancels := make(map[string]context.CancelFunc)
+	started := make(map[string]time.Time)
+	launch := func(w string) {
+		attemptCtx, attemptCancel := context.WithCancel(ctx)
+		cancels[w] = attemptCancel
+		started[w] = time.Now()
+
+		m.mu.Lock()
+		m.workerBusy[w]++
+		m.mu.Unlock()
+
+		go func() {
+			result, err := delegator.DelegateTask(attemptCtx, w, task)
+			m.mu.Lock()
+			m.workerBusy[w]--
+			if m.workerBusy[w] <= 0 {
+				delete(m.workerBusy, w)
+			}
+			m.mu.Unlock()
+			attempts <- chunkAttempt{workerID: w, result: result, err: err}
+		}()
+	}
+
+	start := time.Now()
+	launch(workerID)
+	outstanding := 1
+	stolen := false
+
+	ticker := time.NewTicker(stragglerCheckInterval)
+	defer ticker.Stop()
+
+	var last chunkAttempt
+	finished := make(map[string]struct{})
+	for outstanding > 0 {
+		select {
+		case a := <-attempts:
+			outstanding--
+			if a.err == nil && a.result != nil && a.result.Status == TaskCompleted {
+				for w, c := range cancels {
+					if w != a.workerID {
+						log.Printf("🛑 [COMPUTE] Cancelling chunk %d on %s, result accepted from %s",
+							task.ChunkIndex, truncateID(w, 12), truncateID(a.workerID, 12))
+						c()
+						if _, done := finished[w]; !done {
+							m.ledger.Record(task.ParentJobID, w, time.Since(started[w]), uint64(len(task.InputData)), 0, false)
+						}
+					}
+				}
+				return a.result, a.workerID, nil
+			}
+			if a.err == nil && a.result == nil {
+				a.err = fmt.Errorf("worker returned no result")
+			}
+			finished[a.workerID] = struct{}{}
+			m.ledger.Record(task.ParentJobID, a.workerID, time.Since(started[a.workerID]), uint64(len(task.InputData)), 0, false)
+			last = a
+
+		case <-ticker.C:
+			if stolen {
+				continue
+			}
+			deadline, ok := m.stragglerDeadline(task.ParentJobID)
+			if !ok || time.Since(start) < deadline {
 				continue
 			}
+			if idle := m.pickIdleWorker(delegator, workerID); idle != "" {
+				log.Printf("🐢 [COMPUTE] Chunk %d straggling on %s after %v (deadline %v), re-dispatching to %s",
+					task.ChunkIndex, truncateID(workerID, 12), time.Since(start).Round(time.Millisecond),
+					deadline.Round(time.Millisecond), truncateID(idle, 12))
+				launch(idle)
+				outstanding++
+				stolen = true
+			}
 		}
 	}
 
-	// All retries exhausted, fall back to local execution
-	log.Printf("🔄 [COMPUTE] All remote attempts failed, falling back to local execution for chunk %d", chunkIndex)
-	m.executeChunk(jobID, chunkIndex, manifest, data)
+	return last.result, last.workerID, last.err
+}
+
+// stragglerDeadline returns how long a chunk of the job may run before it is
+// considered a straggler, based on the median of completed chunk times
+func (m *Manager) stragglerDeadline(jobID string) (time.Duration, bool) {
+	if m.config.StragglerFactor <= 0 {
+		return 0, false
+	}
+
+	m.mu.RLock()
+	state, exists := m.jobs[jobID]
+	if !exists || len(state.durations) == 0 || len(state.durations) < m.config.StragglerMinSamples {
+		m.mu.RUnlock()
+		return 0, false
+	}
+	durations := append([]time.Duration(nil), state.durations...)
+	m.mu.RUnlock()
+
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	median := durations[len(durations)/2]
+
+	deadline := time.Duration(float64(median) * m.config.StragglerFactor)
+	if deadline < m.config.StragglerMinDeadline {
+		deadline = m.config.StragglerMinDeadline
+	}
+	return deadline, true
+}
+
+// pickIdleWorker returns an available worker with no remote chunk in flight
+func (m *Manager) pickIdleWorker(delegator TaskDelegator, exclude string) string {
+	workers := delegator.GetAvailableWorkers()
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, w := range workers {
+		if w != exclude && m.workerBusy[w] == 0 {
+			return w
+		}
+	}
+	return ""
 }
 
 // ExecuteMatrixBlockMultiply executes matrix block multiplication (exported for compute protocol)
@@ -803,10 +1541,22 @@ func ExecuteMatrixBlockMultiply(data []byte) ([]byte, error) {
 	return executeMatrixBlockMultiply(data)
 }
 
+// maxMatrixElements caps rows*cols of a matrix parsed from peer input. Using
+// uint64 arithmetic and capping at MaxInt32/8 keeps the byte size within int
+// (int is 32-bit on some platforms, and we need safe bounds for slice operations)
+const maxMatrixElements uint64 = math.MaxInt32 / 8
+
 // executeMatrixBlockMultiply executes matrix block multiplication
 // Input format: [a_rows:4][a_cols:4][a_data:a_rows*a_cols*8][b_rows:4][b_cols:4][b_data:b_rows*b_cols*8]
 // Output format: [c_rows:4][c_cols:4][c_data:c_rows*c_cols*8]
 func executeMatrixBlockMultiply(data []byte) ([]byte, error) {
+	return multiplyMatrixBlocks(context.Background(), data, 0)
+}
+
+// multiplyMatrixBlocks is executeMatrixBlockMultiply for peer tasks: it
+// stops when ctx ends and holds itself to cpuPercent of one core (0 = no
+// limit)
+func multiplyMatrixBlocks(ctx context.Context, data []byte, cpuPercent uint32) ([]byte, error) {
 	if len(data) < 16 {
 		return nil, fmt.Errorf("input data too short: %d bytes", len(data))
 	}
@@ -821,9 +1571,6 @@ func executeMatrixBlockMultiply(data []byte) ([]byte, error) {
 	log.Printf("   📊 [COMPUTE] Parsed dimensions: aRows=%d, aCols=%d", aRows, aCols)
 
 	// Check for integer overflow before multiplication: aRows * aCols * 8
-	// Use uint64 arithmetic and cap at MaxInt32/8 to ensure the result fits in int
-	// (int is 32-bit on some platforms, and we need safe bounds for slice operations)
-	const maxMatrixElements uint64 = math.MaxInt32 / 8
 	if uint64(aRows)*uint64(aCols) > maxMatrixElements {
 		return nil, fmt.Errorf("matrix A dimensions too large: %d x %d would overflow", aRows, aCols)
 	}
@@ -895,7 +1642,11 @@ func executeMatrixBlockMultiply(data []byte) ([]byte, error) {
 	cRows := aRows
 	cCols := bCols
 	matrixC := make([][]float64, cRows)
+	pacer := newCPUPacer(cpuPercent)
 	for i := uint32(0); i < cRows; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("stopped after %d of %d rows: %w", i, cRows, err)
+		}
 		matrixC[i] = make([]float64, cCols)
 		for j := uint32(0); j < cCols; j++ {
 			sum := 0.0
@@ -904,6 +1655,7 @@ func executeMatrixBlockMultiply(data []byte) ([]byte, error) {
 			}
 			matrixC[i][j] = sum
 		}
+		pacer.pace(ctx)
 	}
 
 	// Serialize result matrix
@@ -950,47 +1702,33 @@ func float64bits(f float64) uint64 {
 	return *(*uint64)(unsafe.Pointer(&f))
 }
 
-// splitData splits data into chunks
-func (m *Manager) splitData(data []byte, minSize, maxSize int64) [][]byte {
-	if len(data) == 0 {
-		return [][]byte{}
-	}
-
-	// For matrix multiplication data (which has a specific header structure),
-	// arbitrary splitting will break the format.
-	// If the data fits within MaxChunkSize, keep it as one chunk.
-	// This ensures the worker receives the full matrix problem.
-	if int64(len(data)) <= maxSize {
-		return [][]byte{data}
+// mergeResults reduces chunk results in chunk order with the job's reducer.
+// Caller must hold m.mu.
+func (m *Manager) mergeResults(state *jobState) ([]byte, error) {
+	reducer, err := m.jobReducer(state.manifest)
+	if err != nil {
+		return nil, err
 	}
-
-	// If we MUST split (data > MaxChunkSize), we use the max size.
-	// Note: This will still break matrix multiplication if the logic isn't
-	// matrix-aware, but it's better than forcing small chunks.
-	chunkSize := maxSize
-
-	var chunks [][]byte
-	for i := int64(0); i < int64(len(data)); i += chunkSize {
-		end := i + chunkSize
-		if end > int64(len(data)) {
-			end = int64(len(data))
+	results := make([][]byte, 0, len(state.chunks))
+	for i := uint32(0); i < uint32(len(state.chunks)); i++ {
+		r, ok := state.results[i]
+		if !ok || r.Status != TaskCompleted {
+			return nil, fmt.Errorf("chunk %d of %d has no result", i, len(state.chunks))
 		}
-		chunks = append(chunks, data[i:end])
+		results = append(results, r.ResultData)
 	}
-
-	return chunks
+	return reducer.Reduce(results)
 }
 
-// mergeResults merges results from all chunks
-func (m *Manager) mergeResults(state *jobState) []byte {
-	// Sort results by chunk index and concatenate
-	var result []byte
-	for i := uint32(0); i < uint32(len(state.chunks)); i++ {
-		if r, ok := state.results[i]; ok {
-			result = append(result, r.ResultData...)
+// allChunksCompleted reports whether every chunk of a job has a completed
+// result. Caller must hold m.mu.
+func allChunksCompleted(state *jobState) bool {
+	for i := range state.chunks {
+		if r, ok := state.results[uint32(i)]; !ok || r.Status != TaskCompleted {
+			return false
 		}
 	}
-	return result
+	return true
 }
 
 // estimateTimeRemaining estimates the remaining time for a job
@@ -1006,9 +1744,20 @@ func (m *Manager) estimateTimeRemaining(state *jobState, completed, total uint32
 	return uint32(avgPerChunk.Seconds() * float64(remaining))
 }
 
+// Err returns a non-nil error once the manager has been closed
+func (m *Manager) Err() error {
+	if m.ctx.Err() != nil {
+		return fmt.Errorf("compute manager closed")
+	}
+	return nil
+}
+
 // Close shuts down the manager
 func (m *Manager) Close() {
 	m.cancel()
+	if err := m.ledger.Save(); err != nil {
+		log.Printf("⚠️  [COMPUTE] Failed to save usage ledger: %v", err)
+	}
 }
 
 // generateJobID generates a unique job ID
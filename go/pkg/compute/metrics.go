@@ -0,0 +1,144 @@
+package compute
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Chunk placements reported by the chunk metrics
+const (
+	placementLocal     = "local"     // Ran on this node
+	placementDelegated = "delegated" // A worker's result was accepted
+)
+
+// metrics is a manager's Prometheus metric set. Every manager owns its
+// own, so managers in one process register on separate registries.
+type metrics struct {
+	jobsSubmitted *prometheus.CounterVec
+	jobsFinished  *prometheus.CounterVec
+	jobDuration   *prometheus.HistogramVec
+	chunks        *prometheus.CounterVec
+	chunkDuration *prometheus.HistogramVec
+}
+
+var (
+	queueDepthDesc = prometheus.NewDesc("pangea_compute_queue_depth",
+		"Jobs waiting for a slot, by priority", []string{"priority"}, nil)
+	jobsRunningDesc = prometheus.NewDesc("pangea_compute_jobs_running",
+		"Jobs holding a slot", nil, nil)
+	chunksPendingDesc = prometheus.NewDesc("pangea_compute_chunks_pending",
+		"Chunks waiting for a dispatcher", nil, nil)
+	peerTasksDesc = prometheus.NewDesc("pangea_compute_peer_tasks_active",
+		"Tasks received from peers and still running", nil, nil)
+	workerTrustDesc = prometheus.NewDesc("pangea_compute_worker_trust",
+		"Trust score of each known worker (0.0 to 1.0)", []string{"worker"}, nil)
+)
+
+func newMetrics() *metrics {
+	return &metrics{
+		jobsSubmitted: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "pangea_compute_jobs_submitted_total",
+			Help: "Compute jobs accepted, by priority",
+		}, []string{"priority"}),
+		jobsFinished: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "pangea_compute_jobs_total",
+			Help: "Compute jobs finished, by priority and final status",
+		}, []string{"priority", "status"}),
+		jobDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "pangea_compute_job_duration_seconds",
+			Help:    "Compute job duration from submission to finish in seconds",
+			Buckets: prometheus.ExponentialBuckets(0.1, 2, 14),
+		}, []string{"priority"}),
+		chunks: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "pangea_compute_chunks_total",
+			Help: "Chunks settled, by where they ran and their status",
+		}, []string{"placement", "status"}),
+		chunkDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "pangea_compute_chunk_duration_seconds",
+			Help:    "Chunk latency in seconds: the local run, or from the first delegation attempt to the accepted result",
+			Buckets: prometheus.ExponentialBuckets(0.005, 2, 14),
+		}, []string{"placement"}),
+	}
+}
+
+// Metrics returns the manager's Prometheus collector: job and chunk
+// counters and latencies, and, read at scrape time, queue depth and worker
+// trust
+func (m *Manager) Metrics() prometheus.Collector {
+	return managerCollector{m}
+}
+
+// priorityLabel is a job's priority as a metric label
+func priorityLabel(manifest *JobManifest) string {
+	return strconv.FormatUint(uint64(manifest.Priority), 10)
+}
+
+// recordJobSubmitted counts an accepted job
+func (m *Manager) recordJobSubmitted(manifest *JobManifest) {
+	m.metrics.jobsSubmitted.WithLabelValues(priorityLabel(manifest)).Inc()
+}
+
+// recordJobFinished counts a finished job and its duration
+func (m *Manager) recordJobFinished(jobID string, status TaskStatus) {
+	m.mu.RLock()
+	state, ok := m.jobs[jobID]
+	m.mu.RUnlock()
+	if !ok {
+		return
+	}
+	priority := priorityLabel(state.manifest)
+	m.metrics.jobsFinished.WithLabelValues(priority, status.String()).Inc()
+	m.metrics.jobDuration.WithLabelValues(priority).Observe(time.Since(state.startTime).Seconds())
+}
+
+// recordChunk counts a settled chunk and its latency
+func (m *Manager) recordChunk(placement string, status TaskStatus, elapsed time.Duration) {
+	m.metrics.chunks.WithLabelValues(placement, status.String()).Inc()
+	m.metrics.chunkDuration.WithLabelValues(placement).Observe(elapsed.Seconds())
+}
+
+// managerCollector collects a manager's metrics
+type managerCollector struct {
+	m *Manager
+}
+
+// Describe implements prometheus.Collector
+func (c managerCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.m.metrics.jobsSubmitted.Describe(ch)
+	c.m.metrics.jobsFinished.Describe(ch)
+	c.m.metrics.jobDuration.Describe(ch)
+	c.m.metrics.chunks.Describe(ch)
+	c.m.metrics.chunkDuration.Describe(ch)
+	ch <- queueDepthDesc
+	ch <- jobsRunningDesc
+	ch <- chunksPendingDesc
+	ch <- peerTasksDesc
+	ch <- workerTrustDesc
+}
+
+// Collect implements prometheus.Collector
+func (c managerCollector) Collect(ch chan<- prometheus.Metric) {
+	m := c.m
+	m.metrics.jobsSubmitted.Collect(ch)
+	m.metrics.jobsFinished.Collect(ch)
+	m.metrics.jobDuration.Collect(ch)
+	m.metrics.chunks.Collect(ch)
+	m.metrics.chunkDuration.Collect(ch)
+
+	running, waiting := m.queue.depth()
+	ch <- prometheus.MustNewConstMetric(jobsRunningDesc, prometheus.GaugeValue, float64(running))
+	for priority, n := range waiting {
+		ch <- prometheus.MustNewConstMetric(queueDepthDesc, prometheus.GaugeValue, float64(n),
+			strconv.FormatUint(uint64(priority), 10))
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	ch <- prometheus.MustNewConstMetric(chunksPendingDesc, prometheus.GaugeValue, float64(len(m.pendingChunks)))
+	ch <- prometheus.MustNewConstMetric(peerTasksDesc, prometheus.GaugeValue, float64(m.activeTasks))
+	for id, w := range m.workers {
+		ch <- prometheus.MustNewConstMetric(workerTrustDesc, prometheus.GaugeValue, float64(w.trustScore), id)
+	}
+}
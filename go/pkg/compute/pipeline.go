@@ -0,0 +1,168 @@
+package compute
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// dependencyPollInterval is how often a waiting job checks its dependencies
+const dependencyPollInterval = 50 * time.Millisecond
+
+// SubmitJobGraph submits jobs that consume each other's results, such as a
+// map stage followed by a reduce stage. Every manifest needs a JobID so that
+// DependsOn can refer to it. The graph is accepted or rejected as a whole,
+// and the job IDs are returned in topological order.
+func (m *Manager) SubmitJobGraph(manifests []*JobManifest) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	graph := make(map[string]*JobManifest, len(manifests))
+	for _, manifest := range manifests {
+		if manifest.JobID == "" {
+			return nil, fmt.Errorf("every job in a graph needs a job ID")
+		}
+		if graph[manifest.JobID] != nil {
+			return nil, fmt.Errorf("job %s appears twice in the graph", manifest.JobID)
+		}
+		graph[manifest.JobID] = manifest
+	}
+	for _, manifest := range manifests {
+		if err := m.validateJobLocked(manifest, graph); err != nil {
+			return nil, err
+		}
+	}
+
+	order, err := topoSortJobs(manifests, graph)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.queue.reserve(len(order)); err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(order))
+	for i, manifest := range order {
+		m.startJobLocked(manifest)
+		ids[i] = manifest.JobID
+	}
+	log.Printf("🔗 [COMPUTE] Submitted job graph of %d stages: %v", len(ids), ids)
+	return ids, nil
+}
+
+// topoSortJobs orders the graph so that each job follows the graph jobs it
+// depends on, or reports a cycle
+func topoSortJobs(manifests []*JobManifest, graph map[string]*JobManifest) ([]*JobManifest, error) {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	mark := make(map[string]int, len(manifests))
+	order := make([]*JobManifest, 0, len(manifests))
+
+	var visit func(manifest *JobManifest) error
+	visit = func(manifest *JobManifest) error {
+		switch mark[manifest.JobID] {
+		case visiting:
+			return fmt.Errorf("job graph has a cycle through %s", manifest.JobID)
+		case done:
+			return nil
+		}
+		mark[manifest.JobID] = visiting
+		for _, dep := range manifest.DependsOn {
+			if next := graph[dep]; next != nil {
+				if err := visit(next); err != nil {
+					return err
+				}
+			}
+		}
+		mark[manifest.JobID] = done
+		order = append(order, manifest)
+		return nil
+	}
+
+	for _, manifest := range manifests {
+		if err := visit(manifest); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// awaitDependencies blocks until every job jobID depends on has completed,
+// then prepends their results to its input. It returns false, failing the
+// job, if a dependency did not complete, and false if the job was cancelled.
+func (m *Manager) awaitDependencies(jobID string) bool {
+	ticker := time.NewTicker(dependencyPollInterval)
+	defer ticker.Stop()
+
+	for {
+		m.mu.Lock()
+		state, exists := m.jobs[jobID]
+		if !exists || state.status == TaskCancelled {
+			m.mu.Unlock()
+			return false
+		}
+		if len(state.manifest.DependsOn) == 0 {
+			m.mu.Unlock()
+			return true
+		}
+
+		ready, err := m.collectDependencyOutputs(state.manifest)
+		if err != nil {
+			log.Printf("❌ [COMPUTE] Job %s cannot run: %v", jobID, err)
+			state.status = TaskFailed
+			state.lastUpdate = time.Now()
+			m.mu.Unlock()
+			m.notifyJobFinished(jobID, TaskFailed)
+			return false
+		}
+		if ready != nil {
+			// Resolve into a copy so the caller's manifest is left untouched
+			resolved := *state.manifest
+			resolved.InputData = append(ready, state.manifest.InputData...)
+			resolved.DependsOn = nil
+			upstream := len(state.manifest.DependsOn)
+			state.manifest = &resolved
+			m.mu.Unlock()
+			log.Printf("🔗 [COMPUTE] Job %s received %d bytes from %d upstream jobs", jobID, len(ready), upstream)
+			return true
+		}
+		m.mu.Unlock()
+
+		select {
+		case <-m.ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+}
+
+// collectDependencyOutputs returns the concatenated results of a job's
+// dependencies, nil if some are still running, or an error if one ended
+// without completing. Caller must hold m.mu.
+func (m *Manager) collectDependencyOutputs(manifest *JobManifest) ([]byte, error) {
+	for _, dep := range manifest.DependsOn {
+		depState, exists := m.jobs[dep]
+		if !exists {
+			return nil, fmt.Errorf("dependency %s not found", dep)
+		}
+		switch depState.status {
+		case TaskCompleted:
+		case TaskFailed, TaskCancelled, TaskTimeout:
+			return nil, fmt.Errorf("dependency %s ended as %s", dep, depState.status)
+		default:
+			return nil, nil
+		}
+	}
+
+	input := []byte{}
+	for _, dep := range manifest.DependsOn {
+		output, err := m.jobOutput(m.jobs[dep])
+		if err != nil {
+			return nil, fmt.Errorf("dependency %s: %w", dep, err)
+		}
+		input = append(input, output...)
+	}
+	return input, nil
+}
@@ -0,0 +1,198 @@
+package compute
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// Built-in post-processing steps, listed in JobManifest.PostProcess
+const (
+	PostProcessGunzip       = "gunzip"        // Decompress a gzip result
+	PostProcessVerifyMatrix = "verify_matrix" // Check a matrix result's shape and that every value is finite
+	PostProcessMatrixCSV    = "matrix_csv"    // Convert a matrix result to CSV text
+	PostProcessWASM         = "wasm"          // The job module's exported "postprocess" function
+)
+
+// PostProcessor transforms or checks a job's merged result on the
+// submitting node before it is returned. Returning an error fails the
+// result.
+type PostProcessor interface {
+	Process(result []byte) ([]byte, error)
+}
+
+// PostProcessorFunc adapts a function to the PostProcessor interface
+type PostProcessorFunc func(result []byte) ([]byte, error)
+
+// Process implements PostProcessor
+func (f PostProcessorFunc) Process(result []byte) ([]byte, error) {
+	return f(result)
+}
+
+var (
+	postProcessors = map[string]PostProcessor{
+		PostProcessGunzip:       PostProcessorFunc(gunzipResult),
+		PostProcessVerifyMatrix: PostProcessorFunc(verifyMatrixResult),
+		PostProcessMatrixCSV:    PostProcessorFunc(matrixToCSV),
+	}
+	postProcessorsMu sync.RWMutex
+)
+
+// RegisterPostProcessor adds or replaces a named post-processing step
+func RegisterPostProcessor(name string, p PostProcessor) {
+	postProcessorsMu.Lock()
+	defer postProcessorsMu.Unlock()
+	postProcessors[name] = p
+}
+
+// GetPostProcessor returns the step registered under name
+func GetPostProcessor(name string) (PostProcessor, error) {
+	postProcessorsMu.RLock()
+	defer postProcessorsMu.RUnlock()
+	p, ok := postProcessors[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown post-processing step %q", name)
+	}
+	return p, nil
+}
+
+// PostProcessorNames lists the registered steps and the WASM step, sorted
+func PostProcessorNames() []string {
+	postProcessorsMu.RLock()
+	defer postProcessorsMu.RUnlock()
+	names := make([]string, 0, len(postProcessors)+1)
+	for name := range postProcessors {
+		names = append(names, name)
+	}
+	names = append(names, PostProcessWASM)
+	sort.Strings(names)
+	return names
+}
+
+// RegisterWASMPostProcessor makes a WASM module's "postprocess" export
+// available to jobs on this manager as a named step
+func (m *Manager) RegisterWASMPostProcessor(name string, module []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.wasmSteps == nil {
+		m.wasmSteps = make(map[string][]byte)
+	}
+	m.wasmSteps[name] = append([]byte(nil), module...)
+}
+
+// jobPostProcessors resolves a job's post-processing steps, in order.
+// Caller must hold m.mu.
+func (m *Manager) jobPostProcessors(manifest *JobManifest) ([]PostProcessor, error) {
+	steps := make([]PostProcessor, 0, len(manifest.PostProcess))
+	for _, name := range manifest.PostProcess {
+		module, isWASM := m.wasmSteps[name]
+		if name == PostProcessWASM {
+			module, isWASM = manifest.WASMModule, true
+			if len(module) == 0 {
+				return nil, fmt.Errorf("wasm post-processing requested but the job has no WASM module")
+			}
+		}
+		if !isWASM {
+			p, err := GetPostProcessor(name)
+			if err != nil {
+				return nil, err
+			}
+			steps = append(steps, p)
+			continue
+		}
+
+		if m.wasmRuntime == nil {
+			return nil, fmt.Errorf("wasm post-processing step %q requested but no WASM runtime is configured", name)
+		}
+		runtime := m.wasmRuntime
+		steps = append(steps, PostProcessorFunc(func(result []byte) ([]byte, error) {
+			return runtime.PostProcess(module, result)
+		}))
+	}
+	return steps, nil
+}
+
+// jobOutput merges a completed job's chunk results and runs its
+// post-processing steps. Caller must hold m.mu.
+func (m *Manager) jobOutput(state *jobState) ([]byte, error) {
+	result, err := m.mergeResults(state)
+	if err != nil {
+		return nil, fmt.Errorf("failed to merge results: %w", err)
+	}
+	steps, err := m.jobPostProcessors(state.manifest)
+	if err != nil {
+		return nil, err
+	}
+	for i, step := range steps {
+		if result, err = step.Process(result); err != nil {
+			return nil, fmt.Errorf("post-processing step %s: %w", state.manifest.PostProcess[i], err)
+		}
+	}
+	return result, nil
+}
+
+// gunzipResult decompresses a gzip-compressed result
+func gunzipResult(result []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(result))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// verifyMatrixResult checks that a result is a well-formed matrix of
+// finite values and returns it unchanged
+func verifyMatrixResult(result []byte) ([]byte, error) {
+	_, cols, values, err := parseMatrixResult(result)
+	if err != nil {
+		return nil, err
+	}
+	for i, v := range values {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, fmt.Errorf("value at row %d, column %d is %v", i/int(cols), i%int(cols), v)
+		}
+	}
+	return result, nil
+}
+
+// matrixToCSV renders a matrix result as CSV, one line per row
+func matrixToCSV(result []byte) ([]byte, error) {
+	_, cols, values, err := parseMatrixResult(result)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	for i, v := range values {
+		buf.WriteString(strconv.FormatFloat(v, 'g', -1, 64))
+		if (i+1)%int(cols) == 0 {
+			buf.WriteByte('\n')
+		} else {
+			buf.WriteByte(',')
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// parseMatrixResult decodes a rows | cols | float64 values matrix
+func parseMatrixResult(result []byte) (rows, cols uint32, values []float64, err error) {
+	if len(result) < 8 {
+		return 0, 0, nil, fmt.Errorf("matrix result too short: %d bytes", len(result))
+	}
+	rows = binary.BigEndian.Uint32(result[0:4])
+	cols = binary.BigEndian.Uint32(result[4:8])
+	if want := 8 + uint64(rows)*uint64(cols)*8; uint64(len(result)) != want {
+		return 0, 0, nil, fmt.Errorf("%dx%d matrix needs %d bytes, have %d", rows, cols, want, len(result))
+	}
+	values = make([]float64, int(rows)*int(cols))
+	for i := range values {
+		values[i] = float64frombits(binary.BigEndian.Uint64(result[8+i*8:]))
+	}
+	return rows, cols, values, nil
+}
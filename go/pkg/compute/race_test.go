@@ -0,0 +1,8 @@
+//go:build race
+
+package compute
+
+// raceEnabled reports whether the tests were built with the race detector,
+// whose shadow memory cannot be mapped under the sandbox's address space
+// limit
+const raceEnabled = true
@@ -0,0 +1,150 @@
+package compute
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Built-in reducer names, selected via JobManifest.Reducer
+const (
+	ReducerConcat     = "concat"      // Concatenate results in chunk order
+	ReducerMatrixRows = "matrix_rows" // Stack row-block matrices
+	ReducerSumBlocks  = "sum_blocks"  // Element-wise sum of equal-shaped matrices
+	ReducerWASM       = "wasm"        // The job module's exported "reduce" function
+)
+
+// Reducer combines chunk results, given in chunk order, into a job result
+type Reducer interface {
+	Reduce(results [][]byte) ([]byte, error)
+}
+
+// ReducerFunc adapts a function to the Reducer interface
+type ReducerFunc func(results [][]byte) ([]byte, error)
+
+// Reduce implements Reducer
+func (f ReducerFunc) Reduce(results [][]byte) ([]byte, error) {
+	return f(results)
+}
+
+// WASMRuntime runs a function exported by a job's WASM module. The compute
+// manager has no WASM engine of its own; the host provides one.
+type WASMRuntime interface {
+	// Reduce calls the module's "reduce" export with the chunk results
+	Reduce(module []byte, results [][]byte) ([]byte, error)
+	// PostProcess calls the module's "postprocess" export with a job result
+	PostProcess(module []byte, result []byte) ([]byte, error)
+}
+
+var (
+	reducers = map[string]Reducer{
+		ReducerConcat:     ReducerFunc(concatResults),
+		ReducerMatrixRows: ReducerFunc(MergeMatrixResults),
+		ReducerSumBlocks:  ReducerFunc(sumMatrixBlocks),
+	}
+	reducersMu sync.RWMutex
+)
+
+// RegisterReducer adds or replaces a named reducer
+func RegisterReducer(name string, r Reducer) {
+	reducersMu.Lock()
+	defer reducersMu.Unlock()
+	reducers[name] = r
+}
+
+// GetReducer returns the reducer registered under name
+func GetReducer(name string) (Reducer, error) {
+	reducersMu.RLock()
+	defer reducersMu.RUnlock()
+	r, ok := reducers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown reducer %q", name)
+	}
+	return r, nil
+}
+
+// ReducerNames lists the registered reducers and the WASM reducer, sorted
+func ReducerNames() []string {
+	reducersMu.RLock()
+	defer reducersMu.RUnlock()
+	names := make([]string, 0, len(reducers)+1)
+	for name := range reducers {
+		names = append(names, name)
+	}
+	names = append(names, ReducerWASM)
+	sort.Strings(names)
+	return names
+}
+
+// SetWASMRuntime sets the engine used by jobs that reduce with their own
+// WASM module
+func (m *Manager) SetWASMRuntime(runtime WASMRuntime) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.wasmRuntime = runtime
+}
+
+// jobReducer resolves the reducer for a job: the one it names, otherwise
+// the default of its split strategy. Caller must hold m.mu.
+func (m *Manager) jobReducer(manifest *JobManifest) (Reducer, error) {
+	name := manifest.Reducer
+	if name == "" {
+		strategy, err := GetSplitStrategy(manifest.SplitStrategy)
+		if err != nil {
+			return nil, err
+		}
+		name = strategy.Reducer()
+	}
+
+	if name != ReducerWASM {
+		return GetReducer(name)
+	}
+	if m.wasmRuntime == nil {
+		return nil, fmt.Errorf("wasm reducer requested but no WASM runtime is configured")
+	}
+	if len(manifest.WASMModule) == 0 {
+		return nil, fmt.Errorf("wasm reducer requested but the job has no WASM module")
+	}
+	runtime, module := m.wasmRuntime, manifest.WASMModule
+	return ReducerFunc(func(results [][]byte) ([]byte, error) {
+		return runtime.Reduce(module, results)
+	}), nil
+}
+
+// concatResults joins results whose chunks were independent byte ranges
+func concatResults(results [][]byte) ([]byte, error) {
+	return bytes.Join(results, nil), nil
+}
+
+// sumMatrixBlocks adds partial products element-wise, e.g. the results of
+// splitting A by columns and B by rows
+func sumMatrixBlocks(results [][]byte) ([]byte, error) {
+	if len(results) == 0 {
+		return nil, fmt.Errorf("no results to sum")
+	}
+	if len(results[0]) < 8 {
+		return nil, fmt.Errorf("result 0 too short")
+	}
+	rows := binary.BigEndian.Uint32(results[0][0:4])
+	cols := binary.BigEndian.Uint32(results[0][4:8])
+	size := 8 + int(rows)*int(cols)*8
+
+	sums := make([]float64, int(rows)*int(cols))
+	for i, r := range results {
+		if len(r) != size || !bytes.Equal(r[0:8], results[0][0:8]) {
+			return nil, fmt.Errorf("result %d does not match the %dx%d shape of result 0", i, rows, cols)
+		}
+		for j := range sums {
+			sums[j] += float64frombits(binary.BigEndian.Uint64(r[8+j*8:]))
+		}
+	}
+
+	out := make([]byte, 8, size)
+	copy(out, results[0][0:8])
+	for _, v := range sums {
+		out = binary.BigEndian.AppendUint64(out, float64bits(v))
+	}
+	return out, nil
+}
@@ -0,0 +1,271 @@
+package compute
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ReplayEntry records one execution of a chunk in a job's replay log.
+// ResultHash is computed by this node from the bytes the worker returned,
+// not taken from the worker's own report.
+type ReplayEntry struct {
+	ChunkIndex uint32        `json:"chunkIndex"`
+	WorkerID   string        `json:"workerId"` // "local" for this node
+	InputHash  string        `json:"inputHash"`
+	ResultHash string        `json:"resultHash,omitempty"` // Empty when no result came back
+	Accepted   bool          `json:"accepted"`             // The result went into the job's output
+	StartedAt  time.Time     `json:"startedAt"`
+	Duration   time.Duration `json:"duration"`
+	Error      string        `json:"error,omitempty"`
+}
+
+// ReplayLog is the execution record of a job submitted with RecordReplay
+type ReplayLog struct {
+	JobID   string        `json:"jobId"`
+	Created time.Time     `json:"created"`
+	Entries []ReplayEntry `json:"entries"` // In the order executions finished
+}
+
+// ReplayVerdict compares a recorded result with a local re-execution of
+// its chunk. Match is false, with Error set, when the chunk could not be
+// re-executed.
+type ReplayVerdict struct {
+	ChunkIndex   uint32 `json:"chunkIndex"`
+	WorkerID     string `json:"workerId"`
+	Accepted     bool   `json:"accepted"`
+	RecordedHash string `json:"recordedHash"`
+	LocalHash    string `json:"localHash"`
+	Match        bool   `json:"match"`
+	Error        string `json:"error,omitempty"`
+}
+
+// replayStore keeps replay logs and the chunk inputs needed to re-run them.
+// With a directory, each job gets a subdirectory holding replay.json and
+// one chunk-<index>.bin per chunk; without one, everything stays in memory.
+type replayStore struct {
+	dir    string
+	logs   map[string]*ReplayLog
+	inputs map[string]map[uint32][]byte // In-memory stores only
+	mu     sync.Mutex
+}
+
+func newReplayStore(dir string) *replayStore {
+	return &replayStore{
+		dir:    dir,
+		logs:   make(map[string]*ReplayLog),
+		inputs: make(map[string]map[uint32][]byte),
+	}
+}
+
+// jobDir names a job's directory by a hash of its ID, since job IDs come
+// from clients
+func (s *replayStore) jobDir(jobID string) string {
+	return filepath.Join(s.dir, hashData([]byte(jobID)))
+}
+
+// record adds an entry to a job's log, keeping the chunk input the first
+// time the chunk is seen
+func (s *replayStore) record(jobID string, e ReplayEntry, input []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rl, err := s.getLocked(jobID)
+	if err != nil {
+		return err
+	}
+	if rl == nil {
+		rl = &ReplayLog{JobID: jobID, Created: time.Now()}
+		s.logs[jobID] = rl
+	}
+	seen := slices.ContainsFunc(rl.Entries, func(old ReplayEntry) bool { return old.ChunkIndex == e.ChunkIndex })
+	rl.Entries = append(rl.Entries, e)
+
+	if s.dir == "" {
+		if !seen {
+			if s.inputs[jobID] == nil {
+				s.inputs[jobID] = make(map[uint32][]byte)
+			}
+			s.inputs[jobID][e.ChunkIndex] = input
+		}
+		return nil
+	}
+	dir := s.jobDir(jobID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create replay directory: %w", err)
+	}
+	if !seen {
+		if err := writeFileAtomic(filepath.Join(dir, chunkInputFile(e.ChunkIndex)), input); err != nil {
+			return err
+		}
+	}
+	data, err := json.MarshalIndent(rl, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize replay log: %w", err)
+	}
+	return writeFileAtomic(filepath.Join(dir, "replay.json"), data)
+}
+
+// get returns a copy of a job's log, or nil if it has none
+func (s *replayStore) get(jobID string) (*ReplayLog, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rl, err := s.getLocked(jobID)
+	if rl == nil || err != nil {
+		return nil, err
+	}
+	copied := *rl
+	copied.Entries = slices.Clone(rl.Entries)
+	return &copied, nil
+}
+
+// getLocked returns a job's log, loading it from disk if it was recorded
+// by an earlier run
+func (s *replayStore) getLocked(jobID string) (*ReplayLog, error) {
+	if rl, ok := s.logs[jobID]; ok || s.dir == "" {
+		return rl, nil
+	}
+	data, err := os.ReadFile(filepath.Join(s.jobDir(jobID), "replay.json"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var rl ReplayLog
+	if err := json.Unmarshal(data, &rl); err != nil {
+		return nil, fmt.Errorf("failed to parse replay log: %w", err)
+	}
+	s.logs[jobID] = &rl
+	return &rl, nil
+}
+
+// input returns the recorded input of a chunk
+func (s *replayStore) input(jobID string, chunkIndex uint32) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dir == "" {
+		input, ok := s.inputs[jobID][chunkIndex]
+		if !ok {
+			return nil, fmt.Errorf("no input recorded for chunk %d", chunkIndex)
+		}
+		return input, nil
+	}
+	return os.ReadFile(filepath.Join(s.jobDir(jobID), chunkInputFile(chunkIndex)))
+}
+
+func chunkInputFile(chunkIndex uint32) string {
+	return fmt.Sprintf("chunk-%d.bin", chunkIndex)
+}
+
+// writeFileAtomic replaces path with data via a temporary file
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", filepath.Base(path), err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
+
+// recordReplay adds a chunk execution to the job's replay log if the job
+// asked for one
+func (m *Manager) recordReplay(jobID string, manifest *JobManifest, input []byte, e ReplayEntry) {
+	if !manifest.RecordReplay {
+		return
+	}
+	e.InputHash = hashData(input)
+	if err := m.replay.record(jobID, e, input); err != nil {
+		log.Printf("⚠️  [COMPUTE] Failed to record replay of job %s chunk %d: %v", jobID, e.ChunkIndex, err)
+	}
+}
+
+// GetReplayLog returns the replay log of a job submitted with RecordReplay
+func (m *Manager) GetReplayLog(jobID string) (*ReplayLog, error) {
+	rl, err := m.replay.get(jobID)
+	if err != nil {
+		return nil, err
+	}
+	if rl == nil {
+		return nil, fmt.Errorf("no replay log for job %s", jobID)
+	}
+	return rl, nil
+}
+
+// ReplayJob re-executes recorded chunks of a job locally and compares the
+// outcome with every result workers returned for them. An empty chunks
+// list replays each chunk that has a recorded result.
+func (m *Manager) ReplayJob(jobID string, chunks []uint32) ([]ReplayVerdict, error) {
+	rl, err := m.GetReplayLog(jobID)
+	if err != nil {
+		return nil, err
+	}
+	byChunk := make(map[uint32][]ReplayEntry)
+	for _, e := range rl.Entries {
+		byChunk[e.ChunkIndex] = append(byChunk[e.ChunkIndex], e)
+	}
+	if len(chunks) == 0 {
+		for index, entries := range byChunk {
+			if slices.ContainsFunc(entries, func(e ReplayEntry) bool { return e.ResultHash != "" }) {
+				chunks = append(chunks, index)
+			}
+		}
+		slices.Sort(chunks)
+	}
+
+	var verdicts []ReplayVerdict
+	for _, index := range chunks {
+		entries, ok := byChunk[index]
+		if !ok {
+			verdicts = append(verdicts, ReplayVerdict{ChunkIndex: index, Error: "chunk not recorded"})
+			continue
+		}
+		localHash, err := m.replayChunk(jobID, index, entries[0].InputHash)
+		recorded := false
+		for _, e := range entries {
+			if e.ResultHash == "" {
+				continue
+			}
+			recorded = true
+			v := ReplayVerdict{ChunkIndex: index, WorkerID: e.WorkerID, Accepted: e.Accepted,
+				RecordedHash: e.ResultHash, LocalHash: localHash}
+			if err != nil {
+				v.Error = err.Error()
+			} else {
+				v.Match = localHash == e.ResultHash
+			}
+			verdicts = append(verdicts, v)
+		}
+		if !recorded {
+			v := ReplayVerdict{ChunkIndex: index, LocalHash: localHash, Error: "no result recorded"}
+			if err != nil {
+				v.Error = err.Error()
+			}
+			verdicts = append(verdicts, v)
+		}
+	}
+	return verdicts, nil
+}
+
+// replayChunk re-executes a recorded chunk and returns its result hash
+func (m *Manager) replayChunk(jobID string, chunkIndex uint32, inputHash string) (string, error) {
+	input, err := m.replay.input(jobID, chunkIndex)
+	if err != nil {
+		return "", err
+	}
+	if hashData(input) != inputHash {
+		return "", fmt.Errorf("recorded input of chunk %d does not match its hash", chunkIndex)
+	}
+	result, err := executeMatrixBlockMultiply(input)
+	if err != nil {
+		return "", fmt.Errorf("local execution failed: %w", err)
+	}
+	return hashData(result), nil
+}
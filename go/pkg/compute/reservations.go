@@ -0,0 +1,271 @@
+package compute
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+// Reservation limits
+const (
+	MaxReservationWindow = 24 * time.Hour     // Longest window one reservation may cover
+	MaxReservationLead   = 7 * 24 * time.Hour // How far ahead a window may start
+)
+
+// Reservation is capacity a worker has promised a submitter for a time
+// window. The worker turns away competing tasks that would eat into it.
+type Reservation struct {
+	ID       string    `json:"id"`
+	WorkerID string    `json:"workerId"`
+	Holder   string    `json:"holder"`          // Submitter the capacity is held for
+	JobID    string    `json:"jobId,omitempty"` // Job the capacity is for; empty = any scheduled job of the holder
+	CPUCores uint32    `json:"cpuCores"`
+	RAMMB    uint64    `json:"ramMb"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+}
+
+// Active reports whether the reservation covers t
+func (r *Reservation) Active(t time.Time) bool {
+	return !t.Before(r.Start) && t.Before(r.End)
+}
+
+// overlaps reports whether the reservation intersects [start, end)
+func (r *Reservation) overlaps(start, end time.Time) bool {
+	return r.Start.Before(end) && start.Before(r.End)
+}
+
+// GrantReservation reserves this node's capacity for req.Holder. It fails
+// if, at any point in the window, the request and the reservations already
+// granted would exceed the node's cores or RAM.
+func (m *Manager) GrantReservation(req Reservation) (Reservation, error) {
+	now := time.Now()
+	switch {
+	case req.CPUCores == 0 && req.RAMMB == 0:
+		return Reservation{}, fmt.Errorf("reservation must request cores or RAM")
+	case !req.End.After(req.Start):
+		return Reservation{}, fmt.Errorf("reservation window ends before it starts")
+	case !req.End.After(now):
+		return Reservation{}, fmt.Errorf("reservation window is in the past")
+	case req.End.Sub(req.Start) > MaxReservationWindow:
+		return Reservation{}, fmt.Errorf("reservation window exceeds %v", MaxReservationWindow)
+	case req.Start.Sub(now) > MaxReservationLead:
+		return Reservation{}, fmt.Errorf("reservation starts more than %v ahead", MaxReservationLead)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pruneReservationsLocked(now)
+
+	cores, ram := m.capacity.CPUCores, m.capacity.TotalRAMMB
+	if ram == 0 {
+		ram = m.capacity.RAMMB
+	}
+	peakCores, peakRAM := m.peakReservedLocked(req.Start, req.End)
+	if peakCores+req.CPUCores > cores {
+		return Reservation{}, fmt.Errorf("only %d of %d cores free in that window", cores-min(peakCores, cores), cores)
+	}
+	if peakRAM+req.RAMMB > ram {
+		return Reservation{}, fmt.Errorf("only %dMB of %dMB RAM free in that window", ram-min(peakRAM, ram), ram)
+	}
+
+	req.ID = generateReservationID()
+	r := req
+	m.granted[r.ID] = &r
+	log.Printf("📅 [COMPUTE] Reserved %d cores / %dMB for %s from %s to %s (%s)",
+		r.CPUCores, r.RAMMB, truncateID(r.Holder, 12), r.Start.Format(time.RFC3339), r.End.Format(time.RFC3339), r.ID)
+	return r, nil
+}
+
+// peakReservedLocked returns the most cores and RAM granted at any instant
+// in [start, end). Usage only rises where a reservation starts, so those
+// instants are the only ones checked. Caller must hold m.mu.
+func (m *Manager) peakReservedLocked(start, end time.Time) (cores uint32, ram uint64) {
+	points := []time.Time{start}
+	for _, r := range m.granted {
+		if r.overlaps(start, end) && r.Start.After(start) {
+			points = append(points, r.Start)
+		}
+	}
+	for _, t := range points {
+		var c uint32
+		var mb uint64
+		for _, r := range m.granted {
+			if r.Active(t) {
+				c += r.CPUCores
+				mb += r.RAMMB
+			}
+		}
+		cores, ram = max(cores, c), max(ram, mb)
+	}
+	return cores, ram
+}
+
+// ReleaseReservation cancels a granted reservation. Only its holder may
+// release it; an empty holder is the local operator.
+func (m *Manager) ReleaseReservation(id, holder string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, exists := m.granted[id]
+	if !exists {
+		return fmt.Errorf("reservation %s not found", id)
+	}
+	if holder != "" && r.Holder != holder {
+		return fmt.Errorf("reservation %s is not held by %s", id, truncateID(holder, 12))
+	}
+	delete(m.granted, id)
+	log.Printf("📅 [COMPUTE] Released reservation %s", id)
+	return nil
+}
+
+// GrantedReservations returns the unexpired reservations this node has
+// granted, by start time
+func (m *Manager) GrantedReservations() []Reservation {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pruneReservationsLocked(time.Now())
+	return sortedReservations(m.granted)
+}
+
+// AddHeldReservation records a reservation a worker confirmed for this
+// node's jobs
+func (m *Manager) AddHeldReservation(r Reservation) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.held[r.ID] = &r
+}
+
+// RemoveHeldReservation forgets a reservation this node held
+func (m *Manager) RemoveHeldReservation(id string) (Reservation, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, exists := m.held[id]
+	if !exists {
+		return Reservation{}, false
+	}
+	delete(m.held, id)
+	return *r, true
+}
+
+// HeldReservations returns the unexpired reservations workers have
+// confirmed for this node, by start time
+func (m *Manager) HeldReservations() []Reservation {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pruneReservationsLocked(time.Now())
+	return sortedReservations(m.held)
+}
+
+// admitTaskLocked turns away a task from upstreamID when the cores it could
+// use are reserved for other submitters right now. Caller must hold m.mu.
+func (m *Manager) admitTaskLocked(upstreamID string, now time.Time) error {
+	var reserved uint32
+	for _, r := range m.granted {
+		if r.Holder != upstreamID && r.Active(now) {
+			reserved += r.CPUCores
+		}
+	}
+	if reserved == 0 {
+		return nil
+	}
+	free := int(m.capacity.CPUCores) - int(reserved)
+	if m.activeTasks >= free {
+		return fmt.Errorf("capacity reserved: %d of %d cores held for other submitters, %d tasks running",
+			reserved, m.capacity.CPUCores, m.activeTasks)
+	}
+	return nil
+}
+
+// reservedWorkersLocked returns the workers holding capacity for a job at
+// t, each repeated once per reserved core so that round-robin assignment
+// follows the reserved capacity. Reservations bound to the job always
+// apply; unbound ones only apply to scheduled jobs. Workers not in
+// available are skipped unless available is empty. Caller must hold m.mu.
+func (m *Manager) reservedWorkersLocked(manifest *JobManifest, t time.Time, available []string) []string {
+	online := make(map[string]bool, len(available))
+	for _, w := range available {
+		online[w] = true
+	}
+
+	var workers []string
+	for _, r := range sortedReservations(m.held) {
+		if !r.Active(t) || (r.JobID != manifest.JobID && (r.JobID != "" || manifest.StartAt.IsZero())) {
+			continue
+		}
+		if len(available) > 0 && !online[r.WorkerID] {
+			continue
+		}
+		for range max(r.CPUCores, 1) {
+			workers = append(workers, r.WorkerID)
+		}
+	}
+	return workers
+}
+
+// awaitStart holds a scheduled job until its start time. Returns false if
+// the job was cancelled or the manager closed meanwhile.
+func (m *Manager) awaitStart(jobID string) bool {
+	m.mu.RLock()
+	state, exists := m.jobs[jobID]
+	var startAt time.Time
+	if exists {
+		startAt = state.manifest.StartAt
+	}
+	m.mu.RUnlock()
+	if !exists {
+		return false
+	}
+
+	if wait := time.Until(startAt); wait > 0 {
+		log.Printf("⏰ [COMPUTE] Job %s scheduled for %s", jobID, startAt.Format(time.RFC3339))
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-m.ctx.Done():
+			return false
+		case <-timer.C:
+		}
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	state, exists = m.jobs[jobID]
+	return exists && state.status != TaskCancelled
+}
+
+// pruneReservationsLocked drops reservations whose window has passed.
+// Caller must hold m.mu.
+func (m *Manager) pruneReservationsLocked(now time.Time) {
+	for _, book := range []map[string]*Reservation{m.granted, m.held} {
+		for id, r := range book {
+			if !now.Before(r.End) {
+				delete(book, id)
+			}
+		}
+	}
+}
+
+// sortedReservations copies reservations ordered by start time, then ID
+func sortedReservations(book map[string]*Reservation) []Reservation {
+	out := make([]Reservation, 0, len(book))
+	for _, r := range book {
+		out = append(out, *r)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].Start.Equal(out[j].Start) {
+			return out[i].Start.Before(out[j].Start)
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
+
+// generateReservationID returns a random reservation ID
+func generateReservationID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return "rsv-" + hex.EncodeToString(b)
+}
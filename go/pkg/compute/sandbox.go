@@ -0,0 +1,223 @@
+package compute
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// Task isolation modes
+const (
+	IsolationNone    = "none"    // Peer tasks run in the node's process
+	IsolationProcess = "process" // Each peer task runs in a child process under rlimits and seccomp
+)
+
+// DefaultSandboxMemoryMB caps a sandboxed task's memory when the worker
+// policy sets no per-task limit
+const DefaultSandboxMemoryMB = 256
+
+// sandboxEnv marks a process started to run one task in isolation
+const sandboxEnv = "PANGEA_COMPUTE_SANDBOX"
+
+// maxSandboxStderr is how much of a child's error output is kept
+const maxSandboxStderr = 4096
+
+// ErrSandboxKilled means an isolated task was killed for exceeding its
+// limits or crashing, rather than returning an error
+var ErrSandboxKilled = errors.New("sandboxed task killed")
+
+// SandboxConfig controls how peer tasks are isolated from the node
+type SandboxConfig struct {
+	// Isolation is IsolationNone or IsolationProcess
+	Isolation string
+	// Executable is re-run as the sandbox child (empty = this binary). It
+	// must call RunSandboxIfChild before anything else.
+	Executable string
+	// DefaultMemoryMB caps task memory when the worker policy does not
+	// (0 = DefaultSandboxMemoryMB)
+	DefaultMemoryMB uint64
+}
+
+// WASMTaskRuntime is a WASMRuntime that can also run a task's own module.
+// Tasks carrying a WASM module run through it when the host provides one.
+type WASMTaskRuntime interface {
+	WASMRuntime
+	// Execute calls the module's "execute" export on input, with the
+	// module's linear memory capped at maxMemoryBytes. The manager only
+	// passes modules whose declared memory maximum is within the cap.
+	Execute(ctx context.Context, module []byte, input []byte, maxMemoryBytes uint64) ([]byte, error)
+}
+
+// sandboxLimits are the limits a task runs under
+type sandboxLimits struct {
+	CPUPercent uint32
+	MemoryMB   uint64
+	CPUSeconds uint64 // 0 = no CPU time limit
+}
+
+// taskLimits turns a worker policy snapshot into sandbox limits
+func (m *Manager) taskLimits(policy WorkerPolicy) sandboxLimits {
+	limits := sandboxLimits{CPUPercent: policy.MaxTaskCPUPercent, MemoryMB: policy.MaxTaskMemoryMB}
+	if limits.MemoryMB == 0 {
+		limits.MemoryMB = m.config.Sandbox.DefaultMemoryMB
+	}
+	if limits.MemoryMB == 0 {
+		limits.MemoryMB = DefaultSandboxMemoryMB
+	}
+	if policy.MaxTaskTime > 0 {
+		limits.CPUSeconds = uint64((policy.MaxTaskTime + time.Second - 1) / time.Second)
+	}
+	return limits
+}
+
+// runTask computes a peer task's result under the configured isolation:
+// through the host's WASM runtime if the task brings a module and the
+// runtime can run it, in a child process, or in this process
+func (m *Manager) runTask(ctx context.Context, task *ComputeTask, limits sandboxLimits) ([]byte, error) {
+	m.mu.RLock()
+	wasm, hasWASM := m.wasmRuntime.(WASMTaskRuntime)
+	m.mu.RUnlock()
+	if hasWASM && len(task.WASMModule) > 0 {
+		// The runtime is asked to cap memory too, but the module's own
+		// limits are what hold it to the budget
+		if err := checkWASMMemory(task.WASMModule, limits.MemoryMB<<20); err != nil {
+			return nil, err
+		}
+		return wasm.Execute(ctx, task.WASMModule, task.InputData, limits.MemoryMB<<20)
+	}
+
+	switch m.config.Sandbox.Isolation {
+	case IsolationProcess:
+		return m.runIsolated(ctx, task.InputData, limits)
+	case "", IsolationNone:
+		return multiplyMatrixBlocks(ctx, task.InputData, limits.CPUPercent)
+	default:
+		return nil, fmt.Errorf("unknown task isolation %q", m.config.Sandbox.Isolation)
+	}
+}
+
+// runIsolated runs the matrix kernel in a child process. The child applies
+// its limits before reading the input, so a task that blows them takes
+// down only the child.
+func (m *Manager) runIsolated(ctx context.Context, input []byte, limits sandboxLimits) ([]byte, error) {
+	exe := m.config.Sandbox.Executable
+	if exe == "" {
+		var err error
+		if exe, err = os.Executable(); err != nil {
+			return nil, fmt.Errorf("failed to find sandbox executable: %w", err)
+		}
+	}
+
+	header := make([]byte, 20)
+	binary.BigEndian.PutUint32(header[0:], limits.CPUPercent)
+	binary.BigEndian.PutUint64(header[4:], limits.MemoryMB)
+	binary.BigEndian.PutUint64(header[12:], limits.CPUSeconds)
+
+	var stdout bytes.Buffer
+	stderr := &headBuffer{max: maxSandboxStderr}
+	cmd := exec.CommandContext(ctx, exe)
+	// Without tracebacks a crash leaves just its reason on stderr
+	cmd.Env = append(os.Environ(), sandboxEnv+"=1", "GOTRACEBACK=none")
+	cmd.Stdin = io.MultiReader(bytes.NewReader(header), bytes.NewReader(input))
+	cmd.Stdout = &stdout
+	cmd.Stderr = stderr
+	cmd.SysProcAttr = sandboxProcAttr()
+	cmd.WaitDelay = time.Second
+
+	err := cmd.Run()
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		msg := strings.TrimSpace(stderr.String())
+		if exitErr.ExitCode() == sandboxTaskFailed && msg != "" {
+			return nil, errors.New(msg)
+		}
+		msg, _, _ = strings.Cut(msg, "\n")
+		return nil, fmt.Errorf("%w: %v %s", ErrSandboxKilled, exitErr, msg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to run sandboxed task: %w", err)
+	}
+	return stdout.Bytes(), nil
+}
+
+// sandboxTaskFailed is the exit code of a child whose task returned an
+// error; the error is its only output on stderr
+const sandboxTaskFailed = 3
+
+// RunSandboxIfChild runs one task and exits when this process was started
+// as a sandbox child, and returns otherwise. Binaries that run tasks with
+// IsolationProcess call it first thing in main.
+func RunSandboxIfChild() {
+	if os.Getenv(sandboxEnv) == "" {
+		return
+	}
+	os.Exit(runSandboxChild(os.Stdin, os.Stdout, os.Stderr))
+}
+
+// runSandboxChild locks itself down, then reads the limits header and the
+// task input and writes the result
+func runSandboxChild(stdin io.Reader, stdout, stderr io.Writer) int {
+	log.SetOutput(io.Discard)
+
+	header := make([]byte, 20)
+	if _, err := io.ReadFull(stdin, header); err != nil {
+		fmt.Fprintf(stderr, "sandbox: failed to read limits: %v", err)
+		return 1
+	}
+	limits := sandboxLimits{
+		CPUPercent: binary.BigEndian.Uint32(header[0:]),
+		MemoryMB:   binary.BigEndian.Uint64(header[4:]),
+		CPUSeconds: binary.BigEndian.Uint64(header[12:]),
+	}
+	if limits.MemoryMB > 0 {
+		debug.SetMemoryLimit(int64(limits.MemoryMB << 20))
+	}
+	if err := applySandboxLimits(limits); err != nil {
+		fmt.Fprintf(stderr, "sandbox: %v", err)
+		return 1
+	}
+
+	input, err := io.ReadAll(stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "sandbox: failed to read task: %v", err)
+		return 1
+	}
+	result, err := multiplyMatrixBlocks(context.Background(), input, limits.CPUPercent)
+	if err != nil {
+		fmt.Fprint(stderr, err)
+		return sandboxTaskFailed
+	}
+	if _, err := stdout.Write(result); err != nil {
+		return 1
+	}
+	return 0
+}
+
+// headBuffer keeps the first max bytes written to it
+type headBuffer struct {
+	max int
+	buf []byte
+}
+
+func (b *headBuffer) Write(p []byte) (int, error) {
+	if room := b.max - len(b.buf); room > 0 {
+		b.buf = append(b.buf, p[:min(room, len(p))]...)
+	}
+	return len(p), nil
+}
+
+func (b *headBuffer) String() string {
+	return string(b.buf)
+}
@@ -0,0 +1,124 @@
+//go:build linux
+
+package compute
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// sandboxHeadroomBytes is address space a sandboxed task gets beyond its
+// memory budget. The Go runtime maps heap in large arenas and thread
+// stacks on demand, so a tight RLIMIT_AS would kill the child before the
+// task used its budget; the soft memory limit keeps the heap near budget.
+const sandboxHeadroomBytes = 256 << 20
+
+// deniedSyscalls are refused with EPERM in sandboxed tasks: networking,
+// running programs, opening files and reaching into other processes or
+// the kernel
+var deniedSyscalls = append([]uintptr{
+	unix.SYS_SOCKET, unix.SYS_SOCKETPAIR, unix.SYS_CONNECT, unix.SYS_BIND, unix.SYS_LISTEN, unix.SYS_ACCEPT4,
+	unix.SYS_EXECVE, unix.SYS_EXECVEAT,
+	unix.SYS_OPENAT, unix.SYS_OPENAT2,
+	unix.SYS_PTRACE, unix.SYS_PROCESS_VM_READV, unix.SYS_PROCESS_VM_WRITEV,
+	unix.SYS_MOUNT, unix.SYS_UMOUNT2, unix.SYS_PIVOT_ROOT, unix.SYS_CHROOT, unix.SYS_SETNS, unix.SYS_UNSHARE,
+	unix.SYS_BPF, unix.SYS_PERF_EVENT_OPEN, unix.SYS_USERFAULTFD, unix.SYS_IO_URING_SETUP,
+	unix.SYS_KEXEC_LOAD, unix.SYS_INIT_MODULE, unix.SYS_FINIT_MODULE, unix.SYS_DELETE_MODULE, unix.SYS_REBOOT,
+	unix.SYS_KEYCTL, unix.SYS_ADD_KEY, unix.SYS_REQUEST_KEY, unix.SYS_SWAPON, unix.SYS_SWAPOFF,
+}, archDeniedSyscalls...)
+
+// sandboxProcAttr kills the child if the node dies first
+func sandboxProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Pdeathsig: syscall.SIGKILL}
+}
+
+// applySandboxLimits caps the child's address space, CPU time, files and
+// core dumps, then installs the seccomp filter
+func applySandboxLimits(limits sandboxLimits) error {
+	if limits.MemoryMB > 0 {
+		mapped, err := mappedBytes()
+		if err != nil {
+			return err
+		}
+		max := mapped + limits.MemoryMB<<20 + sandboxHeadroomBytes
+		if err := setrlimit(unix.RLIMIT_AS, max); err != nil {
+			return err
+		}
+	}
+	if limits.CPUSeconds > 0 {
+		if err := setrlimit(unix.RLIMIT_CPU, limits.CPUSeconds); err != nil {
+			return err
+		}
+	}
+	if err := setrlimit(unix.RLIMIT_FSIZE, 0); err != nil {
+		return err
+	}
+	if err := setrlimit(unix.RLIMIT_CORE, 0); err != nil {
+		return err
+	}
+	return installSeccomp()
+}
+
+func setrlimit(resource int, max uint64) error {
+	if err := unix.Setrlimit(resource, &unix.Rlimit{Cur: max, Max: max}); err != nil {
+		return fmt.Errorf("failed to set rlimit %d: %w", resource, err)
+	}
+	return nil
+}
+
+// mappedBytes is the process's current virtual memory size
+func mappedBytes() (uint64, error) {
+	data, err := os.ReadFile("/proc/self/statm")
+	if err != nil {
+		return 0, fmt.Errorf("failed to read memory size: %w", err)
+	}
+	pages, err := strconv.ParseUint(strings.Fields(string(data))[0], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse memory size: %w", err)
+	}
+	return pages * uint64(os.Getpagesize()), nil
+}
+
+// installSeccomp refuses deniedSyscalls on every thread of the process.
+// Architectures without a known audit arch run under rlimits only.
+func installSeccomp() error {
+	if seccompArch == 0 {
+		return nil
+	}
+
+	deny := uint32(unix.SECCOMP_RET_ERRNO | uint32(unix.EPERM))
+	filter := []unix.SockFilter{
+		// Syscall numbers only mean something for the expected arch
+		{Code: unix.BPF_LD | unix.BPF_W | unix.BPF_ABS, K: 4},
+		{Code: unix.BPF_JMP | unix.BPF_JEQ | unix.BPF_K, Jt: 1, K: seccompArch},
+		{Code: unix.BPF_RET | unix.BPF_K, K: unix.SECCOMP_RET_KILL_PROCESS},
+		{Code: unix.BPF_LD | unix.BPF_W | unix.BPF_ABS, K: 0},
+	}
+	if seccompForeignABI != 0 {
+		filter = append(filter,
+			unix.SockFilter{Code: unix.BPF_JMP | unix.BPF_JSET | unix.BPF_K, Jf: 1, K: seccompForeignABI},
+			unix.SockFilter{Code: unix.BPF_RET | unix.BPF_K, K: deny})
+	}
+	for _, nr := range deniedSyscalls {
+		filter = append(filter,
+			unix.SockFilter{Code: unix.BPF_JMP | unix.BPF_JEQ | unix.BPF_K, Jf: 1, K: uint32(nr)},
+			unix.SockFilter{Code: unix.BPF_RET | unix.BPF_K, K: deny})
+	}
+	filter = append(filter, unix.SockFilter{Code: unix.BPF_RET | unix.BPF_K, K: unix.SECCOMP_RET_ALLOW})
+
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("failed to set no_new_privs: %w", err)
+	}
+	prog := unix.SockFprog{Len: uint16(len(filter)), Filter: &filter[0]}
+	if _, _, errno := unix.Syscall(unix.SYS_SECCOMP, unix.SECCOMP_SET_MODE_FILTER,
+		unix.SECCOMP_FILTER_FLAG_TSYNC, uintptr(unsafe.Pointer(&prog))); errno != 0 {
+		return fmt.Errorf("failed to install seccomp filter: %w", errno)
+	}
+	return nil
+}
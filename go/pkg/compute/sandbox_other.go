@@ -0,0 +1,19 @@
+//go:build !linux
+
+package compute
+
+import "syscall"
+
+// seccompArch is 0 where no seccomp filter is installed
+const seccompArch = 0
+
+// sandboxProcAttr has nothing to add outside Linux
+func sandboxProcAttr() *syscall.SysProcAttr {
+	return nil
+}
+
+// applySandboxLimits relies on the soft memory limit alone outside Linux;
+// the child process still keeps a crashing task away from the node
+func applySandboxLimits(limits sandboxLimits) error {
+	return nil
+}
@@ -2,6 +2,7 @@
 package compute
 
 import (
+	"context"
 	"sort"
 	"sync"
 	"time"
@@ -9,9 +10,12 @@ import (
 
 // Scheduler handles task scheduling and load balancing
 type Scheduler struct {
-	manager *Manager
-	queue   []*scheduledTask
-	mu      sync.Mutex
+	manager     *Manager
+	queue       []*scheduledTask
+	inFlight    map[string]int // jobID -> chunks handed out and not yet done
+	perJobLimit int
+	mu          sync.Mutex
+	cond        *sync.Cond
 }
 
 // scheduledTask represents a task in the scheduling queue
@@ -25,10 +29,14 @@ type scheduledTask struct {
 
 // NewScheduler creates a new scheduler
 func NewScheduler(manager *Manager) *Scheduler {
-	return &Scheduler{
-		manager: manager,
-		queue:   make([]*scheduledTask, 0),
+	s := &Scheduler{
+		manager:     manager,
+		queue:       make([]*scheduledTask, 0),
+		inFlight:    make(map[string]int),
+		perJobLimit: manager.config.MaxChunksPerJob,
 	}
+	s.cond = sync.NewCond(&s.mu)
+	return s
 }
 
 // Schedule adds a task to the scheduling queue
@@ -46,6 +54,7 @@ func (s *Scheduler) Schedule(task *ComputeTask, priority int) {
 
 	s.queue = append(s.queue, st)
 	s.sortQueue()
+	s.cond.Signal()
 }
 
 // GetNextTask returns the next task to execute
@@ -53,16 +62,69 @@ func (s *Scheduler) GetNextTask() *ComputeTask {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if len(s.queue) == 0 {
+	st := s.popEligible()
+	if st == nil {
 		return nil
 	}
+	return st.task
+}
 
-	// Get highest priority task
-	st := s.queue[0]
-	s.queue = s.queue[1:]
-	st.attempts++
+// WaitNextTask blocks until a task is eligible to run or ctx is done.
+// Returns nil once ctx is cancelled.
+func (s *Scheduler) WaitNextTask(ctx context.Context) *ComputeTask {
+	// Wake waiters when ctx is cancelled so the loop below observes it
+	stop := context.AfterFunc(ctx, s.Wake)
+	defer stop()
 
-	return st.task
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for {
+		if ctx.Err() != nil {
+			return nil
+		}
+		if st := s.popEligible(); st != nil {
+			return st.task
+		}
+		s.cond.Wait()
+	}
+}
+
+// TaskDone releases the per-job slot held by a task returned from the queue
+func (s *Scheduler) TaskDone(task *ComputeTask) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.inFlight[task.ParentJobID] > 0 {
+		s.inFlight[task.ParentJobID]--
+	}
+	if s.inFlight[task.ParentJobID] == 0 {
+		delete(s.inFlight, task.ParentJobID)
+	}
+	s.cond.Broadcast()
+}
+
+// Wake unblocks all goroutines waiting in WaitNextTask
+func (s *Scheduler) Wake() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cond.Broadcast()
+}
+
+// popEligible removes the highest priority task whose job is below its
+// concurrency limit. Caller must hold s.mu.
+func (s *Scheduler) popEligible() *scheduledTask {
+	for i, st := range s.queue {
+		jobID := st.task.ParentJobID
+		if s.perJobLimit > 0 && s.inFlight[jobID] >= s.perJobLimit {
+			continue
+		}
+		s.queue = append(s.queue[:i], s.queue[i+1:]...)
+		s.inFlight[jobID]++
+		st.attempts++
+		return st
+	}
+	return nil
 }
 
 // GetQueueLength returns the number of tasks in the queue
@@ -0,0 +1,14 @@
+//go:build linux && amd64
+
+package compute
+
+import "golang.org/x/sys/unix"
+
+const seccompArch = unix.AUDIT_ARCH_X86_64
+
+// seccompForeignABI marks x32 syscalls, which would otherwise get past
+// the x86-64 numbers below
+const seccompForeignABI = 0x40000000
+
+// archDeniedSyscalls are older forms of denied syscalls
+var archDeniedSyscalls = []uintptr{unix.SYS_OPEN, unix.SYS_CREAT, unix.SYS_FORK, unix.SYS_VFORK, unix.SYS_ACCEPT}
@@ -0,0 +1,11 @@
+//go:build linux && arm64
+
+package compute
+
+import "golang.org/x/sys/unix"
+
+const seccompArch = unix.AUDIT_ARCH_AARCH64
+
+const seccompForeignABI = 0
+
+var archDeniedSyscalls []uintptr
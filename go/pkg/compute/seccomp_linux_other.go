@@ -0,0 +1,10 @@
+//go:build linux && !amd64 && !arm64
+
+package compute
+
+// Other architectures run sandboxed tasks under rlimits only
+const seccompArch = 0
+
+const seccompForeignABI = 0
+
+var archDeniedSyscalls []uintptr
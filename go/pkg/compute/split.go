@@ -0,0 +1,191 @@
+package compute
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Built-in split strategy names, selected via JobManifest.SplitStrategy
+const (
+	SplitFixedSize  = "fixed_size"  // Fixed-size byte chunks (default)
+	SplitMatrixRows = "matrix_rows" // Row blocks of A, each paired with the full B
+	SplitLines      = "lines"       // Chunks ending on newline boundaries
+	SplitRecords    = "records"     // Length-prefixed records, never cut mid-record
+)
+
+// SplitStrategy partitions job input into chunks. Split must only cut where
+// the job's input format allows it, so that every chunk is a valid input on
+// its own.
+type SplitStrategy interface {
+	Split(data []byte, minSize, maxSize int64) ([][]byte, error)
+	// Reducer names the reducer that recombines this strategy's chunk
+	// results when the job does not choose one
+	Reducer() string
+}
+
+var (
+	splitStrategies = map[string]SplitStrategy{
+		SplitFixedSize:  fixedSizeSplit{},
+		SplitMatrixRows: matrixRowSplit{},
+		SplitLines:      lineSplit{},
+		SplitRecords:    recordSplit{},
+	}
+	splitAliases = map[string]string{
+		"":      SplitFixedSize,
+		"fixed": SplitFixedSize,
+	}
+	splitMu sync.RWMutex
+)
+
+// RegisterSplitStrategy adds or replaces a named split strategy
+func RegisterSplitStrategy(name string, s SplitStrategy) {
+	splitMu.Lock()
+	defer splitMu.Unlock()
+	splitStrategies[name] = s
+}
+
+// GetSplitStrategy returns the strategy registered under name. An empty
+// name selects the fixed-size strategy.
+func GetSplitStrategy(name string) (SplitStrategy, error) {
+	splitMu.RLock()
+	defer splitMu.RUnlock()
+	if alias, ok := splitAliases[name]; ok {
+		name = alias
+	}
+	s, ok := splitStrategies[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown split strategy %q", name)
+	}
+	return s, nil
+}
+
+// SplitStrategyNames lists the registered strategies, sorted
+func SplitStrategyNames() []string {
+	splitMu.RLock()
+	defer splitMu.RUnlock()
+	names := make([]string, 0, len(splitStrategies))
+	for name := range splitStrategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// fixedSizeSplit keeps input that fits in maxSize whole and otherwise cuts
+// it into maxSize chunks. Only suitable for formats without structure.
+type fixedSizeSplit struct{}
+
+func (fixedSizeSplit) Split(data []byte, minSize, maxSize int64) ([][]byte, error) {
+	if len(data) == 0 {
+		return [][]byte{}, nil
+	}
+	if maxSize <= 0 || int64(len(data)) <= maxSize {
+		return [][]byte{data}, nil
+	}
+
+	var chunks [][]byte
+	for i := int64(0); i < int64(len(data)); i += maxSize {
+		end := min(i+maxSize, int64(len(data)))
+		chunks = append(chunks, data[i:end])
+	}
+	return chunks, nil
+}
+
+func (fixedSizeSplit) Reducer() string { return ReducerConcat }
+
+// matrixRowSplit splits a matrix multiplication input into row blocks of A
+// small enough that each chunk (block plus the full B) fits in maxSize
+type matrixRowSplit struct{}
+
+func (matrixRowSplit) Split(data []byte, minSize, maxSize int64) ([][]byte, error) {
+	if len(data) < 8 {
+		return nil, fmt.Errorf("input data too short: %d bytes", len(data))
+	}
+	if maxSize <= 0 || int64(len(data)) <= maxSize {
+		return [][]byte{data}, nil
+	}
+
+	aRows := binary.BigEndian.Uint32(data[0:4])
+	aCols := binary.BigEndian.Uint32(data[4:8])
+	rowBytes := int64(aCols) * 8
+	overhead := int64(len(data)) - int64(aRows)*rowBytes // Header plus B
+	rowsPerPart := int64(1)
+	if rowBytes > 0 && maxSize > overhead {
+		rowsPerPart = max((maxSize-overhead)/rowBytes, 1)
+	}
+	parts := (int64(aRows) + rowsPerPart - 1) / rowsPerPart
+
+	chunks, _, err := SplitMatrixTask(data, int(parts))
+	return chunks, err
+}
+
+func (matrixRowSplit) Reducer() string { return ReducerMatrixRows }
+
+// lineSplit cuts after the last newline before each maxSize boundary. A
+// line longer than maxSize becomes a chunk of its own.
+type lineSplit struct{}
+
+func (lineSplit) Split(data []byte, minSize, maxSize int64) ([][]byte, error) {
+	if len(data) == 0 {
+		return [][]byte{}, nil
+	}
+	if maxSize <= 0 || int64(len(data)) <= maxSize {
+		return [][]byte{data}, nil
+	}
+
+	var chunks [][]byte
+	for len(data) > 0 {
+		if int64(len(data)) <= maxSize {
+			chunks = append(chunks, data)
+			break
+		}
+		end := bytes.LastIndexByte(data[:maxSize], '\n') + 1
+		if end == 0 {
+			// No newline within maxSize: extend to the end of the line
+			if next := bytes.IndexByte(data[maxSize:], '\n'); next >= 0 {
+				end = int(maxSize) + next + 1
+			} else {
+				end = len(data)
+			}
+		}
+		chunks = append(chunks, data[:end])
+		data = data[end:]
+	}
+	return chunks, nil
+}
+
+func (lineSplit) Reducer() string { return ReducerConcat }
+
+// recordSplit groups records, each a 4-byte big-endian length followed by
+// that many bytes, into chunks of at most maxSize. A record larger than
+// maxSize becomes a chunk of its own.
+type recordSplit struct{}
+
+func (recordSplit) Split(data []byte, minSize, maxSize int64) ([][]byte, error) {
+	var chunks [][]byte
+	start, pos := 0, 0
+	for pos < len(data) {
+		if len(data)-pos < 4 {
+			return nil, fmt.Errorf("truncated record header at offset %d", pos)
+		}
+		size := int(binary.BigEndian.Uint32(data[pos : pos+4]))
+		if size > len(data)-pos-4 {
+			return nil, fmt.Errorf("record at offset %d needs %d bytes, have %d", pos, size, len(data)-pos-4)
+		}
+		next := pos + 4 + size
+		if maxSize > 0 && pos > start && int64(next-start) > maxSize {
+			chunks = append(chunks, data[start:pos])
+			start = pos
+		}
+		pos = next
+	}
+	if pos > start {
+		chunks = append(chunks, data[start:pos])
+	}
+	return chunks, nil
+}
+
+func (recordSplit) Reducer() string { return ReducerConcat }
@@ -0,0 +1,364 @@
+package compute
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Provenance records which worker produced a result and, for re-delegated
+// tasks, the sub-results it was merged from. Each node in the tree covers
+// rows [RowStart, RowEnd) of the result matrix.
+type Provenance struct {
+	WorkerID   string       `json:"workerId"`
+	Depth      uint32       `json:"depth"`
+	RowStart   uint32       `json:"rowStart"`
+	RowEnd     uint32       `json:"rowEnd"`
+	ResultHash string       `json:"resultHash"`
+	Children   []Provenance `json:"children,omitempty"`
+}
+
+// ExecuteTask runs a task received from upstreamID. When this node is
+// overloaded and the task is below MaxDelegationDepth, the task is split and
+// forwarded to other peers, and the merged result carries provenance for
+// every sub-result.
+func (m *Manager) ExecuteTask(ctx context.Context, task *ComputeTask, localID, upstreamID string) *TaskResult {
+	m.mu.Lock()
+	policy := m.policy
+	err := m.policyAdmitsLocked(time.Now())
+	if err == nil && policy.MaxTaskMemoryMB > 0 {
+		if need := EstimateTaskMemoryMB(task.InputData); need > policy.MaxTaskMemoryMB {
+			err = fmt.Errorf("%w: needs about %dMB, limit is %dMB", ErrTaskOverBudget, need, policy.MaxTaskMemoryMB)
+		}
+	}
+	if err == nil {
+		err = m.admitTaskLocked(upstreamID, time.Now())
+	}
+	if err != nil {
+		m.mu.Unlock()
+		return &TaskResult{TaskID: task.TaskID, WorkerID: localID, Status: TaskFailed, Error: err.Error()}
+	}
+	m.activeTasks++
+	delegator := m.delegator
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		m.activeTasks--
+		m.mu.Unlock()
+	}()
+
+	if policy.MaxTaskTime > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, policy.MaxTaskTime)
+		defer cancel()
+	}
+
+	limits := m.taskLimits(policy)
+	start := time.Now()
+	var result *TaskResult
+	if workers := m.subdelegationWorkers(task, delegator, upstreamID); len(workers) > 0 {
+		result = m.executeSubdelegated(ctx, task, localID, workers, delegator, limits)
+	}
+	if result == nil {
+		result = m.executeTaskLocally(ctx, task, localID, limits)
+	}
+	result.ExecutionTimeMs = uint64(time.Since(start).Milliseconds())
+	return result
+}
+
+// subdelegationWorkers returns the peers a task may be forwarded to, or nil
+// if it should run locally
+func (m *Manager) subdelegationWorkers(task *ComputeTask, delegator TaskDelegator, upstreamID string) []string {
+	if delegator == nil || task.DelegationDepth >= m.config.MaxDelegationDepth || !m.isOverloaded() {
+		return nil
+	}
+
+	var workers []string
+	for _, w := range delegator.GetAvailableWorkers() {
+		if w != upstreamID {
+			workers = append(workers, w)
+		}
+	}
+	fanout := m.config.SubdelegateFanout
+	if fanout > 0 && len(workers) > fanout {
+		workers = workers[:fanout]
+	}
+	return workers
+}
+
+// isOverloaded reports whether this node should shed received work
+func (m *Manager) isOverloaded() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.capacity.CurrentLoad >= m.config.SubdelegateLoadThreshold {
+		return true
+	}
+	return m.capacity.CPUCores > 0 && m.activeTasks > int(m.capacity.CPUCores)
+}
+
+// executeTaskLocally runs a task on this node within its sandbox limits,
+// records leaf provenance and bills the work to localID in the ledger
+func (m *Manager) executeTaskLocally(ctx context.Context, task *ComputeTask, localID string, limits sandboxLimits) *TaskResult {
+	result := &TaskResult{
+		TaskID:   task.TaskID,
+		WorkerID: localID,
+	}
+
+	start := time.Now()
+	data, err := m.runTask(ctx, task, limits)
+	m.ledger.Record(task.ParentJobID, localID, time.Since(start), uint64(len(task.InputData)), uint64(len(data)), err == nil)
+	if err != nil {
+		result.Status = TaskFailed
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Status = TaskCompleted
+	result.ResultData = data
+	result.ResultHash = hashData(data)
+	result.Provenance = &Provenance{
+		WorkerID:   localID,
+		Depth:      task.DelegationDepth,
+		ResultHash: result.ResultHash,
+	}
+	// Matrix results lead with their row count; WASM results need not
+	if len(data) >= 4 {
+		result.Provenance.RowEnd = binary.BigEndian.Uint32(data[0:4])
+	}
+	return result
+}
+
+// executeSubdelegated splits a task across workers one level deeper and
+// merges their results. Parts a worker fails to compute run locally.
+// Returns nil if the task cannot be split.
+func (m *Manager) executeSubdelegated(ctx context.Context, task *ComputeTask, localID string, workers []string, delegator TaskDelegator, limits sandboxLimits) *TaskResult {
+	parts, rowStarts, err := SplitMatrixTask(task.InputData, len(workers))
+	if err != nil || len(parts) < 2 {
+		return nil
+	}
+
+	log.Printf("🔀 [COMPUTE] Overloaded, splitting task %s into %d parts at depth %d",
+		task.TaskID, len(parts), task.DelegationDepth+1)
+
+	partResults := make([]*TaskResult, len(parts))
+	var wg sync.WaitGroup
+	for i, part := range parts {
+		wg.Add(1)
+		go func(i int, part []byte) {
+			defer wg.Done()
+			sub := &ComputeTask{
+				TaskID:          fmt.Sprintf("%s/%d", task.TaskID, i),
+				ParentJobID:     task.ParentJobID,
+				ChunkIndex:      task.ChunkIndex,
+				WASMModule:      task.WASMModule,
+				InputData:       part,
+				FunctionName:    task.FunctionName,
+				DelegationDepth: task.DelegationDepth + 1,
+				TimeoutMs:       task.TimeoutMs,
+				TraceContext:    task.TraceContext,
+			}
+
+			subStart := time.Now()
+			r, err := delegator.DelegateTask(ctx, workers[i], sub)
+			if err == nil && r != nil && r.Status == TaskCompleted && r.ResultHash == hashData(r.ResultData) &&
+				validPartResult(r.ResultData, part) {
+				m.ledger.Record(task.ParentJobID, workers[i], time.Duration(r.ExecutionTimeMs)*time.Millisecond,
+					uint64(len(part)), uint64(len(r.ResultData)), true)
+				if r.Provenance == nil {
+					r.Provenance = &Provenance{
+						WorkerID:   workers[i],
+						Depth:      sub.DelegationDepth,
+						RowEnd:     binary.BigEndian.Uint32(r.ResultData[0:4]),
+						ResultHash: r.ResultHash,
+					}
+				}
+				partResults[i] = r
+				return
+			}
+			log.Printf("⚠️  [COMPUTE] Sub-task %s failed on %s, executing locally", sub.TaskID, truncateID(workers[i], 12))
+			m.ledger.Record(task.ParentJobID, workers[i], time.Since(subStart), uint64(len(part)), 0, false)
+			partResults[i] = m.executeTaskLocally(ctx, sub, localID, limits)
+		}(i, part)
+	}
+	wg.Wait()
+
+	outputs := make([][]byte, len(partResults))
+	prov := &Provenance{
+		WorkerID: localID,
+		Depth:    task.DelegationDepth,
+		Children: make([]Provenance, len(partResults)),
+	}
+	for i, r := range partResults {
+		if r.Status != TaskCompleted {
+			return &TaskResult{TaskID: task.TaskID, Status: TaskFailed, WorkerID: localID, Error: r.Error}
+		}
+		outputs[i] = r.ResultData
+		child := *r.Provenance
+		child.shiftRows(rowStarts[i])
+		prov.Children[i] = child
+	}
+
+	merged, err := MergeMatrixResults(outputs)
+	if err != nil {
+		return &TaskResult{TaskID: task.TaskID, Status: TaskFailed, WorkerID: localID, Error: err.Error()}
+	}
+	prov.RowEnd = binary.BigEndian.Uint32(merged[0:4])
+	prov.ResultHash = hashData(merged)
+
+	return &TaskResult{
+		TaskID:     task.TaskID,
+		Status:     TaskCompleted,
+		ResultData: merged,
+		ResultHash: prov.ResultHash,
+		WorkerID:   localID,
+		Provenance: prov,
+	}
+}
+
+// validPartResult reports whether a sub-worker's result is a well-formed
+// matrix with one row per row of A in the part it was sent
+func validPartResult(result, part []byte) bool {
+	if len(result) < 8 {
+		return false
+	}
+	rows := binary.BigEndian.Uint32(result[0:4])
+	cols := binary.BigEndian.Uint32(result[4:8])
+	if rows != binary.BigEndian.Uint32(part[0:4]) || uint64(rows)*uint64(cols) > maxMatrixElements {
+		return false
+	}
+	return uint64(len(result)) == 8+uint64(rows)*uint64(cols)*8
+}
+
+// shiftRows moves a provenance subtree by offset rows into its parent's frame
+func (p *Provenance) shiftRows(offset uint32) {
+	p.RowStart += offset
+	p.RowEnd += offset
+	for i := range p.Children {
+		p.Children[i].shiftRows(offset)
+	}
+}
+
+// VerifyProvenance checks a result matrix against its provenance tree: the
+// root hash must match the data, and every sub-result hash must match the
+// rows it claims to have produced
+func VerifyProvenance(result []byte, prov *Provenance) error {
+	if prov == nil {
+		return fmt.Errorf("missing provenance")
+	}
+	if hashData(result) != prov.ResultHash {
+		return fmt.Errorf("result hash mismatch for worker %s", truncateID(prov.WorkerID, 12))
+	}
+	return verifyProvenanceRows(result, prov, prov.RowStart)
+}
+
+func verifyProvenanceRows(result []byte, prov *Provenance, base uint32) error {
+	for i := range prov.Children {
+		child := &prov.Children[i]
+		rows, err := sliceMatrixRows(result, child.RowStart-base, child.RowEnd-base)
+		if err != nil {
+			return err
+		}
+		if hashData(rows) != child.ResultHash {
+			return fmt.Errorf("sub-result hash mismatch for worker %s (rows %d-%d)",
+				truncateID(child.WorkerID, 12), child.RowStart, child.RowEnd)
+		}
+		if err := verifyProvenanceRows(rows, child, child.RowStart); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SplitMatrixTask splits a matrix multiplication input into up to parts
+// row blocks of A, each paired with the full B. Returns the parts and the
+// first row of A covered by each part.
+func SplitMatrixTask(data []byte, parts int) ([][]byte, []uint32, error) {
+	if len(data) < 8 {
+		return nil, nil, fmt.Errorf("input data too short: %d bytes", len(data))
+	}
+	aRows := binary.BigEndian.Uint32(data[0:4])
+	aCols := binary.BigEndian.Uint32(data[4:8])
+	if uint64(aRows)*uint64(aCols) > maxMatrixElements {
+		return nil, nil, fmt.Errorf("matrix A dimensions too large: %d x %d would overflow", aRows, aCols)
+	}
+	rowBytes := int(aCols) * 8
+	bOffset := 8 + int(aRows)*rowBytes
+	if aRows == 0 || len(data) < bOffset+8 {
+		return nil, nil, fmt.Errorf("input data incomplete for matrix A")
+	}
+	if parts > int(aRows) {
+		parts = int(aRows)
+	}
+	if parts < 1 {
+		parts = 1
+	}
+
+	b := data[bOffset:]
+	out := make([][]byte, 0, parts)
+	starts := make([]uint32, 0, parts)
+	per := int(aRows) / parts
+	extra := int(aRows) % parts
+	row := 0
+	for i := 0; i < parts; i++ {
+		n := per
+		if i < extra {
+			n++
+		}
+		part := make([]byte, 8, 8+n*rowBytes+len(b))
+		binary.BigEndian.PutUint32(part[0:4], uint32(n))
+		binary.BigEndian.PutUint32(part[4:8], aCols)
+		part = append(part, data[8+row*rowBytes:8+(row+n)*rowBytes]...)
+		part = append(part, b...)
+		out = append(out, part)
+		starts = append(starts, uint32(row))
+		row += n
+	}
+	return out, starts, nil
+}
+
+// MergeMatrixResults stacks row-block results back into one matrix
+func MergeMatrixResults(results [][]byte) ([]byte, error) {
+	var rows, cols uint32
+	for i, r := range results {
+		if len(r) < 8 {
+			return nil, fmt.Errorf("result %d too short", i)
+		}
+		c := binary.BigEndian.Uint32(r[4:8])
+		if i == 0 {
+			cols = c
+		} else if c != cols {
+			return nil, fmt.Errorf("result %d has %d columns, expected %d", i, c, cols)
+		}
+		rows += binary.BigEndian.Uint32(r[0:4])
+	}
+
+	merged := make([]byte, 8)
+	binary.BigEndian.PutUint32(merged[0:4], rows)
+	binary.BigEndian.PutUint32(merged[4:8], cols)
+	for _, r := range results {
+		merged = append(merged, r[8:]...)
+	}
+	return merged, nil
+}
+
+// sliceMatrixRows extracts rows [start, end) of a serialized result matrix
+func sliceMatrixRows(data []byte, start, end uint32) ([]byte, error) {
+	if len(data) < 8 {
+		return nil, fmt.Errorf("result too short")
+	}
+	rows := binary.BigEndian.Uint32(data[0:4])
+	cols := binary.BigEndian.Uint32(data[4:8])
+	rowBytes := int(cols) * 8
+	if start > end || end > rows || len(data) < 8+int(rows)*rowBytes {
+		return nil, fmt.Errorf("rows %d-%d out of range for %d-row result", start, end, rows)
+	}
+
+	out := make([]byte, 8, 8+int(end-start)*rowBytes)
+	binary.BigEndian.PutUint32(out[0:4], end-start)
+	binary.BigEndian.PutUint32(out[4:8], cols)
+	out = append(out, data[8+int(start)*rowBytes:8+int(end)*rowBytes]...)
+	return out, nil
+}
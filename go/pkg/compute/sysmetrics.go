@@ -0,0 +1,116 @@
+package compute
+
+import (
+	"math"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// DefaultCapacityRefreshInterval is how often host metrics are re-read
+const DefaultCapacityRefreshInterval = 5 * time.Second
+
+// cpuTimes is a cumulative CPU time sample: busy and total jiffies
+type cpuTimes struct {
+	busy  uint64
+	total uint64
+}
+
+// hostMetrics is one reading of the host's resources
+type hostMetrics struct {
+	totalMemMB uint64
+	availMemMB uint64
+	diskFreeMB uint64
+	cpu        cpuTimes   // All cores
+	cores      []cpuTimes // Per core
+}
+
+// hostProber turns successive host readings into utilization figures. CPU
+// utilization is the busy fraction between two samples; the first probe
+// reports the average since boot.
+type hostProber struct {
+	diskPath string
+	prev     *hostMetrics
+	mu       sync.Mutex
+}
+
+func newHostProber(diskPath string) *hostProber {
+	if diskPath == "" {
+		diskPath = "."
+	}
+	return &hostProber{diskPath: diskPath}
+}
+
+// probe reads host metrics and returns the node's capacity. If the host
+// cannot be read (unsupported OS), it falls back to Go runtime estimates.
+func (p *hostProber) probe() ComputeCapacity {
+	cur, err := readHostMetrics(p.diskPath)
+	if err != nil {
+		return runtimeCapacity()
+	}
+
+	p.mu.Lock()
+	prev := p.prev
+	p.prev = cur
+	p.mu.Unlock()
+
+	capacity := ComputeCapacity{
+		CPUCores:      uint32(runtime.NumCPU()),
+		RAMMB:         cur.availMemMB,
+		TotalRAMMB:    cur.totalMemMB,
+		DiskMB:        cur.diskFreeMB,
+		BandwidthMbps: 100.0, // Network probing requires active measurement
+	}
+	if len(cur.cores) > 0 {
+		capacity.CPUCores = uint32(len(cur.cores))
+	}
+
+	var prevCPU cpuTimes
+	if prev != nil {
+		prevCPU = prev.cpu
+	}
+	capacity.CurrentLoad = utilization(prevCPU, cur.cpu)
+	capacity.CoreLoads = make([]float32, len(cur.cores))
+	for i, c := range cur.cores {
+		var prevCore cpuTimes
+		if prev != nil && i < len(prev.cores) {
+			prevCore = prev.cores[i]
+		}
+		capacity.CoreLoads[i] = utilization(prevCore, c)
+	}
+	return capacity
+}
+
+// utilization returns the busy fraction of CPU time between two samples
+func utilization(prev, cur cpuTimes) float32 {
+	if cur.total <= prev.total || cur.busy < prev.busy {
+		return 0
+	}
+	load := float64(cur.busy-prev.busy) / float64(cur.total-prev.total)
+	return float32(math.Min(math.Max(load, 0), 1))
+}
+
+// runtimeCapacity approximates capacity from Go runtime statistics when the
+// host's metrics are unavailable. HeapSys is not system memory, and the
+// goroutine count is only a rough proxy for load.
+func runtimeCapacity() ComputeCapacity {
+	numCPU := runtime.NumCPU()
+
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+	ramMB := memStats.HeapSys / (1024 * 1024)
+	if ramMB < 512 {
+		ramMB = 512 // Minimum reasonable value for fresh processes
+	}
+
+	numGoroutines := runtime.NumGoroutine()
+	currentLoad := math.Min(float64(numGoroutines)/float64(numCPU*10), 1.0)
+
+	return ComputeCapacity{
+		CPUCores:      uint32(numCPU),
+		RAMMB:         ramMB,
+		CurrentLoad:   float32(currentLoad),
+		DiskMB:        100000,
+		BandwidthMbps: 100.0,
+	}
+}
@@ -0,0 +1,111 @@
+//go:build linux
+
+package compute
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// readHostMetrics reads memory from /proc/meminfo, CPU times from /proc/stat
+// and free space on the filesystem holding diskPath
+func readHostMetrics(diskPath string) (*hostMetrics, error) {
+	m := &hostMetrics{}
+	if err := readMemInfo(m); err != nil {
+		return nil, err
+	}
+	if err := readCPUStat(m); err != nil {
+		return nil, err
+	}
+
+	var fs syscall.Statfs_t
+	if err := syscall.Statfs(diskPath, &fs); err == nil {
+		m.diskFreeMB = fs.Bavail * uint64(fs.Bsize) / (1024 * 1024)
+	}
+	return m, nil
+}
+
+func readMemInfo(m *hostMetrics) error {
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		kb, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		switch fields[0] {
+		case "MemTotal:":
+			m.totalMemMB = kb / 1024
+		case "MemAvailable:":
+			m.availMemMB = kb / 1024
+		}
+	}
+	if m.totalMemMB == 0 {
+		return fmt.Errorf("MemTotal missing from /proc/meminfo")
+	}
+	return scanner.Err()
+}
+
+func readCPUStat(m *hostMetrics) error {
+	f, err := os.Open("/proc/stat")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	found := false
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 || !strings.HasPrefix(fields[0], "cpu") {
+			continue
+		}
+		t, err := parseCPUTimes(fields[1:])
+		if err != nil {
+			return err
+		}
+		if fields[0] == "cpu" {
+			m.cpu = t
+			found = true
+		} else {
+			m.cores = append(m.cores, t)
+		}
+	}
+	if !found {
+		return fmt.Errorf("cpu line missing from /proc/stat")
+	}
+	return scanner.Err()
+}
+
+// parseCPUTimes sums a /proc/stat cpu line; idle and iowait count as not busy
+func parseCPUTimes(fields []string) (cpuTimes, error) {
+	var t cpuTimes
+	for i, field := range fields {
+		// Fields after steal (guest, guest_nice) are already included in user/nice
+		if i >= 8 {
+			break
+		}
+		v, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return cpuTimes{}, fmt.Errorf("invalid /proc/stat value %q: %w", field, err)
+		}
+		t.total += v
+		if i != 3 && i != 4 {
+			t.busy += v
+		}
+	}
+	return t, nil
+}
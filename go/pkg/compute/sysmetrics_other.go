@@ -0,0 +1,11 @@
+//go:build !linux
+
+package compute
+
+import "fmt"
+
+// readHostMetrics is only implemented on Linux; other platforms fall back
+// to runtime estimates
+func readHostMetrics(diskPath string) (*hostMetrics, error) {
+	return nil, fmt.Errorf("host metrics not supported on this platform")
+}
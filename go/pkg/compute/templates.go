@@ -0,0 +1,285 @@
+package compute
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// JobTemplate is a named set of job defaults. Saving a template under an
+// existing name adds a new version; earlier versions stay available so that
+// clients pinned to a version keep getting the same jobs.
+type JobTemplate struct {
+	Name             string           `json:"name"`
+	Version          uint32           `json:"version"`
+	Description      string           `json:"description,omitempty"`
+	WASMModule       []byte           `json:"wasmModule,omitempty"`
+	SplitStrategy    string           `json:"splitStrategy"`
+	Reducer          string           `json:"reducer,omitempty"`
+	MinChunkSize     int64            `json:"minChunkSize"`
+	MaxChunkSize     int64            `json:"maxChunkSize"`
+	VerificationMode VerificationMode `json:"verificationMode"`
+	TimeoutSecs      uint32           `json:"timeoutSecs"`
+	RetryCount       uint32           `json:"retryCount"`
+	Priority         uint32           `json:"priority"`
+	Redundancy       uint32           `json:"redundancy"` // Workers per chunk
+	PostProcess      []string         `json:"postProcess,omitempty"`
+	Created          time.Time        `json:"created"`
+}
+
+// TemplateParams are the per-submission values for a template job.
+// Overrides replace template defaults by parameter name: split_strategy,
+// reducer, min_chunk_size, max_chunk_size, verification_mode, timeout_secs,
+// retry_count, priority or redundancy.
+type TemplateParams struct {
+	JobID     string
+	InputData []byte
+	DependsOn []string
+	Overrides map[string]string
+}
+
+// TemplateLibrary stores job templates and persists them as JSON
+type TemplateLibrary struct {
+	path      string
+	templates map[string][]*JobTemplate // name -> versions, oldest first
+	mu        sync.RWMutex
+}
+
+// NewTemplateLibrary creates a library backed by path, loading any existing
+// templates. An empty path keeps the library in memory only.
+func NewTemplateLibrary(path string) *TemplateLibrary {
+	l := &TemplateLibrary{
+		path:      path,
+		templates: make(map[string][]*JobTemplate),
+	}
+	if path != "" {
+		if err := l.load(); err != nil {
+			log.Printf("⚠️  [COMPUTE] Failed to load job templates: %v", err)
+		}
+	}
+	return l
+}
+
+// Put validates a template and saves it as the next version of its name.
+// Returns the assigned version.
+func (l *TemplateLibrary) Put(t JobTemplate) (uint32, error) {
+	if t.Name == "" {
+		return 0, fmt.Errorf("template needs a name")
+	}
+	if err := validateTemplate(&t); err != nil {
+		return 0, err
+	}
+
+	l.mu.Lock()
+	versions := l.templates[t.Name]
+	t.Version = 1
+	if len(versions) > 0 {
+		t.Version = versions[len(versions)-1].Version + 1
+	}
+	t.Created = time.Now()
+	t.WASMModule = append([]byte(nil), t.WASMModule...)
+	l.templates[t.Name] = append(versions, &t)
+	err := l.saveLocked()
+	l.mu.Unlock()
+
+	log.Printf("📋 [COMPUTE] Saved job template %s v%d", t.Name, t.Version)
+	return t.Version, err
+}
+
+// Get returns a version of a template; version 0 selects the latest
+func (l *TemplateLibrary) Get(name string, version uint32) (JobTemplate, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	versions := l.templates[name]
+	if len(versions) == 0 {
+		return JobTemplate{}, fmt.Errorf("job template %s not found", name)
+	}
+	if version == 0 {
+		return *versions[len(versions)-1], nil
+	}
+	for _, t := range versions {
+		if t.Version == version {
+			return *t, nil
+		}
+	}
+	return JobTemplate{}, fmt.Errorf("job template %s has no version %d", name, version)
+}
+
+// List returns the latest version of every template, sorted by name
+func (l *TemplateLibrary) List() []JobTemplate {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	out := make([]JobTemplate, 0, len(l.templates))
+	for _, versions := range l.templates {
+		out = append(out, *versions[len(versions)-1])
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Delete removes a template and all its versions
+func (l *TemplateLibrary) Delete(name string) error {
+	l.mu.Lock()
+	if _, exists := l.templates[name]; !exists {
+		l.mu.Unlock()
+		return fmt.Errorf("job template %s not found", name)
+	}
+	delete(l.templates, name)
+	err := l.saveLocked()
+	l.mu.Unlock()
+
+	log.Printf("🗑️  [COMPUTE] Deleted job template %s", name)
+	return err
+}
+
+// Instantiate builds a job manifest from a template version and the
+// submission's parameters
+func (l *TemplateLibrary) Instantiate(name string, version uint32, params TemplateParams) (*JobManifest, error) {
+	t, err := l.Get(name, version)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range params.Overrides {
+		if err := t.override(key, value); err != nil {
+			return nil, err
+		}
+	}
+	if err := validateTemplate(&t); err != nil {
+		return nil, err
+	}
+
+	return &JobManifest{
+		JobID:            params.JobID,
+		WASMModule:       t.WASMModule,
+		InputData:        params.InputData,
+		SplitStrategy:    t.SplitStrategy,
+		Reducer:          t.Reducer,
+		DependsOn:        params.DependsOn,
+		MinChunkSize:     t.MinChunkSize,
+		MaxChunkSize:     t.MaxChunkSize,
+		VerificationMode: t.VerificationMode,
+		TimeoutSecs:      t.TimeoutSecs,
+		RetryCount:       t.RetryCount,
+		Priority:         t.Priority,
+		Redundancy:       t.Redundancy,
+		PostProcess:      t.PostProcess,
+	}, nil
+}
+
+// override sets one template field from a submission parameter
+func (t *JobTemplate) override(key, value string) error {
+	parseUint := func(bits int) (uint64, error) {
+		n, err := strconv.ParseUint(value, 10, bits)
+		if err != nil {
+			return 0, fmt.Errorf("parameter %s: %w", key, err)
+		}
+		return n, nil
+	}
+
+	switch key {
+	case "split_strategy":
+		t.SplitStrategy = value
+	case "reducer":
+		t.Reducer = value
+	case "verification_mode":
+		mode, err := ParseVerificationMode(value)
+		if err != nil {
+			return err
+		}
+		t.VerificationMode = mode
+	case "min_chunk_size", "max_chunk_size":
+		n, err := parseUint(63)
+		if err != nil {
+			return err
+		}
+		if key == "min_chunk_size" {
+			t.MinChunkSize = int64(n)
+		} else {
+			t.MaxChunkSize = int64(n)
+		}
+	case "timeout_secs", "retry_count", "priority", "redundancy":
+		n, err := parseUint(32)
+		if err != nil {
+			return err
+		}
+		switch key {
+		case "timeout_secs":
+			t.TimeoutSecs = uint32(n)
+		case "retry_count":
+			t.RetryCount = uint32(n)
+		case "priority":
+			t.Priority = uint32(n)
+		case "redundancy":
+			t.Redundancy = uint32(n)
+		}
+	default:
+		return fmt.Errorf("unknown template parameter %q", key)
+	}
+	return nil
+}
+
+// validateTemplate checks the template's strategy, reducer and chunk sizes
+func validateTemplate(t *JobTemplate) error {
+	strategy, err := GetSplitStrategy(t.SplitStrategy)
+	if err != nil {
+		return err
+	}
+	reducer := t.Reducer
+	if reducer == "" {
+		reducer = strategy.Reducer()
+	}
+	if reducer == ReducerWASM {
+		if len(t.WASMModule) == 0 {
+			return fmt.Errorf("wasm reducer requested but the template has no WASM module")
+		}
+	} else if _, err := GetReducer(reducer); err != nil {
+		return err
+	}
+	if t.MaxChunkSize > 0 && t.MinChunkSize > t.MaxChunkSize {
+		return fmt.Errorf("min chunk size %d exceeds max chunk size %d", t.MinChunkSize, t.MaxChunkSize)
+	}
+	return nil
+}
+
+// saveLocked writes the library to disk. Caller must hold l.mu.
+func (l *TemplateLibrary) saveLocked() error {
+	if l.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(l.templates, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize job templates: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return fmt.Errorf("failed to create template directory: %w", err)
+	}
+	tmp := l.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write job templates: %w", err)
+	}
+	if err := os.Rename(tmp, l.path); err != nil {
+		return fmt.Errorf("failed to replace job templates: %w", err)
+	}
+	return nil
+}
+
+func (l *TemplateLibrary) load() error {
+	data, err := os.ReadFile(l.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if err := json.Unmarshal(data, &l.templates); err != nil {
+		return fmt.Errorf("failed to parse job templates: %w", err)
+	}
+	return nil
+}
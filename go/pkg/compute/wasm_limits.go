@@ -0,0 +1,154 @@
+package compute
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// wasmPageSize is the size of one page of WASM linear memory
+const wasmPageSize = 64 << 10
+
+// WASM binary section ids that matter for memory limits
+const (
+	wasmSectionImport = 2
+	wasmSectionMemory = 5
+)
+
+// errBadWASM means a task module could not be parsed
+var errBadWASM = errors.New("malformed WASM module")
+
+// checkWASMMemory makes sure a module cannot grow its linear memory past
+// maxBytes. Every memory the module defines must declare a maximum within
+// the cap, since the WASM machine itself refuses to grow past it whatever
+// runtime runs the module; modules that import their memory, declare none
+// or use 64-bit memories are refused.
+func checkWASMMemory(module []byte, maxBytes uint64) error {
+	if len(module) < 8 || !bytes.Equal(module[:4], []byte("\x00asm")) {
+		return fmt.Errorf("%w: missing header", errBadWASM)
+	}
+	r := &wasmReader{data: module[8:]}
+	for !r.done() {
+		id := r.byte()
+		size := r.u32()
+		body := r.bytes(size)
+		if r.err != nil {
+			return r.err
+		}
+		s := &wasmReader{data: body}
+		switch id {
+		case wasmSectionImport:
+			for n := s.u32(); n > 0 && s.err == nil; n-- {
+				s.bytes(s.u32()) // Module name
+				s.bytes(s.u32()) // Field name
+				switch kind := s.byte(); kind {
+				case 0x00: // Function
+					s.u32()
+				case 0x01: // Table
+					s.byte()
+					s.limits()
+				case 0x02:
+					return fmt.Errorf("%w: module imports its memory", ErrTaskOverBudget)
+				case 0x03: // Global
+					s.byte()
+					s.byte()
+				case 0x04: // Tag
+					s.byte()
+					s.u32()
+				default:
+					return fmt.Errorf("%w: unknown import kind %d", errBadWASM, kind)
+				}
+			}
+		case wasmSectionMemory:
+			for n := s.u32(); n > 0 && s.err == nil; n-- {
+				flags, _, maxPages, hasMax := s.limits()
+				if s.err != nil {
+					break
+				}
+				if flags&0x04 != 0 {
+					return fmt.Errorf("%w: 64-bit memories are not allowed", ErrTaskOverBudget)
+				}
+				if !hasMax {
+					return fmt.Errorf("%w: module memory declares no maximum", ErrTaskOverBudget)
+				}
+				if maxPages*wasmPageSize > maxBytes {
+					return fmt.Errorf("%w: module memory may grow to %d pages of 64KiB, limit is %d", ErrTaskOverBudget, maxPages, maxBytes/wasmPageSize)
+				}
+			}
+		}
+		if s.err != nil {
+			return s.err
+		}
+	}
+	return r.err
+}
+
+// wasmReader reads the LEB128 encoded fields of a WASM binary, keeping
+// the first error
+type wasmReader struct {
+	data []byte
+	err  error
+}
+
+func (r *wasmReader) done() bool {
+	return r.err != nil || len(r.data) == 0
+}
+
+func (r *wasmReader) fail() {
+	if r.err == nil {
+		r.err = fmt.Errorf("%w: truncated", errBadWASM)
+	}
+	r.data = nil
+}
+
+func (r *wasmReader) byte() byte {
+	if len(r.data) == 0 {
+		r.fail()
+		return 0
+	}
+	b := r.data[0]
+	r.data = r.data[1:]
+	return b
+}
+
+func (r *wasmReader) bytes(n uint64) []byte {
+	if uint64(len(r.data)) < n {
+		r.fail()
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+// uleb reads an unsigned LEB128 number of at most bits bits
+func (r *wasmReader) uleb(bits uint) uint64 {
+	var v uint64
+	for shift := uint(0); shift < bits; shift += 7 {
+		b := r.byte()
+		v |= uint64(b&0x7f) << shift
+		if b&0x80 == 0 {
+			return v
+		}
+	}
+	r.fail()
+	return 0
+}
+
+func (r *wasmReader) u32() uint64 {
+	return r.uleb(32)
+}
+
+// limits reads a table or memory type's limits
+func (r *wasmReader) limits() (flags byte, min, max uint64, hasMax bool) {
+	flags = r.byte()
+	bits := uint(32)
+	if flags&0x04 != 0 {
+		bits = 64
+	}
+	min = r.uleb(bits)
+	if flags&0x01 != 0 {
+		max, hasMax = r.uleb(bits), true
+	}
+	return flags, min, max, hasMax
+}
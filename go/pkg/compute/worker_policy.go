@@ -0,0 +1,369 @@
+package compute
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrWorkerUnavailable starts the error of a task this node's worker policy
+// turned away, so that delegators can move on to another worker
+var ErrWorkerUnavailable = errors.New("worker unavailable")
+
+// ErrTaskOverBudget means a task needs more than the policy gives one task
+var ErrTaskOverBudget = errors.New("task over budget")
+
+// WorkerPolicy controls whether this node runs tasks for peers and the
+// resources each task may use. Zero limits are unlimited.
+type WorkerPolicy struct {
+	// Enabled opts the node in as a worker for peers
+	Enabled bool `json:"enabled"`
+	// MaxConcurrentTasks caps peer tasks running at once
+	MaxConcurrentTasks int `json:"maxConcurrentTasks"`
+	// MaxTaskCPUPercent is the share of one core a task may use (1-100)
+	MaxTaskCPUPercent uint32 `json:"maxTaskCpuPercent"`
+	// MaxTaskMemoryMB is the memory a task may need
+	MaxTaskMemoryMB uint64 `json:"maxTaskMemoryMb"`
+	// MaxTaskTime stops tasks that run longer
+	MaxTaskTime time.Duration `json:"maxTaskTime"`
+	// WorkHours are the windows tasks are accepted in (empty = any time)
+	WorkHours []WorkWindow `json:"workHours,omitempty"`
+}
+
+// DefaultWorkerPolicy accepts peer tasks without limits
+func DefaultWorkerPolicy() WorkerPolicy {
+	return WorkerPolicy{Enabled: true}
+}
+
+// Validate checks that the limits make sense
+func (p WorkerPolicy) Validate() error {
+	if p.MaxConcurrentTasks < 0 {
+		return fmt.Errorf("max concurrent tasks must not be negative")
+	}
+	if p.MaxTaskCPUPercent > 100 {
+		return fmt.Errorf("task CPU share %d%% exceeds 100%%", p.MaxTaskCPUPercent)
+	}
+	if p.MaxTaskTime < 0 {
+		return fmt.Errorf("max task time must not be negative")
+	}
+	return nil
+}
+
+// InWorkHours reports whether t falls in one of the work windows
+func (p WorkerPolicy) InWorkHours(t time.Time) bool {
+	if len(p.WorkHours) == 0 {
+		return true
+	}
+	for _, w := range p.WorkHours {
+		if w.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// WorkWindow is a daily time range, in local time, on some weekdays. A
+// window ending before it starts runs past midnight into the next day.
+type WorkWindow struct {
+	Days  uint8 // Bit per time.Weekday; 0 = every day
+	Start int   // Minutes after midnight
+	End   int   // Minutes after midnight, up to 24:00
+}
+
+var weekdayNames = []string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"}
+
+// ParseWorkWindow parses "[days ]HH:MM-HH:MM", where days is a comma list
+// of weekdays and ranges such as "Mon-Fri,Sun"
+func ParseWorkWindow(s string) (WorkWindow, error) {
+	var w WorkWindow
+	fields := strings.Fields(s)
+	switch len(fields) {
+	case 1:
+	case 2:
+		days, err := parseWeekdays(fields[0])
+		if err != nil {
+			return w, fmt.Errorf("work window %q: %w", s, err)
+		}
+		w.Days = days
+	default:
+		return w, fmt.Errorf("work window %q: want [days ]HH:MM-HH:MM", s)
+	}
+
+	start, end, ok := strings.Cut(fields[len(fields)-1], "-")
+	if !ok {
+		return w, fmt.Errorf("work window %q: want HH:MM-HH:MM", s)
+	}
+	var err error
+	if w.Start, err = parseClock(start); err != nil || w.Start == 24*60 {
+		return w, fmt.Errorf("work window %q: bad start %q", s, start)
+	}
+	if w.End, err = parseClock(end); err != nil {
+		return w, fmt.Errorf("work window %q: bad end %q", s, end)
+	}
+	if w.Start == w.End {
+		return w, fmt.Errorf("work window %q is empty", s)
+	}
+	return w, nil
+}
+
+// parseWeekdays parses a comma list of weekdays and weekday ranges
+func parseWeekdays(s string) (uint8, error) {
+	var days uint8
+	for _, part := range strings.Split(s, ",") {
+		from, to, isRange := strings.Cut(part, "-")
+		first, ok := weekdayIndex(from)
+		if !ok {
+			return 0, fmt.Errorf("unknown weekday %q", from)
+		}
+		last := first
+		if isRange {
+			if last, ok = weekdayIndex(to); !ok {
+				return 0, fmt.Errorf("unknown weekday %q", to)
+			}
+		}
+		for d := first; ; d = (d + 1) % 7 {
+			days |= 1 << d
+			if d == last {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+func weekdayIndex(name string) (int, bool) {
+	for i, n := range weekdayNames {
+		if strings.EqualFold(n, name) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// parseClock parses HH:MM into minutes after midnight, allowing 24:00
+func parseClock(s string) (int, error) {
+	h, m, ok := strings.Cut(s, ":")
+	hours, err1 := strconv.Atoi(h)
+	minutes, err2 := strconv.Atoi(m)
+	if !ok || err1 != nil || err2 != nil || hours < 0 || minutes < 0 || minutes > 59 || hours*60+minutes > 24*60 {
+		return 0, fmt.Errorf("bad time %q", s)
+	}
+	return hours*60 + minutes, nil
+}
+
+// onDay reports whether the window applies on a weekday
+func (w WorkWindow) onDay(d time.Weekday) bool {
+	return w.Days == 0 || w.Days&(1<<d) != 0
+}
+
+// Contains reports whether t falls in the window
+func (w WorkWindow) Contains(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	if w.Start < w.End {
+		return w.onDay(t.Weekday()) && minute >= w.Start && minute < w.End
+	}
+	// Past midnight: the late part belongs to the day before
+	yesterday := (t.Weekday() + 6) % 7
+	return (w.onDay(t.Weekday()) && minute >= w.Start) || (w.onDay(yesterday) && minute < w.End)
+}
+
+// String formats the window as ParseWorkWindow reads it
+func (w WorkWindow) String() string {
+	clock := fmt.Sprintf("%02d:%02d-%02d:%02d", w.Start/60, w.Start%60, w.End/60, w.End%60)
+	if w.Days == 0 || w.Days == 0x7f {
+		return clock
+	}
+	var parts []string
+	for d := 0; d < 7; d++ {
+		if w.Days&(1<<d) == 0 {
+			continue
+		}
+		last := d
+		for last+1 < 7 && w.Days&(1<<(last+1)) != 0 {
+			last++
+		}
+		if last == d {
+			parts = append(parts, weekdayNames[d])
+		} else {
+			parts = append(parts, weekdayNames[d]+"-"+weekdayNames[last])
+		}
+		d = last
+	}
+	return strings.Join(parts, ",") + " " + clock
+}
+
+// MarshalText stores windows as their string form
+func (w WorkWindow) MarshalText() ([]byte, error) {
+	return []byte(w.String()), nil
+}
+
+// UnmarshalText parses a window's string form
+func (w *WorkWindow) UnmarshalText(text []byte) error {
+	parsed, err := ParseWorkWindow(string(text))
+	if err != nil {
+		return err
+	}
+	*w = parsed
+	return nil
+}
+
+// EstimateTaskMemoryMB returns the memory a matrix block task needs: its
+// input, both parsed matrices and the result. Input too short to carry
+// dimensions needs nothing beyond itself.
+func EstimateTaskMemoryMB(input []byte) uint64 {
+	bytes := float64(len(input))
+	if len(input) >= 8 {
+		aRows, aCols := readUint32(input[0:]), readUint32(input[4:])
+		aElems := uint64(aRows) * uint64(aCols)
+		if aElems <= maxMatrixElements && 16+aElems*8 <= uint64(len(input)) {
+			bCols := readUint32(input[8+aElems*8+4:])
+			// A and B as floats, C as floats and serialized. Peers pick the
+			// dimensions, so this is done in floats to avoid overflow.
+			bytes += 8 * (float64(aElems) + float64(aCols)*float64(bCols) + 2*float64(aRows)*float64(bCols))
+		}
+	}
+	mb := math.Ceil(bytes / (1 << 20))
+	if mb >= math.MaxUint64 {
+		return math.MaxUint64
+	}
+	return uint64(mb)
+}
+
+func readUint32(b []byte) uint32 {
+	return uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3])
+}
+
+// cpuPacer holds a task to a share of one core by idling, between units of
+// work, in proportion to the time they took
+type cpuPacer struct {
+	percent uint32
+	last    time.Time
+	owed    time.Duration
+}
+
+func newCPUPacer(percent uint32) *cpuPacer {
+	return &cpuPacer{percent: percent, last: time.Now()}
+}
+
+// pace idles off the share of the time since the last call the task may
+// not use. Short idles are saved up to avoid sleeping for microseconds.
+func (p *cpuPacer) pace(ctx context.Context) {
+	if p.percent == 0 || p.percent >= 100 {
+		return
+	}
+	busy := time.Since(p.last)
+	p.owed += busy * time.Duration(100-p.percent) / time.Duration(p.percent)
+	if p.owed >= time.Millisecond {
+		timer := time.NewTimer(p.owed)
+		select {
+		case <-ctx.Done():
+		case <-timer.C:
+		}
+		timer.Stop()
+		p.owed = 0
+	}
+	p.last = time.Now()
+}
+
+// WorkerPolicy returns the current worker policy
+func (m *Manager) WorkerPolicy() WorkerPolicy {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	p := m.policy
+	p.WorkHours = append([]WorkWindow(nil), p.WorkHours...)
+	return p
+}
+
+// SetWorkerPolicy replaces the worker policy, saving it if the manager has
+// a policy path. Running tasks keep the budget they started with.
+func (m *Manager) SetWorkerPolicy(p WorkerPolicy) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	p.WorkHours = append([]WorkWindow(nil), p.WorkHours...)
+	m.mu.Lock()
+	m.policy = p
+	m.mu.Unlock()
+
+	log.Printf("👷 [COMPUTE] Worker policy: enabled=%v, %d tasks, %d%% CPU, %dMB, %v per task, hours %v",
+		p.Enabled, p.MaxConcurrentTasks, p.MaxTaskCPUPercent, p.MaxTaskMemoryMB, p.MaxTaskTime, p.WorkHours)
+	return m.saveWorkerPolicy(p)
+}
+
+// WorkerStatus returns how many peer tasks are running and whether the
+// policy accepts another one now
+func (m *Manager) WorkerStatus() (activeTasks int, accepting bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.activeTasks, m.policyAdmitsLocked(time.Now()) == nil
+}
+
+// policyAdmitsLocked applies the worker policy to a new peer task. Caller
+// must hold m.mu.
+func (m *Manager) policyAdmitsLocked(now time.Time) error {
+	p := m.policy
+	switch {
+	case !p.Enabled:
+		return fmt.Errorf("%w: not accepting peer tasks", ErrWorkerUnavailable)
+	case !p.InWorkHours(now):
+		return fmt.Errorf("%w: outside work hours", ErrWorkerUnavailable)
+	case p.MaxConcurrentTasks > 0 && m.activeTasks >= p.MaxConcurrentTasks:
+		return fmt.Errorf("%w: %d of %d peer tasks running", ErrWorkerUnavailable, m.activeTasks, p.MaxConcurrentTasks)
+	}
+	return nil
+}
+
+// loadWorkerPolicy reads a saved policy over the configured one
+func (m *Manager) loadWorkerPolicy() {
+	if m.config.WorkerPolicyPath == "" {
+		return
+	}
+	data, err := os.ReadFile(m.config.WorkerPolicyPath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("⚠️  [COMPUTE] Failed to read worker policy: %v", err)
+		}
+		return
+	}
+	var p WorkerPolicy
+	if err := json.Unmarshal(data, &p); err != nil {
+		log.Printf("⚠️  [COMPUTE] Ignoring saved worker policy: %v", err)
+		return
+	}
+	if err := p.Validate(); err != nil {
+		log.Printf("⚠️  [COMPUTE] Ignoring saved worker policy: %v", err)
+		return
+	}
+	m.policy = p
+}
+
+// saveWorkerPolicy persists the policy if the manager has a policy path
+func (m *Manager) saveWorkerPolicy(p WorkerPolicy) error {
+	if m.config.WorkerPolicyPath == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize worker policy: %w", err)
+	}
+	path := m.config.WorkerPolicyPath
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create worker policy directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write worker policy: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to replace worker policy: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,389 @@
+package kyberdkg
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/group/edwards25519"
+	"go.dedis.ch/kyber/v3/util/random"
+)
+
+// Threshold Schnorr signing over the DKG key (FROST with two nonces per
+// signer). Signers first publish nonce commitments, then each answers with
+// a partial signature over the message and the full commitment set, so a
+// partial cannot be replayed into a different signing session. The
+// aggregate is an ordinary Schnorr signature (R, z) with
+// zG = R + H(R, Y, msg)Y that anyone holding the group key Y can verify.
+
+// signSuite is the group shared with the DKG
+var signSuite = edwards25519.NewBlakeSHA256Ed25519()
+
+// Domain separation tags for the signing hashes
+const (
+	bindingTag   = "pangea-tsign-binding-v1"
+	challengeTag = "pangea-tsign-challenge-v1"
+)
+
+// SigningShare is one participant's share of a group signing key
+type SigningShare struct {
+	Index        int // Participant index, from 1
+	Threshold    int // Shares needed to sign
+	Secret       kyber.Scalar
+	GroupKey     kyber.Point
+	PublicShares map[int]kyber.Point // Secret*G of every participant, by index
+}
+
+// NonceCommitment is a signer's published commitment to its nonces
+type NonceCommitment struct {
+	Index int
+	D, E  kyber.Point
+}
+
+// SigningNonce is a signer's secret nonce pair. It must be used for at most
+// one partial signature.
+type SigningNonce struct {
+	d, e       kyber.Scalar
+	Commitment NonceCommitment
+}
+
+// SigningShare returns this node's signing share once round 3 succeeded.
+// Public shares are derived from every dealer's commitments, so all
+// participants compute the same set.
+func (s *DKGState) SigningShare() (*SigningShare, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.FinalKey == nil || s.PublicKey == nil {
+		return nil, fmt.Errorf("DKG has not completed")
+	}
+	publicShares := make(map[int]kyber.Point, len(s.Nodes))
+	for id := range s.Nodes {
+		y := s.suite.Point().Null()
+		for _, commit := range s.Commits {
+			y = s.suite.Point().Add(y, evalCommitments(commit.C, id))
+		}
+		publicShares[id] = y
+	}
+	if !s.suite.Point().Mul(s.FinalKey, nil).Equal(publicShares[s.NodeID]) {
+		return nil, fmt.Errorf("key share does not match the dealers' commitments")
+	}
+	return &SigningShare{
+		Index:        s.NodeID,
+		Threshold:    s.Threshold,
+		Secret:       s.FinalKey,
+		GroupKey:     s.PublicKey,
+		PublicShares: publicShares,
+	}, nil
+}
+
+// evalCommitments computes sum(C_k * x^k), the public value of a dealer's
+// polynomial at x
+func evalCommitments(commits []kyber.Point, x int) kyber.Point {
+	xs := signSuite.Scalar().SetInt64(int64(x))
+	xPower := signSuite.Scalar().One()
+	out := signSuite.Point().Null()
+	for _, c := range commits {
+		out = signSuite.Point().Add(out, signSuite.Point().Mul(xPower, c))
+		xPower = signSuite.Scalar().Mul(xPower, xs)
+	}
+	return out
+}
+
+// VerifyShare checks a share dealt to index against the dealer's
+// commitments
+func VerifyShare(commit *Commit, index int, share kyber.Scalar) bool {
+	if commit == nil || share == nil {
+		return false
+	}
+	return signSuite.Point().Mul(share, nil).Equal(evalCommitments(commit.C, index))
+}
+
+// Disqualify drops a dealer's commitment and share, so neither the key
+// share nor the group key include its contribution
+func (s *DKGState) Disqualify(dealerID int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.Commits, dealerID)
+	delete(s.Shares, dealerID)
+}
+
+// NewSigningNonce picks a fresh nonce pair for the signer at index
+func NewSigningNonce(index int) *SigningNonce {
+	stream := random.New()
+	d := signSuite.Scalar().Pick(stream)
+	e := signSuite.Scalar().Pick(stream)
+	return &SigningNonce{
+		d: d,
+		e: e,
+		Commitment: NonceCommitment{
+			Index: index,
+			D:     signSuite.Point().Mul(d, nil),
+			E:     signSuite.Point().Mul(e, nil),
+		},
+	}
+}
+
+// PartialSign signs msg with this share. commitments must hold the nonce
+// commitment of every signer in the session, this one's included.
+func (s *SigningShare) PartialSign(nonce *SigningNonce, msg []byte, commitments []NonceCommitment) (kyber.Scalar, error) {
+	if nonce == nil || nonce.d == nil {
+		return nil, fmt.Errorf("nonce already used")
+	}
+	commitments, err := sortedCommitments(commitments, s.Threshold)
+	if err != nil {
+		return nil, err
+	}
+	var own *NonceCommitment
+	for i := range commitments {
+		if commitments[i].Index == s.Index {
+			own = &commitments[i]
+		}
+	}
+	if own == nil || !own.D.Equal(nonce.Commitment.D) || !own.E.Equal(nonce.Commitment.E) {
+		return nil, fmt.Errorf("signing session does not include this signer's commitment")
+	}
+
+	rhos, r, err := groupCommitment(msg, commitments)
+	if err != nil {
+		return nil, err
+	}
+	c, err := challenge(r, s.GroupKey, msg)
+	if err != nil {
+		return nil, err
+	}
+	lambda := lagrangeAtZero(s.Index, commitments)
+
+	// z_i = d_i + e_i*rho_i + lambda_i*x_i*c
+	z := signSuite.Scalar().Add(nonce.d, signSuite.Scalar().Mul(nonce.e, rhos[s.Index]))
+	z = signSuite.Scalar().Add(z, signSuite.Scalar().Mul(lambda, signSuite.Scalar().Mul(s.Secret, c)))
+	nonce.d, nonce.e = nil, nil
+	return z, nil
+}
+
+// VerifyPartial checks another signer's partial signature against its
+// public share
+func (s *SigningShare) VerifyPartial(index int, z kyber.Scalar, msg []byte, commitments []NonceCommitment) error {
+	y, ok := s.PublicShares[index]
+	if !ok {
+		return fmt.Errorf("no public share for signer %d", index)
+	}
+	commitments, err := sortedCommitments(commitments, s.Threshold)
+	if err != nil {
+		return err
+	}
+	rhos, r, err := groupCommitment(msg, commitments)
+	if err != nil {
+		return err
+	}
+	c, err := challenge(r, s.GroupKey, msg)
+	if err != nil {
+		return err
+	}
+	var own *NonceCommitment
+	for i := range commitments {
+		if commitments[i].Index == index {
+			own = &commitments[i]
+		}
+	}
+	if own == nil {
+		return fmt.Errorf("signer %d is not part of the session", index)
+	}
+
+	// z_i*G == D_i + rho_i*E_i + c*lambda_i*Y_i
+	lambda := lagrangeAtZero(index, commitments)
+	expected := signSuite.Point().Add(own.D, signSuite.Point().Mul(rhos[index], own.E))
+	expected = signSuite.Point().Add(expected, signSuite.Point().Mul(signSuite.Scalar().Mul(c, lambda), y))
+	if !signSuite.Point().Mul(z, nil).Equal(expected) {
+		return fmt.Errorf("partial signature from signer %d is invalid", index)
+	}
+	return nil
+}
+
+// AggregateSignature combines the partial signatures of every signer in
+// the session into a 64-byte signature R || z
+func (s *SigningShare) AggregateSignature(msg []byte, commitments []NonceCommitment, partials map[int]kyber.Scalar) ([]byte, error) {
+	commitments, err := sortedCommitments(commitments, s.Threshold)
+	if err != nil {
+		return nil, err
+	}
+	_, r, err := groupCommitment(msg, commitments)
+	if err != nil {
+		return nil, err
+	}
+	z := signSuite.Scalar().Zero()
+	for _, c := range commitments {
+		partial, ok := partials[c.Index]
+		if !ok {
+			return nil, fmt.Errorf("missing partial signature from signer %d", c.Index)
+		}
+		z = signSuite.Scalar().Add(z, partial)
+	}
+
+	rBytes, err := r.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	zBytes, err := z.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	sig := append(rBytes, zBytes...)
+	if err := VerifySignature(s.GroupKey, msg, sig); err != nil {
+		return nil, err
+	}
+	return sig, nil
+}
+
+// VerifySignature checks a threshold signature against the group key
+func VerifySignature(groupKey kyber.Point, msg, sig []byte) error {
+	pointLen := signSuite.PointLen()
+	if len(sig) != pointLen+signSuite.ScalarLen() {
+		return fmt.Errorf("signature must be %d bytes, got %d", pointLen+signSuite.ScalarLen(), len(sig))
+	}
+	r, err := PointFromBytes(sig[:pointLen])
+	if err != nil {
+		return fmt.Errorf("invalid signature: %w", err)
+	}
+	z, err := ScalarFromBytes(sig[pointLen:])
+	if err != nil {
+		return fmt.Errorf("invalid signature: %w", err)
+	}
+	c, err := challenge(r, groupKey, msg)
+	if err != nil {
+		return err
+	}
+	expected := signSuite.Point().Add(r, signSuite.Point().Mul(c, groupKey))
+	if !signSuite.Point().Mul(z, nil).Equal(expected) {
+		return fmt.Errorf("signature does not verify")
+	}
+	return nil
+}
+
+// sortedCommitments orders a session's commitments by signer index and
+// rejects sessions that cannot produce a valid signature
+func sortedCommitments(commitments []NonceCommitment, threshold int) ([]NonceCommitment, error) {
+	if len(commitments) < threshold {
+		return nil, fmt.Errorf("signing needs %d signers, got %d", threshold, len(commitments))
+	}
+	out := append([]NonceCommitment(nil), commitments...)
+	sort.Slice(out, func(i, j int) bool { return out[i].Index < out[j].Index })
+	for i, c := range out {
+		if c.Index < 1 || c.D == nil || c.E == nil {
+			return nil, fmt.Errorf("invalid commitment for signer %d", c.Index)
+		}
+		if i > 0 && out[i-1].Index == c.Index {
+			return nil, fmt.Errorf("duplicate commitment for signer %d", c.Index)
+		}
+	}
+	return out, nil
+}
+
+// groupCommitment computes each signer's binding factor rho_i and the
+// session nonce R = sum(D_i + rho_i*E_i)
+func groupCommitment(msg []byte, commitments []NonceCommitment) (map[int]kyber.Scalar, kyber.Point, error) {
+	encoded := []byte(bindingTag)
+	encoded = binary.BigEndian.AppendUint64(encoded, uint64(len(msg)))
+	encoded = append(encoded, msg...)
+	for _, c := range commitments {
+		d, err := c.D.MarshalBinary()
+		if err != nil {
+			return nil, nil, err
+		}
+		e, err := c.E.MarshalBinary()
+		if err != nil {
+			return nil, nil, err
+		}
+		encoded = binary.BigEndian.AppendUint32(encoded, uint32(c.Index))
+		encoded = append(append(encoded, d...), e...)
+	}
+
+	rhos := make(map[int]kyber.Scalar, len(commitments))
+	r := signSuite.Point().Null()
+	for _, c := range commitments {
+		rho := hashToScalar(binary.BigEndian.AppendUint32(append([]byte(nil), encoded...), uint32(c.Index)))
+		rhos[c.Index] = rho
+		r = signSuite.Point().Add(r, signSuite.Point().Add(c.D, signSuite.Point().Mul(rho, c.E)))
+	}
+	return rhos, r, nil
+}
+
+// challenge is c = H(R, Y, msg)
+func challenge(r, groupKey kyber.Point, msg []byte) (kyber.Scalar, error) {
+	rBytes, err := r.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	yBytes, err := groupKey.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	input := append([]byte(challengeTag), rBytes...)
+	input = append(input, yBytes...)
+	return hashToScalar(append(input, msg...)), nil
+}
+
+// lagrangeAtZero is signer index's Lagrange coefficient at 0 over the
+// session's signers
+func lagrangeAtZero(index int, commitments []NonceCommitment) kyber.Scalar {
+	xi := signSuite.Scalar().SetInt64(int64(index))
+	num := signSuite.Scalar().One()
+	den := signSuite.Scalar().One()
+	for _, c := range commitments {
+		if c.Index == index {
+			continue
+		}
+		xj := signSuite.Scalar().SetInt64(int64(c.Index))
+		num = signSuite.Scalar().Mul(num, xj)
+		den = signSuite.Scalar().Mul(den, signSuite.Scalar().Sub(xj, xi))
+	}
+	return signSuite.Scalar().Div(num, den)
+}
+
+func hashToScalar(data []byte) kyber.Scalar {
+	return signSuite.Scalar().Pick(signSuite.XOF(data))
+}
+
+// PointFromBytes decodes a marshalled group element
+func PointFromBytes(b []byte) (kyber.Point, error) {
+	p := signSuite.Point()
+	if err := p.UnmarshalBinary(b); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// ScalarFromBytes decodes a marshalled scalar
+func ScalarFromBytes(b []byte) (kyber.Scalar, error) {
+	s := signSuite.Scalar()
+	if err := s.UnmarshalBinary(b); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// DecodeCommit rebuilds a dealer's commitment from its serialized form
+func DecodeCommit(nodeID int, commits [][]byte) (*Commit, error) {
+	c := &Commit{NodeID: nodeID, Degree: len(commits), Commits: commits}
+	for i, b := range commits {
+		p, err := PointFromBytes(b)
+		if err != nil {
+			return nil, fmt.Errorf("commitment %d from dealer %d: %w", i, nodeID, err)
+		}
+		c.C = append(c.C, p)
+	}
+	if len(c.C) > 0 {
+		c.Proof = commits[0]
+	}
+	return c, nil
+}
+
+// DecodeShare rebuilds a share sent by dealer fromID to toID
+func DecodeShare(fromID, toID int, payload []byte) (*Share, error) {
+	s, err := ScalarFromBytes(payload)
+	if err != nil {
+		return nil, fmt.Errorf("share from dealer %d: %w", fromID, err)
+	}
+	return &Share{FromID: fromID, ToID: toID, Index: toID, S: s, Proof: payload, Payload: payload}, nil
+}
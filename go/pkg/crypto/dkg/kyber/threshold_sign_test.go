@@ -0,0 +1,141 @@
+package kyberdkg
+
+import (
+	"testing"
+
+	"go.dedis.ch/kyber/v3"
+)
+
+// runLocalDKG runs the three DKG rounds in memory and returns every
+// participant's signing share
+func runLocalDKG(t *testing.T, n, threshold int) []*SigningShare {
+	t.Helper()
+	nodes := make([]*Node, n)
+	for i := range nodes {
+		nodes[i] = NewNode(i + 1)
+	}
+	states := make([]*DKGState, n)
+	commits := make(map[int]*Commit)
+	for i, node := range nodes {
+		states[i] = NewDKGState(node.ID, nodes, threshold)
+		c, err := states[i].Round1GenerateCommitments()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Commitments travel serialized
+		if commits[node.ID], err = DecodeCommit(node.ID, c.Commits); err != nil {
+			t.Fatal(err)
+		}
+	}
+	byRecipient := make(map[int]map[int]*Share)
+	for i, node := range nodes {
+		shares, err := states[i].Round2GenerateShares(commits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for to, share := range shares {
+			if byRecipient[to] == nil {
+				byRecipient[to] = make(map[int]*Share)
+			}
+			if byRecipient[to][node.ID], err = DecodeShare(node.ID, to, share.Payload); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	out := make([]*SigningShare, n)
+	for i, node := range nodes {
+		sharesByID := make(map[int]map[int]*Share)
+		for from, share := range byRecipient[node.ID] {
+			sharesByID[from] = map[int]*Share{node.ID: share}
+		}
+		if err := states[i].Round3VerifyAndAccumulateShares(sharesByID); err != nil {
+			t.Fatal(err)
+		}
+		share, err := states[i].SigningShare()
+		if err != nil {
+			t.Fatal(err)
+		}
+		out[i] = share
+	}
+	return out
+}
+
+// thresholdSign runs a signing session among signers
+func thresholdSign(t *testing.T, signers []*SigningShare, msg []byte) ([]byte, []NonceCommitment, map[int]kyber.Scalar) {
+	t.Helper()
+	nonces := make([]*SigningNonce, len(signers))
+	commitments := make([]NonceCommitment, len(signers))
+	for i, s := range signers {
+		nonces[i] = NewSigningNonce(s.Index)
+		commitments[i] = nonces[i].Commitment
+	}
+	partials := make(map[int]kyber.Scalar)
+	for i, s := range signers {
+		z, err := s.PartialSign(nonces[i], msg, commitments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := signers[0].VerifyPartial(s.Index, z, msg, commitments); err != nil {
+			t.Fatal(err)
+		}
+		partials[s.Index] = z
+	}
+	sig, err := signers[0].AggregateSignature(msg, commitments, partials)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sig, commitments, partials
+}
+
+func TestThresholdSignatureFromAnySubset(t *testing.T) {
+	shares := runLocalDKG(t, 5, 3)
+	for _, s := range shares[1:] {
+		if !s.GroupKey.Equal(shares[0].GroupKey) {
+			t.Fatal("participants disagree on the group key")
+		}
+	}
+
+	msg := []byte("job result")
+	for _, subset := range [][]int{{0, 1, 2}, {4, 2, 0}, {1, 3, 4, 2}} {
+		signers := make([]*SigningShare, len(subset))
+		for i, idx := range subset {
+			signers[i] = shares[idx]
+		}
+		sig, _, _ := thresholdSign(t, signers, msg)
+		if err := VerifySignature(shares[0].GroupKey, msg, sig); err != nil {
+			t.Fatalf("signature by %v does not verify: %v", subset, err)
+		}
+		if err := VerifySignature(shares[0].GroupKey, []byte("other"), sig); err == nil {
+			t.Fatalf("signature by %v verified for another message", subset)
+		}
+	}
+}
+
+func TestThresholdSigningRejectsBadPartials(t *testing.T) {
+	shares := runLocalDKG(t, 3, 2)
+	msg := []byte("manifest")
+	_, commitments, partials := thresholdSign(t, shares[:2], msg)
+
+	// A partial from one signer does not pass as another's
+	if err := shares[2].VerifyPartial(shares[1].Index, partials[shares[0].Index], msg, commitments); err == nil {
+		t.Fatal("expected a misattributed partial to be rejected")
+	}
+	// Nor for a different message
+	if err := shares[2].VerifyPartial(shares[0].Index, partials[shares[0].Index], []byte("other"), commitments); err == nil {
+		t.Fatal("expected a partial for another message to be rejected")
+	}
+
+	// Too few signers, and nonces are single use
+	nonce := NewSigningNonce(shares[0].Index)
+	if _, err := shares[0].PartialSign(nonce, msg, []NonceCommitment{nonce.Commitment}); err == nil {
+		t.Fatal("expected signing below the threshold to fail")
+	}
+	other := NewSigningNonce(shares[1].Index)
+	session := []NonceCommitment{nonce.Commitment, other.Commitment}
+	if _, err := shares[0].PartialSign(nonce, msg, session); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := shares[0].PartialSign(nonce, msg, session); err == nil {
+		t.Fatal("expected a reused nonce to be refused")
+	}
+}
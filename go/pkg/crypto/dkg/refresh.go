@@ -0,0 +1,87 @@
+package dkg
+
+import (
+	"crypto/rand"
+	"fmt"
+)
+
+// Proactive share refresh for the Shamir shares produced by
+// DistributeFileKey. Every holder deals a sharing of zero; adding the
+// deltas it receives to its share moves all holders to a new polynomial
+// with the same secret, so shares from before a refresh no longer combine
+// with shares from after it. Shares use vault's layout {y1, .., yN, x}
+// over GF(2^8) with the AES polynomial.
+
+// ShareX returns the x coordinate a share was evaluated at
+func ShareX(share []byte) (uint8, error) {
+	if len(share) < 2 || share[len(share)-1] == 0 {
+		return 0, fmt.Errorf("malformed share")
+	}
+	return share[len(share)-1], nil
+}
+
+// RefreshDeltas deals a sharing of zero to the holders at xs. Each delta
+// is length bytes, the length of the shared secret, and every threshold
+// of them interpolate to zero.
+func RefreshDeltas(threshold int, xs []uint8, length int) (map[uint8][]byte, error) {
+	if threshold < 2 || threshold > len(xs) {
+		return nil, fmt.Errorf("invalid threshold %d for %d holders", threshold, len(xs))
+	}
+	if length <= 0 {
+		return nil, fmt.Errorf("invalid secret length %d", length)
+	}
+	deltas := make(map[uint8][]byte, len(xs))
+	for _, x := range xs {
+		if x == 0 {
+			return nil, fmt.Errorf("x coordinate 0 would reveal the secret")
+		}
+		if _, dup := deltas[x]; dup {
+			return nil, fmt.Errorf("duplicate x coordinate %d", x)
+		}
+		deltas[x] = make([]byte, length)
+	}
+
+	// One random polynomial with a zero intercept per secret byte
+	coeffs := make([]byte, threshold-1)
+	for i := 0; i < length; i++ {
+		if _, err := rand.Read(coeffs); err != nil {
+			return nil, fmt.Errorf("failed to generate polynomial: %w", err)
+		}
+		for x, delta := range deltas {
+			// Horner's method; the intercept is zero
+			var y uint8
+			for j := len(coeffs) - 1; j >= 0; j-- {
+				y = gfMult(y, x) ^ coeffs[j]
+			}
+			delta[i] = gfMult(y, x)
+		}
+	}
+	return deltas, nil
+}
+
+// ApplyRefresh adds deltas to a share and returns the refreshed share
+func ApplyRefresh(share []byte, deltas [][]byte) ([]byte, error) {
+	if _, err := ShareX(share); err != nil {
+		return nil, err
+	}
+	out := append([]byte(nil), share...)
+	for _, delta := range deltas {
+		if len(delta) != len(share)-1 {
+			return nil, fmt.Errorf("delta is %d bytes, share holds %d", len(delta), len(share)-1)
+		}
+		for i, d := range delta {
+			out[i] ^= d
+		}
+	}
+	return out, nil
+}
+
+// gfMult multiplies in GF(2^8) with the same reduction as vault's shamir
+// package
+func gfMult(a, b uint8) uint8 {
+	var r uint8
+	for i := 7; i >= 0; i-- {
+		r = (-(b >> i & 1) & a) ^ (-(r >> 7) & 0x1B) ^ (r + r)
+	}
+	return r
+}
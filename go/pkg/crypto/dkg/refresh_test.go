@@ -0,0 +1,90 @@
+package dkg
+
+import (
+	"bytes"
+	"testing"
+
+	vaultshamir "github.com/hashicorp/vault/shamir"
+)
+
+// refresh runs one round in which every holder deals a sharing of zero
+func refresh(t *testing.T, shares [][]byte, threshold int) [][]byte {
+	t.Helper()
+	xs := make([]uint8, len(shares))
+	for i, s := range shares {
+		x, err := ShareX(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		xs[i] = x
+	}
+	received := make(map[uint8][][]byte)
+	for range shares {
+		deltas, err := RefreshDeltas(threshold, xs, len(shares[0])-1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for x, d := range deltas {
+			received[x] = append(received[x], d)
+		}
+	}
+	out := make([][]byte, len(shares))
+	for i, s := range shares {
+		refreshed, err := ApplyRefresh(s, received[xs[i]])
+		if err != nil {
+			t.Fatal(err)
+		}
+		out[i] = refreshed
+	}
+	return out
+}
+
+func TestRefreshKeepsSecret(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	shares, err := vaultshamir.Split(secret, 5, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	refreshed := refresh(t, shares, 3)
+	refreshed = refresh(t, refreshed, 3)
+	for i := range shares {
+		if bytes.Equal(shares[i], refreshed[i]) {
+			t.Fatalf("share %d did not change", i)
+		}
+	}
+	for _, subset := range [][]int{{0, 1, 2}, {4, 2, 3}, {1, 3, 4, 0}} {
+		parts := make([][]byte, len(subset))
+		for i, idx := range subset {
+			parts[i] = refreshed[idx]
+		}
+		got, err := vaultshamir.Combine(parts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, secret) {
+			t.Fatalf("refreshed shares %v combine to a different secret", subset)
+		}
+	}
+
+	// Shares from before the refresh do not combine with later ones
+	got, err := vaultshamir.Combine([][]byte{shares[0], shares[1], refreshed[2]})
+	if err == nil && bytes.Equal(got, secret) {
+		t.Fatal("stale share combined with refreshed shares")
+	}
+}
+
+func TestRefreshRejectsBadInput(t *testing.T) {
+	if _, err := RefreshDeltas(3, []uint8{1, 2}, 32); err == nil {
+		t.Fatal("expected a threshold above the holder count to fail")
+	}
+	if _, err := RefreshDeltas(2, []uint8{1, 0}, 32); err == nil {
+		t.Fatal("expected x = 0 to be refused")
+	}
+	if _, err := RefreshDeltas(2, []uint8{7, 7}, 32); err == nil {
+		t.Fatal("expected duplicate x coordinates to be refused")
+	}
+	if _, err := ApplyRefresh([]byte{1, 2, 3}, [][]byte{{1}}); err == nil {
+		t.Fatal("expected a delta of the wrong length to be refused")
+	}
+}
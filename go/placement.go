@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// PeerCandidate describes a peer that may hold shards
+type PeerCandidate struct {
+	PeerID        uint32
+	Uptime        float64 // Fraction of probes answered (0.0 to 1.0)
+	FreeStorageMB uint64  // Advertised free storage, 0 if unknown
+	LatencyMs     float32 // Smoothed RTT, 0 if unknown
+	BandwidthMbps float32 // Advertised bandwidth, 0 if unknown
+	Trust         float64 // 1.0 - threat score
+	Eligible      bool    // False for peers in purgatory or dead
+}
+
+// PlacementPolicy decides which peer holds each shard
+type PlacementPolicy interface {
+	// Name returns the policy identifier used in configuration
+	Name() string
+	// Assign returns the holder peer ID for each of shardCount shards
+	Assign(candidates []PeerCandidate, shardCount int) ([]uint32, error)
+}
+
+// PlacementWeights controls how ScoredPlacementPolicy combines peer metrics
+type PlacementWeights struct {
+	Uptime  float64
+	Storage float64
+	Latency float64
+	Trust   float64
+}
+
+// DefaultPlacementWeights returns the default scoring weights
+func DefaultPlacementWeights() PlacementWeights {
+	return PlacementWeights{
+		Uptime:  0.3,
+		Storage: 0.2,
+		Latency: 0.25,
+		Trust:   0.25,
+	}
+}
+
+// ScoredPlacementPolicy ranks peers by a weighted score and spreads shards
+// so that better peers hold more shards without concentrating all of them
+type ScoredPlacementPolicy struct {
+	Weights PlacementWeights
+}
+
+// Name implements PlacementPolicy
+func (p *ScoredPlacementPolicy) Name() string { return "scored" }
+
+// Score returns the weighted score (0.0 to 1.0) of a candidate
+func (p *ScoredPlacementPolicy) Score(c PeerCandidate, maxStorageMB uint64) float64 {
+	storageScore := 0.5 // Unknown capacity is treated as average
+	if maxStorageMB > 0 && c.FreeStorageMB > 0 {
+		storageScore = float64(c.FreeStorageMB) / float64(maxStorageMB)
+	}
+
+	latencyScore := 0.5 // Unmeasured peers are treated as average
+	if c.LatencyMs > 0 {
+		latencyScore = 1.0 / (1.0 + float64(c.LatencyMs)/50.0)
+	}
+
+	w := p.Weights
+	total := w.Uptime + w.Storage + w.Latency + w.Trust
+	if total <= 0 {
+		return 0
+	}
+	return (c.Uptime*w.Uptime + storageScore*w.Storage + latencyScore*w.Latency + c.Trust*w.Trust) / total
+}
+
+// Assign implements PlacementPolicy
+func (p *ScoredPlacementPolicy) Assign(candidates []PeerCandidate, shardCount int) ([]uint32, error) {
+	eligible := make([]PeerCandidate, 0, len(candidates))
+	var maxStorage uint64
+	for _, c := range candidates {
+		if !c.Eligible {
+			continue
+		}
+		eligible = append(eligible, c)
+		if c.FreeStorageMB > maxStorage {
+			maxStorage = c.FreeStorageMB
+		}
+	}
+	if len(eligible) == 0 {
+		return nil, fmt.Errorf("no eligible peers for placement")
+	}
+
+	scores := make([]float64, len(eligible))
+	for i, c := range eligible {
+		scores[i] = p.Score(c, maxStorage)
+	}
+
+	// Greedy spread: each shard goes to the peer with the highest score
+	// discounted by how many shards it already holds
+	assigned := make([]int, len(eligible))
+	result := make([]uint32, shardCount)
+	for shard := 0; shard < shardCount; shard++ {
+		best := 0
+		bestValue := -1.0
+		for i := range eligible {
+			value := scores[i] / float64(1+assigned[i])
+			if value > bestValue {
+				best = i
+				bestValue = value
+			}
+		}
+		assigned[best]++
+		result[shard] = eligible[best].PeerID
+	}
+	return result, nil
+}
+
+// RoundRobinPlacementPolicy assigns shards to peers in order, ignoring scores
+type RoundRobinPlacementPolicy struct{}
+
+// Name implements PlacementPolicy
+func (p *RoundRobinPlacementPolicy) Name() string { return "round_robin" }
+
+// Assign implements PlacementPolicy
+func (p *RoundRobinPlacementPolicy) Assign(candidates []PeerCandidate, shardCount int) ([]uint32, error) {
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no peers for placement")
+	}
+	result := make([]uint32, shardCount)
+	for i := range result {
+		result[i] = candidates[i%len(candidates)].PeerID
+	}
+	return result, nil
+}
+
+var (
+	placementPolicies   = make(map[string]func() PlacementPolicy)
+	placementPoliciesMu sync.RWMutex
+)
+
+func init() {
+	RegisterPlacementPolicy("scored", func() PlacementPolicy {
+		return &ScoredPlacementPolicy{Weights: DefaultPlacementWeights()}
+	})
+	RegisterPlacementPolicy("round_robin", func() PlacementPolicy {
+		return &RoundRobinPlacementPolicy{}
+	})
+}
+
+// RegisterPlacementPolicy makes a placement policy selectable by name
+func RegisterPlacementPolicy(name string, factory func() PlacementPolicy) {
+	placementPoliciesMu.Lock()
+	defer placementPoliciesMu.Unlock()
+	placementPolicies[name] = factory
+}
+
+// NewPlacementPolicy returns a registered policy by name
+func NewPlacementPolicy(name string) (PlacementPolicy, error) {
+	placementPoliciesMu.RLock()
+	factory, exists := placementPolicies[name]
+	placementPoliciesMu.RUnlock()
+	if !exists {
+		return nil, fmt.Errorf("unknown placement policy %q", name)
+	}
+	return factory(), nil
+}
+
+// PlacementPolicyNames returns the names of all registered policies
+func PlacementPolicyNames() []string {
+	placementPoliciesMu.RLock()
+	defer placementPoliciesMu.RUnlock()
+	names := make([]string, 0, len(placementPolicies))
+	for name := range placementPolicies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// buildPlacementCandidates gathers scoring inputs for each target peer from
+// the node store, the quality prober, and advertised compute capacity
+func buildPlacementCandidates(network NetworkAdapter, store *NodeStore, peerIDs []uint32) []PeerCandidate {
+	lib, _ := libp2pOf(network)
+
+	candidates := make([]PeerCandidate, 0, len(peerIDs))
+	for _, id := range peerIDs {
+		c := PeerCandidate{
+			PeerID:   id,
+			Uptime:   0.5,
+			Trust:    1.0,
+			Eligible: true,
+		}
+
+		if node, exists := store.GetNode(id); exists {
+			c.Trust = 1.0 - float64(node.ThreatScore)
+			c.Eligible = node.Status == StateActive
+		}
+
+		if lib != nil {
+			if q, ok := lib.GetPeerQuality(id); ok {
+				c.Uptime = q.Availability()
+				c.LatencyMs = q.LatencyMs
+			}
+			if capacity, ok := lib.GetPeerCapacity(id); ok {
+				c.FreeStorageMB = capacity.DiskMB
+				c.BandwidthMbps = capacity.BandwidthMbps
+			}
+		}
+
+		candidates = append(candidates, c)
+	}
+	return candidates
+}
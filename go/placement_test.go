@@ -0,0 +1,68 @@
+package main
+
+import "testing"
+
+func TestScoredPlacementFavorsBetterPeers(t *testing.T) {
+	policy, err := NewPlacementPolicy("scored")
+	if err != nil {
+		t.Fatalf("NewPlacementPolicy failed: %v", err)
+	}
+
+	candidates := []PeerCandidate{
+		{PeerID: 1, Uptime: 1.0, FreeStorageMB: 100000, LatencyMs: 5, Trust: 1.0, Eligible: true},
+		{PeerID: 2, Uptime: 0.4, FreeStorageMB: 1000, LatencyMs: 400, Trust: 0.5, Eligible: true},
+		{PeerID: 3, Uptime: 1.0, FreeStorageMB: 100000, LatencyMs: 5, Trust: 1.0, Eligible: false},
+	}
+
+	holders, err := policy.Assign(candidates, 12)
+	if err != nil {
+		t.Fatalf("Assign failed: %v", err)
+	}
+	if len(holders) != 12 {
+		t.Fatalf("expected 12 holders, got %d", len(holders))
+	}
+
+	counts := make(map[uint32]int)
+	for _, h := range holders {
+		counts[h]++
+	}
+	if counts[3] != 0 {
+		t.Errorf("ineligible peer received %d shards", counts[3])
+	}
+	if counts[1] <= counts[2] {
+		t.Errorf("expected better peer to hold more shards: peer1=%d peer2=%d", counts[1], counts[2])
+	}
+	if counts[2] == 0 {
+		t.Error("expected shards to be spread across eligible peers")
+	}
+}
+
+func TestScoredPlacementNoEligiblePeers(t *testing.T) {
+	policy := &ScoredPlacementPolicy{Weights: DefaultPlacementWeights()}
+	_, err := policy.Assign([]PeerCandidate{{PeerID: 1, Eligible: false}}, 4)
+	if err == nil {
+		t.Error("expected error when no peers are eligible")
+	}
+}
+
+func TestRoundRobinPlacement(t *testing.T) {
+	policy, err := NewPlacementPolicy("round_robin")
+	if err != nil {
+		t.Fatalf("NewPlacementPolicy failed: %v", err)
+	}
+
+	holders, err := policy.Assign([]PeerCandidate{{PeerID: 7}, {PeerID: 8}}, 4)
+	if err != nil {
+		t.Fatalf("Assign failed: %v", err)
+	}
+	expected := []uint32{7, 8, 7, 8}
+	for i := range expected {
+		if holders[i] != expected[i] {
+			t.Errorf("shard %d: expected peer %d, got %d", i, expected[i], holders[i])
+		}
+	}
+
+	if _, err := NewPlacementPolicy("does-not-exist"); err == nil {
+		t.Error("expected error for unknown policy")
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/libp2p/go-libp2p/core/metrics"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+// Protocol categories reported by per-peer protocol statistics
+const (
+	ProtocolCategoryCompute = "compute"
+	ProtocolCategoryShard   = "shard"
+	ProtocolCategoryRelay   = "relay"
+	ProtocolCategoryChat    = "chat"
+	ProtocolCategoryVideo   = "video"
+	ProtocolCategoryVoice   = "voice"
+	ProtocolCategoryOther   = "other"
+)
+
+// protocolCategory maps a libp2p protocol ID to a usage category
+func protocolCategory(proto protocol.ID) string {
+	proto = baseProtocol(proto)
+	switch {
+	case proto == ComputeProtocolID, proto == ComputeProtocolV11, proto == ComputeProtocolV12:
+		return ProtocolCategoryCompute
+	case proto == PangeaRPCProtocol:
+		return ProtocolCategoryShard
+	case proto == RelayProtocolID:
+		return ProtocolCategoryRelay
+	case strings.HasPrefix(string(proto), "/pangea/chat/"):
+		return ProtocolCategoryChat
+	case strings.HasPrefix(string(proto), "/pangea/video/"):
+		return ProtocolCategoryVideo
+	case strings.HasPrefix(string(proto), "/pangea/voice/"):
+		return ProtocolCategoryVoice
+	default:
+		return ProtocolCategoryOther
+	}
+}
+
+// ProtocolUsage counts metered stream reads/writes and bytes for one peer and
+// category. A read or write call is not a protocol frame: one message may be
+// split across several reads, and a write may carry several messages.
+type ProtocolUsage struct {
+	StreamReads  uint64
+	StreamWrites uint64
+	BytesIn      uint64
+	BytesOut     uint64
+}
+
+// ProtocolStats records per-peer, per-category protocol usage. It wraps the
+// host's bandwidth counter so every metered stream is also attributed to a
+// peer and protocol category. Usage covers connected peers only and is
+// dropped when a peer disconnects.
+type ProtocolStats struct {
+	*metrics.BandwidthCounter
+
+	usage map[peer.ID]map[string]*ProtocolUsage
+	mu    sync.RWMutex
+}
+
+// NewProtocolStats creates a reporter that also feeds bwCounter
+func NewProtocolStats(bwCounter *metrics.BandwidthCounter) *ProtocolStats {
+	return &ProtocolStats{
+		BandwidthCounter: bwCounter,
+		usage:            make(map[peer.ID]map[string]*ProtocolUsage),
+	}
+}
+
+// LogSentMessageStream implements metrics.Reporter
+func (ps *ProtocolStats) LogSentMessageStream(size int64, proto protocol.ID, p peer.ID) {
+	ps.BandwidthCounter.LogSentMessageStream(size, proto, p)
+	ps.record(p, proto, size, false)
+}
+
+// LogRecvMessageStream implements metrics.Reporter
+func (ps *ProtocolStats) LogRecvMessageStream(size int64, proto protocol.ID, p peer.ID) {
+	ps.BandwidthCounter.LogRecvMessageStream(size, proto, p)
+	ps.record(p, proto, size, true)
+}
+
+func (ps *ProtocolStats) record(p peer.ID, proto protocol.ID, size int64, inbound bool) {
+	if size < 0 {
+		return
+	}
+	category := protocolCategory(proto)
+
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	byCategory, ok := ps.usage[p]
+	if !ok {
+		byCategory = make(map[string]*ProtocolUsage)
+		ps.usage[p] = byCategory
+	}
+	u, ok := byCategory[category]
+	if !ok {
+		u = &ProtocolUsage{}
+		byCategory[category] = u
+	}
+	if inbound {
+		u.StreamReads++
+		u.BytesIn += uint64(size)
+	} else {
+		u.StreamWrites++
+		u.BytesOut += uint64(size)
+	}
+}
+
+// Forget drops usage recorded for a peer that has disconnected
+func (ps *ProtocolStats) Forget(p peer.ID) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	delete(ps.usage, p)
+}
+
+// ForPeer returns usage per category for a peer
+func (ps *ProtocolStats) ForPeer(p peer.ID) map[string]ProtocolUsage {
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+	out := make(map[string]ProtocolUsage, len(ps.usage[p]))
+	for category, u := range ps.usage[p] {
+		out[category] = *u
+	}
+	return out
+}
+
+// Peers returns all peers with recorded usage
+func (ps *ProtocolStats) Peers() []peer.ID {
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+	peers := make([]peer.ID, 0, len(ps.usage))
+	for p := range ps.usage {
+		peers = append(peers, p)
+	}
+	sort.Slice(peers, func(i, j int) bool { return peers[i] < peers[j] })
+	return peers
+}
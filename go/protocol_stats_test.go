@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+func TestProtocolCategory(t *testing.T) {
+	cases := map[string]string{
+		ComputeProtocolID:    ProtocolCategoryCompute,
+		PangeaRPCProtocol:    ProtocolCategoryShard,
+		RelayProtocolID:      ProtocolCategoryRelay,
+		"/pangea/chat/1.0.0": ProtocolCategoryChat,
+		"/pangea/voice/2.0":  ProtocolCategoryVoice,
+		"/ipfs/ping/1.0.0":   ProtocolCategoryOther,
+	}
+	for proto, want := range cases {
+		if got := protocolCategory(protocol.ID(proto)); got != want {
+			t.Errorf("%s: expected %s, got %s", proto, want, got)
+		}
+	}
+}
+
+func TestProtocolStatsTracksShardTraffic(t *testing.T) {
+	store1 := NewNodeStore()
+	n1, err := NewLibP2PPangeaNodeWithOptions(461, store1, false, true, 12460)
+	if err != nil {
+		t.Fatalf("failed to create node1: %v", err)
+	}
+	defer n1.cancel()
+
+	store2 := NewNodeStore()
+	n2, err := NewLibP2PPangeaNodeWithOptions(462, store2, false, true, 12461)
+	if err != nil {
+		t.Fatalf("failed to create node2: %v", err)
+	}
+	defer n2.cancel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := n1.host.Connect(ctx, peer.AddrInfo{ID: n2.host.ID(), Addrs: n2.host.Addrs()}); err != nil {
+		t.Fatalf("connect n1->n2 failed: %v", err)
+	}
+
+	lib1 := NewLibP2PAdapter(n1, store1)
+	lib1.peerIDToUint32[n2.host.ID().String()] = 2
+	lib1.uint32ToPeerID[2] = n2.host.ID().String()
+
+	shard := make([]byte, 32*1024)
+	if err := lib1.SendShard(2, "stats-test", 0, shard); err != nil {
+		t.Fatalf("SendShard failed: %v", err)
+	}
+
+	sent, err := lib1.GetPeerProtocolStats(2)
+	if err != nil {
+		t.Fatalf("GetPeerProtocolStats failed: %v", err)
+	}
+	if out := sent[2][ProtocolCategoryShard].BytesOut; out < uint64(len(shard)) {
+		t.Errorf("expected at least %d shard bytes sent, got %d", len(shard), out)
+	}
+
+	received := n2.GetProtocolStats().ForPeer(n1.host.ID())[ProtocolCategoryShard]
+	if received.BytesIn < uint64(len(shard)) || received.StreamReads == 0 {
+		t.Errorf("expected shard bytes recorded on receiver, got %+v", received)
+	}
+
+	// Usage is pruned once the peer disconnects (mDNS may reconnect it,
+	// but the shard traffic from before must be gone)
+	if err := n1.host.Network().ClosePeer(n2.host.ID()); err != nil {
+		t.Fatalf("disconnect failed: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for n2.GetProtocolStats().ForPeer(n1.host.ID())[ProtocolCategoryShard].BytesIn > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected usage to be dropped after disconnect")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestBandwidthMetering(t *testing.T) {
+	store1 := NewNodeStore()
+	n1, err := NewLibP2PPangeaNodeWithOptions(689, store1, false, true, 0)
+	if err != nil {
+		t.Fatalf("failed to create node1: %v", err)
+	}
+	defer n1.cancel()
+	n2, err := NewLibP2PPangeaNodeWithOptions(690, NewNodeStore(), false, true, 0)
+	if err != nil {
+		t.Fatalf("failed to create node2: %v", err)
+	}
+	defer n2.cancel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := n1.host.Connect(ctx, peer.AddrInfo{ID: n2.host.ID(), Addrs: n2.host.Addrs()}); err != nil {
+		t.Fatalf("connect n1->n2 failed: %v", err)
+	}
+	lib1 := NewLibP2PAdapter(n1, store1)
+	lib1.peerIDToUint32[n2.host.ID().String()] = 2
+	lib1.uint32ToPeerID[2] = n2.host.ID().String()
+	client := NodeService_ServerToClient(NewNodeServiceServer(store1, lib1, nil))
+	defer client.Release()
+
+	// Rates are sampled once a second, so keep shards flowing until the
+	// upload rate shows
+	shard := make([]byte, 256<<10)
+	var sent uint64
+	var uploadMbps float32
+	for uploadMbps == 0 {
+		if ctx.Err() != nil {
+			t.Fatal("upload rate never reported")
+		}
+		if err := lib1.SendShard(2, "metered", uint32(sent/uint64(len(shard))), shard); err != nil {
+			t.Fatalf("SendShard failed: %v", err)
+		}
+		sent += uint64(len(shard))
+		future, release := client.GetNetworkMetrics(ctx, nil)
+		res, err := future.Struct()
+		if err != nil {
+			release()
+			t.Fatal(err)
+		}
+		metrics, _ := res.Metrics()
+		uploadMbps = metrics.UploadMbps()
+		if metrics.BandwidthMbps() < uploadMbps+metrics.DownloadMbps()-0.001 {
+			t.Errorf("total %.3f Mbps below upload %.3f plus download %.3f", metrics.BandwidthMbps(), uploadMbps, metrics.DownloadMbps())
+		}
+		release()
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	// Per peer and per protocol counters catch up with the shard bytes at
+	// the next sample
+	for {
+		peerOut, err := lib1.GetPeerBandwidth(2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		protoOut := lib1.GetProtocolBandwidth()[PangeaRPCProtocol].TotalOut
+		peerIn := n2.GetBandwidthForPeer(n1.host.ID()).TotalIn
+		if uint64(min(peerOut.TotalOut, protoOut, peerIn)) >= sent {
+			break
+		}
+		if ctx.Err() != nil {
+			t.Fatalf("counters hold %d bytes to the peer, %d on %s and %d received, sent %d",
+				peerOut.TotalOut, protoOut, PangeaRPCProtocol, peerIn, sent)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if _, err := lib1.GetPeerBandwidth(99); err == nil {
+		t.Error("expected an unknown peer to be an error")
+	}
+
+	// getPeerBandwidth reports the same, for one peer or every peer
+	for _, peerID := range []uint32{2, 0} {
+		future, release := client.GetPeerBandwidth(ctx, func(p NodeService_getPeerBandwidth_Params) error {
+			p.SetPeerId(peerID)
+			return nil
+		})
+		res, err := future.Struct()
+		if err != nil {
+			release()
+			t.Fatalf("getPeerBandwidth(%d) failed: %v", peerID, err)
+		}
+		peers, _ := res.Peers()
+		protocols, _ := res.Protocols()
+		if peers.Len() != 1 || peers.At(0).PeerId() != 2 || peers.At(0).TotalOut() < sent {
+			t.Errorf("getPeerBandwidth(%d) gave %d peers, sent %d bytes", peerID, peers.Len(), sent)
+		}
+		found := false
+		for i := 0; i < protocols.Len(); i++ {
+			p := protocols.At(i)
+			if name, _ := p.Protocol(); name == PangeaRPCProtocol && p.TotalOut() >= sent {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("getPeerBandwidth(%d) lacks the %s counter", peerID, PangeaRPCProtocol)
+		}
+		release()
+	}
+}
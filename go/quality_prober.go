@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/p2p/protocol/ping"
+)
+
+const (
+	// DefaultProbeInterval is how often every connected peer is probed
+	DefaultProbeInterval = 10 * time.Second
+	// DefaultProbesPerRound is the number of pings sent to a peer each round
+	DefaultProbesPerRound = 3
+	// probeTimeout bounds a single probe round for one peer
+	probeTimeout = 5 * time.Second
+	// qualityAlpha is the EWMA smoothing factor for new samples
+	qualityAlpha = 0.2
+)
+
+// PeerQuality holds smoothed connection quality for a peer
+type PeerQuality struct {
+	LatencyMs  float32
+	JitterMs   float32
+	PacketLoss float32 // Fraction of probes lost (0.0 to 1.0)
+	Sent       uint64
+	Received   uint64
+	LastProbe  time.Time
+
+	lastRTTMs float32
+}
+
+// Availability returns the fraction of probes the peer has answered
+func (q *PeerQuality) Availability() float64 {
+	if q.Sent == 0 {
+		return 0
+	}
+	return float64(q.Received) / float64(q.Sent)
+}
+
+// record folds one probe round into the smoothed metrics
+func (q *PeerQuality) record(rtts []time.Duration, sent int) {
+	if sent == 0 {
+		return
+	}
+	first := q.Sent == 0
+	q.Sent += uint64(sent)
+	q.Received += uint64(len(rtts))
+	q.LastProbe = time.Now()
+
+	for _, rtt := range rtts {
+		ms := float32(rtt.Microseconds()) / 1000.0
+		if first && q.LatencyMs == 0 {
+			q.LatencyMs = ms
+		} else {
+			q.LatencyMs = q.LatencyMs*(1-qualityAlpha) + ms*qualityAlpha
+		}
+		if q.lastRTTMs > 0 {
+			delta := ms - q.lastRTTMs
+			if delta < 0 {
+				delta = -delta
+			}
+			q.JitterMs = q.JitterMs*(1-qualityAlpha) + delta*qualityAlpha
+		}
+		q.lastRTTMs = ms
+	}
+
+	lossRate := float32(sent-len(rtts)) / float32(sent)
+	if first {
+		q.PacketLoss = lossRate
+	} else {
+		q.PacketLoss = q.PacketLoss*(1-qualityAlpha) + lossRate*qualityAlpha
+	}
+}
+
+// QualityProber periodically pings connected peers and tracks latency,
+// jitter, and packet loss per peer
+type QualityProber struct {
+	host           host.Host
+	ping           *ping.PingService
+	interval       time.Duration
+	probesPerRound int
+
+	quality map[peer.ID]*PeerQuality
+	mu      sync.RWMutex
+
+	// While partitioned, disconnected peers are likely still up, so their
+	// history is kept rather than pruned
+	partition *PartitionDetector
+}
+
+// NewQualityProber creates a prober using the host's ping service
+func NewQualityProber(h host.Host, pingService *ping.PingService, interval time.Duration) *QualityProber {
+	if interval <= 0 {
+		interval = DefaultProbeInterval
+	}
+	return &QualityProber{
+		host:           h,
+		ping:           pingService,
+		interval:       interval,
+		probesPerRound: DefaultProbesPerRound,
+		quality:        make(map[peer.ID]*PeerQuality),
+	}
+}
+
+// Run probes all connected peers every interval until ctx is cancelled
+func (qp *QualityProber) Run(ctx context.Context) {
+	ticker := time.NewTicker(qp.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			qp.ProbeAll(ctx)
+		}
+	}
+}
+
+// ProbeAll runs one probe round against every connected peer
+func (qp *QualityProber) ProbeAll(ctx context.Context) {
+	peers := qp.host.Network().Peers()
+
+	var wg sync.WaitGroup
+	for _, p := range peers {
+		wg.Add(1)
+		go func(p peer.ID) {
+			defer wg.Done()
+			qp.probePeer(ctx, p)
+		}(p)
+	}
+	wg.Wait()
+
+	if qp.partition == nil || !qp.partition.Partitioned() {
+		qp.pruneDisconnected(peers)
+	}
+}
+
+// probePeer sends probesPerRound pings and records the results
+func (qp *QualityProber) probePeer(ctx context.Context, p peer.ID) {
+	probeCtx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	results := qp.ping.Ping(probeCtx, p)
+	rtts := make([]time.Duration, 0, qp.probesPerRound)
+	sent := 0
+	for sent < qp.probesPerRound {
+		res, ok := <-results
+		if !ok {
+			break
+		}
+		sent++
+		if res.Error != nil {
+			// Treat the remaining probes in this round as lost
+			sent = qp.probesPerRound
+			break
+		}
+		rtts = append(rtts, res.RTT)
+	}
+	if sent < qp.probesPerRound {
+		sent = qp.probesPerRound
+	}
+
+	qp.mu.Lock()
+	q, exists := qp.quality[p]
+	if !exists {
+		q = &PeerQuality{}
+		qp.quality[p] = q
+	}
+	q.record(rtts, sent)
+	qp.mu.Unlock()
+
+	if len(rtts) == 0 {
+		log.Printf("❌ Quality probe to %s: all %d probes lost", shortPeerID(p), sent)
+	}
+}
+
+// pruneDisconnected drops metrics for peers that are no longer connected
+func (qp *QualityProber) pruneDisconnected(connected []peer.ID) {
+	live := make(map[peer.ID]bool, len(connected))
+	for _, p := range connected {
+		live[p] = true
+	}
+
+	qp.mu.Lock()
+	defer qp.mu.Unlock()
+	for p := range qp.quality {
+		if !live[p] {
+			delete(qp.quality, p)
+		}
+	}
+}
+
+// Get returns the current quality metrics for a peer
+func (qp *QualityProber) Get(p peer.ID) (PeerQuality, bool) {
+	qp.mu.RLock()
+	defer qp.mu.RUnlock()
+	q, exists := qp.quality[p]
+	if !exists {
+		return PeerQuality{}, false
+	}
+	return *q, true
+}
+
+// Snapshot returns a copy of the metrics for all probed peers
+func (qp *QualityProber) Snapshot() map[peer.ID]PeerQuality {
+	qp.mu.RLock()
+	defer qp.mu.RUnlock()
+	out := make(map[peer.ID]PeerQuality, len(qp.quality))
+	for p, q := range qp.quality {
+		out[p] = *q
+	}
+	return out
+}
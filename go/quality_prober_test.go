@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestPeerQualityRecord(t *testing.T) {
+	var q PeerQuality
+
+	q.record([]time.Duration{10 * time.Millisecond, 10 * time.Millisecond, 10 * time.Millisecond}, 3)
+	if q.LatencyMs < 9.9 || q.LatencyMs > 10.1 {
+		t.Errorf("expected latency ~10ms, got %.2f", q.LatencyMs)
+	}
+	if q.JitterMs != 0 {
+		t.Errorf("expected zero jitter for constant RTT, got %.2f", q.JitterMs)
+	}
+	if q.PacketLoss != 0 {
+		t.Errorf("expected no loss, got %.2f", q.PacketLoss)
+	}
+
+	q.record([]time.Duration{30 * time.Millisecond}, 3)
+	if q.JitterMs <= 0 {
+		t.Error("expected jitter to rise after RTT change")
+	}
+	if q.PacketLoss <= 0 {
+		t.Error("expected packet loss to rise after lost probes")
+	}
+	if q.Sent != 6 || q.Received != 4 {
+		t.Errorf("expected 6 sent / 4 received, got %d / %d", q.Sent, q.Received)
+	}
+}
+
+func TestQualityProberMeasuresConnectedPeer(t *testing.T) {
+	store1 := NewNodeStore()
+	n1, err := NewLibP2PPangeaNodeWithOptions(421, store1, false, true, 12420)
+	if err != nil {
+		t.Fatalf("failed to create node1: %v", err)
+	}
+	defer n1.cancel()
+
+	store2 := NewNodeStore()
+	n2, err := NewLibP2PPangeaNodeWithOptions(422, store2, false, true, 12421)
+	if err != nil {
+		t.Fatalf("failed to create node2: %v", err)
+	}
+	defer n2.cancel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := n1.host.Connect(ctx, peer.AddrInfo{ID: n2.host.ID(), Addrs: n2.host.Addrs()}); err != nil {
+		t.Fatalf("connect n1->n2 failed: %v", err)
+	}
+
+	n1.prober.ProbeAll(ctx)
+
+	q, ok := n1.GetPeerQuality(n2.host.ID())
+	if !ok {
+		t.Fatal("expected quality metrics for connected peer")
+	}
+	if q.Received == 0 {
+		t.Errorf("expected successful probes, got %d/%d", q.Received, q.Sent)
+	}
+
+	lib1 := NewLibP2PAdapter(n1, store1)
+	lib1.peerIDToUint32[n2.host.ID().String()] = 2
+	lib1.uint32ToPeerID[2] = n2.host.ID().String()
+
+	latency, _, loss, err := lib1.GetConnectionQuality(2)
+	if err != nil {
+		t.Fatalf("GetConnectionQuality failed: %v", err)
+	}
+	if latency != q.LatencyMs || loss != q.PacketLoss {
+		t.Errorf("adapter returned %.2f/%.2f, prober has %.2f/%.2f", latency, loss, q.LatencyMs, q.PacketLoss)
+	}
+}
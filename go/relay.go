@@ -0,0 +1,563 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+const (
+	// RelayProtocolID is the libp2p protocol for store-and-forward relaying
+	RelayProtocolID = "/pangea/relay/1.0.0"
+
+	// Message types for relay protocol
+	MsgTypeRelayOptIn    uint8 = 1 // Recipient asks relay to hold items for it
+	MsgTypeRelayOptOut   uint8 = 2 // Recipient withdraws consent, pending items are dropped
+	MsgTypeRelayDeposit  uint8 = 3 // Sender leaves an item for an opted-in recipient
+	MsgTypeRelayAnnounce uint8 = 4 // Recipient announces presence and pulls pending items
+	MsgTypeRelayDeliver  uint8 = 5 // Relay pushes one pending item to the recipient
+	MsgTypeRelayAck      uint8 = 6 // Generic response carrying a RelayAck
+
+	// Default per-recipient quotas
+	DefaultRelayMaxBytesPerRecipient = 64 * 1024 * 1024
+	DefaultRelayMaxItemsPerRecipient = 1024
+
+	// DefaultRelayMaxTotalBytes caps bytes held across all recipients
+	DefaultRelayMaxTotalBytes = 256 * 1024 * 1024
+
+	// maxRelayDepositAttempts bounds how many peers a sender asks to relay one item
+	maxRelayDepositAttempts = 8
+
+	// maxRelayFrameSize bounds a single relay protocol frame
+	maxRelayFrameSize = 8 * 1024 * 1024
+)
+
+// RelayItemKind identifies what a relayed item carries
+type RelayItemKind string
+
+const (
+	RelayItemShard   RelayItemKind = "shard"
+	RelayItemMessage RelayItemKind = "message"
+)
+
+// RelayItem is a shard or message held for an offline recipient
+type RelayItem struct {
+	Kind       RelayItemKind `json:"kind"`
+	From       string        `json:"from"`
+	Recipient  string        `json:"recipient"`
+	FileHash   string        `json:"fileHash,omitempty"`
+	ShardIndex uint32        `json:"shardIndex,omitempty"`
+	Payload    []byte        `json:"payload"`
+	StoredAt   int64         `json:"storedAt"`
+}
+
+// RelayAck is the relay's answer to opt-in, opt-out, and deposit requests
+type RelayAck struct {
+	Accepted bool   `json:"accepted"`
+	Error    string `json:"error,omitempty"`
+}
+
+// relayMailbox holds pending items for one recipient
+type relayMailbox struct {
+	items []RelayItem
+	bytes int
+}
+
+// RelayService implements the store-forward relay role. A node only holds
+// items when it has relaying enabled AND the recipient has opted in with it.
+type RelayService struct {
+	host host.Host
+
+	// Relay side
+	enabled         bool
+	optedIn         map[peer.ID]bool
+	mailboxes       map[peer.ID]*relayMailbox
+	maxBytesPerPeer int
+	maxItemsPerPeer int
+	maxTotalBytes   int
+	totalBytes      int
+	relayMu         sync.RWMutex
+
+	// Recipient side
+	onShard  func(fileHash string, shardIndex uint32, data []byte) error
+	inbox    []RelayItem
+	inboxMu  sync.Mutex
+	relays   map[peer.ID]bool
+	relaysMu sync.RWMutex
+}
+
+// NewRelayService creates a relay service with relaying disabled
+func NewRelayService(h host.Host, onShard func(fileHash string, shardIndex uint32, data []byte) error) *RelayService {
+	rs := &RelayService{
+		host:            h,
+		optedIn:         make(map[peer.ID]bool),
+		mailboxes:       make(map[peer.ID]*relayMailbox),
+		maxBytesPerPeer: DefaultRelayMaxBytesPerRecipient,
+		maxItemsPerPeer: DefaultRelayMaxItemsPerRecipient,
+		maxTotalBytes:   DefaultRelayMaxTotalBytes,
+		onShard:         onShard,
+		relays:          make(map[peer.ID]bool),
+	}
+	h.SetStreamHandler(protocol.ID(RelayProtocolID), rs.handleStream)
+	return rs
+}
+
+// SetRelayEnabled turns the relay-storage role on or off for this node
+func (rs *RelayService) SetRelayEnabled(enabled bool) {
+	rs.relayMu.Lock()
+	defer rs.relayMu.Unlock()
+	rs.enabled = enabled
+	if !enabled {
+		rs.optedIn = make(map[peer.ID]bool)
+		rs.mailboxes = make(map[peer.ID]*relayMailbox)
+		rs.totalBytes = 0
+	}
+	log.Printf("📮 [RELAY] Relay role enabled=%t", enabled)
+}
+
+// SetQuota sets per-recipient limits for held items
+func (rs *RelayService) SetQuota(maxBytes, maxItems int) {
+	rs.relayMu.Lock()
+	defer rs.relayMu.Unlock()
+	rs.maxBytesPerPeer = maxBytes
+	rs.maxItemsPerPeer = maxItems
+}
+
+// SetTotalQuota sets the limit on bytes held across all recipients
+func (rs *RelayService) SetTotalQuota(maxBytes int) {
+	rs.relayMu.Lock()
+	defer rs.relayMu.Unlock()
+	rs.maxTotalBytes = maxBytes
+}
+
+// IsRelayEnabled reports whether this node holds items for other peers
+func (rs *RelayService) IsRelayEnabled() bool {
+	rs.relayMu.RLock()
+	defer rs.relayMu.RUnlock()
+	return rs.enabled
+}
+
+// PendingFor returns the number of items and bytes held for a recipient
+func (rs *RelayService) PendingFor(recipient peer.ID) (items int, bytes int) {
+	rs.relayMu.RLock()
+	defer rs.relayMu.RUnlock()
+	if mb, ok := rs.mailboxes[recipient]; ok {
+		return len(mb.items), mb.bytes
+	}
+	return 0, 0
+}
+
+// handleStream dispatches incoming relay protocol messages
+func (rs *RelayService) handleStream(s network.Stream) {
+	defer s.Close()
+
+	remote := s.Conn().RemotePeer()
+	msgType, payload, err := readRelayFrame(s)
+	if err != nil {
+		log.Printf("❌ [RELAY] Failed to read frame from %s: %v", shortPeerID(remote), err)
+		return
+	}
+
+	switch msgType {
+	case MsgTypeRelayOptIn:
+		writeRelayAck(s, rs.acceptOptIn(remote))
+	case MsgTypeRelayOptOut:
+		rs.relayMu.Lock()
+		delete(rs.optedIn, remote)
+		if mb, ok := rs.mailboxes[remote]; ok {
+			rs.totalBytes -= mb.bytes
+			delete(rs.mailboxes, remote)
+		}
+		rs.relayMu.Unlock()
+		writeRelayAck(s, RelayAck{Accepted: true})
+	case MsgTypeRelayDeposit:
+		var item RelayItem
+		if err := json.Unmarshal(payload, &item); err != nil {
+			writeRelayAck(s, RelayAck{Error: "malformed deposit"})
+			return
+		}
+		item.From = remote.String()
+		writeRelayAck(s, rs.deposit(item))
+	case MsgTypeRelayAnnounce:
+		items := rs.drain(remote)
+		if sent, err := writeRelayDeliveries(s, items); err != nil {
+			log.Printf("❌ [RELAY] Failed to deliver to %s: %v", shortPeerID(remote), err)
+			rs.requeue(remote, items[sent:])
+		}
+	case MsgTypeRelayDeliver:
+		rs.relaysMu.RLock()
+		trusted := rs.relays[remote]
+		rs.relaysMu.RUnlock()
+		if !trusted {
+			log.Printf("⚠️  [RELAY] Ignoring delivery from %s: not an opted-in relay", shortPeerID(remote))
+			return
+		}
+		items, err := readRelayDeliveries(s, payload)
+		if err != nil {
+			log.Printf("❌ [RELAY] Malformed delivery from %s: %v", shortPeerID(remote), err)
+		}
+		rs.receive(items)
+	default:
+		log.Printf("❌ [RELAY] Unknown message type: %d", msgType)
+	}
+}
+
+// acceptOptIn records recipient consent if this node is acting as a relay
+func (rs *RelayService) acceptOptIn(recipient peer.ID) RelayAck {
+	rs.relayMu.Lock()
+	defer rs.relayMu.Unlock()
+	if !rs.enabled {
+		return RelayAck{Error: "relay role not enabled on this node"}
+	}
+	rs.optedIn[recipient] = true
+	log.Printf("📮 [RELAY] %s opted in for store-forward", shortPeerID(recipient))
+	return RelayAck{Accepted: true}
+}
+
+// deposit holds an item for a recipient subject to consent and quotas
+func (rs *RelayService) deposit(item RelayItem) RelayAck {
+	recipient, err := peer.Decode(item.Recipient)
+	if err != nil {
+		return RelayAck{Error: "invalid recipient"}
+	}
+
+	rs.relayMu.Lock()
+	defer rs.relayMu.Unlock()
+	if !rs.enabled {
+		return RelayAck{Error: "relay role not enabled on this node"}
+	}
+	if !rs.optedIn[recipient] {
+		return RelayAck{Error: "recipient has not opted in to this relay"}
+	}
+
+	mb, ok := rs.mailboxes[recipient]
+	if !ok {
+		mb = &relayMailbox{}
+		rs.mailboxes[recipient] = mb
+	}
+	if len(mb.items)+1 > rs.maxItemsPerPeer || mb.bytes+len(item.Payload) > rs.maxBytesPerPeer {
+		return RelayAck{Error: "recipient quota exceeded"}
+	}
+	if rs.totalBytes+len(item.Payload) > rs.maxTotalBytes {
+		return RelayAck{Error: "relay storage full"}
+	}
+
+	item.StoredAt = time.Now().Unix()
+	mb.items = append(mb.items, item)
+	mb.bytes += len(item.Payload)
+	rs.totalBytes += len(item.Payload)
+	log.Printf("📮 [RELAY] Holding %s for %s (%d items, %d bytes pending)",
+		item.Kind, shortPeerID(recipient), len(mb.items), mb.bytes)
+	return RelayAck{Accepted: true}
+}
+
+// drain removes and returns all pending items for a recipient
+func (rs *RelayService) drain(recipient peer.ID) []RelayItem {
+	rs.relayMu.Lock()
+	defer rs.relayMu.Unlock()
+	mb, ok := rs.mailboxes[recipient]
+	if !ok {
+		return nil
+	}
+	delete(rs.mailboxes, recipient)
+	rs.totalBytes -= mb.bytes
+	return mb.items
+}
+
+// requeue puts items back after a failed delivery
+func (rs *RelayService) requeue(recipient peer.ID, items []RelayItem) {
+	if len(items) == 0 {
+		return
+	}
+	rs.relayMu.Lock()
+	defer rs.relayMu.Unlock()
+	mb, ok := rs.mailboxes[recipient]
+	if !ok {
+		mb = &relayMailbox{}
+		rs.mailboxes[recipient] = mb
+	}
+	for _, item := range items {
+		mb.items = append(mb.items, item)
+		mb.bytes += len(item.Payload)
+		rs.totalBytes += len(item.Payload)
+	}
+}
+
+// receive stores delivered items on the recipient side
+func (rs *RelayService) receive(items []RelayItem) {
+	for _, item := range items {
+		switch item.Kind {
+		case RelayItemShard:
+			if rs.onShard != nil {
+				if err := rs.onShard(item.FileHash, item.ShardIndex, item.Payload); err != nil {
+					log.Printf("❌ [RELAY] Failed to store relayed shard %d: %v", item.ShardIndex, err)
+				}
+			}
+		default:
+			rs.inboxMu.Lock()
+			rs.inbox = append(rs.inbox, item)
+			rs.inboxMu.Unlock()
+		}
+	}
+	if len(items) > 0 {
+		log.Printf("📬 [RELAY] Received %d relayed items", len(items))
+	}
+}
+
+// PeerConnected pushes pending items when an opted-in recipient comes online
+func (rs *RelayService) PeerConnected(p peer.ID) {
+	rs.relayMu.RLock()
+	pending := rs.enabled && rs.optedIn[p] && rs.mailboxes[p] != nil
+	rs.relayMu.RUnlock()
+	if !pending {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := rs.push(ctx, p); err != nil {
+			log.Printf("❌ [RELAY] Push to %s failed: %v", shortPeerID(p), err)
+		}
+	}()
+}
+
+// push delivers all pending items to a connected recipient
+func (rs *RelayService) push(ctx context.Context, recipient peer.ID) error {
+	items := rs.drain(recipient)
+	if len(items) == 0 {
+		return nil
+	}
+	s, err := rs.host.NewStream(ctx, recipient, protocol.ID(RelayProtocolID))
+	if err != nil {
+		rs.requeue(recipient, items)
+		return err
+	}
+	defer s.Close()
+
+	if sent, err := writeRelayDeliveries(s, items); err != nil {
+		rs.requeue(recipient, items[sent:])
+		return err
+	}
+	log.Printf("📤 [RELAY] Pushed %d items to %s", len(items), shortPeerID(recipient))
+	return nil
+}
+
+// OptIn registers this node as a recipient with a relay peer
+func (rs *RelayService) OptIn(ctx context.Context, relay peer.ID) error {
+	rs.relaysMu.Lock()
+	rs.relays[relay] = true
+	rs.relaysMu.Unlock()
+
+	ack, err := rs.request(ctx, relay, MsgTypeRelayOptIn, nil)
+	if err != nil {
+		return err
+	}
+	if !ack.Accepted {
+		rs.relaysMu.Lock()
+		delete(rs.relays, relay)
+		rs.relaysMu.Unlock()
+		return fmt.Errorf("relay refused opt-in: %s", ack.Error)
+	}
+	return nil
+}
+
+// OptOut withdraws consent from a relay peer
+func (rs *RelayService) OptOut(ctx context.Context, relay peer.ID) error {
+	rs.relaysMu.Lock()
+	delete(rs.relays, relay)
+	rs.relaysMu.Unlock()
+
+	_, err := rs.request(ctx, relay, MsgTypeRelayOptOut, nil)
+	return err
+}
+
+// Deposit asks a relay to hold an item for an offline recipient
+func (rs *RelayService) Deposit(ctx context.Context, relay peer.ID, item RelayItem) error {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	ack, err := rs.request(ctx, relay, MsgTypeRelayDeposit, data)
+	if err != nil {
+		return err
+	}
+	if !ack.Accepted {
+		return fmt.Errorf("relay rejected deposit: %s", ack.Error)
+	}
+	return nil
+}
+
+// DepositAny offers an item to each candidate relay in turn and returns the
+// first one that accepts it. Candidates that are not relaying or that the
+// recipient has not opted in with refuse the deposit.
+func (rs *RelayService) DepositAny(ctx context.Context, candidates []peer.ID, item RelayItem) (peer.ID, error) {
+	var lastErr error = fmt.Errorf("no relay candidates")
+	attempts := 0
+	for _, relay := range candidates {
+		if relay.String() == item.Recipient || relay == rs.host.ID() {
+			continue
+		}
+		if attempts == maxRelayDepositAttempts {
+			break
+		}
+		attempts++
+		if err := rs.Deposit(ctx, relay, item); err != nil {
+			lastErr = err
+			continue
+		}
+		return relay, nil
+	}
+	return "", lastErr
+}
+
+// Announce tells a relay this node is online and collects pending items
+func (rs *RelayService) Announce(ctx context.Context, relay peer.ID) (int, error) {
+	s, err := rs.host.NewStream(ctx, relay, protocol.ID(RelayProtocolID))
+	if err != nil {
+		return 0, err
+	}
+	defer s.Close()
+
+	if err := writeRelayFrame(s, MsgTypeRelayAnnounce, nil); err != nil {
+		return 0, err
+	}
+	// Relay answers with one delivery frame per pending item, then closes
+	msgType, payload, err := readRelayFrame(s)
+	if err == io.EOF {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if msgType != MsgTypeRelayDeliver {
+		return 0, fmt.Errorf("unexpected response type: %d", msgType)
+	}
+	items, err := readRelayDeliveries(s, payload)
+	rs.receive(items)
+	return len(items), err
+}
+
+// Inbox returns and clears relayed messages received by this node
+func (rs *RelayService) Inbox() []RelayItem {
+	rs.inboxMu.Lock()
+	defer rs.inboxMu.Unlock()
+	items := rs.inbox
+	rs.inbox = nil
+	return items
+}
+
+// request sends a single frame and waits for a RelayAck
+func (rs *RelayService) request(ctx context.Context, p peer.ID, msgType uint8, payload []byte) (*RelayAck, error) {
+	s, err := rs.host.NewStream(ctx, p, protocol.ID(RelayProtocolID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open relay stream: %w", err)
+	}
+	defer s.Close()
+
+	if err := writeRelayFrame(s, msgType, payload); err != nil {
+		return nil, err
+	}
+	respType, respData, err := readRelayFrame(s)
+	if err != nil {
+		return nil, err
+	}
+	if respType != MsgTypeRelayAck {
+		return nil, fmt.Errorf("unexpected response type: %d", respType)
+	}
+	var ack RelayAck
+	if err := json.Unmarshal(respData, &ack); err != nil {
+		return nil, err
+	}
+	return &ack, nil
+}
+
+// writeRelayFrame writes [type(1)][length(4)][payload]
+func writeRelayFrame(w io.Writer, msgType uint8, payload []byte) error {
+	buf := bytes.NewBuffer(nil)
+	buf.WriteByte(msgType)
+	binary.Write(buf, binary.BigEndian, uint32(len(payload)))
+	buf.Write(payload)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// readRelayFrame reads a frame written by writeRelayFrame
+func readRelayFrame(r io.Reader) (uint8, []byte, error) {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+	length := binary.BigEndian.Uint32(header[1:])
+	if length > maxRelayFrameSize {
+		return 0, nil, fmt.Errorf("relay frame too large: %d bytes", length)
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	return header[0], payload, nil
+}
+
+// writeRelayDeliveries writes one delivery frame per item and returns how
+// many were written before any error
+func writeRelayDeliveries(w io.Writer, items []RelayItem) (int, error) {
+	for i, item := range items {
+		data, err := json.Marshal(item)
+		if err != nil {
+			return i, err
+		}
+		if err := writeRelayFrame(w, MsgTypeRelayDeliver, data); err != nil {
+			return i, err
+		}
+	}
+	return len(items), nil
+}
+
+// readRelayDeliveries decodes the first delivery payload and any further
+// delivery frames until the sender closes the stream
+func readRelayDeliveries(r io.Reader, first []byte) ([]RelayItem, error) {
+	var items []RelayItem
+	payload := first
+	for {
+		var item RelayItem
+		if err := json.Unmarshal(payload, &item); err != nil {
+			return items, err
+		}
+		items = append(items, item)
+
+		msgType, next, err := readRelayFrame(r)
+		if err == io.EOF {
+			return items, nil
+		}
+		if err != nil {
+			return items, err
+		}
+		if msgType != MsgTypeRelayDeliver {
+			return items, fmt.Errorf("unexpected frame type: %d", msgType)
+		}
+		payload = next
+	}
+}
+
+// writeRelayAck writes a RelayAck response frame
+func writeRelayAck(w io.Writer, ack RelayAck) {
+	data, err := json.Marshal(ack)
+	if err != nil {
+		log.Printf("❌ [RELAY] Failed to marshal ack: %v", err)
+		return
+	}
+	if err := writeRelayFrame(w, MsgTypeRelayAck, data); err != nil {
+		log.Printf("❌ [RELAY] Failed to write ack: %v", err)
+	}
+}
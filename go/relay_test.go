@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestRelayStoreForwardRequiresOptIn(t *testing.T) {
+	relayStore := NewNodeStore()
+	relayNode, err := NewLibP2PPangeaNodeWithOptions(431, relayStore, false, true, 12430)
+	if err != nil {
+		t.Fatalf("failed to create relay node: %v", err)
+	}
+	defer relayNode.cancel()
+
+	recipientStore := NewNodeStore()
+	recipient, err := NewLibP2PPangeaNodeWithOptions(432, recipientStore, false, true, 12431)
+	if err != nil {
+		t.Fatalf("failed to create recipient node: %v", err)
+	}
+	defer recipient.cancel()
+
+	senderStore := NewNodeStore()
+	sender, err := NewLibP2PPangeaNodeWithOptions(433, senderStore, false, true, 12432)
+	if err != nil {
+		t.Fatalf("failed to create sender node: %v", err)
+	}
+	defer sender.cancel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	relayInfo := peer.AddrInfo{ID: relayNode.host.ID(), Addrs: relayNode.host.Addrs()}
+	if err := recipient.host.Connect(ctx, relayInfo); err != nil {
+		t.Fatalf("connect recipient->relay failed: %v", err)
+	}
+	if err := sender.host.Connect(ctx, relayInfo); err != nil {
+		t.Fatalf("connect sender->relay failed: %v", err)
+	}
+
+	item := RelayItem{
+		Kind:       RelayItemShard,
+		Recipient:  recipient.host.ID().String(),
+		FileHash:   "relay-file",
+		ShardIndex: 3,
+		Payload:    []byte("held shard bytes"),
+	}
+
+	// Relay role disabled: opt-in must be refused
+	if err := recipient.relay.OptIn(ctx, relayNode.host.ID()); err == nil {
+		t.Fatal("expected opt-in to fail when relay role is disabled")
+	}
+
+	relayNode.relay.SetRelayEnabled(true)
+
+	// Recipient has not opted in yet: deposit must be refused
+	if err := sender.relay.Deposit(ctx, relayNode.host.ID(), item); err == nil {
+		t.Fatal("expected deposit to fail before recipient opts in")
+	}
+
+	if err := recipient.relay.OptIn(ctx, relayNode.host.ID()); err != nil {
+		t.Fatalf("OptIn failed: %v", err)
+	}
+	if err := sender.relay.Deposit(ctx, relayNode.host.ID(), item); err != nil {
+		t.Fatalf("Deposit failed: %v", err)
+	}
+
+	items, _ := relayNode.relay.PendingFor(recipient.host.ID())
+	if items != 1 {
+		t.Fatalf("expected 1 pending item on relay, got %d", items)
+	}
+
+	delivered, err := recipient.relay.Announce(ctx, relayNode.host.ID())
+	if err != nil {
+		t.Fatalf("Announce failed: %v", err)
+	}
+	if delivered != 1 {
+		t.Fatalf("expected 1 delivered item, got %d", delivered)
+	}
+
+	data, ok := recipient.FetchLocalShard("relay-file", 3)
+	if !ok || string(data) != "held shard bytes" {
+		t.Fatalf("relayed shard not stored on recipient: %q", data)
+	}
+}
+
+func TestRelayQuotaPerRecipient(t *testing.T) {
+	store := NewNodeStore()
+	node, err := NewLibP2PPangeaNodeWithOptions(434, store, false, true, 12433)
+	if err != nil {
+		t.Fatalf("failed to create node: %v", err)
+	}
+	defer node.cancel()
+
+	rs := node.relay
+	rs.SetRelayEnabled(true)
+	rs.SetQuota(10, 5)
+
+	recipient := node.host.ID()
+	rs.acceptOptIn(recipient)
+
+	ack := rs.deposit(RelayItem{Kind: RelayItemMessage, Recipient: recipient.String(), Payload: []byte("12345678")})
+	if !ack.Accepted {
+		t.Fatalf("expected first deposit to be accepted: %s", ack.Error)
+	}
+	ack = rs.deposit(RelayItem{Kind: RelayItemMessage, Recipient: recipient.String(), Payload: []byte("12345")})
+	if ack.Accepted {
+		t.Fatal("expected deposit over byte quota to be rejected")
+	}
+}
+
+func TestRelayTotalQuotaAcrossRecipients(t *testing.T) {
+	store := NewNodeStore()
+	node, err := NewLibP2PPangeaNodeWithOptions(435, store, false, true, 12434)
+	if err != nil {
+		t.Fatalf("failed to create node: %v", err)
+	}
+	defer node.cancel()
+
+	rs := node.relay
+	rs.SetRelayEnabled(true)
+	rs.SetTotalQuota(10)
+
+	var recipients []peer.ID
+	for _, s := range []string{
+		"12D3KooWKrmQuKLFGCN3JX1ajteTvRv4m5hKkLh7YKiwZUf3huqq",
+		"12D3KooWPpf68KLxEYQq1DZLNDudJsDAsisXjBKybWxVUUeeyQCW",
+	} {
+		p, err := peer.Decode(s)
+		if err != nil {
+			t.Fatalf("decode peer: %v", err)
+		}
+		rs.acceptOptIn(p)
+		recipients = append(recipients, p)
+	}
+
+	ack := rs.deposit(RelayItem{Kind: RelayItemMessage, Recipient: recipients[0].String(), Payload: []byte("12345678")})
+	if !ack.Accepted {
+		t.Fatalf("expected first deposit to be accepted: %s", ack.Error)
+	}
+	ack = rs.deposit(RelayItem{Kind: RelayItemMessage, Recipient: recipients[1].String(), Payload: []byte("12345")})
+	if ack.Accepted {
+		t.Fatal("expected deposit over the total quota to be rejected")
+	}
+
+	// Draining one mailbox frees space for others
+	rs.drain(recipients[0])
+	ack = rs.deposit(RelayItem{Kind: RelayItemMessage, Recipient: recipients[1].String(), Payload: []byte("12345")})
+	if !ack.Accepted {
+		t.Fatalf("expected deposit after drain to be accepted: %s", ack.Error)
+	}
+}
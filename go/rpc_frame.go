@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Frames on PangeaRPCProtocol streams:
+//
+//	[magic "PG"(2)][version(1)][type(1)][length(4)][crc32c(payload)(4)][payload]
+//
+// Integers are big endian. The header layout is the same in every version,
+// so a peer can always read the hello that opens each stream: the opener
+// sends its supported version range, the other side answers with the
+// version both will use or an error frame, and then one request follows.
+const (
+	rpcMagic      = "PG"
+	rpcHeaderSize = 12
+
+	// RPC protocol versions this node speaks. Version 1 sent each shard in
+	// a single frame; version 2 streams it in chunks (see shard_stream.go).
+	rpcMinVersion uint8 = 2
+	rpcMaxVersion uint8 = 2
+
+	// rpcMaxPayload covers a shard chunk or DKG share plus its request fields
+	rpcMaxPayload = shardChunkSize + 64*1024
+
+	// rpcTimeout bounds a whole request, shard transfer included
+	rpcTimeout = 2 * time.Minute
+)
+
+var rpcCRCTable = crc32.MakeTable(crc32.Castagnoli)
+
+// rpcMsgType identifies a frame's payload
+type rpcMsgType uint8
+
+const (
+	rpcMsgHello             rpcMsgType = 1  // [min(1)][max(1)], answered with [version(1)]
+	rpcMsgError             rpcMsgType = 2  // [message]
+	rpcMsgFetchShard        rpcMsgType = 3  // [fileHash][shardIndex(4)][grant, optional], see access_control.go
+	rpcMsgShardBegin        rpcMsgType = 4  // [size(8)], then the shard's chunks
+	rpcMsgFetchShare        rpcMsgType = 5  // [fileID]
+	rpcMsgShareData         rpcMsgType = 6  // [share]
+	rpcMsgStoreShard        rpcMsgType = 7  // [fileHash][shardIndex(4)][size(8)][flags(1), optional]
+	rpcMsgStoreShardAck     rpcMsgType = 8  // [status(1)][sha256(shard)(32)]
+	rpcMsgStoreShare        rpcMsgType = 9  // [fileID][fromPeer(4)][share]
+	rpcMsgStoreShareAck     rpcMsgType = 10 // empty
+	rpcMsgData              rpcMsgType = 11 // Opaque SendMessage payload
+	rpcMsgShardReady        rpcMsgType = 12 // empty; the storing peer takes the shard
+	rpcMsgShardChunk        rpcMsgType = 13 // [chunkIndex(4)][bytes]
+	rpcMsgShardEnd          rpcMsgType = 14 // [sha256(shard)(32)]
+	rpcMsgChunkRequest      rpcMsgType = 15 // [chunkIndex(4)]... to send again
+	rpcMsgDKG               rpcMsgType = 16 // [groupID][sessionID][step(1)][body], see dkg_network.go
+	rpcMsgDKGReply          rpcMsgType = 17 // [body]
+	rpcMsgShareRefresh      rpcMsgType = 18 // [fileID][sessionID][step(1)][body], see share_refresh.go
+	rpcMsgShareRefreshReply rpcMsgType = 19 // [body]
+	rpcMsgRevokeGrant       rpcMsgType = 20 // [grantID][fileHash][expires(8)]
+	rpcMsgRevokeGrantAck    rpcMsgType = 21 // empty
+	rpcMsgDeleteShards      rpcMsgType = 22 // [fileHash], see key_rotation.go
+	rpcMsgDeleteShardsAck   rpcMsgType = 23 // [shardsDeleted(4)]
+)
+
+// Strings in payloads are [length(2)][bytes]
+
+// errRPCBadFrame marks frames that break the protocol, as opposed to
+// streams that simply ended
+var errRPCBadFrame = errors.New("malformed RPC frame")
+
+// errRPCChecksum is returned for a frame whose payload arrived intact in
+// length but not in content; the stream is still in sync after it
+var errRPCChecksum = fmt.Errorf("%w: checksum mismatch", errRPCBadFrame)
+
+// rpcFrame is one decoded frame
+type rpcFrame struct {
+	Version uint8
+	Type    rpcMsgType
+	Payload []byte
+}
+
+// writeRPCFrame encodes and writes one frame
+func writeRPCFrame(w io.Writer, version uint8, t rpcMsgType, payload []byte) error {
+	if len(payload) > rpcMaxPayload {
+		return fmt.Errorf("RPC payload of %d bytes exceeds %d", len(payload), rpcMaxPayload)
+	}
+	header := make([]byte, rpcHeaderSize, rpcHeaderSize+len(payload))
+	copy(header, rpcMagic)
+	header[2] = version
+	header[3] = byte(t)
+	binary.BigEndian.PutUint32(header[4:], uint32(len(payload)))
+	binary.BigEndian.PutUint32(header[8:], crc32.Checksum(payload, rpcCRCTable))
+	_, err := w.Write(append(header, payload...))
+	return err
+}
+
+// readRPCFrame reads and verifies one frame. A frame over rpcMaxPayload is
+// reported with its header filled in but without reading the payload.
+func readRPCFrame(r io.Reader) (rpcFrame, error) {
+	header := make([]byte, rpcHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return rpcFrame{}, err
+	}
+	if string(header[:2]) != rpcMagic {
+		return rpcFrame{}, fmt.Errorf("%w: bad magic %x", errRPCBadFrame, header[:2])
+	}
+	frame := rpcFrame{Version: header[2], Type: rpcMsgType(header[3])}
+	length := binary.BigEndian.Uint32(header[4:])
+	if length > rpcMaxPayload {
+		return frame, fmt.Errorf("%w: %d byte payload exceeds %d", errRPCBadFrame, length, rpcMaxPayload)
+	}
+	frame.Payload = make([]byte, length)
+	if _, err := io.ReadFull(r, frame.Payload); err != nil {
+		return frame, err
+	}
+	if crc32.Checksum(frame.Payload, rpcCRCTable) != binary.BigEndian.Uint32(header[8:]) {
+		return frame, errRPCChecksum
+	}
+	return frame, nil
+}
+
+// rpcStream is a PangeaRPCProtocol stream after version negotiation
+type rpcStream struct {
+	network.Stream
+	version uint8
+	pace    func(n int) error // Waits before each frame sent; may be nil
+}
+
+// openRPCStream opens a stream to p and negotiates the protocol version
+func openRPCStream(ctx context.Context, h host.Host, p peer.ID) (*rpcStream, error) {
+	s, err := h.NewStream(ctx, p, PangeaRPCProtocol)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open stream: %w", err)
+	}
+	s.SetDeadline(time.Now().Add(rpcTimeout))
+
+	rs := &rpcStream{Stream: s, version: rpcMinVersion}
+	if err := rs.send(rpcMsgHello, []byte{rpcMinVersion, rpcMaxVersion}); err != nil {
+		s.Reset()
+		return nil, err
+	}
+	reply, err := readHello(s)
+	if err != nil {
+		s.Reset()
+		return nil, fmt.Errorf("version negotiation failed: %w", err)
+	}
+	if len(reply) != 1 || reply[0] < rpcMinVersion || reply[0] > rpcMaxVersion {
+		s.Reset()
+		return nil, fmt.Errorf("%w: peer chose an unsupported version", errRPCBadFrame)
+	}
+	rs.version = reply[0]
+	return rs, nil
+}
+
+// acceptRPCStream answers the opener's hello with the highest version both
+// sides speak
+func acceptRPCStream(s network.Stream) (*rpcStream, error) {
+	s.SetDeadline(time.Now().Add(rpcTimeout))
+	rs := &rpcStream{Stream: s, version: rpcMinVersion}
+	hello, err := readHello(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(hello) != 2 || hello[0] > hello[1] {
+		return nil, fmt.Errorf("%w: bad hello", errRPCBadFrame)
+	}
+	version := min(hello[1], rpcMaxVersion)
+	if version < max(hello[0], rpcMinVersion) {
+		rs.sendError(fmt.Sprintf("no common version: peer speaks %d-%d, this node %d-%d", hello[0], hello[1], rpcMinVersion, rpcMaxVersion))
+		return nil, fmt.Errorf("no common RPC version with %d-%d", hello[0], hello[1])
+	}
+	rs.version = version
+	return rs, rs.send(rpcMsgHello, []byte{version})
+}
+
+// readHello reads a hello frame, whatever version its header carries
+func readHello(r io.Reader) ([]byte, error) {
+	frame, err := readRPCFrame(r)
+	if err != nil {
+		return nil, err
+	}
+	if frame.Type == rpcMsgError {
+		return nil, fmt.Errorf("peer error: %s", frame.Payload)
+	}
+	if frame.Type != rpcMsgHello {
+		return nil, fmt.Errorf("%w: expected hello, got type %d", errRPCBadFrame, frame.Type)
+	}
+	return frame.Payload, nil
+}
+
+func (rs *rpcStream) send(t rpcMsgType, payload []byte) error {
+	if rs.pace != nil {
+		if err := rs.pace(rpcHeaderSize + len(payload)); err != nil {
+			return err
+		}
+	}
+	return writeRPCFrame(rs.Stream, rs.version, t, payload)
+}
+
+func (rs *rpcStream) sendError(msg string) error {
+	return rs.send(rpcMsgError, []byte(msg))
+}
+
+// recv reads the next frame, which must carry the negotiated version
+func (rs *rpcStream) recv() (rpcFrame, error) {
+	frame, err := readRPCFrame(rs.Stream)
+	if err != nil {
+		return frame, err
+	}
+	if frame.Version != rs.version {
+		return frame, fmt.Errorf("%w: version %d on a version %d stream", errRPCBadFrame, frame.Version, rs.version)
+	}
+	return frame, nil
+}
+
+// expect reads the next frame and returns its payload if it has type t. An
+// error frame is returned as an error.
+func (rs *rpcStream) expect(t rpcMsgType) ([]byte, error) {
+	frame, err := rs.recv()
+	if err != nil {
+		return nil, err
+	}
+	if frame.Type == rpcMsgError {
+		return nil, fmt.Errorf("peer error: %s", frame.Payload)
+	}
+	if frame.Type != t {
+		return nil, fmt.Errorf("%w: expected type %d, got %d", errRPCBadFrame, t, frame.Type)
+	}
+	return frame.Payload, nil
+}
+
+// call sends a request and waits for a response of type want
+func (rs *rpcStream) call(t rpcMsgType, payload []byte, want rpcMsgType) ([]byte, error) {
+	if err := rs.send(t, payload); err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	return rs.expect(want)
+}
+
+// appendRPCString appends a length-prefixed string
+func appendRPCString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+// rpcPayload decodes payload fields in order. The first short read is kept
+// in err and makes every later read return zero values.
+type rpcPayload struct {
+	b   []byte
+	err error
+}
+
+func (p *rpcPayload) take(n int) []byte {
+	if p.err != nil {
+		return nil
+	}
+	if len(p.b) < n {
+		p.err = fmt.Errorf("%w: payload truncated", errRPCBadFrame)
+		return nil
+	}
+	field := p.b[:n]
+	p.b = p.b[n:]
+	return field
+}
+
+func (p *rpcPayload) string() string {
+	n := p.take(2)
+	if n == nil {
+		return ""
+	}
+	return string(p.take(int(binary.BigEndian.Uint16(n))))
+}
+
+func (p *rpcPayload) uint64() uint64 {
+	b := p.take(8)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint64(b)
+}
+
+func (p *rpcPayload) uint32() uint32 {
+	b := p.take(4)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b)
+}
+
+// rest returns the remaining bytes
+func (p *rpcPayload) rest() []byte {
+	if p.err != nil {
+		return nil
+	}
+	b := p.b
+	p.b = nil
+	return b
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestRPCFrameEncoding(t *testing.T) {
+	payload := appendRPCString(nil, "file-hash")
+	payload = append(payload, 0, 0, 0, 9, 'x', 'y')
+
+	var buf bytes.Buffer
+	if err := writeRPCFrame(&buf, 1, rpcMsgStoreShard, payload); err != nil {
+		t.Fatal(err)
+	}
+	encoded := append([]byte(nil), buf.Bytes()...)
+
+	frame, err := readRPCFrame(&buf)
+	if err != nil {
+		t.Fatalf("failed to decode frame: %v", err)
+	}
+	if frame.Version != 1 || frame.Type != rpcMsgStoreShard {
+		t.Fatalf("unexpected header: %+v", frame)
+	}
+	req := &rpcPayload{b: frame.Payload}
+	if hash, idx, rest := req.string(), req.uint32(), req.rest(); req.err != nil || hash != "file-hash" || idx != 9 || string(rest) != "xy" {
+		t.Fatalf("unexpected fields %q %d %q: %v", hash, idx, rest, req.err)
+	}
+
+	short := &rpcPayload{b: []byte{0, 5, 'a'}}
+	if short.string(); !errors.Is(short.err, errRPCBadFrame) {
+		t.Fatalf("expected a truncated payload to fail, got %v", short.err)
+	}
+
+	corrupt := append([]byte(nil), encoded...)
+	corrupt[len(corrupt)-1] ^= 0xff
+	if _, err := readRPCFrame(bytes.NewReader(corrupt)); !errors.Is(err, errRPCBadFrame) {
+		t.Fatalf("expected a checksum failure, got %v", err)
+	}
+
+	badMagic := append([]byte(nil), encoded...)
+	badMagic[0] = 'X'
+	if _, err := readRPCFrame(bytes.NewReader(badMagic)); !errors.Is(err, errRPCBadFrame) {
+		t.Fatalf("expected a bad magic failure, got %v", err)
+	}
+
+	// The length is checked before anything is allocated
+	huge := append([]byte(nil), encoded[:rpcHeaderSize]...)
+	huge[4], huge[5], huge[6], huge[7] = 0xff, 0xff, 0xff, 0xff
+	if frame, err := readRPCFrame(bytes.NewReader(huge)); !errors.Is(err, errRPCBadFrame) || frame.Type != rpcMsgStoreShard {
+		t.Fatalf("expected an oversized frame to fail with its type, got %+v %v", frame, err)
+	}
+
+	if err := writeRPCFrame(&buf, 1, rpcMsgData, make([]byte, rpcMaxPayload+1)); err == nil {
+		t.Fatal("expected an oversized payload to be refused")
+	}
+}
+
+func TestRPCVersionNegotiation(t *testing.T) {
+	n1, err := NewLibP2PPangeaNodeWithOptions(560, NewNodeStore(), false, true, 12560)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer n1.cancel()
+	n2, err := NewLibP2PPangeaNodeWithOptions(561, NewNodeStore(), false, true, 12561)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer n2.cancel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := n1.host.Connect(ctx, peer.AddrInfo{ID: n2.host.ID(), Addrs: n2.host.Addrs()}); err != nil {
+		t.Fatal(err)
+	}
+
+	rs, err := openRPCStream(ctx, n1.host, n2.host.ID())
+	if err != nil {
+		t.Fatalf("negotiation failed: %v", err)
+	}
+	if rs.version != rpcMaxVersion {
+		t.Fatalf("expected version %d, got %d", rpcMaxVersion, rs.version)
+	}
+	rs.Close()
+
+	// A peer that only speaks future versions is told there is no overlap
+	s, err := n1.host.NewStream(ctx, n2.host.ID(), PangeaRPCProtocol)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	future := &rpcStream{Stream: s, version: rpcMaxVersion + 1}
+	if err := future.send(rpcMsgHello, []byte{rpcMaxVersion + 1, rpcMaxVersion + 2}); err != nil {
+		t.Fatal(err)
+	}
+	frame, err := readRPCFrame(s)
+	if err != nil || frame.Type != rpcMsgError {
+		t.Fatalf("expected an error frame, got %+v %v", frame, err)
+	}
+}
@@ -10,17 +10,33 @@ import (
 	"encoding/pem"
 	"fmt"
 	"log"
+	"strings"
 	"sync"
 	"time"
 )
 
+const (
+	// DefaultChatRetention is how long sessions without a TTL keep messages
+	DefaultChatRetention = 24 * time.Hour
+
+	// ChatReapInterval is how often expired chat messages are deleted
+	ChatReapInterval = time.Second
+)
+
 // SecurityManager handles encryption configuration and key management
 type SecurityManager struct {
 	proxyConfig      *ProxyConfigData
 	encryptionConfig *EncryptionConfigData
 	keyPairs         map[string]*RSAKeyPair
+	retiredKeys      map[string][]*RSAKeyPair  // Rotated-out pairs still within their overlap, oldest first
+	agreementKeys    map[string]*X25519KeyPair // Message encryption keys; RSA is kept for legacy peers
+	keyStore         *KeyStore                 // Persists keys when set
+	keyGen           uint64                    // Numbers key store snapshots
 	chatSessions     map[string]*ChatSessionData
 	mu               sync.RWMutex
+
+	persistMu sync.Mutex // Serialises key store saves
+	savedGen  uint64     // Newest snapshot saved
 }
 
 // ProxyConfigData holds SOCKS5/Tor proxy configuration
@@ -46,6 +62,8 @@ type RSAKeyPair struct {
 	PrivateKey *rsa.PrivateKey
 	PublicKey  *rsa.PublicKey
 	Created    time.Time
+	Version    int       // Starts at 1; each rotation or replacement adds one
+	RetiresAt  time.Time // When a rotated-out pair stops being used; zero while current
 }
 
 // ChatSessionData represents an active chat session
@@ -56,7 +74,85 @@ type ChatSessionData struct {
 	PublicKey        []byte
 	SessionKey       []byte
 	Established      time.Time
+	MessageTTL       time.Duration // Messages expire this long after arrival, 0 = DefaultChatRetention
+	BurnOnRead       bool          // Delete messages as soon as they are first read
 	MessageQueue     []*EphemeralChatMessageData
+	opened           bool           // Peer holds the session; set once the initiator's handshake completes
+	openMu           sync.Mutex     // Held by the one send performing the initiator's handshake
+	ratchet          *doubleRatchet // Per-message keys; nil for unencrypted sessions
+}
+
+// retention returns how long the session keeps a message
+func (cs *ChatSessionData) retention() time.Duration {
+	if cs.MessageTTL > 0 {
+		return cs.MessageTTL
+	}
+	return DefaultChatRetention
+}
+
+// enqueue adds a message, stamping its expiry from the session retention
+// or the message's own shorter TTL. Caller must hold the SecurityManager
+// lock.
+func (cs *ChatSessionData) enqueue(msg *EphemeralChatMessageData) {
+	ttl := cs.retention()
+	if msg.TTL > 0 && msg.TTL < ttl {
+		ttl = msg.TTL
+	}
+	msg.ExpiresAt = time.Now().Add(ttl)
+	cs.MessageQueue = append(cs.MessageQueue, msg)
+}
+
+// takeMessages returns unread messages, and already read ones too if
+// includeRead is set. Burn-on-read sessions delete what they return;
+// others keep it until it expires. Caller must hold the SecurityManager
+// lock.
+func (cs *ChatSessionData) takeMessages(includeRead bool) []*EphemeralChatMessageData {
+	now := time.Now()
+	cs.reap(now)
+	messages := make([]*EphemeralChatMessageData, 0, len(cs.MessageQueue))
+	kept := make([]*EphemeralChatMessageData, 0, len(cs.MessageQueue))
+	for _, m := range cs.MessageQueue {
+		if !m.Read || includeRead {
+			messages = append(messages, m)
+		}
+		if !cs.BurnOnRead {
+			m.Read = true
+			kept = append(kept, m)
+		}
+	}
+	cs.MessageQueue = kept
+	return messages
+}
+
+// reap deletes and wipes expired messages, returning how many it removed.
+// Caller must hold the SecurityManager lock.
+func (cs *ChatSessionData) reap(now time.Time) int {
+	kept := cs.MessageQueue[:0]
+	for _, m := range cs.MessageQueue {
+		if now.Before(m.ExpiresAt) {
+			kept = append(kept, m)
+		} else {
+			clear(m.Message)
+		}
+	}
+	removed := len(cs.MessageQueue) - len(kept)
+	clear(cs.MessageQueue[len(kept):])
+	cs.MessageQueue = kept
+	return removed
+}
+
+// wipe zeroes the session's keys, ratchet state and undelivered messages.
+// Caller must hold the SecurityManager lock.
+func (cs *ChatSessionData) wipe() {
+	if cs.ratchet != nil {
+		cs.ratchet.zero()
+		cs.ratchet = nil
+	}
+	clear(cs.SessionKey)
+	for _, m := range cs.MessageQueue {
+		clear(m.Message)
+	}
+	cs.SessionKey, cs.MessageQueue = nil, nil
 }
 
 // EphemeralChatMessageData represents a chat message
@@ -68,6 +164,9 @@ type EphemeralChatMessageData struct {
 	MessageID      string
 	EncryptionType string
 	Signature      []byte
+	TTL            time.Duration // Sender's per-message TTL, 0 = the session's retention
+	ExpiresAt      time.Time     // When the receiving session deletes the message
+	Read           bool          // Returned by an earlier read; kept until it expires
 }
 
 // NewSecurityManager creates a new security manager
@@ -83,8 +182,10 @@ func NewSecurityManager() *SecurityManager {
 			SymmetricAlgo:    "chacha20",
 			EnableSignatures: true,
 		},
-		keyPairs:     make(map[string]*RSAKeyPair),
-		chatSessions: make(map[string]*ChatSessionData),
+		keyPairs:      make(map[string]*RSAKeyPair),
+		retiredKeys:   make(map[string][]*RSAKeyPair),
+		agreementKeys: make(map[string]*X25519KeyPair),
+		chatSessions:  make(map[string]*ChatSessionData),
 	}
 }
 
@@ -145,11 +246,25 @@ func (sm *SecurityManager) GenerateRSAKeyPair(keyID string) (*RSAKeyPair, error)
 	}
 
 	sm.mu.Lock()
-	sm.keyPairs[keyID] = keyPair
+	sm.replaceKeyLocked(keyID, keyPair)
+	persist := sm.persistKeysLocked()
 	sm.mu.Unlock()
 
 	log.Printf("Generated RSA key pair: %s", keyID)
-	return keyPair, nil
+	return keyPair, persist()
+}
+
+// replaceKeyLocked installs a key pair under keyID, numbering it after
+// the pair it replaces. Caller must hold sm.mu.
+func (sm *SecurityManager) replaceKeyLocked(keyID string, keyPair *RSAKeyPair) {
+	keyPair.Version = 1
+	if old, ok := sm.keyPairs[keyID]; ok {
+		keyPair.Version = old.Version + 1
+	}
+	for _, retired := range sm.retiredKeys[keyID] {
+		keyPair.Version = max(keyPair.Version, retired.Version+1)
+	}
+	sm.keyPairs[keyID] = keyPair
 }
 
 // ExportPublicKey exports a public key in PEM format
@@ -167,12 +282,15 @@ func (sm *SecurityManager) ExportPublicKey(keyID string) ([]byte, error) {
 		return nil, fmt.Errorf("failed to marshal public key: %w", err)
 	}
 
-	publicKeyPEM := pem.EncodeToMemory(&pem.Block{
+	return encodePublicKeyPEM(publicKeyBytes), nil
+}
+
+// encodePublicKeyPEM wraps a PKIX public key in PEM
+func encodePublicKeyPEM(publicKeyBytes []byte) []byte {
+	return pem.EncodeToMemory(&pem.Block{
 		Type:  "RSA PUBLIC KEY",
 		Bytes: publicKeyBytes,
 	})
-
-	return publicKeyPEM, nil
 }
 
 // ImportPublicKey imports a public key from PEM format
@@ -198,11 +316,12 @@ func (sm *SecurityManager) ImportPublicKey(keyID string, publicKeyPEM []byte) er
 	}
 
 	sm.mu.Lock()
-	sm.keyPairs[keyID] = keyPair
+	sm.replaceKeyLocked(keyID, keyPair)
+	persist := sm.persistKeysLocked()
 	sm.mu.Unlock()
 
 	log.Printf("Imported public key: %s", keyID)
-	return nil
+	return persist()
 }
 
 // EncryptWithPublicKey encrypts data with RSA public key
@@ -223,22 +342,21 @@ func (sm *SecurityManager) EncryptWithPublicKey(keyID string, plaintext []byte)
 	return ciphertext, nil
 }
 
-// DecryptWithPrivateKey decrypts data with RSA private key
+// DecryptWithPrivateKey decrypts data with RSA private key, falling back
+// to rotated-out keys still within their overlap
 func (sm *SecurityManager) DecryptWithPrivateKey(keyID string, ciphertext []byte) ([]byte, error) {
-	sm.mu.RLock()
-	keyPair, exists := sm.keyPairs[keyID]
-	sm.mu.RUnlock()
-
-	if !exists || keyPair.PrivateKey == nil {
-		return nil, fmt.Errorf("private key not found: %s", keyID)
-	}
-
-	plaintext, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, keyPair.PrivateKey, ciphertext, nil)
-	if err != nil {
-		return nil, fmt.Errorf("decryption failed: %w", err)
+	err := fmt.Errorf("private key not found: %s", keyID)
+	for _, keyPair := range sm.usableKeys(keyID) {
+		if keyPair.PrivateKey == nil {
+			continue
+		}
+		plaintext, decErr := rsa.DecryptOAEP(sha256.New(), rand.Reader, keyPair.PrivateKey, ciphertext, nil)
+		if decErr == nil {
+			return plaintext, nil
+		}
+		err = fmt.Errorf("decryption failed: %w", decErr)
 	}
-
-	return plaintext, nil
+	return nil, err
 }
 
 // SignMessage signs a message with RSA private key
@@ -260,23 +378,19 @@ func (sm *SecurityManager) SignMessage(keyID string, message []byte) ([]byte, er
 	return signature, nil
 }
 
-// VerifySignature verifies a message signature with RSA public key
+// VerifySignature verifies a message signature with RSA public key,
+// falling back to rotated-out keys still within their overlap
 func (sm *SecurityManager) VerifySignature(keyID string, message, signature []byte) error {
-	sm.mu.RLock()
-	keyPair, exists := sm.keyPairs[keyID]
-	sm.mu.RUnlock()
-
-	if !exists || keyPair.PublicKey == nil {
-		return fmt.Errorf("public key not found: %s", keyID)
-	}
-
 	hashed := sha256.Sum256(message)
-	err := rsa.VerifyPKCS1v15(keyPair.PublicKey, crypto.SHA256, hashed[:], signature)
-	if err != nil {
-		return fmt.Errorf("signature verification failed: %w", err)
+	err := fmt.Errorf("public key not found: %s", keyID)
+	for _, keyPair := range sm.usableKeys(keyID) {
+		verifyErr := rsa.VerifyPKCS1v15(keyPair.PublicKey, crypto.SHA256, hashed[:], signature)
+		if verifyErr == nil {
+			return nil
+		}
+		err = fmt.Errorf("signature verification failed: %w", verifyErr)
 	}
-
-	return nil
+	return err
 }
 
 // CreateChatSession creates a new ephemeral chat session
@@ -320,13 +434,13 @@ func (sm *SecurityManager) AddChatMessage(sessionID string, message *EphemeralCh
 		return fmt.Errorf("chat session not found: %s", sessionID)
 	}
 
-	session.MessageQueue = append(session.MessageQueue, message)
+	session.enqueue(message)
 	log.Printf("Added message to session %s: %s", sessionID, message.MessageID)
 
 	return nil
 }
 
-// GetChatMessages retrieves messages from a chat session
+// GetChatMessages returns a chat session's unread messages
 func (sm *SecurityManager) GetChatMessages(sessionID string) ([]*EphemeralChatMessageData, error) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
@@ -336,12 +450,55 @@ func (sm *SecurityManager) GetChatMessages(sessionID string) ([]*EphemeralChatMe
 		return nil, fmt.Errorf("chat session not found: %s", sessionID)
 	}
 
-	// Return copy of messages and clear queue
-	messages := make([]*EphemeralChatMessageData, len(session.MessageQueue))
-	copy(messages, session.MessageQueue)
-	session.MessageQueue = make([]*EphemeralChatMessageData, 0)
+	return session.takeMessages(false), nil
+}
+
+// ReapExpiredMessages deletes expired messages from every chat session,
+// returning how many were removed
+func (sm *SecurityManager) ReapExpiredMessages(now time.Time) int {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	removed := 0
+	for _, session := range sm.chatSessions {
+		removed += session.reap(now)
+	}
+	return removed
+}
+
+// PruneRetiredKeys drops rotated-out keys past their overlap, returning
+// how many were dropped
+func (sm *SecurityManager) PruneRetiredKeys(now time.Time) int {
+	sm.mu.Lock()
+	removed := sm.pruneRetiredKeysLocked(now)
+	persist := func() error { return nil }
+	if removed > 0 {
+		persist = sm.persistKeysLocked()
+	}
+	sm.mu.Unlock()
+	if err := persist(); err != nil {
+		log.Printf("⚠️  %v", err)
+	}
+	return removed
+}
 
-	return messages, nil
+// Run deletes expired chat messages every ChatReapInterval, and retired
+// keys past their overlap, until ctx is done
+func (sm *SecurityManager) Run(ctx context.Context) {
+	ticker := time.NewTicker(ChatReapInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if removed := sm.ReapExpiredMessages(now); removed > 0 {
+				log.Printf("🗑️  Deleted %d expired chat messages", removed)
+			}
+			if removed := sm.PruneRetiredKeys(now); removed > 0 {
+				log.Printf("🗑️  Dropped %d retired keys", removed)
+			}
+		}
+	}
 }
 
 // CloseChatSession closes a chat session
@@ -349,36 +506,31 @@ func (sm *SecurityManager) CloseChatSession(sessionID string) error {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
+	if session, exists := sm.chatSessions[sessionID]; exists {
+		session.wipe()
+	}
 	delete(sm.chatSessions, sessionID)
 	log.Printf("Closed chat session: %s", sessionID)
 
 	return nil
 }
 
-// KeyExchange performs key exchange with a peer
+// KeyExchange returns the public key a peer should encrypt messages to:
+// the raw X25519 key, or the RSA key in PEM when the configured key
+// exchange is RSA
 func (sm *SecurityManager) KeyExchange(ctx context.Context, peerAddr string) ([]byte, error) {
-	// Generate or retrieve key pair
-	_, exists := sm.keyPairs["default"]
-	if !exists {
-		_, err := sm.GenerateRSAKeyPair("default")
-		if err != nil {
-			return nil, fmt.Errorf("failed to generate key pair: %w", err)
-		}
+	log.Printf("Performing key exchange with peer: %s", peerAddr)
+	if !strings.EqualFold(sm.GetEncryptionConfig().KeyExchangeAlgo, "rsa") {
+		return sm.AgreementPublicKey("default")
 	}
 
-	// Export public key
+	// Legacy RSA peers
+	if _, err := sm.defaultKeyPair(); err != nil {
+		return nil, fmt.Errorf("failed to generate key pair: %w", err)
+	}
 	publicKeyPEM, err := sm.ExportPublicKey("default")
 	if err != nil {
 		return nil, fmt.Errorf("failed to export public key: %w", err)
 	}
-
-	log.Printf("Performing key exchange with peer: %s", peerAddr)
-
-	// In a full implementation, this would:
-	// 1. Send our public key to the peer
-	// 2. Receive peer's public key
-	// 3. Optionally generate and exchange a symmetric session key
-	// 4. Verify signatures if enabled
-
 	return publicKeyPEM, nil
 }
@@ -0,0 +1,426 @@
+package main
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// The key store is a single file sealed like a key bundle (see
+// key_escrow.go), holding the JSON list of every key pair and imported
+// public key, retired ones included
+const (
+	keyStoreMagic   = "PGKS"
+	keyStoreVersion = 1
+
+	// storedKeyX25519 marks key agreement keys; other keys are RSA
+	storedKeyX25519 = "x25519"
+
+	// DefaultKeyOverlap is how long a rotated-out key still decrypts and
+	// verifies, so peers holding the old public key keep working
+	DefaultKeyOverlap = 7 * 24 * time.Hour
+)
+
+// KeyInfo describes a key held by the SecurityManager
+type KeyInfo struct {
+	KeyID       string
+	Version     int
+	Fingerprint string // Hex SHA-256 of the PKIX public key
+	Created     time.Time
+	RetiresAt   time.Time // Zero for the current key
+	HasPrivate  bool
+	PublicPEM   []byte
+}
+
+// KeyStore keeps the SecurityManager's keys on disk, encrypted under a
+// passphrase
+type KeyStore struct {
+	path       string
+	passphrase string
+}
+
+// storedKey is one key pair in the key store
+type storedKey struct {
+	KeyID     string    `json:"keyId"`
+	Algorithm string    `json:"algorithm,omitempty"` // "x25519", or empty for RSA
+	Version   int       `json:"version"`
+	Private   []byte    `json:"private,omitempty"` // PKCS#8
+	Public    []byte    `json:"public"`            // PKIX
+	Created   time.Time `json:"created"`
+	RetiresAt time.Time `json:"retiresAt"`
+}
+
+// NewKeyStore opens the key store at path; the file is created on the
+// first save
+func NewKeyStore(path, passphrase string) (*KeyStore, error) {
+	if len(passphrase) < minKeyPassphraseLen {
+		return nil, fmt.Errorf("passphrase must be at least %d characters", minKeyPassphraseLen)
+	}
+	return &KeyStore{path: path, passphrase: passphrase}, nil
+}
+
+// load returns the stored keys, none if the file does not exist yet
+func (ks *KeyStore) load() ([]storedKey, error) {
+	data, err := os.ReadFile(ks.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := openWithPassphrase(data, keyStoreMagic, keyStoreVersion, ks.passphrase, "key store")
+	if err != nil {
+		return nil, err
+	}
+	var keys []storedKey
+	if err := json.Unmarshal(plaintext, &keys); err != nil {
+		return nil, fmt.Errorf("invalid key store contents: %w", err)
+	}
+	return keys, nil
+}
+
+// save replaces the stored keys, so a partial write is never loaded
+func (ks *KeyStore) save(keys []storedKey) error {
+	plaintext, err := json.Marshal(keys)
+	if err != nil {
+		return err
+	}
+	defer clear(plaintext)
+	data, err := sealWithPassphrase(keyStoreMagic, keyStoreVersion, plaintext, ks.passphrase)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(ks.path), 0700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(ks.path+".tmp", data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(ks.path+".tmp", ks.path)
+}
+
+// SetKeyStore persists keys in ks from now on and loads the keys already
+// there, which replace in-memory keys with the same ID
+func (sm *SecurityManager) SetKeyStore(ks *KeyStore) error {
+	stored, err := ks.load()
+	if err != nil {
+		return err
+	}
+	persist, err := sm.loadKeys(ks, stored)
+	if err != nil {
+		return err
+	}
+	return persist()
+}
+
+// loadKeys installs keys loaded from ks and makes ks the key store
+func (sm *SecurityManager) loadKeys(ks *KeyStore, stored []storedKey) (func() error, error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	loaded := make(map[string]bool)
+	for _, k := range stored {
+		if k.Algorithm == storedKeyX25519 {
+			pair, err := k.agreementKey()
+			if err != nil {
+				return nil, fmt.Errorf("X25519 key %s: %w", k.KeyID, err)
+			}
+			sm.agreementKeys[k.KeyID] = pair
+			continue
+		}
+		pair, err := k.keyPair()
+		if err != nil {
+			return nil, fmt.Errorf("key %s v%d: %w", k.KeyID, k.Version, err)
+		}
+		if !loaded[k.KeyID] {
+			loaded[k.KeyID] = true
+			delete(sm.keyPairs, k.KeyID)
+			delete(sm.retiredKeys, k.KeyID)
+		}
+		if pair.RetiresAt.IsZero() {
+			sm.keyPairs[k.KeyID] = pair
+		} else {
+			sm.retiredKeys[k.KeyID] = append(sm.retiredKeys[k.KeyID], pair)
+		}
+	}
+	for _, pairs := range sm.retiredKeys {
+		sort.Slice(pairs, func(i, j int) bool { return pairs[i].Version < pairs[j].Version })
+	}
+	sm.keyStore = ks
+	if len(stored) > 0 {
+		log.Printf("🔑 Loaded %d keys from %s", len(stored), ks.path)
+	}
+	return sm.persistKeysLocked(), nil
+}
+
+// persistKeysLocked snapshots every key for the key store, if one is set,
+// and returns the function that saves the snapshot. Sealing the store runs
+// Argon2id, so callers release sm.mu before calling it; when saves race,
+// an older snapshot never replaces a newer one. Caller must hold sm.mu.
+func (sm *SecurityManager) persistKeysLocked() func() error {
+	if sm.keyStore == nil {
+		return func() error { return nil }
+	}
+	var keys []storedKey
+	wipe := func() {
+		for _, k := range keys {
+			clear(k.Private)
+		}
+	}
+	add := func(id string, pair *RSAKeyPair) error {
+		k := storedKey{KeyID: id, Version: pair.Version, Created: pair.Created, RetiresAt: pair.RetiresAt}
+		var err error
+		if pair.PrivateKey != nil {
+			if k.Private, err = x509.MarshalPKCS8PrivateKey(pair.PrivateKey); err != nil {
+				return err
+			}
+		}
+		if k.Public, err = x509.MarshalPKIXPublicKey(pair.PublicKey); err != nil {
+			return err
+		}
+		keys = append(keys, k)
+		return nil
+	}
+	snapshot := func() error {
+		for id, pair := range sm.keyPairs {
+			if err := add(id, pair); err != nil {
+				return err
+			}
+		}
+		for id, pairs := range sm.retiredKeys {
+			for _, pair := range pairs {
+				if err := add(id, pair); err != nil {
+					return err
+				}
+			}
+		}
+		for id, pair := range sm.agreementKeys {
+			k := storedKey{KeyID: id, Algorithm: storedKeyX25519, Created: pair.Created}
+			var err error
+			if pair.PrivateKey != nil {
+				if k.Private, err = x509.MarshalPKCS8PrivateKey(pair.PrivateKey); err != nil {
+					return err
+				}
+			}
+			if k.Public, err = x509.MarshalPKIXPublicKey(pair.PublicKey); err != nil {
+				return err
+			}
+			keys = append(keys, k)
+		}
+		return nil
+	}
+	if err := snapshot(); err != nil {
+		wipe()
+		return func() error { return fmt.Errorf("failed to persist keys: %w", err) }
+	}
+	sm.keyGen++
+	gen, store := sm.keyGen, sm.keyStore
+
+	return func() error {
+		defer wipe()
+		sm.persistMu.Lock()
+		defer sm.persistMu.Unlock()
+		if gen < sm.savedGen {
+			return nil // A newer snapshot is already saved
+		}
+		if err := store.save(keys); err != nil {
+			return fmt.Errorf("failed to persist keys: %w", err)
+		}
+		sm.savedGen = gen
+		return nil
+	}
+}
+
+// keyPair decodes a stored key
+func (k storedKey) keyPair() (*RSAKeyPair, error) {
+	pair := &RSAKeyPair{Version: k.Version, Created: k.Created, RetiresAt: k.RetiresAt}
+	if len(k.Private) > 0 {
+		parsed, err := x509.ParsePKCS8PrivateKey(k.Private)
+		if err != nil {
+			return nil, err
+		}
+		priv, ok := parsed.(*rsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("not an RSA private key")
+		}
+		pair.PrivateKey, pair.PublicKey = priv, &priv.PublicKey
+		return pair, nil
+	}
+	parsed, err := x509.ParsePKIXPublicKey(k.Public)
+	if err != nil {
+		return nil, err
+	}
+	pub, ok := parsed.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("not an RSA public key")
+	}
+	pair.PublicKey = pub
+	return pair, nil
+}
+
+// agreementKey decodes a stored X25519 key
+func (k storedKey) agreementKey() (*X25519KeyPair, error) {
+	pair := &X25519KeyPair{Created: k.Created}
+	if len(k.Private) > 0 {
+		parsed, err := x509.ParsePKCS8PrivateKey(k.Private)
+		if err != nil {
+			return nil, err
+		}
+		priv, ok := parsed.(*ecdh.PrivateKey)
+		if !ok || priv.Curve() != ecdh.X25519() {
+			return nil, errors.New("not an X25519 private key")
+		}
+		pair.PrivateKey, pair.PublicKey = priv, priv.PublicKey()
+		return pair, nil
+	}
+	parsed, err := x509.ParsePKIXPublicKey(k.Public)
+	if err != nil {
+		return nil, err
+	}
+	pub, ok := parsed.(*ecdh.PublicKey)
+	if !ok || pub.Curve() != ecdh.X25519() {
+		return nil, errors.New("not an X25519 public key")
+	}
+	pair.PublicKey = pub
+	return pair, nil
+}
+
+// RotateKey replaces a key pair with a fresh one. The old pair keeps
+// decrypting and verifying for overlap, while new encryptions and
+// signatures use the new pair.
+func (sm *SecurityManager) RotateKey(keyID string, overlap time.Duration) (*RSAKeyPair, error) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate RSA key: %w", err)
+	}
+
+	sm.mu.Lock()
+	old, ok := sm.keyPairs[keyID]
+	if !ok || old.PrivateKey == nil {
+		sm.mu.Unlock()
+		return nil, fmt.Errorf("no key pair %s to rotate", keyID)
+	}
+	now := time.Now()
+	sm.pruneRetiredKeysLocked(now)
+	old.RetiresAt = now.Add(overlap)
+	sm.retiredKeys[keyID] = append(sm.retiredKeys[keyID], old)
+	pair := &RSAKeyPair{PrivateKey: privateKey, PublicKey: &privateKey.PublicKey, Created: now, Version: old.Version + 1}
+	sm.keyPairs[keyID] = pair
+	persist := sm.persistKeysLocked()
+	sm.mu.Unlock()
+	log.Printf("🔄 Rotated key %s to version %d; version %d retires %s", keyID, pair.Version, old.Version, old.RetiresAt.Format(time.RFC3339))
+	return pair, persist()
+}
+
+// ListKeys describes every key, current and retiring, by ID and newest
+// version first
+func (sm *SecurityManager) ListKeys() []KeyInfo {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	now := time.Now()
+	var out []KeyInfo
+	add := func(id string, pair *RSAKeyPair) {
+		pub, err := x509.MarshalPKIXPublicKey(pair.PublicKey)
+		if err != nil {
+			return
+		}
+		out = append(out, KeyInfo{
+			KeyID:       id,
+			Version:     pair.Version,
+			Fingerprint: keyFingerprint(pub),
+			Created:     pair.Created,
+			RetiresAt:   pair.RetiresAt,
+			HasPrivate:  pair.PrivateKey != nil,
+			PublicPEM:   encodePublicKeyPEM(pub),
+		})
+	}
+	for id, pair := range sm.keyPairs {
+		add(id, pair)
+	}
+	for id, pairs := range sm.retiredKeys {
+		for _, pair := range pairs {
+			if now.Before(pair.RetiresAt) {
+				add(id, pair)
+			}
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].KeyID != out[j].KeyID {
+			return out[i].KeyID < out[j].KeyID
+		}
+		return out[i].Version > out[j].Version
+	})
+	return out
+}
+
+// KeyFingerprint returns the fingerprint of a key's current public key
+func (sm *SecurityManager) KeyFingerprint(keyID string) (string, error) {
+	sm.mu.RLock()
+	pair, ok := sm.keyPairs[keyID]
+	sm.mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("key pair not found: %s", keyID)
+	}
+	pub, err := x509.MarshalPKIXPublicKey(pair.PublicKey)
+	if err != nil {
+		return "", err
+	}
+	return keyFingerprint(pub), nil
+}
+
+// usableKeys returns a key's current pair followed by retired pairs still
+// within their overlap, newest first
+func (sm *SecurityManager) usableKeys(keyID string) []*RSAKeyPair {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	var out []*RSAKeyPair
+	if pair, ok := sm.keyPairs[keyID]; ok {
+		out = append(out, pair)
+	}
+	now := time.Now()
+	retired := sm.retiredKeys[keyID]
+	for i := len(retired) - 1; i >= 0; i-- {
+		if now.Before(retired[i].RetiresAt) {
+			out = append(out, retired[i])
+		}
+	}
+	return out
+}
+
+// pruneRetiredKeysLocked drops retired pairs past their overlap and
+// returns how many it dropped. Caller must hold sm.mu.
+func (sm *SecurityManager) pruneRetiredKeysLocked(now time.Time) int {
+	removed := 0
+	for id, pairs := range sm.retiredKeys {
+		kept := pairs[:0]
+		for _, pair := range pairs {
+			if now.Before(pair.RetiresAt) {
+				kept = append(kept, pair)
+			} else {
+				removed++
+			}
+		}
+		if len(kept) == 0 {
+			delete(sm.retiredKeys, id)
+		} else {
+			sm.retiredKeys[id] = kept
+		}
+	}
+	return removed
+}
+
+// keyFingerprint is the hex SHA-256 of a PKIX-encoded public key
+func keyFingerprint(pkix []byte) string {
+	sum := sha256.Sum256(pkix)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestKeyStorePersistsAndRotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "security_keys.pgks")
+	ks, err := NewKeyStore(path, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sm := NewSecurityManager()
+	if err := sm.SetKeyStore(ks); err != nil {
+		t.Fatalf("SetKeyStore failed: %v", err)
+	}
+	if _, err := sm.GenerateRSAKeyPair("default"); err != nil {
+		t.Fatal(err)
+	}
+	oldCiphertext, err := sm.EncryptWithPublicKey("default", []byte("before rotation"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldSignature, err := sm.SignMessage("default", []byte("signed before"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldFingerprint, _ := sm.KeyFingerprint("default")
+
+	if _, err := sm.RotateKey("default", time.Hour); err != nil {
+		t.Fatalf("RotateKey failed: %v", err)
+	}
+	if fp, _ := sm.KeyFingerprint("default"); fp == oldFingerprint {
+		t.Fatal("fingerprint did not change on rotation")
+	}
+
+	// A restarted node loads both versions from disk
+	if _, err := NewKeyStore(path, "short"); err == nil {
+		t.Error("expected a short passphrase to be rejected")
+	}
+	wrong, _ := NewKeyStore(path, "wrong horse!")
+	if err := NewSecurityManager().SetKeyStore(wrong); err != ErrBadPassphrase {
+		t.Fatalf("expected ErrBadPassphrase, got %v", err)
+	}
+	restarted := NewSecurityManager()
+	if err := restarted.SetKeyStore(ks); err != nil {
+		t.Fatalf("reloading key store failed: %v", err)
+	}
+	keys := restarted.ListKeys()
+	if len(keys) != 2 || keys[0].Version != 2 || keys[1].Version != 1 || keys[1].RetiresAt.IsZero() {
+		t.Fatalf("unexpected keys after reload: %+v", keys)
+	}
+	if keys[1].Fingerprint != oldFingerprint || !keys[0].HasPrivate {
+		t.Fatalf("reloaded keys do not match: %+v", keys)
+	}
+
+	// The retired key still decrypts and verifies during the overlap
+	if plaintext, err := restarted.DecryptWithPrivateKey("default", oldCiphertext); err != nil || string(plaintext) != "before rotation" {
+		t.Fatalf("old ciphertext did not decrypt: %v", err)
+	}
+	if err := restarted.VerifySignature("default", []byte("signed before"), oldSignature); err != nil {
+		t.Fatalf("old signature did not verify: %v", err)
+	}
+
+	// Once the overlap ends it is dropped
+	if n := restarted.PruneRetiredKeys(time.Now().Add(2 * time.Hour)); n != 1 {
+		t.Fatalf("expected 1 pruned key, got %d", n)
+	}
+	if _, err := restarted.DecryptWithPrivateKey("default", oldCiphertext); err == nil {
+		t.Fatal("expected old ciphertext to fail after the overlap")
+	}
+	if len(restarted.ListKeys()) != 1 {
+		t.Fatal("retired key still listed after pruning")
+	}
+}
+
+func TestKeyStoreSavesOutsideTheLock(t *testing.T) {
+	ks, err := NewKeyStore(filepath.Join(t.TempDir(), "security_keys.pgks"), "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sm := NewSecurityManager()
+	if err := sm.SetKeyStore(ks); err != nil {
+		t.Fatal(err)
+	}
+
+	// Readers are not held up while a save seals the store
+	sm.persistMu.Lock()
+	done := make(chan error, 1)
+	go func() {
+		_, err := sm.GenerateAgreementKey("blocked")
+		done <- err
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		sm.mu.RLock()
+		_, ok := sm.agreementKeys["blocked"]
+		sm.mu.RUnlock()
+		if ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			sm.persistMu.Unlock()
+			t.Fatal("key not visible while its save was pending")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	sm.persistMu.Unlock()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	// Racing saves leave the newest snapshot on disk
+	var wg sync.WaitGroup
+	for i := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := sm.GenerateAgreementKey(fmt.Sprintf("peer-%d", i)); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	restarted := NewSecurityManager()
+	if err := restarted.SetKeyStore(ks); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"blocked", "peer-0", "peer-1", "peer-2", "peer-3"} {
+		restarted.mu.RLock()
+		pair := restarted.agreementKeys[id]
+		restarted.mu.RUnlock()
+		if pair == nil || pair.PrivateKey == nil {
+			t.Fatalf("key %s missing after reload", id)
+		}
+	}
+}
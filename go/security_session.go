@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// Message encryption seals each message to an X25519 public key: a fresh
+// ephemeral key agrees a secret with the recipient, HKDF turns it into an
+// AES-256-GCM or ChaCha20-Poly1305 key, and the envelope carries the
+// ephemeral public key. RSA-OAEP is only used for peers that have not
+// published an X25519 key.
+const (
+	messageEnvelopeMagic   = "PGSE"
+	messageEnvelopeVersion = 1
+	messageKeyInfo         = "pangea-message-key"
+	messageKeySize         = 32
+
+	// Cipher IDs in the envelope header
+	messageCipherAESGCM   = 1
+	messageCipherChaCha20 = 2
+)
+
+// messageEnvelopeHeaderSize is magic, version, cipher ID and ephemeral key
+const messageEnvelopeHeaderSize = len(messageEnvelopeMagic) + 2 + 32
+
+// X25519KeyPair is a key agreement key; PrivateKey is nil for an imported
+// peer key
+type X25519KeyPair struct {
+	PrivateKey *ecdh.PrivateKey
+	PublicKey  *ecdh.PublicKey
+	Created    time.Time
+}
+
+// GenerateAgreementKey generates an X25519 key pair, replacing any held
+// under keyID
+func (sm *SecurityManager) GenerateAgreementKey(keyID string) (*X25519KeyPair, error) {
+	priv, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate X25519 key: %w", err)
+	}
+	pair := &X25519KeyPair{PrivateKey: priv, PublicKey: priv.PublicKey(), Created: time.Now()}
+
+	sm.mu.Lock()
+	sm.agreementKeys[keyID] = pair
+	persist := sm.persistKeysLocked()
+	sm.mu.Unlock()
+	log.Printf("Generated X25519 key pair: %s", keyID)
+	return pair, persist()
+}
+
+// AgreementPublicKey returns the raw X25519 public key for keyID,
+// generating a key pair if this node has none
+func (sm *SecurityManager) AgreementPublicKey(keyID string) ([]byte, error) {
+	sm.mu.RLock()
+	pair, ok := sm.agreementKeys[keyID]
+	sm.mu.RUnlock()
+	if !ok || pair.PrivateKey == nil {
+		var err error
+		if pair, err = sm.GenerateAgreementKey(keyID); err != nil {
+			return nil, err
+		}
+	}
+	return pair.PublicKey.Bytes(), nil
+}
+
+// ImportAgreementKey stores a peer's raw X25519 public key under keyID
+func (sm *SecurityManager) ImportAgreementKey(keyID string, publicKey []byte) error {
+	pub, err := ecdh.X25519().NewPublicKey(publicKey)
+	if err != nil {
+		return fmt.Errorf("invalid X25519 public key: %w", err)
+	}
+
+	sm.mu.Lock()
+	sm.agreementKeys[keyID] = &X25519KeyPair{PublicKey: pub, Created: time.Now()}
+	persist := sm.persistKeysLocked()
+	sm.mu.Unlock()
+	log.Printf("Imported X25519 public key: %s", keyID)
+	return persist()
+}
+
+// EncryptMessage encrypts plaintext for the holder of keyID, binding aad
+// to the ciphertext. It seals to keyID's X25519 key when there is one and
+// falls back to RSA-OAEP, which cannot carry aad, for legacy peers.
+func (sm *SecurityManager) EncryptMessage(keyID string, plaintext, aad []byte) ([]byte, error) {
+	sm.mu.RLock()
+	pair, ok := sm.agreementKeys[keyID]
+	sm.mu.RUnlock()
+	if ok {
+		return sm.SealMessage(pair.PublicKey.Bytes(), plaintext, aad)
+	}
+	if len(aad) > 0 {
+		return nil, fmt.Errorf("no X25519 key for %s, and RSA-OAEP cannot bind associated data", keyID)
+	}
+	return sm.EncryptWithPublicKey(keyID, plaintext)
+}
+
+// SealMessage encrypts plaintext to a raw X25519 public key with the
+// configured symmetric cipher
+func (sm *SecurityManager) SealMessage(recipient, plaintext, aad []byte) ([]byte, error) {
+	pub, err := ecdh.X25519().NewPublicKey(recipient)
+	if err != nil {
+		return nil, fmt.Errorf("invalid X25519 public key: %w", err)
+	}
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate ephemeral key: %w", err)
+	}
+	cipherID, err := messageCipherID(sm.GetEncryptionConfig().SymmetricAlgo)
+	if err != nil {
+		return nil, err
+	}
+
+	header := append([]byte(messageEnvelopeMagic), messageEnvelopeVersion, cipherID)
+	header = append(header, ephemeral.PublicKey().Bytes()...)
+	key, err := deriveMessageKey(ephemeral, pub, header)
+	if err != nil {
+		return nil, err
+	}
+	defer wipe(key)
+	aead, err := messageAEAD(cipherID, key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out := append(header, nonce...)
+	return aead.Seal(out, nonce, plaintext, append(header[:len(header):len(header)], aad...)), nil
+}
+
+// DecryptMessage decrypts a message encrypted for this node's keyID.
+// Sealed envelopes are opened with the X25519 key; anything else is taken
+// to be legacy RSA-OAEP.
+func (sm *SecurityManager) DecryptMessage(keyID string, ciphertext, aad []byte) ([]byte, error) {
+	if !bytes.HasPrefix(ciphertext, []byte(messageEnvelopeMagic)) {
+		if len(aad) > 0 {
+			return nil, errors.New("RSA-OAEP messages cannot carry associated data")
+		}
+		return sm.DecryptWithPrivateKey(keyID, ciphertext)
+	}
+	if len(ciphertext) < messageEnvelopeHeaderSize {
+		return nil, errors.New("truncated message envelope")
+	}
+	if v := ciphertext[len(messageEnvelopeMagic)]; v != messageEnvelopeVersion {
+		return nil, fmt.Errorf("unsupported message envelope version %d", v)
+	}
+
+	sm.mu.RLock()
+	pair, ok := sm.agreementKeys[keyID]
+	sm.mu.RUnlock()
+	if !ok || pair.PrivateKey == nil {
+		return nil, fmt.Errorf("X25519 private key not found: %s", keyID)
+	}
+	header := ciphertext[:messageEnvelopeHeaderSize]
+	ephemeral, err := ecdh.X25519().NewPublicKey(header[len(messageEnvelopeMagic)+2:])
+	if err != nil {
+		return nil, fmt.Errorf("invalid ephemeral key: %w", err)
+	}
+	key, err := deriveMessageKey(pair.PrivateKey, ephemeral, header)
+	if err != nil {
+		return nil, err
+	}
+	defer wipe(key)
+	aead, err := messageAEAD(header[len(messageEnvelopeMagic)+1], key)
+	if err != nil {
+		return nil, err
+	}
+	body := ciphertext[messageEnvelopeHeaderSize:]
+	if len(body) < aead.NonceSize() {
+		return nil, errors.New("truncated message envelope")
+	}
+	plaintext, err := aead.Open(nil, body[:aead.NonceSize()], body[aead.NonceSize():], append(header[:len(header):len(header)], aad...))
+	if err != nil {
+		return nil, fmt.Errorf("decryption failed: %w", err)
+	}
+	return plaintext, nil
+}
+
+// deriveMessageKey runs X25519 and HKDF-SHA256, salted with the envelope
+// header so the key is bound to the ephemeral key and cipher
+func deriveMessageKey(priv *ecdh.PrivateKey, pub *ecdh.PublicKey, header []byte) ([]byte, error) {
+	shared, err := priv.ECDH(pub)
+	if err != nil {
+		return nil, err
+	}
+	defer wipe(shared)
+	return hkdf.Key(sha256.New, shared, header, messageKeyInfo, messageKeySize)
+}
+
+// messageCipherID maps a configured symmetric algorithm to its envelope
+// cipher ID, with the same names chat sessions accept
+func messageCipherID(algo string) (byte, error) {
+	switch strings.ToLower(algo) {
+	case "aes256", "aes-256-gcm", "aes":
+		return messageCipherAESGCM, nil
+	case "", "chacha20", "chacha20-poly1305":
+		return messageCipherChaCha20, nil
+	}
+	return 0, fmt.Errorf("unsupported symmetric algorithm %q", algo)
+}
+
+// messageAEAD returns the cipher named by an envelope's cipher ID
+func messageAEAD(cipherID byte, key []byte) (cipher.AEAD, error) {
+	switch cipherID {
+	case messageCipherAESGCM:
+		return chatAEAD("aes256", key)
+	case messageCipherChaCha20:
+		return chatAEAD("chacha20", key)
+	}
+	return nil, fmt.Errorf("unsupported cipher ID %d", cipherID)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestMessageEncryption(t *testing.T) {
+	ks, err := NewKeyStore(filepath.Join(t.TempDir(), "security_keys.pgks"), "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	recipient := NewSecurityManager()
+	if err := recipient.SetKeyStore(ks); err != nil {
+		t.Fatal(err)
+	}
+	publicKey, err := recipient.AgreementPublicKey("default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender := NewSecurityManager()
+	if err := sender.ImportAgreementKey("bob", publicKey); err != nil {
+		t.Fatal(err)
+	}
+
+	aad := []byte("session-1")
+	for _, algo := range []string{"chacha20", "aes256"} {
+		sender.SetEncryptionConfig(&EncryptionConfigData{EncryptionType: "asymmetric", SymmetricAlgo: algo})
+		ciphertext, err := sender.EncryptMessage("bob", []byte("hello "+algo), aad)
+		if err != nil {
+			t.Fatalf("%s: EncryptMessage failed: %v", algo, err)
+		}
+		plaintext, err := recipient.DecryptMessage("default", ciphertext, aad)
+		if err != nil || string(plaintext) != "hello "+algo {
+			t.Fatalf("%s: DecryptMessage = %q, %v", algo, plaintext, err)
+		}
+		if _, err := recipient.DecryptMessage("default", ciphertext, []byte("session-2")); err == nil {
+			t.Fatalf("%s: expected different associated data to be rejected", algo)
+		}
+		tampered := append([]byte(nil), ciphertext...)
+		tampered[len(messageEnvelopeMagic)+1] ^= 3 // Swap the cipher ID
+		if _, err := recipient.DecryptMessage("default", tampered, aad); err == nil {
+			t.Fatalf("%s: expected a modified header to be rejected", algo)
+		}
+	}
+
+	// The agreement key survives a restart
+	sealed, err := sender.SealMessage(publicKey, []byte("after restart"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restarted := NewSecurityManager()
+	if err := restarted.SetKeyStore(ks); err != nil {
+		t.Fatal(err)
+	}
+	if plaintext, err := restarted.DecryptMessage("default", sealed, nil); err != nil || string(plaintext) != "after restart" {
+		t.Fatalf("DecryptMessage after reload = %q, %v", plaintext, err)
+	}
+
+	// Peers with only an RSA key get RSA-OAEP
+	if _, err := recipient.GenerateRSAKeyPair("legacy"); err != nil {
+		t.Fatal(err)
+	}
+	pem, _ := recipient.ExportPublicKey("legacy")
+	if err := sender.ImportPublicKey("legacy", pem); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sender.EncryptMessage("legacy", []byte("old"), aad); err == nil {
+		t.Fatal("expected associated data to be refused for an RSA peer")
+	}
+	ciphertext, err := sender.EncryptMessage("legacy", []byte("old"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plaintext, err := recipient.DecryptMessage("legacy", ciphertext, nil); err != nil || string(plaintext) != "old" {
+		t.Fatalf("legacy DecryptMessage = %q, %v", plaintext, err)
+	}
+}
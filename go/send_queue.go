@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+const (
+	// Policies for a send to a peer whose queue is full: fail at once,
+	// or wait for room until the caller's context ends
+	SendQueueDrop = "drop"
+	SendQueuePark = "park"
+
+	// DefaultSendQueueSize is how many sends may wait for one peer
+	DefaultSendQueueSize = 64
+
+	// sendQueueWriters bounds the sends in flight to one peer. Compute
+	// tasks hold their stream until the result arrives, so one writer
+	// would run a worker's tasks one at a time.
+	sendQueueWriters = 4
+
+	// Traffic classes, used as the metrics label
+	sendClassCompute = "compute"
+	sendClassShard   = "shard"
+	sendClassChat    = "chat"
+)
+
+// ErrSendQueueFull is returned under the drop policy when a peer already
+// has a full queue of sends waiting
+var ErrSendQueueFull = errors.New("send queue full")
+
+// SendQueues gives each peer a bounded queue of outbound sends, drained by
+// writer goroutines, so a slow peer holds up only the sends queued for it.
+// A nil SendQueues runs every send directly.
+type SendQueues struct {
+	size   int
+	policy string
+	queues map[peer.ID]*peerSendQueue
+	mu     sync.Mutex
+}
+
+// peerSendQueue holds one peer's waiting sends; writers start on demand
+// and exit once it is empty
+type peerSendQueue struct {
+	jobs    chan *sendJob
+	writers int // Guarded by SendQueues.mu
+}
+
+// sendJob is one queued send
+type sendJob struct {
+	ctx   context.Context
+	class string
+	fn    func(context.Context) error
+	done  chan error
+}
+
+// NewSendQueues creates queues of the default size with the park policy
+func NewSendQueues() *SendQueues {
+	return &SendQueues{size: DefaultSendQueueSize, policy: SendQueuePark, queues: make(map[peer.ID]*peerSendQueue)}
+}
+
+// Configure sets the queue size and full-queue policy. Queues that
+// already exist keep their size.
+func (q *SendQueues) Configure(size int, policy string) error {
+	if size <= 0 {
+		return fmt.Errorf("send queue size must be positive, got %d", size)
+	}
+	if policy != SendQueueDrop && policy != SendQueuePark {
+		return fmt.Errorf("unknown send queue policy %q (want %s or %s)", policy, SendQueueDrop, SendQueuePark)
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.size, q.policy = size, policy
+	return nil
+}
+
+// Do queues fn behind the peer's earlier sends and waits for its result.
+// A full queue fails with ErrSendQueueFull or waits for room, per the
+// policy; fn is skipped if ctx ends while it is queued.
+func (q *SendQueues) Do(ctx context.Context, p peer.ID, class string, fn func(context.Context) error) error {
+	if q == nil {
+		return fn(ctx)
+	}
+	job := &sendJob{ctx: ctx, class: class, fn: fn, done: make(chan error, 1)}
+
+	q.mu.Lock()
+	pq, ok := q.queues[p]
+	if !ok {
+		pq = &peerSendQueue{jobs: make(chan *sendJob, q.size)}
+		q.queues[p] = pq
+	}
+	policy := q.policy
+	q.mu.Unlock()
+
+	select {
+	case pq.jobs <- job:
+	default:
+		if policy == SendQueueDrop {
+			sendQueueDroppedTotal.WithLabelValues(class).Inc()
+			return fmt.Errorf("%s send to %s: %w", class, shortPeerID(p), ErrSendQueueFull)
+		}
+		select {
+		case pq.jobs <- job:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	sendQueueDepth.WithLabelValues(class).Inc()
+
+	// A writer that found the queue empty may have exited since
+	q.mu.Lock()
+	if pq.writers < sendQueueWriters && (pq.writers == 0 || len(pq.jobs) > 0) {
+		pq.writers++
+		go q.write(p, pq)
+	}
+	q.mu.Unlock()
+
+	select {
+	case err := <-job.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// write runs the peer's queued sends until the queue is empty
+func (q *SendQueues) write(p peer.ID, pq *peerSendQueue) {
+	for {
+		var job *sendJob
+		select {
+		case job = <-pq.jobs:
+		default:
+			q.mu.Lock()
+			if len(pq.jobs) > 0 {
+				q.mu.Unlock()
+				continue
+			}
+			pq.writers--
+			if pq.writers == 0 && q.queues[p] == pq {
+				delete(q.queues, p)
+			}
+			q.mu.Unlock()
+			return
+		}
+		sendQueueDepth.WithLabelValues(job.class).Dec()
+		if err := job.ctx.Err(); err != nil {
+			job.done <- err
+			continue
+		}
+		job.done <- job.fn(job.ctx)
+	}
+}
+
+// Depth returns how many sends are waiting for a peer
+func (q *SendQueues) Depth(p peer.ID) int {
+	if q == nil {
+		return 0
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if pq, ok := q.queues[p]; ok {
+		return len(pq.jobs)
+	}
+	return 0
+}
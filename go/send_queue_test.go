@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSendQueues(t *testing.T) {
+	q := NewSendQueues()
+	if err := q.Configure(1, SendQueueDrop); err != nil {
+		t.Fatal(err)
+	}
+	slow, fast := newTestPeerID(t), newTestPeerID(t)
+	ctx := context.Background()
+
+	// Fill every writer for the slow peer, then its queue
+	release := make(chan struct{})
+	started := make(chan struct{}, sendQueueWriters)
+	results := make(chan error, sendQueueWriters+1)
+	block := func(context.Context) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	}
+	for i := 0; i < sendQueueWriters; i++ {
+		go func() { results <- q.Do(ctx, slow, sendClassShard, block) }()
+		<-started
+	}
+	go func() { results <- q.Do(ctx, slow, sendClassShard, block) }()
+	for deadline := time.Now().Add(2 * time.Second); q.Depth(slow) != 1; {
+		if time.Now().After(deadline) {
+			t.Fatal("send was not queued")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// Drop refuses more; other peers are unaffected
+	if err := q.Do(ctx, slow, sendClassShard, block); !errors.Is(err, ErrSendQueueFull) {
+		t.Fatalf("expected ErrSendQueueFull, got %v", err)
+	}
+	if err := q.Do(ctx, fast, sendClassChat, func(context.Context) error { return nil }); err != nil {
+		t.Fatalf("send to another peer failed: %v", err)
+	}
+
+	// Park waits for room until the caller gives up
+	if err := q.Configure(1, SendQueuePark); err != nil {
+		t.Fatal(err)
+	}
+	short, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if err := q.Do(short, slow, sendClassShard, block); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the parked send to time out, got %v", err)
+	}
+	parked := make(chan error, 1)
+	go func() {
+		parked <- q.Do(ctx, slow, sendClassShard, func(context.Context) error { return errors.New("sent") })
+	}()
+
+	close(release)
+	for i := 0; i < sendQueueWriters+1; i++ {
+		if err := <-results; err != nil {
+			t.Fatalf("queued send failed: %v", err)
+		}
+	}
+	select {
+	case err := <-parked:
+		if err == nil || err.Error() != "sent" {
+			t.Fatalf("parked send returned %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("parked send never ran")
+	}
+
+	if err := q.Configure(0, SendQueuePark); err == nil {
+		t.Error("expected a zero queue size to be refused")
+	}
+	if err := q.Configure(8, "spill"); err == nil {
+		t.Error("expected an unknown policy to be refused")
+	}
+}
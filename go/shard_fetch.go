@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/peerstore"
+	"github.com/multiformats/go-multihash"
+)
+
+const (
+	// defaultDownloadParallelism is the number of concurrent shard fetches
+	// when the download request does not specify one
+	defaultDownloadParallelism = 4
+
+	// shardProviderTimeout bounds a DHT provider announcement or lookup
+	shardProviderTimeout = 10 * time.Second
+
+	// maxShardProviders caps the providers a lookup returns per shard
+	maxShardProviders = 8
+)
+
+// shardCID is the DHT key under which holders of a shard announce it
+func shardCID(fileHash string, shardIndex uint32) (cid.Cid, error) {
+	mh, err := multihash.Sum([]byte(fmt.Sprintf("pangea-shard/%s/%d", fileHash, shardIndex)), multihash.SHA2_256, -1)
+	if err != nil {
+		return cid.Undef, err
+	}
+	return cid.NewCidV1(cid.Raw, mh), nil
+}
+
+// announceShard advertises this node as a provider of a stored shard so
+// downloads can find it when the recorded holder is unreachable
+func (n *LibP2PPangeaNode) announceShard(fileHash string, shardIndex uint32) {
+	if n.dht == nil {
+		return
+	}
+	c, err := shardCID(fileHash, shardIndex)
+	if err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(n.ctx, shardProviderTimeout)
+	defer cancel()
+	if err := n.dht.Provide(ctx, c, true); err != nil && n.ctx.Err() == nil {
+		log.Printf("⚠️  Failed to announce shard %d of %s: %v", shardIndex, fileHash, err)
+	}
+}
+
+// FindShardProviders looks up peers other than this node that announced a
+// shard. It returns nothing in local mode, where there is no DHT.
+func (n *LibP2PPangeaNode) FindShardProviders(ctx context.Context, fileHash string, shardIndex uint32) []peer.AddrInfo {
+	if n.dht == nil {
+		return nil
+	}
+	c, err := shardCID(fileHash, shardIndex)
+	if err != nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, shardProviderTimeout)
+	defer cancel()
+
+	var providers []peer.AddrInfo
+	for info := range n.dht.FindProvidersAsync(ctx, c, maxShardProviders) {
+		if info.ID != n.host.ID() {
+			providers = append(providers, info)
+		}
+	}
+	return providers
+}
+
+// ShardProviders returns the uint32 IDs of peers that announced a shard,
+// remembering their addresses so FetchShard can dial them
+func (a *LibP2PAdapter) ShardProviders(ctx context.Context, fileHash string, shardIndex uint32) []uint32 {
+	providers := a.node.FindShardProviders(ctx, fileHash, shardIndex)
+	ids := make([]uint32, 0, len(providers))
+	for _, info := range providers {
+		a.node.host.Peerstore().AddAddrs(info.ID, info.Addrs, peerstore.TempAddrTTL)
+		ids = append(ids, a.getPeerUint32ID(info.ID.String()))
+	}
+	return ids
+}
+
+// shardFetch records how one shard was fetched during a download
+type shardFetch struct {
+	shardIndex uint32
+	peerID     uint32 // Holder that served the shard, or the last one tried
+	attempts   uint32
+	duration   time.Duration
+	verified   bool // The shard matched its recorded hash
+	err        error
+}
+
+// shardSource is where a download gets shard bytes and, when the listed
+// holders fail, more holders to try. providers and onFetch may be nil.
+type shardSource struct {
+	fetch     func(ctx context.Context, peerID uint32, fileHash string, shardIndex uint32) ([]byte, error)
+	providers func(ctx context.Context, fileHash string, shardIndex uint32) []uint32
+
+	// onFetch is called from the fetching goroutine with each finished
+	// fetch, and the shard bytes when it succeeded
+	onFetch func(fetch shardFetch, data []byte)
+}
+
+// fetchShards fetches shards concurrently from their listed holders, trying
+// each holder of a shard in turn and then its DHT providers. Shards with a
+// recorded hash must match it. Fetching stops once need shards are
+// verified against their hash; shards without one are returned but never
+// stop it early. Shards never attempted are left out of the returned
+// fetches.
+func fetchShards(ctx context.Context, src shardSource, fileHash string, locations []shardPlacement, shardCount, need, parallelism int) ([]ShardData, []bool, []shardFetch) {
+	holders := make(map[uint32][]shardPlacement)
+	var indexes []uint32
+	for _, loc := range locations {
+		if int(loc.shardIndex) >= shardCount {
+			log.Printf("Warning: Ignoring location for shard %d of a %d shard file", loc.shardIndex, shardCount)
+			continue
+		}
+		if _, ok := holders[loc.shardIndex]; !ok {
+			indexes = append(indexes, loc.shardIndex)
+		}
+		holders[loc.shardIndex] = append(holders[loc.shardIndex], loc)
+	}
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i] < indexes[j] })
+
+	if parallelism <= 0 {
+		parallelism = defaultDownloadParallelism
+	}
+	if parallelism > len(indexes) {
+		parallelism = len(indexes)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobs := make(chan uint32, len(indexes))
+	for _, idx := range indexes {
+		jobs <- idx
+	}
+	close(jobs)
+
+	var (
+		shards   = make([]ShardData, shardCount)
+		present  = make([]bool, shardCount)
+		fetches  []shardFetch
+		verified int
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+	)
+	for w := 0; w < parallelism; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				if ctx.Err() != nil {
+					continue // Enough shards already verified
+				}
+				start := time.Now()
+				data, fetch := fetchShardWithFailover(ctx, src, fileHash, idx, holders[idx])
+				fetch.duration = time.Since(start)
+				if src.onFetch != nil {
+					src.onFetch(fetch, data)
+				}
+
+				mu.Lock()
+				fetches = append(fetches, fetch)
+				if fetch.err == nil {
+					shards[idx] = ShardData{Data: data}
+					present[idx] = true
+				}
+				if fetch.verified {
+					verified++
+					if verified >= need {
+						cancel()
+					}
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	sort.Slice(fetches, func(i, j int) bool { return fetches[i].shardIndex < fetches[j].shardIndex })
+	return shards, present, fetches
+}
+
+// fetchShardWithFailover tries a shard's holders in order, then any DHT
+// providers not already tried, until one returns bytes matching the hash.
+// Without a recorded hash the first bytes returned are taken unverified.
+func fetchShardWithFailover(ctx context.Context, src shardSource, fileHash string, shardIndex uint32, holders []shardPlacement) ([]byte, shardFetch) {
+	fetch := shardFetch{shardIndex: shardIndex}
+	var expected string
+	candidates := make([]uint32, 0, len(holders))
+	tried := make(map[uint32]bool)
+	for _, h := range holders {
+		candidates = append(candidates, h.peerID)
+		if h.shardHash != "" {
+			expected = h.shardHash
+		}
+	}
+
+	lookedUp := src.providers == nil
+	for i := 0; ; i++ {
+		if i == len(candidates) {
+			if lookedUp {
+				break
+			}
+			lookedUp = true
+			candidates = append(candidates, src.providers(ctx, fileHash, shardIndex)...)
+			if i == len(candidates) {
+				break
+			}
+		}
+		peerID := candidates[i]
+		if tried[peerID] {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			fetch.err = err
+			return nil, fetch
+		}
+		tried[peerID] = true
+		fetch.peerID = peerID
+		fetch.attempts++
+
+		data, err := src.fetch(ctx, peerID, fileHash, shardIndex)
+		if err != nil {
+			log.Printf("Warning: Failed to fetch shard %d from peer %d: %v", shardIndex, peerID, err)
+			fetch.err = err
+			continue
+		}
+		if expected == "" {
+			fetch.err = nil
+			return data, fetch
+		}
+		if fmt.Sprintf("%x", sha256.Sum256(data)) != expected {
+			log.Printf("Warning: Shard %d from peer %d does not match its recorded hash", shardIndex, peerID)
+			fetch.err = fmt.Errorf("shard %d from peer %d does not match its recorded hash", shardIndex, peerID)
+			continue
+		}
+		fetch.verified, fetch.err = true, nil
+		return data, fetch
+	}
+	if fetch.err == nil {
+		fetch.err = fmt.Errorf("no holders for shard %d", shardIndex)
+	}
+	return nil, fetch
+}
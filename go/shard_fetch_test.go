@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/pangea-net/go-node/internal/testutil"
+)
+
+// newShardHolders starts an in-memory network on which node 0 fetches from
+// holders 1 to count, each holding every shard of file. Holders in down are
+// unreachable and holders in bad serve corrupt bytes.
+func newShardHolders(file string, count int, down, bad map[uint32]bool) (*testutil.Network, *testutil.Adapter) {
+	n := testutil.NewNetwork(1)
+	ids := []uint32{0}
+	for id := uint32(1); id <= uint32(count); id++ {
+		ids = append(ids, id)
+	}
+	n.Connect(ids...)
+	for _, id := range ids[1:] {
+		for i := uint32(0); i < cesDataShards+cesParityShards; i++ {
+			data := fakeShardBytes(i)
+			if bad[id] {
+				data = []byte("corrupt")
+			}
+			n.Node(id).StoreShard(file, i, data)
+		}
+		if down[id] {
+			n.SetLink(0, id, testutil.Link{Down: true})
+		}
+	}
+	return n, n.Node(0)
+}
+
+// servedFetches counts the fetches that reached holders 1 to count
+func servedFetches(n *testutil.Network, count int) int {
+	var served int
+	for id := uint32(1); id <= uint32(count); id++ {
+		served += n.Node(id).Served()
+	}
+	return served
+}
+
+func fakeShardBytes(shardIndex uint32) []byte {
+	return []byte(fmt.Sprintf("shard-%d", shardIndex))
+}
+
+func fakeShardHash(shardIndex uint32) string {
+	return fmt.Sprintf("%x", sha256.Sum256(fakeShardBytes(shardIndex)))
+}
+
+func TestFetchShardsFailsOverToAlternateHolders(t *testing.T) {
+	_, local := newShardHolders("file", 4, map[uint32]bool{1: true}, map[uint32]bool{3: true})
+	src := shardSource{
+		fetch: local.FetchShard,
+		providers: func(ctx context.Context, fileHash string, shardIndex uint32) []uint32 {
+			return []uint32{3, 4} // 3 was already tried for shard 1
+		},
+	}
+	locations := []shardPlacement{
+		{shardIndex: 0, peerID: 1},
+		{shardIndex: 0, peerID: 2},
+		{shardIndex: 1, peerID: 3, shardHash: fakeShardHash(1)},
+	}
+
+	shards, present, fetches := fetchShards(context.Background(), src, "file", locations, 4, 4, 2)
+	if !present[0] || !present[1] || present[2] {
+		t.Fatalf("unexpected present shards %v", present)
+	}
+	if string(shards[1].Data) != string(fakeShardBytes(1)) {
+		t.Fatalf("expected the provider's copy of shard 1, got %q", shards[1].Data)
+	}
+	if len(fetches) != 2 {
+		t.Fatalf("expected 2 fetch records, got %d", len(fetches))
+	}
+	// Shard 0 has no recorded hash, so it is used but not verified
+	if f := fetches[0]; f.peerID != 2 || f.attempts != 2 || f.verified || f.err != nil {
+		t.Errorf("unexpected shard 0 fetch %+v", f)
+	}
+	if f := fetches[1]; f.peerID != 4 || f.attempts != 2 || !f.verified {
+		t.Errorf("unexpected shard 1 fetch %+v", f)
+	}
+}
+
+func TestFetchShardsReportsUnrecoverableShards(t *testing.T) {
+	_, local := newShardHolders("file", 2, map[uint32]bool{1: true}, nil)
+	locations := []shardPlacement{{shardIndex: 0, peerID: 1}, {shardIndex: 9, peerID: 2}}
+
+	_, present, fetches := fetchShards(context.Background(), shardSource{fetch: local.FetchShard}, "file", locations, 4, 4, 0)
+	if present[0] {
+		t.Fatal("expected shard 0 to be missing")
+	}
+	if len(fetches) != 1 || fetches[0].verified || fetches[0].err == nil || fetches[0].attempts != 1 {
+		t.Fatalf("expected one failed fetch, got %+v", fetches)
+	}
+}
+
+func TestFetchShardsStopsOnceEnoughAreVerified(t *testing.T) {
+	n, local := newShardHolders("file", cesDataShards+cesParityShards, nil, nil)
+	locations := make([]shardPlacement, cesDataShards+cesParityShards)
+	for i := range locations {
+		locations[i] = shardPlacement{shardIndex: uint32(i), peerID: uint32(i + 1), shardHash: fakeShardHash(uint32(i))}
+	}
+
+	_, present, fetches := fetchShards(context.Background(), shardSource{fetch: local.FetchShard}, "file", locations, len(locations), cesDataShards, 1)
+	count := 0
+	for _, p := range present {
+		if p {
+			count++
+		}
+	}
+	if calls := servedFetches(n, len(locations)); count != cesDataShards || len(fetches) != cesDataShards || calls != cesDataShards {
+		t.Fatalf("expected exactly %d fetches, got %d present, %d records, %d calls", cesDataShards, count, len(fetches), calls)
+	}
+	// Unhashed shards cannot be verified, so they never end fetching early
+	for i := range locations {
+		locations[i].shardHash = ""
+	}
+	_, present, fetches = fetchShards(context.Background(), shardSource{fetch: local.FetchShard}, "file", locations, len(locations), cesDataShards, 1)
+	for i, p := range present {
+		if !p || fetches[i].verified {
+			t.Fatalf("expected every unhashed shard fetched unverified, got %+v", fetches[i])
+		}
+	}
+}
+
+func TestFetchShardsCancelsInFlightFetches(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	src := shardSource{fetch: func(ctx context.Context, peerID uint32, fileHash string, shardIndex uint32) ([]byte, error) {
+		cancel()
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}}
+	locations := []shardPlacement{{shardIndex: 0, peerID: 1}, {shardIndex: 0, peerID: 2}}
+
+	_, present, fetches := fetchShards(ctx, src, "file", locations, 1, 1, 1)
+	if present[0] || len(fetches) != 1 || fetches[0].attempts != 1 || !errors.Is(fetches[0].err, context.Canceled) {
+		t.Fatalf("expected the fetch abandoned with its context, got %+v", fetches)
+	}
+}
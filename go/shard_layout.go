@@ -0,0 +1,126 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+// defaultPeerBandwidthMbps is assumed for peers that have not advertised
+// their bandwidth
+const defaultPeerBandwidthMbps = 100.0
+
+// LayoutPeer is one peer's share of a proposed shard layout
+type LayoutPeer struct {
+	PeerID        uint32
+	ShardCount    uint32
+	Uptime        float64
+	LatencyMs     float32
+	BandwidthMbps float32
+}
+
+// ShardLayout is the shard layout an upload would use, computed without
+// sending anything
+type ShardLayout struct {
+	Policy         string
+	Holders        []uint32 // Holder peer for each shard index
+	Peers          []LayoutPeer
+	DataShards     uint32
+	ParityShards   uint32
+	ShardSizeBytes uint64
+	// Durability is the probability that enough shards stay reachable to
+	// reconstruct the file, treating peer uptimes as independent
+	Durability    float64
+	EstimatedTime time.Duration
+}
+
+// planShardLayout runs the placement policy over the candidates exactly as an
+// upload would, then estimates durability and transfer time for the layout.
+// Shard sizes assume incompressible data.
+func planShardLayout(policy PlacementPolicy, candidates []PeerCandidate, fileSize uint64, parallelism int) (*ShardLayout, error) {
+	shardCount := cesDataShards + cesParityShards
+	holders, err := policy.Assign(candidates, shardCount)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &ShardLayout{
+		Policy:         policy.Name(),
+		Holders:        holders,
+		DataShards:     cesDataShards,
+		ParityShards:   cesParityShards,
+		ShardSizeBytes: (fileSize + cesDataShards - 1) / cesDataShards,
+	}
+
+	byID := make(map[uint32]PeerCandidate, len(candidates))
+	for _, c := range candidates {
+		byID[c.PeerID] = c
+	}
+	counts := make(map[uint32]uint32)
+	for _, h := range holders {
+		counts[h]++
+	}
+	for id, n := range counts {
+		c := byID[id]
+		bandwidth := c.BandwidthMbps
+		if bandwidth <= 0 {
+			bandwidth = defaultPeerBandwidthMbps
+		}
+		plan.Peers = append(plan.Peers, LayoutPeer{
+			PeerID:        id,
+			ShardCount:    n,
+			Uptime:        c.Uptime,
+			LatencyMs:     c.LatencyMs,
+			BandwidthMbps: bandwidth,
+		})
+	}
+	sort.Slice(plan.Peers, func(i, j int) bool { return plan.Peers[i].PeerID < plan.Peers[j].PeerID })
+
+	plan.Durability = reconstructProbability(plan.Peers, cesDataShards)
+	plan.EstimatedTime = estimateUploadTime(plan.Peers, plan.ShardSizeBytes, parallelism)
+	return plan, nil
+}
+
+// reconstructProbability returns the probability that at least needed
+// shards are reachable. A peer's shards are lost together when it is down.
+func reconstructProbability(peers []LayoutPeer, needed int) float64 {
+	// dist[n] is the probability that exactly n shards are reachable
+	dist := []float64{1}
+	for _, p := range peers {
+		next := make([]float64, len(dist)+int(p.ShardCount))
+		for n, prob := range dist {
+			next[n] += prob * (1 - p.Uptime)
+			next[n+int(p.ShardCount)] += prob * p.Uptime
+		}
+		dist = next
+	}
+
+	total := 0.0
+	for n := needed; n < len(dist); n++ {
+		total += dist[n]
+	}
+	return total
+}
+
+// estimateUploadTime estimates how long distributing the shards takes with
+// parallelism concurrent sends: no faster than the slowest peer's share, nor
+// than the total send time spread across the send slots
+func estimateUploadTime(peers []LayoutPeer, shardSize uint64, parallelism int) time.Duration {
+	if parallelism <= 0 {
+		parallelism = defaultUploadParallelism
+	}
+
+	var slowest, total float64
+	for _, p := range peers {
+		perShard := float64(shardSize)*8/(float64(p.BandwidthMbps)*1e6) + float64(p.LatencyMs)/1000
+		peerTime := perShard * float64(p.ShardCount)
+		total += peerTime
+		if peerTime > slowest {
+			slowest = peerTime
+		}
+	}
+	secs := total / float64(parallelism)
+	if slowest > secs {
+		secs = slowest
+	}
+	return time.Duration(secs * float64(time.Second))
+}
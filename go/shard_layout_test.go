@@ -0,0 +1,55 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestPlanShardLayoutMatchesPlacement(t *testing.T) {
+	policy, err := NewPlacementPolicy("round_robin")
+	if err != nil {
+		t.Fatalf("NewPlacementPolicy failed: %v", err)
+	}
+	candidates := []PeerCandidate{
+		{PeerID: 1, Uptime: 0.5, Eligible: true},
+		{PeerID: 2, Uptime: 0.5, Eligible: true},
+		{PeerID: 3, Uptime: 0.5, Eligible: true, BandwidthMbps: 100},
+	}
+
+	layout, err := planShardLayout(policy, candidates, 8*1000*1000, 4)
+	if err != nil {
+		t.Fatalf("planShardLayout failed: %v", err)
+	}
+	holders, _ := policy.Assign(candidates, cesDataShards+cesParityShards)
+	if len(layout.Holders) != len(holders) {
+		t.Fatalf("expected %d holders, got %d", len(holders), len(layout.Holders))
+	}
+	for i := range holders {
+		if layout.Holders[i] != holders[i] {
+			t.Errorf("shard %d: plan uses peer %d, upload would use %d", i, layout.Holders[i], holders[i])
+		}
+	}
+	if len(layout.Peers) != 3 || layout.Peers[0].ShardCount != 4 {
+		t.Fatalf("expected 4 shards on each of 3 peers, got %+v", layout.Peers)
+	}
+	if layout.ShardSizeBytes != 1000*1000 {
+		t.Errorf("expected 1 MB shards, got %d bytes", layout.ShardSizeBytes)
+	}
+
+	// 8 of 12 shards need at least 2 of the 3 peers up
+	if math.Abs(layout.Durability-0.5) > 1e-9 {
+		t.Errorf("expected durability 0.5, got %f", layout.Durability)
+	}
+	// Each peer takes 4 x 80ms at 100 Mbps, more than the parallel total
+	if layout.EstimatedTime != 320*time.Millisecond {
+		t.Errorf("expected 320ms transfer estimate, got %v", layout.EstimatedTime)
+	}
+}
+
+func TestPlanShardLayoutNoEligiblePeers(t *testing.T) {
+	policy, _ := NewPlacementPolicy("scored")
+	if _, err := planShardLayout(policy, []PeerCandidate{{PeerID: 1}}, 1024, 0); err == nil {
+		t.Error("expected planning without eligible peers to fail")
+	}
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ShardGCInterval is how often held shards past the retention period are
+// collected
+const ShardGCInterval = 10 * time.Minute
+
+// maxPinRefs bounds the hashes one pinned manifest may cover
+const maxPinRefs = 100000
+
+// Pin keeps content held by this node from being collected. A pin on a
+// file hash covers its shards; a pin on a manifest also covers the blobs
+// it references, so a whole tree or version history is pinned at once.
+type Pin struct {
+	Hash   string   `json:"hash"`
+	Label  string   `json:"label,omitempty"`
+	Refs   []string `json:"refs,omitempty"` // Hashes the pinned manifest references
+	Pinned int64    `json:"pinned"`         // Unix seconds
+}
+
+// PinSet is the set of pins of a node. Pinned content is exempt from
+// retention GC and quota eviction.
+type PinSet struct {
+	path    string // Empty keeps pins in memory only
+	pins    map[string]*Pin
+	covered map[string]int // Hash -> pins covering it, directly or as a ref
+	mu      sync.RWMutex
+}
+
+// NewPinSet creates an empty pin set
+func NewPinSet() *PinSet {
+	return &PinSet{pins: make(map[string]*Pin), covered: make(map[string]int)}
+}
+
+// SetPath persists pins to path and loads any already there
+func (ps *PinSet) SetPath(path string) error {
+	var saved []*Pin
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &saved); err != nil {
+			return fmt.Errorf("invalid pin file: %w", err)
+		}
+	}
+
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	ps.path = path
+	for _, p := range saved {
+		if p.Hash != "" {
+			ps.addLocked(p)
+		}
+	}
+	if len(saved) > 0 {
+		log.Printf("📌 [PINS] Loaded %d pins from %s", len(saved), path)
+	}
+	return nil
+}
+
+// Pin pins hash and the hashes in refs, replacing any earlier pin of hash
+func (ps *PinSet) Pin(hash, label string, refs []string) (Pin, error) {
+	if hash == "" {
+		return Pin{}, fmt.Errorf("no hash to pin")
+	}
+	if len(refs) > maxPinRefs {
+		return Pin{}, fmt.Errorf("a pin covers at most %d refs, got %d", maxPinRefs, len(refs))
+	}
+	p := &Pin{Hash: hash, Label: label, Pinned: time.Now().Unix()}
+	for _, ref := range refs {
+		if ref != "" && ref != hash {
+			p.Refs = append(p.Refs, ref)
+		}
+	}
+
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	ps.removeLocked(hash)
+	ps.addLocked(p)
+	ps.saveLocked()
+	return *p, nil
+}
+
+// Unpin removes the pin of hash, reporting whether there was one. Content
+// still covered by another pin stays pinned.
+func (ps *PinSet) Unpin(hash string) bool {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	if !ps.removeLocked(hash) {
+		return false
+	}
+	ps.saveLocked()
+	return true
+}
+
+// Get returns the pin of hash itself, if any
+func (ps *PinSet) Get(hash string) (Pin, bool) {
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+	p, ok := ps.pins[hash]
+	if !ok {
+		return Pin{}, false
+	}
+	return *p, true
+}
+
+// Covers reports whether hash is pinned, directly or as a ref of a pinned
+// manifest
+func (ps *PinSet) Covers(hash string) bool {
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+	return ps.covered[hash] > 0
+}
+
+// List returns every pin, oldest first
+func (ps *PinSet) List() []Pin {
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+	pins := make([]Pin, 0, len(ps.pins))
+	for _, p := range ps.pins {
+		pins = append(pins, *p)
+	}
+	sort.Slice(pins, func(i, j int) bool {
+		if pins[i].Pinned != pins[j].Pinned {
+			return pins[i].Pinned < pins[j].Pinned
+		}
+		return pins[i].Hash < pins[j].Hash
+	})
+	return pins
+}
+
+func (ps *PinSet) addLocked(p *Pin) {
+	ps.pins[p.Hash] = p
+	ps.covered[p.Hash]++
+	for _, ref := range p.Refs {
+		ps.covered[ref]++
+	}
+}
+
+func (ps *PinSet) removeLocked(hash string) bool {
+	p, ok := ps.pins[hash]
+	if !ok {
+		return false
+	}
+	delete(ps.pins, hash)
+	for _, h := range append([]string{hash}, p.Refs...) {
+		if ps.covered[h]--; ps.covered[h] <= 0 {
+			delete(ps.covered, h)
+		}
+	}
+	return true
+}
+
+func (ps *PinSet) saveLocked() {
+	if ps.path == "" {
+		return
+	}
+	pins := make([]*Pin, 0, len(ps.pins))
+	for _, p := range ps.pins {
+		pins = append(pins, p)
+	}
+	data, err := json.MarshalIndent(pins, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(ps.path), 0700)
+	}
+	if err == nil {
+		err = os.WriteFile(ps.path+".tmp", data, 0600)
+	}
+	if err == nil {
+		err = os.Rename(ps.path+".tmp", ps.path)
+	}
+	if err != nil {
+		log.Printf("⚠️  [PINS] Failed to save pins: %v", err)
+	}
+}
+
+// ShardRetention controls when a node drops shards it holds. Pinned files
+// are always kept, as is everything while the node is partitioned.
+type ShardRetention struct {
+	TTL          time.Duration // Drop files whose shards were last stored this long ago, 0 = keep
+	EvictOnQuota bool          // Drop the oldest files to fit new shards instead of refusing them
+}
+
+// SetShardRetention sets the node's shard retention policy
+func (n *LibP2PPangeaNode) SetShardRetention(r ShardRetention) {
+	n.shardMu.Lock()
+	defer n.shardMu.Unlock()
+	n.retention = r
+}
+
+// GetPins returns the node's pin set
+func (n *LibP2PPangeaNode) GetPins() *PinSet {
+	return n.pins
+}
+
+// LocalShardUsage returns how many shards of fileHash this node holds and
+// their total size
+func (n *LibP2PPangeaNode) LocalShardUsage(fileHash string) (shards int, bytes uint64) {
+	n.shardMu.RLock()
+	defer n.shardMu.RUnlock()
+	for _, data := range n.shardStore[fileHash] {
+		shards++
+		bytes += uint64(len(data))
+	}
+	return shards, bytes
+}
+
+// runShardGC collects shards past the retention period every
+// ShardGCInterval until ctx is cancelled
+func (n *LibP2PPangeaNode) runShardGC(ctx context.Context) {
+	ticker := time.NewTicker(ShardGCInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n.collectShards(time.Now())
+		}
+	}
+}
+
+// collectShards drops the unpinned files whose shards were last stored
+// more than the retention TTL before now, returning how many were dropped
+func (n *LibP2PPangeaNode) collectShards(now time.Time) int {
+	if n.partition.Partitioned() {
+		return 0
+	}
+	n.shardMu.Lock()
+	ttl := n.retention.TTL
+	if ttl <= 0 {
+		n.shardMu.Unlock()
+		return 0
+	}
+	var dropped []string
+	var events []DiskEvent
+	for fileHash, stored := range n.shardStored {
+		if now.Sub(stored) > ttl && !n.pins.Covers(fileHash) {
+			events = append(events, n.dropShardsLocked(fileHash)...)
+			dropped = append(dropped, fileHash)
+		}
+	}
+	dm := n.disk
+	n.shardMu.Unlock()
+
+	dm.emit(events)
+	for _, fileHash := range dropped {
+		n.publishShardsDropped(fileHash, "expired")
+	}
+	if len(dropped) > 0 {
+		log.Printf("🧹 [GC] Dropped shards of %d files held longer than %s", len(dropped), ttl)
+	}
+	return len(dropped)
+}
+
+// evictOldestLocked drops the unpinned file whose shards were stored
+// longest ago, other than keep, to make room for new shards. It returns
+// the file dropped and false if there is none to drop. Caller must hold
+// shardMu.
+func (n *LibP2PPangeaNode) evictOldestLocked(keep string) (string, []DiskEvent, bool) {
+	var oldest string
+	var oldestAt time.Time
+	for fileHash, stored := range n.shardStored {
+		if fileHash == keep || n.pins.Covers(fileHash) {
+			continue
+		}
+		if oldest == "" || stored.Before(oldestAt) || (stored.Equal(oldestAt) && fileHash < oldest) {
+			oldest, oldestAt = fileHash, stored
+		}
+	}
+	if oldest == "" {
+		return "", nil, false
+	}
+	return oldest, n.dropShardsLocked(oldest), true
+}
+
+// canEvictLocked reports whether evicting unpinned files other than keep
+// could free room for need more bytes. Caller must hold shardMu.
+func (n *LibP2PPangeaNode) canEvictLocked(keep string, need uint64) bool {
+	if !n.retention.EvictOnQuota || n.partition.Partitioned() {
+		return false
+	}
+	var evictable uint64
+	for fileHash, shards := range n.shardStore {
+		if fileHash == keep || n.pins.Covers(fileHash) {
+			continue
+		}
+		for _, data := range shards {
+			evictable += uint64(len(data))
+		}
+	}
+	used, quota := n.disk.Usage()
+	return evictable > 0 && quota > 0 && used+need <= quota+evictable
+}
+
+// finishEviction emits disk events and reports evicted files once shardMu
+// is released
+func (n *LibP2PPangeaNode) finishEviction(dm *DiskMonitor, events []DiskEvent, evicted []string) {
+	dm.emit(events)
+	for _, fileHash := range evicted {
+		n.publishShardsDropped(fileHash, "evicted")
+	}
+	if len(evicted) > 0 {
+		log.Printf("🧹 [GC] Evicted shards of %d unpinned files to stay within quota", len(evicted))
+	}
+}
+
+// dropShardsLocked deletes every shard of fileHash and returns its bytes
+// to the quota. Caller must hold shardMu.
+func (n *LibP2PPangeaNode) dropShardsLocked(fileHash string) []DiskEvent {
+	var freed uint64
+	for _, data := range n.shardStore[fileHash] {
+		freed += uint64(len(data))
+	}
+	delete(n.shardStore, fileHash)
+	delete(n.shardStored, fileHash)
+	return n.disk.release(freed)
+}
+
+// publishShardsDropped reports that the node no longer holds fileHash
+func (n *LibP2PPangeaNode) publishShardsDropped(fileHash, reason string) {
+	n.events.Publish(NodeEvent{
+		Type:       EventShardsDropped,
+		Subject:    fileHash,
+		Attributes: map[string]string{"reason": reason},
+	})
+}
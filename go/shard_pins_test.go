@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPinnedShardsSurviveEvictionAndGC(t *testing.T) {
+	node, err := NewLibP2PPangeaNodeWithOptions(580, NewNodeStore(), true, true, 0)
+	if err != nil {
+		t.Fatalf("failed to create node: %v", err)
+	}
+	defer node.cancel()
+	config := DefaultDiskMonitorConfig()
+	config.QuotaBytes = 1000
+	config.ReadOnlyRatio = 1.0
+	node.ConfigureDiskMonitor(config)
+
+	shard := make([]byte, 300)
+	for _, fileHash := range []string{"pinned", "ref", "old", "new"} {
+		if err := node.StoreShard(fileHash, 0, shard); err != nil && fileHash != "new" {
+			t.Fatalf("store %s failed: %v", fileHash, err)
+		}
+	}
+	if _, ok := node.FetchLocalShard("new", 0); ok {
+		t.Fatal("expected the shard over quota refused without eviction")
+	}
+
+	pins := node.GetPins()
+	if _, err := pins.Pin("pinned", "", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pins.Pin("manifest", "tree", []string{"ref"}); err != nil {
+		t.Fatal(err)
+	}
+	node.SetShardRetention(ShardRetention{TTL: time.Hour, EvictOnQuota: true})
+	if err := node.StoreShard("new", 0, shard); err != nil {
+		t.Fatalf("expected eviction to make room: %v", err)
+	}
+	for fileHash, want := range map[string]bool{"pinned": true, "ref": true, "old": false, "new": true} {
+		if _, ok := node.FetchLocalShard(fileHash, 0); ok != want {
+			t.Fatalf("%s held = %v, expected %v", fileHash, ok, want)
+		}
+	}
+	// The oldest unpinned file goes first, and a shard that cannot fit even
+	// with every unpinned file gone evicts nothing
+	if err := node.StoreShard("newer", 0, shard); err != nil {
+		t.Fatalf("expected new to be evicted for newer: %v", err)
+	}
+	if _, ok := node.FetchLocalShard("new", 0); ok {
+		t.Fatal("expected new evicted")
+	}
+	if err := node.StoreShard("huge", 0, make([]byte, 700)); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("expected quota error, got %v", err)
+	}
+	if _, ok := node.FetchLocalShard("newer", 0); !ok {
+		t.Fatal("a refused store must not evict anything")
+	}
+
+	// Past the TTL only unpinned files go; unpinning the manifest frees its refs
+	pins.Unpin("manifest")
+	if n := node.collectShards(time.Now().Add(30 * time.Minute)); n != 0 {
+		t.Fatalf("expected nothing collected within the TTL, got %d", n)
+	}
+	if n := node.collectShards(time.Now().Add(2 * time.Hour)); n != 2 {
+		t.Fatalf("expected ref and newer collected, got %d", n)
+	}
+	if _, ok := node.FetchLocalShard("pinned", 0); !ok {
+		t.Fatal("pinned shard was collected")
+	}
+	if used, _ := node.GetDiskMonitor().Usage(); used != 300 {
+		t.Fatalf("expected collected shards released from the quota, %d bytes used", used)
+	}
+}
+
+func TestPinSetPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pins.json")
+	pins := NewPinSet()
+	if err := pins.SetPath(path); err != nil {
+		t.Fatal(err)
+	}
+	pins.Pin("a", "first", []string{"b", "c"})
+	pins.Pin("c", "", nil)
+	if _, err := pins.Pin("", "", nil); err == nil {
+		t.Fatal("expected an empty hash to be refused")
+	}
+
+	loaded := NewPinSet()
+	if err := loaded.SetPath(path); err != nil {
+		t.Fatal(err)
+	}
+	if got := loaded.List(); len(got) != 2 {
+		t.Fatalf("expected 2 pins loaded, got %+v", got)
+	}
+	// c stays covered by its own pin after a is unpinned
+	loaded.Unpin("a")
+	if loaded.Covers("b") || !loaded.Covers("c") {
+		t.Fatal("unpinning a manifest must release only refs no other pin covers")
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestSendShardReceivesPlacementAck(t *testing.T) {
+	store1 := NewNodeStore()
+	n1, err := NewLibP2PPangeaNodeWithOptions(401, store1, false, true, 12410)
+	if err != nil {
+		t.Fatalf("failed to create node1: %v", err)
+	}
+	defer n1.cancel()
+
+	store2 := NewNodeStore()
+	n2, err := NewLibP2PPangeaNodeWithOptions(402, store2, false, true, 12411)
+	if err != nil {
+		t.Fatalf("failed to create node2: %v", err)
+	}
+	defer n2.cancel()
+
+	lib1 := NewLibP2PAdapter(n1, store1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := n1.host.Connect(ctx, peer.AddrInfo{ID: n2.host.ID(), Addrs: n2.host.Addrs()}); err != nil {
+		t.Fatalf("connect n1->n2 failed: %v", err)
+	}
+
+	lib1.peerIDToUint32[n2.host.ID().String()] = 2
+	lib1.uint32ToPeerID[2] = n2.host.ID().String()
+
+	// Larger than any single stream read, to catch truncation
+	shard := make([]byte, 3*1024*1024)
+	for i := range shard {
+		shard[i] = byte(i % 251)
+	}
+
+	if err := lib1.SendShard(2, "placement-test", 7, shard); err != nil {
+		t.Fatalf("SendShard failed: %v", err)
+	}
+
+	stored, ok := n2.FetchLocalShard("placement-test", 7)
+	if !ok {
+		t.Fatal("shard not stored on receiving peer")
+	}
+	if len(stored) != len(shard) {
+		t.Fatalf("stored shard has %d bytes, want %d", len(stored), len(shard))
+	}
+
+	fetched, err := lib1.FetchShard(context.Background(), 2, "placement-test", 7)
+	if err != nil {
+		t.Fatalf("FetchShard failed: %v", err)
+	}
+	if !bytes.Equal(fetched, shard) {
+		t.Fatalf("fetched shard has %d bytes, want the %d stored", len(fetched), len(shard))
+	}
+	if _, err := lib1.FetchShard(context.Background(), 2, "placement-test", 8); err == nil {
+		t.Fatal("expected a missing shard to be reported")
+	}
+	// DKG shares travel over the same framed protocol
+	if err := lib1.SendShare(2, "share-test", []byte("share")); err != nil {
+		t.Fatalf("SendShare failed: %v", err)
+	}
+	if share, err := lib1.FetchShare(2, "share-test"); err != nil || string(share) != "share" {
+		t.Fatalf("FetchShare returned %q, %v", share, err)
+	}
+}
+
+func TestOversizedShardIsRejectedNotTruncated(t *testing.T) {
+	store1 := NewNodeStore()
+	n1, err := NewLibP2PPangeaNodeWithOptions(403, store1, false, true, 12412)
+	if err != nil {
+		t.Fatalf("failed to create node1: %v", err)
+	}
+	defer n1.cancel()
+
+	store2 := NewNodeStore()
+	n2, err := NewLibP2PPangeaNodeWithOptions(404, store2, false, true, 12413)
+	if err != nil {
+		t.Fatalf("failed to create node2: %v", err)
+	}
+	defer n2.cancel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := n1.host.Connect(ctx, peer.AddrInfo{ID: n2.host.ID(), Addrs: n2.host.Addrs()}); err != nil {
+		t.Fatalf("connect n1->n2 failed: %v", err)
+	}
+
+	rs, err := openRPCStream(ctx, n1.host, n2.host.ID())
+	if err != nil {
+		t.Fatalf("failed to open stream: %v", err)
+	}
+	defer rs.Close()
+
+	// SendShard refuses to send this, so build the request by hand
+	fileHash := "oversized"
+	req := appendRPCString(nil, fileHash)
+	req = append(req, 0, 0, 0, 1)
+	req = binary.BigEndian.AppendUint64(req, maxShardSize+1)
+	ack, err := rs.call(rpcMsgStoreShard, req, rpcMsgStoreShardAck)
+	if err != nil {
+		t.Fatalf("no ack: %v", err)
+	}
+	if ack[0] != shardAckTooLarge {
+		t.Errorf("expected too-large status, got %d", ack[0])
+	}
+	if _, ok := n2.FetchLocalShard(fileHash, 1); ok {
+		t.Error("oversized shard should not be stored")
+	}
+}
+
+func TestPlacementQuorum(t *testing.T) {
+	placements := make([]shardPlacement, cesDataShards+cesParityShards)
+	for i := 0; i < cesDataShards; i++ {
+		placements[i].confirmed = true
+	}
+
+	confirmed, required := placementQuorum(placements)
+	if required != cesDataShards {
+		t.Errorf("expected quorum of %d, got %d", cesDataShards, required)
+	}
+	if confirmed != cesDataShards {
+		t.Errorf("expected %d confirmed, got %d", cesDataShards, confirmed)
+	}
+
+	placements[0].confirmed = false
+	confirmed, _ = placementQuorum(placements)
+	if confirmed >= required {
+		t.Errorf("expected quorum failure with %d confirmed", confirmed)
+	}
+}
+
+func TestUploadParallelism(t *testing.T) {
+	for _, c := range []struct{ requested, holders, want int }{
+		{0, 14, defaultUploadParallelism},
+		{-3, 14, defaultUploadParallelism},
+		{2, 14, 2},
+		{100, 14, maxUploadParallelism},
+		{8, 3, 3},
+		{0, 0, 1},
+	} {
+		if got := uploadParallelism(c.requested, c.holders); got != c.want {
+			t.Errorf("uploadParallelism(%d, %d) = %d, want %d", c.requested, c.holders, got, c.want)
+		}
+	}
+}
+
+func TestDistributeShardsInParallel(t *testing.T) {
+	const shards, parallelism = 12, 3
+	var inFlight, peak, emitted atomic.Int32
+	var sendsBeforeLastEmit atomic.Bool
+	produce := func(emit func(int, []byte)) error {
+		for i := 0; i < shards; i++ {
+			emitted.Add(1)
+			emit(i, bytes.Repeat([]byte{byte(i)}, 100))
+		}
+		return nil
+	}
+	send := func(index int, data []byte) (shardPlacement, bool) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		if emitted.Load() < shards {
+			sendsBeforeLastEmit.Store(true)
+		}
+		time.Sleep(20 * time.Millisecond)
+		// Every third shard fails
+		ok := index%3 != 2
+		return shardPlacement{shardIndex: uint32(index), confirmed: ok}, ok
+	}
+
+	start := time.Now()
+	placements, sent, err := distributeShards(parallelism, produce, send)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := peak.Load(); p != parallelism {
+		t.Errorf("%d sends ran at once, expected %d", p, parallelism)
+	}
+	if took := time.Since(start); took > shards*20*time.Millisecond*2/parallelism {
+		t.Errorf("%d sends of 20ms with parallelism %d took %v", shards, parallelism, took)
+	}
+	if !sendsBeforeLastEmit.Load() {
+		t.Error("no send started before the last shard was emitted")
+	}
+	if len(placements) != shards {
+		t.Fatalf("%d placements for %d shards", len(placements), shards)
+	}
+	for i, p := range placements {
+		if p.shardIndex != uint32(i) || p.confirmed != (i%3 != 2) {
+			t.Fatalf("placement %d out of order or wrong: %+v", i, p)
+		}
+	}
+	if sent != 8*100 {
+		t.Errorf("%d bytes counted as sent, expected %d", sent, 8*100)
+	}
+
+	// A pipeline failure is returned once the shards already emitted are sent
+	failed := errors.New("ces failed")
+	var sends atomic.Int32
+	_, _, err = distributeShards(parallelism, func(emit func(int, []byte)) error {
+		emit(0, []byte("x"))
+		return failed
+	}, func(index int, data []byte) (shardPlacement, bool) {
+		sends.Add(1)
+		return shardPlacement{}, true
+	})
+	if !errors.Is(err, failed) || sends.Load() != 1 {
+		t.Errorf("pipeline failure gave %v after %d sends", err, sends.Load())
+	}
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Shards travel as a run of chunk frames followed by a trailer carrying
+// the SHA-256 of the whole shard. Each chunk frame has its own checksum;
+// the receiver asks again for chunks whose frames failed it, then checks
+// the assembled shard against the trailer.
+//
+// Fetch: FetchShard → ShardBegin [size(8)], chunks, ShardEnd, then any
+// number of ChunkRequest → chunks until the fetcher closes the stream.
+// Store: StoreShard [fileHash][shardIndex(4)][size(8)] → ShardReady, or a
+// StoreShardAck rejecting it; then chunks, ShardEnd, any ChunkRequest →
+// chunks rounds, and a final StoreShardAck.
+const (
+	// shardChunkSize is the data carried by each chunk frame
+	shardChunkSize = 256 * 1024
+
+	// maxChunkRetries is how many rounds of re-requests a transfer allows
+	maxChunkRetries = 3
+)
+
+// shardChunkCount returns the number of chunks a shard of size bytes needs
+func shardChunkCount(size uint64) uint32 {
+	return uint32((size + shardChunkSize - 1) / shardChunkSize)
+}
+
+// sendShardChunk sends chunk i of data: [chunkIndex(4)][bytes]
+func sendShardChunk(rs *rpcStream, data []byte, i uint32) error {
+	start := uint64(i) * shardChunkSize
+	end := min(start+shardChunkSize, uint64(len(data)))
+	payload := binary.BigEndian.AppendUint32(make([]byte, 0, 4+end-start), i)
+	return rs.send(rpcMsgShardChunk, append(payload, data[start:end]...))
+}
+
+// sendShardChunks sends the given chunks of data, or all of them if
+// indexes is nil, and the trailer after a full run
+func sendShardChunks(rs *rpcStream, data []byte, indexes []uint32) error {
+	if indexes == nil {
+		for i := uint32(0); i < shardChunkCount(uint64(len(data))); i++ {
+			if err := sendShardChunk(rs, data, i); err != nil {
+				return err
+			}
+		}
+		digest := sha256.Sum256(data)
+		return rs.send(rpcMsgShardEnd, digest[:])
+	}
+	for _, i := range indexes {
+		if i >= shardChunkCount(uint64(len(data))) {
+			return fmt.Errorf("%w: chunk %d requested of %d", errRPCBadFrame, i, shardChunkCount(uint64(len(data))))
+		}
+		if err := sendShardChunk(rs, data, i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// serveChunkRequests answers ChunkRequest frames until the peer closes the
+// stream or sends a frame of type done, which is returned
+func serveChunkRequests(rs *rpcStream, data []byte, done rpcMsgType) (rpcFrame, error) {
+	for {
+		frame, err := rs.recv()
+		if err != nil {
+			return frame, err
+		}
+		if frame.Type != rpcMsgChunkRequest {
+			if frame.Type == done {
+				return frame, nil
+			}
+			if frame.Type == rpcMsgError {
+				return frame, fmt.Errorf("peer error: %s", frame.Payload)
+			}
+			return frame, fmt.Errorf("%w: expected a chunk request, got type %d", errRPCBadFrame, frame.Type)
+		}
+		if len(frame.Payload)%4 != 0 {
+			return frame, fmt.Errorf("%w: bad chunk request", errRPCBadFrame)
+		}
+		indexes := make([]uint32, 0, len(frame.Payload)/4)
+		for b := frame.Payload; len(b) > 0; b = b[4:] {
+			indexes = append(indexes, binary.BigEndian.Uint32(b))
+		}
+		if err := sendShardChunks(rs, data, indexes); err != nil {
+			return frame, err
+		}
+	}
+}
+
+// shardReceiver assembles a shard from chunk frames
+type shardReceiver struct {
+	data []byte
+	have []bool
+}
+
+func newShardReceiver(size uint64) *shardReceiver {
+	return &shardReceiver{data: make([]byte, size), have: make([]bool, shardChunkCount(size))}
+}
+
+// missing returns the chunks not yet received
+func (r *shardReceiver) missing() []uint32 {
+	var indexes []uint32
+	for i, ok := range r.have {
+		if !ok {
+			indexes = append(indexes, uint32(i))
+		}
+	}
+	return indexes
+}
+
+// receive reads one chunk frame per expected index. Frames that fail their
+// checksum leave their chunk missing; any other error ends the transfer.
+func (r *shardReceiver) receive(rs *rpcStream, expected []uint32) error {
+	for range expected {
+		frame, err := rs.recv()
+		if errors.Is(err, errRPCChecksum) && frame.Type == rpcMsgShardChunk {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if frame.Type == rpcMsgError {
+			return fmt.Errorf("peer error: %s", frame.Payload)
+		}
+		if frame.Type != rpcMsgShardChunk || len(frame.Payload) < 4 {
+			return fmt.Errorf("%w: expected a shard chunk, got type %d", errRPCBadFrame, frame.Type)
+		}
+		i := binary.BigEndian.Uint32(frame.Payload)
+		start := uint64(i) * shardChunkSize
+		if int(i) >= len(r.have) || uint64(len(frame.Payload)-4) != min(shardChunkSize, uint64(len(r.data))-start) {
+			return fmt.Errorf("%w: chunk %d does not fit the shard", errRPCBadFrame, i)
+		}
+		copy(r.data[start:], frame.Payload[4:])
+		r.have[i] = true
+	}
+	return nil
+}
+
+// receiveAll reads a full run of chunks and the trailer, re-requesting
+// chunks that failed their checksum, and verifies the assembled shard
+func (r *shardReceiver) receiveAll(rs *rpcStream) ([]byte, error) {
+	if err := r.receive(rs, r.missing()); err != nil {
+		return nil, err
+	}
+	trailer, err := rs.expect(rpcMsgShardEnd)
+	if err != nil {
+		return nil, err
+	}
+	if len(trailer) != sha256.Size {
+		return nil, fmt.Errorf("%w: bad shard trailer", errRPCBadFrame)
+	}
+
+	for round := 0; round < maxChunkRetries && len(r.missing()) > 0; round++ {
+		missing := r.missing()
+		req := make([]byte, 0, 4*len(missing))
+		for _, i := range missing {
+			req = binary.BigEndian.AppendUint32(req, i)
+		}
+		if err := rs.send(rpcMsgChunkRequest, req); err != nil {
+			return nil, err
+		}
+		if err := r.receive(rs, missing); err != nil {
+			return nil, err
+		}
+	}
+	if missing := r.missing(); len(missing) > 0 {
+		return nil, fmt.Errorf("%d chunks still corrupt after %d retries", len(missing), maxChunkRetries)
+	}
+
+	if digest := sha256.Sum256(r.data); string(digest[:]) != string(trailer) {
+		return r.data, errShardHashMismatch
+	}
+	return r.data, nil
+}
+
+// errShardHashMismatch is returned when an assembled shard does not match
+// its trailer
+var errShardHashMismatch = errors.New("shard does not match its SHA-256")
+
+// fetchShardStream requests a shard over rs and returns it once verified.
+// grant is the access grant to present for a restricted file, if any.
+func fetchShardStream(rs *rpcStream, fileHash string, shardIndex uint32, grant []byte) ([]byte, error) {
+	req := appendRPCString(nil, fileHash)
+	req = append(binary.BigEndian.AppendUint32(req, shardIndex), grant...)
+	begin, err := rs.call(rpcMsgFetchShard, req, rpcMsgShardBegin)
+	if err != nil {
+		return nil, err
+	}
+	if len(begin) != 8 {
+		return nil, fmt.Errorf("%w: bad shard header", errRPCBadFrame)
+	}
+	size := binary.BigEndian.Uint64(begin)
+	if size > maxShardSize {
+		return nil, fmt.Errorf("%w: %d byte shard exceeds %d", errRPCBadFrame, size, maxShardSize)
+	}
+	return newShardReceiver(size).receiveAll(rs)
+}
+
+// serveShardStream sends a shard for a fetch request and answers chunk
+// re-requests until the fetcher is done
+func serveShardStream(rs *rpcStream, data []byte) error {
+	if err := rs.send(rpcMsgShardBegin, binary.BigEndian.AppendUint64(nil, uint64(len(data)))); err != nil {
+		return err
+	}
+	if err := sendShardChunks(rs, data, nil); err != nil {
+		return err
+	}
+	if _, err := serveChunkRequests(rs, data, 0); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// pushShardStream offers a shard for storage over rs, sends it once the
+// peer is ready and returns the peer's StoreShardAck. A shard sent with
+// its file's ownership claim is restricted: the peer serves it to the
+// claim's owner and its grantees only.
+func pushShardStream(rs *rpcStream, fileHash string, shardIndex uint32, data []byte, claim []byte) ([]byte, error) {
+	req := appendRPCString(nil, fileHash)
+	req = binary.BigEndian.AppendUint32(req, shardIndex)
+	req = binary.BigEndian.AppendUint64(req, uint64(len(data)))
+	if claim != nil {
+		req = append(req, shardFlagRestricted)
+		req = append(req, claim...)
+	}
+	if err := rs.send(rpcMsgStoreShard, req); err != nil {
+		return nil, err
+	}
+	reply, err := rs.recv()
+	if err != nil {
+		return nil, err
+	}
+	switch reply.Type {
+	case rpcMsgStoreShardAck:
+		return reply.Payload, nil // Refused up front
+	case rpcMsgShardReady:
+	case rpcMsgError:
+		return nil, fmt.Errorf("peer error: %s", reply.Payload)
+	default:
+		return nil, fmt.Errorf("%w: expected shard ready, got type %d", errRPCBadFrame, reply.Type)
+	}
+
+	if err := sendShardChunks(rs, data, nil); err != nil {
+		return nil, err
+	}
+	ack, err := serveChunkRequests(rs, data, rpcMsgStoreShardAck)
+	if err != nil {
+		return nil, err
+	}
+	return ack.Payload, nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/network"
+)
+
+// pipeStream is one end of an in-memory stream. When corrupt is set it
+// flips a payload byte in that many ShardChunk frames it writes.
+type pipeStream struct {
+	network.Stream
+	c net.Conn
+
+	mu      sync.Mutex
+	corrupt int
+}
+
+func (s *pipeStream) Read(b []byte) (int, error) { return s.c.Read(b) }
+func (s *pipeStream) Close() error               { return s.c.Close() }
+
+func (s *pipeStream) Write(b []byte) (int, error) {
+	s.mu.Lock()
+	if s.corrupt > 0 && len(b) > rpcHeaderSize+4 && rpcMsgType(b[3]) == rpcMsgShardChunk {
+		s.corrupt--
+		b = bytes.Clone(b)
+		b[len(b)-1] ^= 0xff
+	}
+	s.mu.Unlock()
+	return s.c.Write(b)
+}
+
+func newRPCPipe() (*rpcStream, *rpcStream, *pipeStream) {
+	a, b := net.Pipe()
+	local, remote := &pipeStream{c: a}, &pipeStream{c: b}
+	return &rpcStream{Stream: local, version: rpcMaxVersion}, &rpcStream{Stream: remote, version: rpcMaxVersion}, remote
+}
+
+func randomShard(t *testing.T, size int) []byte {
+	data := make([]byte, size)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestShardStreamFetchRetriesCorruptChunks(t *testing.T) {
+	data := randomShard(t, 3*shardChunkSize+100)
+	client, server, serverPipe := newRPCPipe()
+	serverPipe.corrupt = 2
+
+	done := make(chan error, 1)
+	go func() {
+		frame, err := server.recv()
+		if err != nil || frame.Type != rpcMsgFetchShard {
+			done <- errors.New("expected a fetch request")
+			return
+		}
+		done <- serveShardStream(server, data)
+	}()
+
+	got, err := fetchShardStream(client, "file", 0, nil)
+	if err != nil {
+		t.Fatalf("fetch failed: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatal("fetched shard differs from the original")
+	}
+	client.Close()
+	if err := <-done; err != nil {
+		t.Fatalf("serve failed: %v", err)
+	}
+}
+
+func TestShardStreamStoreRetriesCorruptChunks(t *testing.T) {
+	data := randomShard(t, 2*shardChunkSize)
+	client, server, _ := newRPCPipe()
+	client.Stream.(*pipeStream).corrupt = 1
+
+	received := make(chan []byte, 1)
+	go func() {
+		defer close(received)
+		frame, err := server.recv()
+		if err != nil || frame.Type != rpcMsgStoreShard {
+			return
+		}
+		req := &rpcPayload{b: frame.Payload}
+		req.string()
+		req.uint32()
+		size := req.uint64()
+		if server.send(rpcMsgShardReady, nil) != nil {
+			return
+		}
+		shard, err := newShardReceiver(size).receiveAll(server)
+		if err != nil {
+			return
+		}
+		server.send(rpcMsgStoreShardAck, []byte{shardAckStored})
+		received <- shard
+	}()
+
+	ack, err := pushShardStream(client, "file", 0, data, nil)
+	if err != nil {
+		t.Fatalf("push failed: %v", err)
+	}
+	if len(ack) == 0 || ack[0] != shardAckStored {
+		t.Fatalf("unexpected ack %v", ack)
+	}
+	if got := <-received; !bytes.Equal(got, data) {
+		t.Fatal("stored shard differs from the original")
+	}
+}
+
+func TestShardStreamGivesUpAfterRetries(t *testing.T) {
+	data := randomShard(t, shardChunkSize/2)
+	client, server, serverPipe := newRPCPipe()
+	serverPipe.corrupt = 1 + maxChunkRetries
+
+	go func() {
+		server.recv()
+		serveShardStream(server, data)
+	}()
+
+	if _, err := fetchShardStream(client, "file", 0, nil); err == nil {
+		t.Fatal("expected a chunk corrupt on every retry to fail the fetch")
+	}
+	client.Close()
+}
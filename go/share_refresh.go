@@ -0,0 +1,411 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/pangea-net/go-node/pkg/crypto/dkg"
+)
+
+// Proactive refresh of the file key shares in dkgShares. A coordinator,
+// normally the node that dealt the key, runs rounds over
+// PangeaRPCProtocol:
+//
+//  1. prepare: every holder reports its share's epoch, x coordinate and
+//     length, which must agree.
+//  2. deal: every holder deals a sharing of zero to the others.
+//  3. commit: every holder adds the deltas it received to its share and
+//     moves to the next epoch.
+//
+// Every holder must take part, since a holder left behind keeps a share
+// that no longer combines with the others. If any step fails the round is
+// aborted and holders that already committed roll back.
+
+// Share refresh steps carried in rpcMsgShareRefresh frames
+const (
+	refreshStepPrepare byte = 1 // empty -> [epoch(8)][x(1)][length(4)]
+	refreshStepDeal    byte = 2 // [epoch(8)][threshold(4)][count(4)][peerID][x(1)]... -> empty, once dealt
+	refreshStepDelta   byte = 3 // [epoch(8)][delta] -> empty
+	refreshStepCommit  byte = 4 // [epoch(8)] -> empty
+	refreshStepAbort   byte = 5 // [epoch(8)] -> empty
+)
+
+const (
+	// refreshStepTimeout bounds one step with one holder
+	refreshStepTimeout = 15 * time.Second
+
+	// DefaultShareRefreshInterval is how often tracked files are refreshed
+	DefaultShareRefreshInterval = 24 * time.Hour
+
+	// maxRefreshHolders matches the shamir limit on shares per secret
+	maxRefreshHolders = 255
+)
+
+// refreshTarget is a file whose shares this node keeps refreshed
+type refreshTarget struct {
+	holders   []peer.ID
+	threshold int
+}
+
+// refreshSession is a holder's state during one refresh round
+type refreshSession struct {
+	id          string
+	coordinator peer.ID
+	holders     map[peer.ID]uint8 // x coordinate by holder, set by deal
+	deltas      map[peer.ID][]byte
+}
+
+// ShareRefresher re-randomizes the DKG shares of files without changing
+// their keys, so shares an attacker collects over time stop combining
+// once the holders have refreshed. Shares are numbered by epoch, starting
+// at 0 when dealt.
+type ShareRefresher struct {
+	node     *LibP2PPangeaNode
+	epochs   map[string]uint64
+	previous map[string][]byte // Share before the last commit, for rollback
+	sessions map[string]*refreshSession
+	tracked  map[string]refreshTarget
+	mu       sync.Mutex
+}
+
+// NewShareRefresher creates the refresher for a node's shares
+func NewShareRefresher(n *LibP2PPangeaNode) *ShareRefresher {
+	return &ShareRefresher{
+		node:     n,
+		epochs:   make(map[string]uint64),
+		previous: make(map[string][]byte),
+		sessions: make(map[string]*refreshSession),
+		tracked:  make(map[string]refreshTarget),
+	}
+}
+
+// Epoch returns the epoch of this node's share of fileID
+func (r *ShareRefresher) Epoch(fileID string) uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.epochs[fileID]
+}
+
+// Track adds a file to the periodic refresh
+func (r *ShareRefresher) Track(fileID string, holders []peer.ID, threshold int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tracked[fileID] = refreshTarget{holders: holders, threshold: threshold}
+}
+
+// Untrack drops a file from the periodic refresh and forgets its epoch,
+// once this node no longer holds a share of it
+func (r *ShareRefresher) Untrack(fileID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.tracked, fileID)
+	delete(r.epochs, fileID)
+	delete(r.previous, fileID)
+}
+
+// Run refreshes every tracked file each interval until ctx is cancelled
+func (r *ShareRefresher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.mu.Lock()
+			tracked := make(map[string]refreshTarget, len(r.tracked))
+			for fileID, target := range r.tracked {
+				tracked[fileID] = target
+			}
+			r.mu.Unlock()
+			for fileID, target := range tracked {
+				if _, err := r.Refresh(ctx, fileID, target.holders, target.threshold); err != nil {
+					log.Printf("⚠️  [REFRESH] Shares of %s not refreshed: %v", fileID, err)
+				}
+			}
+		}
+	}
+}
+
+// Refresh moves every holder of fileID's shares to the next epoch and
+// returns it. The file key is unchanged.
+func (r *ShareRefresher) Refresh(ctx context.Context, fileID string, holders []peer.ID, threshold int) (uint64, error) {
+	var unique []peer.ID
+	for _, p := range holders {
+		if !slices.Contains(unique, p) {
+			unique = append(unique, p)
+		}
+	}
+	holders = unique
+	if len(holders) > maxRefreshHolders {
+		return 0, fmt.Errorf("at most %d holders", maxRefreshHolders)
+	}
+	if threshold < 2 || threshold > len(holders) {
+		return 0, fmt.Errorf("invalid threshold %d for %d holders", threshold, len(holders))
+	}
+	sessionID := generateSessionID()
+
+	// Prepare: holders must agree on the epoch and share length
+	replies, errs := r.callAll(ctx, holders, fileID, sessionID, refreshStepPrepare, func(int) []byte { return nil })
+	if err := errors.Join(errs...); err != nil {
+		return 0, err
+	}
+	var epoch uint64
+	var length uint32
+	xs := make([]uint8, len(holders))
+	for i, reply := range replies {
+		p := &rpcPayload{b: reply}
+		e, x, l := p.uint64(), p.take(1), p.uint32()
+		if p.err != nil {
+			return 0, fmt.Errorf("holder %s: %w", shortPeerID(holders[i]), p.err)
+		}
+		if i == 0 {
+			epoch, length = e, l
+		} else if e != epoch || l != length {
+			return 0, fmt.Errorf("holder %s is at epoch %d with a %d byte share, expected epoch %d and %d bytes",
+				shortPeerID(holders[i]), e, l, epoch, length)
+		}
+		if slices.Contains(xs[:i], x[0]) {
+			return 0, fmt.Errorf("holder %s reuses x coordinate %d", shortPeerID(holders[i]), x[0])
+		}
+		xs[i] = x[0]
+	}
+	next := binary.BigEndian.AppendUint64(nil, epoch+1)
+
+	// Deal, then commit; any failure rolls everyone back
+	deal := binary.BigEndian.AppendUint64(nil, epoch+1)
+	deal = binary.BigEndian.AppendUint32(deal, uint32(threshold))
+	deal = binary.BigEndian.AppendUint32(deal, uint32(len(holders)))
+	for i, p := range holders {
+		deal = appendRPCString(deal, p.String())
+		deal = append(deal, xs[i])
+	}
+	_, errs = r.callAll(ctx, holders, fileID, sessionID, refreshStepDeal, func(int) []byte { return deal })
+	err := errors.Join(errs...)
+	if err == nil {
+		_, errs = r.callAll(ctx, holders, fileID, sessionID, refreshStepCommit, func(int) []byte { return next })
+		err = errors.Join(errs...)
+	}
+	if err != nil {
+		r.callAll(context.Background(), holders, fileID, sessionID, refreshStepAbort, func(int) []byte { return next })
+		return 0, fmt.Errorf("refresh of %s aborted: %w", fileID, err)
+	}
+
+	// Copies of the holders' old shares, kept by the dealer, are now stale
+	r.node.dkgMu.Lock()
+	for id := range r.node.dkgShares[fileID] {
+		if id != r.node.nodeID {
+			delete(r.node.dkgShares[fileID], id)
+		}
+	}
+	r.node.dkgMu.Unlock()
+	log.Printf("🔄 [REFRESH] Shares of %s moved to epoch %d across %d holders", fileID, epoch+1, len(holders))
+	return epoch + 1, nil
+}
+
+// callAll runs a step with every holder concurrently
+func (r *ShareRefresher) callAll(ctx context.Context, holders []peer.ID, fileID, sessionID string, step byte, body func(i int) []byte) ([][]byte, []error) {
+	replies := make([][]byte, len(holders))
+	errs := make([]error, len(holders))
+	var wg sync.WaitGroup
+	for i, p := range holders {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			replies[i], errs[i] = r.call(ctx, p, fileID, sessionID, step, body(i))
+			if errs[i] != nil {
+				errs[i] = fmt.Errorf("holder %s: %w", shortPeerID(p), errs[i])
+			}
+		}()
+	}
+	wg.Wait()
+	return replies, errs
+}
+
+// call runs a step with one holder. Steps for this node are handled in
+// place.
+func (r *ShareRefresher) call(ctx context.Context, p peer.ID, fileID, sessionID string, step byte, body []byte) ([]byte, error) {
+	h := r.node.host
+	if p == h.ID() {
+		return r.serve(ctx, p, fileID, sessionID, step, body)
+	}
+	ctx, cancel := context.WithTimeout(ctx, refreshStepTimeout)
+	defer cancel()
+	rs, err := openRPCStream(ctx, h, p)
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Close()
+	rs.SetDeadline(time.Now().Add(refreshStepTimeout))
+
+	req := appendRPCString(nil, fileID)
+	req = appendRPCString(req, sessionID)
+	req = append(req, step)
+	return rs.call(rpcMsgShareRefresh, append(req, body...), rpcMsgShareRefreshReply)
+}
+
+// serve handles a step from remote, which may be this node
+func (r *ShareRefresher) serve(ctx context.Context, remote peer.ID, fileID, sessionID string, step byte, body []byte) ([]byte, error) {
+	req := &rpcPayload{b: body}
+	share, ok := r.node.GetLocalShare(fileID)
+	if !ok {
+		return nil, fmt.Errorf("no share of %s", fileID)
+	}
+	if step == refreshStepPrepare {
+		x, err := dkg.ShareX(share)
+		if err != nil {
+			return nil, err
+		}
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.sessions[fileID] = &refreshSession{id: sessionID, coordinator: remote, deltas: make(map[peer.ID][]byte)}
+		reply := binary.BigEndian.AppendUint64(nil, r.epochs[fileID])
+		reply = append(reply, x)
+		return binary.BigEndian.AppendUint32(reply, uint32(len(share)-1)), nil
+	}
+
+	epoch := req.uint64()
+	if req.err != nil {
+		return nil, req.err
+	}
+	r.mu.Lock()
+	s, ok := r.sessions[fileID]
+	current := r.epochs[fileID]
+	r.mu.Unlock()
+	if !ok || s.id != sessionID {
+		return nil, fmt.Errorf("no refresh session %s for %s", sessionID, fileID)
+	}
+	if step == refreshStepDelta {
+		return nil, r.receiveDelta(remote, s, epoch, current, req.rest())
+	}
+	if remote != s.coordinator {
+		return nil, errors.New("only the coordinator drives a refresh")
+	}
+	switch step {
+	case refreshStepDeal:
+		if epoch != current+1 {
+			return nil, fmt.Errorf("refresh to epoch %d, share is at epoch %d", epoch, current)
+		}
+		return nil, r.deal(ctx, fileID, s, epoch, share, req)
+	case refreshStepCommit:
+		return nil, r.commit(fileID, s, epoch, current, share)
+	case refreshStepAbort:
+		r.abort(fileID, s, epoch)
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown refresh step %d", step)
+	}
+}
+
+// deal sends every holder its delta of a fresh sharing of zero
+func (r *ShareRefresher) deal(ctx context.Context, fileID string, s *refreshSession, epoch uint64, share []byte, req *rpcPayload) error {
+	threshold, count := int(req.uint32()), int(req.uint32())
+	if req.err != nil || count > maxRefreshHolders {
+		return errors.New("invalid refresh parameters")
+	}
+	holders := make(map[peer.ID]uint8, count)
+	var order []peer.ID
+	var xs []uint8
+	for i := 0; i < count; i++ {
+		p, err := peer.Decode(req.string())
+		x := req.take(1)
+		if err != nil || req.err != nil {
+			return errors.New("invalid holder list")
+		}
+		holders[p] = x[0]
+		order = append(order, p)
+		xs = append(xs, x[0])
+	}
+	self, _ := dkg.ShareX(share)
+	if x, ok := holders[r.node.host.ID()]; !ok || x != self {
+		return errors.New("this node is not among the holders")
+	}
+	deltas, err := dkg.RefreshDeltas(threshold, xs, len(share)-1)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	s.holders = holders
+	r.mu.Unlock()
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(order))
+	for i, p := range order {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			body := binary.BigEndian.AppendUint64(nil, epoch)
+			_, errs[i] = r.call(ctx, p, fileID, s.id, refreshStepDelta, append(body, deltas[xs[i]]...))
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// receiveDelta stores a holder's delta for this node's share
+func (r *ShareRefresher) receiveDelta(remote peer.ID, s *refreshSession, epoch, current uint64, delta []byte) error {
+	if epoch != current+1 {
+		return fmt.Errorf("delta for epoch %d, share is at epoch %d", epoch, current)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(s.deltas) >= maxRefreshHolders {
+		return errors.New("too many deltas")
+	}
+	s.deltas[remote] = delta
+	return nil
+}
+
+// commit applies the deltas from every holder and moves to epoch
+func (r *ShareRefresher) commit(fileID string, s *refreshSession, epoch, current uint64, share []byte) error {
+	if epoch != current+1 {
+		return fmt.Errorf("commit of epoch %d, share is at epoch %d", epoch, current)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s.holders == nil {
+		return errors.New("nothing dealt")
+	}
+	deltas := make([][]byte, 0, len(s.holders))
+	for p := range s.holders {
+		d, ok := s.deltas[p]
+		if !ok {
+			return fmt.Errorf("no delta from holder %s", shortPeerID(p))
+		}
+		deltas = append(deltas, d)
+	}
+	refreshed, err := dkg.ApplyRefresh(share, deltas)
+	if err != nil {
+		return err
+	}
+
+	r.node.dkgMu.Lock()
+	r.node.dkgShares[fileID] = map[uint32][]byte{r.node.nodeID: refreshed}
+	r.node.dkgMu.Unlock()
+	r.previous[fileID] = share
+	r.epochs[fileID] = epoch
+	s.holders, s.deltas = nil, nil
+	log.Printf("🔄 [REFRESH] Share of %s at epoch %d", fileID, epoch)
+	return nil
+}
+
+// abort ends a round; a holder that already committed epoch goes back to
+// its previous share
+func (r *ShareRefresher) abort(fileID string, s *refreshSession, epoch uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.epochs[fileID] == epoch && s.deltas == nil {
+		r.node.StoreDKGShare(fileID, r.node.nodeID, r.previous[fileID])
+		r.epochs[fileID] = epoch - 1
+		log.Printf("↩️  [REFRESH] Share of %s rolled back to epoch %d", fileID, epoch-1)
+	}
+	delete(r.sessions, fileID)
+}
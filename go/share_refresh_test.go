@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	vaultshamir "github.com/hashicorp/vault/shamir"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestShareRefresh(t *testing.T) {
+	nodes := newDKGTestNodes(t, 12590, 4)
+	dealer, holders := nodes[0], nodes[1:]
+
+	// The dealer keeps copies of every share, as DistributeFileKey does
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	shares, err := vaultshamir.Split(secret, len(holders), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pids []peer.ID
+	for i, h := range holders {
+		h.StoreDKGShare("file", h.nodeID, shares[i])
+		dealer.StoreDKGShare("file", h.nodeID, shares[i])
+		pids = append(pids, h.host.ID())
+	}
+	combine := func(i, j int) []byte {
+		a, _ := holders[i].GetLocalShare("file")
+		b, _ := holders[j].GetLocalShare("file")
+		key, err := vaultshamir.Combine([][]byte{a, b})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	for want := uint64(1); want <= 2; want++ {
+		epoch, err := dealer.GetShareRefresher().Refresh(ctx, "file", pids, 2)
+		if err != nil {
+			t.Fatalf("Refresh failed: %v", err)
+		}
+		if epoch != want {
+			t.Fatalf("expected epoch %d, got %d", want, epoch)
+		}
+	}
+	for i, h := range holders {
+		if share, _ := h.GetLocalShare("file"); bytes.Equal(share, shares[i]) {
+			t.Fatalf("share of holder %d did not change", i)
+		}
+		if h.GetShareRefresher().Epoch("file") != 2 {
+			t.Fatalf("holder %d is not at epoch 2", i)
+		}
+	}
+	if !bytes.Equal(combine(0, 2), secret) || !bytes.Equal(combine(1, 2), secret) {
+		t.Fatal("refreshed shares combine to a different key")
+	}
+	dealer.dkgMu.RLock()
+	stale := len(dealer.dkgShares["file"])
+	dealer.dkgMu.RUnlock()
+	if stale != 0 {
+		t.Fatalf("dealer still holds %d stale share copies", stale)
+	}
+
+	// A holder that committed rolls back when the round is aborted
+	before, _ := holders[0].GetLocalShare("file")
+	r := dealer.GetShareRefresher()
+	if _, errs := r.callAll(ctx, pids, "file", "s1", refreshStepPrepare, func(int) []byte { return nil }); errs[0] != nil {
+		t.Fatal(errs[0])
+	}
+	deal := binary.BigEndian.AppendUint64(nil, 3)
+	deal = binary.BigEndian.AppendUint32(deal, 2)
+	deal = binary.BigEndian.AppendUint32(deal, uint32(len(pids)))
+	for _, h := range holders {
+		share, _ := h.GetLocalShare("file")
+		deal = appendRPCString(deal, h.host.ID().String())
+		deal = append(deal, share[len(share)-1])
+	}
+	if _, errs := r.callAll(ctx, pids, "file", "s1", refreshStepDeal, func(int) []byte { return deal }); errs[0] != nil {
+		t.Fatal(errs[0])
+	}
+	next := binary.BigEndian.AppendUint64(nil, 3)
+	if _, err := r.call(ctx, pids[0], "file", "s1", refreshStepCommit, next); err != nil {
+		t.Fatal(err)
+	}
+	if holders[0].GetShareRefresher().Epoch("file") != 3 {
+		t.Fatal("holder did not commit")
+	}
+	r.callAll(ctx, pids, "file", "s1", refreshStepAbort, func(int) []byte { return next })
+	if share, _ := holders[0].GetLocalShare("file"); !bytes.Equal(share, before) || holders[0].GetShareRefresher().Epoch("file") != 2 {
+		t.Fatal("holder did not roll back")
+	}
+	if !bytes.Equal(combine(0, 1), secret) {
+		t.Fatal("shares no longer combine after the rollback")
+	}
+
+	// Only the coordinator drives a round
+	if _, err := holders[2].GetShareRefresher().call(ctx, pids[1], "file", "s1", refreshStepCommit, next); err == nil {
+		t.Fatal("expected a commit from another holder to be refused")
+	}
+
+	// A refresh needs every holder
+	holders[2].cancel()
+	holders[2].host.Close()
+	if _, err := dealer.GetShareRefresher().Refresh(ctx, "file", pids, 2); err == nil {
+		t.Fatal("expected a refresh with a holder down to fail")
+	}
+	if holders[0].GetShareRefresher().Epoch("file") != 2 || !bytes.Equal(combine(0, 1), secret) {
+		t.Fatal("failed refresh changed the shares")
+	}
+}
@@ -1,269 +1,485 @@
 package main
 
 import (
+	"context"
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"io/fs"
+	"math"
 	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
 	"sync"
-	"syscall"
+	"sync/atomic"
+	"time"
 	"unsafe"
 )
 
-// SharedMemoryRing implements a lock-free ring buffer in shared memory
-// for high-performance data streaming between Go and Python
+// SharedMemoryRing is a ring buffer in a memory-mapped file, for streaming
+// data between the node and local clients such as the Python CLI. Any
+// process that maps the file can read and write it; the layout is
+//
+//	[0-3]     magic "PGRB"
+//	[4-7]     layout version
+//	[8-15]    capacity of the data area in bytes
+//	[16-23]   write cursor: bytes published to readers
+//	[24-31]   read cursor: bytes readers have freed
+//	[32-39]   messages written, the ring's sequence number
+//	[40-47]   messages read
+//	[48-55]   reserve cursor: bytes claimed by writers
+//	[56-63]   claim cursor: bytes claimed by readers
+//	[64-67]   waiters sleeping on the sequence number
+//	[68-127]  reserved
+//	[128...]  data area
+//
+// with every field little-endian. Cursors only grow; a cursor's position
+// in the data area is its value modulo the capacity, so messages wrap
+// around the end of the area. Each message is a 4-byte length and its
+// data.
+//
+// Any number of writers and readers, in any process, may share a ring. A
+// writer claims space by compare-and-swap on the reserve cursor, copies
+// its message in, and publishes it by advancing the write cursor once the
+// writers that claimed space before it have published theirs. Readers
+// claim messages the same way on the claim cursor and free their space by
+// advancing the read cursor in claim order. Each message is taken by
+// exactly one reader. A process that dies between claiming and publishing
+// or freeing stalls the ring until it is destroyed.
+//
+// Writers bump the sequence number after publishing and wake the readers
+// sleeping on it (a futex on Linux; other platforms poll it), so readers
+// wait for data rather than spin.
 type SharedMemoryRing struct {
 	name     string
-	size     int
-	fd       int
+	path     string
+	capacity uint64
 	data     []byte
-	mu       sync.RWMutex
-	writePos uint64
-	readPos  uint64
+	unmap    func() error
+	mu       sync.RWMutex // Held for writing only to unmap
 }
 
 const (
-	// Ring buffer layout in shared memory:
-	// [0-7]: write position (8 bytes)
-	// [8-15]: read position (8 bytes)
-	// [16-23]: total messages written (8 bytes)
-	// [24-31]: total messages read (8 bytes)
-	// [32...]: ring buffer data
-	HeaderSize     = 32
-	MaxMessageSize = 64 * 1024 * 1024 // 64MB max message size
+	SharedMemoryHeaderSize = 128
+	MaxMessageSize         = 64 * 1024 * 1024 // 64MB max message size
+
+	// Rings are between 4 KiB and 4 GiB. The maximum does not fit an int
+	// where int is 32 bits, so it is compared as an int64.
+	MinSharedMemoryRingSize       = 4 << 10
+	MaxSharedMemoryRingSize int64 = 4 << 30
+
+	// DefaultSharedMemoryRingSize is the size of rings created on first
+	// use rather than with CreateRing
+	DefaultSharedMemoryRingSize = 16 << 20
+
+	shmMagic          = "PGRB"
+	shmLayoutVersion  = 2
+	shmCapacityOffset = 8
+	shmWriteOffset    = 16
+	shmReadOffset     = 24
+	shmWrittenOffset  = 32
+	shmReadCountOff   = 40
+	shmReserveOffset  = 48
+	shmClaimOffset    = 56
+	shmWaitersOffset  = 64
+
+	// shmWaitSlice bounds each sleep of a waiting reader, so it notices a
+	// cancelled context or a detached ring
+	shmWaitSlice = 50 * time.Millisecond
 )
 
-// NewSharedMemoryRing creates or opens a shared memory ring buffer
-func NewSharedMemoryRing(name string, size int) (*SharedMemoryRing, error) {
-	shmPath := fmt.Sprintf("/dev/shm/pangea_%s", name)
+var (
+	// errRingEmpty is returned by Read when no message is waiting
+	errRingEmpty = errors.New("ring buffer empty")
 
-	// Create or open shared memory file
-	fd, err := syscall.Open(shmPath, syscall.O_RDWR|syscall.O_CREAT, 0666)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open shared memory: %w", err)
-	}
+	// errRingDetached is returned for a ring used after it was unmapped
+	errRingDetached = errors.New("ring is detached")
+
+	// shmNamePattern keeps ring names usable as file names on every
+	// platform
+	shmNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+)
 
-	totalSize := HeaderSize + size
+// SharedMemoryRingStats describes a ring and its cursors
+type SharedMemoryRingStats struct {
+	Name            string
+	Path            string
+	Capacity        uint64
+	WriteCursor     uint64
+	ReadCursor      uint64
+	Used            uint64 // Bytes written and not yet read
+	MessagesWritten uint64 // Also the ring's sequence number
+	MessagesRead    uint64
+}
 
-	// Set file size
-	if err := syscall.Ftruncate(fd, int64(totalSize)); err != nil {
-		syscall.Close(fd)
-		return nil, fmt.Errorf("failed to set size: %w", err)
+// sharedMemoryPath is the file backing a ring
+func sharedMemoryPath(dir, name string) (string, error) {
+	if !shmNamePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid ring name %q: use 1-64 letters, digits, - or _", name)
 	}
+	return filepath.Join(dir, "pangea_"+name+".ring"), nil
+}
 
-	// Memory map the file
-	data, err := syscall.Mmap(fd, 0, totalSize, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+// createSharedMemoryRing creates a ring file with a data area of size
+// bytes; it fails if the ring already exists
+func createSharedMemoryRing(dir, name string, size int) (*SharedMemoryRing, error) {
+	if size < MinSharedMemoryRingSize || int64(size) > MaxSharedMemoryRingSize {
+		return nil, fmt.Errorf("ring size %d outside %d-%d bytes", size, MinSharedMemoryRingSize, MaxSharedMemoryRingSize)
+	}
+	path, err := sharedMemoryPath(dir, name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			return nil, fmt.Errorf("ring %s already exists; attach or destroy it", name)
+		}
+		return nil, fmt.Errorf("failed to create ring file: %w", err)
+	}
+	defer f.Close()
+	total := SharedMemoryHeaderSize + size
+	if err := f.Truncate(int64(total)); err != nil {
+		os.Remove(path)
+		return nil, fmt.Errorf("failed to size ring file: %w", err)
+	}
+	data, unmap, err := mapSharedFile(f, total)
 	if err != nil {
-		syscall.Close(fd)
-		return nil, fmt.Errorf("failed to mmap: %w", err)
+		os.Remove(path)
+		return nil, fmt.Errorf("failed to map ring file: %w", err)
 	}
+	copy(data[0:4], shmMagic)
+	binary.LittleEndian.PutUint32(data[4:8], shmLayoutVersion)
+	binary.LittleEndian.PutUint64(data[shmCapacityOffset:], uint64(size))
+	return &SharedMemoryRing{name: name, path: path, capacity: uint64(size), data: data, unmap: unmap}, nil
+}
 
-	ring := &SharedMemoryRing{
-		name: name,
-		size: size,
-		fd:   fd,
-		data: data,
+// attachSharedMemoryRing maps an existing ring file
+func attachSharedMemoryRing(dir, name string) (*SharedMemoryRing, error) {
+	path, err := sharedMemoryPath(dir, name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("ring %s does not exist", name)
+		}
+		return nil, fmt.Errorf("failed to open ring file: %w", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	total := info.Size()
+	if total < SharedMemoryHeaderSize+MinSharedMemoryRingSize || total > SharedMemoryHeaderSize+MaxSharedMemoryRingSize || total > math.MaxInt {
+		return nil, fmt.Errorf("ring file %s has invalid size %d", path, total)
+	}
+	data, unmap, err := mapSharedFile(f, int(total))
+	if err != nil {
+		return nil, fmt.Errorf("failed to map ring file: %w", err)
+	}
+	capacity := binary.LittleEndian.Uint64(data[shmCapacityOffset:])
+	if string(data[0:4]) != shmMagic || binary.LittleEndian.Uint32(data[4:8]) != shmLayoutVersion || capacity != uint64(total-SharedMemoryHeaderSize) {
+		unmap()
+		return nil, fmt.Errorf("%s is not a version %d ring file", path, shmLayoutVersion)
 	}
+	return &SharedMemoryRing{name: name, path: path, capacity: capacity, data: data, unmap: unmap}, nil
+}
 
-	// Initialize header if this is a new buffer
-	ring.writePos = ring.getWritePos()
-	ring.readPos = ring.getReadPos()
+// cursor returns the header field at off, which the mapping's page
+// alignment keeps 8-byte aligned for atomic access
+func (r *SharedMemoryRing) cursor(off int) *uint64 {
+	return (*uint64)(unsafe.Pointer(&r.data[off]))
+}
 
-	return ring, nil
+// sequenceWord is the low half of the sequence number, the word waiting
+// readers sleep on
+func (r *SharedMemoryRing) sequenceWord() *uint32 {
+	return (*uint32)(unsafe.Pointer(&r.data[shmWrittenOffset]))
 }
 
-// getWritePos reads the write position from shared memory
-func (r *SharedMemoryRing) getWritePos() uint64 {
-	return binary.LittleEndian.Uint64(r.data[0:8])
+// waiters counts the readers sleeping on the sequence number, so writers
+// only make the wake call when someone is waiting
+func (r *SharedMemoryRing) waiters() *uint32 {
+	return (*uint32)(unsafe.Pointer(&r.data[shmWaitersOffset]))
 }
 
-// setWritePos updates the write position in shared memory
-func (r *SharedMemoryRing) setWritePos(pos uint64) {
-	binary.LittleEndian.PutUint64(r.data[0:8], pos)
+// copyIn writes b into the data area at cursor pos, wrapping at its end
+func (r *SharedMemoryRing) copyIn(pos uint64, b []byte) {
+	at := pos % r.capacity
+	n := copy(r.data[SharedMemoryHeaderSize+at:], b)
+	copy(r.data[SharedMemoryHeaderSize:], b[n:])
 }
 
-// getReadPos reads the read position from shared memory
-func (r *SharedMemoryRing) getReadPos() uint64 {
-	return binary.LittleEndian.Uint64(r.data[8:16])
+// copyOut reads len(b) bytes from the data area at cursor pos, wrapping
+// at its end
+func (r *SharedMemoryRing) copyOut(pos uint64, b []byte) {
+	at := pos % r.capacity
+	n := copy(b, r.data[SharedMemoryHeaderSize+at:])
+	copy(b[n:], r.data[SharedMemoryHeaderSize:])
 }
 
-// setReadPos updates the read position in shared memory
-func (r *SharedMemoryRing) setReadPos(pos uint64) {
-	binary.LittleEndian.PutUint64(r.data[8:16], pos)
+// advanceInOrder moves the cursor at off from pos to next once every
+// earlier claim has moved it to pos
+func (r *SharedMemoryRing) advanceInOrder(off int, pos, next uint64) {
+	c := r.cursor(off)
+	for atomic.LoadUint64(c) != pos {
+		runtime.Gosched()
+	}
+	atomic.StoreUint64(c, next)
 }
 
-// Write writes a message to the ring buffer
-// Returns the offset in shared memory where the data was written
+// Write appends a message to the ring and wakes waiting readers. It
+// returns the file offset of the message, for passing to a reader over
+// RPC.
 func (r *SharedMemoryRing) Write(data []byte) (uint64, error) {
-	r.mu.Lock()
-	defer r.mu.Unlock()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 
+	if r.data == nil {
+		return 0, errRingDetached
+	}
 	if len(data) > MaxMessageSize {
 		return 0, fmt.Errorf("message too large: %d bytes (max %d)", len(data), MaxMessageSize)
 	}
-
-	// Message format: [4 bytes length][data]
-	msgSize := 4 + len(data)
-
-	writePos := r.getWritePos()
-	readPos := r.getReadPos()
-
-	// Check if there's enough space
-	available := r.size
-	if writePos >= readPos {
-		available = r.size - int(writePos-readPos)
-	} else {
-		available = int(readPos - writePos)
-	}
-
-	if msgSize > available {
-		return 0, fmt.Errorf("ring buffer full: need %d bytes, have %d", msgSize, available)
+	msgSize := uint64(4 + len(data))
+	reserve := r.cursor(shmReserveOffset)
+	var writePos uint64
+	for {
+		writePos = atomic.LoadUint64(reserve)
+		readPos := atomic.LoadUint64(r.cursor(shmReadOffset))
+		if free := r.capacity - (writePos - readPos); msgSize > free {
+			return 0, fmt.Errorf("ring buffer full: need %d bytes, have %d", msgSize, free)
+		}
+		if atomic.CompareAndSwapUint64(reserve, writePos, writePos+msgSize) {
+			break
+		}
 	}
 
-	// Calculate actual position in ring buffer
-	offset := HeaderSize + (writePos % uint64(r.size))
-
-	// Write length prefix
-	binary.LittleEndian.PutUint32(r.data[offset:offset+4], uint32(len(data)))
+	var length [4]byte
+	binary.LittleEndian.PutUint32(length[:], uint32(len(data)))
+	r.copyIn(writePos, length[:])
+	r.copyIn(writePos+4, data)
 
-	// Write data (may wrap around)
-	dataOffset := offset + 4
-	remaining := uint64(r.size) - (dataOffset - HeaderSize)
-
-	if uint64(len(data)) <= remaining {
-		// Fits without wrapping
-		copy(r.data[dataOffset:dataOffset+uint64(len(data))], data)
-	} else {
-		// Wrap around
-		copy(r.data[dataOffset:HeaderSize+uint64(r.size)], data[:remaining])
-		copy(r.data[HeaderSize:], data[remaining:])
+	// Publish the message only once it and every earlier one are in place
+	r.advanceInOrder(shmWriteOffset, writePos, writePos+msgSize)
+	atomic.AddUint64(r.cursor(shmWrittenOffset), 1)
+	if atomic.LoadUint32(r.waiters()) > 0 {
+		futexWake(r.sequenceWord())
 	}
-
-	// Update write position
-	newWritePos := writePos + uint64(msgSize)
-	r.setWritePos(newWritePos)
-	r.writePos = newWritePos
-
-	// Return the offset for RPC pointer passing
-	return offset, nil
+	return SharedMemoryHeaderSize + writePos%r.capacity, nil
 }
 
-// Read reads a message from the ring buffer
+// Read takes the oldest unclaimed message from the ring
 func (r *SharedMemoryRing) Read() ([]byte, error) {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-
-	writePos := r.getWritePos()
-	readPos := r.getReadPos()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 
-	// Check if buffer is empty
-	if readPos >= writePos {
-		return nil, fmt.Errorf("ring buffer empty")
+	if r.data == nil {
+		return nil, errRingDetached
 	}
-
-	// Calculate actual position in ring buffer
-	offset := HeaderSize + (readPos % uint64(r.size))
-
-	// Read length prefix
-	msgLen := binary.LittleEndian.Uint32(r.data[offset : offset+4])
-	if msgLen > MaxMessageSize {
-		return nil, fmt.Errorf("corrupted message: length %d exceeds max", msgLen)
+	claim := r.cursor(shmClaimOffset)
+	var readPos, msgLen uint64
+	for {
+		readPos = atomic.LoadUint64(claim)
+		writePos := atomic.LoadUint64(r.cursor(shmWriteOffset))
+		if readPos >= writePos {
+			return nil, errRingEmpty
+		}
+		var length [4]byte
+		r.copyOut(readPos, length[:])
+		msgLen = uint64(binary.LittleEndian.Uint32(length[:]))
+		if msgLen > MaxMessageSize || 4+msgLen > writePos-readPos {
+			if atomic.LoadUint64(claim) != readPos {
+				continue // Another reader took the message meanwhile
+			}
+			return nil, fmt.Errorf("corrupted message: length %d exceeds the %d bytes written", msgLen, writePos-readPos-4)
+		}
+		if atomic.CompareAndSwapUint64(claim, readPos, readPos+4+msgLen) {
+			break
+		}
 	}
-
-	// Read data
-	dataOffset := offset + 4
 	data := make([]byte, msgLen)
-	remaining := uint64(r.size) - (dataOffset - HeaderSize)
+	r.copyOut(readPos+4, data)
 
-	if uint64(msgLen) <= remaining {
-		// No wrap around
-		copy(data, r.data[dataOffset:dataOffset+uint64(msgLen)])
-	} else {
-		// Wrap around
-		copy(data[:remaining], r.data[dataOffset:HeaderSize+uint64(r.size)])
-		copy(data[remaining:], r.data[HeaderSize:])
-	}
+	// Free the space only once every earlier message has been copied out
+	r.advanceInOrder(shmReadOffset, readPos, readPos+4+msgLen)
+	atomic.AddUint64(r.cursor(shmReadCountOff), 1)
+	return data, nil
+}
 
-	// Update read position
-	newReadPos := readPos + 4 + uint64(msgLen)
-	r.setReadPos(newReadPos)
-	r.readPos = newReadPos
+// Sequence returns the ring's sequence number: the messages ever written
+// to it
+func (r *SharedMemoryRing) Sequence() uint64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.data == nil {
+		return 0
+	}
+	return atomic.LoadUint64(r.cursor(shmWrittenOffset))
+}
 
-	return data, nil
+// Wait blocks until the sequence number passes after, then returns it. It
+// fails once ctx ends or the ring is detached.
+func (r *SharedMemoryRing) Wait(ctx context.Context, after uint64) (uint64, error) {
+	for {
+		r.mu.RLock()
+		if r.data == nil {
+			r.mu.RUnlock()
+			return 0, errRingDetached
+		}
+		seq := atomic.LoadUint64(r.cursor(shmWrittenOffset))
+		if seq > after {
+			r.mu.RUnlock()
+			return seq, nil
+		}
+		if err := ctx.Err(); err != nil {
+			r.mu.RUnlock()
+			return seq, err
+		}
+		atomic.AddUint32(r.waiters(), 1)
+		futexWait(r.sequenceWord(), uint32(seq), shmWaitSlice)
+		atomic.AddUint32(r.waiters(), ^uint32(0))
+		r.mu.RUnlock()
+	}
 }
 
-// ReadAt reads a message at a specific offset (for RPC pointer passing)
+// ReadAt reads the message at a file offset returned by Write, without
+// consuming it
 func (r *SharedMemoryRing) ReadAt(offset uint64) ([]byte, error) {
-	if offset < HeaderSize || offset >= uint64(len(r.data)) {
+	if offset < SharedMemoryHeaderSize || offset >= SharedMemoryHeaderSize+r.capacity {
 		return nil, fmt.Errorf("invalid offset: %d", offset)
 	}
-
-	// Read length prefix
-	msgLen := binary.LittleEndian.Uint32(r.data[offset : offset+4])
-	if msgLen > MaxMessageSize {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.data == nil {
+		return nil, errRingDetached
+	}
+	pos := offset - SharedMemoryHeaderSize
+	var length [4]byte
+	r.copyOut(pos, length[:])
+	msgLen := uint64(binary.LittleEndian.Uint32(length[:]))
+	if msgLen > MaxMessageSize || 4+msgLen > r.capacity {
 		return nil, fmt.Errorf("corrupted message: length %d exceeds max", msgLen)
 	}
-
-	// Read data
-	dataOffset := offset + 4
 	data := make([]byte, msgLen)
-	remaining := uint64(r.size) - (dataOffset - HeaderSize)
-
-	if uint64(msgLen) <= remaining {
-		copy(data, r.data[dataOffset:dataOffset+uint64(msgLen)])
-	} else {
-		copy(data[:remaining], r.data[dataOffset:HeaderSize+uint64(r.size)])
-		copy(data[remaining:], r.data[HeaderSize:])
-	}
-
+	r.copyOut(pos+4, data)
 	return data, nil
 }
 
-// GetStats returns buffer statistics
-func (r *SharedMemoryRing) GetStats() (writePos, readPos uint64, used int) {
+// Name returns the ring's name
+func (r *SharedMemoryRing) Name() string {
+	return r.name
+}
+
+// Stats returns the ring's size and, while it is attached, its cursors
+func (r *SharedMemoryRing) Stats() SharedMemoryRingStats {
+	stats := SharedMemoryRingStats{Name: r.name, Path: r.path, Capacity: r.capacity}
 	r.mu.RLock()
 	defer r.mu.RUnlock()
-
-	writePos = r.getWritePos()
-	readPos = r.getReadPos()
-	used = int(writePos - readPos)
-
-	return
+	if r.data == nil {
+		return stats
+	}
+	stats.WriteCursor = atomic.LoadUint64(r.cursor(shmWriteOffset))
+	stats.ReadCursor = atomic.LoadUint64(r.cursor(shmReadOffset))
+	stats.Used = stats.WriteCursor - stats.ReadCursor
+	stats.MessagesWritten = atomic.LoadUint64(r.cursor(shmWrittenOffset))
+	stats.MessagesRead = atomic.LoadUint64(r.cursor(shmReadCountOff))
+	return stats
 }
 
-// Close unmaps and closes the shared memory
+// Close unmaps the ring, leaving its file for other processes or a later
+// attach. Readers waiting in this process are woken and fail.
 func (r *SharedMemoryRing) Close() error {
-	if err := syscall.Munmap(r.data); err != nil {
-		return err
-	}
-	if err := syscall.Close(r.fd); err != nil {
-		return err
+	r.mu.RLock()
+	if r.data != nil {
+		futexWake(r.sequenceWord())
 	}
+	r.mu.RUnlock()
 
-	// Optionally remove the shared memory file
-	shmPath := fmt.Sprintf("/dev/shm/pangea_%s", r.name)
-	os.Remove(shmPath)
-
-	return nil
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.data == nil {
+		return nil
+	}
+	r.data = nil
+	return r.unmap()
 }
 
-// Cleanup removes a shared memory segment
-func CleanupSharedMemory(name string) error {
-	shmPath := fmt.Sprintf("/dev/shm/pangea_%s", name)
-	return os.Remove(shmPath)
+// GetDataPointer returns the address of the mapping
+func (r *SharedMemoryRing) GetDataPointer() uintptr {
+	return uintptr(unsafe.Pointer(&r.data[0]))
 }
 
-// SharedMemoryManager manages multiple shared memory rings
+// SharedMemoryManager creates, attaches, detaches and destroys the rings
+// of one directory. Rings outlive the node until destroyed, so clients
+// keep theirs across a node restart and reattach.
 type SharedMemoryManager struct {
-	rings map[string]*SharedMemoryRing
-	mu    sync.RWMutex
+	dir         string
+	defaultSize int
+	rings       map[string]*SharedMemoryRing // Attached rings
+	subs        map[uint64]*SharedMemoryRingSubscription
+	nextSubID   uint64
+	mu          sync.RWMutex
 }
 
+// SharedMemoryRingSubscription receives a ring's sequence number on C when
+// messages are written to it, until it is unsubscribed or the ring is
+// detached, when C is closed. A sequence number not yet taken is replaced
+// by the next, so a slow receiver gets one notification for many writes.
+type SharedMemoryRingSubscription struct {
+	ID   uint64
+	Ring string
+	C    <-chan uint64
+
+	cancel context.CancelFunc
+}
+
+// NewSharedMemoryManager creates a manager for the default directory:
+// /dev/shm where there is one, so rings stay in memory, else the system
+// temporary directory
 func NewSharedMemoryManager() *SharedMemoryManager {
 	return &SharedMemoryManager{
-		rings: make(map[string]*SharedMemoryRing),
+		dir:         defaultSharedMemoryDir(),
+		defaultSize: DefaultSharedMemoryRingSize,
+		rings:       make(map[string]*SharedMemoryRing),
+		subs:        make(map[uint64]*SharedMemoryRingSubscription),
+	}
+}
+
+// Configure sets the ring directory (empty keeps the current one) and the
+// size of rings created on first use. Attached rings stay where they are.
+func (m *SharedMemoryManager) Configure(dir string, defaultSize int) error {
+	if defaultSize < MinSharedMemoryRingSize || int64(defaultSize) > MaxSharedMemoryRingSize {
+		return fmt.Errorf("ring size %d outside %d-%d bytes", defaultSize, MinSharedMemoryRingSize, MaxSharedMemoryRingSize)
+	}
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return fmt.Errorf("failed to create ring directory: %w", err)
+		}
 	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if dir != "" {
+		m.dir = dir
+	}
+	m.defaultSize = defaultSize
+	return nil
+}
+
+// Dir returns the directory rings are created in
+func (m *SharedMemoryManager) Dir() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.dir
 }
 
+// CreateRing creates a ring with a data area of size bytes (0 = the
+// default size) and attaches it
 func (m *SharedMemoryManager) CreateRing(name string, size int) (*SharedMemoryRing, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -271,16 +487,74 @@ func (m *SharedMemoryManager) CreateRing(name string, size int) (*SharedMemoryRi
 	if _, exists := m.rings[name]; exists {
 		return nil, fmt.Errorf("ring %s already exists", name)
 	}
-
-	ring, err := NewSharedMemoryRing(name, size)
+	if size == 0 {
+		size = m.defaultSize
+	}
+	ring, err := createSharedMemoryRing(m.dir, name, size)
 	if err != nil {
 		return nil, err
 	}
+	m.rings[name] = ring
+	return ring, nil
+}
 
+// AttachRing maps an existing ring, such as one a client created or one
+// left by an earlier run of the node. A ring already attached is returned
+// as is.
+func (m *SharedMemoryManager) AttachRing(name string) (*SharedMemoryRing, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if ring, ok := m.rings[name]; ok {
+		return ring, nil
+	}
+	ring, err := attachSharedMemoryRing(m.dir, name)
+	if err != nil {
+		return nil, err
+	}
 	m.rings[name] = ring
 	return ring, nil
 }
 
+// DetachRing unmaps a ring, leaving its file and contents in place
+func (m *SharedMemoryManager) DetachRing(name string) error {
+	m.mu.Lock()
+	ring, ok := m.rings[name]
+	delete(m.rings, name)
+	m.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("ring %s is not attached", name)
+	}
+	return ring.Close()
+}
+
+// DestroyRing unmaps a ring if attached and removes its file. Processes
+// that still map it keep their mapping until they unmap it.
+func (m *SharedMemoryManager) DestroyRing(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	path, err := sharedMemoryPath(m.dir, name)
+	if err != nil {
+		return err
+	}
+	if ring, ok := m.rings[name]; ok {
+		delete(m.rings, name)
+		if err := ring.Close(); err != nil {
+			return err
+		}
+	}
+	if err := os.Remove(path); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("ring %s does not exist", name)
+		}
+		return err
+	}
+	return nil
+}
+
+// GetRing returns an attached ring
 func (m *SharedMemoryManager) GetRing(name string) (*SharedMemoryRing, bool) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
@@ -289,20 +563,100 @@ func (m *SharedMemoryManager) GetRing(name string) (*SharedMemoryRing, bool) {
 	return ring, ok
 }
 
+// GetOrCreateRing returns an attached ring, attaching it if it exists and
+// creating it at the default size if not
+func (m *SharedMemoryManager) GetOrCreateRing(name string) (*SharedMemoryRing, error) {
+	if ring, ok := m.GetRing(name); ok {
+		return ring, nil
+	}
+	if ring, err := m.AttachRing(name); err == nil {
+		return ring, nil
+	}
+	return m.CreateRing(name, 0)
+}
+
+// Rings returns the attached rings by name
+func (m *SharedMemoryManager) Rings() []*SharedMemoryRing {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	rings := make([]*SharedMemoryRing, 0, len(m.rings))
+	for _, ring := range m.rings {
+		rings = append(rings, ring)
+	}
+	sort.Slice(rings, func(i, j int) bool { return rings[i].name < rings[j].name })
+	return rings
+}
+
+// Subscribe follows an attached ring's sequence number. If messages are
+// waiting the current sequence number is sent at once.
+func (m *SharedMemoryManager) Subscribe(name string) (*SharedMemoryRingSubscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ring, ok := m.rings[name]
+	if !ok {
+		return nil, fmt.Errorf("ring %s is not attached", name)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := make(chan uint64, 1)
+	m.nextSubID++
+	sub := &SharedMemoryRingSubscription{ID: m.nextSubID, Ring: name, C: ch, cancel: cancel}
+	m.subs[sub.ID] = sub
+
+	stats := ring.Stats()
+	if stats.Used > 0 {
+		ch <- stats.MessagesWritten
+	}
+	go func() {
+		defer close(ch)
+		seq := stats.MessagesWritten
+		for {
+			next, err := ring.Wait(ctx, seq)
+			if err != nil {
+				m.Unsubscribe(sub.ID)
+				return
+			}
+			seq = next
+			select {
+			case ch <- seq:
+			default:
+				// Replace the notification the receiver has not taken
+				select {
+				case <-ch:
+				default:
+				}
+				ch <- seq
+			}
+		}
+	}()
+	return sub, nil
+}
+
+// Unsubscribe ends a subscription. Returns false if it had already ended.
+func (m *SharedMemoryManager) Unsubscribe(id uint64) bool {
+	m.mu.Lock()
+	sub, ok := m.subs[id]
+	delete(m.subs, id)
+	m.mu.Unlock()
+
+	if ok {
+		sub.cancel()
+	}
+	return ok
+}
+
+// CloseAll detaches every ring, leaving their files
 func (m *SharedMemoryManager) CloseAll() error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	var errs []error
 	for _, ring := range m.rings {
 		if err := ring.Close(); err != nil {
-			return err
+			errs = append(errs, err)
 		}
 	}
 	m.rings = make(map[string]*SharedMemoryRing)
-	return nil
-}
-
-// Helper function to get pointer to shared memory data
-func (r *SharedMemoryRing) GetDataPointer() uintptr {
-	return uintptr(unsafe.Pointer(&r.data[0]))
+	return errors.Join(errs...)
 }
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"math"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// Futex operations on a word shared between processes; the private
+// variants would only wake waiters in this process
+const (
+	futexOpWait = 0
+	futexOpWake = 1
+)
+
+// futexWait sleeps while *addr holds val, for at most timeout or until
+// woken
+func futexWait(addr *uint32, val uint32, timeout time.Duration) {
+	ts := syscall.NsecToTimespec(int64(timeout))
+	syscall.Syscall6(syscall.SYS_FUTEX, uintptr(unsafe.Pointer(addr)), futexOpWait, uintptr(val), uintptr(unsafe.Pointer(&ts)), 0, 0)
+}
+
+// futexWake wakes every process and thread sleeping on addr
+func futexWake(addr *uint32) {
+	syscall.Syscall6(syscall.SYS_FUTEX, uintptr(unsafe.Pointer(addr)), futexOpWake, math.MaxInt32, 0, 0, 0)
+}
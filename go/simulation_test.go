@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/pangea-net/go-node/pkg/compute"
+)
+
+// simNode is one node of a simulation, wired the way main wires a node
+type simNode struct {
+	node    *LibP2PPangeaNode
+	store   *NodeStore
+	adapter *LibP2PAdapter
+	compute *compute.Manager
+	service *nodeServiceServer
+}
+
+// simulation runs several nodes in the test process over localhost, every
+// pair connected and registered as each other's compute workers, so
+// integration tests can drive upload, download, compute and chat flows
+// across real nodes without launching binaries
+type simulation struct {
+	t     *testing.T
+	ctx   context.Context
+	nodes []*simNode
+}
+
+// newSimulation starts n nodes listening on consecutive ports from
+// basePort, using the ports as node IDs. The nodes stop when the test ends.
+func newSimulation(t *testing.T, n, basePort int) *simulation {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	t.Cleanup(cancel)
+	sim := &simulation{t: t, ctx: ctx}
+
+	for i := 0; i < n; i++ {
+		store := NewNodeStore()
+		node, err := NewLibP2PPangeaNodeWithOptions(uint32(basePort+i), store, false, true, basePort+i)
+		if err != nil {
+			t.Fatalf("failed to create node %d: %v", i, err)
+		}
+		t.Cleanup(func() { node.Stop() })
+
+		manager := compute.NewManager(compute.DefaultConfig())
+		t.Cleanup(manager.Close)
+		cp := NewComputeProtocol(node.GetHost(), manager, node.nodeID)
+		t.Cleanup(cp.Close)
+		node.SetComputeProtocol(cp)
+		manager.SetDelegator(cp)
+
+		adapter := NewLibP2PAdapter(node, store)
+		service := NewNodeServiceServerWithManager(store, adapter, nil, manager).(*nodeServiceServer)
+		sim.nodes = append(sim.nodes, &simNode{node: node, store: store, adapter: adapter, compute: manager, service: service})
+	}
+
+	for i, a := range sim.nodes {
+		for _, b := range sim.nodes[i+1:] {
+			if err := a.node.host.Connect(ctx, peer.AddrInfo{ID: b.node.host.ID(), Addrs: b.node.host.Addrs()}); err != nil {
+				t.Fatalf("failed to connect node %d to %d: %v", a.node.nodeID, b.node.nodeID, err)
+			}
+		}
+	}
+	for _, a := range sim.nodes {
+		for _, b := range sim.nodes {
+			if a != b {
+				a.node.computeProtocol.RegisterWorker(b.node.host.ID(), b.compute.GetCapacity())
+			}
+		}
+	}
+	return sim
+}
+
+// peerID is the ID node from uses for node to in network adapter calls
+func (sim *simulation) peerID(from, to int) uint32 {
+	return sim.nodes[from].adapter.PeerUint32ID(sim.nodes[to].node.host.ID())
+}
+
+// peerIDs maps node indexes to the IDs node from uses for them
+func (sim *simulation) peerIDs(from int, to ...int) []uint32 {
+	ids := make([]uint32, len(to))
+	for i, t := range to {
+		ids[i] = sim.peerID(from, t)
+	}
+	return ids
+}
+
+// requireCES skips tests that need the CES library when the node was built
+// against one that cannot process data
+func requireCES(t *testing.T) {
+	t.Helper()
+	pipeline := NewCESPipeline(3)
+	if pipeline == nil {
+		t.Skip("CES library unavailable")
+	}
+	defer pipeline.Close()
+	if shards, err := pipeline.Process([]byte("probe")); err != nil || len(shards) == 0 {
+		t.Skip("CES library cannot process data")
+	}
+}
+
+// upload CES-processes data on node from and places its shards on the
+// holders, as the upload RPC does. It returns the file hash and placements.
+func (sim *simulation) upload(from int, data []byte, holders ...int) (string, []shardPlacement) {
+	sim.t.Helper()
+	sum := sha256.Sum256(data)
+	fileHash := fmt.Sprintf("%x", sum[:])
+	stored, err := sim.nodes[from].service.uploadBlob(sim.ctx, fileHash, data, sim.peerIDs(from, holders...), 0, "")
+	if err != nil {
+		sim.t.Fatalf("upload from node %d failed: %v", from, err)
+	}
+	return fileHash, sim.translate(from, stored.placements)
+}
+
+// download fetches a file's shards on node at and reconstructs it, as the
+// download RPC does
+func (sim *simulation) download(at int, fileHash string, placements []shardPlacement) []byte {
+	sim.t.Helper()
+	s := sim.nodes[at].service
+	locations := sim.localize(at, placements)
+	shards, present, _ := fetchShards(sim.ctx, s.shardSource(), fileHash, locations,
+		cesDataShards+cesParityShards, cesDataShards, 0)
+	data, err := s.reconstructDownload(sim.ctx, fileHash, locations, shards, present)
+	if err != nil {
+		sim.t.Fatalf("download on node %d failed: %v", at, err)
+	}
+	return data
+}
+
+// storeShards places raw shards round-robin on the holders from node from,
+// bypassing CES, and returns their placements
+func (sim *simulation) storeShards(from int, fileHash string, shards [][]byte, holders ...int) []shardPlacement {
+	sim.t.Helper()
+	ids := sim.peerIDs(from, holders...)
+	placements := make([]shardPlacement, len(shards))
+	for i, data := range shards {
+		digest := sha256.Sum256(data)
+		placements[i] = shardPlacement{shardIndex: uint32(i), peerID: ids[i%len(ids)], shardHash: fmt.Sprintf("%x", digest[:])}
+		confirmed, err := sim.nodes[from].service.sendShardToPeer(placements[i].peerID, fileHash, uint32(i), data)
+		if err != nil {
+			sim.t.Fatalf("failed to place shard %d on node %d: %v", i, holders[i%len(holders)], err)
+		}
+		placements[i].confirmed = confirmed
+	}
+	return sim.translate(from, placements)
+}
+
+// fetchShards fetches raw shards on node at from their placements
+func (sim *simulation) fetchShards(at int, fileHash string, placements []shardPlacement) ([][]byte, []shardFetch) {
+	sim.t.Helper()
+	shards, present, fetches := fetchShards(sim.ctx, sim.nodes[at].service.shardSource(), fileHash,
+		sim.localize(at, placements), len(placements), len(placements), 0)
+	out := make([][]byte, len(shards))
+	for i := range shards {
+		if present[i] {
+			out[i] = shards[i].Data
+		}
+	}
+	return out, fetches
+}
+
+// delegate runs a compute task from node from on node to
+func (sim *simulation) delegate(from, to int, input []byte) *compute.TaskResult {
+	sim.t.Helper()
+	task := &compute.ComputeTask{
+		TaskID:       fmt.Sprintf("sim-%d-%d-%d", from, to, time.Now().UnixNano()),
+		InputData:    input,
+		FunctionName: "matrix_block_multiply",
+		TimeoutMs:    10000,
+	}
+	result, err := sim.nodes[from].node.computeProtocol.DelegateTask(sim.ctx, sim.nodes[to].node.host.ID().String(), task)
+	if err != nil {
+		sim.t.Fatalf("delegating from node %d to %d failed: %v", from, to, err)
+	}
+	return result
+}
+
+// chat opens an encrypted chat session from node from to node to and sends
+// the messages on it
+func (sim *simulation) chat(from, to int, session string, messages ...string) {
+	sim.t.Helper()
+	config := &EncryptionConfigData{EncryptionType: "asymmetric", KeyExchangeAlgo: "curve25519", SymmetricAlgo: "chacha20", EnableSignatures: true}
+	toPeer := sim.nodes[to].node.host.ID().String()
+	if _, err := sim.nodes[from].node.GetSecurityManager().GetChatSession(session); err != nil {
+		if _, err := sim.nodes[from].node.GetSecurityManager().CreateChatSession(session, toPeer, config); err != nil {
+			sim.t.Fatalf("failed to open chat %s: %v", session, err)
+		}
+	}
+	for _, text := range messages {
+		msg := &EphemeralChatMessageData{ToPeer: toPeer, Message: []byte(text)}
+		if err := sim.nodes[from].node.GetChatService().Send(sim.ctx, session, msg); err != nil {
+			sim.t.Fatalf("chat from node %d failed: %v", from, err)
+		}
+	}
+}
+
+// chatMessages returns the messages node at received on a session
+func (sim *simulation) chatMessages(at int, session string) []string {
+	sim.t.Helper()
+	received, err := sim.nodes[at].node.GetSecurityManager().GetChatMessages(session)
+	if err != nil {
+		sim.t.Fatalf("node %d has no chat %s: %v", at, session, err)
+	}
+	out := make([]string, len(received))
+	for i, m := range received {
+		out[i] = string(m.Message)
+	}
+	return out
+}
+
+// translate rewrites placements from node from's peer IDs to node indexes
+// in peerID, so they can be handed to any node with localize
+func (sim *simulation) translate(from int, placements []shardPlacement) []shardPlacement {
+	out := append([]shardPlacement(nil), placements...)
+	for i := range out {
+		for j := range sim.nodes {
+			if j != from && sim.peerID(from, j) == out[i].peerID {
+				out[i].peerID = uint32(j)
+			}
+		}
+	}
+	return out
+}
+
+// localize rewrites placements from node indexes to node at's peer IDs
+func (sim *simulation) localize(at int, placements []shardPlacement) []shardPlacement {
+	out := append([]shardPlacement(nil), placements...)
+	for i := range out {
+		out[i].peerID = sim.peerID(at, int(out[i].peerID))
+	}
+	return out
+}
+
+// matrixInput encodes a 1x1 by 1x1 block multiply of a and b
+func matrixInput(a, b float64) []byte {
+	var input bytes.Buffer
+	for _, v := range []float64{a, b} {
+		binary.Write(&input, binary.BigEndian, uint32(1))
+		binary.Write(&input, binary.BigEndian, uint32(1))
+		binary.Write(&input, binary.BigEndian, math.Float64bits(v))
+	}
+	return input.Bytes()
+}
+
+func TestSimulationFlows(t *testing.T) {
+	sim := newSimulation(t, 4, 12670)
+
+	t.Run("shards", func(t *testing.T) {
+		sim.t = t
+		shards := [][]byte{[]byte("alpha"), []byte("beta"), []byte("gamma")}
+		placements := sim.storeShards(0, "sim-file", shards, 1, 2)
+		for _, p := range placements {
+			if !p.confirmed {
+				t.Fatalf("shard %d not confirmed", p.shardIndex)
+			}
+		}
+		// A node that took no part in the upload fetches every shard
+		fetched, fetches := sim.fetchShards(3, "sim-file", placements)
+		for i := range shards {
+			if !bytes.Equal(fetched[i], shards[i]) {
+				t.Fatalf("shard %d fetched as %q, want %q", i, fetched[i], shards[i])
+			}
+		}
+		for _, f := range fetches {
+			if !f.verified {
+				t.Fatalf("shard %d not verified: %+v", f.shardIndex, f)
+			}
+		}
+	})
+
+	t.Run("upload and download", func(t *testing.T) {
+		sim.t = t
+		requireCES(t)
+		data := bytes.Repeat([]byte("simulated file "), 1024)
+		fileHash, placements := sim.upload(0, data, 1, 2, 3)
+		if got := sim.download(2, fileHash, placements); !bytes.Equal(got, data) {
+			t.Fatalf("downloaded %d bytes differing from the %d uploaded", len(got), len(data))
+		}
+	})
+
+	t.Run("compute", func(t *testing.T) {
+		sim.t = t
+		input := matrixInput(3, 4)
+		expected, err := compute.ExecuteMatrixBlockMultiply(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result := sim.delegate(1, 3, input)
+		if result.Status != compute.TaskCompleted || !bytes.Equal(result.ResultData, expected) {
+			t.Fatalf("delegated task returned %v (%s)", result.Status, result.Error)
+		}
+		if result.WorkerID != sim.nodes[3].node.host.ID().String() {
+			t.Errorf("task ran on %s, expected node 3", result.WorkerID)
+		}
+	})
+
+	t.Run("chat", func(t *testing.T) {
+		sim.t = t
+		sim.chat(0, 2, "sim-chat", "hello", "again")
+		if got := sim.chatMessages(2, "sim-chat"); len(got) != 2 || got[0] != "hello" || got[1] != "again" {
+			t.Fatalf("node 2 received %q", got)
+		}
+		sim.chat(2, 0, "sim-chat", "hi back")
+		if got := sim.chatMessages(0, "sim-chat"); len(got) != 1 || got[0] != "hi back" {
+			t.Fatalf("node 0 received %q", got)
+		}
+	})
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+const (
+	// StateGossipProtocolID is the libp2p protocol nodes spread signed state
+	// summaries over
+	StateGossipProtocolID = "/pangea/state-gossip/1.0.0"
+
+	// StateGossipInterval is how often a node publishes its own state
+	StateGossipInterval = 30 * time.Second
+
+	gossipFanout        = 6 // Peers each new summary is pushed to
+	gossipMaxHops       = 6 // Forwarding stops after this many relays
+	gossipStreamTimeout = 10 * time.Second
+	gossipMaxMessage    = 16 * 1024
+
+	// gossipOwnerExpiry is how long a node ID stays bound to the peer that
+	// announced it after its last summary, so a node restarted with a new
+	// identity can reclaim its ID
+	gossipOwnerExpiry = 5 * time.Minute
+)
+
+// NodeStateSummary is one node's signed description of its own state. The
+// origin signs everything but Hops and Signature with its libp2p identity
+// key, so relays can forward but not alter it.
+type NodeStateSummary struct {
+	Origin      string      `json:"origin"` // Origin's libp2p peer ID
+	PublicKey   []byte      `json:"publicKey"`
+	NodeID      uint32      `json:"nodeId"`
+	Seq         uint64      `json:"seq"`       // Increases with every publish
+	Timestamp   int64       `json:"timestamp"` // Unix milliseconds
+	Status      NodeState   `json:"status"`
+	LatencyMs   float32     `json:"latencyMs"`
+	ThreatScore float32     `json:"threatScore"`
+	StorageRole StorageRole `json:"storageRole"`
+	Hops        uint8       `json:"hops"`
+	Signature   []byte      `json:"signature"`
+}
+
+// signingBytes is the summary's signed content
+func (s NodeStateSummary) signingBytes() []byte {
+	s.Hops, s.Signature = 0, nil
+	data, _ := json.Marshal(s)
+	return data
+}
+
+// StateGossip publishes this node's NodeStore entry to the swarm and merges
+// the entries other nodes publish, giving every node a network-wide view.
+// Summaries are pushed to a few random peers, which forward new ones on.
+//
+// This is deliberately not GossipSub: no go-libp2p-pubsub release that
+// builds against this go-libp2p is a dependency yet. Summaries are
+// self-signed and deduplicated by origin sequence, so moving them onto a
+// pubsub topic later only replaces push and handleStream.
+type StateGossip struct {
+	host   host.Host
+	store  *NodeStore
+	nodeID uint32
+	seq    uint64
+	threat *ThreatEngine // Scores senders of bad summaries; may be nil
+
+	latest map[peer.ID]uint64     // Newest sequence merged per origin
+	owners map[uint32]gossipOwner // Origin announcing each node ID
+	mu     sync.Mutex
+}
+
+type gossipOwner struct {
+	peer peer.ID
+	seen time.Time
+}
+
+// NewStateGossip creates the service and registers its protocol handler.
+// nodeID is this node's entry in store.
+func NewStateGossip(h host.Host, store *NodeStore, nodeID uint32) *StateGossip {
+	g := &StateGossip{
+		host:   h,
+		store:  store,
+		nodeID: nodeID,
+		// Starts at the clock so a restarted node's summaries are newer
+		seq:    uint64(time.Now().UnixNano()),
+		latest: make(map[peer.ID]uint64),
+		owners: map[uint32]gossipOwner{nodeID: {peer: h.ID()}},
+	}
+	h.SetStreamHandler(protocol.ID(StateGossipProtocolID), g.handleStream)
+	return g
+}
+
+// Run publishes this node's state every interval until ctx is done
+func (g *StateGossip) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := g.Publish(ctx); err != nil {
+				log.Printf("⚠️  State gossip publish failed: %v", err)
+			}
+		}
+	}
+}
+
+// Publish signs this node's current state and pushes it to random peers
+func (g *StateGossip) Publish(ctx context.Context) error {
+	self, ok := g.store.GetNode(g.nodeID)
+	if !ok {
+		return fmt.Errorf("node %d is not in the store", g.nodeID)
+	}
+	pub, err := crypto.MarshalPublicKey(g.host.Peerstore().PubKey(g.host.ID()))
+	if err != nil {
+		return err
+	}
+
+	g.mu.Lock()
+	g.seq++
+	seq := g.seq
+	g.mu.Unlock()
+
+	self.mu.RLock()
+	summary := NodeStateSummary{
+		Origin:      g.host.ID().String(),
+		PublicKey:   pub,
+		NodeID:      g.nodeID,
+		Seq:         seq,
+		Timestamp:   time.Now().UnixMilli(),
+		Status:      self.Status,
+		LatencyMs:   self.LatencyMs,
+		ThreatScore: self.ThreatScore,
+		StorageRole: self.StorageRole,
+	}
+	self.mu.RUnlock()
+
+	if summary.Signature, err = g.host.Peerstore().PrivKey(g.host.ID()).Sign(summary.signingBytes()); err != nil {
+		return fmt.Errorf("failed to sign state: %w", err)
+	}
+	g.push(ctx, summary, "")
+	return nil
+}
+
+// push sends a summary to up to gossipFanout connected peers other than
+// the one it came from and its origin
+func (g *StateGossip) push(ctx context.Context, summary NodeStateSummary, from peer.ID) {
+	var targets []peer.ID
+	for _, p := range g.host.Network().Peers() {
+		if p != from && p.String() != summary.Origin {
+			targets = append(targets, p)
+		}
+	}
+	rand.Shuffle(len(targets), func(i, j int) { targets[i], targets[j] = targets[j], targets[i] })
+	if len(targets) > gossipFanout {
+		targets = targets[:gossipFanout]
+	}
+
+	for _, p := range targets {
+		// Peers without the protocol are expected; they miss this round
+		go g.send(ctx, p, summary)
+	}
+}
+
+func (g *StateGossip) send(ctx context.Context, p peer.ID, summary NodeStateSummary) error {
+	ctx, cancel := context.WithTimeout(ctx, gossipStreamTimeout)
+	defer cancel()
+	s, err := g.host.NewStream(ctx, p, protocol.ID(StateGossipProtocolID))
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	s.SetDeadline(time.Now().Add(gossipStreamTimeout))
+	return json.NewEncoder(s).Encode(summary)
+}
+
+func (g *StateGossip) handleStream(s network.Stream) {
+	defer s.Close()
+	s.SetDeadline(time.Now().Add(gossipStreamTimeout))
+
+	var summary NodeStateSummary
+	if err := json.NewDecoder(io.LimitReader(s, gossipMaxMessage)).Decode(&summary); err != nil {
+		log.Printf("⚠️  Bad state gossip from %s: %v", shortPeerID(s.Conn().RemotePeer()), err)
+		g.threat.Record(s.Conn().RemotePeer(), ThreatProtocolViolation, "malformed state gossip")
+		return
+	}
+	merged, err := g.merge(summary)
+	if err != nil {
+		log.Printf("⚠️  Rejected state gossip from %s: %v", shortPeerID(s.Conn().RemotePeer()), err)
+		g.threat.Record(s.Conn().RemotePeer(), ThreatProtocolViolation, err.Error())
+		return
+	}
+	if merged && summary.Hops < gossipMaxHops {
+		summary.Hops++
+		g.push(context.Background(), summary, s.Conn().RemotePeer())
+	}
+}
+
+// merge verifies a summary and applies it to the store. It reports whether
+// the summary was new, which is when it should be forwarded.
+func (g *StateGossip) merge(summary NodeStateSummary) (bool, error) {
+	origin, err := peer.Decode(summary.Origin)
+	if err != nil {
+		return false, fmt.Errorf("invalid origin: %w", err)
+	}
+	pub, err := crypto.UnmarshalPublicKey(summary.PublicKey)
+	if err != nil {
+		return false, fmt.Errorf("invalid public key: %w", err)
+	}
+	if !origin.MatchesPublicKey(pub) {
+		return false, fmt.Errorf("public key does not match origin %s", shortPeerID(origin))
+	}
+	valid, err := pub.Verify(summary.signingBytes(), summary.Signature)
+	if err != nil || !valid {
+		return false, fmt.Errorf("invalid signature from %s", shortPeerID(origin))
+	}
+	if origin == g.host.ID() {
+		return false, nil
+	}
+
+	g.mu.Lock()
+	owner, owned := g.owners[summary.NodeID]
+	if owned && owner.peer != origin && (owner.peer == g.host.ID() || time.Since(owner.seen) < gossipOwnerExpiry) {
+		g.mu.Unlock()
+		return false, fmt.Errorf("%s claims node %d, announced by %s", shortPeerID(origin), summary.NodeID, shortPeerID(owner.peer))
+	}
+	if summary.Seq <= g.latest[origin] {
+		g.mu.Unlock()
+		return false, nil
+	}
+	g.owners[summary.NodeID] = gossipOwner{peer: origin, seen: time.Now()}
+	g.latest[origin] = summary.Seq
+	g.mu.Unlock()
+
+	g.store.MergeRemoteState(&LocalNode{
+		ID:          summary.NodeID,
+		Status:      summary.Status,
+		LatencyMs:   summary.LatencyMs,
+		ThreatScore: summary.ThreatScore,
+		StorageRole: summary.StorageRole,
+		PeerID:      summary.Origin,
+		UpdatedAt:   summary.Timestamp,
+	})
+	return true, nil
+}
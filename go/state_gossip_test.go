@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// mustMarshalPubKey returns the node's serialized libp2p public key
+func mustMarshalPubKey(t *testing.T, n *LibP2PPangeaNode) []byte {
+	pub, err := crypto.MarshalPublicKey(n.host.Peerstore().PubKey(n.host.ID()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pub
+}
+
+func TestStateGossipSpreadsSignedState(t *testing.T) {
+	newNode := func(id uint32, port int) *LibP2PPangeaNode {
+		store := NewNodeStore()
+		store.CreateNode(id)
+		n, err := NewLibP2PPangeaNodeWithOptions(id, store, false, true, port)
+		if err != nil {
+			t.Fatalf("failed to create node %d: %v", id, err)
+		}
+		t.Cleanup(n.cancel)
+		return n
+	}
+	n1, n2, n3 := newNode(540, 12540), newNode(541, 12541), newNode(542, 12542)
+
+	// A line: n1's state must reach n3 through n2
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := n1.host.Connect(ctx, peer.AddrInfo{ID: n2.host.ID(), Addrs: n2.host.Addrs()}); err != nil {
+		t.Fatal(err)
+	}
+	if err := n2.host.Connect(ctx, peer.AddrInfo{ID: n3.host.ID(), Addrs: n3.host.Addrs()}); err != nil {
+		t.Fatal(err)
+	}
+
+	n1.store.UpdateThreatScore(540, 0.9)
+	if err := n1.gossip.Publish(ctx); err != nil {
+		t.Fatalf("publish failed: %v", err)
+	}
+
+	var got *LocalNode
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if node, ok := n3.store.GetNode(540); ok {
+			got = node
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if got == nil {
+		t.Fatal("n1's state never reached n3")
+	}
+	got.mu.RLock()
+	status, threat, origin, updated := got.Status, got.ThreatScore, got.PeerID, got.UpdatedAt
+	got.mu.RUnlock()
+	if status != StatePurgatory || threat != 0.9 || origin != n1.host.ID().String() || updated == 0 {
+		t.Fatalf("unexpected merged state: status %v threat %v origin %s updated %d", status, threat, origin, updated)
+	}
+	if _, ok := n2.store.GetNode(540); !ok {
+		t.Fatal("expected the relay to merge the state too")
+	}
+}
+
+func TestStateGossipRejectsForgedState(t *testing.T) {
+	n1, err := NewLibP2PPangeaNodeWithOptions(543, NewNodeStore(), true, true, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer n1.cancel()
+	n2, err := NewLibP2PPangeaNodeWithOptions(544, NewNodeStore(), true, true, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer n2.cancel()
+
+	// A summary signed by n2 about node 7
+	sign := func(s NodeStateSummary) NodeStateSummary {
+		sig, err := n2.host.Peerstore().PrivKey(n2.host.ID()).Sign(s.signingBytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.Signature = sig
+		return s
+	}
+	n2.store.CreateNode(7)
+	n2gossip := NewStateGossip(n2.host, n2.store, 7)
+	summary := NodeStateSummary{Origin: n2.host.ID().String(), NodeID: 7, Seq: 10, Timestamp: 1000, ThreatScore: 0.5}
+	summary.PublicKey = mustMarshalPubKey(t, n2)
+	summary = sign(summary)
+
+	if merged, err := n1.gossip.merge(summary); err != nil || !merged {
+		t.Fatalf("expected a valid summary to merge, got %v %v", merged, err)
+	}
+	if merged, _ := n1.gossip.merge(summary); merged {
+		t.Fatal("expected a replayed summary to be ignored")
+	}
+
+	tampered := summary
+	tampered.Seq, tampered.ThreatScore = 11, 0
+	if _, err := n1.gossip.merge(tampered); err == nil {
+		t.Fatal("expected a tampered summary to be rejected")
+	}
+
+	// Hops is left out of the signature so relays can count it
+	relayed := summary
+	relayed.Hops = 3
+	relayed.Seq = 12
+	relayed = sign(relayed)
+	relayed.Hops = 5
+	if merged, err := n1.gossip.merge(relayed); err != nil || !merged {
+		t.Fatalf("expected a relayed summary to merge, got %v %v", merged, err)
+	}
+
+	// Another origin cannot take over node 7, nor this node's own ID
+	forged := NodeStateSummary{Origin: n1.host.ID().String(), NodeID: 7, Seq: 20, Timestamp: 2000}
+	forged.PublicKey = mustMarshalPubKey(t, n1)
+	forged.Signature, _ = n1.host.Peerstore().PrivKey(n1.host.ID()).Sign(forged.signingBytes())
+	if _, err := n2gossip.merge(forged); err == nil {
+		t.Fatal("expected a claim on another node's ID to be rejected")
+	}
+	claim := sign(NodeStateSummary{Origin: n2.host.ID().String(), PublicKey: mustMarshalPubKey(t, n2), NodeID: 543, Seq: 30, Timestamp: 3000})
+	if _, err := n1.gossip.merge(claim); err == nil {
+		t.Fatal("expected a claim on the receiver's own ID to be rejected")
+	}
+
+	node, _ := n1.store.GetNode(7)
+	if node.ThreatScore != 0.5 || node.UpdatedAt != 1000 {
+		t.Fatalf("unexpected stored state: threat %v updated %d", node.ThreatScore, node.UpdatedAt)
+	}
+}
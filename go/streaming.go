@@ -59,6 +59,9 @@ type StreamingService struct {
 	// Peer connections for TCP chat
 	tcpPeers map[string]net.Conn
 	peerMu   sync.RWMutex
+
+	// Paces sends as interactive traffic; may be nil
+	bandwidth *BandwidthScheduler
 }
 
 // NewStreamingService creates a new streaming service
@@ -283,6 +286,9 @@ func (s *StreamingService) SendVideoFrame(peerAddr *net.UDPAddr, frameID uint32,
 	binary.BigEndian.PutUint16(packet[7:9], 1) // totalPackets
 	copy(packet[9:], data)
 
+	if err := s.bandwidth.Wait(s.ctx, BandwidthInteractive, len(packet)); err != nil {
+		return err
+	}
 	_, err := s.udpConn.WriteToUDP(packet, peerAddr)
 	return err
 }
@@ -308,6 +314,9 @@ func (s *StreamingService) sendFragmentedFrame(peerAddr *net.UDPAddr, frameID ui
 		binary.BigEndian.PutUint16(packet[7:9], uint16(totalPackets))
 		copy(packet[9:], chunk)
 
+		if err := s.bandwidth.Wait(s.ctx, BandwidthInteractive, len(packet)); err != nil {
+			return err
+		}
 		if _, err := s.udpConn.WriteToUDP(packet, peerAddr); err != nil {
 			return fmt.Errorf("failed to send fragment %d/%d: %w", i+1, totalPackets, err)
 		}
@@ -328,6 +337,9 @@ func (s *StreamingService) SendAudioChunk(peerAddr *net.UDPAddr, data []byte) er
 	// Reserved bytes for future use (sync info, etc.)
 	copy(packet[9:], data)
 
+	if err := s.bandwidth.Wait(s.ctx, BandwidthInteractive, len(packet)); err != nil {
+		return err
+	}
 	_, err := s.udpConn.WriteToUDP(packet, peerAddr)
 	return err
 }
@@ -342,6 +354,9 @@ func (s *StreamingService) SendChatMessage(peerAddr string, message string) erro
 		return fmt.Errorf("peer %s not connected", peerAddr)
 	}
 
+	if err := s.bandwidth.Wait(s.ctx, BandwidthInteractive, len(message)); err != nil {
+		return err
+	}
 	_, err := conn.Write([]byte(message))
 	return err
 }
@@ -483,6 +498,7 @@ func (s *StreamingStats) RecordSent(bytes int) {
 	s.FramesSent++
 	s.BytesSent += uint64(bytes)
 	s.mu.Unlock()
+	udpStreamBytesTotal.WithLabelValues("out").Add(float64(bytes))
 }
 
 // RecordReceived records a received frame
@@ -491,6 +507,7 @@ func (s *StreamingStats) RecordReceived(bytes int) {
 	s.FramesReceived++
 	s.BytesReceived += uint64(bytes)
 	s.mu.Unlock()
+	udpStreamBytesTotal.WithLabelValues("in").Add(float64(bytes))
 }
 
 // GetStats returns current statistics
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/control"
+	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+)
+
+// Threat scoring defaults
+const (
+	DefaultThreatHalfLife            = 15 * time.Minute // Scores halve this long after the last offence
+	DefaultThreatDisconnectThreshold = 0.8              // Matches the store's purgatory threshold
+
+	// threatForgetBelow is the score under which a peer's record is dropped
+	threatForgetBelow = 0.01
+)
+
+// ThreatEvent is a kind of peer misbehaviour that raises its threat score
+type ThreatEvent int
+
+const (
+	ThreatFailedHandshake   ThreatEvent = iota // Identify or session key exchange failed
+	ThreatProtocolViolation                    // Malformed, unknown or forged protocol messages
+	ThreatShardAuditFailure                    // A stored shard did not match its hash
+	ThreatComputeMismatch                      // A compute result failed verification
+)
+
+func (e ThreatEvent) String() string {
+	switch e {
+	case ThreatFailedHandshake:
+		return "failed_handshake"
+	case ThreatProtocolViolation:
+		return "protocol_violation"
+	case ThreatShardAuditFailure:
+		return "shard_audit_failure"
+	case ThreatComputeMismatch:
+		return "compute_mismatch"
+	default:
+		return "unknown"
+	}
+}
+
+// DefaultThreatWeights is the score each event adds. Handshakes also fail
+// on flaky links, so they weigh least; a wrong result is deliberate more
+// often than not.
+var DefaultThreatWeights = map[ThreatEvent]float64{
+	ThreatFailedHandshake:   0.05,
+	ThreatProtocolViolation: 0.2,
+	ThreatShardAuditFailure: 0.3,
+	ThreatComputeMismatch:   0.4,
+}
+
+// ThreatConfig configures threat scoring
+type ThreatConfig struct {
+	// Score added per event, between 0 and 1; events left out use
+	// DefaultThreatWeights
+	Weights map[ThreatEvent]float64
+
+	// Time for a score to decay to half
+	HalfLife time.Duration
+
+	// Peers at or above this score are disconnected, and refused until
+	// their score decays below it
+	DisconnectThreshold float64
+}
+
+// DefaultThreatConfig returns the default scoring
+func DefaultThreatConfig() ThreatConfig {
+	return ThreatConfig{
+		HalfLife:            DefaultThreatHalfLife,
+		DisconnectThreshold: DefaultThreatDisconnectThreshold,
+	}
+}
+
+// ThreatEngine keeps a decaying threat score between 0 and 1 per peer,
+// raised by the misbehaviour subsystems report. It is also the host's
+// connection gater, so peers over the threshold cannot reconnect.
+type ThreatEngine struct {
+	config ThreatConfig
+
+	scores    map[peer.ID]*peerThreat
+	listeners []func(p peer.ID, score float64)
+	mu        sync.Mutex
+}
+
+type peerThreat struct {
+	score   float64
+	updated time.Time
+}
+
+// NewThreatEngine creates an engine, filling unset config fields with the
+// defaults
+func NewThreatEngine(config ThreatConfig) *ThreatEngine {
+	defaults := DefaultThreatConfig()
+	if config.HalfLife <= 0 {
+		config.HalfLife = defaults.HalfLife
+	}
+	if config.DisconnectThreshold <= 0 {
+		config.DisconnectThreshold = defaults.DisconnectThreshold
+	}
+	weights := make(map[ThreatEvent]float64, len(DefaultThreatWeights))
+	for event, weight := range DefaultThreatWeights {
+		weights[event] = weight
+	}
+	for event, weight := range config.Weights {
+		weights[event] = math.Min(math.Max(weight, 0), 1)
+	}
+	config.Weights = weights
+	return &ThreatEngine{config: config, scores: make(map[peer.ID]*peerThreat)}
+}
+
+// Config returns the engine's configuration
+func (te *ThreatEngine) Config() ThreatConfig {
+	return te.config
+}
+
+// OnChange registers a listener called with a peer's new score after every
+// recorded event
+func (te *ThreatEngine) OnChange(fn func(p peer.ID, score float64)) {
+	te.mu.Lock()
+	defer te.mu.Unlock()
+	te.listeners = append(te.listeners, fn)
+}
+
+// Record raises a peer's score for an event and returns the new score. It
+// is a no-op on a nil engine, so subsystems need not check for one.
+func (te *ThreatEngine) Record(p peer.ID, event ThreatEvent, reason string) float64 {
+	if te == nil {
+		return 0
+	}
+	now := time.Now()
+
+	te.mu.Lock()
+	for id, t := range te.scores {
+		if id != p && te.decayed(t, now) < threatForgetBelow {
+			delete(te.scores, id)
+		}
+	}
+	t, ok := te.scores[p]
+	if !ok {
+		t = &peerThreat{}
+		te.scores[p] = t
+	}
+	before := te.decayed(t, now)
+	t.score = math.Min(before+te.config.Weights[event], 1)
+	t.updated = now
+	score := t.score
+	listeners := te.listeners
+	te.mu.Unlock()
+
+	log.Printf("⚠️  [THREAT] %s: %s (%s), score %.2f", shortPeerID(p), event, reason, score)
+	if before < te.config.DisconnectThreshold && score >= te.config.DisconnectThreshold {
+		log.Printf("⛔ [THREAT] %s reached %.2f, disconnecting", shortPeerID(p), score)
+	}
+	for _, fn := range listeners {
+		fn(p, score)
+	}
+	return score
+}
+
+// Score returns a peer's current, decayed score
+func (te *ThreatEngine) Score(p peer.ID) float64 {
+	te.mu.Lock()
+	defer te.mu.Unlock()
+	t, ok := te.scores[p]
+	if !ok {
+		return 0
+	}
+	return te.decayed(t, time.Now())
+}
+
+// Blocked reports whether a peer is at or above the disconnect threshold
+func (te *ThreatEngine) Blocked(p peer.ID) bool {
+	return te.Score(p) >= te.config.DisconnectThreshold
+}
+
+// Scores returns the current score of every peer with a record
+func (te *ThreatEngine) Scores() map[peer.ID]float64 {
+	te.mu.Lock()
+	defer te.mu.Unlock()
+	now := time.Now()
+	scores := make(map[peer.ID]float64, len(te.scores))
+	for id, t := range te.scores {
+		scores[id] = te.decayed(t, now)
+	}
+	return scores
+}
+
+// decayed returns t's score at now. Caller must hold te.mu.
+func (te *ThreatEngine) decayed(t *peerThreat, now time.Time) float64 {
+	elapsed := now.Sub(t.updated)
+	if elapsed <= 0 {
+		return t.score
+	}
+	return t.score * math.Pow(0.5, float64(elapsed)/float64(te.config.HalfLife))
+}
+
+// Watch records failed identify handshakes until ctx is cancelled
+func (te *ThreatEngine) Watch(ctx context.Context, bus event.Bus, nw network.Network) error {
+	sub, err := bus.Subscribe(new(event.EvtPeerIdentificationFailed))
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to identify events: %w", err)
+	}
+
+	go func() {
+		defer sub.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case e, ok := <-sub.Out():
+				if !ok {
+					return
+				}
+				evt := e.(event.EvtPeerIdentificationFailed)
+				if droppedDuplicateConn(nw, evt) {
+					continue
+				}
+				te.Record(evt.Peer, ThreatFailedHandshake, fmt.Sprintf("identify: %v", evt.Reason))
+			}
+		}
+	}()
+	return nil
+}
+
+// droppedDuplicateConn reports whether identify failed only because its
+// connection was torn down while the peer stays connected over another,
+// as when dials to a dual-stack peer's IPv4 and IPv6 addresses both land
+func droppedDuplicateConn(nw network.Network, evt event.EvtPeerIdentificationFailed) bool {
+	if nw == nil || nw.Connectedness(evt.Peer) != network.Connected {
+		return false
+	}
+	return errors.Is(evt.Reason, syscall.ECONNRESET) || errors.Is(evt.Reason, network.ErrReset) ||
+		errors.Is(evt.Reason, net.ErrClosed) || errors.Is(evt.Reason, io.EOF)
+}
+
+// applyThreatScore copies a peer's new score to the store entries it
+// announced and disconnects it once blocked
+func (n *LibP2PPangeaNode) applyThreatScore(p peer.ID, score float64) {
+	for _, node := range n.store.GetAllNodes() {
+		node.mu.RLock()
+		announced := node.PeerID == p.String()
+		node.mu.RUnlock()
+		if announced {
+			n.store.UpdateThreatScore(node.ID, float32(score))
+		}
+	}
+	if n.threat.Blocked(p) {
+		if err := n.host.Network().ClosePeer(p); err != nil {
+			log.Printf("⚠️  [THREAT] Failed to disconnect %s: %v", shortPeerID(p), err)
+		}
+	}
+}
+
+// ConnectionGater methods: blocked peers are neither dialed nor accepted
+
+func (te *ThreatEngine) InterceptPeerDial(p peer.ID) bool {
+	return !te.Blocked(p)
+}
+
+func (te *ThreatEngine) InterceptAddrDial(p peer.ID, _ multiaddr.Multiaddr) bool {
+	return !te.Blocked(p)
+}
+
+func (te *ThreatEngine) InterceptAccept(network.ConnMultiaddrs) bool {
+	return true // The peer is not known until the connection is secured
+}
+
+func (te *ThreatEngine) InterceptSecured(_ network.Direction, p peer.ID, _ network.ConnMultiaddrs) bool {
+	return !te.Blocked(p)
+}
+
+func (te *ThreatEngine) InterceptUpgraded(network.Conn) (bool, control.DisconnectReason) {
+	return true, 0
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestThreatEngineScoring(t *testing.T) {
+	engine := NewThreatEngine(ThreatConfig{
+		Weights:             map[ThreatEvent]float64{ThreatFailedHandshake: 0.25},
+		HalfLife:            100 * time.Millisecond,
+		DisconnectThreshold: 0.7,
+	})
+	p := peer.ID("suspect")
+
+	var changes []float64
+	engine.OnChange(func(id peer.ID, score float64) {
+		if id == p {
+			changes = append(changes, score)
+		}
+	})
+
+	if got := engine.Record(p, ThreatFailedHandshake, "test"); got != 0.25 {
+		t.Fatalf("expected the configured weight, got %v", got)
+	}
+	if got := engine.Record(p, ThreatComputeMismatch, "test"); math.Abs(got-0.65) > 0.01 {
+		t.Fatalf("expected the default weight to add up to 0.65, got %v", got)
+	}
+	if engine.Blocked(p) {
+		t.Fatal("peer blocked below the threshold")
+	}
+	engine.Record(p, ThreatShardAuditFailure, "test")
+	engine.Record(p, ThreatComputeMismatch, "test")
+	if got := engine.Score(p); got > 1 {
+		t.Fatalf("expected the score capped at 1, got %v", got)
+	}
+	if !engine.Blocked(p) || len(changes) != 4 {
+		t.Fatalf("expected a blocked peer after 4 changes, got %v %v", engine.Blocked(p), changes)
+	}
+	if engine.InterceptPeerDial(p) || engine.InterceptSecured(network.DirInbound, p, nil) {
+		t.Fatal("expected the gater to refuse a blocked peer")
+	}
+
+	// Two half-lives bring 1.0 well under the threshold
+	time.Sleep(250 * time.Millisecond)
+	if got := engine.Score(p); got > 0.25 || engine.Blocked(p) {
+		t.Fatalf("expected the score to decay, got %v", got)
+	}
+	if !engine.InterceptPeerDial(p) {
+		t.Fatal("expected a decayed peer to be allowed again")
+	}
+
+	var nilEngine *ThreatEngine
+	nilEngine.Record(p, ThreatProtocolViolation, "ignored")
+}
+
+func TestThreatEngineDisconnectsPeer(t *testing.T) {
+	newNode := func(id uint32, port int) *LibP2PPangeaNode {
+		n, err := NewLibP2PPangeaNodeWithOptions(id, NewNodeStore(), false, true, port)
+		if err != nil {
+			t.Fatalf("failed to create node %d: %v", id, err)
+		}
+		t.Cleanup(n.cancel)
+		return n
+	}
+	n1, n2 := newNode(550, 12550), newNode(551, 12551)
+
+	connect := func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return n1.host.Connect(ctx, peer.AddrInfo{ID: n2.host.ID(), Addrs: n2.host.Addrs()})
+	}
+	if err := connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	// n1 announced node 7 through gossip, so its score follows n1's
+	n2.store.MergeRemoteState(&LocalNode{ID: 7, PeerID: n1.host.ID().String(), UpdatedAt: 1})
+
+	// Unknown RPC types are protocol violations
+	rs, err := openRPCStream(context.Background(), n1.host, n2.host.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs.send(0xff, nil)
+	rs.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for n2.threat.Score(n1.host.ID()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if got := n2.threat.Score(n1.host.ID()); got < 0.19 {
+		t.Fatalf("expected a protocol violation to be scored, got %v", got)
+	}
+
+	for i := 0; i < 4; i++ {
+		n2.threat.Record(n1.host.ID(), ThreatProtocolViolation, "test")
+	}
+	if n2.host.Network().Connectedness(n1.host.ID()) == network.Connected {
+		t.Fatal("expected a blocked peer to be disconnected")
+	}
+	node, _ := n2.store.GetNode(7)
+	node.mu.RLock()
+	threat, status := node.ThreatScore, node.Status
+	node.mu.RUnlock()
+	if threat < 0.79 || status != StatePurgatory {
+		t.Fatalf("expected the announced node in purgatory, got %v %v", threat, status)
+	}
+
+	// QUIC may report the dial as complete before the gater closes it
+	connect()
+	time.Sleep(200 * time.Millisecond)
+	if n2.host.Network().Connectedness(n1.host.ID()) == network.Connected {
+		t.Fatal("expected a blocked peer to be refused")
+	}
+}
@@ -0,0 +1,411 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	kyberdkg "github.com/pangea-net/go-node/pkg/crypto/dkg/kyber"
+	"go.dedis.ch/kyber/v3"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+const (
+	// ThresholdSignProtocolID is the libp2p protocol signing group members
+	// use to generate a group key and sign with their shares of it
+	ThresholdSignProtocolID = "/pangea/tsign/1.0.0"
+
+	// tsignTimeout bounds one request to a group member
+	tsignTimeout = 15 * time.Second
+
+	// tsignNonceTTL is how long a signer keeps a committed nonce waiting
+	// for the signing request
+	tsignNonceTTL = time.Minute
+
+	// Threshold signing frame types
+	tsignFrameCommit = "sign-commit" // Signing round 1: answered with a nonce commitment
+	tsignFrameSign   = "sign"        // Signing round 2: answered with a partial signature
+	tsignFrameReply  = "reply"
+)
+
+// Signature purposes; the purpose is part of the signed message so a
+// signature over a manifest cannot pass for one over a job result
+const (
+	SignPurposeManifest  = "manifest"
+	SignPurposeJobResult = "job-result"
+)
+
+// tsignFrame is one JSON message on a threshold signing stream
+type tsignFrame struct {
+	Type        string            `json:"type"`
+	GroupID     string            `json:"groupId"`
+	SessionID   string            `json:"sessionId,omitempty"`
+	Purpose     string            `json:"purpose,omitempty"`
+	Payload     []byte            `json:"payload,omitempty"`
+	Commitments []tsignCommitment `json:"commitments,omitempty"`
+	Partial     []byte            `json:"partial,omitempty"`
+	Error       string            `json:"error,omitempty"`
+}
+
+// tsignCommitment is a NonceCommitment on the wire
+type tsignCommitment struct {
+	Index int    `json:"index"`
+	D     []byte `json:"d"`
+	E     []byte `json:"e"`
+}
+
+// ThresholdSignature is a group signature and the members that made it
+type ThresholdSignature struct {
+	GroupKey  []byte
+	Signature []byte
+	Signers   []string
+}
+
+type tsignNonce struct {
+	nonce   *kyberdkg.SigningNonce
+	created time.Time
+}
+
+// ThresholdSigner produces threshold Schnorr signatures with group keys
+// generated by NetworkDKG. Any threshold members can sign; members only
+// work for other members.
+type ThresholdSigner struct {
+	host   host.Host
+	dkg    *NetworkDKG
+	nonces map[string]map[string]*tsignNonce // Committed nonces by group and signing session
+	mu     sync.Mutex
+}
+
+// NewThresholdSigner creates the service and registers its protocol handler
+func NewThresholdSigner(h host.Host, dkg *NetworkDKG) *ThresholdSigner {
+	ts := &ThresholdSigner{host: h, dkg: dkg, nonces: make(map[string]map[string]*tsignNonce)}
+	h.SetStreamHandler(protocol.ID(ThresholdSignProtocolID), ts.handleStream)
+	return ts
+}
+
+// CreateGroup runs key generation among peers, this node included, and
+// returns the group key. Any threshold of the members can then sign.
+func (ts *ThresholdSigner) CreateGroup(ctx context.Context, groupID string, peers []peer.ID, threshold int) ([]byte, error) {
+	key, err := ts.dkg.Run(ctx, groupID, peers, threshold)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("✍️  [TSIGN] Group %s ready: %d of %d members sign", groupID, threshold, len(key.Participants))
+	return key.Share.GroupKey.MarshalBinary()
+}
+
+// Sign produces a threshold signature over payload for purpose. Members
+// that do not answer or return invalid partials are replaced by others
+// until the threshold is met or no members are left.
+func (ts *ThresholdSigner) Sign(ctx context.Context, groupID, purpose string, payload []byte) (*ThresholdSignature, error) {
+	key, ok := ts.dkg.Key(groupID)
+	if !ok {
+		return nil, fmt.Errorf("not a member of signing group %s", groupID)
+	}
+	share, participants := key.Share, key.Participants
+	if purpose == "" {
+		return nil, errors.New("signature purpose required")
+	}
+	msg := thresholdSigningMessage(groupID, purpose, payload)
+
+	// This node first, then the others in order
+	candidates := []int{share.Index}
+	for i := range participants {
+		if i+1 != share.Index {
+			candidates = append(candidates, i+1)
+		}
+	}
+
+	for len(candidates) >= share.Threshold {
+		sessionID := generateSessionID()
+
+		// Round 1: collect nonce commitments from the first members to answer
+		var commitments []kyberdkg.NonceCommitment
+		var signers []int
+		for len(signers) < share.Threshold && len(candidates) > 0 {
+			idx := candidates[0]
+			candidates = candidates[1:]
+			reply, err := ts.call(ctx, participants[idx-1], tsignFrame{Type: tsignFrameCommit, GroupID: groupID, SessionID: sessionID})
+			if err != nil {
+				log.Printf("⚠️  [TSIGN] Member %s did not commit: %v", shortPeerID(participants[idx-1]), err)
+				continue
+			}
+			c, err := decodeCommitment(reply.Commitments)
+			if err != nil || c.Index != idx {
+				log.Printf("⚠️  [TSIGN] Member %s sent a bad commitment", shortPeerID(participants[idx-1]))
+				continue
+			}
+			commitments = append(commitments, c)
+			signers = append(signers, idx)
+		}
+		if len(signers) < share.Threshold {
+			break
+		}
+
+		// Round 2: every committed member signs; a bad partial drops that
+		// member and the session starts over with the others
+		wire := encodeCommitments(commitments)
+		partials := make(map[int]kyber.Scalar, len(signers))
+		var failed []int
+		for _, idx := range signers {
+			reply, err := ts.call(ctx, participants[idx-1], tsignFrame{
+				Type: tsignFrameSign, GroupID: groupID, SessionID: sessionID,
+				Purpose: purpose, Payload: payload, Commitments: wire,
+			})
+			var z kyber.Scalar
+			if err == nil {
+				z, err = kyberdkg.ScalarFromBytes(reply.Partial)
+			}
+			if err == nil {
+				err = share.VerifyPartial(idx, z, msg, commitments)
+			}
+			if err != nil {
+				log.Printf("⚠️  [TSIGN] Member %s did not sign: %v", shortPeerID(participants[idx-1]), err)
+				failed = append(failed, idx)
+				continue
+			}
+			partials[idx] = z
+		}
+		if len(failed) > 0 {
+			for _, idx := range signers {
+				if !slices.Contains(failed, idx) {
+					candidates = append(candidates, idx)
+				}
+			}
+			continue
+		}
+
+		sig, err := share.AggregateSignature(msg, commitments, partials)
+		if err != nil {
+			return nil, err
+		}
+		groupKey, err := share.GroupKey.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		out := &ThresholdSignature{GroupKey: groupKey, Signature: sig}
+		for _, idx := range signers {
+			out.Signers = append(out.Signers, participants[idx-1].String())
+		}
+		log.Printf("✍️  [TSIGN] Signed %s for group %s with %d members", purpose, groupID, len(signers))
+		return out, nil
+	}
+	return nil, fmt.Errorf("fewer than %d members of group %s could sign", share.Threshold, groupID)
+}
+
+// GroupKey returns a group's public key if this node is a member
+func (ts *ThresholdSigner) GroupKey(groupID string) ([]byte, bool) {
+	key, ok := ts.dkg.Key(groupID)
+	if !ok {
+		return nil, false
+	}
+	groupKey, err := key.Share.GroupKey.MarshalBinary()
+	return groupKey, err == nil
+}
+
+// VerifyThresholdSignature checks a signature over payload for purpose
+// against a group key
+func VerifyThresholdSignature(groupID string, groupKey []byte, purpose string, payload, sig []byte) error {
+	key, err := kyberdkg.PointFromBytes(groupKey)
+	if err != nil {
+		return fmt.Errorf("invalid group key: %w", err)
+	}
+	return kyberdkg.VerifySignature(key, thresholdSigningMessage(groupID, purpose, payload), sig)
+}
+
+// thresholdSigningMessage binds a payload to its group and purpose
+func thresholdSigningMessage(groupID, purpose string, payload []byte) []byte {
+	digest := sha256.Sum256(payload)
+	msg := []byte("pangea-tsign-v1")
+	for _, field := range []string{groupID, purpose} {
+		msg = binary.BigEndian.AppendUint16(msg, uint16(len(field)))
+		msg = append(msg, field...)
+	}
+	return append(msg, digest[:]...)
+}
+
+// call sends a frame to a member and waits for its reply. Frames for this
+// node are handled in place.
+func (ts *ThresholdSigner) call(ctx context.Context, p peer.ID, frame tsignFrame) (tsignFrame, error) {
+	var reply tsignFrame
+	if p == ts.host.ID() {
+		reply = ts.handle(p, &frame)
+	} else {
+		ctx, cancel := context.WithTimeout(ctx, tsignTimeout)
+		defer cancel()
+		s, err := ts.host.NewStream(ctx, p, protocol.ID(ThresholdSignProtocolID))
+		if err != nil {
+			return reply, fmt.Errorf("failed to open signing stream: %w", err)
+		}
+		defer s.Close()
+		s.SetDeadline(time.Now().Add(tsignTimeout))
+		if err := json.NewEncoder(s).Encode(frame); err != nil {
+			return reply, err
+		}
+		if err := json.NewDecoder(s).Decode(&reply); err != nil {
+			return reply, fmt.Errorf("no reply: %w", err)
+		}
+	}
+	if reply.Error != "" {
+		return reply, errors.New(reply.Error)
+	}
+	return reply, nil
+}
+
+// handleStream answers one request from another member
+func (ts *ThresholdSigner) handleStream(s network.Stream) {
+	defer s.Close()
+	s.SetDeadline(time.Now().Add(tsignTimeout))
+
+	var frame tsignFrame
+	if err := json.NewDecoder(s).Decode(&frame); err != nil {
+		return
+	}
+	reply := ts.handle(s.Conn().RemotePeer(), &frame)
+	json.NewEncoder(s).Encode(reply)
+}
+
+// handle processes a request from remote, which may be this node
+func (ts *ThresholdSigner) handle(remote peer.ID, frame *tsignFrame) tsignFrame {
+	var reply tsignFrame
+	var err error
+	switch frame.Type {
+	case tsignFrameCommit:
+		reply, err = ts.signCommit(remote, frame)
+	case tsignFrameSign:
+		reply, err = ts.signPartial(remote, frame)
+	default:
+		err = fmt.Errorf("unknown frame type %q", frame.Type)
+	}
+	reply.Type, reply.GroupID = tsignFrameReply, frame.GroupID
+	if err != nil {
+		reply.Error = err.Error()
+	}
+	return reply
+}
+
+// memberKey returns this node's key share for a group remote belongs to
+func (ts *ThresholdSigner) memberKey(groupID string, remote peer.ID) (*DKGKey, error) {
+	key, ok := ts.dkg.Key(groupID)
+	if !ok {
+		return nil, fmt.Errorf("not a member of signing group %s", groupID)
+	}
+	if !slices.Contains(key.Participants, remote) {
+		return nil, fmt.Errorf("peer is not a member of signing group %s", groupID)
+	}
+	return key, nil
+}
+
+// signCommit commits to a fresh nonce for a signing session
+func (ts *ThresholdSigner) signCommit(remote peer.ID, frame *tsignFrame) (tsignFrame, error) {
+	key, err := ts.memberKey(frame.GroupID, remote)
+	if err != nil {
+		return tsignFrame{}, err
+	}
+	if frame.SessionID == "" {
+		return tsignFrame{}, errors.New("session ID required")
+	}
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	nonces := ts.nonces[frame.GroupID]
+	if nonces == nil {
+		nonces = make(map[string]*tsignNonce)
+		ts.nonces[frame.GroupID] = nonces
+	}
+	for id, n := range nonces {
+		if time.Since(n.created) > tsignNonceTTL {
+			delete(nonces, id)
+		}
+	}
+	if _, ok := nonces[frame.SessionID]; ok {
+		return tsignFrame{}, errors.New("session already committed")
+	}
+	nonce := kyberdkg.NewSigningNonce(key.Share.Index)
+	nonces[frame.SessionID] = &tsignNonce{nonce: nonce, created: time.Now()}
+	return tsignFrame{Commitments: encodeCommitments([]kyberdkg.NonceCommitment{nonce.Commitment})}, nil
+}
+
+// signPartial signs with the nonce committed for the session, which is
+// then discarded
+func (ts *ThresholdSigner) signPartial(remote peer.ID, frame *tsignFrame) (tsignFrame, error) {
+	key, err := ts.memberKey(frame.GroupID, remote)
+	if err != nil {
+		return tsignFrame{}, err
+	}
+	ts.mu.Lock()
+	n, ok := ts.nonces[frame.GroupID][frame.SessionID]
+	delete(ts.nonces[frame.GroupID], frame.SessionID)
+	ts.mu.Unlock()
+	if !ok {
+		return tsignFrame{}, errors.New("no nonce committed for this session")
+	}
+	if frame.Purpose == "" {
+		return tsignFrame{}, errors.New("signature purpose required")
+	}
+
+	commitments := make([]kyberdkg.NonceCommitment, 0, len(frame.Commitments))
+	for _, c := range frame.Commitments {
+		decoded, err := decodeCommitment([]tsignCommitment{c})
+		if err != nil {
+			return tsignFrame{}, err
+		}
+		commitments = append(commitments, decoded)
+	}
+	msg := thresholdSigningMessage(frame.GroupID, frame.Purpose, frame.Payload)
+	z, err := key.Share.PartialSign(n.nonce, msg, commitments)
+	if err != nil {
+		return tsignFrame{}, err
+	}
+	partial, err := z.MarshalBinary()
+	if err != nil {
+		return tsignFrame{}, err
+	}
+	log.Printf("✍️  [TSIGN] Signed %s for group %s at the request of %s", frame.Purpose, frame.GroupID, shortPeerID(remote))
+	return tsignFrame{Partial: partial}, nil
+}
+
+func encodeCommitments(commitments []kyberdkg.NonceCommitment) []tsignCommitment {
+	out := make([]tsignCommitment, len(commitments))
+	for i, c := range commitments {
+		d, _ := c.D.MarshalBinary()
+		e, _ := c.E.MarshalBinary()
+		out[i] = tsignCommitment{Index: c.Index, D: d, E: e}
+	}
+	return out
+}
+
+// decodeCommitment decodes the single commitment in a reply
+func decodeCommitment(wire []tsignCommitment) (kyberdkg.NonceCommitment, error) {
+	if len(wire) != 1 {
+		return kyberdkg.NonceCommitment{}, errors.New("expected one nonce commitment")
+	}
+	d, err := kyberdkg.PointFromBytes(wire[0].D)
+	if err != nil {
+		return kyberdkg.NonceCommitment{}, err
+	}
+	e, err := kyberdkg.PointFromBytes(wire[0].E)
+	if err != nil {
+		return kyberdkg.NonceCommitment{}, err
+	}
+	return kyberdkg.NonceCommitment{Index: wire[0].Index, D: d, E: e}, nil
+}
+
+// generateSessionID names a signing session
+func generateSessionID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestThresholdSigningGroup(t *testing.T) {
+	var nodes []*LibP2PPangeaNode
+	for i := 0; i < 4; i++ {
+		n, err := NewLibP2PPangeaNodeWithOptions(uint32(570+i), NewNodeStore(), false, true, 12570+i)
+		if err != nil {
+			t.Fatalf("failed to create node %d: %v", i, err)
+		}
+		t.Cleanup(n.cancel)
+		nodes = append(nodes, n)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	for i, a := range nodes {
+		for _, b := range nodes[i+1:] {
+			if err := a.host.Connect(ctx, peer.AddrInfo{ID: b.host.ID(), Addrs: b.host.Addrs()}); err != nil {
+				t.Fatalf("connect failed: %v", err)
+			}
+		}
+	}
+
+	// Three members, any two of which sign; the fourth node is an outsider
+	members := []peer.ID{nodes[1].host.ID(), nodes[2].host.ID()}
+	groupKey, err := nodes[0].GetThresholdSigner().CreateGroup(ctx, "release", members, 2)
+	if err != nil {
+		t.Fatalf("CreateGroup failed: %v", err)
+	}
+	if key, ok := nodes[2].GetThresholdSigner().GroupKey("release"); !ok || string(key) != string(groupKey) {
+		t.Fatal("member does not hold the same group key")
+	}
+
+	manifest := []byte(`{"file":"abc","shards":12}`)
+	sig, err := nodes[0].GetThresholdSigner().Sign(ctx, "release", SignPurposeManifest, manifest)
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	if len(sig.Signers) != 2 || string(sig.GroupKey) != string(groupKey) {
+		t.Fatalf("unexpected signature %+v", sig)
+	}
+	if err := VerifyThresholdSignature("release", groupKey, SignPurposeManifest, manifest, sig.Signature); err != nil {
+		t.Fatalf("signature does not verify: %v", err)
+	}
+	if err := VerifyThresholdSignature("release", groupKey, SignPurposeJobResult, manifest, sig.Signature); err == nil {
+		t.Fatal("signature verified for another purpose")
+	}
+
+	// The outsider can neither sign nor get members to sign for it
+	if _, err := nodes[3].GetThresholdSigner().Sign(ctx, "release", SignPurposeManifest, manifest); err == nil {
+		t.Fatal("outsider signed for the group")
+	}
+	reply, err := nodes[3].GetThresholdSigner().call(ctx, nodes[1].host.ID(), tsignFrame{Type: tsignFrameCommit, GroupID: "release", SessionID: "x"})
+	if err == nil || reply.Commitments != nil {
+		t.Fatal("member committed a nonce for an outsider")
+	}
+
+	// With one member gone the other two still sign
+	nodes[1].cancel()
+	nodes[1].host.Close()
+	sig, err = nodes[2].GetThresholdSigner().Sign(ctx, "release", SignPurposeJobResult, []byte("result"))
+	if err != nil {
+		t.Fatalf("Sign without a member failed: %v", err)
+	}
+	if err := VerifyThresholdSignature("release", groupKey, SignPurposeJobResult, []byte("result"), sig.Signature); err != nil {
+		t.Fatalf("signature does not verify: %v", err)
+	}
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// tracerName is the instrumentation scope of the node's spans
+	tracerName = "github.com/pangea-net/go-node"
+
+	// spanQueueSize bounds finished spans waiting for export; spans beyond
+	// it are dropped
+	spanQueueSize = 2048
+
+	// spanBatchSize is the most spans sent in one export request
+	spanBatchSize = 512
+
+	// spanExportInterval is how often queued spans are exported
+	spanExportInterval = 5 * time.Second
+)
+
+// tracer starts the node's spans. Until InitTracing installs an exporter it
+// creates non-recording spans that still carry a remote parent's context, so
+// trace context passes through nodes that do not export.
+var tracer = otel.Tracer(tracerName)
+
+// traceContext propagates W3C traceparent/tracestate headers
+var traceContext = propagation.TraceContext{}
+
+// InitTracing exports spans as OTLP/HTTP JSON to endpoint (for example
+// http://localhost:4318). The returned function flushes queued spans and
+// stops the exporter.
+func InitTracing(endpoint, serviceName string, attrs ...attribute.KeyValue) (func(context.Context) error, error) {
+	if !strings.HasPrefix(endpoint, "http://") && !strings.HasPrefix(endpoint, "https://") {
+		return nil, fmt.Errorf("OTLP endpoint %q must be an http:// or https:// URL", endpoint)
+	}
+	url := strings.TrimSuffix(endpoint, "/")
+	if !strings.HasSuffix(url, "/v1/traces") {
+		url += "/v1/traces"
+	}
+
+	res := resource.NewSchemaless(append([]attribute.KeyValue{attribute.String("service.name", serviceName)}, attrs...)...)
+	provider := newTracerProvider(newOTLPExporter(url), res)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(traceContext)
+	return provider.Shutdown, nil
+}
+
+// newTracerProvider batches the spans of res to exporter
+func newTracerProvider(exporter sdktrace.SpanExporter, res *resource.Resource) *sdktrace.TracerProvider {
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithResource(res),
+		sdktrace.WithBatcher(exporter,
+			sdktrace.WithMaxQueueSize(spanQueueSize),
+			sdktrace.WithMaxExportBatchSize(spanBatchSize),
+			sdktrace.WithBatchTimeout(spanExportInterval),
+		),
+	)
+}
+
+// injectTraceContext returns the trace context of ctx's span as headers, or
+// nil if ctx carries no span
+func injectTraceContext(ctx context.Context) map[string]string {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return nil
+	}
+	carrier := propagation.MapCarrier{}
+	traceContext.Inject(ctx, carrier)
+	return carrier
+}
+
+// extractTraceContext returns ctx with the remote span context in headers,
+// if any
+func extractTraceContext(ctx context.Context, headers map[string]string) context.Context {
+	if len(headers) == 0 {
+		return ctx
+	}
+	return traceContext.Extract(ctx, propagation.MapCarrier(headers))
+}
+
+// endSpan records err, if any, on span and ends it
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// ============================================================
+// OTLP Export
+// ============================================================
+
+// otlpExporter is an sdktrace.SpanExporter that posts spans as OTLP/HTTP
+// JSON. It stands in for otlptracehttp, which pulls in gRPC and genproto
+// packages that conflict with the versions vault pins for this module.
+type otlpExporter struct {
+	url    string
+	client *http.Client
+}
+
+func newOTLPExporter(url string) *otlpExporter {
+	return &otlpExporter{url: url, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// ExportSpans implements sdktrace.SpanExporter
+func (e *otlpExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	if len(spans) == 0 {
+		return nil
+	}
+	// Group spans by resource, then by instrumentation scope
+	type scopeKey struct {
+		resource int
+		scope    instrumentation.Scope
+	}
+	var req otlpTraceRequest
+	resources := make(map[attribute.Distinct]int)
+	scopes := make(map[scopeKey]int)
+	for _, s := range spans {
+		res := s.Resource()
+		ri, ok := resources[res.Equivalent()]
+		if !ok {
+			ri = len(req.ResourceSpans)
+			resources[res.Equivalent()] = ri
+			req.ResourceSpans = append(req.ResourceSpans, otlpResourceSpans{Resource: otlpResource{Attributes: otlpAttributes(res.Attributes())}})
+		}
+		rs := &req.ResourceSpans[ri]
+		key := scopeKey{ri, s.InstrumentationScope()}
+		si, ok := scopes[key]
+		if !ok {
+			si = len(rs.ScopeSpans)
+			scopes[key] = si
+			rs.ScopeSpans = append(rs.ScopeSpans, otlpScopeSpans{Scope: otlpScope{Name: key.scope.Name}})
+		}
+		rs.ScopeSpans[si].Spans = append(rs.ScopeSpans[si].Spans, otlpEncodeSpan(s))
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp, err := e.client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("collector returned %s", resp.Status)
+	}
+	return nil
+}
+
+// Shutdown implements sdktrace.SpanExporter
+func (e *otlpExporter) Shutdown(ctx context.Context) error {
+	e.client.CloseIdleConnections()
+	return nil
+}
+
+// OTLP/HTTP JSON encoding of ExportTraceServiceRequest
+type otlpTraceRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	TraceState        string         `json:"traceState,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Events            []otlpEvent    `json:"events,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpEvent struct {
+	TimeUnixNano string         `json:"timeUnixNano"`
+	Name         string         `json:"name"`
+	Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"` // 0 unset, 1 ok, 2 error
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string         `json:"key"`
+	Value map[string]any `json:"value"`
+}
+
+// otlpEncodeSpan encodes a finished span
+func otlpEncodeSpan(s sdktrace.ReadOnlySpan) otlpSpan {
+	sc := s.SpanContext()
+	span := otlpSpan{
+		TraceID:           sc.TraceID().String(),
+		SpanID:            sc.SpanID().String(),
+		TraceState:        sc.TraceState().String(),
+		Name:              s.Name(),
+		Kind:              int(s.SpanKind()), // trace.SpanKind values match OTLP's
+		StartTimeUnixNano: strconv.FormatInt(s.StartTime().UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(s.EndTime().UnixNano(), 10),
+		Attributes:        otlpAttributes(s.Attributes()),
+	}
+	if parent := s.Parent(); parent.IsValid() {
+		span.ParentSpanID = parent.SpanID().String()
+	}
+	if span.Kind == int(trace.SpanKindUnspecified) {
+		span.Kind = int(trace.SpanKindInternal)
+	}
+	for _, ev := range s.Events() {
+		span.Events = append(span.Events, otlpEvent{
+			TimeUnixNano: strconv.FormatInt(ev.Time.UnixNano(), 10),
+			Name:         ev.Name,
+			Attributes:   otlpAttributes(ev.Attributes),
+		})
+	}
+	switch status := s.Status(); status.Code {
+	case codes.Ok:
+		span.Status.Code = 1
+	case codes.Error:
+		span.Status = otlpStatus{Code: 2, Message: status.Description}
+	}
+	return span
+}
+
+// otlpAttributes encodes attributes as OTLP AnyValues
+func otlpAttributes(attrs []attribute.KeyValue) []otlpKeyValue {
+	out := make([]otlpKeyValue, 0, len(attrs))
+	for _, kv := range attrs {
+		var value map[string]any
+		switch kv.Value.Type() {
+		case attribute.BOOL:
+			value = map[string]any{"boolValue": kv.Value.AsBool()}
+		case attribute.INT64:
+			value = map[string]any{"intValue": strconv.FormatInt(kv.Value.AsInt64(), 10)}
+		case attribute.FLOAT64:
+			value = map[string]any{"doubleValue": kv.Value.AsFloat64()}
+		default:
+			value = map[string]any{"stringValue": kv.Value.Emit()}
+		}
+		out = append(out, otlpKeyValue{Key: string(kv.Key), Value: value})
+	}
+	return out
+}
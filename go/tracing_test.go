@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracingPropagatesAndExports(t *testing.T) {
+	var mu sync.Mutex
+	var spans []otlpSpan
+	var res []otlpKeyValue
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		var req otlpTraceRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		for _, rs := range req.ResourceSpans {
+			res = rs.Resource.Attributes
+			for _, ss := range rs.ScopeSpans {
+				spans = append(spans, ss.Spans...)
+			}
+		}
+	}))
+	defer collector.Close()
+
+	provider := newTracerProvider(newOTLPExporter(collector.URL+"/v1/traces"), resource.NewSchemaless(attribute.String("service.name", "test-node")))
+	tr := provider.Tracer(tracerName)
+
+	// Submitter: the RPC span's context travels as headers
+	ctx, root := tr.Start(context.Background(), "NodeService.submitComputeJob", trace.WithSpanKind(trace.SpanKindServer))
+	headers := injectTraceContext(ctx)
+	if headers["traceparent"] == "" {
+		t.Fatalf("expected a traceparent header, got %v", headers)
+	}
+	root.End()
+
+	// Worker: a fresh context continues the trace from the headers
+	_, child := tr.Start(extractTraceContext(context.Background(), headers), "compute.execute",
+		trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(attribute.Int("compute.input_bytes", 42)))
+	endSpan(child, errors.New("wasm trap"))
+
+	if injectTraceContext(context.Background()) != nil {
+		t.Fatal("expected no headers without a span")
+	}
+	if err := provider.Shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 exported spans, got %d", len(spans))
+	}
+	rootSpan, childSpan := spans[0], spans[1]
+	if childSpan.TraceID != rootSpan.TraceID || childSpan.ParentSpanID != rootSpan.SpanID {
+		t.Fatalf("child not linked to root: %+v %+v", rootSpan, childSpan)
+	}
+	if rootSpan.ParentSpanID != "" || rootSpan.Kind != int(trace.SpanKindServer) || rootSpan.Status.Code != 0 {
+		t.Fatalf("unexpected root span: %+v", rootSpan)
+	}
+	if childSpan.Status.Code != 2 || childSpan.Status.Message != "wasm trap" || len(childSpan.Events) != 1 {
+		t.Fatalf("expected error status and exception event: %+v", childSpan)
+	}
+	if len(childSpan.Attributes) != 1 || childSpan.Attributes[0].Value["intValue"] != "42" {
+		t.Fatalf("unexpected attributes: %+v", childSpan.Attributes)
+	}
+	if len(res) != 1 || res[0].Value["stringValue"] != "test-node" {
+		t.Fatalf("unexpected resource: %+v", res)
+	}
+}
+
+func TestInitTracingRejectsBadEndpoint(t *testing.T) {
+	if _, err := InitTracing("localhost:4318", "test-node"); err == nil {
+		t.Fatal("expected an error for an endpoint without a scheme")
+	}
+}
@@ -20,9 +20,12 @@ type LocalNode struct {
 	Status      NodeState
 	LatencyMs   float32
 	ThreatScore float32
-	JitterMs    float32 // Latency variance
-	PacketLoss  float32 // Packet loss percentage (0.0-1.0)
-	LastSeen    int64   // Unix timestamp of last successful ping
+	JitterMs    float32     // Latency variance
+	PacketLoss  float32     // Packet loss percentage (0.0-1.0)
+	LastSeen    int64       // Unix timestamp of last successful ping
+	StorageRole StorageRole // Whether the node accepts new shards
+	PeerID      string      // libp2p peer that announced this node, if gossiped
+	UpdatedAt   int64       // Unix milliseconds of the newest gossiped state, 0 = local only
 	mu          sync.RWMutex
 }
 
@@ -126,6 +129,45 @@ func (ns *NodeStore) UpdateThreatScore(nodeID uint32, threatScore float32) bool
 	return true
 }
 
+// UpdateStorageRole records whether a node's storage is read-only
+func (ns *NodeStore) UpdateStorageRole(nodeID uint32, role StorageRole) bool {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	node, exists := ns.nodes[nodeID]
+	if !exists {
+		return false
+	}
+	node.mu.Lock()
+	defer node.mu.Unlock()
+	node.StorageRole = role
+	return true
+}
+
+// MergeRemoteState applies a node's gossiped state if it is newer than the
+// stored one, creating the node if needed. It reports whether it applied.
+func (ns *NodeStore) MergeRemoteState(state *LocalNode) bool {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	node, exists := ns.nodes[state.ID]
+	if !exists {
+		node = &LocalNode{ID: state.ID}
+		ns.nodes[state.ID] = node
+	}
+	node.mu.Lock()
+	defer node.mu.Unlock()
+	if state.UpdatedAt <= node.UpdatedAt {
+		return false
+	}
+	node.Status = state.Status
+	node.LatencyMs = state.LatencyMs
+	node.ThreatScore = state.ThreatScore
+	node.StorageRole = state.StorageRole
+	node.PeerID = state.PeerID
+	node.UpdatedAt = state.UpdatedAt
+	node.LastSeen = state.UpdatedAt / 1000
+	return true
+}
+
 // CreateNode creates a new node
 func (ns *NodeStore) CreateNode(id uint32) *LocalNode {
 	node := &LocalNode{
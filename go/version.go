@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+const (
+	// NodeVersion is the semantic version of this node, advertised to peers
+	// through the libp2p identify handshake
+	NodeVersion = "0.1.0"
+
+	// nodeAgentPrefix prefixes NodeVersion in the identify agent string
+	nodeAgentPrefix = "pangea-go-node/"
+
+	// outdatedMinorLag is how many minor versions a compatible peer may fall
+	// behind before it is reported as outdated
+	outdatedMinorLag = 2
+)
+
+// NodeAgentVersion returns the identify agent string for this node
+func NodeAgentVersion() string {
+	return nodeAgentPrefix + NodeVersion
+}
+
+// SemVer is a parsed MAJOR.MINOR.PATCH version
+type SemVer struct {
+	Major int
+	Minor int
+	Patch int
+}
+
+// ParseSemVer parses "1.2.3", "v1.2.3", or "1.2.3-rc1" (pre-release and
+// build metadata are ignored)
+func ParseSemVer(s string) (SemVer, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if i := strings.IndexAny(s, "-+"); i >= 0 {
+		s = s[:i]
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return SemVer{}, fmt.Errorf("invalid semantic version %q", s)
+	}
+
+	var nums [3]int
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return SemVer{}, fmt.Errorf("invalid semantic version %q", s)
+		}
+		nums[i] = n
+	}
+	return SemVer{Major: nums[0], Minor: nums[1], Patch: nums[2]}, nil
+}
+
+func (v SemVer) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// VersionStatus classifies a peer's version relative to ours
+type VersionStatus int
+
+const (
+	VersionUnknown VersionStatus = iota
+	VersionCompatible
+	VersionOutdated
+	VersionIncompatible
+)
+
+func (s VersionStatus) String() string {
+	switch s {
+	case VersionCompatible:
+		return "compatible"
+	case VersionOutdated:
+		return "outdated"
+	case VersionIncompatible:
+		return "incompatible"
+	default:
+		return "unknown"
+	}
+}
+
+// CheckVersionCompatibility compares a remote version against the local one.
+// Versions are incompatible when the major version differs (or, before 1.0,
+// when the minor version differs); a compatible peer more than
+// outdatedMinorLag minor versions behind is outdated.
+func CheckVersionCompatibility(local, remote SemVer) (VersionStatus, string) {
+	if local.Major != remote.Major || (local.Major == 0 && local.Minor != remote.Minor) {
+		return VersionIncompatible, fmt.Sprintf("peer runs %s, incompatible with local %s", remote, local)
+	}
+	if local.Minor-remote.Minor >= outdatedMinorLag {
+		return VersionOutdated, fmt.Sprintf("peer runs %s, %d minor versions behind local %s",
+			remote, local.Minor-remote.Minor, local)
+	}
+	return VersionCompatible, ""
+}
+
+// PeerVersionInfo is the version a peer advertised during identify
+type PeerVersionInfo struct {
+	PeerID       peer.ID
+	AgentVersion string
+	Version      string // Parsed semantic version, empty if not a Pangea node
+	Status       VersionStatus
+	Warning      string
+	SeenAt       time.Time
+}
+
+// VersionTracker records the versions advertised by connected peers
+type VersionTracker struct {
+	local SemVer
+	peers map[peer.ID]PeerVersionInfo
+	mu    sync.RWMutex
+}
+
+// NewVersionTracker creates a tracker comparing peers against localVersion
+func NewVersionTracker(localVersion string) *VersionTracker {
+	local, err := ParseSemVer(localVersion)
+	if err != nil {
+		log.Printf("⚠️  [VERSION] Invalid local version: %v", err)
+	}
+	return &VersionTracker{
+		local: local,
+		peers: make(map[peer.ID]PeerVersionInfo),
+	}
+}
+
+// Watch records peer versions as identify completes until ctx is cancelled
+func (vt *VersionTracker) Watch(ctx context.Context, bus event.Bus) error {
+	sub, err := bus.Subscribe(new(event.EvtPeerIdentificationCompleted))
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to identify events: %w", err)
+	}
+
+	go func() {
+		defer sub.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case e, ok := <-sub.Out():
+				if !ok {
+					return
+				}
+				evt := e.(event.EvtPeerIdentificationCompleted)
+				vt.Observe(evt.Peer, evt.AgentVersion)
+			}
+		}
+	}()
+	return nil
+}
+
+// Observe records the agent string a peer advertised and warns if its
+// version is incompatible or outdated
+func (vt *VersionTracker) Observe(p peer.ID, agentVersion string) PeerVersionInfo {
+	info := PeerVersionInfo{
+		PeerID:       p,
+		AgentVersion: agentVersion,
+		SeenAt:       time.Now(),
+	}
+	if strings.HasPrefix(agentVersion, nodeAgentPrefix) {
+		if v, err := ParseSemVer(strings.TrimPrefix(agentVersion, nodeAgentPrefix)); err == nil {
+			info.Version = v.String()
+			info.Status, info.Warning = CheckVersionCompatibility(vt.local, v)
+		}
+	}
+
+	vt.mu.Lock()
+	prev, seen := vt.peers[p]
+	vt.peers[p] = info
+	vt.mu.Unlock()
+
+	if info.Warning != "" && (!seen || prev.Version != info.Version) {
+		log.Printf("⚠️  [VERSION] %s: %s", shortPeerID(p), info.Warning)
+	}
+	return info
+}
+
+// Forget drops the version record for a disconnected peer
+func (vt *VersionTracker) Forget(p peer.ID) {
+	vt.mu.Lock()
+	defer vt.mu.Unlock()
+	delete(vt.peers, p)
+}
+
+// Get returns the recorded version info for a peer
+func (vt *VersionTracker) Get(p peer.ID) (PeerVersionInfo, bool) {
+	vt.mu.RLock()
+	defer vt.mu.RUnlock()
+	info, exists := vt.peers[p]
+	return info, exists
+}
+
+// Snapshot returns version info for all tracked peers
+func (vt *VersionTracker) Snapshot() []PeerVersionInfo {
+	vt.mu.RLock()
+	defer vt.mu.RUnlock()
+	out := make([]PeerVersionInfo, 0, len(vt.peers))
+	for _, info := range vt.peers {
+		out = append(out, info)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].PeerID < out[j].PeerID })
+	return out
+}
+
+// VersionCounts returns the number of tracked peers per version
+// ("unknown" for peers that are not Pangea nodes)
+func (vt *VersionTracker) VersionCounts() map[string]int {
+	vt.mu.RLock()
+	defer vt.mu.RUnlock()
+	counts := make(map[string]int)
+	for _, info := range vt.peers {
+		version := info.Version
+		if version == "" {
+			version = "unknown"
+		}
+		counts[version]++
+	}
+	return counts
+}
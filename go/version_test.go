@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestParseSemVer(t *testing.T) {
+	v, err := ParseSemVer("v1.4.2-rc1")
+	if err != nil {
+		t.Fatalf("ParseSemVer failed: %v", err)
+	}
+	if v != (SemVer{Major: 1, Minor: 4, Patch: 2}) {
+		t.Errorf("unexpected version %s", v)
+	}
+
+	for _, bad := range []string{"", "1.2", "1.x.3", "1.2.3.4"} {
+		if _, err := ParseSemVer(bad); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestCheckVersionCompatibility(t *testing.T) {
+	local := SemVer{Major: 1, Minor: 5, Patch: 0}
+	cases := []struct {
+		remote SemVer
+		want   VersionStatus
+	}{
+		{SemVer{1, 5, 3}, VersionCompatible},
+		{SemVer{1, 4, 0}, VersionCompatible},
+		{SemVer{1, 2, 0}, VersionOutdated},
+		{SemVer{2, 0, 0}, VersionIncompatible},
+	}
+	for _, c := range cases {
+		if got, _ := CheckVersionCompatibility(local, c.remote); got != c.want {
+			t.Errorf("remote %s: expected %s, got %s", c.remote, c.want, got)
+		}
+	}
+
+	// Before 1.0 a minor bump is a breaking change
+	if got, _ := CheckVersionCompatibility(SemVer{0, 2, 0}, SemVer{0, 1, 9}); got != VersionIncompatible {
+		t.Errorf("expected 0.1.9 to be incompatible with 0.2.0, got %s", got)
+	}
+}
+
+func TestVersionTrackerCounts(t *testing.T) {
+	vt := NewVersionTracker("1.5.0")
+	vt.Observe(peer.ID("a"), nodeAgentPrefix+"1.5.0")
+	vt.Observe(peer.ID("b"), nodeAgentPrefix+"1.5.0")
+	old := vt.Observe(peer.ID("c"), nodeAgentPrefix+"1.1.0")
+	vt.Observe(peer.ID("d"), "kubo/0.30.0")
+
+	if old.Status != VersionOutdated || old.Warning == "" {
+		t.Errorf("expected outdated warning for 1.1.0, got %s %q", old.Status, old.Warning)
+	}
+
+	counts := vt.VersionCounts()
+	if counts["1.5.0"] != 2 || counts["1.1.0"] != 1 || counts["unknown"] != 1 {
+		t.Errorf("unexpected version counts: %v", counts)
+	}
+
+	vt.Forget(peer.ID("c"))
+	if _, ok := vt.Get(peer.ID("c")); ok {
+		t.Error("expected forgotten peer to be removed")
+	}
+}
+
+func TestPeerVersionExchangedOnConnect(t *testing.T) {
+	store1 := NewNodeStore()
+	n1, err := NewLibP2PPangeaNodeWithOptions(451, store1, false, true, 12450)
+	if err != nil {
+		t.Fatalf("failed to create node1: %v", err)
+	}
+	defer n1.cancel()
+
+	store2 := NewNodeStore()
+	n2, err := NewLibP2PPangeaNodeWithOptions(452, store2, false, true, 12451)
+	if err != nil {
+		t.Fatalf("failed to create node2: %v", err)
+	}
+	defer n2.cancel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := n1.host.Connect(ctx, peer.AddrInfo{ID: n2.host.ID(), Addrs: n2.host.Addrs()}); err != nil {
+		t.Fatalf("connect n1->n2 failed: %v", err)
+	}
+
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		if info, ok := n1.GetVersionTracker().Get(n2.host.ID()); ok {
+			if info.Version != NodeVersion || info.Status != VersionCompatible {
+				t.Errorf("expected compatible %s, got %s (%s)", NodeVersion, info.Version, info.Status)
+			}
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatal("peer version not recorded after identify")
+}
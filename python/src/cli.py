@@ -557,17 +557,13 @@ def history(host, port, peer, limit, schema):
     click.echo("\n💬 Chat History")
     click.echo("=" * 60)
 
-    # Get chat history via RPC or local file
-    messages = client.get_chat_history(peer)
+    # Newest page first, shown oldest first
+    messages, _, _ = client.search_chat_history(peer_id=peer or "", limit=limit)
 
     if not messages:
         click.echo("No messages found.")
-        click.echo(
-            "\nNote: Chat history is stored at ~/.pangea/communication/chat_history.json"
-        )
     else:
-        # Show last N messages
-        for msg in messages[-limit:]:
+        for msg in reversed(messages):
             from_id = msg.get("from", "Unknown")[:12]
             content = msg.get("content", "")
             timestamp = msg.get("timestamp", "")
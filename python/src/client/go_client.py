@@ -9,7 +9,7 @@ import threading
 import time
 import sys
 import asyncio
-from typing import Optional, List, Dict, Tuple, Any
+from typing import Optional, List, Dict, Tuple, Any, Callable
 from pathlib import Path
 from concurrent.futures import ThreadPoolExecutor, Future
 
@@ -34,8 +34,9 @@ class GoNodeClient:
         Initialize Go node client.
 
         Args:
-            host: Go node host address
-            port: Go node RPC port (default 8080)
+            host: Go node host address, or unix:///path to match a node
+                started with a unix -capnp-addr
+            port: Go node RPC port (default 8080, unused for unix sockets)
             schema_path: Path to schema.capnp file (if None, uses absolute path from project root)
         """
         self.host = host
@@ -64,6 +65,16 @@ class GoNodeClient:
         self._connection_error: Optional[BaseException] = None
         self._connection_future: Optional[Future] = None
 
+    def _unix_path(self) -> str:
+        """Socket path of a unix:// host."""
+        return self.host[len("unix://") :]
+
+    def _address(self) -> str:
+        """Human-readable address of the Go node."""
+        if self.host.startswith("unix://"):
+            return self.host
+        return f"{self.host}:{self.port}"
+
     def _run_event_loop(self):
         """Run the Cap'n Proto event loop in a background thread."""
         asyncio.set_event_loop(self._loop)
@@ -109,15 +120,20 @@ class GoNodeClient:
                         self.schema = capnp.load(self.schema_path)
 
                         # Connect to Go node using AsyncIoStream
-                        sock = await capnp.AsyncIoStream.create_connection(
-                            self.host, self.port
-                        )
+                        if self.host.startswith("unix://"):
+                            sock = await capnp.AsyncIoStream.create_unix_connection(
+                                self._unix_path()
+                            )
+                        else:
+                            sock = await capnp.AsyncIoStream.create_connection(
+                                self.host, self.port
+                            )
                         self.client = capnp.TwoPartyClient(sock)
                         self.service = self.client.bootstrap().cast_as(
                             self.schema.NodeService
                         )
                         self._connected = True
-                        logger.info(f"Connected to Go node at {self.host}:{self.port}")
+                        logger.info(f"Connected to Go node at {self._address()}")
 
                         # Signal connection success
                         self._connection_event.set()
@@ -190,6 +206,8 @@ class GoNodeClient:
                         "status": node.status,
                         "latencyMs": node.latencyMs,
                         "threatScore": node.threatScore,
+                        "peerId": node.peerId,
+                        "updatedAt": node.updatedAt,
                     }
                 )
             return nodes
@@ -482,13 +500,17 @@ class GoNodeClient:
             logger.error(f"Error in CES reconstruct: {e}")
             return None
 
-    def upload(self, data: bytes, target_peers: List[int]) -> Optional[Dict]:
+    def upload(
+        self, data: bytes, target_peers: List[int], restricted: bool = False
+    ) -> Optional[Dict]:
         """
         High-level upload: CES process + distribute to peers.
 
         Args:
             data: Raw data to upload
             target_peers: List of peer IDs to distribute shards to
+            restricted: Have holders serve the shards only to this node and
+                peers it issues access grants to (libp2p only)
 
         Returns:
             File manifest dictionary with shard locations, or None on error
@@ -502,6 +524,7 @@ class GoNodeClient:
         async def _async_upload():
             request = self.schema.UploadRequest.new_message()
             request.data = data
+            request.restricted = restricted
 
             # Build target peers list
             peers_list = request.init("targetPeers", len(target_peers))
@@ -515,24 +538,7 @@ class GoNodeClient:
                 logger.error(f"Upload failed: {error_msg}")
                 return None
 
-            # Extract manifest
-            manifest = result.response.manifest
-            shard_locations = []
-            for loc in manifest.shardLocations:
-                shard_locations.append(
-                    {"shardIndex": loc.shardIndex, "peerId": loc.peerId}
-                )
-
-            return {
-                "fileHash": manifest.fileHash,
-                "fileName": manifest.fileName,
-                "fileSize": manifest.fileSize,
-                "shardCount": manifest.shardCount,
-                "parityCount": manifest.parityCount,
-                "shardLocations": shard_locations,
-                "timestamp": manifest.timestamp,
-                "ttl": manifest.ttl,
-            }
+            return self._file_manifest(result.response.manifest)
 
         try:
             future = asyncio.run_coroutine_threadsafe(_async_upload(), self._loop)
@@ -542,14 +548,31 @@ class GoNodeClient:
             return None
 
     def download(
-        self, shard_locations: List[Dict], file_hash: str = ""
+        self,
+        shard_locations: List[Dict],
+        file_hash: str = "",
+        parallelism: int = 0,
+        manifest: Optional[Dict] = None,
+        content_hash: str = "",
+        expected_signer: str = "",
     ) -> Optional[Tuple[bytes, int]]:
         """
         High-level download: fetch shards + CES reconstruct.
 
         Args:
-            shard_locations: List of dicts with 'shardIndex' and 'peerId' keys
+            shard_locations: List of dicts with 'shardIndex' and 'peerId' keys,
+                and optionally 'shardHash'. List a shard once per holder to
+                give the node alternates to fail over to.
             file_hash: Optional file hash for cache lookup
+            parallelism: Concurrent shard fetches (0 = node default)
+            manifest: Signed manifest from upload to download instead of
+                shard_locations and file_hash. The node checks its signature
+                before fetching anything. Nodes with a libp2p identity
+                refuse downloads without one.
+            content_hash: BLAKE3 of the file from the upload manifest; the
+                node refuses to return data that does not match it
+            expected_signer: Peer ID the manifest must be signed by; empty
+                trusts only manifests the node signed itself
 
         Returns:
             Tuple of (data: bytes, bytes_downloaded: int), or None on error
@@ -558,26 +581,28 @@ class GoNodeClient:
             raise RuntimeError("Not connected to Go node")
 
         async def _async_download():
-            request = self.schema.DownloadRequest.new_message()
-            request.fileHash = file_hash
-
-            # Build shard locations list
-            locs_list = request.init("shardLocations", len(shard_locations))
-            for i, loc in enumerate(shard_locations):
-                if "shardIndex" not in loc or "peerId" not in loc:
-                    raise ValueError(
-                        f"shard_locations[{i}] missing required keys 'shardIndex' or 'peerId'"
-                    )
-                loc_msg = locs_list[i]
-                loc_msg.shardIndex = loc["shardIndex"]
-                loc_msg.peerId = loc["peerId"]
+            request = self._download_request(
+                shard_locations, file_hash, parallelism, content_hash
+            )
+            request.expectedSigner = expected_signer
+            if manifest is not None:
+                self._fill_file_manifest(request.init("manifest"), manifest)
             result = await self.service.download(request)
 
+            for fetch in result.response.shardFetches:
+                logger.debug(
+                    f"Shard {fetch.shardIndex}: peer {fetch.peerId}, "
+                    f"{fetch.attempts} attempt(s), {fetch.durationMs:.1f} ms"
+                    + ("" if fetch.verified else f", failed: {fetch.errorMsg}")
+                )
+
             if not result.response.success:
                 error_msg = result.response.errorMsg
                 logger.error(f"Download failed: {error_msg}")
                 return None
 
+            if result.response.signer:
+                logger.debug(f"Manifest signed by {result.response.signer}")
             return bytes(result.response.data), result.response.bytesDownloaded
 
         try:
@@ -587,295 +612,2109 @@ class GoNodeClient:
             logger.error(f"Error in download: {e}")
             return None
 
-    # ========================================================================
-    # Streaming Methods (Go handles all networking per Golden Rule)
-    # ========================================================================
-
-    def start_streaming(
+    def upload_directory(
         self,
-        port: int,
-        peer_host: str = "",
-        peer_port: int = 9996,
-        stream_type: int = 0,
-    ) -> bool:
+        path: str,
+        target_peers: List[int],
+        parallelism: int = 0,
+        placement_policy: str = "",
+        pack_threshold: int = 0,
+    ) -> Optional[Dict]:
         """
-        Start streaming service.
+        Upload a directory on the node's filesystem as a tree of CES blobs.
+
+        Small files are packed into shared blobs and duplicate files are
+        stored once. The returned manifest's rootHash covers every entry,
+        so it alone identifies the tree.
 
         Args:
-            port: Local port to start streaming on
-            peer_host: Remote peer host (if connecting to a peer)
-            peer_port: Remote peer port
-            stream_type: 0=video, 1=audio, 2=chat
+            path: Directory path on the Go node
+            target_peers: List of peer IDs to distribute shards to
+            parallelism: As for upload (0 = node default)
+            placement_policy: As for upload ("" = node default)
+            pack_threshold: Files smaller than this share blobs (0 = 64 KiB)
 
         Returns:
-            True if successful
+            Tree manifest dict with rootHash, entries and blobs, or None on error
         """
         if not self._connected:
             raise RuntimeError("Not connected to Go node")
 
-        async def _async_start_streaming():
-            config = self.schema.StreamConfig.new_message()
-            config.port = port
-            config.peerHost = peer_host
-            config.peerPort = peer_port
-            config.streamType = stream_type
-            result = await self.service.startStreaming(config)
-            return result.success
+        if not target_peers:
+            raise ValueError("target_peers cannot be empty or None")
+
+        async def _async_upload_directory():
+            request = self.schema.DirectoryUploadRequest.new_message()
+            request.path = path
+            request.parallelism = parallelism
+            request.placementPolicy = placement_policy
+            request.packThreshold = pack_threshold
+
+            peers_list = request.init("targetPeers", len(target_peers))
+            for i, peer_id in enumerate(target_peers):
+                peers_list[i] = peer_id
+
+            result = await self.service.uploadDirectory(request)
+            if not result.success:
+                logger.error(f"Directory upload failed: {result.errorMsg}")
+                return None
+
+            manifest = result.manifest
+            return {
+                "rootHash": manifest.rootHash,
+                "entries": [
+                    {
+                        "path": e.path,
+                        "isDir": e.isDir,
+                        "size": e.size,
+                        "hash": e.hash,
+                        "blob": e.blob,
+                        "offset": e.offset,
+                    }
+                    for e in manifest.entries
+                ],
+                "blobs": [
+                    {
+                        "blobHash": b.blobHash,
+                        "size": b.size,
+                        "packed": b.packed,
+                        "shardLocations": [
+                            {
+                                "shardIndex": loc.shardIndex,
+                                "peerId": loc.peerId,
+                                "shardHash": loc.shardHash,
+                            }
+                            for loc in b.shardLocations
+                        ],
+                    }
+                    for b in manifest.blobs
+                ],
+                "signature": self._manifest_signature(manifest),
+            }
 
         try:
             future = asyncio.run_coroutine_threadsafe(
-                _async_start_streaming(), self._loop
+                _async_upload_directory(), self._loop
             )
-            return future.result(timeout=10.0)
+            return future.result(timeout=300.0)
         except Exception as e:
-            logger.error(f"Error starting streaming: {e}")
-            return False
+            logger.error(f"Error in directory upload: {e}")
+            return None
 
-    def stop_streaming(self) -> bool:
-        """Stop streaming service."""
+    def download_tree_file(
+        self, manifest: Dict, path: str, parallelism: int = 0
+    ) -> Optional[bytes]:
+        """
+        Download one file of an uploaded directory.
+
+        Only the blob holding the file is fetched. The node checks the
+        manifest against its rootHash and the file against its hash.
+
+        Args:
+            manifest: Tree manifest dict from upload_directory
+            path: File path relative to the tree root
+            parallelism: Concurrent shard fetches (0 = node default)
+
+        Returns:
+            File contents, or None on error
+        """
         if not self._connected:
             raise RuntimeError("Not connected to Go node")
 
-        async def _async_stop_streaming():
-            result = await self.service.stopStreaming()
-            return result.success
+        async def _async_download_tree_file():
+            tree = self.schema.TreeManifest.new_message()
+            tree.rootHash = manifest["rootHash"]
+
+            entries = tree.init("entries", len(manifest["entries"]))
+            for i, e in enumerate(manifest["entries"]):
+                entries[i].path = e["path"]
+                entries[i].isDir = e["isDir"]
+                entries[i].size = e["size"]
+                entries[i].hash = e["hash"]
+                entries[i].blob = e["blob"]
+                entries[i].offset = e["offset"]
+
+            blobs = tree.init("blobs", len(manifest["blobs"]))
+            for i, b in enumerate(manifest["blobs"]):
+                blobs[i].blobHash = b["blobHash"]
+                blobs[i].size = b["size"]
+                blobs[i].packed = b["packed"]
+                locs = blobs[i].init("shardLocations", len(b["shardLocations"]))
+                for j, loc in enumerate(b["shardLocations"]):
+                    locs[j].shardIndex = loc["shardIndex"]
+                    locs[j].peerId = loc["peerId"]
+                    if loc.get("shardHash"):
+                        locs[j].shardHash = loc["shardHash"]
+            self._fill_manifest_signature(tree, manifest.get("signature"))
+
+            result = await self.service.downloadTreeFile(tree, path, parallelism)
+            if not result.success:
+                logger.error(f"Tree file download failed: {result.errorMsg}")
+                return None
+            return bytes(result.data)
 
         try:
             future = asyncio.run_coroutine_threadsafe(
-                _async_stop_streaming(), self._loop
+                _async_download_tree_file(), self._loop
             )
-            return future.result(timeout=5.0)
+            return future.result(timeout=30.0)
         except Exception as e:
-            logger.error(f"Error stopping streaming: {e}")
-            return False
+            logger.error(f"Error in tree file download: {e}")
+            return None
 
-    def send_video_frame(
+    def upload_delta(
         self,
-        frame_id: int,
         data: bytes,
-        width: int = 640,
-        height: int = 480,
-        quality: int = 60,
-    ) -> bool:
+        target_peers: List[int],
+        base: Optional[Dict] = None,
+        parallelism: int = 0,
+        placement_policy: str = "",
+        block_size: int = 0,
+    ) -> Optional[Tuple[Dict, int]]:
         """
-        Send a video frame to the connected peer.
-        Go handles the actual UDP networking and fragmentation.
+        Upload a new version of a file, storing only what changed.
+
+        The node rebuilds the latest version in base, matches data against
+        it block by block with a rolling hash, and stores just the changed
+        bytes. A version that changed too much is stored whole.
 
         Args:
-            frame_id: Unique frame identifier
-            data: JPEG encoded frame data
-            width: Frame width
-            height: Frame height
-            quality: JPEG quality (0-100)
+            data: New content of the file
+            target_peers: List of peer IDs to distribute shards to
+            base: Versioned file manifest from an earlier upload_delta, or
+                None to start a new history
+            parallelism: As for upload (0 = node default)
+            placement_policy: As for upload ("" = node default)
+            block_size: Match granularity in bytes (0 = 4096)
 
         Returns:
-            True if successful
+            Tuple of (manifest with the new version appended, bytes stored),
+            or None on error
         """
         if not self._connected:
             raise RuntimeError("Not connected to Go node")
 
-        async def _async_send_frame():
-            frame = self.schema.VideoFrame.new_message()
-            frame.frameId = frame_id
-            frame.data = data
-            frame.width = width
-            frame.height = height
-            frame.quality = quality
-            result = await self.service.sendVideoFrame(frame)
-            return result.success
+        if not target_peers:
+            raise ValueError("target_peers cannot be empty or None")
+
+        async def _async_upload_delta():
+            request = self.schema.DeltaUploadRequest.new_message()
+            request.data = data
+            request.parallelism = parallelism
+            request.placementPolicy = placement_policy
+            request.blockSize = block_size
+
+            peers_list = request.init("targetPeers", len(target_peers))
+            for i, peer_id in enumerate(target_peers):
+                peers_list[i] = peer_id
+            if base and base.get("versions"):
+                self._fill_versioned_file(request.init("base"), base)
+
+            result = await self.service.uploadDelta(request)
+            if not result.success:
+                logger.error(f"Delta upload failed: {result.errorMsg}")
+                return None
+
+            versions = []
+            for v in result.manifest.versions:
+                versions.append(
+                    {
+                        "fileHash": v.fileHash,
+                        "size": v.size,
+                        "full": v.full,
+                        "blobHash": v.blobHash,
+                        "blobSize": v.blobSize,
+                        "shardLocations": [
+                            {
+                                "shardIndex": loc.shardIndex,
+                                "peerId": loc.peerId,
+                                "shardHash": loc.shardHash,
+                            }
+                            for loc in v.shardLocations
+                        ],
+                        "ops": [
+                            {
+                                "fromBase": op.fromBase,
+                                "offset": op.offset,
+                                "length": op.length,
+                            }
+                            for op in v.ops
+                        ],
+                        "created": v.created,
+                    }
+                )
+            return {
+                "versions": versions,
+                "signature": self._manifest_signature(result.manifest),
+            }, result.storedBytes
 
         try:
-            future = asyncio.run_coroutine_threadsafe(_async_send_frame(), self._loop)
-            return future.result(timeout=1.0)  # Short timeout for real-time
+            future = asyncio.run_coroutine_threadsafe(
+                _async_upload_delta(), self._loop
+            )
+            return future.result(timeout=60.0)
         except Exception as e:
-            logger.error(f"Error sending video frame: {e}")
-            return False
+            logger.error(f"Error in delta upload: {e}")
+            return None
 
-    def send_audio_chunk(
-        self, data: bytes, sample_rate: int = 48000, channels: int = 1
-    ) -> bool:
+    def download_version(
+        self, manifest: Dict, version: int = 0, parallelism: int = 0
+    ) -> Optional[bytes]:
         """
-        Send an audio chunk to the connected peer.
-        Go handles the actual UDP networking.
+        Download one version of a file uploaded with upload_delta.
 
         Args:
-            data: Audio samples (16-bit PCM)
-            sample_rate: Sample rate in Hz
-            channels: Number of audio channels
+            manifest: Versioned file manifest from upload_delta
+            version: 1-based version number (0 = latest)
+            parallelism: Concurrent shard fetches (0 = node default)
 
         Returns:
-            True if successful
+            The version's content, or None on error
         """
         if not self._connected:
             raise RuntimeError("Not connected to Go node")
 
-        async def _async_send_audio():
-            chunk = self.schema.AudioChunk.new_message()
-            chunk.data = data
-            chunk.sampleRate = sample_rate
-            chunk.channels = channels
-            result = await self.service.sendAudioChunk(chunk)
-            return result.success
+        async def _async_download_version():
+            versioned = self.schema.VersionedFile.new_message()
+            self._fill_versioned_file(versioned, manifest)
+            result = await self.service.downloadVersion(
+                versioned, version, parallelism
+            )
+            if not result.success:
+                logger.error(f"Version download failed: {result.errorMsg}")
+                return None
+            return bytes(result.data)
 
         try:
-            future = asyncio.run_coroutine_threadsafe(_async_send_audio(), self._loop)
-            return future.result(timeout=1.0)
+            future = asyncio.run_coroutine_threadsafe(
+                _async_download_version(), self._loop
+            )
+            return future.result(timeout=60.0)
         except Exception as e:
-            logger.error(f"Error sending audio chunk: {e}")
-            return False
+            logger.error(f"Error in version download: {e}")
+            return None
 
-    def send_chat_message(self, peer_addr: str, message: str) -> bool:
+    @staticmethod
+    def _fill_versioned_file(msg, manifest: Dict):
+        """Copy a versioned file manifest dict into a VersionedFile message."""
+        versions = msg.init("versions", len(manifest["versions"]))
+        for i, v in enumerate(manifest["versions"]):
+            fv = versions[i]
+            fv.fileHash = v["fileHash"]
+            fv.size = v["size"]
+            fv.full = v["full"]
+            fv.blobHash = v["blobHash"]
+            fv.blobSize = v["blobSize"]
+            fv.created = v.get("created", 0)
+            locs = fv.init("shardLocations", len(v["shardLocations"]))
+            for j, loc in enumerate(v["shardLocations"]):
+                locs[j].shardIndex = loc["shardIndex"]
+                locs[j].peerId = loc["peerId"]
+                if loc.get("shardHash"):
+                    locs[j].shardHash = loc["shardHash"]
+            ops = fv.init("ops", len(v["ops"]))
+            for j, op in enumerate(v["ops"]):
+                ops[j].fromBase = op["fromBase"]
+                ops[j].offset = op["offset"]
+                ops[j].length = op["length"]
+        GoNodeClient._fill_manifest_signature(msg, manifest.get("signature"))
+
+    @staticmethod
+    def _file_manifest(m) -> Dict:
+        """Convert a FileManifest to a dict."""
+        return {
+            "fileHash": m.fileHash,
+            "fileName": m.fileName,
+            "fileSize": m.fileSize,
+            "shardCount": m.shardCount,
+            "parityCount": m.parityCount,
+            "shardLocations": [
+                {
+                    "shardIndex": loc.shardIndex,
+                    "peerId": loc.peerId,
+                    "shardHash": loc.shardHash,
+                }
+                for loc in m.shardLocations
+            ],
+            "timestamp": m.timestamp,
+            "ttl": m.ttl,
+            "contentHash": m.contentHash,
+            "signature": GoNodeClient._manifest_signature(m),
+        }
+
+    @staticmethod
+    def _fill_file_manifest(msg, manifest: Dict):
+        """Copy a file manifest dict into a FileManifest message."""
+        msg.fileHash = manifest["fileHash"]
+        msg.fileName = manifest.get("fileName", "")
+        msg.fileSize = manifest.get("fileSize", 0)
+        msg.shardCount = manifest.get("shardCount", 0)
+        msg.parityCount = manifest.get("parityCount", 0)
+        msg.timestamp = manifest.get("timestamp", 0)
+        msg.ttl = manifest.get("ttl", 0)
+        msg.contentHash = manifest.get("contentHash", "")
+        locs = msg.init("shardLocations", len(manifest["shardLocations"]))
+        for i, loc in enumerate(manifest["shardLocations"]):
+            locs[i].shardIndex = loc["shardIndex"]
+            locs[i].peerId = loc["peerId"]
+            if loc.get("shardHash"):
+                locs[i].shardHash = loc["shardHash"]
+        GoNodeClient._fill_manifest_signature(msg, manifest.get("signature"))
+
+    @staticmethod
+    def _manifest_signature(m) -> Optional[Dict]:
+        """Convert a manifest's signature to a dict, None if it has none."""
+        if not m._has("signature"):
+            return None
+        sig = m.signature
+        return {
+            "signer": sig.signer,
+            "publicKey": bytes(sig.publicKey),
+            "signature": bytes(sig.signature),
+        }
+
+    @staticmethod
+    def _fill_manifest_signature(msg, signature: Optional[Dict]):
+        """Copy a manifest signature dict into a manifest message."""
+        if not signature:
+            return
+        sig = msg.init("signature")
+        sig.signer = signature["signer"]
+        sig.publicKey = signature["publicKey"]
+        sig.signature = signature["signature"]
+
+    def publish_name(self, name: str, value: str, ttl_seconds: int = 0) -> Optional[Dict]:
         """
-        Send a chat message to a peer.
-        Go handles the actual TCP networking.
+        Point one of this node's names at a manifest hash.
+
+        The node signs a versioned name record with its identity key and
+        stores it in the DHT; each publish bumps the version, and the node
+        republishes its names while it runs.
 
         Args:
-            peer_addr: Peer address (host:port)
-            message: Text message
+            name: Human-readable name, up to 128 bytes
+            value: Manifest hash the name points to
+            ttl_seconds: Record validity (0 = 7 days)
 
         Returns:
-            True if successful
+            Dict with name, value, version, publisher, published and expires
+            (Unix milliseconds), or None on error
         """
         if not self._connected:
             raise RuntimeError("Not connected to Go node")
 
-        async def _async_send_chat():
-            chat_msg = self.schema.ChatMessage.new_message()
-            chat_msg.peerAddr = peer_addr
-            chat_msg.message = message
-            chat_msg.timestamp = int(time.time() * 1000)
-            result = await self.service.sendChatMessage(chat_msg)
-            return result.success
+        async def _async_publish():
+            result = await self.service.publishName(name, value, ttl_seconds)
+            if not result.success:
+                logger.error(f"Publish name failed: {result.errorMsg}")
+                return None
+            return self._name_record(result.record)
 
         try:
-            future = asyncio.run_coroutine_threadsafe(_async_send_chat(), self._loop)
-            return future.result(timeout=5.0)
+            future = asyncio.run_coroutine_threadsafe(_async_publish(), self._loop)
+            return future.result(timeout=60.0)
         except Exception as e:
-            logger.error(f"Error sending chat message: {e}")
-            return False
+            logger.error(f"Error publishing name: {e}")
+            return None
 
-    def connect_stream_peer(self, host: str, port: int) -> Tuple[bool, str]:
+    def resolve_name(self, name: str, publisher: str = "") -> Optional[Dict]:
         """
-        Connect to a streaming peer.
+        Resolve a publisher's name to its newest record.
 
         Args:
-            host: Peer host
-            port: Peer port
+            name: Name to resolve
+            publisher: libp2p peer ID of the publisher ("" = this node)
 
         Returns:
-            Tuple of (success, peer_address_string)
+            Dict as from publish_name, or None if the name is not found
         """
         if not self._connected:
             raise RuntimeError("Not connected to Go node")
 
-        async def _async_connect_stream():
-            result = await self.service.connectStreamPeer(host, port)
-            return result.success, result.peerAddr
+        async def _async_resolve():
+            result = await self.service.resolveName(publisher, name)
+            if not result.success:
+                logger.error(f"Resolve name failed: {result.errorMsg}")
+                return None
+            return self._name_record(result.record)
 
         try:
-            future = asyncio.run_coroutine_threadsafe(
-                _async_connect_stream(), self._loop
-            )
-            return future.result(timeout=10.0)
+            future = asyncio.run_coroutine_threadsafe(_async_resolve(), self._loop)
+            return future.result(timeout=30.0)
         except Exception as e:
-            logger.error(f"Error connecting to stream peer: {e}")
-            return False, ""
+            logger.error(f"Error resolving name: {e}")
+            return None
 
-    def get_stream_stats(self) -> Optional[Dict]:
+    @staticmethod
+    def _name_record(record) -> Dict:
+        """Convert a PublishedName to a dict."""
+        return {
+            "name": record.name,
+            "value": record.value,
+            "version": record.version,
+            "publisher": record.publisher,
+            "published": record.published,
+            "expires": record.expires,
+        }
+
+    def issue_access_grant(
+        self, file_hash: str, grantee: str = "", ttl_seconds: int = 0
+    ) -> Optional[Dict]:
         """
-        Get streaming statistics.
+        Let a peer fetch the shards of one of this node's restricted uploads.
+
+        Args:
+            file_hash: File hash of a restricted upload
+            grantee: libp2p peer ID allowed to use the grant ("" = any bearer)
+            ttl_seconds: Grant validity (0 = 24 hours)
 
         Returns:
-            Dict with streaming stats or None
+            Dict with the grant's fields plus "token", the string to hand to
+            the grantee for add_access_grant, or None on error
         """
         if not self._connected:
             raise RuntimeError("Not connected to Go node")
 
-        async def _async_get_stats():
-            result = await self.service.getStreamStats()
-            stats = result.stats
-            return {
-                "framesSent": stats.framesSent,
-                "framesReceived": stats.framesReceived,
-                "bytesSent": stats.bytesSent,
-                "bytesReceived": stats.bytesReceived,
-                "avgLatencyMs": stats.avgLatencyMs,
-            }
+        async def _async_issue():
+            result = await self.service.issueAccessGrant(file_hash, grantee, ttl_seconds)
+            if not result.success:
+                logger.error(f"Issue access grant failed: {result.errorMsg}")
+                return None
+            grant = self._access_grant(result.grant)
+            grant["token"] = result.token
+            return grant
 
         try:
-            future = asyncio.run_coroutine_threadsafe(_async_get_stats(), self._loop)
-            return future.result(timeout=5.0)
+            future = asyncio.run_coroutine_threadsafe(_async_issue(), self._loop)
+            return future.result(timeout=30.0)
         except Exception as e:
-            logger.error(f"Error getting stream stats: {e}")
+            logger.error(f"Error issuing access grant: {e}")
             return None
 
-    def get_received_frames(self, max_frames: int = 30) -> List[Dict]:
+    def revoke_access_grant(self, grant_id: str) -> Optional[int]:
         """
-        Get received video frames from buffer.
+        Revoke a grant this node issued and tell the file's shard holders.
+
+        Holders that cannot be reached keep honouring the grant until it
+        expires.
 
         Args:
-            max_frames: Maximum number of frames to retrieve
+            grant_id: ID of the grant to revoke
 
         Returns:
-            List of frame dictionaries with frameId, data, width, height, quality
+            Number of holders that acknowledged the revocation, or None on error
         """
         if not self._connected:
             raise RuntimeError("Not connected to Go node")
 
-        async def _async_get_frames():
-            result = await self.service.getReceivedFrames(max_frames)
-            frames = []
-            for frame in result.frames:
-                frames.append(
-                    {
-                        "frameId": frame.frameId,
-                        "data": bytes(frame.data),
-                        "width": frame.width,
-                        "height": frame.height,
-                        "quality": frame.quality,
-                    }
-                )
-            return frames
+        async def _async_revoke():
+            result = await self.service.revokeAccessGrant(grant_id)
+            if not result.success:
+                logger.error(f"Revoke access grant failed: {result.errorMsg}")
+                return None
+            return result.holdersNotified
 
         try:
-            future = asyncio.run_coroutine_threadsafe(_async_get_frames(), self._loop)
-            return future.result(timeout=2.0)
+            future = asyncio.run_coroutine_threadsafe(_async_revoke(), self._loop)
+            return future.result(timeout=60.0)
         except Exception as e:
-            logger.error(f"Error getting received frames: {e}")
-            return []
+            logger.error(f"Error revoking access grant: {e}")
+            return None
 
-    def get_received_audio(self, max_chunks: int = 50) -> List[Dict]:
+    def add_access_grant(self, token: str) -> Optional[Dict]:
         """
-        Get received audio chunks from buffer.
+        Keep a grant token from a file's owner; this node's downloads of
+        that file present it to the shard holders.
 
         Args:
-            max_chunks: Maximum number of chunks to retrieve
+            token: Token from issue_access_grant on the owner's node
 
         Returns:
-            List of audio chunk dictionaries with data, sampleRate, channels
+            Dict with the grant's fields, or None if the token is invalid
         """
         if not self._connected:
             raise RuntimeError("Not connected to Go node")
 
-        async def _async_get_audio():
-            result = await self.service.getReceivedAudio(max_chunks)
-            chunks = []
-            for chunk in result.chunks:
-                chunks.append(
-                    {
-                        "data": bytes(chunk.data),
-                        "sampleRate": chunk.sampleRate,
-                        "channels": chunk.channels,
-                    }
-                )
-            return chunks
+        async def _async_add():
+            result = await self.service.addAccessGrant(token)
+            if not result.success:
+                logger.error(f"Add access grant failed: {result.errorMsg}")
+                return None
+            return self._access_grant(result.grant)
 
         try:
-            future = asyncio.run_coroutine_threadsafe(_async_get_audio(), self._loop)
-            return future.result(timeout=2.0)
+            future = asyncio.run_coroutine_threadsafe(_async_add(), self._loop)
+            return future.result(timeout=30.0)
         except Exception as e:
-            logger.error(f"Error getting received audio: {e}")
-            return []
+            logger.error(f"Error adding access grant: {e}")
+            return None
+
+    def list_access_grants(self, file_hash: str = "") -> Optional[List[Dict]]:
+        """
+        List the grants this node issued.
+
+        Args:
+            file_hash: Only list grants for this file ("" = all files)
+
+        Returns:
+            List of grant dicts, oldest first, or None on error
+        """
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_list():
+            result = await self.service.listAccessGrants(file_hash)
+            if not result.success:
+                logger.error(f"List access grants failed: {result.errorMsg}")
+                return None
+            return [self._access_grant(g) for g in result.grants]
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_list(), self._loop)
+            return future.result(timeout=30.0)
+        except Exception as e:
+            logger.error(f"Error listing access grants: {e}")
+            return None
+
+    @staticmethod
+    def _access_grant(grant) -> Dict:
+        """Convert an AccessGrantInfo to a dict."""
+        return {
+            "grantId": grant.grantId,
+            "fileHash": grant.fileHash,
+            "grantee": grant.grantee,
+            "issuer": grant.issuer,
+            "issuedAt": grant.issuedAt,
+            "expires": grant.expires,
+            "revoked": grant.revoked,
+        }
+
+    def pin(
+        self, content_hash: str, label: str = "", refs: Optional[List[str]] = None
+    ) -> Optional[Dict]:
+        """
+        Pin content so the node never drops it through the shard TTL or
+        quota eviction.
+
+        Args:
+            content_hash: File hash, or a manifest hash when refs are given
+            label: Note stored with the pin
+            refs: Hashes the manifest references, e.g. the blob hashes of a
+                tree manifest or version history, pinned along with it
+
+        Returns:
+            Pin status dict as from pin_status, or None on error
+        """
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+        refs = refs or []
+
+        async def _async_pin():
+            result = await self.service.pinContent(content_hash, label, refs)
+            if not result.success:
+                logger.error(f"Pin failed: {result.errorMsg}")
+                return None
+            return self._pin_status(result.status)
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_pin(), self._loop)
+            return future.result(timeout=10.0)
+        except Exception as e:
+            logger.error(f"Error pinning content: {e}")
+            return None
+
+    def unpin(self, content_hash: str) -> bool:
+        """
+        Remove a pin. Refs of the pin stay pinned while another pin covers
+        them.
+
+        Args:
+            content_hash: Hash that was pinned
+
+        Returns:
+            True if the pin was removed
+        """
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_unpin():
+            result = await self.service.unpinContent(content_hash)
+            if not result.success:
+                logger.error(f"Unpin failed: {result.errorMsg}")
+                return False
+            return True
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_unpin(), self._loop)
+            return future.result(timeout=10.0)
+        except Exception as e:
+            logger.error(f"Error unpinning content: {e}")
+            return False
+
+    def pin_status(self, content_hash: str) -> Optional[Dict]:
+        """
+        Get the pin state of a hash and the shards of it held by the node.
+
+        Returns:
+            Dict with hash, label, pinned (pinned itself), protected (pinned
+            itself or by a manifest), pinnedAt, refs, localShards and
+            localBytes, or None on error
+        """
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_status():
+            result = await self.service.getPinStatus(content_hash)
+            if not result.success:
+                logger.error(f"Get pin status failed: {result.errorMsg}")
+                return None
+            return self._pin_status(result.status)
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_status(), self._loop)
+            return future.result(timeout=10.0)
+        except Exception as e:
+            logger.error(f"Error getting pin status: {e}")
+            return None
+
+    def list_pins(self) -> Optional[List[Dict]]:
+        """
+        List the node's pins, oldest first.
+
+        Returns:
+            List of pin status dicts, or None on error
+        """
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_list():
+            result = await self.service.listPins()
+            if not result.success:
+                logger.error(f"List pins failed: {result.errorMsg}")
+                return None
+            return [self._pin_status(p) for p in result.pins]
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_list(), self._loop)
+            return future.result(timeout=10.0)
+        except Exception as e:
+            logger.error(f"Error listing pins: {e}")
+            return None
+
+    @staticmethod
+    def _pin_status(status) -> Dict:
+        """Convert a PinStatus to a dict."""
+        return {
+            "hash": status.hash,
+            "label": status.label,
+            "pinned": status.pinned,
+            "protected": status.protected,
+            "pinnedAt": status.pinnedAt,
+            "refs": list(status.refs),
+            "localShards": status.localShards,
+            "localBytes": status.localBytes,
+        }
+
+    def rotate_content_key(
+        self,
+        manifest: Dict,
+        target_peers: Optional[List[int]] = None,
+        parallelism: int = 0,
+        placement_policy: str = "",
+    ) -> Optional[str]:
+        """
+        Start re-keying a file whose CES key may be compromised.
+
+        The node downloads the file, re-encrypts it under a freshly dealt
+        key as new shards with a new file hash, and once those reach quorum
+        has the old holders securely delete the old shards and key shares.
+        Poll get_key_rotation for the new manifest; the old one stops working.
+
+        Args:
+            manifest: File manifest as returned by upload
+            target_peers: Peers for the new shards (None = the old holders)
+            parallelism: Concurrent shard transfers (0 = node default)
+            placement_policy: Placement policy name ("" = node config)
+
+        Returns:
+            Rotation job ID, or None on error
+        """
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_rotate():
+            request = self.schema.KeyRotationRequest.new_message()
+            self._fill_file_manifest(request.init("manifest"), manifest)
+            peers = request.init("targetPeers", len(target_peers or []))
+            for i, peer_id in enumerate(target_peers or []):
+                peers[i] = peer_id
+            request.parallelism = parallelism
+            request.placementPolicy = placement_policy
+
+            result = await self.service.rotateContentKey(request)
+            if not result.success:
+                logger.error(f"Key rotation failed: {result.errorMsg}")
+                return None
+            return result.jobId
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_rotate(), self._loop)
+            return future.result(timeout=30.0)
+        except Exception as e:
+            logger.error(f"Error starting key rotation: {e}")
+            return None
+
+    def get_key_rotation(self, job_id: str) -> Optional[Dict]:
+        """
+        Get the state of a key rotation.
+
+        Returns:
+            Dict with jobId, fileHash, newFileHash, state (downloading,
+            reencrypting, deleting, completed or failed), holders,
+            holdersCleared, shardsDeleted, deleteErrors, errorMsg, started,
+            updated and, once completed, the new manifest; or None on error
+        """
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_get():
+            result = await self.service.getKeyRotation(job_id)
+            if not result.success:
+                logger.error(f"Get key rotation failed: {result.errorMsg}")
+                return None
+            st = result.status
+            status = {
+                "jobId": st.jobId,
+                "fileHash": st.fileHash,
+                "newFileHash": st.newFileHash,
+                "state": st.state,
+                "holders": st.holders,
+                "holdersCleared": st.holdersCleared,
+                "shardsDeleted": st.shardsDeleted,
+                "deleteErrors": list(st.deleteErrors),
+                "errorMsg": st.errorMsg,
+                "started": st.started,
+                "updated": st.updated,
+                "manifest": None,
+            }
+            if st.state == "completed":
+                status["manifest"] = self._file_manifest(st.manifest)
+            return status
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_get(), self._loop)
+            return future.result(timeout=10.0)
+        except Exception as e:
+            logger.error(f"Error getting key rotation: {e}")
+            return None
+
+    def get_node_summary(self, since_version: int = 0) -> Optional[Dict]:
+        """
+        Get the node's peers, reachability, storage, compute jobs, stream
+        sessions and capacity in one call.
+
+        Args:
+            since_version: version of a previous summary; sections unchanged
+                since are left out. 0 returns every section.
+
+        Returns:
+            Dict with version, nodeId, uptimeSecs and each changed section
+            (peers, reachability, storage, jobs, streams, capacity) as a
+            dict; or None on error
+        """
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_summary():
+            result = await self.service.getNodeSummary(since_version)
+            s = result.summary
+            summary = {
+                "version": s.version,
+                "nodeId": s.nodeId,
+                "uptimeSecs": s.uptimeSecs,
+            }
+            for section in ("peers", "reachability", "storage", "jobs", "streams", "capacity"):
+                if s._has(section):
+                    summary[section] = getattr(s, section).to_dict()
+            return summary
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_summary(), self._loop)
+            return future.result(timeout=10.0)
+        except Exception as e:
+            logger.error(f"Error getting node summary: {e}")
+            return None
+
+    def set_fault_injection(
+        self,
+        shard_fetch_drop_rate: float = 0.0,
+        compute_delay_ms: int = 0,
+        compute_delay_rate: float = 0.0,
+        stream_kill_rate: float = 0.0,
+        stream_kill_after_bytes: int = 0,
+        seed: int = 0,
+    ) -> Tuple[bool, str]:
+        """
+        Set the faults the node injects into its own traffic. Only nodes
+        started with -chaos accept faults; all-zero arguments stop injecting.
+
+        Args:
+            shard_fetch_drop_rate: fraction of shard fetches failed
+            compute_delay_ms: delay added to delegated compute responses
+            compute_delay_rate: fraction of compute responses delayed
+            stream_kill_rate: fraction of outgoing file transfers reset
+            stream_kill_after_bytes: bytes a killed transfer sends first;
+                0 means one chunk
+            seed: seeds fault choices for reproducible runs; 0 is random
+
+        Returns:
+            Tuple of (success, error_message)
+        """
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_set():
+            config = self.schema.FaultConfig.new_message()
+            config.shardFetchDropRate = shard_fetch_drop_rate
+            config.computeDelayMs = compute_delay_ms
+            config.computeDelayRate = compute_delay_rate
+            config.streamKillRate = stream_kill_rate
+            config.streamKillAfterBytes = stream_kill_after_bytes
+            config.seed = seed
+            result = await self.service.setFaultInjection(config)
+            return result.success, result.errorMsg
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_set(), self._loop)
+            return future.result(timeout=10.0)
+        except Exception as e:
+            logger.error(f"Error setting fault injection: {e}")
+            return False, str(e)
+
+    def get_fault_injection(self) -> Optional[Dict]:
+        """
+        Get whether fault injection is enabled, the current faults and how
+        many were injected since they were set.
+
+        Returns:
+            Dict with enabled, config and stats; or None on error
+        """
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_get():
+            result = await self.service.getFaultInjection()
+            return {
+                "enabled": result.enabled,
+                "config": result.config.to_dict(),
+                "stats": result.stats.to_dict(),
+            }
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_get(), self._loop)
+            return future.result(timeout=10.0)
+        except Exception as e:
+            logger.error(f"Error getting fault injection: {e}")
+            return None
+
+    def set_bandwidth_limits(
+        self,
+        total_bytes_per_sec: int = 0,
+        bulk_bytes_per_sec: int = 0,
+        interactive_bytes_per_sec: int = 0,
+    ) -> Tuple[bool, str]:
+        """
+        Set the node's outgoing bandwidth limits. Shard uploads and shards
+        served to peers are bulk traffic; voice, video and chat streaming is
+        interactive and is not held back by the total limit. 0 leaves a
+        limit off.
+
+        Args:
+            total_bytes_per_sec: cap on bulk and interactive traffic together
+            bulk_bytes_per_sec: cap on shard transfers
+            interactive_bytes_per_sec: cap on streaming
+
+        Returns:
+            Tuple of (success, error_message)
+        """
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_set():
+            limits = self.schema.BandwidthLimits.new_message()
+            limits.totalBytesPerSec = total_bytes_per_sec
+            limits.bulkBytesPerSec = bulk_bytes_per_sec
+            limits.interactiveBytesPerSec = interactive_bytes_per_sec
+            result = await self.service.setBandwidthLimits(limits)
+            return result.success, result.errorMsg
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_set(), self._loop)
+            return future.result(timeout=10.0)
+        except Exception as e:
+            logger.error(f"Error setting bandwidth limits: {e}")
+            return False, str(e)
+
+    def get_bandwidth_limits(self) -> Optional[Dict]:
+        """
+        Get the node's outgoing bandwidth limits and, per class, the bytes
+        sent and the time sends waited on the limits.
+
+        Returns:
+            Dict with limits and classes; or None on error
+        """
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_get():
+            result = await self.service.getBandwidthLimits()
+            return {
+                "limits": result.limits.to_dict(),
+                "classes": [c.to_dict() for c in result.classes],
+            }
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_get(), self._loop)
+            return future.result(timeout=10.0)
+        except Exception as e:
+            logger.error(f"Error getting bandwidth limits: {e}")
+            return None
+
+    def create_shm_ring(
+        self, name: str, size_bytes: int = 0
+    ) -> Tuple[Optional[Dict], str]:
+        """
+        Create a named shared memory ring on the node. The ring is a file
+        (pangea_<name>.ring in the node's shared memory directory) that this
+        process can map to exchange data with the node.
+
+        Args:
+            name: ring name (letters, digits, '-' and '_')
+            size_bytes: data area size; 0 uses the node's default
+
+        Returns:
+            Tuple of (ring info dict or None, error_message)
+        """
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_create():
+            result = await self.service.createSharedMemoryRing(name, size_bytes)
+            if not result.success:
+                return None, result.errorMsg
+            return result.ring.to_dict(), ""
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_create(), self._loop)
+            return future.result(timeout=10.0)
+        except Exception as e:
+            logger.error(f"Error creating shared memory ring: {e}")
+            return None, str(e)
+
+    def attach_shm_ring(self, name: str) -> Tuple[Optional[Dict], str]:
+        """
+        Attach the node to an existing shared memory ring file, e.g. one
+        created by another process or left from before a restart.
+
+        Returns:
+            Tuple of (ring info dict or None, error_message)
+        """
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_attach():
+            result = await self.service.attachSharedMemoryRing(name)
+            if not result.success:
+                return None, result.errorMsg
+            return result.ring.to_dict(), ""
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_attach(), self._loop)
+            return future.result(timeout=10.0)
+        except Exception as e:
+            logger.error(f"Error attaching shared memory ring: {e}")
+            return None, str(e)
+
+    def detach_shm_ring(self, name: str) -> Tuple[bool, str]:
+        """
+        Unmap a ring from the node, leaving its file and contents in place.
+
+        Returns:
+            Tuple of (success, error_message)
+        """
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_detach():
+            result = await self.service.detachSharedMemoryRing(name)
+            return result.success, result.errorMsg
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_detach(), self._loop)
+            return future.result(timeout=10.0)
+        except Exception as e:
+            logger.error(f"Error detaching shared memory ring: {e}")
+            return False, str(e)
+
+    def destroy_shm_ring(self, name: str) -> Tuple[bool, str]:
+        """
+        Unmap a ring from the node and remove its file.
+
+        Returns:
+            Tuple of (success, error_message)
+        """
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_destroy():
+            result = await self.service.destroySharedMemoryRing(name)
+            return result.success, result.errorMsg
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_destroy(), self._loop)
+            return future.result(timeout=10.0)
+        except Exception as e:
+            logger.error(f"Error destroying shared memory ring: {e}")
+            return False, str(e)
+
+    def list_shm_rings(self) -> Optional[List[Dict]]:
+        """
+        List the shared memory rings the node has mapped, with their
+        cursors and message counts.
+
+        Returns:
+            List of ring info dicts; or None on error
+        """
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_list():
+            result = await self.service.listSharedMemoryRings()
+            return [r.to_dict() for r in result.rings]
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_list(), self._loop)
+            return future.result(timeout=10.0)
+        except Exception as e:
+            logger.error(f"Error listing shared memory rings: {e}")
+            return None
+
+    def subscribe_shm_ring(
+        self, name: str, callback: Callable[[Dict], None]
+    ) -> Tuple[Optional[int], str]:
+        """Subscribe to writes to an attached shared memory ring.
+
+        The node calls back when messages are written, at once if some are
+        already waiting, so readers need not poll the ring. Notifications
+        the client has not yet taken are merged into the latest one.
+
+        Args:
+            name: The ring to follow
+            callback: Called with a dict of name, sequence (messages ever
+                written) and pending (messages not yet read)
+
+        Returns:
+            Tuple of (subscription_id or None, error_message)
+        """
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        class _Listener(self.schema.SharedMemoryRingListener.Server):
+            async def onRingData(self, name, sequence, pending, **kwargs):
+                try:
+                    callback(
+                        {"name": name, "sequence": sequence, "pending": pending}
+                    )
+                except Exception as e:
+                    logger.error(f"Error in shared memory ring callback: {e}")
+
+        async def _async_subscribe():
+            result = await self.service.subscribeSharedMemoryRing(name, _Listener())
+            if not result.success:
+                return None, result.errorMsg
+            return result.subscriptionId, ""
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_subscribe(), self._loop)
+            return future.result(timeout=5.0)
+        except Exception as e:
+            logger.error(f"Error subscribing to shared memory ring: {e}")
+            return None, str(e)
+
+    def unsubscribe_shm_ring(self, subscription_id: int) -> bool:
+        """End a shared memory ring subscription."""
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_unsubscribe():
+            result = await self.service.unsubscribeSharedMemoryRing(subscription_id)
+            return result.success
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_unsubscribe(), self._loop)
+            return future.result(timeout=5.0)
+        except Exception as e:
+            logger.error(f"Error unsubscribing from shared memory ring: {e}")
+            return False
+
+    def read_shm_ring(
+        self, name: str, max_messages: int = 1, timeout_ms: int = 0
+    ) -> Tuple[Optional[List[bytes]], str]:
+        """
+        Take messages from an attached shared memory ring through the node,
+        which claims them so they are not also read by other consumers.
+
+        Args:
+            name: The ring to read
+            max_messages: Most messages to take (0 = 1)
+            timeout_ms: How long to wait for a message if none is waiting
+                (0 = return at once)
+
+        Returns:
+            Tuple of (list of messages, possibly empty, or None, error_message)
+        """
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_read():
+            result = await self.service.readSharedMemoryRing(
+                name, max_messages, timeout_ms
+            )
+            if not result.success:
+                return None, result.errorMsg
+            return [bytes(m) for m in result.messages], ""
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_read(), self._loop)
+            return future.result(timeout=10.0 + timeout_ms / 1000.0)
+        except Exception as e:
+            logger.error(f"Error reading shared memory ring: {e}")
+            return None, str(e)
+
+    def _download_request(
+        self,
+        shard_locations: List[Dict],
+        file_hash: str,
+        parallelism: int,
+        content_hash: str = "",
+    ):
+        """Build a DownloadRequest from shard location dicts."""
+        request = self.schema.DownloadRequest.new_message()
+        request.fileHash = file_hash
+        request.parallelism = parallelism
+        request.contentHash = content_hash
+
+        locs_list = request.init("shardLocations", len(shard_locations))
+        for i, loc in enumerate(shard_locations):
+            if "shardIndex" not in loc or "peerId" not in loc:
+                raise ValueError(
+                    f"shard_locations[{i}] missing required keys 'shardIndex' or 'peerId'"
+                )
+            loc_msg = locs_list[i]
+            loc_msg.shardIndex = loc["shardIndex"]
+            loc_msg.peerId = loc["peerId"]
+            if loc.get("shardHash"):
+                loc_msg.shardHash = loc["shardHash"]
+        return request
+
+    def start_download(
+        self, shard_locations: List[Dict], file_hash: str, parallelism: int = 0
+    ) -> Optional[str]:
+        """Start a background download and return its session ID.
+
+        Fetched shards are spooled on the node, so starting the same file
+        again after cancel_download, a failure or a node restart fetches
+        only the shards still missing. If the file is already downloading,
+        the running session's ID is returned.
+
+        Args:
+            shard_locations: Same as for download()
+            file_hash: File hash from the upload manifest
+            parallelism: Concurrent shard fetches (0 = node default)
+        """
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_start():
+            request = self._download_request(shard_locations, file_hash, parallelism)
+            result = await self.service.startDownload(request)
+            if not result.success:
+                logger.error(f"Start download failed: {result.errorMsg}")
+                return None
+            return result.sessionId
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_start(), self._loop)
+            return future.result(timeout=5.0)
+        except Exception as e:
+            logger.error(f"Error starting download: {e}")
+            return None
+
+    def get_download_progress(self, session_id: str) -> Optional[Dict]:
+        """Get a background download's progress.
+
+        Returns:
+            Dict with sessionId, fileHash, state ("fetching", "reconstructing",
+            "completed", "failed" or "cancelled"), shardsVerified,
+            shardsRequired, shardsResumed, bytesFetched, error, updatedAt,
+            shardFetches and, once completed, data; or None on error
+        """
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_progress():
+            result = await self.service.getDownloadProgress(session_id)
+            if not result.success:
+                logger.error(f"Download progress failed: {result.errorMsg}")
+                return None
+            p = result.progress
+            return {
+                "sessionId": p.sessionId,
+                "fileHash": p.fileHash,
+                "state": p.state,
+                "shardsVerified": p.shardsVerified,
+                "shardsRequired": p.shardsRequired,
+                "shardsResumed": p.shardsResumed,
+                "bytesFetched": p.bytesFetched,
+                "error": p.error,
+                "data": bytes(p.data),
+                "updatedAt": p.updatedAt,
+                "shardFetches": [
+                    {
+                        "shardIndex": f.shardIndex,
+                        "peerId": f.peerId,
+                        "attempts": f.attempts,
+                        "durationMs": f.durationMs,
+                        "verified": f.verified,
+                        "errorMsg": f.errorMsg,
+                    }
+                    for f in p.shardFetches
+                ],
+            }
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_progress(), self._loop)
+            return future.result(timeout=5.0)
+        except Exception as e:
+            logger.error(f"Error getting download progress: {e}")
+            return None
+
+    def cancel_download(self, session_id: str, discard: bool = False) -> Tuple[bool, str]:
+        """Stop a background download.
+
+        Without discard this pauses it: the spooled shards are kept and
+        start_download resumes from them.
+        """
+        return self._success_call("cancelDownload", session_id, discard)
+
+    # ========================================================================
+    # Threshold Signing Methods
+    # ========================================================================
+
+    def create_signing_group(
+        self, group_id: str, peer_ids: List[str], threshold: int
+    ) -> Optional[bytes]:
+        """Run key generation among this node and peer_ids.
+
+        Any threshold members of the group can then sign with
+        threshold_sign; no member ever holds the whole key.
+
+        Returns:
+            The group public key, or None on error
+        """
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_create():
+            result = await self.service.createSigningGroup(group_id, peer_ids, threshold)
+            if not result.success:
+                logger.error(f"Create signing group failed: {result.errorMsg}")
+                return None
+            return bytes(result.groupKey)
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_create(), self._loop)
+            return future.result(timeout=60.0)
+        except Exception as e:
+            logger.error(f"Error creating signing group: {e}")
+            return None
+
+    def threshold_sign(self, group_id: str, purpose: str, payload: bytes) -> Optional[Dict]:
+        """Sign a payload with a signing group this node belongs to.
+
+        Args:
+            group_id: Signing group
+            purpose: What is signed, e.g. "manifest" or "job-result"
+            payload: Bytes to sign
+
+        Returns:
+            Dict with signature, groupKey and signers (peer IDs), or None on error
+        """
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_sign():
+            result = await self.service.thresholdSign(group_id, purpose, payload)
+            if not result.success:
+                logger.error(f"Threshold sign failed: {result.errorMsg}")
+                return None
+            return {
+                "signature": bytes(result.signature),
+                "groupKey": bytes(result.groupKey),
+                "signers": list(result.signers),
+            }
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_sign(), self._loop)
+            return future.result(timeout=60.0)
+        except Exception as e:
+            logger.error(f"Error threshold signing: {e}")
+            return None
+
+    def verify_threshold_signature(
+        self, group_id: str, group_key: bytes, purpose: str, payload: bytes, signature: bytes
+    ) -> Tuple[bool, str]:
+        """Check a threshold signature against a group key.
+
+        Returns:
+            Tuple of (valid, error_message)
+        """
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_verify():
+            result = await self.service.verifyThresholdSignature(
+                group_id, group_key, purpose, payload, signature
+            )
+            return result.valid, result.errorMsg
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_verify(), self._loop)
+            return future.result(timeout=5.0)
+        except Exception as e:
+            logger.error(f"Error verifying threshold signature: {e}")
+            return False, str(e)
+
+    # ========================================================================
+    # Security Key Methods
+    # ========================================================================
+
+    def list_keys(self) -> List[Dict]:
+        """List the node's keys, current and retiring, with keyId, version,
+        fingerprint, created, retiresAt (0 for current keys), hasPrivateKey
+        and publicKey (PEM)."""
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_list():
+            result = await self.service.listKeys()
+            return [
+                {
+                    "keyId": k.keyId,
+                    "version": k.version,
+                    "fingerprint": k.fingerprint,
+                    "created": k.created,
+                    "retiresAt": k.retiresAt,
+                    "hasPrivateKey": k.hasPrivateKey,
+                    "publicKey": bytes(k.publicKey),
+                }
+                for k in result.keys
+            ]
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_list(), self._loop)
+            return future.result(timeout=5.0)
+        except Exception as e:
+            logger.error(f"Error listing keys: {e}")
+            return []
+
+    def rotate_key(self, key_id: str = "default", overlap_secs: int = 0) -> Optional[Dict]:
+        """Replace a key pair with a fresh one.
+
+        The old pair keeps decrypting and verifying for overlap_secs
+        (0 means the node default of 7 days).
+
+        Returns:
+            The new key, as in list_keys, or None on error
+        """
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_rotate():
+            result = await self.service.rotateKey(key_id, overlap_secs)
+            if not result.success:
+                logger.error(f"Rotate key failed: {result.errorMsg}")
+                return None
+            k = result.key
+            return {
+                "keyId": k.keyId,
+                "version": k.version,
+                "fingerprint": k.fingerprint,
+                "created": k.created,
+                "retiresAt": k.retiresAt,
+                "hasPrivateKey": k.hasPrivateKey,
+                "publicKey": bytes(k.publicKey),
+            }
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_rotate(), self._loop)
+            return future.result(timeout=30.0)
+        except Exception as e:
+            logger.error(f"Error rotating key: {e}")
+            return None
+
+    def get_agreement_key(self, key_id: str = "default") -> Optional[bytes]:
+        """Get this node's raw X25519 public key, for peers to encrypt to."""
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_get():
+            result = await self.service.getAgreementKey(key_id)
+            if not result.success:
+                logger.error(f"Get agreement key failed: {result.errorMsg}")
+                return None
+            return bytes(result.publicKey)
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_get(), self._loop)
+            return future.result(timeout=5.0)
+        except Exception as e:
+            logger.error(f"Error getting agreement key: {e}")
+            return None
+
+    def import_agreement_key(self, key_id: str, public_key: bytes) -> Tuple[bool, str]:
+        """Store a peer's raw X25519 public key under key_id."""
+        return self._success_call("importAgreementKey", key_id, public_key)
+
+    def encrypt_message(
+        self,
+        plaintext: bytes,
+        key_id: str = "",
+        recipient_key: bytes = b"",
+        aad: bytes = b"",
+    ) -> Optional[bytes]:
+        """Encrypt a message with X25519, HKDF and the configured cipher.
+
+        Args:
+            plaintext: Message to encrypt
+            key_id: Imported peer key, used when recipient_key is empty;
+                peers with only an RSA key get RSA-OAEP
+            recipient_key: Raw X25519 public key to seal to
+            aad: Associated data the recipient must present to decrypt
+
+        Returns:
+            The ciphertext, or None on error
+        """
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_encrypt():
+            result = await self.service.encryptMessage(key_id, recipient_key, plaintext, aad)
+            if not result.success:
+                logger.error(f"Encrypt message failed: {result.errorMsg}")
+                return None
+            return bytes(result.ciphertext)
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_encrypt(), self._loop)
+            return future.result(timeout=5.0)
+        except Exception as e:
+            logger.error(f"Error encrypting message: {e}")
+            return None
+
+    def decrypt_message(
+        self, ciphertext: bytes, key_id: str = "default", aad: bytes = b""
+    ) -> Optional[bytes]:
+        """Decrypt a message encrypted to this node's key_id.
+
+        Returns:
+            The plaintext, or None on error
+        """
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_decrypt():
+            result = await self.service.decryptMessage(key_id, ciphertext, aad)
+            if not result.success:
+                logger.error(f"Decrypt message failed: {result.errorMsg}")
+                return None
+            return bytes(result.plaintext)
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_decrypt(), self._loop)
+            return future.result(timeout=5.0)
+        except Exception as e:
+            logger.error(f"Error decrypting message: {e}")
+            return None
+
+    # ========================================================================
+    # Peer Verification Methods
+    # ========================================================================
+
+    @staticmethod
+    def _peer_verification_dict(v) -> Dict:
+        return {
+            "peerId": v.peerId,
+            "safetyNumber": v.safetyNumber,
+            "fingerprint": v.fingerprint,
+            "verified": v.verified,
+            "verifiedAt": v.verifiedAt,
+            "keyChanged": v.keyChanged,
+            "keyChangedAt": v.keyChangedAt,
+            "seenFingerprint": v.seenFingerprint,
+        }
+
+    def get_peer_verification(self, peer_id: str) -> Optional[Dict]:
+        """Get the safety number shared with a peer and its verification state.
+
+        Returns:
+            Dict with peerId, safetyNumber, fingerprint, verified, verifiedAt,
+            keyChanged, keyChangedAt and seenFingerprint, or None on error
+        """
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_get():
+            result = await self.service.getPeerVerification(peer_id)
+            if not result.success:
+                logger.error(f"Get peer verification failed: {result.errorMsg}")
+                return None
+            return self._peer_verification_dict(result.verification)
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_get(), self._loop)
+            return future.result(timeout=5.0)
+        except Exception as e:
+            logger.error(f"Error getting peer verification: {e}")
+            return None
+
+    def verify_peer(self, peer_id: str, safety_number: str = "") -> Tuple[bool, str]:
+        """Mark a peer as verified after comparing safety numbers out of band.
+
+        If safety_number is given, the node checks it matches.
+        """
+        return self._success_call("verifyPeer", peer_id, safety_number)
+
+    def unverify_peer(self, peer_id: str) -> Tuple[bool, str]:
+        """Forget a peer's verification."""
+        return self._success_call("unverifyPeer", peer_id)
+
+    def list_verified_peers(self) -> List[Dict]:
+        """List every verified peer, as in get_peer_verification."""
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_list():
+            result = await self.service.listVerifiedPeers()
+            return [self._peer_verification_dict(v) for v in result.peers]
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_list(), self._loop)
+            return future.result(timeout=5.0)
+        except Exception as e:
+            logger.error(f"Error listing verified peers: {e}")
+            return []
+
+    # ========================================================================
+    # Peer ID Methods
+    # ========================================================================
+
+    def resolve_peer_id(self, peer_id: int) -> Optional[str]:
+        """Get the libp2p peer ID behind a numeric peer ID.
+
+        Numeric peer IDs are derived from libp2p peer IDs and stay the same
+        across node restarts.
+        """
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_resolve():
+            result = await self.service.resolvePeerId(peer_id)
+            if not result.success:
+                logger.error(f"Resolve peer ID failed: {result.errorMsg}")
+                return None
+            return result.libp2pId
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_resolve(), self._loop)
+            return future.result(timeout=5.0)
+        except Exception as e:
+            logger.error(f"Error resolving peer ID: {e}")
+            return None
+
+    def lookup_peer_id(self, libp2p_id: str) -> Optional[int]:
+        """Get the numeric peer ID of a libp2p peer, assigning one if needed."""
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_lookup():
+            result = await self.service.lookupPeerId(libp2p_id)
+            if not result.success:
+                logger.error(f"Lookup peer ID failed: {result.errorMsg}")
+                return None
+            return result.peerId
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_lookup(), self._loop)
+            return future.result(timeout=5.0)
+        except Exception as e:
+            logger.error(f"Error looking up peer ID: {e}")
+            return None
+
+    # ========================================================================
+    # WebRTC Methods
+    # ========================================================================
+
+    def get_webrtc_addrs(self) -> Tuple[bool, str, List[str]]:
+        """Get the WebRTC-direct multiaddrs browsers can dial.
+
+        Returns:
+            Tuple of (enabled, peer_id, multiaddrs); enabled is False when
+            the node runs without -enable-webrtc or on a private network
+        """
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_get():
+            result = await self.service.getWebRTCAddrs()
+            return result.enabled, result.peerId, list(result.addrs)
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_get(), self._loop)
+            return future.result(timeout=5.0)
+        except Exception as e:
+            logger.error(f"Error getting WebRTC addresses: {e}")
+            return False, "", []
+
+    # ========================================================================
+    # Network Backend Methods
+    # ========================================================================
+
+    def get_network_backends(self) -> List[Dict[str, Any]]:
+        """Get the node's network backends (libp2p and legacy).
+
+        Returns:
+            List of dicts with name, enabled, primary, switchable,
+            listen_addr and peer_count
+        """
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_get():
+            result = await self.service.getNetworkBackends()
+            return [
+                {
+                    "name": b.name,
+                    "enabled": b.enabled,
+                    "primary": b.primary,
+                    "switchable": b.switchable,
+                    "listen_addr": b.listenAddr,
+                    "peer_count": b.peerCount,
+                }
+                for b in result.backends
+            ]
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_get(), self._loop)
+            return future.result(timeout=5.0)
+        except Exception as e:
+            logger.error(f"Error getting network backends: {e}")
+            return []
+
+    def set_network_backend(self, name: str, enabled: bool, listen_addr: str = "") -> bool:
+        """Enable a backend on listen_addr, or disable it.
+
+        Only switchable backends (the legacy listener) can change at runtime.
+        """
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_set():
+            result = await self.service.setNetworkBackend(name, enabled, listen_addr)
+            if not result.success:
+                logger.error(f"Set network backend failed: {result.errorMsg}")
+            return result.success
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_set(), self._loop)
+            return future.result(timeout=10.0)
+        except Exception as e:
+            logger.error(f"Error setting network backend: {e}")
+            return False
+
+    def set_primary_backend(self, name: str) -> bool:
+        """Choose the backend used for peers not connected on any."""
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_set():
+            result = await self.service.setPrimaryBackend(name)
+            if not result.success:
+                logger.error(f"Set primary backend failed: {result.errorMsg}")
+            return result.success
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_set(), self._loop)
+            return future.result(timeout=5.0)
+        except Exception as e:
+            logger.error(f"Error setting primary backend: {e}")
+            return False
+
+    def get_worker_policy(self) -> Optional[Dict]:
+        """Get this node's compute worker policy and how many peer tasks it runs."""
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_get():
+            result = await self.service.getWorkerPolicy()
+            p = result.policy
+            return {
+                "enabled": p.enabled,
+                "max_concurrent_tasks": p.maxConcurrentTasks,
+                "max_task_cpu_percent": p.maxTaskCpuPercent,
+                "max_task_memory_mb": p.maxTaskMemoryMb,
+                "max_task_time_ms": p.maxTaskTimeMs,
+                "work_hours": list(p.workHours),
+                "active_tasks": result.activeTasks,
+                "accepting": result.accepting,
+            }
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_get(), self._loop)
+            return future.result(timeout=5.0)
+        except Exception as e:
+            logger.error(f"Error getting worker policy: {e}")
+            return None
+
+    def set_worker_policy(
+        self,
+        enabled: bool,
+        max_concurrent_tasks: int = 0,
+        max_task_cpu_percent: int = 0,
+        max_task_memory_mb: int = 0,
+        max_task_time_ms: int = 0,
+        work_hours: Optional[List[str]] = None,
+    ) -> bool:
+        """Opt in or out of running peer compute tasks and set per-task limits.
+
+        Zero limits are unlimited. work_hours entries look like
+        "Mon-Fri 09:00-17:00" or "22:00-06:00" (local time).
+        """
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_set():
+            policy = self.schema.WorkerPolicy.new_message()
+            policy.enabled = enabled
+            policy.maxConcurrentTasks = max_concurrent_tasks
+            policy.maxTaskCpuPercent = max_task_cpu_percent
+            policy.maxTaskMemoryMb = max_task_memory_mb
+            policy.maxTaskTimeMs = max_task_time_ms
+            hours = work_hours or []
+            policy.init("workHours", len(hours))
+            for i, w in enumerate(hours):
+                policy.workHours[i] = w
+            result = await self.service.setWorkerPolicy(policy)
+            if not result.success:
+                logger.error(f"Set worker policy failed: {result.errorMsg}")
+            return result.success
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_set(), self._loop)
+            return future.result(timeout=5.0)
+        except Exception as e:
+            logger.error(f"Error setting worker policy: {e}")
+            return False
+
+    # ========================================================================
+    # Streaming Methods (Go handles all networking per Golden Rule)
+    # ========================================================================
+
+    def start_streaming(
+        self,
+        port: int,
+        peer_host: str = "",
+        peer_port: int = 9996,
+        stream_type: int = 0,
+    ) -> bool:
+        """
+        Start streaming service.
+
+        Args:
+            port: Local port to start streaming on
+            peer_host: Remote peer host (if connecting to a peer)
+            peer_port: Remote peer port
+            stream_type: 0=video, 1=audio, 2=chat
+
+        Returns:
+            True if successful
+        """
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_start_streaming():
+            config = self.schema.StreamConfig.new_message()
+            config.port = port
+            config.peerHost = peer_host
+            config.peerPort = peer_port
+            config.streamType = stream_type
+            result = await self.service.startStreaming(config)
+            return result.success
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(
+                _async_start_streaming(), self._loop
+            )
+            return future.result(timeout=10.0)
+        except Exception as e:
+            logger.error(f"Error starting streaming: {e}")
+            return False
+
+    def stop_streaming(self) -> bool:
+        """Stop streaming service."""
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_stop_streaming():
+            result = await self.service.stopStreaming()
+            return result.success
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(
+                _async_stop_streaming(), self._loop
+            )
+            return future.result(timeout=5.0)
+        except Exception as e:
+            logger.error(f"Error stopping streaming: {e}")
+            return False
+
+    def send_video_frame(
+        self,
+        frame_id: int,
+        data: bytes,
+        width: int = 640,
+        height: int = 480,
+        quality: int = 60,
+    ) -> bool:
+        """
+        Send a video frame to the connected peer.
+        Go handles the actual UDP networking and fragmentation.
+
+        Args:
+            frame_id: Unique frame identifier
+            data: JPEG encoded frame data
+            width: Frame width
+            height: Frame height
+            quality: JPEG quality (0-100)
+
+        Returns:
+            True if successful
+        """
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_send_frame():
+            frame = self.schema.VideoFrame.new_message()
+            frame.frameId = frame_id
+            frame.data = data
+            frame.width = width
+            frame.height = height
+            frame.quality = quality
+            result = await self.service.sendVideoFrame(frame)
+            return result.success
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_send_frame(), self._loop)
+            return future.result(timeout=1.0)  # Short timeout for real-time
+        except Exception as e:
+            logger.error(f"Error sending video frame: {e}")
+            return False
+
+    def send_audio_chunk(
+        self, data: bytes, sample_rate: int = 48000, channels: int = 1
+    ) -> bool:
+        """
+        Send an audio chunk to the connected peer.
+        Go handles the actual UDP networking.
+
+        Args:
+            data: Audio samples (16-bit PCM)
+            sample_rate: Sample rate in Hz
+            channels: Number of audio channels
+
+        Returns:
+            True if successful
+        """
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_send_audio():
+            chunk = self.schema.AudioChunk.new_message()
+            chunk.data = data
+            chunk.sampleRate = sample_rate
+            chunk.channels = channels
+            result = await self.service.sendAudioChunk(chunk)
+            return result.success
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_send_audio(), self._loop)
+            return future.result(timeout=1.0)
+        except Exception as e:
+            logger.error(f"Error sending audio chunk: {e}")
+            return False
+
+    def send_chat_message(self, peer_addr: str, message: str) -> bool:
+        """
+        Send a chat message to a peer.
+        Go handles the actual TCP networking.
+
+        Args:
+            peer_addr: Peer address (host:port)
+            message: Text message
+
+        Returns:
+            True if successful
+        """
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_send_chat():
+            chat_msg = self.schema.ChatMessage.new_message()
+            chat_msg.peerAddr = peer_addr
+            chat_msg.message = message
+            chat_msg.timestamp = int(time.time() * 1000)
+            result = await self.service.sendChatMessage(chat_msg)
+            return result.success
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_send_chat(), self._loop)
+            return future.result(timeout=5.0)
+        except Exception as e:
+            logger.error(f"Error sending chat message: {e}")
+            return False
+
+    def connect_stream_peer(self, host: str, port: int) -> Tuple[bool, str]:
+        """
+        Connect to a streaming peer.
+
+        Args:
+            host: Peer host
+            port: Peer port
+
+        Returns:
+            Tuple of (success, peer_address_string)
+        """
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_connect_stream():
+            result = await self.service.connectStreamPeer(host, port)
+            return result.success, result.peerAddr
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(
+                _async_connect_stream(), self._loop
+            )
+            return future.result(timeout=10.0)
+        except Exception as e:
+            logger.error(f"Error connecting to stream peer: {e}")
+            return False, ""
+
+    def get_stream_stats(self) -> Optional[Dict]:
+        """
+        Get streaming statistics.
+
+        Returns:
+            Dict with streaming stats or None
+        """
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_get_stats():
+            result = await self.service.getStreamStats()
+            stats = result.stats
+            return {
+                "framesSent": stats.framesSent,
+                "framesReceived": stats.framesReceived,
+                "bytesSent": stats.bytesSent,
+                "bytesReceived": stats.bytesReceived,
+                "avgLatencyMs": stats.avgLatencyMs,
+            }
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_get_stats(), self._loop)
+            return future.result(timeout=5.0)
+        except Exception as e:
+            logger.error(f"Error getting stream stats: {e}")
+            return None
+
+    def get_received_frames(self, max_frames: int = 30) -> List[Dict]:
+        """
+        Get received video frames from buffer.
+
+        Args:
+            max_frames: Maximum number of frames to retrieve
+
+        Returns:
+            List of frame dictionaries with frameId, data, width, height, quality
+        """
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_get_frames():
+            result = await self.service.getReceivedFrames(max_frames)
+            frames = []
+            for frame in result.frames:
+                frames.append(
+                    {
+                        "frameId": frame.frameId,
+                        "data": bytes(frame.data),
+                        "width": frame.width,
+                        "height": frame.height,
+                        "quality": frame.quality,
+                    }
+                )
+            return frames
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_get_frames(), self._loop)
+            return future.result(timeout=2.0)
+        except Exception as e:
+            logger.error(f"Error getting received frames: {e}")
+            return []
+
+    def get_received_audio(self, max_chunks: int = 50) -> List[Dict]:
+        """
+        Get received audio chunks from buffer.
+
+        Args:
+            max_chunks: Maximum number of chunks to retrieve
+
+        Returns:
+            List of audio chunk dictionaries with data, sampleRate, channels
+        """
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_get_audio():
+            result = await self.service.getReceivedAudio(max_chunks)
+            chunks = []
+            for chunk in result.chunks:
+                chunks.append(
+                    {
+                        "data": bytes(chunk.data),
+                        "sampleRate": chunk.sampleRate,
+                        "channels": chunk.channels,
+                    }
+                )
+            return chunks
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_get_audio(), self._loop)
+            return future.result(timeout=2.0)
+        except Exception as e:
+            logger.error(f"Error getting received audio: {e}")
+            return []
 
     def get_local_multiaddr(self) -> str:
         """
@@ -910,75 +2749,431 @@ class GoNodeClient:
         if not self._connected:
             raise RuntimeError("Not connected to Go node")
 
-        async def _async_list_peers():
-            result = await self.service.listLibp2pPeers()
-            return list(result.peers)
+        async def _async_list_peers():
+            result = await self.service.listLibp2pPeers()
+            return list(result.peers)
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_list_peers(), self._loop)
+            return future.result(timeout=5.0)
+        except Exception as e:
+            logger.error(f"Error listing libp2p peers: {e}")
+            return []
+
+    def search_chat_history(
+        self,
+        peer_id: str = "",
+        text: str = "",
+        from_peer: str = "",
+        since: int = 0,
+        until: int = 0,
+        cursor: str = "",
+        limit: int = 50,
+    ) -> Tuple[List[Dict], str, int]:
+        """Search chat history, newest first.
+
+        Args:
+            peer_id: Conversation to search; empty searches all
+            text: Every word must start a word of the message (case-insensitive)
+            from_peer: Only messages from this sender
+            since: Unix seconds, inclusive (0 = no bound)
+            until: Unix seconds, exclusive (0 = no bound)
+            cursor: next_cursor from the previous page; empty starts at the newest
+            limit: Page size (at most 500)
+
+        Returns:
+            Tuple of (messages, next_cursor, total). next_cursor is empty on the
+            last page; total counts matches across all pages.
+        """
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_search():
+            request = self.service.searchChatHistory_request()
+            query = request.query
+            query.peerId = peer_id
+            query.text = text
+            query.fromPeer = from_peer
+            query.sinceUnix = since
+            query.untilUnix = until
+            query.cursor = cursor
+            query.limit = limit
+
+            result = await request.send()
+            if not result.success:
+                raise RuntimeError(result.errorMsg)
+            messages = [
+                {
+                    "id": m.id,
+                    "from": m.fromPeer,
+                    "to": m.toPeer,
+                    "content": m.content,
+                    "timestamp": m.timestamp,
+                    "tags": list(m.tags),
+                }
+                for m in result.messages
+            ]
+            return messages, result.nextCursor, result.total
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_search(), self._loop)
+            return future.result(timeout=5.0)
+        except Exception as e:
+            logger.error(f"Error searching chat history: {e}")
+            return [], "", 0
+
+    def list_chat_conversations(self) -> List[Dict]:
+        """List peers with chat history, most recent first.
+
+        Returns:
+            List of dicts with peerId, messageCount and lastMessage (Unix seconds)
+        """
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_list():
+            result = await self.service.listChatConversations()
+            return [
+                {
+                    "peerId": c.peerId,
+                    "messageCount": c.messageCount,
+                    "lastMessage": c.lastMessage,
+                }
+                for c in result.conversations
+            ]
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_list(), self._loop)
+            return future.result(timeout=5.0)
+        except Exception as e:
+            logger.error(f"Error listing chat conversations: {e}")
+            return []
+
+    def get_chat_history(self, peer_id: Optional[str] = None) -> List[Dict]:
+        """
+        Get chat history from the Go communication service.
+
+        Pages through search_chat_history, so encrypted history must be
+        unlocked first. Use search_chat_history directly to load long
+        conversations lazily.
+
+        Args:
+            peer_id: Optional peer ID to filter by. If None, returns all history.
+
+        Returns:
+            List of chat message dictionaries, oldest first, with keys:
+            - id: Message ID
+            - from: Sender peer ID
+            - to: Recipient peer ID
+            - content: Message text
+            - timestamp: Unix seconds
+            - tags: Filter tags
+        """
+        messages: List[Dict] = []
+        cursor = ""
+        while True:
+            page, cursor, _ = self.search_chat_history(
+                peer_id=peer_id or "", cursor=cursor, limit=500
+            )
+            messages.extend(page)
+            if not cursor:
+                break
+        messages.reverse()
+        return messages
+
+    # ============================================================
+    # File Transfer Methods
+    # ============================================================
+
+    def send_file(self, peer_id: str, path: str) -> Tuple[Optional[str], str]:
+        """Offer a file to a peer over the direct file transfer protocol.
+
+        The peer receives it once it accepts the offer.
+
+        Args:
+            peer_id: Recipient peer ID
+            path: Path of the file on the Go node's machine
+
+        Returns:
+            Tuple of (transfer_id or None, error_message)
+        """
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_send():
+            result = await self.service.sendFile(peer_id, path)
+            if not result.success:
+                return None, result.errorMsg
+            return result.transferId, ""
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_send(), self._loop)
+            return future.result(timeout=30.0)
+        except Exception as e:
+            logger.error(f"Error sending file: {e}")
+            return None, str(e)
+
+    def accept_file(self, transfer_id: str, dest_path: str = "") -> Tuple[bool, str]:
+        """Accept a file offered by a peer.
+
+        If a partial download exists at dest_path, the transfer resumes from it.
+
+        Args:
+            transfer_id: Transfer ID from the offer
+            dest_path: Where to save the file; empty saves it in ~/.pangea/downloads
+
+        Returns:
+            Tuple of (success, error_message)
+        """
+        return self._success_call("acceptFile", transfer_id, dest_path)
+
+    def pause_transfer(self, transfer_id: str) -> Tuple[bool, str]:
+        """Pause receiving a file, keeping the data received so far."""
+        return self._success_call("pauseTransfer", transfer_id)
+
+    def resume_transfer(self, transfer_id: str) -> Tuple[bool, str]:
+        """Resume receiving a paused file from where it stopped."""
+        return self._success_call("resumeTransfer", transfer_id)
+
+    def _success_call(self, method: str, *args) -> Tuple[bool, str]:
+        """Call an RPC that returns only success and errorMsg."""
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_call():
+            result = await getattr(self.service, method)(*args)
+            return result.success, result.errorMsg
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_call(), self._loop)
+            return future.result(timeout=5.0)
+        except Exception as e:
+            logger.error(f"Error calling {method}: {e}")
+            return False, str(e)
+
+    def get_transfer_status(self, transfer_id: str = "") -> List[Dict]:
+        """Get the state of a file transfer, or of all transfers.
+
+        Args:
+            transfer_id: Transfer ID; empty lists every transfer, newest first
+
+        Returns:
+            List of dicts with transferId, peerId, name, path, size,
+            transferred, outgoing, state, error and updatedAt
+        """
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_status():
+            result = await self.service.getTransferStatus(transfer_id)
+            if not result.success:
+                logger.error(f"Transfer status failed: {result.errorMsg}")
+                return []
+            return [
+                {
+                    "transferId": t.transferId,
+                    "peerId": t.peerId,
+                    "name": t.name,
+                    "path": t.path,
+                    "size": t.size,
+                    "transferred": t.transferred,
+                    "outgoing": t.outgoing,
+                    "state": t.state,
+                    "error": t.error,
+                    "updatedAt": t.updatedAt,
+                }
+                for t in result.transfers
+            ]
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_status(), self._loop)
+            return future.result(timeout=5.0)
+        except Exception as e:
+            logger.error(f"Error getting transfer status: {e}")
+            return []
+
+    # ============================================================
+    # Screen Sharing Methods
+    # ============================================================
+
+    def start_screen_share(self, peer_id: str, keyframe_interval: int = 0) -> Tuple[bool, str]:
+        """Start sharing this node's screen with a peer.
+
+        Screen sharing has its own protocol, separate from camera video.
+
+        Args:
+            peer_id: Viewer peer ID
+            keyframe_interval: Updates between keyframes, 0 = 100
+
+        Returns:
+            Tuple of (success, error_message)
+        """
+        return self._success_call("startScreenShare", peer_id, keyframe_interval)
+
+    def stop_screen_share(self, peer_id: str) -> Tuple[bool, str]:
+        """Stop sharing this node's screen with a peer."""
+        return self._success_call("stopScreenShare", peer_id)
+
+    def send_screen_update(
+        self,
+        peer_id: str,
+        screen_width: int,
+        screen_height: int,
+        rects: List[Dict],
+        keyframe: bool = False,
+    ) -> Tuple[bool, bool, str]:
+        """Send a screen update to a peer.
+
+        The first update, and one every keyframe interval, must be a keyframe
+        covering the screen; other updates carry only the changed regions.
+
+        Args:
+            peer_id: Viewer peer ID
+            screen_width: Screen width in pixels
+            screen_height: Screen height in pixels
+            rects: Regions as dicts with x, y, width, height and data (bytes)
+            keyframe: Whether the update repaints the whole screen
+
+        Returns:
+            Tuple of (success, keyframe_required, error_message), where
+            keyframe_required says whether the next update must be a keyframe
+        """
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_send():
+            request = self.service.sendScreenUpdate_request()
+            request.peerId = peer_id
+            update = request.update
+            update.screenWidth = screen_width
+            update.screenHeight = screen_height
+            update.keyframe = keyframe
+            out = update.init("rects", len(rects))
+            for i, rect in enumerate(rects):
+                out[i].x = rect.get("x", 0)
+                out[i].y = rect.get("y", 0)
+                out[i].width = rect["width"]
+                out[i].height = rect["height"]
+                out[i].data = rect.get("data", b"")
+
+            result = await request.send()
+            return result.success, result.keyframeRequired, result.errorMsg
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_send(), self._loop)
+            return future.result(timeout=5.0)
+        except Exception as e:
+            logger.error(f"Error sending screen update: {e}")
+            return False, True, str(e)
+
+    def set_video_spread(
+        self,
+        enabled: bool,
+        key: bytes = b"",
+        min_frame_bytes: int = 0,
+        relays: Optional[List[str]] = None,
+    ) -> Tuple[bool, str]:
+        """Enable or disable spreading of large keyframes in group calls.
+
+        Spread keyframes are CES-encoded and their fragments sent through
+        several relay peers, so receivers can rebuild them even if some
+        relays leave mid-stream. Everyone in the call must use the same key.
+
+        Args:
+            enabled: Whether to spread keyframes
+            key: 32-byte key shared by the call
+            min_frame_bytes: Smallest keyframe to spread, 0 = 64KB
+            relays: Relay peer IDs, empty = the receivers relay for each other
+
+        Returns:
+            Tuple of (success, error_message)
+        """
+        return self._success_call("setVideoSpread", enabled, key, min_frame_bytes, relays or [])
+
+    def send_group_video_frame(
+        self,
+        peer_ids: List[str],
+        frame_id: int,
+        data: bytes,
+        width: int = 640,
+        height: int = 480,
+        quality: int = 60,
+        keyframe: bool = False,
+    ) -> Tuple[bool, str]:
+        """Send a video frame to every peer in a group call over libp2p.
+
+        Large keyframes are spread when set_video_spread is enabled.
+
+        Returns:
+            Tuple of (success, error_message)
+        """
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_send():
+            request = self.service.sendGroupVideoFrame_request()
+            peers = request.init("peerIds", len(peer_ids))
+            for i, peer_id in enumerate(peer_ids):
+                peers[i] = peer_id
+            frame = request.frame
+            frame.frameId = frame_id
+            frame.data = data
+            frame.width = width
+            frame.height = height
+            frame.quality = quality
+            frame.keyframe = keyframe
+            result = await request.send()
+            return result.success, result.errorMsg
 
         try:
-            future = asyncio.run_coroutine_threadsafe(_async_list_peers(), self._loop)
+            future = asyncio.run_coroutine_threadsafe(_async_send(), self._loop)
             return future.result(timeout=5.0)
         except Exception as e:
-            logger.error(f"Error listing libp2p peers: {e}")
-            return []
-
-    def get_chat_history(self, peer_id: Optional[str] = None) -> List[Dict]:
-        """
-        Get chat history from the Go communication service.
-
-        This method reads the chat history from the local file stored by the
-        Go communication service. The file is located at:
-        ~/.pangea/communication/chat_history.json
+            logger.error(f"Error sending group video frame: {e}")
+            return False, str(e)
 
-        If an RPC method for getChatHistory is added to the Cap'n Proto schema
-        and implemented in Go, this method can be updated to use that instead.
+    def get_screen_updates(self, max_updates: int = 0) -> List[Dict]:
+        """Collect screen updates received from peers, oldest first.
 
         Args:
-            peer_id: Optional peer ID to filter by. If None, returns all history.
+            max_updates: Most updates to return, 0 = all queued
 
         Returns:
-            List of chat message dictionaries with keys:
-            - id: Message ID
-            - from: Sender peer ID
-            - to: Recipient peer ID
-            - content: Message text
-            - timestamp: ISO timestamp
+            List of dicts with fromPeer, seq, screenWidth, screenHeight,
+            keyframe, timestamp (Unix ms) and rects
         """
-        import json
-        import os
-
-        # Path to chat history file stored by Go communication service
-        home_dir = os.path.expanduser("~")
-        history_file = os.path.join(
-            home_dir, ".pangea", "communication", "chat_history.json"
-        )
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
 
-        if not os.path.exists(history_file):
-            logger.info(f"Chat history file not found at {history_file}")
-            return []
+        async def _async_get():
+            result = await self.service.getScreenUpdates(max_updates)
+            return [
+                {
+                    "fromPeer": u.fromPeer,
+                    "seq": u.seq,
+                    "screenWidth": u.screenWidth,
+                    "screenHeight": u.screenHeight,
+                    "keyframe": u.keyframe,
+                    "timestamp": u.timestamp,
+                    "rects": [
+                        {
+                            "x": r.x,
+                            "y": r.y,
+                            "width": r.width,
+                            "height": r.height,
+                            "data": bytes(r.data),
+                        }
+                        for r in u.rects
+                    ],
+                }
+                for u in result.updates
+            ]
 
         try:
-            with open(history_file, "r") as f:
-                history = json.load(f)
-
-            # history is a dict mapping peer_id -> list of messages
-            if peer_id:
-                # Return messages for specific peer
-                messages = history.get(peer_id, [])
-                return messages
-            else:
-                # Flatten all messages from all peers
-                all_messages = []
-                for pid, messages in history.items():
-                    all_messages.extend(messages)
-                # Sort by timestamp
-                all_messages.sort(key=lambda m: m.get("timestamp", ""))
-                return all_messages
-
-        except json.JSONDecodeError as e:
-            logger.error(f"Failed to parse chat history: {e}")
-            return []
+            future = asyncio.run_coroutine_threadsafe(_async_get(), self._loop)
+            return future.result(timeout=5.0)
         except Exception as e:
-            logger.error(f"Error reading chat history: {e}")
+            logger.error(f"Error getting screen updates: {e}")
             return []
 
     # ============================================================
@@ -994,17 +3189,20 @@ class GoNodeClient:
         max_chunk_size: int = 65536,
         timeout_secs: int = 300,
         priority: int = 5,
+        record_replay: bool = False,
     ) -> Tuple[bool, str]:
         """Submit a compute job to the Go orchestrator.
 
         Args:
             job_id: Unique job identifier
             input_data: The input data to process
-            split_strategy: How to split data ("fixed", "adaptive", "semantic")
+            split_strategy: How to split data ("fixed_size", "matrix_rows", "lines", "records")
             min_chunk_size: Minimum chunk size in bytes
             max_chunk_size: Maximum chunk size in bytes
             timeout_secs: Job timeout in seconds
             priority: Job priority (1-10, higher = more important)
+            record_replay: Keep chunk inputs and result hashes so that
+                replay_job can re-check workers' results later
 
         Returns:
             Tuple of (success, error_message)
@@ -1025,6 +3223,7 @@ class GoNodeClient:
             manifest.retryCount = 3
             manifest.priority = priority
             manifest.redundancy = 1
+            manifest.recordReplay = record_replay
 
             result = await self.service.submitComputeJob(manifest)
             return result.success, result.errorMsg
@@ -1036,6 +3235,51 @@ class GoNodeClient:
             logger.error(f"Error submitting compute job: {e}")
             return False, str(e)
 
+    def submit_template_job(
+        self,
+        template: str,
+        job_id: str,
+        input_data: bytes,
+        parameters: Optional[Dict[str, str]] = None,
+        version: int = 0,
+    ) -> Tuple[bool, str]:
+        """Submit a compute job built from a job template stored on the node.
+
+        Args:
+            template: Template name
+            job_id: Unique job identifier
+            input_data: The input data to process
+            parameters: Overrides of template defaults, e.g. {"max_chunk_size": "65536"}
+            version: Template version (0 = latest)
+
+        Returns:
+            Tuple of (success, error_message)
+        """
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_submit():
+            params = list((parameters or {}).items())
+            request = self.service.submitTemplateJob_request()
+            request.name = template
+            request.version = version
+            request.jobId = job_id
+            request.inputData = input_data
+            entries = request.init("parameters", len(params))
+            for i, (key, value) in enumerate(params):
+                entries[i].key = key
+                entries[i].value = str(value)
+
+            result = await request.send()
+            return result.success, result.errorMsg
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_submit(), self._loop)
+            return future.result(timeout=10.0)
+        except Exception as e:
+            logger.error(f"Error submitting template job: {e}")
+            return False, str(e)
+
     def get_compute_job_status(self, job_id: str) -> Optional[Dict]:
         """Get status of a compute job.
 
@@ -1059,6 +3303,23 @@ class GoNodeClient:
                 "totalChunks": status.totalChunks,
                 "estimatedTimeRemaining": status.estimatedTimeRemaining,
                 "errorMsg": status.errorMsg,
+                "chunks": [
+                    {
+                        "index": chunk.index,
+                        "status": chunk.status,
+                        "assignedWorker": chunk.assignedWorker,
+                        "attempts": [
+                            {
+                                "workerId": a.workerId,
+                                "startedAt": a.startedAt,
+                                "durationMs": a.durationMs,
+                                "errorMsg": a.errorMsg,
+                            }
+                            for a in chunk.attempts
+                        ],
+                    }
+                    for chunk in status.chunks
+                ],
             }
 
         try:
@@ -1068,6 +3329,32 @@ class GoNodeClient:
             logger.error(f"Error getting compute job status: {e}")
             return None
 
+    def list_compute_jobs(self) -> List[Dict]:
+        """List the status of every compute job the node knows, oldest first."""
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_list_jobs():
+            result = await self.service.listComputeJobs()
+            return [
+                {
+                    "jobId": status.jobId,
+                    "status": status.status,
+                    "progress": status.progress,
+                    "completedChunks": status.completedChunks,
+                    "totalChunks": status.totalChunks,
+                    "estimatedTimeRemaining": status.estimatedTimeRemaining,
+                }
+                for status in result.jobs
+            ]
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_list_jobs(), self._loop)
+            return future.result(timeout=5.0)
+        except Exception as e:
+            logger.error(f"Error listing compute jobs: {e}")
+            return []
+
     def get_compute_job_result(
         self, job_id: str, timeout_ms: int = 60000
     ) -> Tuple[Optional[bytes], str, str]:
@@ -1123,6 +3410,159 @@ class GoNodeClient:
             logger.error(f"Error cancelling compute job: {e}")
             return False
 
+    def get_replay_log(self, job_id: str) -> Tuple[Optional[List[Dict]], str]:
+        """Get the replay log of a job submitted with record_replay.
+
+        Args:
+            job_id: The job identifier
+
+        Returns:
+            Tuple of (entries or None, error_message). Each entry has
+            chunkIndex, workerId, inputHash, resultHash, accepted,
+            startedAt (Unix ms), durationMs and errorMsg.
+        """
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_get_replay_log():
+            result = await self.service.getReplayLog(job_id)
+            if not result.success:
+                return None, result.errorMsg
+            return [
+                {
+                    "chunkIndex": e.chunkIndex,
+                    "workerId": e.workerId,
+                    "inputHash": e.inputHash,
+                    "resultHash": e.resultHash,
+                    "accepted": e.accepted,
+                    "startedAt": e.startedAt,
+                    "durationMs": e.durationMs,
+                    "errorMsg": e.errorMsg,
+                }
+                for e in result.entries
+            ], ""
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_get_replay_log(), self._loop)
+            return future.result(timeout=5.0)
+        except Exception as e:
+            logger.error(f"Error getting replay log: {e}")
+            return None, str(e)
+
+    def replay_job(
+        self, job_id: str, chunks: Optional[List[int]] = None, timeout: float = 60.0
+    ) -> Tuple[Optional[List[Dict]], str]:
+        """Re-execute recorded chunks of a job on the node and check workers' results.
+
+        Args:
+            job_id: The job identifier
+            chunks: Chunk indexes to replay; None or empty replays every
+                chunk with a recorded result
+            timeout: Seconds to wait for the re-execution
+
+        Returns:
+            Tuple of (verdicts or None, error_message). Each verdict has
+            chunkIndex, workerId, accepted, recordedHash, localHash, match
+            and errorMsg.
+        """
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_replay():
+            result = await self.service.replayJob(job_id, chunks or [])
+            if not result.success:
+                return None, result.errorMsg
+            return [
+                {
+                    "chunkIndex": v.chunkIndex,
+                    "workerId": v.workerId,
+                    "accepted": v.accepted,
+                    "recordedHash": v.recordedHash,
+                    "localHash": v.localHash,
+                    "match": v.match,
+                    "errorMsg": v.errorMsg,
+                }
+                for v in result.verdicts
+            ], ""
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_replay(), self._loop)
+            return future.result(timeout=timeout)
+        except Exception as e:
+            logger.error(f"Error replaying job: {e}")
+            return None, str(e)
+
+    def subscribe_job_events(
+        self, callback: Callable[[Dict], None], job_id: str = ""
+    ) -> Tuple[Optional[int], str]:
+        """Subscribe to progress events of a compute job, pushed by the Go node.
+
+        Event types: job_state_changed, chunk_completed and chunk_failed. A
+        job subscription opens with the job's current state and ends by
+        itself after the job completes, fails or is cancelled.
+
+        Args:
+            callback: Called with a dict of seq, jobId, type, status,
+                chunkIndex, workerId, completedChunks, totalChunks, progress,
+                errorMsg and timestamp (Unix ms) for each event
+            job_id: The job to follow; empty follows every job
+
+        Returns:
+            Tuple of (subscription_id or None, error_message)
+        """
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        class _Listener(self.schema.JobEventListener.Server):
+            async def onJobEvent(self, event, **kwargs):
+                try:
+                    callback(
+                        {
+                            "seq": event.seq,
+                            "jobId": event.jobId,
+                            "type": event.type,
+                            "status": event.status,
+                            "chunkIndex": event.chunkIndex,
+                            "workerId": event.workerId,
+                            "completedChunks": event.completedChunks,
+                            "totalChunks": event.totalChunks,
+                            "progress": event.progress,
+                            "errorMsg": event.errorMsg,
+                            "timestamp": event.timestamp,
+                        }
+                    )
+                except Exception as e:
+                    logger.error(f"Error in job event callback: {e}")
+
+        async def _async_subscribe():
+            result = await self.service.subscribeJobEvents(job_id, _Listener())
+            if not result.success:
+                return None, result.errorMsg
+            return result.subscriptionId, ""
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_subscribe(), self._loop)
+            return future.result(timeout=5.0)
+        except Exception as e:
+            logger.error(f"Error subscribing to job events: {e}")
+            return None, str(e)
+
+    def unsubscribe_job_events(self, subscription_id: int) -> bool:
+        """End a job event subscription."""
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_unsubscribe():
+            result = await self.service.unsubscribeJobEvents(subscription_id)
+            return result.success
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_unsubscribe(), self._loop)
+            return future.result(timeout=5.0)
+        except Exception as e:
+            logger.error(f"Error unsubscribing from job events: {e}")
+            return False
+
     def get_compute_capacity(self) -> Optional[Dict]:
         """Get compute capacity of the connected node.
 
@@ -1150,6 +3590,36 @@ class GoNodeClient:
             logger.error(f"Error getting compute capacity: {e}")
             return None
 
+    def get_queue_stats(self) -> Optional[Dict]:
+        """Get the compute job admission queue: jobs running, waiting and refused.
+
+        Returns:
+            Dict with queue stats or None if error
+        """
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_get_queue_stats():
+            result = await self.service.getQueueStats()
+            stats = result.stats
+            return {
+                "running": stats.running,
+                "queued": stats.queued,
+                "pending": stats.pending,
+                "maxConcurrent": stats.maxConcurrent,
+                "maxQueued": stats.maxQueued,
+                "admitted": stats.admitted,
+                "rejected": stats.rejected,
+                "avgWaitMs": stats.avgWaitMs,
+            }
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_get_queue_stats(), self._loop)
+            return future.result(timeout=5.0)
+        except Exception as e:
+            logger.error(f"Error getting queue stats: {e}")
+            return None
+
     # ========================================================================
     # Mandate 3: Security, Ephemeral Chat, and ML RPC Methods
     # ========================================================================
@@ -1273,13 +3743,19 @@ class GoNodeClient:
             return False, str(e)
 
     def start_chat_session(
-        self, peer_addr: str, encryption_type: str = "asymmetric"
+        self,
+        peer_addr: str,
+        encryption_type: str = "asymmetric",
+        message_ttl_secs: int = 0,
+        burn_on_read: bool = False,
     ) -> Tuple[bool, Optional[str], str]:
         """Start an ephemeral chat session with a peer.
 
         Args:
             peer_addr: Peer address (e.g., "worker1:8080")
             encryption_type: Type of encryption (asymmetric, symmetric, none)
+            message_ttl_secs: Message retention, 0 = the node default (24h)
+            burn_on_read: Delete messages as soon as they are first read
 
         Returns:
             Tuple of (success, session_id, error_message)
@@ -1290,6 +3766,8 @@ class GoNodeClient:
         async def _async_start_chat():
             request = self.service.startChatSession_request()
             request.peerAddr = peer_addr
+            request.messageTtlSecs = message_ttl_secs
+            request.burnOnRead = burn_on_read
 
             enc_config = request.encryptionConfig
             enc_config.encryptionType = encryption_type
@@ -1310,7 +3788,12 @@ class GoNodeClient:
             return False, None, str(e)
 
     def send_ephemeral_message(
-        self, session_id: str, from_peer: str, to_peer: str, message: bytes
+        self,
+        session_id: str,
+        from_peer: str,
+        to_peer: str,
+        message: bytes,
+        ttl_secs: int = 0,
     ) -> Tuple[bool, str]:
         """Send an encrypted ephemeral message.
 
@@ -1319,6 +3802,7 @@ class GoNodeClient:
             from_peer: Sender peer address
             to_peer: Recipient peer address
             message: Message content (will be encrypted)
+            ttl_secs: Per-message TTL, 0 = the receiving session's retention
 
         Returns:
             Tuple of (success, error_message)
@@ -1328,6 +3812,7 @@ class GoNodeClient:
 
         async def _async_send_message():
             request = self.service.sendEphemeralMessage_request()
+            request.sessionId = session_id
             msg = request.message
             msg.fromPeer = from_peer
             msg.toPeer = to_peer
@@ -1335,6 +3820,7 @@ class GoNodeClient:
             msg.timestamp = int(time.time())
             msg.messageId = f"msg-{int(time.time())}"
             msg.encryptionType = "asymmetric"
+            msg.ttlSecs = ttl_secs
 
             result = await request.send()
             error_msg = result.errorMsg if hasattr(result, "errorMsg") else ""
@@ -1347,11 +3833,15 @@ class GoNodeClient:
             logger.error(f"Error sending ephemeral message: {e}")
             return False, str(e)
 
-    def receive_chat_messages(self, session_id: str) -> List[Dict]:
-        """Receive messages from a chat session.
+    def receive_chat_messages(
+        self, session_id: str, include_read: bool = False
+    ) -> List[Dict]:
+        """Receive unread messages from a chat session.
 
         Args:
             session_id: Chat session ID
+            include_read: Also return messages already read that the
+                session still retains
 
         Returns:
             List of message dictionaries
@@ -1362,6 +3852,7 @@ class GoNodeClient:
         async def _async_receive_messages():
             request = self.service.receiveChatMessages_request()
             request.sessionId = session_id
+            request.includeRead = include_read
 
             result = await request.send()
             messages = []
@@ -1374,6 +3865,8 @@ class GoNodeClient:
                         "timestamp": msg.timestamp,
                         "messageId": msg.messageId,
                         "encryptionType": msg.encryptionType,
+                        "ttlSecs": msg.ttlSecs,
+                        "expiresAt": msg.expiresAt,
                     }
                 )
             return messages
@@ -1396,6 +3889,7 @@ class GoNodeClient:
         aggregator_node: str,
         epochs: int = 10,
         batch_size: int = 32,
+        privacy: Optional[Dict[str, float]] = None,
     ) -> Tuple[bool, str]:
         """Start distributed ML training task.
 
@@ -1407,6 +3901,8 @@ class GoNodeClient:
             aggregator_node: Aggregator node address
             epochs: Number of training epochs
             batch_size: Batch size
+            privacy: Differential privacy settings: clip_norm,
+                noise_multiplier, and optionally delta and max_epsilon
 
         Returns:
             Tuple of (success, error_message)
@@ -1429,6 +3925,13 @@ class GoNodeClient:
             for i, worker in enumerate(worker_nodes):
                 workers[i] = worker
 
+            if privacy:
+                dp = task.init("privacy")
+                dp.clipNorm = privacy["clip_norm"]
+                dp.noiseMultiplier = privacy.get("noise_multiplier", 0.0)
+                dp.delta = privacy.get("delta", 0.0)
+                dp.maxEpsilon = privacy.get("max_epsilon", 0.0)
+
             result = await request.send()
             error_msg = result.errorMsg if hasattr(result, "errorMsg") else ""
             return result.success, error_msg
@@ -1469,6 +3972,13 @@ class GoNodeClient:
                 "currentLoss": status.currentLoss,
                 "currentAccuracy": status.currentAccuracy,
                 "estimatedTimeRemaining": status.estimatedTimeRemaining,
+                "privacyEnabled": status.privacyEnabled,
+                "privacyRounds": status.privacyRounds,
+                "privacyEpsilon": status.privacyEpsilon,
+                "privacyDelta": status.privacyDelta,
+                "gradientWireBytes": status.gradientWireBytes,
+                "gradientRawBytes": status.gradientRawBytes,
+                "bandwidthSavings": status.bandwidthSavings,
             }
 
         try:
@@ -1477,3 +3987,234 @@ class GoNodeClient:
         except Exception as e:
             logger.error(f"Error getting ML training status: {e}")
             return None
+
+    def ml_heartbeat(self, worker_id: str) -> Tuple[bool, bool, str]:
+        """Tell the aggregator an ML worker is alive.
+
+        Args:
+            worker_id: Worker identifier
+
+        Returns:
+            Tuple of (success, readmitted, error_message); readmitted is True
+            when a worker previously marked failed has rejoined its task
+        """
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_heartbeat():
+            request = self.service.mlHeartbeat_request()
+            request.workerId = worker_id
+
+            result = await request.send()
+            return result.success, result.readmitted, result.errorMsg
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_heartbeat(), self._loop)
+            return future.result(timeout=5.0)
+        except Exception as e:
+            logger.error(f"Error sending ML heartbeat: {e}")
+            return False, False, str(e)
+
+    # ============================================================
+    # Event Methods
+    # ============================================================
+
+    def subscribe_events(
+        self, callback: Callable[[Dict], None], types: Optional[List[str]] = None
+    ) -> Tuple[Optional[int], str]:
+        """Subscribe to node events pushed by the Go node.
+
+        Event types: peer_connected, peer_disconnected, shard_stored,
+        job_finished, chat_received, nat_changed and peer_key_changed.
+
+        Args:
+            callback: Called with a dict of seq, type, timestamp (Unix ms),
+                peerId, subject and attributes for each event
+            types: Event types to receive; None or empty receives all
+
+        Returns:
+            Tuple of (subscription_id or None, error_message)
+        """
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        class _Listener(self.schema.EventListener.Server):
+            async def onEvent(self, event, **kwargs):
+                try:
+                    callback(
+                        {
+                            "seq": event.seq,
+                            "type": event.type,
+                            "timestamp": event.timestamp,
+                            "peerId": event.peerId,
+                            "subject": event.subject,
+                            "attributes": {kv.key: kv.value for kv in event.attributes},
+                        }
+                    )
+                except Exception as e:
+                    logger.error(f"Error in event callback: {e}")
+
+        async def _async_subscribe():
+            result = await self.service.subscribeEvents(types or [], _Listener())
+            if not result.success:
+                return None, result.errorMsg
+            return result.subscriptionId, ""
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_subscribe(), self._loop)
+            return future.result(timeout=5.0)
+        except Exception as e:
+            logger.error(f"Error subscribing to events: {e}")
+            return None, str(e)
+
+    def unsubscribe_events(self, subscription_id: int) -> bool:
+        """End an event subscription."""
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_unsubscribe():
+            result = await self.service.unsubscribeEvents(subscription_id)
+            return result.success
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_unsubscribe(), self._loop)
+            return future.result(timeout=5.0)
+        except Exception as e:
+            logger.error(f"Error unsubscribing from events: {e}")
+            return False
+
+    def add_webhook(
+        self, url: str, secret: str = "", types: Optional[List[str]] = None
+    ) -> Tuple[Optional[str], str]:
+        """Deliver node events to an HTTP endpoint as JSON POSTs.
+
+        With a secret, each body is signed with HMAC-SHA256 in the
+        X-Pangea-Signature header ("sha256=<hex>").
+
+        Args:
+            url: http or https URL
+            secret: Signing secret, empty for unsigned delivery
+            types: Event types to deliver; None or empty delivers all
+
+        Returns:
+            Tuple of (webhook_id or None, error_message)
+        """
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_add():
+            result = await self.service.addWebhook(url, secret, types or [])
+            if not result.success:
+                return None, result.errorMsg
+            return result.webhookId, ""
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_add(), self._loop)
+            return future.result(timeout=5.0)
+        except Exception as e:
+            logger.error(f"Error adding webhook: {e}")
+            return None, str(e)
+
+    def remove_webhook(self, webhook_id: str) -> bool:
+        """Stop delivering events to a webhook."""
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_remove():
+            result = await self.service.removeWebhook(webhook_id)
+            return result.success
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_remove(), self._loop)
+            return future.result(timeout=5.0)
+        except Exception as e:
+            logger.error(f"Error removing webhook: {e}")
+            return False
+
+    def list_webhooks(self) -> List[Dict]:
+        """List registered webhooks with id, url, types, delivered and failed."""
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_list():
+            result = await self.service.listWebhooks()
+            return [
+                {
+                    "id": w.id,
+                    "url": w.url,
+                    "types": list(w.types),
+                    "delivered": w.delivered,
+                    "failed": w.failed,
+                }
+                for w in result.webhooks
+            ]
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_list(), self._loop)
+            return future.result(timeout=5.0)
+        except Exception as e:
+            logger.error(f"Error listing webhooks: {e}")
+            return []
+
+    def get_health(self) -> Optional[Dict]:
+        """Get node health: state (ok, degraded or down), ready, uptime_secs
+        and per-subsystem components with their last error."""
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_health():
+            result = await self.service.getHealth()
+            if not result.success:
+                logger.error(f"Health check failed: {result.errorMsg}")
+                return None
+            return {
+                "state": result.state,
+                "ready": result.ready,
+                "uptime_secs": result.uptimeSecs,
+                "components": [
+                    {
+                        "name": c.name,
+                        "state": c.state,
+                        "critical": c.critical,
+                        "since": c.since,
+                        "last_error": c.lastError,
+                        "last_error_at": c.lastErrorAt,
+                    }
+                    for c in result.components
+                ],
+            }
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_health(), self._loop)
+            return future.result(timeout=5.0)
+        except Exception as e:
+            logger.error(f"Error getting health: {e}")
+            return None
+
+    def list_bootstrap_peers(self) -> Tuple[List[str], bool]:
+        """Get the DHT bootstrap peers and whether the node is on a private network.
+
+        Returns:
+            Tuple of (multiaddrs ending in /p2p/<peerId>, private_network)
+        """
+        if not self._connected:
+            raise RuntimeError("Not connected to Go node")
+
+        async def _async_list():
+            result = await self.service.listBootstrapPeers()
+            return list(result.peers), result.privateNetwork
+
+        try:
+            future = asyncio.run_coroutine_threadsafe(_async_list(), self._loop)
+            return future.result(timeout=5.0)
+        except Exception as e:
+            logger.error(f"Error listing bootstrap peers: {e}")
+            return [], False
+
+    def add_bootstrap_peer(self, addr: str) -> Tuple[bool, str]:
+        """Add a bootstrap peer multiaddr ending in /p2p/<peerId> and dial it."""
+        return self._success_call("addBootstrapPeer", addr)
+
+    def remove_bootstrap_peer(self, addr: str) -> Tuple[bool, str]:
+        """Remove a bootstrap peer, given by multiaddr or peer ID."""
+        return self._success_call("removeBootstrapPeer", addr)
@@ -9,7 +9,7 @@ import logging
 import time
 from dataclasses import dataclass
 from enum import IntEnum
-from typing import Dict, List, Optional, Any, Tuple
+from typing import Callable, Dict, List, Optional, Any, Tuple
 from pathlib import Path
 import sys
 
@@ -32,6 +32,19 @@ class TaskStatus(IntEnum):
     CANCELLED = 7
 
 
+# Go status strings as TaskStatus
+_GO_STATUS = {
+    "pending": TaskStatus.PENDING,
+    "assigned": TaskStatus.ASSIGNED,
+    "computing": TaskStatus.COMPUTING,
+    "verifying": TaskStatus.VERIFYING,
+    "completed": TaskStatus.COMPLETED,
+    "failed": TaskStatus.FAILED,
+    "timeout": TaskStatus.TIMEOUT,
+    "cancelled": TaskStatus.CANCELLED,
+}
+
+
 @dataclass
 class JobStatus:
     """Status of a compute job."""
@@ -67,13 +80,9 @@ class ComputeClient:
         client.connect()
 
         job_id = client.submit_job(my_job_definition, input_data)
-
-        while True:
-            status = client.get_status(job_id)
-            print(f"Progress: {status.progress * 100:.1f}%")
-            if status.status == TaskStatus.COMPLETED:
-                break
-            time.sleep(1)
+        client.watch_job(
+            job_id, lambda status: print(f"Progress: {status.progress * 100:.1f}%")
+        )
 
         result, worker_node = client.get_result(job_id)
         print(f"Executed by: {worker_node}")
@@ -259,17 +268,7 @@ class ComputeClient:
 
         if status_dict:
             # Map Go status string to TaskStatus enum
-            status_map = {
-                "pending": TaskStatus.PENDING,
-                "assigned": TaskStatus.ASSIGNED,
-                "computing": TaskStatus.COMPUTING,
-                "verifying": TaskStatus.VERIFYING,
-                "completed": TaskStatus.COMPLETED,
-                "failed": TaskStatus.FAILED,
-                "timeout": TaskStatus.TIMEOUT,
-                "cancelled": TaskStatus.CANCELLED,
-            }
-            status = status_map.get(status_dict["status"].lower(), TaskStatus.PENDING)
+            status = _GO_STATUS.get(status_dict["status"].lower(), TaskStatus.PENDING)
 
             return JobStatus(
                 job_id=job_id,
@@ -293,6 +292,61 @@ class ComputeClient:
             error=job.get("error"),
         )
 
+    def watch_job(self, job_id: str, callback: Callable[[JobStatus], None]) -> Optional[int]:
+        """Have the Go orchestrator push a job's status as it progresses.
+
+        The callback runs with the job's current status, then each time the
+        job changes state or a chunk completes or fails, until the job ends.
+        It runs on the RPC thread, so it should return quickly.
+
+        Args:
+            job_id: The job ID
+            callback: Called with a JobStatus for each update
+
+        Returns:
+            Subscription ID for unwatch_job, or None if the job cannot be
+            watched
+        """
+        if not self._connected:
+            raise ConnectionError("Not connected to compute service")
+
+        go_client = self._go_client
+        assert go_client is not None
+
+        def _on_event(event: Dict):
+            callback(
+                JobStatus(
+                    job_id=event["jobId"],
+                    status=_GO_STATUS.get(event["status"].lower(), TaskStatus.PENDING),
+                    progress=event["progress"],
+                    completed_chunks=event["completedChunks"],
+                    total_chunks=event["totalChunks"],
+                    estimated_time_remaining=0,
+                    error=event["errorMsg"] or None,
+                )
+            )
+
+        subscription_id, error_msg = go_client.subscribe_job_events(_on_event, job_id)
+        if subscription_id is None:
+            logger.warning(f"Cannot watch job {job_id}: {error_msg}")
+        return subscription_id
+
+    def unwatch_job(self, subscription_id: int) -> bool:
+        """Stop the updates started by watch_job.
+
+        Args:
+            subscription_id: ID returned by watch_job
+
+        Returns:
+            True if the updates were still running
+        """
+        if not self._connected:
+            raise ConnectionError("Not connected to compute service")
+
+        go_client = self._go_client
+        assert go_client is not None
+        return go_client.unsubscribe_job_events(subscription_id)
+
     def get_result(self, job_id: str, timeout: float = 300.0) -> Tuple[bytes, str]:
         """Get the result of a completed job from Go orchestrator.
 
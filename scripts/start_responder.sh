@@ -97,6 +97,7 @@ export DYLD_LIBRARY_PATH="$PROJECT_ROOT/rust/target/release:$DYLD_LIBRARY_PATH"
     -capnp-addr=0.0.0.0:8081 \
     -libp2p=true \
     -libp2p-port=7778 \
+    -compute-worker \
     -test \
     -peers="$PEER_ADDR" \
     > "$LOG_FILE" 2>&1 &
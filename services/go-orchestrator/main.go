@@ -9,32 +9,52 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/pangea-net/go-node/pkg/compute"
+	"github.com/pangea-net/go-orchestrator/pkg/cluster"
 	"github.com/pangea-net/go-orchestrator/pkg/gradient"
 	"github.com/pangea-net/go-orchestrator/pkg/metrics"
 	"github.com/pangea-net/go-orchestrator/pkg/observability"
+	"github.com/pangea-net/go-orchestrator/pkg/rpcserver"
+	"github.com/pangea-net/go-orchestrator/pkg/workerpool"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
 )
 
 // OrchestratorConfig holds configuration for the orchestrator
 type OrchestratorConfig struct {
-	ID               uint32
-	RpcAddr          string
-	ListenPort       string
-	MaxWorkers       int
-	GracefulShutdown time.Duration
+	ID                uint32
+	RpcAddr           string
+	MetricsAddr       string
+	TrainingAddr      string // JSON worker and federated training API; empty disables it
+	TrainingToken     string // Bearer token the training API requires
+	TrainingURL       string // Training API URL advertised to cluster peers
+	AdvertiseAddr     string // gRPC address advertised to cluster peers
+	ClusterPeers      []cluster.Peer
+	ClusterToken      string // Secret cluster peers authenticate with
+	LeaseDuration     time.Duration
+	ListenPort        string
+	MaxWorkers        int
+	HeartbeatInterval time.Duration
+	GracefulShutdown  time.Duration
 }
 
 // Orchestrator represents the RPC server and coordination logic
 type Orchestrator struct {
 	config          *OrchestratorConfig
 	listener        net.Listener
+	grpcServer      *grpc.Server
 	ctx             context.Context
 	cancel          context.CancelFunc
 	gradientManager *gradient.Manager
+	computeManager  *compute.Manager
+	workers         *workerpool.Pool
 	obsManager      *observability.Manager
+	clusterNode     *cluster.Node // nil = a lone orchestrator
 }
 
 // NewOrchestrator creates a new orchestrator instance
@@ -45,11 +65,20 @@ func NewOrchestrator(cfg *OrchestratorConfig) *Orchestrator {
 	obsConfig := observability.LoadConfigFromEnv()
 	obsManager := observability.NewManager(obsConfig)
 
+	// Workers silent for three heartbeat intervals are dropped
+	workers := workerpool.NewPool(3*cfg.HeartbeatInterval, cfg.MaxWorkers)
+	workers.SetCountCallback(func(n int) { metrics.ActiveWorkers.Set(float64(n)) })
+
+	computeManager := compute.NewManager(compute.DefaultConfig())
+	metrics.RegisterCompute(computeManager)
+
 	return &Orchestrator{
 		config:          cfg,
 		ctx:             ctx,
 		cancel:          cancel,
 		gradientManager: gradient.NewManager(),
+		computeManager:  computeManager,
+		workers:         workers,
 		obsManager:      obsManager,
 	}
 }
@@ -68,77 +97,119 @@ func (o *Orchestrator) Start() error {
 	}
 
 	log.Printf("🚀 Go Orchestrator started (ID: %d)", o.config.ID)
-	log.Printf("📡 RPC server listening on %s", o.config.RpcAddr)
+	log.Printf("📡 gRPC server listening on %s", o.config.RpcAddr)
 	log.Printf("🤝 Max workers: %d", o.config.MaxWorkers)
 	log.Printf("⏱️  Graceful shutdown timeout: %v", o.config.GracefulShutdown)
 
+	// Serve the gRPC service; chunks of submitted jobs go to the workers
+	// and gradients from go-nodes to the gradient manager
+	service := rpcserver.New(o.workers, o.computeManager, o.gradientManager, o.config.HeartbeatInterval)
+	if len(o.config.ClusterPeers) > 0 {
+		if err := o.joinCluster(service); err != nil {
+			return err
+		}
+	}
+	o.grpcServer = grpc.NewServer(
+		grpc.MaxRecvMsgSize(rpcserver.MaxMessageSize),
+		grpc.ChainUnaryInterceptor(rpcserver.MetricsInterceptor, o.obsManager.TracingInterceptor, service.LeaderInterceptor),
+	)
+	service.Register(o.grpcServer)
+	go o.workers.Run(o.ctx)
+	go o.gradientManager.Run(o.ctx)
+	if o.clusterNode != nil {
+		go o.clusterNode.Run(o.ctx)
+	}
+
 	// Start Prometheus metrics server
 	go o.startMetricsServer()
 
-	// Simulate accepting connections (actual implementation would handle gRPC/Cap'n Proto)
-	go o.acceptConnections()
+	// go-nodes without a gRPC stack reach the worker and federated
+	// training methods through the JSON API, on its own authenticated
+	// address
+	if o.config.TrainingAddr != "" {
+		if o.config.TrainingToken == "" {
+			return fmt.Errorf("training API on %s requires a token", o.config.TrainingAddr)
+		}
+		go o.startTrainingServer(service.JSONHandler(o.config.TrainingToken))
+	}
+	go func() {
+		if err := o.grpcServer.Serve(o.listener); err != nil {
+			log.Printf("❌ gRPC server error: %v", err)
+		}
+	}()
 
 	return nil
 }
 
+// joinCluster makes this orchestrator one of a cluster that elects a
+// leader for job and training coordination. Followers hold a replica of
+// the leader's training state and take over from it if it fails.
+func (o *Orchestrator) joinCluster(service *rpcserver.Server) error {
+	if o.config.ClusterToken == "" {
+		return fmt.Errorf("a cluster requires ORCHESTRATOR_CLUSTER_TOKEN")
+	}
+	if o.config.AdvertiseAddr == "" {
+		return fmt.Errorf("a cluster requires -advertise-addr")
+	}
+	node, err := cluster.New(cluster.Config{
+		ID:            o.config.ID,
+		Addr:          o.config.AdvertiseAddr,
+		APIURL:        o.config.TrainingURL,
+		Peers:         o.config.ClusterPeers,
+		LeaseDuration: o.config.LeaseDuration,
+	}, rpcserver.NewGRPCTransport(o.config.ClusterToken), o.gradientManager)
+	if err != nil {
+		return fmt.Errorf("invalid cluster configuration: %w", err)
+	}
+
+	// Only the leader times out training rounds
+	o.gradientManager.SetStandby(true)
+	node.OnLeadershipChange(func(leader bool) {
+		o.gradientManager.SetStandby(!leader)
+		if leader {
+			metrics.ClusterLeader.Set(1)
+			log.Printf("👑 Orchestrator %d is now the cluster leader", o.config.ID)
+		} else {
+			metrics.ClusterLeader.Set(0)
+			log.Printf("👑 Orchestrator %d is no longer the cluster leader", o.config.ID)
+		}
+	})
+	service.SetCluster(node, o.config.ClusterToken)
+	o.clusterNode = node
+	log.Printf("🕸️  Joined a cluster of %d orchestrators as %d (%s)", len(o.config.ClusterPeers)+1, o.config.ID, o.config.AdvertiseAddr)
+	return nil
+}
+
 // startMetricsServer starts the Prometheus metrics HTTP server
 func (o *Orchestrator) startMetricsServer() {
-	http.Handle("/metrics", promhttp.Handler())
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
 
 	// Health check endpoint
-	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		metrics.HTTPRequestsTotal.WithLabelValues(r.Method, "/health", "200").Inc()
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("OK"))
 	})
 
-	metricsAddr := ":8080"
+	metricsAddr := o.config.MetricsAddr
 	log.Printf("📊 Metrics server listening on %s/metrics", metricsAddr)
 
-	if err := http.ListenAndServe(metricsAddr, nil); err != nil {
+	if err := http.ListenAndServe(metricsAddr, mux); err != nil {
 		log.Printf("❌ Metrics server error: %v", err)
 	}
 }
 
-// acceptConnections accepts incoming client connections
-func (o *Orchestrator) acceptConnections() {
-	for {
-		select {
-		case <-o.ctx.Done():
-			log.Println("📤 Stopping connection acceptance")
-			return
-		default:
-			conn, err := o.listener.Accept()
-			if err != nil {
-				log.Printf("⚠️  Failed to accept connection: %v", err)
-				continue
-			}
-
-			// Handle connection in goroutine
-			log.Printf("✅ New connection accepted from %s", conn.RemoteAddr())
-			go o.handleConnection(conn)
-
-			// Update metrics
-			metrics.ActiveWorkers.Inc()
-		}
-	}
-}
-
-// handleConnection processes a single client connection
-func (o *Orchestrator) handleConnection(conn net.Conn) {
-	defer conn.Close()
-	defer metrics.ActiveWorkers.Dec()
-
-	log.Printf("🔌 Handling connection from %s", conn.RemoteAddr())
-
-	// Track RPC requests
-	start := time.Now()
-	metrics.RPCRequestsTotal.WithLabelValues("connection", "success").Inc()
+// startTrainingServer serves the JSON worker and federated training API
+// under /rpc/
+func (o *Orchestrator) startTrainingServer(rpc http.Handler) {
+	mux := http.NewServeMux()
+	mux.Handle("/rpc/", rpc)
 
-	// Actual message handling would occur here
-
-	duration := time.Since(start).Seconds()
-	metrics.RPCRequestDuration.WithLabelValues("connection").Observe(duration)
+	log.Printf("🧠 Training API listening on %s/rpc/", o.config.TrainingAddr)
+	if err := http.ListenAndServe(o.config.TrainingAddr, mux); err != nil {
+		log.Printf("❌ Training API server error: %v", err)
+	}
 }
 
 // Stop gracefully shuts down the orchestrator
@@ -147,14 +218,20 @@ func (o *Orchestrator) Stop() error {
 
 	o.cancel()
 
-	if o.listener != nil {
-		if err := o.listener.Close(); err != nil {
-			log.Printf("⚠️  Error closing listener: %v", err)
+	// Give in-flight requests time to complete
+	if o.grpcServer != nil {
+		stopped := make(chan struct{})
+		go func() {
+			o.grpcServer.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-time.After(o.config.GracefulShutdown):
+			o.grpcServer.Stop()
 		}
 	}
-
-	// Give in-flight requests time to complete
-	<-time.After(o.config.GracefulShutdown)
+	o.computeManager.Close()
 
 	// Shutdown observability tools
 	o.obsManager.Shutdown()
@@ -166,20 +243,41 @@ func (o *Orchestrator) Stop() error {
 // main entry point
 func main() {
 	var (
-		id               = flag.Uint("id", 1, "Orchestrator node ID")
-		rpcAddr          = flag.String("rpc-addr", ":8080", "RPC server address")
-		listenPort       = flag.String("listen", "0.0.0.0:8080", "Server listen address")
-		maxWorkers       = flag.Int("max-workers", 10, "Maximum number of connected workers")
-		gracefulShutdown = flag.Duration("shutdown-timeout", 30*time.Second, "Graceful shutdown timeout")
+		id                = flag.Uint("id", 1, "Orchestrator node ID")
+		rpcAddr           = flag.String("rpc-addr", ":50051", "gRPC server address")
+		metricsAddr       = flag.String("metrics-addr", ":8080", "Metrics and health check HTTP address")
+		trainingAddr      = flag.String("training-addr", "", "JSON API address go-nodes register as workers and train through (empty disables it)")
+		listenPort        = flag.String("listen", "0.0.0.0:8080", "Server listen address")
+		maxWorkers        = flag.Int("max-workers", 10, "Maximum number of connected workers")
+		heartbeatInterval = flag.Duration("heartbeat-interval", 5*time.Second, "Interval workers are told to send heartbeats at")
+		gracefulShutdown  = flag.Duration("shutdown-timeout", 30*time.Second, "Graceful shutdown timeout")
+		clusterPeers      = flag.String("cluster-peers", "", "Comma-separated id=host:port gRPC addresses of the other orchestrators of an HA cluster (empty = run alone)")
+		advertiseAddr     = flag.String("advertise-addr", "", "gRPC address cluster peers and workers reach this orchestrator at")
+		trainingURL       = flag.String("training-url", "", "Training API URL cluster followers forward go-node requests to when this orchestrator leads, e.g. http://host:8090")
+		leaseDuration     = flag.Duration("lease-duration", cluster.DefaultLeaseDuration, "How long a cluster leader's lease lasts without renewal")
 	)
 	flag.Parse()
 
+	peers, err := parsePeers(*clusterPeers)
+	if err != nil {
+		log.Fatalf("❌ Invalid -cluster-peers: %v", err)
+	}
+
 	config := &OrchestratorConfig{
-		ID:               uint32(*id),
-		RpcAddr:          *rpcAddr,
-		ListenPort:       *listenPort,
-		MaxWorkers:       *maxWorkers,
-		GracefulShutdown: *gracefulShutdown,
+		ID:                uint32(*id),
+		RpcAddr:           *rpcAddr,
+		MetricsAddr:       *metricsAddr,
+		TrainingAddr:      *trainingAddr,
+		TrainingToken:     os.Getenv("ORCHESTRATOR_TRAINING_TOKEN"),
+		TrainingURL:       *trainingURL,
+		AdvertiseAddr:     *advertiseAddr,
+		ClusterPeers:      peers,
+		ClusterToken:      os.Getenv("ORCHESTRATOR_CLUSTER_TOKEN"),
+		LeaseDuration:     *leaseDuration,
+		ListenPort:        *listenPort,
+		MaxWorkers:        *maxWorkers,
+		HeartbeatInterval: *heartbeatInterval,
+		GracefulShutdown:  *gracefulShutdown,
 	}
 
 	orchestrator := NewOrchestrator(config)
@@ -197,3 +295,24 @@ func main() {
 		log.Fatalf("❌ Error during shutdown: %v", err)
 	}
 }
+
+// parsePeers parses "id=host:port,..." cluster peers
+func parsePeers(spec string) ([]cluster.Peer, error) {
+	var peers []cluster.Peer
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, addr, ok := strings.Cut(entry, "=")
+		if !ok || addr == "" {
+			return nil, fmt.Errorf("%q is not id=host:port", entry)
+		}
+		n, err := strconv.ParseUint(id, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid peer ID %q", id)
+		}
+		peers = append(peers, cluster.Peer{ID: uint32(n), Addr: addr})
+	}
+	return peers, nil
+}
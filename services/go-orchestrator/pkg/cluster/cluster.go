@@ -0,0 +1,546 @@
+// Package cluster elects a leader among orchestrator instances and
+// replicates the leader's state to the others.
+//
+// Election is lease based. A candidate asks its peers for votes in a new
+// term and leads once a majority grants them. It first polls them without
+// raising its term, so a node cut off from the others cannot raise the
+// term and depose the leader when it returns. The leader renews a lease
+// with every peer several times per lease period; a peer holding a live
+// lease refuses to vote, so a healthy leader is never deposed. A peer also
+// refuses a candidate whose replica is older than its own, so an elected
+// leader holds every state a majority acknowledged. The leader trusts its
+// lease for slightly less time than its followers do and stops acting as
+// leader once it runs out, so two leaders never act at once.
+//
+// Terms and votes are kept in memory only. A restarted orchestrator does
+// not vote for its first lease period, so it cannot vote twice in an
+// election it may have voted in before the restart.
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// DefaultLeaseDuration is how long a leader's lease lasts without renewal
+const DefaultLeaseDuration = 5 * time.Second
+
+// leaseDriftDivisor sets the margin the leader takes off its own lease, a
+// tenth of it, for clocks running at slightly different rates
+const leaseDriftDivisor = 10
+
+// ErrNotLeader is returned for work only the leader may do
+var ErrNotLeader = errors.New("not the cluster leader")
+
+// Role is an orchestrator's part in the cluster
+type Role int
+
+const (
+	Follower Role = iota
+	Candidate
+	Leader
+)
+
+func (r Role) String() string {
+	switch r {
+	case Candidate:
+		return "candidate"
+	case Leader:
+		return "leader"
+	default:
+		return "follower"
+	}
+}
+
+// Peer is another orchestrator of the cluster
+type Peer struct {
+	ID   uint32
+	Addr string // gRPC address
+}
+
+// Config describes this orchestrator and its peers
+type Config struct {
+	ID            uint32 // Non-zero and unique in the cluster
+	Addr          string // gRPC address peers and clients reach this orchestrator at
+	APIURL        string // JSON training API URL; followers forward to the leader's
+	Peers         []Peer
+	LeaseDuration time.Duration // 0 = DefaultLeaseDuration
+}
+
+// StateMachine is the state the leader replicates to its followers
+type StateMachine interface {
+	Revision() uint64
+	Snapshot() ([]byte, error)
+	Restore(data []byte) error
+}
+
+// Transport carries election and lease messages to a peer
+type Transport interface {
+	RequestVote(ctx context.Context, addr string, req *VoteRequest) (*VoteResponse, error)
+	RenewLease(ctx context.Context, addr string, req *LeaseRequest) (*LeaseResponse, error)
+}
+
+// VoteRequest asks a peer to vote for a candidate in a term. A pre-vote
+// only asks whether the peer would, and changes nothing. StateTerm and
+// Revision identify the newest leader state the candidate holds.
+type VoteRequest struct {
+	Term        uint64
+	CandidateID uint32
+	PreVote     bool
+	StateTerm   uint64 // Term of the leader whose state the candidate holds
+	Revision    uint64 // That leader's revision
+}
+
+// VoteResponse answers a VoteRequest with the peer's term
+type VoteResponse struct {
+	Term    uint64
+	Granted bool
+}
+
+// LeaseRequest renews the leader's lease with a follower. Snapshot is set
+// when the follower's replica is behind the leader's state.
+type LeaseRequest struct {
+	Term       uint64
+	LeaderID   uint32
+	LeaderAddr string
+	LeaderAPI  string
+	Lease      time.Duration
+	Revision   uint64
+	Snapshot   []byte
+}
+
+// LeaseResponse answers a LeaseRequest with the follower's term
+type LeaseResponse struct {
+	Term     uint64
+	Accepted bool
+}
+
+// Status reports an orchestrator's view of the cluster
+type Status struct {
+	ID         uint32
+	Role       Role
+	Term       uint64
+	LeaderID   uint32 // 0 = no known leader
+	LeaderAddr string
+	LeaderAPI  string
+	Members    int
+}
+
+// Node is one orchestrator's membership in the cluster
+type Node struct {
+	cfg       Config
+	transport Transport
+	state     StateMachine
+	onChange  func(leader bool)
+
+	mu         sync.Mutex
+	role       Role
+	term       uint64
+	votedFor   uint32 // 0 = no vote this term
+	leaderID   uint32
+	leaderAddr string
+	leaderAPI  string
+	leaseUntil time.Time         // Leader: lease a majority acknowledged; follower: leader's lease
+	electionAt time.Time         // When a follower without a lease campaigns
+	voteAfter  time.Time         // No votes before this after a restart
+	replicated map[uint32]uint64 // Leader: peer -> revision it holds
+	stateTerm  uint64            // Term of the leader whose state this node holds
+	revision   uint64            // That leader's revision of it
+	now        func() time.Time
+}
+
+// New creates a cluster node. It follows until Run elects a leader.
+func New(cfg Config, transport Transport, state StateMachine) (*Node, error) {
+	if cfg.ID == 0 {
+		return nil, fmt.Errorf("cluster node ID must be non-zero")
+	}
+	seen := map[uint32]bool{cfg.ID: true}
+	for _, p := range cfg.Peers {
+		if p.ID == 0 || seen[p.ID] {
+			return nil, fmt.Errorf("peer ID %d is zero or not unique", p.ID)
+		}
+		if p.Addr == "" {
+			return nil, fmt.Errorf("peer %d has no address", p.ID)
+		}
+		seen[p.ID] = true
+	}
+	if cfg.LeaseDuration <= 0 {
+		cfg.LeaseDuration = DefaultLeaseDuration
+	}
+	n := &Node{cfg: cfg, transport: transport, state: state, now: time.Now}
+	n.voteAfter = n.now().Add(cfg.LeaseDuration)
+	n.electionAt = n.now().Add(n.electionTimeout())
+	if len(cfg.Peers) == 0 {
+		n.electionAt = n.now()
+	}
+	return n, nil
+}
+
+// OnLeadershipChange sets a function called when this orchestrator becomes
+// or stops being the leader
+func (n *Node) OnLeadershipChange(fn func(leader bool)) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.onChange = fn
+}
+
+// IsLeader reports whether this orchestrator leads the cluster and its
+// lease has not run out
+func (n *Node) IsLeader() bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.role == Leader && n.now().Before(n.leaseUntil)
+}
+
+// Status returns this orchestrator's view of the cluster
+func (n *Node) Status() Status {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	st := Status{
+		ID:         n.cfg.ID,
+		Role:       n.role,
+		Term:       n.term,
+		LeaderID:   n.leaderID,
+		LeaderAddr: n.leaderAddr,
+		LeaderAPI:  n.leaderAPI,
+		Members:    len(n.cfg.Peers) + 1,
+	}
+	if n.role == Leader {
+		st.LeaderID, st.LeaderAddr, st.LeaderAPI = n.cfg.ID, n.cfg.Addr, n.cfg.APIURL
+	}
+	return st
+}
+
+// Run campaigns and renews leases until ctx is cancelled
+func (n *Node) Run(ctx context.Context) {
+	ticker := time.NewTicker(n.heartbeatInterval())
+	defer ticker.Stop()
+	for {
+		n.tick(ctx, n.now())
+		select {
+		case <-ctx.Done():
+			n.mu.Lock()
+			notify := n.becomeFollowerLocked()
+			n.mu.Unlock()
+			notify()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// tick renews the lease as leader, or campaigns once a follower's election
+// timeout has passed without a lease
+func (n *Node) tick(ctx context.Context, now time.Time) {
+	n.mu.Lock()
+	role, electionAt := n.role, n.electionAt
+	n.mu.Unlock()
+
+	switch {
+	case role == Leader:
+		n.renewLeases(ctx, now)
+	case !now.Before(electionAt):
+		n.campaign(ctx, now)
+	}
+}
+
+// campaign polls the peers and, if a majority would vote for it, starts
+// a new term and asks for their votes
+func (n *Node) campaign(ctx context.Context, now time.Time) {
+	n.mu.Lock()
+	next := n.term + 1
+	n.mu.Unlock()
+	if n.requestVotes(ctx, next, true) < n.quorum() {
+		n.mu.Lock()
+		n.electionAt = n.now().Add(n.electionTimeout())
+		n.mu.Unlock()
+		return
+	}
+
+	n.mu.Lock()
+	if n.term >= next || n.role == Leader {
+		n.mu.Unlock()
+		return
+	}
+	n.term = next
+	n.role = Candidate
+	n.votedFor = n.cfg.ID
+	n.leaderID, n.leaderAddr, n.leaderAPI = 0, "", ""
+	term := n.term
+	n.mu.Unlock()
+
+	votes := n.requestVotes(ctx, term, false)
+	n.mu.Lock()
+	if n.role != Candidate || n.term != term {
+		n.mu.Unlock()
+		return
+	}
+	if votes < n.quorum() {
+		n.role = Follower
+		n.electionAt = n.now().Add(n.electionTimeout())
+		n.mu.Unlock()
+		return
+	}
+	n.role = Leader
+	n.leaseUntil = now.Add(n.leaderLease())
+	n.replicated = make(map[uint32]uint64)
+	onChange := n.onChange
+	n.mu.Unlock()
+
+	log.Printf("👑 Orchestrator %d elected leader for term %d with %d of %d votes", n.cfg.ID, term, votes, len(n.cfg.Peers)+1)
+	if onChange != nil {
+		onChange(true)
+	}
+	n.renewLeases(ctx, n.now())
+}
+
+// requestVotes asks every peer for its vote in a term and returns the
+// votes granted, counting this node's own
+func (n *Node) requestVotes(ctx context.Context, term uint64, preVote bool) int {
+	n.mu.Lock()
+	req := &VoteRequest{Term: term, CandidateID: n.cfg.ID, PreVote: preVote, StateTerm: n.stateTerm, Revision: n.revision}
+	n.mu.Unlock()
+
+	votes := 1
+	var mu sync.Mutex
+	n.broadcast(ctx, func(ctx context.Context, p Peer) {
+		resp, err := n.transport.RequestVote(ctx, p.Addr, req)
+		if err != nil {
+			return
+		}
+		n.mu.Lock()
+		if resp.Term > n.term && !preVote {
+			n.term, n.votedFor = resp.Term, 0
+			n.role = Follower
+			n.electionAt = n.now().Add(n.electionTimeout())
+		}
+		n.mu.Unlock()
+		if resp.Granted {
+			mu.Lock()
+			votes++
+			mu.Unlock()
+		}
+	})
+	return votes
+}
+
+// renewLeases sends a lease to every peer, with a snapshot to those whose
+// replica is behind, and steps down if a majority has not renewed within a
+// lease
+func (n *Node) renewLeases(ctx context.Context, now time.Time) {
+	n.mu.Lock()
+	if n.role != Leader {
+		n.mu.Unlock()
+		return
+	}
+	term := n.term
+	replicated := make(map[uint32]uint64, len(n.replicated))
+	for id, rev := range n.replicated {
+		replicated[id] = rev
+	}
+	n.mu.Unlock()
+
+	revision := n.state.Revision()
+	n.mu.Lock()
+	if n.role == Leader && n.term == term {
+		n.stateTerm, n.revision = term, revision
+	}
+	n.mu.Unlock()
+	var snapshot []byte
+	for _, p := range n.cfg.Peers {
+		if rev, ok := replicated[p.ID]; !ok || rev != revision {
+			data, err := n.state.Snapshot()
+			if err != nil {
+				log.Printf("⚠️  Failed to snapshot orchestrator state: %v", err)
+			}
+			snapshot = data
+			break
+		}
+	}
+
+	acks := 1
+	var mu sync.Mutex
+	n.broadcast(ctx, func(ctx context.Context, p Peer) {
+		req := &LeaseRequest{
+			Term:       term,
+			LeaderID:   n.cfg.ID,
+			LeaderAddr: n.cfg.Addr,
+			LeaderAPI:  n.cfg.APIURL,
+			Lease:      n.cfg.LeaseDuration,
+			Revision:   revision,
+		}
+		if rev, ok := replicated[p.ID]; !ok || rev != revision {
+			req.Snapshot = snapshot
+		}
+		resp, err := n.transport.RenewLease(ctx, p.Addr, req)
+		if err != nil {
+			return
+		}
+		if !resp.Accepted {
+			n.mu.Lock()
+			if resp.Term > n.term {
+				n.term, n.votedFor = resp.Term, 0
+				notify := n.becomeFollowerLocked()
+				n.mu.Unlock()
+				notify()
+				return
+			}
+			n.mu.Unlock()
+			return
+		}
+		mu.Lock()
+		acks++
+		mu.Unlock()
+		if req.Snapshot != nil {
+			n.mu.Lock()
+			if n.replicated != nil {
+				n.replicated[p.ID] = revision
+			}
+			n.mu.Unlock()
+		}
+	})
+
+	n.mu.Lock()
+	if n.role != Leader || n.term != term {
+		n.mu.Unlock()
+		return
+	}
+	if acks >= n.quorum() {
+		n.leaseUntil = now.Add(n.leaderLease())
+	}
+	if n.now().Before(n.leaseUntil) {
+		n.mu.Unlock()
+		return
+	}
+	log.Printf("⚠️  Orchestrator %d lost its majority in term %d, stepping down", n.cfg.ID, term)
+	notify := n.becomeFollowerLocked()
+	n.mu.Unlock()
+	notify()
+}
+
+// HandleVote answers a candidate's VoteRequest
+func (n *Node) HandleVote(req *VoteRequest) *VoteResponse {
+	n.mu.Lock()
+	now := n.now()
+	// A live leader keeps the cluster: neither it nor its followers vote
+	liveLeader := (n.role == Leader || n.leaderID != 0) && now.Before(n.leaseUntil)
+	// Nor is a candidate elected that would replicate older state
+	behind := req.StateTerm < n.stateTerm || (req.StateTerm == n.stateTerm && req.Revision < n.revision)
+	if req.Term < n.term || liveLeader || behind || now.Before(n.voteAfter) {
+		defer n.mu.Unlock()
+		return &VoteResponse{Term: n.term}
+	}
+	if req.PreVote {
+		defer n.mu.Unlock()
+		return &VoteResponse{Term: n.term, Granted: req.Term > n.term}
+	}
+	notify := func() {}
+	if req.Term > n.term {
+		n.term, n.votedFor = req.Term, 0
+		notify = n.becomeFollowerLocked()
+	}
+	resp := &VoteResponse{Term: n.term}
+	if n.votedFor == 0 || n.votedFor == req.CandidateID {
+		n.votedFor = req.CandidateID
+		n.electionAt = now.Add(n.electionTimeout())
+		resp.Granted = true
+	}
+	n.mu.Unlock()
+	notify()
+	return resp
+}
+
+// HandleLease answers the leader's LeaseRequest, restoring its snapshot
+func (n *Node) HandleLease(req *LeaseRequest) *LeaseResponse {
+	n.mu.Lock()
+	if req.Term < n.term || (req.Term == n.term && n.role == Leader) {
+		defer n.mu.Unlock()
+		return &LeaseResponse{Term: n.term}
+	}
+	notify := func() {}
+	if n.role != Follower {
+		notify = n.becomeFollowerLocked()
+	}
+	if n.leaderID != req.LeaderID {
+		log.Printf("👑 Orchestrator %d follows leader %d (%s) in term %d", n.cfg.ID, req.LeaderID, req.LeaderAddr, req.Term)
+	}
+	now := n.now()
+	n.term = req.Term
+	n.leaderID, n.leaderAddr, n.leaderAPI = req.LeaderID, req.LeaderAddr, req.LeaderAPI
+	n.leaseUntil = now.Add(req.Lease)
+	n.electionAt = n.leaseUntil.Add(n.electionTimeout() - n.cfg.LeaseDuration)
+	n.mu.Unlock()
+	notify()
+
+	if req.Snapshot != nil {
+		if err := n.state.Restore(req.Snapshot); err != nil {
+			log.Printf("⚠️  Failed to restore the leader's state: %v", err)
+			return &LeaseResponse{Term: req.Term}
+		}
+		n.mu.Lock()
+		if n.term == req.Term {
+			n.stateTerm, n.revision = req.Term, req.Revision
+		}
+		n.mu.Unlock()
+	}
+	return &LeaseResponse{Term: req.Term, Accepted: true}
+}
+
+// becomeFollowerLocked drops leadership and returns a function reporting
+// the change, to be called without n.mu held. Caller must hold n.mu.
+func (n *Node) becomeFollowerLocked() func() {
+	wasLeader := n.role == Leader
+	n.role = Follower
+	n.replicated = nil
+	n.leaseUntil = time.Time{}
+	n.electionAt = n.now().Add(n.electionTimeout())
+	onChange := n.onChange
+	return func() {
+		if wasLeader && onChange != nil {
+			onChange(false)
+		}
+	}
+}
+
+// broadcast calls fn for every peer at once, each bounded by the heartbeat
+// interval, and waits for all of them
+func (n *Node) broadcast(ctx context.Context, fn func(context.Context, Peer)) {
+	ctx, cancel := context.WithTimeout(ctx, n.heartbeatInterval())
+	defer cancel()
+	var wg sync.WaitGroup
+	for _, p := range n.cfg.Peers {
+		wg.Add(1)
+		go func(p Peer) {
+			defer wg.Done()
+			fn(ctx, p)
+		}(p)
+	}
+	wg.Wait()
+}
+
+// quorum is the majority of the cluster
+func (n *Node) quorum() int {
+	return (len(n.cfg.Peers)+1)/2 + 1
+}
+
+// leaderLease is how long the leader acts on a renewed lease, short of the
+// followers' lease by a drift margin
+func (n *Node) leaderLease() time.Duration {
+	return n.cfg.LeaseDuration - n.cfg.LeaseDuration/leaseDriftDivisor
+}
+
+// heartbeatInterval is how often the leader renews leases
+func (n *Node) heartbeatInterval() time.Duration {
+	return n.cfg.LeaseDuration / 4
+}
+
+// electionTimeout is a random wait between one and two leases, so that
+// followers rarely campaign at once
+func (n *Node) electionTimeout() time.Duration {
+	return n.cfg.LeaseDuration + time.Duration(rand.Int63n(int64(n.cfg.LeaseDuration)))
+}
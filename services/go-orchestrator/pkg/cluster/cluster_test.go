@@ -0,0 +1,342 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memState is a replicated value
+type memState struct {
+	mu       sync.Mutex
+	revision uint64
+	value    string
+}
+
+func (s *memState) Revision() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.revision
+}
+
+func (s *memState) Snapshot() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return []byte(s.value), nil
+}
+
+func (s *memState) Restore(data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.value = string(data)
+	s.revision++
+	return nil
+}
+
+func (s *memState) set(value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.value = value
+	s.revision++
+}
+
+func (s *memState) get() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.value
+}
+
+// memNetwork delivers messages between nodes in the process and can cut
+// nodes off
+type memNetwork struct {
+	mu    sync.Mutex
+	nodes map[string]*Node
+	down  map[string]bool
+}
+
+type memTransport struct {
+	net  *memNetwork
+	from string
+}
+
+func (t *memTransport) peer(addr string) (*Node, error) {
+	t.net.mu.Lock()
+	defer t.net.mu.Unlock()
+	if t.net.down[addr] || t.net.down[t.from] {
+		return nil, errors.New("unreachable")
+	}
+	return t.net.nodes[addr], nil
+}
+
+func (t *memTransport) RequestVote(ctx context.Context, addr string, req *VoteRequest) (*VoteResponse, error) {
+	n, err := t.peer(addr)
+	if err != nil {
+		return nil, err
+	}
+	return n.HandleVote(req), nil
+}
+
+func (t *memTransport) RenewLease(ctx context.Context, addr string, req *LeaseRequest) (*LeaseResponse, error) {
+	n, err := t.peer(addr)
+	if err != nil {
+		return nil, err
+	}
+	return n.HandleLease(req), nil
+}
+
+func (m *memNetwork) setDown(addr string, down bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.down[addr] = down
+}
+
+func startCluster(t *testing.T, size int) (*memNetwork, []*Node, []*memState) {
+	t.Helper()
+	net := &memNetwork{nodes: make(map[string]*Node), down: make(map[string]bool)}
+	nodes := make([]*Node, size)
+	states := make([]*memState, size)
+	for i := range nodes {
+		cfg := Config{ID: uint32(i + 1), Addr: fmt.Sprintf("o%d", i+1), APIURL: fmt.Sprintf("http://o%d", i+1), LeaseDuration: 80 * time.Millisecond}
+		for j := 0; j < size; j++ {
+			if j != i {
+				cfg.Peers = append(cfg.Peers, Peer{ID: uint32(j + 1), Addr: fmt.Sprintf("o%d", j+1)})
+			}
+		}
+		states[i] = &memState{}
+		n, err := New(cfg, &memTransport{net: net, from: cfg.Addr}, states[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		nodes[i] = n
+		net.nodes[cfg.Addr] = n
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	for _, n := range nodes {
+		wg.Add(1)
+		go func(n *Node) {
+			defer wg.Done()
+			n.Run(ctx)
+		}(n)
+	}
+	t.Cleanup(func() {
+		cancel()
+		wg.Wait()
+	})
+	return net, nodes, states
+}
+
+// waitLeader waits for exactly one leader among the nodes that are up
+func waitLeader(t *testing.T, net *memNetwork, nodes []*Node) *Node {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		var leaders []*Node
+		for _, n := range nodes {
+			net.mu.Lock()
+			down := net.down[n.cfg.Addr]
+			net.mu.Unlock()
+			if !down && n.IsLeader() {
+				leaders = append(leaders, n)
+			}
+		}
+		if len(leaders) == 1 {
+			return leaders[0]
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("no single leader elected")
+	return nil
+}
+
+func TestLeaderElectionAndFailover(t *testing.T) {
+	net, nodes, states := startCluster(t, 3)
+	leader := waitLeader(t, net, nodes)
+
+	var changes []bool
+	var mu sync.Mutex
+	leader.OnLeadershipChange(func(leading bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		changes = append(changes, leading)
+	})
+
+	// The leader's state reaches every follower
+	states[leader.cfg.ID-1].set("epoch-1")
+	deadline := time.Now().Add(2 * time.Second)
+	for _, s := range states {
+		for s.get() != "epoch-1" {
+			if time.Now().After(deadline) {
+				t.Fatal("state not replicated to a follower")
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	for _, n := range nodes {
+		if st := n.Status(); st.LeaderID != leader.cfg.ID || st.LeaderAPI != leader.cfg.APIURL {
+			t.Errorf("node %d sees leader %d at %q", n.cfg.ID, st.LeaderID, st.LeaderAPI)
+		}
+	}
+
+	// Cut the leader off: the others elect a new one and the old one
+	// steps down once it cannot renew its lease
+	net.setDown(leader.cfg.Addr, true)
+	var rest []*Node
+	for _, n := range nodes {
+		if n != leader {
+			rest = append(rest, n)
+		}
+	}
+	next := waitLeader(t, net, rest)
+	if next.Status().Term <= leader.Status().Term {
+		t.Errorf("expected the new leader in a later term")
+	}
+	if next.Status().Role != Leader || states[next.cfg.ID-1].get() != "epoch-1" {
+		t.Errorf("expected the new leader to hold the replicated state")
+	}
+	deadline = time.Now().Add(time.Second)
+	for leader.IsLeader() {
+		if time.Now().After(deadline) {
+			t.Fatal("isolated leader did not step down")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	mu.Lock()
+	if len(changes) != 1 || changes[0] {
+		t.Errorf("expected one step-down notification, got %v", changes)
+	}
+	mu.Unlock()
+
+	// Back on the network the old leader follows and cannot depose the
+	// new one, even after campaigning alone
+	term := next.Status().Term
+	net.setDown(leader.cfg.Addr, false)
+	time.Sleep(300 * time.Millisecond)
+	if !next.IsLeader() || next.Status().Term != term {
+		t.Errorf("returning node disrupted the leader: term %d -> %d", term, next.Status().Term)
+	}
+	if st := leader.Status(); st.Role != Follower || st.LeaderID != next.cfg.ID {
+		t.Errorf("returning node should follow %d, got %+v", next.cfg.ID, st)
+	}
+}
+
+func TestSingleOrchestratorLeadsAlone(t *testing.T) {
+	n, err := New(Config{ID: 1, Addr: "solo"}, nil, &memState{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.tick(context.Background(), time.Now())
+	if !n.IsLeader() {
+		t.Fatal("expected a single orchestrator to lead")
+	}
+	if _, err := New(Config{ID: 1, Peers: []Peer{{ID: 1, Addr: "x"}}}, nil, nil); err == nil {
+		t.Error("expected a duplicate peer ID refused")
+	}
+}
+
+// fakeClock is a settable time source
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestLeaderStopsActingWhenItsLeaseRunsOut(t *testing.T) {
+	n, err := New(Config{ID: 1, Addr: "solo"}, nil, &memState{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := &fakeClock{now: time.Now()}
+	n.now = clock.Now
+	n.tick(context.Background(), clock.Now())
+	if !n.IsLeader() {
+		t.Fatal("expected a single orchestrator to lead")
+	}
+
+	// Without a renewal the leader gives up before a follower's lease of
+	// the same length would end
+	clock.advance(n.leaderLease() - time.Millisecond)
+	if !n.IsLeader() {
+		t.Fatal("expected the leader to act within its lease")
+	}
+	clock.advance(time.Millisecond)
+	if n.IsLeader() {
+		t.Fatal("expected the leader to stop acting once its lease ran out")
+	}
+	if margin := n.cfg.LeaseDuration - n.leaderLease(); margin <= 0 {
+		t.Fatalf("expected a drift margin, got %v", margin)
+	}
+
+	// Renewing restores it
+	n.tick(context.Background(), clock.Now())
+	if !n.IsLeader() {
+		t.Fatal("expected the renewed leader to act again")
+	}
+}
+
+func TestStaleReplicaCannotWinElection(t *testing.T) {
+	net := &memNetwork{nodes: make(map[string]*Node), down: make(map[string]bool)}
+	clock := &fakeClock{now: time.Now().Add(time.Minute)} // Past the restart vote delay
+	nodes := make([]*Node, 3)
+	for i := range nodes {
+		cfg := Config{ID: uint32(i + 1), Addr: fmt.Sprintf("o%d", i+1), LeaseDuration: 80 * time.Millisecond}
+		for j := range nodes {
+			if j != i {
+				cfg.Peers = append(cfg.Peers, Peer{ID: uint32(j + 1), Addr: fmt.Sprintf("o%d", j+1)})
+			}
+		}
+		n, err := New(cfg, &memTransport{net: net, from: cfg.Addr}, &memState{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		n.now = clock.Now
+		nodes[i] = n
+		net.nodes[cfg.Addr] = n
+	}
+
+	// Leader 3 of term 1 is gone; node 2 applied its revision 5 but node 1
+	// only revision 3
+	net.setDown("o3", true)
+	nodes[0].term, nodes[0].stateTerm, nodes[0].revision = 1, 1, 3
+	nodes[1].term, nodes[1].stateTerm, nodes[1].revision = 1, 1, 5
+
+	ctx := context.Background()
+	nodes[0].campaign(ctx, clock.Now())
+	if nodes[0].IsLeader() || nodes[0].Status().Term != 1 {
+		t.Fatalf("stale replica started a term: %+v", nodes[0].Status())
+	}
+	nodes[1].campaign(ctx, clock.Now())
+	if !nodes[1].IsLeader() {
+		t.Fatal("expected the current replica elected")
+	}
+
+	// Followers record the new leader's state as they restore it
+	if n := nodes[0]; n.stateTerm != 2 || n.revision != 0 {
+		t.Fatalf("expected node 1 to hold term 2 state, got term %d revision %d", n.stateTerm, n.revision)
+	}
+
+	// Once that lease lapses, a newer term outranks any revision of an
+	// older one
+	clock.advance(time.Second)
+	if resp := nodes[0].HandleVote(&VoteRequest{Term: 5, CandidateID: 3, StateTerm: 1, Revision: 9}); resp.Granted {
+		t.Fatal("expected a vote refused for state from an older term")
+	}
+	if resp := nodes[0].HandleVote(&VoteRequest{Term: 5, CandidateID: 3, StateTerm: 3}); !resp.Granted {
+		t.Fatal("expected a vote for state from a later term")
+	}
+}
@@ -1,112 +1,379 @@
 package gradient
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"slices"
+	"sort"
 	"sync"
 	"time"
 )
 
-// GradientUpdate represents a gradient computation result from a worker
+// Epoch barrier defaults. A round waits for every node of its task; once it
+// has waited the round timeout, MinNodes gradients suffice.
+const (
+	DefaultLearningRate = 0.01
+	DefaultRoundTimeout = 2 * time.Minute
+	monitorInterval     = time.Second
+)
+
+var (
+	// ErrTaskNotFound is returned for a training task that was never started
+	ErrTaskNotFound = errors.New("training task not found")
+
+	// ErrStaleGradient is returned for a gradient computed on a model other
+	// than the current global model
+	ErrStaleGradient = errors.New("stale gradient")
+
+	// ErrTaskNotRunning is returned for a gradient sent to a finished task
+	ErrTaskNotRunning = errors.New("training task is not running")
+
+	// ErrTaskExists is returned when starting a task ID already in use
+	ErrTaskExists = errors.New("training task already exists")
+)
+
+// TrainingConfig describes a federated training task across go-node
+// instances. Each node trains on its own data, submits one gradient per
+// epoch, and continues from the global model published for the next epoch.
+type TrainingConfig struct {
+	TaskID            string
+	Nodes             []string // Nodes expected to submit every epoch
+	Epochs            uint32
+	MinNodes          int           // Gradients a timed-out round needs; 0 = a majority of Nodes
+	RoundTimeout      time.Duration // 0 = DefaultRoundTimeout
+	LearningRate      float64       // 0 = DefaultLearningRate
+	InitialParameters []float64     // Empty = zeros, sized by the first gradient
+}
+
+// GradientUpdate is one node's gradient for an epoch, the sample-weighted
+// mean over the data it trained on
 type GradientUpdate struct {
-	WorkerID  uint32
-	Loss      float64
-	Timestamp time.Time
-	Data      []float64
+	TaskID       string
+	NodeID       string
+	ModelVersion uint32 // Global model version the gradient was computed on
+	Data         []float64
+	NumSamples   uint32
+	Loss         float64
+	Accuracy     float64
+	Timestamp    time.Time
+}
+
+// GlobalModel is the model published after an epoch. Version 0 is the
+// initial model; version N follows the Nth aggregation.
+type GlobalModel struct {
+	TaskID     string
+	Version    uint32
+	Parameters []float64
+	NumNodes   uint32 // Nodes whose gradients formed this version
+	Loss       float64
+	Accuracy   float64
+	Final      bool // Last version of the task
+	Timestamp  time.Time
+}
+
+// TaskStatus reports a training task's progress
+type TaskStatus struct {
+	TaskID    string
+	Status    string // "running", "completed", "stopped"
+	Epoch     uint32 // Current global model version
+	Epochs    uint32
+	Nodes     []string
+	Submitted []string // Nodes that sent a gradient for the current epoch
+	Loss      float64  // Loss of the latest global model
+}
+
+type task struct {
+	config     TrainingConfig
+	status     string
+	epoch      uint32
+	model      *GlobalModel
+	pending    map[string]*GradientUpdate // Node -> gradient for the current epoch
+	roundStart time.Time
+	published  chan struct{} // Closed and replaced when a new model is published
 }
 
-// Manager handles gradient aggregation and synchronization across workers
+// Manager aggregates gradients from go-node instances into global models,
+// one federated averaging round per epoch
 type Manager struct {
-	mu               sync.RWMutex
-	gradients        map[uint32]*GradientUpdate
-	aggregationRound uint64
-	lastAggregation  time.Time
+	mu       sync.RWMutex
+	tasks    map[string]*task
+	revision uint64 // Bumped on every change, for replication
+	standby  bool   // Replica of another orchestrator's state
 }
 
 // NewManager creates a new gradient manager
 func NewManager() *Manager {
-	return &Manager{
-		gradients:       make(map[uint32]*GradientUpdate),
-		lastAggregation: time.Now(),
-	}
+	return &Manager{tasks: make(map[string]*task)}
 }
 
-// SubmitGradient receives a gradient update from a worker
-func (m *Manager) SubmitGradient(update *GradientUpdate) error {
+// StartTask starts a training task and publishes its initial model
+func (m *Manager) StartTask(cfg TrainingConfig) error {
+	if cfg.TaskID == "" {
+		return fmt.Errorf("task ID is required")
+	}
+	if len(cfg.Nodes) == 0 {
+		return fmt.Errorf("at least one node is required")
+	}
+	if cfg.Epochs == 0 {
+		return fmt.Errorf("at least one epoch is required")
+	}
+	if cfg.MinNodes > len(cfg.Nodes) {
+		return fmt.Errorf("min nodes %d exceeds the %d nodes", cfg.MinNodes, len(cfg.Nodes))
+	}
+	cfg.Nodes = append([]string(nil), cfg.Nodes...)
+	if cfg.MinNodes <= 0 {
+		cfg.MinNodes = len(cfg.Nodes)/2 + 1
+	}
+	if cfg.RoundTimeout <= 0 {
+		cfg.RoundTimeout = DefaultRoundTimeout
+	}
+	if cfg.LearningRate <= 0 {
+		cfg.LearningRate = DefaultLearningRate
+	}
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
-
-	m.gradients[update.WorkerID] = update
-	log.Printf("📊 Gradient received from worker %d (Loss: %.6f)", update.WorkerID, update.Loss)
-
+	if _, exists := m.tasks[cfg.TaskID]; exists {
+		return fmt.Errorf("%w: %s", ErrTaskExists, cfg.TaskID)
+	}
+	now := time.Now()
+	m.tasks[cfg.TaskID] = &task{
+		config:     cfg,
+		status:     "running",
+		pending:    make(map[string]*GradientUpdate),
+		roundStart: now,
+		published:  make(chan struct{}),
+		model: &GlobalModel{
+			TaskID:     cfg.TaskID,
+			Parameters: append([]float64(nil), cfg.InitialParameters...),
+			Timestamp:  now,
+		},
+	}
+	m.revision++
+	log.Printf("🧠 Federated training task %s started with %d nodes for %d epochs", cfg.TaskID, len(cfg.Nodes), cfg.Epochs)
 	return nil
 }
 
-// AggregateGradients performs gradient aggregation across all submitted gradients
-func (m *Manager) AggregateGradients() ([]float64, error) {
+// StopTask stops a training task; its latest model stays available
+func (m *Manager) StopTask(taskID string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-
-	if len(m.gradients) == 0 {
-		log.Println("⚠️  No gradients available for aggregation")
-		return nil, fmt.Errorf("no gradients available for aggregation")
+	t, ok := m.tasks[taskID]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrTaskNotFound, taskID)
+	}
+	if t.status == "running" {
+		t.status = "stopped"
+		t.pending = make(map[string]*GradientUpdate)
+		m.publishLocked(t)
+		m.revision++
 	}
+	return nil
+}
 
-	// Average gradients from all workers
-	var sumGradients []float64
-	var expectedLength int
-	count := 0
+// SubmitGradient receives a node's gradient for the current epoch,
+// replacing any it sent earlier in the epoch. It reports whether the
+// gradient completed the epoch and a new global model was published.
+func (m *Manager) SubmitGradient(update *GradientUpdate) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
-	for _, update := range m.gradients {
-		if count == 0 {
-			expectedLength = len(update.Data)
-			sumGradients = make([]float64, expectedLength)
-		} else if len(update.Data) != expectedLength {
-			// Return error immediately on length mismatch for data consistency
-			return nil, fmt.Errorf("gradient length mismatch: expected %d, got %d from worker %d",
-				expectedLength, len(update.Data), update.WorkerID)
-		}
-		for i, v := range update.Data {
-			sumGradients[i] += v
-		}
-		count++
+	t, ok := m.tasks[update.TaskID]
+	if !ok {
+		return false, fmt.Errorf("%w: %s", ErrTaskNotFound, update.TaskID)
 	}
-
-	if count == 0 {
-		return nil, fmt.Errorf("no valid gradients to aggregate")
+	if t.status != "running" {
+		return false, fmt.Errorf("%w: %s is %s", ErrTaskNotRunning, update.TaskID, t.status)
+	}
+	if !slices.Contains(t.config.Nodes, update.NodeID) {
+		return false, fmt.Errorf("node %q is not part of task %s", update.NodeID, update.TaskID)
+	}
+	if update.ModelVersion != t.epoch {
+		return false, fmt.Errorf("%w: computed on model version %d, current is %d", ErrStaleGradient, update.ModelVersion, t.epoch)
+	}
+	if update.NumSamples == 0 {
+		return false, fmt.Errorf("gradient from %s covers no samples", update.NodeID)
+	}
+	if want := t.paramCount(); want >= 0 && len(update.Data) != want {
+		return false, fmt.Errorf("gradient length mismatch: expected %d, got %d from node %s", want, len(update.Data), update.NodeID)
 	}
 
-	// Average
-	for i := range sumGradients {
-		sumGradients[i] /= float64(count)
+	update.Timestamp = time.Now()
+	t.pending[update.NodeID] = update
+	m.revision++
+	log.Printf("📊 Gradient received from node %s for task %s epoch %d (Loss: %.6f)", update.NodeID, update.TaskID, t.epoch, update.Loss)
+
+	if len(t.pending) < len(t.config.Nodes) {
+		return false, nil
 	}
+	m.aggregateLocked(t)
+	return true, nil
+}
 
-	m.aggregationRound++
-	m.lastAggregation = time.Now()
+// GlobalModel returns a task's latest global model
+func (m *Manager) GlobalModel(taskID string) (*GlobalModel, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	t, ok := m.tasks[taskID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrTaskNotFound, taskID)
+	}
+	return t.model, nil
+}
 
-	log.Printf("✅ Aggregated gradients from %d workers (Round: %d)", count, m.aggregationRound)
+// WaitGlobalModel returns a task's latest global model once its version
+// is past after, or the task has finished, waiting until ctx is done. On
+// timeout it returns the latest model, which the caller can tell apart by
+// its version.
+func (m *Manager) WaitGlobalModel(ctx context.Context, taskID string, after uint32) (*GlobalModel, error) {
+	for {
+		m.mu.RLock()
+		t, ok := m.tasks[taskID]
+		if !ok {
+			m.mu.RUnlock()
+			return nil, fmt.Errorf("%w: %s", ErrTaskNotFound, taskID)
+		}
+		model, published := t.model, t.published
+		done := t.status != "running"
+		m.mu.RUnlock()
 
-	return sumGradients, nil
+		if model.Version > after || done {
+			return model, nil
+		}
+		select {
+		case <-published:
+		case <-ctx.Done():
+			return model, nil
+		}
+	}
 }
 
-// GetAggregationStats returns statistics about gradient aggregation
-func (m *Manager) GetAggregationStats() map[string]interface{} {
+// Status returns a task's progress
+func (m *Manager) Status(taskID string) (*TaskStatus, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
+	t, ok := m.tasks[taskID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrTaskNotFound, taskID)
+	}
+	status := &TaskStatus{
+		TaskID: taskID,
+		Status: t.status,
+		Epoch:  t.epoch,
+		Epochs: t.config.Epochs,
+		Nodes:  append([]string(nil), t.config.Nodes...),
+		Loss:   t.model.Loss,
+	}
+	for node := range t.pending {
+		status.Submitted = append(status.Submitted, node)
+	}
+	sort.Strings(status.Submitted)
+	return status, nil
+}
 
-	return map[string]interface{}{
-		"aggregation_round":   m.aggregationRound,
-		"last_aggregation":    m.lastAggregation,
-		"active_workers":      len(m.gradients),
-		"time_since_last_agg": time.Since(m.lastAggregation).Seconds(),
+// Run relaxes epoch barriers that have waited too long until ctx is
+// cancelled
+func (m *Manager) Run(ctx context.Context) {
+	ticker := time.NewTicker(monitorInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			m.checkRounds(now)
+		}
 	}
 }
 
-// Reset clears all stored gradients for the next round
-func (m *Manager) Reset() {
+// checkRounds aggregates every running round that has outlived its
+// timeout with at least its minimum number of gradients
+func (m *Manager) checkRounds(now time.Time) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	if m.standby {
+		return
+	}
+	for _, t := range m.tasks {
+		if t.status != "running" || now.Sub(t.roundStart) < t.config.RoundTimeout {
+			continue
+		}
+		if received := len(t.pending); received >= t.config.MinNodes {
+			log.Printf("⏱️  Task %s epoch %d timed out, proceeding with %d of %d nodes",
+				t.config.TaskID, t.epoch, received, len(t.config.Nodes))
+			m.aggregateLocked(t)
+		}
+	}
+}
+
+// aggregateLocked applies the sample-weighted mean of the epoch's
+// gradients to the global model and publishes the next version. Caller
+// must hold m.mu.
+func (m *Manager) aggregateLocked(t *task) {
+	var totalSamples float64
+	var loss, accuracy float64
+	var mean []float64
+	for _, update := range t.pending {
+		if mean == nil {
+			mean = make([]float64, len(update.Data))
+		}
+		weight := float64(update.NumSamples)
+		totalSamples += weight
+		loss += update.Loss * weight
+		accuracy += update.Accuracy * weight
+		for i, v := range update.Data {
+			mean[i] += v * weight
+		}
+	}
 
-	m.gradients = make(map[uint32]*GradientUpdate)
-	log.Println("🔄 Gradient manager reset for next round")
+	params := t.model.Parameters
+	if len(params) == 0 {
+		params = make([]float64, len(mean))
+	}
+	next := make([]float64, len(params))
+	for i := range params {
+		next[i] = params[i] - t.config.LearningRate*mean[i]/totalSamples
+	}
+
+	t.epoch++
+	t.model = &GlobalModel{
+		TaskID:     t.config.TaskID,
+		Version:    t.epoch,
+		Parameters: next,
+		NumNodes:   uint32(len(t.pending)),
+		Loss:       loss / totalSamples,
+		Accuracy:   accuracy / totalSamples,
+		Final:      t.epoch >= t.config.Epochs,
+		Timestamp:  time.Now(),
+	}
+	t.pending = make(map[string]*GradientUpdate)
+	t.roundStart = t.model.Timestamp
+	if t.model.Final {
+		t.status = "completed"
+	}
+	log.Printf("✅ Aggregated gradients from %d nodes for task %s (Version: %d, Loss: %.6f)",
+		t.model.NumNodes, t.config.TaskID, t.epoch, t.model.Loss)
+	m.publishLocked(t)
+	m.revision++
+}
+
+// publishLocked wakes callers waiting for a new model. Caller must hold
+// m.mu.
+func (m *Manager) publishLocked(t *task) {
+	close(t.published)
+	t.published = make(chan struct{})
+}
+
+// paramCount returns the model's parameter count, or -1 if neither the
+// initial parameters nor a gradient have fixed it yet
+func (t *task) paramCount() int {
+	if len(t.model.Parameters) > 0 {
+		return len(t.model.Parameters)
+	}
+	for _, update := range t.pending {
+		return len(update.Data)
+	}
+	return -1
 }
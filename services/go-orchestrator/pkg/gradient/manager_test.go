@@ -0,0 +1,104 @@
+package gradient
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestEpochsAggregateAcrossNodes(t *testing.T) {
+	m := NewManager()
+	err := m.StartTask(TrainingConfig{
+		TaskID:            "fl",
+		Nodes:             []string{"a", "b"},
+		Epochs:            2,
+		LearningRate:      0.5,
+		InitialParameters: []float64{1, 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	waited := make(chan *GlobalModel, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		model, _ := m.WaitGlobalModel(ctx, "fl", 0)
+		waited <- model
+	}()
+
+	if done, err := m.SubmitGradient(&GradientUpdate{TaskID: "fl", NodeID: "a", Data: []float64{1, 0}, NumSamples: 1, Loss: 3}); err != nil || done {
+		t.Fatalf("expected the first gradient held, got %v %v", done, err)
+	}
+	if _, err := m.SubmitGradient(&GradientUpdate{TaskID: "fl", NodeID: "c", Data: []float64{0, 0}, NumSamples: 1}); err == nil {
+		t.Fatal("expected a gradient from an unknown node refused")
+	}
+	if _, err := m.SubmitGradient(&GradientUpdate{TaskID: "fl", NodeID: "b", Data: []float64{1}, NumSamples: 1}); err == nil {
+		t.Fatal("expected a gradient of the wrong size refused")
+	}
+	// Sample-weighted: mean = (1*[1,0] + 3*[0,2]) / 4 = [0.25, 1.5]
+	if done, err := m.SubmitGradient(&GradientUpdate{TaskID: "fl", NodeID: "b", Data: []float64{0, 2}, NumSamples: 3, Loss: 1}); err != nil || !done {
+		t.Fatalf("expected the epoch aggregated, got %v %v", done, err)
+	}
+
+	model := <-waited
+	if model.Version != 1 || model.NumNodes != 2 || model.Final {
+		t.Fatalf("unexpected model %+v", model)
+	}
+	if model.Parameters[0] != 0.875 || model.Parameters[1] != 0.25 || model.Loss != 1.5 {
+		t.Fatalf("unexpected parameters %v loss %v", model.Parameters, model.Loss)
+	}
+
+	if _, err := m.SubmitGradient(&GradientUpdate{TaskID: "fl", NodeID: "a", ModelVersion: 0, Data: []float64{0, 0}, NumSamples: 1}); !errors.Is(err, ErrStaleGradient) {
+		t.Fatalf("expected a stale gradient refused, got %v", err)
+	}
+	for _, node := range []string{"a", "b"} {
+		if _, err := m.SubmitGradient(&GradientUpdate{TaskID: "fl", NodeID: node, ModelVersion: 1, Data: []float64{0, 0}, NumSamples: 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	status, _ := m.Status("fl")
+	if status.Status != "completed" || status.Epoch != 2 {
+		t.Fatalf("expected the task completed, got %+v", status)
+	}
+	if model, _ := m.GlobalModel("fl"); !model.Final {
+		t.Fatal("expected the last model marked final")
+	}
+	if _, err := m.SubmitGradient(&GradientUpdate{TaskID: "fl", NodeID: "a", ModelVersion: 2, Data: []float64{0, 0}, NumSamples: 1}); !errors.Is(err, ErrTaskNotRunning) {
+		t.Fatalf("expected a completed task to refuse gradients, got %v", err)
+	}
+}
+
+func TestTimedOutRoundProceedsWithMinNodes(t *testing.T) {
+	m := NewManager()
+	if err := m.StartTask(TrainingConfig{TaskID: "fl", Nodes: []string{"a", "b", "c"}, Epochs: 5, RoundTimeout: time.Minute}); err != nil {
+		t.Fatal(err)
+	}
+	m.SubmitGradient(&GradientUpdate{TaskID: "fl", NodeID: "a", Data: []float64{1}, NumSamples: 1})
+
+	// A majority of 2 is needed even after the timeout
+	m.checkRounds(time.Now().Add(2 * time.Minute))
+	if model, _ := m.GlobalModel("fl"); model.Version != 0 {
+		t.Fatal("expected the round to wait for a majority")
+	}
+	m.SubmitGradient(&GradientUpdate{TaskID: "fl", NodeID: "b", Data: []float64{1}, NumSamples: 1})
+	m.checkRounds(time.Now())
+	if model, _ := m.GlobalModel("fl"); model.Version != 0 {
+		t.Fatal("expected the round to wait for its timeout")
+	}
+	m.checkRounds(time.Now().Add(2 * time.Minute))
+	if model, _ := m.GlobalModel("fl"); model.Version != 1 || model.NumNodes != 2 {
+		t.Fatalf("expected the timed out round aggregated, got %+v", model)
+	}
+
+	// A wait that times out returns the current model
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if model, err := m.WaitGlobalModel(ctx, "fl", 1); err != nil || model.Version != 1 {
+		t.Fatalf("unexpected wait result %+v %v", model, err)
+	}
+	if _, err := m.WaitGlobalModel(ctx, "missing", 0); !errors.Is(err, ErrTaskNotFound) {
+		t.Fatalf("expected ErrTaskNotFound, got %v", err)
+	}
+}
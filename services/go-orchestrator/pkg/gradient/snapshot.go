@@ -0,0 +1,96 @@
+package gradient
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// taskSnapshot is a task as replicated to standby orchestrators
+type taskSnapshot struct {
+	Config     TrainingConfig             `json:"config"`
+	Status     string                     `json:"status"`
+	Epoch      uint32                     `json:"epoch"`
+	Model      *GlobalModel               `json:"model"`
+	Pending    map[string]*GradientUpdate `json:"pending"`
+	RoundStart time.Time                  `json:"round_start"`
+}
+
+// Revision returns a counter that changes whenever the manager's state
+// does, so a replica knows when it needs a new snapshot
+func (m *Manager) Revision() uint64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.revision
+}
+
+// Snapshot serializes every task, including the gradients received for
+// the current epochs
+func (m *Manager) Snapshot() ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	tasks := make(map[string]*taskSnapshot, len(m.tasks))
+	for id, t := range m.tasks {
+		tasks[id] = &taskSnapshot{
+			Config:     t.config,
+			Status:     t.status,
+			Epoch:      t.epoch,
+			Model:      t.model,
+			Pending:    t.pending,
+			RoundStart: t.roundStart,
+		}
+	}
+	return json.Marshal(tasks)
+}
+
+// Restore replaces every task with a snapshot's. Callers waiting for a
+// model are woken and see the restored state.
+func (m *Manager) Restore(data []byte) error {
+	var tasks map[string]*taskSnapshot
+	if err := json.Unmarshal(data, &tasks); err != nil {
+		return fmt.Errorf("invalid training snapshot: %w", err)
+	}
+	restored := make(map[string]*task, len(tasks))
+	for id, s := range tasks {
+		if s.Model == nil {
+			return fmt.Errorf("invalid training snapshot: task %s has no model", id)
+		}
+		pending := s.Pending
+		if pending == nil {
+			pending = make(map[string]*GradientUpdate)
+		}
+		restored[id] = &task{
+			config:     s.Config,
+			status:     s.Status,
+			epoch:      s.Epoch,
+			model:      s.Model,
+			pending:    pending,
+			roundStart: s.RoundStart,
+			published:  make(chan struct{}),
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, t := range m.tasks {
+		close(t.published)
+	}
+	m.tasks = restored
+	m.revision++
+	return nil
+}
+
+// SetStandby stops or resumes timing out epochs. A standby manager holds a
+// replica of the leader's state and must not aggregate on its own.
+func (m *Manager) SetStandby(standby bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.standby = standby
+	if !standby {
+		// Rounds replicated mid-epoch restart their timeout on takeover
+		now := time.Now()
+		for _, t := range m.tasks {
+			t.roundStart = now
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package metrics
 
 import (
+	"github.com/pangea-net/go-node/pkg/compute"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
@@ -61,6 +62,14 @@ var (
 		},
 	)
 
+	// Cluster leadership gauge
+	ClusterLeader = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "orchestrator_cluster_leader",
+			Help: "1 if this orchestrator leads its cluster, else 0",
+		},
+	)
+
 	// Gradient aggregation counter
 	GradientAggregationsTotal = promauto.NewCounter(
 		prometheus.CounterOpts{
@@ -69,3 +78,9 @@ var (
 		},
 	)
 )
+
+// RegisterCompute exports a compute manager's job, chunk, queue depth and
+// worker trust metrics, labelled by job priority where they apply
+func RegisterCompute(manager *compute.Manager) {
+	prometheus.MustRegister(manager.Metrics())
+}
@@ -0,0 +1,108 @@
+package observability
+
+import (
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// backendRetryMin and backendRetryMax bound how long an unreachable
+	// backend is skipped before the next attempt; the wait doubles with
+	// each failure
+	backendRetryMin = time.Second
+	backendRetryMax = time.Minute
+)
+
+// BackendStatus reports how an exporter is faring
+type BackendStatus struct {
+	Name      string
+	Healthy   bool
+	Sent      uint64 // Spans or log lines delivered
+	Dropped   uint64 // Spans or log lines lost to a full queue or an unreachable backend
+	LastError string
+}
+
+// backendHealth tracks an exporter's backend. While it is unreachable
+// exports are skipped, with exponential backoff between attempts, so a down
+// backend costs neither memory nor a timeout per batch. State changes are
+// logged once rather than per failure.
+type backendHealth struct {
+	name    string
+	mu      sync.Mutex
+	down    bool
+	delay   time.Duration
+	retryAt time.Time
+	sent    uint64
+	lost    uint64
+	lastErr string
+}
+
+func newBackendHealth(name string) *backendHealth {
+	return &backendHealth{name: name}
+}
+
+// ready reports whether an export should be attempted now
+func (h *backendHealth) ready() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return !h.down || !time.Now().Before(h.retryAt)
+}
+
+// report records the outcome of exporting n items
+func (h *backendHealth) report(err error, n int) {
+	h.mu.Lock()
+	wasDown := h.down
+	if err == nil {
+		h.sent += uint64(n)
+		h.down, h.delay = false, 0
+	} else {
+		h.lost += uint64(n)
+		h.delay = min(max(2*h.delay, backendRetryMin), backendRetryMax)
+		h.down, h.retryAt, h.lastErr = true, time.Now().Add(h.delay), err.Error()
+	}
+	h.mu.Unlock()
+
+	if err != nil && !wasDown {
+		log.Printf("⚠️  [OBS] %s backend unreachable, dropping data until it recovers: %v", h.name, err)
+	} else if err == nil && wasDown {
+		log.Printf("✅ [OBS] %s backend reachable again", h.name)
+	}
+}
+
+// dropped records n items lost without an export attempt
+func (h *backendHealth) dropped(n int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lost += uint64(n)
+}
+
+func (h *backendHealth) status() BackendStatus {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return BackendStatus{Name: h.name, Healthy: !h.down, Sent: h.sent, Dropped: h.lost, LastError: h.lastErr}
+}
+
+// parseKeyValues parses comma separated key=value pairs, as used by
+// OTEL_EXPORTER_OTLP_HEADERS and LOKI_LABELS
+func parseKeyValues(s string) map[string]string {
+	out := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(pair, "=")
+		if k = strings.TrimSpace(k); ok && k != "" {
+			out[k] = strings.TrimSpace(v)
+		}
+	}
+	return out
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,258 @@
+package observability
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// logQueueSize bounds log lines waiting to be pushed to Loki; lines
+	// beyond it are dropped
+	logQueueSize = 4096
+
+	// logBatchSize is the most lines sent in one push
+	logBatchSize = 1000
+
+	// logPushInterval is how often queued lines are pushed
+	logPushInterval = 2 * time.Second
+)
+
+// logShipper is an io.Writer for the standard logger that copies each line
+// to a size-rotated file and/or Loki. Writes never fail or block on a
+// backend, so logging keeps working when shipping does not.
+type logShipper struct {
+	fileMu   sync.Mutex
+	file     *os.File
+	filePath string
+	fileMax  int64 // Rotate when the file would exceed this, 0 = never
+	fileSize int64
+	fileErr  error
+
+	loki *lokiSink
+}
+
+// openLogFile appends to path, rotating it to path.1 past maxBytes
+func (l *logShipper) openLogFile(path string, maxBytes int64) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	l.file, l.filePath, l.fileMax, l.fileSize = f, path, maxBytes, info.Size()
+	return nil
+}
+
+// Write implements io.Writer. The standard logger writes one line per call.
+func (l *logShipper) Write(p []byte) (int, error) {
+	l.writeFile(p)
+	if l.loki != nil {
+		l.loki.enqueue(time.Now(), strings.TrimRight(string(p), "\n"))
+	}
+	return len(p), nil
+}
+
+func (l *logShipper) writeFile(p []byte) {
+	l.fileMu.Lock()
+	defer l.fileMu.Unlock()
+	if l.file == nil {
+		return
+	}
+	if l.fileMax > 0 && l.fileSize > 0 && l.fileSize+int64(len(p)) > l.fileMax {
+		l.rotateLocked()
+		if l.file == nil {
+			return
+		}
+	}
+	n, err := l.file.Write(p)
+	l.fileSize += int64(n)
+	if err != nil && l.fileErr == nil {
+		// Reported once on stderr; logging it would write here again
+		l.fileErr = err
+		fmt.Fprintf(os.Stderr, "⚠️  [OBS] Log file write failed: %v\n", err)
+	}
+}
+
+// rotateLocked moves the log file to path.1, replacing any older one, and
+// starts a new file. Caller must hold fileMu.
+func (l *logShipper) rotateLocked() {
+	l.file.Close()
+	l.file = nil
+	if err := os.Rename(l.filePath, l.filePath+".1"); err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  [OBS] Log file rotation failed: %v\n", err)
+	}
+	f, err := os.OpenFile(l.filePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  [OBS] Log file reopen failed, file logging stopped: %v\n", err)
+		return
+	}
+	l.file, l.fileSize = f, 0
+}
+
+// close flushes Loki and closes the log file
+func (l *logShipper) close(ctx context.Context) error {
+	var err error
+	if l.loki != nil {
+		err = l.loki.shutdown(ctx)
+	}
+	l.fileMu.Lock()
+	defer l.fileMu.Unlock()
+	if l.file != nil {
+		l.file.Close()
+		l.file = nil
+	}
+	return err
+}
+
+// ============================================================
+// Loki
+// ============================================================
+
+type lokiEntry struct {
+	time time.Time
+	line string
+}
+
+// lokiSink batches log lines and posts them to Loki's push API. While
+// Loki is unreachable lines are dropped rather than queued without bound.
+type lokiSink struct {
+	url    string
+	labels map[string]string
+	client *http.Client
+	health *backendHealth
+	queue  chan lokiEntry
+	flush  chan chan struct{}
+	done   chan struct{}
+}
+
+// newLokiSink pushes to endpoint, Loki's base URL such as
+// http://localhost:3100 or the full /loki/api/v1/push URL
+func newLokiSink(endpoint string, labels map[string]string) (*lokiSink, error) {
+	if !strings.HasPrefix(endpoint, "http://") && !strings.HasPrefix(endpoint, "https://") {
+		return nil, fmt.Errorf("loki URL %q must be an http:// or https:// URL", endpoint)
+	}
+	if len(labels) == 0 {
+		return nil, fmt.Errorf("loki streams need at least one label")
+	}
+	url := strings.TrimSuffix(endpoint, "/")
+	if !strings.HasSuffix(url, "/loki/api/v1/push") {
+		url += "/loki/api/v1/push"
+	}
+	s := &lokiSink{
+		url:    url,
+		labels: labels,
+		client: &http.Client{Timeout: 10 * time.Second},
+		health: newBackendHealth("Loki"),
+		queue:  make(chan lokiEntry, logQueueSize),
+		flush:  make(chan chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go s.run()
+	return s, nil
+}
+
+// enqueue queues a log line, dropping it if the queue is full
+func (s *lokiSink) enqueue(t time.Time, line string) {
+	select {
+	case s.queue <- lokiEntry{time: t, line: line}:
+	default:
+		s.health.dropped(1)
+	}
+}
+
+// shutdown pushes queued lines and stops the sink
+func (s *lokiSink) shutdown(ctx context.Context) error {
+	flushed := make(chan struct{})
+	select {
+	case s.flush <- flushed:
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-flushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *lokiSink) run() {
+	ticker := time.NewTicker(logPushInterval)
+	defer ticker.Stop()
+	var batch []lokiEntry
+	send := func(force bool) {
+		for len(batch) > 0 {
+			n := min(len(batch), logBatchSize)
+			if force || s.health.ready() {
+				s.health.report(s.push(batch[:n]), n)
+			} else {
+				s.health.dropped(n)
+			}
+			batch = batch[n:]
+		}
+		batch = nil
+	}
+	for {
+		select {
+		case e := <-s.queue:
+			batch = append(batch, e)
+			if len(batch) >= logBatchSize {
+				send(false)
+			}
+		case <-ticker.C:
+			send(false)
+		case flushed := <-s.flush:
+			for drained := false; !drained; {
+				select {
+				case e := <-s.queue:
+					batch = append(batch, e)
+				default:
+					drained = true
+				}
+			}
+			send(true)
+			close(s.done)
+			close(flushed)
+			return
+		}
+	}
+}
+
+// push posts one batch of lines as a single stream
+func (s *lokiSink) push(entries []lokiEntry) error {
+	values := make([][2]string, 0, len(entries))
+	for _, e := range entries {
+		values = append(values, [2]string{strconv.FormatInt(e.time.UnixNano(), 10), e.line})
+	}
+	body, err := json.Marshal(lokiPushRequest{Streams: []lokiStream{{Stream: s.labels, Values: values}}})
+	if err != nil {
+		return err
+	}
+	return postJSON(s.client, s.url, nil, body)
+}
+
+// JSON encoding of Loki's push API request
+type lokiPushRequest struct {
+	Streams []lokiStream `json:"streams"`
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"` // [unix nanos, line]
+}
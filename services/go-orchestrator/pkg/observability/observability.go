@@ -3,8 +3,10 @@ package observability
 import (
 	"context"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"strconv"
 	"time"
 
 	ddtrace "github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
@@ -13,6 +15,8 @@ import (
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/getsentry/sentry-go"
 	"github.com/newrelic/go-agent/v3/newrelic"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
 // Config holds configuration for observability tools
@@ -32,6 +36,17 @@ type Config struct {
 	AWSAccessKeyID        string
 	AWSSecretAccessKey    string
 	AWSRegion             string
+	// OTLP traces
+	OTLPEndpoint string
+	OTLPHeaders  map[string]string
+	ServiceName  string
+	// Log shipping
+	LokiURL      string
+	LokiLabels   map[string]string
+	LogFile      string
+	LogFileMaxMB int
+	// Runtime profiling
+	PprofAddr string
 }
 
 // LoadConfigFromEnv loads configuration from environment variables
@@ -48,6 +63,14 @@ func LoadConfigFromEnv() *Config {
 		AWSAccessKeyID:        os.Getenv("AWS_ACCESS_KEY_ID"),
 		AWSSecretAccessKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
 		AWSRegion:             getEnvOrDefault("AWS_REGION", "eu-west-2"),
+		OTLPEndpoint:          getEnvOrDefault("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")),
+		OTLPHeaders:           parseKeyValues(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS")),
+		ServiceName:           getEnvOrDefault("OTEL_SERVICE_NAME", "go-orchestrator"),
+		LokiURL:               os.Getenv("LOKI_URL"),
+		LokiLabels:            parseKeyValues(os.Getenv("LOKI_LABELS")),
+		LogFile:               os.Getenv("LOG_FILE"),
+		LogFileMaxMB:          getEnvInt("LOG_FILE_MAX_MB", 100),
+		PprofAddr:             os.Getenv("PPROF_ADDR"),
 	}
 }
 
@@ -58,6 +81,13 @@ func getEnvOrDefault(key, defaultValue string) string {
 	return defaultValue
 }
 
+func getEnvInt(key string, defaultValue int) int {
+	if value, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
 // Manager manages all observability integrations
 type Manager struct {
 	config        *Config
@@ -65,6 +95,10 @@ type Manager struct {
 	awsSession    *session.Session
 	datadogActive bool
 	sentryActive  bool
+	spans         *spanPipeline
+	logs          *logShipper
+	logOutput     io.Writer // Standard logger output before logs were shipped
+	profiling     *profilingServer
 }
 
 // NewManager creates a new observability manager
@@ -116,6 +150,33 @@ func (m *Manager) Initialize() error {
 		}
 	}
 
+	// Initialize OTLP tracing
+	if m.config.OTLPEndpoint != "" {
+		if err := m.initializeTracing(); err != nil {
+			log.Printf("⚠️  Failed to initialize OTLP tracing: %v", err)
+		} else {
+			log.Printf("✅ OTLP tracing initialized (%s)", m.spans.url)
+		}
+	}
+
+	// Initialize log shipping
+	if m.config.LokiURL != "" || m.config.LogFile != "" {
+		if err := m.initializeLogShipping(); err != nil {
+			log.Printf("⚠️  Failed to initialize log shipping: %v", err)
+		}
+	}
+
+	// Initialize runtime profiling
+	if m.config.PprofAddr != "" {
+		p, err := startProfiling(m.config.PprofAddr)
+		if err != nil {
+			log.Printf("⚠️  Failed to start profiling server: %v", err)
+		} else {
+			m.profiling = p
+			log.Printf("✅ Runtime profiling at http://%s/debug/pprof/", p.Addr())
+		}
+	}
+
 	return nil
 }
 
@@ -194,6 +255,54 @@ func (m *Manager) initializeAWS() error {
 	return nil
 }
 
+// initializeTracing sets up OTLP/HTTP span export
+func (m *Manager) initializeTracing() error {
+	resource := map[string]string{"service.name": m.config.ServiceName}
+	if version := os.Getenv("DD_VERSION"); version != "" {
+		resource["service.version"] = version
+	}
+	spans, err := newSpanPipeline(m.config.OTLPEndpoint, m.config.OTLPHeaders, resource)
+	if err != nil {
+		return err
+	}
+	m.spans = spans
+	return nil
+}
+
+// initializeLogShipping copies the standard logger's output to a log file
+// and/or Loki. Each backend that fails to start is skipped.
+func (m *Manager) initializeLogShipping() error {
+	shipper := &logShipper{}
+	if m.config.LogFile != "" {
+		if err := shipper.openLogFile(m.config.LogFile, int64(m.config.LogFileMaxMB)<<20); err != nil {
+			log.Printf("⚠️  Failed to open log file: %v", err)
+		} else {
+			log.Printf("✅ Logging to %s", m.config.LogFile)
+		}
+	}
+	if m.config.LokiURL != "" {
+		labels := map[string]string{"service": m.config.ServiceName}
+		for k, v := range m.config.LokiLabels {
+			labels[k] = v
+		}
+		loki, err := newLokiSink(m.config.LokiURL, labels)
+		if err != nil {
+			log.Printf("⚠️  Failed to initialize Loki: %v", err)
+		} else {
+			shipper.loki = loki
+			log.Printf("✅ Shipping logs to %s", loki.url)
+		}
+	}
+	if shipper.file == nil && shipper.loki == nil {
+		return fmt.Errorf("no log backend available")
+	}
+
+	m.logs = shipper
+	m.logOutput = log.Writer()
+	log.SetOutput(io.MultiWriter(m.logOutput, shipper))
+	return nil
+}
+
 // GetAWSSession returns the AWS session for LocalStack
 func (m *Manager) GetAWSSession() *session.Session {
 	return m.awsSession
@@ -227,10 +336,44 @@ func (m *Manager) StartDatadogSpan(ctx context.Context, operationName string) co
 	return ctx
 }
 
+// StartSpan starts an OTLP span, a child of any span in ctx. The span
+// records nothing if OTLP tracing is not enabled.
+func (m *Manager) StartSpan(ctx context.Context, operationName string) (context.Context, trace.Span) {
+	if m.spans == nil {
+		return ctx, noop.Span{}
+	}
+	return m.spans.tracer.Start(ctx, operationName)
+}
+
+// Status reports the exporters that are running and how they are faring
+func (m *Manager) Status() []BackendStatus {
+	var statuses []BackendStatus
+	if m.spans != nil {
+		statuses = append(statuses, m.spans.health.status())
+	}
+	if m.logs != nil && m.logs.loki != nil {
+		statuses = append(statuses, m.logs.loki.health.status())
+	}
+	return statuses
+}
+
 // Shutdown gracefully shuts down all observability tools
 func (m *Manager) Shutdown() {
 	log.Println("🔭 Shutting down observability tools...")
 
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if m.profiling != nil {
+		m.profiling.shutdown(ctx)
+	}
+
+	if m.spans != nil {
+		if err := m.spans.shutdown(ctx); err != nil {
+			log.Printf("⚠️  Failed to flush spans: %v", err)
+		}
+	}
+
 	if m.datadogActive {
 		ddtrace.Stop()
 	}
@@ -242,4 +385,12 @@ func (m *Manager) Shutdown() {
 	if m.newRelicApp != nil {
 		m.newRelicApp.Shutdown(5 * time.Second)
 	}
+
+	// Last, so the lines above are shipped too
+	if m.logs != nil {
+		log.SetOutput(m.logOutput)
+		if err := m.logs.close(ctx); err != nil {
+			log.Printf("⚠️  Failed to flush logs: %v", err)
+		}
+	}
 }
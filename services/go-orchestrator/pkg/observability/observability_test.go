@@ -0,0 +1,244 @@
+package observability
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+// collector records the JSON bodies posted to it
+type collector struct {
+	mu     sync.Mutex
+	bodies [][]byte
+	paths  []string
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	c.mu.Lock()
+	c.bodies = append(c.bodies, body)
+	c.paths = append(c.paths, r.URL.Path+" "+r.Header.Get("Authorization"))
+	c.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func TestSpansExportAsOTLP(t *testing.T) {
+	c := &collector{}
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	m := NewManager(&Config{OTLPEndpoint: srv.URL, OTLPHeaders: map[string]string{"Authorization": "Bearer t"}, ServiceName: "orch"})
+	if err := m.initializeTracing(); err != nil {
+		t.Fatal(err)
+	}
+	ctx, parent := m.StartSpan(context.Background(), "job")
+	_, child := m.StartSpan(ctx, "chunk")
+	child.SetAttributes(attribute.String("chunk", "0"))
+	EndSpan(child, errors.New("worker lost"))
+	EndSpan(parent, nil)
+	if err := m.spans.shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(c.bodies) != 1 || c.paths[0] != "/v1/traces Bearer t" {
+		t.Fatalf("expected one export to /v1/traces, got %v", c.paths)
+	}
+	var req coltracepb.ExportTraceServiceRequest
+	if err := proto.Unmarshal(c.bodies[0], &req); err != nil {
+		t.Fatal(err)
+	}
+	res := req.ResourceSpans[0]
+	if attrs := res.Resource.Attributes; len(attrs) != 1 || attrs[0].Value.GetStringValue() != "orch" {
+		t.Fatalf("unexpected resource %+v", attrs)
+	}
+	spans := res.ScopeSpans[0].Spans
+	if len(spans) != 2 || spans[0].Name != "chunk" || spans[1].Name != "job" {
+		t.Fatalf("unexpected spans %+v", spans)
+	}
+	if !bytes.Equal(spans[0].TraceId, spans[1].TraceId) || !bytes.Equal(spans[0].ParentSpanId, spans[1].SpanId) {
+		t.Fatal("child span not linked to its parent")
+	}
+	if spans[0].Status.Code != tracepb.Status_STATUS_CODE_ERROR || spans[0].Status.Message != "worker lost" {
+		t.Fatalf("expected the child span failed, got %+v", spans[0].Status)
+	}
+	if s := m.Status(); len(s) != 1 || !s[0].Healthy || s[0].Sent != 2 {
+		t.Fatalf("unexpected status %+v", s)
+	}
+
+	// Without an endpoint spans record nothing
+	var off Manager
+	_, span := off.StartSpan(context.Background(), "noop")
+	span.SetAttributes(attribute.String("k", "v"))
+	EndSpan(span, nil)
+	if span.IsRecording() {
+		t.Fatal("expected a non-recording span with tracing disabled")
+	}
+}
+
+func TestTracingInterceptorContinuesCallerTrace(t *testing.T) {
+	c := &collector{}
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	m := NewManager(&Config{OTLPEndpoint: srv.URL, ServiceName: "orch"})
+	if err := m.initializeTracing(); err != nil {
+		t.Fatal(err)
+	}
+	caller := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1, 2, 3},
+		SpanID:     trace.SpanID{4, 5, 6},
+		TraceFlags: trace.FlagsSampled,
+	})
+	md := metadata.MD{}
+	traceContext.Inject(trace.ContextWithRemoteSpanContext(context.Background(), caller), metadataCarrier(md))
+	ctx := metadata.NewIncomingContext(context.Background(), md)
+
+	var got trace.SpanContext
+	info := &grpc.UnaryServerInfo{FullMethod: "/orchestrator.Orchestrator/SubmitJob"}
+	_, err := m.TracingInterceptor(ctx, nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		got = trace.SpanContextFromContext(ctx)
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.TraceID() != caller.TraceID() || got.SpanID() == caller.SpanID() {
+		t.Fatalf("remote trace context not continued: %v", got)
+	}
+	if err := m.spans.shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	var req coltracepb.ExportTraceServiceRequest
+	if len(c.bodies) != 1 || proto.Unmarshal(c.bodies[0], &req) != nil {
+		t.Fatalf("expected one export, got %d", len(c.bodies))
+	}
+	span := req.ResourceSpans[0].ScopeSpans[0].Spans[0]
+	callerID := caller.SpanID()
+	if span.Kind != tracepb.Span_SPAN_KIND_SERVER || !bytes.Equal(span.ParentSpanId, callerID[:]) {
+		t.Fatalf("unexpected RPC span %+v", span)
+	}
+}
+
+func TestUnreachableBackendDropsWithoutBlocking(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close()
+
+	spans, err := newSpanPipeline(url, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		_, s := spans.tracer.Start(context.Background(), "op")
+		s.End()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := spans.shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	status := spans.health.status()
+	if status.Healthy || status.Sent != 0 || status.Dropped != 10 || status.LastError == "" {
+		t.Fatalf("unexpected status %+v", status)
+	}
+	if spans.health.ready() {
+		t.Fatal("expected the down backend skipped until its retry time")
+	}
+}
+
+func TestLogsShipToLokiAndFile(t *testing.T) {
+	c := &collector{}
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "logs", "orch.log")
+
+	m := NewManager(&Config{LokiURL: srv.URL, LokiLabels: map[string]string{"env": "test"}, LogFile: path, ServiceName: "orch"})
+	orig := log.Writer()
+	defer log.SetOutput(orig)
+	log.SetOutput(io.Discard)
+	if err := m.initializeLogShipping(); err != nil {
+		t.Fatal(err)
+	}
+	m.logs.fileMax = 200
+	for i := 0; i < 5; i++ {
+		log.Printf("line %d %s", i, strings.Repeat("x", 40))
+	}
+	if err := m.logs.close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	log.SetOutput(m.logOutput)
+
+	var pushed lokiPushRequest
+	if len(c.bodies) == 0 || c.paths[0] != "/loki/api/v1/push " {
+		t.Fatalf("expected a push to Loki, got %v", c.paths)
+	}
+	var lines []string
+	for _, body := range c.bodies {
+		if err := json.Unmarshal(body, &pushed); err != nil {
+			t.Fatal(err)
+		}
+		stream := pushed.Streams[0]
+		if stream.Stream["service"] != "orch" || stream.Stream["env"] != "test" {
+			t.Fatalf("unexpected labels %v", stream.Stream)
+		}
+		for _, v := range stream.Values {
+			lines = append(lines, v[1])
+		}
+	}
+	var shipped int
+	for _, line := range lines {
+		if strings.Contains(line, "line ") {
+			shipped++
+		}
+	}
+	if shipped != 5 {
+		t.Fatalf("expected 5 lines shipped, got %q", lines)
+	}
+
+	current, _ := os.ReadFile(path)
+	rotated, err := os.ReadFile(path + ".1")
+	if err != nil {
+		t.Fatalf("expected the log file rotated: %v", err)
+	}
+	if len(current) > 200 || !strings.Contains(string(rotated)+string(current), "line 4") {
+		t.Fatalf("unexpected log files %q / %q", rotated, current)
+	}
+}
+
+func TestProfilingServesPprof(t *testing.T) {
+	p, err := startProfiling("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.shutdown(context.Background())
+
+	resp, err := http.Get("http://" + p.Addr() + "/debug/pprof/goroutine?debug=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), "goroutine profile") {
+		t.Fatalf("unexpected profile response %s", resp.Status)
+	}
+}
@@ -0,0 +1,55 @@
+package observability
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"time"
+)
+
+// profilingServer serves the runtime profiles of net/http/pprof under
+// /debug/pprof/ on its own listener, so they are never exposed on the
+// public metrics address
+type profilingServer struct {
+	server   *http.Server
+	listener net.Listener
+}
+
+// startProfiling serves profiles on addr, for example localhost:6060
+func startProfiling(addr string) (*profilingServer, error) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	p := &profilingServer{
+		// No write timeout: CPU profiles and traces stream for as long as
+		// the caller asks
+		server:   &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second},
+		listener: ln,
+	}
+	go func() {
+		if err := p.server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("⚠️  [OBS] Profiling server stopped: %v", err)
+		}
+	}()
+	return p, nil
+}
+
+// Addr returns the address profiles are served on
+func (p *profilingServer) Addr() string {
+	return p.listener.Addr().String()
+}
+
+func (p *profilingServer) shutdown(ctx context.Context) error {
+	return p.server.Shutdown(ctx)
+}
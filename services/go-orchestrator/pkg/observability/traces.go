@@ -0,0 +1,185 @@
+package observability
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// tracerName is the instrumentation scope of the orchestrator's spans
+	tracerName = "github.com/pangea-net/go-orchestrator"
+
+	// spanQueueSize bounds finished spans waiting for export; the SDK drops
+	// spans beyond it without counting them in Status
+	spanQueueSize = 2048
+
+	// spanBatchSize is the most spans sent in one export request
+	spanBatchSize = 512
+
+	// spanExportInterval is how often queued spans are exported
+	spanExportInterval = 5 * time.Second
+)
+
+// traceContext propagates W3C traceparent/tracestate headers
+var traceContext = propagation.TraceContext{}
+
+// EndSpan records err, if any, on span and ends it
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// spanPipeline exports the orchestrator's spans through the OpenTelemetry
+// SDK's batch processor and OTLP/HTTP exporter
+type spanPipeline struct {
+	url      string
+	provider *sdktrace.TracerProvider
+	tracer   trace.Tracer
+	health   *backendHealth
+}
+
+// newSpanPipeline exports to endpoint, an OTLP/HTTP base URL such as
+// http://localhost:4318 or the full /v1/traces URL
+func newSpanPipeline(endpoint string, headers, attrs map[string]string) (*spanPipeline, error) {
+	if !strings.HasPrefix(endpoint, "http://") && !strings.HasPrefix(endpoint, "https://") {
+		return nil, fmt.Errorf("OTLP endpoint %q must be an http:// or https:// URL", endpoint)
+	}
+	url := strings.TrimSuffix(endpoint, "/")
+	if !strings.HasSuffix(url, "/v1/traces") {
+		url += "/v1/traces"
+	}
+	// backendHealth does the backing off, so the exporter does not retry
+	exporter, err := otlptracehttp.New(context.Background(),
+		otlptracehttp.WithEndpointURL(url),
+		otlptracehttp.WithHeaders(headers),
+		otlptracehttp.WithRetry(otlptracehttp.RetryConfig{Enabled: false}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	kvs := make([]attribute.KeyValue, 0, len(attrs))
+	for _, k := range sortedKeys(attrs) {
+		kvs = append(kvs, attribute.String(k, attrs[k]))
+	}
+	p := &spanPipeline{url: url, health: newBackendHealth("OTLP traces")}
+	p.provider = sdktrace.NewTracerProvider(
+		sdktrace.WithResource(resource.NewSchemaless(kvs...)),
+		sdktrace.WithBatcher(&healthExporter{SpanExporter: exporter, health: p.health},
+			sdktrace.WithMaxQueueSize(spanQueueSize),
+			sdktrace.WithMaxExportBatchSize(spanBatchSize),
+			sdktrace.WithBatchTimeout(spanExportInterval),
+		),
+	)
+	p.tracer = p.provider.Tracer(tracerName)
+	return p, nil
+}
+
+// shutdown exports queued spans and stops the exporter
+func (p *spanPipeline) shutdown(ctx context.Context) error {
+	return p.provider.Shutdown(ctx)
+}
+
+// healthExporter skips exports while the collector is unreachable, so
+// spans are dropped rather than each batch waiting out a timeout. Failures
+// are reported through backendHealth, which logs state changes once,
+// instead of to the SDK's error handler on every batch.
+type healthExporter struct {
+	sdktrace.SpanExporter
+	health *backendHealth
+}
+
+// ExportSpans implements sdktrace.SpanExporter
+func (e *healthExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	if len(spans) == 0 {
+		return nil
+	}
+	if !e.health.ready() {
+		e.health.dropped(len(spans))
+		return nil
+	}
+	e.health.report(e.SpanExporter.ExportSpans(ctx, spans), len(spans))
+	return nil
+}
+
+// metadataCarrier adapts gRPC metadata to a propagation.TextMapCarrier
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	if v := metadata.MD(c).Get(key); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// ============================================================
+// gRPC
+// ============================================================
+
+// TracingInterceptor records a span for each unary RPC, continuing the
+// caller's trace if it sent a traceparent header
+func (m *Manager) TracingInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if m.spans == nil {
+		return handler(ctx, req)
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		ctx = traceContext.Extract(ctx, metadataCarrier(md))
+	}
+	ctx, span := m.spans.tracer.Start(ctx, info.FullMethod,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attribute.String("rpc.system", "grpc"), attribute.String("rpc.method", info.FullMethod)),
+	)
+	resp, err := handler(ctx, req)
+	EndSpan(span, err)
+	return resp, err
+}
+
+// postJSON posts body to url, failing on a non-2xx status
+func postJSON(client *http.Client, url string, headers map[string]string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+package rpcserver
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pangea-net/go-orchestrator/pkg/cluster"
+	pb "github.com/pangea-net/go-orchestrator/pkg/orchestratorpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// clusterTokenHeader carries the secret orchestrators of a cluster
+	// share, on election and lease calls
+	clusterTokenHeader = "x-cluster-token"
+
+	// leaderHeader names the leader's address when a follower refuses a call
+	leaderHeader = "x-orchestrator-leader"
+
+	// forwardedHeader marks a JSON API call a follower forwarded, so it is
+	// never forwarded twice
+	forwardedHeader = "X-Orchestrator-Forwarded"
+)
+
+// clusterMethods are served by every orchestrator, leader or not
+var clusterMethods = map[string]bool{
+	pb.Orchestrator_RequestVote_FullMethodName:      true,
+	pb.Orchestrator_RenewLease_FullMethodName:       true,
+	pb.Orchestrator_GetClusterStatus_FullMethodName: true,
+}
+
+// SetCluster makes the service one orchestrator of a cluster. Only the
+// leader serves workers, jobs and training: followers refuse gRPC calls
+// with Unavailable, naming the leader, and forward JSON API calls to it.
+// Peers' election and lease calls must carry token.
+func (s *Server) SetCluster(node *cluster.Node, token string) {
+	s.cluster = node
+	s.clusterToken = token
+}
+
+// LeaderInterceptor refuses calls a follower cannot serve
+func (s *Server) LeaderInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if s.cluster == nil || clusterMethods[info.FullMethod] || s.cluster.IsLeader() {
+		return handler(ctx, req)
+	}
+	st := s.cluster.Status()
+	if st.LeaderAddr == "" {
+		return nil, status.Errorf(codes.Unavailable, "%v and no leader is elected yet", cluster.ErrNotLeader)
+	}
+	grpc.SetHeader(ctx, metadata.Pairs(leaderHeader, st.LeaderAddr))
+	return nil, status.Errorf(codes.Unavailable, "%v; leader is orchestrator %d at %s", cluster.ErrNotLeader, st.LeaderID, st.LeaderAddr)
+}
+
+// ============================================================
+// Cluster Methods
+// ============================================================
+
+func (s *Server) RequestVote(ctx context.Context, req *pb.VoteRequest) (*pb.VoteResponse, error) {
+	if err := s.checkPeer(ctx); err != nil {
+		return nil, err
+	}
+	resp := s.cluster.HandleVote(&cluster.VoteRequest{
+		Term:        req.GetTerm(),
+		CandidateID: req.GetCandidateId(),
+		PreVote:     req.GetPreVote(),
+		StateTerm:   req.GetStateTerm(),
+		Revision:    req.GetRevision(),
+	})
+	return &pb.VoteResponse{Term: resp.Term, Granted: resp.Granted}, nil
+}
+
+func (s *Server) RenewLease(ctx context.Context, req *pb.LeaseRequest) (*pb.LeaseResponse, error) {
+	if err := s.checkPeer(ctx); err != nil {
+		return nil, err
+	}
+	resp := s.cluster.HandleLease(&cluster.LeaseRequest{
+		Term:       req.GetTerm(),
+		LeaderID:   req.GetLeaderId(),
+		LeaderAddr: req.GetLeaderAddr(),
+		LeaderAPI:  req.GetLeaderApi(),
+		Lease:      time.Duration(req.GetLeaseMs()) * time.Millisecond,
+		Revision:   req.GetRevision(),
+		Snapshot:   req.GetSnapshot(),
+	})
+	return &pb.LeaseResponse{Term: resp.Term, Accepted: resp.Accepted}, nil
+}
+
+func (s *Server) GetClusterStatus(ctx context.Context, req *pb.GetClusterStatusRequest) (*pb.ClusterStatus, error) {
+	if s.cluster == nil {
+		return nil, status.Error(codes.FailedPrecondition, "clustering is not enabled")
+	}
+	st := s.cluster.Status()
+	return &pb.ClusterStatus{
+		Id:         st.ID,
+		Role:       st.Role.String(),
+		Term:       st.Term,
+		LeaderId:   st.LeaderID,
+		LeaderAddr: st.LeaderAddr,
+		LeaderApi:  st.LeaderAPI,
+		Members:    uint32(st.Members),
+	}, nil
+}
+
+// checkPeer verifies an election or lease call came from a cluster peer
+func (s *Server) checkPeer(ctx context.Context) error {
+	if s.cluster == nil {
+		return status.Error(codes.FailedPrecondition, "clustering is not enabled")
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	tokens := md.Get(clusterTokenHeader)
+	if s.clusterToken == "" || len(tokens) != 1 || subtle.ConstantTimeCompare([]byte(tokens[0]), []byte(s.clusterToken)) != 1 {
+		return status.Error(codes.PermissionDenied, "invalid cluster token")
+	}
+	return nil
+}
+
+// forwardToLeader relays a JSON API call to the leader's API, so go-nodes
+// can reach any orchestrator of the cluster
+func (s *Server) forwardToLeader(w http.ResponseWriter, r *http.Request) {
+	st := s.cluster.Status()
+	if st.LeaderAPI == "" || r.Header.Get(forwardedHeader) != "" {
+		writeJSONError(w, status.Errorf(codes.Unavailable, "%v and cannot reach one", cluster.ErrNotLeader))
+		return
+	}
+	target, err := url.Parse(st.LeaderAPI)
+	if err != nil {
+		writeJSONError(w, status.Errorf(codes.Unavailable, "invalid leader API %q", st.LeaderAPI))
+		return
+	}
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		writeJSONError(w, status.Errorf(codes.Unavailable, "leader %d unreachable: %v", st.LeaderID, err))
+	}
+	r.Header.Set(forwardedHeader, strconv.FormatUint(uint64(st.ID), 10))
+	r.Body = http.MaxBytesReader(w, r.Body, maxJSONBody)
+	proxy.ServeHTTP(w, r)
+}
+
+// GRPCTransport carries cluster messages over the orchestrator gRPC
+// service
+type GRPCTransport struct {
+	token string
+	mu    sync.Mutex
+	conns map[string]*grpc.ClientConn
+}
+
+// NewGRPCTransport creates a transport that authenticates with token
+func NewGRPCTransport(token string) *GRPCTransport {
+	return &GRPCTransport{token: token, conns: make(map[string]*grpc.ClientConn)}
+}
+
+func (t *GRPCTransport) RequestVote(ctx context.Context, addr string, req *cluster.VoteRequest) (*cluster.VoteResponse, error) {
+	client, err := t.client(addr)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.RequestVote(t.outgoing(ctx), &pb.VoteRequest{
+		Term:        req.Term,
+		CandidateId: req.CandidateID,
+		PreVote:     req.PreVote,
+		StateTerm:   req.StateTerm,
+		Revision:    req.Revision,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &cluster.VoteResponse{Term: resp.GetTerm(), Granted: resp.GetGranted()}, nil
+}
+
+func (t *GRPCTransport) RenewLease(ctx context.Context, addr string, req *cluster.LeaseRequest) (*cluster.LeaseResponse, error) {
+	client, err := t.client(addr)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.RenewLease(t.outgoing(ctx), &pb.LeaseRequest{
+		Term:       req.Term,
+		LeaderId:   req.LeaderID,
+		LeaderAddr: req.LeaderAddr,
+		LeaderApi:  req.LeaderAPI,
+		LeaseMs:    uint32(req.Lease.Milliseconds()),
+		Revision:   req.Revision,
+		Snapshot:   req.Snapshot,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &cluster.LeaseResponse{Term: resp.GetTerm(), Accepted: resp.GetAccepted()}, nil
+}
+
+// Close closes the connections to every peer
+func (t *GRPCTransport) Close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for addr, conn := range t.conns {
+		conn.Close()
+		delete(t.conns, addr)
+	}
+}
+
+func (t *GRPCTransport) outgoing(ctx context.Context) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, clusterTokenHeader, t.token)
+}
+
+func (t *GRPCTransport) client(addr string) (pb.OrchestratorClient, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	conn, ok := t.conns[addr]
+	if !ok {
+		var err error
+		conn, err = grpc.NewClient(addr,
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithDefaultCallOptions(grpc.MaxCallSendMsgSize(MaxMessageSize)))
+		if err != nil {
+			return nil, err
+		}
+		t.conns[addr] = conn
+	}
+	return pb.NewOrchestratorClient(conn), nil
+}
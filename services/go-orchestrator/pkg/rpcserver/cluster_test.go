@@ -0,0 +1,118 @@
+package rpcserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pangea-net/go-node/pkg/compute"
+	"github.com/pangea-net/go-orchestrator/pkg/cluster"
+	"github.com/pangea-net/go-orchestrator/pkg/gradient"
+	pb "github.com/pangea-net/go-orchestrator/pkg/orchestratorpb"
+	"github.com/pangea-net/go-orchestrator/pkg/workerpool"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestFollowerForwardsToLeader(t *testing.T) {
+	newServer := func(gradients *gradient.Manager) *Server {
+		manager := compute.NewManager(compute.DefaultConfig())
+		t.Cleanup(manager.Close)
+		return New(workerpool.NewPool(time.Minute, 1), manager, gradients, time.Second)
+	}
+
+	leaderGradients := gradient.NewManager()
+	leader := newServer(leaderGradients)
+	leaderAPI := httptest.NewServer(leader.JSONHandler("secret"))
+	defer leaderAPI.Close()
+	leaderNode, err := cluster.New(cluster.Config{ID: 1, Addr: "leader:50051", APIURL: leaderAPI.URL}, nil, leaderGradients)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leader.SetCluster(leaderNode, "cluster-secret")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go leaderNode.Run(ctx)
+
+	followerGradients := gradient.NewManager()
+	follower := newServer(followerGradients)
+	followerNode, err := cluster.New(cluster.Config{ID: 2, Peers: []cluster.Peer{{ID: 1, Addr: "leader:50051"}}}, nil, followerGradients)
+	if err != nil {
+		t.Fatal(err)
+	}
+	follower.SetCluster(followerNode, "cluster-secret")
+	followerAPI := httptest.NewServer(follower.JSONHandler("secret"))
+	defer followerAPI.Close()
+
+	post := func(srv *httptest.Server, method, body string) int {
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"/rpc/"+method, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := post(followerAPI, "GetTrainingStatus", `{"taskId": "fl"}`); code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 before a leader is known, got %d", code)
+	}
+
+	// The leader's lease carries its state and API address
+	for !leaderNode.IsLeader() {
+		time.Sleep(time.Millisecond)
+	}
+	if err := leaderGradients.StartTask(gradient.TrainingConfig{TaskID: "old", Nodes: []string{"1"}, Epochs: 1}); err != nil {
+		t.Fatal(err)
+	}
+	snapshot, _ := leaderGradients.Snapshot()
+	resp, err := follower.RenewLease(peerContext("cluster-secret"), &pb.LeaseRequest{
+		Term: 1, LeaderId: 1, LeaderAddr: "leader:50051", LeaderApi: leaderAPI.URL, LeaseMs: 60000, Snapshot: snapshot,
+	})
+	if err != nil || !resp.GetAccepted() {
+		t.Fatalf("lease refused: %v %v", resp, err)
+	}
+	if _, err := followerGradients.Status("old"); err != nil {
+		t.Errorf("expected the leader's task replicated: %v", err)
+	}
+	if _, err := follower.RenewLease(peerContext("wrong"), &pb.LeaseRequest{Term: 2, LeaderId: 3}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("expected a lease without the cluster token refused, got %v", err)
+	}
+
+	if code := post(followerAPI, "StartTraining", `{"taskId": "fl", "nodeIds": ["1"], "epochs": 1}`); code != http.StatusOK {
+		t.Fatalf("expected the follower to forward StartTraining, got %d", code)
+	}
+	if _, err := leaderGradients.Status("fl"); err != nil {
+		t.Errorf("expected the task started on the leader: %v", err)
+	}
+	if _, err := followerGradients.Status("fl"); err == nil {
+		t.Error("expected the follower to leave the task to the leader")
+	}
+
+	info := &grpc.UnaryServerInfo{FullMethod: pb.Orchestrator_SubmitJob_FullMethodName}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return "served", nil }
+	if _, err := follower.LeaderInterceptor(context.Background(), nil, info, handler); status.Code(err) != codes.Unavailable ||
+		!strings.Contains(err.Error(), "leader:50051") {
+		t.Errorf("expected the follower to refuse SubmitJob naming the leader, got %v", err)
+	}
+	if out, err := leader.LeaderInterceptor(context.Background(), nil, info, handler); err != nil || out != "served" {
+		t.Errorf("expected the leader to serve SubmitJob, got %v %v", out, err)
+	}
+	info.FullMethod = pb.Orchestrator_GetClusterStatus_FullMethodName
+	if _, err := follower.LeaderInterceptor(context.Background(), nil, info, handler); err != nil {
+		t.Errorf("expected cluster methods served by followers, got %v", err)
+	}
+	st, err := follower.GetClusterStatus(context.Background(), &pb.GetClusterStatusRequest{})
+	if err != nil || st.GetRole() != "follower" || st.GetLeaderId() != 1 || st.GetMembers() != 2 {
+		t.Errorf("unexpected cluster status %v %v", st, err)
+	}
+}
+
+func peerContext(token string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs(clusterTokenHeader, token))
+}
@@ -0,0 +1,124 @@
+package rpcserver
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	pb "github.com/pangea-net/go-orchestrator/pkg/orchestratorpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// maxJSONBody bounds a JSON request; gradients of large models are big
+const maxJSONBody = 64 << 20
+
+// jsonMethod decodes a protojson request and calls a service method
+type jsonMethod func(ctx context.Context, body []byte) (proto.Message, error)
+
+func unary[Req, Resp proto.Message](newReq func() Req, call func(context.Context, Req) (Resp, error)) jsonMethod {
+	return func(ctx context.Context, body []byte) (proto.Message, error) {
+		req := newReq()
+		if err := protojson.Unmarshal(body, req); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		return call(ctx, req)
+	}
+}
+
+// JSONHandler serves the worker and federated training methods as JSON
+// over HTTP, for go-nodes that do not link a gRPC stack. A request is POST /rpc/<Method>
+// with the request message in protojson and an "Authorization: Bearer
+// <token>" header; the reply is the response message, or {"code": ...,
+// "message": ...} with a matching HTTP status. An empty token refuses
+// every request. A follower of an orchestrator cluster forwards requests
+// to the leader.
+func (s *Server) JSONHandler(token string) http.Handler {
+	methods := map[string]jsonMethod{
+		"RegisterWorker":    unary(func() *pb.RegisterWorkerRequest { return &pb.RegisterWorkerRequest{} }, s.RegisterWorker),
+		"Heartbeat":         unary(func() *pb.HeartbeatRequest { return &pb.HeartbeatRequest{} }, s.Heartbeat),
+		"GetTask":           unary(func() *pb.GetTaskRequest { return &pb.GetTaskRequest{} }, s.GetTask),
+		"SubmitResult":      unary(func() *pb.SubmitResultRequest { return &pb.SubmitResultRequest{} }, s.SubmitResult),
+		"DeregisterWorker":  unary(func() *pb.DeregisterWorkerRequest { return &pb.DeregisterWorkerRequest{} }, s.DeregisterWorker),
+		"StartTraining":     unary(func() *pb.StartTrainingRequest { return &pb.StartTrainingRequest{} }, s.StartTraining),
+		"SubmitGradient":    unary(func() *pb.SubmitGradientRequest { return &pb.SubmitGradientRequest{} }, s.SubmitGradient),
+		"GetGlobalModel":    unary(func() *pb.GetGlobalModelRequest { return &pb.GetGlobalModelRequest{} }, s.GetGlobalModel),
+		"GetTrainingStatus": unary(func() *pb.GetTrainingStatusRequest { return &pb.GetTrainingStatusRequest{} }, s.GetTrainingStatus),
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !validBearer(r, token) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeJSONError(w, status.Error(codes.Unauthenticated, "missing or invalid bearer token"))
+			return
+		}
+		if s.cluster != nil && !s.cluster.IsLeader() {
+			s.forwardToLeader(w, r)
+			return
+		}
+		method, ok := methods[strings.TrimPrefix(r.URL.Path, "/rpc/")]
+		if !ok {
+			writeJSONError(w, status.Error(codes.Unimplemented, "unknown method "+r.URL.Path))
+			return
+		}
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeJSONError(w, status.Error(codes.InvalidArgument, "use POST"))
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxJSONBody))
+		if err != nil {
+			writeJSONError(w, status.Error(codes.InvalidArgument, err.Error()))
+			return
+		}
+		resp, err := method(r.Context(), body)
+		if err != nil {
+			writeJSONError(w, err)
+			return
+		}
+		out, err := protojson.Marshal(resp)
+		if err != nil {
+			writeJSONError(w, status.Error(codes.Internal, err.Error()))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(out)
+	})
+}
+
+// writeJSONError writes a gRPC status error with the matching HTTP status
+func writeJSONError(w http.ResponseWriter, err error) {
+	st := status.Convert(err)
+	code := http.StatusInternalServerError
+	switch st.Code() {
+	case codes.InvalidArgument:
+		code = http.StatusBadRequest
+	case codes.NotFound:
+		code = http.StatusNotFound
+	case codes.FailedPrecondition, codes.AlreadyExists:
+		code = http.StatusConflict
+	case codes.Unimplemented:
+		code = http.StatusNotFound
+	case codes.Unauthenticated:
+		code = http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		code = http.StatusTooManyRequests
+	case codes.Unavailable:
+		code = http.StatusServiceUnavailable
+	case codes.DeadlineExceeded, codes.Canceled:
+		code = http.StatusGatewayTimeout
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"code": st.Code().String(), "message": st.Message()})
+}
+
+// validBearer reports whether the request carries the bearer token
+func validBearer(r *http.Request, token string) bool {
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && token != "" && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
@@ -0,0 +1,138 @@
+package rpcserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pangea-net/go-node/pkg/compute"
+	"github.com/pangea-net/go-orchestrator/pkg/gradient"
+	pb "github.com/pangea-net/go-orchestrator/pkg/orchestratorpb"
+	"github.com/pangea-net/go-orchestrator/pkg/workerpool"
+)
+
+func TestJSONHandlerServesFederatedTraining(t *testing.T) {
+	manager := compute.NewManager(compute.DefaultConfig())
+	defer manager.Close()
+	s := New(workerpool.NewPool(time.Minute, 1), manager, gradient.NewManager(), time.Second)
+	srv := httptest.NewServer(s.JSONHandler("secret"))
+	defer srv.Close()
+
+	token := "secret"
+	call := func(method, body string) (int, map[string]any) {
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"/rpc/"+method, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var out map[string]any
+		json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out
+	}
+
+	token = "wrong"
+	if code, out := call("StartTraining", `{"taskId": "fl", "nodeIds": ["1", "2"], "epochs": 1}`); code != http.StatusUnauthorized || out["code"] != "Unauthenticated" {
+		t.Fatalf("expected a wrong token refused, got %d %v", code, out)
+	}
+	token = "secret"
+	if code, _ := call("StartTraining", `{"taskId": "fl", "nodeIds": ["1", "2"], "epochs": 1}`); code != http.StatusOK {
+		t.Fatalf("StartTraining failed with %d", code)
+	}
+	if code, out := call("StartTraining", `{"taskId": "fl", "nodeIds": ["1"], "epochs": 1}`); code != http.StatusConflict || out["code"] != "AlreadyExists" {
+		t.Fatalf("expected a second start refused with 409, got %d %v", code, out)
+	}
+	if code, out := call("SubmitGradient", `{"task_id": "fl", "node_id": "1", "gradients": [1, 2], "numSamples": 1}`); code != http.StatusOK || out["aggregated"] != nil {
+		t.Fatalf("SubmitGradient failed with %d %v", code, out)
+	}
+	if code, out := call("SubmitGradient", `{"taskId": "fl", "nodeId": "1", "modelVersion": 3, "gradients": [1, 2], "numSamples": 1}`); code != http.StatusConflict || out["code"] != "FailedPrecondition" {
+		t.Fatalf("expected a stale gradient refused with 409, got %d %v", code, out)
+	}
+	if code, out := call("SubmitGradient", `{"taskId": "fl", "nodeId": "2", "gradients": [3, 4], "numSamples": 1}`); code != http.StatusOK || out["aggregated"] != true || out["modelVersion"] != 1.0 {
+		t.Fatalf("expected the epoch aggregated, got %d %v", code, out)
+	}
+	code, out := call("GetGlobalModel", `{"taskId": "fl", "afterVersion": 0}`)
+	if code != http.StatusOK || out["version"] != 1.0 || out["final"] != true {
+		t.Fatalf("unexpected model %d %v", code, out)
+	}
+	if params := out["parameters"].([]any); len(params) != 2 || params[0] != -0.02 {
+		t.Fatalf("unexpected parameters %v", params)
+	}
+
+	if code, _ := call("GetTrainingStatus", `{"taskId": "missing"}`); code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown task, got %d", code)
+	}
+	if code, _ := call("SubmitJob", `{}`); code != http.StatusNotFound {
+		t.Fatalf("expected methods outside federated training unavailable, got %d", code)
+	}
+	if code, _ := call("StartTraining", `{"taskId": 7}`); code != http.StatusBadRequest {
+		t.Fatalf("expected a malformed request refused, got %d", code)
+	}
+}
+
+func TestJSONHandlerServesWorkers(t *testing.T) {
+	manager := compute.NewManager(compute.DefaultConfig())
+	defer manager.Close()
+	pool := workerpool.NewPool(time.Minute, 1)
+	s := New(pool, manager, gradient.NewManager(), time.Second)
+	srv := httptest.NewServer(s.JSONHandler("secret"))
+	defer srv.Close()
+
+	call := func(method, body string) (int, map[string]any) {
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"/rpc/"+method, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var out map[string]any
+		json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out
+	}
+
+	code, out := call("RegisterWorker", `{"workerId": "node-1", "capacity": {"cpuCores": 4, "ramMb": 2048}, "maxTasks": 1}`)
+	if code != http.StatusOK || out["workerId"] != "node-1" || out["maxTasks"] != 1.0 {
+		t.Fatalf("RegisterWorker failed with %d %v", code, out)
+	}
+	if code, out := call("RegisterWorker", `{"workerId": "node-2"}`); code != http.StatusTooManyRequests || out["code"] != "ResourceExhausted" {
+		t.Fatalf("expected the worker limit enforced, got %d %v", code, out)
+	}
+
+	done := make(chan *compute.TaskResult, 1)
+	go func() {
+		result, _ := pool.DelegateTask(context.Background(), "node-1", &compute.ComputeTask{TaskID: "job:0", InputData: []byte("in")})
+		done <- result
+	}()
+	code, out = call("GetTask", `{"workerId": "node-1", "waitMs": 1000}`)
+	task, _ := out["task"].(map[string]any)
+	if code != http.StatusOK || task["taskId"] != "job:0" || task["inputData"] != "aW4=" {
+		t.Fatalf("GetTask failed with %d %v", code, out)
+	}
+	if code, _ := call("GetTask", `{"workerId": "node-1"}`); code != http.StatusTooManyRequests {
+		t.Fatalf("expected a second task refused while the slot is taken, got %d", code)
+	}
+	code, out = call("SubmitResult", `{"workerId": "node-1", "result": {"taskId": "job:0", "status": "TASK_STATUS_COMPLETED", "resultData": "b3V0"}}`)
+	if code != http.StatusOK || out["accepted"] != true {
+		t.Fatalf("SubmitResult failed with %d %v", code, out)
+	}
+	if result := <-done; string(result.ResultData) != "out" || result.Status != compute.TaskCompleted {
+		t.Fatalf("unexpected result %+v", result)
+	}
+
+	list, _ := s.ListWorkers(context.Background(), &pb.ListWorkersRequest{})
+	if len(list.Workers) != 1 || list.Workers[0].CompletedTasks != 1 || list.Workers[0].RunningTasks != 0 {
+		t.Fatalf("unexpected accounting %v", list.Workers)
+	}
+	if code, out := call("DeregisterWorker", `{"workerId": "node-1"}`); code != http.StatusOK || out["deregistered"] != true {
+		t.Fatalf("DeregisterWorker failed with %d %v", code, out)
+	}
+	if code, _ := call("RegisterWorker", `{"workerId": "node-2"}`); code != http.StatusOK {
+		t.Fatalf("expected a place free after the worker left, got %d", code)
+	}
+}
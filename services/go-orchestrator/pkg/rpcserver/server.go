@@ -0,0 +1,340 @@
+// Package rpcserver implements the orchestrator's gRPC service
+package rpcserver
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/pangea-net/go-node/pkg/compute"
+	"github.com/pangea-net/go-orchestrator/pkg/cluster"
+	"github.com/pangea-net/go-orchestrator/pkg/gradient"
+	"github.com/pangea-net/go-orchestrator/pkg/metrics"
+	pb "github.com/pangea-net/go-orchestrator/pkg/orchestratorpb"
+	"github.com/pangea-net/go-orchestrator/pkg/workerpool"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// maxTaskWait bounds a GetTask long-poll
+	maxTaskWait = 30 * time.Second
+
+	// maxResultWait bounds a GetJobResult wait
+	maxResultWait = 10 * time.Minute
+
+	// maxModelWait bounds a GetGlobalModel long-poll
+	maxModelWait = 30 * time.Second
+
+	// MaxMessageSize bounds a gRPC message; replicated training state
+	// carries whole models
+	MaxMessageSize = 64 << 20
+)
+
+// Server serves worker registration, task assignment, heartbeats and result
+// submission for a worker pool, and job submission for clients. Jobs run on
+// a compute.Manager that delegates their chunks to the pool. Gradients from
+// go-nodes training together are aggregated by a gradient.Manager.
+type Server struct {
+	pb.UnimplementedOrchestratorServer
+
+	pool              *workerpool.Pool
+	manager           *compute.Manager
+	gradients         *gradient.Manager
+	heartbeatInterval time.Duration
+	cluster           *cluster.Node // nil = a lone orchestrator
+	clusterToken      string
+}
+
+// New creates the service and makes the pool the manager's delegator
+func New(pool *workerpool.Pool, manager *compute.Manager, gradients *gradient.Manager, heartbeatInterval time.Duration) *Server {
+	manager.SetDelegator(pool)
+	return &Server{pool: pool, manager: manager, gradients: gradients, heartbeatInterval: heartbeatInterval}
+}
+
+// Register adds the service to a gRPC server
+func (s *Server) Register(g *grpc.Server) {
+	pb.RegisterOrchestratorServer(g, s)
+}
+
+// MetricsInterceptor records request counts and durations per method
+func MetricsInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+	}
+	metrics.RPCRequestsTotal.WithLabelValues(info.FullMethod, outcome).Inc()
+	metrics.RPCRequestDuration.WithLabelValues(info.FullMethod).Observe(time.Since(start).Seconds())
+	return resp, err
+}
+
+// ============================================================
+// Worker Methods
+// ============================================================
+
+func (s *Server) RegisterWorker(ctx context.Context, req *pb.RegisterWorkerRequest) (*pb.RegisterWorkerResponse, error) {
+	c := req.GetCapacity()
+	capacity := compute.ComputeCapacity{
+		CPUCores:      c.GetCpuCores(),
+		RAMMB:         c.GetRamMb(),
+		CurrentLoad:   c.GetCurrentLoad(),
+		DiskMB:        c.GetDiskMb(),
+		BandwidthMbps: c.GetBandwidthMbps(),
+	}
+	id, maxTasks, err := s.pool.Register(req.GetWorkerId(), capacity, req.GetMaxTasks())
+	if err != nil {
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	}
+	s.manager.RegisterWorker(id, capacity)
+	return &pb.RegisterWorkerResponse{
+		WorkerId:            id,
+		HeartbeatIntervalMs: uint32(s.heartbeatInterval.Milliseconds()),
+		MaxTasks:            maxTasks,
+	}, nil
+}
+
+func (s *Server) Heartbeat(ctx context.Context, req *pb.HeartbeatRequest) (*pb.HeartbeatResponse, error) {
+	return &pb.HeartbeatResponse{Registered: s.pool.Heartbeat(req.GetWorkerId(), req.GetCurrentLoad())}, nil
+}
+
+func (s *Server) GetTask(ctx context.Context, req *pb.GetTaskRequest) (*pb.GetTaskResponse, error) {
+	wait := min(time.Duration(req.GetWaitMs())*time.Millisecond, maxTaskWait)
+	task, err := s.pool.NextTask(ctx, req.GetWorkerId(), wait)
+	if err != nil {
+		return nil, poolError(err)
+	}
+	if task == nil {
+		return &pb.GetTaskResponse{}, nil
+	}
+	return &pb.GetTaskResponse{Task: &pb.Task{
+		TaskId:       task.TaskID,
+		ParentJobId:  task.ParentJobID,
+		ChunkIndex:   task.ChunkIndex,
+		WasmModule:   task.WASMModule,
+		InputData:    task.InputData,
+		FunctionName: task.FunctionName,
+		TimeoutMs:    task.TimeoutMs,
+	}}, nil
+}
+
+func (s *Server) SubmitResult(ctx context.Context, req *pb.SubmitResultRequest) (*pb.SubmitResultResponse, error) {
+	r := req.GetResult()
+	if r.GetTaskId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "result.task_id is required")
+	}
+	accepted := s.pool.Submit(req.GetWorkerId(), &compute.TaskResult{
+		TaskID:          r.GetTaskId(),
+		Status:          compute.TaskStatus(r.GetStatus()),
+		ResultData:      r.GetResultData(),
+		ResultHash:      r.GetResultHash(),
+		ExecutionTimeMs: r.GetExecutionTimeMs(),
+		Error:           r.GetError(),
+	})
+	return &pb.SubmitResultResponse{Accepted: accepted}, nil
+}
+
+func (s *Server) DeregisterWorker(ctx context.Context, req *pb.DeregisterWorkerRequest) (*pb.DeregisterWorkerResponse, error) {
+	return &pb.DeregisterWorkerResponse{Deregistered: s.pool.Deregister(req.GetWorkerId())}, nil
+}
+
+func (s *Server) ListWorkers(ctx context.Context, req *pb.ListWorkersRequest) (*pb.ListWorkersResponse, error) {
+	workers := s.pool.Workers()
+	resp := &pb.ListWorkersResponse{Workers: make([]*pb.WorkerInfo, len(workers))}
+	for i, w := range workers {
+		resp.Workers[i] = &pb.WorkerInfo{
+			WorkerId: w.ID,
+			Capacity: &pb.Capacity{
+				CpuCores:      w.Capacity.CPUCores,
+				RamMb:         w.Capacity.RAMMB,
+				CurrentLoad:   w.Capacity.CurrentLoad,
+				DiskMb:        w.Capacity.DiskMB,
+				BandwidthMbps: w.Capacity.BandwidthMbps,
+			},
+			MaxTasks:       w.MaxTasks,
+			QueuedTasks:    w.Queued,
+			RunningTasks:   w.Running,
+			CompletedTasks: w.Completed,
+			FailedTasks:    w.Failed,
+			RegisteredAt:   w.Registered.UnixMilli(),
+			LastSeen:       w.LastSeen.UnixMilli(),
+		}
+	}
+	return resp, nil
+}
+
+// ============================================================
+// Client Methods
+// ============================================================
+
+func (s *Server) SubmitJob(ctx context.Context, req *pb.SubmitJobRequest) (*pb.SubmitJobResponse, error) {
+	m := req.GetManifest()
+	if m == nil {
+		return nil, status.Error(codes.InvalidArgument, "manifest is required")
+	}
+	mode, err := compute.ParseVerificationMode(m.GetVerificationMode())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	manifest := &compute.JobManifest{
+		JobID:            m.GetJobId(),
+		WASMModule:       m.GetWasmModule(),
+		InputData:        m.GetInputData(),
+		SplitStrategy:    m.GetSplitStrategy(),
+		Reducer:          m.GetReducer(),
+		DependsOn:        m.GetDependsOn(),
+		PostProcess:      m.GetPostProcess(),
+		MinChunkSize:     m.GetMinChunkSize(),
+		MaxChunkSize:     m.GetMaxChunkSize(),
+		VerificationMode: mode,
+		TimeoutSecs:      m.GetTimeoutSecs(),
+		RetryCount:       m.GetRetryCount(),
+		Priority:         m.GetPriority(),
+		Redundancy:       m.GetRedundancy(),
+	}
+	if m.GetStartAtUnix() > 0 {
+		manifest.StartAt = time.Unix(m.GetStartAtUnix(), 0)
+	}
+
+	jobID, err := s.manager.SubmitJob(manifest)
+	if err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return &pb.SubmitJobResponse{JobId: jobID}, nil
+}
+
+func (s *Server) GetJobStatus(ctx context.Context, req *pb.GetJobStatusRequest) (*pb.JobStatus, error) {
+	st, err := s.manager.GetJobStatus(req.GetJobId())
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	return &pb.JobStatus{
+		JobId:                  st.JobID,
+		Status:                 pb.TaskStatus(st.Status),
+		Progress:               st.Progress,
+		CompletedChunks:        st.CompletedChunks,
+		TotalChunks:            st.TotalChunks,
+		EstimatedTimeRemaining: st.EstimatedTimeRemaining,
+		Error:                  st.Error,
+	}, nil
+}
+
+func (s *Server) GetJobResult(ctx context.Context, req *pb.GetJobResultRequest) (*pb.GetJobResultResponse, error) {
+	if _, err := s.manager.GetJobStatus(req.GetJobId()); err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	timeout := min(time.Duration(req.GetTimeoutMs())*time.Millisecond, maxResultWait)
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = min(timeout, time.Until(deadline))
+	}
+	result, worker, err := s.manager.GetJobResultWithWorker(req.GetJobId(), timeout)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	return &pb.GetJobResultResponse{Result: result, WorkerId: worker}, nil
+}
+
+// ============================================================
+// Federated Training Methods
+// ============================================================
+
+func (s *Server) StartTraining(ctx context.Context, req *pb.StartTrainingRequest) (*pb.StartTrainingResponse, error) {
+	err := s.gradients.StartTask(gradient.TrainingConfig{
+		TaskID:            req.GetTaskId(),
+		Nodes:             req.GetNodeIds(),
+		Epochs:            req.GetEpochs(),
+		MinNodes:          int(req.GetMinNodes()),
+		RoundTimeout:      time.Duration(req.GetRoundTimeoutMs()) * time.Millisecond,
+		LearningRate:      req.GetLearningRate(),
+		InitialParameters: req.GetInitialParameters(),
+	})
+	if err != nil {
+		return nil, gradientError(err)
+	}
+	return &pb.StartTrainingResponse{TaskId: req.GetTaskId()}, nil
+}
+
+func (s *Server) SubmitGradient(ctx context.Context, req *pb.SubmitGradientRequest) (*pb.SubmitGradientResponse, error) {
+	aggregated, err := s.gradients.SubmitGradient(&gradient.GradientUpdate{
+		TaskID:       req.GetTaskId(),
+		NodeID:       req.GetNodeId(),
+		ModelVersion: req.GetModelVersion(),
+		Data:         req.GetGradients(),
+		NumSamples:   req.GetNumSamples(),
+		Loss:         req.GetLoss(),
+		Accuracy:     req.GetAccuracy(),
+	})
+	if err != nil {
+		return nil, gradientError(err)
+	}
+	model, err := s.gradients.GlobalModel(req.GetTaskId())
+	if err != nil {
+		return nil, gradientError(err)
+	}
+	return &pb.SubmitGradientResponse{Aggregated: aggregated, ModelVersion: model.Version}, nil
+}
+
+func (s *Server) GetGlobalModel(ctx context.Context, req *pb.GetGlobalModelRequest) (*pb.GlobalModel, error) {
+	wait := min(time.Duration(req.GetWaitMs())*time.Millisecond, maxModelWait)
+	ctx, cancel := context.WithTimeout(ctx, wait)
+	defer cancel()
+	model, err := s.gradients.WaitGlobalModel(ctx, req.GetTaskId(), req.GetAfterVersion())
+	if err != nil {
+		return nil, gradientError(err)
+	}
+	return &pb.GlobalModel{
+		TaskId:     model.TaskID,
+		Version:    model.Version,
+		Parameters: model.Parameters,
+		NumNodes:   model.NumNodes,
+		Loss:       model.Loss,
+		Accuracy:   model.Accuracy,
+		Final:      model.Final,
+	}, nil
+}
+
+func (s *Server) GetTrainingStatus(ctx context.Context, req *pb.GetTrainingStatusRequest) (*pb.TrainingStatus, error) {
+	st, err := s.gradients.Status(req.GetTaskId())
+	if err != nil {
+		return nil, gradientError(err)
+	}
+	return &pb.TrainingStatus{
+		TaskId:    st.TaskID,
+		Status:    st.Status,
+		Epoch:     st.Epoch,
+		Epochs:    st.Epochs,
+		NodeIds:   st.Nodes,
+		Submitted: st.Submitted,
+		Loss:      st.Loss,
+	}, nil
+}
+
+// gradientError maps gradient manager errors to gRPC status codes
+func gradientError(err error) error {
+	switch {
+	case errors.Is(err, gradient.ErrTaskNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, gradient.ErrStaleGradient), errors.Is(err, gradient.ErrTaskNotRunning):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, gradient.ErrTaskExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	default:
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+}
+
+// poolError maps worker pool errors to gRPC status codes
+func poolError(err error) error {
+	switch {
+	case errors.Is(err, workerpool.ErrUnknownWorker):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, workerpool.ErrNoFreeSlot):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return status.FromContextError(err).Err()
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
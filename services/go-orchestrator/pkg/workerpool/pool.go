@@ -0,0 +1,390 @@
+// Package workerpool tracks compute workers that pull tasks from the
+// orchestrator over RPC
+package workerpool
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pangea-net/go-node/pkg/compute"
+)
+
+// queueSize bounds the tasks waiting for one worker to pull them
+const queueSize = 64
+
+var (
+	// ErrUnknownWorker is returned for a worker that never registered or
+	// was dropped for missing heartbeats
+	ErrUnknownWorker = errors.New("unknown worker")
+
+	// ErrWorkerGone is returned to a delegated task whose worker was
+	// dropped before it submitted a result
+	ErrWorkerGone = errors.New("worker dropped before returning a result")
+
+	// ErrWorkerBusy is returned when a worker's queue is full
+	ErrWorkerBusy = errors.New("worker task queue is full")
+
+	// ErrPoolFull is returned when registering past the worker limit
+	ErrPoolFull = errors.New("worker limit reached")
+
+	// ErrNoFreeSlot is returned to a worker pulling a task while it already
+	// runs as many as it registered for
+	ErrNoFreeSlot = errors.New("worker has no free task slot")
+)
+
+// Pool is the set of registered workers. It implements
+// compute.TaskDelegator: the manager delegates a chunk to a worker, the
+// worker pulls it with NextTask and answers with Submit. A worker runs at
+// most the tasks it registered for at once; chunks delegated past that
+// wait in its queue, and the least occupied workers are offered first.
+type Pool struct {
+	ttl     time.Duration
+	max     int // 0 = unlimited
+	workers map[string]*worker
+	onCount func(int)
+	mu      sync.Mutex
+}
+
+type worker struct {
+	id         string
+	capacity   compute.ComputeCapacity
+	maxTasks   uint32
+	registered time.Time
+	lastSeen   time.Time
+	queue      chan *assignment
+	pending    map[string]*assignment // Task ID -> delegated, awaiting result
+	running    uint32                 // pending tasks the worker has pulled
+	completed  uint64
+	failed     uint64
+	gone       chan struct{}
+}
+
+// assignment is one task delegated to one worker
+type assignment struct {
+	task   *compute.ComputeTask
+	result chan *compute.TaskResult
+	pulled bool
+}
+
+// WorkerInfo is a worker's registration and task accounting
+type WorkerInfo struct {
+	ID         string
+	Capacity   compute.ComputeCapacity
+	MaxTasks   uint32 // Tasks it runs at once
+	Queued     uint32 // Delegated, not yet pulled
+	Running    uint32 // Pulled, result not yet submitted
+	Completed  uint64
+	Failed     uint64
+	Registered time.Time
+	LastSeen   time.Time
+}
+
+// slots returns the tasks a worker runs at once: what it asked for, or
+// one per core
+func slots(capacity compute.ComputeCapacity, maxTasks uint32) uint32 {
+	if maxTasks == 0 {
+		maxTasks = capacity.CPUCores
+	}
+	return max(maxTasks, 1)
+}
+
+// NewPool creates a pool of at most max workers (0 = unlimited) that drops
+// workers silent for longer than ttl
+func NewPool(ttl time.Duration, max int) *Pool {
+	return &Pool{ttl: ttl, max: max, workers: make(map[string]*worker)}
+}
+
+// SetCountCallback sets a function called with the worker count whenever
+// a worker joins or is dropped
+func (p *Pool) SetCountCallback(fn func(int)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.onCount = fn
+}
+
+// Register adds a worker that runs up to maxTasks tasks at once (0 = one
+// per core), or refreshes one that registers again. An empty ID is
+// replaced by a generated one. Returns the worker's ID and task slots.
+func (p *Pool) Register(id string, capacity compute.ComputeCapacity, maxTasks uint32) (string, uint32, error) {
+	if id == "" {
+		b := make([]byte, 8)
+		rand.Read(b)
+		id = "worker-" + hex.EncodeToString(b)
+	}
+	maxTasks = slots(capacity, maxTasks)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if w, ok := p.workers[id]; ok {
+		w.capacity = capacity
+		w.maxTasks = maxTasks
+		w.lastSeen = time.Now()
+		return id, maxTasks, nil
+	}
+	if p.max > 0 && len(p.workers) >= p.max {
+		return "", 0, ErrPoolFull
+	}
+	now := time.Now()
+	p.workers[id] = &worker{
+		id:         id,
+		capacity:   capacity,
+		maxTasks:   maxTasks,
+		registered: now,
+		lastSeen:   now,
+		queue:      make(chan *assignment, queueSize),
+		pending:    make(map[string]*assignment),
+		gone:       make(chan struct{}),
+	}
+	log.Printf("🤝 Worker %s registered (%d cores, %d MB RAM, %d task slots)", id, capacity.CPUCores, capacity.RAMMB, maxTasks)
+	p.countChangedLocked()
+	return id, maxTasks, nil
+}
+
+// Deregister removes a worker that is leaving, freeing its place under the
+// worker limit. Tasks delegated to it fail so the manager can retry them.
+// Returns false if the worker is not registered.
+func (p *Pool) Deregister(id string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	w, ok := p.workers[id]
+	if !ok {
+		return false
+	}
+	p.removeLocked(w)
+	log.Printf("👋 Worker %s left (%d tasks outstanding, %d completed, %d failed)", id, len(w.pending), w.completed, w.failed)
+	p.countChangedLocked()
+	return true
+}
+
+// Heartbeat records that a worker is alive. Returns false if the worker is
+// not registered.
+func (p *Pool) Heartbeat(id string, load float32) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	w, ok := p.workers[id]
+	if !ok {
+		return false
+	}
+	w.lastSeen = time.Now()
+	w.capacity.CurrentLoad = load
+	return true
+}
+
+// NextTask returns the next task delegated to a worker, waiting up to wait
+// for one. Returns nil if none arrived in time, and ErrNoFreeSlot if the
+// worker already runs as many tasks as it registered for.
+func (p *Pool) NextTask(ctx context.Context, id string, wait time.Duration) (*compute.ComputeTask, error) {
+	p.mu.Lock()
+	w, ok := p.workers[id]
+	if ok {
+		w.lastSeen = time.Now()
+	}
+	full := ok && w.running >= w.maxTasks
+	p.mu.Unlock()
+	if !ok {
+		return nil, ErrUnknownWorker
+	}
+	if full {
+		return nil, ErrNoFreeSlot
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for {
+		select {
+		case a := <-w.queue:
+			// Skip tasks the manager stopped waiting for
+			p.mu.Lock()
+			live := w.pending[a.task.TaskID] == a
+			if live {
+				a.pulled = true
+				w.running++
+			}
+			p.mu.Unlock()
+			if live {
+				return a.task, nil
+			}
+		case <-timer.C:
+			return nil, nil
+		case <-w.gone:
+			return nil, ErrUnknownWorker
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Submit delivers a worker's result for a task delegated to it. Returns
+// false if the task was not waiting on this worker.
+func (p *Pool) Submit(id string, result *compute.TaskResult) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	w, ok := p.workers[id]
+	if !ok {
+		return false
+	}
+	w.lastSeen = time.Now()
+	a, ok := w.pending[result.TaskID]
+	if !ok {
+		return false
+	}
+	w.dropLocked(a)
+	if result.Status == compute.TaskCompleted {
+		w.completed++
+	} else {
+		w.failed++
+	}
+	result.WorkerID = id
+	a.result <- result
+	return true
+}
+
+// DelegateTask queues a task for a worker and waits for its result
+func (p *Pool) DelegateTask(ctx context.Context, workerID string, task *compute.ComputeTask) (*compute.TaskResult, error) {
+	a := &assignment{task: task, result: make(chan *compute.TaskResult, 1)}
+
+	p.mu.Lock()
+	w, ok := p.workers[workerID]
+	if !ok {
+		p.mu.Unlock()
+		return nil, ErrUnknownWorker
+	}
+	select {
+	case w.queue <- a:
+		w.pending[task.TaskID] = a
+	default:
+		p.mu.Unlock()
+		return nil, ErrWorkerBusy
+	}
+	p.mu.Unlock()
+
+	select {
+	case result := <-a.result:
+		return result, nil
+	case <-w.gone:
+		return nil, ErrWorkerGone
+	case <-ctx.Done():
+		p.mu.Lock()
+		if w.pending[task.TaskID] == a {
+			w.dropLocked(a)
+		}
+		p.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+// dropLocked forgets a pending assignment, freeing its slot if pulled
+func (w *worker) dropLocked(a *assignment) {
+	delete(w.pending, a.task.TaskID)
+	if a.pulled {
+		w.running--
+	}
+}
+
+// occupancy is the share of a worker's slots its outstanding tasks fill
+func (w *worker) occupancy() float64 {
+	return float64(len(w.pending)) / float64(w.maxTasks)
+}
+
+// GetAvailableWorkers returns the registered worker IDs, those with the
+// most free slots for their size first, then the least loaded
+func (p *Pool) GetAvailableWorkers() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	workers := make([]*worker, 0, len(p.workers))
+	for _, w := range p.workers {
+		workers = append(workers, w)
+	}
+	sort.Slice(workers, func(i, j int) bool {
+		if oi, oj := workers[i].occupancy(), workers[j].occupancy(); oi != oj {
+			return oi < oj
+		}
+		if workers[i].capacity.CurrentLoad != workers[j].capacity.CurrentLoad {
+			return workers[i].capacity.CurrentLoad < workers[j].capacity.CurrentLoad
+		}
+		return workers[i].id < workers[j].id
+	})
+	ids := make([]string, len(workers))
+	for i, w := range workers {
+		ids[i] = w.id
+	}
+	return ids
+}
+
+// Workers returns every worker's accounting, by ID
+func (p *Pool) Workers() []WorkerInfo {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	infos := make([]WorkerInfo, 0, len(p.workers))
+	for _, w := range p.workers {
+		infos = append(infos, WorkerInfo{
+			ID:         w.id,
+			Capacity:   w.capacity,
+			MaxTasks:   w.maxTasks,
+			Queued:     uint32(len(w.pending)) - w.running,
+			Running:    w.running,
+			Completed:  w.completed,
+			Failed:     w.failed,
+			Registered: w.registered,
+			LastSeen:   w.lastSeen,
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ID < infos[j].ID })
+	return infos
+}
+
+// HasWorkers reports whether any worker is registered
+func (p *Pool) HasWorkers() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.workers) > 0
+}
+
+// Run drops workers that stop sending heartbeats until ctx ends. Tasks
+// delegated to a dropped worker fail so the manager can retry them.
+func (p *Pool) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			p.expire(now)
+		}
+	}
+}
+
+func (p *Pool) expire(now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	dropped := false
+	for id, w := range p.workers {
+		if now.Sub(w.lastSeen) > p.ttl {
+			p.removeLocked(w)
+			dropped = true
+			log.Printf("⚠️  Worker %s dropped after missing heartbeats (%d tasks outstanding)", id, len(w.pending))
+		}
+	}
+	if dropped {
+		p.countChangedLocked()
+	}
+}
+
+// removeLocked removes a worker, failing the tasks delegated to it
+func (p *Pool) removeLocked(w *worker) {
+	delete(p.workers, w.id)
+	close(w.gone)
+}
+
+func (p *Pool) countChangedLocked() {
+	if p.onCount != nil {
+		p.onCount(len(p.workers))
+	}
+}
@@ -0,0 +1,152 @@
+package workerpool
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pangea-net/go-node/pkg/compute"
+)
+
+func TestPoolDelegatesToPullingWorker(t *testing.T) {
+	pool := NewPool(time.Minute, 2)
+	id, slots, err := pool.Register("", compute.ComputeCapacity{CPUCores: 4}, 0)
+	if err != nil || id == "" || slots != 4 {
+		t.Fatalf("register failed: %q %d %v", id, slots, err)
+	}
+	if _, _, err := pool.Register("w2", compute.ComputeCapacity{}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := pool.Register("w3", compute.ComputeCapacity{}, 0); !errors.Is(err, ErrPoolFull) {
+		t.Fatalf("expected ErrPoolFull, got %v", err)
+	}
+
+	done := make(chan *compute.TaskResult, 1)
+	go func() {
+		result, err := pool.DelegateTask(context.Background(), id, &compute.ComputeTask{TaskID: "job:0", InputData: []byte("in")})
+		if err != nil {
+			t.Errorf("delegate failed: %v", err)
+		}
+		done <- result
+	}()
+
+	task, err := pool.NextTask(context.Background(), id, time.Second)
+	if err != nil || task == nil || task.TaskID != "job:0" {
+		t.Fatalf("expected job:0, got %+v %v", task, err)
+	}
+	if pool.Submit("w2", &compute.TaskResult{TaskID: "job:0"}) {
+		t.Fatal("a result from the wrong worker must be rejected")
+	}
+	if !pool.Submit(id, &compute.TaskResult{TaskID: "job:0", Status: compute.TaskCompleted, ResultData: []byte("out")}) {
+		t.Fatal("expected result accepted")
+	}
+	if result := <-done; string(result.ResultData) != "out" || result.WorkerID != id {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+
+	// Nothing queued: the long-poll ends empty
+	if task, err := pool.NextTask(context.Background(), id, 10*time.Millisecond); task != nil || err != nil {
+		t.Fatalf("expected empty poll, got %+v %v", task, err)
+	}
+	if _, err := pool.NextTask(context.Background(), "nobody", 0); !errors.Is(err, ErrUnknownWorker) {
+		t.Fatalf("expected ErrUnknownWorker, got %v", err)
+	}
+}
+
+func TestPoolDropsSilentWorkers(t *testing.T) {
+	pool := NewPool(50*time.Millisecond, 0)
+	var counts []int
+	pool.SetCountCallback(func(n int) { counts = append(counts, n) })
+	id, _, _ := pool.Register("w1", compute.ComputeCapacity{}, 0)
+
+	errc := make(chan error, 1)
+	go func() {
+		_, err := pool.DelegateTask(context.Background(), id, &compute.ComputeTask{TaskID: "job:1"})
+		errc <- err
+	}()
+	if task, _ := pool.NextTask(context.Background(), id, time.Second); task == nil {
+		t.Fatal("expected the delegated task")
+	}
+
+	pool.expire(time.Now().Add(time.Second))
+	if err := <-errc; !errors.Is(err, ErrWorkerGone) {
+		t.Fatalf("expected ErrWorkerGone, got %v", err)
+	}
+	if pool.HasWorkers() || pool.Heartbeat(id, 0) {
+		t.Fatal("expected the silent worker dropped")
+	}
+	if len(counts) != 2 || counts[0] != 1 || counts[1] != 0 {
+		t.Fatalf("unexpected count callbacks: %v", counts)
+	}
+}
+
+func TestPoolSkipsAbandonedTasks(t *testing.T) {
+	pool := NewPool(time.Minute, 0)
+	id, _, _ := pool.Register("w1", compute.ComputeCapacity{}, 0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := pool.DelegateTask(ctx, id, &compute.ComputeTask{TaskID: "job:2"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if task, _ := pool.NextTask(context.Background(), id, 10*time.Millisecond); task != nil {
+		t.Fatalf("abandoned task handed out: %+v", task)
+	}
+}
+
+func TestPoolAccountsTaskSlots(t *testing.T) {
+	pool := NewPool(time.Minute, 1)
+	id, slots, _ := pool.Register("w1", compute.ComputeCapacity{CPUCores: 8}, 1)
+	if slots != 1 {
+		t.Fatalf("expected the requested single slot, got %d", slots)
+	}
+
+	results := make(chan error, 2)
+	for _, taskID := range []string{"job:0", "job:1"} {
+		go func(taskID string) {
+			_, err := pool.DelegateTask(context.Background(), id, &compute.ComputeTask{TaskID: taskID})
+			results <- err
+		}(taskID)
+	}
+	first, err := pool.NextTask(context.Background(), id, time.Second)
+	if err != nil || first == nil {
+		t.Fatalf("expected a task, got %v", err)
+	}
+	// The worker's only slot is taken until it answers
+	if _, err := pool.NextTask(context.Background(), id, 0); !errors.Is(err, ErrNoFreeSlot) {
+		t.Fatalf("expected ErrNoFreeSlot, got %v", err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for w := pool.Workers()[0]; w.Running != 1 || w.Queued != 1; w = pool.Workers()[0] {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected one running and one queued, got %+v", w)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	pool.Submit(id, &compute.TaskResult{TaskID: first.TaskID, Status: compute.TaskCompleted})
+	if err := <-results; err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := pool.NextTask(context.Background(), id, time.Second)
+	if err != nil || second == nil || second.TaskID == first.TaskID {
+		t.Fatalf("expected the queued task, got %+v %v", second, err)
+	}
+	pool.Submit(id, &compute.TaskResult{TaskID: second.TaskID, Status: compute.TaskFailed})
+	<-results
+	if w := pool.Workers()[0]; w.Running != 0 || w.Queued != 0 || w.Completed != 1 || w.Failed != 1 {
+		t.Fatalf("unexpected accounting: %+v", w)
+	}
+
+	// Leaving frees the worker's place under the limit
+	if _, _, err := pool.Register("w2", compute.ComputeCapacity{}, 0); !errors.Is(err, ErrPoolFull) {
+		t.Fatalf("expected ErrPoolFull, got %v", err)
+	}
+	if !pool.Deregister(id) || pool.Deregister(id) {
+		t.Fatal("expected the worker deregistered once")
+	}
+	if _, _, err := pool.Register("w2", compute.ComputeCapacity{}, 0); err != nil {
+		t.Fatalf("expected a free place after the worker left, got %v", err)
+	}
+}
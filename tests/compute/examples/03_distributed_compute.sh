@@ -7,7 +7,7 @@
 # 3. Runs distributed matrix multiplication across the connected nodes
 #
 # To run on the OTHER device:
-#   cd go && ./bin/go-node -node-id=2 -capnp-addr=:8080 -local -test -peers="<multiaddr>"
+#   cd go && ./bin/go-node -node-id=2 -capnp-addr=:8080 -local -test -compute-worker -peers="<multiaddr>"
 
 set -e
 